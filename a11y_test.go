@@ -0,0 +1,352 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+// a11yRule is an accessibility check over a parsed page. Rules are named after the
+// axe-core rules they mirror; axe itself needs a browser, so these run statically
+// against the rendered HTML.
+type a11yRule struct {
+	id       string
+	fullPage bool // Only applies to whole pages, not HTMX fragments
+	check    func(doc *html.Node) []string
+}
+
+var a11yRules = []a11yRule{
+	{"html-has-lang", true, func(doc *html.Node) []string {
+		if el := findElement(doc, "html"); el == nil || attr(el, "lang") == "" {
+			return []string{"<html> has no lang attribute"}
+		}
+		return nil
+	}},
+	{"document-title", true, func(doc *html.Node) []string {
+		if el := findElement(doc, "title"); el == nil || strings.TrimSpace(textContent(el)) == "" {
+			return []string{"page has no title"}
+		}
+		return nil
+	}},
+	{"landmark-one-main", true, func(doc *html.Node) []string {
+		if n := len(findElements(doc, "main")); n != 1 {
+			return []string{fmt.Sprintf("page has %d <main> landmarks, want 1", n)}
+		}
+		return nil
+	}},
+	{"landmark-banner-contentinfo", true, func(doc *html.Node) []string {
+		var problems []string
+		for _, tag := range []string{"header", "nav", "footer"} {
+			if findElement(doc, tag) == nil {
+				problems = append(problems, "page has no <"+tag+">")
+			}
+		}
+		for _, nav := range findElements(doc, "nav") {
+			if !hasAccessibleLabel(nav) {
+				problems = append(problems, "<nav> has no aria-label")
+			}
+		}
+		return problems
+	}},
+	{"bypass", true, func(doc *html.Node) []string {
+		for _, a := range findElements(doc, "a") {
+			if href := attr(a, "href"); strings.HasPrefix(href, "#") && strings.Contains(attr(a, "class"), "skip-link") {
+				if findByID(doc, href[1:]) == nil {
+					return []string{"skip link target " + href + " is missing"}
+				}
+				return nil
+			}
+		}
+		return []string{"page has no skip link"}
+	}},
+	{"htmx-announcer", true, func(doc *html.Node) []string {
+		status := findByID(doc, "a11y-status")
+		if status == nil || attr(status, "role") != "status" || attr(status, "aria-live") != "polite" {
+			return []string{"page has no polite #a11y-status region for HTMX announcements"}
+		}
+		return nil
+	}},
+	{"htmx-region-label", false, func(doc *html.Node) []string {
+		var problems []string
+		for _, el := range allElements(doc) {
+			target := attr(el, "hx-target")
+			if !strings.HasPrefix(target, "#") {
+				continue
+			}
+			region := findByID(doc, target[1:])
+			if region == nil {
+				continue // Rendered by a later swap
+			}
+			labelled := false
+			for n := region; n != nil; n = n.Parent {
+				if n.Type == html.ElementNode && hasAccessibleLabel(n) {
+					labelled = true
+					break
+				}
+			}
+			if !labelled {
+				problems = append(problems, "HTMX target "+target+" is not in a labelled region")
+			}
+		}
+		return problems
+	}},
+	{"image-alt", false, func(doc *html.Node) []string {
+		var problems []string
+		for _, img := range findElements(doc, "img") {
+			if _, ok := attrOK(img, "alt"); !ok {
+				problems = append(problems, "<img src="+attr(img, "src")+"> has no alt attribute")
+			}
+		}
+		return problems
+	}},
+	{"label", false, func(doc *html.Node) []string {
+		labelled := make(map[string]bool)
+		for _, label := range findElements(doc, "label") {
+			if id := attr(label, "for"); id != "" {
+				labelled[id] = true
+			}
+		}
+
+		var problems []string
+		for _, el := range allElements(doc) {
+			switch el.Data {
+			case "input":
+				switch attr(el, "type") {
+				case "hidden", "submit", "button", "reset":
+					continue
+				}
+			case "select", "textarea":
+			default:
+				continue
+			}
+			if hasAccessibleLabel(el) || labelled[attr(el, "id")] || hasAncestor(el, "label") {
+				continue
+			}
+			problems = append(problems, fmt.Sprintf("<%s name=%q> has no label", el.Data, attr(el, "name")))
+		}
+		return problems
+	}},
+	{"button-name", false, func(doc *html.Node) []string {
+		var problems []string
+		for _, button := range findElements(doc, "button") {
+			if !hasAccessibleLabel(button) && strings.TrimSpace(textContent(button)) == "" {
+				problems = append(problems, "<button> has no accessible name")
+			}
+		}
+		return problems
+	}},
+	{"link-name", false, func(doc *html.Node) []string {
+		var problems []string
+		for _, a := range findElements(doc, "a") {
+			if _, ok := attrOK(a, "href"); ok && !hasAccessibleLabel(a) && strings.TrimSpace(textContent(a)) == "" {
+				problems = append(problems, "<a href="+attr(a, "href")+"> has no accessible name")
+			}
+		}
+		return problems
+	}},
+	{"empty-table-header", false, func(doc *html.Node) []string {
+		var problems []string
+		for _, th := range findElements(doc, "th") {
+			if strings.TrimSpace(textContent(th)) == "" {
+				problems = append(problems, "<th> is empty")
+			}
+		}
+		return problems
+	}},
+	{"duplicate-id", false, func(doc *html.Node) []string {
+		seen := make(map[string]bool)
+		var problems []string
+		for _, el := range allElements(doc) {
+			id := attr(el, "id")
+			if id == "" {
+				continue
+			}
+			if seen[id] {
+				problems = append(problems, "duplicate id "+id)
+			}
+			seen[id] = true
+		}
+		return problems
+	}},
+}
+
+func TestAccessibility(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	// Seed rows so tables with action buttons render
+	if _, err := db.SaveSavedSearch("Bay Area", SearchFilters{Query: "Lincoln"}, true); err != nil {
+		t.Fatal(err)
+	}
+	alert := NAEPAlert{NCESSCH: "360000100001", SchoolName: "Lincoln Elementary School", Jurisdiction: "California", Subject: "mathematics", Grade: 4, PreviousYear: 2019, CurrentYear: 2022, PreviousScore: 235, CurrentScore: 230, Change: -5}
+	if err := db.SaveNAEPAlert(alert); err != nil {
+		t.Fatal(err)
+	}
+
+	// County and congressional district pages need a geocoded school
+	geocodes := "NCESSCH,NAME,CBSA,NMCBSA,CNTY,NMCNTY,CD\n" +
+		"360000100001,Lincoln Elementary School,41860,\"San Francisco-Oakland-Berkeley, CA\",06075,San Francisco County,0611\n"
+	if err := os.WriteFile(filepath.Join(db.dataDir, "EDGE_GEOCODE_PUBLICSCH_2324.csv"), []byte(geocodes), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SyncGeocodes(db); err != nil {
+		t.Fatal(err)
+	}
+
+	router := NewRouter(ServerConfig{DB: db})
+
+	pages := []struct {
+		method string
+		path   string
+		form   url.Values
+		page   bool
+	}{
+		{"GET", "/", nil, true},
+		{"GET", "/schools/360000100001", nil, true},
+		{"GET", "/districts/0600000", nil, true},
+		{"GET", "/area/94102", nil, true},
+		{"GET", "/area/county", nil, true},
+		{"GET", "/neighborhood", nil, true},
+		{"GET", "/schools/360000100001/inquiry", nil, false},
+		{"GET", "/compare?ids=360000100001,360000100002", nil, true},
+		{"GET", "/languages?courses=1", nil, true},
+		{"GET", "/schools/360000100004/matched?area=state&any_level=1", nil, true},
+		{"GET", "/saved-searches", nil, true},
+		{"GET", "/stats", nil, true},
+		{"GET", "/alerts", nil, true},
+		{"GET", "/agent", nil, true},
+		{"GET", "/import", nil, true},
+		{"GET", "/docs/schema", nil, true},
+		{"GET", "/applications", nil, true},
+		{"GET", "/calendars", nil, true},
+		{"GET", "/children", nil, true},
+		{"GET", "/duplicates", nil, true},
+		{"GET", "/outreach", nil, true},
+		{"GET", "/pipeline", nil, true},
+		{"GET", "/area/county/06075", nil, true},
+		{"GET", "/area/cd", nil, true},
+		{"GET", "/area/cd/CA-11", nil, true},
+		{"GET", "/admin/corrections", nil, true},
+		{"GET", "/admin/users", nil, true},
+		{"GET", "/admin/cache", nil, true},
+		{"POST", "/search", url.Values{"query": {"school"}}, false},
+	}
+
+	for _, p := range pages {
+		t.Run(p.method+" "+p.path, func(t *testing.T) {
+			req := httptest.NewRequest(p.method, p.path, strings.NewReader(p.form.Encode()))
+			if p.form != nil {
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, body = %s", rec.Code, rec.Body.String())
+			}
+
+			doc, err := html.Parse(rec.Body)
+			if err != nil {
+				t.Fatalf("failed to parse HTML: %v", err)
+			}
+			for _, rule := range a11yRules {
+				if rule.fullPage && !p.page {
+					continue
+				}
+				for _, problem := range rule.check(doc) {
+					t.Errorf("%s: %s", rule.id, problem)
+				}
+			}
+		})
+	}
+}
+
+// allElements returns the element nodes under n in document order
+func allElements(n *html.Node) []*html.Node {
+	var elements []*html.Node
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			elements = append(elements, n)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return elements
+}
+
+func findElements(n *html.Node, tag string) []*html.Node {
+	var matches []*html.Node
+	for _, el := range allElements(n) {
+		if el.Data == tag {
+			matches = append(matches, el)
+		}
+	}
+	return matches
+}
+
+func findElement(n *html.Node, tag string) *html.Node {
+	if matches := findElements(n, tag); len(matches) > 0 {
+		return matches[0]
+	}
+	return nil
+}
+
+func findByID(n *html.Node, id string) *html.Node {
+	for _, el := range allElements(n) {
+		if attr(el, "id") == id {
+			return el
+		}
+	}
+	return nil
+}
+
+func attrOK(n *html.Node, key string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+func attr(n *html.Node, key string) string {
+	val, _ := attrOK(n, key)
+	return val
+}
+
+func hasAccessibleLabel(n *html.Node) bool {
+	return strings.TrimSpace(attr(n, "aria-label")) != "" || attr(n, "aria-labelledby") != ""
+}
+
+func hasAncestor(n *html.Node, tag string) bool {
+	for p := n.Parent; p != nil; p = p.Parent {
+		if p.Type == html.ElementNode && p.Data == tag {
+			return true
+		}
+	}
+	return false
+}
+
+func textContent(n *html.Node) string {
+	var b strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return b.String()
+}
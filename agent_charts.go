@@ -0,0 +1,308 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Agent chart types
+const (
+	ChartBar  = "bar"
+	ChartLine = "line"
+	ChartNone = "none"
+)
+
+// Agent charts stay readable up to this many categories and series
+const (
+	maxChartRows   = 50
+	maxChartSeries = 3
+)
+
+// Line chart drawing area, in SVG user units
+const (
+	lineChartWidth  = 600
+	lineChartHeight = 220
+	lineChartPad    = 10
+)
+
+// chartHintPattern matches the agent's chart hint, a fenced block such as
+//
+//	```chart
+//	{"type": "bar", "x": "ST", "y": ["avg_enrollment"], "title": "Average enrollment by state"}
+//	```
+var chartHintPattern = regexp.MustCompile("(?s)```chart\\s*\\n(.*?)```\\s*")
+
+// AgentChartHint is the agent's choice of chart for its query results
+type AgentChartHint struct {
+	Type  string   `json:"type"`  // ChartBar, ChartLine, or ChartNone
+	X     string   `json:"x"`     // Column with the categories or years
+	Y     []string `json:"y"`     // Numeric columns to plot
+	Title string   `json:"title"` // Optional chart title
+}
+
+// extractChartHint removes the chart hint block from the agent's response and
+// returns it with the remaining text. A missing or unreadable hint returns nil.
+func extractChartHint(text string) (*AgentChartHint, string) {
+	match := chartHintPattern.FindStringSubmatch(text)
+	if match == nil {
+		return nil, text
+	}
+	text = strings.TrimSpace(strings.Replace(text, match[0], "", 1))
+
+	var hint AgentChartHint
+	if err := json.Unmarshal([]byte(match[1]), &hint); err != nil {
+		if logger != nil {
+			logger.Warn("Ignoring unreadable chart hint", "error", err)
+		}
+		return nil, text
+	}
+	hint.Type = strings.ToLower(strings.TrimSpace(hint.Type))
+	return &hint, text
+}
+
+// AgentChartSeries is one plotted column
+type AgentChartSeries struct {
+	Name   string
+	Values []float64
+	Index  int // Position among the chart's series, for colors
+}
+
+// AgentChart is a bar or line chart of aggregated agent query results
+type AgentChart struct {
+	Type   string
+	Title  string
+	XLabel string
+	Labels []string
+	Series []AgentChartSeries
+	Min    float64
+	Max    float64
+}
+
+// AgentChartBarValue is one series' bar in a bar chart row
+type AgentChartBarValue struct {
+	Value   float64
+	Percent float64 // Bar length as a percentage of the largest value
+	Index   int
+}
+
+// Display formats the bar's value
+func (b AgentChartBarValue) Display() string {
+	return formatChartValue(b.Value)
+}
+
+// AgentChartBarRow is a category in a bar chart
+type AgentChartBarRow struct {
+	Label  string
+	Values []AgentChartBarValue
+}
+
+// Rows lays out a bar chart, one row per category. Bars start at zero, so
+// negative values draw as empty bars with their value still shown.
+func (c *AgentChart) Rows() []AgentChartBarRow {
+	rows := make([]AgentChartBarRow, len(c.Labels))
+	for i, label := range c.Labels {
+		rows[i].Label = label
+		for _, s := range c.Series {
+			pct := 0.0
+			if c.Max > 0 && s.Values[i] > 0 {
+				pct = s.Values[i] / c.Max * 100
+			}
+			rows[i].Values = append(rows[i].Values, AgentChartBarValue{Value: s.Values[i], Percent: pct, Index: s.Index})
+		}
+	}
+	return rows
+}
+
+// ViewBox is the line chart's SVG viewBox
+func (c *AgentChart) ViewBox() string {
+	return fmt.Sprintf("0 0 %d %d", lineChartWidth, lineChartHeight)
+}
+
+// Points returns a series' SVG polyline points for a line chart, scaled between
+// the chart's Min and Max
+func (c *AgentChart) Points(s AgentChartSeries) string {
+	width := float64(lineChartWidth - 2*lineChartPad)
+	height := float64(lineChartHeight - 2*lineChartPad)
+	span := c.Max - c.Min
+	if span == 0 {
+		span = 1
+	}
+
+	points := make([]string, len(s.Values))
+	for i, v := range s.Values {
+		x := float64(lineChartPad)
+		if len(s.Values) > 1 {
+			x += width * float64(i) / float64(len(s.Values)-1)
+		}
+		y := float64(lineChartPad) + height*(1-(v-c.Min)/span)
+		points[i] = fmt.Sprintf("%.1f,%.1f", x, y)
+	}
+	return strings.Join(points, " ")
+}
+
+// MinLabel and MaxLabel label the line chart's vertical axis
+func (c *AgentChart) MinLabel() string { return formatChartValue(c.Min) }
+func (c *AgentChart) MaxLabel() string { return formatChartValue(c.Max) }
+
+// FirstLabel and LastLabel label the ends of the line chart's horizontal axis
+func (c *AgentChart) FirstLabel() string { return c.Labels[0] }
+func (c *AgentChart) LastLabel() string  { return c.Labels[len(c.Labels)-1] }
+
+// Description summarizes the chart for screen readers
+func (c *AgentChart) Description() string {
+	var names []string
+	for _, s := range c.Series {
+		names = append(names, s.Name)
+	}
+	kind := "Bar chart"
+	if c.Type == ChartLine {
+		kind = "Line chart"
+	}
+	return fmt.Sprintf("%s of %s by %s across %d values; the table below has the figures", kind, strings.Join(names, ", "), c.XLabel, len(c.Labels))
+}
+
+// formatChartValue formats a plotted value compactly, e.g. 1234.5678 as "1,234.6"
+func formatChartValue(v float64) string {
+	s := strconv.FormatFloat(math.Abs(v), 'f', 1, 64)
+	s = strings.TrimSuffix(s, ".0")
+	whole, frac, _ := strings.Cut(s, ".")
+	for i := len(whole) - 3; i > 0; i -= 3 {
+		whole = whole[:i] + "," + whole[i:]
+	}
+	if frac != "" {
+		whole += "." + frac
+	}
+	if v < 0 {
+		return "-" + whole
+	}
+	return whole
+}
+
+// buildAgentChart charts query results using the agent's hint, or by picking a
+// category column and numeric columns when there is no hint. It returns nil when
+// the results aren't aggregated data worth charting or the hint says not to chart.
+func buildAgentChart(hint *AgentChartHint, columns []string, rows []map[string]interface{}) *AgentChart {
+	if len(rows) < 2 || len(rows) > maxChartRows {
+		return nil
+	}
+	if hint == nil {
+		hint = inferChartHint(columns, rows)
+		if hint == nil {
+			return nil
+		}
+	}
+	if hint.Type != ChartBar && hint.Type != ChartLine {
+		return nil
+	}
+
+	known := make(map[string]bool, len(columns))
+	for _, c := range columns {
+		known[c] = true
+	}
+	if !known[hint.X] {
+		return nil
+	}
+
+	chart := &AgentChart{Type: hint.Type, Title: hint.Title, XLabel: hint.X, Min: math.Inf(1), Max: math.Inf(-1)}
+	for _, row := range rows {
+		chart.Labels = append(chart.Labels, csvValue(row[hint.X]))
+	}
+	for _, col := range hint.Y {
+		if !known[col] || col == hint.X || len(chart.Series) == maxChartSeries {
+			continue
+		}
+		series := AgentChartSeries{Name: col, Index: len(chart.Series)}
+		for _, row := range rows {
+			v, ok := numericValue(row[col])
+			if !ok {
+				series.Values = nil
+				break
+			}
+			series.Values = append(series.Values, v)
+		}
+		if series.Values == nil {
+			continue
+		}
+		for _, v := range series.Values {
+			chart.Min = math.Min(chart.Min, v)
+			chart.Max = math.Max(chart.Max, v)
+		}
+		chart.Series = append(chart.Series, series)
+	}
+	if len(chart.Series) == 0 {
+		return nil
+	}
+	return chart
+}
+
+// inferChartHint picks a chart for results without a hint: a bar chart of numeric
+// columns by the one text column, or a line chart by a year column. Row-level
+// results, such as a list of schools, aren't charted.
+func inferChartHint(columns []string, rows []map[string]interface{}) *AgentChartHint {
+	var labels, numbers []string
+	yearColumn := ""
+	for _, col := range columns {
+		lower := strings.ToLower(col)
+		if lower == "ncessch" || lower == "leaid" || strings.HasSuffix(lower, "_id") {
+			return nil
+		}
+		if strings.Contains(lower, "year") {
+			yearColumn = col
+			continue
+		}
+		if _, ok := numericValue(rows[0][col]); ok {
+			numbers = append(numbers, col)
+		} else {
+			labels = append(labels, col)
+		}
+	}
+
+	switch {
+	case len(numbers) == 0:
+		return nil
+	case yearColumn != "" && len(labels) == 0:
+		return &AgentChartHint{Type: ChartLine, X: yearColumn, Y: numbers}
+	case len(labels) == 1 && yearColumn == "":
+		return &AgentChartHint{Type: ChartBar, X: labels[0], Y: numbers}
+	}
+	return nil
+}
+
+// numericValue converts a scanned numeric database value to float64
+func numericValue(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case *big.Int:
+		f, _ := new(big.Float).SetInt(v).Float64()
+		return f, true
+	case interface{ Float64() float64 }: // DuckDB DECIMAL
+		return v.Float64(), true
+	}
+	return 0, false
+}
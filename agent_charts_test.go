@@ -0,0 +1,157 @@
+package main
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestExtractChartHint(t *testing.T) {
+	text := "Texas has the largest schools.\n\n```chart\n{\"type\": \"Bar\", \"x\": \"ST\", \"y\": [\"avg_enrollment\"], \"title\": \"Average enrollment\"}\n```\n"
+	hint, rest := extractChartHint(text)
+	if hint == nil {
+		t.Fatal("expected a chart hint")
+	}
+	if hint.Type != ChartBar || hint.X != "ST" || len(hint.Y) != 1 || hint.Y[0] != "avg_enrollment" || hint.Title != "Average enrollment" {
+		t.Errorf("hint = %+v", hint)
+	}
+	if rest != "Texas has the largest schools." {
+		t.Errorf("remaining text = %q", rest)
+	}
+
+	hint, rest = extractChartHint("Summary\n```chart\n{not json}\n```")
+	if hint != nil || rest != "Summary" {
+		t.Errorf("unreadable hint: got %+v, %q; want nil, %q", hint, rest, "Summary")
+	}
+
+	hint, rest = extractChartHint("No chart here")
+	if hint != nil || rest != "No chart here" {
+		t.Errorf("no hint: got %+v, %q", hint, rest)
+	}
+}
+
+func TestBuildAgentChart(t *testing.T) {
+	byState := []map[string]interface{}{
+		{"ST": "CA", "avg_enrollment": 500.0, "schools": int64(2)},
+		{"ST": "TX", "avg_enrollment": 1000.0, "schools": int64(1)},
+	}
+	byYear := []map[string]interface{}{
+		{"school_year": int32(2021), "students": int64(900)},
+		{"school_year": int32(2022), "students": int64(1000)},
+		{"school_year": int32(2023), "students": int64(1100)},
+	}
+	schools := []map[string]interface{}{
+		{"NCESSCH": "360000100001", "SCH_NAME": "Lincoln", "enrollment": int64(500)},
+		{"NCESSCH": "360000100002", "SCH_NAME": "Washington", "enrollment": int64(300)},
+	}
+	tooMany := make([]map[string]interface{}, maxChartRows+1)
+	for i := range tooMany {
+		tooMany[i] = map[string]interface{}{"ST": "CA", "n": int64(i)}
+	}
+
+	tests := []struct {
+		name       string
+		hint       *AgentChartHint
+		columns    []string
+		rows       []map[string]interface{}
+		wantType   string // Empty for no chart
+		wantSeries int
+	}{
+		{"hinted bar", &AgentChartHint{Type: ChartBar, X: "ST", Y: []string{"avg_enrollment"}}, []string{"ST", "avg_enrollment", "schools"}, byState, ChartBar, 1},
+		{"inferred bar", nil, []string{"ST", "avg_enrollment", "schools"}, byState, ChartBar, 2},
+		{"inferred line", nil, []string{"school_year", "students"}, byYear, ChartLine, 1},
+		{"hint says none", &AgentChartHint{Type: ChartNone}, []string{"ST", "avg_enrollment"}, byState, "", 0},
+		{"unknown x column", &AgentChartHint{Type: ChartBar, X: "STATE", Y: []string{"avg_enrollment"}}, []string{"ST", "avg_enrollment"}, byState, "", 0},
+		{"text y column", &AgentChartHint{Type: ChartBar, X: "avg_enrollment", Y: []string{"ST"}}, []string{"ST", "avg_enrollment"}, byState, "", 0},
+		{"school list", nil, []string{"NCESSCH", "SCH_NAME", "enrollment"}, schools, "", 0},
+		{"too many rows", nil, []string{"ST", "n"}, tooMany, "", 0},
+		{"single row", nil, []string{"ST", "avg_enrollment"}, byState[:1], "", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chart := buildAgentChart(tt.hint, tt.columns, tt.rows)
+			if tt.wantType == "" {
+				if chart != nil {
+					t.Fatalf("expected no chart, got %+v", chart)
+				}
+				return
+			}
+			if chart == nil {
+				t.Fatal("expected a chart")
+			}
+			if chart.Type != tt.wantType || len(chart.Series) != tt.wantSeries {
+				t.Errorf("chart type = %q with %d series, want %q with %d", chart.Type, len(chart.Series), tt.wantType, tt.wantSeries)
+			}
+			if len(chart.Labels) != len(tt.rows) {
+				t.Errorf("got %d labels, want %d", len(chart.Labels), len(tt.rows))
+			}
+		})
+	}
+}
+
+func TestAgentChartLayout(t *testing.T) {
+	chart := &AgentChart{
+		Type:   ChartBar,
+		Labels: []string{"CA", "TX"},
+		Series: []AgentChartSeries{{Name: "students", Values: []float64{500, 1000}}},
+		Min:    500,
+		Max:    1000,
+	}
+	rows := chart.Rows()
+	if len(rows) != 2 || rows[0].Label != "CA" {
+		t.Fatalf("rows = %+v", rows)
+	}
+	if got := rows[0].Values[0].Percent; got != 50 {
+		t.Errorf("CA bar = %v%%, want 50%%", got)
+	}
+	if got := rows[1].Values[0].Display(); got != "1,000" {
+		t.Errorf("TX value = %q, want %q", got, "1,000")
+	}
+
+	chart.Type = ChartLine
+	if got, want := chart.Points(chart.Series[0]), "10.0,210.0 590.0,10.0"; got != want {
+		t.Errorf("Points = %q, want %q", got, want)
+	}
+}
+
+func TestFormatChartValue(t *testing.T) {
+	tests := []struct {
+		value float64
+		want  string
+	}{
+		{0, "0"},
+		{999, "999"},
+		{1234.5678, "1,234.6"},
+		{1234567, "1,234,567"},
+		{-4500.25, "-4,500.2"},
+		{0.15, "0.1"},
+	}
+
+	for _, tt := range tests {
+		if got := formatChartValue(tt.value); got != tt.want {
+			t.Errorf("formatChartValue(%v) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestNumericValue(t *testing.T) {
+	tests := []struct {
+		value  interface{}
+		want   float64
+		wantOK bool
+	}{
+		{int64(42), 42, true},
+		{int32(7), 7, true},
+		{3.5, 3.5, true},
+		{big.NewInt(123456789), 123456789, true},
+		{"42", 0, false},
+		{nil, 0, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := numericValue(tt.value)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("numericValue(%#v) = %v, %v; want %v, %v", tt.value, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Agent result exports are kept in memory, so a download link works until the
+// server restarts, the TTL passes, or newer exports push it out
+const (
+	maxAgentExports = 200
+	agentExportTTL  = 24 * time.Hour
+)
+
+// agentExport is a data explorer question and the SQL the agent ran to answer it.
+// The SQL stays on the server; download links only carry the export's ID.
+type agentExport struct {
+	Query string
+	SQL   string
+}
+
+// newAgentExportID returns a random ID for an agent export
+func newAgentExportID() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate export ID: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Filename names the CSV download after the question, e.g.
+// "average-enrollment-by-state.csv"
+func (e agentExport) Filename() string {
+	var words []string
+	for _, word := range strings.FieldsFunc(strings.ToLower(e.Query), func(r rune) bool {
+		return (r < 'a' || r > 'z') && (r < '0' || r > '9')
+	}) {
+		words = append(words, word)
+		if len(words) == 8 {
+			break
+		}
+	}
+	if len(words) == 0 {
+		return "query-results.csv"
+	}
+	return strings.Join(words, "-") + ".csv"
+}
+
+// WriteQueryCSV runs query and streams every row to w as CSV, with a header row of
+// the query's column names in their SELECT order. It returns the number of data rows
+// written. An error before anything is written means the query itself failed.
+func (d *DB) WriteQueryCSV(w io.Writer, query string) (int, error) {
+	rows, err := d.conn.Query(query)
+	if err != nil {
+		return 0, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+	return writeCSVRows(w, rows)
+}
+
+// WriteReadQueryCSV is WriteQueryCSV for SQL the Data Explorer wrote, run with
+// readQuery so a download can't change anything or read files
+func (d *DB) WriteReadQueryCSV(ctx context.Context, w io.Writer, query string) (int, error) {
+	var count int
+	err := d.readQuery(ctx, query, func(rows *sql.Rows) error {
+		var err error
+		count, err = writeCSVRows(w, rows)
+		return err
+	})
+	return count, err
+}
+
+// writeCSVRows streams rows to w as CSV after a header row of their column names
+func writeCSVRows(w io.Writer, rows *sql.Rows) (int, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get column names: %w", err)
+	}
+
+	out := csv.NewWriter(w)
+	if err := out.Write(columns); err != nil {
+		return 0, fmt.Errorf("failed to write CSV header: %w", err)
+	}
+
+	values := make([]interface{}, len(columns))
+	valuePtrs := make([]interface{}, len(columns))
+	for i := range values {
+		valuePtrs[i] = &values[i]
+	}
+	record := make([]string, len(columns))
+
+	count := 0
+	for rows.Next() {
+		if err := rows.Scan(valuePtrs...); err != nil {
+			return count, fmt.Errorf("failed to scan row: %w", err)
+		}
+		for i, v := range values {
+			record[i] = csvValue(v)
+		}
+		if err := out.Write(record); err != nil {
+			return count, fmt.Errorf("failed to write CSV row: %w", err)
+		}
+		count++
+
+		// Flush as we go so large results stream instead of buffering
+		if count%1000 == 0 {
+			out.Flush()
+			if err := out.Error(); err != nil {
+				return count, fmt.Errorf("failed to write CSV: %w", err)
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return count, err
+	}
+
+	out.Flush()
+	if err := out.Error(); err != nil {
+		return count, fmt.Errorf("failed to write CSV: %w", err)
+	}
+	return count, nil
+}
+
+// csvValue formats a scanned database value for a CSV cell. NULL is an empty cell.
+func csvValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return string(v)
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	case time.Time:
+		if v.Hour() == 0 && v.Minute() == 0 && v.Second() == 0 && v.Nanosecond() == 0 {
+			return v.Format("2006-01-02")
+		}
+		return v.Format(time.RFC3339)
+	default:
+		return fmt.Sprint(v)
+	}
+}
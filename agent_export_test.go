@@ -0,0 +1,113 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestAgentExportFilename(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"What is the average enrollment by state?", "what-is-the-average-enrollment-by-state.csv"},
+		{"Top 20 schools in CA, by student/teacher ratio (lowest first)", "top-20-schools-in-ca-by-student-teacher.csv"},
+		{"???", "query-results.csv"},
+	}
+
+	for _, tt := range tests {
+		if got := (agentExport{Query: tt.query}).Filename(); got != tt.want {
+			t.Errorf("Filename(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestCSVValue(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  string
+	}{
+		{nil, ""},
+		{"Lincoln", "Lincoln"},
+		{[]byte("bytes"), "bytes"},
+		{int64(500), "500"},
+		{19.607843137254903, "19.607843137254903"},
+		{true, "true"},
+		{time.Date(2024, 7, 31, 0, 0, 0, 0, time.UTC), "2024-07-31"},
+		{time.Date(2024, 7, 31, 9, 30, 0, 0, time.UTC), "2024-07-31T09:30:00Z"},
+	}
+
+	for _, tt := range tests {
+		if got := csvValue(tt.value); got != tt.want {
+			t.Errorf("csvValue(%#v) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestAgentExportCSV(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	h := NewWebHandler(db, nil, nil)
+	r := chi.NewRouter()
+	r.Get("/agent/export/{id}", h.AgentExportCSV)
+
+	h.agentExports.Put("schools", agentExport{
+		Query: "Schools in CA",
+		SQL:   "SELECT NCESSCH, SCH_NAME || ', ' || MCITY AS name, NULL AS missing FROM directory WHERE ST = 'CA' ORDER BY NCESSCH",
+	})
+	h.agentExports.Put("broken", agentExport{Query: "Broken", SQL: "SELECT * FROM no_such_table"})
+	h.agentExports.Put("write", agentExport{Query: "Write", SQL: "DELETE FROM directory"})
+
+	t.Run("streams every row", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest("GET", "/agent/export/schools", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, body = %s", rec.Code, rec.Body.String())
+		}
+		if got := rec.Header().Get("Content-Disposition"); got != `attachment; filename="schools-in-ca.csv"` {
+			t.Errorf("Content-Disposition = %q", got)
+		}
+		want := "NCESSCH,name,missing\n" +
+			"360000100001,\"Lincoln Elementary School, San Francisco\",\n" +
+			"360000100002,\"Washington High School, Los Angeles\",\n"
+		if rec.Body.String() != want {
+			t.Errorf("body = %q, want %q", rec.Body.String(), want)
+		}
+	})
+
+	t.Run("unknown export", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest("GET", "/agent/export/expired", nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("status = %d, want 404", rec.Code)
+		}
+	})
+
+	t.Run("failing query", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest("GET", "/agent/export/broken", nil))
+		if rec.Code != http.StatusInternalServerError || rec.Header().Get("Content-Disposition") != "" {
+			t.Errorf("status = %d, Content-Disposition = %q", rec.Code, rec.Header().Get("Content-Disposition"))
+		}
+		if strings.Contains(rec.Body.String(), "no_such_table") {
+			t.Errorf("error body leaks SQL details: %s", rec.Body.String())
+		}
+	})
+
+	t.Run("only reads", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest("GET", "/agent/export/write", nil))
+		if rec.Code == http.StatusOK {
+			t.Errorf("status = %d", rec.Code)
+		}
+		if rows, err := db.ExecuteQuery("SELECT 1 FROM directory LIMIT 1"); err != nil || len(rows) == 0 {
+			t.Errorf("export deleted schools: %v, %v", rows, err)
+		}
+	})
+}
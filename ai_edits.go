@@ -0,0 +1,379 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Where a manual edit of website data was made
+const (
+	aiEditSourceWeb    = "web"    // The detail page's edit form
+	aiEditSourceEditor = "editor" // The TUI's $EDITOR (Ctrl+E)
+)
+
+// Limits on hand-entered website data
+const (
+	maxEditTextLength   = 200 // Characters in a name, title, or list item
+	maxEditListItems    = 100
+	maxEditContacts     = 200
+	blankContactRows    = 2  // Empty staff contact rows offered for new contacts
+	maxAIEditHistory    = 20 // Manual edits shown on the detail page
+	maxEditHistoryValue = 500
+)
+
+// phonePattern matches phone numbers such as "(555) 123-4567", "+1 555.123.4567",
+// and "555-123-4567 ext. 12"
+var phonePattern = regexp.MustCompile(`^\+?[0-9()\-.\s]+(\s*(x|ext\.?)\s*[0-9]{1,6})?$`)
+
+// aiEditFieldLabels names the structured website data fields for the edit history
+var aiEditFieldLabels = map[string]string{
+	"principal":           "Principal",
+	"vice_principals":     "Vice principals",
+	"mascot":              "Mascot",
+	"school_colors":       "School colors",
+	"founded":             "Founded",
+	"staff_contacts":      "Staff contacts",
+	"main_office_email":   "Main office email",
+	"main_office_phone":   "Main office phone",
+	"ap_courses":          "AP courses",
+	"honors":              "Honors courses",
+	"special_programs":    "Special programs",
+	"languages":           "Languages",
+	"language_programs":   "Languages (normalized)",
+	"cte_pathways":        "CTE pathways",
+	"dual_enrollment":     "Dual enrollment",
+	"sports":              "Sports",
+	"athletics":           "Athletics",
+	"athletic_conference": "Athletic conference",
+	"clubs":               "Clubs",
+	"arts":                "Arts programs",
+	"arts_programs":       "Arts programs (normalized)",
+	"facilities":          "Facilities",
+	"bell_schedule":       "Bell schedule",
+	"school_hours":        "School hours",
+	"before_care":         "Before care",
+	"after_care":          "After care",
+	"achievements":        "Achievements",
+	"accreditations":      "Accreditations",
+	"mission":             "Mission",
+	"notes":               "Notes",
+}
+
+// AIDataEdit records one field of a school's website data changed by hand
+type AIDataEdit struct {
+	ID       int64     `json:"id"`
+	NCESSCH  string    `json:"ncessch"`
+	Field    string    `json:"field"` // JSON name of the EnhancedSchoolData field
+	OldValue string    `json:"old_value"`
+	NewValue string    `json:"new_value"`
+	Source   string    `json:"source"` // aiEditSourceWeb or aiEditSourceEditor
+	EditedAt time.Time `json:"edited_at"`
+}
+
+// Label names the edited field for display
+func (e AIDataEdit) Label() string {
+	if label, ok := aiEditFieldLabels[e.Field]; ok {
+		return label
+	}
+	return e.Field
+}
+
+// diffAIData lists the structured fields that differ between before and after
+func diffAIData(before, after *EnhancedSchoolData, source string) []AIDataEdit {
+	old, updated := before.structuredFields(), after.structuredFields()
+
+	fields := make([]string, 0, len(updated))
+	for field := range updated {
+		fields = append(fields, field)
+	}
+	slices.Sort(fields)
+
+	var edits []AIDataEdit
+	for _, field := range fields {
+		oldText, newText := editValueText(old[field]), editValueText(updated[field])
+		if oldText == newText {
+			continue
+		}
+		edits = append(edits, AIDataEdit{
+			NCESSCH:  after.NCESSCH,
+			Field:    field,
+			OldValue: oldText,
+			NewValue: newText,
+			Source:   source,
+		})
+	}
+	return edits
+}
+
+// editValueText formats a structured field's value for the edit history, truncated
+// so long lists don't bloat it
+func editValueText(v interface{}) string {
+	var text string
+	switch v := v.(type) {
+	case string:
+		text = v
+	case []string:
+		text = strings.Join(v, "; ")
+	case []StaffContact:
+		contacts := make([]string, len(v))
+		for i, c := range v {
+			contacts[i] = c.summary()
+		}
+		text = strings.Join(contacts, "; ")
+	default:
+		b, _ := json.Marshal(v)
+		text = string(b)
+	}
+	if runes := []rune(text); len(runes) > maxEditHistoryValue {
+		text = string(runes[:maxEditHistoryValue]) + "…"
+	}
+	return text
+}
+
+// summary formats a staff contact on one line, e.g. "Jane Doe (Counselor, jdoe@example.org)"
+func (c StaffContact) summary() string {
+	var details []string
+	for _, d := range []string{c.Title, c.Department, c.Email, c.Phone} {
+		if d != "" {
+			details = append(details, d)
+		}
+	}
+	if len(details) == 0 {
+		return c.Name
+	}
+	return fmt.Sprintf("%s (%s)", c.Name, strings.Join(details, ", "))
+}
+
+// saveManualEdits saves hand-edited website data and records each changed
+// structured field in the school's edit history, returning the recorded edits.
+// Edits last until the website is re-extracted.
+func saveManualEdits(db *DB, before, after *EnhancedSchoolData, source string) ([]AIDataEdit, error) {
+	if err := saveEnhancedData(db, after); err != nil {
+		return nil, err
+	}
+
+	edits := diffAIData(before, after, source)
+	if len(edits) == 0 {
+		return nil, nil
+	}
+	if err := db.SaveAIDataEdits(edits); err != nil {
+		return nil, err
+	}
+
+	if logger != nil {
+		logger.Info("Saved manual website data edits", "ncessch", after.NCESSCH, "fields", len(edits), "source", source)
+	}
+	return edits, nil
+}
+
+// AIDataEditForm holds the web edit form's values as typed, with any validation
+// errors keyed by field name. Lists are edited one item per line.
+type AIDataEditForm struct {
+	Principal       string
+	VicePrincipals  string
+	MainOfficeEmail string
+	MainOfficePhone string
+	Contacts        []StaffContact
+	APCourses       string
+	Honors          string
+	SpecialPrograms string
+	Languages       string
+	Errors          map[string]string
+}
+
+// newAIDataEditForm fills the edit form from saved website data
+func newAIDataEditForm(data *EnhancedSchoolData) *AIDataEditForm {
+	form := &AIDataEditForm{
+		Principal:       data.Principal,
+		VicePrincipals:  strings.Join(data.VicePrincipals, "\n"),
+		MainOfficeEmail: data.MainOfficeEmail,
+		MainOfficePhone: data.MainOfficePhone,
+		Contacts:        slices.Clone(data.StaffContacts),
+		APCourses:       strings.Join(data.APCourses, "\n"),
+		Honors:          strings.Join(data.Honors, "\n"),
+		SpecialPrograms: strings.Join(data.SpecialPrograms, "\n"),
+		Languages:       strings.Join(data.Languages, "\n"),
+	}
+	for i := 0; i < blankContactRows; i++ {
+		form.Contacts = append(form.Contacts, StaffContact{})
+	}
+	return form
+}
+
+// parseAIDataEditForm reads the edit form from a submission. Staff contact fields
+// repeat once per row, in order.
+func parseAIDataEditForm(values url.Values) *AIDataEditForm {
+	form := &AIDataEditForm{
+		Principal:       strings.TrimSpace(values.Get("principal")),
+		VicePrincipals:  values.Get("vice_principals"),
+		MainOfficeEmail: strings.TrimSpace(values.Get("main_office_email")),
+		MainOfficePhone: strings.TrimSpace(values.Get("main_office_phone")),
+		APCourses:       values.Get("ap_courses"),
+		Honors:          values.Get("honors"),
+		SpecialPrograms: values.Get("special_programs"),
+		Languages:       values.Get("languages"),
+	}
+
+	names := values["contact_name"]
+	column := func(key string, i int) string {
+		if col := values[key]; i < len(col) {
+			return strings.TrimSpace(col[i])
+		}
+		return ""
+	}
+	for i := range names {
+		form.Contacts = append(form.Contacts, StaffContact{
+			Name:       column("contact_name", i),
+			Title:      column("contact_title", i),
+			Email:      column("contact_email", i),
+			Phone:      column("contact_phone", i),
+			Department: column("contact_department", i),
+		})
+	}
+	return form
+}
+
+// Validate checks the form, filling Errors. It reports whether the form is valid.
+func (f *AIDataEditForm) Validate() bool {
+	f.Errors = make(map[string]string)
+
+	if len(f.Principal) > maxEditTextLength {
+		f.Errors["principal"] = fmt.Sprintf("Keep the principal's name under %d characters.", maxEditTextLength)
+	}
+	if err := validateEditEmail(f.MainOfficeEmail); err != "" {
+		f.Errors["main_office_email"] = err
+	}
+	if err := validateEditPhone(f.MainOfficePhone); err != "" {
+		f.Errors["main_office_phone"] = err
+	}
+	for field, text := range map[string]string{
+		"vice_principals":  f.VicePrincipals,
+		"ap_courses":       f.APCourses,
+		"honors":           f.Honors,
+		"special_programs": f.SpecialPrograms,
+		"languages":        f.Languages,
+	} {
+		items := editListItems(text)
+		if len(items) > maxEditListItems {
+			f.Errors[field] = fmt.Sprintf("List at most %d items.", maxEditListItems)
+			continue
+		}
+		for _, item := range items {
+			if len(item) > maxEditTextLength {
+				f.Errors[field] = fmt.Sprintf("Keep each line under %d characters.", maxEditTextLength)
+				break
+			}
+		}
+	}
+
+	contacts := f.staffContacts()
+	if len(contacts) > maxEditContacts {
+		f.Errors["contacts"] = fmt.Sprintf("List at most %d staff contacts.", maxEditContacts)
+	}
+	for i, c := range f.Contacts {
+		if c == (StaffContact{}) {
+			continue
+		}
+		row := fmt.Sprintf("Contact %d", i+1)
+		if c.Name == "" {
+			f.Errors["contacts"] = row + " needs a name."
+		} else if err := validateEditEmail(c.Email); err != "" {
+			f.Errors["contacts"] = row + ": " + err
+		} else if err := validateEditPhone(c.Phone); err != "" {
+			f.Errors["contacts"] = row + ": " + err
+		} else if len(c.Name) > maxEditTextLength || len(c.Title) > maxEditTextLength || len(c.Department) > maxEditTextLength {
+			f.Errors["contacts"] = fmt.Sprintf("%s: keep each field under %d characters.", row, maxEditTextLength)
+		}
+		if f.Errors["contacts"] != "" {
+			break
+		}
+	}
+
+	return len(f.Errors) == 0
+}
+
+// Apply returns a copy of data with the form's values
+func (f *AIDataEditForm) Apply(data *EnhancedSchoolData) *EnhancedSchoolData {
+	edited := *data
+	edited.Principal = f.Principal
+	edited.VicePrincipals = editListItems(f.VicePrincipals)
+	edited.MainOfficeEmail = f.MainOfficeEmail
+	edited.MainOfficePhone = f.MainOfficePhone
+	edited.StaffContacts = f.staffContacts()
+	edited.APCourses = editListItems(f.APCourses)
+	edited.Honors = editListItems(f.Honors)
+	edited.SpecialPrograms = editListItems(f.SpecialPrograms)
+	edited.Languages = editListItems(f.Languages)
+	return &edited
+}
+
+// AIDataEditContactRow is a staff contact row in the edit form
+type AIDataEditContactRow struct {
+	StaffContact
+	Number int // 1-based, for labels
+}
+
+// ContactRows numbers the form's staff contact rows
+func (f *AIDataEditForm) ContactRows() []AIDataEditContactRow {
+	rows := make([]AIDataEditContactRow, len(f.Contacts))
+	for i, c := range f.Contacts {
+		rows[i] = AIDataEditContactRow{StaffContact: c, Number: i + 1}
+	}
+	return rows
+}
+
+// staffContacts returns the form's contacts without blank rows
+func (f *AIDataEditForm) staffContacts() []StaffContact {
+	var contacts []StaffContact
+	for _, c := range f.Contacts {
+		if c != (StaffContact{}) {
+			contacts = append(contacts, c)
+		}
+	}
+	return contacts
+}
+
+// editListItems splits a one-item-per-line list, dropping blank lines
+func editListItems(text string) []string {
+	var items []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			items = append(items, line)
+		}
+	}
+	return items
+}
+
+// validateEditEmail returns a message if email is set but isn't a plain address
+func validateEditEmail(email string) string {
+	if email == "" {
+		return ""
+	}
+	if addr, err := mail.ParseAddress(email); err != nil || addr.Address != email {
+		return fmt.Sprintf("%q isn't a valid email address.", email)
+	}
+	return ""
+}
+
+// validateEditPhone returns a message if phone is set but doesn't look like a phone number
+func validateEditPhone(phone string) string {
+	if phone == "" {
+		return ""
+	}
+	digits := 0
+	for _, r := range phone {
+		if r >= '0' && r <= '9' {
+			digits++
+		}
+	}
+	if !phonePattern.MatchString(phone) || digits < 7 || digits > 20 {
+		return fmt.Sprintf("%q isn't a valid phone number.", phone)
+	}
+	return ""
+}
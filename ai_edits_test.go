@@ -0,0 +1,233 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+func testEnhancedData() *EnhancedSchoolData {
+	return &EnhancedSchoolData{
+		NCESSCH:         "360000100001",
+		SchoolName:      "Lincoln Elementary School",
+		SourceURL:       "https://lincoln.example.org",
+		MarkdownContent: "# Lincoln Elementary",
+		ExtractedAt:     time.Now().Add(-time.Hour).Truncate(time.Second),
+		Principal:       "Dr. Smith",
+		StaffContacts:   []StaffContact{{Name: "Ann Lee", Title: "Counselor", Email: "alee@example.org"}},
+		APCourses:       []string{"AP Biology"},
+	}
+}
+
+func TestAIDataEditFormValidate(t *testing.T) {
+	tests := []struct {
+		name      string
+		values    url.Values
+		wantField string // Field with an error, or "" for a valid form
+	}{
+		{"valid", url.Values{"principal": {"Dr. Jones"}, "main_office_email": {"office@example.org"}, "main_office_phone": {"(555) 123-4567 ext. 12"}}, ""},
+		{"empty", url.Values{}, ""},
+		{"bad email", url.Values{"main_office_email": {"office at example"}}, "main_office_email"},
+		{"email with display name", url.Values{"main_office_email": {"Office <office@example.org>"}}, "main_office_email"},
+		{"bad phone", url.Values{"main_office_phone": {"call us"}}, "main_office_phone"},
+		{"short phone", url.Values{"main_office_phone": {"555-12"}}, "main_office_phone"},
+		{"long principal", url.Values{"principal": {strings.Repeat("x", maxEditTextLength+1)}}, "principal"},
+		{"long list item", url.Values{"ap_courses": {"AP Biology\n" + strings.Repeat("x", maxEditTextLength+1)}}, "ap_courses"},
+		{"too many list items", url.Values{"languages": {strings.Repeat("Spanish\n", maxEditListItems+1)}}, "languages"},
+		{"contact without name", url.Values{"contact_name": {""}, "contact_email": {"a@example.org"}}, "contacts"},
+		{"contact with bad email", url.Values{"contact_name": {"Ann Lee"}, "contact_email": {"nope"}}, "contacts"},
+		{"blank contact rows", url.Values{"contact_name": {"", ""}, "contact_title": {"", ""}}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := parseAIDataEditForm(tt.values)
+			valid := form.Validate()
+			if tt.wantField == "" {
+				if !valid {
+					t.Errorf("Expected valid form, got errors %v", form.Errors)
+				}
+				return
+			}
+			if valid || form.Errors[tt.wantField] == "" {
+				t.Errorf("Expected an error for %s, got %v", tt.wantField, form.Errors)
+			}
+		})
+	}
+}
+
+func TestAIDataEditFormApply(t *testing.T) {
+	before := testEnhancedData()
+	form := newAIDataEditForm(before)
+	if len(form.Contacts) != 1+blankContactRows {
+		t.Fatalf("Expected saved contact plus %d blank rows, got %d", blankContactRows, len(form.Contacts))
+	}
+
+	form.Principal = "Dr. Jones"
+	form.APCourses = "AP Biology\n\n  AP Chemistry  \n"
+	form.Contacts[0] = StaffContact{} // Cleared row removes the contact
+	after := form.Apply(before)
+
+	if after.Principal != "Dr. Jones" || strings.Join(after.APCourses, ",") != "AP Biology,AP Chemistry" || len(after.StaffContacts) != 0 {
+		t.Errorf("Apply = principal %q, AP %q, contacts %v", after.Principal, after.APCourses, after.StaffContacts)
+	}
+	if before.Principal != "Dr. Smith" || len(before.StaffContacts) != 1 {
+		t.Error("Apply modified the original data")
+	}
+	if after.MarkdownContent != before.MarkdownContent || !after.ExtractedAt.Equal(before.ExtractedAt) {
+		t.Error("Apply should keep fields the form doesn't edit")
+	}
+}
+
+func TestDiffAIData(t *testing.T) {
+	before := testEnhancedData()
+	after := *before
+	after.Principal = "Dr. Jones"
+	after.StaffContacts = append(after.StaffContacts, StaffContact{Name: "Bo Park", Title: "Nurse"})
+
+	edits := diffAIData(before, &after, aiEditSourceWeb)
+	if len(edits) != 2 {
+		t.Fatalf("Expected 2 edits, got %+v", edits)
+	}
+	if edits[0].Field != "principal" || edits[0].OldValue != "Dr. Smith" || edits[0].NewValue != "Dr. Jones" || edits[0].Label() != "Principal" {
+		t.Errorf("principal edit = %+v", edits[0])
+	}
+	if want := "Ann Lee (Counselor, alee@example.org); Bo Park (Nurse)"; edits[1].Field != "staff_contacts" || edits[1].NewValue != want {
+		t.Errorf("staff contacts edit = %+v, want new value %q", edits[1], want)
+	}
+
+	if edits := diffAIData(before, before, aiEditSourceWeb); len(edits) != 0 {
+		t.Errorf("Expected no edits for unchanged data, got %+v", edits)
+	}
+}
+
+func TestWebAIDataEdit(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	if err := saveEnhancedData(db, testEnhancedData()); err != nil {
+		t.Fatal(err)
+	}
+	router := NewRouter(ServerConfig{DB: db, AIScraper: &AIScraperService{db: db, cacheTTL: time.Hour}})
+
+	// Unknown schools aren't found
+	req := httptest.NewRequest("GET", "/schools/999999999999/ai/edit", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unknown school edit form: status %d, want 404", rec.Code)
+	}
+
+	// The form is filled in and accessible
+	req = httptest.NewRequest("GET", "/schools/360000100001/ai/edit", nil)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `value="Dr. Smith"`) {
+		t.Fatalf("edit form: status %d, body %s", rec.Code, rec.Body.String())
+	}
+	doc, err := html.Parse(strings.NewReader(rec.Body.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rule := range a11yRules {
+		if rule.fullPage {
+			continue
+		}
+		for _, problem := range rule.check(doc) {
+			t.Errorf("edit form %s: %s", rule.id, problem)
+		}
+	}
+
+	post := func(form url.Values, htmx bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/schools/360000100001/ai/edit", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if htmx {
+			req.Header.Set("HX-Request", "true")
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Invalid edits are returned with errors and not saved
+	rec = post(url.Values{"principal": {"Dr. Jones"}, "main_office_email": {"not an email"}}, false)
+	if rec.Code != http.StatusUnprocessableEntity || !strings.Contains(rec.Body.String(), "valid email") {
+		t.Errorf("invalid form: status %d, body %s", rec.Code, rec.Body.String())
+	}
+	if edits, _ := db.ListAIDataEdits("360000100001", maxAIEditHistory); len(edits) != 0 {
+		t.Errorf("invalid form recorded edits: %+v", edits)
+	}
+
+	// Valid edits are saved and shown in the history
+	rec = post(url.Values{
+		"principal":          {"Dr. Jones"},
+		"main_office_email":  {"office@example.org"},
+		"ap_courses":         {"AP Biology\nAP Chemistry"},
+		"contact_name":       {"Ann Lee", ""},
+		"contact_title":      {"Counselor", ""},
+		"contact_email":      {"alee@example.org", ""},
+		"contact_phone":      {"", ""},
+		"contact_department": {"", ""},
+	}, true)
+	body := rec.Body.String()
+	if rec.Code != http.StatusOK || !strings.Contains(body, "Edits saved") || !strings.Contains(body, "Manual edits (3)") {
+		t.Fatalf("save: status %d, body %s", rec.Code, body)
+	}
+
+	saved, err := (&AIScraperService{db: db}).loadCachedData("360000100001", cacheNoExpiry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Principal != "Dr. Jones" || len(saved.APCourses) != 2 || saved.MainOfficeEmail != "office@example.org" || saved.SourceURL != "https://lincoln.example.org" {
+		t.Errorf("saved data = %+v", saved)
+	}
+
+	edits, err := db.ListAIDataEdits("360000100001", maxAIEditHistory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := make(map[string]AIDataEdit)
+	for _, e := range edits {
+		fields[e.Field] = e
+	}
+	if len(edits) != 3 || fields["principal"].OldValue != "Dr. Smith" || fields["principal"].Source != aiEditSourceWeb {
+		t.Errorf("edit history = %+v", edits)
+	}
+}
+
+func TestEditorEditsRecorded(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	before := testEnhancedData()
+	after := *before
+	after.Mascot = "Lions"
+	b, err := json.Marshal(after)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "school.json")
+	if err := os.WriteFile(path, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := loadAndSaveEditedData(before, path, db); err != nil {
+		t.Fatalf("loadAndSaveEditedData failed: %v", err)
+	}
+
+	edits, err := db.ListAIDataEdits(before.NCESSCH, maxAIEditHistory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(edits) != 1 || edits[0].Field != "mascot" || edits[0].NewValue != "Lions" || edits[0].Source != aiEditSourceEditor {
+		t.Errorf("edit history = %+v", edits)
+	}
+}
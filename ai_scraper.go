@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
@@ -34,6 +35,9 @@ type EnhancedSchoolData struct {
 	// Markdown content from AI extraction
 	MarkdownContent string `json:"markdown_content"`
 
+	Stale      bool `json:"-"` // Served from an expired cache entry
+	Refreshing bool `json:"-"` // A background refresh of the stale entry is running
+
 	// Legacy structured fields (kept for backward compatibility with cached data)
 	Principal      string   `json:"principal,omitempty"`
 	VicePrincipals []string `json:"vice_principals,omitempty"`
@@ -47,22 +51,32 @@ type EnhancedSchoolData struct {
 	MainOfficePhone string         `json:"main_office_phone,omitempty"`
 
 	// Academic Programs
-	APCourses       []string `json:"ap_courses,omitempty"`
-	Honors          []string `json:"honors,omitempty"`
-	SpecialPrograms []string `json:"special_programs,omitempty"`
-	Languages       []string `json:"languages,omitempty"`
+	APCourses        []string           `json:"ap_courses,omitempty"`
+	Honors           []string           `json:"honors,omitempty"`
+	SpecialPrograms  []string           `json:"special_programs,omitempty"`
+	Languages        []string           `json:"languages,omitempty"`
+	LanguagePrograms []LanguageOffering `json:"language_programs,omitempty"` // Languages taught, flagged as immersion or course
+
+	// Career & Technical Education, at high schools
+	CTEPathways    []CTEPathway     `json:"cte_pathways,omitempty"`
+	DualEnrollment []DualEnrollment `json:"dual_enrollment,omitempty"`
 
 	// Activities & Sports
-	Sports []string `json:"sports,omitempty"`
-	Clubs  []string `json:"clubs,omitempty"`
-	Arts   []string `json:"arts,omitempty"`
+	Sports             []string        `json:"sports,omitempty"`
+	Athletics          []SportOffering `json:"athletics,omitempty"` // Teams by sport, season, and level
+	AthleticConference string          `json:"athletic_conference,omitempty"`
+	Clubs              []string        `json:"clubs,omitempty"`
+	Arts               []string        `json:"arts,omitempty"`
+	ArtsPrograms       []ArtsOffering  `json:"arts_programs,omitempty"` // Arts and music programs by discipline
 
 	// Facilities
 	Facilities []string `json:"facilities,omitempty"`
 
 	// Schedule & Calendar
-	BellSchedule string `json:"bell_schedule,omitempty"`
-	SchoolHours  string `json:"school_hours,omitempty"`
+	BellSchedule string       `json:"bell_schedule,omitempty"`
+	SchoolHours  string       `json:"school_hours,omitempty"`
+	BeforeCare   *CareProgram `json:"before_care,omitempty"`
+	AfterCare    *CareProgram `json:"after_care,omitempty"`
 
 	// Achievements
 	Achievements   []string `json:"achievements,omitempty"`
@@ -75,24 +89,26 @@ type EnhancedSchoolData struct {
 
 // AIScraperService handles website scraping with Claude
 type AIScraperService struct {
-	client         *anthropic.Client
+	messages       MessageCreator // Claude messages API
+	anthropicOpts  []option.RequestOption
 	db             *DB
 	cacheTTL       time.Duration
-	httpClient     *http.Client
+	httpClient     HTTPDoer
 	maxSQLRetries  int // Maximum attempts to correct failed SQL queries
+	refresher      backgroundRefresher
+	flights        flightGroup  // Coalesces concurrent extractions for the same school or district
+	retry          *retryPolicy // Retries transient website fetch failures
 }
 
 // NewAIScraperService creates a new AI scraper service
-func NewAIScraperService(apiKey string, db *DB) (*AIScraperService, error) {
+func NewAIScraperService(apiKey string, db *DB, opts ...AIScraperOption) (*AIScraperService, error) {
 	if apiKey == "" {
 		if logger != nil {
 			logger.Error("AI scraper initialization failed: missing API key")
 		}
-		return nil, fmt.Errorf("ANTHROPIC_API_KEY environment variable not set")
+		return nil, ErrAINotConfigured
 	}
 
-	client := anthropic.NewClient(option.WithAPIKey(apiKey))
-
 	// Get max retries from environment or use default
 	maxRetries := 3
 	if retryStr := os.Getenv("AI_SQL_MAX_RETRIES"); retryStr != "" {
@@ -105,19 +121,29 @@ func NewAIScraperService(apiKey string, db *DB) (*AIScraperService, error) {
 		}
 	}
 
+	cacheTTL := cacheTTLFromEnv("AI_CACHE_TTL", defaultAICacheTTL)
+
 	if logger != nil {
-		logger.Info("AI scraper service initialized with database caching", "cache_ttl_days", 30, "max_sql_retries", maxRetries)
+		logger.Info("AI scraper service initialized with database caching", "cache_ttl_days", cacheTTL.Hours()/24, "max_sql_retries", maxRetries)
 	}
 
-	return &AIScraperService{
-		client:        &client,
+	s := &AIScraperService{
 		db:            db,
-		cacheTTL:      30 * 24 * time.Hour, // 30 days
+		cacheTTL:      cacheTTL,
 		maxSQLRetries: maxRetries,
+		retry:         websiteRetry,
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
-	}, nil
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.messages == nil {
+		client := anthropic.NewClient(append([]option.RequestOption{option.WithAPIKey(apiKey)}, s.anthropicOpts...)...)
+		s.messages = &client.Messages
+	}
+	return s, nil
 }
 
 // FetchWebsiteContent fetches the HTML content from a URL
@@ -132,28 +158,36 @@ func (s *AIScraperService) FetchWebsiteContent(url string) (string, error) {
 
 	req.Header.Set("User-Agent", "SchoolFinder/2.0 (Educational Research Tool; Contact Info Collector; +https://github.com/anthropics/claude-code)")
 
-	resp, err := s.httpClient.Do(req)
-	if err != nil {
-		if logger != nil {
-			logger.Error("HTTP request failed for website fetch", "error", err, "url", url)
+	var body []byte
+	err = s.retry.Do(req.Context(), func() error {
+		resp, err := s.httpClient.Do(req)
+		if err != nil {
+			if logger != nil {
+				logger.Error("HTTP request failed for website fetch", "error", err, "url", url)
+			}
+			return err
 		}
-		return "", fmt.Errorf("failed to fetch URL: %w", err)
-	}
-	defer func() { _ = resp.Body.Close() }()
+		defer func() { _ = resp.Body.Close() }()
 
-	if resp.StatusCode != http.StatusOK {
-		if logger != nil {
-			logger.Error("HTTP request returned non-OK status", "status_code", resp.StatusCode, "url", url)
+		if resp.StatusCode != http.StatusOK {
+			if logger != nil {
+				logger.Error("HTTP request returned non-OK status", "status_code", resp.StatusCode, "url", url)
+			}
+			return newHTTPStatusError(resp)
 		}
-		return "", fmt.Errorf("HTTP error: %d", resp.StatusCode)
-	}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		if logger != nil {
+		body, err = io.ReadAll(resp.Body)
+		if err != nil && logger != nil {
 			logger.Error("Failed to read response body", "error", err, "url", url)
 		}
-		return "", err
+		return err
+	})
+	if err != nil {
+		var statusErr *httpStatusError
+		if errors.As(err, &statusErr) {
+			return "", upstreamStatusError(ErrWebsiteUnavailable, statusErr.StatusCode)
+		}
+		return "", fmt.Errorf("%w: %w", ErrWebsiteUnavailable, err)
 	}
 
 	// Limit content size (Claude has token limits)
@@ -178,13 +212,14 @@ func (s *AIScraperService) ExtractSchoolDataWithWebSearch(ctx context.Context, s
 		address = fmt.Sprintf("%s, %s, %s %s", address, school.City, school.State, school.ZipString())
 	}
 
-	websiteURL := school.Website.String
-	if !strings.HasPrefix(websiteURL, "http://") && !strings.HasPrefix(websiteURL, "https://") {
-		websiteURL = "https://" + websiteURL
+	// Point Claude past a recorded website that no longer works
+	websiteNote := fmt.Sprintf("Website: %s.", schoolWebsiteURL(school))
+	if check := s.deadWebsite(school); check != nil {
+		websiteNote = fmt.Sprintf("The school's recorded website, %s, appears to be dead (%s). Start by searching for the school's current official website and use that instead.", check.URL, check.Problem())
 	}
 
 	// Construct the user message
-	content := fmt.Sprintf(`Your PRIMARY OBJECTIVE is to find administrative staff contact information for %s, located at %s. Website: %s.
+	content := fmt.Sprintf(`Your PRIMARY OBJECTIVE is to find administrative staff contact information for %s, located at %s. %s
 
 **CRITICAL REQUIREMENT: You must locate and extract staff contact information with emails and phone numbers.**
 
@@ -210,9 +245,35 @@ If the main website doesn't have a staff directory, search for "[school name] st
 Once you have the staff contacts, also gather:
 - Mascot and school colors
 - Academic programs (AP courses, honors, special programs, languages)
-- Activities (sports, clubs, arts)
+- Program options families filter on: special education services (IEP support, resource rooms, special day classes),
+  gifted/GATE programs, dual-language or language immersion, International Baccalaureate (IB), and Montessori.
+  Name each one the school offers explicitly, under a "Programs" heading
+- World languages. Under a "World Languages" heading, write one line per language taught, saying whether it is
+  an immersion or dual-language program or a world-language course, using "not published" for grades the
+  school doesn't publish:
+  - Language: <language> | <immersion or course> | <grades, e.g. K-5>
+- Career and technical education, at schools serving high school grades. Under a "Career & Technical Education"
+  heading, write one line per CTE pathway and one per college partnership (dual enrollment, early college,
+  or articulated credit), using "not published" for anything the school doesn't publish:
+  - Pathway: <pathway name> | <career cluster> | <certifications or credit earned>
+  - Dual enrollment: <college or university> | <dual enrollment, early college, or articulated credit> | <details>
+  Use one of these career clusters: %s.
+- Athletics. Under an "Athletics" heading, write the athletic conference or league the school competes in,
+  then one line per sport, using "not published" for anything the school doesn't publish:
+  - Athletic conference: <conference or league, and state association section>
+  - Sport: <sport> | <fall, winter, spring, or summer> | <levels, e.g. varsity, JV, freshman>
+- Arts and music. Under an "Arts & Music" heading, write one line per program (band, orchestra, choir,
+  theater, visual arts, dance, film or photography):
+  - Arts program: <program name>
+- Clubs and activities
 - Facilities
-- School hours and schedule
+- School hours and schedule. Start with one line giving the regular school day, using "not published" if the
+  school doesn't publish it:
+  - School hours: <days, e.g. Monday-Friday>, <start time> - <end time>
+- Before-school and after-school care, a deciding factor for working parents. Under a "Before & After Care"
+  heading, write exactly these two lines, using "not published" for anything the school doesn't publish:
+  - Before care: yes/no/not published; Hours: ...; Cost: ...; Provider: ...
+  - After care: yes/no/not published; Hours: ...; Cost: ...; Provider: ...
 - Mission statement
 - Achievements and accreditations
 
@@ -223,39 +284,54 @@ Present your findings in clean, well-formatted markdown with:
 - Email addresses and phone numbers prominently displayed
 
 If you cannot find staff contact information after thorough searching, explicitly state what you searched and why the information may not be publicly available.`,
-		school.Name, address, websiteURL)
+		school.Name, address, websiteNote, strings.Join(cteClusters, "; "))
+
+	responseText, err := s.webSearch(ctx, content, "school_name", school.Name, "ncessch", school.NCESSCH)
+	if err != nil {
+		return nil, err
+	}
+
+	if logger != nil {
+		logger.Info("Successfully extracted school data with Claude", "school_name", school.Name, "ncessch", school.NCESSCH, slog.Int("response_length", len(responseText)))
+	}
 
-	webSearchTool := &anthropic.WebSearchTool20250305Param{}
+	// Store the markdown content directly
+	data := &EnhancedSchoolData{
+		MarkdownContent: responseText,
+	}
 
-	tools := []anthropic.ToolUnionParam{{
-		OfWebSearchTool20250305: webSearchTool,
-	}}
+	return data, nil
+}
 
-	// Create the message parameters
+// webSearch sends a prompt to Claude 4.5 Haiku with the web search tool and
+// returns the text of its answer. logAttrs identify the request in the log.
+func (s *AIScraperService) webSearch(ctx context.Context, prompt string, logAttrs ...any) (string, error) {
 	params := anthropic.MessageNewParams{
 		Model:     anthropic.ModelClaudeHaiku4_5_20251001,
 		MaxTokens: 8000,
 		Messages: []anthropic.MessageParam{
-			anthropic.NewUserMessage(anthropic.NewTextBlock(content)),
+			anthropic.NewUserMessage(anthropic.NewTextBlock(prompt)),
 		},
-		Tools: tools,
+		Tools: []anthropic.ToolUnionParam{{
+			OfWebSearchTool20250305: &anthropic.WebSearchTool20250305Param{},
+		}},
 	}
 
 	// Call the Messages API
-	message, err := s.client.Messages.New(ctx, params)
+	message, err := s.messages.New(ctx, params)
 	if err != nil {
 		if logger != nil {
-			logger.Error("Claude API call failed", "error", err, "school_name", school.Name, "ncessch", school.NCESSCH, "model", "haiku-4.5")
+			logger.Error("Claude API call failed", append([]any{"error", err, "model", "haiku-4.5"}, logAttrs...)...)
 		}
-		return nil, fmt.Errorf("Claude API error: %w", err)
+		return "", fmt.Errorf("Claude API error: %w", err)
 	}
 
 	// Extract the response text from all content blocks
 	if len(message.Content) == 0 {
 		if logger != nil {
-			logger.Error("Empty response from Claude API", "school_name", school.Name, "ncessch", school.NCESSCH)
+			logger.Error("Empty response from Claude API", logAttrs...)
 		}
-		return nil, fmt.Errorf("empty response from Claude")
+		return "", fmt.Errorf("empty response from Claude")
 	}
 
 	responseText := ""
@@ -267,21 +343,11 @@ If you cannot find staff contact information after thorough searching, explicitl
 
 	if responseText == "" {
 		if logger != nil {
-			logger.Error("No text content in Claude API response", "school_name", school.Name, "ncessch", school.NCESSCH, "content_blocks", len(message.Content))
+			logger.Error("No text content in Claude API response", append([]any{"content_blocks", len(message.Content)}, logAttrs...)...)
 		}
-		return nil, fmt.Errorf("no text response from Claude")
+		return "", fmt.Errorf("no text response from Claude")
 	}
-
-	if logger != nil {
-		logger.Info("Successfully extracted school data with Claude", "school_name", school.Name, "ncessch", school.NCESSCH, slog.Int("response_length", len(responseText)))
-	}
-
-	// Store the markdown content directly
-	data := &EnhancedSchoolData{
-		MarkdownContent: responseText,
-	}
-
-	return data, nil
+	return responseText, nil
 }
 
 // ScrapeSchoolWebsite performs the full scraping workflow using web search
@@ -291,23 +357,103 @@ func (s *AIScraperService) ScrapeSchoolWebsite(ctx context.Context, school *Scho
 		if logger != nil {
 			logger.Warn("Cannot scrape school: no website available", "school_name", school.Name, "ncessch", school.NCESSCH)
 		}
-		return nil, fmt.Errorf("no website available for this school")
+		return nil, ErrNoWebsite
 	}
+	s.db.RecordUsage(usageScrape)
 
-	websiteURL := school.Website.String
-	if !strings.HasPrefix(websiteURL, "http://") && !strings.HasPrefix(websiteURL, "https://") {
-		websiteURL = "https://" + websiteURL
-	}
+	websiteURL := schoolWebsiteURL(school)
 
-	// Check database cache first
-	cached, err := s.loadFromCache(school.NCESSCH)
-	if err == nil && cached != nil {
+	// Check database cache first, serving stale entries while they refresh
+	if cached, err := s.CachedSchoolData(school); err == nil {
+		if cached.Stale {
+			s.refreshInBackground(school)
+			cached.Refreshing = s.IsRefreshing(school.NCESSCH)
+		}
 		if logger != nil {
-			logger.Info("Returning cached school data from database", "school_name", school.Name, "ncessch", school.NCESSCH, "cache_age_days", int(time.Since(cached.ExtractedAt).Hours()/24))
+			logger.Info("Returning cached school data from database", "school_name", school.Name, "ncessch", school.NCESSCH, "cache_age_days", int(time.Since(cached.ExtractedAt).Hours()/24), "stale", cached.Stale)
 		}
 		return cached, nil
 	}
 
+	return s.scrapeFresh(ctx, school, websiteURL)
+}
+
+// CachedSchoolData returns cached website data without calling Claude. Entries older than
+// the cache TTL are returned with Stale set; only ScrapeSchoolWebsite, which editors and
+// the rate limiter gate, starts a refresh, so reading a page never spends on Claude.
+func (s *AIScraperService) CachedSchoolData(school *School) (*EnhancedSchoolData, error) {
+	data, err := s.loadCachedData(school.NCESSCH, cacheNoExpiry)
+	if err != nil {
+		return nil, err
+	}
+
+	if time.Since(data.ExtractedAt) > s.cacheTTL {
+		data.Stale = true
+		data.Refreshing = s.IsRefreshing(school.NCESSCH)
+	}
+
+	return data, nil
+}
+
+// deadWebsite returns the latest check of the school's current website if it found
+// the site dead, so extraction searches for the school's new site instead
+func (s *AIScraperService) deadWebsite(school *School) *WebsiteCheck {
+	if s.db == nil {
+		return nil
+	}
+	check, err := s.db.GetWebsiteCheck(school.NCESSCH)
+	if err != nil || !check.Dead() || check.URL != schoolWebsiteURL(school) {
+		return nil
+	}
+	return check
+}
+
+// schoolWebsiteURL returns the school's website normalized for linking, with a
+// scheme added if missing
+func schoolWebsiteURL(school *School) string {
+	websiteURL, ok := normalizeWebsiteURL(school.Website.String)
+	if !ok && !strings.HasPrefix(websiteURL, "http://") && !strings.HasPrefix(websiteURL, "https://") {
+		websiteURL = "https://" + websiteURL
+	}
+	return websiteURL
+}
+
+// refreshInBackground re-extracts a school's website data unless a refresh is already running
+func (s *AIScraperService) refreshInBackground(school *School) {
+	sc := *school
+	websiteURL := schoolWebsiteURL(school)
+
+	started := s.refresher.start(sc.NCESSCH, func() error {
+		// Detached from the request that noticed the stale entry
+		ctx, cancel := context.WithTimeout(context.Background(), backgroundRefreshTimeout)
+		defer cancel()
+		_, err := s.scrapeFresh(ctx, &sc, websiteURL)
+		if err != nil && logger != nil {
+			logger.Warn("Background AI refresh failed", "error", err, "ncessch", sc.NCESSCH)
+		}
+		return err
+	})
+	if started && logger != nil {
+		logger.Info("Refreshing stale AI cache in background", "ncessch", sc.NCESSCH)
+	}
+}
+
+// IsRefreshing reports whether a background refresh is running for a school
+func (s *AIScraperService) IsRefreshing(ncessch string) bool {
+	return s.refresher.refreshing(ncessch)
+}
+
+// scrapeFresh extracts school data with Claude and updates the cache. Concurrent
+// extractions for a school share one Claude request.
+func (s *AIScraperService) scrapeFresh(ctx context.Context, school *School, websiteURL string) (*EnhancedSchoolData, error) {
+	sc := *school
+	return coalesce(ctx, &s.flights, flightKey{sc.NCESSCH, "website"}, func(ctx context.Context) (*EnhancedSchoolData, error) {
+		return s.extractAndCache(ctx, &sc, websiteURL)
+	})
+}
+
+// extractAndCache does scrapeFresh's work
+func (s *AIScraperService) extractAndCache(ctx context.Context, school *School, websiteURL string) (*EnhancedSchoolData, error) {
 	if logger != nil {
 		logger.Info("Scraping school website", "school_name", school.Name, "ncessch", school.NCESSCH, "website", websiteURL)
 	}
@@ -338,13 +484,18 @@ func (s *AIScraperService) ScrapeSchoolWebsite(ctx context.Context, school *Scho
 	return data, nil
 }
 
-// loadFromCache loads cached data from the database
+// loadFromCache loads unexpired cached data from the database
 func (s *AIScraperService) loadFromCache(ncessch string) (*EnhancedSchoolData, error) {
+	return s.loadCachedData(ncessch, s.cacheTTL)
+}
+
+// loadCachedData loads cached data no older than maxAge from the database
+func (s *AIScraperService) loadCachedData(ncessch string, maxAge time.Duration) (*EnhancedSchoolData, error) {
 	if s.db == nil {
 		return nil, fmt.Errorf("database not available")
 	}
 
-	schoolName, sourceURL, markdownContent, legacyData, extractedAt, err := s.db.LoadAIScraperCache(ncessch, s.cacheTTL)
+	schoolName, sourceURL, markdownContent, legacyData, extractedAt, err := s.db.LoadAIScraperCache(ncessch, maxAge)
 	if err != nil {
 		return nil, err
 	}
@@ -374,55 +525,118 @@ func (s *AIScraperService) loadFromCache(ncessch string) (*EnhancedSchoolData, e
 			data.Honors = legacy.Honors
 			data.SpecialPrograms = legacy.SpecialPrograms
 			data.Languages = legacy.Languages
+			data.LanguagePrograms = legacy.LanguagePrograms
+			data.CTEPathways = legacy.CTEPathways
+			data.DualEnrollment = legacy.DualEnrollment
 			data.Sports = legacy.Sports
+			data.Athletics = legacy.Athletics
+			data.AthleticConference = legacy.AthleticConference
 			data.Clubs = legacy.Clubs
 			data.Arts = legacy.Arts
+			data.ArtsPrograms = legacy.ArtsPrograms
 			data.Facilities = legacy.Facilities
 			data.BellSchedule = legacy.BellSchedule
 			data.SchoolHours = legacy.SchoolHours
+			data.BeforeCare = legacy.BeforeCare
+			data.AfterCare = legacy.AfterCare
 			data.Achievements = legacy.Achievements
 			data.Accreditations = legacy.Accreditations
 			data.Mission = legacy.Mission
 			data.Notes = legacy.Notes
 		}
 	}
+	// Data extracted before care was stored may still have the care lines
+	if data.BeforeCare == nil && data.AfterCare == nil {
+		data.BeforeCare, data.AfterCare = parseCareSection(markdownContent)
+	}
+	if data.CTEPathways == nil && data.DualEnrollment == nil {
+		data.CTEPathways, data.DualEnrollment = parseCTESection(markdownContent)
+	}
+	if data.Athletics == nil && data.AthleticConference == "" {
+		data.Athletics, data.AthleticConference = parseAthleticsSection(markdownContent)
+	}
+	if data.ArtsPrograms == nil {
+		data.ArtsPrograms = parseArtsSection(markdownContent)
+	}
+	if data.LanguagePrograms == nil {
+		data.LanguagePrograms = parseLanguagesSection(markdownContent)
+	}
+	if data.SchoolHours == "" {
+		data.SchoolHours = parseSchoolHoursLine(markdownContent)
+	}
 
 	return data, nil
 }
 
+// structuredFields returns the legacy structured fields by their JSON names
+func (data *EnhancedSchoolData) structuredFields() map[string]interface{} {
+	return map[string]interface{}{
+		"principal":           data.Principal,
+		"vice_principals":     data.VicePrincipals,
+		"mascot":              data.Mascot,
+		"school_colors":       data.SchoolColors,
+		"founded":             data.Founded,
+		"staff_contacts":      data.StaffContacts,
+		"main_office_email":   data.MainOfficeEmail,
+		"main_office_phone":   data.MainOfficePhone,
+		"ap_courses":          data.APCourses,
+		"honors":              data.Honors,
+		"special_programs":    data.SpecialPrograms,
+		"languages":           data.Languages,
+		"language_programs":   data.LanguagePrograms,
+		"cte_pathways":        data.CTEPathways,
+		"dual_enrollment":     data.DualEnrollment,
+		"sports":              data.Sports,
+		"athletics":           data.Athletics,
+		"athletic_conference": data.AthleticConference,
+		"clubs":               data.Clubs,
+		"arts":                data.Arts,
+		"arts_programs":       data.ArtsPrograms,
+		"facilities":          data.Facilities,
+		"bell_schedule":       data.BellSchedule,
+		"school_hours":        data.SchoolHours,
+		"before_care":         data.BeforeCare,
+		"after_care":          data.AfterCare,
+		"achievements":        data.Achievements,
+		"accreditations":      data.Accreditations,
+		"mission":             data.Mission,
+		"notes":               data.Notes,
+	}
+}
+
 // saveToCache saves data to the database cache
 func (s *AIScraperService) saveToCache(data *EnhancedSchoolData) error {
 	if s.db == nil {
 		return fmt.Errorf("database not available")
 	}
+	return saveEnhancedData(s.db, data)
+}
+
+// saveEnhancedData saves website data to the AI scraper cache, storing the
+// structured fields as legacy JSON
+func saveEnhancedData(db *DB, data *EnhancedSchoolData) error {
+	// Care is read from the extraction's "Before & After Care" lines unless
+	// it was already set, e.g. by an edit
+	if data.BeforeCare == nil && data.AfterCare == nil {
+		data.BeforeCare, data.AfterCare = parseCareSection(data.MarkdownContent)
+	}
+	if data.CTEPathways == nil && data.DualEnrollment == nil {
+		data.CTEPathways, data.DualEnrollment = parseCTESection(data.MarkdownContent)
+	}
+	if data.Athletics == nil && data.AthleticConference == "" {
+		data.Athletics, data.AthleticConference = parseAthleticsSection(data.MarkdownContent)
+	}
+	if data.ArtsPrograms == nil {
+		data.ArtsPrograms = parseArtsSection(data.MarkdownContent)
+	}
+	if data.LanguagePrograms == nil {
+		data.LanguagePrograms = parseLanguagesSection(data.MarkdownContent)
+	}
+	if data.SchoolHours == "" {
+		data.SchoolHours = parseSchoolHoursLine(data.MarkdownContent)
+	}
 
-	// Marshal legacy data (all structured fields) as JSON
-	legacyData := map[string]interface{}{
-		"principal":         data.Principal,
-		"vice_principals":   data.VicePrincipals,
-		"mascot":            data.Mascot,
-		"school_colors":     data.SchoolColors,
-		"founded":           data.Founded,
-		"staff_contacts":    data.StaffContacts,
-		"main_office_email": data.MainOfficeEmail,
-		"main_office_phone": data.MainOfficePhone,
-		"ap_courses":        data.APCourses,
-		"honors":            data.Honors,
-		"special_programs":  data.SpecialPrograms,
-		"languages":         data.Languages,
-		"sports":            data.Sports,
-		"clubs":             data.Clubs,
-		"arts":              data.Arts,
-		"facilities":        data.Facilities,
-		"bell_schedule":     data.BellSchedule,
-		"school_hours":      data.SchoolHours,
-		"achievements":      data.Achievements,
-		"accreditations":    data.Accreditations,
-		"mission":           data.Mission,
-		"notes":             data.Notes,
-	}
-
-	legacyJSON, err := json.Marshal(legacyData)
+	legacyJSON, err := json.Marshal(data.structuredFields())
 	if err != nil {
 		if logger != nil {
 			logger.Error("Failed to marshal legacy data", "error", err, "ncessch", data.NCESSCH)
@@ -430,14 +644,94 @@ func (s *AIScraperService) saveToCache(data *EnhancedSchoolData) error {
 		legacyJSON = nil // Continue without legacy data
 	}
 
-	return s.db.SaveAIScraperCache(
+	if err := db.SaveAIScraperCache(
 		data.NCESSCH,
 		data.SchoolName,
 		data.SourceURL,
 		data.MarkdownContent,
 		legacyJSON,
 		data.ExtractedAt,
-	)
+	); err != nil {
+		return err
+	}
+	saveDerivedData(db, data)
+	return nil
+}
+
+// saveDerivedData refreshes the tables derived from a school's website data:
+// program flags, CTE programs, sports, arts, and languages. They only help
+// filtering and browsing, so failures are logged rather than losing the data.
+func saveDerivedData(db *DB, data *EnhancedSchoolData) {
+	if err := db.SaveWebsiteProgramFlags(data); err != nil && logger != nil {
+		logger.Warn("Failed to save program flags", "error", err, "ncessch", data.NCESSCH)
+	}
+	if err := db.SaveCTEPrograms(data); err != nil && logger != nil {
+		logger.Warn("Failed to save CTE programs", "error", err, "ncessch", data.NCESSCH)
+	}
+	if err := db.SaveSports(data); err != nil && logger != nil {
+		logger.Warn("Failed to save sports", "error", err, "ncessch", data.NCESSCH)
+	}
+	if err := db.SaveArtsPrograms(data); err != nil && logger != nil {
+		logger.Warn("Failed to save arts programs", "error", err, "ncessch", data.NCESSCH)
+	}
+	if err := db.SaveLanguages(data); err != nil && logger != nil {
+		logger.Warn("Failed to save languages", "error", err, "ncessch", data.NCESSCH)
+	}
+}
+
+// websiteTables are the tables saveDerivedData fills besides program flags,
+// with the saver that fills each group
+var websiteTables = []struct {
+	tables []string
+	save   func(*DB, *EnhancedSchoolData) error
+}{
+	{[]string{"school_cte_pathways", "school_dual_enrollment"}, (*DB).SaveCTEPrograms},
+	{[]string{"school_sports", "school_athletic_conferences"}, (*DB).SaveSports},
+	{[]string{"school_arts"}, (*DB).SaveArtsPrograms},
+	{[]string{"school_languages"}, (*DB).SaveLanguages},
+}
+
+// SyncWebsiteTables backfills CTE programs, sports, arts, and languages from
+// website data cached before they were tracked, or merged into this database
+// by another instance's sync, as SyncProgramFlags does for program flags. It
+// returns the number of schools updated.
+func SyncWebsiteTables(d *DB) (int, error) {
+	scraper := &AIScraperService{db: d}
+	updated := make(map[string]bool)
+	for _, group := range websiteTables {
+		var missing []string
+		for _, table := range group.tables {
+			missing = append(missing, fmt.Sprintf("NOT EXISTS (SELECT 1 FROM %s t WHERE t.ncessch = c.ncessch)", table))
+		}
+		rows, err := d.conn.Query(`SELECT c.ncessch FROM ai_scraper_cache c WHERE ` + strings.Join(missing, " AND "))
+		if err != nil {
+			return 0, fmt.Errorf("failed to list cached website data: %w", err)
+		}
+		var ids []string
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return 0, fmt.Errorf("failed to scan cached website data: %w", err)
+			}
+			ids = append(ids, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return 0, err
+		}
+		for _, id := range ids {
+			data, err := scraper.loadCachedData(id, cacheNoExpiry)
+			if err != nil {
+				continue
+			}
+			if err := group.save(d, data); err != nil {
+				return 0, err
+			}
+			updated[id] = true
+		}
+	}
+	return len(updated), nil
 }
 
 // FormatEnhancedData formats the enhanced data for display
@@ -521,8 +815,30 @@ func FormatEnhancedData(data *EnhancedSchoolData) string {
 		}
 	}
 
-	if len(data.Sports) > 0 {
-		b.WriteString(fmt.Sprintf("\nSports: %s\n", strings.Join(data.Sports, ", ")))
+	if offerings := data.LanguageOfferings(); len(offerings) > 0 {
+		languages := make([]string, len(offerings))
+		for i, o := range offerings {
+			languages[i] = o.Summary()
+		}
+		b.WriteString(fmt.Sprintf("\nLanguages: %s\n", strings.Join(languages, ", ")))
+	}
+
+	if offerings := data.SportOfferings(); len(offerings) > 0 {
+		sports := make([]string, len(offerings))
+		for i, o := range offerings {
+			sports[i] = o.Summary()
+		}
+		b.WriteString(fmt.Sprintf("\nSports: %s\n", strings.Join(sports, ", ")))
+	}
+	if data.AthleticConference != "" {
+		b.WriteString(fmt.Sprintf("Athletic Conference: %s\n", data.AthleticConference))
+	}
+	if offerings := data.ArtsOfferings(); len(offerings) > 0 {
+		programs := make([]string, len(offerings))
+		for i, o := range offerings {
+			programs[i] = o.Program
+		}
+		b.WriteString(fmt.Sprintf("\nArts: %s (%s)\n", strings.Join(programs, ", "), data.ArtsCoverage().Summary()))
 	}
 
 	if len(data.Clubs) > 0 && len(data.Clubs) <= 10 {
@@ -535,6 +851,26 @@ func FormatEnhancedData(data *EnhancedSchoolData) string {
 		b.WriteString(fmt.Sprintf("\nSchool Hours: %s\n", data.SchoolHours))
 	}
 
+	if len(data.CTEPathways) > 0 {
+		b.WriteString("\nCTE Pathways:\n")
+		for _, p := range data.CTEPathways {
+			b.WriteString(fmt.Sprintf("  • %s\n", p.Summary()))
+		}
+	}
+	if len(data.DualEnrollment) > 0 {
+		b.WriteString("\nDual Enrollment:\n")
+		for _, p := range data.DualEnrollment {
+			b.WriteString(fmt.Sprintf("  • %s\n", p.Summary()))
+		}
+	}
+
+	if data.BeforeCare != nil {
+		b.WriteString(fmt.Sprintf("\nBefore Care: %s\n", data.BeforeCare.Summary()))
+	}
+	if data.AfterCare != nil {
+		b.WriteString(fmt.Sprintf("\nAfter Care: %s\n", data.AfterCare.Summary()))
+	}
+
 	if data.Mission != "" {
 		b.WriteString(fmt.Sprintf("\nMission: %s\n", data.Mission))
 	}
@@ -551,10 +887,10 @@ func FormatEnhancedData(data *EnhancedSchoolData) string {
 
 // sqlQueryResult holds the parsed result from Claude's SQL generation
 type sqlQueryResult struct {
-	QueryType   string `json:"query_type"`   // "search" or "analysis"
+	QueryType   string `json:"query_type"` // "search" or "analysis"
 	Explanation string `json:"explanation"`
-	SQLQuery    string `json:"sql_query"`    // Full SQL query
-	Analysis    string `json:"analysis"`     // Additional analysis text (optional)
+	SQLQuery    string `json:"sql_query"` // Full SQL query
+	Analysis    string `json:"analysis"`  // Additional analysis text (optional)
 }
 
 // generateSQLFromClaude calls Claude to generate SQL based on user query and optional error context
@@ -660,7 +996,7 @@ Return ONLY the corrected JSON with the fixed sql_query field.`, promptBase, att
 		},
 	}
 
-	message, err := s.client.Messages.New(ctx, params)
+	message, err := s.messages.New(ctx, params)
 	if err != nil {
 		if logger != nil {
 			logger.Error("Claude API call failed for SQL generation", "error", err, "query", query, "attempt", attempt)
@@ -932,6 +1268,35 @@ func (s *AIScraperService) QuerySchoolDatabase(ctx context.Context, db *DB, quer
 	return "", nil, fmt.Errorf("SQL query failed after %d attempts: %w", s.maxSQLRetries, lastError)
 }
 
+// completeText sends a single-turn prompt to Claude and returns the concatenated text response
+func (s *AIScraperService) completeText(ctx context.Context, prompt string, maxTokens int64) (string, error) {
+	params := anthropic.MessageNewParams{
+		Model:     anthropic.ModelClaudeHaiku4_5_20251001,
+		MaxTokens: maxTokens,
+		Messages: []anthropic.MessageParam{
+			anthropic.NewUserMessage(anthropic.NewTextBlock(prompt)),
+		},
+	}
+
+	message, err := s.messages.New(ctx, params)
+	if err != nil {
+		return "", fmt.Errorf("Claude API error: %w", err)
+	}
+
+	responseText := ""
+	for _, block := range message.Content {
+		if textBlock, ok := block.AsAny().(anthropic.TextBlock); ok {
+			responseText += textBlock.Text
+		}
+	}
+
+	if responseText == "" {
+		return "", fmt.Errorf("no text response from Claude")
+	}
+
+	return responseText, nil
+}
+
 // truncateString truncates a string to maxLen characters, adding "..." if truncated
 func truncateString(s string, maxLen int) string {
 	if len(s) <= maxLen {
@@ -3,8 +3,15 @@ package main
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log"
+	"mime"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -12,16 +19,22 @@ import (
 
 // APIHandler handles JSON API requests
 type APIHandler struct {
-	DB        *DB
-	AIScraper *AIScraperService
+	DB         *DB
+	AIScraper  *AIScraperService
+	NAEPClient *NAEPClient
 }
 
 // Search handles API search requests
 func (h *APIHandler) Search(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query().Get("q")
 	state := r.URL.Query().Get("state")
+	limit := maxResults
+	if n, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && n > 0 && n < maxResults {
+		limit = n
+	}
 
-	schools, err := h.DB.SearchSchools(query, state, maxResults)
+	h.DB.RecordUsage(usageSearch)
+	schools, err := h.DB.SearchSchools(query, state, limit)
 	if err != nil {
 		log.Printf("Search error: %v", err)
 		respondJSON(w, http.StatusInternalServerError, map[string]string{
@@ -30,21 +43,163 @@ func (h *APIHandler) Search(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var districts []District
+	if query != "" {
+		districts, err = h.DB.SearchDistricts(query, state, maxDistrictResults)
+		if err != nil {
+			log.Printf("District search error: %v", err)
+		}
+	}
+
+	// Imported datasets that matched, by school
+	importedMatches, err := h.DB.ImportedMatches(query, schoolIDs(schools))
+	if err != nil {
+		log.Printf("Warning: failed to match imported datasets: %v", err)
+	}
+
 	respondJSON(w, http.StatusOK, map[string]interface{}{
-		"schools": schools,
-		"count":   len(schools),
-		"query":   query,
-		"state":   state,
+		"schools":          schools,
+		"districts":        districts,
+		"imported_matches": importedMatches,
+		"count":            len(schools),
+		"query":            query,
+		"state":            state,
 	})
 }
 
+// Suggestion limits: the command palette lists a few of each
+const (
+	maxSchoolSuggestions   = 8
+	maxDistrictSuggestions = 3
+)
+
+// Suggestion is a school or district the command palette can open
+type Suggestion struct {
+	Kind   string `json:"kind"` // "school" or "district"
+	Label  string `json:"label"`
+	Detail string `json:"detail"`
+	URL    string `json:"url"`
+}
+
+// Suggest returns schools and districts matching what's been typed so far, for
+// the command palette. Queries are too partial to count as searches, so they
+// aren't recorded in usage.
+func (h *APIHandler) Suggest(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	suggestions := []Suggestion{}
+	if len([]rune(query)) < 2 {
+		respondJSON(w, http.StatusOK, map[string]interface{}{"query": query, "suggestions": suggestions})
+		return
+	}
+
+	schools, err := h.DB.SearchSchools(query, "", maxSchoolSuggestions)
+	if err != nil {
+		log.Printf("Suggest error: %v", err)
+		respondJSON(w, http.StatusInternalServerError, map[string]string{
+			"error": "Search failed",
+		})
+		return
+	}
+	for _, s := range schools {
+		suggestions = append(suggestions, Suggestion{
+			Kind:   "school",
+			Label:  s.Name,
+			Detail: fmt.Sprintf("%s, %s · %s", s.City, s.State, s.LevelString()),
+			URL:    "/schools/" + s.NCESSCH,
+		})
+	}
+
+	districts, err := h.DB.SearchDistricts(query, "", maxDistrictSuggestions)
+	if err != nil {
+		log.Printf("District suggest error: %v", err)
+	}
+	for _, d := range districts {
+		suggestions = append(suggestions, Suggestion{
+			Kind:   "district",
+			Label:  d.Name,
+			Detail: fmt.Sprintf("%s · %d schools", d.StateName, d.SchoolCount),
+			URL:    "/districts/" + d.LEAID,
+		})
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{"query": query, "suggestions": suggestions})
+}
+
+// lookupMatchMargin is how far the best lookup candidate must lead the next
+// one to be returned as the match rather than left for the user to pick
+const lookupMatchMargin = 0.05
+
+// Lookup resolves a school a browser extension found on a page, by name, city,
+// state, and the page's website, to its NCESSCH and bundle URL. It answers
+// cross-origin requests so content scripts can call it.
+func (h *APIHandler) Lookup(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	params := r.URL.Query()
+	query := LookupQuery{
+		Name:    params.Get("name"),
+		City:    params.Get("city"),
+		State:   params.Get("state"),
+		Website: params.Get("url"),
+	}
+	if strings.TrimSpace(query.Name) == "" && strings.TrimSpace(query.Website) == "" {
+		respondJSON(w, http.StatusBadRequest, map[string]string{
+			"error": "name or url is required",
+		})
+		return
+	}
+
+	candidates, err := h.DB.LookupSchool(query)
+	if err != nil {
+		log.Printf("Lookup error: %v", err)
+		respondJSON(w, http.StatusInternalServerError, map[string]string{
+			"error": "Lookup failed",
+		})
+		return
+	}
+	if len(candidates) == 0 {
+		respondJSON(w, http.StatusNotFound, map[string]interface{}{
+			"error":      "No matching school",
+			"candidates": []LookupCandidate{},
+		})
+		return
+	}
+
+	base := apiBaseURL(r)
+	for i := range candidates {
+		candidates[i].PageURL = base + "/schools/" + candidates[i].NCESSCH
+		candidates[i].BundleURL = base + "/api/v1/schools/" + candidates[i].NCESSCH + "/bundle"
+	}
+	// Only a confident, clear winner is the match; otherwise the extension asks
+	var match *LookupCandidate
+	if best := candidates[0]; best.Confidence != "low" && (len(candidates) == 1 || best.Score-candidates[1].Score >= lookupMatchMargin) {
+		match = &best
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"match":      match,
+		"candidates": candidates,
+	})
+}
+
+// apiBaseURL is the root URL for links in API responses: SCHOOLFINDER_URL if
+// set, otherwise the host the request came to
+func apiBaseURL(r *http.Request) string {
+	if base := strings.TrimSuffix(os.Getenv("SCHOOLFINDER_URL"), "/"); base != "" {
+		return base
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
 // GetSchool handles API requests for a single school
 func (h *APIHandler) GetSchool(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 
 	school, err := h.DB.GetSchoolByID(id)
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, sql.ErrNoRows) {
 			respondJSON(w, http.StatusNotFound, map[string]string{
 				"error": "School not found",
 			})
@@ -57,43 +212,72 @@ func (h *APIHandler) GetSchool(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check for cached AI data (requires AI scraper)
+	// Check for cached AI data (requires AI scraper); stale entries are refreshed by POST .../ai
 	var enhancedData *EnhancedSchoolData
 	if h.AIScraper != nil {
-		// Try to load from cache with a reasonable TTL
-		_, sourceURL, markdownContent, legacyData, extractedAt, err := h.DB.LoadAIScraperCache(school.NCESSCH, 30*24*time.Hour)
-		if err == nil && sourceURL != "" {
-			// Parse the cached data
-			enhancedData = &EnhancedSchoolData{
-				NCESSCH:         school.NCESSCH,
-				SchoolName:      school.Name,
-				SourceURL:       sourceURL,
-				MarkdownContent: markdownContent,
-				ExtractedAt:     extractedAt,
-			}
-			// Parse legacy data if available
-			if len(legacyData) > 0 {
-				if err := json.Unmarshal(legacyData, enhancedData); err != nil {
-					// Log error but continue - enhanced data is optional
-					log.Printf("Warning: failed to unmarshal legacy data: %v", err)
-				}
-			}
+		if cached, err := h.AIScraper.CachedSchoolData(school); err == nil && cached.SourceURL != "" {
+			enhancedData = cached
 		}
 	}
 
 	respondJSON(w, http.StatusOK, map[string]interface{}{
 		"school":       school,
 		"enhancedData": enhancedData,
+		"stale":        enhancedData != nil && enhancedData.Stale,
 	})
 }
 
+// GetSchoolBundle returns a school's whole dossier, the same document the TUI
+// saves with Ctrl+W, using only cached AI and NAEP data
+func (h *APIHandler) GetSchoolBundle(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	school, err := h.DB.GetSchoolByID(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondJSON(w, http.StatusNotFound, map[string]string{
+				"error": "School not found",
+			})
+			return
+		}
+		log.Printf("Database error: %v", err)
+		respondJSON(w, http.StatusInternalServerError, map[string]string{
+			"error": "Internal server error",
+		})
+		return
+	}
+
+	var enhancedData *EnhancedSchoolData
+	if h.AIScraper != nil {
+		if cached, err := h.AIScraper.CachedSchoolData(school); err == nil && cached.SourceURL != "" {
+			enhancedData = cached
+		}
+	}
+	var naepData *NAEPData
+	if h.NAEPClient != nil {
+		if cached, err := h.NAEPClient.CachedNAEPData(school); err == nil {
+			naepData = cached
+		}
+	}
+
+	bundle, err := BuildSchoolBundle(h.DB, school, enhancedData, naepData)
+	if err != nil {
+		log.Printf("Failed to build bundle for %s: %v", id, err)
+		respondJSON(w, http.StatusInternalServerError, map[string]string{
+			"error": "Internal server error",
+		})
+		return
+	}
+	respondJSON(w, http.StatusOK, bundle)
+}
+
 // ExtractAI handles API requests for AI extraction
 func (h *APIHandler) ExtractAI(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 
 	school, err := h.DB.GetSchoolByID(id)
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, sql.ErrNoRows) {
 			respondJSON(w, http.StatusNotFound, map[string]string{
 				"error": "School not found",
 			})
@@ -108,18 +292,15 @@ func (h *APIHandler) ExtractAI(w http.ResponseWriter, r *http.Request) {
 
 	// Check if AI scraper is available
 	if h.AIScraper == nil {
-		respondJSON(w, http.StatusServiceUnavailable, map[string]string{
-			"error": "AI extraction not available: ANTHROPIC_API_KEY not set",
-		})
+		respondError(w, ErrAINotConfigured, "AI extraction failed")
 		return
 	}
 
+	h.DB.RecordUsage(usageScrape)
 	enhancedData, err := h.AIScraper.ExtractSchoolDataWithWebSearch(r.Context(), school)
 	if err != nil {
 		log.Printf("AI extraction error: %v", err)
-		respondJSON(w, http.StatusInternalServerError, map[string]string{
-			"error": "AI extraction failed: " + err.Error(),
-		})
+		respondError(w, err, "AI extraction failed")
 		return
 	}
 
@@ -129,6 +310,161 @@ func (h *APIHandler) ExtractAI(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// Query runs a read-only SQL query for an editor's terminal client, so the
+// query, schema, summarize, and ask commands work with --server. The SQL gets
+// the same checks as the Data Explorer's (see readQuery).
+func (h *APIHandler) Query(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		SQL string `json:"sql"`
+	}
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&req); err != nil || strings.TrimSpace(req.SQL) == "" {
+		respondJSON(w, http.StatusBadRequest, map[string]string{
+			"error": "Send the query as JSON: {\"sql\": \"...\"}",
+		})
+		return
+	}
+
+	rows, err := h.DB.ExecuteReadQuery(r.Context(), req.SQL)
+	if errors.Is(err, ErrNotReadQuery) {
+		respondJSON(w, ErrNotReadQuery.Status, map[string]string{
+			"error": ErrNotReadQuery.Message + " (" + strings.TrimPrefix(err.Error(), ErrNotReadQuery.Error()+": ") + ")",
+			"hint":  ErrNotReadQuery.Hint,
+		})
+		return
+	}
+	if err != nil {
+		// The query is the editor's own, so its error is theirs to see
+		respondJSON(w, http.StatusUnprocessableEntity, map[string]string{
+			"error": err.Error(),
+		})
+		return
+	}
+	if rows == nil {
+		rows = []map[string]interface{}{}
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"rows": rows,
+	})
+}
+
+// RetryMetrics returns how often requests to outside services (the NAEP API,
+// school websites, and data downloads) were retried since the server started
+func (h *APIHandler) RetryMetrics(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, RetryMetrics())
+}
+
+// Choropleth returns a metric aggregated by state, or by county within a
+// state, for heatmaps: ?metric=ratio|charter_share|proficiency&level=state|county
+// &state=CA, plus subject and grade for NAEP proficiency
+func (h *APIHandler) Choropleth(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	q, err := choroplethQueryFromQuery(r.URL.Query())
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c, err := h.DB.Choropleth(q)
+	if err != nil {
+		log.Printf("Choropleth error: %v", err)
+		respondJSON(w, http.StatusInternalServerError, map[string]string{
+			"error": "Failed to compute the heatmap",
+		})
+		return
+	}
+	respondJSON(w, http.StatusOK, c)
+}
+
+// SyncExport returns the cached extractions, saved schools, and notes changed
+// since the since parameter (RFC 3339), or all of them, for schoolfinder sync
+func (h *APIHandler) SyncExport(w http.ResponseWriter, r *http.Request) {
+	var since time.Time
+	if s := r.URL.Query().Get("since"); s != "" {
+		var err error
+		if since, err = time.Parse(time.RFC3339, s); err != nil {
+			respondJSON(w, http.StatusBadRequest, map[string]string{
+				"error": fmt.Sprintf("invalid since %q: use an RFC 3339 time", s),
+			})
+			return
+		}
+	}
+
+	b, err := h.DB.exportSync("", since)
+	if err != nil {
+		respondError(w, err, "Failed to read rows to sync")
+		return
+	}
+	respondJSON(w, http.StatusOK, b)
+}
+
+// SyncImport merges a peer's changed rows from schoolfinder sync and returns
+// how many it changed. Batches are JSON of at most maxSyncBatchSize.
+func (h *APIHandler) SyncImport(w http.ResponseWriter, r *http.Request) {
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+		respondJSON(w, http.StatusUnsupportedMediaType, map[string]string{
+			"error": "Send the sync batch as application/json",
+		})
+		return
+	}
+
+	var b SyncBatch
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSyncBatchSize)).Decode(&b); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			respondJSON(w, http.StatusRequestEntityTooLarge, map[string]string{
+				"error": fmt.Sprintf("Sync batch is larger than %dMB", maxSyncBatchSize>>20),
+				"hint":  "Sync more often so fewer rows change between syncs.",
+			})
+			return
+		}
+		respondJSON(w, http.StatusBadRequest, map[string]string{
+			"error": "Invalid sync batch: " + err.Error(),
+		})
+		return
+	}
+
+	counts, err := h.DB.applySync("", &b)
+	if err != nil {
+		respondError(w, err, "Failed to merge synced rows")
+		return
+	}
+	respondJSON(w, http.StatusOK, counts)
+}
+
+// respondError sends err's user-facing message and hint as a JSON error response
+func respondError(w http.ResponseWriter, err error, failed string) {
+	ue := describeError(err, failed)
+	respondJSON(w, ue.Status, map[string]string{
+		"error": ue.Message,
+		"hint":  ue.Hint,
+	})
+}
+
+// apiRateLimited sends the rate limit error to an API client that's out of requests
+func apiRateLimited(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+	respondError(w, rateLimitedError(retryAfter), "Request failed")
+}
+
+// apiDenied sends the sign-in or permission error to an API client whose role
+// doesn't allow a request
+func apiDenied(w http.ResponseWriter, r *http.Request, ue *UserError) {
+	respondError(w, ue, "Request failed")
+}
+
+// Me reports the role the request was identified with and what it allows, so
+// clients can hide actions they can't take
+func (h *APIHandler) Me(w http.ResponseWriter, r *http.Request) {
+	role := requestRole(r)
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"role":      role,
+		"signed_in": requestSignedIn(r),
+		"can_edit":  role.CanEdit(),
+		"can_admin": role.CanAdmin(),
+	})
+}
+
 // respondJSON is a helper function to send JSON responses
 func respondJSON(w http.ResponseWriter, status int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
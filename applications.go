@@ -0,0 +1,442 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Application statuses, in the order a season usually goes
+const (
+	appPlanning   = "planning"
+	appApplied    = "applied"
+	appWaitlisted = "waitlisted"
+	appOffered    = "offered"
+	appAccepted   = "accepted"
+	appDeclined   = "declined"
+	appNotOffered = "not_offered"
+	appWithdrawn  = "withdrawn"
+)
+
+// applicationStatuses lists statuses in summary order
+var applicationStatuses = []string{appPlanning, appApplied, appWaitlisted, appOffered, appAccepted, appDeclined, appNotOffered, appWithdrawn}
+
+var applicationStatusLabels = map[string]string{
+	appPlanning:   "Planning to apply",
+	appApplied:    "Applied",
+	appWaitlisted: "Waitlisted",
+	appOffered:    "Offered a seat",
+	appAccepted:   "Accepted",
+	appDeclined:   "Declined offer",
+	appNotOffered: "Not offered",
+	appWithdrawn:  "Withdrawn",
+}
+
+// defaultReminderWindow is how far ahead application reminders look
+const defaultReminderWindow = 14 * 24 * time.Hour
+
+// Application is one school applied to, or planned, in a school choice season
+type Application struct {
+	ID         int64
+	Season     string // School year applied for, e.g. "2027-28"
+	NCESSCH    string
+	SchoolName string
+	Status     string
+	Priorities []string // Priority categories claimed, e.g. "sibling" or "neighborhood"
+	Seats      int      // Seats available, 0 if unknown
+	Applicants int      // Applicants for those seats, 0 if unknown
+	Weight     float64  // Lottery entries per applicant from priorities; 1 when unweighted
+	Notes      string
+	UpdatedAt  time.Time
+	Dates      []SchoolDate // The school's key dates, from the timeline
+}
+
+// StatusLabel is the status in words, e.g. "Offered a seat"
+func (a Application) StatusLabel() string {
+	return applicationStatusLabels[a.Status]
+}
+
+// Open reports whether the application still has steps ahead
+func (a Application) Open() bool {
+	return a.Status == appPlanning || a.Status == appApplied || a.Status == appWaitlisted || a.Status == appOffered
+}
+
+// Odds estimates the chance of an offer as seats times lottery weight over
+// applicants. It ignores how priority groups are drawn, so it's a rough guide,
+// and reports false when seats or applicants aren't known.
+func (a Application) Odds() (float64, bool) {
+	if a.Seats <= 0 || a.Applicants <= 0 {
+		return 0, false
+	}
+	weight := a.Weight
+	if weight <= 0 {
+		weight = 1
+	}
+	return math.Min(1, float64(a.Seats)*weight/float64(a.Applicants)), true
+}
+
+// OddsString formats Odds, e.g. "about 25%", or "" if unknown
+func (a Application) OddsString() string {
+	odds, ok := a.Odds()
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("about %.0f%%", odds*100)
+}
+
+// currentSeason is the school year families apply for at now: applications in
+// the fall and spring are for the school year starting next August
+func currentSeason(now time.Time) string {
+	start := now.Year()
+	if now.Month() >= time.August {
+		start++
+	}
+	return fmt.Sprintf("%d-%02d", start, (start+1)%100)
+}
+
+// seasonWindow is when a season's key dates fall: from the August a year before
+// the school year starts through its first September. ok is false if season
+// isn't a school year like "2027-28".
+func seasonWindow(season string) (from, to time.Time, ok bool) {
+	var start, end int
+	if _, err := fmt.Sscanf(season, "%d-%d", &start, &end); err != nil || end != (start+1)%100 {
+		return time.Time{}, time.Time{}, false
+	}
+	from = time.Date(start-1, time.August, 1, 0, 0, 0, 0, time.UTC)
+	to = time.Date(start, time.October, 1, 0, 0, 0, 0, time.UTC)
+	return from, to, true
+}
+
+// ParseApplicationStatus accepts a status by key or label in any case, with
+// spaces or dashes for underscores
+func ParseApplicationStatus(s string) (string, error) {
+	key := strings.ToLower(strings.NewReplacer(" ", "_", "-", "_").Replace(strings.TrimSpace(s)))
+	for _, status := range applicationStatuses {
+		if key == status || key == strings.ToLower(strings.ReplaceAll(applicationStatusLabels[status], " ", "_")) {
+			return status, nil
+		}
+	}
+	return "", fmt.Errorf("unknown application status %q (use one of: %s)", s, strings.Join(applicationStatuses, ", "))
+}
+
+// SaveApplication validates and saves an application, replacing the one for
+// the same school and season
+func SaveApplication(db *DB, app *Application) error {
+	status, err := ParseApplicationStatus(app.Status)
+	if err != nil {
+		return err
+	}
+	app.Status = status
+	if app.Season == "" {
+		app.Season = currentSeason(time.Now())
+	}
+	if _, _, ok := seasonWindow(app.Season); !ok {
+		return fmt.Errorf("invalid season %q: use a school year like %s", app.Season, currentSeason(time.Now()))
+	}
+	if app.Seats < 0 || app.Applicants < 0 {
+		return fmt.Errorf("seats and applicants can't be negative")
+	}
+	if app.Weight == 0 {
+		app.Weight = 1
+	}
+	if app.Weight < 0 {
+		return fmt.Errorf("lottery weight must be positive")
+	}
+	school, err := db.GetSchoolByID(app.NCESSCH)
+	if err != nil {
+		return err
+	}
+	app.NCESSCH = school.NCESSCH
+	app.SchoolName = school.Name
+
+	var priorities []string
+	for _, p := range app.Priorities {
+		if p = strings.TrimSpace(p); p != "" && !slices.Contains(priorities, p) {
+			priorities = append(priorities, p)
+		}
+	}
+	app.Priorities = priorities
+	prioritiesJSON, err := json.Marshal(priorities)
+	if err != nil {
+		return fmt.Errorf("failed to encode priorities: %w", err)
+	}
+
+	err = db.conn.QueryRow(`
+		INSERT INTO applications (season, ncessch, status, priorities, seats, applicants, weight, notes, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, now())
+		ON CONFLICT (season, ncessch) DO UPDATE SET
+			status = EXCLUDED.status,
+			priorities = EXCLUDED.priorities,
+			seats = EXCLUDED.seats,
+			applicants = EXCLUDED.applicants,
+			weight = EXCLUDED.weight,
+			notes = EXCLUDED.notes,
+			updated_at = EXCLUDED.updated_at
+		RETURNING id, updated_at
+	`, app.Season, app.NCESSCH, app.Status, string(prioritiesJSON), app.Seats, app.Applicants, app.Weight, app.Notes).Scan(&app.ID, &app.UpdatedAt)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to save application", "error", err, "ncessch", app.NCESSCH)
+		}
+		return fmt.Errorf("failed to save application: %w", err)
+	}
+	return nil
+}
+
+// Applications loads a season's applications by school name, each with the
+// school's key dates that fall in the season
+func (d *DB) Applications(season string) ([]Application, error) {
+	rows, err := d.conn.Query(`
+		SELECT id, season, ncessch, status, priorities::VARCHAR, seats, applicants, weight, COALESCE(notes, ''), updated_at
+		FROM applications
+		WHERE season = $1
+	`, season)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load applications: %w", err)
+	}
+	defer rows.Close()
+
+	var apps []Application
+	for rows.Next() {
+		var app Application
+		var priorities sql.NullString
+		if err := rows.Scan(&app.ID, &app.Season, &app.NCESSCH, &app.Status, &priorities, &app.Seats, &app.Applicants, &app.Weight, &app.Notes, &app.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan application: %w", err)
+		}
+		if priorities.Valid {
+			if err := json.Unmarshal([]byte(priorities.String), &app.Priorities); err != nil {
+				return nil, fmt.Errorf("failed to decode priorities: %w", err)
+			}
+		}
+		apps = append(apps, app)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	dates, err := d.SchoolDates("")
+	if err != nil {
+		return nil, err
+	}
+	from, to, _ := seasonWindow(season)
+	for i := range apps {
+		app := &apps[i]
+		app.SchoolName = app.NCESSCH
+		if school, err := d.GetSchoolByID(app.NCESSCH); err == nil {
+			app.SchoolName = school.Name
+		} else if !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		for _, sd := range dates {
+			if sd.NCESSCH == app.NCESSCH && !sd.Date.Before(from) && sd.Date.Before(to) {
+				app.Dates = append(app.Dates, sd)
+			}
+		}
+		// Timeline order is by kind; reminders and recaps read better by date
+		slices.SortStableFunc(app.Dates, func(a, b SchoolDate) int { return a.Date.Compare(b.Date) })
+	}
+
+	slices.SortFunc(apps, func(a, b Application) int {
+		return strings.Compare(a.SchoolName+a.NCESSCH, b.SchoolName+b.NCESSCH)
+	})
+	return apps, nil
+}
+
+// GetApplication loads the application to a school in a season
+func (d *DB) GetApplication(season, ncessch string) (*Application, error) {
+	apps, err := d.Applications(season)
+	if err != nil {
+		return nil, err
+	}
+	for i := range apps {
+		if apps[i].NCESSCH == ncessch {
+			return &apps[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no %s application for school %s: %w", season, ncessch, sql.ErrNoRows)
+}
+
+// SchoolApplications loads a school's applications in every season, oldest
+// season first, without the school name or key dates
+func (d *DB) SchoolApplications(ncessch string) ([]Application, error) {
+	rows, err := d.conn.Query(`
+		SELECT id, season, ncessch, status, seats, applicants, weight, COALESCE(notes, ''), updated_at
+		FROM applications
+		WHERE ncessch = $1
+		ORDER BY season
+	`, ncessch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load applications: %w", err)
+	}
+	defer rows.Close()
+
+	var apps []Application
+	for rows.Next() {
+		var app Application
+		if err := rows.Scan(&app.ID, &app.Season, &app.NCESSCH, &app.Status, &app.Seats, &app.Applicants, &app.Weight, &app.Notes, &app.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan application: %w", err)
+		}
+		apps = append(apps, app)
+	}
+	return apps, rows.Err()
+}
+
+// DeleteApplication removes the application to a school in a season
+func (d *DB) DeleteApplication(season, ncessch string) error {
+	result, err := d.conn.Exec(`DELETE FROM applications WHERE season = $1 AND ncessch = $2`, season, ncessch)
+	if err != nil {
+		return fmt.Errorf("failed to delete application: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("no %s application for school %s", season, ncessch)
+	}
+	return nil
+}
+
+// ApplicationStatusCount is how many applications have one status
+type ApplicationStatusCount struct {
+	Status string
+	Label  string
+	Count  int
+}
+
+// SummarizeApplications counts applications by status, in status order,
+// leaving out statuses no application has
+func SummarizeApplications(apps []Application) []ApplicationStatusCount {
+	var summary []ApplicationStatusCount
+	for _, status := range applicationStatuses {
+		count := 0
+		for _, app := range apps {
+			if app.Status == status {
+				count++
+			}
+		}
+		if count > 0 {
+			summary = append(summary, ApplicationStatusCount{Status: status, Label: applicationStatusLabels[status], Count: count})
+		}
+	}
+	return summary
+}
+
+// ApplicationReminder is something to do or watch for in an open application
+type ApplicationReminder struct {
+	Date       time.Time
+	NCESSCH    string
+	SchoolName string
+	Message    string
+	Overdue    bool // The date passed without the status moving on
+}
+
+// ApplicationReminders lists upcoming key dates of open applications within
+// window of now, and dates that passed without the application moving on,
+// oldest first
+func ApplicationReminders(apps []Application, now time.Time, window time.Duration) []ApplicationReminder {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	until := today.Add(window)
+
+	var reminders []ApplicationReminder
+	for _, app := range apps {
+		if !app.Open() {
+			continue
+		}
+		for _, sd := range app.Dates {
+			reminder := ApplicationReminder{Date: sd.Date, NCESSCH: app.NCESSCH, SchoolName: app.SchoolName}
+			day := sd.Date.Format("Mon, Jan 2")
+			switch {
+			case sd.Date.Before(today):
+				// Only dates that needed the status to change are worth flagging
+				switch {
+				case sd.Kind == dateDeadline && app.Status == appPlanning:
+					reminder.Message = "Application deadline passed on " + day + " but the application is still marked planning"
+				case sd.Kind == dateLottery && app.Status == appApplied:
+					reminder.Message = "Lottery was on " + day + "; record the outcome"
+				case sd.Kind == dateEnrollment && app.Status == appOffered:
+					reminder.Message = "Enrollment deadline passed on " + day + "; record whether you accepted"
+				default:
+					continue
+				}
+				reminder.Overdue = true
+			case sd.Date.After(until):
+				continue
+			case sd.Kind == dateEnrollment && app.Status == appOffered:
+				reminder.Message = "Accept or decline the offer by " + day
+			default:
+				reminder.Message = sd.Label() + " on " + day
+				if sd.Note != "" {
+					reminder.Message += ": " + sd.Note
+				}
+			}
+			reminders = append(reminders, reminder)
+		}
+	}
+
+	slices.SortStableFunc(reminders, func(a, b ApplicationReminder) int { return a.Date.Compare(b.Date) })
+	return reminders
+}
+
+// FormatSeasonRecap renders a season's applications as a markdown recap: the
+// outcome summary, then each school's status, priorities, odds, and dates
+func FormatSeasonRecap(season string, apps []Application) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# School Choice Season %s\n\n", season)
+
+	if len(apps) == 0 {
+		b.WriteString("No applications were recorded.\n")
+		return b.String()
+	}
+
+	var counts []string
+	for _, c := range SummarizeApplications(apps) {
+		counts = append(counts, fmt.Sprintf("%s: %d", c.Label, c.Count))
+	}
+	fmt.Fprintf(&b, "%d schools. %s.\n\n", len(apps), strings.Join(counts, ", "))
+
+	b.WriteString("| School | Status | Priorities | Odds |\n")
+	b.WriteString("|---|---|---|---|\n")
+	for _, app := range apps {
+		odds := app.OddsString()
+		if odds == "" {
+			odds = "—"
+		}
+		priorities := strings.Join(app.Priorities, ", ")
+		if priorities == "" {
+			priorities = "—"
+		}
+		fmt.Fprintf(&b, "| %s | %s | %s | %s |\n", escapeMarkdownTable(app.SchoolName), app.StatusLabel(), escapeMarkdownTable(priorities), odds)
+	}
+	b.WriteString("\n")
+
+	for _, app := range apps {
+		fmt.Fprintf(&b, "## %s\n\n", app.SchoolName)
+		fmt.Fprintf(&b, "- NCES ID: %s\n", app.NCESSCH)
+		fmt.Fprintf(&b, "- Status: %s\n", app.StatusLabel())
+		if len(app.Priorities) > 0 {
+			fmt.Fprintf(&b, "- Priorities: %s\n", strings.Join(app.Priorities, ", "))
+		}
+		if odds := app.OddsString(); odds != "" {
+			fmt.Fprintf(&b, "- Estimated odds: %s (%d seats, %d applicants, weight %g)\n", odds, app.Seats, app.Applicants, app.Weight)
+		}
+		for _, sd := range app.Dates {
+			line := fmt.Sprintf("- %s: %s", sd.Label(), sd.Date.Format("Jan 2, 2006"))
+			if sd.Note != "" {
+				line += " (" + sd.Note + ")"
+			}
+			b.WriteString(line + "\n")
+		}
+		if app.Notes != "" {
+			fmt.Fprintf(&b, "\n%s\n", app.Notes)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// escapeMarkdownTable keeps a value from breaking out of a table cell
+func escapeMarkdownTable(s string) string {
+	return strings.ReplaceAll(s, "|", `\|`)
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCurrentSeason(t *testing.T) {
+	tests := map[time.Time]string{
+		time.Date(2026, time.October, 17, 0, 0, 0, 0, time.UTC):  "2027-28",
+		time.Date(2027, time.March, 1, 0, 0, 0, 0, time.UTC):     "2027-28",
+		time.Date(2027, time.August, 1, 0, 0, 0, 0, time.UTC):    "2028-29",
+		time.Date(2099, time.September, 1, 0, 0, 0, 0, time.UTC): "2100-01",
+	}
+	for now, want := range tests {
+		if got := currentSeason(now); got != want {
+			t.Errorf("currentSeason(%s) = %q, want %q", now.Format(timelineDateLayout), got, want)
+		}
+	}
+
+	from, to, ok := seasonWindow("2027-28")
+	if !ok || from.Format(timelineDateLayout) != "2026-08-01" || to.Format(timelineDateLayout) != "2027-10-01" {
+		t.Errorf("seasonWindow(2027-28) = %s, %s, %v", from, to, ok)
+	}
+	for _, season := range []string{"2027", "2027-29", "fall"} {
+		if _, _, ok := seasonWindow(season); ok {
+			t.Errorf("seasonWindow(%q) accepted", season)
+		}
+	}
+}
+
+func TestApplicationOdds(t *testing.T) {
+	tests := []struct {
+		app  Application
+		want string
+	}{
+		{Application{Seats: 60, Applicants: 400, Weight: 1}, "about 15%"},
+		{Application{Seats: 60, Applicants: 400, Weight: 2}, "about 30%"},
+		{Application{Seats: 60, Applicants: 40, Weight: 1}, "about 100%"},
+		{Application{Seats: 60, Weight: 1}, ""},
+	}
+	for _, tt := range tests {
+		if got := tt.app.OddsString(); got != tt.want {
+			t.Errorf("%d seats, %d applicants, weight %g: odds %q, want %q", tt.app.Seats, tt.app.Applicants, tt.app.Weight, got, tt.want)
+		}
+	}
+}
+
+func TestSaveApplication(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	for _, app := range []Application{
+		{Season: "2027-28", NCESSCH: "360000100001", Status: "pending"},
+		{Season: "2027", NCESSCH: "360000100001", Status: appApplied},
+		{Season: "2027-28", NCESSCH: "360000100001", Status: appApplied, Seats: -1},
+		{Season: "2027-28", NCESSCH: "360000100001", Status: appApplied, Weight: -2},
+		{Season: "2027-28", NCESSCH: "999999999999", Status: appApplied},
+	} {
+		if err := SaveApplication(db, &app); err == nil {
+			t.Errorf("SaveApplication(%+v) succeeded", app)
+		}
+	}
+
+	app := &Application{Season: "2027-28", NCESSCH: "360000100001", Status: "Applied", Priorities: []string{"sibling", " sibling", ""}}
+	if err := SaveApplication(db, app); err != nil {
+		t.Fatal(err)
+	}
+	if app.Status != appApplied || app.Weight != 1 || strings.Join(app.Priorities, ",") != "sibling" {
+		t.Errorf("saved application = %+v", app)
+	}
+
+	app.Status = appOffered
+	app.Priorities = append(app.Priorities, "neighborhood")
+	if err := SaveApplication(db, app); err != nil {
+		t.Fatal(err)
+	}
+	apps, err := db.Applications("2027-28")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(apps) != 1 || apps[0].Status != appOffered || apps[0].SchoolName != "Lincoln Elementary School" || strings.Join(apps[0].Priorities, ",") != "sibling,neighborhood" {
+		t.Errorf("applications after update = %+v", apps)
+	}
+	if other, _ := db.Applications("2028-29"); len(other) != 0 {
+		t.Errorf("2028-29 has %d applications", len(other))
+	}
+
+	if err := db.DeleteApplication("2027-28", "360000100001"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.DeleteApplication("2027-28", "360000100001"); err == nil {
+		t.Error("deleted a missing application")
+	}
+}
+
+func TestApplicationReminders(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	for _, tt := range []struct{ id, kind, date, note string }{
+		{"360000100001", dateDeadline, "2027-01-15", "Round 1"},
+		{"360000100001", dateLottery, "2027-02-20", ""},
+		{"360000100001", dateDeadline, "2025-01-15", "Last season"},
+		{"360000100002", dateDeadline, "2027-01-10", ""},
+		{"360000100002", dateEnrollment, "2027-03-15", ""},
+	} {
+		if _, err := AddSchoolDate(db, tt.id, tt.kind, tt.date, tt.note); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, app := range []Application{
+		{Season: "2027-28", NCESSCH: "360000100001", Status: appPlanning},
+		{Season: "2027-28", NCESSCH: "360000100002", Status: appOffered, Seats: 100, Applicants: 250},
+	} {
+		if err := SaveApplication(db, &app); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	apps, err := db.Applications("2027-28")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(apps[0].Dates) != 2 {
+		t.Errorf("Lincoln has %d dates in season, want 2", len(apps[0].Dates))
+	}
+
+	now := time.Date(2027, time.March, 5, 9, 0, 0, 0, time.UTC)
+	var got []string
+	for _, r := range ApplicationReminders(apps, now, defaultReminderWindow) {
+		got = append(got, r.Date.Format(timelineDateLayout)+" "+r.SchoolName+": "+r.Message)
+		if r.Overdue != r.Date.Before(now) {
+			t.Errorf("reminder %q overdue = %v", r.Message, r.Overdue)
+		}
+	}
+	want := []string{
+		"2027-01-15 Lincoln Elementary School: Application deadline passed on Fri, Jan 15 but the application is still marked planning",
+		"2027-03-15 Washington High School: Accept or decline the offer by Mon, Mar 15",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("reminders:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	summary := SummarizeApplications(apps)
+	if len(summary) != 2 || summary[0].Status != appPlanning || summary[1].Status != appOffered {
+		t.Errorf("summary = %+v", summary)
+	}
+
+	recap := FormatSeasonRecap("2027-28", apps)
+	for _, want := range []string{
+		"# School Choice Season 2027-28\n\n2 schools. Planning to apply: 1, Offered a seat: 1.\n",
+		"| Washington High School | Offered a seat | — | about 40% |\n",
+		"## Lincoln Elementary School\n\n- NCES ID: 360000100001\n- Status: Planning to apply\n- Application deadline: Jan 15, 2027 (Round 1)\n- Lottery: Feb 20, 2027\n",
+		"- Estimated odds: about 40% (100 seats, 250 applicants, weight 1)\n",
+	} {
+		if !strings.Contains(recap, want) {
+			t.Errorf("recap is missing %q:\n%s", want, recap)
+		}
+	}
+}
+
+func TestWebApplications(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	router := NewRouter(ServerConfig{DB: db})
+
+	app := &Application{Season: "2027-28", NCESSCH: "360000100001", Status: appWaitlisted, Priorities: []string{"sibling"}, Notes: "Position 12"}
+	if err := SaveApplication(db, app); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/applications?season=2027-28", nil))
+	body := rec.Body.String()
+	if rec.Code != 200 || !strings.Contains(body, `<a href="/schools/360000100001">Lincoln Elementary School</a>`) || !strings.Contains(body, "<strong>1</strong> Waitlisted") || !strings.Contains(body, "Position 12") {
+		t.Errorf("applications page = %d\n%s", rec.Code, body)
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/applications?season=next", nil))
+	if rec.Code != 400 {
+		t.Errorf("invalid season = %d, want 400", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/applications/recap.md?season=2027-28", nil))
+	if rec.Code != 200 || !strings.Contains(rec.Header().Get("Content-Disposition"), "applications-2027-28.md") || !strings.Contains(rec.Body.String(), "| Lincoln Elementary School | Waitlisted | sibling | — |") {
+		t.Errorf("recap = %d %s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,531 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Kinds of area with a summary page
+const (
+	AreaZip    = "zip"
+	AreaCBSA   = "cbsa"
+	AreaCounty = "county"
+	AreaCD     = "cd" // Congressional district
+)
+
+// geocodeFilePattern matches NCES EDGE public school geocode files saved as CSV,
+// which supply each school's core-based statistical area (CBSA), county, and
+// congressional district
+const geocodeFilePattern = "EDGE_GEOCODE_PUBLICSCH_*.csv"
+
+// schoolLevelOrder is the display order of CCD school levels
+var schoolLevelOrder = []string{"Prekindergarten", "Elementary", "Middle", "Secondary", "High", "Other", "Ungraded", "Not applicable", "Not reported"}
+
+// AreaLevelCount is the number of schools in an area at one school level
+type AreaLevelCount struct {
+	Level string
+	Count int
+}
+
+// EnrollmentDistribution is the five-number summary of school enrollments, for a box plot
+type EnrollmentDistribution struct {
+	Schools int // Schools reporting enrollment
+	Min     float64
+	Q1      float64
+	Median  float64
+	Q3      float64
+	Max     float64
+}
+
+// position returns v's position between Min and Max as a percentage
+func (e EnrollmentDistribution) position(v float64) float64 {
+	if e.Max == e.Min {
+		return 50
+	}
+	return (v - e.Min) / (e.Max - e.Min) * 100
+}
+
+// BoxLeft is the left edge of the box plot's box, as a percentage of the plot width
+func (e EnrollmentDistribution) BoxLeft() float64 {
+	return e.position(e.Q1)
+}
+
+// BoxWidth is the width of the box plot's box, as a percentage of the plot width
+func (e EnrollmentDistribution) BoxWidth() float64 {
+	return e.position(e.Q3) - e.position(e.Q1)
+}
+
+// MedianLeft is the position of the median line, as a percentage of the plot width
+func (e EnrollmentDistribution) MedianLeft() float64 {
+	return e.position(e.Median)
+}
+
+// AreaState is a state with schools in an area, for NAEP context
+type AreaState struct {
+	Code    string
+	Name    string
+	Schools int
+	NAEP    []AreaNAEPScore // Most recent cached state results
+	// NAEPSchoolID is the school in the area whose NAEP fetch covers the most
+	// grades, used to load the state's results when none are cached
+	NAEPSchoolID string
+	NAEPError    string // Why loading the state's results failed
+}
+
+// AreaNAEPScore is a state's most recent NAEP result for a subject and grade
+type AreaNAEPScore struct {
+	Subject            string
+	Grade              int
+	Year               int
+	MeanScore          float64
+	AtProficient       float64
+	NationalMeanScore  float64 // Zero when no national result is cached
+	NationalProficient float64
+}
+
+// AreaSummary aggregates the schools in a zip code, metro area, county, or
+// congressional district
+type AreaSummary struct {
+	Kind               string
+	Code               string
+	Name               string
+	SchoolCount        int
+	Levels             []AreaLevelCount
+	Enrollment         EnrollmentDistribution
+	TotalEnrollment    int64
+	Teachers           float64 // Full-time equivalent teachers at schools reporting them
+	StudentsPerTeacher float64 // Pooled across schools reporting both; zero when none do
+	RatioSchools       int     // Schools reporting both enrollment and teachers
+	States             []AreaState
+	Schools            []School // At most maxAreaSchools, by name
+}
+
+// Title names the area for headings, e.g. "ZIP 97214", "Portland-Vancouver-Hillsboro, OR-WA",
+// "Cook County, IL", or "Illinois's 7th Congressional District"
+func (a *AreaSummary) Title() string {
+	if a.Name != "" && a.Kind != AreaZip {
+		return a.Name
+	}
+	switch a.Kind {
+	case AreaCBSA:
+		return "Metro area " + a.Code
+	case AreaCounty:
+		return "County " + a.Code
+	case AreaCD:
+		return "Congressional district " + a.Code
+	}
+	return "ZIP " + a.Code
+}
+
+// KindLabel names the kind of area with its code, e.g. "CBSA 38900" or "County FIPS 17031"
+func (a *AreaSummary) KindLabel() string {
+	switch a.Kind {
+	case AreaCBSA:
+		return "CBSA " + a.Code
+	case AreaCounty:
+		return "County FIPS " + a.Code
+	case AreaCD:
+		return "Congressional district " + a.Code
+	}
+	return "Zip code"
+}
+
+// normalizeAreaCode validates a zip, CBSA, county FIPS, or congressional district
+// code. Zip+4 codes are shortened to five digits. Congressional districts are
+// either four digits, the state FIPS code and district number, or a state and
+// district number, normalized to e.g. "IL-07" ("AK-00" for at-large).
+func normalizeAreaCode(kind, code string) (string, error) {
+	code = strings.TrimSpace(code)
+	switch kind {
+	case AreaZip:
+		code, _, _ = strings.Cut(code, "-")
+	case AreaCD:
+		return normalizeCongressionalDistrict(code)
+	}
+	if len(code) != 5 || strings.Trim(code, "0123456789") != "" {
+		switch kind {
+		case AreaCBSA:
+			return "", fmt.Errorf("invalid CBSA code %q (expected five digits)", code)
+		case AreaCounty:
+			return "", fmt.Errorf("invalid county FIPS code %q (expected five digits)", code)
+		}
+		return "", fmt.Errorf("invalid zip code %q (expected five digits)", code)
+	}
+	return code, nil
+}
+
+// normalizeCongressionalDistrict accepts "0612", "CA-12", "ca12", or "AK-AL"
+func normalizeCongressionalDistrict(code string) (string, error) {
+	invalid := fmt.Errorf("invalid congressional district %q (expected e.g. IL-07 or four digits)", code)
+	if len(code) == 4 && strings.Trim(code, "0123456789") == "" {
+		return code, nil
+	}
+	code = strings.ToUpper(code)
+	if len(code) < 3 || strings.Trim(code[:2], "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "" {
+		return "", invalid
+	}
+	state, number := code[:2], strings.TrimPrefix(code[2:], "-")
+	if number == "AL" {
+		number = "00"
+	}
+	if number == "" || len(number) > 2 || strings.Trim(number, "0123456789") != "" {
+		return "", invalid
+	}
+	return state + "-" + fmt.Sprintf("%02s", number), nil
+}
+
+// congressionalDistrictName names a district from its state and code, e.g.
+// "Illinois's 7th Congressional District"; 00 and 98 are at-large seats and
+// non-voting delegates
+func congressionalDistrictName(stateName, cd string) string {
+	number := strings.TrimLeft(cd[len(cd)-2:], "0")
+	switch number {
+	case "", "98":
+		return stateName + "'s At-Large Congressional District"
+	}
+	suffix := "th"
+	if n := len(number); n < 2 || number[n-2] != '1' {
+		switch number[n-1] {
+		case '1':
+			suffix = "st"
+		case '2':
+			suffix = "nd"
+		case '3':
+			suffix = "rd"
+		}
+	}
+	return stateName + "'s " + number + suffix + " Congressional District"
+}
+
+// LoadAreaSummary summarizes the schools in a zip code (AreaZip), core-based
+// statistical area (AreaCBSA), county (AreaCounty), or congressional district
+// (AreaCD), with cached NAEP results for each state. It returns an error
+// wrapping sql.ErrNoRows when the area has no schools.
+func LoadAreaSummary(db *DB, kind, code string) (*AreaSummary, error) {
+	code, err := normalizeAreaCode(kind, code)
+	if err != nil {
+		return nil, err
+	}
+
+	var schools []School
+	var name string
+	switch kind {
+	case AreaZip:
+		schools, err = db.GetSchoolsByZip(code, maxAreaStatsSchools)
+	case AreaCBSA:
+		schools, err = db.GetSchoolsByCBSA(code, maxAreaStatsSchools)
+		if err == nil && len(schools) > 0 {
+			name, err = db.CBSAName(code)
+		}
+	case AreaCounty:
+		schools, err = db.GetSchoolsByCounty(code, maxAreaStatsSchools)
+		if err == nil && len(schools) > 0 {
+			name, err = db.CountyName(code)
+			if name != "" {
+				name += ", " + schools[0].State
+			}
+		}
+	case AreaCD:
+		if code, err = db.congressionalDistrictCode(code); err != nil {
+			return nil, err
+		}
+		schools, err = db.GetSchoolsByCongressionalDistrict(code, maxAreaStatsSchools)
+		if err == nil && len(schools) > 0 {
+			name = congressionalDistrictName(schools[0].StateName, code)
+			code = schools[0].State + "-" + code[2:]
+		}
+	default:
+		return nil, fmt.Errorf("unknown area kind %q", kind)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(schools) == 0 {
+		switch kind {
+		case AreaCBSA:
+			return nil, fmt.Errorf("no schools in CBSA %s (metro areas need an %s file in the data directory): %w", code, geocodeFilePattern, sql.ErrNoRows)
+		case AreaCounty:
+			return nil, fmt.Errorf("no schools in county %s (counties need an %s file in the data directory): %w", code, geocodeFilePattern, sql.ErrNoRows)
+		case AreaCD:
+			return nil, fmt.Errorf("no schools in congressional district %s (districts need an %s file in the data directory): %w", code, geocodeFilePattern, sql.ErrNoRows)
+		}
+		return nil, fmt.Errorf("no schools in zip code %s: %w", code, sql.ErrNoRows)
+	}
+
+	summary := summarizeArea(kind, code, name, schools)
+	for i := range summary.States {
+		state := &summary.States[i]
+		state.NAEP, err = StateNAEPContext(db, state.Code)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(summary.Schools) > maxAreaSchools {
+		summary.Schools = summary.Schools[:maxAreaSchools]
+	}
+	return summary, nil
+}
+
+// summarizeArea computes level counts, the enrollment distribution, and the pooled
+// student/teacher ratio for an area's schools
+func summarizeArea(kind, code, name string, schools []School) *AreaSummary {
+	summary := &AreaSummary{Kind: kind, Code: code, Name: name, SchoolCount: len(schools), Schools: schools}
+
+	levelCounts := make(map[string]int)
+	stateIndex := make(map[string]int)
+	var enrollments []float64
+	var ratioStudents, ratioTeachers float64
+	naepClient := &NAEPClient{}
+	naepGrades := make(map[string]int) // NAEP grades covered by each state's NAEPSchoolID
+
+	for i := range schools {
+		s := &schools[i]
+
+		level := strings.TrimSpace(s.Level.String)
+		if level == "" {
+			level = "Not reported"
+		}
+		levelCounts[level]++
+
+		if s.Teachers.Valid && s.Teachers.Float64 > 0 {
+			summary.Teachers += s.Teachers.Float64
+		}
+		if s.Enrollment.Valid && s.Enrollment.Int64 > 0 {
+			enrollments = append(enrollments, float64(s.Enrollment.Int64))
+			summary.TotalEnrollment += s.Enrollment.Int64
+			if s.Teachers.Valid && s.Teachers.Float64 > 0 {
+				ratioStudents += float64(s.Enrollment.Int64)
+				ratioTeachers += s.Teachers.Float64
+				summary.RatioSchools++
+			}
+		}
+
+		idx, ok := stateIndex[s.State]
+		if !ok {
+			idx = len(summary.States)
+			stateIndex[s.State] = idx
+			summary.States = append(summary.States, AreaState{Code: s.State, Name: s.StateName})
+		}
+		summary.States[idx].Schools++
+		if grades := len(naepClient.determineGrades(s)); grades > naepGrades[s.State] {
+			naepGrades[s.State] = grades
+			summary.States[idx].NAEPSchoolID = s.NCESSCH
+		}
+	}
+
+	for _, level := range schoolLevelOrder {
+		if count, ok := levelCounts[level]; ok {
+			summary.Levels = append(summary.Levels, AreaLevelCount{Level: level, Count: count})
+			delete(levelCounts, level)
+		}
+	}
+	var unknown []string
+	for level := range levelCounts {
+		unknown = append(unknown, level)
+	}
+	sort.Strings(unknown)
+	for _, level := range unknown {
+		summary.Levels = append(summary.Levels, AreaLevelCount{Level: level, Count: levelCounts[level]})
+	}
+
+	summary.Enrollment = enrollmentDistribution(enrollments)
+	if ratioTeachers > 0 {
+		summary.StudentsPerTeacher = ratioStudents / ratioTeachers
+	}
+
+	sort.Slice(summary.States, func(i, j int) bool {
+		if summary.States[i].Schools != summary.States[j].Schools {
+			return summary.States[i].Schools > summary.States[j].Schools
+		}
+		return summary.States[i].Code < summary.States[j].Code
+	})
+	return summary
+}
+
+// enrollmentDistribution returns the five-number summary of enrollments, using
+// linear interpolation between values for the quartiles
+func enrollmentDistribution(enrollments []float64) EnrollmentDistribution {
+	if len(enrollments) == 0 {
+		return EnrollmentDistribution{}
+	}
+
+	sorted := append([]float64(nil), enrollments...)
+	sort.Float64s(sorted)
+
+	quantile := func(q float64) float64 {
+		pos := q * float64(len(sorted)-1)
+		lower := int(pos)
+		if lower+1 >= len(sorted) {
+			return sorted[lower]
+		}
+		return sorted[lower] + (pos-float64(lower))*(sorted[lower+1]-sorted[lower])
+	}
+
+	return EnrollmentDistribution{
+		Schools: len(sorted),
+		Min:     sorted[0],
+		Q1:      quantile(0.25),
+		Median:  quantile(0.5),
+		Q3:      quantile(0.75),
+		Max:     sorted[len(sorted)-1],
+	}
+}
+
+// StateNAEPContext returns a state's most recent cached NAEP result for each subject
+// and grade, with the national result for comparison. It never calls the NAEP API;
+// results are cached as school pages load them.
+func StateNAEPContext(db *DB, state string) ([]AreaNAEPScore, error) {
+	stateJSON, nationalJSON, err := db.NAEPStateScores(state)
+	if err != nil {
+		return nil, err
+	}
+
+	// latest keeps the most recent score for each subject and grade
+	latest := func(rows [][]byte) map[string]NAEPScore {
+		byKey := make(map[string]NAEPScore)
+		for _, row := range rows {
+			var scores []NAEPScore
+			if err := json.Unmarshal(row, &scores); err != nil {
+				if logger != nil {
+					logger.Warn("Skipping unreadable cached NAEP scores", "error", err, "state", state)
+				}
+				continue
+			}
+			for _, score := range scores {
+				if score.ErrorCode != 0 {
+					continue
+				}
+				key := fmt.Sprintf("%s-%d", score.Subject, score.Grade)
+				if current, ok := byKey[key]; !ok || score.Year > current.Year {
+					byKey[key] = score
+				}
+			}
+		}
+		return byKey
+	}
+
+	stateScores := latest(stateJSON)
+	nationalScores := latest(nationalJSON)
+
+	var context []AreaNAEPScore
+	for key, score := range stateScores {
+		result := AreaNAEPScore{
+			Subject:      score.Subject,
+			Grade:        score.Grade,
+			Year:         score.Year,
+			MeanScore:    score.MeanScore,
+			AtProficient: score.AtProficient,
+		}
+		if national, ok := nationalScores[key]; ok && national.Year == score.Year {
+			result.NationalMeanScore = national.MeanScore
+			result.NationalProficient = national.AtProficient
+		}
+		context = append(context, result)
+	}
+
+	sort.Slice(context, func(i, j int) bool {
+		if context[i].Grade != context[j].Grade {
+			return context[i].Grade < context[j].Grade
+		}
+		return context[i].Subject < context[j].Subject
+	})
+	return context, nil
+}
+
+// AreaRollup totals the schools in a county or congressional district
+type AreaRollup struct {
+	OverviewStat         // Key is the county FIPS code or the district, e.g. "IL-07"
+	Teachers     float64 // Full-time equivalent
+}
+
+// AreaRollups totals schools, charter schools, students, and teachers for each
+// county (AreaCounty) or congressional district (AreaCD), in one state or all
+// of them, by state and name. Ratios pool the schools reporting both students and
+// teachers, like the statistics overview; merged duplicates aren't counted.
+func (d *DB) AreaRollups(kind, state string) ([]AreaRollup, error) {
+	var area, join, order string
+	switch kind {
+	case AreaCounty:
+		area = `c.county AS key, COALESCE(c.county_name, 'County ' || c.county) AS name`
+		join = `JOIN school_counties c ON c.ncessch = d.NCESSCH`
+		order = `any_value(state), any_value(name), key`
+	case AreaCD:
+		area = `d.ST || '-' || right(c.cd, 2) AS key, c.cd AS name`
+		join = `JOIN school_congressional_districts c ON c.ncessch = d.NCESSCH`
+		order = `key`
+	default:
+		return nil, fmt.Errorf("no rollups for area kind %q", kind)
+	}
+	rows, err := d.conn.Query(`
+		WITH schools AS (
+			SELECT `+area+`, d.ST AS state, COALESCE(d.STATENAME, d.ST) AS state_name,
+				COALESCE(d.CHARTER_TEXT = 'Yes', false) AS charter,
+				NULLIF(TRY_CAST(e.STUDENT_COUNT AS DOUBLE), 0) AS students,
+				NULLIF(TRY_CAST(t.TEACHERS AS DOUBLE), 0) AS teachers
+			FROM directory d
+			`+join+`
+			LEFT JOIN teachers t ON d.NCESSCH = t.NCESSCH
+			LEFT JOIN enrollment e ON d.NCESSCH = e.NCESSCH AND e.TOTAL_INDICATOR = 'Education Unit Total'
+			WHERE ($1 = '' OR d.ST = $1) AND `+notMergedCondition+`
+		)
+		SELECT key, any_value(name), any_value(state), any_value(state_name), count(*), count(*) FILTER (WHERE charter),
+			COALESCE(sum(students), 0)::BIGINT, COALESCE(sum(teachers), 0),
+			COALESCE(sum(students) FILTER (WHERE teachers > 0) / NULLIF(sum(teachers) FILTER (WHERE students > 0), 0), 0)
+		FROM schools
+		GROUP BY key
+		ORDER BY `+order+`
+	`, strings.ToUpper(state))
+	if err != nil {
+		return nil, fmt.Errorf("failed to total schools by %s: %w", kind, err)
+	}
+	defer rows.Close()
+
+	var rollups []AreaRollup
+	for rows.Next() {
+		var r AreaRollup
+		var stateName string
+		if err := rows.Scan(&r.Key, &r.Name, &r.State, &stateName, &r.Schools, &r.CharterSchools, &r.Students, &r.Teachers, &r.Ratio); err != nil {
+			return nil, fmt.Errorf("failed to scan %s totals: %w", kind, err)
+		}
+		if kind == AreaCD {
+			r.Name = congressionalDistrictName(stateName, r.Name)
+		}
+		rollups = append(rollups, r)
+	}
+	return rollups, rows.Err()
+}
+
+// SyncGeocodes loads any new EDGE geocode files in the data directory and returns
+// the number of new files loaded. Counties and congressional districts in the
+// CCD directory fill in for schools the files don't cover.
+func SyncGeocodes(db *DB) (int, error) {
+	if err := db.loadDirectoryGeographies(); err != nil {
+		return 0, err
+	}
+
+	paths, err := filepath.Glob(filepath.Join(db.dataDir, geocodeFilePattern))
+	if err != nil {
+		return 0, fmt.Errorf("failed to list geocode files: %w", err)
+	}
+
+	loaded := 0
+	for _, path := range paths {
+		isNew, err := db.loadGeocodeFile(path)
+		if err != nil {
+			return loaded, err
+		}
+		if isNew {
+			loaded++
+		}
+	}
+
+	if loaded > 0 && logger != nil {
+		logger.Info("School geocodes loaded", "files", loaded)
+	}
+	return loaded, nil
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNormalizeAreaCode(t *testing.T) {
+	tests := []struct {
+		kind    string
+		code    string
+		want    string
+		wantErr bool
+	}{
+		{AreaZip, "97214", "97214", false},
+		{AreaZip, " 97214-1234 ", "97214", false},
+		{AreaZip, "9721", "", true},
+		{AreaZip, "abcde", "", true},
+		{AreaCBSA, "38900", "38900", false},
+		{AreaCBSA, "38900-1", "", true},
+		{AreaCounty, "17031", "17031", false},
+		{AreaCounty, "1703", "", true},
+		{AreaCD, "1707", "1707", false},
+		{AreaCD, "il-7", "IL-07", false},
+		{AreaCD, "IL07", "IL-07", false},
+		{AreaCD, "AK-AL", "AK-00", false},
+		{AreaCD, "IL-123", "", true},
+		{AreaCD, "Illinois", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := normalizeAreaCode(tt.kind, tt.code)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("normalizeAreaCode(%q, %q) error = %v, wantErr %v", tt.kind, tt.code, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("normalizeAreaCode(%q, %q) = %q, want %q", tt.kind, tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestCongressionalDistrictName(t *testing.T) {
+	for cd, want := range map[string]string{
+		"1701": "Illinois's 1st Congressional District",
+		"1707": "Illinois's 7th Congressional District",
+		"1712": "Illinois's 12th Congressional District",
+		"1723": "Illinois's 23rd Congressional District",
+		"1700": "Illinois's At-Large Congressional District",
+		"1798": "Illinois's At-Large Congressional District",
+	} {
+		if got := congressionalDistrictName("Illinois", cd); got != want {
+			t.Errorf("congressionalDistrictName(%q) = %q, want %q", cd, got, want)
+		}
+	}
+}
+
+func TestEnrollmentDistribution(t *testing.T) {
+	tests := []struct {
+		name        string
+		enrollments []float64
+		want        EnrollmentDistribution
+	}{
+		{"empty", nil, EnrollmentDistribution{}},
+		{"single school", []float64{400}, EnrollmentDistribution{Schools: 1, Min: 400, Q1: 400, Median: 400, Q3: 400, Max: 400}},
+		{"interpolated quartiles", []float64{500, 100, 400, 200, 300}, EnrollmentDistribution{Schools: 5, Min: 100, Q1: 200, Median: 300, Q3: 400, Max: 500}},
+		{"even count", []float64{100, 200, 300, 400}, EnrollmentDistribution{Schools: 4, Min: 100, Q1: 175, Median: 250, Q3: 325, Max: 400}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := enrollmentDistribution(tt.enrollments); got != tt.want {
+				t.Errorf("enrollmentDistribution() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	e := EnrollmentDistribution{Schools: 5, Min: 100, Q1: 200, Median: 300, Q3: 400, Max: 500}
+	if e.BoxLeft() != 25 || e.BoxWidth() != 50 || e.MedianLeft() != 50 {
+		t.Errorf("box plot positions = %v, %v, %v, want 25, 50, 50", e.BoxLeft(), e.BoxWidth(), e.MedianLeft())
+	}
+}
+
+func TestSummarizeArea(t *testing.T) {
+	school := func(id, state, level, low, high string, enrollment int64, teachers float64) School {
+		s := School{NCESSCH: id, State: state, StateName: state, Level: sql.NullString{String: level, Valid: level != ""}}
+		s.GradeLow = sql.NullString{String: low, Valid: low != ""}
+		s.GradeHigh = sql.NullString{String: high, Valid: high != ""}
+		if enrollment > 0 {
+			s.Enrollment = sql.NullInt64{Int64: enrollment, Valid: true}
+		}
+		if teachers > 0 {
+			s.Teachers = sql.NullFloat64{Float64: teachers, Valid: true}
+		}
+		return s
+	}
+
+	schools := []School{
+		school("1", "OR", "High", "09", "12", 1200, 60),
+		school("2", "OR", "Elementary", "KG", "05", 400, 20),
+		school("3", "WA", "Elementary", "KG", "05", 300, 0),
+		school("4", "OR", "Middle", "06", "08", 0, 30),
+		school("5", "OR", "Elementary", "KG", "08", 500, 25),
+		school("6", "OR", "", "", "", 0, 0),
+	}
+
+	area := summarizeArea(AreaZip, "97214", "", schools)
+
+	if area.SchoolCount != 6 || area.Title() != "ZIP 97214" {
+		t.Errorf("SchoolCount = %d, Title = %q", area.SchoolCount, area.Title())
+	}
+
+	wantLevels := []AreaLevelCount{{"Elementary", 3}, {"Middle", 1}, {"High", 1}, {"Not reported", 1}}
+	if len(area.Levels) != len(wantLevels) {
+		t.Fatalf("Levels = %+v, want %+v", area.Levels, wantLevels)
+	}
+	for i, want := range wantLevels {
+		if area.Levels[i] != want {
+			t.Errorf("Levels[%d] = %+v, want %+v", i, area.Levels[i], want)
+		}
+	}
+
+	if area.TotalEnrollment != 2400 || area.Enrollment.Schools != 4 || area.Enrollment.Median != 450 {
+		t.Errorf("enrollment = %d over %+v", area.TotalEnrollment, area.Enrollment)
+	}
+
+	// Pooled over the three schools reporting both: 2100 students / 105 teachers
+	if area.RatioSchools != 3 || math.Abs(area.StudentsPerTeacher-20) > 0.001 {
+		t.Errorf("ratio = %.2f over %d schools, want 20 over 3", area.StudentsPerTeacher, area.RatioSchools)
+	}
+
+	if len(area.States) != 2 || area.States[0].Code != "OR" || area.States[0].Schools != 5 || area.States[1].Code != "WA" {
+		t.Fatalf("States = %+v", area.States)
+	}
+	// The K-8 school covers both NAEP grades
+	if area.States[0].NAEPSchoolID != "5" || area.States[1].NAEPSchoolID != "3" {
+		t.Errorf("NAEPSchoolID = %q, %q, want 5, 3", area.States[0].NAEPSchoolID, area.States[1].NAEPSchoolID)
+	}
+}
+
+func TestLoadAreaSummary(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	t.Run("zip", func(t *testing.T) {
+		area, err := LoadAreaSummary(db, AreaZip, "94102")
+		if err != nil {
+			t.Fatalf("LoadAreaSummary() error = %v", err)
+		}
+		if area.SchoolCount != 1 || area.Schools[0].NCESSCH != "360000100001" {
+			t.Errorf("schools = %+v", area.Schools)
+		}
+		if len(area.States) != 1 || area.States[0].Code != "CA" || len(area.States[0].NAEP) != 0 {
+			t.Errorf("States = %+v", area.States)
+		}
+	})
+
+	t.Run("empty zip", func(t *testing.T) {
+		if _, err := LoadAreaSummary(db, AreaZip, "00000"); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("LoadAreaSummary() error = %v, want sql.ErrNoRows", err)
+		}
+	})
+
+	t.Run("metro area", func(t *testing.T) {
+		geocodes := "NCESSCH,NAME,CBSA,NMCBSA\n" +
+			"360000100001,Lincoln Elementary School,41860,\"San Francisco-Oakland-Berkeley, CA\"\n" +
+			"360000100002,Washington High School,31080,\"Los Angeles-Long Beach-Anaheim, CA\"\n" +
+			"360000100003,Jefferson Middle School,N,N\n"
+		if err := os.WriteFile(filepath.Join(db.dataDir, "EDGE_GEOCODE_PUBLICSCH_2324.csv"), []byte(geocodes), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if loaded, err := SyncGeocodes(db); err != nil || loaded != 1 {
+			t.Fatalf("SyncGeocodes() = %d, %v", loaded, err)
+		}
+		if loaded, err := SyncGeocodes(db); err != nil || loaded != 0 {
+			t.Errorf("second SyncGeocodes() = %d, %v, want 0", loaded, err)
+		}
+
+		area, err := LoadAreaSummary(db, AreaCBSA, "31080")
+		if err != nil {
+			t.Fatalf("LoadAreaSummary() error = %v", err)
+		}
+		if area.Title() != "Los Angeles-Long Beach-Anaheim, CA" || area.SchoolCount != 1 || area.Schools[0].NCESSCH != "360000100002" {
+			t.Errorf("area = %q with %+v", area.Title(), area.Schools)
+		}
+
+		cbsa, name, err := db.SchoolCBSA("360000100003")
+		if err != nil || cbsa != "" || name != "" {
+			t.Errorf("SchoolCBSA() for a school outside any CBSA = %q, %q, %v", cbsa, name, err)
+		}
+	})
+
+	t.Run("naep context", func(t *testing.T) {
+		state, _ := json.Marshal([]NAEPScore{
+			MockNAEPScore("mathematics", 4, 2019, 235, 38),
+			MockNAEPScore("mathematics", 4, 2022, 230, 32),
+		})
+		national, _ := json.Marshal([]NAEPScore{MockNAEPScore("mathematics", 4, 2022, 236, 36)})
+		if err := db.SaveNAEPCache("360000100001", "CA", "", state, nil, national, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+
+		context, err := StateNAEPContext(db, "CA")
+		if err != nil {
+			t.Fatalf("StateNAEPContext() error = %v", err)
+		}
+		if len(context) != 1 || context[0].Year != 2022 || context[0].AtProficient != 32 || context[0].NationalProficient != 36 {
+			t.Errorf("StateNAEPContext() = %+v", context)
+		}
+	})
+}
+
+func TestCountiesAndCongressionalDistricts(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	geocodes := "NCESSCH,NAME,CBSA,NMCBSA,CNTY,NMCNTY,CD\n" +
+		"360000100001,Lincoln Elementary School,41860,\"San Francisco-Oakland-Berkeley, CA\",6075,San Francisco County,0611\n" +
+		"360000100002,Washington High School,31080,\"Los Angeles-Long Beach-Anaheim, CA\",06037,Los Angeles County,0611\n" +
+		"360000100004,Roosevelt Charter School,N,N,36061,New York County,3612\n"
+	if err := os.WriteFile(filepath.Join(db.dataDir, "EDGE_GEOCODE_PUBLICSCH_2324.csv"), []byte(geocodes), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SyncGeocodes(db); err != nil {
+		t.Fatal(err)
+	}
+
+	county, err := LoadAreaSummary(db, AreaCounty, "06075")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if county.Title() != "San Francisco County, CA" || county.SchoolCount != 1 || county.KindLabel() != "County FIPS 06075" {
+		t.Errorf("county = %q (%s) with %d schools", county.Title(), county.KindLabel(), county.SchoolCount)
+	}
+
+	// Districts are found by state and number as well as by code
+	for _, code := range []string{"CA-11", "ca11", "0611"} {
+		district, err := LoadAreaSummary(db, AreaCD, code)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if district.Title() != "California's 11th Congressional District" || district.Code != "CA-11" || district.SchoolCount != 2 {
+			t.Errorf("%s = %q (%s) with %d schools", code, district.Title(), district.Code, district.SchoolCount)
+		}
+		if district.Teachers <= 0 {
+			t.Errorf("%s has no teachers", code)
+		}
+	}
+	if _, err := LoadAreaSummary(db, AreaCD, "CA-12"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected no schools in CA-12, got %v", err)
+	}
+
+	fips, name, cd, err := db.SchoolGeographies("360000100004")
+	if err != nil || fips != "36061" || name != "New York County" || cd != "NY-12" {
+		t.Errorf("SchoolGeographies() = %q, %q, %q, %v", fips, name, cd, err)
+	}
+
+	counties, err := db.AreaRollups(AreaCounty, "ca")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(counties) != 2 || counties[0].Name != "Los Angeles County" || counties[0].Schools != 1 || counties[0].Teachers <= 0 {
+		t.Errorf("CA counties = %+v", counties)
+	}
+	districts, err := db.AreaRollups(AreaCD, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(districts) != 2 || districts[0].Key != "CA-11" || districts[0].Schools != 2 || districts[1].CharterShare() != 100 {
+		t.Errorf("congressional districts = %+v", districts)
+	}
+
+	router := NewRouter(ServerConfig{DB: db})
+	for path, want := range map[string]string{
+		"/area/county/06075":                      "San Francisco County, CA",
+		"/area/cd/ca-11":                          "California&#39;s 11th Congressional District",
+		"/area/county?state=CA":                   `href="/area/county/06037"`,
+		"/area/cd":                                `href="/area/cd/NY-12"`,
+		"/schools/360000100004":                   `href="/area/cd/NY-12"`,
+		"/stats/choropleth?level=county&state=CA": `href="/area/county/06075"`,
+	} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		if rec.Code != 200 || !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("%s = %d, missing %s", path, rec.Code, want)
+		}
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/area/county/123", nil))
+	if rec.Code != 400 {
+		t.Errorf("expected 400 for an invalid county, got %d", rec.Code)
+	}
+}
+
+func TestDirectoryGeographies(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	// Directory extracts with CNTY and CD fill in for schools EDGE files don't cover
+	if _, err := db.conn.Exec(`
+		ALTER TABLE directory ADD COLUMN CNTY VARCHAR;
+		ALTER TABLE directory ADD COLUMN NMCNTY VARCHAR;
+		ALTER TABLE directory ADD COLUMN CD VARCHAR;
+		UPDATE directory SET CNTY = '48201', NMCNTY = 'Harris County', CD = '4818' WHERE NCESSCH = '360000100003';
+	`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SyncGeocodes(db); err != nil {
+		t.Fatal(err)
+	}
+	area, err := LoadAreaSummary(db, AreaCD, "TX-18")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if area.Title() != "Texas's 18th Congressional District" || area.Schools[0].NCESSCH != "360000100003" {
+		t.Errorf("area = %q with %+v", area.Title(), area.Schools)
+	}
+	if county, err := db.CountyName("48201"); err != nil || county != "Harris County" {
+		t.Errorf("CountyName() = %q, %v", county, err)
+	}
+}
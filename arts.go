@@ -0,0 +1,214 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Arts disciplines, the areas an arts coverage score counts
+const (
+	artsInstrumental = "Instrumental Music"
+	artsVocal        = "Vocal Music"
+	artsTheater      = "Theater"
+	artsVisual       = "Visual Arts"
+	artsDance        = "Dance"
+	artsMedia        = "Media Arts"
+)
+
+var artsDisciplines = []string{artsInstrumental, artsVocal, artsTheater, artsVisual, artsDance, artsMedia}
+
+// ArtsOffering is an arts or music program a school offers, normalized from
+// its website so "Symphonic Winds" is Band and "Drama Club" is Theater
+type ArtsOffering struct {
+	Program    string `json:"program"`    // Canonical name, e.g. "Band"
+	Discipline string `json:"discipline"` // One of artsDisciplines; empty for programs not in canonicalArts
+}
+
+// canonicalArt is an arts program's canonical name, its discipline, and the
+// words websites use for it. More specific programs come first so "jazz
+// band" isn't read as Band.
+type canonicalArt struct {
+	program    string
+	discipline string
+	pattern    *regexp.Regexp
+}
+
+func art(program, discipline, aliases string) canonicalArt {
+	return canonicalArt{program: program, discipline: discipline, pattern: regexp.MustCompile(`(?i)\b(` + aliases + `)`)}
+}
+
+var canonicalArts = []canonicalArt{
+	art("Marching Band", artsInstrumental, `marching band|drumline|color guard`),
+	art("Jazz Band", artsInstrumental, `jazz`),
+	art("Mariachi", artsInstrumental, `mariachi`),
+	art("Orchestra", artsInstrumental, `orchestra|string ensemble|strings\b`),
+	art("Band", artsInstrumental, `band|wind ensemble|symphonic winds|wind symphony`),
+	art("Guitar", artsInstrumental, `guitar`),
+	art("Piano", artsInstrumental, `piano|keyboard`),
+	art("Choir", artsVocal, `choir|chorus|choral|chorale|vocal|a cappella|glee`),
+	art("Musical Theater", artsTheater, `musical theat|spring musical|musicals?\b`),
+	art("Theater", artsTheater, `theat|drama|stagecraft|improv|acting|thespian`),
+	art("Ceramics", artsVisual, `ceramic|pottery`),
+	art("Painting & Drawing", artsVisual, `painting|drawing`),
+	art("Sculpture", artsVisual, `sculpt`),
+	art("Dance", artsDance, `danc|ballet|folklorico|hip[- ]hop`),
+	art("Film", artsMedia, `film|video|broadcast`),
+	art("Photography", artsMedia, `photo`),
+	art("Digital Art", artsMedia, `digital art|digital media|graphic design|animation`),
+	art("Visual Arts", artsVisual, `visual art|studio art|fine art|art\b`),
+}
+
+// artsOffering normalizes one arts program as a website lists it. Programs
+// not in canonicalArts keep their listed name and have no discipline.
+func artsOffering(name string) (ArtsOffering, bool) {
+	name = strings.Trim(strings.TrimSpace(name), "*")
+	if name == "" || careUnknown.MatchString(name) || strings.EqualFold(name, "none") {
+		return ArtsOffering{}, false
+	}
+	for _, a := range canonicalArts {
+		if a.pattern.MatchString(name) {
+			return ArtsOffering{Program: a.program, Discipline: a.discipline}, true
+		}
+	}
+	return ArtsOffering{Program: name}, true
+}
+
+// artsLinePattern matches the arts lines the extraction prompt asks for, e.g.
+// "- Arts program: Symphonic Band", allowing for markdown bold and bullets
+var artsLinePattern = regexp.MustCompile(`(?im)^[\s>*+-]*\**arts? program\**\s*:\**\s*(.+)$`)
+
+// parseArtsSection reads the arts and music programs from the extracted
+// markdown's "Arts & Music" lines
+func parseArtsSection(markdown string) []ArtsOffering {
+	var offerings []ArtsOffering
+	for _, m := range artsLinePattern.FindAllStringSubmatch(markdown, -1) {
+		if o, ok := artsOffering(m[1]); ok {
+			offerings = append(offerings, o)
+		}
+	}
+	return offerings
+}
+
+// normalizeArts merges the extracted arts programs with the free-text arts
+// list, one offering per program, in discipline order
+func normalizeArts(programs []ArtsOffering, arts []string) []ArtsOffering {
+	var all []ArtsOffering
+	for _, p := range programs {
+		if o, ok := artsOffering(p.Program); ok {
+			all = append(all, o)
+		}
+	}
+	for _, a := range arts {
+		if o, ok := artsOffering(a); ok {
+			all = append(all, o)
+		}
+	}
+
+	seen := make(map[string]bool)
+	var offerings []ArtsOffering
+	// Programs outside the disciplines (Discipline "") go last
+	for _, d := range slices.Concat(artsDisciplines, []string{""}) {
+		for _, o := range all {
+			if o.Discipline == d && !seen[o.Program] {
+				seen[o.Program] = true
+				offerings = append(offerings, o)
+			}
+		}
+	}
+	return offerings
+}
+
+// ArtsOfferings returns the school's arts and music programs, normalized
+func (data *EnhancedSchoolData) ArtsOfferings() []ArtsOffering {
+	return normalizeArts(data.ArtsPrograms, data.Arts)
+}
+
+// ArtsCoverage is how many of the arts disciplines a school's programs cover
+type ArtsCoverage struct {
+	Offerings []ArtsOffering
+}
+
+// Score is the number of artsDisciplines with at least one program
+func (c *ArtsCoverage) Score() int {
+	score := 0
+	for _, d := range artsDisciplines {
+		if c.ProgramsIn(d) != "" {
+			score++
+		}
+	}
+	return score
+}
+
+// Summary describes the coverage, e.g. "4 of 6 arts disciplines"
+func (c *ArtsCoverage) Summary() string {
+	return fmt.Sprintf("%d of %d arts disciplines", c.Score(), len(artsDisciplines))
+}
+
+// ProgramsIn lists the programs in a discipline, e.g. "Band, Jazz Band"
+func (c *ArtsCoverage) ProgramsIn(discipline string) string {
+	var programs []string
+	for _, o := range c.Offerings {
+		if o.Discipline == discipline {
+			programs = append(programs, o.Program)
+		}
+	}
+	return strings.Join(programs, ", ")
+}
+
+// ArtsCoverage scores the school's arts programs by discipline
+func (data *EnhancedSchoolData) ArtsCoverage() *ArtsCoverage {
+	return &ArtsCoverage{Offerings: data.ArtsOfferings()}
+}
+
+// SaveArtsPrograms replaces a school's normalized arts programs with those in
+// its extracted website data
+func (d *DB) SaveArtsPrograms(data *EnhancedSchoolData) error {
+	tx, err := d.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.Exec(`DELETE FROM school_arts WHERE ncessch = $1`, data.NCESSCH); err != nil {
+		return fmt.Errorf("failed to clear arts programs: %w", err)
+	}
+	for _, o := range data.ArtsOfferings() {
+		if _, err := tx.Exec(`INSERT INTO school_arts (ncessch, program, discipline) VALUES ($1, $2, $3)`,
+			data.NCESSCH, o.Program, o.Discipline); err != nil {
+			return fmt.Errorf("failed to save arts program: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to save arts programs: %w", err)
+	}
+	return nil
+}
+
+// SchoolArtsCoverage loads the arts coverage of the given schools, keyed by
+// NCES ID. Schools without arts programs on record are left out.
+func (d *DB) SchoolArtsCoverage(ncesschs []string) (map[string]*ArtsCoverage, error) {
+	coverage := make(map[string]*ArtsCoverage)
+	if len(ncesschs) == 0 {
+		return coverage, nil
+	}
+	rows, err := d.conn.Query(`SELECT ncessch, program, discipline FROM school_arts WHERE ncessch = ANY($1) ORDER BY rowid`, ncesschs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load arts programs: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var ncessch string
+		var o ArtsOffering
+		if err := rows.Scan(&ncessch, &o.Program, &o.Discipline); err != nil {
+			return nil, fmt.Errorf("failed to scan arts program: %w", err)
+		}
+		if coverage[ncessch] == nil {
+			coverage[ncessch] = &ArtsCoverage{}
+		}
+		coverage[ncessch].Offerings = append(coverage[ncessch].Offerings, o)
+	}
+	return coverage, rows.Err()
+}
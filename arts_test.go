@@ -0,0 +1,75 @@
+package main
+
+import (
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNormalizeArts(t *testing.T) {
+	markdown := `## Arts & Music
+- **Arts program:** Symphonic Winds
+- Arts program: Jazz Ensemble
+- Arts program: Drama Club
+- Arts program: not published`
+
+	var got []string
+	for _, o := range normalizeArts(parseArtsSection(markdown), []string{"Concert Band", "Ballet Folklorico", "AP Studio Art", "Poetry Slam"}) {
+		got = append(got, o.Program+"/"+o.Discipline)
+	}
+	want := []string{
+		"Band/Instrumental Music",
+		"Jazz Band/Instrumental Music",
+		"Theater/Theater",
+		"Visual Arts/Visual Arts",
+		"Dance/Dance",
+		"Poetry Slam/", // Not a canonical program, so it keeps its name
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("normalizeArts() =\n%q\nwant\n%q", got, want)
+	}
+
+	coverage := (&EnhancedSchoolData{Arts: []string{"Marching Band", "Orchestra", "Choir"}}).ArtsCoverage()
+	if coverage.Score() != 2 || coverage.Summary() != "2 of 6 arts disciplines" {
+		t.Errorf("coverage = %d (%q)", coverage.Score(), coverage.Summary())
+	}
+	if got := coverage.ProgramsIn(artsInstrumental); got != "Marching Band, Orchestra" {
+		t.Errorf("instrumental programs = %q", got)
+	}
+}
+
+func TestCompareArts(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	for id, website := range map[string]*EnhancedSchoolData{
+		"360000100002": {Arts: []string{"Wind Ensemble", "Chamber Choir"}, MarkdownContent: "- Arts program: Stagecraft"},
+		"360000100004": {Arts: []string{"Photography"}},
+	} {
+		website.NCESSCH, website.ExtractedAt = id, time.Now()
+		if err := saveEnhancedData(db, website); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	coverage, err := db.SchoolArtsCoverage([]string{"360000100002", "360000100004", "360000100001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(coverage) != 2 || coverage["360000100002"].Score() != 3 || coverage["360000100004"].ProgramsIn(artsMedia) != "Photography" {
+		t.Errorf("coverage = %+v", coverage)
+	}
+
+	// The compare view lines the schools' programs up by discipline
+	router := NewRouter(ServerConfig{DB: db})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/compare?ids=360000100002,360000100004,360000100001", nil))
+	body := rec.Body.String()
+	for _, want := range []string{"3 of 6 arts disciplines", "1 of 6 arts disciplines", "<th>Vocal Music</th><td>Choir</td><td>—</td><td>N/A</td>"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("compare page is missing %q", want)
+		}
+	}
+}
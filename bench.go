@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"time"
+)
+
+// Latency budgets (p95) checked by the bench command
+const (
+	searchBudget     = 50 * time.Millisecond
+	detailBudget     = 10 * time.Millisecond
+	enrichmentBudget = 25 * time.Millisecond
+)
+
+const defaultBenchIterations = 50
+
+// benchSearchQueries are representative search box queries, cycled through on each iteration
+var benchSearchQueries = []string{"elementary", "high school", "lincoln", "washington", "academy", "middle"}
+
+// BenchmarkResult holds latency percentiles for one benchmarked operation
+type BenchmarkResult struct {
+	Name       string
+	Iterations int
+	P50        time.Duration
+	P95        time.Duration
+	Max        time.Duration
+	Budget     time.Duration // p95 target; zero means no budget
+	Skipped    string        // Why the case didn't run, if it didn't
+}
+
+// WithinBudget reports whether the p95 latency meets the budget
+func (r BenchmarkResult) WithinBudget() bool {
+	return r.Skipped != "" || r.Budget == 0 || r.P95 <= r.Budget
+}
+
+// BenchmarkRun is one invocation of the bench command
+type BenchmarkRun struct {
+	RunAt   time.Time
+	Version string
+	Results []BenchmarkResult
+}
+
+// WithinBudget reports whether every result meets its budget
+func (r *BenchmarkRun) WithinBudget() bool {
+	for _, result := range r.Results {
+		if !result.WithinBudget() {
+			return false
+		}
+	}
+	return true
+}
+
+// Result returns the named result, if present
+func (r *BenchmarkRun) Result(name string) (BenchmarkResult, bool) {
+	for _, result := range r.Results {
+		if result.Name == name {
+			return result, true
+		}
+	}
+	return BenchmarkResult{}, false
+}
+
+// benchmarkCase is a single operation to time; run receives the iteration number
+type benchmarkCase struct {
+	name   string
+	budget time.Duration
+	skip   string
+	run    func(i int) error
+}
+
+// RunBenchmarks times search, detail lookup, and enrichment against the local database.
+// Enrichment only reads cached AI and NAEP data; no external services are called.
+func RunBenchmarks(db *DB, iterations int) (*BenchmarkRun, error) {
+	if iterations <= 0 {
+		iterations = defaultBenchIterations
+	}
+
+	sample, err := db.SearchSchools("", "", 20)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load sample schools: %w", err)
+	}
+	if len(sample) == 0 {
+		return nil, fmt.Errorf("no schools in database to benchmark")
+	}
+	schoolID := func(i int) string { return sample[i%len(sample)].NCESSCH }
+
+	ftsSkip := ""
+	if !db.hasFTS {
+		ftsSkip = "FTS extension not available"
+	}
+
+	naepClient := NewNAEPClient(db)
+	aiCache := &AIScraperService{db: db}
+	web := &WebHandler{NAEPClient: naepClient}
+
+	cases := []benchmarkCase{
+		{"search_fts", searchBudget, ftsSkip, func(i int) error {
+			_, err := db.searchSchools(SearchFilters{Query: benchSearchQueries[i%len(benchSearchQueries)]}, maxResults, true)
+			return err
+		}},
+		{"search_like", searchBudget, "", func(i int) error {
+			_, err := db.searchSchools(SearchFilters{Query: benchSearchQueries[i%len(benchSearchQueries)]}, maxResults, false)
+			return err
+		}},
+		{"search_state", searchBudget, "", func(i int) error {
+			_, err := db.SearchSchools("", sample[i%len(sample)].State, maxResults)
+			return err
+		}},
+		{"detail_lookup", detailBudget, "", func(i int) error {
+			// Measure the DuckDB query rather than the in-memory cache
+			db.schoolCache.Delete(schoolID(i))
+			_, err := db.GetSchoolByID(schoolID(i))
+			return err
+		}},
+		{"detail_lookup_cached", detailBudget, "", func(i int) error {
+			_, err := db.GetSchoolByID(schoolID(i))
+			return err
+		}},
+		{"enrichment", enrichmentBudget, "", func(i int) error {
+			school, err := db.GetSchoolByID(schoolID(i))
+			if err != nil {
+				return err
+			}
+			enhanced, _ := aiCache.loadCachedData(school.NCESSCH, cacheNoExpiry)
+			naepData, _ := naepClient.loadCachedData(school.NCESSCH, cacheNoExpiry)
+			if naepData != nil {
+				web.enrichNAEPData(naepData)
+			}
+			GenerateTourQuestions(school, enhanced, naepData)
+			return nil
+		}},
+	}
+
+	run := &BenchmarkRun{RunAt: time.Now().UTC(), Version: version}
+	for _, c := range cases {
+		result, err := runBenchmarkCase(c, iterations)
+		if err != nil {
+			return nil, err
+		}
+		run.Results = append(run.Results, result)
+	}
+
+	return run, nil
+}
+
+// runBenchmarkCase runs one warm-up call then times iterations calls
+func runBenchmarkCase(c benchmarkCase, iterations int) (BenchmarkResult, error) {
+	result := BenchmarkResult{Name: c.name, Budget: c.budget, Skipped: c.skip}
+	if c.skip != "" {
+		return result, nil
+	}
+
+	if err := c.run(0); err != nil {
+		return result, fmt.Errorf("benchmark %s failed: %w", c.name, err)
+	}
+
+	durations := make([]time.Duration, iterations)
+	for i := range durations {
+		start := time.Now()
+		if err := c.run(i); err != nil {
+			return result, fmt.Errorf("benchmark %s failed: %w", c.name, err)
+		}
+		durations[i] = time.Since(start)
+	}
+
+	slices.Sort(durations)
+	result.Iterations = iterations
+	result.P50 = percentile(durations, 0.50)
+	result.P95 = percentile(durations, 0.95)
+	result.Max = durations[len(durations)-1]
+	return result, nil
+}
+
+// percentile returns the nearest-rank percentile of sorted durations
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	return sorted[max(0, min(rank, len(sorted)-1))]
+}
+
+// durationMS converts a duration to fractional milliseconds
+func durationMS(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// msDuration converts fractional milliseconds to a duration
+func msDuration(ms float64) time.Duration {
+	return time.Duration(ms * float64(time.Millisecond))
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// TestPercentile tests nearest-rank percentiles
+func TestPercentile(t *testing.T) {
+	var sorted []time.Duration
+	for i := 1; i <= 20; i++ {
+		sorted = append(sorted, time.Duration(i)*time.Millisecond)
+	}
+
+	testCases := []struct {
+		name     string
+		input    []time.Duration
+		p        float64
+		expected time.Duration
+	}{
+		{"Empty", nil, 0.95, 0},
+		{"Single", []time.Duration{time.Second}, 0.95, time.Second},
+		{"Median", sorted, 0.50, 10 * time.Millisecond},
+		{"P95", sorted, 0.95, 19 * time.Millisecond},
+		{"Max", sorted, 1.0, 20 * time.Millisecond},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := percentile(tc.input, tc.p); got != tc.expected {
+				t.Errorf("Expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+// TestRunBenchmarks tests that a bench run covers every operation and round-trips through the database
+func TestRunBenchmarks(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	run, err := RunBenchmarks(db, 5)
+	if err != nil {
+		t.Fatalf("RunBenchmarks failed: %v", err)
+	}
+
+	for _, name := range []string{"search_fts", "search_like", "search_state", "detail_lookup", "detail_lookup_cached", "enrichment"} {
+		result, ok := run.Result(name)
+		if !ok {
+			t.Errorf("Missing result for %s", name)
+			continue
+		}
+		if result.Skipped == "" && (result.Iterations != 5 || result.P95 < result.P50 || result.Max < result.P95) {
+			t.Errorf("Inconsistent result for %s: %+v", name, result)
+		}
+	}
+
+	if err := db.SaveBenchmarkRun(*run); err != nil {
+		t.Fatalf("Failed to save run: %v", err)
+	}
+	latest, err := db.LatestBenchmarkRun()
+	if err != nil {
+		t.Fatalf("Failed to load run: %v", err)
+	}
+	if latest == nil || len(latest.Results) != len(run.Results) {
+		t.Fatalf("Expected %d saved results, got %+v", len(run.Results), latest)
+	}
+	saved, _ := latest.Result("search_like")
+	original, _ := run.Result("search_like")
+	if saved.Budget != searchBudget || saved.P95.Round(time.Microsecond) != original.P95.Round(time.Microsecond) {
+		t.Errorf("Saved result doesn't match: got %+v, want %+v", saved, original)
+	}
+}
+
+// TestBenchmarkBudget tests budget checks, including skipped operations
+func TestBenchmarkBudget(t *testing.T) {
+	run := &BenchmarkRun{Results: []BenchmarkResult{
+		{Name: "fast", P95: 10 * time.Millisecond, Budget: searchBudget},
+		{Name: "skipped", Skipped: "FTS extension not available", Budget: searchBudget},
+	}}
+	if !run.WithinBudget() {
+		t.Error("Expected run to be within budget")
+	}
+
+	run.Results = append(run.Results, BenchmarkResult{Name: "slow", P95: 80 * time.Millisecond, Budget: searchBudget})
+	if run.WithinBudget() {
+		t.Error("Expected run over budget when a p95 exceeds its budget")
+	}
+}
+
+func BenchmarkSearchSchoolsLIKE(b *testing.B) {
+	db, cleanup := SetupTestDB(b)
+	defer cleanup()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := db.searchSchools(SearchFilters{Query: benchSearchQueries[i%len(benchSearchQueries)]}, maxResults, false); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSearchSchoolsFTS(b *testing.B) {
+	db, cleanup := SetupTestDB(b)
+	defer cleanup()
+	if !db.hasFTS {
+		b.Skip("FTS extension not available")
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := db.searchSchools(SearchFilters{Query: benchSearchQueries[i%len(benchSearchQueries)]}, maxResults, true); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGetSchoolByID(b *testing.B) {
+	db, cleanup := SetupTestDB(b)
+	defer cleanup()
+
+	b.Run("uncached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			db.schoolCache.Delete("360000100001")
+			if _, err := db.GetSchoolByID("360000100001"); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("cached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := db.GetSchoolByID("360000100001"); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkEnrichment(b *testing.B) {
+	db, cleanup := SetupTestDB(b)
+	defer cleanup()
+
+	data := MockNAEPData("360000100001", "CA", "", false, true)
+	stateScores, _ := json.Marshal(data.StateScores)
+	nationalScores, _ := json.Marshal(data.NationalScores)
+	if err := db.SaveNAEPCache(data.NCESSCH, "CA", "", stateScores, nil, nationalScores, time.Now()); err != nil {
+		b.Fatal(err)
+	}
+
+	client := NewNAEPClient(db)
+	web := &WebHandler{NAEPClient: client}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		school, err := db.GetSchoolByID(data.NCESSCH)
+		if err != nil {
+			b.Fatal(err)
+		}
+		naepData, err := client.loadCachedData(school.NCESSCH, cacheNoExpiry)
+		if err != nil {
+			b.Fatal(err)
+		}
+		web.enrichNAEPData(naepData)
+		GenerateTourQuestions(school, nil, naepData)
+	}
+}
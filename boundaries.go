@@ -0,0 +1,496 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// boundaryFilePattern matches NCES School Attendance Boundary Survey (SABS)
+// files converted to GeoJSON, e.g. with
+// ogr2ogr -f GeoJSON -t_srs EPSG:4326 SABS_1516_CA.geojson SABS_1516_CA.shp
+const boundaryFilePattern = "SABS_*.geojson"
+
+// feederFilePattern matches district feeder tables: CSV files with a
+// FROM_NCESSCH and TO_NCESSCH column for each school that feeds another
+const feederFilePattern = "FEEDERS_*.csv"
+
+// SABS level codes
+const (
+	sabsPrimary = "1"
+	sabsMiddle  = "2"
+	sabsHigh    = "3"
+)
+
+// PipelineStage is one step of a feeder pattern, such as the assigned middle school
+type PipelineStage struct {
+	Stage     string  // Elementary, Middle, or High
+	Grade     string  // The grade checked for this stage, e.g. "07"
+	School    *School // nil when no boundary or feeder covers the stage
+	Source    string  // "boundary", "feeder", or "inferred"
+	FeedsFrom string  // NCES ID of the previous stage's school, for feeder and inferred stages
+	Note      string
+
+	// Inferred stages come from AI reading school and district websites, not
+	// boundary data or feeder tables
+	Confidence string  // high, medium, or low, for inferred stages
+	Evidence   string  // What the website says, for inferred stages
+	Previous   *School // The previous stage's school, whose websites could be read when the stage is missing
+}
+
+// FeederPipeline is the elementary, middle, and high school assigned to a location
+type FeederPipeline struct {
+	Location Home
+	Stages   []PipelineStage
+}
+
+// Found reports whether any stage has an assigned school
+func (p *FeederPipeline) Found() bool {
+	for _, stage := range p.Stages {
+		if stage.School != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// pipelineStages are the stages of a feeder pattern, each checked at a grade
+// every school at that level serves
+var pipelineStages = []struct {
+	Stage string
+	Grade string
+	Level string
+}{
+	{"Elementary", "03", sabsPrimary},
+	{"Middle", "07", sabsMiddle},
+	{"High", "10", sabsHigh},
+}
+
+// boundary is an attendance boundary that contains a location
+type boundary struct {
+	NCESSCH   string
+	Level     string
+	GradeLow  string
+	GradeHigh string
+}
+
+// serves reports whether the boundary's grades, or the school's when the
+// boundary has none, include grade
+func (b boundary) serves(school *School, grade string) bool {
+	low, high := b.GradeLow, b.GradeHigh
+	if _, ok := gradeRank(low); !ok {
+		low = school.GradeLow.String
+	}
+	if _, ok := gradeRank(high); !ok {
+		high = school.GradeHigh.String
+	}
+	return servesGrade(low, high, grade)
+}
+
+// servesGrade reports whether the grade span low-high includes grade
+func servesGrade(low, high, grade string) bool {
+	rank, ok := gradeRank(grade)
+	lowRank, lowOK := gradeRank(low)
+	highRank, highOK := gradeRank(high)
+	return ok && lowOK && highOK && lowRank <= rank && rank <= highRank
+}
+
+// BuildFeederPipeline finds the elementary, middle, and high school assigned
+// to a location. Each stage uses the attendance boundary containing the
+// location, and otherwise the school the previous stage's school feeds into:
+// from feeder tables, then from feeder patterns inferred with AI.
+func BuildFeederPipeline(db *DB, location Home) (*FeederPipeline, error) {
+	boundaries, err := db.boundariesContaining(location.Lat, location.Lon)
+	if err != nil {
+		return nil, err
+	}
+
+	pipeline := &FeederPipeline{Location: location}
+	var previous *School
+	for _, stage := range pipelineStages {
+		step := PipelineStage{Stage: stage.Stage, Grade: stage.Grade}
+
+		// Prefer a boundary drawn for this level, then any boundary whose school serves the grade
+		var fallback *School
+		for _, b := range boundaries {
+			school, err := db.GetSchoolByID(b.NCESSCH)
+			if errors.Is(err, sql.ErrNoRows) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			if !b.serves(school, stage.Grade) {
+				continue
+			}
+			if b.Level == stage.Level {
+				step.School = school
+				break
+			}
+			if fallback == nil {
+				fallback = school
+			}
+		}
+		if step.School == nil {
+			step.School = fallback
+		}
+		if step.School != nil {
+			step.Source = "boundary"
+		}
+
+		if step.School == nil && previous != nil {
+			fed, err := db.feederTarget(previous.NCESSCH, stage.Grade)
+			if err != nil {
+				return nil, err
+			}
+			if fed != nil {
+				step.School = fed
+				step.Source = "feeder"
+				step.FeedsFrom = previous.NCESSCH
+				step.Note = "Fed by " + previous.Name
+			}
+		}
+
+		if step.School == nil && previous != nil {
+			inferred, feeder, err := db.inferredFeederTarget(previous.NCESSCH, stage.Grade)
+			if err != nil {
+				return nil, err
+			}
+			if inferred != nil {
+				step.School = inferred
+				step.Source = "inferred"
+				step.FeedsFrom = previous.NCESSCH
+				step.Confidence = feeder.Confidence
+				step.Evidence = feeder.Evidence
+				step.Note = fmt.Sprintf("Inferred with AI from %s's and its district's websites (%s confidence)", previous.Name, feeder.Confidence)
+			}
+		}
+
+		if step.School == nil {
+			step.Previous = previous
+			step.Note = fmt.Sprintf("No loaded attendance boundary or feeder table covers grade %s here", gradeLabel(stage.Grade))
+		} else {
+			previous = step.School
+		}
+		pipeline.Stages = append(pipeline.Stages, step)
+	}
+	return pipeline, nil
+}
+
+// boundariesContaining returns the attendance boundaries that contain a point
+func (d *DB) boundariesContaining(lat, lon float64) ([]boundary, error) {
+	rows, err := d.conn.Query(`
+		SELECT ncessch, level, grade_low, grade_high, polygons
+		FROM school_boundaries
+		WHERE $1 BETWEEN min_lat AND max_lat AND $2 BETWEEN min_lon AND max_lon
+		ORDER BY ncessch
+	`, lat, lon)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up attendance boundaries: %w", err)
+	}
+	defer rows.Close()
+
+	var found []boundary
+	for rows.Next() {
+		var b boundary
+		var polygonsJSON string
+		if err := rows.Scan(&b.NCESSCH, &b.Level, &b.GradeLow, &b.GradeHigh, &polygonsJSON); err != nil {
+			return nil, fmt.Errorf("failed to scan attendance boundary: %w", err)
+		}
+		var polygons [][][][2]float64
+		if err := json.Unmarshal([]byte(polygonsJSON), &polygons); err != nil {
+			return nil, fmt.Errorf("failed to read boundary of %s: %w", b.NCESSCH, err)
+		}
+		if polygonsContain(polygons, lat, lon) {
+			found = append(found, b)
+		}
+	}
+	return found, rows.Err()
+}
+
+// feederTarget returns the school that ncessch feeds into and that serves
+// grade, or nil if the feeder tables have none
+func (d *DB) feederTarget(ncessch, grade string) (*School, error) {
+	rows, err := d.conn.Query(`SELECT to_ncessch FROM school_feeders WHERE from_ncessch = $1 ORDER BY to_ncessch`, ncessch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up feeder schools: %w", err)
+	}
+	var targets []string
+	for rows.Next() {
+		var target string
+		if err := rows.Scan(&target); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan feeder school: %w", err)
+		}
+		targets = append(targets, target)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, target := range targets {
+		school, err := d.GetSchoolByID(target)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if servesGrade(school.GradeLow.String, school.GradeHigh.String, grade) {
+			return school, nil
+		}
+	}
+	return nil, nil
+}
+
+// polygonsContain reports whether any polygon contains the point. Each
+// polygon is an outer ring followed by its holes, as [lon, lat] pairs.
+func polygonsContain(polygons [][][][2]float64, lat, lon float64) bool {
+	for _, polygon := range polygons {
+		if len(polygon) == 0 || !ringContains(polygon[0], lat, lon) {
+			continue
+		}
+		inHole := false
+		for _, hole := range polygon[1:] {
+			if ringContains(hole, lat, lon) {
+				inHole = true
+				break
+			}
+		}
+		if !inHole {
+			return true
+		}
+	}
+	return false
+}
+
+// ringContains is the ray-casting point-in-polygon test for one ring
+func ringContains(ring [][2]float64, lat, lon float64) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		xi, yi := ring[i][0], ring[i][1]
+		xj, yj := ring[j][0], ring[j][1]
+		if (yi > lat) != (yj > lat) && lon < (xj-xi)*(lat-yi)/(yj-yi)+xi {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// geoJSONFeatureCollection is the part of a GeoJSON file we read
+type geoJSONFeatureCollection struct {
+	Features []struct {
+		Properties map[string]any `json:"properties"`
+		Geometry   *struct {
+			Type        string          `json:"type"`
+			Coordinates json.RawMessage `json:"coordinates"`
+		} `json:"geometry"`
+	} `json:"features"`
+}
+
+// geoJSONPolygons returns a Polygon or MultiPolygon geometry as a list of polygons
+func geoJSONPolygons(geometryType string, coordinates json.RawMessage) ([][][][2]float64, error) {
+	switch geometryType {
+	case "Polygon":
+		var polygon [][][2]float64
+		if err := json.Unmarshal(coordinates, &polygon); err != nil {
+			return nil, err
+		}
+		return [][][][2]float64{polygon}, nil
+	case "MultiPolygon":
+		var polygons [][][][2]float64
+		if err := json.Unmarshal(coordinates, &polygons); err != nil {
+			return nil, err
+		}
+		return polygons, nil
+	default:
+		return nil, nil
+	}
+}
+
+// geoJSONProperty returns a feature property by case-insensitive name, as text
+func geoJSONProperty(properties map[string]any, name string) string {
+	for key, value := range properties {
+		if !strings.EqualFold(key, name) || value == nil {
+			continue
+		}
+		if f, ok := value.(float64); ok {
+			return strings.TrimSpace(fmt.Sprintf("%.0f", f))
+		}
+		return strings.TrimSpace(fmt.Sprint(value))
+	}
+	return ""
+}
+
+// SyncBoundaries loads any new SABS boundary files in the data directory and
+// returns the number of new files loaded
+func SyncBoundaries(db *DB) (int, error) {
+	paths, err := filepath.Glob(filepath.Join(db.dataDir, boundaryFilePattern))
+	if err != nil {
+		return 0, fmt.Errorf("failed to list boundary files: %w", err)
+	}
+
+	loaded := 0
+	for _, path := range paths {
+		isNew, err := db.loadBoundaryFile(path)
+		if err != nil {
+			return loaded, err
+		}
+		if isNew {
+			loaded++
+		}
+	}
+
+	if loaded > 0 && logger != nil {
+		logger.Info("Attendance boundaries loaded", "files", loaded)
+	}
+	return loaded, nil
+}
+
+// loadBoundaryFile adds a SABS GeoJSON file's boundaries to school_boundaries
+// unless it was loaded before. It reports whether the file was new.
+func (d *DB) loadBoundaryFile(path string) (bool, error) {
+	filename := filepath.Base(path)
+
+	var loaded int
+	if err := d.conn.QueryRow(`SELECT count(*) FROM boundary_files WHERE filename = $1`, filename).Scan(&loaded); err != nil {
+		return false, fmt.Errorf("failed to check boundary files: %w", err)
+	}
+	if loaded > 0 {
+		return false, nil
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s: %w", filename, err)
+	}
+	var collection geoJSONFeatureCollection
+	if err := json.Unmarshal(raw, &collection); err != nil {
+		return false, fmt.Errorf("failed to parse %s: %w", filename, err)
+	}
+
+	tx, err := d.conn.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, feature := range collection.Features {
+		ncessch := geoJSONProperty(feature.Properties, "ncessch")
+		if ncessch == "" || feature.Geometry == nil {
+			continue
+		}
+		polygons, err := geoJSONPolygons(feature.Geometry.Type, feature.Geometry.Coordinates)
+		if err != nil {
+			return false, fmt.Errorf("failed to read boundary of %s in %s: %w", ncessch, filename, err)
+		}
+		if len(polygons) == 0 {
+			continue
+		}
+
+		minLat, minLon, maxLat, maxLon := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+		for _, polygon := range polygons {
+			for _, ring := range polygon {
+				for _, point := range ring {
+					minLon, maxLon = math.Min(minLon, point[0]), math.Max(maxLon, point[0])
+					minLat, maxLat = math.Min(minLat, point[1]), math.Max(maxLat, point[1])
+				}
+			}
+		}
+		polygonsJSON, err := json.Marshal(polygons)
+		if err != nil {
+			return false, fmt.Errorf("failed to store boundary of %s: %w", ncessch, err)
+		}
+
+		// Grades are stored as CCD codes so they compare with the directory's
+		gradeLow, _ := normalizeGrade(geoJSONProperty(feature.Properties, "gslo"))
+		gradeHigh, _ := normalizeGrade(geoJSONProperty(feature.Properties, "gshi"))
+		_, err = tx.Exec(`
+			INSERT OR REPLACE INTO school_boundaries
+				(ncessch, level, grade_low, grade_high, min_lat, min_lon, max_lat, max_lon, polygons, source)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		`, ncessch, geoJSONProperty(feature.Properties, "level"), gradeLow, gradeHigh, minLat, minLon, maxLat, maxLon, string(polygonsJSON), filename)
+		if err != nil {
+			return false, fmt.Errorf("failed to load boundary of %s from %s: %w", ncessch, filename, err)
+		}
+	}
+
+	if _, err := tx.Exec(`INSERT INTO boundary_files (filename) VALUES ($1)`, filename); err != nil {
+		return false, fmt.Errorf("failed to record boundary file: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit attendance boundaries: %w", err)
+	}
+	return true, nil
+}
+
+// SyncFeeders loads any new district feeder tables in the data directory and
+// returns the number of new files loaded
+func SyncFeeders(db *DB) (int, error) {
+	paths, err := filepath.Glob(filepath.Join(db.dataDir, feederFilePattern))
+	if err != nil {
+		return 0, fmt.Errorf("failed to list feeder files: %w", err)
+	}
+
+	loaded := 0
+	for _, path := range paths {
+		isNew, err := db.loadFeederFile(path)
+		if err != nil {
+			return loaded, err
+		}
+		if isNew {
+			loaded++
+		}
+	}
+
+	if loaded > 0 && logger != nil {
+		logger.Info("Feeder tables loaded", "files", loaded)
+	}
+	return loaded, nil
+}
+
+// loadFeederFile adds a feeder table to school_feeders unless it was loaded
+// before. It reports whether the file was new.
+func (d *DB) loadFeederFile(path string) (bool, error) {
+	filename := filepath.Base(path)
+
+	var loaded int
+	if err := d.conn.QueryRow(`SELECT count(*) FROM feeder_files WHERE filename = $1`, filename).Scan(&loaded); err != nil {
+		return false, fmt.Errorf("failed to check feeder files: %w", err)
+	}
+	if loaded > 0 {
+		return false, nil
+	}
+
+	tx, err := d.conn.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	_, err = tx.Exec(fmt.Sprintf(`
+		INSERT OR REPLACE INTO school_feeders (from_ncessch, to_ncessch, source)
+		SELECT DISTINCT trim(FROM_NCESSCH), trim(TO_NCESSCH), $1
+		FROM read_csv('%s', all_varchar=true)
+		WHERE FROM_NCESSCH IS NOT NULL AND TO_NCESSCH IS NOT NULL
+	`, path), filename)
+	if err != nil {
+		return false, fmt.Errorf("failed to load %s into school feeders: %w", filename, err)
+	}
+
+	if _, err := tx.Exec(`INSERT INTO feeder_files (filename) VALUES ($1)`, filename); err != nil {
+		return false, fmt.Errorf("failed to record feeder file: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit school feeders: %w", err)
+	}
+	return true, nil
+}
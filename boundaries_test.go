@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testBoundaries has an elementary boundary for Lincoln Elementary and a
+// middle school boundary, with a hole, for Jefferson Middle
+const testBoundaries = `{
+  "type": "FeatureCollection",
+  "features": [
+    {
+      "type": "Feature",
+      "properties": {"ncessch": "360000100001", "Level": "1", "gslo": "KG", "gshi": "05"},
+      "geometry": {"type": "MultiPolygon", "coordinates": [[[[-122.45, 37.75], [-122.40, 37.75], [-122.40, 37.80], [-122.45, 37.80], [-122.45, 37.75]]]]}
+    },
+    {
+      "type": "Feature",
+      "properties": {"NCESSCH": 360000100003, "LEVEL": 2},
+      "geometry": {"type": "Polygon", "coordinates": [
+        [[-122.50, 37.70], [-122.30, 37.70], [-122.30, 37.90], [-122.50, 37.90], [-122.50, 37.70]],
+        [[-122.41, 37.79], [-122.39, 37.79], [-122.39, 37.81], [-122.41, 37.81], [-122.41, 37.79]]
+      ]}
+    },
+    {"type": "Feature", "properties": {"ncessch": "360000100004"}, "geometry": null}
+  ]
+}`
+
+func TestFeederPipeline(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	if err := os.WriteFile(filepath.Join(db.dataDir, "SABS_1516_CA.geojson"), []byte(testBoundaries), 0644); err != nil {
+		t.Fatal(err)
+	}
+	feeders := "FROM_NCESSCH,TO_NCESSCH\n360000100003,360000100002\n360000100003,360000100001\n"
+	if err := os.WriteFile(filepath.Join(db.dataDir, "FEEDERS_SFUSD.csv"), []byte(feeders), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if loaded, err := SyncBoundaries(db); err != nil || loaded != 1 {
+		t.Fatalf("SyncBoundaries() = %d, %v", loaded, err)
+	}
+	if loaded, err := SyncBoundaries(db); err != nil || loaded != 0 {
+		t.Errorf("second SyncBoundaries() = %d, %v, want 0", loaded, err)
+	}
+	if loaded, err := SyncFeeders(db); err != nil || loaded != 1 {
+		t.Fatalf("SyncFeeders() = %d, %v", loaded, err)
+	}
+
+	t.Run("boundaries and feeders", func(t *testing.T) {
+		pipeline, err := BuildFeederPipeline(db, Home{Lat: 37.78, Lon: -122.42})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []struct{ ncessch, source string }{
+			{"360000100001", "boundary"},
+			{"360000100003", "boundary"},
+			{"360000100002", "feeder"},
+		}
+		if len(pipeline.Stages) != len(want) {
+			t.Fatalf("stages = %+v", pipeline.Stages)
+		}
+		for i, w := range want {
+			stage := pipeline.Stages[i]
+			if stage.School == nil || stage.School.NCESSCH != w.ncessch || stage.Source != w.source {
+				t.Errorf("%s stage = %+v, want %s from %s", stage.Stage, stage, w.ncessch, w.source)
+			}
+		}
+		if high := pipeline.Stages[2]; high.FeedsFrom != "360000100003" {
+			t.Errorf("high school feeds from %q", high.FeedsFrom)
+		}
+	})
+
+	t.Run("point in a hole", func(t *testing.T) {
+		pipeline, err := BuildFeederPipeline(db, Home{Lat: 37.80, Lon: -122.395})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pipeline.Found() {
+			t.Errorf("stages = %+v, want none assigned", pipeline.Stages)
+		}
+		if pipeline.Stages[1].Note == "" {
+			t.Error("Expected a note for an unassigned stage")
+		}
+	})
+
+	t.Run("web page", func(t *testing.T) {
+		router := NewRouter(ServerConfig{DB: db})
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest("GET", "/pipeline?address=37.78,-122.42", nil))
+		body := rec.Body.String()
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status %d, body %s", rec.Code, body)
+		}
+		for _, link := range []string{`href="/schools/360000100001"`, `href="/schools/360000100003"`, `href="/schools/360000100002"`, "Feeder table"} {
+			if !strings.Contains(body, link) {
+				t.Errorf("pipeline page is missing %s", link)
+			}
+		}
+
+		// Without an address or home, the page is just the form
+		rec = httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest("GET", "/pipeline", nil))
+		if rec.Code != http.StatusOK || strings.Contains(rec.Body.String(), "Attendance boundary") {
+			t.Errorf("empty pipeline page: status %d", rec.Code)
+		}
+	})
+}
+
+func TestGeocoder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("benchmark") != "Public_AR_Current" {
+			t.Errorf("benchmark = %q", r.URL.Query().Get("benchmark"))
+		}
+		if strings.Contains(r.URL.Query().Get("address"), "Nowhere") {
+			_, _ = w.Write([]byte(`{"result": {"addressMatches": []}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"result": {"addressMatches": [{"matchedAddress": "555 FRANKLIN ST, SAN FRANCISCO, CA, 94102", "coordinates": {"x": -122.42, "y": 37.78}}]}}`))
+	}))
+	defer server.Close()
+	geocoder := NewGeocoder(WithGeocoderURL(server.URL))
+
+	home, err := geocoder.Geocode(context.Background(), "555 Franklin St, San Francisco, CA")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if home.Lat != 37.78 || home.Lon != -122.42 || home.Label != "555 FRANKLIN ST, SAN FRANCISCO, CA, 94102" {
+		t.Errorf("Geocode() = %+v", home)
+	}
+	if _, err := geocoder.Geocode(context.Background(), "1 Nowhere Rd"); !errors.Is(err, ErrAddressNotFound) {
+		t.Errorf("Geocode() of an unknown address error = %v, want ErrAddressNotFound", err)
+	}
+
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	if _, err := ResolveLocation(context.Background(), db, geocoder, ""); !errors.Is(err, ErrNoLocation) {
+		t.Errorf("ResolveLocation() without a home error = %v, want ErrNoLocation", err)
+	}
+	if _, err := SaveHome(db, "45.52,-122.68", "Home"); err != nil {
+		t.Fatal(err)
+	}
+	if home, err := ResolveLocation(context.Background(), db, geocoder, ""); err != nil || home.Label != "Home" {
+		t.Errorf("ResolveLocation() of the home = %+v, %v", home, err)
+	}
+
+	// Addresses shown on the page are geocoded
+	router := NewRouter(ServerConfig{DB: db, Geocoder: geocoder})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/pipeline?address=1+Nowhere+Rd", nil))
+	if !strings.Contains(rec.Body.String(), "couldn&#39;t find that address") {
+		t.Errorf("pipeline page for an unknown address is missing the error")
+	}
+}
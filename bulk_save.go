@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// Bulk saves write a dossier for every school in a search result to one
+// directory, with a manifest listing each file and where its data came from
+
+// bulkManifestName is the manifest file written alongside the dossiers
+const bulkManifestName = "manifest.json"
+
+// defaultBulkConcurrency is how many schools are enriched at once by default
+const defaultBulkConcurrency = 4
+
+// Where a dossier's AI or NAEP data came from, in the manifest
+const (
+	bulkDataCached  = "cached"
+	bulkDataFetched = "fetched"
+	bulkDataNone    = "none"
+	bulkDataFailed  = "failed"
+)
+
+// BulkSaveOptions says where and how to save a set of dossiers
+type BulkSaveOptions struct {
+	Dir          string
+	Markdown     bool               // Markdown notes instead of JSON
+	Template     *template.Template // Report template to write dossiers with, instead of JSON or notes
+	TemplatePath string             // Where Template was loaded from, so a resumed save can reload it
+	FetchNAEP    bool               // Fetch NAEP data for schools without it cached
+	FetchAI      bool               // Extract website data for schools without it cached
+	Concurrency  int                // Schools enriched at once; 0 uses defaultBulkConcurrency
+	Search       string             // What was searched, recorded in the manifest
+}
+
+// BulkSaveManifest describes a bulk save: one entry per school, in result order
+type BulkSaveManifest struct {
+	SavedAt  time.Time       `json:"saved_at"`
+	Search   string          `json:"search,omitempty"`
+	Format   string          `json:"format"`                 // "json", "markdown", or "template"
+	Template string          `json:"template,omitempty"`     // The report template's name
+	Data     []DataVersion   `json:"data_version,omitempty"` // The CCD files the dossiers are from
+	Saved    int             `json:"saved"`
+	Failed   int             `json:"failed"`
+	Schools  []BulkSaveEntry `json:"schools"`
+}
+
+// BulkSaveEntry is one school's dossier in a bulk save
+type BulkSaveEntry struct {
+	NCESSCH  string   `json:"ncessch"`
+	Name     string   `json:"name"`
+	File     string   `json:"file,omitempty"` // Relative to the save directory; empty if it couldn't be written
+	AIData   string   `json:"ai_data"`        // One of the bulkData* sources
+	NAEPData string   `json:"naep_data"`
+	Errors   []string `json:"errors,omitempty"`
+}
+
+// bulkDossierFilename names a school's dossier after its name and NCES ID, so
+// two schools with one name don't collide
+func bulkDossierFilename(school *School, opts BulkSaveOptions) string {
+	name := schoolNoteFilename(school)
+	switch {
+	case opts.Template != nil:
+		return strings.TrimSuffix(name, noteExtension) + reportExtension(opts.Template)
+	case opts.Markdown:
+		return name
+	}
+	return strings.TrimSuffix(name, noteExtension) + ".json"
+}
+
+// SaveDossiers writes a dossier for each school to opts.Dir and a manifest
+// beside them. Cached AI and NAEP data are always included; missing data is
+// fetched only when asked for, a few schools at a time. SCHOOLFINDER_SAVE_HOOK
+// runs for each dossier written. A school that can't be enriched or written,
+// or whose hook fails, is recorded in the manifest rather than stopping the
+// others. ai and naep may be nil.
+//
+// With a database, the save is recorded as a job as it goes, so one cut short
+// can be finished with ResumeBulkSave.
+func SaveDossiers(ctx context.Context, db *DB, ai *AIScraperService, naep *NAEPClient, schools []School, opts BulkSaveOptions) (*BulkSaveManifest, error) {
+	if opts.FetchAI && ai == nil {
+		return nil, ErrAINotConfigured
+	}
+	var jobID int64
+	if db != nil {
+		var err error
+		if jobID, err = db.startBulkSaveJob(schools, opts); err != nil {
+			return nil, err
+		}
+	}
+	return runBulkSave(ctx, db, ai, naep, jobID, schools, make([]*BulkSaveEntry, len(schools)), opts)
+}
+
+// runBulkSave saves the dossiers of the schools without an entry yet and
+// writes the manifest, recording each school's progress under jobID unless
+// it's 0. done holds the entries of schools already saved, or nil.
+func runBulkSave(ctx context.Context, db *DB, ai *AIScraperService, naep *NAEPClient, jobID int64, schools []School, done []*BulkSaveEntry, opts BulkSaveOptions) (*BulkSaveManifest, error) {
+	manifest, err := writeBulkSave(ctx, db, ai, naep, jobID, schools, done, opts)
+	if jobID != 0 {
+		if jobErr := db.finishJob(jobID, err); jobErr != nil && err == nil {
+			err = jobErr
+		}
+	}
+	return manifest, err
+}
+
+func writeBulkSave(ctx context.Context, db *DB, ai *AIScraperService, naep *NAEPClient, jobID int64, schools []School, done []*BulkSaveEntry, opts BulkSaveOptions) (*BulkSaveManifest, error) {
+	if err := os.MkdirAll(opts.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", opts.Dir, err)
+	}
+	workers := opts.Concurrency
+	if workers <= 0 {
+		workers = defaultBulkConcurrency
+	}
+
+	manifest := &BulkSaveManifest{
+		SavedAt: time.Now().UTC(),
+		Search:  opts.Search,
+		Format:  "json",
+		Schools: make([]BulkSaveEntry, len(schools)),
+	}
+	if db != nil {
+		versions, err := db.CurrentDataVersions()
+		if err != nil {
+			return nil, err
+		}
+		manifest.Data = versions
+	}
+	switch {
+	case opts.Template != nil:
+		manifest.Format, manifest.Template = "template", opts.Template.Name()
+	case opts.Markdown:
+		manifest.Format = "markdown"
+	}
+
+	// Each school is marked running before its work starts, so a crash leaves
+	// it to be redone on resume, and done or failed with its entry after
+	track := func(err error) {
+		if err != nil && logger != nil {
+			logger.Warn("Failed to record bulk save progress", "error", err, "job", jobID)
+		}
+	}
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range min(workers, len(schools)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				if jobID != 0 {
+					track(db.setJobItemRunning(jobID, i))
+				}
+				entry := saveDossier(ctx, db, ai, naep, &schools[i], opts)
+				manifest.Schools[i] = entry
+				if jobID != 0 {
+					errText := ""
+					if entry.File == "" {
+						errText = strings.Join(entry.Errors, "; ")
+					}
+					track(db.finishJobItem(jobID, i, entry, errText))
+				}
+			}
+		}()
+	}
+	for i := range schools {
+		if done[i] != nil {
+			manifest.Schools[i] = *done[i]
+			continue
+		}
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	for _, entry := range manifest.Schools {
+		if entry.File == "" {
+			manifest.Failed++
+		} else {
+			manifest.Saved++
+		}
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(opts.Dir, bulkManifestName), data, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return manifest, nil
+}
+
+// saveDossier enriches and writes one school's dossier
+func saveDossier(ctx context.Context, db *DB, ai *AIScraperService, naep *NAEPClient, school *School, opts BulkSaveOptions) BulkSaveEntry {
+	entry := BulkSaveEntry{NCESSCH: school.NCESSCH, Name: school.Name, AIData: bulkDataNone, NAEPData: bulkDataNone}
+
+	var enhanced *EnhancedSchoolData
+	if ai != nil {
+		if cached, err := ai.CachedSchoolData(school); err == nil && cached.SourceURL != "" {
+			enhanced, entry.AIData = cached, bulkDataCached
+		} else if opts.FetchAI && ctx.Err() == nil {
+			if enhanced, err = ai.ExtractSchoolDataWithWebSearch(ctx, school); err != nil {
+				entry.AIData = bulkDataFailed
+				entry.Errors = append(entry.Errors, "AI extraction: "+errorText(err))
+			} else {
+				entry.AIData = bulkDataFetched
+			}
+		}
+	}
+
+	var naepData *NAEPData
+	if naep != nil {
+		if cached, err := naep.CachedNAEPData(school); err == nil {
+			naepData, entry.NAEPData = cached, bulkDataCached
+		} else if opts.FetchNAEP && ctx.Err() == nil {
+			if naepData, err = naep.FetchNAEPData(ctx, school); err != nil {
+				entry.NAEPData = bulkDataFailed
+				entry.Errors = append(entry.Errors, "NAEP: "+errorText(err))
+			} else {
+				entry.NAEPData = bulkDataFetched
+			}
+		}
+	}
+
+	filename := bulkDossierFilename(school, opts)
+	path := filepath.Join(opts.Dir, filename)
+	var err error
+	if opts.Template != nil {
+		err = writeSchoolReport(db, naep, opts.Template, school, enhanced, naepData, path)
+	} else {
+		err = writeSchoolDossier(db, school, enhanced, naepData, path)
+	}
+	if err != nil {
+		entry.Errors = append(entry.Errors, err.Error())
+		return entry
+	}
+	entry.File = filename
+
+	// Each dossier runs the save hook, as saving one school does; a failed hook
+	// is noted but the dossier still counts as saved
+	if hook := saveHookCommand(); hook != "" {
+		if err := runSaveHook(ctx, hook, path, school); err != nil {
+			entry.Errors = append(entry.Errors, err.Error())
+		}
+	}
+	return entry
+}
+
+// Summary describes the save in a line, e.g. "Saved 5 dossiers to out (1 failed, see manifest.json)"
+func (m *BulkSaveManifest) Summary(dir string) string {
+	noun := "dossiers"
+	if m.Saved == 1 {
+		noun = "dossier"
+	}
+	summary := fmt.Sprintf("Saved %d %s to %s", m.Saved, noun, dir)
+	if m.Failed > 0 {
+		summary += fmt.Sprintf(" (%d failed, see %s)", m.Failed, bulkManifestName)
+	}
+	return summary
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestSaveDossiers(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	if err := saveEnhancedData(db, &EnhancedSchoolData{
+		NCESSCH:         "360000100001",
+		SchoolName:      "Lincoln Elementary School",
+		SourceURL:       "https://lincoln.example.org",
+		MarkdownContent: "# Lincoln",
+		ExtractedAt:     time.Now(),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	schools, err := db.SearchSchools("School", "", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ai := &AIScraperService{db: db, cacheTTL: time.Hour}
+
+	dir := filepath.Join(t.TempDir(), "out")
+	manifest, err := SaveDossiers(context.Background(), db, ai, nil, schools, BulkSaveOptions{Dir: dir, Concurrency: 2, Search: "School"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.Saved != 5 || manifest.Failed != 0 || len(manifest.Schools) != 5 {
+		t.Fatalf("manifest = %+v", manifest)
+	}
+	for i, entry := range manifest.Schools {
+		if entry.NCESSCH != schools[i].NCESSCH {
+			t.Errorf("manifest entry %d is %s, want results in order", i, entry.NCESSCH)
+		}
+		var bundle SchoolBundle
+		data, err := os.ReadFile(filepath.Join(dir, entry.File))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal(data, &bundle); err != nil || bundle.School.NCESSCH != entry.NCESSCH {
+			t.Errorf("%s: bundle for %+v, %v", entry.File, bundle.School, err)
+		}
+		wantAI := bulkDataNone
+		if entry.NCESSCH == "360000100001" {
+			wantAI = bulkDataCached
+		}
+		if entry.AIData != wantAI || entry.NAEPData != bulkDataNone {
+			t.Errorf("%s: ai_data %q, naep_data %q", entry.NCESSCH, entry.AIData, entry.NAEPData)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, bulkManifestName)); err != nil {
+		t.Error(err)
+	}
+	if got := manifest.Summary("out"); got != "Saved 5 dossiers to out" {
+		t.Errorf("summary = %q", got)
+	}
+
+	// Markdown notes, fetching NAEP data that isn't cached
+	naep := NewNAEPClient(db, WithNAEPHTTPClient(&http.Client{Transport: testFixtures(t, "naep.json", naepFixtureKey)}))
+	lincoln, err := db.GetSchoolByID("360000100001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest, err = SaveDossiers(context.Background(), db, nil, naep, []School{*lincoln}, BulkSaveOptions{Dir: dir, Markdown: true, FetchNAEP: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := manifest.Schools[0]
+	if entry.File != schoolNoteFilename(lincoln) || entry.NAEPData != bulkDataFetched {
+		t.Errorf("markdown entry = %+v", entry)
+	}
+
+	if _, err := SaveDossiers(context.Background(), db, nil, nil, schools, BulkSaveOptions{Dir: dir, FetchAI: true}); !errors.Is(err, ErrAINotConfigured) {
+		t.Errorf("fetching AI data without a scraper: %v", err)
+	}
+}
+
+func TestSaveDossiersRunsSaveHook(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hooks run with sh")
+	}
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	schools, err := db.SearchSchools("School", "", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	log := filepath.Join(dir, "hook.log")
+	t.Setenv("SCHOOLFINDER_SAVE_HOOK", `[ "$SCHOOLFINDER_SCHOOL_ID" = 360000100002 ] && exit 1; echo "$SCHOOLFINDER_SCHOOL_ID $1" >> `+log)
+
+	out := filepath.Join(dir, "out")
+	manifest, err := SaveDossiers(context.Background(), db, nil, nil, schools, BulkSaveOptions{Dir: out, Concurrency: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.Saved != len(schools) || manifest.Failed != 0 {
+		t.Errorf("a failed hook failed the save: %+v", manifest)
+	}
+	data, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range manifest.Schools {
+		ran := strings.Contains(string(data), entry.NCESSCH+" "+filepath.Join(out, entry.File))
+		if entry.NCESSCH == "360000100002" {
+			if ran || len(entry.Errors) != 1 || !strings.Contains(entry.Errors[0], "save hook failed") {
+				t.Errorf("failed hook: ran %v, errors %q", ran, entry.Errors)
+			}
+		} else if !ran || len(entry.Errors) != 0 {
+			t.Errorf("%s: hook ran %v, errors %q", entry.NCESSCH, ran, entry.Errors)
+		}
+	}
+}
+
+func TestSaveAllPrompt(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	schools, err := db.SearchSchools("School", "", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := initialModel(db, nil, nil, "")
+	m.schools = schools
+
+	update := func(msg tea.Msg) tea.Cmd {
+		newModel, cmd := m.Update(msg)
+		m = newModel.(model)
+		return cmd
+	}
+
+	update(tea.KeyMsg{Type: tea.KeyCtrlL})
+	if m.currentView != saveAllPromptView || !strings.Contains(m.View(), "Saving 5 dossiers") {
+		t.Fatal("Expected Ctrl+L to open the save all prompt")
+	}
+	dir := filepath.Join(t.TempDir(), "dossiers")
+	m.saveAllInput.SetValue(dir)
+	update(tea.KeyMsg{Type: tea.KeyTab})
+	if !m.bulkSave.Markdown {
+		t.Error("Expected Tab to switch to markdown notes")
+	}
+	cmd := update(tea.KeyMsg{Type: tea.KeyEnter})
+	if !m.savingAll || cmd == nil {
+		t.Fatal("Expected Enter to start saving")
+	}
+	update(cmd())
+	if m.currentView != searchView || m.err != nil || m.savedNotice != "Saved 5 dossiers to "+dir {
+		t.Errorf("after saving: view %v, err %v, notice %q", m.currentView, m.err, m.savedNotice)
+	}
+	notes, _ := filepath.Glob(filepath.Join(dir, "*.md"))
+	if len(notes) != 5 {
+		t.Errorf("saved notes = %v", notes)
+	}
+}
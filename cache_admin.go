@@ -0,0 +1,93 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// managedCache is a cache table admins can review and clear
+type managedCache struct {
+	Table       string
+	Label       string
+	Description string
+	TimeColumn  string // When an entry was fetched or generated
+}
+
+var managedCaches = []managedCache{
+	{"ai_scraper_cache", "School websites", "Data extracted from school websites with AI", "extracted_at"},
+	{"naep_cache", "NAEP scores", "State, district, and national NAEP results for each school", "extracted_at"},
+	{"parent_summary_cache", "Parent summaries", "AI-written plain-language summaries", "generated_at"},
+}
+
+// findManagedCache looks up a managed cache by table name
+func findManagedCache(table string) (managedCache, bool) {
+	for _, c := range managedCaches {
+		if c.Table == table {
+			return c, true
+		}
+	}
+	return managedCache{}, false
+}
+
+// CacheSize describes what a managed cache holds
+type CacheSize struct {
+	managedCache
+	Entries int
+	Oldest  time.Time // Zero when empty
+	Newest  time.Time
+}
+
+// CacheSizes counts the entries in each managed cache
+func (d *DB) CacheSizes() ([]CacheSize, error) {
+	sizes := make([]CacheSize, 0, len(managedCaches))
+	for _, c := range managedCaches {
+		size := CacheSize{managedCache: c}
+		var oldest, newest sql.NullTime
+		query := fmt.Sprintf(`SELECT count(*), min(%[1]s), max(%[1]s) FROM %[2]s`, c.TimeColumn, c.Table)
+		if err := d.conn.QueryRow(query).Scan(&size.Entries, &oldest, &newest); err != nil {
+			return nil, fmt.Errorf("failed to count %s: %w", c.Table, err)
+		}
+		size.Oldest, size.Newest = oldest.Time, newest.Time
+		sizes = append(sizes, size)
+	}
+	return sizes, nil
+}
+
+// ClearCache deletes every entry in a managed cache, along with the in-memory
+// copies, so they're fetched or generated again when next needed. It returns
+// the number of entries deleted.
+func (d *DB) ClearCache(table string) (int, error) {
+	c, ok := findManagedCache(table)
+	if !ok {
+		return 0, fmt.Errorf("unknown cache %q", table)
+	}
+
+	rows, err := d.conn.Query(fmt.Sprintf(`DELETE FROM %s RETURNING ncessch`, c.Table))
+	if err != nil {
+		return 0, fmt.Errorf("failed to clear %s: %w", c.Table, err)
+	}
+	var cleared []string
+	for rows.Next() {
+		var ncessch string
+		if err := rows.Scan(&ncessch); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to clear %s: %w", c.Table, err)
+		}
+		cleared = append(cleared, ncessch)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to clear %s: %w", c.Table, err)
+	}
+
+	d.aiCache.Purge()
+	d.naepCache.Purge()
+	for _, ncessch := range cleared {
+		d.notifyCacheInvalidated(ncessch)
+	}
+	if logger != nil {
+		logger.Info("Cleared cache", "table", c.Table, "entries", len(cleared))
+	}
+	return len(cleared), nil
+}
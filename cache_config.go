@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Default cache lifetimes, overridable with AI_CACHE_TTL and NAEP_CACHE_TTL
+const (
+	defaultAICacheTTL   = 30 * 24 * time.Hour // 30 days
+	defaultNAEPCacheTTL = 90 * 24 * time.Hour // 90 days
+)
+
+// cacheNoExpiry loads a cache entry regardless of age, for stale-while-revalidate
+const cacheNoExpiry = time.Duration(math.MaxInt64)
+
+// Background cache refresh limits
+const (
+	backgroundRefreshTimeout = 5 * time.Minute  // Bounds a single refresh
+	refreshRetryDelay        = 15 * time.Minute // Wait after a failed refresh before trying again
+)
+
+// Defaults for the in-memory hot-read cache in front of DuckDB, overridable with
+// HOT_CACHE_SIZE (entries per cache, 0 disables) and HOT_CACHE_TTL
+const (
+	defaultHotCacheSize = 500
+	defaultHotCacheTTL  = 5 * time.Minute
+)
+
+// parseCacheTTL parses a cache lifetime such as "30d", "12h", or "90m"
+func parseCacheTTL(raw string) (time.Duration, error) {
+	raw = strings.TrimSpace(raw)
+	if days, ok := strings.CutSuffix(raw, "d"); ok {
+		n, err := strconv.ParseFloat(days, 64)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid cache TTL %q", raw)
+		}
+		return time.Duration(n * float64(24*time.Hour)), nil
+	}
+
+	ttl, err := time.ParseDuration(raw)
+	if err != nil || ttl <= 0 {
+		return 0, fmt.Errorf("invalid cache TTL %q", raw)
+	}
+	return ttl, nil
+}
+
+// cacheTTLFromEnv reads a cache lifetime from an environment variable, falling back to def
+func cacheTTLFromEnv(name string, def time.Duration) time.Duration {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return def
+	}
+
+	ttl, err := parseCacheTTL(raw)
+	if err != nil {
+		if logger != nil {
+			logger.Warn("Ignoring invalid cache TTL", "variable", name, "value", raw)
+		}
+		return def
+	}
+	return ttl
+}
+
+// hotCacheSizeFromEnv reads the hot-read cache size from HOT_CACHE_SIZE, falling back to the default
+func hotCacheSizeFromEnv() int {
+	raw := strings.TrimSpace(os.Getenv("HOT_CACHE_SIZE"))
+	if raw == "" {
+		return defaultHotCacheSize
+	}
+
+	size, err := strconv.Atoi(raw)
+	if err != nil || size < 0 {
+		if logger != nil {
+			logger.Warn("Ignoring invalid cache size", "variable", "HOT_CACHE_SIZE", "value", raw)
+		}
+		return defaultHotCacheSize
+	}
+	return size
+}
+
+// backgroundRefresher runs at most one refresh per key at a time and backs off after
+// failures so a broken upstream isn't hit on every page view. The zero value is ready to use.
+type backgroundRefresher struct {
+	mu       sync.Mutex
+	inFlight map[string]bool
+	failedAt map[string]time.Time
+}
+
+// start runs fn in a goroutine unless a refresh for key is already running or recently failed.
+// It reports whether a new refresh was started.
+func (r *backgroundRefresher) start(key string, fn func() error) bool {
+	r.mu.Lock()
+	if r.inFlight == nil {
+		r.inFlight = make(map[string]bool)
+		r.failedAt = make(map[string]time.Time)
+	}
+	if r.inFlight[key] || time.Since(r.failedAt[key]) < refreshRetryDelay {
+		r.mu.Unlock()
+		return false
+	}
+	r.inFlight[key] = true
+	r.mu.Unlock()
+
+	go func() {
+		err := fn()
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		delete(r.inFlight, key)
+		if err != nil {
+			r.failedAt[key] = time.Now()
+		} else {
+			delete(r.failedAt, key)
+		}
+	}()
+	return true
+}
+
+// refreshing reports whether a refresh for key is currently running
+func (r *backgroundRefresher) refreshing(key string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.inFlight[key]
+}
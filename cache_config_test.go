@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestParseCacheTTL tests parsing of day and Go duration cache lifetimes
+func TestParseCacheTTL(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected time.Duration
+		wantErr  bool
+	}{
+		{"30d", 30 * 24 * time.Hour, false},
+		{"0.5d", 12 * time.Hour, false},
+		{"12h", 12 * time.Hour, false},
+		{" 90m ", 90 * time.Minute, false},
+		{"0d", 0, true},
+		{"-1h", 0, true},
+		{"soon", 0, true},
+		{"", 0, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			ttl, err := parseCacheTTL(tc.input)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("Expected error for %q, got %v", tc.input, ttl)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if ttl != tc.expected {
+				t.Errorf("Expected %v, got %v", tc.expected, ttl)
+			}
+		})
+	}
+}
+
+// TestCacheTTLFromEnv tests environment overrides and fallback on invalid values
+func TestCacheTTLFromEnv(t *testing.T) {
+	t.Setenv("NAEP_CACHE_TTL", "7d")
+	if ttl := cacheTTLFromEnv("NAEP_CACHE_TTL", defaultNAEPCacheTTL); ttl != 7*24*time.Hour {
+		t.Errorf("Expected 7 days, got %v", ttl)
+	}
+
+	t.Setenv("NAEP_CACHE_TTL", "bogus")
+	if ttl := cacheTTLFromEnv("NAEP_CACHE_TTL", defaultNAEPCacheTTL); ttl != defaultNAEPCacheTTL {
+		t.Errorf("Expected default for invalid value, got %v", ttl)
+	}
+}
+
+// TestBackgroundRefresher tests de-duplication of concurrent refreshes and backoff after failures
+func TestBackgroundRefresher(t *testing.T) {
+	var r backgroundRefresher
+	release := make(chan struct{})
+	done := make(chan struct{})
+
+	if !r.start("a", func() error { <-release; close(done); return nil }) {
+		t.Fatal("Expected first refresh to start")
+	}
+	if r.start("a", func() error { return nil }) {
+		t.Error("Expected duplicate refresh to be skipped")
+	}
+	if !r.refreshing("a") {
+		t.Error("Expected refresh to be in flight")
+	}
+
+	close(release)
+	<-done
+	waitForRefresh(t, &r, "a")
+
+	// A failed refresh is not retried immediately
+	failed := make(chan struct{})
+	r.start("b", func() error { close(failed); return errTestRefresh })
+	<-failed
+	waitForRefresh(t, &r, "b")
+	if r.start("b", func() error { return nil }) {
+		t.Error("Expected refresh to be skipped during retry delay")
+	}
+}
+
+// TestCachedNAEPDataStale tests that expired NAEP data is served while a refresh runs
+func TestCachedNAEPDataStale(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	data := MockNAEPData("360000100001", "CA", "", false, false)
+	stateScores, _ := json.Marshal(data.StateScores)
+	if err := db.SaveNAEPCache("360000100001", "CA", "", stateScores, nil, nil, time.Now().Add(-48*time.Hour)); err != nil {
+		t.Fatalf("Failed to seed NAEP cache: %v", err)
+	}
+
+	client := &NAEPClient{httpClient: server.Client(), baseURL: server.URL, db: db, cacheTTL: 24 * time.Hour}
+	school := MockSchool("360000100001", "Lincoln Elementary", "San Francisco Unified", "CA", "PK", "05")
+
+	cached, err := client.FetchNAEPData(context.Background(), school)
+	if err != nil {
+		t.Fatalf("Expected stale data instead of error: %v", err)
+	}
+	if !cached.Stale {
+		t.Error("Expected data past its TTL to be marked stale")
+	}
+	if len(cached.StateScores) == 0 {
+		t.Error("Expected stale scores to be served")
+	}
+
+	waitForRefresh(t, &client.refresher, "360000100001")
+	if requests.Load() == 0 {
+		t.Error("Expected a background refresh to hit the API")
+	}
+
+	// Within the TTL, cached data is fresh
+	client.cacheTTL = 72 * time.Hour
+	fresh, err := client.CachedNAEPData(school)
+	if err != nil || fresh.Stale {
+		t.Errorf("Expected fresh cached data, got stale=%v err=%v", fresh != nil && fresh.Stale, err)
+	}
+}
+
+// TestCachedSchoolDataStale tests that reading expired website data doesn't
+// start a Claude extraction, which only editors can ask for
+func TestCachedSchoolDataStale(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	if err := saveEnhancedData(db, &EnhancedSchoolData{
+		NCESSCH:     "360000100001",
+		SchoolName:  "Lincoln Elementary School",
+		SourceURL:   "https://lincoln.example.org",
+		ExtractedAt: time.Now().Add(-48 * time.Hour),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	scraper := &AIScraperService{db: db, cacheTTL: 24 * time.Hour}
+	school, err := db.GetSchoolByID("360000100001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cached, err := scraper.CachedSchoolData(school)
+	if err != nil || !cached.Stale || cached.Refreshing {
+		t.Fatalf("CachedSchoolData = %+v, %v; want stale and not refreshing", cached, err)
+	}
+
+	router := NewRouter(ServerConfig{DB: db, AIScraper: scraper, AdminPassword: "hunter22"})
+	for _, path := range []string{"/schools/360000100001", "/api/schools/360000100001", "/api/v1/schools/360000100001/bundle"} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("GET %s: status %d", path, rec.Code)
+		}
+		if path == "/schools/360000100001" && !strings.Contains(rec.Body.String(), "An editor can re-extract it") {
+			t.Error("viewer isn't told the website data is stale")
+		}
+	}
+	if scraper.refresher.inFlight != nil {
+		t.Error("a viewer's request started a background refresh")
+	}
+
+	// Editors, here everyone on a single-user server, are offered the refresh instead
+	rec := httptest.NewRecorder()
+	NewRouter(ServerConfig{DB: db, AIScraper: scraper}).ServeHTTP(rec, httptest.NewRequest("GET", "/schools/360000100001", nil))
+	if !strings.Contains(rec.Body.String(), "Re-extract") {
+		t.Error("single-user detail page doesn't offer to re-extract stale website data")
+	}
+}
+
+var errTestRefresh = errors.New("refresh failed")
+
+// waitForRefresh blocks until a background refresh for key finishes
+func waitForRefresh(t *testing.T, r *backgroundRefresher, key string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for r.refreshing(key) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for refresh of %s", key)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
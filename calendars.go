@@ -0,0 +1,483 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Kinds of academic calendar events
+const (
+	calendarFirstDay = "first_day"
+	calendarLastDay  = "last_day"
+	calendarBreak    = "break"   // Several days off, such as winter break
+	calendarHoliday  = "holiday" // A single day off
+)
+
+// CalendarEvent is a date or range of dates in a school's academic calendar.
+// End is the last day, and is the same as Start for single days.
+type CalendarEvent struct {
+	Kind  string    `json:"kind"`
+	Name  string    `json:"name"`
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// Off reports whether the event is a day or days without school
+func (e CalendarEvent) Off() bool {
+	return e.Kind == calendarBreak || e.Kind == calendarHoliday
+}
+
+// Dates formats the event's dates, e.g. "Dec 22 – Jan 2" or "Nov 11"
+func (e CalendarEvent) Dates() string {
+	if e.End.Equal(e.Start) {
+		return e.Start.Format("Jan 2")
+	}
+	return e.Start.Format("Jan 2") + " – " + e.End.Format("Jan 2")
+}
+
+// SchoolCalendar is a school's academic calendar extracted from its or its
+// district's website
+type SchoolCalendar struct {
+	NCESSCH     string          `json:"ncessch"`
+	SchoolName  string          `json:"school_name"`
+	SchoolYear  string          `json:"school_year"` // e.g. 2025-2026, as the calendar gives it
+	SourceURL   string          `json:"source_url"`
+	ExtractedAt time.Time       `json:"extracted_at"`
+	Events      []CalendarEvent `json:"events"` // In date order
+
+	// Markdown content from AI extraction
+	MarkdownContent string `json:"markdown_content"`
+
+	Stale bool `json:"-"` // Older than the AI cache TTL
+}
+
+// FirstDay returns the first day of school, if the calendar has one
+func (c *SchoolCalendar) FirstDay() (time.Time, bool) {
+	return c.eventDate(calendarFirstDay)
+}
+
+// LastDay returns the last day of school, if the calendar has one
+func (c *SchoolCalendar) LastDay() (time.Time, bool) {
+	return c.eventDate(calendarLastDay)
+}
+
+func (c *SchoolCalendar) eventDate(kind string) (time.Time, bool) {
+	for _, e := range c.Events {
+		if e.Kind == kind {
+			return e.Start, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// off reports whether day is a break or holiday
+func (c *SchoolCalendar) off(day time.Time) bool {
+	for _, e := range c.Events {
+		if e.Off() && !day.Before(e.Start) && !day.After(e.End) {
+			return true
+		}
+	}
+	return false
+}
+
+// inSession reports whether day falls between the first and last day of school
+func (c *SchoolCalendar) inSession(day time.Time) bool {
+	first, hasFirst := c.FirstDay()
+	last, hasLast := c.LastDay()
+	return hasFirst && hasLast && !day.Before(first) && !day.After(last)
+}
+
+// calendarSourcePattern matches the "Calendar:" line the extraction prompt asks for
+var calendarSourcePattern = regexp.MustCompile(`(?im)^[\s>*+-]*\**calendar\**\s*:\**\s*<?(https?://[^\s>)]+)`)
+
+// calendarYearPattern matches the "School year:" line the extraction prompt asks for
+var calendarYearPattern = regexp.MustCompile(`(?im)^[\s>*+-]*\**school year\**\s*:\**\s*(\d{4})\s*[-–/]\s*(\d{2,4})`)
+
+// calendarHeadingPattern matches the "Calendar" heading the extraction prompt asks for
+var calendarHeadingPattern = regexp.MustCompile(`(?im)^#{1,4}\s*\**\s*calendar\b.*$`)
+
+// parseSchoolCalendar reads the calendar page, school year, and the
+// "- Event | Start | End" lines under the "Calendar" heading. Events named
+// like the first or last day of school mark the school year; other events
+// are breaks when they span several days and holidays otherwise.
+func parseSchoolCalendar(markdown string) (source, year string, events []CalendarEvent) {
+	if m := calendarSourcePattern.FindStringSubmatch(markdown); m != nil {
+		source = strings.TrimRight(m[1], ".,")
+	}
+	if m := calendarYearPattern.FindStringSubmatch(markdown); m != nil {
+		end := m[2]
+		if len(end) == 2 {
+			end = m[1][:2] + end
+		}
+		year = m[1] + "-" + end
+	}
+
+	heading := calendarHeadingPattern.FindStringIndex(markdown)
+	if heading == nil {
+		return source, year, nil
+	}
+	body := markdown[heading[1]:]
+	if next := strings.Index(body, "\n#"); next >= 0 {
+		body = body[:next]
+	}
+
+	for _, line := range strings.Split(body, "\n") {
+		values, ok := markdownRowFields(line, 3)
+		if !ok || values[0] == "" {
+			continue
+		}
+		start, err := time.Parse(timelineDateLayout, values[1])
+		if err != nil {
+			continue
+		}
+		end := start
+		if values[2] != "" {
+			if parsed, err := time.Parse(timelineDateLayout, values[2]); err == nil && !parsed.Before(start) {
+				end = parsed
+			}
+		}
+
+		event := CalendarEvent{Name: values[0], Start: start, End: end}
+		name := strings.ToLower(values[0])
+		switch {
+		case strings.Contains(name, "first day"):
+			event.Kind = calendarFirstDay
+		case strings.Contains(name, "last day"):
+			event.Kind = calendarLastDay
+		case end.After(start):
+			event.Kind = calendarBreak
+		default:
+			event.Kind = calendarHoliday
+		}
+		events = append(events, event)
+	}
+	sortCalendarEvents(events)
+	return source, year, events
+}
+
+// sortCalendarEvents orders events by date, then name
+func sortCalendarEvents(events []CalendarEvent) {
+	slices.SortFunc(events, func(a, b CalendarEvent) int {
+		return cmp.Or(a.Start.Compare(b.Start), a.End.Compare(b.End), cmp.Compare(a.Name, b.Name))
+	})
+}
+
+// ExtractCalendar uses Claude with web search to find a school's academic
+// calendar: the first and last day of school, breaks, and holidays
+func (s *AIScraperService) ExtractCalendar(ctx context.Context, school *School) (*SchoolCalendar, error) {
+	content := fmt.Sprintf(`Find the academic calendar for the current or upcoming school year at %s, a public school in %s, %s (district: %s, NCES ID %s).
+
+Use web search to find the school's or its district's official calendar page or PDF. Most schools follow their district's calendar.
+
+Write your findings in markdown, in exactly this format:
+
+Calendar: <URL of the calendar page or PDF>
+School year: <e.g. 2025-2026>
+
+## Calendar
+- First day of school | YYYY-MM-DD
+- Event name | YYYY-MM-DD | YYYY-MM-DD
+- Last day of school | YYYY-MM-DD
+
+List every break (e.g. Thanksgiving break, winter break, spring break) with its first and last day off, and every single-day holiday or no-school day with just its date.
+Leave out weekends, early release days, and events when school is in session. Don't guess dates the calendar doesn't give.`,
+		school.Name, school.City, school.State, school.District, school.NCESSCH)
+
+	responseText, err := s.webSearch(ctx, content, "school_name", school.Name, "ncessch", school.NCESSCH)
+	if err != nil {
+		return nil, err
+	}
+
+	if logger != nil {
+		logger.Info("Successfully extracted school calendar with Claude", "school_name", school.Name, "ncessch", school.NCESSCH, slog.Int("response_length", len(responseText)))
+	}
+
+	calendar := &SchoolCalendar{
+		NCESSCH:         school.NCESSCH,
+		SchoolName:      school.Name,
+		ExtractedAt:     time.Now(),
+		MarkdownContent: responseText,
+	}
+	calendar.SourceURL, calendar.SchoolYear, calendar.Events = parseSchoolCalendar(responseText)
+	return calendar, nil
+}
+
+// ScrapeCalendar returns a school's cached calendar, extracting it with
+// Claude when there is none or it's older than the AI cache TTL
+func (s *AIScraperService) ScrapeCalendar(ctx context.Context, school *School) (*SchoolCalendar, error) {
+	s.db.RecordUsage(usageScrape)
+
+	if cached, err := s.db.LoadSchoolCalendar(school.NCESSCH); err == nil && time.Since(cached.ExtractedAt) <= s.cacheTTL {
+		if logger != nil {
+			logger.Info("Returning cached school calendar from database", "school_name", school.Name, "ncessch", school.NCESSCH)
+		}
+		return cached, nil
+	}
+
+	// Concurrent extractions for a school share one Claude request
+	sc := *school
+	return coalesce(ctx, &s.flights, flightKey{sc.NCESSCH, "calendar"}, func(ctx context.Context) (*SchoolCalendar, error) {
+		calendar, err := s.ExtractCalendar(ctx, &sc)
+		if err != nil {
+			if logger != nil {
+				logger.Error("Failed to extract school calendar", "error", err, "school_name", sc.Name, "ncessch", sc.NCESSCH)
+			}
+			return nil, err
+		}
+
+		// Don't fail if cache save fails, just log
+		if err := s.db.SaveSchoolCalendar(calendar); err != nil && logger != nil {
+			logger.Warn("Failed to save school calendar", "error", err, "ncessch", sc.NCESSCH)
+		}
+		return calendar, nil
+	})
+}
+
+// SaveSchoolCalendar saves a school's extracted calendar, replacing any earlier extraction
+func (d *DB) SaveSchoolCalendar(c *SchoolCalendar) error {
+	events, err := json.Marshal(c.Events)
+	if err != nil {
+		return fmt.Errorf("failed to marshal calendar events: %w", err)
+	}
+
+	_, err = d.conn.Exec(`
+		INSERT INTO school_calendars (ncessch, school_name, school_year, source_url, events, markdown_content, extracted_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (ncessch) DO UPDATE SET
+			school_name = EXCLUDED.school_name,
+			school_year = EXCLUDED.school_year,
+			source_url = EXCLUDED.source_url,
+			events = EXCLUDED.events,
+			markdown_content = EXCLUDED.markdown_content,
+			extracted_at = EXCLUDED.extracted_at
+	`, c.NCESSCH, c.SchoolName, c.SchoolYear, c.SourceURL, string(events), c.MarkdownContent, c.ExtractedAt)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to save school calendar", "error", err, "ncessch", c.NCESSCH)
+		}
+		return fmt.Errorf("failed to save school calendar: %w", err)
+	}
+	return nil
+}
+
+// LoadSchoolCalendar loads a school's extracted calendar, returning
+// sql.ErrNoRows when there is none. Calendars older than the AI cache TTL
+// are returned with Stale set.
+func (d *DB) LoadSchoolCalendar(ncessch string) (*SchoolCalendar, error) {
+	c := &SchoolCalendar{NCESSCH: ncessch}
+	var events string
+	err := d.conn.QueryRow(`
+		SELECT school_name, school_year, source_url, events, markdown_content, extracted_at
+		FROM school_calendars
+		WHERE ncessch = $1
+	`, ncessch).Scan(&c.SchoolName, &c.SchoolYear, &c.SourceURL, &events, &c.MarkdownContent, &c.ExtractedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to load school calendar: %w", err)
+	}
+	if err := json.Unmarshal([]byte(events), &c.Events); err != nil {
+		return nil, fmt.Errorf("failed to read calendar events: %w", err)
+	}
+	c.Stale = time.Since(c.ExtractedAt) > cacheTTLFromEnv("AI_CACHE_TTL", defaultAICacheTTL)
+	return c, nil
+}
+
+// CalendarComparison lines up several schools' calendars on one timeline
+type CalendarComparison struct {
+	Start, End time.Time // The earliest first day and latest last day
+	Rows       []CalendarRow
+	Months     []CalendarTick
+	Mismatches []CalendarMismatch
+}
+
+// CalendarRow is one school on a calendar comparison. Calendar is nil when
+// the school's calendar hasn't been extracted.
+type CalendarRow struct {
+	School   *School
+	Calendar *SchoolCalendar
+	Bars     []CalendarBar
+}
+
+// CalendarBar places an event on the comparison timeline, as percentages
+type CalendarBar struct {
+	Event CalendarEvent
+	Left  float64
+	Width float64
+}
+
+// CalendarTick labels the start of a month on the comparison timeline
+type CalendarTick struct {
+	Label string
+	Left  float64
+}
+
+// CalendarMismatch is a run of weekdays when some of the schools are off and
+// others are in session, such as one school's spring break
+type CalendarMismatch struct {
+	Start, End time.Time
+	Off        []string // Names of the schools off
+	InSession  []string // Names of the schools in session
+}
+
+// Dates formats the mismatch's dates, e.g. "Mar 23 – Mar 27" or "Nov 11"
+func (m CalendarMismatch) Dates() string {
+	return CalendarEvent{Start: m.Start, End: m.End}.Dates()
+}
+
+// CompareCalendars lines up the calendars of schools, placing each event on a
+// shared timeline from the earliest first day to the latest last day and
+// finding the weekdays the schools' breaks don't line up
+func CompareCalendars(db *DB, schools []*School) (*CalendarComparison, error) {
+	comparison := &CalendarComparison{}
+	var calendars []*SchoolCalendar
+	for _, school := range schools {
+		row := CalendarRow{School: school}
+		calendar, err := db.LoadSchoolCalendar(school.NCESSCH)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		if calendar != nil {
+			row.Calendar = calendar
+			calendars = append(calendars, calendar)
+			if first, ok := calendar.FirstDay(); ok && (comparison.Start.IsZero() || first.Before(comparison.Start)) {
+				comparison.Start = first
+			}
+			if last, ok := calendar.LastDay(); ok && last.After(comparison.End) {
+				comparison.End = last
+			}
+		}
+		comparison.Rows = append(comparison.Rows, row)
+	}
+	if comparison.Start.IsZero() || !comparison.End.After(comparison.Start) {
+		return comparison, nil
+	}
+
+	span := comparison.End.Sub(comparison.Start).Hours()/24 + 1
+	position := func(day time.Time) float64 {
+		return day.Sub(comparison.Start).Hours() / 24 / span * 100
+	}
+	for i := range comparison.Rows {
+		row := &comparison.Rows[i]
+		if row.Calendar == nil {
+			continue
+		}
+		for _, e := range row.Calendar.Events {
+			if e.End.Before(comparison.Start) || e.Start.After(comparison.End) {
+				continue
+			}
+			row.Bars = append(row.Bars, CalendarBar{Event: e, Left: position(e.Start), Width: position(e.End.AddDate(0, 0, 1)) - position(e.Start)})
+		}
+	}
+	for month := time.Date(comparison.Start.Year(), comparison.Start.Month(), 1, 0, 0, 0, 0, time.UTC); !month.After(comparison.End); month = month.AddDate(0, 1, 0) {
+		if month.Before(comparison.Start) {
+			continue
+		}
+		comparison.Months = append(comparison.Months, CalendarTick{Label: month.Format("Jan"), Left: position(month)})
+	}
+
+	// Walk the weekdays, grouping runs with the same schools off and in session
+	var current *CalendarMismatch
+	key := func(m *CalendarMismatch) string {
+		return strings.Join(m.Off, "\x00") + "\x01" + strings.Join(m.InSession, "\x00")
+	}
+	for day := comparison.Start; !day.After(comparison.End); day = day.AddDate(0, 0, 1) {
+		if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+			continue
+		}
+		m := CalendarMismatch{Start: day, End: day}
+		for _, c := range calendars {
+			switch {
+			case !c.inSession(day):
+			case c.off(day):
+				m.Off = append(m.Off, c.SchoolName)
+			default:
+				m.InSession = append(m.InSession, c.SchoolName)
+			}
+		}
+		if len(m.Off) == 0 || len(m.InSession) == 0 {
+			current = nil
+			continue
+		}
+		if current != nil && key(current) == key(&m) {
+			current.End = day
+			continue
+		}
+		comparison.Mismatches = append(comparison.Mismatches, m)
+		current = &comparison.Mismatches[len(comparison.Mismatches)-1]
+	}
+	return comparison, nil
+}
+
+// WriteCalendarICS writes a school's academic calendar as an iCalendar file of
+// all-day events. UIDs are stable, so importing again updates events instead
+// of duplicating them.
+func WriteCalendarICS(w io.Writer, calendar *SchoolCalendar, location string, now time.Time) error {
+	var b strings.Builder
+	line := func(s string) {
+		b.WriteString(foldICSLine(s))
+		b.WriteString("\r\n")
+	}
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//School Finder//School Calendar//EN")
+	line("CALSCALE:GREGORIAN")
+	line("X-WR-CALNAME:" + escapeICSText(calendar.SchoolName+" calendar"))
+	stamp := now.UTC().Format("20060102T150405Z")
+	for _, e := range calendar.Events {
+		description := fmt.Sprintf("%s academic calendar", calendar.SchoolName)
+		if calendar.SchoolYear != "" {
+			description += " " + calendar.SchoolYear
+		}
+		if calendar.SourceURL != "" {
+			description += "\n" + calendar.SourceURL
+		}
+
+		line("BEGIN:VEVENT")
+		line(fmt.Sprintf("UID:school-calendar-%s-%s-%s@schoolfinder", calendar.NCESSCH, e.Kind, e.Start.Format("20060102")))
+		line("DTSTAMP:" + stamp)
+		line("DTSTART;VALUE=DATE:" + e.Start.Format("20060102"))
+		line("DTEND;VALUE=DATE:" + e.End.AddDate(0, 0, 1).Format("20060102"))
+		line("SUMMARY:" + escapeICSText(e.Name+": "+calendar.SchoolName))
+		line("DESCRIPTION:" + escapeICSText(description))
+		if location != "" {
+			line("LOCATION:" + escapeICSText(location))
+		}
+		line("TRANSP:TRANSPARENT")
+		line("END:VEVENT")
+	}
+	line("END:VCALENDAR")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// favoriteSchoolIDs returns the schools saved for any child, in the order
+// children and their saved schools are listed, without repeats
+func favoriteSchoolIDs(db *DB) ([]string, error) {
+	children, err := db.Children()
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, c := range children {
+		for _, id := range c.Schools {
+			if !slices.Contains(ids, id) {
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids, nil
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSchoolCalendar = `I found the district's 2025-26 calendar.
+
+Calendar: https://www.sfusd.edu/calendar.pdf
+**School year:** 2025-26
+
+## Calendar
+- First day of school | 2025-08-18
+- Labor Day | 2025-09-01
+- Winter break | 2025-12-22 | 2026-01-02
+- Spring break | 2026-03-23 | 2026-03-27
+- Last day of school | 2026-06-04
+- Open house | sometime in the fall
+
+## Notes
+- Dates are subject to change.
+`
+
+func TestParseSchoolCalendar(t *testing.T) {
+	source, year, events := parseSchoolCalendar(testSchoolCalendar)
+	if source != "https://www.sfusd.edu/calendar.pdf" || year != "2025-2026" {
+		t.Errorf("source, year = %q, %q", source, year)
+	}
+	if len(events) != 5 {
+		t.Fatalf("events = %+v", events)
+	}
+	kinds := []string{calendarFirstDay, calendarHoliday, calendarBreak, calendarBreak, calendarLastDay}
+	for i, e := range events {
+		if e.Kind != kinds[i] {
+			t.Errorf("%s kind = %q, want %q", e.Name, e.Kind, kinds[i])
+		}
+	}
+	if winter := events[2]; winter.Dates() != "Dec 22 – Jan 2" {
+		t.Errorf("winter break dates = %q", winter.Dates())
+	}
+
+	if _, _, events := parseSchoolCalendar("I couldn't find a calendar."); len(events) != 0 {
+		t.Errorf("events when none were found = %+v", events)
+	}
+}
+
+// saveTestCalendar saves a calendar with a school year and the given breaks
+func saveTestCalendar(t *testing.T, db *DB, ncessch, name string, breaks ...CalendarEvent) {
+	t.Helper()
+	events := append([]CalendarEvent{
+		{Kind: calendarFirstDay, Name: "First day of school", Start: calendarDate(2025, 8, 18), End: calendarDate(2025, 8, 18)},
+		{Kind: calendarLastDay, Name: "Last day of school", Start: calendarDate(2026, 6, 4), End: calendarDate(2026, 6, 4)},
+	}, breaks...)
+	sortCalendarEvents(events)
+	if err := db.SaveSchoolCalendar(&SchoolCalendar{NCESSCH: ncessch, SchoolName: name, SchoolYear: "2025-2026", ExtractedAt: time.Now(), Events: events}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func calendarDate(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestCompareCalendars(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	winter := CalendarEvent{Kind: calendarBreak, Name: "Winter break", Start: calendarDate(2025, 12, 22), End: calendarDate(2026, 1, 2)}
+	saveTestCalendar(t, db, "360000100001", "Lincoln Elementary", winter,
+		CalendarEvent{Kind: calendarBreak, Name: "Spring break", Start: calendarDate(2026, 3, 23), End: calendarDate(2026, 3, 27)})
+	saveTestCalendar(t, db, "360000100002", "Washington High", winter,
+		CalendarEvent{Kind: calendarBreak, Name: "Spring break", Start: calendarDate(2026, 3, 30), End: calendarDate(2026, 4, 3)})
+
+	schools, err := db.GetSchoolsInOrder([]string{"360000100001", "360000100002", "360000100003"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	comparison, err := CompareCalendars(db, schools)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !comparison.Start.Equal(calendarDate(2025, 8, 18)) || !comparison.End.Equal(calendarDate(2026, 6, 4)) {
+		t.Errorf("timeline = %v to %v", comparison.Start, comparison.End)
+	}
+	if len(comparison.Rows) != 3 || comparison.Rows[2].Calendar != nil || len(comparison.Rows[0].Bars) != 4 {
+		t.Errorf("rows = %+v", comparison.Rows)
+	}
+
+	// The shared winter break lines up; the spring breaks are a week apart
+	if len(comparison.Mismatches) != 2 {
+		t.Fatalf("mismatches = %+v", comparison.Mismatches)
+	}
+	first := comparison.Mismatches[0]
+	if first.Dates() != "Mar 23 – Mar 27" || first.Off[0] != "Lincoln Elementary" || first.InSession[0] != "Washington High" {
+		t.Errorf("first mismatch = %+v", first)
+	}
+	if second := comparison.Mismatches[1]; second.Dates() != "Mar 30 – Apr 3" || second.Off[0] != "Washington High" {
+		t.Errorf("second mismatch = %+v", second)
+	}
+}
+
+func TestWriteCalendarICS(t *testing.T) {
+	calendar := &SchoolCalendar{NCESSCH: "360000100001", SchoolName: "Lincoln Elementary", SchoolYear: "2025-2026", Events: []CalendarEvent{
+		{Kind: calendarBreak, Name: "Winter break", Start: calendarDate(2025, 12, 22), End: calendarDate(2026, 1, 2)},
+	}}
+	var buf bytes.Buffer
+	if err := WriteCalendarICS(&buf, calendar, "San Francisco, CA", calendarDate(2025, 8, 1)); err != nil {
+		t.Fatal(err)
+	}
+	ics := buf.String()
+	for _, want := range []string{
+		"UID:school-calendar-360000100001-break-20251222@schoolfinder\r\n",
+		"DTSTART;VALUE=DATE:20251222\r\n",
+		"DTEND;VALUE=DATE:20260103\r\n", // All-day end dates are exclusive
+		"SUMMARY:Winter break: Lincoln Elementary\r\n",
+		"LOCATION:San Francisco\\, CA\r\n",
+	} {
+		if !strings.Contains(ics, want) {
+			t.Errorf("ICS is missing %q:\n%s", want, ics)
+		}
+	}
+}
+
+func TestCalendarsPage(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	child := &Child{Name: "Sam", Grade: "03"}
+	if err := SaveChild(db, child); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"360000100001", "360000100002"} {
+		if err := SaveChildSchool(db, child.ID, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	saveTestCalendar(t, db, "360000100001", "Lincoln Elementary",
+		CalendarEvent{Kind: calendarBreak, Name: "Spring break", Start: calendarDate(2026, 3, 23), End: calendarDate(2026, 3, 27)})
+
+	messages := &fakeMessages{text: testSchoolCalendar}
+	ai, err := NewAIScraperService("test-key", db, WithMessageCreator(messages))
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := NewRouter(ServerConfig{DB: db, AIScraper: ai})
+
+	// With no IDs, the children's saved schools are compared
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/calendars", nil))
+	body := rec.Body.String()
+	if rec.Code != http.StatusOK || !strings.Contains(body, "calendar-bar-break") || !strings.Contains(body, `hx-post="/schools/360000100002/calendar"`) {
+		t.Fatalf("calendars page: status %d, body %s", rec.Code, body)
+	}
+
+	req := httptest.NewRequest("POST", "/schools/360000100002/calendar", nil)
+	req.Header.Set("HX-Request", "true")
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent || rec.Header().Get("HX-Refresh") != "true" {
+		t.Fatalf("extraction: status %d, body %s", rec.Code, rec.Body.String())
+	}
+
+	// Washington's spring break is the same week, but it has Labor Day off
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/calendars?ids=360000100001,360000100002", nil))
+	if body := rec.Body.String(); !strings.Contains(body, "<td>Sep 1</td>") || strings.Contains(body, "Mar 23 – Mar 27</td>") {
+		t.Errorf("calendars page after extraction has the wrong mismatches")
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/schools/360000100002/calendar.ics", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "text/calendar; charset=utf-8" || !strings.Contains(rec.Body.String(), "SUMMARY:Labor Day: ") {
+		t.Errorf("ICS download: status %d, body %s", rec.Code, rec.Body.String())
+	}
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/schools/360000100003/calendar.ics", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("ICS download without a calendar: status %d", rec.Code)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// callLogTimeLayout is how call times are entered and exported, in local time
+const callLogTimeLayout = "2006-01-02 15:04"
+
+// maxCallNote is the longest note a logged call keeps
+const maxCallNote = 2000
+
+// SchoolCall is a call to a school's front office in the call log, and what
+// the school said
+type SchoolCall struct {
+	ID        int64
+	NCESSCH   string
+	CalledAt  time.Time
+	Note      string
+	CreatedAt time.Time
+}
+
+// parseCallTime reads when a call was made: "YYYY-MM-DD HH:MM" in local time,
+// the "YYYY-MM-DDTHH:MM" browsers send from datetime inputs, or now when empty
+func parseCallTime(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return now.Truncate(time.Minute), nil
+	}
+	for _, layout := range []string{callLogTimeLayout, "2006-01-02T15:04"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid call time %q: use YYYY-MM-DD HH:MM", s)
+}
+
+// AddSchoolCall validates and logs a call to a school. calledAt is when the
+// call was made (see parseCallTime); note is what the school said.
+func AddSchoolCall(db *DB, ncessch, calledAt, note string, now time.Time) (*SchoolCall, error) {
+	note = strings.TrimSpace(note)
+	if note == "" {
+		return nil, errors.New("add a note about what the school said")
+	}
+	if len(note) > maxCallNote {
+		return nil, fmt.Errorf("keep the note under %d characters", maxCallNote)
+	}
+	at, err := parseCallTime(calledAt, now)
+	if err != nil {
+		return nil, err
+	}
+	school, err := db.GetSchoolByID(ncessch)
+	if err != nil {
+		return nil, err
+	}
+
+	call := &SchoolCall{NCESSCH: school.NCESSCH, CalledAt: at, Note: note}
+	err = db.conn.QueryRow(`
+		INSERT INTO school_calls (ncessch, called_at, note) VALUES ($1, $2, $3)
+		RETURNING id, created_at
+	`, call.NCESSCH, at.Format(callLogTimeLayout), call.Note).Scan(&call.ID, &call.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to log call: %w", err)
+	}
+	return call, nil
+}
+
+// DeleteSchoolCall removes a logged call by ID
+func (d *DB) DeleteSchoolCall(id int64) error {
+	result, err := d.conn.Exec(`DELETE FROM school_calls WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete call: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("no call with ID %d: %w", id, sql.ErrNoRows)
+	}
+	return nil
+}
+
+// SchoolCalls returns the calls logged for one school, or for every school
+// when ncessch is empty, most recent first
+func (d *DB) SchoolCalls(ncessch string) ([]SchoolCall, error) {
+	rows, err := d.conn.Query(`
+		SELECT id, ncessch, strftime(called_at, '%Y-%m-%d %H:%M'), note, created_at
+		FROM school_calls
+		WHERE $1 = '' OR ncessch = $1
+		ORDER BY called_at DESC, id DESC
+	`, ncessch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load calls: %w", err)
+	}
+	defer rows.Close()
+
+	var calls []SchoolCall
+	for rows.Next() {
+		var call SchoolCall
+		var calledAt string
+		if err := rows.Scan(&call.ID, &call.NCESSCH, &calledAt, &call.Note, &call.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan call: %w", err)
+		}
+		// Call times are wall-clock times where they were logged
+		if call.CalledAt, err = time.ParseInLocation(callLogTimeLayout, calledAt, time.Local); err != nil {
+			return nil, fmt.Errorf("failed to read call time: %w", err)
+		}
+		calls = append(calls, call)
+	}
+	return calls, rows.Err()
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSchoolCalls(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	now := time.Date(2026, 10, 14, 10, 17, 42, 0, time.Local)
+
+	for _, tt := range []struct{ id, at, note string }{
+		{"360000100001", "", "  "},
+		{"360000100001", "Oct 12", "Left a voicemail"},
+		{"360000100001", "", strings.Repeat("x", maxCallNote+1)},
+		{"999999999999", "", "Left a voicemail"},
+	} {
+		if _, err := AddSchoolCall(db, tt.id, tt.at, tt.note, now); err == nil {
+			t.Errorf("AddSchoolCall(%s, %q, %.20q) succeeded", tt.id, tt.at, tt.note)
+		}
+	}
+
+	for _, tt := range []struct{ id, at, note string }{
+		{"360000100001", "2026-10-12 14:30", "Left a voicemail"},
+		{"360000100002", "2026-10-13T09:05", "Tours on Tuesdays"},
+		{"360000100001", "", " Spoke with the registrar; call back in November "},
+	} {
+		if _, err := AddSchoolCall(db, tt.id, tt.at, tt.note, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	calls, err := db.SchoolCalls("360000100001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 2 {
+		t.Fatalf("calls = %+v", calls)
+	}
+	if got := calls[0].CalledAt.Format(callLogTimeLayout); got != "2026-10-14 10:17" || calls[0].Note != "Spoke with the registrar; call back in November" {
+		t.Errorf("latest call = %s %q", got, calls[0].Note)
+	}
+	if got := calls[1].CalledAt.Format(callLogTimeLayout); got != "2026-10-12 14:30" {
+		t.Errorf("earlier call at %s", got)
+	}
+
+	all, err := db.SchoolCalls("")
+	if err != nil || len(all) != 3 || all[1].NCESSCH != "360000100002" {
+		t.Errorf("all calls = %+v, %v", all, err)
+	}
+
+	if err := db.DeleteSchoolCall(calls[1].ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.DeleteSchoolCall(calls[1].ID); err == nil {
+		t.Error("deleting a deleted call succeeded")
+	}
+	if calls, _ := db.SchoolCalls("360000100001"); len(calls) != 1 {
+		t.Errorf("calls after delete = %+v", calls)
+	}
+}
+
+func TestWebCallLog(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	router := NewRouter(ServerConfig{DB: db})
+
+	post := func(path string, form url.Values, htmx bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if htmx {
+			req.Header.Set("HX-Request", "true")
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := post("/schools/360000100001/calls", url.Values{"note": {"Tours on Tuesdays at 9"}, "called_at": {"2026-10-12T14:30"}}, true)
+	if body := rec.Body.String(); rec.Code != 200 || !strings.Contains(body, "Mon, Oct 12, 2026 2:30 PM") || !strings.Contains(body, "Tours on Tuesdays at 9") {
+		t.Fatalf("log call = %d\n%s", rec.Code, body)
+	}
+
+	// Invalid calls come back with the form filled in, swapped by HTMX or a 422 otherwise
+	rec = post("/schools/360000100001/calls", url.Values{"note": {"Left a voicemail"}, "called_at": {"yesterday"}}, true)
+	if body := rec.Body.String(); rec.Code != 200 || !strings.Contains(body, "invalid call time") || !strings.Contains(body, ">Left a voicemail</textarea>") {
+		t.Errorf("invalid call = %d\n%s", rec.Code, body)
+	}
+	if rec := post("/schools/360000100001/calls", url.Values{"note": {""}}, false); rec.Code != 422 {
+		t.Errorf("call without a note = %d, want 422", rec.Code)
+	}
+	if rec := post("/schools/999999999999/calls", url.Values{"note": {"Hello"}}, true); rec.Code != 404 {
+		t.Errorf("call to an unknown school = %d, want 404", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/schools/360000100001", nil))
+	if !strings.Contains(rec.Body.String(), "Tours on Tuesdays at 9") {
+		t.Error("detail page doesn't show the call log")
+	}
+
+	// Calls are kept with the school's notes
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/schools/360000100001/note.md", nil))
+	if !strings.Contains(rec.Body.String(), "## Call Log\n\n- 2026-10-12 14:30: Tours on Tuesdays at 9\n") {
+		t.Errorf("note has no call log:\n%s", rec.Body.String())
+	}
+
+	calls, err := db.SchoolCalls("360000100001")
+	if err != nil || len(calls) != 1 {
+		t.Fatalf("calls = %+v, %v", calls, err)
+	}
+	path := "/schools/360000100001/calls/" + strconv.FormatInt(calls[0].ID, 10) + "/delete"
+	if rec := post(path, nil, true); rec.Code != 200 || !strings.Contains(rec.Body.String(), "No calls logged yet.") {
+		t.Errorf("delete call = %d\n%s", rec.Code, rec.Body.String())
+	}
+	if rec := post(path, nil, true); rec.Code != 404 {
+		t.Errorf("delete a deleted call = %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Care filter values: schools must offer before care, after care, or both
+const (
+	careBefore = "before"
+	careAfter  = "after"
+	careBoth   = "both"
+)
+
+var careFilterLabels = map[string]string{
+	careBefore: "before care",
+	careAfter:  "after care",
+	careBoth:   "before and after care",
+}
+
+// CareProgram is a school's before- or after-school care as published on its
+// website. A nil *CareProgram means the website didn't say.
+type CareProgram struct {
+	Available bool   `json:"available"`
+	Hours     string `json:"hours,omitempty"`    // e.g. "7:00-8:15 AM"
+	Cost      string `json:"cost,omitempty"`     // e.g. "$180/month"
+	Provider  string `json:"provider,omitempty"` // e.g. "YMCA", when not the school
+}
+
+// Summary describes the care, e.g. "Until 6:00 PM · $180/month · YMCA", or
+// "Not offered"
+func (c *CareProgram) Summary() string {
+	if c == nil {
+		return "Not published"
+	}
+	if !c.Available {
+		return "Not offered"
+	}
+	var parts []string
+	for _, part := range []string{c.Hours, c.Cost, c.Provider} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	if len(parts) == 0 {
+		return "Offered (hours and cost not published)"
+	}
+	return strings.Join(parts, " · ")
+}
+
+// careLinePattern matches the care lines the extraction prompt asks for, e.g.
+// "- After care: yes; Hours: until 6 PM; Cost: $180/month; Provider: YMCA",
+// allowing for markdown bold and bullets
+var careLinePattern = regexp.MustCompile(`(?im)^[\s>*+-]*\**(before|after)[- ]?(?:school )?care\**\s*:\**\s*(.+)$`)
+
+// careUnknown matches values the website didn't publish
+var careUnknown = regexp.MustCompile(`(?i)^(not published|not listed|unknown|n/?a|none listed|not found)\.?$`)
+
+// parseCareSection reads before and after care from the extracted markdown's
+// "Before & After Care" lines. Either is nil when the website didn't say.
+func parseCareSection(markdown string) (before, after *CareProgram) {
+	for _, m := range careLinePattern.FindAllStringSubmatch(markdown, -1) {
+		care := parseCareLine(m[2])
+		if strings.EqualFold(m[1], careBefore) {
+			if before == nil {
+				before = care
+			}
+		} else if after == nil {
+			after = care
+		}
+	}
+	return before, after
+}
+
+// parseCareLine parses "yes; Hours: ...; Cost: ...; Provider: ..."
+func parseCareLine(line string) *CareProgram {
+	care := &CareProgram{}
+	var answer string
+	for i, part := range strings.Split(strings.Trim(line, " *"), ";") {
+		key, value, found := strings.Cut(part, ":")
+		if !found {
+			if i == 0 {
+				answer = strings.ToLower(strings.Trim(strings.TrimSpace(part), "*."))
+			}
+			continue
+		}
+		value = strings.Trim(strings.TrimSpace(value), "*")
+		if value == "" || careUnknown.MatchString(value) {
+			continue
+		}
+		switch strings.ToLower(strings.Trim(strings.TrimSpace(key), "*")) {
+		case "hours":
+			care.Hours = value
+		case "cost":
+			care.Cost = value
+		case "provider":
+			care.Provider = value
+		}
+	}
+
+	switch {
+	case strings.HasPrefix(answer, "yes"):
+		care.Available = true
+	case strings.HasPrefix(answer, "no") && !strings.HasPrefix(answer, "not"):
+		return care
+	case care.Hours != "" || care.Cost != "":
+		// Published details without a plain yes still mean it's offered
+		care.Available = true
+	default:
+		return nil
+	}
+	return care
+}
+
+// careSQL returns the condition that a school's website data shows the care
+// a care filter asks for
+func careSQL(filter string) string {
+	has := func(kind string) string {
+		return "json_extract_string(legacy_data, '$." + kind + "_care.available') = 'true'"
+	}
+	condition := has(filter)
+	if filter == careBoth {
+		condition = has(careBefore) + " AND " + has(careAfter)
+	}
+	return "d.NCESSCH IN (SELECT ncessch FROM ai_scraper_cache WHERE " + condition + ")"
+}
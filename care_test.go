@@ -0,0 +1,96 @@
+package main
+
+import (
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCareSection(t *testing.T) {
+	markdown := `## Before & After Care
+- **Before care:** yes; Hours: 7:00-8:15 AM; Cost: not published; Provider: not published
+- **After care:** Yes; Hours: until 6:00 PM; Cost: $180/month; Provider: YMCA
+
+## Mission`
+	before, after := parseCareSection(markdown)
+	if got := before.Summary(); got != "7:00-8:15 AM" {
+		t.Errorf("before care = %q", got)
+	}
+	if got := after.Summary(); got != "until 6:00 PM · $180/month · YMCA" {
+		t.Errorf("after care = %q", got)
+	}
+
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"- Before care: no; Hours: not published; Cost: not published; Provider: not published", "Not offered"},
+		{"- Before care: not published; Hours: not published; Cost: not published; Provider: not published", "Not published"},
+		{"- Before-school care: yes", "Offered (hours and cost not published)"},
+		{"* Before care: Hours: 6:30 AM; Cost: $5/day", "6:30 AM · $5/day"},
+	}
+	for _, tt := range tests {
+		before, _ := parseCareSection(tt.line)
+		if got := before.Summary(); got != tt.want {
+			t.Errorf("parseCareSection(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+
+	if before, after := parseCareSection("Our after care program is popular."); before != nil || after != nil {
+		t.Errorf("prose parsed as care lines: %+v, %+v", before, after)
+	}
+}
+
+func TestCareFilter(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	for id, care := range map[string]string{
+		"360000100001": "- Before care: yes\n- After care: yes; Hours: until 6 PM",
+		"360000100003": "- Before care: no\n- After care: yes; Cost: $200/month",
+		"360000100005": "- Before care: not published\n- After care: no",
+	} {
+		if err := saveEnhancedData(db, &EnhancedSchoolData{NCESSCH: id, MarkdownContent: care, ExtractedAt: time.Now()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	search := func(care string) string {
+		t.Helper()
+		schools, err := db.SearchSchoolsFiltered(SearchFilters{Care: care}, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, s := range schools {
+			ids = append(ids, s.NCESSCH)
+		}
+		slices.Sort(ids)
+		return strings.Join(ids, ",")
+	}
+	if got := search(careAfter); got != "360000100001,360000100003" {
+		t.Errorf("after care = %s", got)
+	}
+	if got := search(careBoth); got != "360000100001" {
+		t.Errorf("before and after care = %s", got)
+	}
+
+	// Care round-trips through the cache as structured fields
+	data, err := (&AIScraperService{db: db}).loadCachedData("360000100003", cacheNoExpiry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data.BeforeCare == nil || data.BeforeCare.Available || data.AfterCare.Cost != "$200/month" {
+		t.Errorf("cached care = %+v, %+v", data.BeforeCare, data.AfterCare)
+	}
+
+	filters, err := SearchFiltersFromValues(url.Values{"care": {"after"}})
+	if err != nil || filters.Summary() != "with after care" || filters.DrawerFilterCount() != 1 {
+		t.Errorf("filters = %+v, %v", filters, err)
+	}
+	if _, err := SearchFiltersFromValues(url.Values{"care": {"evenings"}}); err == nil {
+		t.Error("invalid care filter accepted")
+	}
+}
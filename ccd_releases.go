@@ -0,0 +1,377 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// NCES publishes each CCD release as a zip on its file listing. The release
+// checker reads the listing for files newer than the ones loaded, and can fetch
+// a newer directory or membership file so its school year loads alongside the
+// current one, for --as-of queries, without changing what the app shows.
+
+// ccdFileListingURL is the NCES page listing CCD data files
+const ccdFileListingURL = "https://nces.ed.gov/ccd/files.asp"
+
+// ccdReleaseCheckInterval is how often the TUI checks for new releases at startup
+const ccdReleaseCheckInterval = 7 * 24 * time.Hour
+
+// ccdReleaseLinkPattern matches a CCD school file's zip in the listing, e.g.
+// "Data/zip/ccd_sch_029_2425_w_1a_071725.zip"
+var ccdReleaseLinkPattern = regexp.MustCompile(`[^"'\s<>]*ccd_sch_\d{3}_\d{4}_[A-Za-z0-9_]+_\d{6}\.zip`)
+
+// CCDRelease is a CCD file NCES has published
+type CCDRelease struct {
+	DataVersion        // Filename is the CSV inside the zip
+	URL         string `json:"url"`
+}
+
+// Loadable reports whether the release can be fetched into a parallel school
+// year: directory and membership files have history tables, staff files don't
+func (r CCDRelease) Loadable() bool {
+	return r.Dataset == datasetDirectory || r.Dataset == datasetMembership
+}
+
+// CCDReleaseCheck is the result of checking the listing
+type CCDReleaseCheck struct {
+	CheckedAt time.Time    `json:"checked_at"`
+	New       []CCDRelease `json:"new"` // Releases newer than the loaded ones, newest school year first
+}
+
+// Summary describes the new releases in a line, e.g. "CCD 2024-2025 directory
+// and membership are available"
+func (c *CCDReleaseCheck) Summary() string {
+	if len(c.New) == 0 {
+		return "CCD data is up to date"
+	}
+	byYear := make(map[string][]string)
+	var years []string
+	for _, r := range c.New {
+		if byYear[r.SchoolYear] == nil {
+			years = append(years, r.SchoolYear)
+		}
+		byYear[r.SchoolYear] = append(byYear[r.SchoolYear], r.Dataset)
+	}
+	var parts []string
+	for _, year := range years {
+		parts = append(parts, fmt.Sprintf("CCD %s %s", year, joinWithAnd(byYear[year])))
+	}
+	verb := "is"
+	if len(c.New) > 1 {
+		verb = "are"
+	}
+	return strings.Join(parts, "; ") + " " + verb + " available"
+}
+
+// joinWithAnd joins words as a list, e.g. "directory, membership, and staff"
+func joinWithAnd(words []string) string {
+	switch len(words) {
+	case 0:
+		return ""
+	case 1:
+		return words[0]
+	case 2:
+		return words[0] + " and " + words[1]
+	}
+	return strings.Join(words[:len(words)-1], ", ") + ", and " + words[len(words)-1]
+}
+
+// CCDReleaseChecker checks the NCES listing for new CCD releases
+type CCDReleaseChecker struct {
+	httpClient *http.Client
+	listingURL string
+}
+
+// CCDReleaseOption configures a CCDReleaseChecker
+type CCDReleaseOption func(*CCDReleaseChecker)
+
+// WithCCDListingURL checks a different listing, such as a mirror or a test server
+func WithCCDListingURL(listingURL string) CCDReleaseOption {
+	return func(c *CCDReleaseChecker) { c.listingURL = listingURL }
+}
+
+// WithCCDHTTPClient sets the HTTP client used for the listing and downloads
+func WithCCDHTTPClient(client *http.Client) CCDReleaseOption {
+	return func(c *CCDReleaseChecker) { c.httpClient = client }
+}
+
+// NewCCDReleaseChecker creates a release checker for the NCES listing
+func NewCCDReleaseChecker(opts ...CCDReleaseOption) *CCDReleaseChecker {
+	c := &CCDReleaseChecker{
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		listingURL: ccdFileListingURL,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Releases lists the CCD school files on the listing, with the newest release
+// of each dataset and school year only
+func (c *CCDReleaseChecker) Releases(ctx context.Context) ([]CCDRelease, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.listingURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the CCD file listing: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to load the CCD file listing: %w", newHTTPStatusError(resp))
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 10<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the CCD file listing: %w", err)
+	}
+	base, err := url.Parse(c.listingURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid listing URL: %w", err)
+	}
+
+	newest := make(map[string]CCDRelease)
+	for _, link := range ccdReleaseLinkPattern.FindAllString(string(body), -1) {
+		ref, err := url.Parse(link)
+		if err != nil {
+			continue
+		}
+		name := strings.TrimSuffix(filepath.Base(ref.Path), ".zip") + ".csv"
+		v, err := ccdFileVersion(name)
+		if err != nil {
+			continue
+		}
+		key := v.Dataset + " " + v.SchoolYear
+		if have, ok := newest[key]; ok && !v.Released.After(have.Released) {
+			continue
+		}
+		newest[key] = CCDRelease{DataVersion: v, URL: base.ResolveReference(ref).String()}
+	}
+
+	releases := make([]CCDRelease, 0, len(newest))
+	for _, r := range newest {
+		releases = append(releases, r)
+	}
+	sortCCDReleases(releases)
+	return releases, nil
+}
+
+// sortCCDReleases orders releases newest school year first, then by dataset
+func sortCCDReleases(releases []CCDRelease) {
+	sort.Slice(releases, func(i, j int) bool {
+		if releases[i].SchoolYear != releases[j].SchoolYear {
+			return releases[i].SchoolYear > releases[j].SchoolYear
+		}
+		return releases[i].Dataset < releases[j].Dataset
+	})
+}
+
+// newCCDReleases picks the releases newer than the loaded files: a later school
+// year than any loaded for the dataset, or a revised release of a loaded year
+func newCCDReleases(releases []CCDRelease, loaded []DataVersion) []CCDRelease {
+	latestYear := make(map[string]string)
+	loadedRelease := make(map[string]time.Time)
+	for _, v := range loaded {
+		if v.SchoolYear > latestYear[v.Dataset] {
+			latestYear[v.Dataset] = v.SchoolYear
+		}
+		key := v.Dataset + " " + v.SchoolYear
+		if v.Released.After(loadedRelease[key]) {
+			loadedRelease[key] = v.Released
+		}
+	}
+
+	var fresh []CCDRelease
+	for _, r := range releases {
+		released, ok := loadedRelease[r.Dataset+" "+r.SchoolYear]
+		switch {
+		case ok && r.Released.After(released):
+			fresh = append(fresh, r)
+		case !ok && r.SchoolYear > latestYear[r.Dataset]:
+			fresh = append(fresh, r)
+		}
+	}
+	return fresh
+}
+
+// Check compares the listing with the loaded files and saves the result, so
+// the TUI and doctor can show it without checking again
+func (c *CCDReleaseChecker) Check(ctx context.Context, db *DB) (*CCDReleaseCheck, error) {
+	releases, err := c.Releases(ctx)
+	if err != nil {
+		return nil, err
+	}
+	loaded, err := db.DataVersions("")
+	if err != nil {
+		return nil, err
+	}
+
+	check := &CCDReleaseCheck{CheckedAt: time.Now().UTC(), New: newCCDReleases(releases, loaded)}
+	if err := db.SaveCCDReleaseCheck(check); err != nil {
+		return nil, err
+	}
+	return check, nil
+}
+
+// CheckIfDue returns the last saved check, checking the listing first if the
+// last check is older than ccdReleaseCheckInterval. When the listing can't be
+// reached, the last saved check, if any, is returned with the error.
+func (c *CCDReleaseChecker) CheckIfDue(ctx context.Context, db *DB) (*CCDReleaseCheck, error) {
+	last, err := db.LastCCDReleaseCheck()
+	if err != nil {
+		return nil, err
+	}
+	if last != nil && time.Since(last.CheckedAt) < ccdReleaseCheckInterval {
+		return last, nil
+	}
+	check, err := c.Check(ctx, db)
+	if err != nil {
+		return last, err
+	}
+	return check, nil
+}
+
+// Fetch downloads releases into the data directory and loads each directory
+// and membership file into its school year's history, alongside the current
+// year. The current directory, enrollment, and teachers tables don't change.
+// It returns the versions loaded.
+func (c *CCDReleaseChecker) Fetch(ctx context.Context, db *DB, releases []CCDRelease) ([]DataVersion, error) {
+	tempDir := filepath.Join(db.dataDir, ".temp")
+	if err := os.MkdirAll(tempDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(tempDir) }()
+
+	for _, r := range releases {
+		if !r.Loadable() {
+			return nil, fmt.Errorf("%s can't be loaded alongside the current year; only directory and membership files can", r.Filename)
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		zipPath := filepath.Join(tempDir, strings.TrimSuffix(r.Filename, ".csv")+".zip")
+		if err := c.download(ctx, r.URL, zipPath); err != nil {
+			return nil, fmt.Errorf("failed to download %s: %w", r.URL, err)
+		}
+		if err := UnzipFile(zipPath, db.dataDir); err != nil {
+			return nil, fmt.Errorf("failed to extract %s: %w", filepath.Base(zipPath), err)
+		}
+		if _, err := os.Stat(filepath.Join(db.dataDir, r.Filename)); err != nil {
+			return nil, fmt.Errorf("%s didn't contain %s", filepath.Base(zipPath), r.Filename)
+		}
+	}
+
+	if _, err := SyncDirectoryHistory(db); err != nil {
+		return nil, err
+	}
+	if _, err := SyncEnrollmentHistory(db); err != nil {
+		return nil, err
+	}
+	if _, err := SyncDataVersions(db); err != nil {
+		return nil, err
+	}
+
+	var loaded []DataVersion
+	for _, r := range releases {
+		versions, err := db.DataVersions(r.SchoolYear)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range versions {
+			if v.Filename == r.Filename {
+				loaded = append(loaded, v)
+			}
+		}
+	}
+
+	// The fetched releases are no longer new
+	if last, err := db.LastCCDReleaseCheck(); err == nil && last != nil {
+		last.New = newCCDReleases(last.New, loaded)
+		if err := db.SaveCCDReleaseCheck(last); err != nil {
+			return loaded, err
+		}
+	}
+	return loaded, nil
+}
+
+// download saves a release's zip, retrying transient failures
+func (c *CCDReleaseChecker) download(ctx context.Context, fileURL, path string) error {
+	return downloadRetry.Do(ctx, func() error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, fileURL, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = resp.Body.Close() }()
+		if resp.StatusCode != http.StatusOK {
+			return newHTTPStatusError(resp)
+		}
+
+		out, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(out, resp.Body); err != nil {
+			_ = out.Close()
+			return err
+		}
+		return out.Close()
+	})
+}
+
+// SaveCCDReleaseCheck replaces the saved release check
+func (d *DB) SaveCCDReleaseCheck(check *CCDReleaseCheck) error {
+	data, err := json.Marshal(check.New)
+	if err != nil {
+		return fmt.Errorf("failed to marshal release check: %w", err)
+	}
+	tx, err := d.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.Exec(`DELETE FROM ccd_release_checks`); err != nil {
+		return fmt.Errorf("failed to clear release check: %w", err)
+	}
+	if _, err := tx.Exec(`INSERT INTO ccd_release_checks (checked_at, new_releases) VALUES ($1, $2)`, check.CheckedAt, string(data)); err != nil {
+		return fmt.Errorf("failed to save release check: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to save release check: %w", err)
+	}
+	return nil
+}
+
+// LastCCDReleaseCheck loads the saved release check, or nil if there hasn't been one
+func (d *DB) LastCCDReleaseCheck() (*CCDReleaseCheck, error) {
+	var check CCDReleaseCheck
+	var data string
+	err := d.conn.QueryRow(`SELECT checked_at, new_releases FROM ccd_release_checks ORDER BY checked_at DESC LIMIT 1`).Scan(&check.CheckedAt, &data)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load release check: %w", err)
+	}
+	if err := json.Unmarshal([]byte(data), &check.New); err != nil {
+		return nil, fmt.Errorf("failed to read release check: %w", err)
+	}
+	check.CheckedAt = check.CheckedAt.UTC()
+	return &check, nil
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// nextYearDirectory is a 2024-25 directory release, with a school that closed
+const nextYearDirectory = `NCESSCH,SCH_NAME,ST,STATENAME,MCITY,LEA_NAME,LEAID,SCHOOL_YEAR,LEVEL,PHONE,WEBSITE,MZIP,MSTREET1,MSTREET2,MSTREET3,SCH_TYPE_TEXT,GSLO,GSHI,CHARTER_TEXT
+360000100001,Lincoln Elementary School,CA,California,San Francisco,San Francisco Unified School District,0600000,2024-2025,Elementary,,,94102,123 Lincoln St,,,Regular school,PK,05,Not applicable
+360000100002,Washington Senior High School,CA,California,Los Angeles,Los Angeles Unified School District,0600001,2024-2025,High,,,90001,456 Washington Ave,,,Regular school,09,12,Not applicable
+`
+
+// nextYearMembership is a 2024-25 membership release
+const nextYearMembership = `NCESSCH,TOTAL_INDICATOR,STUDENT_COUNT
+360000100001,Education Unit Total,50
+360000100002,Education Unit Total,3000
+`
+
+// ccdListing serves a CCD file listing and the zips it links to
+func ccdListing(t *testing.T, files map[string]string, links ...string) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ccd/files.asp", func(w http.ResponseWriter, r *http.Request) {
+		var b strings.Builder
+		b.WriteString("<html><body><table>")
+		for _, link := range links {
+			b.WriteString(`<tr><td><a href="` + link + `">Download</a></td></tr>`)
+		}
+		b.WriteString("</table></body></html>")
+		_, _ = w.Write([]byte(b.String()))
+	})
+	for name, contents := range files {
+		var buf bytes.Buffer
+		zw := zip.NewWriter(&buf)
+		f, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write([]byte(contents)); err != nil {
+			t.Fatal(err)
+		}
+		if err := zw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		data := buf.Bytes()
+		mux.HandleFunc("/ccd/Data/zip/"+strings.TrimSuffix(name, ".csv")+".zip", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write(data)
+		})
+	}
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestCCDReleases(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	// Load past years, so the current year has trends and badges
+	if err := os.WriteFile(filepath.Join(db.dataDir, "ccd_sch_029_2223_w_1a_083023.csv"), []byte(priorYearDirectory), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for name, contents := range priorYearEnrollment {
+		if err := os.WriteFile(filepath.Join(db.dataDir, name), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := SyncDirectoryHistory(db); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SyncEnrollmentHistory(db); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SyncDataVersions(db); err != nil {
+		t.Fatal(err)
+	}
+
+	server := ccdListing(t, map[string]string{
+		"ccd_sch_029_2425_w_1a_071725.csv": nextYearDirectory,
+		"ccd_sch_052_2425_l_1a_071725.csv": nextYearMembership,
+	},
+		"Data/zip/ccd_sch_029_2324_w_1a_073124.zip", // Loaded
+		"Data/zip/ccd_sch_029_2324_w_1a_050124.zip", // Older than the loaded release
+		"Data/zip/ccd_sch_029_2425_w_1a_071725.zip",
+		"/ccd/Data/zip/ccd_sch_052_2425_l_1a_071725.zip",
+		"https://nces.ed.gov/ccd/Data/zip/ccd_sch_059_2425_l_1a_071725.zip",
+		"Data/zip/ccd_sch_052_2021_l_1a_071721.zip", // Older than any loaded year
+	)
+	checker := NewCCDReleaseChecker(WithCCDListingURL(server.URL + "/ccd/files.asp"))
+
+	if last, err := db.LastCCDReleaseCheck(); err != nil || last != nil {
+		t.Fatalf("LastCCDReleaseCheck() before checking = %+v, %v", last, err)
+	}
+	check, err := checker.Check(context.Background(), db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(check.New) != 3 || check.New[0].Filename != "ccd_sch_029_2425_w_1a_071725.csv" || check.New[2].Dataset != datasetStaff {
+		t.Fatalf("Check().New = %+v", check.New)
+	}
+	if check.New[1].URL != server.URL+"/ccd/Data/zip/ccd_sch_052_2425_l_1a_071725.zip" {
+		t.Errorf("relative link resolved to %s", check.New[1].URL)
+	}
+	if check.New[2].Loadable() {
+		t.Error("staff releases shouldn't be loadable")
+	}
+	if got, want := check.Summary(), "CCD 2024-2025 directory, membership, and staff are available"; got != want {
+		t.Errorf("Summary() = %q, want %q", got, want)
+	}
+
+	// The saved check is used until it's due again
+	due, err := NewCCDReleaseChecker(WithCCDListingURL("http://127.0.0.1:1/unreachable")).CheckIfDue(context.Background(), db)
+	if err != nil || len(due.New) != 3 {
+		t.Fatalf("CheckIfDue() = %+v, %v", due, err)
+	}
+	if report := releaseCheck(context.Background(), db, nil); report.Status != doctorWarn || !strings.Contains(report.Detail, "2024-2025") {
+		t.Errorf("doctor release check = %+v", report)
+	}
+
+	trends, err := db.EnrollmentTrends([]string{"360000100001"})
+	if err != nil || len(trends) != 1 {
+		t.Fatalf("EnrollmentTrends() = %+v, %v", trends, err)
+	}
+	changes, err := db.SchoolYearChanges()
+	if err != nil || len(changes) == 0 {
+		t.Fatalf("SchoolYearChanges() = %+v, %v", changes, err)
+	}
+
+	fetched, err := checker.Fetch(context.Background(), db, check.New[:2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fetched) != 2 || fetched[0].SchoolYear != "2024-2025" || fetched[0].Rows != 2 {
+		t.Fatalf("Fetch() = %+v", fetched)
+	}
+	if _, err := os.Stat(filepath.Join(db.dataDir, ".temp")); !os.IsNotExist(err) {
+		t.Errorf("Fetch() left its downloads: %v", err)
+	}
+	if _, err := checker.Fetch(context.Background(), db, check.New[2:]); err == nil {
+		t.Error("Expected fetching a staff release to fail")
+	}
+
+	// The new year is queryable, and the current one is unchanged
+	pinned, err := db.AsOfQuery(`SELECT count(*) AS n FROM directory`, "2024-25")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := db.ExecuteQuery(pinned)
+	if n, _ := numericValue(rows[0]["n"]); err != nil || n != 2 {
+		t.Errorf("2024-25 directory = %v, %v", rows, err)
+	}
+	if current, _ := db.CurrentDataVersions(); len(current) != 3 || current[0].SchoolYear != "2023-2024" {
+		t.Errorf("CurrentDataVersions() after fetching = %+v", current)
+	}
+	var schools int
+	if err := db.conn.QueryRow(`SELECT count(*) FROM directory`).Scan(&schools); err != nil || schools != 5 {
+		t.Errorf("directory has %d schools after fetching, %v", schools, err)
+	}
+	after, err := db.EnrollmentTrends([]string{"360000100001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after["360000100001"].AnnualChange != trends["360000100001"].AnnualChange || len(after["360000100001"].Years) != len(trends["360000100001"].Years) {
+		t.Errorf("EnrollmentTrends() changed from %+v to %+v", trends, after)
+	}
+	if afterChanges, _ := db.SchoolYearChanges(); len(afterChanges) != len(changes) || afterChanges["360000100003"] != changes["360000100003"] {
+		t.Errorf("SchoolYearChanges() changed from %+v to %+v", changes, afterChanges)
+	}
+
+	// Fetched releases drop out of the saved check
+	if last, err := db.LastCCDReleaseCheck(); err != nil || len(last.New) != 1 || last.New[0].Dataset != datasetStaff {
+		t.Errorf("LastCCDReleaseCheck() after fetching = %+v, %v", last, err)
+	}
+	if again, err := checker.Check(context.Background(), db); err != nil || len(again.New) != 1 {
+		t.Errorf("Check() after fetching = %+v, %v", again, err)
+	}
+}
+
+func TestNewCCDReleases(t *testing.T) {
+	loaded := []DataVersion{
+		mustCCDFileVersion(t, "ccd_sch_029_2324_w_1a_073124.csv"),
+		mustCCDFileVersion(t, "ccd_sch_052_2223_l_1a_083023.csv"),
+	}
+	releases := []CCDRelease{
+		{DataVersion: mustCCDFileVersion(t, "ccd_sch_029_2324_w_1a_010125.csv")}, // Revised
+		{DataVersion: mustCCDFileVersion(t, "ccd_sch_052_2223_l_1a_083023.csv")}, // Loaded
+		{DataVersion: mustCCDFileVersion(t, "ccd_sch_052_2324_l_1a_073124.csv")}, // Newer year
+		{DataVersion: mustCCDFileVersion(t, "ccd_sch_052_2122_l_1a_071722.csv")}, // Older year
+		{DataVersion: mustCCDFileVersion(t, "ccd_sch_059_2324_l_1a_073124.csv")}, // No staff loaded
+	}
+	fresh := newCCDReleases(releases, loaded)
+	var names []string
+	for _, r := range fresh {
+		names = append(names, r.Filename)
+	}
+	want := "ccd_sch_029_2324_w_1a_010125.csv ccd_sch_052_2324_l_1a_073124.csv ccd_sch_059_2324_l_1a_073124.csv"
+	if got := strings.Join(names, " "); got != want {
+		t.Errorf("newCCDReleases() = %s, want %s", got, want)
+	}
+}
+
+func mustCCDFileVersion(t *testing.T, filename string) DataVersion {
+	t.Helper()
+	v, err := ccdFileVersion(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
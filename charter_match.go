@@ -0,0 +1,378 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Areas a charter school's matches are drawn from
+const (
+	matchAreaDistrict = "district" // The charter's district (LEA)
+	matchAreaCity     = "city"     // The charter's city, for charters that are their own district
+	matchAreaState    = "state"    // The charter's state
+)
+
+// matchAreas are the areas in the order they widen
+var matchAreas = []string{matchAreaDistrict, matchAreaCity, matchAreaState}
+
+// Match limits
+const (
+	maxCharterMatches   = 5     // Most matched schools compared side by side
+	maxMatchCandidates  = 20000 // Most traditional schools considered, enough for any state
+	traditionalSchool   = "Regular school"
+	raceSubtotalsFilter = "Derived - Subtotal by Race/Ethnicity and Sex minus Adult Education Count"
+)
+
+// raceCategories are the CCD membership race/ethnicity categories, in the
+// order they're shown
+var raceCategories = []string{
+	"American Indian or Alaska Native",
+	"Asian",
+	"Black or African American",
+	"Hispanic/Latino",
+	"Native Hawaiian or Other Pacific Islander",
+	"Two or more races",
+	"White",
+}
+
+// MatchCriteria are how closely a traditional public school has to resemble a
+// charter school to be compared with it
+type MatchCriteria struct {
+	Area                 string  // matchAreaDistrict, matchAreaCity, or matchAreaState
+	AnyLevel             bool    // Match schools at any level, not just the charter's
+	SizeTolerance        float64 // Largest enrollment difference as a share of the charter's, e.g. 0.5 for ±50%; 0 for any size
+	DemographicTolerance float64 // Largest race/ethnicity difference in percentage points; 0 to ignore demographics
+	Limit                int     // Most matches compared
+}
+
+// DefaultMatchCriteria are the criteria used unless they're adjusted: the
+// same district and level, enrollment within half again either way, and a
+// student body no more than 20 points different by race/ethnicity
+var DefaultMatchCriteria = MatchCriteria{
+	Area:                 matchAreaDistrict,
+	SizeTolerance:        0.5,
+	DemographicTolerance: 20,
+	Limit:                3,
+}
+
+// Validate checks the criteria are in range
+func (c MatchCriteria) Validate() error {
+	if !slices.Contains(matchAreas, c.Area) {
+		return fmt.Errorf("unknown area %q (use %s)", c.Area, strings.Join(matchAreas, ", "))
+	}
+	if c.SizeTolerance < 0 {
+		return fmt.Errorf("size tolerance can't be negative")
+	}
+	if c.DemographicTolerance < 0 || c.DemographicTolerance > 100 {
+		return fmt.Errorf("demographic tolerance must be between 0 and 100 points")
+	}
+	if c.Limit < 1 || c.Limit > maxCharterMatches {
+		return fmt.Errorf("limit must be between 1 and %d", maxCharterMatches)
+	}
+	return nil
+}
+
+// MatchStep is one criterion applied to the candidates, with how many were
+// left after it, so readers can see which criterion narrowed the field
+type MatchStep struct {
+	Criterion string // e.g. "Enrollment within ±50% of 725"
+	Remaining int
+}
+
+// SchoolMatch is a traditional public school matched to a charter
+type SchoolMatch struct {
+	School                *School
+	SizeDifference        float64 // Enrollment difference as a share of the charter's, e.g. -0.12
+	DemographicDifference float64 // Race/ethnicity difference in percentage points; NaN without counts
+}
+
+// SizeLabel describes the enrollment difference, e.g. "12% smaller"
+func (m SchoolMatch) SizeLabel() string {
+	pct := math.Round(math.Abs(m.SizeDifference) * 100)
+	switch {
+	case pct == 0:
+		return "Same size"
+	case m.SizeDifference < 0:
+		return fmt.Sprintf("%.0f%% smaller", pct)
+	}
+	return fmt.Sprintf("%.0f%% larger", pct)
+}
+
+// DemographicLabel describes the race/ethnicity difference, e.g. "8 points"
+func (m SchoolMatch) DemographicLabel() string {
+	if math.IsNaN(m.DemographicDifference) {
+		return ""
+	}
+	return fmt.Sprintf("%.0f points", m.DemographicDifference)
+}
+
+// CharterMatch is a charter school with the traditional public schools most
+// like it, and how they were chosen
+type CharterMatch struct {
+	Charter     *School
+	Criteria    MatchCriteria
+	Steps       []MatchStep
+	Notes       []string // Criteria that couldn't be applied, and why
+	Matches     []SchoolMatch
+	Composition map[string]map[string]float64 // Race/ethnicity shares by NCESSCH, then category
+}
+
+// Schools lists the charter followed by its matches
+func (m *CharterMatch) Schools() []*School {
+	schools := []*School{m.Charter}
+	for _, match := range m.Matches {
+		schools = append(schools, match.School)
+	}
+	return schools
+}
+
+// MatchCharterSchool finds the traditional public schools most like a charter
+// school. Candidates are the regular, non-charter schools in the charter's
+// area, narrowed to its level, then to enrollment and race/ethnicity
+// composition within the tolerances, and ranked by how far they are from the
+// charter on both. Race/ethnicity is only matched when the enrollment file
+// has the breakdown.
+func (d *DB) MatchCharterSchool(ncessch string, criteria MatchCriteria) (*CharterMatch, error) {
+	if err := criteria.Validate(); err != nil {
+		return nil, err
+	}
+	charter, err := d.GetSchoolByID(ncessch)
+	if err != nil {
+		return nil, err
+	}
+	if charter.CharterString() != "Yes" {
+		return nil, ErrNotCharter
+	}
+	result := &CharterMatch{Charter: charter, Criteria: criteria}
+
+	// Traditional public schools in the area
+	var condition, arg, area string
+	switch criteria.Area {
+	case matchAreaDistrict:
+		condition, arg, area = "d.LEAID = $1", charter.DistrictID.String, charter.District
+	case matchAreaCity:
+		condition, arg, area = "d.ST || '|' || upper(d.MCITY) = $1", charter.State+"|"+strings.ToUpper(charter.City), charter.City+", "+charter.State
+	case matchAreaState:
+		condition, arg, area = "d.ST = $1", charter.State, charter.StateName
+	}
+	condition += fmt.Sprintf(" AND COALESCE(d.CHARTER_TEXT, '') <> 'Yes' AND d.SCH_TYPE_TEXT = '%s'", traditionalSchool)
+	candidates, err := d.listSchools(condition, arg, maxMatchCandidates)
+	if err != nil {
+		return nil, err
+	}
+	result.Steps = append(result.Steps, MatchStep{Criterion: "Traditional public schools in " + area, Remaining: len(candidates)})
+	if len(candidates) == 0 && criteria.Area != matchAreaState {
+		result.Notes = append(result.Notes, "Charter schools are often their own district; widen the area to the city or state to find matches.")
+	}
+
+	if !criteria.AnyLevel {
+		level := charter.LevelString()
+		candidates = slices.DeleteFunc(candidates, func(s School) bool { return s.LevelString() != level })
+		result.Steps = append(result.Steps, MatchStep{Criterion: "Same level (" + level + ")", Remaining: len(candidates)})
+	}
+
+	if criteria.SizeTolerance > 0 {
+		if charter.Enrollment.Valid && charter.Enrollment.Int64 > 0 {
+			candidates = slices.DeleteFunc(candidates, func(s School) bool {
+				return !s.Enrollment.Valid || math.Abs(sizeDifference(charter, &s)) > criteria.SizeTolerance
+			})
+			result.Steps = append(result.Steps, MatchStep{
+				Criterion: fmt.Sprintf("Enrollment within ±%.0f%% of %d", criteria.SizeTolerance*100, charter.Enrollment.Int64),
+				Remaining: len(candidates),
+			})
+		} else {
+			result.Notes = append(result.Notes, "The charter has no enrollment on file, so schools weren't matched on size.")
+		}
+	}
+
+	ids := []string{charter.NCESSCH}
+	for _, s := range candidates {
+		ids = append(ids, s.NCESSCH)
+	}
+	composition, err := d.RaceComposition(ids)
+	if err != nil {
+		return nil, err
+	}
+	result.Composition = composition
+
+	matches := make([]SchoolMatch, len(candidates))
+	for i := range candidates {
+		matches[i] = SchoolMatch{
+			School:                &candidates[i],
+			SizeDifference:        sizeDifference(charter, &candidates[i]),
+			DemographicDifference: compositionDifference(composition[charter.NCESSCH], composition[candidates[i].NCESSCH]),
+		}
+	}
+	if criteria.DemographicTolerance > 0 {
+		if _, ok := composition[charter.NCESSCH]; ok {
+			matches = slices.DeleteFunc(matches, func(m SchoolMatch) bool {
+				return math.IsNaN(m.DemographicDifference) || m.DemographicDifference > criteria.DemographicTolerance
+			})
+			result.Steps = append(result.Steps, MatchStep{
+				Criterion: fmt.Sprintf("Race/ethnicity within %.0f points", criteria.DemographicTolerance),
+				Remaining: len(matches),
+			})
+		} else {
+			result.Notes = append(result.Notes, "No race/ethnicity counts are loaded for the charter, so schools weren't matched on demographics. They come from the full CCD membership file.")
+		}
+	}
+
+	// Closest first: each point of race/ethnicity difference counts as much
+	// as a percent of enrollment
+	distance := func(m SchoolMatch) float64 {
+		dist := math.Abs(m.SizeDifference)
+		if !math.IsNaN(m.DemographicDifference) {
+			dist += m.DemographicDifference / 100
+		}
+		return dist
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return distance(matches[i]) < distance(matches[j]) })
+	if len(matches) > criteria.Limit {
+		matches = matches[:criteria.Limit]
+	}
+	result.Matches = matches
+	return result, nil
+}
+
+// sizeDifference is s's enrollment difference from the charter's as a share of
+// the charter's, or 0 when either is missing
+func sizeDifference(charter, s *School) float64 {
+	if !charter.Enrollment.Valid || charter.Enrollment.Int64 == 0 || !s.Enrollment.Valid {
+		return 0
+	}
+	return float64(s.Enrollment.Int64-charter.Enrollment.Int64) / float64(charter.Enrollment.Int64)
+}
+
+// compositionDifference is the dissimilarity of two race/ethnicity
+// compositions in percentage points: the share of either school's students
+// who would have to be in another category for the two to match. It is NaN
+// when either composition is missing.
+func compositionDifference(a, b map[string]float64) float64 {
+	if a == nil || b == nil {
+		return math.NaN()
+	}
+	var total float64
+	for _, category := range raceCategories {
+		total += math.Abs(a[category] - b[category])
+	}
+	return total / 2 * 100
+}
+
+// RaceComposition loads each school's share of students by race/ethnicity,
+// keyed by NCESSCH and then category. Schools without counts are left out,
+// as are all schools when the enrollment file has no race breakdown.
+func (d *DB) RaceComposition(ncesschList []string) (map[string]map[string]float64, error) {
+	composition := make(map[string]map[string]float64)
+	columns, err := d.tableColumns("enrollment", "", false)
+	if err != nil {
+		return nil, err
+	}
+	if len(ncesschList) == 0 || !slices.Contains(columns, "RACE_ETHNICITY") {
+		return composition, nil
+	}
+
+	rows, err := d.conn.Query(`
+		SELECT NCESSCH, RACE_ETHNICITY, sum(TRY_CAST(STUDENT_COUNT AS BIGINT))
+		FROM enrollment
+		WHERE NCESSCH = ANY($1) AND TOTAL_INDICATOR = $2 AND RACE_ETHNICITY = ANY($3)
+		GROUP BY NCESSCH, RACE_ETHNICITY
+	`, ncesschList, raceSubtotalsFilter, raceCategories)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load race/ethnicity counts: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[string]map[string]float64)
+	totals := make(map[string]float64)
+	for rows.Next() {
+		var id, category string
+		var count *int64
+		if err := rows.Scan(&id, &category, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan race/ethnicity counts: %w", err)
+		}
+		if count == nil {
+			continue
+		}
+		if counts[id] == nil {
+			counts[id] = make(map[string]float64)
+		}
+		counts[id][category] += float64(*count)
+		totals[id] += float64(*count)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for id, byCategory := range counts {
+		if totals[id] == 0 {
+			continue
+		}
+		composition[id] = make(map[string]float64, len(byCategory))
+		for category, count := range byCategory {
+			composition[id][category] = count / totals[id]
+		}
+	}
+	return composition, nil
+}
+
+// CharterMatchComparison lines the charter and its matches up field by field
+// from the directory and cached data, with how each match differs from the
+// charter and each school's race/ethnicity shares ahead of the fields compare
+// shows
+func (d *DB) CharterMatchComparison(m *CharterMatch) (*SchoolComparison, error) {
+	cacheReader := &AIScraperService{db: d}
+	naepClient := NewNAEPClient(d)
+	schools := m.Schools()
+	inputs := make([]CompareSchoolInput, len(schools))
+	ids := make([]string, len(schools))
+	for i, school := range schools {
+		// Expired entries are still worth comparing
+		enhanced, _ := cacheReader.loadCachedData(school.NCESSCH, cacheNoExpiry)
+		naepData, _ := naepClient.loadCachedData(school.NCESSCH, cacheNoExpiry)
+		inputs[i] = CompareSchoolInput{School: school, Enhanced: enhanced, NAEP: naepData}
+		ids[i] = school.NCESSCH
+	}
+	percentiles, err := d.SchoolPercentiles(ids)
+	if err != nil {
+		return nil, err
+	}
+	comparison := CompareSchools(inputs, percentiles)
+
+	var rows []ComparisonRow
+	add := func(field string, values []string) {
+		row := ComparisonRow{Field: field, Values: values}
+		found := false
+		for _, v := range values {
+			found = found || v != ""
+			row.Differs = row.Differs || v != values[0]
+		}
+		if found {
+			rows = append(rows, row)
+		}
+	}
+	size := []string{"Charter"}
+	demographics := []string{"Charter"}
+	for _, match := range m.Matches {
+		size = append(size, match.SizeLabel())
+		demographics = append(demographics, match.DemographicLabel())
+	}
+	add("Enrollment vs. charter", size)
+	if len(m.Composition) > 0 {
+		add("Race/ethnicity difference", demographics)
+	}
+	for _, category := range raceCategories {
+		values := make([]string, len(inputs))
+		for i, in := range inputs {
+			if shares, ok := m.Composition[in.School.NCESSCH]; ok {
+				values[i] = fmt.Sprintf("%.0f%%", shares[category]*100)
+			}
+		}
+		add(category, values)
+	}
+
+	comparison.Rows = append(rows, comparison.Rows...)
+	return comparison, nil
+}
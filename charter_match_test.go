@@ -0,0 +1,156 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// addMatchCandidates adds New York schools to match Roosevelt Charter School
+// (a 725-student high school) against, with race/ethnicity counts for the
+// charter and the district's high schools
+func addMatchCandidates(t *testing.T, db *DB) {
+	t.Helper()
+	schools := []struct {
+		id, name, leaid, level, charter string
+		students                        int
+	}{
+		{"360000100011", "Hudson High School", "3600000", "High", "No", 700},
+		{"360000100012", "Bronx Middle School", "3600000", "Middle", "No", 700},
+		{"360000100013", "Queens High School", "3600000", "High", "No", 2000},
+		{"360000100014", "Brooklyn High School", "3600000", "High", "No", 650},
+		{"360000100015", "Harlem Charter High School", "3600000", "High", "Yes", 720},
+		{"360000100016", "Albany High School", "3600100", "High", "No", 725},
+	}
+	for _, s := range schools {
+		_, err := db.conn.Exec(`
+			INSERT INTO directory BY NAME
+			SELECT $1 AS NCESSCH, $2 AS SCH_NAME, 'NY' AS ST, 'New York' AS STATENAME, 'New York City' AS MCITY,
+				'NY District' AS LEA_NAME, $3 AS LEAID, '2023-2024' AS SCHOOL_YEAR, $4 AS LEVEL,
+				'Regular school' AS SCH_TYPE_TEXT, '09' AS GSLO, '12' AS GSHI, $5 AS CHARTER_TEXT
+		`, s.id, s.name, s.leaid, s.level, s.charter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.conn.Exec(`INSERT INTO enrollment BY NAME SELECT $1 AS NCESSCH, 'Education Unit Total' AS TOTAL_INDICATOR, $2 AS STUDENT_COUNT`, s.id, s.students); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := db.conn.Exec(`ALTER TABLE enrollment ADD COLUMN RACE_ETHNICITY VARCHAR`); err != nil {
+		t.Fatal(err)
+	}
+	counts := []struct {
+		id, race string
+		students int
+	}{
+		{"360000100004", "Black or African American", 360},
+		{"360000100004", "Hispanic/Latino", 365},
+		{"360000100011", "Black or African American", 280},
+		{"360000100011", "Hispanic/Latino", 420},
+		{"360000100014", "White", 650},
+		{"360000100013", "Asian", 2000},
+	}
+	for _, c := range counts {
+		_, err := db.conn.Exec(`
+			INSERT INTO enrollment BY NAME
+			SELECT $1 AS NCESSCH, $2 AS TOTAL_INDICATOR, $3 AS RACE_ETHNICITY, $4 AS STUDENT_COUNT
+		`, c.id, raceSubtotalsFilter, c.race, c.students)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMatchCharterSchool(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	addMatchCandidates(t, db)
+
+	match, err := db.MatchCharterSchool("360000100004", DefaultMatchCriteria)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 4 traditional schools in the district, 3 high schools, 2 of a similar
+	// size, and 1 with a similar student body
+	var remaining []int
+	for _, step := range match.Steps {
+		remaining = append(remaining, step.Remaining)
+	}
+	if len(remaining) != 4 || remaining[0] != 4 || remaining[1] != 3 || remaining[2] != 2 || remaining[3] != 1 {
+		t.Errorf("unexpected matching steps %+v", match.Steps)
+	}
+	if len(match.Matches) != 1 || match.Matches[0].School.Name != "Hudson High School" {
+		t.Fatalf("expected Hudson High, got %+v", match.Matches)
+	}
+	if m := match.Matches[0]; math.Abs(m.DemographicDifference-9.7) > 0.1 || m.SizeLabel() != "3% smaller" {
+		t.Errorf("unexpected differences: %.1f points, %s", m.DemographicDifference, m.SizeLabel())
+	}
+
+	// Without demographics, schools are ranked by size; the state adds Albany
+	criteria := DefaultMatchCriteria
+	criteria.Area = matchAreaState
+	criteria.DemographicTolerance = 0
+	match, err = db.MatchCharterSchool("360000100004", criteria)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, m := range match.Matches {
+		names = append(names, m.School.Name)
+	}
+	if strings.Join(names, ", ") != "Albany High School, Hudson High School, Brooklyn High School" {
+		t.Errorf("unexpected statewide matches %v", names)
+	}
+
+	comparison, err := db.CharterMatchComparison(match)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(comparison.Schools) != 4 || comparison.Rows[0].Field != "Enrollment vs. charter" || comparison.Rows[0].Values[1] != "Same size" {
+		t.Errorf("unexpected comparison %+v", comparison.Rows[0])
+	}
+
+	if _, err := db.MatchCharterSchool("360000100001", DefaultMatchCriteria); !errors.Is(err, ErrNotCharter) {
+		t.Errorf("expected ErrNotCharter, got %v", err)
+	}
+	criteria.Area = "county"
+	if _, err := db.MatchCharterSchool("360000100004", criteria); err == nil {
+		t.Error("expected an error for an unknown area")
+	}
+}
+
+func TestCharterMatchPage(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	addMatchCandidates(t, db)
+	router := NewRouter(ServerConfig{DB: db})
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/schools/360000100004/matched", nil))
+	body := rec.Body.String()
+	for _, want := range []string{"Hudson High School", "Race/ethnicity within 20 points", "<th>Race/ethnicity difference</th>", "10 points"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("matched comparison page is missing %q", want)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/schools/360000100004/matched?size=15&demographics=0", nil))
+	if body := rec.Body.String(); !strings.Contains(body, "Brooklyn High School") || strings.Contains(body, "Queens High School") {
+		t.Error("adjusted criteria weren't applied")
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/schools/360000100001/matched", nil))
+	if rec.Code != 422 {
+		t.Errorf("expected 422 for a traditional school, got %d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/schools/360000100004/matched?size=abc", nil))
+	if rec.Code != 400 {
+		t.Errorf("expected 400 for an invalid tolerance, got %d", rec.Code)
+	}
+}
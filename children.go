@@ -0,0 +1,301 @@
+package main
+
+import (
+	"cmp"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Child is a child the family is choosing a school for. Searches can be limited
+// to schools serving each child's grade, and each child keeps a list of saved schools.
+type Child struct {
+	ID        int64
+	Name      string
+	Grade     string   // CCD grade code the child is entering, e.g. "KG" or "06"
+	Needs     []string // e.g. "IEP" or "English learner"
+	CreatedAt time.Time
+	Schools   []string // NCESSCH IDs saved to this child's list, oldest first
+}
+
+// GradeLabel formats the child's grade for display, e.g. "K" or "6"
+func (c Child) GradeLabel() string {
+	return gradeLabel(c.Grade)
+}
+
+// Serves reports whether a school's grade span includes the child's grade
+func (c Child) Serves(s School) bool {
+	grade, ok := gradeRank(c.Grade)
+	if !ok {
+		return false
+	}
+	low, lowOK := gradeRank(s.GradeLow.String)
+	high, highOK := gradeRank(s.GradeHigh.String)
+	return lowOK && highOK && low <= grade && grade <= high
+}
+
+// Saved reports whether a school is on the child's list
+func (c Child) Saved(ncessch string) bool {
+	return slices.Contains(c.Schools, ncessch)
+}
+
+// normalizeGrade returns the CCD code for a grade written as "K", "kg", "6",
+// or "06"
+func normalizeGrade(grade string) (string, bool) {
+	rank, ok := gradeRank(grade)
+	switch {
+	case !ok:
+		return "", false
+	case rank == -1:
+		return "PK", true
+	case rank == 0:
+		return "KG", true
+	default:
+		return fmt.Sprintf("%02d", rank), true
+	}
+}
+
+// SaveChild validates and saves a child profile, replacing the one with the
+// same name
+func SaveChild(db *DB, child *Child) error {
+	child.Name = strings.TrimSpace(child.Name)
+	if child.Name == "" {
+		return fmt.Errorf("child name cannot be empty")
+	}
+	grade, ok := normalizeGrade(child.Grade)
+	if !ok {
+		return fmt.Errorf("invalid grade %q (use PK, K, or 1-12)", child.Grade)
+	}
+	child.Grade = grade
+
+	var needs []string
+	for _, need := range child.Needs {
+		if need = strings.TrimSpace(need); need != "" && !slices.Contains(needs, need) {
+			needs = append(needs, need)
+		}
+	}
+	child.Needs = needs
+	needsJSON, err := json.Marshal(needs)
+	if err != nil {
+		return fmt.Errorf("failed to encode needs: %w", err)
+	}
+
+	err = db.conn.QueryRow(`
+		INSERT INTO children (name, grade, needs) VALUES ($1, $2, $3)
+		ON CONFLICT (name) DO UPDATE SET grade = EXCLUDED.grade, needs = EXCLUDED.needs
+		RETURNING id, created_at
+	`, child.Name, child.Grade, string(needsJSON)).Scan(&child.ID, &child.CreatedAt)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to save child", "error", err)
+		}
+		return fmt.Errorf("failed to save child: %w", err)
+	}
+	return nil
+}
+
+// Children loads every child profile with its saved schools, youngest grade first
+func (d *DB) Children() ([]Child, error) {
+	rows, err := d.conn.Query(`SELECT id, name, grade, needs::VARCHAR, created_at FROM children`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load children: %w", err)
+	}
+	defer rows.Close()
+
+	var children []Child
+	for rows.Next() {
+		var c Child
+		var needs sql.NullString
+		if err := rows.Scan(&c.ID, &c.Name, &c.Grade, &needs, &c.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan child: %w", err)
+		}
+		if needs.Valid {
+			if err := json.Unmarshal([]byte(needs.String), &c.Needs); err != nil {
+				return nil, fmt.Errorf("failed to decode needs: %w", err)
+			}
+		}
+		children = append(children, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	saved, err := d.conn.Query(`SELECT child_id, ncessch FROM child_schools ORDER BY added_at, ncessch`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load saved schools: %w", err)
+	}
+	defer saved.Close()
+	for saved.Next() {
+		var childID int64
+		var ncessch string
+		if err := saved.Scan(&childID, &ncessch); err != nil {
+			return nil, fmt.Errorf("failed to scan saved school: %w", err)
+		}
+		for i := range children {
+			if children[i].ID == childID {
+				children[i].Schools = append(children[i].Schools, ncessch)
+			}
+		}
+	}
+	if err := saved.Err(); err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(children, func(a, b Child) int {
+		rankA, _ := gradeRank(a.Grade)
+		rankB, _ := gradeRank(b.Grade)
+		return cmp.Or(cmp.Compare(rankA, rankB), cmp.Compare(a.Name, b.Name))
+	})
+	return children, nil
+}
+
+// GetChild loads a child profile by ID
+func (d *DB) GetChild(id int64) (*Child, error) {
+	children, err := d.Children()
+	if err != nil {
+		return nil, err
+	}
+	for i := range children {
+		if children[i].ID == id {
+			return &children[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no child with ID %d: %w", id, sql.ErrNoRows)
+}
+
+// DeleteChild removes a child profile and its saved schools
+func (d *DB) DeleteChild(id int64) error {
+	result, err := d.conn.Exec(`DELETE FROM children WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete child: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("no child with ID %d", id)
+	}
+	if _, err := d.conn.Exec(`DELETE FROM child_schools WHERE child_id = $1`, id); err != nil {
+		return fmt.Errorf("failed to delete saved schools: %w", err)
+	}
+	return nil
+}
+
+// SaveChildSchool adds a school to a child's list. Saving a school twice keeps
+// its original place in the list.
+func SaveChildSchool(db *DB, childID int64, ncessch string) error {
+	if _, err := db.GetChild(childID); err != nil {
+		return err
+	}
+	school, err := db.GetSchoolByID(ncessch)
+	if err != nil {
+		return err
+	}
+	_, err = db.conn.Exec(`
+		INSERT INTO child_schools (child_id, ncessch) VALUES ($1, $2)
+		ON CONFLICT DO NOTHING
+	`, childID, school.NCESSCH)
+	if err != nil {
+		return fmt.Errorf("failed to save school: %w", err)
+	}
+	return nil
+}
+
+// RemoveChildSchool takes a school off a child's list
+func (d *DB) RemoveChildSchool(childID int64, ncessch string) error {
+	result, err := d.conn.Exec(`DELETE FROM child_schools WHERE child_id = $1 AND ncessch = $2`, childID, ncessch)
+	if err != nil {
+		return fmt.Errorf("failed to remove saved school: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("school %s isn't saved for child %d", ncessch, childID)
+	}
+	return nil
+}
+
+// childGradeFilter joins the children's grades for SearchFilters.ChildGrades, e.g. "KG,06"
+func childGradeFilter(children []Child) string {
+	var grades []string
+	for _, c := range children {
+		if !slices.Contains(grades, c.Grade) {
+			grades = append(grades, c.Grade)
+		}
+	}
+	return strings.Join(grades, ",")
+}
+
+// childrenSummary lists children with their grades, e.g. "Maya (K), Leo (6)"
+func childrenSummary(children []Child) string {
+	parts := make([]string, len(children))
+	for i, c := range children {
+		parts[i] = c.Name + " (" + c.GradeLabel() + ")"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// ChildFit is which children a school serves by grade
+type ChildFit struct {
+	Served []Child
+	All    bool // Serves every child, and there's more than one
+}
+
+// Badge describes the fit for a search result, e.g. "Fits all 2 children" or
+// "Fits Maya"
+func (f ChildFit) Badge() string {
+	if f.All {
+		return "Fits all " + strconv.Itoa(len(f.Served)) + " children"
+	}
+	names := make([]string, len(f.Served))
+	for i, c := range f.Served {
+		names[i] = c.Name
+	}
+	return "Fits " + strings.Join(names, " & ")
+}
+
+// childFit works out which children a school serves
+func childFit(s School, children []Child) ChildFit {
+	var fit ChildFit
+	for _, c := range children {
+		if c.Serves(s) {
+			fit.Served = append(fit.Served, c)
+		}
+	}
+	fit.All = len(children) > 1 && len(fit.Served) == len(children)
+	return fit
+}
+
+// ChildFits returns the fit of each school that serves at least one child, by NCESSCH
+func ChildFits(schools []School, children []Child) map[string]ChildFit {
+	fits := make(map[string]ChildFit)
+	if len(children) == 0 {
+		return fits
+	}
+	for _, s := range schools {
+		if fit := childFit(s, children); len(fit.Served) > 0 {
+			fits[s.NCESSCH] = fit
+		}
+	}
+	return fits
+}
+
+// RankForChildren moves schools that serve more of the children ahead of the
+// rest, keeping search order otherwise
+func RankForChildren(schools []School, fits map[string]ChildFit) {
+	slices.SortStableFunc(schools, func(a, b School) int {
+		return cmp.Compare(len(fits[b.NCESSCH].Served), len(fits[a.NCESSCH].Served))
+	})
+}
+
+// loadChildren loads child profiles for decorating results, where a failure
+// only drops the decoration
+func loadChildren(db *DB) []Child {
+	children, err := db.Children()
+	if err != nil {
+		if logger != nil {
+			logger.Warn("Failed to load child profiles", "error", err)
+		}
+		return nil
+	}
+	return children
+}
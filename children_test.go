@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestSaveChild(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	for _, c := range []Child{{Name: " ", Grade: "KG"}, {Name: "Maya", Grade: "14"}, {Name: "Maya", Grade: "first"}} {
+		if err := SaveChild(db, &c); err == nil {
+			t.Errorf("SaveChild(%+v) succeeded", c)
+		}
+	}
+
+	leo := &Child{Name: "Leo", Grade: "6"}
+	maya := &Child{Name: "Maya", Grade: "k", Needs: []string{"IEP", " IEP", ""}}
+	for _, c := range []*Child{leo, maya} {
+		if err := SaveChild(db, c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if leo.Grade != "06" || maya.Grade != "KG" || strings.Join(maya.Needs, ",") != "IEP" {
+		t.Errorf("saved children = %+v, %+v", leo, maya)
+	}
+
+	if err := SaveChildSchool(db, maya.ID, "360000100001"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveChildSchool(db, maya.ID, "360000100001"); err != nil {
+		t.Errorf("saving a school twice: %v", err)
+	}
+	if err := SaveChildSchool(db, maya.ID, "999999999999"); err == nil {
+		t.Error("saved a missing school")
+	}
+	if err := SaveChildSchool(db, 12345, "360000100001"); err == nil {
+		t.Error("saved a school for a missing child")
+	}
+
+	children, err := db.Children()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(children) != 2 || children[0].Name != "Maya" || children[1].Name != "Leo" {
+		t.Fatalf("children = %+v, want Maya then Leo", children)
+	}
+	if !children[0].Saved("360000100001") || len(children[0].Schools) != 1 || len(children[1].Schools) != 0 {
+		t.Errorf("saved schools = %v, %v", children[0].Schools, children[1].Schools)
+	}
+
+	if err := db.DeleteChild(maya.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.RemoveChildSchool(maya.ID, "360000100001"); err == nil {
+		t.Error("deleting a child kept their saved schools")
+	}
+}
+
+func TestSearchForChildren(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	children := []Child{{Name: "Maya", Grade: "KG"}, {Name: "Leo", Grade: "06"}}
+	filters := SearchFilters{ChildGrades: childGradeFilter(children)}
+	if filters.ChildGrades != "KG,06" || filters.Summary() != "for grade K or 6" {
+		t.Errorf("filters = %q, summary %q", filters.ChildGrades, filters.Summary())
+	}
+
+	schools, err := db.SearchSchoolsFiltered(filters, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fits := ChildFits(schools, children)
+	RankForChildren(schools, fits)
+
+	var got []string
+	for _, s := range schools {
+		got = append(got, s.Name+": "+fits[s.NCESSCH].Badge())
+	}
+	if len(got) != 3 || got[0] != "Madison K-8 School: Fits all 2 children" {
+		t.Errorf("ranked results = %v", got)
+	}
+	for _, want := range []string{"Lincoln Elementary School: Fits Maya", "Jefferson Middle School: Fits Leo"} {
+		if !strings.Contains(strings.Join(got, "\n"), want) {
+			t.Errorf("results are missing %q: %v", want, got)
+		}
+	}
+
+	if _, err := SearchFiltersFromValues(url.Values{"child_grades": {"KG,14"}}); err == nil {
+		t.Error("invalid child grade accepted")
+	}
+}
+
+func TestWebChildren(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	router := NewRouter(ServerConfig{DB: db})
+
+	post := func(path string, form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := post("/children", url.Values{"name": {"Maya"}, "grade": {"KG"}, "needs": {"IEP, speech"}}); rec.Code != 200 || !strings.Contains(rec.Body.String(), "Needs: IEP, speech") {
+		t.Fatalf("add child = %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := post("/children", url.Values{"name": {"Leo"}, "grade": {"14"}}); rec.Code != 400 {
+		t.Errorf("invalid grade = %d, want 400", rec.Code)
+	}
+	if rec := post("/children", url.Values{"name": {"Leo"}, "grade": {"06"}}); rec.Code != 200 {
+		t.Fatalf("add child = %d", rec.Code)
+	}
+
+	// New searches default to the children's grades and flag schools fitting both
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if body := rec.Body.String(); !strings.Contains(body, `name="child_grades" value="KG,06" checked`) || !strings.Contains(body, "For my children: Maya (K), Leo (6)") {
+		t.Error("search page doesn't default to the children's grades")
+	}
+	rec = post("/search", url.Values{"query": {"School"}, "child_grades": {"KG,06"}})
+	if body := rec.Body.String(); rec.Code != 200 || !strings.Contains(body, "Fits all 2 children") || strings.Contains(body, "Washington High School") {
+		t.Errorf("search for children = %d\n%s", rec.Code, body)
+	}
+
+	children, _ := db.Children()
+	maya := children[0]
+	rec = post(fmt.Sprintf("/children/%d/schools/360000100001", maya.ID), url.Values{"saved": {"1"}})
+	if rec.Code != 200 || !strings.Contains(rec.Body.String(), "★ Saved for") || !strings.Contains(rec.Body.String(), `aria-pressed="true"`) {
+		t.Errorf("save for child = %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/children", nil))
+	if rec.Code != 200 || !strings.Contains(rec.Body.String(), `<a href="/schools/360000100001">Lincoln Elementary School</a>`) {
+		t.Errorf("children page doesn't list Maya's saved school")
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/schools/360000100002", nil))
+	if body := rec.Body.String(); !strings.Contains(body, "☆ Save for") || !strings.Contains(body, `title="Doesn't serve grade K"`) {
+		t.Error("detail page doesn't offer saving for each child")
+	}
+}
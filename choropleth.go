@@ -0,0 +1,387 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+)
+
+// Choropleth metrics
+const (
+	choroplethRatio        = "ratio"         // Pooled students per teacher
+	choroplethCharterShare = "charter_share" // Percentage of schools that are charters
+	choroplethProficiency  = "proficiency"   // NAEP percentage at or above Proficient
+)
+
+// Choropleth levels
+const (
+	choroplethStates   = "state"
+	choroplethCounties = "county"
+)
+
+// choroplethMetric is a metric the heatmap can show
+type choroplethMetric struct {
+	Key, Label string
+}
+
+// choroplethMetrics lists the metrics in the order the stats page offers them
+var choroplethMetrics = []choroplethMetric{
+	{choroplethRatio, "Students per teacher"},
+	{choroplethCharterShare, "Charter share"},
+	{choroplethProficiency, "NAEP proficiency"},
+}
+
+// naepGradesTested are the grades NAEP reports state results for
+var naepGradesTested = []int{4, 8, 12}
+
+// ChoroplethQuery picks the metric and the areas a heatmap shows
+type ChoroplethQuery struct {
+	Metric  string `json:"metric"`
+	Level   string `json:"level"`   // state or county
+	State   string `json:"state"`   // The state whose counties are shown
+	Subject string `json:"subject"` // NAEP subject, for proficiency
+	Grade   int    `json:"grade"`   // NAEP grade, for proficiency
+}
+
+// DefaultChoroplethQuery maps the student/teacher ratio by state
+var DefaultChoroplethQuery = ChoroplethQuery{
+	Metric:  choroplethRatio,
+	Level:   choroplethStates,
+	Subject: "mathematics",
+	Grade:   8,
+}
+
+// Validate checks the metric, level, and NAEP subject and grade
+func (q ChoroplethQuery) Validate() error {
+	if choroplethLabel(q.Metric) == "" {
+		keys := make([]string, len(choroplethMetrics))
+		for i, m := range choroplethMetrics {
+			keys[i] = m.Key
+		}
+		return fmt.Errorf("unknown metric %q (use %s)", q.Metric, strings.Join(keys, ", "))
+	}
+	switch q.Level {
+	case choroplethStates:
+	case choroplethCounties:
+		if len(q.State) != 2 {
+			return fmt.Errorf("a two-letter state is required to map counties")
+		}
+		if q.Metric == choroplethProficiency {
+			return fmt.Errorf("NAEP proficiency is only reported by state")
+		}
+	default:
+		return fmt.Errorf("unknown level %q (use %s or %s)", q.Level, choroplethStates, choroplethCounties)
+	}
+	if q.Metric == choroplethProficiency {
+		if _, ok := naepSubjects[q.Subject]; !ok {
+			return fmt.Errorf("unknown NAEP subject %q", q.Subject)
+		}
+		if !slices.Contains(naepGradesTested, q.Grade) {
+			return fmt.Errorf("NAEP grade must be 4, 8, or 12")
+		}
+	}
+	return nil
+}
+
+// choroplethLabel names a metric, or returns "" for an unknown one
+func choroplethLabel(metric string) string {
+	for _, m := range choroplethMetrics {
+		if m.Key == metric {
+			return m.Label
+		}
+	}
+	return ""
+}
+
+// ChoroplethArea is one state or county on a heatmap
+type ChoroplethArea struct {
+	Key     string   `json:"key"` // State code or county FIPS code
+	Name    string   `json:"name"`
+	Schools int      `json:"schools"`
+	Value   *float64 `json:"value"`          // nil when the area has no data for the metric
+	Year    int      `json:"year,omitempty"` // The NAEP year, for proficiency
+	Lat     *float64 `json:"lat,omitempty"`  // Average of the county's geocoded schools
+	Lon     *float64 `json:"lon,omitempty"`
+}
+
+// Choropleth is a metric aggregated by state, or by county within a state
+type Choropleth struct {
+	Query ChoroplethQuery  `json:"query"`
+	Label string           `json:"label"`
+	Min   *float64         `json:"min"`
+	Max   *float64         `json:"max"`
+	Areas []ChoroplethArea `json:"areas"` // By key
+	Note  string           `json:"note,omitempty"`
+}
+
+// Choropleth aggregates a metric for each state, or each county of a state.
+// Ratios pool the students and teachers of schools reporting both, like the
+// statistics overview; proficiency is the latest state NAEP result cached for
+// the subject and grade. Merged duplicates aren't counted.
+func (d *DB) Choropleth(q ChoroplethQuery) (*Choropleth, error) {
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+	q.State = strings.ToUpper(q.State)
+
+	var rows *sql.Rows
+	var err error
+	if q.Metric == choroplethProficiency {
+		rows, err = d.conn.Query(`
+			WITH scores AS (
+				SELECT upper(state) AS state, unnest(from_json(state_scores,
+					'[{"subject":"VARCHAR","grade":"INTEGER","year":"INTEGER","at_proficient":"DOUBLE","error_code":"INTEGER","proficiency_error_code":"INTEGER"}]')) AS s
+				FROM naep_cache
+				WHERE state_scores IS NOT NULL
+			), latest AS (
+				SELECT state, arg_max(s.at_proficient, s.year) AS value, max(s.year) AS year
+				FROM scores
+				WHERE s.subject = $1 AND s.grade = $2 AND s.at_proficient > 0
+					AND COALESCE(s.error_code, 0) = 0 AND COALESCE(s.proficiency_error_code, 0) = 0
+				GROUP BY state
+			), states AS (
+				SELECT d.ST AS key, any_value(COALESCE(d.STATENAME, d.ST)) AS name, count(*) AS schools
+				FROM directory d
+				WHERE d.ST IS NOT NULL AND `+notMergedCondition+`
+				GROUP BY d.ST
+			)
+			SELECT s.key, s.name, s.schools, l.value, COALESCE(l.year, 0), NULL::DOUBLE, NULL::DOUBLE
+			FROM states s
+			LEFT JOIN latest l ON l.state = s.key
+			ORDER BY s.key
+		`, q.Subject, q.Grade)
+	} else {
+		measure := `sum(students) FILTER (WHERE teachers > 0) / NULLIF(sum(teachers) FILTER (WHERE students > 0), 0)`
+		if q.Metric == choroplethCharterShare {
+			measure = `100.0 * count(*) FILTER (WHERE charter) / count(*)`
+		}
+		area := `d.ST AS key, COALESCE(d.STATENAME, d.ST) AS name, NULL::DOUBLE AS lat, NULL::DOUBLE AS lon`
+		join := ``
+		where := `d.ST IS NOT NULL`
+		args := []interface{}{}
+		if q.Level == choroplethCounties {
+			area = `c.county AS key, COALESCE(c.county_name, c.county) AS name, co.lat, co.lon`
+			join = `JOIN school_counties c ON c.ncessch = d.NCESSCH LEFT JOIN school_coordinates co ON co.ncessch = d.NCESSCH`
+			where = `d.ST = $1`
+			args = append(args, q.State)
+		}
+		rows, err = d.conn.Query(`
+			WITH schools AS (
+				SELECT `+area+`,
+					COALESCE(d.CHARTER_TEXT = 'Yes', false) AS charter,
+					NULLIF(TRY_CAST(e.STUDENT_COUNT AS DOUBLE), 0) AS students,
+					NULLIF(TRY_CAST(t.TEACHERS AS DOUBLE), 0) AS teachers
+				FROM directory d
+				LEFT JOIN teachers t ON d.NCESSCH = t.NCESSCH
+				LEFT JOIN enrollment e ON d.NCESSCH = e.NCESSCH AND e.TOTAL_INDICATOR = 'Education Unit Total'
+				`+join+`
+				WHERE `+where+` AND `+notMergedCondition+`
+			)
+			SELECT key, any_value(name), count(*), `+measure+`, 0, avg(lat), avg(lon)
+			FROM schools
+			GROUP BY key
+			ORDER BY key
+		`, args...)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute %s by %s: %w", q.Metric, q.Level, err)
+	}
+	defer rows.Close()
+
+	c := &Choropleth{Query: q, Label: choroplethLabel(q.Metric), Areas: []ChoroplethArea{}}
+	for rows.Next() {
+		var a ChoroplethArea
+		var value, lat, lon sql.NullFloat64
+		if err := rows.Scan(&a.Key, &a.Name, &a.Schools, &value, &a.Year, &lat, &lon); err != nil {
+			return nil, fmt.Errorf("failed to scan %s: %w", q.Level, err)
+		}
+		if value.Valid {
+			v := value.Float64
+			a.Value = &v
+		}
+		if lat.Valid && lon.Valid {
+			a.Lat, a.Lon = &lat.Float64, &lon.Float64
+		}
+		c.Areas = append(c.Areas, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for _, a := range c.Areas {
+		if a.Value == nil {
+			continue
+		}
+		if c.Min == nil || *a.Value < *c.Min {
+			c.Min = a.Value
+		}
+		if c.Max == nil || *a.Value > *c.Max {
+			c.Max = a.Value
+		}
+	}
+
+	if q.Metric == choroplethProficiency {
+		c.Note = fmt.Sprintf("Grade %d %s, latest year cached. A state appears once NAEP results have been fetched for one of its schools.", q.Grade, q.Subject)
+	} else if q.Level == choroplethCounties {
+		c.Note = "Counties come from the NCES EDGE geocode files loaded; schools without one aren't counted."
+	}
+	return c, nil
+}
+
+// FormatValue formats a value of the map's metric, e.g. "15.4:1" or "12.5%"
+func (c *Choropleth) FormatValue(v *float64) string {
+	if v == nil {
+		return "No data"
+	}
+	switch c.Query.Metric {
+	case choroplethRatio:
+		return fmt.Sprintf("%.1f:1", *v)
+	case choroplethProficiency:
+		return fmt.Sprintf("%.0f%%", *v)
+	default:
+		return fmt.Sprintf("%.1f%%", *v)
+	}
+}
+
+// Heatmap colors: a light to dark blue scale, and gray for areas with no data
+var (
+	choroplethLow    = [3]float64{219, 234, 254}
+	choroplethHigh   = [3]float64{30, 58, 138}
+	choroplethNoData = "#e5e7eb"
+)
+
+// choroplethColor interpolates the heatmap scale, t from 0 to 1
+func choroplethColor(t float64) string {
+	t = math.Max(0, math.Min(1, t))
+	var rgb [3]int
+	for i := range rgb {
+		rgb[i] = int(math.Round(choroplethLow[i] + (choroplethHigh[i]-choroplethLow[i])*t))
+	}
+	return fmt.Sprintf("#%02x%02x%02x", rgb[0], rgb[1], rgb[2])
+}
+
+// stateTiles places each state on a grid that roughly follows the map, so
+// small states get as much room as large ones: {column, row}
+var stateTiles = map[string][2]int{
+	"AK": {0, 0}, "ME": {11, 0},
+	"VT": {10, 1}, "NH": {11, 1},
+	"WA": {1, 2}, "ID": {2, 2}, "MT": {3, 2}, "ND": {4, 2}, "MN": {5, 2}, "IL": {6, 2}, "WI": {7, 2}, "MI": {8, 2}, "NY": {9, 2}, "RI": {10, 2}, "MA": {11, 2},
+	"OR": {1, 3}, "NV": {2, 3}, "WY": {3, 3}, "SD": {4, 3}, "IA": {5, 3}, "IN": {6, 3}, "OH": {7, 3}, "PA": {8, 3}, "NJ": {9, 3}, "CT": {10, 3},
+	"CA": {1, 4}, "UT": {2, 4}, "CO": {3, 4}, "NE": {4, 4}, "MO": {5, 4}, "KY": {6, 4}, "WV": {7, 4}, "VA": {8, 4}, "MD": {9, 4}, "DE": {10, 4},
+	"AZ": {2, 5}, "NM": {3, 5}, "KS": {4, 5}, "AR": {5, 5}, "TN": {6, 5}, "NC": {7, 5}, "SC": {8, 5}, "DC": {9, 5},
+	"OK": {4, 6}, "LA": {5, 6}, "MS": {6, 6}, "AL": {7, 6}, "GA": {8, 6},
+	"HI": {0, 7}, "TX": {4, 7}, "FL": {9, 7}, "PR": {10, 7},
+}
+
+// Heatmap layout, in SVG units
+const (
+	choroplethTile    = 44
+	choroplethGap     = 4
+	choroplethColumns = 12
+	choroplethWidth   = choroplethColumns * (choroplethTile + choroplethGap)
+	choroplethHeight  = 8 * (choroplethTile + choroplethGap)
+	countyRadius      = 9
+)
+
+// ChoroplethShape is a state tile or county dot on the heatmap
+type ChoroplethShape struct {
+	Key, Label string // Label is drawn on state tiles
+	Title      string // Name and value, for hover and screen readers
+	Fill, Ink  string // Ink is the label color, readable on Fill
+	Circle     bool
+	X, Y       float64 // Tile corner, or dot center
+	LabelX     float64 // Tile center
+	LabelY     float64
+}
+
+// ChoroplethMap is the SVG view of a heatmap
+type ChoroplethMap struct {
+	Width, Height int
+	Tile, Radius  int
+	Shapes        []ChoroplethShape
+	Unplaced      int // Areas left off the map, but listed in the table
+	Low, High     string
+	NoData        string
+	MinLabel      string
+	MaxLabel      string
+}
+
+// Map lays the areas out as SVG shapes: states on a tile grid, and counties
+// as dots at the average position of their schools. States without a tile
+// and counties without geocoded schools are only in the table.
+func (c *Choropleth) Map() ChoroplethMap {
+	m := ChoroplethMap{
+		Width: choroplethWidth, Height: choroplethHeight,
+		Tile: choroplethTile, Radius: countyRadius,
+		Low: choroplethColor(0), High: choroplethColor(1), NoData: choroplethNoData,
+		MinLabel: c.FormatValue(c.Min), MaxLabel: c.FormatValue(c.Max),
+	}
+	var located []ChoroplethArea
+	for _, a := range c.Areas {
+		if c.Query.Level == choroplethCounties && a.Lat != nil {
+			located = append(located, a)
+		}
+	}
+	project := countyProjection(located, float64(m.Width), float64(m.Height))
+
+	for _, a := range c.Areas {
+		shape := ChoroplethShape{
+			Key:   a.Key,
+			Label: a.Key,
+			Title: a.Name + ": " + c.FormatValue(a.Value),
+			Fill:  choroplethNoData,
+			Ink:   "#1f2937",
+		}
+		if a.Value != nil {
+			t := 0.5
+			if *c.Max > *c.Min {
+				t = (*a.Value - *c.Min) / (*c.Max - *c.Min)
+			}
+			shape.Fill = choroplethColor(t)
+			if t > 0.5 {
+				shape.Ink = "#ffffff"
+			}
+		}
+		if c.Query.Level == choroplethCounties {
+			if a.Lat == nil {
+				m.Unplaced++
+				continue
+			}
+			shape.Circle = true
+			shape.X, shape.Y = project(*a.Lat, *a.Lon)
+		} else {
+			tile, ok := stateTiles[a.Key]
+			if !ok {
+				m.Unplaced++
+				continue
+			}
+			shape.X = float64(tile[0]*(choroplethTile+choroplethGap) + choroplethGap/2)
+			shape.Y = float64(tile[1]*(choroplethTile+choroplethGap) + choroplethGap/2)
+			shape.LabelX, shape.LabelY = shape.X+choroplethTile/2, shape.Y+choroplethTile/2
+		}
+		m.Shapes = append(m.Shapes, shape)
+	}
+	return m
+}
+
+// countyProjection fits an equirectangular projection of the areas' positions
+// into a width by height box, leaving room for the dots at the edges
+func countyProjection(areas []ChoroplethArea, width, height float64) func(lat, lon float64) (float64, float64) {
+	minLat, maxLat, minLon, maxLon := 90.0, -90.0, 180.0, -180.0
+	for _, a := range areas {
+		minLat, maxLat = math.Min(minLat, *a.Lat), math.Max(maxLat, *a.Lat)
+		minLon, maxLon = math.Min(minLon, *a.Lon), math.Max(maxLon, *a.Lon)
+	}
+	// Longitude degrees shrink away from the equator
+	xScale := math.Cos((minLat + maxLat) / 2 * math.Pi / 180)
+	spanX := math.Max((maxLon-minLon)*xScale, 1e-6)
+	spanY := math.Max(maxLat-minLat, 1e-6)
+	pad := float64(2 * countyRadius)
+	scale := math.Min((width-2*pad)/spanX, (height-2*pad)/spanY)
+	offsetX := (width - spanX*scale) / 2
+	offsetY := (height - spanY*scale) / 2
+	return func(lat, lon float64) (float64, float64) {
+		return offsetX + (lon-minLon)*xScale*scale, offsetY + (maxLat-lat)*scale
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChoropleth(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	overview, err := db.StatsOverview()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// State ratios and charter shares agree with the statistics overview
+	ratios, err := db.Choropleth(DefaultChoroplethQuery)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shares, err := db.Choropleth(ChoroplethQuery{Metric: choroplethCharterShare, Level: choroplethStates})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ratios.Areas) != len(overview.States) || len(shares.Areas) != len(overview.States) {
+		t.Fatalf("expected %d states, got %d and %d", len(overview.States), len(ratios.Areas), len(shares.Areas))
+	}
+	for _, s := range overview.States {
+		for i, a := range ratios.Areas {
+			if a.Key != s.Key {
+				continue
+			}
+			if a.Value == nil || ratios.FormatValue(a.Value) != s.RatioLabel() || a.Schools != s.Schools {
+				t.Errorf("%s ratio = %s, overview has %s", s.Key, ratios.FormatValue(a.Value), s.RatioLabel())
+			}
+			if share := shares.Areas[i].Value; share == nil || *share != s.CharterShare() {
+				t.Errorf("%s charter share = %v, overview has %v", s.Key, share, s.CharterShare())
+			}
+		}
+	}
+	if shares.FormatValue(shares.Max) != "100.0%" || shares.FormatValue(shares.Min) != "0.0%" {
+		t.Errorf("charter share ranges from %s to %s", shares.FormatValue(shares.Min), shares.FormatValue(shares.Max))
+	}
+
+	// Proficiency is the latest cached state result for the subject and grade
+	state, _ := json.Marshal([]NAEPScore{
+		MockNAEPScore("mathematics", 8, 2019, 282, 34),
+		MockNAEPScore("mathematics", 8, 2022, 280, 30),
+		MockNAEPScore("reading", 8, 2022, 265, 32),
+	})
+	if err := db.SaveNAEPCache("360000100001", "CA", "", state, nil, nil, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	proficiency, err := db.Choropleth(ChoroplethQuery{Metric: choroplethProficiency, Level: choroplethStates, Subject: "mathematics", Grade: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range proficiency.Areas {
+		switch {
+		case a.Key == "CA" && (a.Value == nil || *a.Value != 30 || a.Year != 2022):
+			t.Errorf("CA proficiency = %s (%d)", proficiency.FormatValue(a.Value), a.Year)
+		case a.Key != "CA" && a.Value != nil:
+			t.Errorf("%s has proficiency %v without cached results", a.Key, *a.Value)
+		}
+	}
+
+	// Every state with data gets a tile, shaded by its value
+	m := proficiency.Map()
+	if len(m.Shapes) != len(proficiency.Areas) || m.Unplaced != 0 || m.MinLabel != "30%" {
+		t.Errorf("unexpected state map %+v", m)
+	}
+	for _, shape := range m.Shapes {
+		if shape.Circle || (shape.Key == "CA") == (shape.Fill == choroplethNoData) {
+			t.Errorf("unexpected tile %+v", shape)
+		}
+	}
+
+	// Counties are dots at the average position of their schools
+	if _, err := db.conn.Exec(`
+		INSERT OR REPLACE INTO school_counties VALUES ('360000100001', '06075', 'San Francisco County'), ('360000100002', '06037', 'Los Angeles County');
+		INSERT OR REPLACE INTO school_coordinates VALUES ('360000100001', 37.7749, -122.4194);
+		DELETE FROM school_coordinates WHERE ncessch = '360000100002';
+	`); err != nil {
+		t.Fatal(err)
+	}
+	counties, err := db.Choropleth(ChoroplethQuery{Metric: choroplethRatio, Level: choroplethCounties, State: "ca"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(counties.Areas) != 2 || counties.Areas[0].Name != "Los Angeles County" || counties.Areas[1].Lat == nil {
+		t.Fatalf("unexpected counties %+v", counties.Areas)
+	}
+	if m := counties.Map(); len(m.Shapes) != 1 || !m.Shapes[0].Circle || m.Shapes[0].Key != "06075" || m.Unplaced != 1 {
+		t.Errorf("unexpected county map %+v", m)
+	}
+
+	for _, q := range []ChoroplethQuery{
+		{Metric: "enrollment", Level: choroplethStates},
+		{Metric: choroplethRatio, Level: choroplethCounties},
+		{Metric: choroplethProficiency, Level: choroplethCounties, State: "CA", Subject: "mathematics", Grade: 8},
+		{Metric: choroplethProficiency, Level: choroplethStates, Subject: "mathematics", Grade: 5},
+	} {
+		if _, err := db.Choropleth(q); err == nil {
+			t.Errorf("expected an error for %+v", q)
+		}
+	}
+}
+
+func TestChoroplethHandlers(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	router := NewRouter(ServerConfig{DB: db})
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/stats", nil))
+	if body := rec.Body.String(); !strings.Contains(body, `aria-label="Heatmap"`) || !strings.Contains(body, `<title>California: `) {
+		t.Error("stats page has no heatmap")
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/stats/choropleth?metric=charter_share", nil))
+	if body := rec.Body.String(); !strings.Contains(body, "<title>New York: 100.0%</title>") || !strings.Contains(body, `<option value="charter_share" selected>`) {
+		t.Errorf("heatmap partial doesn't show charter share:\n%s", body)
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/stats/choropleth?metric=charter_share&level=state", nil))
+	var c Choropleth
+	if err := json.Unmarshal(rec.Body.Bytes(), &c); err != nil || rec.Code != 200 {
+		t.Fatalf("API returned %d: %v", rec.Code, err)
+	}
+	if c.Query.Metric != choroplethCharterShare || len(c.Areas) == 0 || c.Max == nil || *c.Max != 100 {
+		t.Errorf("unexpected API response %+v", c)
+	}
+
+	for _, path := range []string{"/api/v1/stats/choropleth?level=county", "/stats/choropleth?grade=eight"} {
+		rec = httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		if rec.Code != 400 {
+			t.Errorf("%s returned %d, expected 400", path, rec.Code)
+		}
+	}
+}
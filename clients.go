@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/anthropics/anthropic-sdk-go/option"
+)
+
+// HTTPDoer sends HTTP requests. *http.Client implements it; tests substitute
+// clients of httptest servers or recorded fixtures.
+type HTTPDoer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// MessageCreator creates Anthropic messages. The SDK's MessageService
+// implements it.
+type MessageCreator interface {
+	New(ctx context.Context, body anthropic.MessageNewParams, opts ...option.RequestOption) (*anthropic.Message, error)
+}
+
+// NAEPOption configures a NAEPClient
+type NAEPOption func(*NAEPClient)
+
+// WithNAEPHTTPClient sends NAEP API requests with h
+func WithNAEPHTTPClient(h HTTPDoer) NAEPOption {
+	return func(c *NAEPClient) {
+		c.httpClient = h
+	}
+}
+
+// WithNAEPBaseURL sends NAEP API requests to baseURL instead of the NAEP data
+// service
+func WithNAEPBaseURL(baseURL string) NAEPOption {
+	return func(c *NAEPClient) {
+		c.baseURL = baseURL
+	}
+}
+
+// WithNAEPClock timestamps fetched NAEP data, and ages cached data, with now
+// instead of the system clock
+func WithNAEPClock(now func() time.Time) NAEPOption {
+	return func(c *NAEPClient) {
+		c.now = now
+	}
+}
+
+// AIScraperOption configures an AIScraperService
+type AIScraperOption func(*AIScraperService)
+
+// WithMessageCreator creates Claude messages with m instead of the Anthropic API
+func WithMessageCreator(m MessageCreator) AIScraperOption {
+	return func(s *AIScraperService) {
+		s.messages = m
+	}
+}
+
+// WithAnthropicOptions adds request options to the Anthropic client, e.g.
+// option.WithBaseURL to send its requests to another server
+func WithAnthropicOptions(opts ...option.RequestOption) AIScraperOption {
+	return func(s *AIScraperService) {
+		s.anthropicOpts = append(s.anthropicOpts, opts...)
+	}
+}
+
+// WithWebsiteHTTPClient fetches school websites with h
+func WithWebsiteHTTPClient(h HTTPDoer) AIScraperOption {
+	return func(s *AIScraperService) {
+		s.httpClient = h
+	}
+}
@@ -0,0 +1,294 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+// ApplicationJSON represents one school applied to in a school choice season
+type ApplicationJSON struct {
+	ID          int64            `json:"id"`
+	Season      string           `json:"season"`
+	NCESSCH     string           `json:"ncessch"`
+	SchoolName  string           `json:"school_name"`
+	Status      string           `json:"status"`
+	StatusLabel string           `json:"status_label"`
+	Priorities  []string         `json:"priorities,omitempty"`
+	Seats       int              `json:"seats,omitempty"`
+	Applicants  int              `json:"applicants,omitempty"`
+	Weight      float64          `json:"weight"`
+	Odds        *float64         `json:"estimated_odds,omitempty"` // 0-1, when seats and applicants are known
+	Notes       string           `json:"notes,omitempty"`
+	Dates       []SchoolDateJSON `json:"dates,omitempty"`
+}
+
+// ApplicationReminderJSON represents an upcoming or missed step in an application
+type ApplicationReminderJSON struct {
+	Date       string `json:"date"`
+	NCESSCH    string `json:"ncessch"`
+	SchoolName string `json:"school_name"`
+	Message    string `json:"message"`
+	Overdue    bool   `json:"overdue"`
+}
+
+// ApplicationStatusCountJSON represents how many applications have one status
+type ApplicationStatusCountJSON struct {
+	Status string `json:"status"`
+	Label  string `json:"label"`
+	Count  int    `json:"count"`
+}
+
+// ApplicationsReportJSON represents a season's applications with a status
+// summary and reminders
+type ApplicationsReportJSON struct {
+	Season       string                       `json:"season"`
+	Summary      []ApplicationStatusCountJSON `json:"summary"`
+	Reminders    []ApplicationReminderJSON    `json:"reminders"`
+	Applications []ApplicationJSON            `json:"applications"`
+}
+
+// ApplicationUpdateJSON holds the application fields to change; nil fields keep
+// their current values
+type ApplicationUpdateJSON struct {
+	Status     *string
+	Priorities *[]string
+	Seats      *int
+	Applicants *int
+	Weight     *float64
+	Notes      *string
+}
+
+var (
+	appSeason     string
+	appTable      bool
+	appDays       int
+	appStatus     string
+	appPriorities []string
+	appSeats      int
+	appApplicants int
+	appWeight     float64
+	appNotes      string
+	appOutput     string
+
+	applicationsCmd = &cobra.Command{
+		Use:     "applications",
+		Aliases: []string{"apps"},
+		Short:   "Track school choice and charter applications",
+		Long: `Track the schools you applied to in a school choice season: status, priority
+categories, lottery odds, and outcomes. The listing includes a status summary and
+reminders for key dates recorded with "schoolfinder timeline add", such as
+lotteries and enrollment deadlines. Results are returned as JSON.
+
+Statuses: planning, applied, waitlisted, offered, accepted, declined,
+not_offered, withdrawn.
+
+Odds are a rough estimate: seats times lottery weight (entries per applicant from
+priorities) over applicants.
+
+Example:
+  schoolfinder applications set 360000100001 --status applied --priority sibling --seats 60 --applicants 400 --weight 2
+  schoolfinder applications --table
+  schoolfinder applications remind --days 7
+  schoolfinder applications recap -o recap.md`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			report := loadApplicationsReport()
+			if appTable {
+				printApplicationsTable(report)
+				return
+			}
+			printJSON(report)
+		},
+	}
+
+	applicationsSetCmd = &cobra.Command{
+		Use:   "set [school-id]",
+		Short: "Add an application or update its status, priorities, odds, or notes",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			var update ApplicationUpdateJSON
+			flags := cmd.Flags()
+			if flags.Changed("status") {
+				update.Status = &appStatus
+			}
+			if flags.Changed("priority") {
+				update.Priorities = &appPriorities
+			}
+			if flags.Changed("seats") {
+				update.Seats = &appSeats
+			}
+			if flags.Changed("applicants") {
+				update.Applicants = &appApplicants
+			}
+			if flags.Changed("weight") {
+				update.Weight = &appWeight
+			}
+			if flags.Changed("note") {
+				update.Notes = &appNotes
+			}
+
+			db, cleanup, err := InitDB(dataDir)
+			if err != nil {
+				HandleError(err, "Failed to initialize database")
+			}
+			defer cleanup()
+
+			app, err := SetApplication(db, appSeason, args[0], update)
+			if err != nil {
+				HandleError(err, "Failed to save application")
+			}
+			printJSON(app)
+		},
+	}
+
+	applicationsRemoveCmd = &cobra.Command{
+		Use:   "remove [school-id]",
+		Short: "Remove an application",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			db, cleanup, err := InitDB(dataDir)
+			if err != nil {
+				HandleError(err, "Failed to initialize database")
+			}
+			defer cleanup()
+
+			if err := RemoveApplication(db, appSeason, args[0]); err != nil {
+				HandleError(err, "Failed to remove application")
+			}
+			fmt.Fprintf(os.Stderr, "Removed application to %s\n", args[0])
+		},
+	}
+
+	applicationsRemindCmd = &cobra.Command{
+		Use:   "remind",
+		Short: "Print upcoming and missed application dates, one per line",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			report := loadApplicationsReport()
+			if len(report.Reminders) == 0 {
+				fmt.Fprintf(os.Stderr, "Nothing due in the next %d days.\n", appDays)
+				return
+			}
+			for _, r := range report.Reminders {
+				printReminder(r)
+			}
+		},
+	}
+
+	applicationsRecapCmd = &cobra.Command{
+		Use:   "recap",
+		Short: "Export a markdown recap of the season's applications and outcomes",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			db, cleanup, err := InitDB(dataDir)
+			if err != nil {
+				HandleError(err, "Failed to initialize database")
+			}
+			defer cleanup()
+
+			recap, err := SeasonRecap(db, appSeason)
+			if err != nil {
+				HandleError(err, "Failed to build recap")
+			}
+			if appOutput == "" {
+				fmt.Print(recap)
+				return
+			}
+			if err := os.WriteFile(appOutput, []byte(recap), 0644); err != nil {
+				HandleError(err, "Failed to write recap")
+			}
+			fmt.Fprintf(os.Stderr, "Saved recap to %s\n", appOutput)
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(applicationsCmd)
+	applicationsCmd.AddCommand(applicationsSetCmd, applicationsRemoveCmd, applicationsRemindCmd, applicationsRecapCmd)
+
+	applicationsCmd.PersistentFlags().StringVar(&appSeason, "season", "", "School year applied for, e.g. 2027-28 (default: the upcoming one)")
+	applicationsCmd.Flags().BoolVar(&appTable, "table", false, "Print a table instead of JSON")
+	for _, c := range []*cobra.Command{applicationsCmd, applicationsRemindCmd} {
+		c.Flags().IntVar(&appDays, "days", 14, "Remind about dates this many days ahead")
+	}
+
+	applicationsSetCmd.Flags().StringVar(&appStatus, "status", "", "Application status (new applications start as planning)")
+	applicationsSetCmd.Flags().StringSliceVar(&appPriorities, "priority", nil, "Priority categories claimed, e.g. sibling,neighborhood")
+	applicationsSetCmd.Flags().IntVar(&appSeats, "seats", 0, "Seats available")
+	applicationsSetCmd.Flags().IntVar(&appApplicants, "applicants", 0, "Applicants for those seats")
+	applicationsSetCmd.Flags().Float64Var(&appWeight, "weight", 1, "Lottery entries per applicant from priorities")
+	applicationsSetCmd.Flags().StringVar(&appNotes, "note", "", "Notes")
+
+	applicationsRecapCmd.Flags().StringVarP(&appOutput, "output", "o", "", "Write to a file instead of stdout")
+}
+
+func loadApplicationsReport() *ApplicationsReportJSON {
+	db, cleanup, err := InitDB(dataDir)
+	if err != nil {
+		HandleError(err, "Failed to initialize database")
+	}
+	defer cleanup()
+
+	report, err := ApplicationsReport(db, appSeason, appDays)
+	if err != nil {
+		HandleError(err, "Failed to load applications")
+	}
+	return report
+}
+
+// printApplicationsTable writes the season's summary, reminders, and
+// applications as aligned text
+func printApplicationsTable(report *ApplicationsReportJSON) {
+	fmt.Printf("Season %s\n", report.Season)
+	var counts []string
+	for _, c := range report.Summary {
+		counts = append(counts, fmt.Sprintf("%s: %d", c.Label, c.Count))
+	}
+	if len(counts) > 0 {
+		fmt.Println(strings.Join(counts, " · "))
+	}
+	fmt.Println()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "SCHOOL\tSTATUS\tPRIORITIES\tODDS")
+	for _, a := range report.Applications {
+		odds := "-"
+		if a.Odds != nil {
+			odds = fmt.Sprintf("~%.0f%%", *a.Odds*100)
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", a.SchoolName, a.StatusLabel, strings.Join(a.Priorities, ", "), odds)
+	}
+	_ = w.Flush()
+
+	if len(report.Reminders) > 0 {
+		fmt.Println("\nReminders:")
+		for _, r := range report.Reminders {
+			printReminder(r)
+		}
+	}
+}
+
+// printReminder writes a reminder on one line, marking missed dates with "!"
+func printReminder(r ApplicationReminderJSON) {
+	mark := " "
+	if r.Overdue {
+		mark = "!"
+	}
+	fmt.Printf("%s %s  %s: %s\n", mark, r.Date, r.SchoolName, r.Message)
+}
+
+// ApplicationsReport is set by main package; an empty season means the upcoming one
+var ApplicationsReport func(db DBInterface, season string, days int) (*ApplicationsReportJSON, error)
+
+// SetApplication is set by main package
+var SetApplication func(db DBInterface, season, ncessch string, update ApplicationUpdateJSON) (*ApplicationJSON, error)
+
+// RemoveApplication is set by main package
+var RemoveApplication func(db DBInterface, season, ncessch string) error
+
+// SeasonRecap is set by main package
+var SeasonRecap func(db DBInterface, season string) (string, error)
@@ -0,0 +1,274 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+// AreaLevelJSON represents the number of schools at one school level
+type AreaLevelJSON struct {
+	Level string `json:"level"`
+	Count int    `json:"count"`
+}
+
+// EnrollmentDistributionJSON represents the five-number summary of school enrollments
+type EnrollmentDistributionJSON struct {
+	Schools int     `json:"schools"`
+	Min     float64 `json:"min"`
+	Q1      float64 `json:"q1"`
+	Median  float64 `json:"median"`
+	Q3      float64 `json:"q3"`
+	Max     float64 `json:"max"`
+}
+
+// AreaNAEPScoreJSON represents a state's most recent NAEP result for a subject and grade
+type AreaNAEPScoreJSON struct {
+	Subject            string   `json:"subject"`
+	Grade              int      `json:"grade"`
+	Year               int      `json:"year"`
+	MeanScore          float64  `json:"mean_score"`
+	AtProficient       float64  `json:"at_proficient"`
+	NationalMeanScore  *float64 `json:"national_mean_score,omitempty"`
+	NationalProficient *float64 `json:"national_proficient,omitempty"`
+}
+
+// AreaStateJSON represents a state with schools in the area and its cached NAEP results
+type AreaStateJSON struct {
+	State   string              `json:"state"`
+	Name    string              `json:"name"`
+	Schools int                 `json:"schools"`
+	NAEP    []AreaNAEPScoreJSON `json:"naep"`
+}
+
+// AreaSchoolJSON represents a school in the area
+type AreaSchoolJSON struct {
+	NCESSCH    string   `json:"ncessch"`
+	Name       string   `json:"name"`
+	City       string   `json:"city"`
+	Level      string   `json:"level,omitempty"`
+	Grades     string   `json:"grades,omitempty"`
+	Enrollment *int64   `json:"enrollment,omitempty"`
+	Ratio      *float64 `json:"student_teacher_ratio,omitempty"`
+}
+
+// AreaJSON represents the summary of the schools in a zip code, metro area, county, or congressional district
+type AreaJSON struct {
+	Kind               string                     `json:"kind"`
+	Code               string                     `json:"code"`
+	Name               string                     `json:"name"`
+	SchoolCount        int                        `json:"school_count"`
+	Levels             []AreaLevelJSON            `json:"levels"`
+	Enrollment         EnrollmentDistributionJSON `json:"enrollment"`
+	TotalEnrollment    int64                      `json:"total_enrollment"`
+	Teachers           float64                    `json:"teachers"` // Full-time equivalent
+	StudentsPerTeacher *float64                   `json:"students_per_teacher,omitempty"`
+	States             []AreaStateJSON            `json:"states"`
+	Schools            []AreaSchoolJSON           `json:"schools"`
+}
+
+// AreaRollupJSON represents the totals for one county or congressional district
+type AreaRollupJSON struct {
+	Code               string   `json:"code"` // County FIPS code, or a district like IL-07
+	Name               string   `json:"name"`
+	State              string   `json:"state"`
+	Schools            int      `json:"schools"`
+	CharterSchools     int      `json:"charter_schools"`
+	Students           int64    `json:"students"`
+	Teachers           float64  `json:"teachers"`
+	StudentsPerTeacher *float64 `json:"students_per_teacher,omitempty"`
+}
+
+var (
+	areaCBSA   bool
+	areaCounty bool
+	areaCD     bool
+	areaList   bool
+	areaTable  bool
+	areaCmd    = &cobra.Command{
+		Use:   "area [zip]",
+		Short: "Summarize the schools in a zip code, metro area, county, or congressional district",
+		Long: `Summarize the schools in a zip code, or with --cbsa a core-based statistical
+area (metro area), with --county a county by FIPS code, or with --cd a
+congressional district (IL-07, or AK-AL for an at-large seat): counts by level,
+the enrollment distribution, teachers and the pooled student/teacher ratio,
+cached state NAEP results, and the list of schools.
+
+With --list and --county or --cd, totals schools, charter schools, students,
+and teachers for every county or congressional district in the state given
+(or all of them).
+
+Metro areas, counties, and congressional districts need an NCES EDGE public
+school geocode file saved as CSV (EDGE_GEOCODE_PUBLICSCH_*.csv) in the data
+directory. NAEP results come from the cache only; open a school page in this
+state to load them.
+
+Example:
+  schoolfinder area 97214
+  schoolfinder area 38900 --cbsa --table
+  schoolfinder area 17031 --county --table
+  schoolfinder area IL --cd --list --table`,
+		Args: cobra.RangeArgs(0, 1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if len(args) == 0 && !areaList {
+				HandleError(fmt.Errorf("an area code is required"), "Failed to summarize area")
+			}
+			db, cleanup, err := InitDB(dataDir)
+			if err != nil {
+				HandleError(err, "Failed to initialize database")
+			}
+			defer cleanup()
+
+			kind := "zip"
+			switch {
+			case areaCBSA:
+				kind = "cbsa"
+			case areaCounty:
+				kind = "county"
+			case areaCD:
+				kind = "cd"
+			}
+
+			if areaList {
+				state := ""
+				if len(args) > 0 {
+					state = args[0]
+				}
+				rollups, err := AreaRollups(db, kind, state)
+				if err != nil {
+					HandleError(err, "Failed to total areas")
+				}
+				if areaTable {
+					printAreaRollups(rollups)
+					return
+				}
+				printJSON(rollups)
+				return
+			}
+
+			area, err := AreaSummary(db, kind, args[0])
+			if err != nil {
+				HandleError(err, "Failed to summarize area")
+			}
+
+			if areaTable {
+				printAreaTable(area)
+				return
+			}
+
+			output, err := json.MarshalIndent(area, "", "  ")
+			if err != nil {
+				HandleError(err, "Failed to encode JSON")
+			}
+			fmt.Println(string(output))
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(areaCmd)
+	areaCmd.Flags().BoolVar(&areaCBSA, "cbsa", false, "Treat the argument as a CBSA (metro area) code")
+	areaCmd.Flags().BoolVar(&areaCounty, "county", false, "Treat the argument as a county FIPS code")
+	areaCmd.Flags().BoolVar(&areaCD, "cd", false, "Treat the argument as a congressional district, e.g. IL-07")
+	areaCmd.Flags().BoolVar(&areaList, "list", false, "List every county or congressional district (with --county or --cd) in the state given")
+	areaCmd.MarkFlagsMutuallyExclusive("cbsa", "county", "cd")
+	areaCmd.Flags().BoolVar(&areaTable, "table", false, "Print a text summary instead of JSON")
+}
+
+// printAreaTable writes the area summary as text with an enrollment box plot
+func printAreaTable(area *AreaJSON) {
+	fmt.Printf("%s: %d schools\n", area.Name, area.SchoolCount)
+
+	fmt.Println("\nSchools by level")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, l := range area.Levels {
+		_, _ = fmt.Fprintf(w, "  %s\t%d\n", l.Level, l.Count)
+	}
+	_ = w.Flush()
+
+	if e := area.Enrollment; e.Schools > 0 {
+		fmt.Printf("\nEnrollment per school (%d schools, %d students)\n", e.Schools, area.TotalEnrollment)
+		fmt.Printf("  %s\n", boxPlot(e, 50))
+		fmt.Printf("  min %.0f  q1 %.0f  median %.0f  q3 %.0f  max %.0f\n", e.Min, e.Q1, e.Median, e.Q3, e.Max)
+	}
+	if area.Teachers > 0 {
+		fmt.Printf("\nTeachers: %.1f FTE\n", area.Teachers)
+	}
+	if area.StudentsPerTeacher != nil {
+		fmt.Printf("Student-teacher ratio: %.1f:1\n", *area.StudentsPerTeacher)
+	}
+
+	for _, state := range area.States {
+		fmt.Printf("\nNAEP context: %s\n", state.Name)
+		if len(state.NAEP) == 0 {
+			fmt.Println("  No cached results")
+			continue
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "  SUBJECT\tGRADE\tYEAR\tSCORE\tPROFICIENT\tNATIONAL")
+		for _, s := range state.NAEP {
+			national := "N/A"
+			if s.NationalMeanScore != nil {
+				national = fmt.Sprintf("%.0f / %.0f%%", *s.NationalMeanScore, *s.NationalProficient)
+			}
+			_, _ = fmt.Fprintf(w, "  %s\t%d\t%d\t%.0f\t%.0f%%\t%s\n", s.Subject, s.Grade, s.Year, s.MeanScore, s.AtProficient, national)
+		}
+		_ = w.Flush()
+	}
+
+	fmt.Printf("\nSchools (%d)\n", len(area.Schools))
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "NCESSCH\tNAME\tCITY\tLEVEL\tGRADES\tENROLLMENT")
+	for _, s := range area.Schools {
+		enrollment := "N/A"
+		if s.Enrollment != nil {
+			enrollment = fmt.Sprintf("%d", *s.Enrollment)
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", s.NCESSCH, s.Name, s.City, s.Level, s.Grades, enrollment)
+	}
+	_ = w.Flush()
+}
+
+// printAreaRollups writes county or congressional district totals as a table
+func printAreaRollups(rollups []AreaRollupJSON) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "CODE\tNAME\tSTATE\tSCHOOLS\tCHARTERS\tSTUDENTS\tTEACHERS\tRATIO")
+	for _, r := range rollups {
+		ratio := "N/A"
+		if r.StudentsPerTeacher != nil {
+			ratio = fmt.Sprintf("%.1f:1", *r.StudentsPerTeacher)
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%d\t%.1f\t%s\n", r.Code, r.Name, r.State, r.Schools, r.CharterSchools, r.Students, r.Teachers, ratio)
+	}
+	_ = w.Flush()
+}
+
+// boxPlot draws the enrollment distribution as a text box plot, e.g. ├───[██│████]──────┤
+func boxPlot(e EnrollmentDistributionJSON, width int) string {
+	col := func(v float64) int {
+		if e.Max == e.Min {
+			return width / 2
+		}
+		return int(math.Round((v - e.Min) / (e.Max - e.Min) * float64(width-1)))
+	}
+
+	line := []rune(strings.Repeat("─", width))
+	for i := col(e.Q1); i <= col(e.Q3); i++ {
+		line[i] = '█'
+	}
+	line[0], line[width-1] = '├', '┤'
+	line[col(e.Q1)], line[col(e.Q3)] = '[', ']'
+	line[col(e.Median)] = '│'
+	return string(line)
+}
+
+// AreaSummary is set by main package
+var AreaSummary func(db DBInterface, kind, code string) (*AreaJSON, error)
+
+// AreaRollups is set by main package
+var AreaRollups func(db DBInterface, kind, state string) ([]AreaRollupJSON, error)
@@ -15,7 +15,8 @@ var askCmd = &cobra.Command{
 	Long: `Ask a natural language question and get an AI-powered answer using Claude Haiku 4.5.
 This command uses the Fantasy library to interact with Claude.
 
-Requires ANTHROPIC_API_KEY environment variable to be set.
+Requires an Anthropic API key: run schoolfinder auth set anthropic, or set
+ANTHROPIC_API_KEY.
 
 Example:
   schoolfinder ask "What are the most important factors when choosing a school?"
@@ -25,6 +26,12 @@ Example:
 		// Get the question from arguments
 		question := args[0]
 
+		// Count the question if usage tracking is on
+		if db, cleanup, err := InitDB(dataDir); err == nil {
+			RecordUsage(db, "agent_query")
+			cleanup()
+		}
+
 		// Wrap the initialization functions to match the agent package's interface
 		initDBWrapper := func(dataDir string) (agent.DBInterface, func(), error) {
 			db, cleanup, err := InitDB(dataDir)
@@ -45,10 +52,15 @@ Example:
 			return &aiScraperInterfaceAdapter{scraper: scraper}, nil
 		}
 
+		apiKey := Secret("anthropic")
+		if apiKey == "" {
+			HandleError(fmt.Errorf("no Anthropic API key: run schoolfinder auth set anthropic or set ANTHROPIC_API_KEY"), "Failed to create agent")
+		}
+
 		// Create the agent using the factory with options
 		fantasyAgent, err := agent.NewAskAgent(
 			rootCmd,
-			agent.WithAPIKeyFromEnv(),
+			agent.WithAPIKey(apiKey),
 			agent.WithDataDir(dataDir),
 			agent.WithDBInitializer(initDBWrapper),
 			agent.WithAIScraperInitializer(initAIScraperWrapper),
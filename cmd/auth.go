@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// SecretStatusJSON represents where a secret comes from, without its value
+type SecretStatusJSON struct {
+	Name        string `json:"name"`
+	Env         string `json:"env"` // Environment variable that overrides the stored value
+	Description string `json:"description"`
+	Source      string `json:"source,omitempty"` // The environment variable or store it comes from; empty when unset
+}
+
+var (
+	authTable bool
+	authCmd   = &cobra.Command{
+		Use:   "auth",
+		Short: "Store API keys and passwords in the OS keychain",
+		Long: `List the API keys and passwords School Finder uses and where each comes from,
+without showing them. Secrets are kept in the OS keychain (the macOS keychain,
+or the Secret Service keyring through secret-tool on Linux) and otherwise in
+an encrypted file in the user config directory, so they needn't be exported
+in a shell profile or typed into shell history. An environment variable, such
+as ANTHROPIC_API_KEY, still takes precedence over the stored value.
+
+The encrypted file's key is kept beside it, readable only by you, unless
+SCHOOLFINDER_SECRETS_PASSPHRASE is set when the file is created; then the key
+is derived from the passphrase, which must be set whenever it's read.
+
+Secrets: anthropic, smtp, token, peer-token
+
+Example:
+  schoolfinder auth set anthropic
+  pass show anthropic | schoolfinder auth set anthropic
+  schoolfinder auth --table
+  schoolfinder auth remove smtp`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			statuses := SecretStatuses()
+			if authTable {
+				printSecretStatuses(statuses)
+				return
+			}
+			printJSON(statuses)
+		},
+	}
+
+	authSetCmd = &cobra.Command{
+		Use:   "set [name]",
+		Short: "Store a secret, read from a prompt or stdin",
+		Long: `Store a secret in the OS keychain, or the encrypted file when there's no
+keychain. The value is read from a prompt that doesn't echo it, or from stdin
+when it's piped in.
+
+Example:
+  schoolfinder auth set anthropic`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			value, err := readSecret(args[0])
+			if err != nil {
+				HandleError(err, "Failed to read secret")
+			}
+			store, err := SetSecret(args[0], value)
+			if err != nil {
+				HandleError(err, "Failed to store secret")
+			}
+			fmt.Printf("✓ Stored %s in the %s\n", args[0], store)
+			for _, s := range SecretStatuses() {
+				if s.Name == args[0] && s.Source == s.Env {
+					fmt.Printf("  %s is set and takes precedence; unset it to use the stored value\n", s.Env)
+				}
+			}
+		},
+	}
+
+	authRemoveCmd = &cobra.Command{
+		Use:   "remove [name]",
+		Short: "Remove a stored secret",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := RemoveSecret(args[0]); err != nil {
+				HandleError(err, "Failed to remove secret")
+			}
+			fmt.Printf("✓ Removed %s\n", args[0])
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(authCmd)
+	authCmd.AddCommand(authSetCmd, authRemoveCmd)
+	authCmd.Flags().BoolVar(&authTable, "table", false, "Print a table instead of JSON")
+}
+
+// readSecret prompts for a secret without echoing it, or reads it from stdin
+// when stdin isn't a terminal
+func readSecret(name string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		b, err := io.ReadAll(io.LimitReader(os.Stdin, 64*1024))
+		return strings.TrimSpace(string(b)), err
+	}
+	fmt.Fprintf(os.Stderr, "%s: ", name)
+	b, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	return strings.TrimSpace(string(b)), err
+}
+
+// printSecretStatuses writes where each secret comes from as a table
+func printSecretStatuses(statuses []SecretStatusJSON) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SECRET\tSOURCE\tUSED FOR")
+	for _, s := range statuses {
+		source := s.Source
+		if source == "" {
+			source = "not set (" + s.Env + ")"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", s.Name, source, s.Description)
+	}
+	w.Flush()
+}
+
+// Secret returns a secret from its environment variable or the secret store,
+// or empty when it's unset
+func Secret(name string) string {
+	if LookupSecret == nil {
+		return ""
+	}
+	return LookupSecret(name)
+}
+
+// These are set by main package
+var (
+	LookupSecret   func(name string) string
+	SetSecret      func(name, value string) (string, error)
+	RemoveSecret   func(name string) error
+	SecretStatuses func() []SecretStatusJSON
+)
@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+// BenchResultJSON represents latency percentiles for one benchmarked operation
+type BenchResultJSON struct {
+	Name          string   `json:"name"`
+	Iterations    int      `json:"iterations"`
+	P50MS         float64  `json:"p50_ms"`
+	P95MS         float64  `json:"p95_ms"`
+	MaxMS         float64  `json:"max_ms"`
+	BudgetMS      float64  `json:"budget_ms,omitempty"`
+	WithinBudget  bool     `json:"within_budget"`
+	Skipped       string   `json:"skipped,omitempty"`
+	PreviousP95MS *float64 `json:"previous_p95_ms,omitempty"`
+}
+
+// BenchReportJSON represents a bench run and the run it is compared against
+type BenchReportJSON struct {
+	Version         string            `json:"version"`
+	RunAt           string            `json:"run_at"`
+	PreviousVersion string            `json:"previous_version,omitempty"`
+	PreviousRunAt   string            `json:"previous_run_at,omitempty"`
+	WithinBudget    bool              `json:"within_budget"`
+	Results         []BenchResultJSON `json:"results"`
+}
+
+var (
+	benchIterations   int
+	benchTable        bool
+	benchFailOnBudget bool
+	benchCmd          = &cobra.Command{
+		Use:   "bench",
+		Short: "Measure search, detail, and enrichment latency",
+		Long: `Measure search latency (FTS and LIKE paths), school detail lookups, and
+enrichment from cached AI and NAEP data against the local database.
+
+Each run is saved to the database and compared with the previous run so
+regressions between releases are visible. Every operation has a p95 budget
+(search < 50ms, detail < 10ms, enrichment < 25ms).
+
+Example:
+  schoolfinder bench
+  schoolfinder bench --iterations 200 --table
+  schoolfinder bench --fail-on-budget`,
+		Run: func(cmd *cobra.Command, args []string) {
+			db, cleanup, err := InitDB(dataDir)
+			if err != nil {
+				HandleError(err, "Failed to initialize database")
+			}
+			defer cleanup()
+
+			report, err := RunBenchmarks(db, benchIterations)
+			if err != nil {
+				HandleError(err, "Failed to run benchmarks")
+			}
+
+			if benchTable {
+				printBenchTable(report)
+			} else {
+				output, err := json.MarshalIndent(report, "", "  ")
+				if err != nil {
+					HandleError(err, "Failed to encode JSON")
+				}
+				fmt.Println(string(output))
+			}
+
+			if benchFailOnBudget && !report.WithinBudget {
+				cleanup()
+				os.Exit(1)
+			}
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(benchCmd)
+	benchCmd.Flags().IntVarP(&benchIterations, "iterations", "n", 50, "Timed iterations per operation")
+	benchCmd.Flags().BoolVar(&benchTable, "table", false, "Print a table instead of JSON")
+	benchCmd.Flags().BoolVar(&benchFailOnBudget, "fail-on-budget", false, "Exit with status 1 if any p95 exceeds its budget")
+}
+
+// printBenchTable writes a bench report as an aligned table
+func printBenchTable(report *BenchReportJSON) {
+	fmt.Printf("Version %s, run at %s\n", report.Version, report.RunAt)
+	if report.PreviousRunAt != "" {
+		fmt.Printf("Compared with version %s, run at %s\n", report.PreviousVersion, report.PreviousRunAt)
+	}
+	fmt.Println()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "OPERATION\tP50\tP95\tMAX\tBUDGET\tPREV P95\tSTATUS")
+	for _, r := range report.Results {
+		if r.Skipped != "" {
+			_, _ = fmt.Fprintf(w, "%s\t-\t-\t-\t-\t-\tskipped (%s)\n", r.Name, r.Skipped)
+			continue
+		}
+
+		budget, previous, status := "-", "-", "ok"
+		if r.BudgetMS > 0 {
+			budget = fmt.Sprintf("%.1fms", r.BudgetMS)
+		}
+		if r.PreviousP95MS != nil {
+			previous = fmt.Sprintf("%.2fms", *r.PreviousP95MS)
+		}
+		if !r.WithinBudget {
+			status = "OVER BUDGET"
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%.2fms\t%.2fms\t%.2fms\t%s\t%s\t%s\n", r.Name, r.P50MS, r.P95MS, r.MaxMS, budget, previous, status)
+	}
+	_ = w.Flush()
+}
+
+// RunBenchmarks is set by main package
+var RunBenchmarks func(db DBInterface, iterations int) (*BenchReportJSON, error)
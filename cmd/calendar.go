@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+// CalendarEventJSON represents a date or range of dates in a school's calendar
+type CalendarEventJSON struct {
+	Kind  string `json:"kind"` // first_day, last_day, break, or holiday
+	Name  string `json:"name"`
+	Start string `json:"start"`
+	End   string `json:"end"` // The last day; the same as start for single days
+}
+
+// SchoolCalendarJSON represents a school's academic calendar
+type SchoolCalendarJSON struct {
+	NCESSCH     string              `json:"ncessch"`
+	SchoolName  string              `json:"school_name"`
+	SchoolYear  string              `json:"school_year,omitempty"`
+	SourceURL   string              `json:"source_url,omitempty"`
+	ExtractedAt string              `json:"extracted_at,omitempty"` // Empty when the calendar hasn't been extracted
+	Events      []CalendarEventJSON `json:"events"`
+}
+
+// CalendarMismatchJSON represents weekdays when some schools are off and others aren't
+type CalendarMismatchJSON struct {
+	Start     string   `json:"start"`
+	End       string   `json:"end"`
+	Off       []string `json:"off"`
+	InSession []string `json:"in_session"`
+}
+
+// CalendarComparisonJSON represents several schools' calendars side by side
+type CalendarComparisonJSON struct {
+	Start      string                 `json:"start,omitempty"`
+	End        string                 `json:"end,omitempty"`
+	Schools    []SchoolCalendarJSON   `json:"schools"`
+	Mismatches []CalendarMismatchJSON `json:"mismatches"`
+}
+
+var (
+	calendarTable  bool
+	calendarOutput string
+	calendarCmd    = &cobra.Command{
+		Use:   "calendar [school-id...]",
+		Short: "Compare schools' academic calendars to see which breaks line up",
+		Long: `Line up the academic calendars of schools: the first and last day of school,
+breaks, and holidays, and the weekdays when some of the schools are off and
+others are in session. With no school IDs, every child's saved schools are
+compared.
+
+Calendars are found on school and district websites with AI by
+"calendar extract" and cached in the school_calendars table. "calendar ics"
+writes one school's calendar as an iCalendar file.
+
+Example:
+  schoolfinder calendar extract 360000100001
+  schoolfinder calendar --table
+  schoolfinder calendar 360000100001 360000100002
+  schoolfinder calendar ics 360000100001 -o lincoln.ics`,
+		Run: func(cmd *cobra.Command, args []string) {
+			db, cleanup, err := InitDB(dataDir)
+			if err != nil {
+				HandleError(err, "Failed to initialize database")
+			}
+			defer cleanup()
+
+			comparison, err := CompareCalendars(db, args)
+			if err != nil {
+				HandleError(err, "Failed to compare calendars")
+			}
+			if calendarTable {
+				printCalendarTable(comparison)
+				return
+			}
+			printJSON(comparison)
+		},
+	}
+
+	calendarExtractCmd = &cobra.Command{
+		Use:   "extract [school-id]",
+		Short: "Find a school's academic calendar on its or its district's website with AI",
+		Long: `Find a school's academic calendar on its or its district's website with
+Claude AI web search. Calendars are cached and extracted again after the AI
+cache TTL.
+
+Requires an Anthropic API key: run schoolfinder auth set anthropic, or set
+ANTHROPIC_API_KEY.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			db, cleanup, err := InitDB(dataDir)
+			if err != nil {
+				HandleError(err, "Failed to initialize database")
+			}
+			defer cleanup()
+
+			calendar, err := ExtractCalendar(db, args[0])
+			if err != nil {
+				HandleError(err, "Failed to extract calendar")
+			}
+			printJSON(calendar)
+		},
+	}
+
+	calendarICSCmd = &cobra.Command{
+		Use:   "ics [school-id]",
+		Short: "Export a school's academic calendar as an iCalendar (.ics) file",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			db, cleanup, err := InitDB(dataDir)
+			if err != nil {
+				HandleError(err, "Failed to initialize database")
+			}
+			defer cleanup()
+
+			var w io.Writer = os.Stdout
+			if calendarOutput != "" {
+				f, err := os.Create(calendarOutput)
+				if err != nil {
+					HandleError(err, "Failed to create output file")
+				}
+				defer f.Close()
+				w = f
+			}
+			if err := ExportCalendar(db, w, args[0]); err != nil {
+				HandleError(err, "Failed to export calendar")
+			}
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(calendarCmd)
+	calendarCmd.AddCommand(calendarExtractCmd, calendarICSCmd)
+	calendarCmd.Flags().BoolVar(&calendarTable, "table", false, "Print a table instead of JSON")
+	calendarICSCmd.Flags().StringVarP(&calendarOutput, "output", "o", "", "Write to a file instead of stdout")
+}
+
+// printCalendarTable writes each school's calendar and the mismatched weekdays as aligned tables
+func printCalendarTable(comparison *CalendarComparisonJSON) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "SCHOOL\tEVENT\tSTART\tEND")
+	for _, s := range comparison.Schools {
+		if len(s.Events) == 0 {
+			_, _ = fmt.Fprintf(w, "%s\t(no calendar; run calendar extract %s)\t\t\n", s.SchoolName, s.NCESSCH)
+			continue
+		}
+		for _, e := range s.Events {
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", s.SchoolName, e.Name, e.Start, e.End)
+		}
+	}
+	_ = w.Flush()
+
+	if len(comparison.Mismatches) == 0 {
+		return
+	}
+	fmt.Println()
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "START\tEND\tOFF\tIN SESSION")
+	for _, m := range comparison.Mismatches {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", m.Start, m.End, strings.Join(m.Off, ", "), strings.Join(m.InSession, ", "))
+	}
+	_ = w.Flush()
+}
+
+// CompareCalendars is set by main package
+var CompareCalendars func(db DBInterface, ncesschList []string) (*CalendarComparisonJSON, error)
+
+// ExtractCalendar is set by main package
+var ExtractCalendar func(db DBInterface, ncessch string) (*SchoolCalendarJSON, error)
+
+// ExportCalendar is set by main package
+var ExportCalendar func(db DBInterface, w io.Writer, ncessch string) error
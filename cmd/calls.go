@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+// SchoolCallJSON represents a call to a school in the call log
+type SchoolCallJSON struct {
+	ID         int64  `json:"id"`
+	NCESSCH    string `json:"ncessch"`
+	SchoolName string `json:"school_name"`
+	Phone      string `json:"phone,omitempty"` // E.164, for dialing
+	CalledAt   string `json:"called_at"`
+	Note       string `json:"note"`
+}
+
+var (
+	callsSchool string
+	callsTable  bool
+	callsAt     string
+	callsCmd    = &cobra.Command{
+		Use:   "calls",
+		Short: "Log calls to schools and what they said",
+		Long: `List the calls logged to schools' front offices, most recent first, with
+what each school said. Results are returned as JSON. Calls are also in each
+school's markdown note, and on its web page.
+
+Example:
+  schoolfinder calls add 360000100001 "Tours on Tuesdays at 9; call back in November"
+  schoolfinder calls add 360000100001 "Left a voicemail" --at "2026-10-12 14:30"
+  schoolfinder calls --school 360000100001 --table
+  schoolfinder calls remove 3`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			db, cleanup, err := InitDB(dataDir)
+			if err != nil {
+				HandleError(err, "Failed to initialize database")
+			}
+			defer cleanup()
+
+			calls, err := ListSchoolCalls(db, callsSchool)
+			if err != nil {
+				HandleError(err, "Failed to load calls")
+			}
+			if callsTable {
+				printCallsTable(calls)
+				return
+			}
+			printJSON(calls)
+		},
+	}
+
+	callsAddCmd = &cobra.Command{
+		Use:   "add [school-id] [note]",
+		Short: "Log a call to a school and what they said",
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			db, cleanup, err := InitDB(dataDir)
+			if err != nil {
+				HandleError(err, "Failed to initialize database")
+			}
+			defer cleanup()
+
+			call, err := AddSchoolCall(db, args[0], callsAt, args[1])
+			if err != nil {
+				HandleError(err, "Failed to log call")
+			}
+			printJSON(call)
+		},
+	}
+
+	callsRemoveCmd = &cobra.Command{
+		Use:   "remove [call-id]",
+		Short: "Remove a call by the ID shown in the call log",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			id, err := strconv.ParseInt(args[0], 10, 64)
+			if err != nil {
+				HandleError(fmt.Errorf("invalid call ID %q", args[0]), "Invalid argument")
+			}
+
+			db, cleanup, err := InitDB(dataDir)
+			if err != nil {
+				HandleError(err, "Failed to initialize database")
+			}
+			defer cleanup()
+
+			if err := RemoveSchoolCall(db, id); err != nil {
+				HandleError(err, "Failed to remove call")
+			}
+			fmt.Fprintf(os.Stderr, "Removed call %d\n", id)
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(callsCmd)
+	callsCmd.AddCommand(callsAddCmd, callsRemoveCmd)
+	callsCmd.Flags().StringVar(&callsSchool, "school", "", "Only list calls to this NCES school ID")
+	callsCmd.Flags().BoolVar(&callsTable, "table", false, "Print a table instead of JSON")
+	callsAddCmd.Flags().StringVar(&callsAt, "at", "", `When the call was made, "YYYY-MM-DD HH:MM" in local time (default now)`)
+}
+
+// printCallsTable writes logged calls as an aligned table, with notes on one line
+func printCallsTable(calls []SchoolCallJSON) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "ID\tSCHOOL\tPHONE\tCALLED\tNOTE")
+	for _, c := range calls {
+		_, _ = fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", c.ID, c.SchoolName, c.Phone, c.CalledAt, strings.Join(strings.Fields(c.Note), " "))
+	}
+	_ = w.Flush()
+}
+
+// ListSchoolCalls is set by main package; an empty ncessch lists every school
+var ListSchoolCalls func(db DBInterface, ncessch string) ([]SchoolCallJSON, error)
+
+// AddSchoolCall is set by main package; an empty calledAt is now
+var AddSchoolCall func(db DBInterface, ncessch, calledAt, note string) (*SchoolCallJSON, error)
+
+// RemoveSchoolCall is set by main package
+var RemoveSchoolCall func(db DBInterface, id int64) error
@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+// MatchCriteriaJSON represents the criteria a charter's matches had to meet
+type MatchCriteriaJSON struct {
+	Area                 string  `json:"area"` // district, city, or state
+	SameLevel            bool    `json:"same_level"`
+	SizeTolerancePct     float64 `json:"size_tolerance_pct"`    // 0 for any size
+	DemographicTolerance float64 `json:"demographic_tolerance"` // Percentage points; 0 when not matched on
+	Limit                int     `json:"limit"`
+}
+
+// MatchStepJSON represents one criterion applied and how many schools were left
+type MatchStepJSON struct {
+	Criterion string `json:"criterion"`
+	Remaining int    `json:"remaining"`
+}
+
+// SchoolMatchJSON represents a traditional public school matched to a charter
+type SchoolMatchJSON struct {
+	NCESSCH               string   `json:"ncessch"`
+	SchoolName            string   `json:"school_name"`
+	SizeDifferencePct     float64  `json:"size_difference_pct"`
+	DemographicDifference *float64 `json:"demographic_difference,omitempty"` // Percentage points
+}
+
+// CharterMatchJSON represents a charter school compared with matched traditional public schools
+type CharterMatchJSON struct {
+	Charter  ComparedSchoolJSON   `json:"charter"`
+	Criteria MatchCriteriaJSON    `json:"criteria"`
+	Steps    []MatchStepJSON      `json:"steps"`
+	Notes    []string             `json:"notes"`
+	Matches  []SchoolMatchJSON    `json:"matches"`
+	Schools  []ComparedSchoolJSON `json:"schools"` // The charter, then its matches, in the order of each row's values
+	Rows     []ComparisonRowJSON  `json:"rows"`
+}
+
+// MatchOptions are the adjustable matching criteria
+type MatchOptions struct {
+	Area                 string
+	AnyLevel             bool
+	SizeTolerancePct     float64
+	DemographicTolerance float64
+	Limit                int
+}
+
+var (
+	charterMatchOptions MatchOptions
+	charterMatchTable   bool
+	charterMatchCmd     = &cobra.Command{
+		Use:   "charter-match [charter-school-id]",
+		Short: "Compare a charter school with similar traditional public schools",
+		Long: `Find the traditional public schools most like a charter school and compare
+them side by side. Candidates are regular, non-charter schools in the charter's
+district, narrowed to its level, enrollment within --size percent, and a
+race/ethnicity composition within --demographics percentage points (the share
+of students who'd have to be in another group for the two to match; needs the
+full CCD membership file). The closest schools are compared field by field,
+from the directory and cached data, like compare.
+
+Every criterion is listed with how many schools were left after it. Charter
+schools are often their own district; use --area city or --area state to
+look further.
+
+Example:
+  schoolfinder charter-match 360000100004 --table
+  schoolfinder charter-match 360000100004 --area city --size 30 --demographics 0`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			db, cleanup, err := InitDB(dataDir)
+			if err != nil {
+				HandleError(err, "Failed to initialize database")
+			}
+			defer cleanup()
+
+			match, err := CharterMatch(db, args[0], charterMatchOptions)
+			if err != nil {
+				HandleError(err, "Failed to match charter school")
+			}
+			if charterMatchTable {
+				printCharterMatch(match)
+				return
+			}
+			printJSON(match)
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(charterMatchCmd)
+	charterMatchCmd.Flags().StringVar(&charterMatchOptions.Area, "area", "district", "Where to look for matches: district, city, or state")
+	charterMatchCmd.Flags().BoolVar(&charterMatchOptions.AnyLevel, "any-level", false, "Match schools at any level, not just the charter's")
+	charterMatchCmd.Flags().Float64Var(&charterMatchOptions.SizeTolerancePct, "size", 50, "Largest enrollment difference in percent; 0 for any size")
+	charterMatchCmd.Flags().Float64Var(&charterMatchOptions.DemographicTolerance, "demographics", 20, "Largest race/ethnicity difference in percentage points; 0 to ignore")
+	charterMatchCmd.Flags().IntVar(&charterMatchOptions.Limit, "limit", 3, "Most matched schools to compare (up to 5)")
+	charterMatchCmd.Flags().BoolVar(&charterMatchTable, "table", false, "Print the criteria and a comparison table instead of JSON")
+}
+
+// printCharterMatch writes the criteria funnel, then the comparison as columns
+func printCharterMatch(match *CharterMatchJSON) {
+	fmt.Printf("%s (%s)\n\n", match.Charter.SchoolName, match.Charter.NCESSCH)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "  CRITERION\tSCHOOLS LEFT")
+	for _, step := range match.Steps {
+		_, _ = fmt.Fprintf(w, "  %s\t%d\n", step.Criterion, step.Remaining)
+	}
+	_ = w.Flush()
+	for _, note := range match.Notes {
+		fmt.Printf("  Note: %s\n", note)
+	}
+	fmt.Println()
+	if len(match.Matches) == 0 {
+		fmt.Println("No traditional public schools meet the criteria; loosen --size or --demographics, or widen --area.")
+		return
+	}
+	printCompareTable(&SchoolComparisonJSON{Schools: match.Schools, Rows: match.Rows})
+}
+
+// CharterMatch is set by main package
+var CharterMatch func(db DBInterface, ncessch string, options MatchOptions) (*CharterMatchJSON, error)
@@ -0,0 +1,198 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+// ChildJSON represents a child the family is choosing a school for
+type ChildJSON struct {
+	ID           int64    `json:"id"`
+	Name         string   `json:"name"`
+	Grade        string   `json:"grade"`
+	GradeLabel   string   `json:"grade_label"`
+	Needs        []string `json:"needs,omitempty"`
+	SavedSchools []string `json:"saved_schools,omitempty"`
+}
+
+var (
+	childTable bool
+	childNeeds []string
+
+	childrenCmd = &cobra.Command{
+		Use:   "children",
+		Short: "Manage child profiles used to limit searches and keep per-child saved lists",
+		Long: `List the children you're choosing schools for, with the grade each is entering
+and their saved schools. With children added, "schoolfinder search --children" and
+the web search are limited to schools serving at least one child's grade, and
+schools that fit more than one child are flagged and listed first. Results are
+returned as JSON.
+
+Example:
+  schoolfinder children add Maya K --need IEP
+  schoolfinder children add Leo 6
+  schoolfinder children save 1 360000100001
+  schoolfinder children --table
+  schoolfinder search --children "Lincoln"`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			db, cleanup, err := InitDB(dataDir)
+			if err != nil {
+				HandleError(err, "Failed to initialize database")
+			}
+			defer cleanup()
+
+			children, err := ListChildren(db)
+			if err != nil {
+				HandleError(err, "Failed to load children")
+			}
+			if childTable {
+				printChildrenTable(children)
+				return
+			}
+			printJSON(children)
+		},
+	}
+
+	childrenAddCmd = &cobra.Command{
+		Use:   "add [name] [grade]",
+		Short: "Add a child, or update the grade and needs of one with the same name",
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			db, cleanup, err := InitDB(dataDir)
+			if err != nil {
+				HandleError(err, "Failed to initialize database")
+			}
+			defer cleanup()
+
+			child, err := SaveChild(db, args[0], args[1], childNeeds)
+			if err != nil {
+				HandleError(err, "Failed to save child")
+			}
+			printJSON(child)
+		},
+	}
+
+	childrenRemoveCmd = &cobra.Command{
+		Use:   "remove [child-id]",
+		Short: "Remove a child and their saved schools",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			id := parseChildID(args[0])
+
+			db, cleanup, err := InitDB(dataDir)
+			if err != nil {
+				HandleError(err, "Failed to initialize database")
+			}
+			defer cleanup()
+
+			if err := RemoveChild(db, id); err != nil {
+				HandleError(err, "Failed to remove child")
+			}
+			fmt.Fprintf(os.Stderr, "Removed child %d\n", id)
+		},
+	}
+
+	childrenSaveCmd = &cobra.Command{
+		Use:   "save [child-id] [school-id]",
+		Short: "Add a school to a child's saved list",
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			setChildSchool(args, true)
+		},
+	}
+
+	childrenUnsaveCmd = &cobra.Command{
+		Use:   "unsave [child-id] [school-id]",
+		Short: "Remove a school from a child's saved list",
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			setChildSchool(args, false)
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(childrenCmd)
+	childrenCmd.AddCommand(childrenAddCmd, childrenRemoveCmd, childrenSaveCmd, childrenUnsaveCmd)
+	childrenCmd.Flags().BoolVar(&childTable, "table", false, "Print a table instead of JSON")
+	childrenAddCmd.Flags().StringSliceVar(&childNeeds, "need", nil, "Needs to note, e.g. IEP,\"English learner\"")
+}
+
+func parseChildID(arg string) int64 {
+	id, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil {
+		HandleError(fmt.Errorf("invalid child ID %q", arg), "Invalid argument")
+	}
+	return id
+}
+
+// setChildSchool saves or unsaves the school in args[1] for the child in args[0]
+func setChildSchool(args []string, saved bool) {
+	id := parseChildID(args[0])
+
+	db, cleanup, err := InitDB(dataDir)
+	if err != nil {
+		HandleError(err, "Failed to initialize database")
+	}
+	defer cleanup()
+
+	if err := SetChildSchool(db, id, args[1], saved); err != nil {
+		HandleError(err, "Failed to update saved schools")
+	}
+	if saved {
+		fmt.Fprintf(os.Stderr, "Saved %s for child %d\n", args[1], id)
+	} else {
+		fmt.Fprintf(os.Stderr, "Removed %s from child %d\n", args[1], id)
+	}
+}
+
+// printChildrenTable writes child profiles as an aligned table
+func printChildrenTable(children []ChildJSON) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "ID\tNAME\tGRADE\tNEEDS\tSAVED")
+	for _, c := range children {
+		_, _ = fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", c.ID, c.Name, c.GradeLabel, strings.Join(c.Needs, ", "), strings.Join(c.SavedSchools, ", "))
+	}
+	_ = w.Flush()
+}
+
+// ListChildren is set by main package
+var ListChildren func(db DBInterface) ([]ChildJSON, error)
+
+// SaveChild is set by main package; grade is a CCD code or a number, e.g. K or 6
+var SaveChild func(db DBInterface, name, grade string, needs []string) (*ChildJSON, error)
+
+// RemoveChild is set by main package
+var RemoveChild func(db DBInterface, id int64) error
+
+// SetChildSchool is set by main package
+var SetChildSchool func(db DBInterface, id int64, ncessch string, saved bool) error
+
+// SearchNeeds narrows a search to schools meeting a family's needs
+type SearchNeeds struct {
+	Query       string
+	State       string
+	Programs    []string // Program flags or needs, e.g. "iep" or "dual-language"
+	Care        string   // "before", "after", or "both"
+	Sector      string   // "early_childhood" or "k12"
+	PreK        bool     // Only schools offering pre-K
+	CTE         []string // CTE pathways or career clusters, any of which matches
+	Sports      []string // Sports teams, all of which must be fielded
+	Conference  string   // Athletic conference or league name contains
+	WithinMiles float64  // Straight-line distance from Near; 0 for any
+	Near        string   // An address or "lat,lon"; empty for the saved home
+	Language    string   // Language taught, e.g. "mandarin"
+	Immersion   bool     // Only immersion or dual-language programs
+	ForChildren bool     // Serving a child's grade and offering the programs their needs call for
+	Limit       int
+}
+
+// SearchWithNeeds is set by main package; it searches schools meeting the
+// needs and, for the children, ranks those that fit more children first
+var SearchWithNeeds func(db DBInterface, needs SearchNeeds) ([]SchoolData, error)
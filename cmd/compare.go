@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+// ComparedSchoolJSON identifies a school in a comparison
+type ComparedSchoolJSON struct {
+	NCESSCH    string `json:"ncessch"`
+	SchoolName string `json:"school_name"`
+}
+
+// ComparisonRowJSON represents one field compared across schools
+type ComparisonRowJSON struct {
+	Field   string   `json:"field"`
+	Values  []string `json:"values"` // One per school, in order; empty where a school has no data
+	Differs bool     `json:"differs"`
+}
+
+// SchoolComparisonJSON represents schools lined up field by field
+type SchoolComparisonJSON struct {
+	Schools []ComparedSchoolJSON `json:"schools"`
+	Rows    []ComparisonRowJSON  `json:"rows"`
+}
+
+// compareTableWidth is the widest a value is printed in --table before
+// it's cut short; the JSON has every value in full
+const compareTableWidth = 48
+
+var (
+	compareTable       bool
+	compareJSON        bool
+	compareDiffersOnly bool
+	compareCmd         = &cobra.Command{
+		Use:   "compare [school-id] [school-id...]",
+		Short: "Compare two or three schools field by field",
+		Long: `Line up two or three schools field by field: directory data, student/teacher
+ratios and where each school stands among its peers, cached NAEP averages,
+and cached website data (from scrape). Nothing is fetched, so it works
+offline; fields no school has data for are left out. Results are returned
+as JSON, or with --table, as columns with rows that differ marked "*".
+
+Example:
+  schoolfinder compare 360000100001 360000100002 --table
+  schoolfinder compare 360000100001 360000100002 --table --differs`,
+		Args: cobra.MinimumNArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			db, cleanup, err := InitDB(dataDir)
+			if err != nil {
+				HandleError(err, "Failed to initialize database")
+			}
+			defer cleanup()
+
+			comparison, err := CompareSchools(db, args)
+			if err != nil {
+				HandleError(err, "Failed to compare schools")
+			}
+			if compareDiffersOnly {
+				rows := []ComparisonRowJSON{}
+				for _, row := range comparison.Rows {
+					if row.Differs {
+						rows = append(rows, row)
+					}
+				}
+				comparison.Rows = rows
+			}
+			if compareTable {
+				printCompareTable(comparison)
+				return
+			}
+			printJSON(comparison)
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(compareCmd)
+	compareCmd.Flags().BoolVar(&compareTable, "table", false, "Print a table instead of JSON")
+	compareCmd.Flags().BoolVar(&compareJSON, "json", false, "Print JSON (the default)")
+	compareCmd.Flags().BoolVar(&compareDiffersOnly, "differs", false, "Only show fields where the schools differ")
+	compareCmd.MarkFlagsMutuallyExclusive("table", "json")
+}
+
+// printCompareTable writes a comparison as aligned columns, one per school
+func printCompareTable(comparison *SchoolComparisonJSON) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	header := []string{"", "FIELD"}
+	ids := []string{"", ""}
+	for _, s := range comparison.Schools {
+		header = append(header, truncate(s.SchoolName, compareTableWidth))
+		ids = append(ids, s.NCESSCH)
+	}
+	_, _ = fmt.Fprintln(w, strings.Join(header, "\t"))
+	_, _ = fmt.Fprintln(w, strings.Join(ids, "\t"))
+	for _, row := range comparison.Rows {
+		marker := ""
+		if row.Differs {
+			marker = "*"
+		}
+		cells := []string{marker, row.Field}
+		for _, v := range row.Values {
+			if v == "" {
+				v = "—"
+			}
+			cells = append(cells, truncate(v, compareTableWidth))
+		}
+		_, _ = fmt.Fprintln(w, strings.Join(cells, "\t"))
+	}
+	_ = w.Flush()
+}
+
+// CompareSchools is set by main package
+var CompareSchools func(db DBInterface, ncesschList []string) (*SchoolComparisonJSON, error)
+
+// truncate cuts s to at most n characters, ending in "…" when cut
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
@@ -0,0 +1,233 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/spf13/cobra"
+)
+
+// ReindexJSON represents a rebuilt search index
+type ReindexJSON struct {
+	Schools          int     `json:"schools"`
+	CorrectedSchools int     `json:"corrected_schools"`
+	WebsiteText      int     `json:"schools_with_website_text"`
+	ImportedText     int     `json:"schools_with_imported_text"`
+	FullText         bool    `json:"full_text"`
+	Seconds          float64 `json:"seconds"`
+}
+
+// DataVersionJSON represents a CCD release file loaded into the database
+type DataVersionJSON struct {
+	Filename   string `json:"filename"`
+	Dataset    string `json:"dataset"`
+	SchoolYear string `json:"school_year"`
+	Released   string `json:"released"`
+	LoadedAt   string `json:"loaded_at"`
+	Rows       int64  `json:"rows"`
+}
+
+// CCDReleaseJSON represents a CCD release NCES has published
+type CCDReleaseJSON struct {
+	Filename   string `json:"filename"`
+	Dataset    string `json:"dataset"`
+	SchoolYear string `json:"school_year"`
+	Released   string `json:"released"`
+	URL        string `json:"url"`
+	Loadable   bool   `json:"loadable"` // Whether --fetch can load it
+}
+
+// CCDReleasesJSON represents a check for CCD releases newer than the loaded ones
+type CCDReleasesJSON struct {
+	CheckedAt string            `json:"checked_at"`
+	Summary   string            `json:"summary"`
+	New       []CCDReleaseJSON  `json:"new"`
+	Fetched   []DataVersionJSON `json:"fetched,omitempty"`
+}
+
+// GeocodeBackfillJSON represents a geocoding backfill of schools without coordinates
+type GeocodeBackfillJSON struct {
+	Provider    string  `json:"provider"`
+	Missing     int     `json:"missing"`
+	Cached      int     `json:"cached"`
+	Geocoded    int     `json:"geocoded"`
+	Approximate int     `json:"approximate"`
+	NotFound    int     `json:"not_found"`
+	Failed      int     `json:"failed"`
+	Located     int     `json:"located"`
+	Schools     int     `json:"schools"`
+	Coverage    float64 `json:"coverage_percent"`
+}
+
+// GeocodeOptions configures a geocoding backfill from the CLI
+type GeocodeOptions struct {
+	Provider string
+	Limit    int
+	Rate     float64
+	Retry    bool
+}
+
+var (
+	releasesFetch bool
+	geocodeOpts   GeocodeOptions
+	dbCmd         = &cobra.Command{
+		Use:   "db",
+		Short: "Maintain the local database",
+	}
+
+	reindexCmd = &cobra.Command{
+		Use:   "reindex",
+		Short: "Rebuild the search index",
+		Long: `Rebuild the search index from the school directory, corrected addresses,
+website text extracted with AI, and imported tables keyed by NCESSCH.
+
+The index is kept up to date as corrections are saved, websites are
+extracted, and files are imported, so this is only needed after changing
+tables by hand, e.g. from the Data Explorer or the query command. Without
+the DuckDB FTS extension, searches match the index without ranking.
+
+Example:
+  schoolfinder db reindex`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			db, cleanup, err := InitDB(dataDir)
+			if err != nil {
+				HandleError(err, "Failed to initialize database")
+			}
+			defer cleanup()
+
+			result, err := Reindex(db)
+			if err != nil {
+				HandleError(err, "Failed to rebuild the search index")
+			}
+			printJSON(result)
+		},
+	}
+
+	versionsCmd = &cobra.Command{
+		Use:   "versions",
+		Short: "List the CCD releases loaded, by school year",
+		Long: `List each CCD file loaded into the database: its dataset (directory,
+membership, or staff), school year, NCES release date, when it was loaded,
+and its row count. The same list is in the data_versions table.
+
+Past school years can be queried with query --as-of and notebook run --as-of.
+
+Example:
+  schoolfinder db versions`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			db, cleanup, err := InitDB(dataDir)
+			if err != nil {
+				HandleError(err, "Failed to initialize database")
+			}
+			defer cleanup()
+
+			versions, err := DataVersions(db)
+			if err != nil {
+				HandleError(err, "Failed to list data versions")
+			}
+			printJSON(versions)
+		},
+	}
+
+	releasesCmd = &cobra.Command{
+		Use:   "releases",
+		Short: "Check NCES for CCD releases newer than the loaded ones",
+		Long: `Check the NCES CCD file listing for school files newer than the loaded ones:
+a later school year, or a revised release of a loaded year.
+
+With --fetch, new directory and membership files are downloaded to the data
+directory and loaded as their own school year, alongside the current one.
+Searches and school pages keep showing the current year; the new year can be
+read with query --as-of and compared with diff-years. Staff files are listed
+but not fetched.
+
+The TUI checks weekly at startup (turn off with CCD_RELEASE_CHECK=0), and
+doctor reports the last check.
+
+Example:
+  schoolfinder db releases
+  schoolfinder db releases --fetch`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			db, cleanup, err := InitDB(dataDir)
+			if err != nil {
+				HandleError(err, "Failed to initialize database")
+			}
+			defer cleanup()
+
+			result, err := CCDReleases(db, releasesFetch)
+			if err != nil {
+				HandleError(err, "Failed to check for CCD releases")
+			}
+			fmt.Fprintln(os.Stderr, result.Summary)
+			printJSON(result)
+		},
+	}
+
+	geocodeCmd = &cobra.Command{
+		Use:   "geocode",
+		Short: "Geocode the addresses of schools without coordinates",
+		Long: `Locate the schools that NCES EDGE geocode files don't cover, from their
+mailing address (MSTREET1, MCITY, ST, MZIP), so maps, distances, and
+neighborhood reports include them.
+
+Every address is cached in the geocodes table, including those that weren't
+found, so each is only sent once; --retry sends the misses again. PO box
+addresses are placed by city and zip, and schools that still can't be placed
+get the average location of their zip code's other schools. Requests are
+spaced to --rate a second. Interrupting keeps everything found so far, and an
+EDGE file loaded later replaces geocoded locations.
+
+Providers: census (the default; the Census Bureau geocoder, street addresses
+only) and nominatim (OpenStreetMap, which also places cities and zip codes;
+its usage policy allows one request a second).
+
+Example:
+  schoolfinder db geocode --limit 100
+  schoolfinder db geocode --provider nominatim --retry`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			db, cleanup, err := InitDB(dataDir)
+			if err != nil {
+				HandleError(err, "Failed to initialize database")
+			}
+			defer cleanup()
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer stop()
+			result, err := GeocodeSchools(ctx, db, geocodeOpts)
+			if err != nil {
+				HandleError(err, "Failed to geocode schools")
+			}
+			fmt.Fprintf(os.Stderr, "Located %d of %d schools missing coordinates (%d cached, %d geocoded, %d by zip code); %d of %d schools (%.1f%%) now have coordinates\n",
+				result.Cached+result.Geocoded+result.Approximate, result.Missing, result.Cached, result.Geocoded, result.Approximate, result.Located, result.Schools, result.Coverage)
+			printJSON(result)
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(dbCmd)
+	dbCmd.AddCommand(reindexCmd, versionsCmd, releasesCmd, geocodeCmd)
+	releasesCmd.Flags().BoolVar(&releasesFetch, "fetch", false, "Download and load new directory and membership releases")
+	geocodeCmd.Flags().StringVar(&geocodeOpts.Provider, "provider", "census", "Geocoder: census or nominatim")
+	geocodeCmd.Flags().IntVar(&geocodeOpts.Limit, "limit", 0, "Geocode at most this many schools (0 for all)")
+	geocodeCmd.Flags().Float64Var(&geocodeOpts.Rate, "rate", 1, "Requests a second")
+	geocodeCmd.Flags().BoolVar(&geocodeOpts.Retry, "retry", false, "Geocode again addresses cached as not found or placed by zip code")
+}
+
+// Reindex is set by main package
+var Reindex func(db DBInterface) (*ReindexJSON, error)
+
+// DataVersions is set by main package
+var DataVersions func(db DBInterface) ([]DataVersionJSON, error)
+
+// CCDReleases is set by main package; with fetch, it loads the new releases
+var CCDReleases func(db DBInterface, fetch bool) (*CCDReleasesJSON, error)
+
+// GeocodeSchools is set by main package
+var GeocodeSchools func(ctx context.Context, db DBInterface, opts GeocodeOptions) (*GeocodeBackfillJSON, error)
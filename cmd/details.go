@@ -7,14 +7,22 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var detailsTemplate string
+
+// SchoolReport is set by main package; it renders a school's dossier with a
+// report template
+var SchoolReport func(db DBInterface, ncessch, templatePath string) (string, error)
+
 var detailsCmd = &cobra.Command{
 	Use:   "details [school-id]",
 	Short: "Get detailed information about a school",
 	Long: `Get detailed information about a specific school by NCESSCH ID.
-Returns school data as JSON.
+Returns school data as JSON, or with --template, a report from your own Go
+template (see docs/REPORT_TEMPLATES.md for the data it can use).
 
-Example:
-  schoolfinder details 060207001814`,
+Examples:
+  schoolfinder details 060207001814
+  schoolfinder details --template my_report.tmpl 060207001814 > report.txt`,
 	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		schoolID := args[0]
@@ -37,6 +45,15 @@ Example:
 			return
 		}
 
+		if detailsTemplate != "" {
+			report, err := SchoolReport(db, school.NCESSCH, detailsTemplate)
+			if err != nil {
+				HandleError(err, "Failed to render report")
+			}
+			fmt.Print(report)
+			return
+		}
+
 		// Convert to JSON output format
 		output, err := json.MarshalIndent(school, "", "  ")
 		if err != nil {
@@ -48,5 +65,6 @@ Example:
 }
 
 func init() {
+	detailsCmd.Flags().StringVar(&detailsTemplate, "template", "", "Render the school with this Go template instead of printing JSON")
 	rootCmd.AddCommand(detailsCmd)
 }
@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+// SchoolYearChangeJSON represents a school that opened, closed, was renamed, or changed NCES ID
+type SchoolYearChangeJSON struct {
+	NCESSCH         string `json:"ncessch"`
+	Name            string `json:"name"`
+	State           string `json:"state"`
+	City            string `json:"city"`
+	PreviousNCESSCH string `json:"previous_ncessch,omitempty"`
+	PreviousName    string `json:"previous_name,omitempty"`
+}
+
+// YearDiffJSON represents the school directory changes between two school years
+type YearDiffJSON struct {
+	FromYear  string                 `json:"from_year"`
+	ToYear    string                 `json:"to_year"`
+	FromCount int                    `json:"from_count"`
+	ToCount   int                    `json:"to_count"`
+	Opened    []SchoolYearChangeJSON `json:"opened"`
+	Closed    []SchoolYearChangeJSON `json:"closed"`
+	Renamed   []SchoolYearChangeJSON `json:"renamed"`
+	IDChanged []SchoolYearChangeJSON `json:"id_changed"`
+}
+
+var (
+	diffYearsTable bool
+	diffYearsCmd   = &cobra.Command{
+		Use:   "diff-years [from-year] [to-year]",
+		Short: "Report schools that opened, closed, or changed between school years",
+		Long: `Compare the CCD school directory for two school years and report schools
+that opened, closed, were renamed, or were assigned a new NCES ID. A school
+missing from one year is matched to the other year by address, then by name
+and city, so ID changes aren't reported as a closure plus an opening.
+
+Each year's directory file (ccd_sch_029_*.csv) must be in the data directory;
+files are loaded automatically the next time the database is opened.
+
+Example:
+  schoolfinder diff-years 2022-23 2023-24
+  schoolfinder diff-years 2022-23 2023-24 --table`,
+		Args: cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			db, cleanup, err := InitDB(dataDir)
+			if err != nil {
+				HandleError(err, "Failed to initialize database")
+			}
+			defer cleanup()
+
+			diff, err := DiffYears(db, args[0], args[1])
+			if err != nil {
+				HandleError(err, "Failed to compare school years")
+			}
+
+			if diffYearsTable {
+				printYearDiffTable(diff)
+				return
+			}
+
+			output, err := json.MarshalIndent(diff, "", "  ")
+			if err != nil {
+				HandleError(err, "Failed to encode JSON")
+			}
+			fmt.Println(string(output))
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(diffYearsCmd)
+	diffYearsCmd.Flags().BoolVar(&diffYearsTable, "table", false, "Print tables instead of JSON")
+}
+
+// printYearDiffTable writes each group of changes as an aligned table
+func printYearDiffTable(diff *YearDiffJSON) {
+	fmt.Printf("%s: %d schools, %s: %d schools\n", diff.FromYear, diff.FromCount, diff.ToYear, diff.ToCount)
+
+	groups := []struct {
+		title   string
+		changes []SchoolYearChangeJSON
+	}{
+		{"Opened", diff.Opened},
+		{"Closed", diff.Closed},
+		{"Renamed", diff.Renamed},
+		{"New NCES ID", diff.IDChanged},
+	}
+	for _, g := range groups {
+		fmt.Printf("\n%s (%d)\n", g.title, len(g.changes))
+		if len(g.changes) == 0 {
+			continue
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "NCESSCH\tNAME\tCITY\tSTATE\tPREVIOUS")
+		for _, c := range g.changes {
+			previous := c.PreviousName
+			if c.PreviousNCESSCH != "" {
+				previous = fmt.Sprintf("%s (%s)", c.PreviousName, c.PreviousNCESSCH)
+			}
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", c.NCESSCH, c.Name, c.City, c.State, previous)
+		}
+		_ = w.Flush()
+	}
+}
+
+// DiffYears is set by main package
+var DiffYears func(db DBInterface, from, to string) (*YearDiffJSON, error)
@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+// DoctorCheckJSON represents one doctor check
+type DoctorCheckJSON struct {
+	Name   string `json:"name"`
+	Status string `json:"status"` // ok, warn, or fail
+	Detail string `json:"detail"`
+	Hint   string `json:"hint,omitempty"`
+}
+
+// DoctorReportJSON represents the doctor checks
+type DoctorReportJSON struct {
+	Checks []DoctorCheckJSON `json:"checks"`
+	OK     bool              `json:"ok"` // No check failed
+}
+
+var (
+	doctorOffline bool
+	doctorTable   bool
+	doctorCmd     = &cobra.Command{
+		Use:   "doctor",
+		Short: "Check the data files, database, and setup",
+		Long: `Check that the CCD data files are present, the database opens, which CCD
+releases are loaded, whether NCES has published newer ones, and whether
+AI features are configured.
+
+Each check is ok, warn, or fail. doctor exits with status 1 if any check
+fails. --offline skips contacting NCES and reports the last saved release
+check instead.
+
+Example:
+  schoolfinder doctor --table
+  schoolfinder doctor --offline`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			report := RunDoctor(dataDir, doctorOffline)
+			if doctorTable {
+				printDoctorTable(report)
+			} else {
+				printJSON(report)
+			}
+			if !report.OK {
+				os.Exit(1)
+			}
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+	doctorCmd.Flags().BoolVar(&doctorOffline, "offline", false, "Don't check NCES for new CCD releases")
+	doctorCmd.Flags().BoolVar(&doctorTable, "table", false, "Print a table instead of JSON")
+}
+
+// printDoctorTable writes the doctor checks as an aligned table
+func printDoctorTable(report *DoctorReportJSON) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "CHECK\tSTATUS\tDETAIL")
+	for _, c := range report.Checks {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", c.Name, c.Status, c.Detail)
+		if c.Hint != "" {
+			_, _ = fmt.Fprintf(w, "\t\t→ %s\n", c.Hint)
+		}
+	}
+	_ = w.Flush()
+}
+
+// RunDoctor is set by main package
+var RunDoctor func(dataDir string, offline bool) *DoctorReportJSON
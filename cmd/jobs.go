@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+// JobJSON represents a recorded long-running job and how far it got
+type JobJSON struct {
+	ID        int64  `json:"id"`
+	Kind      string `json:"kind"`
+	Status    string `json:"status"`
+	Summary   string `json:"summary"`
+	Total     int    `json:"total"`
+	Done      int    `json:"done"`
+	Failed    int    `json:"failed"`
+	Pending   int    `json:"pending"`
+	Resumable bool   `json:"resumable"`
+	Error     string `json:"error,omitempty"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+var (
+	jobsTable       bool
+	jobsRetryFailed bool
+	jobsCmd         = &cobra.Command{
+		Use:   "jobs",
+		Short: "List long-running jobs and resume ones cut short",
+		Long: `List bulk saves (search --save-dir, and Ctrl+L in the TUI), most recent
+first, with how many schools each has saved, failed, or has yet to do.
+Results are returned as JSON.
+
+Each school's progress is recorded as it goes, so a save stopped by a crash or
+Ctrl+C can be finished with "jobs resume". A job still "running" that isn't
+was cut short.
+
+Example:
+  schoolfinder jobs --table
+  schoolfinder jobs resume 3
+  schoolfinder jobs resume 3 --retry-failed`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			db, cleanup, err := InitDB(dataDir)
+			if err != nil {
+				HandleError(err, "Failed to initialize database")
+			}
+			defer cleanup()
+
+			jobs, err := ListJobs(db)
+			if err != nil {
+				HandleError(err, "Failed to load jobs")
+			}
+			if jobsTable {
+				printJobsTable(jobs)
+				return
+			}
+			printJSON(jobs)
+		},
+	}
+
+	jobsResumeCmd = &cobra.Command{
+		Use:   "resume [job-id]",
+		Short: "Finish a job that was cut short",
+		Long: `Finish a bulk save that was cut short, saving the schools it hadn't saved
+yet (including any it was working on when it stopped) with the options it
+was started with, and rewrite its manifest. Schools that failed are tried
+again with --retry-failed, which also works on jobs that finished.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			id, err := strconv.ParseInt(args[0], 10, 64)
+			if err != nil {
+				HandleError(fmt.Errorf("invalid job ID %q", args[0]), "Invalid argument")
+			}
+
+			db, cleanup, err := InitDB(dataDir)
+			if err != nil {
+				HandleError(err, "Failed to initialize database")
+			}
+			defer cleanup()
+
+			summary, err := ResumeJob(db, id, jobsRetryFailed)
+			if err != nil {
+				HandleError(err, "Failed to resume job")
+			}
+			fmt.Fprintln(os.Stderr, summary)
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(jobsCmd)
+	jobsCmd.AddCommand(jobsResumeCmd)
+	jobsCmd.Flags().BoolVar(&jobsTable, "table", false, "Print a table instead of JSON")
+	jobsResumeCmd.Flags().BoolVar(&jobsRetryFailed, "retry-failed", false, "Also try again the schools that failed")
+}
+
+// printJobsTable writes jobs as an aligned table
+func printJobsTable(jobs []JobJSON) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "ID\tSTATUS\tDONE\tFAILED\tPENDING\tSTARTED\tJOB")
+	for _, j := range jobs {
+		_, _ = fmt.Fprintf(w, "%d\t%s\t%d/%d\t%d\t%d\t%s\t%s\n", j.ID, j.Status, j.Done, j.Total, j.Failed, j.Pending, j.CreatedAt, j.Summary)
+	}
+	_ = w.Flush()
+}
+
+// ListJobs is set by main package
+var ListJobs func(db DBInterface) ([]JobJSON, error)
+
+// ResumeJob is set by main package; it returns a one-line summary
+var ResumeJob func(db DBInterface, id int64, retryFailed bool) (string, error)
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	notebookOutput string
+	notebookAsOf   string
+	notebookCmd    = &cobra.Command{
+		Use:   "notebook",
+		Short: "Run reproducible notebooks of SQL and data explorer queries",
+		Long: `A notebook is a YAML or markdown file listing named queries: SQL to run as
+written, or questions for the data explorer agent (which needs
+ANTHROPIC_API_KEY). See docs/NOTEBOOKS.md for the format.
+
+Example:
+  schoolfinder notebook run analysis.yaml
+  schoolfinder notebook run analysis.md --out results/2026-10
+  schoolfinder notebook run analysis.yaml --as-of 2022-23`,
+	}
+
+	notebookRunCmd = &cobra.Command{
+		Use:   "run [notebook]",
+		Short: "Run a notebook's queries in order, saving CSVs and charts",
+		Long: `Run each of a notebook's queries in order. Each query's SQL, results as CSV,
+and a chart when the results are worth charting are saved to the results
+directory, numbered in notebook order, with run.json recording the data
+version, row counts, and a checksum of every CSV. The run stops at the first
+failing query.
+
+The results directory defaults to the notebook's name plus "-results", beside
+the notebook. --as-of runs the notebook's SQL against a past school year's
+data, as query --as-of does, overriding the notebook's own as_of.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			outDir := notebookOutput
+			if outDir == "" {
+				outDir = strings.TrimSuffix(args[0], filepath.Ext(args[0])) + "-results"
+			}
+
+			db, cleanup, err := InitDB(dataDir)
+			if err != nil {
+				HandleError(err, "Failed to initialize database")
+			}
+			defer cleanup()
+
+			summary, err := RunNotebook(db, args[0], outDir, notebookAsOf)
+			if err != nil {
+				HandleError(err, "Failed to run notebook")
+			}
+			fmt.Fprintln(os.Stderr, summary)
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(notebookCmd)
+	notebookCmd.AddCommand(notebookRunCmd)
+	notebookRunCmd.Flags().StringVarP(&notebookOutput, "out", "o", "", "Results directory (default: <notebook>-results)")
+	notebookRunCmd.Flags().StringVar(&notebookAsOf, "as-of", "", "Query a past school year's data, e.g. 2022-23")
+}
+
+// RunNotebook is set by main package; it returns a one-line summary of the run.
+// A non-empty asOf overrides the notebook's school year.
+var RunNotebook func(db DBInterface, path, outDir, asOf string) (string, error)
@@ -0,0 +1,199 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+// OutreachJSON represents an email to a school and whether it has replied
+type OutreachJSON struct {
+	ID          int64  `json:"id"`
+	NCESSCH     string `json:"ncessch"`
+	SchoolName  string `json:"school_name"`
+	To          string `json:"to,omitempty"`
+	Subject     string `json:"subject"`
+	Template    string `json:"template,omitempty"`
+	Status      string `json:"status"`
+	StatusLabel string `json:"status_label"`
+	SentOn      string `json:"sent_on"`
+	FollowUpOn  string `json:"follow_up_on,omitempty"`
+	FollowUpDue bool   `json:"follow_up_due"`
+	Note        string `json:"note,omitempty"`
+}
+
+// OutreachInput is an email to record, or the fields of one to change; empty
+// fields keep their defaults or current values
+type OutreachInput struct {
+	To         string
+	Subject    string
+	Template   string
+	Status     string
+	SentOn     string
+	FollowUpOn string
+	Note       string
+}
+
+var (
+	outreachSchool string
+	outreachTable  bool
+	outreachInput  OutreachInput
+	outreachCmd    = &cobra.Command{
+		Use:   "outreach",
+		Short: "Track emails to schools and whether they've replied",
+		Long: `List emails sent to schools, those awaiting a reply first by follow-up date.
+Results are returned as JSON. Emails awaiting a reply get a follow-up date a
+week after they're sent; "schoolfinder serve" sends a reminder when one comes
+due if OUTREACH_WEBHOOK_URL or OUTREACH_NOTIFY_EMAIL is set.
+
+Statuses: awaiting_reply, replied, no_reply, closed
+
+Example:
+  schoolfinder outreach add 360000100001 "Enrollment inquiry for 2027" --to enroll@sfusd.edu --template enrollment
+  schoolfinder outreach --table
+  schoolfinder outreach set 3 --status replied --note "Tours on Tuesdays"
+  schoolfinder outreach set 4 --follow-up 2026-11-02
+  schoolfinder outreach remove 3`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			db, cleanup, err := InitDB(dataDir)
+			if err != nil {
+				HandleError(err, "Failed to initialize database")
+			}
+			defer cleanup()
+
+			items, err := ListOutreach(db, outreachSchool)
+			if err != nil {
+				HandleError(err, "Failed to load outreach")
+			}
+			if outreachTable {
+				printOutreachTable(items)
+				return
+			}
+			printJSON(items)
+		},
+	}
+
+	outreachAddCmd = &cobra.Command{
+		Use:   "add [school-id] [subject]",
+		Short: "Record an email sent to a school",
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			db, cleanup, err := InitDB(dataDir)
+			if err != nil {
+				HandleError(err, "Failed to initialize database")
+			}
+			defer cleanup()
+
+			input := outreachInput
+			input.Subject = args[1]
+			item, err := AddOutreach(db, args[0], input)
+			if err != nil {
+				HandleError(err, "Failed to record outreach")
+			}
+			printJSON(item)
+		},
+	}
+
+	outreachSetCmd = &cobra.Command{
+		Use:   "set [outreach-id]",
+		Short: "Change an email's status, follow-up date, or note",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			id := parseOutreachID(args[0])
+			db, cleanup, err := InitDB(dataDir)
+			if err != nil {
+				HandleError(err, "Failed to initialize database")
+			}
+			defer cleanup()
+
+			// Only flags that were given change; --follow-up "" clears the date
+			var changed []string
+			for _, name := range []string{"status", "follow-up", "note"} {
+				if cmd.Flags().Changed(name) {
+					changed = append(changed, name)
+				}
+			}
+			if len(changed) == 0 {
+				HandleError(fmt.Errorf("nothing to change"), "Give --status, --follow-up, or --note")
+			}
+			item, err := SetOutreach(db, id, outreachInput, changed)
+			if err != nil {
+				HandleError(err, "Failed to update outreach")
+			}
+			printJSON(item)
+		},
+	}
+
+	outreachRemoveCmd = &cobra.Command{
+		Use:   "remove [outreach-id]",
+		Short: "Stop tracking an email",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			id := parseOutreachID(args[0])
+			db, cleanup, err := InitDB(dataDir)
+			if err != nil {
+				HandleError(err, "Failed to initialize database")
+			}
+			defer cleanup()
+
+			if err := RemoveOutreach(db, id); err != nil {
+				HandleError(err, "Failed to remove outreach")
+			}
+			fmt.Fprintf(os.Stderr, "Removed outreach %d\n", id)
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(outreachCmd)
+	outreachCmd.AddCommand(outreachAddCmd, outreachSetCmd, outreachRemoveCmd)
+	outreachCmd.Flags().StringVar(&outreachSchool, "school", "", "Only list emails to this NCES school ID")
+	outreachCmd.Flags().BoolVar(&outreachTable, "table", false, "Print a table instead of JSON")
+
+	outreachAddCmd.Flags().StringVar(&outreachInput.To, "to", "", "Address the email was sent to")
+	outreachAddCmd.Flags().StringVar(&outreachInput.Template, "template", "", "Inquiry template the email was drafted from")
+	outreachAddCmd.Flags().StringVar(&outreachInput.SentOn, "sent", "", "Date sent, YYYY-MM-DD (default today)")
+	for _, c := range []*cobra.Command{outreachAddCmd, outreachSetCmd} {
+		c.Flags().StringVar(&outreachInput.Status, "status", "", "awaiting_reply, replied, no_reply, or closed")
+		c.Flags().StringVar(&outreachInput.FollowUpOn, "follow-up", "", "Date to follow up if there's no reply, YYYY-MM-DD")
+		c.Flags().StringVar(&outreachInput.Note, "note", "", "What the school said, or other notes")
+	}
+}
+
+func parseOutreachID(arg string) int64 {
+	id, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil {
+		HandleError(fmt.Errorf("invalid outreach ID %q", arg), "Invalid argument")
+	}
+	return id
+}
+
+// printOutreachTable writes tracked emails as an aligned table, flagging those due a follow-up
+func printOutreachTable(items []OutreachJSON) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "ID\tSCHOOL\tSENT\tSUBJECT\tSTATUS\tFOLLOW UP")
+	for _, o := range items {
+		followUp := o.FollowUpOn
+		if o.FollowUpDue {
+			followUp += " (due)"
+		}
+		_, _ = fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n", o.ID, o.SchoolName, o.SentOn, o.Subject, o.StatusLabel, followUp)
+	}
+	_ = w.Flush()
+}
+
+// ListOutreach is set by main package; an empty ncessch lists every school
+var ListOutreach func(db DBInterface, ncessch string) ([]OutreachJSON, error)
+
+// AddOutreach is set by main package
+var AddOutreach func(db DBInterface, ncessch string, input OutreachInput) (*OutreachJSON, error)
+
+// SetOutreach is set by main package; only the named flags' fields change
+var SetOutreach func(db DBInterface, id int64, input OutreachInput, changed []string) (*OutreachJSON, error)
+
+// RemoveOutreach is set by main package
+var RemoveOutreach func(db DBInterface, id int64) error
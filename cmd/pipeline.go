@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+// PipelineStageJSON represents one step of a feeder pattern
+type PipelineStageJSON struct {
+	Stage      string `json:"stage"` // Elementary, Middle, or High
+	Grade      string `json:"grade"`
+	NCESSCH    string `json:"ncessch,omitempty"`
+	Name       string `json:"name,omitempty"`
+	Grades     string `json:"grades,omitempty"`
+	Source     string `json:"source,omitempty"` // boundary, feeder, or inferred
+	FeedsFrom  string `json:"feeds_from,omitempty"`
+	Confidence string `json:"confidence,omitempty"` // high, medium, or low, for inferred stages
+	Note       string `json:"note,omitempty"`
+}
+
+// FeederPipelineJSON represents the schools assigned to a location
+type FeederPipelineJSON struct {
+	Location string              `json:"location"`
+	Lat      float64             `json:"lat"`
+	Lon      float64             `json:"lon"`
+	Stages   []PipelineStageJSON `json:"stages"`
+}
+
+var (
+	pipelineTable bool
+	pipelineCmd   = &cobra.Command{
+		Use:   "pipeline [address]",
+		Short: "Show the elementary, middle, and high school assigned to an address",
+		Long: `Show the feeder pattern for an address: the assigned elementary, middle,
+and high school.
+
+Each stage uses the attendance boundary that contains the address, from NCES
+School Attendance Boundary Survey files converted to GeoJSON
+(SABS_*.geojson) in the data directory. Where no boundary covers a stage,
+the school the previous stage's school feeds into is used, from district
+feeder tables (FEEDERS_*.csv with FROM_NCESSCH and TO_NCESSCH columns), and
+then from feeder patterns inferred with AI by "scrape --feeders", which are
+labeled inferred with their confidence.
+
+The address is geocoded with the Census Bureau geocoder. Give latitude,longitude
+instead to skip geocoding, or no address to use the home location set with
+"transport home".
+
+Example:
+  schoolfinder pipeline "555 Franklin St, San Francisco, CA 94102"
+  schoolfinder pipeline 37.7793,-122.4193 --table`,
+		Args: cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			db, cleanup, err := InitDB(dataDir)
+			if err != nil {
+				HandleError(err, "Failed to initialize database")
+			}
+			defer cleanup()
+
+			pipeline, err := FeederPipeline(db, strings.Join(args, " "))
+			if err != nil {
+				HandleError(err, "Failed to find the schools serving the address")
+			}
+			if pipelineTable {
+				printPipelineTable(pipeline)
+			} else {
+				printJSON(pipeline)
+			}
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(pipelineCmd)
+	pipelineCmd.Flags().BoolVar(&pipelineTable, "table", false, "Print a table instead of JSON")
+}
+
+// printPipelineTable writes a feeder pattern as an aligned table
+func printPipelineTable(pipeline *FeederPipelineJSON) {
+	fmt.Println(pipeline.Location)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "STAGE\tNCESSCH\tSCHOOL\tGRADES\tSOURCE")
+	for _, s := range pipeline.Stages {
+		if s.NCESSCH == "" {
+			_, _ = fmt.Fprintf(w, "%s\t-\t%s\t\t\n", s.Stage, s.Note)
+			continue
+		}
+		source := s.Source
+		if s.Confidence != "" {
+			source += " (" + s.Confidence + " confidence)"
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", s.Stage, s.NCESSCH, s.Name, s.Grades, source)
+	}
+	_ = w.Flush()
+}
+
+// FeederPipeline is set by main package
+var FeederPipeline func(db DBInterface, address string) (*FeederPipelineJSON, error)
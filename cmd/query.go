@@ -7,7 +7,10 @@ import (
 	"github.com/spf13/cobra"
 )
 
-var queryString string
+var (
+	queryString string
+	queryAsOf   string
+)
 
 var queryCmd = &cobra.Command{
 	Use:   "query",
@@ -18,7 +21,17 @@ The query can be any valid DuckDB SQL query, including SELECT, DESCRIBE, SHOW TA
 Examples:
   schoolfinder query --sql "SELECT * FROM directory LIMIT 5"
   schoolfinder query --sql "SELECT COUNT(*) as total FROM directory"
-  schoolfinder query --sql "SHOW TABLES"`,
+  schoolfinder query --sql "SHOW TABLES"
+  schoolfinder query --as-of 2022-23 --sql "SELECT ST, count(*) FROM directory GROUP BY ST"
+
+With --as-of, the directory and enrollment tables hold that school year's
+data instead of the current year's, from the CCD files loaded for it (see
+schoolfinder db versions). Past years keep only names, addresses, districts,
+and total enrollment, and tables with only current data, such as teachers,
+can't be read.
+
+With --server, the query runs on the server with an editor's token, and must
+be a single SELECT (or SHOW, DESCRIBE, or SUMMARIZE) on its tables.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		if queryString == "" {
 			HandleError(fmt.Errorf("query is required"), "Missing query parameter")
@@ -37,6 +50,12 @@ Examples:
 			HandleError(fmt.Errorf("database does not support ExecuteQuery"), "Unsupported operation")
 		}
 
+		if queryAsOf != "" {
+			if queryString, err = AsOfQuery(db, queryString, queryAsOf); err != nil {
+				HandleError(err, "Failed to pin query to school year")
+			}
+		}
+
 		// Execute the query
 		rows, err := dbExt.ExecuteQuery(queryString)
 		if err != nil {
@@ -55,6 +74,10 @@ Examples:
 
 func init() {
 	queryCmd.Flags().StringVarP(&queryString, "sql", "q", "", "SQL query to execute (required)")
+	queryCmd.Flags().StringVar(&queryAsOf, "as-of", "", "Query a past school year's data, e.g. 2022-23")
 	_ = queryCmd.MarkFlagRequired("sql")
 	rootCmd.AddCommand(queryCmd)
 }
+
+// AsOfQuery is set by main package; it rewrites a query to read a school year's data
+var AsOfQuery func(db DBInterface, query, year string) (string, error)
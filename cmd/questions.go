@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+// TourQuestionJSON represents a suggested question to ask on a school tour
+type TourQuestionJSON struct {
+	Category string `json:"category"`
+	Question string `json:"question"`
+	Reason   string `json:"reason"`
+}
+
+// TourQuestionsJSON represents the full set of tour questions for a school
+type TourQuestionsJSON struct {
+	NCESSCH    string             `json:"ncessch"`
+	SchoolName string             `json:"school_name"`
+	Questions  []TourQuestionJSON `json:"questions"`
+	Markdown   string             `json:"markdown"`
+}
+
+var (
+	questionsMarkdown bool
+	questionsCmd      = &cobra.Command{
+		Use:   "questions [school-id]",
+		Short: "Generate questions to ask on a school tour",
+		Long: `Generate a tailored list of questions to ask on a school tour or at an open house.
+Questions are based on the school's data gaps and weak points, such as a high
+student/teacher ratio, declining NAEP trends, or programs missing from its website.
+
+Uses only cached NAEP and AI-extracted data; run the TUI, web UI, or scrape
+command first for richer questions.
+
+Example:
+  schoolfinder questions 060207001814
+  schoolfinder questions 060207001814 --markdown > tour.md`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			schoolID := args[0]
+
+			db, cleanup, err := InitDB(dataDir)
+			if err != nil {
+				HandleError(err, "Failed to initialize database")
+			}
+			defer cleanup()
+
+			questions, err := GenerateTourQuestions(db, schoolID)
+			if err != nil {
+				HandleError(err, "Failed to generate tour questions")
+			}
+
+			if questionsMarkdown {
+				fmt.Print(questions.Markdown)
+				return
+			}
+
+			output, err := json.MarshalIndent(questions, "", "  ")
+			if err != nil {
+				HandleError(err, "Failed to encode JSON")
+			}
+
+			fmt.Println(string(output))
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(questionsCmd)
+	questionsCmd.Flags().BoolVar(&questionsMarkdown, "markdown", false, "Output a markdown checklist instead of JSON")
+}
+
+// GenerateTourQuestions is set by main package
+var GenerateTourQuestions func(db DBInterface, ncessch string) (*TourQuestionsJSON, error)
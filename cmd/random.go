@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// SchoolPickJSON represents a randomly picked school and what stands out about it
+type SchoolPickJSON struct {
+	School       SchoolData `json:"school"`
+	Highlights   []string   `json:"highlights"`
+	QualityFlags []string   `json:"quality_flags"` // Data-quality flags on the school's record
+}
+
+var (
+	randomState string
+	randomLevel string
+	randomDaily bool
+	randomText  bool
+	randomCmd   = &cobra.Command{
+		Use:   "random",
+		Short: "Pick a random school and show its highlights",
+		Long: `Pick a random school, optionally in one state or at one level, and show
+what stands out about it: where its size and student/teacher ratio rank among
+its peers, a growing or shrinking enrollment, charter status, programs, and
+any data-quality flags on its record. Handy for exploring the data and for
+spot-checking it. With --daily, picks the school of the day the web search
+page shows, the same all day.
+
+Levels: Elementary, Middle, High, Secondary, Prekindergarten, Other
+
+Example:
+  schoolfinder random
+  schoolfinder random --state MT --level High --text`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			db, cleanup, err := InitDB(dataDir)
+			if err != nil {
+				HandleError(err, "Failed to initialize database")
+			}
+			defer cleanup()
+
+			pick, err := RandomSchool(db, randomState, randomLevel, randomDaily)
+			if err != nil {
+				HandleError(err, "Failed to pick a school")
+			}
+			if randomText {
+				printSchoolPick(pick)
+				return
+			}
+			printJSON(pick)
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(randomCmd)
+	randomCmd.Flags().StringVar(&randomState, "state", "", "Only pick from this state (two-letter code)")
+	randomCmd.Flags().StringVar(&randomLevel, "level", "", "Only pick schools at this level, e.g. High")
+	randomCmd.Flags().BoolVar(&randomDaily, "daily", false, "Pick the school of the day instead of a new school each run")
+	randomCmd.Flags().BoolVar(&randomText, "text", false, "Print a short summary instead of JSON")
+}
+
+// printSchoolPick writes a picked school as a few lines of text
+func printSchoolPick(pick *SchoolPickJSON) {
+	s := pick.School
+	fmt.Printf("%s (%s)\n", s.Name, s.NCESSCH)
+	fmt.Printf("%s, %s | %s\n", s.City, s.State, s.District)
+	var facts []string
+	if s.Level != nil {
+		facts = append(facts, *s.Level)
+	}
+	if s.GradeLow != nil && s.GradeHigh != nil {
+		facts = append(facts, "grades "+*s.GradeLow+"-"+*s.GradeHigh)
+	}
+	if s.Enrollment != nil {
+		facts = append(facts, fmt.Sprintf("%d students", *s.Enrollment))
+	}
+	if len(facts) > 0 {
+		fmt.Println(strings.Join(facts, " | "))
+	}
+	for _, h := range pick.Highlights {
+		fmt.Printf("  • %s\n", h)
+	}
+	for _, f := range pick.QualityFlags {
+		fmt.Printf("  ⚠ Data check: %s\n", f)
+	}
+}
+
+// RandomSchool is set by main package
+var RandomSchool func(db DBInterface, state, level string, daily bool) (*SchoolPickJSON, error)
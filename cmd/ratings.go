@@ -0,0 +1,167 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+// StateRatingSourceJSON represents a state whose report card ratings can be refreshed
+type StateRatingSourceJSON struct {
+	ID    string `json:"id"`
+	State string `json:"state"`
+	Name  string `json:"name"`
+}
+
+// StateRatingJSON represents one official rating a state published for a school
+type StateRatingJSON struct {
+	Source     string   `json:"source"`
+	SourceName string   `json:"source_name"`
+	SchoolYear string   `json:"school_year"`
+	Indicator  string   `json:"indicator"`
+	Rating     string   `json:"rating"`
+	Score      *float64 `json:"score,omitempty"`
+	SourceURL  string   `json:"source_url"`
+	FetchedAt  string   `json:"fetched_at"`
+}
+
+// StateRatingRefreshJSON represents one refresh of a source's ratings
+type StateRatingRefreshJSON struct {
+	Source      string   `json:"source"`
+	SchoolYear  string   `json:"school_year"`
+	Files       []string `json:"files"`
+	Ratings     int      `json:"ratings"`
+	Matched     int      `json:"matched"`
+	RefreshedAt string   `json:"refreshed_at"`
+}
+
+var (
+	ratingsYear         int
+	ratingsFiles        []string
+	ratingsHistoryTable bool
+
+	ratingsCmd = &cobra.Command{
+		Use:   "ratings [school-id]",
+		Short: "Show a school's state report card ratings",
+		Long: `Show the official report card ratings a school's state published, such as
+California School Dashboard colors or Texas A-F grades, with the file each came
+from and when it was fetched. Results are returned as JSON.
+
+Ratings are downloaded per state with "ratings refresh". Each state is a source
+in state_ratings_<state>.go; see "ratings sources" for those available.
+
+Example:
+  schoolfinder ratings sources
+  schoolfinder ratings refresh ca-dashboard --year 2024
+  schoolfinder ratings refresh tx-accountability --year 2024 --file ~/Downloads/camprate.csv
+  schoolfinder ratings history --table
+  schoolfinder ratings 360000100001`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			db, cleanup, err := InitDB(dataDir)
+			if err != nil {
+				HandleError(err, "Failed to initialize database")
+			}
+			defer cleanup()
+
+			ratings, err := SchoolStateRatings(db, args[0])
+			if err != nil {
+				HandleError(err, "Failed to load ratings")
+			}
+			printJSON(ratings)
+		},
+	}
+
+	ratingsSourcesCmd = &cobra.Command{
+		Use:   "sources",
+		Short: "List the states whose ratings can be refreshed",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			printJSON(ListStateRatingSources())
+		},
+	}
+
+	ratingsRefreshCmd = &cobra.Command{
+		Use:   "refresh [source]",
+		Short: "Download a state's ratings for a reporting year",
+		Long: `Download a state's ratings for a reporting year and replace that year's saved
+ratings. Ratings are matched to schools by the state school IDs in the CCD
+directory (ST_SCHID); unmatched ratings are counted and skipped. Use --file to
+read downloaded copies or other URLs instead of the source's default files.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			db, cleanup, err := InitDB(dataDir)
+			if err != nil {
+				HandleError(err, "Failed to initialize database")
+			}
+			defer cleanup()
+
+			refresh, err := RefreshStateRatings(context.Background(), db, args[0], ratingsYear, ratingsFiles)
+			if err != nil {
+				HandleError(err, "Failed to refresh ratings")
+			}
+			fmt.Fprintf(os.Stderr, "Saved %d of %d ratings for %s (%d not matched to a school)\n",
+				refresh.Matched, refresh.Ratings, refresh.SchoolYear, refresh.Ratings-refresh.Matched)
+			printJSON(refresh)
+		},
+	}
+
+	ratingsHistoryCmd = &cobra.Command{
+		Use:   "history",
+		Short: "List ratings refreshes",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			db, cleanup, err := InitDB(dataDir)
+			if err != nil {
+				HandleError(err, "Failed to initialize database")
+			}
+			defer cleanup()
+
+			refreshes, err := ListStateRatingRefreshes(db)
+			if err != nil {
+				HandleError(err, "Failed to load ratings refreshes")
+			}
+			if ratingsHistoryTable {
+				printRatingsHistoryTable(refreshes)
+				return
+			}
+			printJSON(refreshes)
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(ratingsCmd)
+	ratingsCmd.AddCommand(ratingsSourcesCmd, ratingsRefreshCmd, ratingsHistoryCmd)
+	ratingsRefreshCmd.Flags().IntVar(&ratingsYear, "year", 0, "Reporting year, e.g. 2024 for the 2023-24 school year (required)")
+	ratingsRefreshCmd.Flags().StringSliceVar(&ratingsFiles, "file", nil, "Local file or URL to read instead of the source's files (repeatable)")
+	_ = ratingsRefreshCmd.MarkFlagRequired("year")
+	ratingsHistoryCmd.Flags().BoolVar(&ratingsHistoryTable, "table", false, "Print a table instead of JSON")
+}
+
+// printRatingsHistoryTable writes ratings refreshes as an aligned table
+func printRatingsHistoryTable(refreshes []StateRatingRefreshJSON) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "SOURCE\tYEAR\tMATCHED\tREFRESHED\tFILES")
+	for _, r := range refreshes {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%d/%d\t%s\t%s\n", r.Source, r.SchoolYear, r.Matched, r.Ratings, r.RefreshedAt, strings.Join(r.Files, " "))
+	}
+	_ = w.Flush()
+}
+
+// SchoolStateRatings is set by main package
+var SchoolStateRatings func(db DBInterface, ncessch string) ([]StateRatingJSON, error)
+
+// ListStateRatingSources is set by main package
+var ListStateRatingSources func() []StateRatingSourceJSON
+
+// RefreshStateRatings is set by main package; files overrides the source's
+// default downloads
+var RefreshStateRatings func(ctx context.Context, db DBInterface, source string, year int, files []string) (*StateRatingRefreshJSON, error)
+
+// ListStateRatingRefreshes is set by main package
+var ListStateRatingRefreshes func(db DBInterface) ([]StateRatingRefreshJSON, error)
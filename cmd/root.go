@@ -9,16 +9,45 @@ import (
 )
 
 var (
-	dataDir string
-	rootCmd = &cobra.Command{
+	dataDir     string
+	serverURL   string
+	serverToken string
+	demoMode    bool
+	rootCmd     = &cobra.Command{
 		Use:   "schoolfinder",
 		Short: "School Finder - Search and explore school data",
 		Long: `School Finder is a CLI/TUI application for searching and exploring
 school data from the Common Core of Data (CCD).
 
 When run without commands, it launches an interactive TUI.
-Use subcommands for CLI mode with JSON output.`,
+Use subcommands for CLI mode with JSON output.
+
+With --server, CLI commands read from another School Finder web server's API
+instead of a local database. The TUI doesn't support --server yet; it always
+runs on a local database.
+
+With --demo, everything runs on a few sample schools with recorded NAEP and
+Claude responses, so no data download, API key, or network is needed.`,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if !demoMode {
+				return nil
+			}
+			if serverURL != "" {
+				return fmt.Errorf("--demo can't be used with --server")
+			}
+			dir, err := PrepareDemo()
+			if err != nil {
+				return fmt.Errorf("failed to prepare demo: %w", err)
+			}
+			dataDir = dir
+			return nil
+		},
 		Run: func(cmd *cobra.Command, args []string) {
+			if serverURL != "" {
+				fmt.Fprintln(os.Stderr, "Error: The TUI needs a local database.")
+				fmt.Fprintln(os.Stderr, "Hint: With --server, use the search, details, scrape, query, schema, summarize, and ask commands.")
+				os.Exit(1)
+			}
 			// No subcommand specified - launch TUI
 			LaunchTUI(dataDir)
 		},
@@ -27,6 +56,29 @@ Use subcommands for CLI mode with JSON output.`,
 
 func init() {
 	rootCmd.PersistentFlags().StringVarP(&dataDir, "data-dir", "d", "tmpdata/", "Directory containing CSV data files")
+	rootCmd.PersistentFlags().StringVar(&serverURL, "server", os.Getenv("SCHOOLFINDER_SERVER"), "URL of a School Finder web server for CLI commands to use instead of the local database (or SCHOOLFINDER_SERVER)")
+	rootCmd.PersistentFlags().StringVar(&serverToken, "token", "", "API token for --server (or SCHOOLFINDER_TOKEN, or auth set token)")
+	rootCmd.PersistentFlags().BoolVar(&demoMode, "demo", false, "Run on sample schools with recorded NAEP and Claude responses, without keys or network")
+}
+
+// PrepareDemo is set by main package. It sets up the demo and returns its data
+// directory.
+var PrepareDemo func() (string, error)
+
+// Demo reports whether --demo is set
+func Demo() bool {
+	return demoMode
+}
+
+// RemoteServer returns the server URL and API token set with --server and
+// --token, or their environment variable or stored secret. The URL is empty
+// for a local database.
+func RemoteServer() (url, token string) {
+	token = serverToken
+	if token == "" {
+		token = Secret("token")
+	}
+	return serverURL, token
 }
 
 // Execute runs the root command
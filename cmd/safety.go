@@ -0,0 +1,218 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+// SafetyValueJSON represents one school year's value of a safety measure
+type SafetyValueJSON struct {
+	Year  string  `json:"school_year"`
+	Value float64 `json:"value"`
+}
+
+// SafetyMetricJSON represents a safety measure across school years
+type SafetyMetricJSON struct {
+	Name   string            `json:"name"`
+	Values []SafetyValueJSON `json:"values"`
+	Change string            `json:"change,omitempty"`
+}
+
+// SafetySourceJSON represents a school's measures from one published report
+type SafetySourceJSON struct {
+	Source  string             `json:"source"`
+	Metrics []SafetyMetricJSON `json:"metrics"`
+}
+
+// SafetyJoinJSON represents how a safety file's rows were matched to schools
+type SafetyJoinJSON struct {
+	Column    string  `json:"column"`
+	KeyType   string  `json:"key_type"`
+	Matched   int     `json:"matched_rows"`
+	Rows      int     `json:"rows"`
+	MatchRate float64 `json:"match_rate"`
+}
+
+// SafetyImportOptions describes a safety report to import (matches main.SafetyImportOptions)
+type SafetyImportOptions struct {
+	Source    string
+	Year      string
+	State     string
+	KeyColumn string
+	KeyType   string
+	Filters   map[string]string
+	DryRun    bool
+}
+
+// SafetyImportJSON represents the result of importing a safety report
+type SafetyImportJSON struct {
+	Filename   string           `json:"filename"`
+	Join       SafetyJoinJSON   `json:"join"`
+	Candidates []SafetyJoinJSON `json:"candidates,omitempty"`
+	Metrics    []string         `json:"metrics"`
+	Years      []string         `json:"school_years"`
+	Values     int              `json:"values_saved"`
+	DryRun     bool             `json:"dry_run,omitempty"`
+}
+
+// SafetyFileJSON represents an imported safety report
+type SafetyFileJSON struct {
+	Filename string         `json:"filename"`
+	Source   string         `json:"source"`
+	Join     SafetyJoinJSON `json:"join"`
+	LoadedAt string         `json:"loaded_at"`
+}
+
+var (
+	safetyOpts       SafetyImportOptions
+	safetyFilters    []string
+	safetyFilesTable bool
+
+	safetyCmd = &cobra.Command{
+		Use:   "safety [school-id]",
+		Short: "Show a school's imported safety and discipline data",
+		Long: `Show a school's incident and discipline measures from imported state reports,
+by source and school year, with the change from the previous year. Results are
+returned as JSON.
+
+State reports aren't part of the NCES data: download them from your state's
+department of education and load them with "safety import". Each file's school
+key column is detected by matching its values to NCES school IDs, state school
+IDs (when the CCD directory has ST_SCHID), or exact school names.
+
+Example:
+  schoolfinder safety import suspensions_2223.csv --year 2022-23 --state CA \
+    --source "CDE Suspension Data 2022-23" --filter "Reporting Category=TA"
+  schoolfinder safety files --table
+  schoolfinder safety 360000100001`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			db, cleanup, err := InitDB(dataDir)
+			if err != nil {
+				HandleError(err, "Failed to initialize database")
+			}
+			defer cleanup()
+
+			safety, err := SchoolSafety(db, args[0])
+			if err != nil {
+				HandleError(err, "Failed to load safety data")
+			}
+			printJSON(safety)
+		},
+	}
+
+	safetyImportCmd = &cobra.Command{
+		Use:   "import [csv-file]",
+		Short: "Import a state-published incident or discipline report",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			safetyOpts.Filters = map[string]string{}
+			for _, f := range safetyFilters {
+				column, value, ok := strings.Cut(f, "=")
+				if !ok {
+					HandleError(fmt.Errorf("invalid filter %q (use COLUMN=VALUE)", f), "Invalid argument")
+				}
+				safetyOpts.Filters[column] = value
+			}
+
+			db, cleanup, err := InitDB(dataDir)
+			if err != nil {
+				HandleError(err, "Failed to initialize database")
+			}
+			defer cleanup()
+
+			result, err := ImportSafety(db, args[0], safetyOpts)
+			if err != nil {
+				if result != nil {
+					printJSON(result)
+				}
+				HandleError(err, "Failed to import safety data")
+			}
+			j := result.Join
+			fmt.Fprintf(os.Stderr, "Matched %d of %d rows by %q (%s, %.0f%%)\n", j.Matched, j.Rows, j.Column, j.KeyType, j.MatchRate*100)
+			printJSON(result)
+		},
+	}
+
+	safetyFilesCmd = &cobra.Command{
+		Use:   "files",
+		Short: "List imported safety reports",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			db, cleanup, err := InitDB(dataDir)
+			if err != nil {
+				HandleError(err, "Failed to initialize database")
+			}
+			defer cleanup()
+
+			files, err := ListSafetyFiles(db)
+			if err != nil {
+				HandleError(err, "Failed to load safety files")
+			}
+			if safetyFilesTable {
+				printSafetyFilesTable(files)
+				return
+			}
+			printJSON(files)
+		},
+	}
+
+	safetyRemoveCmd = &cobra.Command{
+		Use:   "remove [filename]",
+		Short: "Remove an imported safety report and its data",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			db, cleanup, err := InitDB(dataDir)
+			if err != nil {
+				HandleError(err, "Failed to initialize database")
+			}
+			defer cleanup()
+
+			if err := RemoveSafetyFile(db, args[0]); err != nil {
+				HandleError(err, "Failed to remove safety file")
+			}
+			fmt.Fprintf(os.Stderr, "Removed %s\n", args[0])
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(safetyCmd)
+	safetyCmd.AddCommand(safetyImportCmd, safetyFilesCmd, safetyRemoveCmd)
+	safetyImportCmd.Flags().StringVar(&safetyOpts.Source, "source", "", "Who published the report, e.g. its name or URL (required)")
+	safetyImportCmd.Flags().StringVar(&safetyOpts.Year, "year", "", "School year of a file without a year column, e.g. 2022-23")
+	safetyImportCmd.Flags().StringVar(&safetyOpts.State, "state", "", "Match only schools in this state (recommended for name matching)")
+	safetyImportCmd.Flags().StringVar(&safetyOpts.KeyColumn, "key-column", "", "Column holding the school key, instead of detecting it")
+	safetyImportCmd.Flags().StringVar(&safetyOpts.KeyType, "key-type", "", "Key column type: ncessch, state_id, or name")
+	safetyImportCmd.Flags().StringSliceVar(&safetyFilters, "filter", nil, "Import only rows where COLUMN=VALUE, e.g. a total for all students (repeatable)")
+	safetyImportCmd.Flags().BoolVar(&safetyOpts.DryRun, "dry-run", false, "Show the detected key column and measures without saving")
+	_ = safetyImportCmd.MarkFlagRequired("source")
+	safetyFilesCmd.Flags().BoolVar(&safetyFilesTable, "table", false, "Print a table instead of JSON")
+}
+
+// printSafetyFilesTable writes imported safety reports as an aligned table
+func printSafetyFilesTable(files []SafetyFileJSON) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "FILE\tMATCHED\tKEY\tSOURCE\tLOADED")
+	for _, f := range files {
+		_, _ = fmt.Fprintf(w, "%s\t%d/%d\t%s (%s)\t%s\t%s\n", f.Filename, f.Join.Matched, f.Join.Rows, f.Join.Column, f.Join.KeyType, f.Source, f.LoadedAt)
+	}
+	_ = w.Flush()
+}
+
+// SchoolSafety is set by main package
+var SchoolSafety func(db DBInterface, ncessch string) ([]SafetySourceJSON, error)
+
+// ImportSafety is set by main package; on a failed join it may return the
+// result so far along with the error, to show the candidate key columns
+var ImportSafety func(db DBInterface, path string, opts SafetyImportOptions) (*SafetyImportJSON, error)
+
+// ListSafetyFiles is set by main package
+var ListSafetyFiles func(db DBInterface) ([]SafetyFileJSON, error)
+
+// RemoveSafetyFile is set by main package
+var RemoveSafetyFile func(db DBInterface, filename string) error
@@ -3,6 +3,7 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 
 	"github.com/spf13/cobra"
 )
@@ -10,100 +11,134 @@ import (
 // SchemaOutput represents the schema information for a table
 type SchemaOutput struct {
 	TableName   string       `json:"table_name"`
+	Description string       `json:"description,omitempty"`
 	ColumnCount int          `json:"column_count"`
 	Columns     []ColumnInfo `json:"columns"`
 }
 
 // ColumnInfo represents information about a single column
 type ColumnInfo struct {
-	Name     string `json:"name"`
-	Type     string `json:"type"`
-	Nullable string `json:"nullable"`
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Nullable    string `json:"nullable"`
+	Description string `json:"description,omitempty"`
 }
 
-var schemaCmd = &cobra.Command{
-	Use:   "schema",
-	Short: "Retrieve a summary of the DuckDB database schema",
-	Long: `Retrieve a summary of the local DuckDB database schema.
-This command returns information about all tables and their columns in the database.
+var (
+	schemaTable bool
+	schemaCmd   = &cobra.Command{
+		Use:   "schema [table]",
+		Short: "Describe the tables and columns in the database",
+		Long: `Describe every table in the local DuckDB database, or only the named table,
+with each column's type and a description of what it holds. CCD columns are
+described from the NCES file layouts; imported tables are described from
+their import.
 
-Examples:
-  schoolfinder schema`,
-	Run: func(cmd *cobra.Command, args []string) {
-		// Initialize database
-		db, cleanup, err := InitDB(dataDir)
-		if err != nil {
-			HandleError(err, "Failed to initialize database")
-		}
-		defer cleanup()
+The same descriptions are shown at /docs/schema on the web server and given
+to the Data Explorer when it writes SQL.
 
-		// Get schema information for all tables
-		tables := []string{"directory", "teachers", "enrollment", "ai_scraper_cache", "naep_cache"}
-		schemas := make([]SchemaOutput, 0, len(tables))
+Examples:
+  schoolfinder schema
+  schoolfinder schema directory --table`,
+		Args: cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			// Initialize database
+			db, cleanup, err := InitDB(dataDir)
+			if err != nil {
+				HandleError(err, "Failed to initialize database")
+			}
+			defer cleanup()
 
-		for _, tableName := range tables {
-			schema, err := getTableSchema(db, tableName)
+			var table string
+			if len(args) > 0 {
+				table = args[0]
+			}
+			schemas, err := getSchema(db, table)
 			if err != nil {
-				// Skip tables that don't exist
-				continue
+				HandleError(err, "Failed to read the schema")
+			}
+			if table != "" && len(schemas) == 0 {
+				HandleError(fmt.Errorf("no table named %q", table), "Failed to read the schema")
 			}
-			schemas = append(schemas, schema)
-		}
 
-		// Convert to JSON output
-		output, err := json.MarshalIndent(schemas, "", "  ")
-		if err != nil {
-			HandleError(err, "Failed to encode JSON")
-		}
+			if schemaTable {
+				printSchemaTable(schemas)
+				return
+			}
 
-		fmt.Println(string(output))
-	},
-}
+			// Convert to JSON output
+			output, err := json.MarshalIndent(schemas, "", "  ")
+			if err != nil {
+				HandleError(err, "Failed to encode JSON")
+			}
+
+			fmt.Println(string(output))
+		},
+	}
+)
 
-// getTableSchema retrieves schema information for a specific table
-func getTableSchema(db DBInterface, tableName string) (SchemaOutput, error) {
+// getSchema retrieves the tables and columns in the database with their
+// descriptions, or only those of the named table
+func getSchema(db DBInterface, table string) ([]SchemaOutput, error) {
 	// Cast to the extended interface to access ExecuteQuery
 	dbExt, ok := db.(DBInterfaceExtended)
 	if !ok {
-		return SchemaOutput{}, fmt.Errorf("database does not support ExecuteQuery")
+		return nil, fmt.Errorf("database does not support ExecuteQuery")
 	}
 
-	query := fmt.Sprintf("PRAGMA table_info('%s')", tableName)
-	rows, err := dbExt.ExecuteQuery(query)
-	if err != nil {
-		return SchemaOutput{}, fmt.Errorf("failed to get schema for table %s: %w", tableName, err)
+	query := `
+		SELECT t.table_name, COALESCE(t.comment, '') AS table_comment, c.column_name, c.data_type,
+			CASE WHEN c.is_nullable THEN 'YES' ELSE 'NO' END AS nullable, COALESCE(c.comment, '') AS comment
+		FROM duckdb_tables() t
+		JOIN duckdb_columns() c ON c.table_oid = t.table_oid
+		WHERE t.schema_name = 'main' AND NOT t.internal`
+	if table != "" {
+		query += fmt.Sprintf(" AND t.table_name = '%s'", strings.ReplaceAll(table, "'", "''"))
 	}
-
-	schema := SchemaOutput{
-		TableName: tableName,
-		Columns:   []ColumnInfo{},
+	rows, err := dbExt.ExecuteQuery(query + " ORDER BY t.table_name, c.column_index")
+	if err != nil {
+		return nil, err
 	}
 
+	schemas := []SchemaOutput{}
 	for _, row := range rows {
-		// PRAGMA table_info returns: cid, name, type, notnull, dflt_value, pk
-		if len(row) >= 3 {
-			name, _ := row["name"].(string)
-			colType, _ := row["type"].(string)
-			notnull, _ := row["notnull"].(string)
-
-			nullable := "YES"
-			if notnull == "1" {
-				nullable = "NO"
-			}
-
-			schema.Columns = append(schema.Columns, ColumnInfo{
-				Name:     name,
-				Type:     colType,
-				Nullable: nullable,
-			})
+		name := fmt.Sprint(row["table_name"])
+		if len(schemas) == 0 || schemas[len(schemas)-1].TableName != name {
+			schemas = append(schemas, SchemaOutput{TableName: name, Description: fmt.Sprint(row["table_comment"])})
 		}
+		schema := &schemas[len(schemas)-1]
+		schema.Columns = append(schema.Columns, ColumnInfo{
+			Name:        fmt.Sprint(row["column_name"]),
+			Type:        fmt.Sprint(row["data_type"]),
+			Nullable:    fmt.Sprint(row["nullable"]),
+			Description: fmt.Sprint(row["comment"]),
+		})
+		schema.ColumnCount = len(schema.Columns)
 	}
+	return schemas, nil
+}
 
-	schema.ColumnCount = len(schema.Columns)
-
-	return schema, nil
+// printSchemaTable prints each table's description and columns
+func printSchemaTable(schemas []SchemaOutput) {
+	for i, s := range schemas {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Println(s.TableName)
+		if s.Description != "" {
+			fmt.Println("  " + s.Description)
+		}
+		width := 0
+		for _, c := range s.Columns {
+			width = max(width, len(c.Name)+len(c.Type)+1)
+		}
+		for _, c := range s.Columns {
+			fmt.Printf("  %-*s  %s\n", width, c.Name+" "+c.Type, c.Description)
+		}
+	}
 }
 
 func init() {
 	rootCmd.AddCommand(schemaCmd)
+	schemaCmd.Flags().BoolVar(&schemaTable, "table", false, "Print descriptions as text instead of JSON")
 }
@@ -3,24 +3,120 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
 
 	"github.com/spf13/cobra"
 )
 
+// DistrictContactsJSON represents contacts extracted from a district's website
+type DistrictContactsJSON struct {
+	LEAID            string         `json:"leaid"`
+	DistrictName     string         `json:"district_name"`
+	SourceURL        string         `json:"source_url"`
+	ExtractedAt      string         `json:"extracted_at"`
+	Superintendent   *StaffContact  `json:"superintendent,omitempty"`
+	BoardMembers     []StaffContact `json:"board_members,omitempty"`
+	EnrollmentOffice []StaffContact `json:"enrollment_office,omitempty"`
+	MarkdownContent  string         `json:"markdown_content"`
+}
+
+// InferredFeederJSON represents a school another school was inferred to feed into
+type InferredFeederJSON struct {
+	Name       string `json:"name"`
+	NCESSCH    string `json:"ncessch,omitempty"` // Empty when no directory school matches the name
+	Confidence string `json:"confidence"`        // high, medium, or low
+	Evidence   string `json:"evidence,omitempty"`
+	SourceURL  string `json:"source_url,omitempty"`
+}
+
+// FeederInferenceJSON represents the schools a school was inferred to feed into
+type FeederInferenceJSON struct {
+	NCESSCH    string               `json:"ncessch"`
+	SchoolName string               `json:"school_name"`
+	InferredAt string               `json:"inferred_at"`
+	Inferred   bool                 `json:"inferred"` // Always true: from AI, not boundary data
+	Feeders    []InferredFeederJSON `json:"feeders"`
+}
+
+// Exit codes for scrape, so scripts can tell a school with nothing to scrape
+// from a failure worth retrying later. Other failures exit with 1.
+const (
+	ExitNoWebsite = 2 // The school has no website on file
+	ExitUpstream  = 3 // A school website, the Claude API, or the server failed
+)
+
+// ScrapeErrorJSON reports a failed scrape on stdout with --json, so output
+// from many schools stays one JSON object per line
+type ScrapeErrorJSON struct {
+	ID       string `json:"id"`
+	Status   string `json:"status"` // no_website, upstream_error, or error
+	ExitCode int    `json:"exit_code"`
+	Error    string `json:"error"`
+	Hint     string `json:"hint,omitempty"`
+}
+
+// scrapeFieldAliases name groups of fields for --fields
+var scrapeFieldAliases = map[string][]string{
+	"contacts": {"staff_contacts", "main_office_email", "main_office_phone"},
+}
+
+var (
+	scrapeDistrict     bool
+	scrapeFeeders      bool
+	scrapeJSON         bool
+	scrapeFields       []string
+	scrapeForceRefresh bool
+)
+
 var scrapeCmd = &cobra.Command{
 	Use:   "scrape [school-id]",
 	Short: "Scrape enhanced data from school website using AI",
 	Long: `Scrape enhanced data from a school's website using Claude AI with web search.
 Extracts staff contacts, programs, facilities, and other information.
-Returns enhanced data as JSON.
+Returns enhanced data as JSON. Data extracted within the cache TTL is
+returned without calling Claude; --force-refresh extracts it again.
+
+--json prints one compact JSON object per school, and a failure as an object
+with its status and error, so a list of IDs can be piped through xargs.
+--fields keeps only the named fields (plus ncessch and school_name); use
+the JSON names, or "contacts" for staff contacts and the main office's email
+and phone.
+
+Exit codes: 0 on success, 2 when the school has no website on file, 3 when a
+school website, the Claude API, or the server failed (worth retrying later),
+and 1 otherwise.
+
+With --district, the ID is a district's NCES LEA ID, and the superintendent,
+school board members, and enrollment office contacts are extracted from the
+district's website instead. They're cached in the district_contacts table
+and shown on the district's web page.
+
+With --feeders, the school's and its district's websites are read for where
+the school's students go next ("Lincoln Elementary feeds into Jefferson
+Middle"). Each school found has a confidence level: high when an official
+page states it, medium when it's unofficial or implied, low when only
+suggested. They're cached in the inferred_feeders table and fill pipeline
+stages no attendance boundary or feeder table covers, labeled as inferred.
 
-Requires ANTHROPIC_API_KEY environment variable to be set.
+Requires an Anthropic API key: run schoolfinder auth set anthropic, or set
+ANTHROPIC_API_KEY.
 
 Example:
-  schoolfinder scrape 060207001814`,
+  schoolfinder scrape 060207001814
+  schoolfinder scrape 060207001814 --json --fields principal,contacts
+  cat ids.txt | xargs -n1 schoolfinder scrape --json > contacts.jsonl
+  schoolfinder scrape --district 0622710
+  schoolfinder scrape --feeders 060207001814`,
 	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		schoolID := args[0]
+		fields, err := expandScrapeFields(scrapeFields)
+		if err != nil {
+			HandleError(err, "Invalid --fields")
+		}
 
 		db, cleanup, err := InitDB(dataDir)
 		if err != nil {
@@ -28,35 +124,160 @@ Example:
 		}
 		defer cleanup()
 
-		school, err := db.GetSchoolByID(schoolID)
-		if err != nil {
-			HandleError(err, "Failed to get school details")
+		if scrapeDistrict {
+			contacts, err := ScrapeDistrict(db, schoolID)
+			if err != nil {
+				cleanup()
+				scrapeFailed(schoolID, err, "Failed to scrape district contacts")
+			}
+			printScrapeJSON(contacts)
+			return
 		}
 
-		if school == nil {
-			_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "No school found with ID: %s\n", schoolID)
+		if scrapeFeeders {
+			inference, err := ScrapeFeeders(db, schoolID)
+			if err != nil {
+				cleanup()
+				scrapeFailed(schoolID, err, "Failed to infer feeder schools")
+			}
+			printScrapeJSON(inference)
 			return
 		}
 
-		aiScraper, err := InitAIScraper(db)
+		enhancedData, err := ScrapeSchool(db, schoolID, scrapeForceRefresh)
 		if err != nil {
-			HandleError(err, "Failed to initialize AI scraper")
+			cleanup()
+			scrapeFailed(schoolID, err, "Failed to scrape school data")
 		}
 
-		enhancedData, err := aiScraper.ExtractSchoolDataWithWebSearch(school)
-		if err != nil {
-			HandleError(err, "Failed to scrape school data")
+		if len(fields) == 0 {
+			printScrapeJSON(enhancedData)
+			return
 		}
-
-		output, err := json.MarshalIndent(enhancedData, "", "  ")
+		selected, err := selectScrapeFields(enhancedData, fields)
 		if err != nil {
 			HandleError(err, "Failed to encode JSON")
 		}
-
-		fmt.Println(string(output))
+		printScrapeJSON(selected)
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(scrapeCmd)
+	scrapeCmd.Flags().BoolVar(&scrapeDistrict, "district", false, "Extract district leadership and enrollment contacts for a district's LEA ID")
+	scrapeCmd.Flags().BoolVar(&scrapeFeeders, "feeders", false, "Infer which schools the school feeds into from school and district websites")
+	scrapeCmd.Flags().BoolVar(&scrapeJSON, "json", false, "Print compact JSON on one line, including failures")
+	scrapeCmd.Flags().StringSliceVar(&scrapeFields, "fields", nil, "Only include these fields, e.g. principal,contacts")
+	scrapeCmd.Flags().BoolVar(&scrapeForceRefresh, "force-refresh", false, "Extract again even if cached data is fresh")
+	scrapeCmd.MarkFlagsMutuallyExclusive("district", "feeders")
+	scrapeCmd.MarkFlagsMutuallyExclusive("district", "fields")
+	scrapeCmd.MarkFlagsMutuallyExclusive("feeders", "fields")
+	scrapeCmd.MarkFlagsMutuallyExclusive("district", "force-refresh")
+	scrapeCmd.MarkFlagsMutuallyExclusive("feeders", "force-refresh")
+}
+
+// printScrapeJSON prints v indented, or on one line with --json
+func printScrapeJSON(v any) {
+	if !scrapeJSON {
+		printJSON(v)
+		return
+	}
+	output, err := json.Marshal(v)
+	if err != nil {
+		HandleError(err, "Failed to encode JSON")
+	}
+	fmt.Println(string(output))
+}
+
+// scrapeFailed reports err and exits with the code for its kind of failure.
+// With --json the failure is printed to stdout as a ScrapeErrorJSON.
+func scrapeFailed(id string, err error, message string) {
+	code := 1
+	if ScrapeExitCode != nil {
+		code = ScrapeExitCode(err)
+	}
+	if !scrapeJSON {
+		HandleErrorCode(err, message, code)
+	}
+
+	result := ScrapeErrorJSON{ID: id, Status: "error", ExitCode: code, Error: fmt.Sprintf("%s: %v", message, err)}
+	switch code {
+	case ExitNoWebsite:
+		result.Status = "no_website"
+	case ExitUpstream:
+		result.Status = "upstream_error"
+	}
+	if DescribeError != nil {
+		if msg, hint, ok := DescribeError(err); ok {
+			result.Error, result.Hint = msg, hint
+		}
+	}
+	printScrapeJSON(result)
+	os.Exit(code)
 }
+
+// scrapeFieldNames lists the JSON field names of EnhancedSchoolDataJSON
+func scrapeFieldNames() []string {
+	t := reflect.TypeOf(EnhancedSchoolDataJSON{})
+	names := make([]string, 0, t.NumField())
+	for i := range t.NumField() {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		names = append(names, name)
+	}
+	return names
+}
+
+// expandScrapeFields resolves --fields into JSON field names, expanding
+// aliases, and fails on names that aren't fields
+func expandScrapeFields(requested []string) ([]string, error) {
+	valid := scrapeFieldNames()
+	var fields []string
+	for _, f := range requested {
+		f = strings.ToLower(strings.TrimSpace(f))
+		if f == "" {
+			continue
+		}
+		if alias, ok := scrapeFieldAliases[f]; ok {
+			fields = append(fields, alias...)
+			continue
+		}
+		if !slices.Contains(valid, f) {
+			return nil, fmt.Errorf("unknown field %q; use contacts or one of: %s", f, strings.Join(valid, ", "))
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// selectScrapeFields keeps only fields of data, plus the fields that
+// identify the school. Fields the school has no data for are left out.
+func selectScrapeFields(data *EnhancedSchoolDataJSON, fields []string) (map[string]json.RawMessage, error) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(encoded, &all); err != nil {
+		return nil, err
+	}
+	selected := map[string]json.RawMessage{"ncessch": all["ncessch"], "school_name": all["school_name"]}
+	for _, f := range fields {
+		if v, ok := all[f]; ok {
+			selected[f] = v
+		}
+	}
+	return selected, nil
+}
+
+// ScrapeSchool is set by main package
+var ScrapeSchool func(db DBInterface, ncessch string, forceRefresh bool) (*EnhancedSchoolDataJSON, error)
+
+// ScrapeExitCode is set by main package; it maps a scrape failure to
+// ExitNoWebsite, ExitUpstream, or 1
+var ScrapeExitCode func(err error) int
+
+// ScrapeFeeders is set by main package
+var ScrapeFeeders func(db DBInterface, ncessch string) (*FeederInferenceJSON, error)
+
+// ScrapeDistrict is set by main package
+var ScrapeDistrict func(db DBInterface, leaid string) (*DistrictContactsJSON, error)
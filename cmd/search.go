@@ -3,15 +3,51 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
+	"os"
+	"strings"
 
 	"github.com/spf13/cobra"
 )
 
 var (
-	stateFilter string
-	searchLimit int
+	stateFilter    string
+	searchLimit    int
+	searchChildren bool
+	searchPrograms []string
+	searchCare     string
+	searchSector   string
+	searchPreK     bool
+	searchCTE      []string
+	searchSports   []string
+	searchLeague   string
+	searchWithin   float64
+	searchNear     string
+	searchLanguage string
+	searchImmerse  bool
+
+	searchSaveDir     string
+	searchFormat      string
+	searchFetchNAEP   bool
+	searchFetchAI     bool
+	searchConcurrency int
+	searchTemplate    string
 )
 
+// SaveDossiersOptions says where and how search --save-dir writes dossiers
+type SaveDossiersOptions struct {
+	Dir         string
+	Markdown    bool   // Markdown notes instead of JSON
+	FetchNAEP   bool   // Fetch NAEP data for schools without it cached
+	FetchAI     bool   // Extract website data for schools without it cached
+	Concurrency int    // Schools enriched at once
+	Template    string // Report template file to write dossiers with, instead of JSON or notes
+	Search      string
+}
+
+// SaveDossiers is set by main package; it writes a dossier for each school,
+// and a manifest beside them, and returns a one-line summary
+var SaveDossiers func(db DBInterface, ids []string, opts SaveDossiersOptions) (string, error)
+
 var searchCmd = &cobra.Command{
 	Use:   "search [query]",
 	Short: "Search for schools",
@@ -21,10 +57,28 @@ Results are returned as JSON.
 Examples:
   schoolfinder search "Lincoln High"
   schoolfinder search --state CA "Lincoln"
-  schoolfinder search --limit 10 "Elementary"`,
+  schoolfinder search --limit 10 "Elementary"
+  schoolfinder search --children "Lincoln"
+  schoolfinder search --program iep --program dual-language "Elementary"
+  schoolfinder search --care after --state CA "Elementary"
+  schoolfinder search --prek --state CA "Elementary"
+  schoolfinder search --sector early_childhood --state CA ""
+  schoolfinder search --cte aviation --cte "health sciences" --state CA "High"
+  schoolfinder search --sport swimming --within 15 --near "123 Main St, Portland, OR" "High"
+  schoolfinder search --language mandarin --immersion --state WA "Elementary"
+  schoolfinder search --state CA --save-dir out/ "Lincoln"
+  schoolfinder search --save-dir notes/ --format markdown --fetch-naep "Lincoln"
+  schoolfinder search --save-dir reports/ --template district_report.md.tmpl "Lincoln"
+
+With --save-dir, each result's dossier (the file Ctrl+W saves in the TUI) is
+also written to the directory with a manifest.json listing them.`,
 	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		query := args[0]
+		format := strings.ToLower(searchFormat)
+		if format != "json" && format != "markdown" && format != "md" {
+			HandleError(fmt.Errorf("unknown format %q: use json or markdown", searchFormat), "Invalid --format")
+		}
 
 		// Initialize database
 		db, cleanup, err := InitDB(dataDir)
@@ -34,7 +88,30 @@ Examples:
 		defer cleanup()
 
 		// Search schools
-		schools, err := db.SearchSchools(query, stateFilter, searchLimit)
+		RecordUsage(db, "search")
+		var schools []SchoolData
+		if searchChildren || len(searchPrograms) > 0 || searchCare != "" || searchSector != "" || searchPreK || len(searchCTE) > 0 ||
+			len(searchSports) > 0 || searchLeague != "" || searchWithin > 0 || searchLanguage != "" || searchImmerse {
+			schools, err = SearchWithNeeds(db, SearchNeeds{
+				Query:       query,
+				State:       stateFilter,
+				Programs:    searchPrograms,
+				Care:        searchCare,
+				Sector:      searchSector,
+				PreK:        searchPreK,
+				CTE:         searchCTE,
+				Sports:      searchSports,
+				Conference:  searchLeague,
+				WithinMiles: searchWithin,
+				Near:        searchNear,
+				Language:    searchLanguage,
+				Immersion:   searchImmerse,
+				ForChildren: searchChildren,
+				Limit:       searchLimit,
+			})
+		} else {
+			schools, err = db.SearchSchools(query, stateFilter, searchLimit)
+		}
 		if err != nil {
 			HandleError(err, "Failed to search schools")
 		}
@@ -46,11 +123,49 @@ Examples:
 		}
 
 		fmt.Println(string(output))
+
+		if searchSaveDir != "" {
+			ids := make([]string, len(schools))
+			for i, s := range schools {
+				ids[i] = s.NCESSCH
+			}
+			summary, err := SaveDossiers(db, ids, SaveDossiersOptions{
+				Dir:         searchSaveDir,
+				Markdown:    format != "json",
+				FetchNAEP:   searchFetchNAEP,
+				FetchAI:     searchFetchAI,
+				Concurrency: searchConcurrency,
+				Template:    searchTemplate,
+				Search:      query,
+			})
+			if err != nil {
+				HandleError(err, "Failed to save dossiers")
+			}
+			fmt.Fprintln(os.Stderr, summary)
+		}
 	},
 }
 
 func init() {
 	searchCmd.Flags().StringVarP(&stateFilter, "state", "s", "", "Filter by state (e.g., CA, NY)")
 	searchCmd.Flags().IntVarP(&searchLimit, "limit", "l", 100, "Maximum number of results")
+	searchCmd.Flags().BoolVar(&searchChildren, "children", false, "Only schools serving a child profile's grade and needs, best fits first")
+	searchCmd.Flags().StringSliceVar(&searchPrograms, "program", nil, "Only schools offering a program: special_ed (or iep), gifted, dual_language, ib, or montessori")
+	searchCmd.Flags().StringVar(&searchCare, "care", "", "Only schools whose website data shows before care, after care, or both: before, after, or both")
+	searchCmd.Flags().StringVar(&searchSector, "sector", "", "Only schools in a sector: early_childhood (pre-K only) or k12")
+	searchCmd.Flags().BoolVar(&searchPreK, "prek", false, "Only schools offering pre-K, from CCD grades or pre-K locator files")
+	searchCmd.Flags().StringArrayVar(&searchCTE, "cte", nil, "Only schools whose website data lists a CTE pathway or career cluster, e.g. aviation or \"health sciences\" (repeat for any of several)")
+	searchCmd.Flags().StringArrayVar(&searchSports, "sport", nil, "Only schools whose website data lists a sports team, e.g. swimming (repeat to require several)")
+	searchCmd.Flags().StringVar(&searchLeague, "conference", "", "Only schools in an athletic conference or league whose name contains this")
+	searchCmd.Flags().Float64Var(&searchWithin, "within", 0, "Only schools within this many miles of --near (straight line, from EDGE geocode files)")
+	searchCmd.Flags().StringVar(&searchNear, "near", "", "Where --within is measured from: an address or lat,lon (default: the saved home)")
+	searchCmd.Flags().StringVar(&searchLanguage, "language", "", "Only schools whose website data lists this language, e.g. mandarin")
+	searchCmd.Flags().BoolVar(&searchImmerse, "immersion", false, "Only immersion or dual-language programs (in --language, if given)")
+	searchCmd.Flags().StringVar(&searchSaveDir, "save-dir", "", "Also save each result's dossier to this directory, with a manifest.json")
+	searchCmd.Flags().StringVar(&searchFormat, "format", "json", "Dossier format for --save-dir: json or markdown")
+	searchCmd.Flags().BoolVar(&searchFetchNAEP, "fetch-naep", false, "With --save-dir, fetch NAEP data for results without it cached")
+	searchCmd.Flags().BoolVar(&searchFetchAI, "fetch-ai", false, "With --save-dir, extract website data for results without it cached (requires ANTHROPIC_API_KEY)")
+	searchCmd.Flags().StringVar(&searchTemplate, "template", "", "With --save-dir, write each dossier with this Go template (see docs/REPORT_TEMPLATES.md)")
+	searchCmd.Flags().IntVar(&searchConcurrency, "concurrency", 4, "With --save-dir, how many results to enrich at once")
 	rootCmd.AddCommand(searchCmd)
 }
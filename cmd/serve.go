@@ -9,13 +9,18 @@ import (
 
 var (
 	port     int
+	devMode  bool
 	serveCmd = &cobra.Command{
 		Use:   "serve",
 		Short: "Start the web server",
 		Long: `Start the HTTP web server with HTMX interface.
 
 The web server provides a browser-based interface for searching and exploring
-school data, with the same functionality as the TUI plus API endpoints.`,
+school data, with the same functionality as the TUI plus API endpoints.
+
+Use --dev while working on templates: they reload on change without a restart,
+responses aren't cached, error pages include details, and each rendered
+template is logged.`,
 		Run: func(cmd *cobra.Command, args []string) {
 			runServe()
 		},
@@ -25,6 +30,7 @@ school data, with the same functionality as the TUI plus API endpoints.`,
 func init() {
 	rootCmd.AddCommand(serveCmd)
 	serveCmd.Flags().IntVarP(&port, "port", "p", 3000, "Port to run the server on")
+	serveCmd.Flags().BoolVar(&devMode, "dev", false, "Development mode: reload templates on change, disable caching, and show error details")
 }
 
 func runServe() {
@@ -37,13 +43,17 @@ func runServe() {
 
 	fmt.Printf("Starting School Finder web server...\n")
 	fmt.Printf("Data directory: %s\n", dataDir)
-	fmt.Printf("Port: %d\n\n", port)
+	fmt.Printf("Port: %d\n", port)
+	if devMode {
+		fmt.Printf("Development mode: on\n")
+	}
+	fmt.Println()
 
 	// Start the server (this will be implemented in main.go)
-	if err := StartServer(db, port, dataDir); err != nil {
+	if err := StartServer(db, port, dataDir, devMode); err != nil {
 		log.Fatalf("Server failed: %v\n", err)
 	}
 }
 
 // StartServer is set by main package
-var StartServer func(db DBInterface, port int, dataDir string) error
+var StartServer func(db DBInterface, port int, dataDir string, dev bool) error
@@ -28,37 +28,91 @@ type SchoolData struct {
 	GradeHigh   *string  `json:"grade_high,omitempty"`
 	CharterText *string  `json:"charter_text,omitempty"`
 	Enrollment  *int64   `json:"enrollment,omitempty"`
+
+	CorrectedFields []string `json:"corrected_fields,omitempty"` // Fields overridden by user corrections
+	FitsChildren    []string `json:"fits_children,omitempty"`    // Child profiles whose grade the school serves
 }
 
 // EnhancedSchoolDataJSON represents enhanced data from AI scraping
 type EnhancedSchoolDataJSON struct {
-	NCESSCH         string         `json:"ncessch"`
-	SchoolName      string         `json:"school_name"`
-	ExtractedAt     string         `json:"extracted_at"`
-	SourceURL       string         `json:"source_url"`
-	MarkdownContent string         `json:"markdown_content"`
-	Principal       string         `json:"principal,omitempty"`
-	VicePrincipals  []string       `json:"vice_principals,omitempty"`
-	Mascot          string         `json:"mascot,omitempty"`
-	SchoolColors    []string       `json:"school_colors,omitempty"`
-	Founded         string         `json:"founded,omitempty"`
-	StaffContacts   []StaffContact `json:"staff_contacts,omitempty"`
-	MainOfficeEmail string         `json:"main_office_email,omitempty"`
-	MainOfficePhone string         `json:"main_office_phone,omitempty"`
-	APCourses       []string       `json:"ap_courses,omitempty"`
-	Honors          []string       `json:"honors,omitempty"`
-	SpecialPrograms []string       `json:"special_programs,omitempty"`
-	Languages       []string       `json:"languages,omitempty"`
-	Sports          []string       `json:"sports,omitempty"`
-	Clubs           []string       `json:"clubs,omitempty"`
-	Arts            []string       `json:"arts,omitempty"`
-	Facilities      []string       `json:"facilities,omitempty"`
-	BellSchedule    string         `json:"bell_schedule,omitempty"`
-	SchoolHours     string         `json:"school_hours,omitempty"`
-	Achievements    []string       `json:"achievements,omitempty"`
-	Accreditations  []string       `json:"accreditations,omitempty"`
-	Mission         string         `json:"mission,omitempty"`
-	Notes           string         `json:"notes,omitempty"`
+	NCESSCH          string             `json:"ncessch"`
+	SchoolName       string             `json:"school_name"`
+	ExtractedAt      string             `json:"extracted_at"`
+	SourceURL        string             `json:"source_url"`
+	MarkdownContent  string             `json:"markdown_content"`
+	Principal        string             `json:"principal,omitempty"`
+	VicePrincipals   []string           `json:"vice_principals,omitempty"`
+	Mascot           string             `json:"mascot,omitempty"`
+	SchoolColors     []string           `json:"school_colors,omitempty"`
+	Founded          string             `json:"founded,omitempty"`
+	StaffContacts    []StaffContact     `json:"staff_contacts,omitempty"`
+	MainOfficeEmail  string             `json:"main_office_email,omitempty"`
+	MainOfficePhone  string             `json:"main_office_phone,omitempty"`
+	APCourses        []string           `json:"ap_courses,omitempty"`
+	Honors           []string           `json:"honors,omitempty"`
+	SpecialPrograms  []string           `json:"special_programs,omitempty"`
+	Languages        []string           `json:"languages,omitempty"`
+	CTEPathways      []CTEPathway       `json:"cte_pathways,omitempty"`
+	DualEnrollment   []DualEnrollment   `json:"dual_enrollment,omitempty"`
+	Sports           []string           `json:"sports,omitempty"`
+	Athletics        []SportOffering    `json:"athletics,omitempty"`
+	Conference       string             `json:"athletic_conference,omitempty"`
+	Clubs            []string           `json:"clubs,omitempty"`
+	Arts             []string           `json:"arts,omitempty"`
+	ArtsPrograms     []ArtsOffering     `json:"arts_programs,omitempty"`
+	LanguagePrograms []LanguageOffering `json:"language_programs,omitempty"`
+	ArtsCoverage     int                `json:"arts_coverage"` // Arts disciplines covered, out of 6
+	Facilities       []string           `json:"facilities,omitempty"`
+	BellSchedule     string             `json:"bell_schedule,omitempty"`
+	SchoolHours      string             `json:"school_hours,omitempty"`
+	BeforeCare       *CareProgram       `json:"before_care,omitempty"`
+	AfterCare        *CareProgram       `json:"after_care,omitempty"`
+	Achievements     []string           `json:"achievements,omitempty"`
+	Accreditations   []string           `json:"accreditations,omitempty"`
+	Mission          string             `json:"mission,omitempty"`
+	Notes            string             `json:"notes,omitempty"`
+}
+
+// CareProgram represents before- or after-school care published by a school
+type CareProgram struct {
+	Available bool   `json:"available"`
+	Hours     string `json:"hours,omitempty"`
+	Cost      string `json:"cost,omitempty"`
+	Provider  string `json:"provider,omitempty"`
+}
+
+// CTEPathway represents a career and technical education pathway published by a school
+type CTEPathway struct {
+	Name        string `json:"name"`
+	Cluster     string `json:"cluster,omitempty"`
+	Credentials string `json:"credentials,omitempty"`
+}
+
+// DualEnrollment represents a college credit partnership published by a school
+type DualEnrollment struct {
+	Partner string `json:"partner"`
+	Kind    string `json:"kind,omitempty"`
+	Details string `json:"details,omitempty"`
+}
+
+// SportOffering represents a team a school fields, normalized by sport, season, and level
+type SportOffering struct {
+	Sport  string `json:"sport"`
+	Season string `json:"season,omitempty"`
+	Level  string `json:"level,omitempty"`
+}
+
+// LanguageOffering represents a language a school teaches, as immersion or a course
+type LanguageOffering struct {
+	Language  string `json:"language"`
+	Immersion bool   `json:"immersion"`
+	Grades    string `json:"grades,omitempty"`
+}
+
+// ArtsOffering represents an arts or music program offered by a school
+type ArtsOffering struct {
+	Program    string `json:"program"`
+	Discipline string `json:"discipline,omitempty"`
 }
 
 // StaffContact represents staff contact information
@@ -93,10 +147,29 @@ var (
 	LaunchTUI     func(dataDir string)
 	InitDB        func(dataDir string) (DBInterface, func(), error)
 	InitAIScraper func(db DBInterface) (AIScraperInterface, error)
+
+	// DescribeError returns the user-facing message and remediation hint for err,
+	// with ok false when err has none
+	DescribeError func(err error) (message, hint string, ok bool)
 )
 
-// HandleError prints error and exits
+// HandleError prints error and exits. Errors with a user-facing message print it
+// and its hint instead of the raw error.
 func HandleError(err error, message string) {
+	HandleErrorCode(err, message, 1)
+}
+
+// HandleErrorCode prints error like HandleError and exits with code
+func HandleErrorCode(err error, message string, code int) {
+	if DescribeError != nil {
+		if msg, hint, ok := DescribeError(err); ok {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", msg)
+			if hint != "" {
+				fmt.Fprintf(os.Stderr, "Hint: %s\n", hint)
+			}
+			os.Exit(code)
+		}
+	}
 	fmt.Fprintf(os.Stderr, "Error: %s: %v\n", message, err)
-	os.Exit(1)
+	os.Exit(code)
 }
@@ -0,0 +1,263 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+// UsageStatsJSON represents whether usage tracking is on and the counts recorded
+type UsageStatsJSON struct {
+	Enabled         bool             `json:"enabled"`
+	ChangedAt       string           `json:"changed_at,omitempty"`
+	UploadedThrough string           `json:"uploaded_through,omitempty"`
+	Events          []UsageEventJSON `json:"events"`
+}
+
+// UsageEventJSON represents the recorded count of one usage event
+type UsageEventJSON struct {
+	Event      string `json:"event"`
+	Total      int64  `json:"total"`
+	Last30Days int64  `json:"last_30_days"`
+}
+
+// UsagePayloadJSON represents exactly what a usage upload sends
+type UsagePayloadJSON struct {
+	Schema  int              `json:"schema"`
+	Version string           `json:"version"`
+	From    string           `json:"from"`
+	To      string           `json:"to"`
+	Counts  map[string]int64 `json:"counts"`
+}
+
+// OverviewStatJSON represents the totals for the country, a state, a school level, or a district
+type OverviewStatJSON struct {
+	Key            string   `json:"key"`
+	Name           string   `json:"name"`
+	State          string   `json:"state,omitempty"`
+	Schools        int      `json:"schools"`
+	CharterSchools int      `json:"charter_schools"`
+	CharterShare   float64  `json:"charter_share"`
+	Students       int64    `json:"students"`
+	Ratio          *float64 `json:"student_teacher_ratio,omitempty"`
+}
+
+// StatsOverviewJSON represents the statewide and national statistics overview
+type StatsOverviewJSON struct {
+	ComputedAt string             `json:"computed_at"`
+	National   OverviewStatJSON   `json:"national"`
+	States     []OverviewStatJSON `json:"states"`
+	Levels     []OverviewStatJSON `json:"levels"`
+	Districts  []OverviewStatJSON `json:"largest_districts"`
+}
+
+var (
+	statsEnable  bool
+	statsDisable bool
+	statsUpload  bool
+	statsDryRun  bool
+	statsURL     string
+	statsTable   bool
+	statsCmd     = &cobra.Command{
+		Use:   "stats",
+		Short: "Show or change opt-in feature usage counts",
+		Long: `Show the feature usage counts recorded on this computer, or turn recording on or off.
+
+Usage tracking is off unless you turn it on. When on, School Finder counts how
+many searches, website scrapes, and data agent questions happen each day.
+Nothing else is recorded: no search terms, questions, school IDs, or anything
+that identifies you. The counts stay in the local database.
+
+Uploading is separate and never automatic. --upload sends the daily totals not
+sent before, summed into one set of numbers, to the URL given with --url or
+SCHOOLFINDER_TELEMETRY_URL, and prints exactly what was sent. Use --dry-run to
+see the payload without sending it. Turning tracking off deletes the counts.
+
+Example:
+  schoolfinder stats
+  schoolfinder stats --enable
+  schoolfinder stats --upload --dry-run
+  schoolfinder stats --disable`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if statsEnable && statsDisable {
+				HandleError(fmt.Errorf("--enable and --disable can't be used together"), "Invalid flags")
+			}
+
+			db, cleanup, err := InitDB(dataDir)
+			if err != nil {
+				HandleError(err, "Failed to initialize database")
+			}
+			defer cleanup()
+
+			if statsEnable || statsDisable {
+				if err := SetUsageTracking(db, statsEnable); err != nil {
+					HandleError(err, "Failed to change usage tracking")
+				}
+				if statsEnable {
+					fmt.Fprintln(os.Stderr, "Usage tracking is on: daily counts of searches, scrapes, and agent questions are kept locally.")
+				} else {
+					fmt.Fprintln(os.Stderr, "Usage tracking is off and recorded counts were deleted.")
+				}
+			}
+
+			if statsUpload {
+				url := statsURL
+				if url == "" {
+					url = os.Getenv("SCHOOLFINDER_TELEMETRY_URL")
+				}
+				payload, err := UploadUsage(db, url, statsDryRun)
+				if err != nil {
+					HandleError(err, "Failed to upload usage counts")
+				}
+				if payload == nil {
+					fmt.Fprintln(os.Stderr, "No new usage counts to upload.")
+					return
+				}
+				if statsDryRun {
+					fmt.Fprintf(os.Stderr, "Would send to %s:\n", url)
+				} else {
+					fmt.Fprintf(os.Stderr, "Sent to %s:\n", url)
+				}
+				printJSON(payload)
+				return
+			}
+
+			stats, err := UsageStats(db)
+			if err != nil {
+				HandleError(err, "Failed to load usage counts")
+			}
+			if statsTable {
+				printStatsTable(stats)
+				return
+			}
+			printJSON(stats)
+		},
+	}
+)
+
+var (
+	statsOverviewTable bool
+	statsOverviewCmd   = &cobra.Command{
+		Use:   "overview",
+		Short: "Show schools per state, charter share, ratios by level, and the largest districts",
+		Long: `Show statistics for the loaded school data: schools, charter share,
+enrollment, and the student/teacher ratio for the country and each state,
+ratios by school level, and the largest districts by enrollment.
+
+The figures are computed when the database is built, so they show instantly.
+Ratios pool the students and teachers of schools reporting both. The same
+figures are on the web UI's /stats page and in the overview_stats table.
+
+Example:
+  schoolfinder stats overview
+  schoolfinder stats overview --table`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			db, cleanup, err := InitDB(dataDir)
+			if err != nil {
+				HandleError(err, "Failed to initialize database")
+			}
+			defer cleanup()
+
+			overview, err := StatsOverview(db)
+			if err != nil {
+				HandleError(err, "Failed to load statistics")
+			}
+			if statsOverviewTable {
+				printStatsOverviewTable(overview)
+				return
+			}
+			printJSON(overview)
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(statsCmd)
+	statsCmd.AddCommand(statsOverviewCmd)
+	statsOverviewCmd.Flags().BoolVar(&statsOverviewTable, "table", false, "Print tables instead of JSON")
+	statsCmd.Flags().BoolVar(&statsEnable, "enable", false, "Turn on local usage tracking")
+	statsCmd.Flags().BoolVar(&statsDisable, "disable", false, "Turn off usage tracking and delete recorded counts")
+	statsCmd.Flags().BoolVar(&statsUpload, "upload", false, "Send daily totals not sent before and print them")
+	statsCmd.Flags().BoolVar(&statsDryRun, "dry-run", false, "With --upload, print the payload without sending it")
+	statsCmd.Flags().StringVar(&statsURL, "url", "", "Upload URL (default $SCHOOLFINDER_TELEMETRY_URL)")
+	statsCmd.Flags().BoolVar(&statsTable, "table", false, "Print a table instead of JSON")
+}
+
+func printJSON(v any) {
+	output, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		HandleError(err, "Failed to encode JSON")
+	}
+	fmt.Println(string(output))
+}
+
+// printStatsTable writes usage counts as an aligned table
+func printStatsTable(stats *UsageStatsJSON) {
+	if stats.Enabled {
+		fmt.Println("Usage tracking: on")
+	} else {
+		fmt.Println("Usage tracking: off (turn on with --enable)")
+	}
+	if stats.UploadedThrough != "" {
+		fmt.Printf("Uploaded through %s\n", stats.UploadedThrough)
+	}
+	fmt.Println()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "EVENT\tLAST 30 DAYS\tTOTAL")
+	for _, e := range stats.Events {
+		_, _ = fmt.Fprintf(w, "%s\t%d\t%d\n", e.Event, e.Last30Days, e.Total)
+	}
+	_ = w.Flush()
+}
+
+// printStatsOverviewTable writes the statistics overview as aligned tables
+func printStatsOverviewTable(overview *StatsOverviewJSON) {
+	ratio := func(s OverviewStatJSON) string {
+		if s.Ratio == nil {
+			return "N/A"
+		}
+		return fmt.Sprintf("%.1f", *s.Ratio)
+	}
+
+	n := overview.National
+	fmt.Printf("United States: %d schools, %d charter (%.1f%%), %d students, %s students per teacher\n",
+		n.Schools, n.CharterSchools, n.CharterShare, n.Students, ratio(n))
+	fmt.Printf("Computed %s\n\n", overview.ComputedAt)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "STATE\tSCHOOLS\tCHARTER %\tSTUDENTS\tRATIO")
+	for _, s := range overview.States {
+		_, _ = fmt.Fprintf(w, "%s\t%d\t%.1f\t%d\t%s\n", s.Key, s.Schools, s.CharterShare, s.Students, ratio(s))
+	}
+	_, _ = fmt.Fprintln(w)
+	_, _ = fmt.Fprintln(w, "LEVEL\tSCHOOLS\tRATIO")
+	for _, s := range overview.Levels {
+		_, _ = fmt.Fprintf(w, "%s\t%d\t%s\n", s.Name, s.Schools, ratio(s))
+	}
+	_, _ = fmt.Fprintln(w)
+	_, _ = fmt.Fprintln(w, "LEAID\tDISTRICT\tSTATE\tSTUDENTS\tSCHOOLS\tRATIO")
+	for _, s := range overview.Districts {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%s\n", s.Key, s.Name, s.State, s.Students, s.Schools, ratio(s))
+	}
+	_ = w.Flush()
+}
+
+// StatsOverview is set by main package
+var StatsOverview func(db DBInterface) (*StatsOverviewJSON, error)
+
+// UsageStats is set by main package
+var UsageStats func(db DBInterface) (*UsageStatsJSON, error)
+
+// SetUsageTracking is set by main package
+var SetUsageTracking func(db DBInterface, enabled bool) error
+
+// UploadUsage is set by main package; with dryRun it only builds the payload
+var UploadUsage func(db DBInterface, url string, dryRun bool) (*UsagePayloadJSON, error)
+
+// RecordUsage is set by main package; it counts a feature use if tracking is on
+var RecordUsage func(db DBInterface, event string)
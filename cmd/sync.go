@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+// SyncCountsJSON counts the rows a sync changed on one side
+type SyncCountsJSON struct {
+	AIScrapes int `json:"ai_scraper_cache"`
+	NAEP      int `json:"naep_cache"`
+	Favorites int `json:"favorites"` // Children's saved schools
+	Notes     int `json:"notes"`     // Call log entries and application notes
+}
+
+// SyncResultJSON represents what a sync with a peer changed on each side
+type SyncResultJSON struct {
+	Peer   string         `json:"peer"`
+	Since  string         `json:"since,omitempty"` // Empty for a full sync
+	Pulled SyncCountsJSON `json:"pulled"`          // Rows merged into this instance
+	Pushed SyncCountsJSON `json:"pushed"`          // Rows merged into the peer
+}
+
+var (
+	syncPeer      string
+	syncPeerToken string
+	syncFull      bool
+	syncText      bool
+	syncCmd       = &cobra.Command{
+		Use:   "sync",
+		Short: "Merge caches, saved schools, and notes with another instance",
+		Long: `Merge this instance's AI scraper and NAEP caches, children's saved schools,
+call log, and application notes with another School Finder instance, in both
+directions, so a laptop and a desktop stay in step.
+
+The peer is either the other instance's data directory (or its data.duckdb),
+for example on a shared or synced drive, or the URL of its web server. A
+database file can't be synced while schoolfinder is running on it; sync with
+its server instead, using an admin's API token; servers only offer sync when
+they have accounts or an admin password.
+
+Cached extractions keep whichever side extracted more recently, and
+application notes whichever side updated the application more recently.
+Saved schools and logged calls are added to whichever side is missing them;
+children are matched by name. Deletions aren't synced. After the first sync,
+only rows changed since the last sync with the peer are exchanged; --full
+exchanges everything.
+
+Example:
+  schoolfinder sync --peer /mnt/desktop/schoolfinder-data
+  schoolfinder sync --peer http://desktop.local:8080 --peer-token $TOKEN --text`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			token := syncPeerToken
+			if token == "" {
+				token = Secret("peer-token")
+			}
+
+			db, cleanup, err := InitDB(dataDir)
+			if err != nil {
+				HandleError(err, "Failed to initialize database")
+			}
+			defer cleanup()
+
+			result, err := SyncPeer(db, syncPeer, token, syncFull)
+			if err != nil {
+				HandleError(err, "Failed to sync")
+			}
+			if syncText {
+				printSyncResult(result)
+				return
+			}
+			printJSON(result)
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(syncCmd)
+	syncCmd.Flags().StringVar(&syncPeer, "peer", "", "The other instance's data directory, database file, or server URL")
+	syncCmd.Flags().StringVar(&syncPeerToken, "peer-token", "", "Admin API token for a peer server (or SCHOOLFINDER_PEER_TOKEN, or auth set peer-token)")
+	syncCmd.Flags().BoolVar(&syncFull, "full", false, "Exchange every row, not just those changed since the last sync")
+	syncCmd.Flags().BoolVar(&syncText, "text", false, "Print a short summary instead of JSON")
+	_ = syncCmd.MarkFlagRequired("peer")
+}
+
+// printSyncResult writes what a sync changed as a few lines of text
+func printSyncResult(r *SyncResultJSON) {
+	if r.Since == "" {
+		fmt.Printf("Synced everything with %s\n", r.Peer)
+	} else {
+		fmt.Printf("Synced changes since %s with %s\n", r.Since, r.Peer)
+	}
+	for _, side := range []struct {
+		label  string
+		counts SyncCountsJSON
+	}{{"Pulled", r.Pulled}, {"Pushed", r.Pushed}} {
+		c := side.counts
+		fmt.Printf("  %s: %d AI extractions, %d NAEP results, %d saved schools, %d notes\n", side.label, c.AIScrapes, c.NAEP, c.Favorites, c.Notes)
+	}
+}
+
+// SyncPeer is set by main package
+var SyncPeer func(db DBInterface, peer, token string, full bool) (*SyncResultJSON, error)
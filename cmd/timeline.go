@@ -0,0 +1,171 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+// SchoolDateJSON represents a key date in a school's application timeline
+type SchoolDateJSON struct {
+	ID         int64  `json:"id"`
+	NCESSCH    string `json:"ncessch"`
+	SchoolName string `json:"school_name"`
+	Type       string `json:"type"`
+	Label      string `json:"label"`
+	Date       string `json:"date"`
+	Note       string `json:"note,omitempty"`
+}
+
+var (
+	timelineSchool string
+	timelineTable  bool
+	timelineFormat string
+	timelineOutput string
+	timelineCmd    = &cobra.Command{
+		Use:   "timeline",
+		Short: "Track application season dates and export them to a calendar",
+		Long: `List the key dates recorded for schools, such as open houses, application
+deadlines, and lotteries, grouped by school and type. Results are returned as JSON.
+
+Date types: open_house, tour, application_deadline, lottery, decision,
+enrollment_deadline, other.
+
+Example:
+  schoolfinder timeline add 360000100001 application_deadline 2027-01-15 "Round 1"
+  schoolfinder timeline --table
+  schoolfinder timeline export --format ics -o applications.ics
+  schoolfinder timeline remove 3`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			db, cleanup, err := InitDB(dataDir)
+			if err != nil {
+				HandleError(err, "Failed to initialize database")
+			}
+			defer cleanup()
+
+			dates, err := ListSchoolDates(db, timelineSchool)
+			if err != nil {
+				HandleError(err, "Failed to load dates")
+			}
+			if timelineTable {
+				printTimelineTable(dates)
+				return
+			}
+			printJSON(dates)
+		},
+	}
+
+	timelineAddCmd = &cobra.Command{
+		Use:   "add [school-id] [type] [YYYY-MM-DD] [note]",
+		Short: "Record a key date for a school",
+		Args:  cobra.RangeArgs(3, 4),
+		Run: func(cmd *cobra.Command, args []string) {
+			db, cleanup, err := InitDB(dataDir)
+			if err != nil {
+				HandleError(err, "Failed to initialize database")
+			}
+			defer cleanup()
+
+			var note string
+			if len(args) == 4 {
+				note = args[3]
+			}
+			date, err := AddSchoolDate(db, args[0], args[1], args[2], note)
+			if err != nil {
+				HandleError(err, "Failed to add date")
+			}
+			printJSON(date)
+		},
+	}
+
+	timelineRemoveCmd = &cobra.Command{
+		Use:   "remove [date-id]",
+		Short: "Remove a key date by the ID shown in the timeline",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			id, err := strconv.ParseInt(args[0], 10, 64)
+			if err != nil {
+				HandleError(fmt.Errorf("invalid date ID %q", args[0]), "Invalid argument")
+			}
+
+			db, cleanup, err := InitDB(dataDir)
+			if err != nil {
+				HandleError(err, "Failed to initialize database")
+			}
+			defer cleanup()
+
+			if err := RemoveSchoolDate(db, id); err != nil {
+				HandleError(err, "Failed to remove date")
+			}
+			fmt.Fprintf(os.Stderr, "Removed date %d\n", id)
+		},
+	}
+
+	timelineExportCmd = &cobra.Command{
+		Use:   "export",
+		Short: "Export all key dates as an iCalendar (.ics) or CSV file",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			format := strings.ToLower(timelineFormat)
+			if format != "ics" && format != "csv" {
+				HandleError(fmt.Errorf("unknown format %q (use ics or csv)", timelineFormat), "Invalid flags")
+			}
+
+			db, cleanup, err := InitDB(dataDir)
+			if err != nil {
+				HandleError(err, "Failed to initialize database")
+			}
+			defer cleanup()
+
+			var w io.Writer = os.Stdout
+			if timelineOutput != "" {
+				f, err := os.Create(timelineOutput)
+				if err != nil {
+					HandleError(err, "Failed to create output file")
+				}
+				defer f.Close()
+				w = f
+			}
+			if err := ExportTimeline(db, w, format); err != nil {
+				HandleError(err, "Failed to export timeline")
+			}
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(timelineCmd)
+	timelineCmd.AddCommand(timelineAddCmd, timelineRemoveCmd, timelineExportCmd)
+	timelineCmd.Flags().StringVar(&timelineSchool, "school", "", "Only list dates for this NCES school ID")
+	timelineCmd.Flags().BoolVar(&timelineTable, "table", false, "Print a table instead of JSON")
+	timelineExportCmd.Flags().StringVarP(&timelineFormat, "format", "f", "ics", "Export format: ics or csv")
+	timelineExportCmd.Flags().StringVarP(&timelineOutput, "output", "o", "", "Write to a file instead of stdout")
+}
+
+// printTimelineTable writes key dates as an aligned table
+func printTimelineTable(dates []SchoolDateJSON) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "ID\tSCHOOL\tTYPE\tDATE\tNOTE")
+	for _, d := range dates {
+		_, _ = fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", d.ID, d.SchoolName, d.Label, d.Date, d.Note)
+	}
+	_ = w.Flush()
+}
+
+// ListSchoolDates is set by main package; an empty ncessch lists every school
+var ListSchoolDates func(db DBInterface, ncessch string) ([]SchoolDateJSON, error)
+
+// AddSchoolDate is set by main package
+var AddSchoolDate func(db DBInterface, ncessch, kind, date, note string) (*SchoolDateJSON, error)
+
+// RemoveSchoolDate is set by main package
+var RemoveSchoolDate func(db DBInterface, id int64) error
+
+// ExportTimeline is set by main package; format is "ics" or "csv"
+var ExportTimeline func(db DBInterface, w io.Writer, format string) error
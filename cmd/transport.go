@@ -0,0 +1,202 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+// BusRuleJSON represents a district's or state's bus eligibility rule
+type BusRuleJSON struct {
+	ID     int64   `json:"id"`
+	Scope  string  `json:"scope"`
+	Grades string  `json:"grades"`
+	Miles  float64 `json:"miles"`
+	Source string  `json:"source"`
+}
+
+// BusEstimateJSON represents an estimate of bus eligibility from home to a school
+type BusEstimateJSON struct {
+	NCESSCH       string      `json:"ncessch"`
+	SchoolName    string      `json:"school_name"`
+	Status        string      `json:"status"`
+	Estimate      bool        `json:"estimate"` // Always true: this is never a district decision
+	DistanceMiles float64     `json:"straight_line_miles"`
+	Grade         string      `json:"grade"`
+	Rule          BusRuleJSON `json:"rule"`
+	DefaultRule   bool        `json:"default_rule"`
+	Summary       string      `json:"summary"`
+	Note          string      `json:"note,omitempty"`
+}
+
+var (
+	busRuleGrades string
+	busRuleSource string
+	busHomeLabel  string
+	busRulesTable bool
+
+	transportCmd = &cobra.Command{
+		Use:   "transport [school-id]",
+		Short: "Estimate whether bus service likely covers your home for a school",
+		Long: `Estimate whether a school's bus service likely covers your home, from the
+straight-line distance and the district's or state's bus eligibility rule. Set
+your home with "transport home" first; school coordinates come from NCES EDGE
+geocode files in the data directory. Without a rule for the district or state,
+a common 1.5-mile walk zone is used and labeled as such. Results are returned as
+JSON.
+
+This is an estimate: districts measure the walking or driving route and make
+exceptions for hazards and programs, so confirm with the district.
+
+Example:
+  schoolfinder transport home 45.52,-122.68
+  schoolfinder transport add-rule CA 1.5 --grades K-5 --source "District policy BP 3541"
+  schoolfinder transport add-rule 0612345 2 --source "https://example.k12.ca.us/transportation"
+  schoolfinder transport rules --table
+  schoolfinder transport 360000100001`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			db, cleanup, err := InitDB(dataDir)
+			if err != nil {
+				HandleError(err, "Failed to initialize database")
+			}
+			defer cleanup()
+
+			estimate, err := EstimateBus(db, args[0])
+			if err != nil {
+				HandleError(err, "Failed to estimate bus eligibility")
+			}
+			printJSON(estimate)
+		},
+	}
+
+	transportHomeCmd = &cobra.Command{
+		Use:   "home [latitude,longitude]",
+		Short: "Set the home location distances are measured from",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			db, cleanup, err := InitDB(dataDir)
+			if err != nil {
+				HandleError(err, "Failed to initialize database")
+			}
+			defer cleanup()
+
+			home, err := SetHome(db, args[0], busHomeLabel)
+			if err != nil {
+				HandleError(err, "Failed to set home")
+			}
+			fmt.Fprintf(os.Stderr, "Home set to %s\n", home)
+		},
+	}
+
+	transportRulesCmd = &cobra.Command{
+		Use:   "rules",
+		Short: "List bus eligibility rules",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			db, cleanup, err := InitDB(dataDir)
+			if err != nil {
+				HandleError(err, "Failed to initialize database")
+			}
+			defer cleanup()
+
+			rules, err := ListBusRules(db)
+			if err != nil {
+				HandleError(err, "Failed to load bus rules")
+			}
+			if busRulesTable {
+				printBusRulesTable(rules)
+				return
+			}
+			printJSON(rules)
+		},
+	}
+
+	transportAddRuleCmd = &cobra.Command{
+		Use:   "add-rule [state-or-district-id] [miles]",
+		Short: "Add a rule: students living farther than miles from school are bused",
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			miles, err := strconv.ParseFloat(args[1], 64)
+			if err != nil {
+				HandleError(fmt.Errorf("invalid distance %q", args[1]), "Invalid argument")
+			}
+
+			db, cleanup, err := InitDB(dataDir)
+			if err != nil {
+				HandleError(err, "Failed to initialize database")
+			}
+			defer cleanup()
+
+			rule, err := AddBusRule(db, args[0], busRuleGrades, miles, busRuleSource)
+			if err != nil {
+				HandleError(err, "Failed to add bus rule")
+			}
+			printJSON(rule)
+		},
+	}
+
+	transportRemoveRuleCmd = &cobra.Command{
+		Use:   "remove-rule [rule-id]",
+		Short: "Remove a bus eligibility rule",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			id, err := strconv.ParseInt(args[0], 10, 64)
+			if err != nil {
+				HandleError(fmt.Errorf("invalid rule ID %q", args[0]), "Invalid argument")
+			}
+
+			db, cleanup, err := InitDB(dataDir)
+			if err != nil {
+				HandleError(err, "Failed to initialize database")
+			}
+			defer cleanup()
+
+			if err := RemoveBusRule(db, id); err != nil {
+				HandleError(err, "Failed to remove bus rule")
+			}
+			fmt.Fprintf(os.Stderr, "Removed bus rule %d\n", id)
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(transportCmd)
+	transportCmd.AddCommand(transportHomeCmd, transportRulesCmd, transportAddRuleCmd, transportRemoveRuleCmd)
+	transportHomeCmd.Flags().StringVar(&busHomeLabel, "label", "", "Name to show for the location, e.g. Home")
+	transportRulesCmd.Flags().BoolVar(&busRulesTable, "table", false, "Print a table instead of JSON")
+	transportAddRuleCmd.Flags().StringVar(&busRuleGrades, "grades", "", "Grade band the rule covers, e.g. K-5 (default all grades)")
+	transportAddRuleCmd.Flags().StringVar(&busRuleSource, "source", "", "Where the rule comes from, e.g. the policy name or URL (required)")
+	_ = transportAddRuleCmd.MarkFlagRequired("source")
+}
+
+// printBusRulesTable writes bus eligibility rules as an aligned table
+func printBusRulesTable(rules []BusRuleJSON) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "ID\tSCOPE\tGRADES\tMILES\tSOURCE")
+	for _, r := range rules {
+		_, _ = fmt.Fprintf(w, "%d\t%s\t%s\t%g\t%s\n", r.ID, r.Scope, r.Grades, r.Miles, r.Source)
+	}
+	_ = w.Flush()
+}
+
+// EstimateBus is set by main package; it estimates bus eligibility from the
+// saved home to a school
+var EstimateBus func(db DBInterface, ncessch string) (*BusEstimateJSON, error)
+
+// SetHome is set by main package; coordinates are "latitude,longitude", and it
+// returns the saved location for display
+var SetHome func(db DBInterface, coordinates, label string) (string, error)
+
+// ListBusRules is set by main package
+var ListBusRules func(db DBInterface) ([]BusRuleJSON, error)
+
+// AddBusRule is set by main package; scope is a state code or district LEAID
+// and grades an optional band such as "K-5"
+var AddBusRule func(db DBInterface, scope, grades string, miles float64, source string) (*BusRuleJSON, error)
+
+// RemoveBusRule is set by main package
+var RemoveBusRule func(db DBInterface, id int64) error
@@ -0,0 +1,353 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Compare basket limits
+const (
+	minCompareSchools = 2
+	maxCompareSchools = 3
+)
+
+// CompareDataPoint is a single citable fact about a school used in a comparison
+type CompareDataPoint struct {
+	ID     string `json:"id"`     // Citation key such as "A3"
+	School string `json:"school"` // School name
+	Label  string `json:"label"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+}
+
+// ComparisonNarrative is an AI-written "which is better for us?" comparison of schools
+type ComparisonNarrative struct {
+	NCESSCHs    []string           `json:"ncesschs"`
+	Priorities  string             `json:"priorities,omitempty"`
+	Narrative   string             `json:"narrative"` // Markdown with [A1]-style citations
+	DataPoints  []CompareDataPoint `json:"data_points"`
+	GeneratedAt time.Time          `json:"generated_at"`
+}
+
+// CompareSchoolInput bundles a school with whatever enrichment is cached for it
+type CompareSchoolInput struct {
+	School   *School
+	Enhanced *EnhancedSchoolData
+	NAEP     *NAEPData
+}
+
+// ValidateCompareCount checks that a comparison has an acceptable number of schools
+func ValidateCompareCount(n int) error {
+	if n < minCompareSchools || n > maxCompareSchools {
+		return fmt.Errorf("comparison needs %d to %d schools, got %d", minCompareSchools, maxCompareSchools, n)
+	}
+	return nil
+}
+
+// BuildCompareDataPoints flattens each school's facts into citable data points keyed A1, A2, B1...
+func BuildCompareDataPoints(inputs []CompareSchoolInput) []CompareDataPoint {
+	var points []CompareDataPoint
+
+	for i, in := range inputs {
+		letter := string(rune('A' + i))
+		s := in.School
+		n := 0
+		add := func(label, value, source string) {
+			if value == "" || value == "N/A" {
+				return
+			}
+			n++
+			points = append(points, CompareDataPoint{
+				ID:     fmt.Sprintf("%s%d", letter, n),
+				School: s.Name,
+				Label:  label,
+				Value:  value,
+				Source: source,
+			})
+		}
+
+		ccdSource := fmt.Sprintf("NCES CCD %s", s.SchoolYear)
+
+		// Academics, size, and logistics from CCD
+		add("Location", fmt.Sprintf("%s, %s", s.City, s.State), ccdSource)
+		add("District", s.District, ccdSource)
+		add("Grades", s.GradeRangeString(), ccdSource)
+		add("Level", s.LevelString(), ccdSource)
+		add("Charter", s.CharterString(), ccdSource)
+		add("Enrollment", s.EnrollmentString(), ccdSource)
+		add("Teachers (FTE)", s.TeachersString(), ccdSource)
+		add("Student/teacher ratio", s.StudentTeacherRatio(), ccdSource)
+
+		// Academics from NAEP (area averages, not school-specific)
+		if in.NAEP != nil {
+			useDistrict := len(in.NAEP.DistrictScores) > 0
+			jurisdiction := in.NAEP.State
+			if useDistrict {
+				jurisdiction = in.NAEP.District
+			}
+			for _, grade := range []int{4, 8} {
+				for _, subject := range []string{"mathematics", "reading"} {
+					score := in.NAEP.GetMostRecentScore(subject, grade, useDistrict)
+					if score == nil {
+						continue
+					}
+					add(fmt.Sprintf("NAEP grade %d %s (%s average)", grade, subject, jurisdiction),
+						fmt.Sprintf("%.0f%% proficient or above", score.AtProficient),
+						fmt.Sprintf("NAEP %d", score.Year))
+				}
+			}
+		}
+
+		// Programs from AI extraction
+		if in.Enhanced != nil {
+			source := "School website (AI-extracted " + in.Enhanced.ExtractedAt.Format("2006-01-02") + ")"
+			add("Special programs", strings.Join(in.Enhanced.SpecialPrograms, ", "), source)
+			add("AP courses", strings.Join(in.Enhanced.APCourses, ", "), source)
+			var languages []string
+			for _, o := range in.Enhanced.LanguageOfferings() {
+				languages = append(languages, o.Summary())
+			}
+			add("Languages", strings.Join(languages, ", "), source)
+			add("Sports", strings.Join(in.Enhanced.Sports, ", "), source)
+			add("Athletic conference", in.Enhanced.AthleticConference, source)
+			if coverage := in.Enhanced.ArtsCoverage(); len(coverage.Offerings) > 0 {
+				var programs []string
+				for _, o := range coverage.Offerings {
+					programs = append(programs, o.Program)
+				}
+				add("Arts", strings.Join(programs, ", "), source)
+				add("Arts coverage", coverage.Summary(), source)
+			}
+			var pathways, partners []string
+			for _, p := range in.Enhanced.CTEPathways {
+				pathways = append(pathways, p.Name)
+			}
+			for _, p := range in.Enhanced.DualEnrollment {
+				partners = append(partners, p.Partner)
+			}
+			add("CTE pathways", strings.Join(pathways, ", "), source)
+			add("Dual enrollment", strings.Join(partners, ", "), source)
+			add("School hours", in.Enhanced.SchoolHours, source)
+			if in.Enhanced.BeforeCare != nil {
+				add("Before care", in.Enhanced.BeforeCare.Summary(), source)
+			}
+			if in.Enhanced.AfterCare != nil {
+				add("After care", in.Enhanced.AfterCare.Summary(), source)
+			}
+			if in.Enhanced.MarkdownContent != "" {
+				add("Website notes", truncateString(in.Enhanced.MarkdownContent, 3000), source)
+			}
+		}
+	}
+
+	return points
+}
+
+// buildComparisonPrompt builds the prompt asking Claude to contrast schools using only cited data points
+func buildComparisonPrompt(inputs []CompareSchoolInput, points []CompareDataPoint, priorities string) string {
+	var b strings.Builder
+
+	b.WriteString("You are helping a family choose between schools. Compare the schools below using ONLY the numbered data points.\n\n")
+
+	b.WriteString("## Schools\n")
+	for i, in := range inputs {
+		b.WriteString(fmt.Sprintf("- School %c: %s (NCES ID %s)\n", 'A'+i, in.School.Name, in.School.NCESSCH))
+	}
+
+	b.WriteString("\n## Data Points\n")
+	for _, p := range points {
+		b.WriteString(fmt.Sprintf("[%s] %s - %s: %s (source: %s)\n", p.ID, p.School, p.Label, p.Value, p.Source))
+	}
+
+	if strings.TrimSpace(priorities) != "" {
+		b.WriteString("\n## Family Priorities\n")
+		b.WriteString(strings.TrimSpace(priorities))
+		b.WriteString("\n")
+	}
+
+	b.WriteString(`
+## Instructions
+Write a markdown comparison titled "Which Is Better for Us?" with these sections:
+1. "### Academics", "### Size & Class Sizes", "### Programs", and "### Logistics" - contrast the schools in each, citing every fact with its bracketed key, e.g. [A3] or [B5].
+2. "### Bottom Line" - which school fits which kind of family, tied to the family priorities if given. Do not declare a single winner when the data is thin.
+3. "### What the Data Can't Tell You" - gaps worth investigating in person.
+4. "### Disclaimer" - state that this is an AI-generated summary of public data, that NAEP figures are state or district averages rather than school results, and that families should verify with the schools.
+
+Never state a fact without a citation, and never invent data points.`)
+
+	return b.String()
+}
+
+// GenerateComparisonNarrative asks Claude to contrast two or three schools with cited data points
+func (s *AIScraperService) GenerateComparisonNarrative(ctx context.Context, inputs []CompareSchoolInput, priorities string) (*ComparisonNarrative, error) {
+	if err := ValidateCompareCount(len(inputs)); err != nil {
+		return nil, err
+	}
+
+	points := BuildCompareDataPoints(inputs)
+	prompt := buildComparisonPrompt(inputs, points, priorities)
+
+	responseText, err := s.completeText(ctx, prompt, 4000)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to generate comparison narrative", "error", err, "schools", len(inputs))
+		}
+		return nil, err
+	}
+
+	ids := make([]string, len(inputs))
+	for i, in := range inputs {
+		ids[i] = in.School.NCESSCH
+	}
+
+	if logger != nil {
+		logger.Info("Generated comparison narrative", "ncesschs", strings.Join(ids, ","), "data_points", len(points))
+	}
+
+	return &ComparisonNarrative{
+		NCESSCHs:    ids,
+		Priorities:  priorities,
+		Narrative:   strings.TrimSpace(responseText),
+		DataPoints:  points,
+		GeneratedAt: time.Now(),
+	}, nil
+}
+
+// ComparisonRow is one field compared across schools, with each school's
+// value in the comparison's order. A value is empty where a school has no data.
+type ComparisonRow struct {
+	Field   string
+	Values  []string
+	Differs bool // The schools' values aren't all the same
+}
+
+// SchoolComparison lines up schools field by field for a quick side-by-side
+type SchoolComparison struct {
+	Schools []*School
+	Rows    []ComparisonRow
+}
+
+// CompareSchools lines up schools field by field: directory data, computed
+// metrics and percentiles among peers, cached NAEP averages, and cached
+// website data. Fields no school has a value for are left out.
+func CompareSchools(inputs []CompareSchoolInput, percentiles map[string]SchoolPercentiles) *SchoolComparison {
+	comparison := &SchoolComparison{}
+	for _, in := range inputs {
+		comparison.Schools = append(comparison.Schools, in.School)
+	}
+
+	add := func(field string, value func(in CompareSchoolInput) string) {
+		row := ComparisonRow{Field: field, Values: make([]string, len(inputs))}
+		found := false
+		for i, in := range inputs {
+			v := value(in)
+			if v == "N/A" {
+				v = ""
+			}
+			row.Values[i] = v
+			found = found || v != ""
+			row.Differs = row.Differs || v != row.Values[0]
+		}
+		if found {
+			comparison.Rows = append(comparison.Rows, row)
+		}
+	}
+	enhanced := func(value func(e *EnhancedSchoolData) string) func(in CompareSchoolInput) string {
+		return func(in CompareSchoolInput) string {
+			if in.Enhanced == nil {
+				return ""
+			}
+			return value(in.Enhanced)
+		}
+	}
+
+	// Directory data and what's computed from it
+	add("Location", func(in CompareSchoolInput) string { return fmt.Sprintf("%s, %s", in.School.City, in.School.State) })
+	add("District", func(in CompareSchoolInput) string { return in.School.District })
+	add("Grades", func(in CompareSchoolInput) string { return in.School.GradeRangeString() })
+	add("Level", func(in CompareSchoolInput) string { return in.School.LevelString() })
+	add("Type", func(in CompareSchoolInput) string { return in.School.SchoolTypeString() })
+	add("Charter", func(in CompareSchoolInput) string { return in.School.CharterString() })
+	add("Enrollment", func(in CompareSchoolInput) string { return in.School.EnrollmentString() })
+	add("Enrollment among peers", func(in CompareSchoolInput) string {
+		return percentiles[in.School.NCESSCH].Annotation(percentileEnrollment)
+	})
+	add("Teachers (FTE)", func(in CompareSchoolInput) string { return in.School.TeachersString() })
+	add("Teachers among peers", func(in CompareSchoolInput) string {
+		return percentiles[in.School.NCESSCH].Annotation(percentileTeachers)
+	})
+	add("Student/teacher ratio", func(in CompareSchoolInput) string { return in.School.StudentTeacherRatio() })
+	add("Ratio among peers", func(in CompareSchoolInput) string {
+		return percentiles[in.School.NCESSCH].Annotation(percentileRatio)
+	})
+	add("Phone", func(in CompareSchoolInput) string { return in.School.PhoneString() })
+	add("Website", func(in CompareSchoolInput) string { return in.School.WebsiteString() })
+
+	// NAEP averages for each school's district, or its state without one
+	for _, grade := range []int{4, 8} {
+		for _, subject := range []string{"mathematics", "reading"} {
+			add(fmt.Sprintf("NAEP grade %d %s", grade, subject), func(in CompareSchoolInput) string {
+				if in.NAEP == nil {
+					return ""
+				}
+				useDistrict := len(in.NAEP.DistrictScores) > 0
+				score := in.NAEP.GetMostRecentScore(subject, grade, useDistrict)
+				if score == nil {
+					return ""
+				}
+				return fmt.Sprintf("%.0f%% proficient or above (%s average, %d)", score.AtProficient, score.Jurisdiction, score.Year)
+			})
+		}
+	}
+
+	// Cached website data
+	add("Principal", enhanced(func(e *EnhancedSchoolData) string { return e.Principal }))
+	add("Special programs", enhanced(func(e *EnhancedSchoolData) string { return strings.Join(e.SpecialPrograms, ", ") }))
+	add("AP courses", enhanced(func(e *EnhancedSchoolData) string { return strings.Join(e.APCourses, ", ") }))
+	add("Languages", enhanced(func(e *EnhancedSchoolData) string {
+		var languages []string
+		for _, o := range e.LanguageOfferings() {
+			languages = append(languages, o.Summary())
+		}
+		return strings.Join(languages, ", ")
+	}))
+	add("Sports", enhanced(func(e *EnhancedSchoolData) string { return strings.Join(e.Sports, ", ") }))
+	add("Arts coverage", enhanced(func(e *EnhancedSchoolData) string {
+		if coverage := e.ArtsCoverage(); len(coverage.Offerings) > 0 {
+			return coverage.Summary()
+		}
+		return ""
+	}))
+	add("CTE pathways", enhanced(func(e *EnhancedSchoolData) string {
+		var pathways []string
+		for _, p := range e.CTEPathways {
+			pathways = append(pathways, p.Name)
+		}
+		return strings.Join(pathways, ", ")
+	}))
+	add("Dual enrollment", enhanced(func(e *EnhancedSchoolData) string {
+		var partners []string
+		for _, p := range e.DualEnrollment {
+			partners = append(partners, p.Partner)
+		}
+		return strings.Join(partners, ", ")
+	}))
+	add("School hours", enhanced(func(e *EnhancedSchoolData) string { return e.SchoolHours }))
+	add("Before care", enhanced(func(e *EnhancedSchoolData) string {
+		if e.BeforeCare == nil {
+			return ""
+		}
+		return e.BeforeCare.Summary()
+	}))
+	add("After care", enhanced(func(e *EnhancedSchoolData) string {
+		if e.AfterCare == nil {
+			return ""
+		}
+		return e.AfterCare.Summary()
+	}))
+	add("Website data extracted", enhanced(func(e *EnhancedSchoolData) string { return e.ExtractedAt.Format("2006-01-02") }))
+
+	return comparison
+}
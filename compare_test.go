@@ -0,0 +1,162 @@
+package main
+
+import (
+	"database/sql"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestValidateCompareCount tests the compare basket size limits
+func TestValidateCompareCount(t *testing.T) {
+	testCases := []struct {
+		count   int
+		wantErr bool
+	}{
+		{0, true},
+		{1, true},
+		{2, false},
+		{3, false},
+		{4, true},
+	}
+
+	for _, tc := range testCases {
+		err := ValidateCompareCount(tc.count)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("ValidateCompareCount(%d) error = %v, wantErr %v", tc.count, err, tc.wantErr)
+		}
+	}
+}
+
+// TestParseCompareIDs tests parsing of the comma-separated compare basket
+func TestParseCompareIDs(t *testing.T) {
+	testCases := []struct {
+		name string
+		raw  string
+		want []string
+	}{
+		{"Empty", "", nil},
+		{"Two IDs", "111,222", []string{"111", "222"}},
+		{"Whitespace and blanks", " 111 , ,222,", []string{"111", "222"}},
+		{"Duplicates removed", "111,222,111", []string{"111", "222"}},
+		{"Capped at max", "1,2,3,4", []string{"1", "2", "3"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := parseCompareIDs(tc.raw)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("parseCompareIDs(%q) = %v, want %v", tc.raw, got, tc.want)
+			}
+		})
+	}
+}
+
+// TestBuildCompareDataPoints tests that data points get per-school citation keys
+func TestBuildCompareDataPoints(t *testing.T) {
+	a := MockSchool("111111111111", "Alpha Elementary", "District A", "CA", "KG", "05")
+	b := MockSchool("222222222222", "Beta Elementary", "District B", "CA", "KG", "05")
+	b.Teachers = sql.NullFloat64{} // Missing values should be skipped, not cited as N/A
+
+	inputs := []CompareSchoolInput{
+		{School: a, NAEP: MockNAEPData(a.NCESSCH, "CA", "", false, false)},
+		{School: b, Enhanced: &EnhancedSchoolData{Sports: []string{"Soccer", "Swim"}, ExtractedAt: time.Now()}},
+	}
+
+	points := BuildCompareDataPoints(inputs)
+
+	if points[0].ID != "A1" {
+		t.Errorf("Expected first data point to be A1, got %s", points[0].ID)
+	}
+
+	var sawNAEP, sawSports, sawB bool
+	for _, p := range points {
+		if p.Value == "N/A" {
+			t.Errorf("Data point %s should not cite N/A values", p.ID)
+		}
+		if p.School == "Beta Elementary" {
+			sawB = true
+			if !strings.HasPrefix(p.ID, "B") {
+				t.Errorf("Expected Beta data point to use B prefix, got %s", p.ID)
+			}
+			if p.Label == "Teachers (FTE)" {
+				t.Error("Expected missing teacher count to be skipped")
+			}
+		}
+		if strings.HasPrefix(p.Label, "NAEP grade 4 mathematics") && p.Value == "40% proficient or above" {
+			sawNAEP = true
+		}
+		if p.Label == "Sports" && p.Value == "Soccer, Swim" {
+			sawSports = true
+		}
+	}
+
+	if !sawB || !sawNAEP || !sawSports {
+		t.Errorf("Missing expected data points (beta=%v naep=%v sports=%v)", sawB, sawNAEP, sawSports)
+	}
+}
+
+// TestBuildComparisonPrompt tests that the prompt carries citations, priorities, and the disclaimer instruction
+func TestBuildComparisonPrompt(t *testing.T) {
+	inputs := []CompareSchoolInput{
+		{School: MockSchool("111111111111", "Alpha Elementary", "District A", "CA", "KG", "05")},
+		{School: MockSchool("222222222222", "Beta Elementary", "District B", "CA", "KG", "05")},
+	}
+	points := BuildCompareDataPoints(inputs)
+
+	prompt := buildComparisonPrompt(inputs, points, "Small classes")
+
+	for _, want := range []string{"School A: Alpha Elementary", "[A1]", "[B1]", "## Family Priorities\nSmall classes", "### Disclaimer"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("Expected prompt to contain %q", want)
+		}
+	}
+}
+
+// TestCompareSchools tests lining schools up field by field
+func TestCompareSchools(t *testing.T) {
+	a := MockSchool("111111111111", "Alpha Elementary", "District A", "CA", "KG", "05")
+	b := MockSchool("222222222222", "Beta Elementary", "District B", "CA", "KG", "05")
+	b.Teachers = sql.NullFloat64{}
+
+	inputs := []CompareSchoolInput{
+		{School: a, NAEP: MockNAEPData(a.NCESSCH, "CA", "", false, false)},
+		{School: b, Enhanced: &EnhancedSchoolData{Sports: []string{"Soccer", "Swim"}, ExtractedAt: time.Now()}},
+	}
+	percentiles := map[string]SchoolPercentiles{
+		a.NCESSCH: {percentileEnrollment: {Metric: percentileEnrollment, State: "CA", Level: "Elementary", StatePct: 78, StatePeers: 40}},
+	}
+
+	comparison := CompareSchools(inputs, percentiles)
+	rows := make(map[string]ComparisonRow)
+	for _, row := range comparison.Rows {
+		if len(row.Values) != 2 {
+			t.Errorf("%s has %d values, want 2", row.Field, len(row.Values))
+		}
+		rows[row.Field] = row
+	}
+
+	if row := rows["Grades"]; row.Differs || row.Values[0] != row.Values[1] {
+		t.Errorf("Grades = %+v, want the same for both", row)
+	}
+	if row := rows["District"]; !row.Differs {
+		t.Errorf("District = %+v, want differing", row)
+	}
+	if row := rows["Teachers (FTE)"]; row.Values[1] != "" {
+		t.Errorf("missing teachers = %q, want empty", row.Values[1])
+	}
+	if row := rows["Enrollment among peers"]; row.Values[0] != "larger than 78% of CA elementary schools" || row.Values[1] != "" {
+		t.Errorf("Enrollment among peers = %+v", row)
+	}
+	if row := rows["NAEP grade 4 mathematics"]; !strings.HasPrefix(row.Values[0], "40% proficient or above") || row.Values[1] != "" {
+		t.Errorf("NAEP = %+v", row)
+	}
+	if row := rows["Sports"]; row.Values[0] != "" || row.Values[1] != "Soccer, Swim" {
+		t.Errorf("Sports = %+v", row)
+	}
+	// Fields neither school has are left out
+	if _, ok := rows["Principal"]; ok {
+		t.Error("expected Principal to be left out")
+	}
+}
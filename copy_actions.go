@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/atotto/clipboard"
+)
+
+// CopyAction is something about a school that can be copied to the clipboard
+type CopyAction struct {
+	Key   string // Key that copies it from the TUI's copy menu
+	Label string
+	Value string
+}
+
+// writeClipboard copies text to the system clipboard
+var writeClipboard = clipboard.WriteAll
+
+// emailPattern finds email addresses in extracted website markdown
+var emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+
+// SchoolCopyActions lists what can be copied about a school, leaving out
+// values it doesn't have. enhanced may be nil.
+func SchoolCopyActions(s *School, enhanced *EnhancedSchoolData) []CopyAction {
+	actions := []CopyAction{{Key: "i", Label: "ID", Value: s.NCESSCH}}
+	if address := schoolMailingAddress(s); address != "" {
+		actions = append(actions, CopyAction{Key: "a", Label: "Address", Value: address})
+	}
+	if phone := dialablePhone(s.Phone.String); phone != "" {
+		actions = append(actions, CopyAction{Key: "p", Label: "Phone", Value: phone})
+	}
+	actions = append(actions, CopyAction{Key: "s", Label: "Summary", Value: schoolOneLine(s)})
+	if s.Website.Valid && s.Website.String != "" {
+		actions = append(actions, CopyAction{Key: "w", Label: "Website", Value: schoolWebsiteURL(s)})
+	}
+	if email := mainOfficeEmail(enhanced); email != "" {
+		actions = append(actions, CopyAction{Key: "e", Label: "Office email", Value: email})
+	}
+	return actions
+}
+
+// phoneExtension matches an extension at the end of a phone number, e.g. " x204" or " ext. 12"
+var phoneExtension = regexp.MustCompile(`(?i)\s*(?:ext\.?|x)\s*\d+\s*$`)
+
+// dialablePhone converts a North American phone number to E.164 for dialing,
+// e.g. "(415) 555-0123" to "+14155550123", or returns "" when it isn't one.
+// Extensions are dropped.
+func dialablePhone(phone string) string {
+	var digits []rune
+	for _, r := range phoneExtension.ReplaceAllString(phone, "") {
+		if r >= '0' && r <= '9' {
+			digits = append(digits, r)
+		}
+	}
+	if len(digits) == 11 && digits[0] == '1' {
+		digits = digits[1:]
+	}
+	// Area codes and exchanges don't start with 0 or 1
+	if len(digits) != 10 || digits[0] < '2' || digits[3] < '2' {
+		return ""
+	}
+	return "+1" + string(digits)
+}
+
+// schoolMailingAddress formats a school's address on one line, e.g.
+// "123 Lincoln St, San Francisco, CA 94102", or "" without a street address
+func schoolMailingAddress(s *School) string {
+	var parts []string
+	for _, street := range []string{s.Street1.String, s.Street2.String, s.Street3.String} {
+		if street = strings.TrimSpace(street); street != "" {
+			parts = append(parts, street)
+		}
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	if s.City != "" {
+		parts = append(parts, s.City)
+	}
+	parts = append(parts, strings.TrimSpace(s.State+" "+s.Zip.String))
+	return strings.Join(parts, ", ")
+}
+
+// schoolOneLine summarizes a school for pasting into a message or notes, e.g.
+// "Lincoln Elementary School — PK-5, 500 students, 20:1 ratio, lincoln.sfusd.edu"
+func schoolOneLine(s *School) string {
+	var facts []string
+	if s.GradeLow.Valid && s.GradeHigh.Valid {
+		facts = append(facts, gradeLabel(s.GradeLow.String)+"-"+gradeLabel(s.GradeHigh.String))
+	}
+	if s.Enrollment.Valid {
+		facts = append(facts, fmt.Sprintf("%d students", s.Enrollment.Int64))
+	}
+	if s.Enrollment.Valid && s.Teachers.Valid && s.Teachers.Float64 > 0 {
+		facts = append(facts, fmt.Sprintf("%.0f:1 ratio", math.Round(float64(s.Enrollment.Int64)/s.Teachers.Float64)))
+	}
+	if s.Website.Valid && s.Website.String != "" {
+		facts = append(facts, websiteHost(schoolWebsiteURL(s)))
+	}
+	if len(facts) == 0 {
+		return s.Name
+	}
+	return s.Name + " — " + strings.Join(facts, ", ")
+}
+
+// websiteHost shortens a website URL for display, e.g.
+// "https://www.lincoln.org/" to "lincoln.org"
+func websiteHost(website string) string {
+	u, err := url.Parse(website)
+	if err != nil || u.Host == "" {
+		return website
+	}
+	host := strings.TrimPrefix(u.Host, "www.")
+	if path := strings.TrimSuffix(u.Path, "/"); path != "" {
+		return host + path
+	}
+	return host
+}
+
+// mainOfficeEmail returns the school's main office email from its website
+// data: the extracted field, or else the first email on a line of the
+// markdown that mentions the office
+func mainOfficeEmail(enhanced *EnhancedSchoolData) string {
+	if enhanced == nil {
+		return ""
+	}
+	if enhanced.MainOfficeEmail != "" {
+		return enhanced.MainOfficeEmail
+	}
+	for _, line := range strings.Split(enhanced.MarkdownContent, "\n") {
+		if !strings.Contains(strings.ToLower(line), "office") {
+			continue
+		}
+		if email := emailPattern.FindString(line); email != "" {
+			return email
+		}
+	}
+	return ""
+}
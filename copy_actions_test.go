@@ -0,0 +1,161 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestSchoolCopyActions(t *testing.T) {
+	school := MockSchool("360000100001", "Lincoln Elementary School", "SFUSD", "CA", "KG", "05")
+	school.City = "San Francisco"
+	school.Zip = sql.NullString{String: "94102", Valid: true}
+	school.Street1 = sql.NullString{String: "123 Lincoln St", Valid: true}
+	school.Website = sql.NullString{String: "www.lincolnelementary.org/", Valid: true}
+	school.Enrollment = sql.NullInt64{Int64: 480, Valid: true}
+	school.Teachers = sql.NullFloat64{Float64: 25.5, Valid: true}
+	school.Phone = sql.NullString{String: "(415) 555-0100", Valid: true}
+	enhanced := &EnhancedSchoolData{MarkdownContent: "- Principal: Maria Alvarez, malvarez@sfusd.edu\n- Office Manager: Linda Chen, lchen@sfusd.edu, (415) 555-0100"}
+
+	got := map[string]string{}
+	for _, action := range SchoolCopyActions(school, enhanced) {
+		got[action.Key] = action.Value
+	}
+	want := map[string]string{
+		"i": "360000100001",
+		"a": "123 Lincoln St, San Francisco, CA 94102",
+		"p": "+14155550100",
+		"s": "Lincoln Elementary School — K-5, 480 students, 19:1 ratio, lincolnelementary.org",
+		"w": "https://www.lincolnelementary.org/",
+		"e": "lchen@sfusd.edu",
+	}
+	for key, value := range want {
+		if got[key] != value {
+			t.Errorf("copy %s = %q, want %q", key, got[key], value)
+		}
+	}
+
+	// Values a school doesn't have aren't offered
+	bare := &School{NCESSCH: "360000100009", Name: "New School", Phone: sql.NullString{String: "555-0100", Valid: true}}
+	actions := SchoolCopyActions(bare, nil)
+	if len(actions) != 2 || actions[1].Value != "New School" {
+		t.Errorf("actions without address, dialable phone, website, or email = %+v", actions)
+	}
+
+	// The extracted office email wins over the markdown
+	enhanced.MainOfficeEmail = "office@lincolnelementary.org"
+	if email := mainOfficeEmail(enhanced); email != "office@lincolnelementary.org" {
+		t.Errorf("mainOfficeEmail = %q", email)
+	}
+}
+
+func TestDialablePhone(t *testing.T) {
+	tests := map[string]string{
+		"415-555-0100":          "+14155550100",
+		"(415)555-0100":         "+14155550100",
+		"1 (415) 555-0100 x204": "+14155550100",
+		"415.555.0100 ext. 12":  "+14155550100",
+		"+1 415 555 0100":       "+14155550100",
+		"555-0100":              "",
+		"(015) 555-0100":        "",
+		"N/A":                   "",
+	}
+	for phone, want := range tests {
+		if got := dialablePhone(phone); got != want {
+			t.Errorf("dialablePhone(%q) = %q, want %q", phone, got, want)
+		}
+	}
+}
+
+func TestDetailViewCopyMenu(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	var copied []string
+	defer func(orig func(string) error) { writeClipboard = orig }(writeClipboard)
+	writeClipboard = func(text string) error {
+		copied = append(copied, text)
+		return nil
+	}
+
+	school, err := db.GetSchoolByID("360000100001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := initialModel(db, nil, nil, "")
+	m.width = 80
+	m.height = 24
+	m.viewportReady = true
+	m.currentView = detailView
+	m.selectedItem = school
+
+	press := func(key tea.KeyMsg) {
+		newModel, _ := m.handleDetailViewKeys(key)
+		m = newModel.(model)
+	}
+
+	press(tea.KeyMsg{Type: tea.KeyCtrlY})
+	if !m.copyMenu || !strings.Contains(m.detailViewRender(), "a: Address") {
+		t.Fatal("Expected Ctrl+Y to open the copy menu")
+	}
+	press(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("a")})
+	if m.copyMenu || len(copied) != 1 || copied[0] != "123 Lincoln St, San Francisco, CA 94102" {
+		t.Fatalf("copied %q", copied)
+	}
+	if m.saveSuccess != "Copied "+copied[0] {
+		t.Errorf("status = %q", m.saveSuccess)
+	}
+
+	// Ctrl+Y twice copies the ID; other keys just close the menu
+	press(tea.KeyMsg{Type: tea.KeyCtrlY})
+	press(tea.KeyMsg{Type: tea.KeyCtrlY})
+	press(tea.KeyMsg{Type: tea.KeyCtrlY})
+	press(tea.KeyMsg{Type: tea.KeyEsc})
+	if len(copied) != 2 || copied[1] != "360000100001" {
+		t.Errorf("copied %q", copied)
+	}
+	if m.currentView != detailView || m.copyMenu {
+		t.Error("Expected Esc to close the copy menu and stay on the details")
+	}
+
+	// The phone number copies ready to dial
+	press(tea.KeyMsg{Type: tea.KeyCtrlY})
+	press(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("p")})
+	if len(copied) != 3 || copied[2] != "+14155550100" {
+		t.Errorf("copied %q", copied)
+	}
+
+	writeClipboard = func(string) error { return errors.New("no clipboard utility") }
+	press(tea.KeyMsg{Type: tea.KeyCtrlY})
+	press(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("s")})
+	if m.err == nil {
+		t.Error("Expected a clipboard failure to be reported")
+	}
+}
+
+func TestDetailPageCopyButtons(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	router := NewRouter(ServerConfig{DB: db})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/schools/360000100001", nil))
+	body := rec.Body.String()
+	for _, value := range []string{"360000100001", "123 Lincoln St, San Francisco, CA 94102", "https://lincoln.sfusd.edu"} {
+		if !strings.Contains(body, `data-copy="`+value+`"`) {
+			t.Errorf("detail page has no button copying %q", value)
+		}
+	}
+
+	// html/template escapes the plus, which browsers read back
+	if !strings.Contains(body, `data-copy="&#43;14155550100"`) {
+		t.Error("detail page has no button copying the dialable phone")
+	}
+	if !strings.Contains(body, `<a href="tel:&#43;14155550100" class="tel-link">415-555-0100</a>`) {
+		t.Error("detail page phone isn't a click-to-call link")
+	}
+}
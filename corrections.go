@@ -0,0 +1,296 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Directory fields that can be corrected by hand
+const (
+	correctionPhone   = "phone"
+	correctionWebsite = "website"
+	correctionStreet  = "street"
+	correctionCity    = "city"
+	correctionZip     = "zip"
+)
+
+// correctionFields lists the correctable fields in display order, with labels
+var correctionFields = []struct {
+	Field string
+	Label string
+}{
+	{correctionPhone, "Phone"},
+	{correctionWebsite, "Website"},
+	{correctionStreet, "Street address"},
+	{correctionCity, "City"},
+	{correctionZip, "ZIP code"},
+}
+
+var zipPattern = regexp.MustCompile(`^[0-9]{5}(-?[0-9]{4})?$`)
+
+// SchoolCorrection overrides one CCD directory field for a school. The source
+// tables are never changed; corrections are applied as schools are loaded.
+type SchoolCorrection struct {
+	NCESSCH   string    `json:"ncessch"`
+	Field     string    `json:"field"` // One of the correction* fields
+	Value     string    `json:"value"` // Empty clears the field, e.g. a dead website
+	Note      string    `json:"note,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ValidateCorrection checks that a correction's field is correctable and its value
+// fits the field, normalizing the value (e.g. adding a scheme to a website)
+func ValidateCorrection(c *SchoolCorrection) error {
+	c.Value = strings.TrimSpace(c.Value)
+	if len(c.Value) > maxEditTextLength {
+		return fmt.Errorf("keep the value under %d characters", maxEditTextLength)
+	}
+	if c.Value == "" {
+		return nil
+	}
+
+	switch c.Field {
+	case correctionPhone:
+		if msg := validateEditPhone(c.Value); msg != "" {
+			return fmt.Errorf("%s", msg)
+		}
+	case correctionWebsite:
+		normalized, ok := normalizeWebsiteURL(c.Value)
+		if !ok {
+			return fmt.Errorf("%q isn't a valid website address", c.Value)
+		}
+		c.Value = normalized
+	case correctionZip:
+		if !zipPattern.MatchString(c.Value) {
+			return fmt.Errorf("%q isn't a valid ZIP code", c.Value)
+		}
+	case correctionStreet, correctionCity:
+	default:
+		return fmt.Errorf("%q can't be corrected (use phone, website, street, city, or zip)", c.Field)
+	}
+	return nil
+}
+
+// applyCorrections overlays the given corrections onto s, remembering the original
+// CCD value of each corrected field
+func (s *School) applyCorrections(fields map[string]string) {
+	for field, value := range fields {
+		var original string
+		switch field {
+		case correctionPhone:
+			original = s.Phone.String
+			s.Phone = correctedString(value)
+		case correctionWebsite:
+			original = s.Website.String
+			s.Website = correctedString(value)
+		case correctionStreet:
+			original = s.Street1.String
+			s.Street1 = correctedString(value)
+		case correctionCity:
+			original, s.City = s.City, value
+		case correctionZip:
+			original = s.Zip.String
+			s.Zip = correctedString(value)
+		default:
+			continue
+		}
+		if s.Corrected == nil {
+			s.Corrected = make(map[string]string)
+		}
+		s.Corrected[field] = original
+	}
+}
+
+func correctedString(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: value != ""}
+}
+
+// IsCorrected reports whether a user correction replaced the field's CCD value
+func (s *School) IsCorrected(field string) bool {
+	_, ok := s.Corrected[field]
+	return ok
+}
+
+// CCDValue returns a field's value from the CCD source data, before any correction
+func (s *School) CCDValue(field string) string {
+	if original, ok := s.Corrected[field]; ok {
+		return original
+	}
+	return s.fieldValue(field)
+}
+
+// fieldValue returns a correctable field's current value
+func (s *School) fieldValue(field string) string {
+	switch field {
+	case correctionPhone:
+		return s.Phone.String
+	case correctionWebsite:
+		return s.Website.String
+	case correctionStreet:
+		return s.Street1.String
+	case correctionCity:
+		return s.City
+	case correctionZip:
+		return s.Zip.String
+	}
+	return ""
+}
+
+// CorrectionField is a correctable field's current and CCD values for the corrections form
+type CorrectionField struct {
+	Field     string
+	Label     string
+	Value     string
+	CCDValue  string
+	Corrected bool
+	Error     string // Validation error from the last submission
+}
+
+// CorrectionFields lists the school's correctable fields for the corrections form
+func (s *School) CorrectionFields() []CorrectionField {
+	fields := make([]CorrectionField, len(correctionFields))
+	for i, f := range correctionFields {
+		fields[i] = CorrectionField{
+			Field:     f.Field,
+			Label:     f.Label,
+			Value:     s.fieldValue(f.Field),
+			CCDValue:  s.CCDValue(f.Field),
+			Corrected: s.IsCorrected(f.Field),
+		}
+	}
+	return fields
+}
+
+// applyCorrections overlays saved corrections onto schools as they're loaded
+func (d *DB) applyCorrections(s *School) {
+	d.correctionsMu.RLock()
+	fields := d.corrections[s.NCESSCH]
+	d.correctionsMu.RUnlock()
+	if len(fields) > 0 {
+		s.applyCorrections(fields)
+	}
+}
+
+// loadCorrections reads all corrections into memory; there are few, and every
+// school load checks them
+func (d *DB) loadCorrections() error {
+	rows, err := d.conn.Query(`SELECT ncessch, field, value FROM school_corrections`)
+	if err != nil {
+		return fmt.Errorf("failed to load school corrections: %w", err)
+	}
+	defer rows.Close()
+
+	corrections := make(map[string]map[string]string)
+	for rows.Next() {
+		var ncessch, field, value string
+		if err := rows.Scan(&ncessch, &field, &value); err != nil {
+			return fmt.Errorf("failed to scan school correction: %w", err)
+		}
+		if corrections[ncessch] == nil {
+			corrections[ncessch] = make(map[string]string)
+		}
+		corrections[ncessch][field] = value
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	d.correctionsMu.Lock()
+	d.corrections = corrections
+	d.correctionsMu.Unlock()
+	return nil
+}
+
+// SaveSchoolCorrection creates or replaces a correction of one directory field
+func (d *DB) SaveSchoolCorrection(c SchoolCorrection) error {
+	if err := ValidateCorrection(&c); err != nil {
+		return err
+	}
+
+	_, err := d.conn.Exec(`
+		INSERT INTO school_corrections (ncessch, field, value, note, updated_at)
+		VALUES ($1, $2, $3, $4, now())
+		ON CONFLICT (ncessch, field) DO UPDATE SET
+			value = EXCLUDED.value,
+			note = EXCLUDED.note,
+			updated_at = now()
+	`, c.NCESSCH, c.Field, c.Value, c.Note)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to save school correction", "error", err, "ncessch", c.NCESSCH, "field", c.Field)
+		}
+		return fmt.Errorf("failed to save school correction: %w", err)
+	}
+
+	d.correctionsMu.Lock()
+	if d.corrections == nil {
+		d.corrections = make(map[string]map[string]string)
+	}
+	if d.corrections[c.NCESSCH] == nil {
+		d.corrections[c.NCESSCH] = make(map[string]string)
+	}
+	d.corrections[c.NCESSCH][c.Field] = c.Value
+	d.correctionsMu.Unlock()
+	d.schoolCache.Delete(c.NCESSCH)
+	d.queueSearchIndex(c.NCESSCH)
+	return nil
+}
+
+// updateCorrection applies one field of the corrections form: restoring or
+// re-entering the CCD value deletes the correction, and a new value saves one.
+// Unchanged fields are left alone.
+func (d *DB) updateCorrection(school *School, f CorrectionField, restore bool, note string) error {
+	switch {
+	case restore || (f.Value == f.CCDValue && f.Corrected):
+		if !f.Corrected {
+			return nil
+		}
+		return d.DeleteSchoolCorrection(school.NCESSCH, f.Field)
+	case f.Value == school.fieldValue(f.Field):
+		return nil
+	}
+	return d.SaveSchoolCorrection(SchoolCorrection{NCESSCH: school.NCESSCH, Field: f.Field, Value: f.Value, Note: note})
+}
+
+// DeleteSchoolCorrection removes a correction, restoring the CCD value
+func (d *DB) DeleteSchoolCorrection(ncessch, field string) error {
+	if _, err := d.conn.Exec(`DELETE FROM school_corrections WHERE ncessch = $1 AND field = $2`, ncessch, field); err != nil {
+		return fmt.Errorf("failed to delete school correction: %w", err)
+	}
+
+	d.correctionsMu.Lock()
+	delete(d.corrections[ncessch], field)
+	d.correctionsMu.Unlock()
+	d.schoolCache.Delete(ncessch)
+	d.queueSearchIndex(ncessch)
+	return nil
+}
+
+// ListSchoolCorrections returns a school's corrections in field order. An empty
+// ncessch lists corrections for all schools.
+func (d *DB) ListSchoolCorrections(ncessch string) ([]SchoolCorrection, error) {
+	rows, err := d.conn.Query(`
+		SELECT ncessch, field, value, note, updated_at
+		FROM school_corrections
+		WHERE $1 = '' OR ncessch = $1
+		ORDER BY ncessch, field
+	`, ncessch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list school corrections: %w", err)
+	}
+	defer rows.Close()
+
+	var corrections []SchoolCorrection
+	for rows.Next() {
+		var c SchoolCorrection
+		if err := rows.Scan(&c.NCESSCH, &c.Field, &c.Value, &c.Note, &c.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan school correction: %w", err)
+		}
+		corrections = append(corrections, c)
+	}
+
+	return corrections, rows.Err()
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestValidateCorrection(t *testing.T) {
+	tests := []struct {
+		name      string
+		field     string
+		value     string
+		wantValue string
+		wantErr   bool
+	}{
+		{"phone", correctionPhone, " (415) 555-0199 ", "(415) 555-0199", false},
+		{"bad phone", correctionPhone, "call the office", "", true},
+		{"website gets a scheme", correctionWebsite, "lincoln.example.org", "https://lincoln.example.org", false},
+		{"website with scheme", correctionWebsite, "http://lincoln.example.org/about", "http://lincoln.example.org/about", false},
+		{"bad website", correctionWebsite, "lincoln", "", true},
+		{"zip", correctionZip, "94103", "94103", false},
+		{"zip+4", correctionZip, "94103-1234", "94103-1234", false},
+		{"bad zip", correctionZip, "9410", "", true},
+		{"street", correctionStreet, "125 Lincoln St", "125 Lincoln St", false},
+		{"empty clears the field", correctionWebsite, "", "", false},
+		{"too long", correctionCity, strings.Repeat("x", maxEditTextLength+1), "", true},
+		{"uncorrectable field", "name", "New Name", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := SchoolCorrection{NCESSCH: "360000100001", Field: tt.field, Value: tt.value}
+			err := ValidateCorrection(&c)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateCorrection() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && c.Value != tt.wantValue {
+				t.Errorf("value = %q, want %q", c.Value, tt.wantValue)
+			}
+		})
+	}
+}
+
+func TestSchoolCorrectionsOverlay(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	// Load the school first so the correction has to invalidate the cache
+	school, err := db.GetSchoolByID("360000100001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if school.IsCorrected(correctionPhone) {
+		t.Fatal("school corrected before any corrections were saved")
+	}
+
+	for _, c := range []SchoolCorrection{
+		{NCESSCH: "360000100001", Field: correctionPhone, Value: "415-555-0199", Note: "Called the office"},
+		{NCESSCH: "360000100001", Field: correctionWebsite, Value: ""},
+	} {
+		if err := db.SaveSchoolCorrection(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	check := func(view string, s *School) {
+		t.Helper()
+		if s.PhoneString() != "415-555-0199" || !s.IsCorrected(correctionPhone) || s.CCDValue(correctionPhone) != "415-555-0100" {
+			t.Errorf("%s: phone = %q (corrected %v, CCD %q)", view, s.PhoneString(), s.IsCorrected(correctionPhone), s.CCDValue(correctionPhone))
+		}
+		if s.Website.Valid || s.CCDValue(correctionWebsite) != "https://lincoln.sfusd.edu" {
+			t.Errorf("%s: website = %+v, CCD %q", view, s.Website, s.CCDValue(correctionWebsite))
+		}
+		if s.IsCorrected(correctionCity) || s.City != "San Francisco" {
+			t.Errorf("%s: city = %q, corrected %v", view, s.City, s.IsCorrected(correctionCity))
+		}
+	}
+
+	school, err = db.GetSchoolByID("360000100001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	check("detail", school)
+
+	schools, err := db.SearchSchools("Lincoln", "", 10)
+	if err != nil || len(schools) == 0 {
+		t.Fatalf("search: %v, %d results", err, len(schools))
+	}
+	check("search", &schools[0])
+
+	compared, err := db.GetSchoolsByIDs([]string{"360000100001"})
+	if err != nil || len(compared) != 1 {
+		t.Fatalf("GetSchoolsByIDs: %v, %d results", err, len(compared))
+	}
+	check("compare", compared[0])
+
+	if data := convertSchoolToCmd(*school); strings.Join(data.CorrectedFields, ",") != "phone,website" {
+		t.Errorf("export corrected fields = %v", data.CorrectedFields)
+	}
+
+	// Corrections are reloaded from the database on startup
+	if err := db.loadCorrections(); err != nil {
+		t.Fatal(err)
+	}
+	corrections, err := db.ListSchoolCorrections("360000100001")
+	if err != nil || len(corrections) != 2 || corrections[0].Note != "Called the office" {
+		t.Fatalf("ListSchoolCorrections = %+v, %v", corrections, err)
+	}
+
+	// Deleting a correction restores the CCD value
+	if err := db.DeleteSchoolCorrection("360000100001", correctionPhone); err != nil {
+		t.Fatal(err)
+	}
+	school, err = db.GetSchoolByID("360000100001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if school.PhoneString() != "415-555-0100" || school.IsCorrected(correctionPhone) {
+		t.Errorf("after delete: phone = %q, corrected %v", school.PhoneString(), school.IsCorrected(correctionPhone))
+	}
+}
+
+func TestWebSaveCorrections(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	router := NewRouter(ServerConfig{DB: db})
+
+	post := func(form url.Values, htmx bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/schools/360000100001/corrections", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if htmx {
+			req.Header.Set("HX-Request", "true")
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+	unchanged := func() url.Values {
+		return url.Values{
+			"phone":   {"415-555-0100"},
+			"website": {"https://lincoln.sfusd.edu"},
+			"street":  {"123 Lincoln St"},
+			"city":    {"San Francisco"},
+			"zip":     {"94102"},
+		}
+	}
+
+	req := httptest.NewRequest("POST", "/schools/999999999999/corrections", strings.NewReader(unchanged().Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unknown school: status %d, want 404", rec.Code)
+	}
+
+	// An invalid field is reported and nothing is saved
+	form := unchanged()
+	form.Set("city", "Oakland")
+	form.Set("zip", "not a zip")
+	rec = post(form, false)
+	if rec.Code != http.StatusUnprocessableEntity || !strings.Contains(rec.Body.String(), "valid ZIP code") {
+		t.Fatalf("invalid form: status %d, body %s", rec.Code, rec.Body.String())
+	}
+	doc, err := html.Parse(strings.NewReader(rec.Body.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rule := range a11yRules {
+		if rule.fullPage {
+			continue
+		}
+		for _, problem := range rule.check(doc) {
+			t.Errorf("corrections form %s: %s", rule.id, problem)
+		}
+	}
+	if corrections, _ := db.ListSchoolCorrections("360000100001"); len(corrections) != 0 {
+		t.Errorf("invalid form saved corrections: %+v", corrections)
+	}
+
+	// Only changed fields are saved, and the page is reloaded
+	form = unchanged()
+	form.Set("city", "Oakland")
+	form.Set("note", "Moved campuses")
+	rec = post(form, true)
+	if rec.Code != http.StatusNoContent || rec.Header().Get("HX-Refresh") != "true" {
+		t.Fatalf("save: status %d, headers %v", rec.Code, rec.Header())
+	}
+	corrections, err := db.ListSchoolCorrections("360000100001")
+	if err != nil || len(corrections) != 1 || corrections[0].Field != correctionCity || corrections[0].Note != "Moved campuses" {
+		t.Fatalf("corrections = %+v, %v", corrections, err)
+	}
+
+	// The detail page marks the corrected value
+	req = httptest.NewRequest("GET", "/schools/360000100001", nil)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if body := rec.Body.String(); !strings.Contains(body, "Oakland") || !strings.Contains(body, `class="corrected-marker"`) {
+		t.Errorf("detail page doesn't show the correction: status %d", rec.Code)
+	}
+
+	// Restoring the CCD value removes the correction
+	form = unchanged()
+	form.Set("city", "Oakland")
+	form.Set("restore_city", "1")
+	rec = post(form, false)
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("restore: status %d", rec.Code)
+	}
+	if corrections, _ := db.ListSchoolCorrections("360000100001"); len(corrections) != 0 {
+		t.Errorf("restore left corrections: %+v", corrections)
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"mime"
+	"net/http"
+	"net/url"
+)
+
+// CSRF tokens use the double-submit pattern: the token is kept in an HttpOnly
+// cookie, rendered into the page for HTMX to send back in a header, and
+// compared on each protected POST. Another site can make the browser send the
+// cookie but can't read the token to put in the header.
+const (
+	csrfCookie = "schoolfinder_csrf"
+	csrfHeader = "X-CSRF-Token"
+	csrfField  = "csrf_token" // Form field alternative to the header, for plain forms
+)
+
+// csrfToken returns the visitor's CSRF token, issuing one in a cookie if they
+// don't have one yet
+func csrfToken(w http.ResponseWriter, r *http.Request) string {
+	if c, err := r.Cookie(csrfCookie); err == nil && validCSRFToken(c.Value) {
+		return c.Value
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err) // crypto/rand doesn't fail on supported platforms
+	}
+	token := hex.EncodeToString(b)
+	http.SetCookie(w, &http.Cookie{
+		Name:     csrfCookie,
+		Value:    token,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+	return token
+}
+
+// validCSRFToken reports whether token looks like one csrfToken issued
+func validCSRFToken(token string) bool {
+	if len(token) != 64 {
+		return false
+	}
+	_, err := hex.DecodeString(token)
+	return err == nil
+}
+
+// checkCSRF reports whether r carries the token in its CSRF cookie, in the
+// header or, for URL-encoded forms, the csrf_token field. Multipart bodies
+// aren't parsed here, so uploads must send the header.
+func checkCSRF(r *http.Request) bool {
+	c, err := r.Cookie(csrfCookie)
+	if err != nil || !validCSRFToken(c.Value) {
+		return false
+	}
+	sent := r.Header.Get(csrfHeader)
+	if sent == "" {
+		if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "application/x-www-form-urlencoded" {
+			sent = r.PostFormValue(csrfField)
+		}
+	}
+	return subtle.ConstantTimeCompare([]byte(sent), []byte(c.Value)) == 1
+}
+
+// requireCSRF rejects POSTs without the visitor's CSRF token. Pages with
+// protected forms pass csrfToken to their template as "CSRFToken".
+func (h *WebHandler) requireCSRF(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && !checkCSRF(r) {
+			h.renderUserError(w, r, ErrCSRFMismatch, "The form couldn't be submitted")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// sameOrigin reports whether r didn't come from another site. Browsers label
+// cross-site requests with Sec-Fetch-Site, and older ones still send Origin on
+// POSTs; clients that send neither, like the CLI with an API token, aren't
+// browsers another site could make send the visitor's credentials.
+func sameOrigin(r *http.Request) bool {
+	switch r.Header.Get("Sec-Fetch-Site") {
+	case "same-origin", "none":
+		return true
+	case "":
+	default:
+		return false
+	}
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host == r.Host
+}
+
+// requireSameOrigin rejects POSTs from other sites with reject. Browsers send
+// HTTP Basic credentials with any request to the server, so every route that
+// changes data for a signed-in editor or admin needs it, not just the forms
+// with a CSRF token.
+func requireSameOrigin(reject func(http.ResponseWriter, *http.Request, *UserError)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodPost && !sameOrigin(r) {
+				reject(w, r, ErrCSRFMismatch)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
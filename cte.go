@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// CTEPathway is a career and technical education pathway a high school
+// publishes, e.g. "Aviation Maintenance" in Transportation
+type CTEPathway struct {
+	Name        string `json:"name"`
+	Cluster     string `json:"cluster,omitempty"`     // Career cluster, e.g. "Health Science"
+	Credentials string `json:"credentials,omitempty"` // Certifications or credit earned, e.g. "CNA certification"
+}
+
+// DualEnrollment is a partnership that lets students earn college credit in
+// high school, e.g. dual enrollment or early college with a community college
+type DualEnrollment struct {
+	Partner string `json:"partner"`           // College or university
+	Kind    string `json:"kind,omitempty"`    // e.g. "Dual enrollment", "Early college", or "Articulated credit"
+	Details string `json:"details,omitempty"` // e.g. "Up to 30 units of nursing prerequisites"
+}
+
+// Summary describes the pathway, e.g. "Aviation Maintenance (Transportation) · FAA A&P prep"
+func (p CTEPathway) Summary() string {
+	s := p.Name
+	if p.Cluster != "" && !strings.EqualFold(p.Cluster, p.Name) {
+		s += " (" + p.Cluster + ")"
+	}
+	if p.Credentials != "" {
+		s += " · " + p.Credentials
+	}
+	return s
+}
+
+// Summary describes the partnership, e.g. "Foothill College: Dual enrollment · Nursing prerequisites"
+func (d DualEnrollment) Summary() string {
+	s := d.Partner
+	if d.Kind != "" {
+		s += ": " + d.Kind
+	}
+	if d.Details != "" {
+		s += " · " + d.Details
+	}
+	return s
+}
+
+// cteClusters are the National Career Clusters the extraction prompt asks pathways to be filed under
+var cteClusters = []string{
+	"Agriculture, Food & Natural Resources",
+	"Architecture & Construction",
+	"Arts, A/V Technology & Communications",
+	"Business Management & Administration",
+	"Education & Training",
+	"Finance",
+	"Government & Public Administration",
+	"Health Science",
+	"Hospitality & Tourism",
+	"Human Services",
+	"Information Technology",
+	"Law, Public Safety, Corrections & Security",
+	"Manufacturing",
+	"Marketing",
+	"Science, Technology, Engineering & Mathematics",
+	"Transportation, Distribution & Logistics",
+}
+
+// cteLinePattern matches the pathway and partnership lines the extraction
+// prompt asks for, e.g. "- Pathway: Aviation | Transportation, Distribution &
+// Logistics | FAA certification" or "- Dual enrollment: Foothill College |
+// Early college | ...", allowing for markdown bold and bullets
+var cteLinePattern = regexp.MustCompile(`(?im)^[\s>*+-]*\**(cte pathway|pathway|dual enrollment)\**\s*:\**\s*(.+)$`)
+
+// parseCTESection reads the CTE pathways and dual-enrollment partnerships from
+// the extracted markdown's "Career & Technical Education" lines
+func parseCTESection(markdown string) (pathways []CTEPathway, partners []DualEnrollment) {
+	for _, m := range cteLinePattern.FindAllStringSubmatch(markdown, -1) {
+		var fields []string
+		for _, field := range strings.Split(m[2], "|") {
+			field = strings.Trim(strings.TrimSpace(field), "*")
+			if careUnknown.MatchString(field) {
+				field = ""
+			}
+			fields = append(fields, field)
+		}
+		for len(fields) < 3 {
+			fields = append(fields, "")
+		}
+		if fields[0] == "" || strings.EqualFold(fields[0], "none") {
+			continue
+		}
+
+		if strings.EqualFold(m[1], "dual enrollment") {
+			partners = append(partners, DualEnrollment{Partner: fields[0], Kind: fields[1], Details: fields[2]})
+			continue
+		}
+		pathways = append(pathways, CTEPathway{Name: fields[0], Cluster: fields[1], Credentials: fields[2]})
+	}
+	return pathways, partners
+}
+
+// ctePathwayTerms splits a comma-separated pathway filter, e.g. "aviation, health sciences"
+func ctePathwayTerms(filter string) []string {
+	var terms []string
+	for _, term := range strings.Split(filter, ",") {
+		if term = strings.TrimSpace(term); term != "" {
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// cteTermPattern returns the LIKE pattern a pathway filter term matches. A
+// trailing "s" is dropped so "health sciences" matches the Health Science cluster.
+func cteTermPattern(term string) string {
+	term = strings.ToLower(term)
+	if len(term) > 3 && strings.HasSuffix(term, "s") && !strings.HasSuffix(term, "ss") {
+		term = strings.TrimSuffix(term, "s")
+	}
+	return "%" + term + "%"
+}
+
+// SaveCTEPrograms replaces a school's CTE pathways and dual-enrollment
+// partnerships with those in its extracted website data
+func (d *DB) SaveCTEPrograms(data *EnhancedSchoolData) error {
+	tx, err := d.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.Exec(`DELETE FROM school_cte_pathways WHERE ncessch = $1`, data.NCESSCH); err != nil {
+		return fmt.Errorf("failed to clear CTE pathways: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM school_dual_enrollment WHERE ncessch = $1`, data.NCESSCH); err != nil {
+		return fmt.Errorf("failed to clear dual-enrollment partnerships: %w", err)
+	}
+	for _, p := range data.CTEPathways {
+		if _, err := tx.Exec(`INSERT INTO school_cte_pathways (ncessch, pathway, cluster, credentials) VALUES ($1, $2, $3, $4)`,
+			data.NCESSCH, p.Name, p.Cluster, p.Credentials); err != nil {
+			return fmt.Errorf("failed to save CTE pathway: %w", err)
+		}
+	}
+	for _, p := range data.DualEnrollment {
+		if _, err := tx.Exec(`INSERT INTO school_dual_enrollment (ncessch, partner, kind, details) VALUES ($1, $2, $3, $4)`,
+			data.NCESSCH, p.Partner, p.Kind, p.Details); err != nil {
+			return fmt.Errorf("failed to save dual-enrollment partnership: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to save CTE programs: %w", err)
+	}
+	return nil
+}
+
+// CTEPathways lists the distinct pathways and clusters schools publish, most
+// common first, for suggesting pathway filters
+func (d *DB) CTEPathways() ([]string, error) {
+	rows, err := d.conn.Query(`
+		SELECT name FROM (
+			SELECT pathway AS name FROM school_cte_pathways
+			UNION ALL
+			SELECT cluster FROM school_cte_pathways WHERE cluster <> ''
+		)
+		GROUP BY name
+		ORDER BY count(*) DESC, name
+		LIMIT 50
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list CTE pathways: %w", err)
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to scan CTE pathway: %w", err)
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
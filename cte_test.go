@@ -0,0 +1,111 @@
+package main
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCTESection(t *testing.T) {
+	markdown := `## Career & Technical Education
+- **Pathway:** Aviation Maintenance | Transportation, Distribution & Logistics | FAA A&P exam prep
+- Pathway: Patient Care | Health Science | not published
+- Dual enrollment: Foothill College | Early college | Up to 30 units
+- **Dual enrollment:** not published | not published | not published
+
+Students can explore a pathway in 9th grade.`
+	pathways, partners := parseCTESection(markdown)
+	if len(pathways) != 2 || len(partners) != 1 {
+		t.Fatalf("pathways = %+v, partners = %+v", pathways, partners)
+	}
+	if got := pathways[0].Summary(); got != "Aviation Maintenance (Transportation, Distribution & Logistics) · FAA A&P exam prep" {
+		t.Errorf("first pathway = %q", got)
+	}
+	if pathways[1].Credentials != "" {
+		t.Errorf("unpublished credentials = %q", pathways[1].Credentials)
+	}
+	if got := partners[0].Summary(); got != "Foothill College: Early college · Up to 30 units" {
+		t.Errorf("partnership = %q", got)
+	}
+
+	if pathways, partners := parseCTESection("Our pathway to success starts here."); pathways != nil || partners != nil {
+		t.Errorf("prose parsed as CTE lines: %+v, %+v", pathways, partners)
+	}
+}
+
+func TestCTEFilter(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	for id, cte := range map[string]string{
+		"360000100002": "- Pathway: Aviation Maintenance | Transportation, Distribution & Logistics\n- Dual enrollment: Foothill College | Dual enrollment",
+		"360000100004": "- Pathway: Patient Care | Health Science | CNA certification",
+		"360000100003": "- Pathway: Culinary Arts | Hospitality & Tourism",
+	} {
+		if err := saveEnhancedData(db, &EnhancedSchoolData{NCESSCH: id, MarkdownContent: cte, ExtractedAt: time.Now()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	search := func(cte string) string {
+		t.Helper()
+		schools, err := db.SearchSchoolsFiltered(SearchFilters{CTE: cte}, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, s := range schools {
+			ids = append(ids, s.NCESSCH)
+		}
+		slices.Sort(ids)
+		return strings.Join(ids, ",")
+	}
+	// Pathway names and career clusters both match, and any term will do
+	if got := search("aviation, health sciences"); got != "360000100002,360000100004" {
+		t.Errorf("aviation or health sciences = %s", got)
+	}
+	if got := search("hospitality"); got != "360000100003" {
+		t.Errorf("hospitality = %s", got)
+	}
+	if schools, err := db.SearchSchoolsFiltered(SearchFilters{Query: `cte:"patient care"`}, 100); err != nil || len(schools) != 1 {
+		t.Errorf("cte: query syntax = %+v, %v", schools, err)
+	}
+
+	// Pathways round-trip through the cache as structured fields
+	data, err := (&AIScraperService{db: db}).loadCachedData("360000100002", cacheNoExpiry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data.CTEPathways) != 1 || len(data.DualEnrollment) != 1 || data.DualEnrollment[0].Partner != "Foothill College" {
+		t.Errorf("cached CTE = %+v, %+v", data.CTEPathways, data.DualEnrollment)
+	}
+
+	// Re-extraction replaces the school's rows
+	if err := saveEnhancedData(db, &EnhancedSchoolData{NCESSCH: "360000100003", MarkdownContent: "No pathways listed.", ExtractedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	if got := search("hospitality"); got != "" {
+		t.Errorf("hospitality after re-extraction = %s", got)
+	}
+
+	filters, err := SearchFiltersFromValues(url.Values{"cte": {"aviation, health sciences"}})
+	if err != nil || filters.Summary() != "with aviation or health sciences CTE" || filters.DrawerFilterCount() != 1 {
+		t.Errorf("filters = %+v, %v", filters, err)
+	}
+
+	// The search page suggests published pathways, and school pages list them
+	router := NewRouter(ServerConfig{DB: db, AIScraper: &AIScraperService{db: db, cacheTTL: time.Hour}})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if !strings.Contains(rec.Body.String(), `<option value="Aviation Maintenance">`) {
+		t.Error("search page is missing CTE pathway suggestions")
+	}
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/schools/360000100002/ai", nil))
+	if body := rec.Body.String(); !strings.Contains(body, "Career &amp; Technical Education") || !strings.Contains(body, "Foothill College") {
+		t.Errorf("website data is missing CTE programs: %s", body)
+	}
+}
@@ -0,0 +1,708 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The data dictionary describes every table and column in the database. The
+// descriptions below are applied as table and column comments, so everything
+// that reads the schema sees them: the Data Explorer's schema tool, the
+// /docs/schema page, and `schoolfinder schema`. Comments written where a table
+// is created, and those generated for imported tables, take precedence.
+
+// TableDoc describes a table and its columns
+type TableDoc struct {
+	Name        string      `json:"table_name"`
+	Description string      `json:"description,omitempty"`
+	Columns     []ColumnDoc `json:"columns"`
+}
+
+// ColumnDoc describes a column
+type ColumnDoc struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Nullable    bool   `json:"nullable"`
+	Description string `json:"description,omitempty"`
+}
+
+// ccdColumnDocs describes the columns of the CCD school files, from the NCES
+// file layouts for the 2023-24 directory (029), membership (052), and staff
+// (059) files. Every value is loaded as text.
+var ccdColumnDocs = map[string]string{
+	"SCHOOL_YEAR":         "School year of the record, e.g. 2023-2024",
+	"FIPST":               "Two-digit state FIPS code",
+	"STATENAME":           "State name in capitals, e.g. CALIFORNIA",
+	"ST":                  "Two-letter state abbreviation, e.g. CA; filter states with this column",
+	"SCH_NAME":            "School name",
+	"LEA_NAME":            "Name of the school's district (local education agency)",
+	"STATE_AGENCY_NO":     "State education agency number",
+	"UNION":               "Supervisory union number, in states whose districts share administration",
+	"ST_LEAID":            "The state's own district ID",
+	"LEAID":               "Seven-digit NCES district ID, the first seven digits of NCESSCH",
+	"ST_SCHID":            "The state's own school ID",
+	"NCESSCH":             "Twelve-digit NCES school ID; joins every table keyed by school",
+	"SCHID":               "Seven-digit NCES school ID within the state: the state FIPS code and school number",
+	"MSTREET1":            "Mailing address, first line",
+	"MSTREET2":            "Mailing address, second line",
+	"MSTREET3":            "Mailing address, third line",
+	"MCITY":               "Mailing address city; the city shown for the school",
+	"MSTATE":              "Mailing address state abbreviation",
+	"MZIP":                "Mailing address five-digit ZIP code",
+	"MZIP4":               "Mailing address ZIP+4 extension",
+	"LSTREET1":            "Location (physical) address, first line",
+	"LSTREET2":            "Location address, second line",
+	"LSTREET3":            "Location address, third line",
+	"LCITY":               "Location address city",
+	"LSTATE":              "Location address state abbreviation",
+	"LZIP":                "Location address five-digit ZIP code",
+	"LZIP4":               "Location address ZIP+4 extension",
+	"PHONE":               "School phone number",
+	"WEBSITE":             "School website as reported by the state",
+	"SY_STATUS":           "Status code at the start of the school year (1 open, 2 closed, 3 new, 4 added, 5 changed agency, 6 inactive, 7 future, 8 reopened)",
+	"SY_STATUS_TEXT":      "Status at the start of the school year, e.g. Open or New",
+	"UPDATED_STATUS":      "Status code as of the end of the collection, same codes as SY_STATUS",
+	"UPDATED_STATUS_TEXT": "Status as of the end of the collection",
+	"EFFECTIVE_DATE":      "Date the updated status took effect",
+	"SCH_TYPE":            "School type code (1 regular, 2 special education, 3 career and technical, 4 alternative)",
+	"SCH_TYPE_TEXT":       "School type, e.g. Regular School or Alternative Education School",
+	"RECON_STATUS":        "Whether the school was reconstituted (restructured) this year",
+	"OUT_OF_STATE_FLAG":   "Whether the school is located in another state than its agency",
+	"CHARTER_TEXT":        "Whether the school is a charter school: Yes, No, or Not applicable",
+	"CHARTAUTH1":          "ID of the charter school's first authorizer",
+	"CHARTAUTHN1":         "Name of the charter school's first authorizer",
+	"CHARTAUTH2":          "ID of the charter school's second authorizer, if any",
+	"CHARTAUTHN2":         "Name of the charter school's second authorizer, if any",
+	"NOGRADES":            "Whether the school reports no grades offered",
+	"G_PK_OFFERED":        "Whether prekindergarten is offered: Yes or No",
+	"G_KG_OFFERED":        "Whether kindergarten is offered: Yes or No",
+	"G_1_OFFERED":         "Whether grade 1 is offered: Yes or No",
+	"G_2_OFFERED":         "Whether grade 2 is offered: Yes or No",
+	"G_3_OFFERED":         "Whether grade 3 is offered: Yes or No",
+	"G_4_OFFERED":         "Whether grade 4 is offered: Yes or No",
+	"G_5_OFFERED":         "Whether grade 5 is offered: Yes or No",
+	"G_6_OFFERED":         "Whether grade 6 is offered: Yes or No",
+	"G_7_OFFERED":         "Whether grade 7 is offered: Yes or No",
+	"G_8_OFFERED":         "Whether grade 8 is offered: Yes or No",
+	"G_9_OFFERED":         "Whether grade 9 is offered: Yes or No",
+	"G_10_OFFERED":        "Whether grade 10 is offered: Yes or No",
+	"G_11_OFFERED":        "Whether grade 11 is offered: Yes or No",
+	"G_12_OFFERED":        "Whether grade 12 is offered: Yes or No",
+	"G_13_OFFERED":        "Whether grade 13 is offered: Yes or No",
+	"G_UG_OFFERED":        "Whether ungraded classes are offered: Yes or No",
+	"G_AE_OFFERED":        "Whether adult education is offered: Yes or No",
+	"GSLO":                "Lowest grade offered: PK, KG, 01-13, UG (ungraded), AE (adult education), or N (none)",
+	"GSHI":                "Highest grade offered, same codes as GSLO",
+	"LEVEL":               "School level derived from the grades offered: Elementary, Middle, High, Secondary, Prekindergarten, Adult Education, Ungraded, Other, or Not reported",
+	"IGOFFERED":           "Whether the grades offered were reported by the state or derived by NCES",
+	"GRADE":               "Grade of the count, e.g. Grade 5, or No Category Codes for totals",
+	"RACE_ETHNICITY":      "Race or ethnicity of the count, or No Category Codes for totals",
+	"SEX":                 "Sex of the count, or No Category Codes for totals",
+	"STUDENT_COUNT":       "Number of students in the category; cast to a number before doing arithmetic",
+	"TOTAL_INDICATOR":     "Which breakdown the row is; use 'Education Unit Total' for the school's total enrollment",
+	"TEACHERS":            "Full-time equivalent (FTE) classroom teachers",
+	"DMS_FLAG":            "Data reporting status: Reported, Not reported, Missing, Not applicable, or Derived",
+}
+
+// dataDictionary describes each table and its columns. CCD tables use
+// ccdColumnDocs for their columns.
+var dataDictionary = []struct {
+	Table       string
+	Description string
+	Columns     map[string]string
+}{
+	{"directory", "One row per school from the CCD 2023-24 school directory file: names, addresses, contacts, type, level, and grades", ccdColumnDocs},
+	{"enrollment", "Student counts per school from the CCD 2023-24 membership file, broken down by grade, race, and sex; join on NCESSCH with TOTAL_INDICATOR = 'Education Unit Total' for each school's total", ccdColumnDocs},
+	{"teachers", "Full-time equivalent teachers per school from the CCD 2023-24 staff file", ccdColumnDocs},
+	{"search_index", "", map[string]string{
+		"NCESSCH":       "NCES school ID",
+		"SCH_NAME":      "School name",
+		"LEA_NAME":      "District name",
+		"MCITY":         "City, with any user correction applied",
+		"MSTREET1":      "Street address, with any user correction applied",
+		"MZIP":          "ZIP code, with any user correction applied",
+		"WEBSITE_TEXT":  "Text of the school's website, from AI extraction",
+		"IMPORTED_TEXT": "Searchable text from the user's imported tables",
+	}},
+	{"ai_scraper_cache", "Data extracted with AI from each school's website", map[string]string{
+		"ncessch":          "NCES school ID",
+		"school_name":      "School name when extracted",
+		"extracted_at":     "When the website was read",
+		"source_url":       "Page the data was extracted from",
+		"markdown_content": "The website's text as markdown",
+		"legacy_data":      "Extracted fields as JSON: principal, contacts, programs, sports, clubs, hours, and more",
+		"created_at":       "When the row was first saved",
+	}},
+	{"ai_data_edits", "History of hand edits to the website data extracted with AI", map[string]string{
+		"id":        "Edit ID",
+		"ncessch":   "NCES school ID",
+		"field":     "JSON name of the edited field, e.g. principal",
+		"old_value": "Value before the edit",
+		"new_value": "Value after the edit",
+		"source":    "Where the edit was made: web or editor",
+		"edited_at": "When the edit was made",
+	}},
+	{"naep_cache", "NAEP (Nation's Report Card) results fetched for each school's state and district", map[string]string{
+		"ncessch":         "NCES school ID",
+		"state":           "State jurisdiction the results are for",
+		"district":        "Large-city district jurisdiction, if the school's district takes part in NAEP",
+		"extracted_at":    "When the results were fetched from the NAEP API",
+		"state_scores":    "State results as JSON",
+		"district_scores": "District results as JSON",
+		"national_scores": "National results as JSON",
+		"created_at":      "When the row was first saved",
+	}},
+	{"naep_raw_responses", "Raw NAEP API responses, kept for troubleshooting", map[string]string{
+		"id":           "Response ID",
+		"url":          "Request URL",
+		"jurisdiction": "NAEP jurisdiction code, e.g. CA or a district code",
+		"subject":      "Subject, e.g. mathematics or reading",
+		"grade":        "Grade tested: 4, 8, or 12",
+		"stat_type":    "Statistic requested, e.g. MN:MN for mean scores",
+		"http_status":  "HTTP status of the response",
+		"body":         "Response body",
+		"fetched_at":   "When the response was received",
+	}},
+	{"naep_overrides", "Per-school NAEP settings", map[string]string{
+		"ncessch":            "NCES school ID",
+		"disable_auto_fetch": "Whether NAEP data is only fetched on request for this school",
+		"jurisdiction":       "NAEP jurisdiction to use instead of the school's state or district",
+		"updated_at":         "When the settings were changed",
+	}},
+	{"naep_alerts", "NAEP score declines found for schools' states and districts", map[string]string{
+		"id":             "Alert ID",
+		"ncessch":        "NCES school ID",
+		"school_name":    "School name",
+		"jurisdiction":   "NAEP jurisdiction whose score declined",
+		"subject":        "Subject, e.g. mathematics or reading",
+		"grade":          "Grade tested",
+		"previous_year":  "Earlier assessment year",
+		"current_year":   "Later assessment year",
+		"previous_score": "Mean scale score in the earlier year",
+		"current_score":  "Mean scale score in the later year",
+		"change":         "Score change, negative for a decline",
+		"dismissed":      "Whether the user dismissed the alert",
+		"created_at":     "When the decline was found",
+	}},
+	{"parent_summary_cache", "Plain-language school summaries for parents, written with AI", map[string]string{
+		"ncessch":      "NCES school ID",
+		"summary":      "Summary in markdown",
+		"generated_at": "When the summary was written",
+		"created_at":   "When the row was first saved",
+	}},
+	{"district_contacts", "Superintendent, school board, and enrollment office contacts extracted from district websites with AI", map[string]string{
+		"leaid":             "NCES district (LEA) ID; joins directory.LEAID",
+		"district_name":     "District name",
+		"source_url":        "The district website the contacts were found on",
+		"superintendent":    "JSON contact: name, title, email, phone",
+		"board_members":     "JSON list of school board member contacts",
+		"enrollment_office": "JSON list of enrollment or registration office contacts",
+		"markdown_content":  "Extracted contacts in markdown",
+		"extracted_at":      "When the contacts were extracted",
+	}},
+	{"feeder_inferences", "Where each school's students go next, inferred from school and district websites with AI; not official boundary data", map[string]string{
+		"ncessch":          "NCES school ID",
+		"school_name":      "School name",
+		"markdown_content": "Inferred feeder pattern in markdown",
+		"inferred_at":      "When the feeder pattern was inferred",
+	}},
+	{"inferred_feeders", "Each school an AI inference says a school feeds into, with a confidence level", map[string]string{
+		"from_ncessch": "NCES ID of the feeding school",
+		"to_name":      "Name of the school it feeds into, as the website gives it",
+		"to_ncessch":   "NCES ID of the directory school matching to_name, if exactly one matched",
+		"confidence":   "high (an official page states it), medium (unofficial or implied), or low (suggested by location or names)",
+		"evidence":     "What the website says",
+		"source_url":   "Page the evidence came from",
+		"inferred_at":  "When the feeder pattern was inferred",
+	}},
+	{"school_calendars", "Academic calendars (first and last day, breaks, holidays) extracted from school and district websites with AI", map[string]string{
+		"ncessch":          "NCES school ID",
+		"school_name":      "School name",
+		"school_year":      "School year the calendar covers, e.g. 2025-2026",
+		"source_url":       "The calendar page or PDF",
+		"events":           "JSON list of events: kind (first_day, last_day, break, holiday), name, start, and end",
+		"markdown_content": "Extracted calendar in markdown",
+		"extracted_at":     "When the calendar was extracted",
+	}},
+	{"saved_searches", "Searches the user saved, optionally checked for changed results", map[string]string{
+		"id":         "Saved search ID",
+		"name":       "Name the user gave the search",
+		"filters":    "Search filters as JSON",
+		"subscribed": "Whether the user is alerted when the results change",
+		"result_ids": "NCESSCH IDs of the last results, as JSON",
+		"checked_at": "When the results were last checked",
+		"created_at": "When the search was saved",
+	}},
+	{"saved_search_changes", "Schools that entered or left a saved search's results", map[string]string{
+		"id":          "Change ID",
+		"search_id":   "saved_searches.id",
+		"added":       "NCESSCH IDs that joined the results, as JSON",
+		"removed":     "NCESSCH IDs that left the results, as JSON",
+		"detected_at": "When the change was found",
+	}},
+	{"bench_results", "Timings from `schoolfinder bench` runs", map[string]string{
+		"run_at":     "When the run started",
+		"version":    "App version that ran",
+		"name":       "Operation timed",
+		"iterations": "Timed iterations",
+		"p50_ms":     "Median time in milliseconds",
+		"p95_ms":     "95th percentile time in milliseconds",
+		"max_ms":     "Slowest time in milliseconds",
+		"budget_ms":  "The operation's p95 budget in milliseconds",
+		"skipped":    "Why the operation was skipped, if it was",
+	}},
+	{"directory_history", "One row per school per loaded CCD directory year, for finding openings, closings, and renames", map[string]string{
+		"school_year": "School year, e.g. 2022-2023",
+		"ncessch":     "NCES school ID",
+		"name":        "School name that year",
+		"state":       "State abbreviation",
+		"city":        "City",
+		"district":    "District name",
+		"street":      "Street address",
+		"zip":         "ZIP code",
+	}},
+	{"directory_history_files", "CCD directory files loaded into directory_history", map[string]string{
+		"filename":  "File name",
+		"loaded_at": "When the file was loaded",
+	}},
+	{"school_year_changes", "Schools that opened, closed, were renamed, or changed ID between the last two loaded years", map[string]string{
+		"kind":             "opened, closed, renamed, or id_changed",
+		"ncessch":          "NCES school ID",
+		"name":             "School name",
+		"state":            "State abbreviation",
+		"city":             "City",
+		"from_year":        "Earlier school year",
+		"to_year":          "Later school year",
+		"previous_ncessch": "Former NCES school ID, for ID changes",
+		"previous_name":    "Former name, for renames",
+	}},
+	{"school_geocodes", "Each school's metro area from NCES EDGE geocode files", map[string]string{
+		"ncessch":   "NCES school ID",
+		"cbsa":      "Core-based statistical area (metro area) code",
+		"cbsa_name": "Metro area name",
+	}},
+	{"geocode_files", "NCES EDGE geocode files loaded", map[string]string{
+		"filename":  "File name",
+		"loaded_at": "When the file was loaded",
+	}},
+	{"school_coordinates", "Each school's location from NCES EDGE geocode files, or geocoded from its address by db geocode", map[string]string{
+		"ncessch": "NCES school ID",
+		"lat":     "Latitude",
+		"lon":     "Longitude",
+	}},
+	{"school_counties", "Each school's county from NCES EDGE geocode files (or the CCD directory's CNTY), for county pages and time zones", map[string]string{
+		"ncessch":     "NCES school ID",
+		"county":      "County FIPS code (state and county, 5 digits)",
+		"county_name": "County name, e.g. Cook County; names repeat across states",
+	}},
+	{"school_congressional_districts", "Each school's congressional district from NCES EDGE geocode files (or the CCD directory's CD)", map[string]string{
+		"ncessch": "NCES school ID",
+		"cd":      "State FIPS code and district number (4 digits); district 00 is an at-large seat and 98 a non-voting delegate",
+	}},
+	{"geocodes", "Cache of addresses geocoded by db geocode, including those that weren't found", map[string]string{
+		"address":         "Normalized address (uppercase, single spaces)",
+		"provider":        "census, nominatim, or zip (the average location of the zip code's schools)",
+		"lat":             "Latitude; NULL when the address wasn't found",
+		"lon":             "Longitude; NULL when the address wasn't found",
+		"matched_address": "The address or place the provider matched",
+		"precision":       "address, city (a PO box address placed by city and zip), or zip",
+		"geocoded_at":     "When the address was geocoded",
+	}},
+	{"school_boundaries", "Attendance boundaries from NCES School Attendance Boundary Survey (SABS) files", map[string]string{
+		"ncessch":    "NCES school ID",
+		"level":      "SABS level: 1 primary, 2 middle, 3 high, 4 other",
+		"grade_low":  "Lowest grade the boundary is drawn for",
+		"grade_high": "Highest grade the boundary is drawn for",
+		"min_lat":    "Southern edge of the boundary",
+		"min_lon":    "Western edge of the boundary",
+		"max_lat":    "Northern edge of the boundary",
+		"max_lon":    "Eastern edge of the boundary",
+		"polygons":   "JSON list of polygons, each an outer ring and its holes as [lon, lat] pairs",
+		"source":     "File the boundary was loaded from",
+	}},
+	{"boundary_files", "SABS boundary files loaded", map[string]string{
+		"filename":  "File name",
+		"loaded_at": "When the file was loaded",
+	}},
+	{"school_feeders", "Which schools feed into which, from district feeder tables", map[string]string{
+		"from_ncessch": "NCES ID of the feeding school",
+		"to_ncessch":   "NCES ID of the school it feeds into",
+		"source":       "File the row was loaded from",
+	}},
+	{"feeder_files", "District feeder tables loaded", map[string]string{
+		"filename":  "File name",
+		"loaded_at": "When the file was loaded",
+	}},
+	{"prek_programs", "Pre-K and Head Start program sites from state locator exports", map[string]string{
+		"name":         "Program or site name",
+		"program_type": "Kind of program, e.g. Head Start or State Pre-K",
+		"street":       "Street address",
+		"city":         "City",
+		"state":        "State code",
+		"zip":          "Zip code",
+		"phone":        "Phone number",
+		"website":      "Website",
+		"ncessch":      "NCES ID of the public school the program is housed in, if any",
+		"source":       "File the row was loaded from",
+	}},
+	{"prek_files", "Pre-K and Head Start locator files loaded", map[string]string{
+		"filename":  "File name",
+		"loaded_at": "When the file was loaded",
+	}},
+	{"enrollment_history", "Total enrollment per school for each loaded CCD membership year", map[string]string{
+		"school_year": "School year, e.g. 2022-2023",
+		"ncessch":     "NCES school ID",
+		"students":    "Total students",
+	}},
+	{"enrollment_history_files", "CCD membership files loaded into enrollment_history", map[string]string{
+		"filename":  "File name",
+		"loaded_at": "When the file was loaded",
+	}},
+	{"data_versions", "Each CCD release file loaded, for stamping exports and querying past school years", map[string]string{
+		"filename":    "File name",
+		"dataset":     "directory, membership, or staff",
+		"school_year": "School year, e.g. 2022-2023",
+		"released":    "NCES release date, from the file name",
+		"loaded_at":   "When the file was first loaded",
+		"rows":        "Rows loaded from the file",
+	}},
+	{"ccd_release_checks", "The last check of the NCES file listing for newer CCD releases", map[string]string{
+		"checked_at":   "When NCES was checked",
+		"new_releases": "JSON list of the releases newer than the loaded files",
+	}},
+	{"enrollment_trends", "Enrollment growth pressure per school from enrollment_history", map[string]string{
+		"ncessch":       "NCES school ID",
+		"pressure":      "growing, stable, or shrinking",
+		"annual_change": "Average yearly change as a fraction, e.g. 0.03 for 3% growth",
+	}},
+	{"enrollment_projections", "", map[string]string{
+		"ncessch":     "NCES school ID",
+		"school_year": "School year projected",
+		"method":      "Projection method",
+		"projected":   "Projected total enrollment",
+		"based_on":    "Loaded years the projection is based on",
+	}},
+	{"district_enrollment_projections", "", map[string]string{
+		"leaid":       "NCES district ID, matching directory.LEAID",
+		"school_year": "School year projected",
+		"method":      "Projection method",
+		"projected":   "Projected total enrollment",
+		"based_on":    "Loaded years the projection is based on",
+	}},
+	{"school_percentiles", "", map[string]string{
+		"ncessch": "NCES school ID",
+		"value":   "The school's value of the metric",
+		"state":   "State abbreviation",
+		"level":   "School level, as in directory.LEVEL",
+	}},
+	{"overview_stats", "", map[string]string{
+		"key":             "The section's key: US, a state code, a level, or a LEAID",
+		"name":            "Display name of the key",
+		"state":           "State abbreviation, for districts",
+		"schools":         "Number of schools",
+		"charter_schools": "Number of charter schools",
+		"students":        "Total students",
+		"computed_at":     "When the totals were computed",
+	}},
+	{"district_staff", "Staffing composition per district from the CCD district staff file", map[string]string{
+		"leaid":       "NCES district ID, matching directory.LEAID",
+		"staff_group": "Staff category, e.g. Teachers or Counselors",
+		"fte":         "Full-time equivalent staff in the category",
+	}},
+	{"staff_files", "CCD district staff files loaded into district_staff", map[string]string{
+		"filename":  "File name",
+		"loaded_at": "When the file was loaded",
+	}},
+	{"school_corrections", "User corrections of CCD directory fields, applied when schools are loaded", map[string]string{
+		"ncessch":    "NCES school ID",
+		"field":      "Corrected field: phone, website, street, city, or zip",
+		"value":      "Corrected value; empty clears the field",
+		"note":       "Why the value was corrected",
+		"updated_at": "When the correction was saved",
+	}},
+	{"correction_suggestions", "Corrections suggested by visitors, waiting for an admin's review", map[string]string{
+		"id":          "Suggestion ID",
+		"ncessch":     "NCES school ID",
+		"school_name": "School name",
+		"field":       "Field to correct, as in school_corrections.field",
+		"value":       "Suggested value",
+		"old_value":   "Value shown when suggested",
+		"comment":     "The visitor's comment",
+		"submitter":   "Who suggested it, if they said",
+		"status":      "pending, approved, or rejected",
+		"created_at":  "When it was suggested",
+		"reviewed_at": "When an admin reviewed it",
+	}},
+	{"school_merges", "Duplicate school records merged into a canonical record", map[string]string{
+		"duplicate_ncessch": "NCES ID of the duplicate record",
+		"canonical_ncessch": "NCES ID of the record it was merged into",
+		"note":              "Why the records were merged",
+		"merged_at":         "When they were merged",
+	}},
+	{"school_distinct", "Pairs of schools the user marked as not duplicates", map[string]string{
+		"ncessch_a": "NCES ID of one school",
+		"ncessch_b": "NCES ID of the other school",
+	}},
+	{"website_checks", "Results of checking whether school websites still work", map[string]string{
+		"ncessch":     "NCES school ID",
+		"url":         "Website checked",
+		"status":      "ok, dead, redirected, or unknown",
+		"status_code": "HTTP status of the last response",
+		"final_url":   "Where the website redirected to",
+		"error":       "Why the check failed",
+		"checked_at":  "When the website was checked",
+	}},
+	{"school_dates", "Key dates for school applications, e.g. open houses, tours, and deadlines", map[string]string{
+		"id":         "Date ID",
+		"ncessch":    "NCES school ID",
+		"kind":       "open_house, tour, application_deadline, lottery, decision, enrollment_deadline, or other",
+		"date":       "The date",
+		"note":       "Details, e.g. the time or place",
+		"created_at": "When the date was added",
+	}},
+	{"school_calls", "The user's call log: calls to schools and what they said", map[string]string{
+		"id":         "Call ID",
+		"ncessch":    "NCES school ID",
+		"called_at":  "When the call was made, local time",
+		"note":       "What the school said",
+		"created_at": "When the call was logged",
+	}},
+	{"outreach", "The user's emails to schools and whether they've replied", map[string]string{
+		"id":           "Outreach ID",
+		"ncessch":      "NCES school ID",
+		"recipient":    "Email address written to",
+		"subject":      "Email subject",
+		"template":     "Inquiry template the email was drafted from, if any",
+		"status":       "awaiting_reply, replied, no_reply, or closed",
+		"sent_on":      "When the email was sent",
+		"follow_up_on": "When to follow up if there's no reply",
+		"note":         "The user's notes, e.g. what the reply said",
+		"nudged_at":    "When the server last sent a follow-up reminder",
+		"updated_at":   "When the outreach was last changed",
+	}},
+	{"jobs", "Long-running jobs such as bulk saves, recorded as they run so they can be resumed", map[string]string{
+		"id":         "Job ID",
+		"kind":       "What the job does, e.g. bulk_save",
+		"status":     "queued, running, done, or failed; a job left running was cut short unless it's still going",
+		"options":    "JSON of what the job was started with, to run the rest of it again",
+		"error":      "Why the job failed",
+		"created_at": "When the job started",
+		"updated_at": "When the job's status last changed",
+	}},
+	{"job_items", "Each unit of a job's work, such as one school's dossier, and its progress", map[string]string{
+		"job_id":     "Job ID",
+		"position":   "Order of the item in the job",
+		"item_key":   "What the item is for, e.g. an NCES school ID",
+		"status":     "queued, running, done, or failed",
+		"error":      "Why the item failed",
+		"result":     "JSON of the item's result, e.g. its manifest entry",
+		"updated_at": "When the item's status last changed",
+	}},
+	{"applications", "The user's school choice applications", map[string]string{
+		"id":         "Application ID",
+		"season":     "School year applied for, e.g. 2027-28",
+		"ncessch":    "NCES school ID",
+		"status":     "planning, applied, waitlisted, offered, accepted, declined, not_offered, or withdrawn",
+		"priorities": "Priority categories claimed, as JSON, e.g. sibling",
+		"seats":      "Seats available",
+		"applicants": "Applicants for those seats",
+		"weight":     "Lottery entries per applicant from priorities",
+		"notes":      "The user's notes",
+		"updated_at": "When the application was last changed",
+	}},
+	{"school_program_flags", "Programs each school offers, from CCD school types and names and from website text", map[string]string{
+		"ncessch":  "NCES school ID",
+		"flag":     "special_ed, gifted, dual_language, ib, or montessori",
+		"source":   "ccd or website",
+		"evidence": "The text the program was found in",
+	}},
+	{"school_cte_pathways", "Career and technical education pathways from high school websites", map[string]string{
+		"ncessch":     "NCES school ID",
+		"pathway":     "Pathway name, e.g. Aviation Maintenance",
+		"cluster":     "National Career Cluster, e.g. Health Science",
+		"credentials": "Certifications or credit earned",
+	}},
+	{"school_dual_enrollment", "Dual-enrollment, early college, and articulated credit partnerships from high school websites", map[string]string{
+		"ncessch": "NCES school ID",
+		"partner": "College or university",
+		"kind":    "Dual enrollment, early college, or articulated credit",
+		"details": "What students earn or take",
+	}},
+	{"school_sports", "Sports teams from school websites, normalized to canonical sport names", map[string]string{
+		"ncessch": "NCES school ID",
+		"sport":   "Canonical sport, e.g. Swimming or Track & Field",
+		"season":  "fall, winter, spring, or summer; empty where not published and it varies by state",
+		"level":   "Varsity, JV, Freshman, Middle School, Intramural, or Club; empty where not published",
+	}},
+	{"school_athletic_conferences", "The athletic conference or league each school competes in, from school websites", map[string]string{
+		"ncessch":    "NCES school ID",
+		"conference": "Conference or league, e.g. West Bay Athletic League",
+	}},
+	{"school_arts", "Arts and music programs from school websites, normalized to canonical program names; count(DISTINCT discipline) is a school's arts coverage score out of 6", map[string]string{
+		"ncessch":    "NCES school ID",
+		"program":    "Canonical program, e.g. Band, Orchestra, Theater, or Ceramics",
+		"discipline": "Instrumental Music, Vocal Music, Theater, Visual Arts, Dance, or Media Arts; empty for other programs",
+	}},
+	{"school_languages", "Languages taught at each school, from school websites, normalized to canonical names; one row per school and language", map[string]string{
+		"ncessch":   "NCES school ID",
+		"language":  "Canonical language, e.g. Spanish, Mandarin, French, or American Sign Language",
+		"immersion": "True for immersion or dual-language programs, where subjects are taught in the language; false for world-language courses",
+		"grades":    "Grades the program serves as the school publishes them, e.g. K-5; empty if not published",
+	}},
+	{"data_quality_flags", "Anomalies the data-quality pass finds in CCD records at startup; one row per school and flag. Flagged enrollment and ratio values are left out of search result averages by default", map[string]string{
+		"ncessch": "NCES school ID",
+		"flag":    "zero_enrollment (0 students with 10+ teachers), extreme_ratio (over 100 students per teacher), missing_website (no website for 1,000+ students), or grade_range (lowest grade above the highest)",
+		"field":   "Field the flag is about: enrollment, ratio, website, or grades",
+		"detail":  "The values involved, e.g. 0 students, 40 teachers",
+	}},
+	{"children", "The user's child profiles", map[string]string{
+		"id":         "Child ID",
+		"name":       "Child's name",
+		"grade":      "Grade the child is entering, e.g. KG or 06",
+		"needs":      "Needs to look for, as JSON, e.g. IEP",
+		"created_at": "When the profile was added",
+	}},
+	{"child_schools", "Schools saved to each child's list", map[string]string{
+		"child_id": "children.id",
+		"ncessch":  "NCES school ID",
+		"added_at": "When the school was saved",
+	}},
+	{"sync_peers", "When schoolfinder sync last merged caches, favorites, and notes with each peer", map[string]string{
+		"peer":      "The peer's data directory or server URL",
+		"synced_at": "When the last sync started; the next one sends only rows changed since then",
+	}},
+	{"bus_rules", "Distance rules for school bus eligibility, by state or district", map[string]string{
+		"id":         "Rule ID",
+		"scope":      "State code, or seven-digit LEAID for a district",
+		"grade_low":  "Lowest grade the rule covers; empty for all",
+		"grade_high": "Highest grade the rule covers; empty for all",
+		"miles":      "Students living farther than this many miles are eligible",
+		"source":     "Where the rule comes from",
+		"created_at": "When the rule was added",
+	}},
+	{"home_location", "The user's home location, for distances to schools", map[string]string{
+		"id":         "Always 1",
+		"lat":        "Latitude",
+		"lon":        "Longitude",
+		"label":      "Address or name of the location",
+		"updated_at": "When it was set",
+	}},
+	{"school_safety", "Safety and discipline figures imported from state reports", map[string]string{
+		"ncessch":     "NCES school ID",
+		"school_year": "School year of the figure",
+		"metric":      "What was counted, as named in the report",
+		"value":       "The figure",
+		"source":      "Who published the report",
+		"filename":    "File the figure was imported from",
+	}},
+	{"safety_files", "State safety report files imported into school_safety", map[string]string{
+		"filename":   "File name",
+		"source":     "Who published the report",
+		"key_column": "Column that identified schools",
+		"key_type":   "What the key column held: ncessch, state_id, or name",
+		"matched":    "Rows matched to schools",
+		"rows":       "Rows in the file",
+		"loaded_at":  "When the file was imported",
+	}},
+	{"state_ratings", "Ratings from state report cards", map[string]string{
+		"ncessch":     "NCES school ID",
+		"state":       "State abbreviation",
+		"source":      "State report card source",
+		"school_year": "School year rated",
+		"indicator":   "What is rated, e.g. Overall or Chronic Absenteeism",
+		"rating":      "The state's rating, e.g. a color or letter grade",
+		"score":       "Numeric score, if the state publishes one",
+		"source_url":  "Where the rating was published",
+		"fetched_at":  "When the rating was fetched",
+	}},
+	{"state_rating_refreshes", "Refreshes of state report card ratings", map[string]string{
+		"source":       "State report card source",
+		"school_year":  "School year refreshed",
+		"files":        "Files or URLs read",
+		"ratings":      "Ratings read",
+		"matched":      "Ratings matched to schools",
+		"refreshed_at": "When the refresh ran",
+	}},
+	{"imported_tables", "", map[string]string{
+		"table_name":  "Name of the imported table",
+		"description": "The user's description of the data",
+		"source_file": "Uploaded file name",
+		"row_count":   "Rows imported",
+		"imported_at": "When the file was imported",
+	}},
+	{"usage_settings", "Whether the user opted in to counting feature usage", map[string]string{
+		"id":               "Always 1",
+		"enabled":          "Whether usage is counted",
+		"changed_at":       "When the setting changed",
+		"uploaded_through": "Last day whose counts were sent",
+	}},
+	{"usage_counts", "Daily counts of feature usage, kept only when the user opts in", map[string]string{
+		"event": "search, scrape, or agent_query",
+		"day":   "Day counted",
+		"count": "Times the feature was used",
+	}},
+}
+
+// SyncDataDictionary adds the data dictionary's descriptions as comments on
+// tables and columns that have none
+func SyncDataDictionary(d *DB) error {
+	tables, err := d.DataDictionary("")
+	if err != nil {
+		return err
+	}
+
+	var statements []string
+	for _, entry := range dataDictionary {
+		for _, t := range tables {
+			if t.Name != entry.Table {
+				continue
+			}
+			if t.Description == "" && entry.Description != "" {
+				statements = append(statements, fmt.Sprintf("COMMENT ON TABLE %s IS %s", quoteIdent(t.Name), quoteLiteral(entry.Description)))
+			}
+			for _, c := range t.Columns {
+				if desc := entry.Columns[c.Name]; c.Description == "" && desc != "" {
+					statements = append(statements, fmt.Sprintf("COMMENT ON COLUMN %s.%s IS %s", quoteIdent(t.Name), quoteIdent(c.Name), quoteLiteral(desc)))
+				}
+			}
+		}
+	}
+	if len(statements) == 0 {
+		return nil
+	}
+	if _, err := d.conn.Exec(strings.Join(statements, ";\n")); err != nil {
+		return fmt.Errorf("failed to describe tables: %w", err)
+	}
+	return nil
+}
+
+// DataDictionary describes the tables in the database, or only the named
+// table, with their comments
+func (d *DB) DataDictionary(table string) ([]TableDoc, error) {
+	rows, err := d.conn.Query(`
+		SELECT t.table_name, COALESCE(t.comment, ''), c.column_name, c.data_type, c.is_nullable, COALESCE(c.comment, '')
+		FROM duckdb_tables() t
+		JOIN duckdb_columns() c ON c.table_oid = t.table_oid
+		WHERE t.schema_name = 'main' AND NOT t.internal AND ($1 = '' OR t.table_name = $1)
+		ORDER BY t.table_name, c.column_index
+	`, table)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the schema: %w", err)
+	}
+	defer rows.Close()
+
+	var tables []TableDoc
+	for rows.Next() {
+		var name, desc string
+		var c ColumnDoc
+		if err := rows.Scan(&name, &desc, &c.Name, &c.Type, &c.Nullable, &c.Description); err != nil {
+			return nil, fmt.Errorf("failed to read the schema: %w", err)
+		}
+		if len(tables) == 0 || tables[len(tables)-1].Name != name {
+			tables = append(tables, TableDoc{Name: name, Description: desc})
+		}
+		t := &tables[len(tables)-1]
+		t.Columns = append(t.Columns, c)
+	}
+	return tables, rows.Err()
+}
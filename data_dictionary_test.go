@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDataDictionary(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	tables, err := db.DataDictionary("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, table := range tables {
+		if table.Description == "" {
+			t.Errorf("table %s has no description", table.Name)
+		}
+		for _, c := range table.Columns {
+			if c.Description == "" {
+				t.Errorf("column %s.%s has no description", table.Name, c.Name)
+			}
+		}
+	}
+
+	directory, err := db.DataDictionary("directory")
+	if err != nil || len(directory) != 1 {
+		t.Fatalf("DataDictionary(directory) = %+v, %v", directory, err)
+	}
+	for _, c := range directory[0].Columns {
+		if c.Name == "GSLO" && !strings.Contains(c.Description, "PK, KG") {
+			t.Errorf("GSLO description = %q", c.Description)
+		}
+	}
+
+	// Comments written elsewhere, as for imported tables, are kept
+	if _, err := db.conn.Exec(`COMMENT ON COLUMN directory.ST IS 'Custom note'`); err != nil {
+		t.Fatal(err)
+	}
+	if err := SyncDataDictionary(db); err != nil {
+		t.Fatal(err)
+	}
+	var comment string
+	if err := db.conn.QueryRow(`SELECT comment FROM duckdb_columns() WHERE table_name = 'directory' AND column_name = 'ST'`).Scan(&comment); err != nil || comment != "Custom note" {
+		t.Errorf("ST comment = %q, %v", comment, err)
+	}
+
+	router := NewRouter(ServerConfig{DB: db})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/docs/schema", nil))
+	body := rec.Body.String()
+	if rec.Code != 200 || !strings.Contains(body, `id="table-enrollment"`) || !strings.Contains(body, "Education Unit Total") {
+		t.Errorf("GET /docs/schema = %d:\n%s", rec.Code, body)
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"archive/zip"
+	"context"
 	"fmt"
 	"io"
 	"net/http"
@@ -109,8 +110,21 @@ func PromptUserForDownload(missing []DataFile) bool {
 	return response == "y" || response == "yes"
 }
 
-// DownloadFileWithProgress downloads a file with enhanced progress tracking
+// DownloadFileWithProgress downloads a file with enhanced progress tracking, starting
+// over when a transient failure interrupts the download
 func DownloadFileWithProgress(filepath string, url string, fileIndex, totalFiles int, fileSize, totalSize int64) error {
+	attempt := 0
+	return downloadRetry.Do(context.Background(), func() error {
+		attempt++
+		if attempt > 1 {
+			fmt.Printf("   Retrying download (attempt %d)...\n", attempt)
+		}
+		return downloadFile(filepath, url, fileIndex, totalFiles, fileSize)
+	})
+}
+
+// downloadFile makes one attempt at downloading a file, showing its progress
+func downloadFile(filepath string, url string, fileIndex, totalFiles int, fileSize int64) error {
 	// Create the file
 	out, err := os.Create(filepath)
 	if err != nil {
@@ -127,7 +141,7 @@ func DownloadFileWithProgress(filepath string, url string, fileIndex, totalFiles
 
 	// Check server response
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("bad status: %s", resp.Status)
+		return fmt.Errorf("bad status: %w", newHTTPStatusError(resp))
 	}
 
 	// Get the file size (use the provided size if available, otherwise use response)
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Data versions record each CCD release file loaded into the database, so
+// exports and reports can say which vintage they came from and analyses can be
+// pinned to an older school year with --as-of
+
+// CCD datasets, in data_versions
+const (
+	datasetDirectory  = "directory"
+	datasetMembership = "membership"
+	datasetStaff      = "staff"
+)
+
+// ccdDatasets maps the CCD file number in a file name to its dataset, e.g.
+// "029" in ccd_sch_029_2324_w_1a_073124.csv
+var ccdDatasets = map[string]string{
+	"029": datasetDirectory,
+	"052": datasetMembership,
+	"059": datasetStaff,
+}
+
+// DataVersion is a CCD release file loaded into the database
+type DataVersion struct {
+	Filename   string    `json:"filename"`
+	Dataset    string    `json:"dataset"`     // datasetDirectory, datasetMembership, or datasetStaff
+	SchoolYear string    `json:"school_year"` // e.g. "2023-2024"
+	Released   time.Time `json:"released"`    // NCES release date, from the file name
+	LoadedAt   time.Time `json:"loaded_at"`   // When the file was loaded; rebuilding the database loads it again
+	Rows       int64     `json:"rows"`
+}
+
+// String describes the version, e.g. "CCD 2023-2024 directory, released 2024-07-31"
+func (v DataVersion) String() string {
+	return fmt.Sprintf("CCD %s %s, released %s", v.SchoolYear, v.Dataset, v.Released.Format("2006-01-02"))
+}
+
+// ccdFileVersion reads a CCD file's dataset, school year, and release date from
+// its name, e.g. ccd_sch_052_2324_l_1a_073124.csv is 2023-24 membership
+// released July 31, 2024
+func ccdFileVersion(filename string) (DataVersion, error) {
+	v := DataVersion{Filename: filepath.Base(filename)}
+	parts := strings.Split(strings.TrimSuffix(v.Filename, filepath.Ext(v.Filename)), "_")
+	if len(parts) < 6 || ccdDatasets[parts[2]] == "" {
+		return v, fmt.Errorf("%s isn't a CCD school file", v.Filename)
+	}
+	v.Dataset = ccdDatasets[parts[2]]
+
+	year, err := ccdFileYear(v.Filename)
+	if err != nil {
+		return v, err
+	}
+	v.SchoolYear = year
+	if v.Released, err = time.Parse("010206", parts[len(parts)-1]); err != nil {
+		return v, fmt.Errorf("can't tell the release date of %s", v.Filename)
+	}
+	return v, nil
+}
+
+// SyncDataVersions records every loaded CCD file in data_versions: the
+// directory and membership files for each school year, and the staff file
+// behind the teachers table. A file keeps the time it was first recorded;
+// its row count is refreshed. It returns the number of newly recorded files.
+func SyncDataVersions(db *DB) (int, error) {
+	var versions []DataVersion
+	for _, table := range []string{"directory_history_files", "enrollment_history_files"} {
+		loaded, err := db.loadedCCDFiles(table)
+		if err != nil {
+			return 0, err
+		}
+		versions = append(versions, loaded...)
+	}
+	staff, err := ccdFileVersion(ccdStaffFile.Name)
+	if err != nil {
+		return 0, err
+	}
+	staff.LoadedAt = time.Now().UTC()
+	versions = append(versions, staff)
+
+	added := 0
+	for _, v := range versions {
+		var err error
+		switch v.Dataset {
+		case datasetDirectory:
+			err = db.conn.QueryRow(`SELECT count(*) FROM directory_history WHERE school_year = $1`, v.SchoolYear).Scan(&v.Rows)
+		case datasetMembership:
+			err = db.conn.QueryRow(`SELECT count(*) FROM enrollment_history WHERE school_year = $1`, v.SchoolYear).Scan(&v.Rows)
+		case datasetStaff:
+			err = db.conn.QueryRow(`SELECT count(*) FROM teachers`).Scan(&v.Rows)
+		}
+		if err != nil {
+			return added, fmt.Errorf("failed to count %s rows: %w", v.Filename, err)
+		}
+
+		var recorded int
+		if err := db.conn.QueryRow(`SELECT count(*) FROM data_versions WHERE filename = $1`, v.Filename).Scan(&recorded); err != nil {
+			return added, fmt.Errorf("failed to check data versions: %w", err)
+		}
+		if recorded > 0 {
+			if _, err := db.conn.Exec(`UPDATE data_versions SET rows = $2 WHERE filename = $1`, v.Filename, v.Rows); err != nil {
+				return added, fmt.Errorf("failed to update %s: %w", v.Filename, err)
+			}
+			continue
+		}
+		_, err = db.conn.Exec(`
+			INSERT INTO data_versions (filename, dataset, school_year, released, loaded_at, rows)
+			VALUES ($1, $2, $3, $4, $5, $6)
+		`, v.Filename, v.Dataset, v.SchoolYear, v.Released, v.LoadedAt, v.Rows)
+		if err != nil {
+			return added, fmt.Errorf("failed to record %s: %w", v.Filename, err)
+		}
+		added++
+	}
+	return added, nil
+}
+
+// loadedCCDFiles lists the versions of the CCD files recorded in a history
+// files table, with when each was loaded
+func (d *DB) loadedCCDFiles(table string) ([]DataVersion, error) {
+	rows, err := d.conn.Query(fmt.Sprintf(`SELECT filename, loaded_at FROM %s ORDER BY filename`, table))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	var versions []DataVersion
+	for rows.Next() {
+		var filename string
+		var loadedAt time.Time
+		if err := rows.Scan(&filename, &loadedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan %s: %w", table, err)
+		}
+		v, err := ccdFileVersion(filename)
+		if err != nil {
+			// Renamed files still load; they just can't be versioned
+			if logger != nil {
+				logger.Warn("Skipping unversioned CCD file", "file", filename, "error", err)
+			}
+			continue
+		}
+		v.LoadedAt = loadedAt.UTC()
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+// DataVersions lists the recorded CCD files, newest school year first. An
+// empty year lists every year.
+func (d *DB) DataVersions(year string) ([]DataVersion, error) {
+	rows, err := d.conn.Query(`
+		SELECT filename, dataset, school_year, released, loaded_at, rows
+		FROM data_versions
+		WHERE $1 = '' OR school_year = $1
+		ORDER BY school_year DESC, dataset
+	`, year)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list data versions: %w", err)
+	}
+	defer rows.Close()
+
+	var versions []DataVersion
+	for rows.Next() {
+		var v DataVersion
+		if err := rows.Scan(&v.Filename, &v.Dataset, &v.SchoolYear, &v.Released, &v.LoadedAt, &v.Rows); err != nil {
+			return nil, fmt.Errorf("failed to scan data version: %w", err)
+		}
+		v.Released, v.LoadedAt = v.Released.UTC(), v.LoadedAt.UTC()
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+// CurrentDataVersions lists the CCD files behind the directory, enrollment, and
+// teachers tables, which is what searches, pages, and exports show
+func (d *DB) CurrentDataVersions() ([]DataVersion, error) {
+	versions, err := d.DataVersions(currentSchoolYear())
+	if err != nil {
+		return nil, err
+	}
+	current := map[string]bool{ccdDirectoryFile.Name: true, ccdMembershipFile.Name: true, ccdStaffFile.Name: true}
+	var files []DataVersion
+	for _, v := range versions {
+		if current[v.Filename] {
+			files = append(files, v)
+		}
+	}
+	return files, nil
+}
+
+// currentSchoolYear is the school year of the loaded directory, e.g. "2023-2024"
+func currentSchoolYear() string {
+	year, _ := ccdFileYear(ccdDirectoryFile.Name)
+	return year
+}
+
+// asOfQueryPattern matches the statements an as-of query can pin: queries
+// starting with SELECT, WITH, FROM, VALUES, or a parenthesis
+var asOfQueryPattern = regexp.MustCompile(`(?i)^\s*(select|with|from|values|\()`)
+
+// asOfWithPattern matches a query's own WITH clause, which the pinned tables join
+var asOfWithPattern = regexp.MustCompile(`(?i)^\s*with(\s+recursive)?\s+`)
+
+// asOfCurrentOnly are tables holding only the current year's data, so an
+// as-of query for another year that reads them fails instead of mixing years
+var asOfCurrentOnly = []string{"teachers", "school_percentiles", "district_staff", "overview_stats"}
+
+// AsOfQuery rewrites a query to read a past school year's data: directory and
+// enrollment become that year's directory_history and enrollment_history rows,
+// under the CCD column names they had, and tables with only current data raise
+// an error if read. The current year's query is returned unchanged. Columns
+// not kept in history, such as grade ranges, aren't available for past years.
+func (d *DB) AsOfQuery(query, year string) (string, error) {
+	year, err := normalizeSchoolYear(year)
+	if err != nil {
+		return "", err
+	}
+	if year == currentSchoolYear() {
+		return query, nil
+	}
+	if !asOfQueryPattern.MatchString(query) {
+		return "", fmt.Errorf("--as-of works only with SELECT queries")
+	}
+
+	versions, err := d.DataVersions(year)
+	if err != nil {
+		return "", err
+	}
+	loaded := make(map[string]bool)
+	for _, v := range versions {
+		loaded[v.Dataset] = true
+	}
+	if len(loaded) == 0 {
+		years, err := d.dataVersionYears()
+		if err != nil {
+			return "", err
+		}
+		return "", fmt.Errorf("no CCD data for %s is loaded (loaded: %s)", year, strings.Join(years, ", "))
+	}
+
+	missing := func(table, dataset string) string {
+		return fmt.Sprintf(`%s AS (SELECT * FROM %s WHERE error('No %s %s data is loaded'))`, table, table, year, dataset)
+	}
+	var ctes []string
+	if loaded[datasetDirectory] {
+		ctes = append(ctes, fmt.Sprintf(`directory AS (SELECT school_year AS SCHOOL_YEAR, ncessch AS NCESSCH, name AS SCH_NAME, state AS ST, city AS MCITY, district AS LEA_NAME, street AS MSTREET1, zip AS MZIP FROM directory_history WHERE school_year = '%s')`, year))
+	} else {
+		ctes = append(ctes, missing("directory", datasetDirectory))
+	}
+	if loaded[datasetMembership] {
+		ctes = append(ctes, fmt.Sprintf(`enrollment AS (SELECT ncessch AS NCESSCH, 'Education Unit Total' AS TOTAL_INDICATOR, CAST(students AS VARCHAR) AS STUDENT_COUNT FROM enrollment_history WHERE school_year = '%s')`, year))
+	} else {
+		ctes = append(ctes, missing("enrollment", datasetMembership))
+	}
+	for _, table := range asOfCurrentOnly {
+		ctes = append(ctes, fmt.Sprintf(`%s AS (SELECT * FROM %s WHERE error('%s has only %s data'))`, table, table, table, currentSchoolYear()))
+	}
+
+	pinned := strings.Join(ctes, ",\n")
+	if match := asOfWithPattern.FindString(query); match != "" {
+		return match + pinned + ",\n" + query[len(match):], nil
+	}
+	return "WITH " + pinned + "\n" + query, nil
+}
+
+// dataVersionYears lists the school years with recorded CCD files, oldest first
+func (d *DB) dataVersionYears() ([]string, error) {
+	rows, err := d.conn.Query(`SELECT DISTINCT school_year FROM data_versions ORDER BY school_year`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list data version years: %w", err)
+	}
+	defer rows.Close()
+
+	var years []string
+	for rows.Next() {
+		var year string
+		if err := rows.Scan(&year); err != nil {
+			return nil, fmt.Errorf("failed to scan data version year: %w", err)
+		}
+		years = append(years, year)
+	}
+	return years, rows.Err()
+}
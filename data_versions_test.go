@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCCDFileVersion(t *testing.T) {
+	v, err := ccdFileVersion("data/ccd_sch_052_2223_l_1a_083023.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Dataset != datasetMembership || v.SchoolYear != "2022-2023" || !v.Released.Equal(time.Date(2023, 8, 30, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("ccdFileVersion = %+v", v)
+	}
+	for _, name := range []string{"ccd_sch_099_2223_l_1a_083023.csv", "ccd_sch_029_2223_w_1a_latest.csv", "directory.csv"} {
+		if _, err := ccdFileVersion(name); err == nil {
+			t.Errorf("ccdFileVersion(%q) should fail", name)
+		}
+	}
+}
+
+func TestDataVersions(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	current, err := db.CurrentDataVersions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(current) != 3 || current[0].String() != "CCD 2023-2024 directory, released 2024-07-31" || current[0].Rows != 5 {
+		t.Fatalf("CurrentDataVersions() = %+v", current)
+	}
+
+	// Past years are queryable once their files are loaded
+	if _, err := db.AsOfQuery("SELECT count(*) FROM directory", "2022-23"); err == nil || !strings.Contains(err.Error(), "loaded: 2023-2024") {
+		t.Errorf("AsOfQuery() before loading 2022-23: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(db.dataDir, "ccd_sch_029_2223_w_1a_083023.csv"), []byte(priorYearDirectory), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for name, contents := range priorYearEnrollment {
+		if err := os.WriteFile(filepath.Join(db.dataDir, name), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := SyncDirectoryHistory(db); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SyncEnrollmentHistory(db); err != nil {
+		t.Fatal(err)
+	}
+	if added, err := SyncDataVersions(db); err != nil || added != 3 {
+		t.Fatalf("SyncDataVersions() = %d, %v, want 3 new files", added, err)
+	}
+	if added, _ := SyncDataVersions(db); added != 0 {
+		t.Errorf("SyncDataVersions() recorded %d files again", added)
+	}
+	all, err := db.DataVersions("")
+	if err != nil || len(all) != 6 {
+		t.Fatalf("DataVersions() = %+v, %v", all, err)
+	}
+	if again, _ := db.CurrentDataVersions(); !again[0].LoadedAt.Equal(current[0].LoadedAt) {
+		t.Errorf("loaded_at changed from %v to %v", current[0].LoadedAt, again[0].LoadedAt)
+	}
+
+	number := func(v interface{}) float64 {
+		n, _ := numericValue(v)
+		return n
+	}
+	asOf := func(query, year string) []map[string]interface{} {
+		t.Helper()
+		pinned, err := db.AsOfQuery(query, year)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows, err := db.ExecuteQuery(pinned)
+		if err != nil {
+			t.Fatalf("%s: %v", pinned, err)
+		}
+		return rows
+	}
+	rows := asOf(`SELECT SCH_NAME FROM directory WHERE NCESSCH = '360000100050'`, "2022-23")
+	if len(rows) != 1 || rows[0]["SCH_NAME"] != "Hoover Elementary School" {
+		t.Errorf("2022-23 directory = %v", rows)
+	}
+	rows = asOf(`WITH totals AS (SELECT sum(CAST(STUDENT_COUNT AS INTEGER)) AS students FROM enrollment WHERE TOTAL_INDICATOR = 'Education Unit Total') SELECT students FROM totals`, "2021-2022")
+	if len(rows) != 1 || number(rows[0]["students"]) != 1960 {
+		t.Errorf("2021-22 enrollment = %v", rows)
+	}
+	if rows = asOf(`SELECT count(*) AS n FROM directory`, "2023-24"); number(rows[0]["n"]) != 5 {
+		t.Errorf("current directory = %v", rows)
+	}
+
+	// The current year's query is unchanged; tables without history can't be pinned
+	if q, _ := db.AsOfQuery("SHOW TABLES", "2023-24"); q != "SHOW TABLES" {
+		t.Errorf("current-year query rewritten to %q", q)
+	}
+	if _, err := db.AsOfQuery("SHOW TABLES", "2022-23"); err == nil {
+		t.Error("Expected a non-SELECT as-of query to fail")
+	}
+	pinned, err := db.AsOfQuery(`SELECT count(*) FROM teachers`, "2022-23")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ExecuteQuery(pinned); err == nil || !strings.Contains(err.Error(), "teachers has only 2023-2024 data") {
+		t.Errorf("2022-23 teachers: %v", err)
+	}
+	pinned, err = db.AsOfQuery(`SELECT count(*) FROM directory`, "2021-22")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ExecuteQuery(pinned); err == nil || !strings.Contains(err.Error(), "No 2021-2022 directory data is loaded") {
+		t.Errorf("2021-22 directory: %v", err)
+	}
+
+	// Notebooks pinned to a year stamp that year's files
+	nb := &Notebook{Title: "Past", AsOf: "2022-23", Queries: []NotebookQuery{{Name: "Schools", SQL: "SELECT ST, count(*) AS schools FROM directory GROUP BY ST ORDER BY ST"}}}
+	if err := nb.validate(); err != nil {
+		t.Fatal(err)
+	}
+	run, err := RunNotebook(context.Background(), db, nb, "past.yaml", t.TempDir(), nil)
+	if err != nil || run.Error != "" {
+		t.Fatalf("RunNotebook() = %+v, %v", run, err)
+	}
+	if run.Data.SchoolYear != "2022-2023" || run.Data.Schools != 5 || len(run.Data.Versions) != 2 || run.Queries[0].Rows != 3 {
+		t.Errorf("pinned run = %+v", run)
+	}
+	nb.Queries = append(nb.Queries, NotebookQuery{Name: "Why", Ask: "Why?"})
+	if err := nb.validate(); err == nil {
+		t.Error("Expected an agent query in a pinned notebook to fail")
+	}
+}
@@ -1,11 +1,18 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+	"unicode"
 
 	_ "github.com/duckdb/duckdb-go/v2"
 )
@@ -32,12 +39,65 @@ type School struct {
 	GradeHigh   sql.NullString
 	CharterText sql.NullString
 	Enrollment  sql.NullInt64
+	Corrected   map[string]string // Original CCD values of user-corrected fields, by field
+}
+
+// District is a local education agency (LEA) summarized from its schools
+type District struct {
+	LEAID       string
+	Name        string
+	State       string
+	StateName   string
+	SchoolCount int
+	Enrollment  int64
 }
 
 type DB struct {
 	conn    *sql.DB
 	dataDir string
-	hasFTS  bool // Whether FTS extension is available
+	hasFTS  bool // Whether search_index has a full-text index; guarded by ftsMu
+
+	// The FTS extension loaded, so search_index can have a full-text index
+	ftsLoaded bool
+	ftsMu     sync.RWMutex
+	// Schools whose search_index rows need refreshing
+	searchIndex searchIndexer
+
+	// In-memory caches for hot reads; nil when disabled
+	schoolCache *lruCache[School]
+	naepCache   *lruCache[naepCacheRow]
+	aiCache     *lruCache[aiCacheRow]
+
+	hooksMu         sync.Mutex
+	invalidateHooks []func(ncessch string)
+
+	// User corrections of directory fields, by NCESSCH and field
+	correctionsMu sync.RWMutex
+	corrections   map[string]map[string]string
+
+	// Duplicate records merged into a canonical record, by duplicate NCESSCH
+	mergesMu sync.RWMutex
+	merges   map[string]string
+
+	// Whether the user opted in to counting feature usage
+	usageEnabled atomic.Bool
+
+	// Counts data changes since startup, for the web layer's ETags
+	dataVersion atomic.Uint64
+}
+
+// naepCacheRow is a naep_cache row held in memory
+type naepCacheRow struct {
+	state, district                             string
+	stateScores, districtScores, nationalScores []byte
+	extractedAt                                 time.Time
+}
+
+// aiCacheRow is an ai_scraper_cache row held in memory
+type aiCacheRow struct {
+	schoolName, sourceURL, markdownContent string
+	legacyData                             []byte
+	extractedAt                            time.Time
 }
 
 func NewDB(dataDir string) (*DB, error) {
@@ -58,9 +118,13 @@ func NewDB(dataDir string) (*DB, error) {
 		return nil, fmt.Errorf("failed to open duckdb: %w", err)
 	}
 
+	hotSize, hotTTL := hotCacheSizeFromEnv(), cacheTTLFromEnv("HOT_CACHE_TTL", defaultHotCacheTTL)
 	d := &DB{
-		conn:    db,
-		dataDir: dataDir,
+		conn:        db,
+		dataDir:     dataDir,
+		schoolCache: newLRUCache[School](hotSize, hotTTL),
+		naepCache:   newLRUCache[naepCacheRow](hotSize, hotTTL),
+		aiCache:     newLRUCache[aiCacheRow](hotSize, hotTTL),
 	}
 
 	// Initialize database if needed
@@ -88,12 +152,11 @@ func NewDB(dataDir string) (*DB, error) {
 				if logger != nil {
 					logger.Warn("FTS extension not available for existing database", "error", err, "db_path", dbPath)
 				}
-				d.hasFTS = false
 			} else {
-				d.hasFTS = true
+				d.ftsLoaded = true
 			}
 		} else {
-			d.hasFTS = true
+			d.ftsLoaded = true
 		}
 
 		// Ensure cache tables exist (for databases created before cache tables were added)
@@ -105,6 +168,131 @@ func NewDB(dataDir string) (*DB, error) {
 		}
 	}
 
+	// Pick up directory files for other school years dropped into the data directory
+	if _, err := SyncDirectoryHistory(d); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to load school directory history: %v\n", err)
+		if logger != nil {
+			logger.Warn("Failed to load school directory history", "error", err)
+		}
+	}
+
+	// Pick up membership files for other school years for enrollment trends
+	if _, err := SyncEnrollmentHistory(d); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to load enrollment history: %v\n", err)
+		if logger != nil {
+			logger.Warn("Failed to load enrollment history", "error", err)
+		}
+	}
+
+	// Pick up the district staff file for staffing composition
+	if _, err := SyncStaffing(d); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to load district staffing: %v\n", err)
+		if logger != nil {
+			logger.Warn("Failed to load district staffing", "error", err)
+		}
+	}
+
+	// Record which CCD releases are loaded, for data version stamps and as-of queries
+	if _, err := SyncDataVersions(d); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to record data versions: %v\n", err)
+		if logger != nil {
+			logger.Warn("Failed to record data versions", "error", err)
+		}
+	}
+
+	// Pick up EDGE geocode files for metro area pages
+	if _, err := SyncGeocodes(d); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to load school geocodes: %v\n", err)
+		if logger != nil {
+			logger.Warn("Failed to load school geocodes", "error", err)
+		}
+	}
+
+	// Pick up SABS attendance boundaries and district feeder tables for feeder pipelines
+	if _, err := SyncBoundaries(d); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to load attendance boundaries: %v\n", err)
+		if logger != nil {
+			logger.Warn("Failed to load attendance boundaries", "error", err)
+		}
+	}
+	if _, err := SyncFeeders(d); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to load feeder tables: %v\n", err)
+		if logger != nil {
+			logger.Warn("Failed to load feeder tables", "error", err)
+		}
+	}
+
+	// Pick up state pre-K and Head Start locator exports for early childhood search
+	if _, err := SyncPreKPrograms(d); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to load pre-K programs: %v\n", err)
+		if logger != nil {
+			logger.Warn("Failed to load pre-K programs", "error", err)
+		}
+	}
+
+	// Flag special education, gifted, immersion, IB, and Montessori programs for filtering
+	if _, err := SyncProgramFlags(d); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to update program flags: %v\n", err)
+		if logger != nil {
+			logger.Warn("Failed to update program flags", "error", err)
+		}
+	}
+
+	// Fill CTE, sports, arts, and language tables for website data saved before them
+	if _, err := SyncWebsiteTables(d); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to update website programs: %v\n", err)
+		if logger != nil {
+			logger.Warn("Failed to update website programs", "error", err)
+		}
+	}
+
+	// Rank enrollment, teachers, and ratio for "larger than 78% of CA elementary schools" notes
+	if _, err := SyncPercentiles(d); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to rank schools: %v\n", err)
+		if logger != nil {
+			logger.Warn("Failed to rank schools", "error", err)
+		}
+	}
+
+	// Precompute the statistics dashboard so it loads instantly
+	if _, err := SyncOverviewStats(d); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to compute overview statistics: %v\n", err)
+		if logger != nil {
+			logger.Warn("Failed to compute overview statistics", "error", err)
+		}
+	}
+
+	// Store website addresses in the form links use, so they all open
+	if _, err := NormalizeDirectoryWebsites(d); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to normalize school websites: %v\n", err)
+		if logger != nil {
+			logger.Warn("Failed to normalize school websites", "error", err)
+		}
+	}
+
+	// Flag anomalies such as zero enrollment with a full staff or grade ranges like 12–01
+	if _, err := SyncQualityFlags(d); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to check data quality: %v\n", err)
+		if logger != nil {
+			logger.Warn("Failed to check data quality", "error", err)
+		}
+	}
+
+	// Index the directory, corrections, website text, and imports for search.
+	// Searches read the index, so the database can't be used without it.
+	if _, err := SyncSearchIndex(d); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to build search index: %w", err)
+	}
+
+	// Describe tables and columns for the schema docs and the Data Explorer
+	if err := SyncDataDictionary(d); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to describe tables: %v\n", err)
+		if logger != nil {
+			logger.Warn("Failed to describe tables", "error", err)
+		}
+	}
+
 	return d, nil
 }
 
@@ -124,10 +312,9 @@ func (d *DB) initializeDatabase() error {
 		if logger != nil {
 			logger.Warn("FTS extension not available", "error", err)
 		}
-		d.hasFTS = false
 	} else {
 		fmt.Printf("   ✓ FTS extension loaded (%v)\n", time.Since(start))
-		d.hasFTS = true
+		d.ftsLoaded = true
 	}
 
 	// Start transaction for faster bulk insert
@@ -209,34 +396,6 @@ func (d *DB) initializeDatabase() error {
 		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
-	// Create FTS index (must be done outside transaction)
-	// Only try if FTS extension is available
-	if d.hasFTS {
-		fmt.Println("   Creating full-text search index...")
-		start = time.Now()
-		_, err = d.conn.Exec(`
-			PRAGMA create_fts_index(
-				'directory',
-				'NCESSCH',
-				'SCH_NAME',
-				'LEA_NAME',
-				'MCITY',
-				'MSTREET1',
-				'MZIP',
-				overwrite=1
-			)
-		`)
-		if err != nil {
-			// FTS index creation failed - warn but don't fail initialization
-			fmt.Printf("   ⚠ FTS index creation failed (search will use fallback): %v\n", err)
-			d.hasFTS = false
-		} else {
-			fmt.Printf("   ✓ FTS index created (%v)\n", time.Since(start))
-		}
-	} else {
-		fmt.Println("   ⚠ Skipping FTS index creation (extension not available)")
-	}
-
 	// Create cache tables
 	fmt.Println("   Creating cache tables...")
 	start = time.Now()
@@ -289,278 +448,1584 @@ func (d *DB) createCacheTables() error {
 		return fmt.Errorf("failed to create naep_cache table: %w", err)
 	}
 
-	if logger != nil {
-		logger.Info("Cache tables created successfully")
+	// Create parent summary cache table
+	_, err = d.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS parent_summary_cache (
+			ncessch VARCHAR PRIMARY KEY,
+			summary TEXT,
+			generated_at TIMESTAMP,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to create parent_summary_cache table", "error", err)
+		}
+		return fmt.Errorf("failed to create parent_summary_cache table: %w", err)
 	}
 
-	return nil
-}
-
-func (d *DB) Close() error {
-	return d.conn.Close()
-}
-
-// ExecuteQuery executes an arbitrary SQL query and returns results as a slice of maps
-func (d *DB) ExecuteQuery(query string) ([]map[string]interface{}, error) {
-	rows, err := d.conn.Query(query)
+	// Create district contacts table (leadership and enrollment contacts from district websites)
+	_, err = d.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS district_contacts (
+			leaid VARCHAR PRIMARY KEY,
+			district_name VARCHAR,
+			source_url VARCHAR,
+			superintendent VARCHAR,
+			board_members VARCHAR,
+			enrollment_office VARCHAR,
+			markdown_content TEXT,
+			extracted_at TIMESTAMP
+		)
+	`)
 	if err != nil {
 		if logger != nil {
-			logger.Error("Query execution failed", "error", err, "query", query)
+			logger.Error("Failed to create district_contacts table", "error", err)
 		}
-		return nil, fmt.Errorf("query failed: %w", err)
+		return fmt.Errorf("failed to create district_contacts table: %w", err)
 	}
-	defer rows.Close()
 
-	// Get column names
-	columns, err := rows.Columns()
+	// Create feeder inferences table (where a school's students go next, inferred
+	// from school and district websites with AI)
+	_, err = d.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS feeder_inferences (
+			ncessch VARCHAR PRIMARY KEY,
+			school_name VARCHAR,
+			markdown_content TEXT,
+			inferred_at TIMESTAMP
+		)
+	`)
 	if err != nil {
 		if logger != nil {
-			logger.Error("Failed to get column names", "error", err)
+			logger.Error("Failed to create feeder_inferences table", "error", err)
 		}
-		return nil, fmt.Errorf("failed to get column names: %w", err)
+		return fmt.Errorf("failed to create feeder_inferences table: %w", err)
 	}
 
-	// Prepare result slice
-	var results []map[string]interface{}
-
-	// Iterate through rows
-	for rows.Next() {
-		// Create a slice of interface{} to hold each column value
-		values := make([]interface{}, len(columns))
-		valuePtrs := make([]interface{}, len(columns))
-		for i := range columns {
-			valuePtrs[i] = &values[i]
+	// Create inferred feeders table (each school an inference says a school feeds into)
+	_, err = d.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS inferred_feeders (
+			from_ncessch VARCHAR NOT NULL,
+			to_name VARCHAR NOT NULL,
+			to_ncessch VARCHAR,
+			confidence VARCHAR NOT NULL,
+			evidence VARCHAR,
+			source_url VARCHAR,
+			inferred_at TIMESTAMP,
+			PRIMARY KEY (from_ncessch, to_name)
+		)
+	`)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to create inferred_feeders table", "error", err)
 		}
+		return fmt.Errorf("failed to create inferred_feeders table: %w", err)
+	}
 
-		// Scan the row
-		if err := rows.Scan(valuePtrs...); err != nil {
-			if logger != nil {
-				logger.Error("Failed to scan row", "error", err)
-			}
-			return nil, fmt.Errorf("failed to scan row: %w", err)
+	// Create school calendars table (academic calendars from school and district websites)
+	_, err = d.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS school_calendars (
+			ncessch VARCHAR PRIMARY KEY,
+			school_name VARCHAR,
+			school_year VARCHAR,
+			source_url VARCHAR,
+			events VARCHAR,
+			markdown_content TEXT,
+			extracted_at TIMESTAMP
+		)
+	`)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to create school_calendars table", "error", err)
 		}
+		return fmt.Errorf("failed to create school_calendars table: %w", err)
+	}
 
-		// Create a map for this row
-		rowMap := make(map[string]interface{})
-		for i, col := range columns {
-			val := values[i]
-
-			// Convert []byte to string for better JSON serialization
-			if b, ok := val.([]byte); ok {
-				rowMap[col] = string(b)
-			} else {
-				rowMap[col] = val
-			}
+	// Create NAEP decline alerts table
+	_, err = d.conn.Exec(`
+		CREATE SEQUENCE IF NOT EXISTS naep_alerts_id_seq;
+		CREATE TABLE IF NOT EXISTS naep_alerts (
+			id BIGINT PRIMARY KEY DEFAULT nextval('naep_alerts_id_seq'),
+			ncessch VARCHAR,
+			school_name VARCHAR,
+			jurisdiction VARCHAR,
+			subject VARCHAR,
+			grade INTEGER,
+			previous_year INTEGER,
+			current_year INTEGER,
+			previous_score DOUBLE,
+			current_score DOUBLE,
+			change DOUBLE,
+			dismissed BOOLEAN DEFAULT false,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (ncessch, subject, grade, current_year)
+		)
+	`)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to create naep_alerts table", "error", err)
 		}
-
-		results = append(results, rowMap)
+		return fmt.Errorf("failed to create naep_alerts table: %w", err)
 	}
 
-	if err := rows.Err(); err != nil {
+	// Create NAEP raw response archive table
+	_, err = d.conn.Exec(`
+		CREATE SEQUENCE IF NOT EXISTS naep_raw_responses_id_seq;
+		CREATE TABLE IF NOT EXISTS naep_raw_responses (
+			id BIGINT PRIMARY KEY DEFAULT nextval('naep_raw_responses_id_seq'),
+			url VARCHAR,
+			jurisdiction VARCHAR,
+			subject VARCHAR,
+			grade INTEGER,
+			stat_type VARCHAR,
+			http_status INTEGER,
+			body TEXT,
+			fetched_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
 		if logger != nil {
-			logger.Error("Row iteration error", "error", err, "results_count", len(results))
+			logger.Error("Failed to create naep_raw_responses table", "error", err)
 		}
-		return nil, err
+		return fmt.Errorf("failed to create naep_raw_responses table: %w", err)
 	}
 
-	return results, nil
-}
-
-func (d *DB) SearchSchools(query string, state string, limit int) ([]School, error) {
-	var schools []School
-
-	// Build the SQL query using FTS when query is provided
-	var sqlQuery string
-	var args []interface{}
-
-	if query != "" {
-		if d.hasFTS {
-			// Use full-text search with relevance ranking
-			args = append(args, query)
-
-			stateFilter := ""
-			if state != "" {
-				argIdx := 2
-				stateFilter = fmt.Sprintf("AND d.ST = $%d", argIdx)
-				args = append(args, state)
-			}
-
-			sqlQuery = fmt.Sprintf(`
-				SELECT
-					d.NCESSCH,
-					d.SCH_NAME,
-					d.ST,
-					d.STATENAME,
-					COALESCE(d.MCITY, ''),
-					COALESCE(d.LEA_NAME, ''),
-					d.LEAID,
-					d.SCHOOL_YEAR,
-					t.TEACHERS,
-					d.LEVEL,
-					d.PHONE,
-					d.WEBSITE,
-					d.MZIP,
-					d.MSTREET1,
-					d.MSTREET2,
-					d.MSTREET3,
-					d.SCH_TYPE_TEXT,
-					d.GSLO,
-					d.GSHI,
-					d.CHARTER_TEXT,
-					e.STUDENT_COUNT
-				FROM directory d
-				LEFT JOIN teachers t ON d.NCESSCH = t.NCESSCH
-				LEFT JOIN enrollment e ON d.NCESSCH = e.NCESSCH AND e.TOTAL_INDICATOR = 'Education Unit Total'
-				WHERE fts_main_directory.match_bm25(d.NCESSCH, $1) IS NOT NULL
-				%s
-				ORDER BY fts_main_directory.match_bm25(d.NCESSCH, $1) DESC
-				LIMIT %d
-			`, stateFilter, limit)
-		} else {
-			// Fallback to LIKE-based search when FTS is not available
-			searchPattern := "%" + query + "%"
-			args = append(args, searchPattern)
-
-			stateFilter := ""
-			if state != "" {
-				stateFilter = "AND d.ST = $2"
-				args = append(args, state)
-			}
-
-			sqlQuery = fmt.Sprintf(`
-				SELECT
-					d.NCESSCH,
-					d.SCH_NAME,
-					d.ST,
-					d.STATENAME,
-					COALESCE(d.MCITY, ''),
-					COALESCE(d.LEA_NAME, ''),
-					d.LEAID,
-					d.SCHOOL_YEAR,
-					t.TEACHERS,
-					d.LEVEL,
-					d.PHONE,
-					d.WEBSITE,
-					d.MZIP,
-					d.MSTREET1,
-					d.MSTREET2,
-					d.MSTREET3,
-					d.SCH_TYPE_TEXT,
-					d.GSLO,
-					d.GSHI,
-					d.CHARTER_TEXT,
-					e.STUDENT_COUNT
-				FROM directory d
-				LEFT JOIN teachers t ON d.NCESSCH = t.NCESSCH
-				LEFT JOIN enrollment e ON d.NCESSCH = e.NCESSCH AND e.TOTAL_INDICATOR = 'Education Unit Total'
-				WHERE (
-					LOWER(d.SCH_NAME) LIKE LOWER($1)
-					OR LOWER(d.MCITY) LIKE LOWER($1)
-					OR LOWER(d.LEA_NAME) LIKE LOWER($1)
-					OR LOWER(d.MSTREET1) LIKE LOWER($1)
-					OR d.MZIP LIKE $1
-				)
-				%s
-				ORDER BY d.SCH_NAME
-				LIMIT %d
-			`, stateFilter, limit)
+	// Create per-school NAEP override table
+	_, err = d.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS naep_overrides (
+			ncessch VARCHAR PRIMARY KEY,
+			disable_auto_fetch BOOLEAN DEFAULT false,
+			jurisdiction VARCHAR DEFAULT '',
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to create naep_overrides table", "error", err)
 		}
-	} else {
-		// No search query, just filter by state if provided
-		whereClause := "WHERE 1=1"
-		argIdx := 1
-
-		if state != "" {
-			whereClause += fmt.Sprintf(" AND d.ST = $%d", argIdx)
-			args = append(args, state)
-		}
-
-		sqlQuery = fmt.Sprintf(`
-			SELECT
-				d.NCESSCH,
-				d.SCH_NAME,
-				d.ST,
-				d.STATENAME,
-				COALESCE(d.MCITY, ''),
-				COALESCE(d.LEA_NAME, ''),
-				d.LEAID,
-				d.SCHOOL_YEAR,
-				t.TEACHERS,
-				d.LEVEL,
-				d.PHONE,
-				d.WEBSITE,
-				d.MZIP,
-				d.MSTREET1,
-				d.MSTREET2,
-				d.MSTREET3,
-				d.SCH_TYPE_TEXT,
-				d.GSLO,
-				d.GSHI,
-				d.CHARTER_TEXT,
-				e.STUDENT_COUNT
-			FROM directory d
-			LEFT JOIN teachers t ON d.NCESSCH = t.NCESSCH
-			LEFT JOIN enrollment e ON d.NCESSCH = e.NCESSCH AND e.TOTAL_INDICATOR = 'Education Unit Total'
-			%s
-			ORDER BY d.SCH_NAME
-			LIMIT %d
-		`, whereClause, limit)
+		return fmt.Errorf("failed to create naep_overrides table: %w", err)
 	}
 
-	rows, err := d.conn.Query(sqlQuery, args...)
+	// Create saved searches table
+	_, err = d.conn.Exec(`
+		CREATE SEQUENCE IF NOT EXISTS saved_searches_seq;
+		CREATE TABLE IF NOT EXISTS saved_searches (
+			id BIGINT PRIMARY KEY DEFAULT nextval('saved_searches_seq'),
+			name VARCHAR NOT NULL UNIQUE,
+			filters JSON NOT NULL,
+			subscribed BOOLEAN DEFAULT false,
+			result_ids JSON,
+			checked_at TIMESTAMP,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
 	if err != nil {
 		if logger != nil {
-			logger.Error("School search query failed", "error", err, "query", query, "state", state, "limit", limit)
+			logger.Error("Failed to create saved_searches table", "error", err)
 		}
-		return nil, fmt.Errorf("query failed: %w", err)
+		return fmt.Errorf("failed to create saved_searches table: %w", err)
 	}
-	defer rows.Close()
 
-	for rows.Next() {
-		var s School
-		err := rows.Scan(
-			&s.NCESSCH,
-			&s.Name,
-			&s.State,
-			&s.StateName,
-			&s.City,
-			&s.District,
-			&s.DistrictID,
-			&s.SchoolYear,
-			&s.Teachers,
-			&s.Level,
-			&s.Phone,
-			&s.Website,
-			&s.Zip,
-			&s.Street1,
-			&s.Street2,
-			&s.Street3,
-			&s.SchoolType,
-			&s.GradeLow,
-			&s.GradeHigh,
-			&s.CharterText,
-			&s.Enrollment,
+	// Create saved search change log table
+	_, err = d.conn.Exec(`
+		CREATE SEQUENCE IF NOT EXISTS saved_search_changes_seq;
+		CREATE TABLE IF NOT EXISTS saved_search_changes (
+			id BIGINT PRIMARY KEY DEFAULT nextval('saved_search_changes_seq'),
+			search_id BIGINT NOT NULL,
+			added JSON,
+			removed JSON,
+			detected_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 		)
-		if err != nil {
-			if logger != nil {
-				logger.Error("Failed to scan school row", "error", err, "query", query, "state", state)
-			}
-			return nil, fmt.Errorf("scan failed: %w", err)
+	`)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to create saved_search_changes table", "error", err)
 		}
-		schools = append(schools, s)
+		return fmt.Errorf("failed to create saved_search_changes table: %w", err)
 	}
 
-	if err := rows.Err(); err != nil {
+	// Create benchmark results table
+	_, err = d.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS bench_results (
+			run_at TIMESTAMP NOT NULL,
+			version VARCHAR NOT NULL,
+			name VARCHAR NOT NULL,
+			iterations INTEGER,
+			p50_ms DOUBLE,
+			p95_ms DOUBLE,
+			max_ms DOUBLE,
+			budget_ms DOUBLE,
+			skipped VARCHAR DEFAULT '',
+			PRIMARY KEY (run_at, name)
+		)
+	`)
+	if err != nil {
 		if logger != nil {
-			logger.Error("Row iteration error in search", "error", err, "query", query, "state", state, "results_count", len(schools))
+			logger.Error("Failed to create bench_results table", "error", err)
 		}
-		return nil, err
+		return fmt.Errorf("failed to create bench_results table: %w", err)
 	}
 
-	return schools, nil
-}
-
-func (d *DB) GetSchoolByID(ncessch string) (*School, error) {
+	// Create school directory history tables, one row per school per loaded year
+	_, err = d.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS directory_history (
+			school_year VARCHAR NOT NULL,
+			ncessch VARCHAR NOT NULL,
+			name VARCHAR,
+			state VARCHAR,
+			city VARCHAR,
+			district VARCHAR,
+			street VARCHAR,
+			zip VARCHAR,
+			PRIMARY KEY (school_year, ncessch)
+		);
+		CREATE TABLE IF NOT EXISTS directory_history_files (
+			filename VARCHAR PRIMARY KEY,
+			loaded_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to create directory_history table", "error", err)
+		}
+		return fmt.Errorf("failed to create directory_history table: %w", err)
+	}
+
+	// Create school year changes table (openings, renames, and ID changes in the latest year)
+	_, err = d.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS school_year_changes (
+			kind VARCHAR NOT NULL,
+			ncessch VARCHAR NOT NULL,
+			name VARCHAR,
+			state VARCHAR,
+			city VARCHAR,
+			from_year VARCHAR NOT NULL,
+			to_year VARCHAR NOT NULL,
+			previous_ncessch VARCHAR,
+			previous_name VARCHAR,
+			PRIMARY KEY (kind, ncessch)
+		)
+	`)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to create school_year_changes table", "error", err)
+		}
+		return fmt.Errorf("failed to create school_year_changes table: %w", err)
+	}
+
+	// Create school geocodes table (metro area from NCES EDGE geocode files)
+	_, err = d.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS school_geocodes (
+			ncessch VARCHAR PRIMARY KEY,
+			cbsa VARCHAR,
+			cbsa_name VARCHAR
+		)
+	`)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to create school_geocodes table", "error", err)
+		}
+		return fmt.Errorf("failed to create school_geocodes table: %w", err)
+	}
+
+	// Create geocode files table (which EDGE geocode files have been loaded)
+	_, err = d.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS geocode_files (
+			filename VARCHAR PRIMARY KEY,
+			loaded_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to create geocode_files table", "error", err)
+		}
+		return fmt.Errorf("failed to create geocode_files table: %w", err)
+	}
+
+	// Create school coordinates table (latitude and longitude from NCES EDGE
+	// geocode files). Geocode files loaded before coordinates were kept are
+	// loaded again.
+	var hasCoordinates int
+	if err := d.conn.QueryRow(`SELECT count(*) FROM duckdb_tables() WHERE table_name = 'school_coordinates'`).Scan(&hasCoordinates); err != nil {
+		return fmt.Errorf("failed to check for school_coordinates table: %w", err)
+	}
+	_, err = d.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS school_coordinates (
+			ncessch VARCHAR PRIMARY KEY,
+			lat DOUBLE NOT NULL,
+			lon DOUBLE NOT NULL
+		)
+	`)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to create school_coordinates table", "error", err)
+		}
+		return fmt.Errorf("failed to create school_coordinates table: %w", err)
+	}
+	if hasCoordinates == 0 {
+		if _, err := d.conn.Exec(`DELETE FROM geocode_files`); err != nil {
+			return fmt.Errorf("failed to reset geocode files: %w", err)
+		}
+	}
+
+	// Create school counties table (county FIPS codes from NCES EDGE geocode
+	// files, for time zones). Geocode files loaded before counties were kept
+	// are loaded again.
+	var hasCounties int
+	if err := d.conn.QueryRow(`SELECT count(*) FROM duckdb_tables() WHERE table_name = 'school_counties'`).Scan(&hasCounties); err != nil {
+		return fmt.Errorf("failed to check for school_counties table: %w", err)
+	}
+	_, err = d.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS school_counties (
+			ncessch VARCHAR PRIMARY KEY,
+			county VARCHAR NOT NULL,
+			county_name VARCHAR
+		)
+	`)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to create school_counties table", "error", err)
+		}
+		return fmt.Errorf("failed to create school_counties table: %w", err)
+	}
+	if hasCounties == 0 {
+		if _, err := d.conn.Exec(`DELETE FROM geocode_files`); err != nil {
+			return fmt.Errorf("failed to reset geocode files: %w", err)
+		}
+	}
+
+	// Create school congressional districts table (congressional district
+	// codes from NCES EDGE geocode files). Geocode files loaded before
+	// districts were kept are loaded again.
+	var hasCongressionalDistricts int
+	if err := d.conn.QueryRow(`SELECT count(*) FROM duckdb_tables() WHERE table_name = 'school_congressional_districts'`).Scan(&hasCongressionalDistricts); err != nil {
+		return fmt.Errorf("failed to check for school_congressional_districts table: %w", err)
+	}
+	_, err = d.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS school_congressional_districts (
+			ncessch VARCHAR PRIMARY KEY,
+			cd VARCHAR NOT NULL
+		)
+	`)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to create school_congressional_districts table", "error", err)
+		}
+		return fmt.Errorf("failed to create school_congressional_districts table: %w", err)
+	}
+	if hasCongressionalDistricts == 0 {
+		if _, err := d.conn.Exec(`DELETE FROM geocode_files`); err != nil {
+			return fmt.Errorf("failed to reset geocode files: %w", err)
+		}
+	}
+
+	// Create geocodes table (addresses geocoded by the backfill, keyed by the
+	// normalized address; lat and lon are NULL when the address wasn't found)
+	_, err = d.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS geocodes (
+			address VARCHAR PRIMARY KEY,
+			provider VARCHAR NOT NULL,
+			lat DOUBLE,
+			lon DOUBLE,
+			matched_address VARCHAR,
+			precision VARCHAR,
+			geocoded_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to create geocodes table", "error", err)
+		}
+		return fmt.Errorf("failed to create geocodes table: %w", err)
+	}
+
+	// Create school boundaries table (attendance boundaries from NCES SABS files,
+	// with a bounding box to narrow lookups before the point-in-polygon test)
+	_, err = d.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS school_boundaries (
+			ncessch VARCHAR PRIMARY KEY,
+			level VARCHAR,
+			grade_low VARCHAR,
+			grade_high VARCHAR,
+			min_lat DOUBLE NOT NULL,
+			min_lon DOUBLE NOT NULL,
+			max_lat DOUBLE NOT NULL,
+			max_lon DOUBLE NOT NULL,
+			polygons VARCHAR NOT NULL,
+			source VARCHAR
+		)
+	`)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to create school_boundaries table", "error", err)
+		}
+		return fmt.Errorf("failed to create school_boundaries table: %w", err)
+	}
+
+	// Create boundary files table (which SABS boundary files have been loaded)
+	_, err = d.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS boundary_files (
+			filename VARCHAR PRIMARY KEY,
+			loaded_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to create boundary_files table", "error", err)
+		}
+		return fmt.Errorf("failed to create boundary_files table: %w", err)
+	}
+
+	// Create school feeders table (which schools feed into which, from district feeder tables)
+	_, err = d.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS school_feeders (
+			from_ncessch VARCHAR NOT NULL,
+			to_ncessch VARCHAR NOT NULL,
+			source VARCHAR,
+			PRIMARY KEY (from_ncessch, to_ncessch)
+		)
+	`)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to create school_feeders table", "error", err)
+		}
+		return fmt.Errorf("failed to create school_feeders table: %w", err)
+	}
+
+	// Create feeder files table (which feeder tables have been loaded)
+	_, err = d.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS feeder_files (
+			filename VARCHAR PRIMARY KEY,
+			loaded_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to create feeder_files table", "error", err)
+		}
+		return fmt.Errorf("failed to create feeder_files table: %w", err)
+	}
+
+	// Create pre-K programs table (pre-K and Head Start sites from state locator exports)
+	_, err = d.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS prek_programs (
+			name VARCHAR NOT NULL,
+			program_type VARCHAR,
+			street VARCHAR,
+			city VARCHAR,
+			state VARCHAR,
+			zip VARCHAR,
+			phone VARCHAR,
+			website VARCHAR,
+			ncessch VARCHAR,
+			source VARCHAR
+		)
+	`)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to create prek_programs table", "error", err)
+		}
+		return fmt.Errorf("failed to create prek_programs table: %w", err)
+	}
+
+	// Create pre-K files table (which locator exports have been loaded)
+	_, err = d.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS prek_files (
+			filename VARCHAR PRIMARY KEY,
+			loaded_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to create prek_files table", "error", err)
+		}
+		return fmt.Errorf("failed to create prek_files table: %w", err)
+	}
+
+	// Create enrollment history table (total enrollment per school for each loaded school year)
+	_, err = d.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS enrollment_history (
+			school_year VARCHAR NOT NULL,
+			ncessch VARCHAR NOT NULL,
+			students BIGINT,
+			PRIMARY KEY (school_year, ncessch)
+		)
+	`)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to create enrollment_history table", "error", err)
+		}
+		return fmt.Errorf("failed to create enrollment_history table: %w", err)
+	}
+
+	// Create enrollment history files table (which membership files have been loaded)
+	_, err = d.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS enrollment_history_files (
+			filename VARCHAR PRIMARY KEY,
+			loaded_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to create enrollment_history_files table", "error", err)
+		}
+		return fmt.Errorf("failed to create enrollment_history_files table: %w", err)
+	}
+
+	// Create data versions table (each CCD release file loaded, for stamping exports and as-of queries)
+	_, err = d.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS data_versions (
+			filename VARCHAR PRIMARY KEY,
+			dataset VARCHAR NOT NULL,
+			school_year VARCHAR NOT NULL,
+			released DATE,
+			loaded_at TIMESTAMP NOT NULL,
+			rows BIGINT NOT NULL
+		)
+	`)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to create data_versions table", "error", err)
+		}
+		return fmt.Errorf("failed to create data_versions table: %w", err)
+	}
+
+	// Create CCD release checks table (the last check of NCES for newer releases)
+	_, err = d.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS ccd_release_checks (
+			checked_at TIMESTAMP NOT NULL,
+			new_releases VARCHAR NOT NULL
+		)
+	`)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to create ccd_release_checks table", "error", err)
+		}
+		return fmt.Errorf("failed to create ccd_release_checks table: %w", err)
+	}
+
+	// Create enrollment trends table (growth pressure derived from enrollment history)
+	_, err = d.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS enrollment_trends (
+			ncessch VARCHAR PRIMARY KEY,
+			pressure VARCHAR NOT NULL,
+			annual_change DOUBLE NOT NULL
+		)
+	`)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to create enrollment_trends table", "error", err)
+		}
+		return fmt.Errorf("failed to create enrollment_trends table: %w", err)
+	}
+
+	// Create enrollment projection tables (next year's enrollment for schools and districts),
+	// described for the data agent's schema tool
+	_, err = d.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS enrollment_projections (
+			ncessch VARCHAR PRIMARY KEY,
+			school_year VARCHAR NOT NULL,
+			method VARCHAR NOT NULL,
+			projected DOUBLE NOT NULL,
+			low DOUBLE NOT NULL,
+			high DOUBLE NOT NULL,
+			change DOUBLE NOT NULL,
+			based_on INTEGER NOT NULL
+		);
+		CREATE TABLE IF NOT EXISTS district_enrollment_projections (
+			leaid VARCHAR PRIMARY KEY,
+			school_year VARCHAR NOT NULL,
+			method VARCHAR NOT NULL,
+			projected DOUBLE NOT NULL,
+			low DOUBLE NOT NULL,
+			high DOUBLE NOT NULL,
+			change DOUBLE NOT NULL,
+			based_on INTEGER NOT NULL
+		);
+		COMMENT ON TABLE enrollment_projections IS 'Projected total enrollment per school for the school year after the last loaded one';
+		COMMENT ON TABLE district_enrollment_projections IS 'Projected total enrollment per district (LEAID) for the school year after the last loaded one';
+		COMMENT ON COLUMN enrollment_projections.change IS 'Projected change from the last loaded year as a fraction, e.g. -0.05 for a 5% decline';
+		COMMENT ON COLUMN district_enrollment_projections.change IS 'Projected change from the last loaded year as a fraction, e.g. -0.05 for a 5% decline';
+		COMMENT ON COLUMN enrollment_projections.low IS 'Bottom of the 80% range';
+		COMMENT ON COLUMN enrollment_projections.high IS 'Top of the 80% range';
+		COMMENT ON COLUMN district_enrollment_projections.low IS 'Bottom of the 80% range';
+		COMMENT ON COLUMN district_enrollment_projections.high IS 'Top of the 80% range'
+	`)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to create enrollment projection tables", "error", err)
+		}
+		return fmt.Errorf("failed to create enrollment projection tables: %w", err)
+	}
+
+	// Create school percentiles table (enrollment, teachers, and ratio ranked among
+	// same-level schools in the state and nationally), described for the data agent's schema tool
+	_, err = d.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS school_percentiles (
+			ncessch VARCHAR NOT NULL,
+			metric VARCHAR NOT NULL,
+			value DOUBLE NOT NULL,
+			state VARCHAR NOT NULL,
+			level VARCHAR NOT NULL,
+			state_pct DOUBLE NOT NULL,
+			state_peers INTEGER NOT NULL,
+			national_pct DOUBLE NOT NULL,
+			national_peers INTEGER NOT NULL,
+			PRIMARY KEY (ncessch, metric)
+		);
+		COMMENT ON TABLE school_percentiles IS 'Where each school stands among schools of the same level (directory.LEVEL), in its state and nationally';
+		COMMENT ON COLUMN school_percentiles.metric IS 'enrollment (total students), teachers (FTE), or ratio (students per teacher)';
+		COMMENT ON COLUMN school_percentiles.state_pct IS 'Percent of same-level schools in the state with a smaller value, 0-100';
+		COMMENT ON COLUMN school_percentiles.national_pct IS 'Percent of same-level schools nationally with a smaller value, 0-100';
+		COMMENT ON COLUMN school_percentiles.state_peers IS 'Same-level schools in the state with a value, including this one';
+		COMMENT ON COLUMN school_percentiles.national_peers IS 'Same-level schools nationally with a value, including this one'
+	`)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to create school percentiles table", "error", err)
+		}
+		return fmt.Errorf("failed to create school percentiles table: %w", err)
+	}
+
+	// Create overview statistics table (schools, charter share, enrollment, and ratio
+	// for the country, states, levels, and districts), described for the data agent's schema tool
+	_, err = d.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS overview_stats (
+			section VARCHAR NOT NULL,
+			key VARCHAR NOT NULL,
+			name VARCHAR NOT NULL,
+			state VARCHAR,
+			schools INTEGER NOT NULL,
+			charter_schools INTEGER NOT NULL,
+			students BIGINT NOT NULL,
+			ratio DOUBLE,
+			rank INTEGER NOT NULL,
+			computed_at TIMESTAMP NOT NULL,
+			PRIMARY KEY (section, key)
+		);
+		COMMENT ON TABLE overview_stats IS 'Precomputed totals for the statistics dashboard; cite these for counts of schools, charter share, enrollment, and ratios';
+		COMMENT ON COLUMN overview_stats.section IS 'national (key US), state (key is the state code), level (key is directory.LEVEL), or district (key is the LEAID)';
+		COMMENT ON COLUMN overview_stats.ratio IS 'Pooled students per teacher across schools reporting both';
+		COMMENT ON COLUMN overview_stats.rank IS 'Position within the section: most schools first, or most students first for districts'
+	`)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to create overview statistics table", "error", err)
+		}
+		return fmt.Errorf("failed to create overview statistics table: %w", err)
+	}
+
+	// Create district staff table (staffing composition from the CCD district staff file)
+	_, err = d.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS district_staff (
+			leaid VARCHAR NOT NULL,
+			staff_group VARCHAR NOT NULL,
+			fte DOUBLE,
+			PRIMARY KEY (leaid, staff_group)
+		)
+	`)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to create district_staff table", "error", err)
+		}
+		return fmt.Errorf("failed to create district_staff table: %w", err)
+	}
+
+	// Create staff files table (which district staff file is loaded)
+	_, err = d.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS staff_files (
+			filename VARCHAR PRIMARY KEY,
+			loaded_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to create staff_files table", "error", err)
+		}
+		return fmt.Errorf("failed to create staff_files table: %w", err)
+	}
+
+	// Create manual website data edit history table
+	_, err = d.conn.Exec(`
+		CREATE SEQUENCE IF NOT EXISTS ai_data_edits_seq;
+		CREATE TABLE IF NOT EXISTS ai_data_edits (
+			id BIGINT PRIMARY KEY DEFAULT nextval('ai_data_edits_seq'),
+			ncessch VARCHAR NOT NULL,
+			field VARCHAR NOT NULL,
+			old_value TEXT,
+			new_value TEXT,
+			source VARCHAR,
+			edited_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to create ai_data_edits table", "error", err)
+		}
+		return fmt.Errorf("failed to create ai_data_edits table: %w", err)
+	}
+
+	// Create user corrections of directory fields table
+	_, err = d.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS school_corrections (
+			ncessch VARCHAR NOT NULL,
+			field VARCHAR NOT NULL,
+			value VARCHAR NOT NULL,
+			note VARCHAR DEFAULT '',
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (ncessch, field)
+		)
+	`)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to create school_corrections table", "error", err)
+		}
+		return fmt.Errorf("failed to create school_corrections table: %w", err)
+	}
+	if err := d.loadCorrections(); err != nil {
+		return err
+	}
+
+	// Create suggested corrections review queue table
+	_, err = d.conn.Exec(`
+		CREATE SEQUENCE IF NOT EXISTS correction_suggestions_seq;
+		CREATE TABLE IF NOT EXISTS correction_suggestions (
+			id BIGINT PRIMARY KEY DEFAULT nextval('correction_suggestions_seq'),
+			ncessch VARCHAR NOT NULL,
+			school_name VARCHAR NOT NULL,
+			field VARCHAR NOT NULL,
+			value VARCHAR NOT NULL,
+			old_value VARCHAR DEFAULT '',
+			comment VARCHAR DEFAULT '',
+			submitter VARCHAR DEFAULT '',
+			status VARCHAR NOT NULL DEFAULT 'pending',
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			reviewed_at TIMESTAMP
+		)
+	`)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to create correction_suggestions table", "error", err)
+		}
+		return fmt.Errorf("failed to create correction_suggestions table: %w", err)
+	}
+
+	// Create duplicate school merge tables
+	_, err = d.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS school_merges (
+			duplicate_ncessch VARCHAR PRIMARY KEY,
+			canonical_ncessch VARCHAR NOT NULL,
+			note VARCHAR DEFAULT '',
+			merged_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE TABLE IF NOT EXISTS school_distinct (
+			ncessch_a VARCHAR NOT NULL,
+			ncessch_b VARCHAR NOT NULL,
+			PRIMARY KEY (ncessch_a, ncessch_b)
+		)
+	`)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to create school_merges table", "error", err)
+		}
+		return fmt.Errorf("failed to create school_merges table: %w", err)
+	}
+	if err := d.loadMerges(); err != nil {
+		return err
+	}
+
+	// Create website liveness check table
+	_, err = d.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS website_checks (
+			ncessch VARCHAR PRIMARY KEY,
+			url VARCHAR NOT NULL,
+			status VARCHAR NOT NULL,
+			status_code INTEGER DEFAULT 0,
+			final_url VARCHAR DEFAULT '',
+			error VARCHAR DEFAULT '',
+			checked_at TIMESTAMP NOT NULL
+		)
+	`)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to create website_checks table", "error", err)
+		}
+		return fmt.Errorf("failed to create website_checks table: %w", err)
+	}
+
+	// Create application timeline key dates table
+	_, err = d.conn.Exec(`
+		CREATE SEQUENCE IF NOT EXISTS school_dates_seq;
+		CREATE TABLE IF NOT EXISTS school_dates (
+			id BIGINT PRIMARY KEY DEFAULT nextval('school_dates_seq'),
+			ncessch VARCHAR NOT NULL,
+			kind VARCHAR NOT NULL,
+			date DATE NOT NULL,
+			note VARCHAR,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to create school_dates table", "error", err)
+		}
+		return fmt.Errorf("failed to create school_dates table: %w", err)
+	}
+
+	// Create school choice applications table
+	_, err = d.conn.Exec(`
+		CREATE SEQUENCE IF NOT EXISTS applications_seq;
+		CREATE TABLE IF NOT EXISTS applications (
+			id BIGINT PRIMARY KEY DEFAULT nextval('applications_seq'),
+			season VARCHAR NOT NULL,
+			ncessch VARCHAR NOT NULL,
+			status VARCHAR NOT NULL,
+			priorities JSON,
+			seats INTEGER DEFAULT 0,
+			applicants INTEGER DEFAULT 0,
+			weight DOUBLE DEFAULT 1,
+			notes VARCHAR,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (season, ncessch)
+		)
+	`)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to create applications table", "error", err)
+		}
+		return fmt.Errorf("failed to create applications table: %w", err)
+	}
+
+	// Create call log table (calls to schools' front offices and what they said)
+	_, err = d.conn.Exec(`
+		CREATE SEQUENCE IF NOT EXISTS school_calls_seq;
+		CREATE TABLE IF NOT EXISTS school_calls (
+			id BIGINT PRIMARY KEY DEFAULT nextval('school_calls_seq'),
+			ncessch VARCHAR NOT NULL,
+			called_at TIMESTAMP NOT NULL,
+			note VARCHAR NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to create school_calls table", "error", err)
+		}
+		return fmt.Errorf("failed to create school_calls table: %w", err)
+	}
+
+	// Create outreach table (emails to schools, awaiting a reply or answered)
+	_, err = d.conn.Exec(`
+		CREATE SEQUENCE IF NOT EXISTS outreach_seq;
+		CREATE TABLE IF NOT EXISTS outreach (
+			id BIGINT PRIMARY KEY DEFAULT nextval('outreach_seq'),
+			ncessch VARCHAR NOT NULL,
+			recipient VARCHAR,
+			subject VARCHAR NOT NULL,
+			template VARCHAR,
+			status VARCHAR NOT NULL,
+			sent_on DATE NOT NULL,
+			follow_up_on DATE,
+			note VARCHAR,
+			nudged_at TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to create outreach table", "error", err)
+		}
+		return fmt.Errorf("failed to create outreach table: %w", err)
+	}
+
+	// Create jobs tables (long-running work such as bulk saves, and each item's
+	// progress, so a job cut short can be resumed)
+	_, err = d.conn.Exec(`
+		CREATE SEQUENCE IF NOT EXISTS jobs_seq;
+		CREATE TABLE IF NOT EXISTS jobs (
+			id BIGINT PRIMARY KEY DEFAULT nextval('jobs_seq'),
+			kind VARCHAR NOT NULL,
+			status VARCHAR NOT NULL,
+			options JSON,
+			error VARCHAR,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE TABLE IF NOT EXISTS job_items (
+			job_id BIGINT NOT NULL,
+			position INTEGER NOT NULL,
+			item_key VARCHAR NOT NULL,
+			status VARCHAR NOT NULL,
+			error VARCHAR,
+			result JSON,
+			updated_at TIMESTAMP,
+			PRIMARY KEY (job_id, position)
+		)
+	`)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to create jobs tables", "error", err)
+		}
+		return fmt.Errorf("failed to create jobs tables: %w", err)
+	}
+
+	// Create school program flags (special education, gifted, immersion, IB, Montessori)
+	_, err = d.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS school_program_flags (
+			ncessch VARCHAR NOT NULL,
+			flag VARCHAR NOT NULL,
+			source VARCHAR NOT NULL,
+			evidence VARCHAR,
+			PRIMARY KEY (ncessch, flag, source)
+		)
+	`)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to create school_program_flags table", "error", err)
+		}
+		return fmt.Errorf("failed to create school_program_flags table: %w", err)
+	}
+
+	// Create CTE pathway and dual-enrollment tables (from website extraction, for pathway filters)
+	_, err = d.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS school_cte_pathways (
+			ncessch VARCHAR NOT NULL,
+			pathway VARCHAR NOT NULL,
+			cluster VARCHAR,
+			credentials VARCHAR
+		)
+	`)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to create school_cte_pathways table", "error", err)
+		}
+		return fmt.Errorf("failed to create school_cte_pathways table: %w", err)
+	}
+	_, err = d.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS school_dual_enrollment (
+			ncessch VARCHAR NOT NULL,
+			partner VARCHAR NOT NULL,
+			kind VARCHAR,
+			details VARCHAR
+		)
+	`)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to create school_dual_enrollment table", "error", err)
+		}
+		return fmt.Errorf("failed to create school_dual_enrollment table: %w", err)
+	}
+
+	// Create sports and athletic conference tables (normalized from website
+	// extraction, for sports filters)
+	_, err = d.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS school_sports (
+			ncessch VARCHAR NOT NULL,
+			sport VARCHAR NOT NULL,
+			season VARCHAR,
+			level VARCHAR
+		)
+	`)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to create school_sports table", "error", err)
+		}
+		return fmt.Errorf("failed to create school_sports table: %w", err)
+	}
+	_, err = d.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS school_athletic_conferences (
+			ncessch VARCHAR PRIMARY KEY,
+			conference VARCHAR NOT NULL
+		)
+	`)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to create school_athletic_conferences table", "error", err)
+		}
+		return fmt.Errorf("failed to create school_athletic_conferences table: %w", err)
+	}
+
+	// Create arts programs table (normalized from website extraction, for arts coverage)
+	_, err = d.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS school_arts (
+			ncessch VARCHAR NOT NULL,
+			program VARCHAR NOT NULL,
+			discipline VARCHAR
+		)
+	`)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to create school_arts table", "error", err)
+		}
+		return fmt.Errorf("failed to create school_arts table: %w", err)
+	}
+
+	// Create languages table (normalized from website extraction, for the language directory)
+	_, err = d.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS school_languages (
+			ncessch VARCHAR NOT NULL,
+			language VARCHAR NOT NULL,
+			immersion BOOLEAN NOT NULL,
+			grades VARCHAR
+		)
+	`)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to create school_languages table", "error", err)
+		}
+		return fmt.Errorf("failed to create school_languages table: %w", err)
+	}
+
+	// Create data quality flags table (anomalies found in CCD records after ingestion)
+	_, err = d.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS data_quality_flags (
+			ncessch VARCHAR NOT NULL,
+			flag VARCHAR NOT NULL,
+			field VARCHAR NOT NULL,
+			detail VARCHAR
+		)
+	`)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to create data_quality_flags table", "error", err)
+		}
+		return fmt.Errorf("failed to create data_quality_flags table: %w", err)
+	}
+
+	// Create child profiles and each child's saved schools
+	_, err = d.conn.Exec(`
+		CREATE SEQUENCE IF NOT EXISTS children_seq;
+		CREATE TABLE IF NOT EXISTS children (
+			id BIGINT PRIMARY KEY DEFAULT nextval('children_seq'),
+			name VARCHAR NOT NULL UNIQUE,
+			grade VARCHAR NOT NULL,
+			needs JSON,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE TABLE IF NOT EXISTS child_schools (
+			child_id BIGINT NOT NULL,
+			ncessch VARCHAR NOT NULL,
+			added_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (child_id, ncessch)
+		)
+	`)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to create children tables", "error", err)
+		}
+		return fmt.Errorf("failed to create children tables: %w", err)
+	}
+
+	// Create bus eligibility rules and the home location they're measured from
+	_, err = d.conn.Exec(`
+		CREATE SEQUENCE IF NOT EXISTS bus_rules_seq;
+		CREATE TABLE IF NOT EXISTS bus_rules (
+			id INTEGER PRIMARY KEY DEFAULT nextval('bus_rules_seq'),
+			scope VARCHAR NOT NULL,
+			grade_low VARCHAR NOT NULL DEFAULT '',
+			grade_high VARCHAR NOT NULL DEFAULT '',
+			miles DOUBLE NOT NULL,
+			source VARCHAR NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE TABLE IF NOT EXISTS home_location (
+			id INTEGER PRIMARY KEY,
+			lat DOUBLE NOT NULL,
+			lon DOUBLE NOT NULL,
+			label VARCHAR,
+			updated_at TIMESTAMP
+		)
+	`)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to create bus rule tables", "error", err)
+		}
+		return fmt.Errorf("failed to create bus rule tables: %w", err)
+	}
+
+	// Create tables for imported state safety and discipline reports
+	_, err = d.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS school_safety (
+			ncessch VARCHAR NOT NULL,
+			school_year VARCHAR NOT NULL,
+			metric VARCHAR NOT NULL,
+			value DOUBLE NOT NULL,
+			source VARCHAR NOT NULL,
+			filename VARCHAR NOT NULL,
+			PRIMARY KEY (ncessch, school_year, metric, source)
+		);
+		CREATE TABLE IF NOT EXISTS safety_files (
+			filename VARCHAR PRIMARY KEY,
+			source VARCHAR NOT NULL,
+			key_column VARCHAR NOT NULL,
+			key_type VARCHAR NOT NULL,
+			matched INTEGER NOT NULL,
+			rows INTEGER NOT NULL,
+			loaded_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to create safety tables", "error", err)
+		}
+		return fmt.Errorf("failed to create safety tables: %w", err)
+	}
+
+	// Create tables for state report card ratings and their refreshes
+	_, err = d.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS state_ratings (
+			ncessch VARCHAR NOT NULL,
+			state VARCHAR NOT NULL,
+			source VARCHAR NOT NULL,
+			school_year VARCHAR NOT NULL,
+			indicator VARCHAR NOT NULL,
+			rating VARCHAR NOT NULL,
+			score DOUBLE,
+			source_url VARCHAR NOT NULL,
+			fetched_at TIMESTAMP NOT NULL,
+			PRIMARY KEY (ncessch, source, school_year, indicator)
+		);
+		CREATE TABLE IF NOT EXISTS state_rating_refreshes (
+			source VARCHAR NOT NULL,
+			school_year VARCHAR NOT NULL,
+			files VARCHAR NOT NULL,
+			ratings INTEGER NOT NULL,
+			matched INTEGER NOT NULL,
+			refreshed_at TIMESTAMP NOT NULL
+		)
+	`)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to create state ratings tables", "error", err)
+		}
+		return fmt.Errorf("failed to create state ratings tables: %w", err)
+	}
+
+	// Create the record of tables imported from uploaded files
+	_, err = d.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS imported_tables (
+			table_name VARCHAR PRIMARY KEY,
+			description VARCHAR,
+			source_file VARCHAR,
+			row_count BIGINT,
+			school_column VARCHAR,
+			label VARCHAR,
+			search_columns JSON,
+			imported_at TIMESTAMP NOT NULL
+		);
+		COMMENT ON TABLE imported_tables IS 'Tables the user imported from CSV or Excel files on the Import Data page';
+		COMMENT ON COLUMN imported_tables.school_column IS 'Column holding the NCESSCH school ID, joinable to directory.NCESSCH; NULL when the table isn''t keyed by school';
+		COMMENT ON COLUMN imported_tables.label IS 'Short name for the dataset shown on search results';
+		COMMENT ON COLUMN imported_tables.search_columns IS 'JSON array of the text columns the main search box matches'
+	`)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to create imported tables table", "error", err)
+		}
+		return fmt.Errorf("failed to create imported tables table: %w", err)
+	}
+
+	// Create opt-in usage tracking tables
+	_, err = d.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS usage_settings (
+			id INTEGER PRIMARY KEY,
+			enabled BOOLEAN NOT NULL DEFAULT false,
+			changed_at TIMESTAMP,
+			uploaded_through DATE
+		);
+		CREATE TABLE IF NOT EXISTS usage_counts (
+			event VARCHAR NOT NULL,
+			day DATE NOT NULL,
+			count BIGINT NOT NULL DEFAULT 0,
+			PRIMARY KEY (event, day)
+		)
+	`)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to create usage tables", "error", err)
+		}
+		return fmt.Errorf("failed to create usage tables: %w", err)
+	}
+	if err := d.loadUsageSettings(); err != nil {
+		return err
+	}
+
+	// Create sync watermarks (when this instance last synced with each peer)
+	_, err = d.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS sync_peers (
+			peer VARCHAR PRIMARY KEY,
+			synced_at TIMESTAMP NOT NULL
+		)
+	`)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to create sync_peers table", "error", err)
+		}
+		return fmt.Errorf("failed to create sync_peers table: %w", err)
+	}
+
+	if logger != nil {
+		logger.Info("Cache tables created successfully")
+	}
+
+	return nil
+}
+
+func (d *DB) Close() error {
+	d.closeSearchIndex()
+	return d.conn.Close()
+}
+
+// ExecuteQuery executes an arbitrary SQL query and returns results as a slice of maps
+func (d *DB) ExecuteQuery(query string) ([]map[string]interface{}, error) {
+	rows, err := d.conn.Query(query)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Query execution failed", "error", err, "query", query)
+		}
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+	return scanQueryRows(rows)
+}
+
+// scanQueryRows reads every row of a query's results as a map of column name to value
+func scanQueryRows(rows *sql.Rows) ([]map[string]interface{}, error) {
+	// Get column names
+	columns, err := rows.Columns()
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to get column names", "error", err)
+		}
+		return nil, fmt.Errorf("failed to get column names: %w", err)
+	}
+
+	// Prepare result slice
+	var results []map[string]interface{}
+
+	// Iterate through rows
+	for rows.Next() {
+		// Create a slice of interface{} to hold each column value
+		values := make([]interface{}, len(columns))
+		valuePtrs := make([]interface{}, len(columns))
+		for i := range columns {
+			valuePtrs[i] = &values[i]
+		}
+
+		// Scan the row
+		if err := rows.Scan(valuePtrs...); err != nil {
+			if logger != nil {
+				logger.Error("Failed to scan row", "error", err)
+			}
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		// Create a map for this row
+		rowMap := make(map[string]interface{})
+		for i, col := range columns {
+			val := values[i]
+
+			// Convert []byte to string for better JSON serialization
+			if b, ok := val.([]byte); ok {
+				rowMap[col] = string(b)
+			} else {
+				rowMap[col] = val
+			}
+		}
+
+		results = append(results, rowMap)
+	}
+
+	if err := rows.Err(); err != nil {
+		if logger != nil {
+			logger.Error("Row iteration error", "error", err, "results_count", len(results))
+		}
+		return nil, err
+	}
+
+	return results, nil
+}
+
+func (d *DB) SearchSchools(query string, state string, limit int) ([]School, error) {
+	return d.SearchSchoolsFiltered(SearchFilters{Query: query, State: state}, limit)
+}
+
+// SearchSchoolsFiltered searches with grade, charter, and ratio filters in addition to text and state.
+// Query syntax in the text (see ParseSearchQuery) is applied as filters; if it doesn't parse,
+// the text is searched as-is.
+func (d *DB) SearchSchoolsFiltered(filters SearchFilters, limit int) ([]School, error) {
+	expanded, err := d.expandFilters(filters)
+	if err != nil {
+		return nil, err
+	}
+	return d.searchSchools(expanded, limit, true)
+}
+
+// expandFilters applies query syntax in the text as filters, searching the
+// text as-is if it doesn't parse, and measures a distance filter from the
+// saved home unless it was already resolved (see ResolveNear)
+func (d *DB) expandFilters(filters SearchFilters) (SearchFilters, error) {
+	expanded, err := filters.ExpandQuery()
+	if err != nil && logger != nil {
+		logger.Debug("Query syntax not parsed, using plain search", "query", filters.Query, "error", err)
+	}
+	return expanded.ResolveNear(context.Background(), d, nil)
+}
+
+// searchWhere returns the WHERE clause matching a search, and its arguments.
+// The clause refers to the directory as d, its teachers as t, and its total
+// enrollment as e. With a query, $1 is the full-text query when useFTS is set,
+// or a LIKE pattern matched against search_index otherwise.
+func (filters SearchFilters) searchWhere(useFTS bool) (string, []interface{}) {
+	var args []interface{}
+	var match string
+	switch {
+	case filters.Query != "" && useFTS:
+		args = append(args, filters.Query)
+		match = "fts_main_search_index.match_bm25(d.NCESSCH, $1) IS NOT NULL"
+	case filters.Query != "":
+		// Fallback to LIKE-based search when FTS is not available
+		args = append(args, "%"+filters.Query+"%")
+		match = `d.NCESSCH IN (
+			SELECT si.NCESSCH FROM search_index si
+			WHERE LOWER(si.SCH_NAME) LIKE LOWER($1)
+				OR LOWER(si.MCITY) LIKE LOWER($1)
+				OR LOWER(si.LEA_NAME) LIKE LOWER($1)
+				OR LOWER(si.MSTREET1) LIKE LOWER($1)
+				OR si.MZIP LIKE $1
+				OR LOWER(si.WEBSITE_TEXT) LIKE LOWER($1)
+				OR LOWER(si.IMPORTED_TEXT) LIKE LOWER($1)
+		)`
+	default:
+		// No search query, just apply the filters
+		match = "1=1"
+	}
+
+	var filterClause string
+	filterClause, args = filters.sqlConditions(args)
+	return "WHERE " + match + " " + filterClause + " AND " + notMergedCondition, args
+}
+
+// searchSchools runs a search using FTS ranking when useFTS is set and the
+// full-text index is available, or LIKE matching otherwise
+func (d *DB) searchSchools(filters SearchFilters, limit int, useFTS bool) ([]School, error) {
+	defer d.lockSearchIndex()()
+	return d.querySchools(d.conn, filters, limit, useFTS && d.hasFTS, false)
+}
+
+// querySchools runs a search on q, only among the schools in the
+// search_scope temp table if scoped (see withSearchScope). The caller holds
+// the search index lock.
+func (d *DB) querySchools(q queryer, filters SearchFilters, limit int, useFTS, scoped bool) ([]School, error) {
+	var schools []School
+	query, state := filters.Query, filters.State
+
+	where, args := filters.searchWhere(useFTS)
+	if scoped {
+		where += " AND " + searchScopeCondition
+	}
+	orderBy := "d.SCH_NAME"
+	if query != "" && useFTS {
+		// Rank full-text matches by relevance
+		orderBy = "fts_main_search_index.match_bm25(d.NCESSCH, $1) DESC"
+	}
+
+	sqlQuery := fmt.Sprintf(`
+		SELECT
+			d.NCESSCH,
+			d.SCH_NAME,
+			d.ST,
+			d.STATENAME,
+			COALESCE(d.MCITY, ''),
+			COALESCE(d.LEA_NAME, ''),
+			d.LEAID,
+			d.SCHOOL_YEAR,
+			t.TEACHERS,
+			d.LEVEL,
+			d.PHONE,
+			d.WEBSITE,
+			d.MZIP,
+			d.MSTREET1,
+			d.MSTREET2,
+			d.MSTREET3,
+			d.SCH_TYPE_TEXT,
+			d.GSLO,
+			d.GSHI,
+			d.CHARTER_TEXT,
+			e.STUDENT_COUNT
+		FROM directory d
+		LEFT JOIN teachers t ON d.NCESSCH = t.NCESSCH
+		LEFT JOIN enrollment e ON d.NCESSCH = e.NCESSCH AND e.TOTAL_INDICATOR = 'Education Unit Total'
+		%s
+		ORDER BY %s
+		LIMIT %d
+	`, where, orderBy, limit)
+
+	rows, err := q.Query(sqlQuery, args...)
+	if err != nil {
+		if logger != nil {
+			logger.Error("School search query failed", "error", err, "query", query, "state", state, "limit", limit)
+		}
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var s School
+		err := rows.Scan(
+			&s.NCESSCH,
+			&s.Name,
+			&s.State,
+			&s.StateName,
+			&s.City,
+			&s.District,
+			&s.DistrictID,
+			&s.SchoolYear,
+			&s.Teachers,
+			&s.Level,
+			&s.Phone,
+			&s.Website,
+			&s.Zip,
+			&s.Street1,
+			&s.Street2,
+			&s.Street3,
+			&s.SchoolType,
+			&s.GradeLow,
+			&s.GradeHigh,
+			&s.CharterText,
+			&s.Enrollment,
+		)
+		if err != nil {
+			if logger != nil {
+				logger.Error("Failed to scan school row", "error", err, "query", query, "state", state)
+			}
+			return nil, fmt.Errorf("scan failed: %w", err)
+		}
+		d.applyCorrections(&s)
+		schools = append(schools, s)
+	}
+
+	if err := rows.Err(); err != nil {
+		if logger != nil {
+			logger.Error("Row iteration error in search", "error", err, "query", query, "state", state, "results_count", len(schools))
+		}
+		return nil, err
+	}
+
+	return schools, nil
+}
+
+func (d *DB) GetSchoolByID(ncessch string) (*School, error) {
+	ncessch = d.CanonicalID(ncessch)
+	if cached, ok := d.schoolCache.Get(ncessch); ok {
+		return &cached, nil
+	}
+
+	sqlQuery := `
+		SELECT
+			d.NCESSCH,
+			d.SCH_NAME,
+			d.ST,
+			d.STATENAME,
+			COALESCE(d.MCITY, ''),
+			COALESCE(d.LEA_NAME, ''),
+			d.LEAID,
+			d.SCHOOL_YEAR,
+			t.TEACHERS,
+			d.LEVEL,
+			d.PHONE,
+			d.WEBSITE,
+			d.MZIP,
+			d.MSTREET1,
+			d.MSTREET2,
+			d.MSTREET3,
+			d.SCH_TYPE_TEXT,
+			d.GSLO,
+			d.GSHI,
+			d.CHARTER_TEXT,
+			e.STUDENT_COUNT
+		FROM directory d
+		LEFT JOIN teachers t ON d.NCESSCH = t.NCESSCH
+		LEFT JOIN enrollment e ON d.NCESSCH = e.NCESSCH AND e.TOTAL_INDICATOR = 'Education Unit Total'
+		WHERE d.NCESSCH = $1
+		LIMIT 1
+	`
+
+	var s School
+	err := d.conn.QueryRow(sqlQuery, ncessch).Scan(
+		&s.NCESSCH,
+		&s.Name,
+		&s.State,
+		&s.StateName,
+		&s.City,
+		&s.District,
+		&s.DistrictID,
+		&s.SchoolYear,
+		&s.Teachers,
+		&s.Level,
+		&s.Phone,
+		&s.Website,
+		&s.Zip,
+		&s.Street1,
+		&s.Street2,
+		&s.Street3,
+		&s.SchoolType,
+		&s.GradeLow,
+		&s.GradeHigh,
+		&s.CharterText,
+		&s.Enrollment,
+	)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to get school by ID", "error", err, "ncessch", ncessch)
+		}
+		return nil, fmt.Errorf("school not found: %w", err)
+	}
+
+	d.applyCorrections(&s)
+	d.schoolCache.Put(ncessch, s)
+	return &s, nil
+}
+
+// GetSchoolsInOrder loads schools by NCES ID in the order given, skipping IDs
+// that aren't in the directory
+func (d *DB) GetSchoolsInOrder(ids []string) ([]*School, error) {
+	found, err := d.GetSchoolsByIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string]*School, len(found))
+	for _, school := range found {
+		byID[school.NCESSCH] = school
+	}
+
+	schools := make([]*School, 0, len(ids))
+	for _, id := range ids {
+		if school, ok := byID[id]; ok {
+			schools = append(schools, school)
+		}
+	}
+	return schools, nil
+}
+
+// GetSchoolsByIDs retrieves multiple schools by their NCES IDs
+func (d *DB) GetSchoolsByIDs(ncesschList []string) ([]*School, error) {
+	if len(ncesschList) == 0 {
+		return []*School{}, nil
+	}
+
+	// Merged duplicates stand for their canonical schools, which are listed once
+	seen := make(map[string]bool, len(ncesschList))
+	ids := make([]string, 0, len(ncesschList))
+	for _, id := range ncesschList {
+		if id = d.CanonicalID(id); !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	ncesschList = ids
+
+	// Build a parameterized query with placeholders
 	sqlQuery := `
 		SELECT
 			d.NCESSCH,
@@ -587,441 +2052,1814 @@ func (d *DB) GetSchoolByID(ncessch string) (*School, error) {
 		FROM directory d
 		LEFT JOIN teachers t ON d.NCESSCH = t.NCESSCH
 		LEFT JOIN enrollment e ON d.NCESSCH = e.NCESSCH AND e.TOTAL_INDICATOR = 'Education Unit Total'
-		WHERE d.NCESSCH = $1
-		LIMIT 1
+		WHERE d.NCESSCH = ANY($1)
+	`
+
+	rows, err := d.conn.Query(sqlQuery, ncesschList)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to get schools by IDs", "error", err, "count", len(ncesschList))
+		}
+		return nil, fmt.Errorf("failed to query schools: %w", err)
+	}
+	defer rows.Close()
+
+	schools := make([]*School, 0, len(ncesschList))
+	for rows.Next() {
+		var s School
+		err := rows.Scan(
+			&s.NCESSCH,
+			&s.Name,
+			&s.State,
+			&s.StateName,
+			&s.City,
+			&s.District,
+			&s.DistrictID,
+			&s.SchoolYear,
+			&s.Teachers,
+			&s.Level,
+			&s.Phone,
+			&s.Website,
+			&s.Zip,
+			&s.Street1,
+			&s.Street2,
+			&s.Street3,
+			&s.SchoolType,
+			&s.GradeLow,
+			&s.GradeHigh,
+			&s.CharterText,
+			&s.Enrollment,
+		)
+		if err != nil {
+			if logger != nil {
+				logger.Error("Failed to scan school row", "error", err)
+			}
+			return nil, fmt.Errorf("failed to scan school: %w", err)
+		}
+		d.applyCorrections(&s)
+		schools = append(schools, &s)
+	}
+
+	if err := rows.Err(); err != nil {
+		if logger != nil {
+			logger.Error("Error iterating school rows", "error", err)
+		}
+		return nil, fmt.Errorf("error iterating results: %w", err)
+	}
+
+	return schools, nil
+}
+
+// SearchDistricts finds districts whose name matches query, ranked by full-text relevance
+// when FTS is available. Every word in the query must appear in the name, so
+// "Evanston/Skokie" matches "Evanston/Skokie CCSD 65".
+func (d *DB) SearchDistricts(query string, state string, limit int) ([]District, error) {
+	words := strings.FieldsFunc(query, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) == 0 {
+		return nil, nil
+	}
+
+	var args []interface{}
+	var conditions []string
+	orderBy := "name"
+	defer d.lockSearchIndex()()
+	if d.hasFTS {
+		args = append(args, strings.Join(words, " "))
+		conditions = append(conditions, "fts_main_search_index.match_bm25(d.NCESSCH, $1, fields := 'LEA_NAME') IS NOT NULL")
+		orderBy = "max(fts_main_search_index.match_bm25(d.NCESSCH, $1, fields := 'LEA_NAME')) DESC, name"
+	}
+	for _, word := range words {
+		args = append(args, "%"+word+"%")
+		conditions = append(conditions, fmt.Sprintf("LOWER(d.LEA_NAME) LIKE LOWER($%d)", len(args)))
+	}
+	if state != "" {
+		args = append(args, state)
+		conditions = append(conditions, fmt.Sprintf("d.ST = $%d", len(args)))
+	}
+
+	sqlQuery := fmt.Sprintf(`
+		SELECT
+			d.LEAID,
+			any_value(d.LEA_NAME) AS name,
+			any_value(d.ST),
+			any_value(d.STATENAME),
+			count(*),
+			COALESCE(sum(TRY_CAST(e.STUDENT_COUNT AS BIGINT)), 0)::BIGINT
+		FROM directory d
+		LEFT JOIN enrollment e ON d.NCESSCH = e.NCESSCH AND e.TOTAL_INDICATOR = 'Education Unit Total'
+		WHERE d.LEAID IS NOT NULL AND %s
+		GROUP BY d.LEAID
+		ORDER BY %s
+		LIMIT %d
+	`, strings.Join(conditions, " AND "), orderBy, limit)
+
+	return d.queryDistricts(sqlQuery, args...)
+}
+
+// GetDistrictByID summarizes a district by its LEAID
+func (d *DB) GetDistrictByID(leaid string) (*District, error) {
+	districts, err := d.queryDistricts(`
+		SELECT
+			d.LEAID,
+			any_value(d.LEA_NAME),
+			any_value(d.ST),
+			any_value(d.STATENAME),
+			count(*),
+			COALESCE(sum(TRY_CAST(e.STUDENT_COUNT AS BIGINT)), 0)::BIGINT
+		FROM directory d
+		LEFT JOIN enrollment e ON d.NCESSCH = e.NCESSCH AND e.TOTAL_INDICATOR = 'Education Unit Total'
+		WHERE d.LEAID = $1
+		GROUP BY d.LEAID
+	`, leaid)
+	if err != nil {
+		return nil, err
+	}
+	if len(districts) == 0 {
+		return nil, fmt.Errorf("district not found: %w", sql.ErrNoRows)
+	}
+	return &districts[0], nil
+}
+
+// queryDistricts runs a district summary query
+func (d *DB) queryDistricts(sqlQuery string, args ...interface{}) ([]District, error) {
+	rows, err := d.conn.Query(sqlQuery, args...)
+	if err != nil {
+		if logger != nil {
+			logger.Error("District query failed", "error", err)
+		}
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	var districts []District
+	for rows.Next() {
+		var dist District
+		if err := rows.Scan(&dist.LEAID, &dist.Name, &dist.State, &dist.StateName, &dist.SchoolCount, &dist.Enrollment); err != nil {
+			return nil, fmt.Errorf("scan failed: %w", err)
+		}
+		districts = append(districts, dist)
+	}
+
+	return districts, rows.Err()
+}
+
+// GetSchoolsByDistrict lists a district's schools by name
+func (d *DB) GetSchoolsByDistrict(leaid string, limit int) ([]School, error) {
+	return d.listSchools("d.LEAID = $1", leaid, limit)
+}
+
+// GetSchoolsByZip lists the schools with a five-digit mailing zip code by name
+func (d *DB) GetSchoolsByZip(zip string, limit int) ([]School, error) {
+	return d.listSchools("LEFT(d.MZIP, 5) = $1", zip, limit)
+}
+
+// GetSchoolsByCBSA lists the schools in a core-based statistical area (metro or
+// micro area) by name. Requires a loaded EDGE geocode file.
+func (d *DB) GetSchoolsByCBSA(cbsa string, limit int) ([]School, error) {
+	return d.listSchools("d.NCESSCH IN (SELECT ncessch FROM school_geocodes WHERE cbsa = $1)", cbsa, limit)
+}
+
+// GetSchoolsByCounty lists the schools in a county, by its five-digit FIPS
+// code, by name. Requires a loaded EDGE geocode file.
+func (d *DB) GetSchoolsByCounty(county string, limit int) ([]School, error) {
+	return d.listSchools("d.NCESSCH IN (SELECT ncessch FROM school_counties WHERE county = $1)", county, limit)
+}
+
+// GetSchoolsByCongressionalDistrict lists the schools in a congressional
+// district, by its four-digit code, by name. Requires a loaded EDGE geocode file.
+func (d *DB) GetSchoolsByCongressionalDistrict(cd string, limit int) ([]School, error) {
+	return d.listSchools("d.NCESSCH IN (SELECT ncessch FROM school_congressional_districts WHERE cd = $1)", cd, limit)
+}
+
+// listSchools lists the schools matching a condition on the directory (aliased d) by name
+func (d *DB) listSchools(condition string, arg interface{}, limit int) ([]School, error) {
+	sqlQuery := fmt.Sprintf(`
+		SELECT
+			d.NCESSCH,
+			d.SCH_NAME,
+			d.ST,
+			d.STATENAME,
+			COALESCE(d.MCITY, ''),
+			COALESCE(d.LEA_NAME, ''),
+			d.LEAID,
+			d.SCHOOL_YEAR,
+			t.TEACHERS,
+			d.LEVEL,
+			d.PHONE,
+			d.WEBSITE,
+			d.MZIP,
+			d.MSTREET1,
+			d.MSTREET2,
+			d.MSTREET3,
+			d.SCH_TYPE_TEXT,
+			d.GSLO,
+			d.GSHI,
+			d.CHARTER_TEXT,
+			e.STUDENT_COUNT
+		FROM directory d
+		LEFT JOIN teachers t ON d.NCESSCH = t.NCESSCH
+		LEFT JOIN enrollment e ON d.NCESSCH = e.NCESSCH AND e.TOTAL_INDICATOR = 'Education Unit Total'
+		WHERE %s AND %s
+		ORDER BY d.SCH_NAME
+		LIMIT %d
+	`, condition, notMergedCondition, limit)
+
+	rows, err := d.conn.Query(sqlQuery, arg)
+	if err != nil {
+		if logger != nil {
+			logger.Error("School list query failed", "error", err, "condition", condition)
+		}
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	var schools []School
+	for rows.Next() {
+		var s School
+		err := rows.Scan(
+			&s.NCESSCH,
+			&s.Name,
+			&s.State,
+			&s.StateName,
+			&s.City,
+			&s.District,
+			&s.DistrictID,
+			&s.SchoolYear,
+			&s.Teachers,
+			&s.Level,
+			&s.Phone,
+			&s.Website,
+			&s.Zip,
+			&s.Street1,
+			&s.Street2,
+			&s.Street3,
+			&s.SchoolType,
+			&s.GradeLow,
+			&s.GradeHigh,
+			&s.CharterText,
+			&s.Enrollment,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("scan failed: %w", err)
+		}
+		d.applyCorrections(&s)
+		schools = append(schools, s)
+	}
+
+	return schools, rows.Err()
+}
+
+// EnrollmentString formats the district's total enrollment
+func (dist *District) EnrollmentString() string {
+	if dist.Enrollment == 0 {
+		return "N/A"
+	}
+	return fmt.Sprintf("%d", dist.Enrollment)
+}
+
+func (d *DB) GetStates() ([]string, error) {
+	sqlQuery := `
+		SELECT DISTINCT ST
+		FROM directory
+		ORDER BY ST
+	`
+
+	rows, err := d.conn.Query(sqlQuery)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to query states", "error", err)
+		}
+		return nil, err
+	}
+	defer rows.Close()
+
+	var states []string
+	for rows.Next() {
+		var state string
+		if err := rows.Scan(&state); err != nil {
+			if logger != nil {
+				logger.Error("Failed to scan state row", "error", err)
+			}
+			return nil, err
+		}
+		states = append(states, state)
+	}
+
+	if err := rows.Err(); err != nil {
+		if logger != nil {
+			logger.Error("Row iteration error in GetStates", "error", err, "states_count", len(states))
+		}
+		return nil, err
+	}
+
+	return states, nil
+}
+
+// schoolIDs lists the NCESSCH IDs of schools
+func schoolIDs(schools []School) []string {
+	ids := make([]string, len(schools))
+	for i, s := range schools {
+		ids[i] = s.NCESSCH
+	}
+	return ids
+}
+
+func (s *School) DisplayName() string {
+	return fmt.Sprintf("%s (%s, %s)", s.Name, s.City, s.State)
+}
+
+func (s *School) TeachersString() string {
+	if s.Teachers.Valid {
+		return fmt.Sprintf("%.1f", s.Teachers.Float64)
+	}
+	return "N/A"
+}
+
+func (s *School) LevelString() string {
+	if s.Level.Valid {
+		return s.Level.String
+	}
+	return "N/A"
+}
+
+func (s *School) PhoneString() string {
+	if s.Phone.Valid && s.Phone.String != "" {
+		return s.Phone.String
+	}
+	return "N/A"
+}
+
+func (s *School) WebsiteString() string {
+	if s.Website.Valid && s.Website.String != "" {
+		return schoolWebsiteURL(s)
+	}
+	return "N/A"
+}
+
+func (s *School) FullAddress() string {
+	var parts []string
+
+	if s.Street1.Valid && s.Street1.String != "" {
+		parts = append(parts, s.Street1.String)
+	}
+	if s.Street2.Valid && s.Street2.String != "" {
+		parts = append(parts, s.Street2.String)
+	}
+	if s.Street3.Valid && s.Street3.String != "" {
+		parts = append(parts, s.Street3.String)
+	}
+
+	if len(parts) == 0 {
+		return "N/A"
+	}
+
+	var address string
+	for i, part := range parts {
+		if i > 0 {
+			address += "\n                      "
+		}
+		address += part
+	}
+
+	return address
+}
+
+func (s *School) ZipString() string {
+	if s.Zip.Valid && s.Zip.String != "" {
+		return s.Zip.String
+	}
+	return "N/A"
+}
+
+func (s *School) SchoolTypeString() string {
+	if s.SchoolType.Valid && s.SchoolType.String != "" {
+		return s.SchoolType.String
+	}
+	return "N/A"
+}
+
+func (s *School) GradeRangeString() string {
+	if s.GradeLow.Valid && s.GradeHigh.Valid {
+		low := s.GradeLow.String
+		high := s.GradeHigh.String
+
+		// Convert grade codes to readable format
+		gradeMap := map[string]string{
+			"PK": "Pre-K", "KG": "K", "01": "1", "02": "2", "03": "3",
+			"04": "4", "05": "5", "06": "6", "07": "7", "08": "8",
+			"09": "9", "10": "10", "11": "11", "12": "12", "13": "13",
+			"UG": "Ungraded", "AE": "Adult Ed",
+		}
+
+		lowDisplay := gradeMap[low]
+		highDisplay := gradeMap[high]
+
+		if lowDisplay == "" {
+			lowDisplay = low
+		}
+		if highDisplay == "" {
+			highDisplay = high
+		}
+
+		return fmt.Sprintf("%s - %s", lowDisplay, highDisplay)
+	}
+	return "N/A"
+}
+
+func (s *School) CharterString() string {
+	if s.CharterText.Valid && s.CharterText.String != "" {
+		charter := s.CharterText.String
+		if charter == "Not applicable" || charter == "No" {
+			return "No"
+		}
+		return "Yes"
+	}
+	return "N/A"
+}
+
+func (s *School) EnrollmentString() string {
+	if s.Enrollment.Valid {
+		return fmt.Sprintf("%d", s.Enrollment.Int64)
+	}
+	return "N/A"
+}
+
+func (s *School) StudentTeacherRatio() string {
+	if s.Enrollment.Valid && s.Teachers.Valid && s.Teachers.Float64 > 0 {
+		ratio := float64(s.Enrollment.Int64) / s.Teachers.Float64
+		return fmt.Sprintf("%.1f:1", ratio)
+	}
+	return "N/A"
+}
+
+// SaveAIScraperCache saves AI scraper data to the database cache
+func (d *DB) SaveAIScraperCache(ncessch, schoolName, sourceURL, markdownContent string, legacyData []byte, extractedAt time.Time) error {
+	query := `
+		INSERT INTO ai_scraper_cache (ncessch, school_name, source_url, markdown_content, legacy_data, extracted_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (ncessch) DO UPDATE SET
+			school_name = EXCLUDED.school_name,
+			source_url = EXCLUDED.source_url,
+			markdown_content = EXCLUDED.markdown_content,
+			legacy_data = EXCLUDED.legacy_data,
+			extracted_at = EXCLUDED.extracted_at,
+			created_at = now()
+	`
+
+	_, err := d.conn.Exec(query, ncessch, schoolName, sourceURL, markdownContent, jsonParam(legacyData), extractedAt)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to save AI scraper cache", "error", err, "ncessch", ncessch)
+		}
+		return fmt.Errorf("failed to save AI scraper cache: %w", err)
+	}
+	d.aiCache.Delete(ncessch)
+	d.notifyCacheInvalidated(ncessch)
+
+	if logger != nil {
+		logger.Info("Saved AI scraper data to database cache", "ncessch", ncessch, "school_name", schoolName)
+	}
+
+	return nil
+}
+
+// LoadAIScraperCache loads AI scraper data from the database cache
+func (d *DB) LoadAIScraperCache(ncessch string, maxAge time.Duration) (schoolName, sourceURL, markdownContent string, legacyData []byte, extractedAt time.Time, err error) {
+	if row, ok := d.aiCache.Get(ncessch); ok {
+		if time.Since(row.extractedAt) > maxAge {
+			return "", "", "", nil, time.Time{}, fmt.Errorf("%w: cache expired", ErrCacheMiss)
+		}
+		return row.schoolName, row.sourceURL, row.markdownContent, row.legacyData, row.extractedAt, nil
+	}
+
+	query := `
+		SELECT school_name, source_url, markdown_content, legacy_data::VARCHAR, extracted_at
+		FROM ai_scraper_cache
+		WHERE ncessch = $1
 	`
 
-	var s School
-	err := d.conn.QueryRow(sqlQuery, ncessch).Scan(
-		&s.NCESSCH,
-		&s.Name,
-		&s.State,
-		&s.StateName,
-		&s.City,
-		&s.District,
-		&s.DistrictID,
-		&s.SchoolYear,
-		&s.Teachers,
-		&s.Level,
-		&s.Phone,
-		&s.Website,
-		&s.Zip,
-		&s.Street1,
-		&s.Street2,
-		&s.Street3,
-		&s.SchoolType,
-		&s.GradeLow,
-		&s.GradeHigh,
-		&s.CharterText,
-		&s.Enrollment,
-	)
+	var legacyDataStr sql.NullString
+	err = d.conn.QueryRow(query, ncessch).Scan(&schoolName, &sourceURL, &markdownContent, &legacyDataStr, &extractedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", "", "", nil, time.Time{}, ErrCacheMiss
+		}
+		if logger != nil {
+			logger.Error("Failed to load AI scraper cache", "error", err, "ncessch", ncessch)
+		}
+		return "", "", "", nil, time.Time{}, fmt.Errorf("failed to load AI scraper cache: %w", err)
+	}
+
+	if legacyDataStr.Valid && legacyDataStr.String != "" {
+		legacyData = []byte(legacyDataStr.String)
+	}
+	d.aiCache.Put(ncessch, aiCacheRow{schoolName, sourceURL, markdownContent, legacyData, extractedAt})
+
+	// Check if cache is expired
+	if time.Since(extractedAt) > maxAge {
+		return "", "", "", nil, time.Time{}, fmt.Errorf("%w: cache expired", ErrCacheMiss)
+	}
+
+	if logger != nil {
+		logger.Info("Loaded AI scraper data from database cache", "ncessch", ncessch, "age_hours", int(time.Since(extractedAt).Hours()))
+	}
+
+	return schoolName, sourceURL, markdownContent, legacyData, extractedAt, nil
+}
+
+// jsonParam converts marshaled JSON to a query parameter, using NULL for empty input
+// since DuckDB rejects an empty string in a JSON column
+func jsonParam(b []byte) interface{} {
+	if len(b) == 0 {
+		return nil
+	}
+	return string(b)
+}
+
+// SaveNAEPCache saves NAEP data to the database cache
+func (d *DB) SaveNAEPCache(ncessch, state, district string, stateScores, districtScores, nationalScores []byte, extractedAt time.Time) error {
+	query := `
+		INSERT INTO naep_cache (ncessch, state, district, state_scores, district_scores, national_scores, extracted_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (ncessch) DO UPDATE SET
+			state = EXCLUDED.state,
+			district = EXCLUDED.district,
+			state_scores = EXCLUDED.state_scores,
+			district_scores = EXCLUDED.district_scores,
+			national_scores = EXCLUDED.national_scores,
+			extracted_at = EXCLUDED.extracted_at,
+			created_at = now()
+	`
+
+	_, err := d.conn.Exec(query, ncessch, state, district, jsonParam(stateScores), jsonParam(districtScores), jsonParam(nationalScores), extractedAt)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to save NAEP cache", "error", err, "ncessch", ncessch)
+		}
+		return fmt.Errorf("failed to save NAEP cache: %w", err)
+	}
+	d.naepCache.Delete(ncessch)
+	d.notifyCacheInvalidated(ncessch)
+
+	if logger != nil {
+		logger.Info("Saved NAEP data to database cache", "ncessch", ncessch, "state", state)
+	}
+
+	return nil
+}
+
+// LoadNAEPCache loads NAEP data from the database cache
+func (d *DB) LoadNAEPCache(ncessch string, maxAge time.Duration) (state, district string, stateScores, districtScores, nationalScores []byte, extractedAt time.Time, err error) {
+	if row, ok := d.naepCache.Get(ncessch); ok {
+		if time.Since(row.extractedAt) > maxAge {
+			return "", "", nil, nil, nil, time.Time{}, fmt.Errorf("%w: cache expired", ErrCacheMiss)
+		}
+		return row.state, row.district, row.stateScores, row.districtScores, row.nationalScores, row.extractedAt, nil
+	}
+
+	query := `
+		SELECT state, district, state_scores::VARCHAR, district_scores::VARCHAR, national_scores::VARCHAR, extracted_at
+		FROM naep_cache
+		WHERE ncessch = $1
+	`
+
+	var stateScoresStr, districtScoresStr, nationalScoresStr sql.NullString
+	var districtNull sql.NullString
+
+	err = d.conn.QueryRow(query, ncessch).Scan(&state, &districtNull, &stateScoresStr, &districtScoresStr, &nationalScoresStr, &extractedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", "", nil, nil, nil, time.Time{}, ErrCacheMiss
+		}
+		if logger != nil {
+			logger.Error("Failed to load NAEP cache", "error", err, "ncessch", ncessch)
+		}
+		return "", "", nil, nil, nil, time.Time{}, fmt.Errorf("failed to load NAEP cache: %w", err)
+	}
+
+	if districtNull.Valid {
+		district = districtNull.String
+	}
+
+	if stateScoresStr.Valid && stateScoresStr.String != "" {
+		stateScores = []byte(stateScoresStr.String)
+	}
+
+	if districtScoresStr.Valid && districtScoresStr.String != "" {
+		districtScores = []byte(districtScoresStr.String)
+	}
+
+	if nationalScoresStr.Valid && nationalScoresStr.String != "" {
+		nationalScores = []byte(nationalScoresStr.String)
+	}
+	d.naepCache.Put(ncessch, naepCacheRow{state, district, stateScores, districtScores, nationalScores, extractedAt})
+
+	// Check if cache is expired
+	if time.Since(extractedAt) > maxAge {
+		return "", "", nil, nil, nil, time.Time{}, fmt.Errorf("%w: cache expired", ErrCacheMiss)
+	}
+
+	if logger != nil {
+		logger.Info("Loaded NAEP data from database cache", "ncessch", ncessch, "age_days", int(time.Since(extractedAt).Hours()/24))
+	}
+
+	return state, district, stateScores, districtScores, nationalScores, extractedAt, nil
+}
+
+// SaveParentSummaryCache saves a generated parent summary to the database cache
+func (d *DB) SaveParentSummaryCache(ncessch, summary string, generatedAt time.Time) error {
+	query := `
+		INSERT INTO parent_summary_cache (ncessch, summary, generated_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (ncessch) DO UPDATE SET
+			summary = EXCLUDED.summary,
+			generated_at = EXCLUDED.generated_at,
+			created_at = now()
+	`
+
+	_, err := d.conn.Exec(query, ncessch, summary, generatedAt)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to save parent summary cache", "error", err, "ncessch", ncessch)
+		}
+		return fmt.Errorf("failed to save parent summary cache: %w", err)
+	}
+
+	return nil
+}
+
+// LoadParentSummaryCache loads a parent summary from the database cache
+func (d *DB) LoadParentSummaryCache(ncessch string, maxAge time.Duration) (summary string, generatedAt time.Time, err error) {
+	query := `
+		SELECT summary, generated_at
+		FROM parent_summary_cache
+		WHERE ncessch = $1
+	`
+
+	err = d.conn.QueryRow(query, ncessch).Scan(&summary, &generatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", time.Time{}, ErrCacheMiss
+		}
+		if logger != nil {
+			logger.Error("Failed to load parent summary cache", "error", err, "ncessch", ncessch)
+		}
+		return "", time.Time{}, fmt.Errorf("failed to load parent summary cache: %w", err)
+	}
+
+	// Check if cache is expired
+	if time.Since(generatedAt) > maxAge {
+		return "", time.Time{}, fmt.Errorf("%w: cache expired", ErrCacheMiss)
+	}
+
+	return summary, generatedAt, nil
+}
+
+// SaveNAEPAlert records a NAEP decline alert. An alert already recorded for the same
+// school, subject, grade, and assessment year is left untouched so dismissals stick.
+func (d *DB) SaveNAEPAlert(alert NAEPAlert) error {
+	query := `
+		INSERT INTO naep_alerts (ncessch, school_name, jurisdiction, subject, grade, previous_year, current_year, previous_score, current_score, change)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (ncessch, subject, grade, current_year) DO NOTHING
+	`
+
+	_, err := d.conn.Exec(query, alert.NCESSCH, alert.SchoolName, alert.Jurisdiction, alert.Subject, alert.Grade,
+		alert.PreviousYear, alert.CurrentYear, alert.PreviousScore, alert.CurrentScore, alert.Change)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to save NAEP alert", "error", err, "ncessch", alert.NCESSCH)
+		}
+		return fmt.Errorf("failed to save NAEP alert: %w", err)
+	}
+
+	return nil
+}
+
+// ListNAEPAlerts returns NAEP decline alerts, newest and largest drops first.
+// An empty ncessch lists alerts for all schools.
+func (d *DB) ListNAEPAlerts(ncessch string, includeDismissed bool) ([]NAEPAlert, error) {
+	query := `
+		SELECT id, ncessch, school_name, jurisdiction, subject, grade, previous_year, current_year,
+			previous_score, current_score, change, dismissed, created_at
+		FROM naep_alerts
+		WHERE ($1 = '' OR ncessch = $1)
+			AND ($2 OR NOT dismissed)
+		ORDER BY created_at DESC, change ASC
+	`
+
+	rows, err := d.conn.Query(query, ncessch, includeDismissed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list NAEP alerts: %w", err)
+	}
+	defer rows.Close()
+
+	var alerts []NAEPAlert
+	for rows.Next() {
+		var a NAEPAlert
+		if err := rows.Scan(&a.ID, &a.NCESSCH, &a.SchoolName, &a.Jurisdiction, &a.Subject, &a.Grade,
+			&a.PreviousYear, &a.CurrentYear, &a.PreviousScore, &a.CurrentScore, &a.Change, &a.Dismissed, &a.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan NAEP alert: %w", err)
+		}
+		alerts = append(alerts, a)
+	}
+
+	return alerts, rows.Err()
+}
+
+// AlertedSchoolIDs returns the set of schools with at least one active NAEP alert
+func (d *DB) AlertedSchoolIDs() (map[string]bool, error) {
+	rows, err := d.conn.Query(`SELECT DISTINCT ncessch FROM naep_alerts WHERE NOT dismissed`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load alerted schools: %w", err)
+	}
+	defer rows.Close()
+
+	ids := make(map[string]bool)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan alerted school: %w", err)
+		}
+		ids[id] = true
+	}
+
+	return ids, rows.Err()
+}
+
+// DismissNAEPAlert marks an alert as reviewed so it no longer shows as a badge
+func (d *DB) DismissNAEPAlert(id int64) error {
+	result, err := d.conn.Exec(`UPDATE naep_alerts SET dismissed = true WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to dismiss NAEP alert: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("NAEP alert %d not found", id)
+	}
+	return nil
+}
+
+// SaveNAEPRawResponse archives a raw NAEP API response body
+func (d *DB) SaveNAEPRawResponse(url, jurisdiction, subject string, grade int, statType string, httpStatus int, body string) error {
+	query := `
+		INSERT INTO naep_raw_responses (url, jurisdiction, subject, grade, stat_type, http_status, body)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+
+	_, err := d.conn.Exec(query, url, jurisdiction, subject, grade, statType, httpStatus, body)
+	if err != nil {
+		return fmt.Errorf("failed to save NAEP raw response: %w", err)
+	}
+
+	return nil
+}
+
+// ListNAEPRawResponses returns archived responses for an API URL, newest first
+func (d *DB) ListNAEPRawResponses(url string, limit int) ([]NAEPRawResponse, error) {
+	query := `
+		SELECT id, url, jurisdiction, subject, grade, stat_type, http_status, body, fetched_at
+		FROM naep_raw_responses
+		WHERE url = $1
+		ORDER BY fetched_at DESC, id DESC
+		LIMIT $2
+	`
+
+	rows, err := d.conn.Query(query, url, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list NAEP raw responses: %w", err)
+	}
+	defer rows.Close()
+
+	var responses []NAEPRawResponse
+	for rows.Next() {
+		var r NAEPRawResponse
+		if err := rows.Scan(&r.ID, &r.URL, &r.Jurisdiction, &r.Subject, &r.Grade, &r.StatType, &r.HTTPStatus, &r.Body, &r.FetchedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan NAEP raw response: %w", err)
+		}
+		responses = append(responses, r)
+	}
+
+	return responses, rows.Err()
+}
+
+// GetNAEPOverride loads a school's NAEP override, returning defaults when none is saved
+func (d *DB) GetNAEPOverride(ncessch string) (NAEPOverride, error) {
+	override := NAEPOverride{NCESSCH: ncessch}
+
+	err := d.conn.QueryRow(`
+		SELECT disable_auto_fetch, jurisdiction, updated_at
+		FROM naep_overrides
+		WHERE ncessch = $1
+	`, ncessch).Scan(&override.DisableAutoFetch, &override.Jurisdiction, &override.UpdatedAt)
+	if err != nil && err != sql.ErrNoRows {
+		return override, fmt.Errorf("failed to load NAEP override: %w", err)
+	}
+
+	return override, nil
+}
+
+// SaveNAEPOverride creates or replaces a school's NAEP override
+func (d *DB) SaveNAEPOverride(override NAEPOverride) error {
+	query := `
+		INSERT INTO naep_overrides (ncessch, disable_auto_fetch, jurisdiction, updated_at)
+		VALUES ($1, $2, $3, now())
+		ON CONFLICT (ncessch) DO UPDATE SET
+			disable_auto_fetch = EXCLUDED.disable_auto_fetch,
+			jurisdiction = EXCLUDED.jurisdiction,
+			updated_at = now()
+	`
+
+	if _, err := d.conn.Exec(query, override.NCESSCH, override.DisableAutoFetch, override.Jurisdiction); err != nil {
+		if logger != nil {
+			logger.Error("Failed to save NAEP override", "error", err, "ncessch", override.NCESSCH)
+		}
+		return fmt.Errorf("failed to save NAEP override: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteNAEPCache removes a school's cached NAEP data
+func (d *DB) DeleteNAEPCache(ncessch string) error {
+	if _, err := d.conn.Exec(`DELETE FROM naep_cache WHERE ncessch = $1`, ncessch); err != nil {
+		return fmt.Errorf("failed to delete NAEP cache: %w", err)
+	}
+	d.naepCache.Delete(ncessch)
+	d.notifyCacheInvalidated(ncessch)
+	return nil
+}
+
+// OnCacheInvalidated registers fn to run whenever a school's cached AI or NAEP data
+// changes, so layers that keep derived copies (such as rendered fragments) can drop them
+func (d *DB) OnCacheInvalidated(fn func(ncessch string)) {
+	d.hooksMu.Lock()
+	defer d.hooksMu.Unlock()
+	d.invalidateHooks = append(d.invalidateHooks, fn)
+}
+
+// DataVersion identifies the current state of the data. It changes whenever
+// cached school data changes or the web layer handles a write.
+func (d *DB) DataVersion() uint64 {
+	return d.dataVersion.Load()
+}
+
+// BumpDataVersion records that data changed
+func (d *DB) BumpDataVersion() {
+	d.dataVersion.Add(1)
+}
+
+// notifyCacheInvalidated runs the registered invalidation hooks for a school,
+// moves the data version forward, and queues the school's website text for
+// the search index
+func (d *DB) notifyCacheInvalidated(ncessch string) {
+	d.BumpDataVersion()
+	d.queueSearchIndex(ncessch)
+	d.hooksMu.Lock()
+	hooks := append([]func(string){}, d.invalidateHooks...)
+	d.hooksMu.Unlock()
+
+	for _, fn := range hooks {
+		fn(ncessch)
+	}
+}
+
+// SaveAIDataEdits records manual edits of a school's website data
+func (d *DB) SaveAIDataEdits(edits []AIDataEdit) error {
+	tx, err := d.conn.Begin()
 	if err != nil {
-		if logger != nil {
-			logger.Error("Failed to get school by ID", "error", err, "ncessch", ncessch)
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, e := range edits {
+		_, err := tx.Exec(`
+			INSERT INTO ai_data_edits (ncessch, field, old_value, new_value, source)
+			VALUES ($1, $2, $3, $4, $5)
+		`, e.NCESSCH, e.Field, e.OldValue, e.NewValue, e.Source)
+		if err != nil {
+			if logger != nil {
+				logger.Error("Failed to save website data edit", "error", err, "ncessch", e.NCESSCH, "field", e.Field)
+			}
+			return fmt.Errorf("failed to save website data edit: %w", err)
 		}
-		return nil, fmt.Errorf("school not found: %w", err)
 	}
 
-	return &s, nil
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to save website data edits: %w", err)
+	}
+	return nil
 }
 
-// GetSchoolsByIDs retrieves multiple schools by their NCES IDs
-func (d *DB) GetSchoolsByIDs(ncesschList []string) ([]*School, error) {
-	if len(ncesschList) == 0 {
-		return []*School{}, nil
+// ListAIDataEdits returns a school's most recent manual website data edits, newest first
+func (d *DB) ListAIDataEdits(ncessch string, limit int) ([]AIDataEdit, error) {
+	rows, err := d.conn.Query(`
+		SELECT id, ncessch, field, old_value, new_value, source, edited_at
+		FROM ai_data_edits
+		WHERE ncessch = $1
+		ORDER BY edited_at DESC, id DESC
+		LIMIT $2
+	`, ncessch, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list website data edits: %w", err)
 	}
+	defer rows.Close()
 
-	// Build a parameterized query with placeholders
-	sqlQuery := `
-		SELECT
-			d.NCESSCH,
-			d.SCH_NAME,
-			d.ST,
-			d.STATENAME,
-			COALESCE(d.MCITY, ''),
-			COALESCE(d.LEA_NAME, ''),
-			d.LEAID,
-			d.SCHOOL_YEAR,
-			t.TEACHERS,
-			d.LEVEL,
-			d.PHONE,
-			d.WEBSITE,
-			d.MZIP,
-			d.MSTREET1,
-			d.MSTREET2,
-			d.MSTREET3,
-			d.SCH_TYPE_TEXT,
-			d.GSLO,
-			d.GSHI,
-			d.CHARTER_TEXT,
-			e.STUDENT_COUNT
-		FROM directory d
-		LEFT JOIN teachers t ON d.NCESSCH = t.NCESSCH
-		LEFT JOIN enrollment e ON d.NCESSCH = e.NCESSCH AND e.TOTAL_INDICATOR = 'Education Unit Total'
-		WHERE d.NCESSCH = ANY($1)
-	`
+	var edits []AIDataEdit
+	for rows.Next() {
+		var e AIDataEdit
+		if err := rows.Scan(&e.ID, &e.NCESSCH, &e.Field, &e.OldValue, &e.NewValue, &e.Source, &e.EditedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan website data edit: %w", err)
+		}
+		edits = append(edits, e)
+	}
 
-	rows, err := d.conn.Query(sqlQuery, ncesschList)
+	return edits, rows.Err()
+}
+
+// SaveBenchmarkRun records the results of one bench run
+func (d *DB) SaveBenchmarkRun(run BenchmarkRun) error {
+	tx, err := d.conn.Begin()
 	if err != nil {
-		if logger != nil {
-			logger.Error("Failed to get schools by IDs", "error", err, "count", len(ncesschList))
-		}
-		return nil, fmt.Errorf("failed to query schools: %w", err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
-	defer rows.Close()
+	defer func() { _ = tx.Rollback() }()
 
-	schools := make([]*School, 0, len(ncesschList))
-	for rows.Next() {
-		var s School
-		err := rows.Scan(
-			&s.NCESSCH,
-			&s.Name,
-			&s.State,
-			&s.StateName,
-			&s.City,
-			&s.District,
-			&s.DistrictID,
-			&s.SchoolYear,
-			&s.Teachers,
-			&s.Level,
-			&s.Phone,
-			&s.Website,
-			&s.Zip,
-			&s.Street1,
-			&s.Street2,
-			&s.Street3,
-			&s.SchoolType,
-			&s.GradeLow,
-			&s.GradeHigh,
-			&s.CharterText,
-			&s.Enrollment,
-		)
+	for _, r := range run.Results {
+		_, err := tx.Exec(`
+			INSERT INTO bench_results (run_at, version, name, iterations, p50_ms, p95_ms, max_ms, budget_ms, skipped)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		`, run.RunAt, run.Version, r.Name, r.Iterations, durationMS(r.P50), durationMS(r.P95), durationMS(r.Max), durationMS(r.Budget), r.Skipped)
 		if err != nil {
 			if logger != nil {
-				logger.Error("Failed to scan school row", "error", err)
+				logger.Error("Failed to save benchmark result", "error", err, "name", r.Name)
 			}
-			return nil, fmt.Errorf("failed to scan school: %w", err)
+			return fmt.Errorf("failed to save benchmark result: %w", err)
 		}
-		schools = append(schools, &s)
 	}
 
-	if err := rows.Err(); err != nil {
-		if logger != nil {
-			logger.Error("Error iterating school rows", "error", err)
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to save benchmark run: %w", err)
+	}
+	return nil
+}
+
+// LatestBenchmarkRun loads the most recent bench run, or nil if none has been recorded
+func (d *DB) LatestBenchmarkRun() (*BenchmarkRun, error) {
+	rows, err := d.conn.Query(`
+		SELECT run_at, version, name, iterations, p50_ms, p95_ms, max_ms, budget_ms, skipped
+		FROM bench_results
+		WHERE run_at = (SELECT max(run_at) FROM bench_results)
+		ORDER BY name
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load benchmark results: %w", err)
+	}
+	defer rows.Close()
+
+	var run *BenchmarkRun
+	for rows.Next() {
+		var runAt time.Time
+		var version string
+		var r BenchmarkResult
+		var p50, p95, maxMS, budget float64
+		if err := rows.Scan(&runAt, &version, &r.Name, &r.Iterations, &p50, &p95, &maxMS, &budget, &r.Skipped); err != nil {
+			return nil, fmt.Errorf("failed to scan benchmark result: %w", err)
 		}
-		return nil, fmt.Errorf("error iterating results: %w", err)
+		r.P50, r.P95, r.Max, r.Budget = msDuration(p50), msDuration(p95), msDuration(maxMS), msDuration(budget)
+
+		if run == nil {
+			run = &BenchmarkRun{RunAt: runAt, Version: version}
+		}
+		run.Results = append(run.Results, r)
 	}
 
-	return schools, nil
+	return run, rows.Err()
 }
 
-func (d *DB) GetStates() ([]string, error) {
-	sqlQuery := `
-		SELECT DISTINCT ST
-		FROM directory
-		ORDER BY ST
-	`
-
-	rows, err := d.conn.Query(sqlQuery)
+// SaveSavedSearch creates a saved search, or replaces the filters of the one with the same
+// name. Replacing the filters discards the old result snapshot.
+func (d *DB) SaveSavedSearch(name string, filters SearchFilters, subscribed bool) (*SavedSearch, error) {
+	filtersJSON, err := json.Marshal(filters)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode saved search filters: %w", err)
+	}
+
+	var id int64
+	err = d.conn.QueryRow(`
+		INSERT INTO saved_searches (name, filters, subscribed)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (name) DO UPDATE SET
+			filters = EXCLUDED.filters,
+			subscribed = EXCLUDED.subscribed,
+			result_ids = NULL,
+			checked_at = NULL
+		RETURNING id
+	`, name, string(filtersJSON), subscribed).Scan(&id)
 	if err != nil {
 		if logger != nil {
-			logger.Error("Failed to query states", "error", err)
+			logger.Error("Failed to save saved search", "error", err, "name", name)
 		}
+		return nil, fmt.Errorf("failed to save saved search: %w", err)
+	}
+
+	return d.GetSavedSearch(id)
+}
+
+// GetSavedSearch loads a saved search by ID
+func (d *DB) GetSavedSearch(id int64) (*SavedSearch, error) {
+	searches, err := d.querySavedSearches(`WHERE id = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(searches) == 0 {
+		return nil, fmt.Errorf("saved search %d not found: %w", id, sql.ErrNoRows)
+	}
+	return &searches[0], nil
+}
+
+// ListSavedSearches loads all saved searches by name, with each one's most recent change
+func (d *DB) ListSavedSearches() ([]SavedSearch, error) {
+	searches, err := d.querySavedSearches(`ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := d.ListSavedSearchChanges(0)
+	if err != nil {
 		return nil, err
 	}
+	for i := range searches {
+		for j := range changes {
+			// Changes are newest first
+			if changes[j].SearchID == searches[i].ID {
+				searches[i].LastChange = &changes[j]
+				break
+			}
+		}
+	}
+
+	return searches, nil
+}
+
+// querySavedSearches loads saved searches matching a WHERE/ORDER BY suffix
+func (d *DB) querySavedSearches(suffix string, args ...interface{}) ([]SavedSearch, error) {
+	rows, err := d.conn.Query(`
+		SELECT id, name, filters::VARCHAR, subscribed, result_ids::VARCHAR, checked_at, created_at
+		FROM saved_searches
+		`+suffix, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load saved searches: %w", err)
+	}
 	defer rows.Close()
 
-	var states []string
+	var searches []SavedSearch
 	for rows.Next() {
-		var state string
-		if err := rows.Scan(&state); err != nil {
-			if logger != nil {
-				logger.Error("Failed to scan state row", "error", err)
+		var s SavedSearch
+		var filtersJSON string
+		var resultIDs sql.NullString
+		var checkedAt sql.NullTime
+		if err := rows.Scan(&s.ID, &s.Name, &filtersJSON, &s.Subscribed, &resultIDs, &checkedAt, &s.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan saved search: %w", err)
+		}
+		if err := json.Unmarshal([]byte(filtersJSON), &s.Filters); err != nil {
+			return nil, fmt.Errorf("failed to decode saved search filters: %w", err)
+		}
+		if resultIDs.Valid && resultIDs.String != "" {
+			if err := json.Unmarshal([]byte(resultIDs.String), &s.ResultIDs); err != nil {
+				return nil, fmt.Errorf("failed to decode saved search snapshot: %w", err)
 			}
-			return nil, err
 		}
-		states = append(states, state)
+		if checkedAt.Valid {
+			s.CheckedAt = checkedAt.Time
+		}
+		searches = append(searches, s)
 	}
 
-	if err := rows.Err(); err != nil {
+	return searches, rows.Err()
+}
+
+// SetSavedSearchSubscribed turns change alerts for a saved search on or off
+func (d *DB) SetSavedSearchSubscribed(id int64, subscribed bool) error {
+	result, err := d.conn.Exec(`UPDATE saved_searches SET subscribed = $1 WHERE id = $2`, subscribed, id)
+	if err != nil {
+		return fmt.Errorf("failed to update saved search: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("saved search %d not found: %w", id, sql.ErrNoRows)
+	}
+	return nil
+}
+
+// UpdateSavedSearchSnapshot stores the IDs currently matching a saved search
+func (d *DB) UpdateSavedSearchSnapshot(id int64, resultIDs []string) error {
+	idsJSON, err := json.Marshal(resultIDs)
+	if err != nil {
+		return fmt.Errorf("failed to encode saved search snapshot: %w", err)
+	}
+	if _, err := d.conn.Exec(`
+		UPDATE saved_searches SET result_ids = $1, checked_at = now() WHERE id = $2
+	`, string(idsJSON), id); err != nil {
+		return fmt.Errorf("failed to update saved search snapshot: %w", err)
+	}
+	return nil
+}
+
+// DeleteSavedSearch removes a saved search and its change history
+func (d *DB) DeleteSavedSearch(id int64) error {
+	if _, err := d.conn.Exec(`DELETE FROM saved_search_changes WHERE search_id = $1`, id); err != nil {
+		return fmt.Errorf("failed to delete saved search changes: %w", err)
+	}
+	result, err := d.conn.Exec(`DELETE FROM saved_searches WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete saved search: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("saved search %d not found: %w", id, sql.ErrNoRows)
+	}
+	return nil
+}
+
+// SaveSavedSearchChange records a change to a saved search's results, filling in its ID and time
+func (d *DB) SaveSavedSearchChange(change *SavedSearchChange) error {
+	added, _ := json.Marshal(change.Added)
+	removed, _ := json.Marshal(change.Removed)
+
+	err := d.conn.QueryRow(`
+		INSERT INTO saved_search_changes (search_id, added, removed)
+		VALUES ($1, $2, $3)
+		RETURNING id, detected_at
+	`, change.SearchID, string(added), string(removed)).Scan(&change.ID, &change.DetectedAt)
+	if err != nil {
 		if logger != nil {
-			logger.Error("Row iteration error in GetStates", "error", err, "states_count", len(states))
+			logger.Error("Failed to save saved search change", "error", err, "search_id", change.SearchID)
 		}
-		return nil, err
+		return fmt.Errorf("failed to save saved search change: %w", err)
 	}
+	return nil
+}
 
-	return states, nil
+// ListSavedSearchChanges loads recorded changes, newest first. A limit of 0 returns all of them.
+func (d *DB) ListSavedSearchChanges(limit int) ([]SavedSearchChange, error) {
+	query := `
+		SELECT c.id, c.search_id, s.name, c.added::VARCHAR, c.removed::VARCHAR, c.detected_at
+		FROM saved_search_changes c
+		JOIN saved_searches s ON s.id = c.search_id
+		ORDER BY c.detected_at DESC, c.id DESC
+	`
+	if limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", limit)
+	}
+
+	rows, err := d.conn.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list saved search changes: %w", err)
+	}
+	defer rows.Close()
+
+	var changes []SavedSearchChange
+	for rows.Next() {
+		var c SavedSearchChange
+		var added, removed sql.NullString
+		if err := rows.Scan(&c.ID, &c.SearchID, &c.SearchName, &added, &removed, &c.DetectedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan saved search change: %w", err)
+		}
+		if added.Valid {
+			_ = json.Unmarshal([]byte(added.String), &c.Added)
+		}
+		if removed.Valid {
+			_ = json.Unmarshal([]byte(removed.String), &c.Removed)
+		}
+		changes = append(changes, c)
+	}
+
+	return changes, rows.Err()
 }
 
-func (s *School) DisplayName() string {
-	return fmt.Sprintf("%s (%s, %s)", s.Name, s.City, s.State)
+// loadDirectoryHistoryFile adds a CCD directory file to the directory history unless it
+// was loaded before. It reports whether the file was new.
+func (d *DB) loadDirectoryHistoryFile(path string) (bool, error) {
+	filename := filepath.Base(path)
+
+	var loaded int
+	if err := d.conn.QueryRow(`SELECT count(*) FROM directory_history_files WHERE filename = $1`, filename).Scan(&loaded); err != nil {
+		return false, fmt.Errorf("failed to check directory history: %w", err)
+	}
+	if loaded > 0 {
+		return false, nil
+	}
+
+	tx, err := d.conn.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	_, err = tx.Exec(fmt.Sprintf(`
+		INSERT INTO directory_history (school_year, ncessch, name, state, city, district, street, zip)
+		SELECT SCHOOL_YEAR, NCESSCH, SCH_NAME, ST, MCITY, LEA_NAME, MSTREET1, MZIP
+		FROM read_csv('%s', all_varchar=true)
+		WHERE NCESSCH IS NOT NULL AND SCHOOL_YEAR IS NOT NULL
+		ON CONFLICT DO NOTHING
+	`, path))
+	if err != nil {
+		return false, fmt.Errorf("failed to load %s into directory history: %w", filename, err)
+	}
+
+	if _, err := tx.Exec(`INSERT INTO directory_history_files (filename) VALUES ($1)`, filename); err != nil {
+		return false, fmt.Errorf("failed to record directory history file: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit directory history: %w", err)
+	}
+	return true, nil
 }
 
-func (s *School) TeachersString() string {
-	if s.Teachers.Valid {
-		return fmt.Sprintf("%.1f", s.Teachers.Float64)
+// SchoolYears lists the school years in the directory history, oldest first
+func (d *DB) SchoolYears() ([]string, error) {
+	rows, err := d.conn.Query(`SELECT DISTINCT school_year FROM directory_history ORDER BY school_year`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list school years: %w", err)
 	}
-	return "N/A"
+	defer rows.Close()
+
+	var years []string
+	for rows.Next() {
+		var year string
+		if err := rows.Scan(&year); err != nil {
+			return nil, fmt.Errorf("failed to scan school year: %w", err)
+		}
+		years = append(years, year)
+	}
+	return years, rows.Err()
 }
 
-func (s *School) LevelString() string {
-	if s.Level.Valid {
-		return s.Level.String
+// historySchools loads every school in the directory history for a school year
+func (d *DB) historySchools(year string) ([]historySchool, error) {
+	rows, err := d.conn.Query(`
+		SELECT ncessch, COALESCE(name, ''), COALESCE(state, ''), COALESCE(city, ''),
+			COALESCE(district, ''), COALESCE(street, ''), COALESCE(zip, '')
+		FROM directory_history
+		WHERE school_year = $1
+		ORDER BY ncessch
+	`, year)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load %s schools: %w", year, err)
 	}
-	return "N/A"
+	defer rows.Close()
+
+	var schools []historySchool
+	for rows.Next() {
+		var s historySchool
+		if err := rows.Scan(&s.NCESSCH, &s.Name, &s.State, &s.City, &s.District, &s.Street, &s.Zip); err != nil {
+			return nil, fmt.Errorf("failed to scan history school: %w", err)
+		}
+		schools = append(schools, s)
+	}
+	return schools, rows.Err()
 }
 
-func (s *School) PhoneString() string {
-	if s.Phone.Valid && s.Phone.String != "" {
-		return s.Phone.String
+// SaveSchoolYearChanges replaces the stored school year changes used for badges.
+// Closures are not stored since closed schools have no page to badge.
+func (d *DB) SaveSchoolYearChanges(changes []SchoolYearChange) error {
+	tx, err := d.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.Exec(`DELETE FROM school_year_changes`); err != nil {
+		return fmt.Errorf("failed to clear school year changes: %w", err)
+	}
+
+	for _, c := range changes {
+		if c.Kind == SchoolClosed {
+			continue
+		}
+		_, err := tx.Exec(`
+			INSERT INTO school_year_changes (kind, ncessch, name, state, city, from_year, to_year, previous_ncessch, previous_name)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		`, c.Kind, c.NCESSCH, c.Name, c.State, c.City, c.FromYear, c.ToYear, c.PreviousNCESSCH, c.PreviousName)
+		if err != nil {
+			return fmt.Errorf("failed to save school year change: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to save school year changes: %w", err)
+	}
+	return nil
+}
+
+// SchoolYearChanges loads the stored school year changes keyed by current NCESSCH, for badges
+func (d *DB) SchoolYearChanges() (map[string]SchoolYearChange, error) {
+	rows, err := d.conn.Query(`
+		SELECT kind, ncessch, COALESCE(name, ''), COALESCE(state, ''), COALESCE(city, ''), from_year, to_year,
+			COALESCE(previous_ncessch, ''), COALESCE(previous_name, '')
+		FROM school_year_changes
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load school year changes: %w", err)
 	}
-	return "N/A"
-}
+	defer rows.Close()
 
-func (s *School) WebsiteString() string {
-	if s.Website.Valid && s.Website.String != "" {
-		return s.Website.String
+	changes := make(map[string]SchoolYearChange)
+	for rows.Next() {
+		var c SchoolYearChange
+		if err := rows.Scan(&c.Kind, &c.NCESSCH, &c.Name, &c.State, &c.City, &c.FromYear, &c.ToYear, &c.PreviousNCESSCH, &c.PreviousName); err != nil {
+			return nil, fmt.Errorf("failed to scan school year change: %w", err)
+		}
+		changes[c.NCESSCH] = c
 	}
-	return "N/A"
+	return changes, rows.Err()
 }
 
-func (s *School) FullAddress() string {
-	var parts []string
+// loadGeocodeFile adds an EDGE geocode file's metro areas to school_geocodes unless it
+// was loaded before. It reports whether the file was new.
+func (d *DB) loadGeocodeFile(path string) (bool, error) {
+	filename := filepath.Base(path)
 
-	if s.Street1.Valid && s.Street1.String != "" {
-		parts = append(parts, s.Street1.String)
+	var loaded int
+	if err := d.conn.QueryRow(`SELECT count(*) FROM geocode_files WHERE filename = $1`, filename).Scan(&loaded); err != nil {
+		return false, fmt.Errorf("failed to check geocode files: %w", err)
 	}
-	if s.Street2.Valid && s.Street2.String != "" {
-		parts = append(parts, s.Street2.String)
+	if loaded > 0 {
+		return false, nil
 	}
-	if s.Street3.Valid && s.Street3.String != "" {
-		parts = append(parts, s.Street3.String)
+
+	tx, err := d.conn.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer func() { _ = tx.Rollback() }()
 
-	if len(parts) == 0 {
-		return "N/A"
+	_, err = tx.Exec(fmt.Sprintf(`
+		INSERT OR REPLACE INTO school_geocodes (ncessch, cbsa, cbsa_name)
+		SELECT NCESSCH, any_value(CBSA), any_value(NMCBSA)
+		FROM read_csv('%s', all_varchar=true)
+		WHERE NCESSCH IS NOT NULL AND CBSA IS NOT NULL AND CBSA <> 'N'
+		GROUP BY NCESSCH
+	`, path))
+	if err != nil {
+		return false, fmt.Errorf("failed to load %s into school geocodes: %w", filename, err)
 	}
 
-	var address string
-	for i, part := range parts {
-		if i > 0 {
-			address += "\n                      "
+	// Coordinates are only in EDGE files that include LAT and LON
+	columns, err := csvColumns(tx, path)
+	if err != nil {
+		return false, err
+	}
+	if slices.Contains(columns, "LAT") && slices.Contains(columns, "LON") {
+		_, err = tx.Exec(fmt.Sprintf(`
+			INSERT OR REPLACE INTO school_coordinates (ncessch, lat, lon)
+			SELECT NCESSCH, any_value(TRY_CAST(LAT AS DOUBLE)), any_value(TRY_CAST(LON AS DOUBLE))
+			FROM read_csv('%s', all_varchar=true)
+			WHERE NCESSCH IS NOT NULL AND TRY_CAST(LAT AS DOUBLE) IS NOT NULL AND TRY_CAST(LON AS DOUBLE) IS NOT NULL
+			GROUP BY NCESSCH
+		`, path))
+		if err != nil {
+			return false, fmt.Errorf("failed to load %s into school coordinates: %w", filename, err)
 		}
-		address += part
 	}
 
-	return address
-}
+	// Counties are only in EDGE files that include CNTY
+	if slices.Contains(columns, "CNTY") {
+		countyName := "NULL"
+		if slices.Contains(columns, "NMCNTY") {
+			countyName = "any_value(NMCNTY)"
+		}
+		_, err = tx.Exec(fmt.Sprintf(`
+			INSERT OR REPLACE INTO school_counties (ncessch, county, county_name)
+			SELECT NCESSCH, any_value(lpad(CNTY, 5, '0')), %s
+			FROM read_csv('%s', all_varchar=true)
+			WHERE NCESSCH IS NOT NULL AND CNTY IS NOT NULL AND CNTY <> 'N'
+			GROUP BY NCESSCH
+		`, countyName, path))
+		if err != nil {
+			return false, fmt.Errorf("failed to load %s into school counties: %w", filename, err)
+		}
+	}
 
-func (s *School) ZipString() string {
-	if s.Zip.Valid && s.Zip.String != "" {
-		return s.Zip.String
+	// Congressional districts are only in EDGE files that include CD: the
+	// state FIPS code and the district number, 00 for at-large
+	if slices.Contains(columns, "CD") {
+		_, err = tx.Exec(fmt.Sprintf(`
+			INSERT OR REPLACE INTO school_congressional_districts (ncessch, cd)
+			SELECT NCESSCH, any_value(lpad(CD, 4, '0'))
+			FROM read_csv('%s', all_varchar=true)
+			WHERE NCESSCH IS NOT NULL AND CD IS NOT NULL AND CD <> 'N'
+			GROUP BY NCESSCH
+		`, path))
+		if err != nil {
+			return false, fmt.Errorf("failed to load %s into school congressional districts: %w", filename, err)
+		}
 	}
-	return "N/A"
-}
 
-func (s *School) SchoolTypeString() string {
-	if s.SchoolType.Valid && s.SchoolType.String != "" {
-		return s.SchoolType.String
+	if _, err := tx.Exec(`INSERT INTO geocode_files (filename) VALUES ($1)`, filename); err != nil {
+		return false, fmt.Errorf("failed to record geocode file: %w", err)
 	}
-	return "N/A"
-}
 
-func (s *School) GradeRangeString() string {
-	if s.GradeLow.Valid && s.GradeHigh.Valid {
-		low := s.GradeLow.String
-		high := s.GradeHigh.String
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit school geocodes: %w", err)
+	}
+	return true, nil
+}
 
-		// Convert grade codes to readable format
-		gradeMap := map[string]string{
-			"PK": "Pre-K", "KG": "K", "01": "1", "02": "2", "03": "3",
-			"04": "4", "05": "5", "06": "6", "07": "7", "08": "8",
-			"09": "9", "10": "10", "11": "11", "12": "12", "13": "13",
-			"UG": "Ungraded", "AE": "Adult Ed",
+// loadDirectoryGeographies fills in the counties and congressional districts
+// of schools no EDGE geocode file covers from the directory's CNTY, NMCNTY, and
+// CD columns, which some CCD directory extracts include. EDGE files loaded
+// later replace them.
+func (d *DB) loadDirectoryGeographies() error {
+	columns, err := d.tableColumns("directory", "", false)
+	if err != nil {
+		return err
+	}
+	if slices.Contains(columns, "CNTY") {
+		countyName := "NULL"
+		if slices.Contains(columns, "NMCNTY") {
+			countyName = "any_value(NMCNTY)"
 		}
-
-		lowDisplay := gradeMap[low]
-		highDisplay := gradeMap[high]
-
-		if lowDisplay == "" {
-			lowDisplay = low
+		_, err := d.conn.Exec(fmt.Sprintf(`
+			INSERT OR IGNORE INTO school_counties (ncessch, county, county_name)
+			SELECT NCESSCH, any_value(lpad(CNTY::VARCHAR, 5, '0')), %s
+			FROM directory
+			WHERE NCESSCH IS NOT NULL AND CNTY IS NOT NULL AND CNTY::VARCHAR <> 'N'
+			GROUP BY NCESSCH
+		`, countyName))
+		if err != nil {
+			return fmt.Errorf("failed to load directory counties: %w", err)
 		}
-		if highDisplay == "" {
-			highDisplay = high
+	}
+	if slices.Contains(columns, "CD") {
+		_, err := d.conn.Exec(`
+			INSERT OR IGNORE INTO school_congressional_districts (ncessch, cd)
+			SELECT NCESSCH, any_value(lpad(CD::VARCHAR, 4, '0'))
+			FROM directory
+			WHERE NCESSCH IS NOT NULL AND CD IS NOT NULL AND CD::VARCHAR <> 'N'
+			GROUP BY NCESSCH
+		`)
+		if err != nil {
+			return fmt.Errorf("failed to load directory congressional districts: %w", err)
 		}
+	}
+	return nil
+}
 
-		return fmt.Sprintf("%s - %s", lowDisplay, highDisplay)
+// csvColumns returns the column names of a CSV file
+func csvColumns(tx *sql.Tx, path string) ([]string, error) {
+	rows, err := tx.Query(fmt.Sprintf(`SELECT * FROM read_csv('%s', all_varchar=true) LIMIT 0`, path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read columns of %s: %w", filepath.Base(path), err)
 	}
-	return "N/A"
+	defer rows.Close()
+	return rows.Columns()
 }
 
-func (s *School) CharterString() string {
-	if s.CharterText.Valid && s.CharterText.String != "" {
-		charter := s.CharterText.String
-		if charter == "Not applicable" || charter == "No" {
-			return "No"
-		}
-		return "Yes"
+// CBSAName returns the name of a core-based statistical area, e.g. "Portland-Vancouver-Hillsboro, OR-WA"
+func (d *DB) CBSAName(cbsa string) (string, error) {
+	var name sql.NullString
+	err := d.conn.QueryRow(`SELECT any_value(cbsa_name) FROM school_geocodes WHERE cbsa = $1`, cbsa).Scan(&name)
+	if err != nil {
+		return "", fmt.Errorf("failed to look up CBSA %s: %w", cbsa, err)
 	}
-	return "N/A"
+	return name.String, nil
 }
 
-func (s *School) EnrollmentString() string {
-	if s.Enrollment.Valid {
-		return fmt.Sprintf("%d", s.Enrollment.Int64)
+// CountyName returns the name of a county by its FIPS code, e.g. "Cook County"
+func (d *DB) CountyName(county string) (string, error) {
+	var name sql.NullString
+	err := d.conn.QueryRow(`SELECT any_value(county_name) FROM school_counties WHERE county = $1`, county).Scan(&name)
+	if err != nil {
+		return "", fmt.Errorf("failed to look up county %s: %w", county, err)
 	}
-	return "N/A"
+	return name.String, nil
 }
 
-func (s *School) StudentTeacherRatio() string {
-	if s.Enrollment.Valid && s.Teachers.Valid && s.Teachers.Float64 > 0 {
-		ratio := float64(s.Enrollment.Int64) / s.Teachers.Float64
-		return fmt.Sprintf("%.1f:1", ratio)
+// congressionalDistrictCode returns the four-digit code of a congressional
+// district given as a state and number, e.g. "0612" for "CA-12", from the
+// codes of the state's schools. Codes that are already four digits, and
+// districts with no schools, are returned as is.
+func (d *DB) congressionalDistrictCode(district string) (string, error) {
+	state, number, ok := strings.Cut(district, "-")
+	if !ok {
+		return district, nil
+	}
+	var cd sql.NullString
+	err := d.conn.QueryRow(`
+		SELECT any_value(c.cd)
+		FROM school_congressional_districts c
+		JOIN directory d ON d.NCESSCH = c.ncessch
+		WHERE d.ST = $1 AND (right(c.cd, 2) = $2 OR ($2 = '00' AND right(c.cd, 2) = '98'))
+	`, state, number).Scan(&cd)
+	if err != nil {
+		return "", fmt.Errorf("failed to look up congressional district %s: %w", district, err)
 	}
-	return "N/A"
+	if !cd.Valid {
+		return district, nil
+	}
+	return cd.String, nil
 }
 
-// SaveAIScraperCache saves AI scraper data to the database cache
-func (d *DB) SaveAIScraperCache(ncessch, schoolName, sourceURL, markdownContent string, legacyData []byte, extractedAt time.Time) error {
-	query := `
-		INSERT INTO ai_scraper_cache (ncessch, school_name, source_url, markdown_content, legacy_data, extracted_at)
-		VALUES ($1, $2, $3, $4, $5, $6)
-		ON CONFLICT (ncessch) DO UPDATE SET
-			school_name = EXCLUDED.school_name,
-			source_url = EXCLUDED.source_url,
-			markdown_content = EXCLUDED.markdown_content,
-			legacy_data = EXCLUDED.legacy_data,
-			extracted_at = EXCLUDED.extracted_at,
-			created_at = CURRENT_TIMESTAMP
-	`
+// SchoolGeographies returns a school's county FIPS code and name and its
+// congressional district (e.g. "IL-07"), each empty when no geocode file
+// covers the school
+func (d *DB) SchoolGeographies(ncessch string) (county, countyName, district string, err error) {
+	var name, cd sql.NullString
+	var state string
+	err = d.conn.QueryRow(`
+		SELECT COALESCE(c.county, ''), c.county_name, cd.cd, COALESCE(d.ST, '')
+		FROM directory d
+		LEFT JOIN school_counties c ON c.ncessch = d.NCESSCH
+		LEFT JOIN school_congressional_districts cd ON cd.ncessch = d.NCESSCH
+		WHERE d.NCESSCH = $1
+	`, ncessch).Scan(&county, &name, &cd, &state)
+	if err == sql.ErrNoRows {
+		return "", "", "", nil
+	}
+	if err != nil {
+		return "", "", "", fmt.Errorf("failed to look up school geographies: %w", err)
+	}
+	if cd.Valid && len(cd.String) == 4 {
+		district = state + "-" + cd.String[2:]
+	}
+	return county, name.String, district, nil
+}
 
-	_, err := d.conn.Exec(query, ncessch, schoolName, sourceURL, markdownContent, string(legacyData), extractedAt)
+// SchoolCBSA returns the code and name of a school's core-based statistical area, or
+// empty strings when no geocode file covers the school
+func (d *DB) SchoolCBSA(ncessch string) (cbsa, name string, err error) {
+	var cbsaName sql.NullString
+	err = d.conn.QueryRow(`SELECT cbsa, cbsa_name FROM school_geocodes WHERE ncessch = $1`, ncessch).Scan(&cbsa, &cbsaName)
+	if err == sql.ErrNoRows {
+		return "", "", nil
+	}
 	if err != nil {
-		if logger != nil {
-			logger.Error("Failed to save AI scraper cache", "error", err, "ncessch", ncessch)
-		}
-		return fmt.Errorf("failed to save AI scraper cache: %w", err)
+		return "", "", fmt.Errorf("failed to look up school CBSA: %w", err)
 	}
+	return cbsa, cbsaName.String, nil
+}
 
-	if logger != nil {
-		logger.Info("Saved AI scraper data to database cache", "ncessch", ncessch, "school_name", schoolName)
+// NAEPStateScores returns the cached state and national NAEP scores from every school
+// cached for a state. Schools cache only the grades they serve, so together they give
+// the fullest picture of the state's results.
+func (d *DB) NAEPStateScores(state string) (stateScores, nationalScores [][]byte, err error) {
+	rows, err := d.conn.Query(`
+		SELECT state_scores::VARCHAR, national_scores::VARCHAR
+		FROM naep_cache
+		WHERE state = $1
+		ORDER BY extracted_at DESC
+	`, state)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load cached NAEP scores for %s: %w", state, err)
 	}
+	defer rows.Close()
 
-	return nil
+	for rows.Next() {
+		var stateJSON, nationalJSON sql.NullString
+		if err := rows.Scan(&stateJSON, &nationalJSON); err != nil {
+			return nil, nil, fmt.Errorf("failed to scan cached NAEP scores: %w", err)
+		}
+		if stateJSON.Valid {
+			stateScores = append(stateScores, []byte(stateJSON.String))
+		}
+		if nationalJSON.Valid {
+			nationalScores = append(nationalScores, []byte(nationalJSON.String))
+		}
+	}
+	return stateScores, nationalScores, rows.Err()
 }
 
-// LoadAIScraperCache loads AI scraper data from the database cache
-func (d *DB) LoadAIScraperCache(ncessch string, maxAge time.Duration) (schoolName, sourceURL, markdownContent string, legacyData []byte, extractedAt time.Time, err error) {
-	query := `
-		SELECT school_name, source_url, markdown_content, legacy_data, extracted_at
-		FROM ai_scraper_cache
-		WHERE ncessch = $1
-	`
+// loadEnrollmentHistoryFile adds a CCD membership file's school totals to the enrollment
+// history for year unless it was loaded before. It reports whether the file was new.
+func (d *DB) loadEnrollmentHistoryFile(path, year string) (bool, error) {
+	filename := filepath.Base(path)
 
-	var legacyDataStr sql.NullString
-	err = d.conn.QueryRow(query, ncessch).Scan(&schoolName, &sourceURL, &markdownContent, &legacyDataStr, &extractedAt)
+	var loaded int
+	if err := d.conn.QueryRow(`SELECT count(*) FROM enrollment_history_files WHERE filename = $1`, filename).Scan(&loaded); err != nil {
+		return false, fmt.Errorf("failed to check enrollment history: %w", err)
+	}
+	if loaded > 0 {
+		return false, nil
+	}
+
+	tx, err := d.conn.Begin()
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return "", "", "", nil, time.Time{}, fmt.Errorf("no cache entry found")
-		}
-		if logger != nil {
-			logger.Error("Failed to load AI scraper cache", "error", err, "ncessch", ncessch)
-		}
-		return "", "", "", nil, time.Time{}, fmt.Errorf("failed to load AI scraper cache: %w", err)
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer func() { _ = tx.Rollback() }()
 
-	// Check if cache is expired
-	if time.Since(extractedAt) > maxAge {
-		return "", "", "", nil, time.Time{}, fmt.Errorf("cache expired")
+	_, err = tx.Exec(fmt.Sprintf(`
+		INSERT INTO enrollment_history (school_year, ncessch, students)
+		SELECT $1, NCESSCH, TRY_CAST(STUDENT_COUNT AS BIGINT)
+		FROM read_csv('%s', all_varchar=true)
+		WHERE NCESSCH IS NOT NULL AND TOTAL_INDICATOR = 'Education Unit Total'
+		ON CONFLICT DO NOTHING
+	`, path), year)
+	if err != nil {
+		return false, fmt.Errorf("failed to load %s into enrollment history: %w", filename, err)
 	}
 
-	if legacyDataStr.Valid && legacyDataStr.String != "" {
-		legacyData = []byte(legacyDataStr.String)
+	if _, err := tx.Exec(`INSERT INTO enrollment_history_files (filename) VALUES ($1)`, filename); err != nil {
+		return false, fmt.Errorf("failed to record enrollment history file: %w", err)
 	}
 
-	if logger != nil {
-		logger.Info("Loaded AI scraper data from database cache", "ncessch", ncessch, "age_hours", int(time.Since(extractedAt).Hours()))
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit enrollment history: %w", err)
 	}
+	return true, nil
+}
 
-	return schoolName, sourceURL, markdownContent, legacyData, extractedAt, nil
+// RefreshEnrollmentTrends recomputes the growth pressure of every school with enrollment
+// in at least two school years, from the compound annual change between its first and
+// last year. It returns the number of schools with a trend.
+func (d *DB) RefreshEnrollmentTrends() (int, error) {
+	tx, err := d.conn.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.Exec(`DELETE FROM enrollment_trends`); err != nil {
+		return 0, fmt.Errorf("failed to clear enrollment trends: %w", err)
+	}
+
+	result, err := tx.Exec(`
+		INSERT INTO enrollment_trends (ncessch, pressure, annual_change)
+		SELECT ncessch,
+			CASE WHEN annual_change >= $1 THEN $3 WHEN annual_change <= $2 THEN $5 ELSE $4 END,
+			annual_change
+		FROM (
+			SELECT ncessch,
+				pow(arg_max(students, school_year)::DOUBLE / arg_min(students, school_year),
+					1.0 / (TRY_CAST(LEFT(max(school_year), 4) AS INTEGER) - TRY_CAST(LEFT(min(school_year), 4) AS INTEGER))) - 1 AS annual_change
+			FROM enrollment_history
+			WHERE students > 0 AND school_year <= $6
+			GROUP BY ncessch
+			HAVING count(*) >= 2
+		)
+		WHERE annual_change IS NOT NULL AND isfinite(annual_change)
+	`, rapidGrowthRate, shrinkingRate, EnrollmentGrowing, EnrollmentStable, EnrollmentShrinking, currentSchoolYear())
+	if err != nil {
+		return 0, fmt.Errorf("failed to compute enrollment trends: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to save enrollment trends: %w", err)
+	}
+
+	count, _ := result.RowsAffected()
+	return int(count), nil
 }
 
-// SaveNAEPCache saves NAEP data to the database cache
-func (d *DB) SaveNAEPCache(ncessch, state, district string, stateScores, districtScores, nationalScores []byte, extractedAt time.Time) error {
-	query := `
-		INSERT INTO naep_cache (ncessch, state, district, state_scores, district_scores, national_scores, extracted_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
-		ON CONFLICT (ncessch) DO UPDATE SET
-			state = EXCLUDED.state,
-			district = EXCLUDED.district,
-			state_scores = EXCLUDED.state_scores,
-			district_scores = EXCLUDED.district_scores,
-			national_scores = EXCLUDED.national_scores,
-			extracted_at = EXCLUDED.extracted_at,
-			created_at = CURRENT_TIMESTAMP
-	`
+// EnrollmentTrends loads the enrollment trends of the given schools keyed by NCESSCH.
+// Schools without enrollment in two or more school years are left out.
+func (d *DB) EnrollmentTrends(ncesschList []string) (map[string]EnrollmentTrend, error) {
+	trends := make(map[string]EnrollmentTrend)
+	if len(ncesschList) == 0 {
+		return trends, nil
+	}
 
-	_, err := d.conn.Exec(query, ncessch, state, district, string(stateScores), string(districtScores), string(nationalScores), extractedAt)
+	rows, err := d.conn.Query(`
+		SELECT t.ncessch, t.pressure, t.annual_change, h.school_year, h.students
+		FROM enrollment_trends t
+		JOIN enrollment_history h ON h.ncessch = t.ncessch AND h.students > 0 AND h.school_year <= $2
+		WHERE t.ncessch = ANY($1)
+		ORDER BY t.ncessch, h.school_year
+	`, ncesschList, currentSchoolYear())
 	if err != nil {
-		if logger != nil {
-			logger.Error("Failed to save NAEP cache", "error", err, "ncessch", ncessch)
-		}
-		return fmt.Errorf("failed to save NAEP cache: %w", err)
+		return nil, fmt.Errorf("failed to load enrollment trends: %w", err)
 	}
+	defer rows.Close()
 
-	if logger != nil {
-		logger.Info("Saved NAEP data to database cache", "ncessch", ncessch, "state", state)
+	for rows.Next() {
+		var t EnrollmentTrend
+		var year string
+		var students int64
+		if err := rows.Scan(&t.NCESSCH, &t.Pressure, &t.AnnualChange, &year, &students); err != nil {
+			return nil, fmt.Errorf("failed to scan enrollment trend: %w", err)
+		}
+		if existing, ok := trends[t.NCESSCH]; ok {
+			t = existing
+		}
+		t.Years = append(t.Years, year)
+		t.Counts = append(t.Counts, students)
+		trends[t.NCESSCH] = t
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
 
-	return nil
+	projections, err := d.EnrollmentProjections(ncesschList)
+	if err != nil {
+		return nil, err
+	}
+	for id, p := range projections {
+		if t, ok := trends[id]; ok {
+			t.Projection = &p
+			trends[id] = t
+		}
+	}
+	return trends, nil
 }
 
-// LoadNAEPCache loads NAEP data from the database cache
-func (d *DB) LoadNAEPCache(ncessch string, maxAge time.Duration) (state, district string, stateScores, districtScores, nationalScores []byte, extractedAt time.Time, err error) {
-	query := `
-		SELECT state, district, state_scores, district_scores, national_scores, extracted_at
-		FROM naep_cache
-		WHERE ncessch = $1
-	`
+// loadStaffFile replaces the district staffing with a CCD district staff file unless it
+// is the file already loaded. Derived totals and subtotals are skipped so that each
+// staff member is counted once. It reports whether the file was new.
+func (d *DB) loadStaffFile(path string) (bool, error) {
+	filename := filepath.Base(path)
 
-	var stateScoresStr, districtScoresStr, nationalScoresStr sql.NullString
-	var districtNull sql.NullString
+	var loaded int
+	if err := d.conn.QueryRow(`SELECT count(*) FROM staff_files WHERE filename = $1`, filename).Scan(&loaded); err != nil {
+		return false, fmt.Errorf("failed to check staff files: %w", err)
+	}
+	if loaded > 0 {
+		return false, nil
+	}
 
-	err = d.conn.QueryRow(query, ncessch).Scan(&state, &districtNull, &stateScoresStr, &districtScoresStr, &nationalScoresStr, &extractedAt)
+	tx, err := d.conn.Begin()
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return "", "", nil, nil, nil, time.Time{}, fmt.Errorf("no cache entry found")
-		}
-		if logger != nil {
-			logger.Error("Failed to load NAEP cache", "error", err, "ncessch", ncessch)
-		}
-		return "", "", nil, nil, nil, time.Time{}, fmt.Errorf("failed to load NAEP cache: %w", err)
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer func() { _ = tx.Rollback() }()
 
-	// Check if cache is expired
-	if time.Since(extractedAt) > maxAge {
-		return "", "", nil, nil, nil, time.Time{}, fmt.Errorf("cache expired")
+	if _, err := tx.Exec(`DELETE FROM district_staff`); err != nil {
+		return false, fmt.Errorf("failed to clear district staffing: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM staff_files`); err != nil {
+		return false, fmt.Errorf("failed to clear staff files: %w", err)
 	}
 
-	if districtNull.Valid {
-		district = districtNull.String
+	_, err = tx.Exec(fmt.Sprintf(`
+		INSERT INTO district_staff (leaid, staff_group, fte)
+		SELECT LEAID, %s AS staff_group, sum(TRY_CAST(STAFF_COUNT AS DOUBLE))
+		FROM read_csv('%s', all_varchar=true)
+		WHERE LEAID IS NOT NULL AND STAFF IS NOT NULL
+			AND COALESCE(TOTAL_INDICATOR, '') NOT ILIKE '%%total%%'
+			AND COALESCE(TOTAL_INDICATOR, '') NOT ILIKE 'derived%%'
+		GROUP BY LEAID, staff_group
+	`, staffGroupSQL("STAFF"), path))
+	if err != nil {
+		return false, fmt.Errorf("failed to load %s into district staffing: %w", filename, err)
 	}
 
-	if stateScoresStr.Valid && stateScoresStr.String != "" {
-		stateScores = []byte(stateScoresStr.String)
+	if _, err := tx.Exec(`INSERT INTO staff_files (filename) VALUES ($1)`, filename); err != nil {
+		return false, fmt.Errorf("failed to record staff file: %w", err)
 	}
 
-	if districtScoresStr.Valid && districtScoresStr.String != "" {
-		districtScores = []byte(districtScoresStr.String)
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit district staffing: %w", err)
 	}
+	return true, nil
+}
 
-	if nationalScoresStr.Valid && nationalScoresStr.String != "" {
-		nationalScores = []byte(nationalScoresStr.String)
+// DistrictStaffing loads the staffing composition of the given districts keyed by LEAID,
+// with each district's enrollment for the counselor ratio. Districts without staff data
+// are left out.
+func (d *DB) DistrictStaffing(leaids []string) (map[string]DistrictStaffing, error) {
+	result := make(map[string]DistrictStaffing)
+	if len(leaids) == 0 {
+		return result, nil
+	}
+
+	rows, err := d.conn.Query(`
+		SELECT s.leaid, s.staff_group, COALESCE(s.fte, 0),
+			COALESCE((
+				SELECT sum(TRY_CAST(e.STUDENT_COUNT AS BIGINT))
+				FROM directory dir
+				JOIN enrollment e ON dir.NCESSCH = e.NCESSCH AND e.TOTAL_INDICATOR = 'Education Unit Total'
+				WHERE dir.LEAID = s.leaid
+			), 0)::BIGINT
+		FROM district_staff s
+		WHERE s.leaid = ANY($1)
+	`, leaids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load district staffing: %w", err)
 	}
+	defer rows.Close()
 
-	if logger != nil {
-		logger.Info("Loaded NAEP data from database cache", "ncessch", ncessch, "age_days", int(time.Since(extractedAt).Hours()/24))
+	fte := make(map[string]map[string]float64)
+	students := make(map[string]int64)
+	for rows.Next() {
+		var leaid, group string
+		var value float64
+		var count int64
+		if err := rows.Scan(&leaid, &group, &value, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan district staffing: %w", err)
+		}
+		if fte[leaid] == nil {
+			fte[leaid] = make(map[string]float64)
+		}
+		fte[leaid][group] = value
+		students[leaid] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
 
-	return state, district, stateScores, districtScores, nationalScores, extractedAt, nil
+	for leaid, groups := range fte {
+		result[leaid] = newDistrictStaffing(leaid, groups, students[leaid])
+	}
+	return result, nil
 }
@@ -1,7 +1,11 @@
 package main
 
 import (
+	"database/sql"
+	"errors"
+	"strings"
 	"testing"
+	"time"
 )
 
 // TestNewDB tests database initialization with mock data
@@ -92,6 +96,69 @@ func TestSearchSchools(t *testing.T) {
 	}
 }
 
+// TestSearchDistricts tests matching districts by name, including multi-word and slash-separated queries
+func TestSearchDistricts(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	testCases := []struct {
+		name          string
+		query         string
+		state         string
+		expectedCount int
+		expectedLEAID string
+	}{
+		{"Single word", "Houston", "", 1, "4800000"},
+		{"Slash separated words", "Miami/Dade", "", 1, "1200000"},
+		{"Words in any order", "unified angeles", "", 1, "0600001"},
+		{"Shared word", "Unified", "", 2, ""},
+		{"State filter", "Unified", "TX", 0, ""},
+		{"School names don't match districts", "Lincoln", "", 0, ""},
+		{"Punctuation only", "/", "", 0, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			districts, err := db.SearchDistricts(tc.query, tc.state, 10)
+			if err != nil {
+				t.Fatalf("SearchDistricts failed: %v", err)
+			}
+			if len(districts) != tc.expectedCount {
+				t.Fatalf("Expected %d districts, got %d: %+v", tc.expectedCount, len(districts), districts)
+			}
+			if tc.expectedLEAID != "" && districts[0].LEAID != tc.expectedLEAID {
+				t.Errorf("Expected district %s, got %s", tc.expectedLEAID, districts[0].LEAID)
+			}
+		})
+	}
+}
+
+// TestGetDistrictByID tests district summaries and listing a district's schools
+func TestGetDistrictByID(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	district, err := db.GetDistrictByID("0600000")
+	if err != nil {
+		t.Fatalf("GetDistrictByID failed: %v", err)
+	}
+	if district.Name != "San Francisco Unified School District" || district.SchoolCount != 1 {
+		t.Errorf("Unexpected district: %+v", district)
+	}
+
+	schools, err := db.GetSchoolsByDistrict("0600000", 100)
+	if err != nil {
+		t.Fatalf("GetSchoolsByDistrict failed: %v", err)
+	}
+	if len(schools) != 1 || schools[0].NCESSCH != "360000100001" {
+		t.Errorf("Expected Lincoln Elementary, got %+v", schools)
+	}
+
+	if _, err := db.GetDistrictByID("9999999"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected sql.ErrNoRows for unknown district, got %v", err)
+	}
+}
+
 // TestGetSchoolByID tests retrieving a specific school by ID
 func TestGetSchoolByID(t *testing.T) {
 	db, cleanup := SetupTestDB(t)
@@ -283,3 +350,38 @@ func TestDatabasePersistence(t *testing.T) {
 		t.Errorf("Expected consistent results, got %d then %d", len(schools1), len(schools2))
 	}
 }
+
+// TestCacheRoundTrip tests that AI and NAEP cache entries load back, including empty JSON columns
+func TestCacheRoundTrip(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	extractedAt := time.Now().Add(-time.Hour)
+
+	if err := db.SaveAIScraperCache("360000100001", "Lincoln Elementary", "https://lincoln.example.org", "# Lincoln", []byte(`{"mascot":"Lions"}`), extractedAt); err != nil {
+		t.Fatalf("Failed to save AI cache: %v", err)
+	}
+	_, sourceURL, markdown, legacy, _, err := db.LoadAIScraperCache("360000100001", 24*time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to load AI cache: %v", err)
+	}
+	if sourceURL != "https://lincoln.example.org" || markdown != "# Lincoln" || !strings.Contains(string(legacy), "Lions") {
+		t.Errorf("Unexpected AI cache contents: %q %q %q", sourceURL, markdown, legacy)
+	}
+
+	// State-only schools have no district or national scores
+	if err := db.SaveNAEPCache("360000100001", "CA", "", []byte(`[{"subject":"reading"}]`), nil, nil, extractedAt); err != nil {
+		t.Fatalf("Failed to save NAEP cache: %v", err)
+	}
+	state, _, stateScores, districtScores, _, _, err := db.LoadNAEPCache("360000100001", 24*time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to load NAEP cache: %v", err)
+	}
+	if state != "CA" || !strings.Contains(string(stateScores), "reading") || len(districtScores) != 0 {
+		t.Errorf("Unexpected NAEP cache contents: %q %q %q", state, stateScores, districtScores)
+	}
+
+	if _, _, _, _, _, _, err := db.LoadNAEPCache("360000100001", time.Minute); err == nil {
+		t.Error("Expected expired NAEP cache entry to return an error")
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Demo mode runs the app on the sample schools in demo/, with NAEP and Claude
+// responses recorded in demo/naep.json and demo/anthropic.json. The default
+// HTTP transport is replaced, so every client, including the Data Explorer's
+// agent, is served from the recordings, and nothing reaches the network.
+
+//go:embed demo
+var demoFiles embed.FS
+
+// demoQuestions are the Data Explorer questions demo/anthropic.json has
+// answers for
+var demoQuestions = []string{
+	"What is the average enrollment by state?",
+	"Show me the top 10 schools by student-teacher ratio",
+}
+
+// errDemoOffline is returned for requests demo mode has no recording for
+var errDemoOffline = errors.New("not available in demo mode")
+
+// demoTransport serves NAEP and Claude requests from recorded fixtures and
+// refuses all others
+type demoTransport struct {
+	naep      *fixtureSet
+	anthropic *fixtureSet
+}
+
+// RoundTrip serves req from the recordings
+func (t *demoTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	naepURL, _ := url.Parse(naepDataServiceURL)
+	switch {
+	case req.URL.Host == naepURL.Host:
+		return t.naep.RoundTrip(req)
+	case strings.HasSuffix(req.URL.Path, "/v1/messages"):
+		return t.anthropic.RoundTrip(req)
+	}
+	return nil, fmt.Errorf("%s: %w", req.URL.Host, errDemoOffline)
+}
+
+// newDemoTransport loads the recorded demo responses
+func newDemoTransport() (*demoTransport, error) {
+	t := &demoTransport{}
+	for _, f := range []struct {
+		name string
+		key  func(*http.Request) (string, error)
+		set  **fixtureSet
+	}{
+		{"naep.json", naepFixtureKey, &t.naep},
+		{"anthropic.json", anthropicFixtureKey, &t.anthropic},
+	} {
+		data, err := demoFiles.ReadFile(path.Join("demo", f.name))
+		if err != nil {
+			return nil, err
+		}
+		if *f.set, err = parseFixtures(f.name, data, f.key); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// prepareDemo writes the sample schools to a fresh data directory, so every
+// demo starts the same, and serves external requests from the recordings
+func prepareDemo() (string, error) {
+	transport, err := newDemoTransport()
+	if err != nil {
+		return "", err
+	}
+
+	dir := filepath.Join(os.TempDir(), "schoolfinder-demo")
+	if err := os.RemoveAll(dir); err != nil {
+		return "", fmt.Errorf("failed to clear demo data: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create demo data directory: %w", err)
+	}
+	files, err := fs.Glob(demoFiles, "demo/*.csv")
+	if err != nil {
+		return "", err
+	}
+	for _, name := range files {
+		data, err := demoFiles.ReadFile(name)
+		if err != nil {
+			return "", err
+		}
+		if err := os.WriteFile(filepath.Join(dir, path.Base(name)), data, 0644); err != nil {
+			return "", fmt.Errorf("failed to write demo data: %w", err)
+		}
+	}
+
+	http.DefaultTransport = transport
+	// The recordings answer for Claude, so AI features are on without a key
+	if err := os.Setenv("ANTHROPIC_API_KEY", "demo"); err != nil {
+		return "", err
+	}
+
+	fmt.Fprintln(os.Stderr, "🎭 Demo mode: sample schools with recorded NAEP and Claude responses")
+	return dir, nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// The demo's recordings cover what a demo shows: NAEP results, website data,
+// and parent summaries for every sample school, and the Data Explorer's
+// example questions
+func TestDemoRecordings(t *testing.T) {
+	transport, err := newDemoTransport()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defaultTransport := http.DefaultTransport
+	http.DefaultTransport = transport
+	t.Cleanup(func() { http.DefaultTransport = defaultTransport })
+	t.Setenv("ANTHROPIC_API_KEY", "demo")
+
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+	naep := NewNAEPClient(db)
+	ai, err := NewAIScraperService("demo", db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	schools, err := db.SearchSchools("School", "", maxResults)
+	if err != nil || len(schools) != 5 {
+		t.Fatalf("SearchSchools = %d schools, %v", len(schools), err)
+	}
+	for _, school := range schools {
+		naepData, err := naep.FetchNAEPData(ctx, &school)
+		if err != nil && !errors.Is(err, ErrNoNAEPData) {
+			t.Errorf("%s: FetchNAEPData: %v", school.Name, err)
+		}
+		enhanced, err := ai.ScrapeSchoolWebsite(ctx, &school)
+		if err != nil {
+			t.Errorf("%s: ScrapeSchoolWebsite: %v", school.Name, err)
+			continue
+		}
+		if _, err := ai.GenerateParentSummary(ctx, &school, enhanced, naepData); err != nil {
+			t.Errorf("%s: GenerateParentSummary: %v", school.Name, err)
+		}
+	}
+
+	h := &WebHandler{DB: db}
+	for _, question := range demoQuestions {
+		result, err := h.queryWithAI(ctx, question)
+		if err != nil {
+			t.Errorf("%q: %v", question, err)
+			continue
+		}
+		if result.SQLQuery == "" || len(result.TableData) == 0 || result.ResponseText == "" {
+			t.Errorf("%q = %+v", question, result)
+		}
+	}
+
+	// Anything else stays off the network
+	if _, err := http.Get("https://example.com"); err == nil || !strings.Contains(err.Error(), errDemoOffline.Error()) {
+		t.Errorf("GET example.com in demo mode = %v", err)
+	}
+}
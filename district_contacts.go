@@ -0,0 +1,289 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// DistrictContacts is the leadership and enrollment office contact information
+// extracted from a district's website, keyed by LEAID
+type DistrictContacts struct {
+	LEAID        string    `json:"leaid"`
+	DistrictName string    `json:"district_name"`
+	SourceURL    string    `json:"source_url"` // The district website Claude found
+	ExtractedAt  time.Time `json:"extracted_at"`
+
+	Superintendent   *StaffContact  `json:"superintendent,omitempty"`
+	BoardMembers     []StaffContact `json:"board_members,omitempty"`
+	EnrollmentOffice []StaffContact `json:"enrollment_office,omitempty"`
+
+	// Markdown content from AI extraction
+	MarkdownContent string `json:"markdown_content"`
+
+	Stale bool `json:"-"` // Older than the AI cache TTL
+}
+
+// Empty reports whether no contacts were found
+func (c *DistrictContacts) Empty() bool {
+	return c.Superintendent == nil && len(c.BoardMembers) == 0 && len(c.EnrollmentOffice) == 0
+}
+
+// District contact sections, as the extraction prompt names them
+const (
+	districtSectionSuperintendent = "superintendent"
+	districtSectionBoard          = "school board"
+	districtSectionEnrollment     = "enrollment office"
+)
+
+// districtSectionPattern matches the section headings the extraction prompt asks for
+var districtSectionPattern = regexp.MustCompile(`(?im)^#{1,4}\s*\**\s*(superintendent|school board|enrollment office)\b.*$`)
+
+// districtWebsitePattern matches the "Website:" line the extraction prompt asks for
+var districtWebsitePattern = regexp.MustCompile(`(?im)^[\s>*+-]*\**website\**\s*:\**\s*<?(https?://[^\s>)]+)`)
+
+// parseDistrictContacts reads the contacts under each section heading of the
+// extracted markdown. Each contact is a "- Name | Title | Email | Phone" line;
+// anything the district doesn't publish is "not published".
+func parseDistrictContacts(markdown string) (website string, superintendent *StaffContact, board, enrollment []StaffContact) {
+	if m := districtWebsitePattern.FindStringSubmatch(markdown); m != nil {
+		website = strings.TrimRight(m[1], ".,")
+	}
+
+	headings := districtSectionPattern.FindAllStringSubmatchIndex(markdown, -1)
+	for i, h := range headings {
+		end := len(markdown)
+		if i+1 < len(headings) {
+			end = headings[i+1][0]
+		}
+		// Stop at the next heading of any kind
+		body := markdown[h[1]:end]
+		if next := strings.Index(body, "\n#"); next >= 0 {
+			body = body[:next]
+		}
+
+		var contacts []StaffContact
+		for _, line := range strings.Split(body, "\n") {
+			if contact, ok := parseContactLine(line); ok {
+				contacts = append(contacts, contact)
+			}
+		}
+		switch strings.ToLower(markdown[h[2]:h[3]]) {
+		case districtSectionSuperintendent:
+			if superintendent == nil && len(contacts) > 0 {
+				superintendent = &contacts[0]
+			}
+		case districtSectionBoard:
+			board = append(board, contacts...)
+		case districtSectionEnrollment:
+			enrollment = append(enrollment, contacts...)
+		}
+	}
+	return website, superintendent, board, enrollment
+}
+
+// parseContactLine parses "- Name | Title | Email | Phone", allowing for
+// markdown bold and links, and skipping table separators and "not published"
+// rows
+func parseContactLine(line string) (StaffContact, bool) {
+	values, ok := markdownRowFields(line, 4)
+	if !ok || values[0] == "" || strings.EqualFold(values[0], "name") {
+		return StaffContact{}, false
+	}
+	return StaffContact{Name: values[0], Title: values[1], Email: strings.TrimPrefix(values[2], "mailto:"), Phone: values[3]}, true
+}
+
+// markdownRowFields splits a "- a | b | c" list item or table row into n
+// values, taking the text of markdown links and blanking unknown values such
+// as "not published"
+func markdownRowFields(line string, n int) ([]string, bool) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "-") && !strings.HasPrefix(line, "*") && !strings.HasPrefix(line, "|") {
+		return nil, false
+	}
+	line = strings.Trim(strings.TrimLeft(line, "-*+ "), "| ")
+	fields := strings.Split(line, "|")
+	if len(fields) < 2 {
+		return nil, false
+	}
+
+	values := make([]string, n)
+	for i := 0; i < len(fields) && i < len(values); i++ {
+		value := strings.Trim(strings.TrimSpace(fields[i]), "*`")
+		if link := markdownLinkPattern.FindStringSubmatch(value); link != nil {
+			value = link[1]
+		}
+		if careUnknown.MatchString(value) || strings.Trim(value, "-: ") == "" {
+			value = ""
+		}
+		values[i] = value
+	}
+	return values, true
+}
+
+// markdownLinkPattern matches a markdown link, capturing its text
+var markdownLinkPattern = regexp.MustCompile(`^\[([^\]]*)\]\([^)]*\)$`)
+
+// ExtractDistrictContacts uses Claude with web search to find a district's
+// superintendent, school board members, and enrollment office contacts
+func (s *AIScraperService) ExtractDistrictContacts(ctx context.Context, district *District) (*DistrictContacts, error) {
+	content := fmt.Sprintf(`Find leadership and enrollment contact information for %s, a public school district in %s (NCES LEA ID %s).
+
+Use web search to find the district's official website, then its superintendent, board of education, and enrollment or registration pages.
+
+Write your findings in markdown, in exactly this format:
+
+Website: <the district's official website URL>
+
+## Superintendent
+- Name | Title | Email | Phone
+
+## School Board
+- Name | Title (e.g. President, Vice President, Trustee, Area 3) | Email | Phone
+(one line per board member)
+
+## Enrollment Office
+- Name or office name | Title | Email | Phone
+(the office or staff that handle new student enrollment, registration, or school choice)
+
+Write "not published" for any email or phone the district doesn't publish. Don't guess email addresses from a naming pattern.
+After these sections, you may add a short "## Notes" section, e.g. how families contact the board or when it meets.`,
+		district.Name, district.StateName, district.LEAID)
+
+	responseText, err := s.webSearch(ctx, content, "district_name", district.Name, "leaid", district.LEAID)
+	if err != nil {
+		return nil, err
+	}
+
+	if logger != nil {
+		logger.Info("Successfully extracted district contacts with Claude", "district_name", district.Name, "leaid", district.LEAID, slog.Int("response_length", len(responseText)))
+	}
+
+	contacts := &DistrictContacts{
+		LEAID:           district.LEAID,
+		DistrictName:    district.Name,
+		ExtractedAt:     time.Now(),
+		MarkdownContent: responseText,
+	}
+	contacts.SourceURL, contacts.Superintendent, contacts.BoardMembers, contacts.EnrollmentOffice = parseDistrictContacts(responseText)
+	return contacts, nil
+}
+
+// ScrapeDistrictContacts returns a district's cached contacts, extracting them
+// with Claude when there are none or they're older than the AI cache TTL
+func (s *AIScraperService) ScrapeDistrictContacts(ctx context.Context, district *District) (*DistrictContacts, error) {
+	s.db.RecordUsage(usageScrape)
+
+	if cached, err := s.db.LoadDistrictContacts(district.LEAID); err == nil && time.Since(cached.ExtractedAt) <= s.cacheTTL {
+		if logger != nil {
+			logger.Info("Returning cached district contacts from database", "district_name", district.Name, "leaid", district.LEAID)
+		}
+		return cached, nil
+	}
+
+	// Concurrent extractions for a district share one Claude request
+	d := *district
+	return coalesce(ctx, &s.flights, flightKey{d.LEAID, "district_contacts"}, func(ctx context.Context) (*DistrictContacts, error) {
+		contacts, err := s.ExtractDistrictContacts(ctx, &d)
+		if err != nil {
+			if logger != nil {
+				logger.Error("Failed to extract district contacts", "error", err, "district_name", d.Name, "leaid", d.LEAID)
+			}
+			return nil, err
+		}
+
+		// Don't fail if cache save fails, just log
+		if err := s.db.SaveDistrictContacts(contacts); err != nil && logger != nil {
+			logger.Warn("Failed to save district contacts", "error", err, "leaid", d.LEAID)
+		}
+		return contacts, nil
+	})
+}
+
+// SaveDistrictContacts saves a district's extracted contacts, replacing any
+// earlier extraction
+func (d *DB) SaveDistrictContacts(c *DistrictContacts) error {
+	var superintendent []byte
+	if c.Superintendent != nil {
+		var err error
+		if superintendent, err = json.Marshal(c.Superintendent); err != nil {
+			return fmt.Errorf("failed to marshal superintendent: %w", err)
+		}
+	}
+	board, err := json.Marshal(c.BoardMembers)
+	if err != nil {
+		return fmt.Errorf("failed to marshal board members: %w", err)
+	}
+	enrollment, err := json.Marshal(c.EnrollmentOffice)
+	if err != nil {
+		return fmt.Errorf("failed to marshal enrollment office: %w", err)
+	}
+
+	_, err = d.conn.Exec(`
+		INSERT INTO district_contacts (leaid, district_name, source_url, superintendent, board_members, enrollment_office, markdown_content, extracted_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (leaid) DO UPDATE SET
+			district_name = EXCLUDED.district_name,
+			source_url = EXCLUDED.source_url,
+			superintendent = EXCLUDED.superintendent,
+			board_members = EXCLUDED.board_members,
+			enrollment_office = EXCLUDED.enrollment_office,
+			markdown_content = EXCLUDED.markdown_content,
+			extracted_at = EXCLUDED.extracted_at
+	`, c.LEAID, c.DistrictName, c.SourceURL, nullableJSON(superintendent), string(board), string(enrollment), c.MarkdownContent, c.ExtractedAt)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to save district contacts", "error", err, "leaid", c.LEAID)
+		}
+		return fmt.Errorf("failed to save district contacts: %w", err)
+	}
+	return nil
+}
+
+// nullableJSON stores empty JSON as NULL
+func nullableJSON(data []byte) sql.NullString {
+	return sql.NullString{String: string(data), Valid: len(data) > 0}
+}
+
+// LoadDistrictContacts loads a district's extracted contacts, returning
+// sql.ErrNoRows when there are none. Contacts older than the AI cache TTL are
+// returned with Stale set.
+func (d *DB) LoadDistrictContacts(leaid string) (*DistrictContacts, error) {
+	c := &DistrictContacts{LEAID: leaid}
+	var superintendent, board, enrollment sql.NullString
+	err := d.conn.QueryRow(`
+		SELECT district_name, source_url, superintendent, board_members, enrollment_office, markdown_content, extracted_at
+		FROM district_contacts
+		WHERE leaid = $1
+	`, leaid).Scan(&c.DistrictName, &c.SourceURL, &superintendent, &board, &enrollment, &c.MarkdownContent, &c.ExtractedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to load district contacts: %w", err)
+	}
+
+	if superintendent.Valid {
+		c.Superintendent = &StaffContact{}
+		if err := json.Unmarshal([]byte(superintendent.String), c.Superintendent); err != nil {
+			return nil, fmt.Errorf("failed to read superintendent: %w", err)
+		}
+	}
+	for _, field := range []struct {
+		value sql.NullString
+		into  *[]StaffContact
+	}{{board, &c.BoardMembers}, {enrollment, &c.EnrollmentOffice}} {
+		if field.value.Valid {
+			if err := json.Unmarshal([]byte(field.value.String), field.into); err != nil {
+				return nil, fmt.Errorf("failed to read district contacts: %w", err)
+			}
+		}
+	}
+	c.Stale = time.Since(c.ExtractedAt) > cacheTTLFromEnv("AI_CACHE_TTL", defaultAICacheTTL)
+	return c, nil
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/anthropics/anthropic-sdk-go/option"
+)
+
+const testDistrictContacts = `Website: https://www.sfusd.edu/.
+
+## Superintendent
+- **Maria Su** | Superintendent of Schools | [superintendent@sfusd.edu](mailto:superintendent@sfusd.edu) | (415) 241-6121
+
+## School Board
+| Name | Title | Email | Phone |
+|------|-------|-------|-------|
+| Phil Kim | President | kimp@sfusd.edu | not published |
+| Lisa Weissman-Ward | Vice President | not published | not published |
+
+## Enrollment Office
+- Educational Placement Center | Enrollment and school assignment | enroll@sfusd.edu | (415) 241-6085
+
+## Notes
+- The board meets on the second and fourth Tuesday of each month.
+`
+
+// fakeMessages answers every message with the same text
+type fakeMessages struct {
+	text     string
+	requests int
+}
+
+func (f *fakeMessages) New(ctx context.Context, body anthropic.MessageNewParams, opts ...option.RequestOption) (*anthropic.Message, error) {
+	f.requests++
+	var message anthropic.Message
+	raw, err := json.Marshal(map[string]any{
+		"id": "msg_test", "type": "message", "role": "assistant", "model": "claude-haiku-4-5",
+		"content": []map[string]any{{"type": "text", "text": f.text}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &message, json.Unmarshal(raw, &message)
+}
+
+func TestParseDistrictContacts(t *testing.T) {
+	website, superintendent, board, enrollment := parseDistrictContacts(testDistrictContacts)
+	if website != "https://www.sfusd.edu/" {
+		t.Errorf("website = %q", website)
+	}
+	if superintendent == nil || *superintendent != (StaffContact{Name: "Maria Su", Title: "Superintendent of Schools", Email: "superintendent@sfusd.edu", Phone: "(415) 241-6121"}) {
+		t.Errorf("superintendent = %+v", superintendent)
+	}
+	if len(board) != 2 || board[0] != (StaffContact{Name: "Phil Kim", Title: "President", Email: "kimp@sfusd.edu"}) || board[1].Email != "" {
+		t.Errorf("board = %+v", board)
+	}
+	if len(enrollment) != 1 || enrollment[0].Email != "enroll@sfusd.edu" {
+		t.Errorf("enrollment = %+v", enrollment)
+	}
+
+	// Notes and prose aren't contacts
+	_, superintendent, board, enrollment = parseDistrictContacts("The district website doesn't list its board.\n## Notes\n- Meets monthly | Tuesdays")
+	if superintendent != nil || board != nil || enrollment != nil {
+		t.Errorf("contacts from notes = %+v, %+v, %+v", superintendent, board, enrollment)
+	}
+}
+
+func TestDistrictContacts(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	messages := &fakeMessages{text: testDistrictContacts}
+	ai, err := NewAIScraperService("test-key", db, WithMessageCreator(messages))
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := NewRouter(ServerConfig{DB: db, AIScraper: ai})
+
+	get := func() string {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest("GET", "/districts/0600000", nil))
+		return rec.Body.String()
+	}
+	if body := get(); !strings.Contains(body, "Find Contacts") || strings.Contains(body, "Maria Su") {
+		t.Errorf("district page before extraction is missing the button or has contacts")
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("POST", "/districts/0600000/contacts", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "mailto:kimp@sfusd.edu") {
+		t.Fatalf("extraction: status %d, body %s", rec.Code, rec.Body.String())
+	}
+
+	// Contacts are cached by LEAID and shown on the district page
+	contacts, err := db.LoadDistrictContacts("0600000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contacts.DistrictName != "San Francisco Unified School District" || contacts.Superintendent.Name != "Maria Su" || len(contacts.BoardMembers) != 2 || contacts.Stale {
+		t.Errorf("LoadDistrictContacts() = %+v", contacts)
+	}
+	if body := get(); !strings.Contains(body, "Maria Su") || !strings.Contains(body, "Educational Placement Center") || strings.Contains(body, "Find Contacts") {
+		t.Errorf("district page after extraction is missing contacts")
+	}
+	if _, err := ai.ScrapeDistrictContacts(context.Background(), &District{LEAID: "0600000"}); err != nil || messages.requests != 1 {
+		t.Errorf("cached contacts were extracted again: %d requests, %v", messages.requests, err)
+	}
+
+	// Expired contacts are extracted again
+	contacts.ExtractedAt = time.Now().Add(-2 * defaultAICacheTTL)
+	if err := db.SaveDistrictContacts(contacts); err != nil {
+		t.Fatal(err)
+	}
+	if expired, _ := db.LoadDistrictContacts("0600000"); !expired.Stale {
+		t.Error("Expected old contacts to be stale")
+	}
+	if body := get(); !strings.Contains(body, "Refresh Contacts") {
+		t.Error("Expected a refresh button for stale contacts")
+	}
+	if _, err := ai.ScrapeDistrictContacts(context.Background(), &District{LEAID: "0600000", Name: "San Francisco Unified School District"}); err != nil || messages.requests != 2 {
+		t.Errorf("stale contacts weren't extracted again: %d requests, %v", messages.requests, err)
+	}
+
+	if _, err := db.LoadDistrictContacts("9999999"); err == nil {
+		t.Error("Expected no contacts for an unknown district")
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Doctor check statuses
+const (
+	doctorOK   = "ok"
+	doctorWarn = "warn"
+	doctorFail = "fail"
+)
+
+// DoctorCheck is the result of one doctor check
+type DoctorCheck struct {
+	Name   string
+	Status string // doctorOK, doctorWarn, or doctorFail
+	Detail string
+	Hint   string
+}
+
+// Diagnose checks the data files, database, loaded CCD releases, newer NCES
+// releases, and AI setup. A nil checker reports the last saved release check
+// instead of contacting NCES. Checks that need the database are skipped when
+// it can't be opened.
+func Diagnose(ctx context.Context, dataDir string, checker *CCDReleaseChecker) []DoctorCheck {
+	var checks []DoctorCheck
+
+	missing, err := CheckDataFiles(dataDir)
+	switch {
+	case err != nil:
+		checks = append(checks, DoctorCheck{Name: "Data files", Status: doctorFail, Detail: err.Error()})
+	case len(missing) > 0:
+		names := make([]string, 0, len(missing))
+		for _, f := range missing {
+			names = append(names, f.Name)
+		}
+		return append(checks, DoctorCheck{
+			Name:   "Data files",
+			Status: doctorFail,
+			Detail: fmt.Sprintf("%d missing from %s: %s", len(missing), dataDir, strings.Join(names, ", ")),
+			Hint:   "Run schoolfinder without a command to download them",
+		})
+	default:
+		checks = append(checks, DoctorCheck{Name: "Data files", Status: doctorOK, Detail: fmt.Sprintf("All %d present in %s", len(RequiredDataFiles), dataDir)})
+	}
+
+	db, err := NewDB(dataDir)
+	if err != nil {
+		return append(checks, DoctorCheck{
+			Name:   "Database",
+			Status: doctorFail,
+			Detail: err.Error(),
+			Hint:   "Close other School Finder processes, or delete data.duckdb to rebuild it",
+		})
+	}
+	defer db.Close()
+
+	var schools int
+	if err := db.conn.QueryRow(`SELECT count(*) FROM directory`).Scan(&schools); err != nil {
+		checks = append(checks, DoctorCheck{Name: "Database", Status: doctorFail, Detail: err.Error()})
+	} else {
+		checks = append(checks, DoctorCheck{Name: "Database", Status: doctorOK, Detail: fmt.Sprintf("%d schools", schools)})
+	}
+
+	checks = append(checks, dataVersionCheck(db))
+	checks = append(checks, qualityCheck(db))
+	checks = append(checks, releaseCheck(ctx, db, checker))
+
+	if anthropicAPIKey() != "" {
+		checks = append(checks, DoctorCheck{Name: "AI features", Status: doctorOK, Detail: "An Anthropic API key is set"})
+	} else {
+		checks = append(checks, DoctorCheck{
+			Name:   "AI features",
+			Status: doctorWarn,
+			Detail: "No Anthropic API key is set; website extraction and the data explorer are off",
+			Hint:   "Run schoolfinder auth set anthropic, or set ANTHROPIC_API_KEY, to turn them on",
+		})
+	}
+	return checks
+}
+
+// dataVersionCheck reports the CCD releases behind the current data
+func dataVersionCheck(db *DB) DoctorCheck {
+	check := DoctorCheck{Name: "CCD data"}
+	versions, err := db.CurrentDataVersions()
+	if err != nil {
+		check.Status, check.Detail = doctorFail, err.Error()
+		return check
+	}
+	if len(versions) == 0 {
+		check.Status, check.Detail = doctorWarn, "No CCD releases are recorded"
+		return check
+	}
+	var released []string
+	for _, v := range versions {
+		released = append(released, fmt.Sprintf("%s %s", v.Dataset, v.Released.Format("2006-01-02")))
+	}
+	check.Status = doctorOK
+	check.Detail = fmt.Sprintf("%s (released %s)", currentSchoolYear(), strings.Join(released, ", "))
+	if years, err := db.dataVersionYears(); err == nil && len(years) > 1 {
+		check.Detail += fmt.Sprintf("; %d school years loaded", len(years))
+	}
+	return check
+}
+
+// qualityCheck reports the schools the data-quality pass flagged. CCD has some
+// anomalies in every release, so flags are informational.
+func qualityCheck(db *DB) DoctorCheck {
+	check := DoctorCheck{Name: "Data quality"}
+	counts, err := db.QualityFlagCounts()
+	if err != nil {
+		check.Status, check.Detail = doctorFail, err.Error()
+		return check
+	}
+	check.Status = doctorOK
+	if len(counts) == 0 {
+		check.Detail = "No anomalies found"
+		return check
+	}
+	var flagged []string
+	for _, flag := range []string{qualityZeroEnrollment, qualityExtremeRatio, qualityMissingWebsite, qualityGradeRange} {
+		if n := counts[flag]; n > 0 {
+			flagged = append(flagged, fmt.Sprintf("%d %s", n, strings.ReplaceAll(flag, "_", " ")))
+		}
+	}
+	check.Detail = "Flagged " + strings.Join(flagged, ", ") + "; flagged enrollment and ratios are left out of result charts"
+	return check
+}
+
+// releaseCheck reports whether NCES has newer CCD releases, checking now with a
+// checker or from the last saved check without one
+func releaseCheck(ctx context.Context, db *DB, checker *CCDReleaseChecker) DoctorCheck {
+	check := DoctorCheck{Name: "CCD releases"}
+	var result *CCDReleaseCheck
+	var err error
+	if checker != nil {
+		result, err = checker.Check(ctx, db)
+	} else {
+		result, err = db.LastCCDReleaseCheck()
+	}
+
+	switch {
+	case err != nil:
+		check.Status, check.Detail = doctorWarn, fmt.Sprintf("Couldn't check NCES: %v", err)
+		return check
+	case result == nil:
+		check.Status, check.Detail = doctorWarn, "Never checked"
+		check.Hint = "Run schoolfinder doctor without --offline, or schoolfinder db releases"
+		return check
+	}
+
+	checked := "checked " + result.CheckedAt.Local().Format("2006-01-02")
+	if checker != nil {
+		checked = "checked just now"
+	} else if time.Since(result.CheckedAt) > ccdReleaseCheckInterval {
+		checked += ", which may be out of date"
+	}
+	if len(result.New) == 0 {
+		check.Status, check.Detail = doctorOK, fmt.Sprintf("Up to date (%s)", checked)
+		return check
+	}
+	check.Status = doctorWarn
+	check.Detail = fmt.Sprintf("%s (%s)", result.Summary(), checked)
+	check.Hint = "Run schoolfinder db releases --fetch to load them alongside the current year"
+	return check
+}
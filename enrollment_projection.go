@@ -0,0 +1,291 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Projection methods
+const (
+	projectionLinear = "linear" // Two years: continue the one change seen
+	projectionHolt   = "holt"   // Three or more: Holt's linear trend smoothing
+)
+
+// Holt smoothing weights. Enrollment moves slowly and recent years say the
+// most about next year, so the level follows the data closely and the trend
+// adjusts gradually.
+const (
+	holtLevelWeight = 0.8
+	holtTrendWeight = 0.3
+)
+
+// Projection range. The 80% range is the projection plus or minus 1.28 times
+// the typical one-year-ahead miss. Short histories have few or no misses to
+// measure, so the spread is at least 3% of the last year's enrollment, and
+// twice that with fewer than two measured misses.
+const (
+	projectionRangeZ     = 1.28
+	minProjectionSpread  = 0.03
+	projectionRangeLabel = "80% range"
+)
+
+// EnrollmentProjection is the projected enrollment for the school year after
+// the last one loaded
+type EnrollmentProjection struct {
+	SchoolYear string  // The projected year, e.g. "2024-2025"
+	Method     string  // projectionLinear or projectionHolt
+	Projected  float64 // Projected students
+	Low        float64 // Bottom of the 80% range
+	High       float64 // Top of the 80% range
+	Change     float64 // Change from the last loaded year, e.g. -0.06 for -6%
+	BasedOn    int     // School years the projection is based on
+}
+
+// Summary describes the projection, e.g. "Projected 2024-2025: 495 (80% range
+// 470-520), -2.1%"
+func (p EnrollmentProjection) Summary() string {
+	return fmt.Sprintf("Projected %s: %s (%s %s–%s), %+.1f%%", p.SchoolYear,
+		formatChartValue(math.Round(p.Projected)), projectionRangeLabel,
+		formatChartValue(math.Round(p.Low)), formatChartValue(math.Round(p.High)), p.Change*100)
+}
+
+// MethodLabel describes how the projection was made
+func (p EnrollmentProjection) MethodLabel() string {
+	if p.Method == projectionHolt {
+		return fmt.Sprintf("Holt trend from %d years", p.BasedOn)
+	}
+	return fmt.Sprintf("straight line from %d years", p.BasedOn)
+}
+
+// nextSchoolYear returns the school year after year, e.g. "2024-2025" after "2023-2024"
+func nextSchoolYear(year string) (string, error) {
+	normalized, err := normalizeSchoolYear(year)
+	if err != nil {
+		return "", err
+	}
+	start, _ := strconv.Atoi(normalized[:4])
+	return fmt.Sprintf("%d-%d", start+1, start+2), nil
+}
+
+// projectEnrollment projects the next year from consecutive yearly counts,
+// oldest first. It reports false with fewer than two years.
+func projectEnrollment(lastYear string, counts []float64) (EnrollmentProjection, bool) {
+	n := len(counts)
+	if n < 2 {
+		return EnrollmentProjection{}, false
+	}
+	year, err := nextSchoolYear(lastYear)
+	if err != nil {
+		return EnrollmentProjection{}, false
+	}
+
+	// Holt's method: the trend starts as the first change, so the first
+	// forecast that can miss is for the third year
+	level, trend := counts[1], counts[1]-counts[0]
+	var squaredMisses float64
+	misses := 0
+	for _, y := range counts[2:] {
+		forecast := level + trend
+		squaredMisses += (y - forecast) * (y - forecast)
+		misses++
+		prevLevel := level
+		level = holtLevelWeight*y + (1-holtLevelWeight)*(level+trend)
+		trend = holtTrendWeight*(level-prevLevel) + (1-holtTrendWeight)*trend
+	}
+
+	last := counts[n-1]
+	p := EnrollmentProjection{
+		SchoolYear: year,
+		Method:     projectionLinear,
+		Projected:  math.Max(0, level+trend),
+		BasedOn:    n,
+	}
+	if n > 2 {
+		p.Method = projectionHolt
+	}
+
+	spread := minProjectionSpread * last
+	if misses < 2 {
+		spread *= 2
+	}
+	if misses > 0 {
+		spread = math.Max(spread, math.Sqrt(squaredMisses/float64(misses)))
+	}
+	p.Low = math.Max(0, p.Projected-projectionRangeZ*spread)
+	p.High = p.Projected + projectionRangeZ*spread
+	if last > 0 {
+		p.Change = p.Projected/last - 1
+	}
+	return p, true
+}
+
+// RefreshEnrollmentProjections recomputes the projections of every school and
+// district with enrollment in at least two school years up to the current one.
+// District totals add up their schools' enrollment for each year. It returns
+// the number of schools projected.
+func (d *DB) RefreshEnrollmentProjections() (int, error) {
+	schools, err := d.projectSeries(`
+		SELECT ncessch, school_year, students::DOUBLE FROM enrollment_history
+		WHERE students > 0 AND school_year <= $1 ORDER BY ncessch, school_year
+	`, currentSchoolYear())
+	if err != nil {
+		return 0, err
+	}
+	districts, err := d.projectSeries(`
+		SELECT dir.LEAID, h.school_year, sum(h.students)::DOUBLE FROM enrollment_history h
+		JOIN directory dir ON dir.NCESSCH = h.ncessch
+		WHERE h.students > 0 AND h.school_year <= $1 AND dir.LEAID IS NOT NULL
+		GROUP BY dir.LEAID, h.school_year ORDER BY dir.LEAID, h.school_year
+	`, currentSchoolYear())
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := d.conn.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for table, projections := range map[string]map[string]EnrollmentProjection{
+		"enrollment_projections":          schools,
+		"district_enrollment_projections": districts,
+	} {
+		if _, err := tx.Exec(`DELETE FROM ` + table); err != nil {
+			return 0, fmt.Errorf("failed to clear %s: %w", table, err)
+		}
+		if len(projections) == 0 {
+			continue
+		}
+		// Columns are passed as lists and unnested together, one row per key
+		var keys, years, methods []string
+		var projected, low, high, change []float64
+		var basedOn []int64
+		for key, p := range projections {
+			keys = append(keys, key)
+			years = append(years, p.SchoolYear)
+			methods = append(methods, p.Method)
+			projected = append(projected, p.Projected)
+			low = append(low, p.Low)
+			high = append(high, p.High)
+			change = append(change, p.Change)
+			basedOn = append(basedOn, int64(p.BasedOn))
+		}
+		if _, err := tx.Exec(`
+			INSERT INTO `+table+`
+			SELECT unnest($1::VARCHAR[]), unnest($2::VARCHAR[]), unnest($3::VARCHAR[]), unnest($4::DOUBLE[]),
+				unnest($5::DOUBLE[]), unnest($6::DOUBLE[]), unnest($7::DOUBLE[]), unnest($8::BIGINT[])
+		`, keys, years, methods, projected, low, high, change, basedOn); err != nil {
+			return 0, fmt.Errorf("failed to save %s: %w", table, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to save enrollment projections: %w", err)
+	}
+	return len(schools), nil
+}
+
+// projectSeries projects each key's yearly counts, from a query returning key,
+// school year, and count ordered by key and year. Keys missing a year in the
+// middle of their history are projected from the years after the gap.
+func (d *DB) projectSeries(query string, args ...any) (map[string]EnrollmentProjection, error) {
+	rows, err := d.conn.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load enrollment history: %w", err)
+	}
+	defer rows.Close()
+
+	projections := make(map[string]EnrollmentProjection)
+	var key, lastYear string
+	var counts []float64
+	flush := func() {
+		if p, ok := projectEnrollment(lastYear, counts); ok {
+			projections[key] = p
+		}
+	}
+	for rows.Next() {
+		var k, year string
+		var count float64
+		if err := rows.Scan(&k, &year, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan enrollment history: %w", err)
+		}
+		if k != key {
+			if key != "" {
+				flush()
+			}
+			key, counts = k, nil
+		} else if next, err := nextSchoolYear(lastYear); err != nil || next != year {
+			counts = nil
+		}
+		counts = append(counts, count)
+		lastYear = year
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read enrollment history: %w", err)
+	}
+	if key != "" {
+		flush()
+	}
+	return projections, nil
+}
+
+// enrollmentProjections loads saved projections from table keyed by its first column
+func (d *DB) enrollmentProjections(table, keyColumn string, keys []string) (map[string]EnrollmentProjection, error) {
+	projections := make(map[string]EnrollmentProjection)
+	if len(keys) == 0 {
+		return projections, nil
+	}
+	rows, err := d.conn.Query(`
+		SELECT `+keyColumn+`, school_year, method, projected, low, high, change, based_on
+		FROM `+table+` WHERE `+keyColumn+` = ANY($1)
+	`, keys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load enrollment projections: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key string
+		var p EnrollmentProjection
+		if err := rows.Scan(&key, &p.SchoolYear, &p.Method, &p.Projected, &p.Low, &p.High, &p.Change, &p.BasedOn); err != nil {
+			return nil, fmt.Errorf("failed to scan enrollment projection: %w", err)
+		}
+		projections[key] = p
+	}
+	return projections, rows.Err()
+}
+
+// EnrollmentProjections loads the projections of the given schools keyed by NCESSCH
+func (d *DB) EnrollmentProjections(ncesschList []string) (map[string]EnrollmentProjection, error) {
+	return d.enrollmentProjections("enrollment_projections", "ncessch", ncesschList)
+}
+
+// DistrictEnrollmentProjection loads a district's projection, or nil without one
+func (d *DB) DistrictEnrollmentProjection(leaid string) (*EnrollmentProjection, error) {
+	projections, err := d.enrollmentProjections("district_enrollment_projections", "leaid", []string{leaid})
+	if err != nil {
+		return nil, err
+	}
+	if p, ok := projections[leaid]; ok {
+		return &p, nil
+	}
+	return nil, nil
+}
+
+// needsEnrollmentProjections reports whether there is enrollment history to
+// project but no projections, as in databases from before projections
+func (d *DB) needsEnrollmentProjections() (bool, error) {
+	var projected sql.NullInt64
+	var years int
+	err := d.conn.QueryRow(`
+		SELECT (SELECT count(*) FROM enrollment_projections), count(DISTINCT school_year) FROM enrollment_history
+		WHERE school_year <= $1
+	`, currentSchoolYear()).Scan(&projected, &years)
+	if err != nil {
+		return false, fmt.Errorf("failed to check enrollment projections: %w", err)
+	}
+	return projected.Int64 == 0 && years >= 2, nil
+}
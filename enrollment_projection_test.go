@@ -0,0 +1,106 @@
+package main
+
+import (
+	"math"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestProjectEnrollment(t *testing.T) {
+	if _, ok := projectEnrollment("2023-2024", []float64{500}); ok {
+		t.Error("one year projected")
+	}
+
+	// Two years continue the one change seen, with the doubled minimum spread
+	p, ok := projectEnrollment("2023-2024", []float64{400, 450})
+	if !ok || p.Method != projectionLinear || p.SchoolYear != "2024-2025" || p.Projected != 500 {
+		t.Fatalf("linear projection = %+v", p)
+	}
+	if want := 500 - projectionRangeZ*2*minProjectionSpread*450; math.Abs(p.Low-want) > 1e-9 {
+		t.Errorf("Low = %v, want %v", p.Low, want)
+	}
+
+	// A noisy history widens the range past the minimum
+	steady, _ := projectEnrollment("2023-24", []float64{1000, 1010, 1020, 1030, 1040})
+	noisy, _ := projectEnrollment("2023-24", []float64{1000, 1100, 950, 1120, 1040})
+	if steady.Method != projectionHolt || math.Abs(steady.Projected-1050) > 1e-6 {
+		t.Errorf("steady projection = %+v", steady)
+	}
+	if noisy.High-noisy.Low <= steady.High-steady.Low {
+		t.Errorf("noisy range %v-%v isn't wider than steady %v-%v", noisy.Low, noisy.High, steady.Low, steady.High)
+	}
+
+	// Enrollment can't go below zero
+	if p, _ := projectEnrollment("2023-2024", []float64{100, 20}); p.Projected != 0 || p.Low != 0 || p.Change != -1 {
+		t.Errorf("collapsing projection = %+v", p)
+	}
+
+	if got := steady.Summary(); got != "Projected 2024-2025: 1,050 (80% range 1,010–1,090), +1.0%" {
+		t.Errorf("Summary() = %q", got)
+	}
+}
+
+func TestEnrollmentProjections(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	for name, contents := range priorYearEnrollment {
+		if err := os.WriteFile(filepath.Join(db.dataDir, name), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := SyncEnrollmentHistory(db); err != nil {
+		t.Fatal(err)
+	}
+
+	// Lincoln: 400, 450, 500
+	trends, err := db.EnrollmentTrends([]string{"360000100001", "360000100004"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := trends["360000100001"].Projection
+	if p == nil || p.Method != projectionHolt || p.Projected != 550 || p.SchoolYear != "2024-2025" || math.Abs(p.Change-0.1) > 1e-9 {
+		t.Fatalf("Lincoln projection = %+v", p)
+	}
+	if _, ok := trends["360000100004"]; ok {
+		t.Error("Roosevelt has one year but a trend")
+	}
+
+	// Lincoln is its district's only school
+	district, err := db.DistrictEnrollmentProjection("0600000")
+	if err != nil || district == nil || district.Projected != 550 {
+		t.Errorf("district projection = %+v, %v", district, err)
+	}
+
+	// The agent can find shrinking districts; Jefferson's is declining
+	rows, err := db.ExecuteQuery(`SELECT leaid FROM district_enrollment_projections WHERE change < -0.05`)
+	if err != nil || len(rows) != 1 || rows[0]["leaid"] != "4800000" {
+		t.Errorf("shrinking districts = %+v, %v", rows, err)
+	}
+
+	// Databases with history but no projections get them at startup
+	if _, err := db.conn.Exec(`DELETE FROM enrollment_projections`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SyncEnrollmentHistory(db); err != nil {
+		t.Fatal(err)
+	}
+	if projections, err := db.EnrollmentProjections([]string{"360000100001"}); err != nil || len(projections) != 1 {
+		t.Errorf("projections after resync = %+v, %v", projections, err)
+	}
+
+	router := NewRouter(ServerConfig{DB: db})
+	for path, want := range map[string]string{
+		"/schools/360000100001": "Projected 2024-2025: 550",
+		"/districts/0600000":    "Projected 2024-2025: 550",
+	} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("%s is missing %q", path, want)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// enrollmentFilePattern matches CCD membership (enrollment) files for any school year
+const enrollmentFilePattern = "ccd_sch_052_*.csv"
+
+// Enrollment growth pressure classes
+const (
+	EnrollmentGrowing   = "growing"
+	EnrollmentStable    = "stable"
+	EnrollmentShrinking = "shrinking"
+)
+
+// Annual enrollment change thresholds for the growth pressure classes. A school
+// adding 5% a year outgrows its building within a few years; a 3% yearly decline
+// is enough to put programs and staffing at risk.
+const (
+	rapidGrowthRate = 0.05
+	shrinkingRate   = -0.03
+)
+
+// EnrollmentTrend is a school's enrollment across the loaded school years
+type EnrollmentTrend struct {
+	NCESSCH      string
+	Pressure     string  // EnrollmentGrowing, EnrollmentStable, or EnrollmentShrinking
+	AnnualChange float64 // Compound annual change between the first and last year, e.g. 0.06 for +6%
+	Years        []string
+	Counts       []int64
+	Projection   *EnrollmentProjection // Next year's enrollment, when projected
+}
+
+// Label describes the growth pressure for display, e.g. "Rapidly growing"
+func (t EnrollmentTrend) Label() string {
+	return enrollmentPressureLabel(t.Pressure)
+}
+
+// enrollmentPressureLabel describes a growth pressure class for display
+func enrollmentPressureLabel(pressure string) string {
+	switch pressure {
+	case EnrollmentGrowing:
+		return "Rapidly growing"
+	case EnrollmentStable:
+		return "Stable"
+	case EnrollmentShrinking:
+		return "Shrinking"
+	}
+	return ""
+}
+
+// Sparkline draws the enrollment counts, oldest first
+func (t EnrollmentTrend) Sparkline() string {
+	values := make([]float64, len(t.Counts))
+	for i, count := range t.Counts {
+		values[i] = float64(count)
+	}
+	return Sparkline(values)
+}
+
+// Summary describes the change, e.g. "+6.2% a year, 2021-2022 to 2023-2024 (410 → 487)"
+func (t EnrollmentTrend) Summary() string {
+	if len(t.Years) < 2 {
+		return ""
+	}
+	last := len(t.Years) - 1
+	return fmt.Sprintf("%+.1f%% a year, %s to %s (%d → %d)", t.AnnualChange*100, t.Years[0], t.Years[last], t.Counts[0], t.Counts[last])
+}
+
+// validEnrollmentPressure reports whether pressure is a growth pressure class
+func validEnrollmentPressure(pressure string) bool {
+	return enrollmentPressureLabel(pressure) != ""
+}
+
+// ccdFileYear returns the school year of a CCD file from its name, e.g.
+// "2023-2024" for ccd_sch_052_2324_l_1a_073124.csv. The membership file has no
+// school year column in every release, so the name is the reliable source.
+func ccdFileYear(path string) (string, error) {
+	// The year is always in compact form, so "1920" is 2019-20 rather than 1920
+	parts := strings.Split(filepath.Base(path), "_")
+	if len(parts) < 4 || len(parts[3]) != 4 {
+		return "", fmt.Errorf("can't tell the school year of %s", filepath.Base(path))
+	}
+	year, err := normalizeSchoolYear("20" + parts[3][:2] + "-" + parts[3][2:])
+	if err != nil {
+		return "", fmt.Errorf("can't tell the school year of %s: %w", filepath.Base(path), err)
+	}
+	return year, nil
+}
+
+// SyncEnrollmentHistory loads any new membership files in the data directory and,
+// when any were loaded, recomputes each school's enrollment trend. It returns the
+// number of new files loaded.
+func SyncEnrollmentHistory(db *DB) (int, error) {
+	paths, err := filepath.Glob(filepath.Join(db.dataDir, enrollmentFilePattern))
+	if err != nil {
+		return 0, fmt.Errorf("failed to list enrollment files: %w", err)
+	}
+
+	loaded := 0
+	for _, path := range paths {
+		year, err := ccdFileYear(path)
+		if err != nil {
+			return loaded, err
+		}
+		isNew, err := db.loadEnrollmentHistoryFile(path, year)
+		if err != nil {
+			return loaded, err
+		}
+		if isNew {
+			loaded++
+		}
+	}
+	if loaded == 0 {
+		// Databases from before projections have history but nothing projected
+		needed, err := db.needsEnrollmentProjections()
+		if err != nil || !needed {
+			return 0, err
+		}
+		if _, err := db.RefreshEnrollmentProjections(); err != nil {
+			return 0, err
+		}
+		return 0, nil
+	}
+
+	trends, err := db.RefreshEnrollmentTrends()
+	if err != nil {
+		return loaded, err
+	}
+	projected, err := db.RefreshEnrollmentProjections()
+	if err != nil {
+		return loaded, err
+	}
+
+	if logger != nil {
+		logger.Info("Enrollment trends updated", "files", loaded, "schools", trends, "projected", projected)
+	}
+	return loaded, nil
+}
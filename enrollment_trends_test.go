@@ -0,0 +1,117 @@
+package main
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// priorYearEnrollment holds membership files for two earlier school years: Lincoln
+// grows, Washington holds steady, Jefferson shrinks, and Roosevelt has one year only
+var priorYearEnrollment = map[string]string{
+	"ccd_sch_052_2122_l_1a_071722.csv": `NCESSCH,TOTAL_INDICATOR,STUDENT_COUNT
+360000100001,Education Unit Total,400
+360000100002,Education Unit Total,860
+360000100003,Education Unit Total,700
+360000100001,Grade 1,80
+`,
+	"ccd_sch_052_2223_l_1a_083023.csv": `NCESSCH,TOTAL_INDICATOR,STUDENT_COUNT
+360000100001,Education Unit Total,450
+360000100002,Education Unit Total,840
+360000100003,Education Unit Total,660
+`,
+}
+
+func TestCCDFileYear(t *testing.T) {
+	tests := []struct {
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{"/data/ccd_sch_052_2324_l_1a_073124.csv", "2023-2024", false},
+		{"ccd_sch_052_1920_l_1a_092620.csv", "2019-2020", false},
+		{"ccd_sch_052.csv", "", true},
+		{"ccd_sch_052_latest_l.csv", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := ccdFileYear(tt.path)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ccdFileYear(%q) error = %v, wantErr %v", tt.path, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ccdFileYear(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestEnrollmentTrends(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	// Only the current year is loaded, so there is nothing to compare yet
+	trends, err := db.EnrollmentTrends([]string{"360000100001"})
+	if err != nil || len(trends) != 0 {
+		t.Fatalf("EnrollmentTrends() with one year = %+v, %v", trends, err)
+	}
+
+	for name, contents := range priorYearEnrollment {
+		if err := os.WriteFile(filepath.Join(db.dataDir, name), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if loaded, err := SyncEnrollmentHistory(db); err != nil || loaded != 2 {
+		t.Fatalf("SyncEnrollmentHistory() = %d, %v, want 2 new files", loaded, err)
+	}
+
+	ids := []string{"360000100001", "360000100002", "360000100003", "360000100004"}
+	trends, err = db.EnrollmentTrends(ids)
+	if err != nil {
+		t.Fatalf("EnrollmentTrends() error = %v", err)
+	}
+
+	tests := []struct {
+		ncessch  string
+		pressure string
+		change   float64
+	}{
+		{"360000100001", EnrollmentGrowing, math.Sqrt(500.0/400) - 1},
+		{"360000100002", EnrollmentStable, math.Sqrt(850.0/860) - 1},
+		{"360000100003", EnrollmentShrinking, math.Sqrt(620.0/700) - 1},
+	}
+	for _, tt := range tests {
+		trend, ok := trends[tt.ncessch]
+		if !ok {
+			t.Errorf("no trend for %s", tt.ncessch)
+			continue
+		}
+		if trend.Pressure != tt.pressure || math.Abs(trend.AnnualChange-tt.change) > 1e-9 {
+			t.Errorf("%s: pressure %s at %.4f, want %s at %.4f", tt.ncessch, trend.Pressure, trend.AnnualChange, tt.pressure, tt.change)
+		}
+	}
+	if _, ok := trends["360000100004"]; ok {
+		t.Error("Roosevelt has one year of enrollment and should have no trend")
+	}
+
+	lincoln := trends["360000100001"]
+	if len(lincoln.Counts) != 3 || lincoln.Counts[0] != 400 || lincoln.Years[2] != "2023-2024" {
+		t.Errorf("Lincoln history = %v %v", lincoln.Years, lincoln.Counts)
+	}
+	if got := lincoln.Summary(); got != "+11.8% a year, 2021-2022 to 2023-2024 (400 → 500)" {
+		t.Errorf("Summary() = %q", got)
+	}
+	if got := []rune(lincoln.Sparkline()); len(got) != 3 || got[0] != '▁' || got[2] != '█' {
+		t.Errorf("Sparkline() = %q", string(got))
+	}
+
+	// Filtering by growth pressure
+	schools, err := db.SearchSchoolsFiltered(SearchFilters{Trend: EnrollmentShrinking}, 10)
+	if err != nil {
+		t.Fatalf("SearchSchoolsFiltered() error = %v", err)
+	}
+	if len(schools) != 1 || schools[0].NCESSCH != "360000100003" {
+		t.Errorf("shrinking schools = %+v", schools)
+	}
+}
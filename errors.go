@@ -0,0 +1,225 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"charm.land/fantasy"
+	anthropicsdk "github.com/anthropics/anthropic-sdk-go"
+)
+
+// UserError is a failure people using the app can understand and often fix.
+// Error() is the short technical text for logs and wrapping; Message says what
+// went wrong in plain words and Hint what to do about it.
+type UserError struct {
+	err     string
+	Message string
+	Hint    string
+	Status  int // HTTP status for web responses
+}
+
+func (e *UserError) Error() string {
+	return e.err
+}
+
+// Text formats the message and hint on one line for the TUI and CLI
+func (e *UserError) Text() string {
+	if e.Hint == "" {
+		return e.Message
+	}
+	return e.Message + " " + e.Hint
+}
+
+// Errors with user-facing messages. Wrap them with %w to add detail for the logs,
+// e.g. fmt.Errorf("%w: HTTP 403", ErrWebsiteUnavailable).
+var (
+	ErrNoWebsite = &UserError{
+		err:     "no website available for this school",
+		Message: "This school has no website on file.",
+		Hint:    "Websites come from the NCES directory; check the district's site for school details.",
+		Status:  http.StatusUnprocessableEntity,
+	}
+	ErrWebsiteUnavailable = &UserError{
+		err:     "school website unavailable",
+		Message: "The school's website couldn't be read.",
+		Hint:    "The site may be down or blocking automated visitors. Try again later, or open it in your browser.",
+		Status:  http.StatusBadGateway,
+	}
+	ErrCacheMiss = &UserError{
+		err:     "no cache entry found",
+		Message: "Nothing has been saved for this school yet.",
+		Hint:    "Load it once while online and it will be saved for next time.",
+		Status:  http.StatusNotFound,
+	}
+	ErrUpstreamRateLimited = &UserError{
+		err:     "upstream rate limited",
+		Message: "An outside data service is receiving too many requests right now.",
+		Hint:    "Wait a minute and try again.",
+		Status:  http.StatusTooManyRequests,
+	}
+	ErrNoNAEPData = &UserError{
+		err:     "no NAEP data available",
+		Message: "NAEP has no assessment results for this school.",
+		Hint:    "NAEP tests grades 4, 8, and 12 and reports by state and large city, so some schools have no matching results.",
+		Status:  http.StatusNotFound,
+	}
+	ErrAINotConfigured = &UserError{
+		err:     "ANTHROPIC_API_KEY not set",
+		Message: "AI features aren't set up.",
+		Hint:    "Run schoolfinder auth set anthropic (or set the ANTHROPIC_API_KEY environment variable) and restart SchoolFinder.",
+		Status:  http.StatusServiceUnavailable,
+	}
+	ErrRateLimited = &UserError{
+		err:     "client rate limited",
+		Message: "You've made a lot of AI and import requests in a short time.",
+		Hint:    "Please try again later.",
+		Status:  http.StatusTooManyRequests,
+	}
+	ErrSignInRequired = &UserError{
+		err:     "sign-in required",
+		Message: "You need to sign in to do that.",
+		Hint:    "Sign in as an editor or admin, then try again.",
+		Status:  http.StatusUnauthorized,
+	}
+	ErrForbidden = &UserError{
+		err:     "role not permitted",
+		Message: "Your account can't do that.",
+		Hint:    "Ask an admin for an account with more access.",
+		Status:  http.StatusForbidden,
+	}
+	ErrCSRFMismatch = &UserError{
+		err:     "missing or invalid CSRF token",
+		Message: "This form has expired or came from another site.",
+		Hint:    "Reload the page and try again.",
+		Status:  http.StatusForbidden,
+	}
+	ErrServerUnreachable = &UserError{
+		err:     "remote server unreachable",
+		Message: "The School Finder server couldn't be reached.",
+		Hint:    "Check the --server URL and your connection, then try again.",
+		Status:  http.StatusBadGateway,
+	}
+	ErrNeedsLocalDB = &UserError{
+		err:     "command needs a local database",
+		Message: "This command only works with a local database.",
+		Hint:    "Run it without --server, or on the server itself.",
+		Status:  http.StatusNotImplemented,
+	}
+	ErrNotReadQuery = &UserError{
+		err:     "not a read-only query",
+		Message: "Only a single SELECT query on School Finder's tables can run here.",
+		Hint:    "Queries can read tables and views, but not change them or read files.",
+		Status:  http.StatusBadRequest,
+	}
+	ErrNoMatchingSchools = &UserError{
+		err:     "no schools match",
+		Message: "No schools match those filters.",
+		Hint:    "Check the state code, or try another level or every state.",
+		Status:  http.StatusNotFound,
+	}
+	ErrNotCharter = &UserError{
+		err:     "school is not a charter school",
+		Message: "This school isn't a charter school.",
+		Hint:    "Matched comparisons start from a charter school and find traditional public schools like it.",
+		Status:  http.StatusUnprocessableEntity,
+	}
+	ErrSuggestionQueueFull = &UserError{
+		err:     "correction suggestion queue full",
+		Message: "Too many suggested corrections are waiting for review.",
+		Hint:    "Please try again after the current suggestions have been reviewed.",
+		Status:  http.StatusServiceUnavailable,
+	}
+)
+
+// upstreamStatusError describes a non-OK HTTP status from an outside service,
+// wrapping ErrUpstreamRateLimited for 429s and unavailable otherwise
+func upstreamStatusError(unavailable error, status int) error {
+	if status == http.StatusTooManyRequests {
+		return fmt.Errorf("%w: HTTP %d", ErrUpstreamRateLimited, status)
+	}
+	return fmt.Errorf("%w: HTTP %d", unavailable, status)
+}
+
+// userError finds the user-facing error in err's chain, treating rate limits from
+// the Claude API as ErrUpstreamRateLimited. It returns nil for other errors.
+func userError(err error) *UserError {
+	var ue *UserError
+	if errors.As(err, &ue) {
+		return ue
+	}
+
+	var apiErr *anthropicsdk.Error
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusTooManyRequests {
+		return ErrUpstreamRateLimited
+	}
+	var providerErr *fantasy.ProviderError
+	if errors.As(err, &providerErr) && providerErr.StatusCode == http.StatusTooManyRequests {
+		return ErrUpstreamRateLimited
+	}
+	return nil
+}
+
+// isUserError reports whether err is target, either in err's chain or as
+// relayed by a remote server, which sends only the message
+func isUserError(err error, target *UserError) bool {
+	if errors.Is(err, target) {
+		return true
+	}
+	var ue *UserError
+	return errors.As(err, &ue) && ue.Message == target.Message
+}
+
+// isUpstreamError reports whether err came from an outside service failing
+// or turning the request away: a school website, the Claude API, or a remote
+// server. These are worth retrying later, unlike bad input or configuration.
+func isUpstreamError(err error) bool {
+	if errors.Is(err, ErrUpstreamRateLimited) || errors.Is(err, ErrWebsiteUnavailable) || errors.Is(err, ErrServerUnreachable) {
+		return true
+	}
+	var apiErr *anthropicsdk.Error
+	if errors.As(err, &apiErr) {
+		return true
+	}
+	var providerErr *fantasy.ProviderError
+	if errors.As(err, &providerErr) {
+		return true
+	}
+	// A remote server relays its own upstream failures as 429s and 5xxs
+	var ue *UserError
+	if errors.As(err, &ue) && (ue.Status == http.StatusTooManyRequests || ue.Status >= http.StatusInternalServerError) {
+		return true
+	}
+	return isTransient(err)
+}
+
+// describeError returns the user-facing error for err, or a generic one that says
+// what failed without exposing the underlying error text
+func describeError(err error, failed string) *UserError {
+	if ue := userError(err); ue != nil {
+		return ue
+	}
+	return &UserError{
+		err:     err.Error(),
+		Message: failed + ".",
+		Hint:    "Please try again. If it keeps happening, check the server log for details.",
+		Status:  http.StatusInternalServerError,
+	}
+}
+
+// errorText formats err for the TUI and CLI: the message and hint for known
+// errors, and the error itself otherwise
+func errorText(err error) string {
+	if ue := userError(err); ue != nil {
+		return ue.Text()
+	}
+	return err.Error()
+}
+
+// describeCLIError gives CLI commands the user-facing message and hint for err
+func describeCLIError(err error) (message, hint string, ok bool) {
+	if ue := userError(err); ue != nil {
+		return ue.Message, ue.Hint, true
+	}
+	return "", "", false
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"charm.land/fantasy"
+	anthropicsdk "github.com/anthropics/anthropic-sdk-go"
+)
+
+func TestUserError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want *UserError
+	}{
+		{"sentinel", ErrNoWebsite, ErrNoWebsite},
+		{"wrapped", fmt.Errorf("AI scraping failed: %w", upstreamStatusError(ErrWebsiteUnavailable, 403)), ErrWebsiteUnavailable},
+		{"website rate limited", upstreamStatusError(ErrWebsiteUnavailable, 429), ErrUpstreamRateLimited},
+		{"Claude API rate limited", fmt.Errorf("summary failed: %w", &anthropicsdk.Error{StatusCode: 429}), ErrUpstreamRateLimited},
+		{"agent provider rate limited", &fantasy.RetryError{Errors: []error{&fantasy.ProviderError{StatusCode: 429}}}, ErrUpstreamRateLimited},
+		{"Claude API server error", &anthropicsdk.Error{StatusCode: 500}, nil},
+		{"unknown", errors.New("Binder Error: column \"foo\" not found"), nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := userError(tt.err); got != tt.want {
+				t.Errorf("userError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDescribeError(t *testing.T) {
+	err := fmt.Errorf("%w for state CA, grades [4], years [2022]", ErrNoNAEPData)
+	if got := describeError(err, "NAEP data couldn't be loaded"); got != ErrNoNAEPData {
+		t.Errorf("describeError kept %v, want ErrNoNAEPData", got)
+	}
+
+	// Unknown errors get a generic message instead of the raw error text
+	got := describeError(errors.New("Binder Error: column \"foo\" not found"), "Your question couldn't be answered")
+	if got.Message != "Your question couldn't be answered." {
+		t.Errorf("Message = %q", got.Message)
+	}
+	if strings.Contains(got.Message+got.Hint, "Binder") {
+		t.Errorf("generic error exposes the raw error: %q %q", got.Message, got.Hint)
+	}
+	if got.Status != http.StatusInternalServerError {
+		t.Errorf("Status = %d, want 500", got.Status)
+	}
+}
+
+func TestScrapeExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"no website", ErrNoWebsite, 2},
+		{"no website from a server", &UserError{err: "POST /api/schools/1/ai: HTTP 422", Message: ErrNoWebsite.Message, Status: 422}, 2},
+		{"website down", fmt.Errorf("%w: HTTP 503", ErrWebsiteUnavailable), 3},
+		{"website rate limited", upstreamStatusError(ErrWebsiteUnavailable, 429), 3},
+		{"Claude API error", fmt.Errorf("Claude API error: %w", &anthropicsdk.Error{StatusCode: 529}), 3},
+		{"server unreachable", fmt.Errorf("%w: connection refused", ErrServerUnreachable), 3},
+		{"server error", &UserError{err: "HTTP 500", Message: "AI extraction failed.", Status: 500}, 3},
+		{"AI not configured", ErrAINotConfigured, 1},
+		{"AI not configured on the server", &UserError{err: "HTTP 503", Message: ErrAINotConfigured.Message, Status: 503}, 1},
+		{"sign-in required", &UserError{err: "HTTP 401", Message: "Sign in.", Status: 401}, 1},
+		{"unknown school", errors.New("no school found with ID 1"), 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := scrapeExitCode(tt.err); got != tt.want {
+				t.Errorf("scrapeExitCode(%v) = %d, want %d", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestErrorText(t *testing.T) {
+	err := fmt.Errorf("AI scraping failed: %w", ErrNoWebsite)
+	if got, want := errorText(err), ErrNoWebsite.Message+" "+ErrNoWebsite.Hint; got != want {
+		t.Errorf("errorText = %q, want %q", got, want)
+	}
+	if got := errorText(errors.New("filename cannot be empty")); got != "filename cannot be empty" {
+		t.Errorf("errorText = %q", got)
+	}
+}
+
+func TestCacheMissError(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	if _, _, err := db.LoadParentSummaryCache("360000100001", time.Hour); !errors.Is(err, ErrCacheMiss) {
+		t.Errorf("missing parent summary: err = %v, want ErrCacheMiss", err)
+	}
+	if _, _, _, _, _, _, err := db.LoadNAEPCache("360000100001", time.Hour); !errors.Is(err, ErrCacheMiss) {
+		t.Errorf("missing NAEP cache: err = %v, want ErrCacheMiss", err)
+	}
+}
+
+func TestRenderUserError(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	router := NewRouter(ServerConfig{DB: db})
+
+	tests := []struct {
+		name       string
+		htmx       bool
+		wantStatus int
+	}{
+		{"HTMX request", true, http.StatusOK},
+		{"plain request", false, http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/schools/360000100001/summary", nil)
+			token := strings.Repeat("ab", 32)
+			req.AddCookie(&http.Cookie{Name: csrfCookie, Value: token})
+			req.Header.Set(csrfHeader, token)
+			if tt.htmx {
+				req.Header.Set("HX-Request", "true")
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			body := rec.Body.String()
+			if !strings.Contains(body, template.HTMLEscapeString(ErrAINotConfigured.Message)) || !strings.Contains(body, "ANTHROPIC_API_KEY") {
+				t.Errorf("body is missing the message and hint: %s", body)
+			}
+			if !strings.Contains(body, `role="alert"`) {
+				t.Errorf("error is not announced: %s", body)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// etagCacheControl makes browsers and proxies keep responses but check back
+// before reusing them, which costs a 304 when nothing changed
+const etagCacheControl = "no-cache"
+
+// etagEntry is the ETag last sent for a URL and the data version it was sent at
+type etagEntry struct {
+	version uint64
+	etag    string
+}
+
+// etagWriter holds a response back so its ETag can be computed from the body
+type etagWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *etagWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *etagWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.body.Write(b)
+}
+
+// etagMatches reports whether an If-None-Match header lists etag
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// conditionalGET adds ETags and Cache-Control to successful GET responses and
+// answers matching If-None-Match requests with 304 Not Modified. ETags combine
+// the database's data version with a hash of the body. While the data version
+// hasn't changed, a request repeating the last ETag sent for its URL gets a
+// 304 without running the handler at all; otherwise the response is rendered
+// and compared.
+func conditionalGET(db *DB, known *lruCache[etagEntry]) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// Pages show different actions to each role
+			key := requestRole(r).String() + " " + r.URL.RequestURI()
+			version := db.DataVersion()
+			ifNoneMatch := r.Header.Get("If-None-Match")
+			if entry, ok := known.Get(key); ok && entry.version == version && ifNoneMatch != "" && etagMatches(ifNoneMatch, entry.etag) {
+				w.Header().Set("ETag", entry.etag)
+				w.Header().Set("Cache-Control", etagCacheControl)
+				w.Header().Set("Vary", "Authorization")
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+
+			ew := &etagWriter{ResponseWriter: w}
+			next.ServeHTTP(ew, r)
+			if ew.status == 0 {
+				ew.status = http.StatusOK
+			}
+			if ew.status != http.StatusOK {
+				w.WriteHeader(ew.status)
+				_, _ = w.Write(ew.body.Bytes())
+				return
+			}
+
+			sum := sha256.Sum256(ew.body.Bytes())
+			etag := fmt.Sprintf(`"%d-%s"`, version, hex.EncodeToString(sum[:8]))
+			// A write during rendering may have changed what the body shows
+			if db.DataVersion() == version {
+				known.Put(key, etagEntry{version: version, etag: etag})
+			}
+			w.Header().Set("ETag", etag)
+			w.Header().Set("Vary", "Authorization")
+			if w.Header().Get("Cache-Control") == "" {
+				w.Header().Set("Cache-Control", etagCacheControl)
+			}
+			if ifNoneMatch != "" && etagMatches(ifNoneMatch, etag) {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write(ew.body.Bytes())
+		})
+	}
+}
+
+// bumpDataVersionOnWrite moves the data version forward after every request
+// that can change data, so ETags sent before it are rendered again
+func bumpDataVersionOnWrite(db *DB) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r)
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+			default:
+				db.BumpDataVersion()
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConditionalGET(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	renders := 0
+	body := "school data"
+	handler := conditionalGET(db, newLRUCache[etagEntry](10, time.Minute))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		renders++
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+	get := func(path, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	first := get("/schools/1", "")
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || first.Body.String() != body || etag == "" || first.Header().Get("Cache-Control") != etagCacheControl {
+		t.Fatalf("first response = %d %q, ETag %q", first.Code, first.Body.String(), etag)
+	}
+
+	// Unchanged data answers from the remembered ETag without rendering
+	rec := get("/schools/1", `W/"other", `+etag)
+	if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 || renders != 1 {
+		t.Errorf("repeat = %d after %d renders", rec.Code, renders)
+	}
+
+	// After a write the response is rendered again; the same body gets a new ETag
+	db.BumpDataVersion()
+	rec = get("/schools/1", etag)
+	if rec.Code != http.StatusOK || renders != 2 || rec.Header().Get("ETag") == etag {
+		t.Errorf("after a write = %d, ETag %q after %d renders", rec.Code, rec.Header().Get("ETag"), renders)
+	}
+
+	// Errors aren't tagged
+	if rec := get("/missing", ""); rec.Code != http.StatusNotFound || rec.Header().Get("ETag") != "" {
+		t.Errorf("missing = %d, ETag %q", rec.Code, rec.Header().Get("ETag"))
+	}
+}
+
+func TestETagRoutes(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	router := NewRouter(ServerConfig{DB: db})
+
+	get := func(path, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("If-None-Match", ifNoneMatch)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+	for _, path := range []string{"/api/schools/360000100001", "/api/search?q=lincoln", "/schools/360000100001", "/stats"} {
+		rec := get(path, "")
+		etag := rec.Header().Get("ETag")
+		if rec.Code != http.StatusOK || etag == "" {
+			t.Errorf("%s = %d, ETag %q", path, rec.Code, etag)
+			continue
+		}
+		if rec := get(path, etag); rec.Code != http.StatusNotModified {
+			t.Errorf("%s with its ETag = %d", path, rec.Code)
+		}
+	}
+
+	// Posting a write makes earlier ETags stale
+	etag := get("/stats", "").Header().Get("ETag")
+	req := httptest.NewRequest("POST", "/duplicates/dismiss", strings.NewReader("a=360000100001&b=360000100002"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	if rec := get("/stats", etag); rec.Code != http.StatusOK {
+		t.Errorf("/stats after a write = %d", rec.Code)
+	}
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Confidence levels of an inferred feeder, from the extraction prompt
+const (
+	confidenceHigh   = "high"
+	confidenceMedium = "medium"
+	confidenceLow    = "low"
+)
+
+// confidenceRank orders confidence levels, highest first
+var confidenceRank = map[string]int{confidenceHigh: 0, confidenceMedium: 1, confidenceLow: 2}
+
+// InferredFeeder is a school that another school's or its district's website
+// says it feeds into. It's inferred with AI, not official boundary data.
+type InferredFeeder struct {
+	FromNCESSCH string `json:"from_ncessch"`
+	ToName      string `json:"to_name"`              // As the website names it
+	ToNCESSCH   string `json:"to_ncessch,omitempty"` // Empty when no directory school matches the name
+	Confidence  string `json:"confidence"`           // high, medium, or low
+	Evidence    string `json:"evidence,omitempty"`
+	SourceURL   string `json:"source_url,omitempty"`
+}
+
+// FeederInference is what was inferred about the schools one school feeds into
+type FeederInference struct {
+	NCESSCH    string           `json:"ncessch"`
+	SchoolName string           `json:"school_name"`
+	InferredAt time.Time        `json:"inferred_at"`
+	Feeders    []InferredFeeder `json:"feeders"`
+
+	// Markdown content from AI extraction
+	MarkdownContent string `json:"markdown_content"`
+
+	Stale bool `json:"-"` // Older than the AI cache TTL
+}
+
+// feedsIntoPattern matches the "Feeds Into" heading the inference prompt asks for
+var feedsIntoPattern = regexp.MustCompile(`(?im)^#{1,4}\s*\**\s*feeds into\b.*$`)
+
+// parseInferredFeeders reads the "- School | Confidence | Evidence | Source URL"
+// lines under the "Feeds Into" heading. Lines with an unknown confidence are
+// taken as low confidence.
+func parseInferredFeeders(markdown string) []InferredFeeder {
+	heading := feedsIntoPattern.FindStringIndex(markdown)
+	if heading == nil {
+		return nil
+	}
+	body := markdown[heading[1]:]
+	if next := strings.Index(body, "\n#"); next >= 0 {
+		body = body[:next]
+	}
+
+	var feeders []InferredFeeder
+	for _, line := range strings.Split(body, "\n") {
+		values, ok := markdownRowFields(line, 4)
+		if !ok || values[0] == "" || strings.EqualFold(values[0], "school") {
+			continue
+		}
+		confidence := strings.ToLower(values[1])
+		if _, known := confidenceRank[confidence]; !known {
+			confidence = confidenceLow
+		}
+		feeders = append(feeders, InferredFeeder{ToName: values[0], Confidence: confidence, Evidence: values[2], SourceURL: values[3]})
+	}
+	return feeders
+}
+
+// schoolNameKey reduces a school name to the words that identify it, so
+// "Jefferson Middle School" and "Jefferson Middle" match
+func schoolNameKey(name string) string {
+	var words []string
+	for _, word := range strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9')
+	}) {
+		if word != "school" && word != "the" {
+			words = append(words, word)
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// matchSchoolName finds the directory school a website's name for a school
+// refers to: the one in the same state whose name matches, preferring the
+// same district. It returns "" when no school, or more than one, matches.
+func (d *DB) matchSchoolName(from *School, name string) (string, error) {
+	key := schoolNameKey(name)
+	if key == "" {
+		return "", nil
+	}
+	rows, err := d.conn.Query(fmt.Sprintf(`
+		SELECT d.NCESSCH, d.SCH_NAME, COALESCE(d.LEAID, '')
+		FROM directory d
+		WHERE d.ST = $1 AND d.NCESSCH <> $2 AND strpos(lower(d.SCH_NAME), $3) > 0 AND %s
+	`, notMergedCondition), from.State, from.NCESSCH, strings.Fields(key)[0])
+	if err != nil {
+		return "", fmt.Errorf("failed to match school name: %w", err)
+	}
+	defer rows.Close()
+
+	var inDistrict, inState []string
+	for rows.Next() {
+		var ncessch, schoolName, leaid string
+		if err := rows.Scan(&ncessch, &schoolName, &leaid); err != nil {
+			return "", fmt.Errorf("failed to scan school: %w", err)
+		}
+		if schoolNameKey(schoolName) != key {
+			continue
+		}
+		inState = append(inState, ncessch)
+		if from.DistrictID.Valid && leaid == from.DistrictID.String {
+			inDistrict = append(inDistrict, ncessch)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+	switch {
+	case len(inDistrict) == 1:
+		return inDistrict[0], nil
+	case len(inDistrict) == 0 && len(inState) == 1:
+		return inState[0], nil
+	default:
+		return "", nil
+	}
+}
+
+// ExtractFeeders uses Claude with web search to find which schools a school
+// feeds into, from its own and its district's websites
+func (s *AIScraperService) ExtractFeeders(ctx context.Context, school *School) (*FeederInference, error) {
+	content := fmt.Sprintf(`Find which school(s) students from %s, a public school in %s, %s (district: %s, NCES ID %s, grades %s-%s), go on to after its highest grade.
+
+Use web search to find the school's and the district's websites, and look for feeder patterns, articulation or "feeds into" charts, school assignment or attendance zone pages, and enrollment guides.
+
+Write your findings in markdown, in exactly this format:
+
+## Feeds Into
+- School name | Confidence | Evidence | Source URL
+(one line per school it feeds into)
+
+Confidence is one of:
+- high: an official district or school page states the feeder pattern directly
+- medium: a page states it but is unofficial or may be out of date, or it's strongly implied (e.g. shared transition events)
+- low: only suggested by location or names
+
+Evidence is a short quote or description of what the page says. Use the school's full name as the district writes it.
+If nothing says where students go next, write "## Feeds Into" followed by "- not found".`,
+		school.Name, school.City, school.State, school.District, school.NCESSCH, school.GradeLow.String, school.GradeHigh.String)
+
+	responseText, err := s.webSearch(ctx, content, "school_name", school.Name, "ncessch", school.NCESSCH)
+	if err != nil {
+		return nil, err
+	}
+
+	if logger != nil {
+		logger.Info("Successfully inferred feeder schools with Claude", "school_name", school.Name, "ncessch", school.NCESSCH, slog.Int("response_length", len(responseText)))
+	}
+
+	inference := &FeederInference{
+		NCESSCH:         school.NCESSCH,
+		SchoolName:      school.Name,
+		InferredAt:      time.Now(),
+		MarkdownContent: responseText,
+	}
+	for _, feeder := range parseInferredFeeders(responseText) {
+		feeder.FromNCESSCH = school.NCESSCH
+		if feeder.ToNCESSCH, err = s.db.matchSchoolName(school, feeder.ToName); err != nil {
+			return nil, err
+		}
+		inference.Feeders = append(inference.Feeders, feeder)
+	}
+	return inference, nil
+}
+
+// ScrapeFeeders returns a school's cached feeder inference, inferring again
+// when there is none or it's older than the AI cache TTL
+func (s *AIScraperService) ScrapeFeeders(ctx context.Context, school *School) (*FeederInference, error) {
+	s.db.RecordUsage(usageScrape)
+
+	if cached, err := s.db.LoadFeederInference(school.NCESSCH); err == nil && time.Since(cached.InferredAt) <= s.cacheTTL {
+		if logger != nil {
+			logger.Info("Returning cached feeder inference from database", "school_name", school.Name, "ncessch", school.NCESSCH)
+		}
+		return cached, nil
+	}
+
+	inference, err := s.ExtractFeeders(ctx, school)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to infer feeder schools", "error", err, "school_name", school.Name, "ncessch", school.NCESSCH)
+		}
+		return nil, err
+	}
+
+	// Don't fail if cache save fails, just log
+	if err := s.db.SaveFeederInference(inference); err != nil && logger != nil {
+		logger.Warn("Failed to save feeder inference", "error", err, "ncessch", school.NCESSCH)
+	}
+	return inference, nil
+}
+
+// SaveFeederInference saves a school's inferred feeders, replacing any earlier inference
+func (d *DB) SaveFeederInference(inference *FeederInference) error {
+	tx, err := d.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	_, err = tx.Exec(`
+		INSERT INTO feeder_inferences (ncessch, school_name, markdown_content, inferred_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (ncessch) DO UPDATE SET
+			school_name = EXCLUDED.school_name,
+			markdown_content = EXCLUDED.markdown_content,
+			inferred_at = EXCLUDED.inferred_at
+	`, inference.NCESSCH, inference.SchoolName, inference.MarkdownContent, inference.InferredAt)
+	if err != nil {
+		return fmt.Errorf("failed to save feeder inference: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM inferred_feeders WHERE from_ncessch = $1`, inference.NCESSCH); err != nil {
+		return fmt.Errorf("failed to clear inferred feeders: %w", err)
+	}
+	for _, f := range inference.Feeders {
+		_, err := tx.Exec(`
+			INSERT OR REPLACE INTO inferred_feeders (from_ncessch, to_name, to_ncessch, confidence, evidence, source_url, inferred_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+		`, inference.NCESSCH, f.ToName, sql.NullString{String: f.ToNCESSCH, Valid: f.ToNCESSCH != ""}, f.Confidence, f.Evidence, f.SourceURL, inference.InferredAt)
+		if err != nil {
+			return fmt.Errorf("failed to save inferred feeder: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		if logger != nil {
+			logger.Error("Failed to save feeder inference", "error", err, "ncessch", inference.NCESSCH)
+		}
+		return fmt.Errorf("failed to commit feeder inference: %w", err)
+	}
+	return nil
+}
+
+// LoadFeederInference loads a school's inferred feeders, returning
+// sql.ErrNoRows when it hasn't been inferred. Inferences older than the AI
+// cache TTL are returned with Stale set.
+func (d *DB) LoadFeederInference(ncessch string) (*FeederInference, error) {
+	inference := &FeederInference{NCESSCH: ncessch}
+	err := d.conn.QueryRow(`
+		SELECT school_name, markdown_content, inferred_at FROM feeder_inferences WHERE ncessch = $1
+	`, ncessch).Scan(&inference.SchoolName, &inference.MarkdownContent, &inference.InferredAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to load feeder inference: %w", err)
+	}
+
+	if inference.Feeders, err = d.inferredFeeders(ncessch); err != nil {
+		return nil, err
+	}
+	inference.Stale = time.Since(inference.InferredAt) > cacheTTLFromEnv("AI_CACHE_TTL", defaultAICacheTTL)
+	return inference, nil
+}
+
+// inferredFeeders lists the schools ncessch was inferred to feed into, most
+// confident first
+func (d *DB) inferredFeeders(ncessch string) ([]InferredFeeder, error) {
+	rows, err := d.conn.Query(`
+		SELECT to_name, COALESCE(to_ncessch, ''), confidence, COALESCE(evidence, ''), COALESCE(source_url, '')
+		FROM inferred_feeders
+		WHERE from_ncessch = $1
+		ORDER BY CASE confidence WHEN 'high' THEN 0 WHEN 'medium' THEN 1 ELSE 2 END, to_name
+	`, ncessch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load inferred feeders: %w", err)
+	}
+	defer rows.Close()
+
+	var feeders []InferredFeeder
+	for rows.Next() {
+		f := InferredFeeder{FromNCESSCH: ncessch}
+		if err := rows.Scan(&f.ToName, &f.ToNCESSCH, &f.Confidence, &f.Evidence, &f.SourceURL); err != nil {
+			return nil, fmt.Errorf("failed to scan inferred feeder: %w", err)
+		}
+		feeders = append(feeders, f)
+	}
+	return feeders, rows.Err()
+}
+
+// inferredFeederTarget returns the most confident inferred school that
+// ncessch feeds into and that serves grade, or nil if there's none
+func (d *DB) inferredFeederTarget(ncessch, grade string) (*School, *InferredFeeder, error) {
+	feeders, err := d.inferredFeeders(ncessch)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i, f := range feeders {
+		if f.ToNCESSCH == "" {
+			continue
+		}
+		school, err := d.GetSchoolByID(f.ToNCESSCH)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if servesGrade(school.GradeLow.String, school.GradeHigh.String, grade) {
+			return school, &feeders[i], nil
+		}
+	}
+	return nil, nil, nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testFeederInference = `## Feeds Into
+| School | Confidence | Evidence | Source URL |
+|--------|------------|----------|------------|
+| **Washington High School** | High | "Lincoln students continue to Washington High" | [https://www.sfusd.edu/feeders](https://www.sfusd.edu/feeders) |
+- Jefferson Middle | Medium | Shared 5th grade transition night | not published
+- Roosevelt Academy | maybe | Nearby | not published
+
+## Notes
+- The district redraws feeder patterns every few years.
+`
+
+func TestParseInferredFeeders(t *testing.T) {
+	feeders := parseInferredFeeders(testFeederInference)
+	if len(feeders) != 3 {
+		t.Fatalf("feeders = %+v", feeders)
+	}
+	if feeders[0] != (InferredFeeder{ToName: "Washington High School", Confidence: confidenceHigh, Evidence: `"Lincoln students continue to Washington High"`, SourceURL: "https://www.sfusd.edu/feeders"}) {
+		t.Errorf("first feeder = %+v", feeders[0])
+	}
+	if feeders[1].Confidence != confidenceMedium || feeders[1].SourceURL != "" {
+		t.Errorf("second feeder = %+v", feeders[1])
+	}
+	if feeders[2].Confidence != confidenceLow {
+		t.Errorf("unknown confidence = %q, want low", feeders[2].Confidence)
+	}
+
+	if feeders := parseInferredFeeders("## Feeds Into\n- not found"); len(feeders) != 0 {
+		t.Errorf("feeders when none were found = %+v", feeders)
+	}
+}
+
+func TestSchoolNameKey(t *testing.T) {
+	if a, b := schoolNameKey("Jefferson Middle School"), schoolNameKey("The Jefferson Middle"); a != b || a != "jefferson middle" {
+		t.Errorf("schoolNameKey() = %q, %q", a, b)
+	}
+}
+
+func TestInferFeeders(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	// Only Lincoln Elementary's boundary is loaded
+	lincoln := `{"type": "FeatureCollection", "features": [{"type": "Feature", "properties": {"ncessch": "360000100001", "level": "1"},
+		"geometry": {"type": "Polygon", "coordinates": [[[-122.45, 37.75], [-122.40, 37.75], [-122.40, 37.80], [-122.45, 37.80], [-122.45, 37.75]]]}}]}`
+	if err := os.WriteFile(filepath.Join(db.dataDir, "SABS_1516_CA.geojson"), []byte(lincoln), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SyncBoundaries(db); err != nil {
+		t.Fatal(err)
+	}
+
+	messages := &fakeMessages{text: testFeederInference}
+	ai, err := NewAIScraperService("test-key", db, WithMessageCreator(messages))
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := NewRouter(ServerConfig{DB: db, AIScraper: ai})
+
+	get := func() string {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest("GET", "/pipeline?address=37.78,-122.42", nil))
+		return rec.Body.String()
+	}
+	if body := get(); !strings.Contains(body, "Infer from Lincoln Elementary") || strings.Contains(body, `href="/schools/360000100002"`) {
+		t.Errorf("pipeline before inference is missing the infer button or has a high school")
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("POST", "/schools/360000100001/feeders", strings.NewReader("address=37.78,-122.42")))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `href="/schools/360000100002"`) || !strings.Contains(rec.Body.String(), "Inferred") {
+		t.Fatalf("inference: status %d, body %s", rec.Code, rec.Body.String())
+	}
+
+	// Names are matched to directory schools in the same state
+	inference, err := db.LoadFeederInference("360000100001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inference.Feeders) != 3 || inference.Feeders[0].ToNCESSCH != "360000100002" || inference.Feeders[1].ToNCESSCH != "" {
+		t.Errorf("LoadFeederInference() = %+v", inference.Feeders)
+	}
+	if _, err := ai.ScrapeFeeders(context.Background(), &School{NCESSCH: "360000100001"}); err != nil || messages.requests != 1 {
+		t.Errorf("cached inference was run again: %d requests, %v", messages.requests, err)
+	}
+
+	// The high school stage is filled in and labeled as inferred
+	pipeline, err := BuildFeederPipeline(db, Home{Lat: 37.78, Lon: -122.42})
+	if err != nil {
+		t.Fatal(err)
+	}
+	high := pipeline.Stages[2]
+	if high.School == nil || high.School.NCESSCH != "360000100002" || high.Source != "inferred" || high.Confidence != confidenceHigh || high.FeedsFrom != "360000100001" {
+		t.Errorf("high school stage = %+v", high)
+	}
+	if middle := pipeline.Stages[1]; middle.School != nil {
+		t.Errorf("middle school stage = %+v, want none", middle)
+	}
+	if body := get(); !strings.Contains(body, `href="/schools/360000100002"`) || !strings.Contains(body, "high confidence") {
+		t.Errorf("pipeline after inference is missing the inferred high school")
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Recorded fixtures are golden JSON files of API responses keyed by request,
+// one file per service. A fixtureSet is an http.RoundTripper serving them, so
+// NAEP and Anthropic clients given an http.Client using it work without
+// network access or keys. Recording sends unrecorded requests to the real
+// service and saves its responses.
+
+// fixtureResponse is a recorded response
+type fixtureResponse struct {
+	Status int             `json:"status"`
+	Body   json.RawMessage `json:"body"`
+}
+
+// fixtureSet serves recorded responses from a golden JSON file
+type fixtureSet struct {
+	path     string
+	key      func(*http.Request) (string, error)
+	upstream http.RoundTripper // Records unrecorded requests when set
+
+	mu        sync.Mutex
+	responses map[string]fixtureResponse
+	changed   bool
+}
+
+// loadFixtures loads the recorded responses in path, keyed by key. A missing
+// file has no responses.
+func loadFixtures(path string, key func(*http.Request) (string, error)) (*fixtureSet, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		data = []byte("{}")
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read fixtures: %w", err)
+	}
+	return parseFixtures(path, data, key)
+}
+
+// parseFixtures parses the recorded responses in data, read from path
+func parseFixtures(path string, data []byte, key func(*http.Request) (string, error)) (*fixtureSet, error) {
+	f := &fixtureSet{path: path, key: key, responses: make(map[string]fixtureResponse)}
+	if err := json.Unmarshal(data, &f.responses); err != nil {
+		return nil, fmt.Errorf("failed to parse fixtures %s: %w", path, err)
+	}
+	return f, nil
+}
+
+// record sends unrecorded requests to the real service with upstream and
+// saves the responses
+func (f *fixtureSet) record(upstream http.RoundTripper) {
+	f.upstream = upstream
+}
+
+// RoundTrip serves the recorded response to req
+func (f *fixtureSet) RoundTrip(req *http.Request) (*http.Response, error) {
+	key, err := f.key(req)
+	if err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	recorded, ok := f.responses[key]
+	f.mu.Unlock()
+	if !ok {
+		if f.upstream == nil {
+			return nil, fmt.Errorf("no recorded response for %q in %s", key, f.path)
+		}
+		if recorded, err = f.fetch(req); err != nil {
+			return nil, err
+		}
+		f.mu.Lock()
+		f.responses[key] = recorded
+		f.changed = true
+		f.mu.Unlock()
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", recorded.Status, http.StatusText(recorded.Status)),
+		StatusCode:    recorded.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader(recorded.Body)),
+		ContentLength: int64(len(recorded.Body)),
+		Request:       req,
+	}, nil
+}
+
+// fetch gets the real service's response to req for recording
+func (f *fixtureSet) fetch(req *http.Request) (fixtureResponse, error) {
+	resp, err := f.upstream.RoundTrip(req)
+	if err != nil {
+		return fixtureResponse{}, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fixtureResponse{}, fmt.Errorf("failed to read response: %w", err)
+	}
+	if !json.Valid(body) {
+		// Keep error pages as JSON strings
+		body, _ = json.Marshal(string(body))
+	}
+	return fixtureResponse{Status: resp.StatusCode, Body: body}, nil
+}
+
+// save writes recorded responses back to the golden file, if any were added
+func (f *fixtureSet) save() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.changed {
+		return nil
+	}
+
+	keys := make([]string, 0, len(f.responses))
+	for k := range f.responses {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	// Sorted and indented so re-recording makes readable diffs
+	var b bytes.Buffer
+	b.WriteString("{\n")
+	for i, k := range keys {
+		var body bytes.Buffer
+		if err := json.Indent(&body, f.responses[k].Body, "    ", "  "); err != nil {
+			return fmt.Errorf("failed to format fixture %q: %w", k, err)
+		}
+		fmt.Fprintf(&b, "  %s: {\n    \"status\": %d,\n    \"body\": %s\n  }", strconv.Quote(k), f.responses[k].Status, body.String())
+		if i < len(keys)-1 {
+			b.WriteString(",")
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("}\n")
+
+	if err := os.WriteFile(f.path, b.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to save fixtures: %w", err)
+	}
+	f.changed = false
+	return nil
+}
+
+// naepFixtureKey keys NAEP API requests by what they ask for, e.g.
+// "CA mathematics grade 4 MN:MN 2022,2019,2017"
+func naepFixtureKey(req *http.Request) (string, error) {
+	q := req.URL.Query()
+	return fmt.Sprintf("%s %s grade %s %s %s", q.Get("jurisdiction"), q.Get("subject"), q.Get("grade"), q.Get("stattype"), q.Get("Year")), nil
+}
+
+// anthropicFixturePrefix is how much of a prompt keys its response: enough to
+// name the school, not so much that every wording change needs re-recording
+const anthropicFixturePrefix = 300
+
+// anthropicFixtureKey keys Claude requests by the start of the prompt, with
+// whitespace collapsed, and for later turns of a conversation, e.g. after a
+// tool call, the turn
+func anthropicFixtureKey(req *http.Request) (string, error) {
+	if req.Body == nil {
+		return "", fmt.Errorf("no request body")
+	}
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read request: %w", err)
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	var params struct {
+		Messages []struct {
+			Content []struct {
+				Text string `json:"text"`
+			} `json:"content"`
+		} `json:"messages"`
+	}
+	if err := json.Unmarshal(body, &params); err != nil {
+		return "", fmt.Errorf("failed to parse request: %w", err)
+	}
+	if len(params.Messages) == 0 || len(params.Messages[0].Content) == 0 {
+		return "", fmt.Errorf("request has no prompt")
+	}
+	prompt := strings.Join(strings.Fields(params.Messages[0].Content[0].Text), " ")
+	if r := []rune(prompt); len(r) > anthropicFixturePrefix {
+		prompt = string(r[:anthropicFixturePrefix])
+	}
+	if n := len(params.Messages); n > 1 {
+		prompt += fmt.Sprintf(" [turn %d]", (n+1)/2)
+	}
+	return prompt, nil
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/anthropics/anthropic-sdk-go/option"
+)
+
+// go test -run Fixtures -record-fixtures ./... re-records responses missing
+// from testdata/fixtures from the real APIs; set ANTHROPIC_API_KEY first
+var recordFixtures = flag.Bool("record-fixtures", false, "record missing fixture responses from the real NAEP and Anthropic APIs")
+
+// testFixtures loads a golden fixture file from testdata/fixtures, recording
+// missing responses with -record-fixtures
+func testFixtures(t *testing.T, name string, key func(*http.Request) (string, error)) *fixtureSet {
+	t.Helper()
+	f, err := loadFixtures(filepath.Join("testdata", "fixtures", name), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *recordFixtures {
+		f.record(http.DefaultTransport)
+		t.Cleanup(func() {
+			if err := f.save(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+	return f
+}
+
+// fixtureClients returns NAEP and AI clients that replay recorded responses
+func fixtureClients(t *testing.T, db *DB) (*NAEPClient, *AIScraperService) {
+	t.Helper()
+	naep := NewNAEPClient(db, WithNAEPHTTPClient(&http.Client{Transport: testFixtures(t, "naep.json", naepFixtureKey)}))
+
+	apiKey := "test-key"
+	if *recordFixtures {
+		apiKey = os.Getenv("ANTHROPIC_API_KEY")
+	}
+	ai, err := NewAIScraperService(apiKey, db, WithAnthropicOptions(
+		option.WithHTTPClient(&http.Client{Transport: testFixtures(t, "anthropic.json", anthropicFixtureKey)}),
+		option.WithMaxRetries(0),
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return naep, ai
+}
+
+func TestEnrichmentFixtures(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	naep, ai := fixtureClients(t, db)
+	ctx := context.Background()
+
+	school, err := db.GetSchoolByID("360000100001")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	naepData, err := naep.FetchNAEPData(ctx, school)
+	if err != nil {
+		t.Fatalf("FetchNAEPData: %v", err)
+	}
+	var math2022 *NAEPScore
+	for i, s := range naepData.StateScores {
+		if s.Subject == "mathematics" && s.Grade == 4 && s.Year == 2022 {
+			math2022 = &naepData.StateScores[i]
+		}
+	}
+	if math2022 == nil || math2022.MeanScore != 230.23 || math2022.AtProficient != 30.51 {
+		t.Errorf("CA grade 4 mathematics 2022 = %+v", math2022)
+	}
+	if len(naepData.NationalScores) == 0 {
+		t.Error("no national scores")
+	}
+	if cached, err := naep.loadCachedData(school.NCESSCH, cacheNoExpiry); err != nil || len(cached.StateScores) != len(naepData.StateScores) {
+		t.Errorf("NAEP cache = %+v, %v", cached, err)
+	}
+
+	enhanced, err := ai.ScrapeSchoolWebsite(ctx, school)
+	if err != nil {
+		t.Fatalf("ScrapeSchoolWebsite: %v", err)
+	}
+	if !strings.Contains(enhanced.MarkdownContent, "Principal: Maria Alvarez") {
+		t.Errorf("website data = %q", enhanced.MarkdownContent)
+	}
+	if enhanced.AfterCare == nil || !enhanced.AfterCare.Available {
+		t.Errorf("after care = %+v", enhanced.AfterCare)
+	}
+	if _, err := ai.loadCachedData(school.NCESSCH, cacheNoExpiry); err != nil {
+		t.Errorf("website data not cached: %v", err)
+	}
+
+	summary, err := ai.GenerateParentSummary(ctx, school, enhanced, naepData)
+	if err != nil {
+		t.Fatalf("GenerateParentSummary: %v", err)
+	}
+	if !strings.HasPrefix(summary.Summary, "Lincoln Elementary is a neighborhood") {
+		t.Errorf("summary = %q", summary.Summary)
+	}
+
+	// Requests that weren't recorded fail instead of reaching the network
+	if !*recordFixtures {
+		other, err := db.GetSchoolByID("360000100003")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ai.ScrapeSchoolWebsite(ctx, other); err == nil || !strings.Contains(err.Error(), "no recorded response") {
+			t.Errorf("unrecorded scrape = %v", err)
+		}
+	}
+}
+
+func TestFixtureSetSave(t *testing.T) {
+	// Saving rewrites golden files exactly as they are checked in, so
+	// re-recording only shows the responses that changed
+	for _, name := range []string{"naep.json", "anthropic.json"} {
+		want, err := os.ReadFile(filepath.Join("testdata", "fixtures", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		f, err := loadFixtures(filepath.Join("testdata", "fixtures", name), naepFixtureKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.path = filepath.Join(t.TempDir(), name)
+		f.changed = true
+		if err := f.save(); err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := os.ReadFile(f.path); string(got) != string(want) {
+			t.Errorf("%s changed when saved:\n%s", name, got)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// flightGroup coalesces concurrent calls for the same work, such as two
+// visitors opening the same school, into one upstream call whose result every
+// caller shares. The zero value is ready to use.
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[flightKey]*flight
+}
+
+// flightKey identifies a piece of work: the school (or district) it's for and
+// the operation, e.g. {"360000100001", "naep"}
+type flightKey struct {
+	id string
+	op string
+}
+
+type flight struct {
+	done    chan struct{}
+	val     any
+	err     error
+	waiters int
+	cancel  context.CancelFunc
+}
+
+// coalesce runs fn for key, or waits for the call already running for key and
+// returns its result. The result is shared, so callers must not modify it.
+//
+// fn runs in its own goroutine with a context that keeps ctx's values but is
+// canceled only once every caller waiting on it has given up, so one
+// visitor leaving doesn't fail the others' request. A caller whose ctx is
+// done returns ctx's error without waiting.
+func coalesce[T any](ctx context.Context, g *flightGroup, key flightKey, fn func(context.Context) (T, error)) (T, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[flightKey]*flight)
+	}
+	f, ok := g.calls[key]
+	if ok {
+		f.waiters++
+	} else {
+		runCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		f = &flight{done: make(chan struct{}), waiters: 1, cancel: cancel}
+		g.calls[key] = f
+		go g.run(runCtx, key, f, func(ctx context.Context) (any, error) { return fn(ctx) })
+	}
+	g.mu.Unlock()
+
+	select {
+	case <-f.done:
+		if f.err != nil {
+			var zero T
+			return zero, f.err
+		}
+		return f.val.(T), nil
+	case <-ctx.Done():
+		g.mu.Lock()
+		f.waiters--
+		if f.waiters == 0 {
+			// Nobody wants the result; stop the work, and let the next caller start afresh
+			f.cancel()
+			if g.calls[key] == f {
+				delete(g.calls, key)
+			}
+		}
+		g.mu.Unlock()
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+// run calls fn and hands its result to the flight's waiters. A panic becomes
+// the flight's error, as no request's recovery middleware can see it here.
+func (g *flightGroup) run(ctx context.Context, key flightKey, f *flight, fn func(context.Context) (any, error)) {
+	defer func() {
+		if r := recover(); r != nil {
+			f.err = fmt.Errorf("%s for %s panicked: %v", key.op, key.id, r)
+			if logger != nil {
+				logger.Error("Coalesced call panicked", "operation", key.op, "id", key.id, "panic", r)
+			}
+		}
+		f.cancel()
+
+		g.mu.Lock()
+		if g.calls[key] == f {
+			delete(g.calls, key)
+		}
+		g.mu.Unlock()
+		close(f.done)
+	}()
+	f.val, f.err = fn(ctx)
+}
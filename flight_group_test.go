@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitForWaiters blocks until n callers are waiting on key's flight
+func waitForWaiters(t *testing.T, g *flightGroup, key flightKey, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		g.mu.Lock()
+		f := g.calls[key]
+		waiting := f != nil && f.waiters == n
+		g.mu.Unlock()
+		if waiting {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d callers never waited on %v", n, key)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestCoalesce(t *testing.T) {
+	var g flightGroup
+	key := flightKey{"360000100001", "naep"}
+	release := make(chan struct{})
+	var calls atomic.Int32
+	work := func(ctx context.Context) (string, error) {
+		calls.Add(1)
+		<-release
+		return "scores", nil
+	}
+
+	var wg sync.WaitGroup
+	results := make([]string, 3)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], _ = coalesce(context.Background(), &g, key, work)
+		}()
+	}
+	waitForWaiters(t, &g, key, 3)
+
+	// Other schools and operations aren't held up
+	if got, err := coalesce(context.Background(), &g, flightKey{"360000100001", "website"}, func(context.Context) (string, error) { return "site", nil }); got != "site" || err != nil {
+		t.Errorf("other operation = %q, %v", got, err)
+	}
+
+	close(release)
+	wg.Wait()
+	if calls.Load() != 1 {
+		t.Errorf("work ran %d times, want once", calls.Load())
+	}
+	for i, got := range results {
+		if got != "scores" {
+			t.Errorf("caller %d got %q", i, got)
+		}
+	}
+
+	// Finished flights aren't reused
+	if _, err := coalesce(context.Background(), &g, key, func(context.Context) (string, error) { return "", errors.New("down") }); err == nil {
+		t.Error("expected a new call's error")
+	}
+}
+
+func TestCoalesceCancel(t *testing.T) {
+	var g flightGroup
+	key := flightKey{"360000100001", "website"}
+	started := make(chan struct{})
+	stopped := make(chan error, 1)
+	work := func(ctx context.Context) (int, error) {
+		close(started)
+		<-ctx.Done()
+		stopped <- ctx.Err()
+		return 0, ctx.Err()
+	}
+
+	first, cancelFirst := context.WithCancel(context.Background())
+	second, cancelSecond := context.WithCancel(context.Background())
+	errs := make(chan error, 2)
+	go func() {
+		_, err := coalesce(first, &g, key, work)
+		errs <- err
+	}()
+	<-started
+	go func() {
+		_, err := coalesce(second, &g, key, work)
+		errs <- err
+	}()
+	waitForWaiters(t, &g, key, 2)
+
+	// One caller giving up leaves the work running for the other
+	cancelFirst()
+	if err := <-errs; !errors.Is(err, context.Canceled) {
+		t.Errorf("canceled caller got %v", err)
+	}
+	select {
+	case <-stopped:
+		t.Fatal("work stopped while a caller was still waiting")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	cancelSecond()
+	<-errs
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("work kept running after every caller gave up")
+	}
+
+	// A panic fails the callers instead of the process
+	_, err := coalesce(context.Background(), &g, key, func(context.Context) (int, error) { panic("boom") })
+	if err == nil {
+		t.Error("expected a panic to become an error")
+	}
+}
+
+// TestFetchNAEPDataCoalesced tests that visitors opening the same school at
+// once share one set of NAEP API requests
+func TestFetchNAEPDataCoalesced(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	var requests atomic.Int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		<-release
+		w.Write([]byte(`{"status": 200, "result": []}`))
+	}))
+	defer server.Close()
+
+	client := &NAEPClient{httpClient: server.Client(), baseURL: server.URL, db: db, cacheTTL: time.Hour}
+	school := MockSchool("360000100001", "Lincoln Elementary School", "San Francisco Unified", "CA", "KG", "05")
+	key := flightKey{school.NCESSCH, "naep"}
+
+	errs := make(chan error, 2)
+	for range 2 {
+		go func() {
+			_, err := client.FetchNAEPData(context.Background(), school)
+			errs <- err
+		}()
+	}
+	waitForWaiters(t, &client.flights, key, 2)
+	close(release)
+	for range 2 {
+		if err := <-errs; err == nil {
+			t.Error("expected empty API results to fail")
+		}
+	}
+	shared := requests.Load()
+
+	// Nothing was cached, so a later fetch makes its own requests
+	requests.Store(0)
+	if _, err := client.FetchNAEPData(context.Background(), school); err == nil {
+		t.Fatal("expected empty API results to fail")
+	}
+	if alone := requests.Load(); shared != alone {
+		t.Errorf("two concurrent fetches made %d requests, one fetch makes %d", shared, alone)
+	}
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Geocode precisions, from most to least exact
+const (
+	geocodeAddress = "address" // The street address
+	geocodeCity    = "city"    // City and zip, for schools whose mailing address is a PO box
+	geocodeZip     = "zip"     // The average location of the zip code's other schools
+)
+
+// zipCentroidProvider names zip-average locations in the geocodes cache
+const zipCentroidProvider = "zip"
+
+// defaultGeocodeRate is how many geocoding requests the backfill sends a
+// second. Nominatim's usage policy allows one.
+const defaultGeocodeRate = 1.0
+
+// maildropPattern matches mailing addresses that aren't where the school is
+var maildropPattern = regexp.MustCompile(`(?i)^\s*(p\.?\s*o\.?\s*box|post\s+office\s+box|box\s+\d|drawer\s|pmb\s|rural\s+route|rr\s+\d)`)
+
+// GeocodeBackfillOptions configures a geocoding backfill
+type GeocodeBackfillOptions struct {
+	Limit    int     // Schools to geocode at most; 0 for all
+	Rate     float64 // Requests a second; defaultGeocodeRate when 0
+	Retry    bool    // Geocode again addresses cached as not found or zip averages
+	Progress func(done, total int)
+}
+
+// GeocodeBackfill counts what a geocoding backfill did
+type GeocodeBackfill struct {
+	Provider    string
+	Missing     int // Schools without coordinates when the backfill started
+	Cached      int // Located from the geocodes cache
+	Geocoded    int // Located by the provider
+	Approximate int // Located at their zip code's average school location
+	NotFound    int // Still without coordinates
+	Failed      int // Requests that failed; tried again next time
+	Located     int // Schools with coordinates afterward
+	Schools     int // Schools in the directory
+}
+
+// Coverage is the share of schools with coordinates, as a percentage
+func (b *GeocodeBackfill) Coverage() float64 {
+	if b.Schools == 0 {
+		return 0
+	}
+	return float64(b.Located) / float64(b.Schools) * 100
+}
+
+// cachedGeocode is an address's row in the geocodes cache
+type cachedGeocode struct {
+	provider string
+	lat, lon sql.NullFloat64
+}
+
+// found reports whether the address was located
+func (c cachedGeocode) found() bool {
+	return c.lat.Valid && c.lon.Valid
+}
+
+// schoolGeocodeAddress builds the address to geocode from a school's mailing
+// address. PO boxes and other maildrops are left out, so they're placed by
+// city and zip instead.
+func schoolGeocodeAddress(street, city, state, zip string) (address, precision string) {
+	zip, _, _ = strings.Cut(strings.TrimSpace(zip), "-")
+	place := strings.TrimSpace(strings.TrimSpace(city) + ", " + strings.TrimSpace(state+" "+zip))
+	if strings.TrimSpace(city) == "" && zip == "" {
+		return "", ""
+	}
+	street = strings.TrimSpace(street)
+	if street == "" || maildropPattern.MatchString(street) {
+		return place, geocodeCity
+	}
+	return street + ", " + place, geocodeAddress
+}
+
+// geocodeCacheKey normalizes an address for the geocodes cache
+func geocodeCacheKey(address string) string {
+	return strings.Join(strings.Fields(strings.ToUpper(address)), " ")
+}
+
+// BackfillGeocodes locates the schools without EDGE coordinates by their
+// mailing addresses, caching every answer in the geocodes table so each
+// address is only sent to the provider once. Schools the provider can't place
+// get their zip code's average school location. Requests are spaced to
+// opts.Rate a second; when ctx ends, what was found so far is kept.
+func BackfillGeocodes(ctx context.Context, db *DB, provider GeocodeProvider, opts GeocodeBackfillOptions) (*GeocodeBackfill, error) {
+	result := &GeocodeBackfill{Provider: provider.Name()}
+	query := fmt.Sprintf(`
+		SELECT d.NCESSCH, COALESCE(d.MSTREET1, ''), COALESCE(d.MCITY, ''), COALESCE(d.ST, ''), COALESCE(d.MZIP, '')
+		FROM directory d
+		WHERE %s AND d.NCESSCH NOT IN (SELECT ncessch FROM school_coordinates)
+		ORDER BY d.NCESSCH
+	`, notMergedCondition)
+	if opts.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", opts.Limit)
+	}
+	rows, err := db.conn.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find schools without coordinates: %w", err)
+	}
+	type missingSchool struct{ id, street, city, state, zip string }
+	var missing []missingSchool
+	for rows.Next() {
+		var s missingSchool
+		if err := rows.Scan(&s.id, &s.street, &s.city, &s.state, &s.zip); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan school: %w", err)
+		}
+		missing = append(missing, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	result.Missing = len(missing)
+
+	rate := opts.Rate
+	if rate <= 0 {
+		rate = defaultGeocodeRate
+	}
+	interval := time.Duration(float64(time.Second) / rate)
+	var lastRequest time.Time
+
+	for i, s := range missing {
+		if opts.Progress != nil {
+			opts.Progress(i, len(missing))
+		}
+		address, precision := schoolGeocodeAddress(s.street, s.city, s.state, s.zip)
+		key := geocodeCacheKey(address)
+
+		var lat, lon float64
+		located := false
+		cached, ok, err := db.cachedGeocode(key)
+		if err != nil {
+			return nil, err
+		}
+		retry := opts.Retry && (!cached.found() || cached.provider == zipCentroidProvider)
+		switch {
+		case address == "":
+			// Nothing to send; only the zip fallback below can place it
+		case ok && !retry:
+			if cached.found() {
+				lat, lon, located = cached.lat.Float64, cached.lon.Float64, true
+				result.Cached++
+			}
+		default:
+			if wait := interval - time.Since(lastRequest); wait > 0 {
+				select {
+				case <-ctx.Done():
+					return result, db.countLocated(result)
+				case <-time.After(wait):
+				}
+			}
+			lastRequest = time.Now()
+			home, err := provider.Geocode(ctx, address)
+			switch {
+			case err == nil:
+				lat, lon, located = home.Lat, home.Lon, true
+				result.Geocoded++
+				err = db.saveGeocode(key, provider.Name(), home, precision)
+			case errors.Is(err, ErrAddressNotFound):
+				err = db.saveGeocode(key, provider.Name(), nil, precision)
+			case ctx.Err() != nil:
+				return result, db.countLocated(result)
+			default:
+				if logger != nil {
+					logger.Warn("Geocoding failed", "ncessch", s.id, "error", err)
+				}
+				result.Failed++
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if !located {
+			zipLat, zipLon, found, err := db.zipCentroid(s.zip)
+			if err != nil {
+				return nil, err
+			}
+			if found {
+				lat, lon, located = zipLat, zipLon, true
+				result.Approximate++
+				if address != "" {
+					if err := db.saveGeocode(key, zipCentroidProvider, &Home{Lat: lat, Lon: lon}, geocodeZip); err != nil {
+						return nil, err
+					}
+				}
+			}
+		}
+		if !located {
+			result.NotFound++
+			continue
+		}
+		// EDGE coordinates loaded later replace geocoded ones
+		if _, err := db.conn.Exec(`INSERT INTO school_coordinates (ncessch, lat, lon) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING`, s.id, lat, lon); err != nil {
+			return nil, fmt.Errorf("failed to save school coordinates: %w", err)
+		}
+	}
+	if opts.Progress != nil {
+		opts.Progress(len(missing), len(missing))
+	}
+	return result, db.countLocated(result)
+}
+
+// cachedGeocode looks up an address in the geocodes cache
+func (d *DB) cachedGeocode(key string) (cachedGeocode, bool, error) {
+	var c cachedGeocode
+	err := d.conn.QueryRow(`SELECT provider, lat, lon FROM geocodes WHERE address = $1`, key).Scan(&c.provider, &c.lat, &c.lon)
+	if err == sql.ErrNoRows {
+		return c, false, nil
+	}
+	if err != nil {
+		return c, false, fmt.Errorf("failed to read geocodes cache: %w", err)
+	}
+	return c, true, nil
+}
+
+// saveGeocode caches an address's location, or that it wasn't found when home is nil
+func (d *DB) saveGeocode(key, provider string, home *Home, precision string) error {
+	var lat, lon, matched interface{}
+	if home != nil {
+		lat, lon, matched = home.Lat, home.Lon, home.Label
+	}
+	_, err := d.conn.Exec(`
+		INSERT OR REPLACE INTO geocodes (address, provider, lat, lon, matched_address, precision, geocoded_at)
+		VALUES ($1, $2, $3, $4, $5, $6, CURRENT_TIMESTAMP)
+	`, key, provider, lat, lon, matched, precision)
+	if err != nil {
+		return fmt.Errorf("failed to cache geocode: %w", err)
+	}
+	return nil
+}
+
+// zipCentroid is the average location of the schools with coordinates in a zip code
+func (d *DB) zipCentroid(zip string) (lat, lon float64, ok bool, err error) {
+	zip, _, _ = strings.Cut(strings.TrimSpace(zip), "-")
+	if zip == "" {
+		return 0, 0, false, nil
+	}
+	var avgLat, avgLon sql.NullFloat64
+	err = d.conn.QueryRow(`
+		SELECT avg(c.lat), avg(c.lon)
+		FROM school_coordinates c JOIN directory d ON d.NCESSCH = c.ncessch
+		WHERE LEFT(d.MZIP, 5) = $1
+	`, zip).Scan(&avgLat, &avgLon)
+	if err != nil {
+		return 0, 0, false, fmt.Errorf("failed to average zip code %s: %w", zip, err)
+	}
+	return avgLat.Float64, avgLon.Float64, avgLat.Valid && avgLon.Valid, nil
+}
+
+// countLocated fills in how many schools have coordinates
+func (d *DB) countLocated(result *GeocodeBackfill) error {
+	err := d.conn.QueryRow(fmt.Sprintf(`
+		SELECT count(*), count(c.ncessch)
+		FROM directory d LEFT JOIN school_coordinates c ON c.ncessch = d.NCESSCH
+		WHERE %s
+	`, notMergedCondition)).Scan(&result.Schools, &result.Located)
+	if err != nil {
+		return fmt.Errorf("failed to count located schools: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// fakeGeocoder answers from a fixed map of addresses, counting requests
+type fakeGeocoder struct {
+	places   map[string]Home
+	requests []string
+}
+
+func (f *fakeGeocoder) Name() string { return "fake" }
+
+func (f *fakeGeocoder) Geocode(ctx context.Context, address string) (*Home, error) {
+	f.requests = append(f.requests, address)
+	if strings.Contains(address, "Roosevelt") {
+		return nil, errors.New("service unavailable")
+	}
+	home, ok := f.places[address]
+	if !ok {
+		return nil, ErrAddressNotFound
+	}
+	return &home, nil
+}
+
+func TestSchoolGeocodeAddress(t *testing.T) {
+	tests := []struct {
+		street, city, zip  string
+		address, precision string
+	}{
+		{"123 Lincoln St", "San Francisco", "94102-1234", "123 Lincoln St, San Francisco, CA 94102", geocodeAddress},
+		{"P.O. Box 42", "San Francisco", "94102", "San Francisco, CA 94102", geocodeCity},
+		{"Rural Route 2", "San Francisco", "94102", "San Francisco, CA 94102", geocodeCity},
+		{"Boxwood Ln", "San Francisco", "94102", "Boxwood Ln, San Francisco, CA 94102", geocodeAddress},
+		{"123 Lincoln St", "", "", "", ""},
+	}
+	for _, tt := range tests {
+		address, precision := schoolGeocodeAddress(tt.street, tt.city, "CA", tt.zip)
+		if address != tt.address || precision != tt.precision {
+			t.Errorf("schoolGeocodeAddress(%q, %q, %q) = %q, %q; want %q, %q", tt.street, tt.city, tt.zip, address, precision, tt.address, tt.precision)
+		}
+	}
+}
+
+func TestBackfillGeocodes(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	// Lincoln has EDGE coordinates; Jefferson's mail goes to a PO box; Madison
+	// shares Lincoln's zip code but the geocoder can't find it
+	if _, err := db.conn.Exec(`INSERT INTO school_coordinates VALUES ('360000100001', 37.7749, -122.4194)`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.conn.Exec(`UPDATE directory SET MSTREET1 = 'PO Box 300' WHERE NCESSCH = '360000100003'`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.conn.Exec(`UPDATE directory SET MZIP = '94102' WHERE NCESSCH = '360000100005'`); err != nil {
+		t.Fatal(err)
+	}
+
+	geocoder := &fakeGeocoder{places: map[string]Home{
+		"456 Washington Ave, Los Angeles, CA 90001": {Lat: 33.97, Lon: -118.25, Label: "456 WASHINGTON AVE, LOS ANGELES, CA, 90001"},
+		"Houston, TX 77001":                         {Lat: 29.76, Lon: -95.37, Label: "Houston, TX"},
+	}}
+	opts := GeocodeBackfillOptions{Rate: 1000}
+	result, err := BackfillGeocodes(t.Context(), db, geocoder, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Missing != 4 || result.Geocoded != 2 || result.Approximate != 1 || result.Failed != 1 || result.Cached != 0 {
+		t.Errorf("first backfill = %+v", result)
+	}
+	if result.Located != 4 || result.Schools != 5 || result.Coverage() != 80 {
+		t.Errorf("coverage = %d of %d (%.0f%%)", result.Located, result.Schools, result.Coverage())
+	}
+	var lat float64
+	if err := db.conn.QueryRow(`SELECT lat FROM school_coordinates WHERE ncessch = '360000100005'`).Scan(&lat); err != nil || lat != 37.7749 {
+		t.Errorf("Madison placed at lat %g (%v), want its zip code's average", lat, err)
+	}
+	var precision string
+	if err := db.conn.QueryRow(`SELECT precision FROM geocodes WHERE address = 'HOUSTON, TX 77001'`).Scan(&precision); err != nil || precision != geocodeCity {
+		t.Errorf("PO box geocode precision = %q (%v), want %q", precision, err, geocodeCity)
+	}
+
+	// Geocoded schools are done; only the failed request is sent again
+	geocoder.requests = nil
+	result, err = BackfillGeocodes(t.Context(), db, geocoder, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Missing != 1 || len(geocoder.requests) != 1 || result.Failed != 1 {
+		t.Errorf("second backfill = %+v after %v", result, geocoder.requests)
+	}
+
+	// Schools whose coordinates are removed are located from the cache
+	if _, err := db.conn.Exec(`DELETE FROM school_coordinates WHERE ncessch IN ('360000100002', '360000100005')`); err != nil {
+		t.Fatal(err)
+	}
+	geocoder.requests = nil
+	result, err = BackfillGeocodes(t.Context(), db, geocoder, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Cached != 2 || result.Geocoded != 0 || len(geocoder.requests) != 1 {
+		t.Errorf("cached backfill = %+v after %v", result, geocoder.requests)
+	}
+
+	// Retrying sends zip averages and misses to the geocoder again, up to the limit
+	if _, err := db.conn.Exec(`DELETE FROM school_coordinates WHERE ncessch = '360000100005'`); err != nil {
+		t.Fatal(err)
+	}
+	geocoder.requests = nil
+	if _, err := BackfillGeocodes(t.Context(), db, geocoder, GeocodeBackfillOptions{Rate: 1000, Retry: true, Limit: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if len(geocoder.requests) != 2 || !strings.HasPrefix(geocoder.requests[1], "654 Madison Pkwy") {
+		t.Errorf("retry requests = %v", geocoder.requests)
+	}
+}
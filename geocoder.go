@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// censusGeocoderURL is the Census Bureau's one-line address geocoder, which is
+// free and needs no key
+const censusGeocoderURL = "https://geocoding.geo.census.gov/geocoder/locations/onelineaddress"
+
+// ErrAddressNotFound is returned when the geocoder has no match for an address
+var ErrAddressNotFound = errors.New("address not found")
+
+// ErrNoLocation is returned when no address is given and no home location is set
+var ErrNoLocation = errors.New("no address given and no home location is set")
+
+// Geocoder turns street addresses into coordinates with the Census geocoder
+type Geocoder struct {
+	httpClient HTTPDoer
+	baseURL    string
+}
+
+// GeocoderOption configures a Geocoder
+type GeocoderOption func(*Geocoder)
+
+// WithGeocoderHTTPClient sends geocoding requests with h
+func WithGeocoderHTTPClient(h HTTPDoer) GeocoderOption {
+	return func(g *Geocoder) { g.httpClient = h }
+}
+
+// WithGeocoderURL sends geocoding requests to a different endpoint, such as a test server
+func WithGeocoderURL(baseURL string) GeocoderOption {
+	return func(g *Geocoder) { g.baseURL = baseURL }
+}
+
+// NewGeocoder creates a Census geocoder
+func NewGeocoder(opts ...GeocoderOption) *Geocoder {
+	g := &Geocoder{
+		httpClient: &http.Client{Timeout: 15 * time.Second},
+		baseURL:    censusGeocoderURL,
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g
+}
+
+// censusGeocodeResponse is the part of a Census geocoder response we read
+type censusGeocodeResponse struct {
+	Result struct {
+		AddressMatches []struct {
+			MatchedAddress string `json:"matchedAddress"`
+			Coordinates    struct {
+				X float64 `json:"x"` // Longitude
+				Y float64 `json:"y"` // Latitude
+			} `json:"coordinates"`
+		} `json:"addressMatches"`
+	} `json:"result"`
+}
+
+// Geocode returns the location of a US street address, labeled with the
+// address as the geocoder matched it
+func (g *Geocoder) Geocode(ctx context.Context, address string) (*Home, error) {
+	address = strings.TrimSpace(address)
+	if address == "" {
+		return nil, fmt.Errorf("%w: no address given", ErrAddressNotFound)
+	}
+	query := url.Values{"address": {address}, "benchmark": {"Public_AR_Current"}, "format": {"json"}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, g.baseURL+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create geocoding request: %w", err)
+	}
+
+	var result censusGeocodeResponse
+	err = websiteRetry.Do(ctx, func() error {
+		resp, err := g.httpClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = resp.Body.Close() }()
+		if resp.StatusCode != http.StatusOK {
+			return newHTTPStatusError(resp)
+		}
+		return json.NewDecoder(resp.Body).Decode(&result)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to geocode %q: %w", address, err)
+	}
+
+	matches := result.Result.AddressMatches
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrAddressNotFound, address)
+	}
+	return &Home{Lat: matches[0].Coordinates.Y, Lon: matches[0].Coordinates.X, Label: matches[0].MatchedAddress}, nil
+}
+
+// ResolveLocation reads a location as "lat,lon" coordinates or, failing that,
+// a street address to geocode. An empty location is the saved home.
+func ResolveLocation(ctx context.Context, db *DB, geocoder *Geocoder, location string) (*Home, error) {
+	location = strings.TrimSpace(location)
+	if location == "" {
+		home, err := db.Home()
+		if err != nil {
+			return nil, err
+		}
+		if home == nil {
+			return nil, ErrNoLocation
+		}
+		return home, nil
+	}
+	if lat, lon, err := parseCoordinates(location); err == nil {
+		return &Home{Lat: lat, Lon: lon}, nil
+	}
+	return geocoder.Geocode(ctx, location)
+}
+
+// GeocodeProvider turns addresses into coordinates for the geocoding backfill.
+// Geocode returns an error wrapping ErrAddressNotFound when it has no match.
+type GeocodeProvider interface {
+	Name() string
+	Geocode(ctx context.Context, address string) (*Home, error)
+}
+
+// Name identifies the Census geocoder in the geocodes cache
+func (g *Geocoder) Name() string { return "census" }
+
+// nominatimURL is OpenStreetMap's Nominatim search API, which allows one
+// request a second and asks clients to identify themselves
+const nominatimURL = "https://nominatim.openstreetmap.org/search"
+
+// NominatimGeocoder geocodes with OpenStreetMap's Nominatim. Unlike the Census
+// geocoder it also places cities and zip codes, so it can locate schools whose
+// only address is a PO box.
+type NominatimGeocoder struct {
+	httpClient HTTPDoer
+	baseURL    string
+}
+
+// NewNominatimGeocoder creates a Nominatim geocoder. It takes the Census
+// geocoder's options.
+func NewNominatimGeocoder(opts ...GeocoderOption) *NominatimGeocoder {
+	g := NewGeocoder(append([]GeocoderOption{WithGeocoderURL(nominatimURL)}, opts...)...)
+	return &NominatimGeocoder{httpClient: g.httpClient, baseURL: g.baseURL}
+}
+
+// Name identifies Nominatim in the geocodes cache
+func (g *NominatimGeocoder) Name() string { return "nominatim" }
+
+// Geocode returns the location of a US address or place
+func (g *NominatimGeocoder) Geocode(ctx context.Context, address string) (*Home, error) {
+	address = strings.TrimSpace(address)
+	if address == "" {
+		return nil, fmt.Errorf("%w: no address given", ErrAddressNotFound)
+	}
+	query := url.Values{"q": {address}, "format": {"jsonv2"}, "limit": {"1"}, "countrycodes": {"us"}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, g.baseURL+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create geocoding request: %w", err)
+	}
+	req.Header.Set("User-Agent", "schoolfinder (https://github.com/zorndorff/schoolfinder-pro)")
+
+	var results []struct {
+		Lat         string `json:"lat"`
+		Lon         string `json:"lon"`
+		DisplayName string `json:"display_name"`
+	}
+	err = websiteRetry.Do(ctx, func() error {
+		resp, err := g.httpClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = resp.Body.Close() }()
+		if resp.StatusCode != http.StatusOK {
+			return newHTTPStatusError(resp)
+		}
+		return json.NewDecoder(resp.Body).Decode(&results)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to geocode %q: %w", address, err)
+	}
+
+	if len(results) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrAddressNotFound, address)
+	}
+	lat, lon, err := parseCoordinates(results[0].Lat + "," + results[0].Lon)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Nominatim result for %q: %w", address, err)
+	}
+	return &Home{Lat: lat, Lon: lon, Label: results[0].DisplayName}, nil
+}
+
+// NewGeocodeProvider returns the geocoder named "census" (the default) or "nominatim"
+func NewGeocodeProvider(name string) (GeocodeProvider, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "census":
+		return NewGeocoder(), nil
+	case "nominatim":
+		return NewNominatimGeocoder(), nil
+	default:
+		return nil, fmt.Errorf("unknown geocoder %q (use census or nominatim)", name)
+	}
+}
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// schoolColumnNames are the column names, compared case-insensitively, that
+// mark an imported table as keyed by school
+var schoolColumnNames = []string{"ncessch", "nces_id", "nces_school_id"}
+
+// maxDatasetLabelLength bounds an imported table's label, which is shown on
+// search results
+const maxDatasetLabelLength = 60
+
+// ImportedTable is a table the user imported from an uploaded file
+type ImportedTable struct {
+	Name          string
+	Description   string
+	SourceFile    string
+	RowCount      int64
+	SchoolColumn  string   // Column holding NCESSCH; empty when not keyed by school
+	Label         string   // Short name shown on search results, e.g. "PTA ratings"
+	SearchColumns []string // Text columns the main search box matches
+	ImportedAt    time.Time
+}
+
+// DisplayName is the table's label, or its name when it has none
+func (t ImportedTable) DisplayName() string {
+	if t.Label != "" {
+		return t.Label
+	}
+	return strings.ReplaceAll(t.Name, "_", " ")
+}
+
+// Searchable reports whether the main search box matches column
+func (t ImportedTable) Searchable(column string) bool {
+	return slices.Contains(t.SearchColumns, column)
+}
+
+// RecordImport records an imported table, detecting whether it's keyed by
+// school. The text columns of tables keyed by school are searchable unless
+// t lists which are.
+func (d *DB) RecordImport(t ImportedTable) (*ImportedTable, error) {
+	column, err := d.schoolColumn(t.Name)
+	if err != nil {
+		return nil, err
+	}
+	t.SchoolColumn = column
+	t.ImportedAt = time.Now().UTC()
+	if t.SchoolColumn == "" {
+		t.SearchColumns = nil
+	} else if t.SearchColumns == nil {
+		if t.SearchColumns, err = d.tableColumns(t.Name, t.SchoolColumn, true); err != nil {
+			return nil, err
+		}
+	}
+
+	searchColumns, err := searchColumnsJSON(t.SearchColumns)
+	if err != nil {
+		return nil, err
+	}
+	_, err = d.conn.Exec(`
+		INSERT INTO imported_tables (table_name, description, source_file, row_count, school_column, label, search_columns, imported_at)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, ''), $7, $8)
+		ON CONFLICT (table_name) DO UPDATE SET
+			description = EXCLUDED.description,
+			source_file = EXCLUDED.source_file,
+			row_count = EXCLUDED.row_count,
+			school_column = EXCLUDED.school_column,
+			label = EXCLUDED.label,
+			search_columns = EXCLUDED.search_columns,
+			imported_at = EXCLUDED.imported_at
+	`, t.Name, t.Description, t.SourceFile, t.RowCount, t.SchoolColumn, t.Label, searchColumns, t.ImportedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record import of %s: %w", t.Name, err)
+	}
+	if len(t.SearchColumns) > 0 {
+		d.queueSearchIndex()
+	}
+	return &t, nil
+}
+
+// UpdateImportedTable changes an imported table's label, the column mapping
+// its rows to schools, and which of its text columns the main search box
+// matches. It returns sql.ErrNoRows when no such table was imported.
+func (d *DB) UpdateImportedTable(name, label, schoolColumn string, searchColumns []string) (*ImportedTable, error) {
+	t, err := d.ImportedTable(name)
+	if err != nil {
+		return nil, err
+	}
+
+	label = strings.TrimSpace(label)
+	if len(label) > maxDatasetLabelLength {
+		return nil, fmt.Errorf("keep the label under %d characters", maxDatasetLabelLength)
+	}
+	if schoolColumn != "" {
+		columns, err := d.tableColumns(name, "", false)
+		if err != nil {
+			return nil, err
+		}
+		if !slices.Contains(columns, schoolColumn) {
+			return nil, fmt.Errorf("%s has no column %q", name, schoolColumn)
+		}
+	}
+	if len(searchColumns) > 0 {
+		if schoolColumn == "" {
+			return nil, fmt.Errorf("choose the column with school IDs before making %s searchable", name)
+		}
+		text, err := d.tableColumns(name, schoolColumn, true)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range searchColumns {
+			if !slices.Contains(text, c) {
+				return nil, fmt.Errorf("%s has no text column %q", name, c)
+			}
+		}
+	}
+
+	encoded, err := searchColumnsJSON(searchColumns)
+	if err != nil {
+		return nil, err
+	}
+	_, err = d.conn.Exec(`
+		UPDATE imported_tables SET label = NULLIF($2, ''), school_column = NULLIF($3, ''), search_columns = $4
+		WHERE table_name = $1
+	`, name, label, schoolColumn, encoded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update imported table %s: %w", name, err)
+	}
+
+	// The old or new searchable text may be in the index
+	if len(t.SearchColumns) > 0 || len(searchColumns) > 0 {
+		d.queueSearchIndex()
+	}
+	t.Label, t.SchoolColumn, t.SearchColumns = label, schoolColumn, searchColumns
+	return t, nil
+}
+
+// searchColumnsJSON encodes search columns for imported_tables, NULL when
+// there are none
+func searchColumnsJSON(columns []string) (interface{}, error) {
+	if len(columns) == 0 {
+		return nil, nil
+	}
+	b, err := json.Marshal(columns)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode search columns: %w", err)
+	}
+	return string(b), nil
+}
+
+// schoolColumn finds the column of a table that holds NCESSCH school IDs
+func (d *DB) schoolColumn(table string) (string, error) {
+	var column sql.NullString
+	err := d.conn.QueryRow(fmt.Sprintf(`
+		SELECT column_name FROM information_schema.columns
+		WHERE table_schema = 'main' AND table_name = $1 AND lower(column_name) IN ('%s')
+		ORDER BY ordinal_position
+		LIMIT 1
+	`, strings.Join(schoolColumnNames, "', '")), table).Scan(&column)
+	if err != nil && err != sql.ErrNoRows {
+		return "", fmt.Errorf("failed to check columns of %s: %w", table, err)
+	}
+	return column.String, nil
+}
+
+// tableColumns lists a table's columns other than except, only its text
+// columns when text is set
+func (d *DB) tableColumns(table, except string, text bool) ([]string, error) {
+	rows, err := d.conn.Query(`
+		SELECT column_name FROM information_schema.columns
+		WHERE table_schema = 'main' AND table_name = $1 AND lower(column_name) <> lower($2)
+			AND (NOT $3 OR data_type = 'VARCHAR')
+		ORDER BY ordinal_position
+	`, table, except, text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list columns of %s: %w", table, err)
+	}
+	defer rows.Close()
+	var columns []string
+	for rows.Next() {
+		var c string
+		if err := rows.Scan(&c); err != nil {
+			return nil, fmt.Errorf("failed to list columns of %s: %w", table, err)
+		}
+		columns = append(columns, c)
+	}
+	return columns, rows.Err()
+}
+
+// searchColumns returns the search columns of t that are still text columns,
+// since the table may have been changed with SQL after it was imported
+func (d *DB) searchColumns(t ImportedTable) ([]string, error) {
+	if t.SchoolColumn == "" || len(t.SearchColumns) == 0 {
+		return nil, nil
+	}
+	text, err := d.tableColumns(t.Name, t.SchoolColumn, true)
+	if err != nil {
+		return nil, err
+	}
+	var columns []string
+	for _, c := range t.SearchColumns {
+		if slices.Contains(text, c) {
+			columns = append(columns, c)
+		}
+	}
+	return columns, nil
+}
+
+// importedSchoolID is the SQL for a table's school column as an NCESSCH. IDs
+// read from CSV as numbers lose their leading zeros.
+func importedSchoolID(t ImportedTable) string {
+	return fmt.Sprintf(`lpad(CAST(%s AS VARCHAR), 12, '0')`, quoteIdent(t.SchoolColumn))
+}
+
+// importedText is the SQL joining columns into one string
+func importedText(columns []string) string {
+	quoted := make([]string, len(columns))
+	for i, c := range columns {
+		quoted[i] = quoteIdent(c)
+	}
+	return "concat_ws(' ', " + strings.Join(quoted, ", ") + ")"
+}
+
+// ImportedMatches finds which imported tables have searchable rows matching
+// every word of query for the schools in ids. It returns the matching tables'
+// display names by NCESSCH.
+func (d *DB) ImportedMatches(query string, ids []string) (map[string][]string, error) {
+	var words []string
+	for _, w := range strings.Fields(strings.ToLower(query)) {
+		if w = strings.Trim(w, `"'`); w != "" {
+			words = append(words, w)
+		}
+	}
+	if len(words) == 0 || len(ids) == 0 {
+		return nil, nil
+	}
+	tables, err := d.ImportedTables()
+	if err != nil {
+		return nil, err
+	}
+
+	in := make([]string, len(ids))
+	for i, id := range ids {
+		in[i] = quoteLiteral(id)
+	}
+	matches := make(map[string][]string)
+	for _, t := range tables {
+		columns, err := d.searchColumns(t)
+		if err != nil {
+			return nil, err
+		}
+		if len(columns) == 0 {
+			continue
+		}
+
+		text := "lower(" + importedText(columns) + ")"
+		conditions := make([]string, len(words))
+		args := make([]interface{}, len(words))
+		for i, w := range words {
+			conditions[i] = fmt.Sprintf("contains(%s, $%d)", text, i+1)
+			args[i] = w
+		}
+		id := importedSchoolID(t)
+		rows, err := d.conn.Query(fmt.Sprintf(`SELECT DISTINCT %s FROM %s WHERE %s IN (%s) AND %s`,
+			id, quoteIdent(t.Name), id, strings.Join(in, ", "), strings.Join(conditions, " AND ")), args...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search imported table %s: %w", t.Name, err)
+		}
+		for rows.Next() {
+			var ncessch string
+			if err := rows.Scan(&ncessch); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to search imported table %s: %w", t.Name, err)
+			}
+			matches[ncessch] = append(matches[ncessch], t.DisplayName())
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to search imported table %s: %w", t.Name, err)
+		}
+	}
+	return matches, nil
+}
+
+// importedTablesSelect selects imported_tables rows whose tables still exist
+const importedTablesSelect = `
+	SELECT i.table_name, COALESCE(i.description, ''), COALESCE(i.source_file, ''), COALESCE(i.row_count, 0),
+		COALESCE(i.school_column, ''), COALESCE(i.label, ''), CAST(i.search_columns AS VARCHAR), i.imported_at
+	FROM imported_tables i
+	JOIN information_schema.tables t ON t.table_schema = 'main' AND t.table_name = i.table_name
+`
+
+// scanImportedTable scans a row selected by importedTablesSelect
+func scanImportedTable(scan func(dest ...interface{}) error) (ImportedTable, error) {
+	var t ImportedTable
+	var searchColumns sql.NullString
+	if err := scan(&t.Name, &t.Description, &t.SourceFile, &t.RowCount, &t.SchoolColumn, &t.Label, &searchColumns, &t.ImportedAt); err != nil {
+		return t, err
+	}
+	if searchColumns.Valid {
+		if err := json.Unmarshal([]byte(searchColumns.String), &t.SearchColumns); err != nil {
+			return t, fmt.Errorf("failed to decode search columns of %s: %w", t.Name, err)
+		}
+	}
+	return t, nil
+}
+
+// ImportedTable loads the record of an imported table, or sql.ErrNoRows
+func (d *DB) ImportedTable(name string) (*ImportedTable, error) {
+	t, err := scanImportedTable(d.conn.QueryRow(importedTablesSelect+`WHERE i.table_name = $1`, name).Scan)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to load imported table %s: %w", name, err)
+	}
+	return &t, nil
+}
+
+// ImportedTables lists the recorded imports whose tables still exist, newest
+// first
+func (d *DB) ImportedTables() ([]ImportedTable, error) {
+	rows, err := d.conn.Query(importedTablesSelect + `ORDER BY i.imported_at DESC, i.table_name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list imported tables: %w", err)
+	}
+	defer rows.Close()
+
+	var tables []ImportedTable
+	for rows.Next() {
+		t, err := scanImportedTable(rows.Scan)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan imported table: %w", err)
+		}
+		tables = append(tables, t)
+	}
+	return tables, rows.Err()
+}
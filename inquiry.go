@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+)
+
+// Inquiry emails are drafted from Go text/template templates. The first line
+// of a template is the subject, "Subject: ...", and the rest is the body.
+// Files named inquiry_<name>.tmpl in the data directory add templates or
+// replace the built-in ones of the same name.
+const (
+	inquiryFilePattern     = "inquiry_*.tmpl"
+	defaultInquiryTemplate = "enrollment"
+	maxInquiryQuestions    = 5 // Keeps the mailto link short enough for mail clients
+)
+
+// builtinInquiryTemplates are the inquiry emails available without any files
+var builtinInquiryTemplates = map[string]string{
+	"enrollment": `Subject: Enrollment inquiry: {{.School.Name}}{{with .Child}} ({{gradeName .Grade}}){{end}}
+
+Hello{{with .Recipient.Name}} {{.}}{{end}},
+
+We're considering {{.School.Name}} for {{with .Child}}{{.Name}}, who will be entering {{gradeName .Grade}}{{else}}our child{{end}}, and would like to learn how to enroll.
+{{- if .Questions}}
+
+Could you help with a few questions?
+{{range $i, $q := .Questions}}
+{{inc $i}}. {{$q.Question}}
+{{- end}}
+{{- end}}
+
+Thank you,
+[Your name]
+`,
+	"tour": `Subject: Tour request: {{.School.Name}}
+
+Hello{{with .Recipient.Name}} {{.}}{{end}},
+
+We'd like to visit {{.School.Name}}{{with .Child}} as we look at schools for {{.Name}}, who will be entering {{gradeName .Grade}}{{end}}. Are there tours or open houses coming up, and how do we sign up?
+{{- if .Questions}}
+
+While we're there, we'd love to ask about:
+{{range .Questions}}
+- {{.Question}}
+{{- end}}
+{{- end}}
+
+Thank you,
+[Your name]
+`,
+	"transfer": `Subject: Mid-year transfer: {{.School.Name}}
+
+Hello{{with .Recipient.Name}} {{.}}{{end}},
+
+We're moving to the area and would like to transfer {{with .Child}}{{.Name}} ({{gradeName .Grade}}){{else}}our child{{end}} to {{.School.Name}}. What documents do you need, and is there space in {{with .Child}}{{gradeName .Grade}}{{else}}their grade{{end}}?
+
+Thank you,
+[Your name]
+`,
+}
+
+// inquiryFuncs are the functions inquiry templates can call, besides report templates' own
+var inquiryFuncs = template.FuncMap{
+	"gradeName": gradeName,
+	"inc":       func(i int) int { return i + 1 },
+}
+
+// InquiryData is what an inquiry template is executed with
+type InquiryData struct {
+	School    *School
+	Child     *Child // Nil when no child is chosen
+	Recipient StaffContact
+	Questions []TourQuestion // At most maxInquiryQuestions
+}
+
+// Inquiry is a drafted email to a school's enrollment office
+type Inquiry struct {
+	Template  string
+	To        string // Empty when no email was found
+	ToSource  string // Where the address came from, e.g. "District enrollment office"
+	Recipient StaffContact
+	Subject   string
+	Body      string
+}
+
+// MailtoURL opens the draft in the user's mail client
+func (q *Inquiry) MailtoURL() string {
+	// Mail clients don't decode "+" as a space, so spaces are %20
+	escape := func(s string) string { return strings.ReplaceAll(url.QueryEscape(s), "+", "%20") }
+	return "mailto:" + escape(q.To) + "?subject=" + escape(q.Subject) + "&body=" + escape(strings.ReplaceAll(q.Body, "\n", "\r\n"))
+}
+
+// Text is the draft for pasting into any mail client, headers first
+func (q *Inquiry) Text() string {
+	var b strings.Builder
+	if q.To != "" {
+		b.WriteString("To: " + q.To + "\n")
+	}
+	b.WriteString("Subject: " + q.Subject + "\n\n" + q.Body)
+	return b.String()
+}
+
+// gradeName names a CCD grade in a sentence, e.g. "kindergarten" or "grade 6"
+func gradeName(grade string) string {
+	switch label := gradeLabel(grade); label {
+	case "PK":
+		return "pre-K"
+	case "K":
+		return "kindergarten"
+	default:
+		return "grade " + label
+	}
+}
+
+// LoadInquiryTemplates parses the built-in inquiry templates and those in
+// dataDir, keyed by name
+func LoadInquiryTemplates(dataDir string) (map[string]*template.Template, error) {
+	sources := make(map[string]string, len(builtinInquiryTemplates))
+	for name, text := range builtinInquiryTemplates {
+		sources[name] = text
+	}
+	if dataDir != "" {
+		paths, err := filepath.Glob(filepath.Join(dataDir, inquiryFilePattern))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list inquiry templates: %w", err)
+		}
+		for _, path := range paths {
+			text, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read inquiry template: %w", err)
+			}
+			name := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), "inquiry_"), ".tmpl")
+			sources[name] = string(text)
+		}
+	}
+
+	templates := make(map[string]*template.Template, len(sources))
+	for name, text := range sources {
+		tmpl, err := template.New(name).Funcs(reportFuncs).Funcs(inquiryFuncs).Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse inquiry template %q: %w", name, err)
+		}
+		templates[name] = tmpl
+	}
+	return templates, nil
+}
+
+// inquiryTemplateNames lists template names, the default first
+func inquiryTemplateNames(templates map[string]*template.Template) []string {
+	names := make([]string, 0, len(templates))
+	for name := range templates {
+		if name != defaultInquiryTemplate {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	if _, ok := templates[defaultInquiryTemplate]; ok {
+		names = append([]string{defaultInquiryTemplate}, names...)
+	}
+	return names
+}
+
+// inquiryRecipient picks who an enrollment inquiry goes to: the school's own
+// enrollment or registrar contact, then the district enrollment office, then
+// the school's main office. Either argument may be nil.
+func inquiryRecipient(enhanced *EnhancedSchoolData, district *DistrictContacts) (StaffContact, string) {
+	if enhanced != nil {
+		for _, c := range enhanced.StaffContacts {
+			role := strings.ToLower(c.Title + " " + c.Department)
+			if c.Email != "" && containsAny(role, "enroll", "registrar", "admission") {
+				return c, "School " + strings.ToLower(cmp.Or(c.Title, "enrollment contact"))
+			}
+		}
+	}
+	if district != nil {
+		for _, c := range district.EnrollmentOffice {
+			if c.Email != "" {
+				return c, "District enrollment office"
+			}
+		}
+	}
+	if email := mainOfficeEmail(enhanced); email != "" {
+		return StaffContact{Email: email}, "School main office"
+	}
+	return StaffContact{}, ""
+}
+
+// DraftInquiry fills in an inquiry template for a school. child, enhanced,
+// district, and naep may be nil.
+func DraftInquiry(tmpl *template.Template, school *School, child *Child, enhanced *EnhancedSchoolData, district *DistrictContacts, naep *NAEPData) (*Inquiry, error) {
+	recipient, source := inquiryRecipient(enhanced, district)
+	questions := GenerateTourQuestions(school, enhanced, naep)
+	if len(questions) > maxInquiryQuestions {
+		questions = questions[:maxInquiryQuestions]
+	}
+
+	var buf bytes.Buffer
+	data := InquiryData{School: school, Child: child, Recipient: recipient, Questions: questions}
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("failed to draft inquiry: %w", err)
+	}
+
+	subject, body, _ := strings.Cut(buf.String(), "\n")
+	if rest, ok := strings.CutPrefix(subject, "Subject:"); ok {
+		subject = strings.TrimSpace(rest)
+	} else {
+		subject, body = "Enrollment inquiry: "+school.Name, buf.String()
+	}
+	return &Inquiry{
+		Template:  tmpl.Name(),
+		To:        recipient.Email,
+		ToSource:  source,
+		Recipient: recipient,
+		Subject:   subject,
+		Body:      strings.TrimSpace(body) + "\n",
+	}, nil
+}
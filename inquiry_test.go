@@ -0,0 +1,94 @@
+package main
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDraftInquiry(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	school, err := db.GetSchoolByID("360000100001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	templates, err := LoadInquiryTemplates(db.dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	child := &Child{Name: "Ada", Grade: "KG"}
+	district := &DistrictContacts{LEAID: "0600000", EnrollmentOffice: []StaffContact{{Name: "Educational Placement Center", Email: "epc@sfusd.edu"}}}
+
+	// The school's registrar comes before the district office
+	enhanced := &EnhancedSchoolData{StaffContacts: []StaffContact{
+		{Name: "Pat Lee", Title: "Principal", Email: "lee@sfusd.edu"},
+		{Name: "Sam Ortiz", Title: "Registrar", Email: "ortiz@sfusd.edu"},
+	}}
+	inquiry, err := DraftInquiry(templates["enrollment"], school, child, enhanced, district, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inquiry.To != "ortiz@sfusd.edu" || inquiry.ToSource != "School registrar" {
+		t.Errorf("recipient = %s (%s)", inquiry.To, inquiry.ToSource)
+	}
+	if inquiry.Subject != "Enrollment inquiry: Lincoln Elementary School (kindergarten)" {
+		t.Errorf("subject = %q", inquiry.Subject)
+	}
+	for _, want := range []string{"Hello Sam Ortiz,", "for Ada, who will be entering kindergarten", "1. ", "[Your name]"} {
+		if !strings.Contains(inquiry.Body, want) {
+			t.Errorf("body is missing %q:\n%s", want, inquiry.Body)
+		}
+	}
+	if mailto := inquiry.MailtoURL(); !strings.HasPrefix(mailto, "mailto:ortiz%40sfusd.edu?subject=Enrollment%20inquiry%3A%20Lincoln") || strings.Contains(mailto, "+") || !strings.Contains(mailto, "%0D%0A") {
+		t.Errorf("MailtoURL() = %s", mailto)
+	}
+
+	inquiry, err = DraftInquiry(templates["enrollment"], school, nil, nil, district, nil)
+	if err != nil || inquiry.To != "epc@sfusd.edu" || !strings.Contains(inquiry.Body, "for our child") {
+		t.Errorf("without school contacts or a child = %+v, %v", inquiry, err)
+	}
+
+	// A template file in the data directory replaces the built-in one
+	custom := "Subject: Hi from {{.School.City}}\n\nGrade {{with .Child}}{{.GradeLabel}}{{end}}, please.\n"
+	if err := os.WriteFile(filepath.Join(db.dataDir, "inquiry_tour.tmpl"), []byte(custom), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if templates, err = LoadInquiryTemplates(db.dataDir); err != nil {
+		t.Fatal(err)
+	}
+	inquiry, err = DraftInquiry(templates["tour"], school, child, nil, nil, nil)
+	if err != nil || inquiry.Subject != "Hi from San Francisco" || inquiry.Body != "Grade K, please.\n" || inquiry.To != "" {
+		t.Errorf("custom template = %+v, %v", inquiry, err)
+	}
+
+	// The web draft picks the first child the school serves
+	if err := SaveChild(db, &Child{Name: "Ben", Grade: "10"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveChild(db, child); err != nil {
+		t.Fatal(err)
+	}
+	district.ExtractedAt = time.Now()
+	if err := db.SaveDistrictContacts(district); err != nil {
+		t.Fatal(err)
+	}
+	router := NewRouter(ServerConfig{DB: db})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/schools/360000100001/inquiry", nil))
+	body := rec.Body.String()
+	for _, want := range []string{`href="mailto:epc%40sfusd.edu?subject=`, "for Ada, who will be entering kindergarten", `data-copy="To: epc@sfusd.edu`, `<option value="tour"`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("inquiry draft is missing %q", want)
+		}
+	}
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/schools/360000100001/inquiry?template=nope", nil))
+	if rec.Code != 400 {
+		t.Errorf("unknown template: status %d, want 400", rec.Code)
+	}
+}
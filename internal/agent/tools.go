@@ -248,80 +248,45 @@ func createToolForCommand(
 				}
 				defer cleanup()
 
-				// Get list of all tables from database using DuckDB's SHOW ALL TABLES
-				tablesQuery := "SHOW ALL TABLES"
-				tableRows, err := db.ExecuteQuery(tablesQuery)
+				// Every table's columns, with the data dictionary's descriptions
+				// from the table and column comments
+				rows, err := db.ExecuteQuery(`
+					SELECT t.table_name, COALESCE(t.comment, '') AS table_comment, c.column_name, c.data_type,
+						CASE WHEN c.is_nullable THEN 'YES' ELSE 'NO' END AS nullable, COALESCE(c.comment, '') AS comment
+					FROM duckdb_tables() t
+					JOIN duckdb_columns() c ON c.table_oid = t.table_oid
+					WHERE t.schema_name = 'main' AND NOT t.internal
+					ORDER BY t.table_name, c.column_index`)
 				if err != nil {
-					return fantasy.NewTextErrorResponse(fmt.Sprintf("failed to get table list: %v", err)), nil
+					return fantasy.NewTextErrorResponse(fmt.Sprintf("failed to get schema: %v", err)), nil
 				}
 
-				// Extract table names from SHOW ALL TABLES result
-				var tables []string
-				for _, row := range tableRows {
-					if tableName, ok := row["table_name"].(string); ok {
-						// Skip internal/system tables
-						if tableName != "fts_main_directory" && tableName != "fts_main_directory_docs" && tableName != "fts_main_directory_config" {
-							tables = append(tables, tableName)
-						}
-					} else if name, ok := row["name"].(string); ok {
-						// Some versions use 'name' instead of 'table_name'
-						if name != "fts_main_directory" && name != "fts_main_directory_docs" && name != "fts_main_directory_config" {
-							tables = append(tables, name)
-						}
-					}
+				type ColumnOutput struct {
+					Name        string `json:"name"`
+					Type        string `json:"type"`
+					Nullable    string `json:"nullable"`
+					Description string `json:"description,omitempty"`
 				}
 				type SchemaOutput struct {
-					TableName   string `json:"table_name"`
-					ColumnCount int    `json:"column_count"`
-					Columns     []struct {
-						Name     string `json:"name"`
-						Type     string `json:"type"`
-						Nullable string `json:"nullable"`
-					} `json:"columns"`
+					TableName   string         `json:"table_name"`
+					Description string         `json:"description,omitempty"`
+					ColumnCount int            `json:"column_count"`
+					Columns     []ColumnOutput `json:"columns"`
 				}
-				schemas := make([]SchemaOutput, 0, len(tables))
-
-				for _, tableName := range tables {
-					query := fmt.Sprintf("PRAGMA table_info('%s')", tableName)
-					rows, err := db.ExecuteQuery(query)
-					if err != nil {
-						// Skip tables that don't exist
-						continue
-					}
-
-					schema := SchemaOutput{
-						TableName: tableName,
-						Columns: make([]struct {
-							Name     string `json:"name"`
-							Type     string `json:"type"`
-							Nullable string `json:"nullable"`
-						}, 0),
+				var schemas []SchemaOutput
+				for _, row := range rows {
+					name := fmt.Sprint(row["table_name"])
+					if len(schemas) == 0 || schemas[len(schemas)-1].TableName != name {
+						schemas = append(schemas, SchemaOutput{TableName: name, Description: fmt.Sprint(row["table_comment"])})
 					}
-
-					for _, row := range rows {
-						name, _ := row["name"].(string)
-						colType, _ := row["type"].(string)
-						notnull := row["notnull"]
-
-						nullable := "YES"
-						if fmt.Sprintf("%v", notnull) == "1" {
-							nullable = "NO"
-						}
-
-						col := struct {
-							Name     string `json:"name"`
-							Type     string `json:"type"`
-							Nullable string `json:"nullable"`
-						}{
-							Name:     name,
-							Type:     colType,
-							Nullable: nullable,
-						}
-						schema.Columns = append(schema.Columns, col)
-					}
-
+					schema := &schemas[len(schemas)-1]
+					schema.Columns = append(schema.Columns, ColumnOutput{
+						Name:        fmt.Sprint(row["column_name"]),
+						Type:        fmt.Sprint(row["data_type"]),
+						Nullable:    fmt.Sprint(row["nullable"]),
+						Description: fmt.Sprint(row["comment"]),
+					})
 					schema.ColumnCount = len(schema.Columns)
-					schemas = append(schemas, schema)
 				}
 
 				// Convert result to JSON
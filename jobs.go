@@ -0,0 +1,308 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+)
+
+// Long-running work, such as a bulk save that scrapes every result, is recorded
+// in the jobs table as it goes, one job_items row per school, so a run cut
+// short by a crash or Ctrl+C can be resumed with "schoolfinder jobs resume".
+// Each item is marked running before its work starts and done or failed
+// after, so a resumed job redoes only what was queued or in progress.
+
+// Job and job item statuses
+const (
+	jobQueued  = "queued"
+	jobRunning = "running"
+	jobDone    = "done"
+	jobFailed  = "failed"
+)
+
+// jobBulkSave is the kind of job SaveDossiers records
+const jobBulkSave = "bulk_save"
+
+// Job is a recorded long-running job and how far it got
+type Job struct {
+	ID        int64     `json:"id"`
+	Kind      string    `json:"kind"`
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	Summary   string    `json:"summary"` // What the job does, e.g. where a bulk save writes
+	Total     int       `json:"total"`
+	Done      int       `json:"done"`
+	Failed    int       `json:"failed"`
+	Pending   int       `json:"pending"` // Items queued or interrupted while running
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Resumable reports whether the job stopped before finishing its items or
+// writing its results
+func (j *Job) Resumable() bool {
+	return j.Status != jobDone
+}
+
+// bulkSaveJobOptions are the BulkSaveOptions a bulk save job is recorded
+// with, enough to run the rest of it again
+type bulkSaveJobOptions struct {
+	Dir          string `json:"dir"`
+	Markdown     bool   `json:"markdown,omitempty"`
+	TemplatePath string `json:"template_path,omitempty"`
+	FetchNAEP    bool   `json:"fetch_naep,omitempty"`
+	FetchAI      bool   `json:"fetch_ai,omitempty"`
+	Concurrency  int    `json:"concurrency,omitempty"`
+	Search       string `json:"search,omitempty"`
+}
+
+// startBulkSaveJob records a bulk save of schools, with every school queued,
+// in one transaction. Paths are recorded absolute, so the job can be resumed
+// from any directory.
+func (d *DB) startBulkSaveJob(schools []School, opts BulkSaveOptions) (int64, error) {
+	dir, err := filepath.Abs(opts.Dir)
+	if err != nil {
+		return 0, err
+	}
+	templatePath := opts.TemplatePath
+	if templatePath != "" {
+		if templatePath, err = filepath.Abs(templatePath); err != nil {
+			return 0, err
+		}
+	}
+	options, err := json.Marshal(bulkSaveJobOptions{
+		Dir:          dir,
+		Markdown:     opts.Markdown,
+		TemplatePath: templatePath,
+		FetchNAEP:    opts.FetchNAEP,
+		FetchAI:      opts.FetchAI,
+		Concurrency:  opts.Concurrency,
+		Search:       opts.Search,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := d.conn.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var id int64
+	if err := tx.QueryRow(`INSERT INTO jobs (kind, status, options) VALUES ($1, $2, $3) RETURNING id`,
+		jobBulkSave, jobRunning, string(options)).Scan(&id); err != nil {
+		return 0, fmt.Errorf("failed to record job: %w", err)
+	}
+	for i, school := range schools {
+		if _, err := tx.Exec(`INSERT INTO job_items (job_id, position, item_key, status) VALUES ($1, $2, $3, $4)`,
+			id, i, school.NCESSCH, jobQueued); err != nil {
+			return 0, fmt.Errorf("failed to record job item: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to record job: %w", err)
+	}
+	return id, nil
+}
+
+// setJobItemRunning marks a job item as started, before its work begins
+func (d *DB) setJobItemRunning(jobID int64, position int) error {
+	_, err := d.conn.Exec(`UPDATE job_items SET status = $1, error = NULL, updated_at = now() WHERE job_id = $2 AND position = $3`,
+		jobRunning, jobID, position)
+	if err != nil {
+		return fmt.Errorf("failed to update job item: %w", err)
+	}
+	return nil
+}
+
+// finishJobItem records a job item's result: done, or failed with errText
+func (d *DB) finishJobItem(jobID int64, position int, result any, errText string) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	status := jobDone
+	if errText != "" {
+		status = jobFailed
+	}
+	_, err = d.conn.Exec(`UPDATE job_items SET status = $1, error = $2, result = $3, updated_at = now() WHERE job_id = $4 AND position = $5`,
+		status, sql.NullString{String: errText, Valid: errText != ""}, string(data), jobID, position)
+	if err != nil {
+		return fmt.Errorf("failed to update job item: %w", err)
+	}
+	return nil
+}
+
+// finishJob records that a job ran to the end, or failed with err
+func (d *DB) finishJob(id int64, err error) error {
+	status, errText := jobDone, sql.NullString{}
+	if err != nil {
+		status, errText = jobFailed, sql.NullString{String: err.Error(), Valid: true}
+	}
+	if _, err := d.conn.Exec(`UPDATE jobs SET status = $1, error = $2, updated_at = now() WHERE id = $3`, status, errText, id); err != nil {
+		return fmt.Errorf("failed to update job: %w", err)
+	}
+	return nil
+}
+
+// restartJob marks a job as running again for a resume
+func (d *DB) restartJob(id int64) error {
+	if _, err := d.conn.Exec(`UPDATE jobs SET status = $1, error = NULL, updated_at = now() WHERE id = $2`, jobRunning, id); err != nil {
+		return fmt.Errorf("failed to update job: %w", err)
+	}
+	return nil
+}
+
+// Jobs lists recorded jobs, most recent first, with their items' progress
+func (d *DB) Jobs() ([]Job, error) {
+	rows, err := d.conn.Query(`
+		SELECT j.id, j.kind, j.status, COALESCE(j.error, ''), COALESCE(j.options::VARCHAR, '{}'),
+			COUNT(i.position), COUNT(*) FILTER (WHERE i.status = 'done'), COUNT(*) FILTER (WHERE i.status = 'failed'),
+			j.created_at, GREATEST(j.updated_at, COALESCE(MAX(i.updated_at), j.updated_at))
+		FROM jobs j LEFT JOIN job_items i ON i.job_id = j.id
+		GROUP BY j.id, j.kind, j.status, j.error, j.options::VARCHAR, j.created_at, j.updated_at
+		ORDER BY j.id DESC
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []Job
+	for rows.Next() {
+		var j Job
+		var options string
+		if err := rows.Scan(&j.ID, &j.Kind, &j.Status, &j.Error, &options, &j.Total, &j.Done, &j.Failed, &j.CreatedAt, &j.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan job: %w", err)
+		}
+		j.Pending = j.Total - j.Done - j.Failed
+		j.Summary = jobSummary(j.Kind, options)
+		jobs = append(jobs, j)
+	}
+	return jobs, rows.Err()
+}
+
+// jobSummary describes a job from its recorded options
+func jobSummary(kind, options string) string {
+	if kind != jobBulkSave {
+		return kind
+	}
+	var opts bulkSaveJobOptions
+	if err := json.Unmarshal([]byte(options), &opts); err != nil {
+		return kind
+	}
+	summary := "Save dossiers to " + opts.Dir
+	if opts.Search != "" {
+		summary += fmt.Sprintf(" for %q", opts.Search)
+	}
+	return summary
+}
+
+// jobItem is one unit of a job's work as recorded
+type jobItem struct {
+	Position int
+	Key      string
+	Status   string
+	Result   string // JSON; empty until the item finishes
+}
+
+// jobItems loads a job's items in order
+func (d *DB) jobItems(id int64) ([]jobItem, error) {
+	rows, err := d.conn.Query(`SELECT position, item_key, status, COALESCE(result::VARCHAR, '') FROM job_items WHERE job_id = $1 ORDER BY position`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load job items: %w", err)
+	}
+	defer rows.Close()
+
+	var items []jobItem
+	for rows.Next() {
+		var item jobItem
+		if err := rows.Scan(&item.Position, &item.Key, &item.Status, &item.Result); err != nil {
+			return nil, fmt.Errorf("failed to scan job item: %w", err)
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// ResumeBulkSave continues a bulk save job that stopped before finishing,
+// saving the schools that were queued or in progress when it stopped, and
+// rewrites the manifest with every school's result. Schools that failed are
+// tried again only if retryFailed is set. It returns the manifest and the
+// directory the job saves to.
+func ResumeBulkSave(ctx context.Context, db *DB, ai *AIScraperService, naep *NAEPClient, id int64, retryFailed bool) (*BulkSaveManifest, string, error) {
+	var kind, status, options string
+	err := db.conn.QueryRow(`SELECT kind, status, COALESCE(options::VARCHAR, '{}') FROM jobs WHERE id = $1`, id).Scan(&kind, &status, &options)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, "", fmt.Errorf("no job with ID %d: %w", id, err)
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to load job: %w", err)
+	}
+	if kind != jobBulkSave {
+		return nil, "", fmt.Errorf("job %d is a %s job, which can't be resumed", id, kind)
+	}
+	if status == jobDone && !retryFailed {
+		return nil, "", fmt.Errorf("job %d already finished", id)
+	}
+
+	var recorded bulkSaveJobOptions
+	if err := json.Unmarshal([]byte(options), &recorded); err != nil {
+		return nil, "", fmt.Errorf("job %d has unreadable options: %w", id, err)
+	}
+	opts := BulkSaveOptions{
+		Dir:          recorded.Dir,
+		Markdown:     recorded.Markdown,
+		TemplatePath: recorded.TemplatePath,
+		FetchNAEP:    recorded.FetchNAEP,
+		FetchAI:      recorded.FetchAI,
+		Concurrency:  recorded.Concurrency,
+		Search:       recorded.Search,
+	}
+	if opts.FetchAI && ai == nil {
+		return nil, "", ErrAINotConfigured
+	}
+	if opts.TemplatePath != "" {
+		if opts.Template, err = LoadReportTemplate(opts.TemplatePath); err != nil {
+			return nil, "", err
+		}
+	}
+
+	items, err := db.jobItems(id)
+	if err != nil {
+		return nil, "", err
+	}
+	schools := make([]School, len(items))
+	entries := make([]*BulkSaveEntry, len(items))
+	for i, item := range items {
+		finished := item.Status == jobDone || (item.Status == jobFailed && !retryFailed)
+		if finished && item.Result != "" {
+			var entry BulkSaveEntry
+			if err := json.Unmarshal([]byte(item.Result), &entry); err == nil {
+				entries[i] = &entry
+				continue
+			}
+		}
+		school, err := db.GetSchoolByID(item.Key)
+		if err != nil {
+			// A school no longer in the directory fails rather than stopping the rest
+			entries[i] = &BulkSaveEntry{NCESSCH: item.Key, AIData: bulkDataNone, NAEPData: bulkDataNone, Errors: []string{errorText(err)}}
+			if err := db.finishJobItem(id, item.Position, entries[i], errorText(err)); err != nil {
+				return nil, "", err
+			}
+			continue
+		}
+		schools[i] = *school
+	}
+
+	if err := db.restartJob(id); err != nil {
+		return nil, "", err
+	}
+	manifest, err := runBulkSave(ctx, db, ai, naep, id, schools, entries, opts)
+	return manifest, opts.Dir, err
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBulkSaveJob(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	schools, err := db.SearchSchools("School", "", 3)
+	if err != nil || len(schools) != 3 {
+		t.Fatalf("schools = %d, %v", len(schools), err)
+	}
+
+	// A finished save is recorded as a done job
+	dir := filepath.Join(t.TempDir(), "out")
+	if _, err := SaveDossiers(context.Background(), db, nil, nil, schools, BulkSaveOptions{Dir: dir, Search: "School"}); err != nil {
+		t.Fatal(err)
+	}
+	jobs, err := db.Jobs()
+	if err != nil || len(jobs) != 1 {
+		t.Fatalf("jobs = %+v, %v", jobs, err)
+	}
+	if j := jobs[0]; j.Status != jobDone || j.Total != 3 || j.Done != 3 || j.Pending != 0 || j.Resumable() || j.Summary != `Save dossiers to `+dir+` for "School"` {
+		t.Errorf("finished job = %+v", j)
+	}
+	if _, _, err := ResumeBulkSave(context.Background(), db, nil, nil, jobs[0].ID, false); err == nil {
+		t.Error("resumed a finished job")
+	}
+
+	// A crash leaves the job running, with one school saved, one in progress,
+	// one queued, and one no longer in the directory
+	crashed := filepath.Join(t.TempDir(), "crashed")
+	gone := School{NCESSCH: "999999999999", Name: "Closed School"}
+	id, err := db.startBulkSaveJob(append(schools, gone), BulkSaveOptions{Dir: crashed, Markdown: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(crashed, 0755); err != nil {
+		t.Fatal(err)
+	}
+	saved := saveDossier(context.Background(), db, nil, nil, &schools[0], BulkSaveOptions{Dir: crashed, Markdown: true})
+	if err := db.finishJobItem(id, 0, saved, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.setJobItemRunning(id, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(crashed, saved.File)); err != nil {
+		t.Fatal(err)
+	}
+
+	manifest, resumedDir, err := ResumeBulkSave(context.Background(), db, nil, nil, id, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resumedDir != crashed || manifest.Format != "markdown" || manifest.Saved != 3 || manifest.Failed != 1 {
+		t.Fatalf("resumed into %s: %+v", resumedDir, manifest)
+	}
+	// The school saved before the crash wasn't saved again
+	if _, err := os.Stat(filepath.Join(crashed, saved.File)); !os.IsNotExist(err) {
+		t.Errorf("resume redid a saved school: %v", err)
+	}
+	for _, entry := range manifest.Schools[1:3] {
+		if _, err := os.Stat(filepath.Join(crashed, entry.File)); err != nil {
+			t.Errorf("%s wasn't saved: %v", entry.NCESSCH, err)
+		}
+	}
+	if entry := manifest.Schools[3]; entry.NCESSCH != gone.NCESSCH || entry.File != "" || len(entry.Errors) == 0 {
+		t.Errorf("missing school = %+v", entry)
+	}
+
+	jobs, err = db.Jobs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if j := jobs[0]; j.ID != id || j.Status != jobDone || j.Done != 3 || j.Failed != 1 || j.Pending != 0 {
+		t.Errorf("resumed job = %+v", j)
+	}
+
+	// Failed schools are tried again only when asked
+	manifest, _, err = ResumeBulkSave(context.Background(), db, nil, nil, id, true)
+	if err != nil || manifest.Failed != 1 {
+		t.Errorf("retry failed = %+v, %v", manifest, err)
+	}
+}
@@ -0,0 +1,383 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// LanguageOffering is a language a school teaches, normalized from its website
+// so "Dual Language Immersion (Spanish)" is Spanish immersion and "AP Spanish
+// Language" is a Spanish course
+type LanguageOffering struct {
+	Language  string `json:"language"`         // Canonical name, e.g. "Mandarin"
+	Immersion bool   `json:"immersion"`        // Taught through immersion or dual-language instruction, not only as a course
+	Grades    string `json:"grades,omitempty"` // Grades the program serves, as published, e.g. "K-5"
+}
+
+// Kind is "immersion" or "course"
+func (o LanguageOffering) Kind() string {
+	if o.Immersion {
+		return "immersion"
+	}
+	return "course"
+}
+
+// Summary describes the offering, e.g. "Mandarin immersion (K-5)"
+func (o LanguageOffering) Summary() string {
+	summary := o.Language + " " + o.Kind()
+	if o.Grades != "" {
+		summary += " (" + o.Grades + ")"
+	}
+	return summary
+}
+
+// canonicalLanguage is a language's canonical name and the words websites use
+// for it. Cantonese comes before Mandarin so "Chinese (Cantonese)" isn't read
+// as Mandarin.
+type canonicalLanguage struct {
+	name    string
+	pattern *regexp.Regexp
+}
+
+func language(name, aliases string) canonicalLanguage {
+	return canonicalLanguage{name: name, pattern: regexp.MustCompile(`(?i)\b(` + aliases + `)`)}
+}
+
+var canonicalLanguages = []canonicalLanguage{
+	language("Spanish", `spanish|español|espanol`),
+	language("Cantonese", `cantonese`),
+	language("Mandarin", `mandarin|chinese|putonghua`),
+	language("French", `french|français`),
+	language("German", `german`),
+	language("Japanese", `japanese`),
+	language("Korean", `korean`),
+	language("Italian", `italian`),
+	language("Portuguese", `portuguese`),
+	language("Russian", `russian`),
+	language("Arabic", `arabic`),
+	language("Hebrew", `hebrew`),
+	language("Vietnamese", `vietnamese`),
+	language("Hmong", `hmong`),
+	language("Tagalog", `tagalog|filipino`),
+	language("Hindi", `hindi`),
+	language("Greek", `greek`),
+	language("Latin", `latin\b`),
+	language("Hawaiian", `hawaiian|olelo`),
+	language("Navajo", `navajo|diné`),
+	language("Ojibwe", `ojibwe|anishinaabemowin`),
+	language("Cherokee", `cherokee`),
+	language("American Sign Language", `american sign language|sign language|asl\b`),
+}
+
+// immersionPattern matches programs taught through a language rather than as
+// a course, e.g. "two-way immersion" or "DLI"
+var immersionPattern = regexp.MustCompile(`(?i)immersion|dual[- ]language|two[- ]way|one[- ]way|bilingual|\bDLI\b`)
+
+// unnamedLanguagePattern matches entries that describe a program without naming
+// its language, e.g. "World languages" or "Dual language immersion"
+var unnamedLanguagePattern = regexp.MustCompile(`(?i)language|immersion|bilingual|\bDLI\b`)
+
+// CanonicalLanguageName returns the canonical name of a language as typed,
+// e.g. "Mandarin" for "chinese"
+func CanonicalLanguageName(text string) (string, bool) {
+	for _, l := range canonicalLanguages {
+		if l.pattern.MatchString(text) {
+			return l.name, true
+		}
+	}
+	return "", false
+}
+
+// languageOfferings normalizes one language entry as a website lists it into
+// an offering per language named. kind is "immersion" or "course", or empty to
+// tell from the name. Entries naming no known language keep their listed name
+// unless they only describe a program.
+func languageOfferings(name, kind, grades string) []LanguageOffering {
+	name = strings.Trim(strings.TrimSpace(name), "*")
+	if name == "" || careUnknown.MatchString(name) || strings.EqualFold(name, "none") {
+		return nil
+	}
+	if grades = strings.TrimSpace(grades); careUnknown.MatchString(grades) {
+		grades = ""
+	}
+	immersion := immersionPattern.MatchString(kind + " " + name)
+
+	var offerings []LanguageOffering
+	for _, l := range canonicalLanguages {
+		if l.pattern.MatchString(name) {
+			offerings = append(offerings, LanguageOffering{Language: l.name, Immersion: immersion, Grades: grades})
+		}
+	}
+	if len(offerings) == 0 && !unnamedLanguagePattern.MatchString(name) {
+		offerings = append(offerings, LanguageOffering{Language: name, Immersion: immersion, Grades: grades})
+	}
+	return offerings
+}
+
+// languageLinePattern matches the language lines the extraction prompt asks
+// for, e.g. "- Language: Mandarin | immersion | K-5", allowing for markdown
+// bold and bullets
+var languageLinePattern = regexp.MustCompile(`(?im)^[\s>*+-]*\**language\**\s*:\**\s*(.+)$`)
+
+// parseLanguagesSection reads the languages taught from the extracted
+// markdown's "World Languages" lines
+func parseLanguagesSection(markdown string) []LanguageOffering {
+	var offerings []LanguageOffering
+	for _, m := range languageLinePattern.FindAllStringSubmatch(markdown, -1) {
+		fields := strings.Split(m[1], "|")
+		for i := range fields {
+			fields[i] = strings.Trim(strings.TrimSpace(fields[i]), "*")
+		}
+		for len(fields) < 3 {
+			fields = append(fields, "")
+		}
+		offerings = append(offerings, languageOfferings(fields[0], fields[1], fields[2])...)
+	}
+	return offerings
+}
+
+// normalizeLanguages merges the extracted language programs with the
+// free-text languages list and any immersion programs listed among the
+// special programs, one offering per language, immersion programs first. A
+// language taught both ways is listed once, as immersion.
+func normalizeLanguages(programs []LanguageOffering, languages, specialPrograms []string) []LanguageOffering {
+	var all []LanguageOffering
+	for _, p := range programs {
+		kind := "course"
+		if p.Immersion {
+			kind = "immersion"
+		}
+		all = append(all, languageOfferings(p.Language, kind, p.Grades)...)
+	}
+	for _, l := range languages {
+		all = append(all, languageOfferings(l, "", "")...)
+	}
+	for _, p := range specialPrograms {
+		if immersionPattern.MatchString(p) {
+			for _, o := range languageOfferings(p, "", "") {
+				if _, ok := CanonicalLanguageName(o.Language); ok {
+					all = append(all, o)
+				}
+			}
+		}
+	}
+
+	var offerings []LanguageOffering
+	for _, o := range all {
+		i := slices.IndexFunc(offerings, func(other LanguageOffering) bool { return other.Language == o.Language })
+		switch {
+		case i < 0:
+			offerings = append(offerings, o)
+		case o.Immersion && !offerings[i].Immersion:
+			offerings[i] = o
+		case o.Immersion == offerings[i].Immersion && offerings[i].Grades == "":
+			offerings[i].Grades = o.Grades
+		}
+	}
+	slices.SortStableFunc(offerings, func(a, b LanguageOffering) int {
+		if a.Immersion != b.Immersion {
+			if a.Immersion {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.Language, b.Language)
+	})
+	return offerings
+}
+
+// LanguageOfferings returns the school's languages, normalized and flagged as
+// immersion or course
+func (data *EnhancedSchoolData) LanguageOfferings() []LanguageOffering {
+	return normalizeLanguages(data.LanguagePrograms, data.Languages, data.SpecialPrograms)
+}
+
+// SaveLanguages replaces a school's normalized languages with those in its
+// extracted website data
+func (d *DB) SaveLanguages(data *EnhancedSchoolData) error {
+	tx, err := d.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.Exec(`DELETE FROM school_languages WHERE ncessch = $1`, data.NCESSCH); err != nil {
+		return fmt.Errorf("failed to clear languages: %w", err)
+	}
+	for _, o := range data.LanguageOfferings() {
+		if _, err := tx.Exec(`INSERT INTO school_languages (ncessch, language, immersion, grades) VALUES ($1, $2, $3, $4)`,
+			data.NCESSCH, o.Language, o.Immersion, o.Grades); err != nil {
+			return fmt.Errorf("failed to save language: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to save languages: %w", err)
+	}
+	return nil
+}
+
+// LanguageNames lists the languages schools teach, most common first, for
+// suggesting language filters
+func (d *DB) LanguageNames() ([]string, error) {
+	rows, err := d.conn.Query(`
+		SELECT language FROM school_languages
+		GROUP BY language
+		ORDER BY count(DISTINCT ncessch) DESC, language
+		LIMIT 50
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list languages: %w", err)
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to scan language: %w", err)
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// languageFilterName returns the language a filter names, canonical where known
+func languageFilterName(term string) string {
+	if name, ok := CanonicalLanguageName(term); ok {
+		return name
+	}
+	return strings.TrimSpace(term)
+}
+
+// LanguageDirectoryQuery narrows the language directory
+type LanguageDirectoryQuery struct {
+	Language string // Canonical language name; empty for all
+	State    string // Two-letter state code; empty for all
+	Level    string // directory.LEVEL, e.g. "Elementary"; empty for all
+	Courses  bool   // Include schools teaching the language only as a course
+}
+
+// LanguageCount is how many schools teach a language, by kind
+type LanguageCount struct {
+	Language  string
+	Immersion int
+	Courses   int
+}
+
+// LanguageSchool is a school teaching a language, for the directory
+type LanguageSchool struct {
+	NCESSCH   string
+	Name      string
+	City      string
+	State     string
+	Level     string
+	GradeLow  string
+	GradeHigh string
+	LanguageOffering
+}
+
+// LanguageDirectory lists the schools teaching languages, with counts by language
+type LanguageDirectory struct {
+	Query   LanguageDirectoryQuery
+	Counts  []LanguageCount  // Every language in the state and level, most immersion schools first
+	Schools []LanguageSchool // Schools matching the query, by state, city, and name
+	Levels  []string         // School levels to browse by
+}
+
+// maxLanguageDirectorySchools caps the directory's school list
+const maxLanguageDirectorySchools = 500
+
+// SearchFilters returns the search matching the directory's query, for
+// opening it on the search page
+func (dir *LanguageDirectory) SearchFilters() SearchFilters {
+	f := SearchFilters{Language: dir.Query.Language, State: dir.Query.State}
+	if !dir.Query.Courses {
+		f.Immersion = "Yes"
+	}
+	return f
+}
+
+// LanguageDirectory lists the schools whose website data shows they teach a
+// language, immersion programs only unless q.Courses is set
+func (d *DB) LanguageDirectory(q LanguageDirectoryQuery) (*LanguageDirectory, error) {
+	dir := &LanguageDirectory{Query: q, Levels: []string{"Elementary", "Middle", "High", "Secondary", "Other"}}
+
+	var where []string
+	var args []interface{}
+	add := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		where = append(where, fmt.Sprintf(condition, len(args)))
+	}
+	if q.State != "" {
+		add("d.ST = $%d", q.State)
+	}
+	if q.Level != "" {
+		add("d.LEVEL = $%d", q.Level)
+	}
+	conditions := ""
+	if len(where) > 0 {
+		conditions = "WHERE " + strings.Join(where, " AND ")
+	}
+
+	rows, err := d.conn.Query(`
+		SELECT l.language,
+			count(DISTINCT l.ncessch) FILTER (WHERE l.immersion),
+			count(DISTINCT l.ncessch) FILTER (WHERE NOT l.immersion)
+		FROM school_languages l
+		JOIN directory d ON d.NCESSCH = l.ncessch
+		`+conditions+`
+		GROUP BY l.language
+		ORDER BY 2 DESC, 3 DESC, l.language
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count languages: %w", err)
+	}
+	for rows.Next() {
+		var c LanguageCount
+		if err := rows.Scan(&c.Language, &c.Immersion, &c.Courses); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan language count: %w", err)
+		}
+		dir.Counts = append(dir.Counts, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to count languages: %w", err)
+	}
+
+	if q.Language != "" {
+		add("l.language = $%d", q.Language)
+	}
+	if !q.Courses {
+		where = append(where, "l.immersion")
+	}
+	conditions = ""
+	if len(where) > 0 {
+		conditions = "WHERE " + strings.Join(where, " AND ")
+	}
+	args = append(args, maxLanguageDirectorySchools)
+	rows, err = d.conn.Query(fmt.Sprintf(`
+		SELECT d.NCESSCH, d.SCH_NAME, COALESCE(d.MCITY, ''), d.ST, COALESCE(d.LEVEL, ''),
+			COALESCE(d.GSLO, ''), COALESCE(d.GSHI, ''), l.language, l.immersion, COALESCE(l.grades, '')
+		FROM school_languages l
+		JOIN directory d ON d.NCESSCH = l.ncessch
+		%s
+		ORDER BY d.ST, d.MCITY, d.SCH_NAME, l.language
+		LIMIT $%d
+	`, conditions, len(args)), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list language programs: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var s LanguageSchool
+		if err := rows.Scan(&s.NCESSCH, &s.Name, &s.City, &s.State, &s.Level, &s.GradeLow, &s.GradeHigh,
+			&s.Language, &s.Immersion, &s.Grades); err != nil {
+			return nil, fmt.Errorf("failed to scan language program: %w", err)
+		}
+		dir.Schools = append(dir.Schools, s)
+	}
+	return dir, rows.Err()
+}
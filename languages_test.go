@@ -0,0 +1,110 @@
+package main
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNormalizeLanguages(t *testing.T) {
+	markdown := `## World Languages
+- **Language:** Mandarin Chinese | immersion | K-5
+- Language: Spanish | course | not published
+- Language: World languages | course | 6-8`
+
+	var got []string
+	for _, o := range normalizeLanguages(parseLanguagesSection(markdown), []string{"AP Spanish Language", "French", "Somali"}, []string{"Spanish Dual Language Immersion", "GATE"}) {
+		got = append(got, o.Summary())
+	}
+	want := []string{
+		"Mandarin immersion (K-5)",
+		"Spanish immersion", // Taught both ways, so listed as immersion
+		"French course",
+		"Somali course", // Not a canonical language, so it keeps its name
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("normalizeLanguages() =\n%q\nwant\n%q", got, want)
+	}
+
+	if name, ok := CanonicalLanguageName("Chinese (Cantonese)"); !ok || name != "Cantonese" {
+		t.Errorf("CanonicalLanguageName(Chinese (Cantonese)) = %q, %v", name, ok)
+	}
+}
+
+func TestLanguageDirectory(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	// Lincoln Elementary and Washington High are in CA, Roosevelt Charter School in NY
+	for id, website := range map[string]*EnhancedSchoolData{
+		"360000100001": {MarkdownContent: "- Language: Mandarin | immersion | K-5\n- Language: Spanish | course"},
+		"360000100002": {Languages: []string{"Mandarin", "French"}},
+		"360000100004": {SpecialPrograms: []string{"Two-way Spanish immersion"}},
+	} {
+		website.NCESSCH, website.ExtractedAt = id, time.Now()
+		if err := saveEnhancedData(db, website); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	search := func(query string) string {
+		t.Helper()
+		filters, err := ParseSearchQuery(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		schools, err := db.SearchSchoolsFiltered(filters, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, s := range schools {
+			ids = append(ids, s.NCESSCH[len(s.NCESSCH)-2:])
+		}
+		slices.Sort(ids)
+		return strings.Join(ids, ",")
+	}
+	for query, want := range map[string]string{
+		"language:chinese":               "01,02",
+		"immersion:mandarin":             "01",
+		"language:spanish immersion:yes": "04",
+		"immersion:yes state:CA":         "01",
+	} {
+		if got := search(query); got != want {
+			t.Errorf("%s = %s, want %s", query, got, want)
+		}
+	}
+	if _, err := ParseSearchQuery("immersion:mandarin language:spanish"); err == nil {
+		t.Error("two languages were accepted")
+	}
+
+	filters, err := SearchFiltersFromValues(url.Values{"language": {"mandarin"}, "immersion": {"Yes"}, "state": {"CA"}})
+	if err != nil || filters.Summary() != "Mandarin immersion, CA" || filters.DrawerFilterCount() != 1 {
+		t.Errorf("filters = %+v (%q), %v", filters, filters.Summary(), err)
+	}
+
+	dir, err := db.LanguageDirectory(LanguageDirectoryQuery{Language: "Mandarin", State: "CA", Level: "Elementary"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dir.Schools) != 1 || dir.Schools[0].NCESSCH != "360000100001" || dir.Schools[0].Grades != "K-5" {
+		t.Errorf("Mandarin immersion elementary schools in CA = %+v", dir.Schools)
+	}
+	if len(dir.Counts) != 2 || dir.Counts[0] != (LanguageCount{Language: "Mandarin", Immersion: 1}) {
+		t.Errorf("counts = %+v", dir.Counts)
+	}
+
+	// The directory page links each program to its school and the search page
+	router := NewRouter(ServerConfig{DB: db})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/languages?language=spanish&courses=1", nil))
+	body := rec.Body.String()
+	for _, want := range []string{"Roosevelt Charter School", "Lincoln Elementary", `href="/?language=Spanish"`, "2 Spanish programs"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("directory page is missing %q", want)
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// Lookup limits and thresholds. Candidates scoring under minLookupScore
+// aren't returned; a match at or above lookupHighConfidence can be trusted
+// without asking the user.
+const (
+	maxLookupCandidates  = 200 // Schools the SQL prefilter hands to scoring
+	maxLookupResults     = 5
+	minLookupScore       = 0.6
+	lookupHighConfidence = 0.9
+	lookupMediumScore    = 0.75
+)
+
+// Lookup score weights. The name carries the score; a matching city breaks
+// ties between same-named schools, and a matching website host is nearly
+// conclusive.
+const (
+	lookupNameWeight    = 0.8
+	lookupCityWeight    = 0.2
+	lookupWebsiteWeight = 0.8 // Share of the remaining gap a website match closes
+)
+
+// lookupGenericWords are too common in school names to find candidates by
+var lookupGenericWords = map[string]bool{
+	"elementary": true, "middle": true, "high": true, "junior": true, "senior": true,
+	"academy": true, "charter": true, "public": true, "primary": true, "intermediate": true,
+	"of": true, "and": true, "at": true, "for": true, "st": true,
+}
+
+// lookupAbbreviations expand the abbreviations listings use in school names
+var lookupAbbreviations = map[string]string{
+	"elem": "elementary", "es": "elementary", "ms": "middle", "hs": "high",
+	"jr": "junior", "sr": "senior", "acad": "academy", "intl": "international",
+	"prep": "preparatory", "ctr": "center", "mt": "mount",
+}
+
+// LookupQuery is what a browser extension knows about a school from the page
+// it's on: a school's own website or a realty listing
+type LookupQuery struct {
+	Name    string
+	City    string
+	State   string // Two-letter code or full name
+	Website string // The page's URL or host, matched against directory websites
+}
+
+// LookupCandidate is a directory school scored against a LookupQuery
+type LookupCandidate struct {
+	NCESSCH    string  `json:"ncessch"`
+	Name       string  `json:"name"`
+	City       string  `json:"city"`
+	State      string  `json:"state"`
+	Score      float64 `json:"score"`      // 0 to 1
+	Confidence string  `json:"confidence"` // high, medium, or low
+	Website    bool    `json:"website_match"`
+	PageURL    string  `json:"page_url,omitempty"`
+	BundleURL  string  `json:"bundle_url,omitempty"`
+}
+
+// LookupSchool finds the directory schools query most likely refers to, best
+// first. Candidates come from SQL by state and distinctive name words or the
+// website host, website and city matches and then the most similar names
+// first, so the same schools make the cut every time; scoring happens here so
+// clients get the same answer everywhere.
+func (d *DB) LookupSchool(query LookupQuery) ([]LookupCandidate, error) {
+	key := lookupNameKey(query.Name)
+	host := lookupHost(query.Website)
+	if key == "" && host == "" {
+		return nil, nil
+	}
+
+	var conditions []string
+	args := []interface{}{strings.TrimSpace(query.State), strings.TrimSpace(query.City), key, host}
+	for _, word := range lookupWords(key) {
+		args = append(args, word)
+		conditions = append(conditions, fmt.Sprintf("strpos(lower(d.SCH_NAME), $%d) > 0", len(args)))
+	}
+	if host != "" {
+		conditions = append(conditions, "strpos(lower(COALESCE(d.WEBSITE, '')), $4) > 0")
+	}
+	rows, err := d.conn.Query(fmt.Sprintf(`
+		SELECT d.NCESSCH, d.SCH_NAME, COALESCE(d.MCITY, ''), COALESCE(d.ST, ''), COALESCE(d.WEBSITE, '')
+		FROM directory d
+		WHERE %s AND ($1 = '' OR d.ST = upper($1) OR lower(d.STATENAME) = lower($1)) AND (%s)
+		ORDER BY $4 <> '' AND strpos(lower(COALESCE(d.WEBSITE, '')), $4) > 0 DESC,
+			$2 <> '' AND lower(d.MCITY) = lower($2) DESC,
+			jaro_winkler_similarity(lower(d.SCH_NAME), $3) DESC,
+			d.NCESSCH
+		LIMIT %d
+	`, notMergedCondition, strings.Join(conditions, " OR "), maxLookupCandidates), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up school: %w", err)
+	}
+	defer rows.Close()
+
+	var candidates []LookupCandidate
+	for rows.Next() {
+		var c LookupCandidate
+		var website string
+		if err := rows.Scan(&c.NCESSCH, &c.Name, &c.City, &c.State, &website); err != nil {
+			return nil, fmt.Errorf("failed to scan school: %w", err)
+		}
+		c.Website = host != "" && lookupHost(website) == host
+		c.Score = scoreLookup(query, key, c.Name, c.City, c.Website)
+		if c.Score < minLookupScore {
+			continue
+		}
+		c.Confidence = lookupConfidence(c.Score)
+		candidates = append(candidates, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	slices.SortStableFunc(candidates, func(a, b LookupCandidate) int {
+		if a.Score != b.Score {
+			if a.Score > b.Score {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.NCESSCH, b.NCESSCH)
+	})
+	if len(candidates) > maxLookupResults {
+		candidates = candidates[:maxLookupResults]
+	}
+	return candidates, nil
+}
+
+// scoreLookup scores a school against the query from 0 to 1: name similarity,
+// weighed with the city when the query has one, then raised by a website
+// match. Without a name, only a website match counts.
+func scoreLookup(query LookupQuery, key, name, city string, websiteMatch bool) float64 {
+	score := 0.0
+	switch {
+	case key != "":
+		score = nameSimilarity(key, lookupNameKey(name))
+	case websiteMatch:
+		score = 1
+	}
+	if want := strings.ToLower(strings.TrimSpace(query.City)); want != "" {
+		cityScore := 0.0
+		if got := strings.ToLower(city); got == want {
+			cityScore = 1
+		} else if jw := jaroWinkler(got, want); jw >= 0.9 {
+			cityScore = jw
+		}
+		score = lookupNameWeight*score + lookupCityWeight*cityScore
+	}
+	if websiteMatch {
+		score += (1 - score) * lookupWebsiteWeight
+	}
+	return score
+}
+
+// lookupNameKey is schoolNameKey with abbreviations spelled out, so
+// "Lincoln Elem." and "Lincoln Elementary School" have the same key
+func lookupNameKey(name string) string {
+	words := strings.Fields(schoolNameKey(name))
+	for i, w := range words {
+		if full, ok := lookupAbbreviations[w]; ok {
+			words[i] = full
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// nameSimilarity compares two school name keys: the better of their
+// Jaro-Winkler similarity and the share of words they have in common, so
+// "lincoln elementary" and "abraham lincoln elementary" still match
+func nameSimilarity(a, b string) float64 {
+	if a == "" || b == "" {
+		return 0
+	}
+	if a == b {
+		return 1
+	}
+	wordsA, wordsB := strings.Fields(a), strings.Fields(b)
+	common := 0
+	for _, w := range wordsA {
+		if slices.Contains(wordsB, w) {
+			common++
+		}
+	}
+	overlap := float64(2*common) / float64(len(wordsA)+len(wordsB))
+	return max(jaroWinkler(a, b), overlap)
+}
+
+// lookupConfidence labels a score high, medium, or low
+func lookupConfidence(score float64) string {
+	switch {
+	case score >= lookupHighConfidence:
+		return "high"
+	case score >= lookupMediumScore:
+		return "medium"
+	default:
+		return "low"
+	}
+}
+
+// lookupWords are the words of a name key to find candidates by: the
+// distinctive ones, or every word when all are generic
+func lookupWords(key string) []string {
+	var words []string
+	for _, w := range strings.Fields(key) {
+		if len(w) > 2 && !lookupGenericWords[w] {
+			words = append(words, w)
+		}
+	}
+	if len(words) == 0 {
+		return strings.Fields(key)
+	}
+	return words
+}
+
+// lookupHost reduces a URL or bare host to its lowercase host without "www.",
+// e.g. "https://www.Lincoln.sfusd.edu/about" to "lincoln.sfusd.edu"
+func lookupHost(website string) string {
+	website = strings.TrimSpace(website)
+	if website == "" {
+		return ""
+	}
+	if !strings.Contains(website, "://") {
+		website = "https://" + strings.TrimPrefix(website, "//")
+	}
+	u, err := url.Parse(website)
+	if err != nil || !strings.Contains(u.Hostname(), ".") {
+		return ""
+	}
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
+
+// jaroWinkler is the Jaro-Winkler similarity of two strings, 0 to 1, like
+// DuckDB's jaro_winkler_similarity
+func jaroWinkler(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) == 0 || len(rb) == 0 {
+		return 0
+	}
+	window := max(max(len(ra), len(rb))/2-1, 0)
+	matchedA := make([]bool, len(ra))
+	matchedB := make([]bool, len(rb))
+	matches := 0
+	for i := range ra {
+		for j := max(0, i-window); j < min(len(rb), i+window+1); j++ {
+			if !matchedB[j] && ra[i] == rb[j] {
+				matchedA[i], matchedB[j] = true, true
+				matches++
+				break
+			}
+		}
+	}
+	if matches == 0 {
+		return 0
+	}
+	transpositions, j := 0, 0
+	for i := range ra {
+		if !matchedA[i] {
+			continue
+		}
+		for !matchedB[j] {
+			j++
+		}
+		if ra[i] != rb[j] {
+			transpositions++
+		}
+		j++
+	}
+	m := float64(matches)
+	jaro := (m/float64(len(ra)) + m/float64(len(rb)) + (m-float64(transpositions)/2)/m) / 3
+
+	prefix := 0
+	for prefix < 4 && prefix < min(len(ra), len(rb)) && ra[prefix] == rb[prefix] {
+		prefix++
+	}
+	return jaro + float64(prefix)*0.1*(1-jaro)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestJaroWinkler(t *testing.T) {
+	// Textbook values, which DuckDB's jaro_winkler_similarity also gives
+	for _, tt := range []struct {
+		a, b string
+		want float64
+	}{
+		{"martha", "marhta", 0.9611},
+		{"dixon", "dicksonx", 0.8133},
+		{"lincoln", "lincoln", 1},
+		{"abc", "xyz", 0},
+	} {
+		if got := jaroWinkler(tt.a, tt.b); math.Abs(got-tt.want) > 0.0001 {
+			t.Errorf("jaroWinkler(%q, %q) = %.4f, want %.4f", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestLookupSchool(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	for _, tt := range []struct {
+		query LookupQuery
+		want  string
+		conf  string
+	}{
+		{LookupQuery{Name: "Lincoln Elementary", City: "San Francisco", State: "CA"}, "360000100001", "high"},
+		{LookupQuery{Name: "Abraham Lincoln Elem. School", State: "California"}, "360000100001", "medium"},
+		{LookupQuery{Website: "https://www.Washington.lausd.net/about-us"}, "360000100002", "high"},
+		{LookupQuery{Name: "Jefferson Middle", State: "CA"}, "", ""}, // Jefferson Middle is in TX
+	} {
+		got, err := db.LookupSchool(tt.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tt.want == "" {
+			if len(got) != 0 {
+				t.Errorf("LookupSchool(%+v) = %+v, want no match", tt.query, got)
+			}
+			continue
+		}
+		if len(got) == 0 || got[0].NCESSCH != tt.want || got[0].Confidence != tt.conf {
+			t.Errorf("LookupSchool(%+v) = %+v, want %s (%s)", tt.query, got, tt.want, tt.conf)
+		}
+	}
+
+	// With more same-named schools than the prefilter keeps, the one in the
+	// query's city still makes the cut, even stored after all of them
+	if _, err := db.conn.Exec(fmt.Sprintf(`
+		CREATE TEMP TABLE lincoln AS SELECT * FROM directory WHERE NCESSCH = '360000100001';
+		DELETE FROM directory WHERE NCESSCH = '360000100001';
+		INSERT INTO directory (NCESSCH, SCH_NAME, MCITY, ST, STATENAME)
+		SELECT (370000000000 + i)::VARCHAR, 'Lincoln Elementary School', 'Fresno', 'CA', 'CALIFORNIA'
+		FROM range(%d) t(i);
+		INSERT INTO directory SELECT * FROM lincoln;
+	`, maxLookupCandidates+50)); err != nil {
+		t.Fatal(err)
+	}
+	got, err := db.LookupSchool(LookupQuery{Name: "Lincoln Elementary", City: "San Francisco", State: "CA"})
+	if err != nil || len(got) == 0 || got[0].NCESSCH != "360000100001" {
+		t.Errorf("LookupSchool among many Lincolns = %+v, %v", got, err)
+	}
+
+	router := NewRouter(ServerConfig{DB: db})
+	lookup := func(params url.Values) (int, map[string]json.RawMessage) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/lookup?"+params.Encode(), nil))
+		if rec.Header().Get("Access-Control-Allow-Origin") != "*" {
+			t.Error("lookup doesn't allow cross-origin requests")
+		}
+		var body map[string]json.RawMessage
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		return rec.Code, body
+	}
+
+	code, body := lookup(url.Values{"name": {"Roosevelt Charter"}, "city": {"New York"}, "state": {"NY"}})
+	var match LookupCandidate
+	if err := json.Unmarshal(body["match"], &match); code != 200 || err != nil {
+		t.Fatalf("lookup = %d %s", code, body["match"])
+	}
+	if match.NCESSCH != "360000100004" || match.BundleURL != "http://example.com/api/v1/schools/360000100004/bundle" {
+		t.Errorf("match = %+v", match)
+	}
+	if code, _ := lookup(url.Values{"name": {"Nonexistent Academy"}}); code != 404 {
+		t.Errorf("unknown school = %d, want 404", code)
+	}
+	if code, _ := lookup(url.Values{"city": {"Houston"}}); code != 400 {
+		t.Errorf("lookup without a name = %d, want 400", code)
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// lruCache is a fixed-size, in-process cache that evicts the least recently used entry
+// and drops entries older than its TTL. A nil cache is valid and never stores anything,
+// so callers don't need to check whether caching is enabled.
+type lruCache[V any] struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	order   *list.List // Front is most recently used
+	entries map[string]*list.Element
+	hits    int64
+	misses  int64
+}
+
+type lruEntry[V any] struct {
+	key      string
+	value    V
+	storedAt time.Time
+}
+
+// newLRUCache creates a cache holding up to size entries for at most ttl.
+// It returns nil (caching disabled) when size or ttl is not positive.
+func newLRUCache[V any](size int, ttl time.Duration) *lruCache[V] {
+	if size <= 0 || ttl <= 0 {
+		return nil
+	}
+	return &lruCache[V]{
+		size:    size,
+		ttl:     ttl,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// Get returns the value for key if present and not expired
+func (c *lruCache[V]) Get(key string) (V, bool) {
+	var zero V
+	if c == nil {
+		return zero, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		c.misses++
+		return zero, false
+	}
+
+	entry := elem.Value.(*lruEntry[V])
+	if time.Since(entry.storedAt) > c.ttl {
+		c.order.Remove(elem)
+		delete(c.entries, key)
+		c.misses++
+		return zero, false
+	}
+
+	c.order.MoveToFront(elem)
+	c.hits++
+	return entry.value, true
+}
+
+// Put stores value for key, evicting the least recently used entry when full
+func (c *lruCache[V]) Put(key string, value V) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		entry := elem.Value.(*lruEntry[V])
+		entry.value = value
+		entry.storedAt = time.Now()
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&lruEntry[V]{key: key, value: value, storedAt: time.Now()})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruEntry[V]).key)
+	}
+}
+
+// Delete removes key from the cache
+func (c *lruCache[V]) Delete(key string) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		c.order.Remove(elem)
+		delete(c.entries, key)
+	}
+}
+
+// Purge removes every entry
+func (c *lruCache[V]) Purge() {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.order.Init()
+	clear(c.entries)
+}
+
+// Len returns the number of entries, including any that have expired but not been read
+func (c *lruCache[V]) Len() int {
+	if c == nil {
+		return 0
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// Stats returns the number of cache hits and misses so far
+func (c *lruCache[V]) Stats() (hits, misses int64) {
+	if c == nil {
+		return 0, 0
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hits, c.misses
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// TestLRUCache tests eviction order, expiry, and the disabled (nil) cache
+func TestLRUCache(t *testing.T) {
+	cache := newLRUCache[int](2, time.Hour)
+	cache.Put("a", 1)
+	cache.Put("b", 2)
+
+	// Reading "a" makes "b" the least recently used
+	if v, ok := cache.Get("a"); !ok || v != 1 {
+		t.Fatalf("Expected a=1, got %v (found=%v)", v, ok)
+	}
+	cache.Put("c", 3)
+	if _, ok := cache.Get("b"); ok {
+		t.Error("Expected b to be evicted")
+	}
+	if cache.Len() != 2 {
+		t.Errorf("Expected 2 entries, got %d", cache.Len())
+	}
+
+	cache.Delete("a")
+	if _, ok := cache.Get("a"); ok {
+		t.Error("Expected a to be deleted")
+	}
+
+	hits, misses := cache.Stats()
+	if hits != 1 || misses != 2 {
+		t.Errorf("Expected 1 hit and 2 misses, got %d and %d", hits, misses)
+	}
+
+	cache.Purge()
+	if cache.Len() != 0 {
+		t.Errorf("Expected empty cache after purge, got %d", cache.Len())
+	}
+
+	// Entries older than the TTL are not served
+	short := newLRUCache[int](10, time.Millisecond)
+	short.Put("a", 1)
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := short.Get("a"); ok {
+		t.Error("Expected expired entry to be dropped")
+	}
+
+	// Size 0 disables caching
+	disabled := newLRUCache[int](0, time.Hour)
+	disabled.Put("a", 1)
+	if _, ok := disabled.Get("a"); ok {
+		t.Error("Expected disabled cache to store nothing")
+	}
+}
+
+// TestHotCacheInvalidation tests that saving cached data drops in-memory copies and runs hooks
+func TestHotCacheInvalidation(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	const id = "360000100001"
+
+	// School records are served from memory after the first read
+	if _, err := db.GetSchoolByID(id); err != nil {
+		t.Fatalf("Failed to get school: %v", err)
+	}
+	if _, ok := db.schoolCache.Get(id); !ok {
+		t.Error("Expected school to be cached in memory")
+	}
+
+	var invalidated []string
+	db.OnCacheInvalidated(func(ncessch string) {
+		invalidated = append(invalidated, ncessch)
+	})
+
+	old, _ := json.Marshal([]NAEPScore{MockNAEPScore("mathematics", 4, 2019, 238.0, 38.0)})
+	if err := db.SaveNAEPCache(id, "CA", "", old, nil, nil, time.Now()); err != nil {
+		t.Fatalf("Failed to save NAEP cache: %v", err)
+	}
+	if _, _, _, _, _, _, err := db.LoadNAEPCache(id, time.Hour); err != nil {
+		t.Fatalf("Failed to load NAEP cache: %v", err)
+	}
+
+	// A newer save must not be hidden by the in-memory copy
+	updated, _ := json.Marshal([]NAEPScore{MockNAEPScore("mathematics", 4, 2022, 230.0, 30.0)})
+	if err := db.SaveNAEPCache(id, "CA", "", updated, nil, nil, time.Now()); err != nil {
+		t.Fatalf("Failed to save NAEP cache: %v", err)
+	}
+	_, _, stateScores, _, _, _, err := db.LoadNAEPCache(id, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to load NAEP cache: %v", err)
+	}
+	if string(stateScores) != string(updated) {
+		t.Errorf("Expected updated scores, got %s", stateScores)
+	}
+
+	if err := db.DeleteNAEPCache(id); err != nil {
+		t.Fatalf("Failed to delete NAEP cache: %v", err)
+	}
+	if _, _, _, _, _, _, err := db.LoadNAEPCache(id, time.Hour); err == nil {
+		t.Error("Expected no cache entry after delete")
+	}
+
+	if len(invalidated) != 3 {
+		t.Errorf("Expected 3 invalidation hook calls, got %d", len(invalidated))
+	}
+}
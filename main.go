@@ -2,17 +2,22 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"math"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
+	"time"
 
 	"charm.land/fantasy"
-	"github.com/atotto/clipboard"
 	"github.com/charmbracelet/bubbles/list"
 	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/bubbles/viewport"
@@ -25,11 +30,19 @@ import (
 )
 
 const (
-	maxResults = 100
+	maxResults         = 100
+	maxDistrictResults = 10   // Districts shown above school results
+	maxDistrictSchools = 1000 // Schools listed on a district page
+
+	maxAreaSchools      = 1000  // Schools listed on a zip or metro area page
+	maxAreaStatsSchools = 20000 // Schools summarized for a zip or metro area
 )
 
 var logger *slog.Logger
 
+// version is set at release build time with -ldflags
+var version = "dev"
+
 // setupLogger creates and configures the application logger
 func setupLogger(dataDir string) error {
 	logPath := filepath.Join(dataDir, "err.log")
@@ -52,11 +65,22 @@ func setupLogger(dataDir string) error {
 	})
 
 	logger = slog.New(handler)
-	logger.Info("Application started", "version", "1.0", "data_dir", dataDir)
+	logger.Info("Application started", "version", version, "data_dir", dataDir)
 
 	return nil
 }
 
+// Rendered markdown cache limits
+const (
+	markdownCacheSize = 64
+	markdownCacheTTL  = time.Hour
+)
+
+// markdownRenders caches glamour output by width and content, so re-rendering
+// the details, e.g. while the terminal is resized back and forth, doesn't
+// render long AI markdown again
+var markdownRenders = newLRUCache[string](markdownCacheSize, markdownCacheTTL)
+
 // renderMarkdown renders markdown content with glamour for beautiful display
 func renderMarkdown(content string, width int) (string, error) {
 	// Account for borders, padding, and glamour's internal gutter
@@ -68,6 +92,11 @@ func renderMarkdown(content string, width int) (string, error) {
 		renderWidth = 40 // Minimum width for readable content
 	}
 
+	key := fmt.Sprintf("%d:%x", renderWidth, sha256.Sum256([]byte(content)))
+	if rendered, ok := markdownRenders.Get(key); ok {
+		return rendered, nil
+	}
+
 	renderer, err := glamour.NewTermRenderer(
 		glamour.WithAutoStyle(),
 		glamour.WithWordWrap(renderWidth),
@@ -81,6 +110,7 @@ func renderMarkdown(content string, width int) (string, error) {
 		return "", err
 	}
 
+	markdownRenders.Put(key, rendered)
 	return rendered, nil
 }
 
@@ -90,6 +120,9 @@ const (
 	searchView view = iota
 	detailView
 	savePromptView
+	savedSearchesView
+	saveSearchPromptView
+	saveAllPromptView
 )
 
 type model struct {
@@ -103,38 +136,75 @@ type model struct {
 	viewport        viewport.Model
 	aiViewport      viewport.Model // Separate viewport for AI responses
 	stateFilter     string
+	filters         SearchFilters // Grade, charter, and ratio filters from a saved search
+	scope           SearchScope   // Earlier searches drilled down from; the search looks within their results
 	schools         []School
+	trends          map[string]EnrollmentTrend   // Enrollment trends of the search results
+	percentiles     map[string]SchoolPercentiles // Percentiles of the search results
+	quality         map[string]SchoolQuality     // Data-quality flags of the search results
+	resultStats     *ResultStats                 // Charts of every matching school, not just those listed
+	showStats       bool                         // Show the result charts in place of the list
+	staffing        map[string]DistrictStaffing  // Staffing of the result districts, by LEAID
 	list            list.Model
 	selectedItem    *School
 	enhancedData    *EnhancedSchoolData
+	schoolHours     *SchoolHours // The website data's school hours, in the school's time zone
 	naepData        *NAEPData
+	naepCancel      context.CancelFunc // Cancels the in-flight NAEP fetch
+	parentSummary   *ParentSummary
 	width           int
 	height          int
 	err             error
 	loading         bool
 	scrapingAI      bool
 	loadingNAEP     bool
+	summarizing     bool // Generating a parent summary
 	saveSuccess     string
+	shareQR         string        // QR code of the selected school's web page, shown instead of the details
+	provenance      []FieldSource // Where the selected school's values came from, shown instead of the details
+	copyMenu        bool          // Choosing what to copy about the selected school; the next key picks
 	viewportReady   bool
-	aiViewportReady bool // Track AI viewport readiness
-	autoFetchNAEP   bool // Auto-fetch NAEP data when viewing details
-	useAI           bool // Use AI ask mode instead of search
+	detailContent   string // Content last set in the detail viewport
+	resizeSeq       int    // Counts resizes, so only the last one re-renders
+	aiViewportReady bool   // Track AI viewport readiness
+	autoFetchNAEP   bool   // Auto-fetch NAEP data when viewing details
+	naepNote        string // Why NAEP data is unavailable for the selected school
+	useAI           bool   // Use AI ask mode instead of search
 	aiResponse      string
 	askingAI        bool
+	searchNameInput textinput.Model
+	savedSearches   []SavedSearch
+	savedCursor     int             // Selected row in the saved searches view
+	savedNotice     string          // Saved search status, e.g. changes found at startup
+	saveAllInput    textinput.Model // Directory to save every result's dossier to
+	bulkSave        BulkSaveOptions // Format and enrichment chosen for saving every result
+	savingAll       bool
+	releaseChecker  *CCDReleaseChecker // Checks NCES for new CCD releases at startup; nil when off
+	releaseNotice   string             // New CCD releases found at startup
 }
 
 type schoolItem struct {
-	school School
+	school  School
+	alerted bool     // School has an active NAEP decline alert
+	badge   string   // School year change, e.g. "Opened 2023"
+	matched []string // Imported datasets with rows matching the search
 }
 
 func (i schoolItem) Title() string {
-	return i.school.Name
+	title := i.school.Name
+	if i.alerted {
+		title = "⚠ " + title
+	}
+	if i.badge != "" {
+		title += " [" + i.badge + "]"
+	}
+	return title
 }
 
 func (i schoolItem) Description() string {
 	teachers := i.school.TeachersString()
 	enrollment := i.school.EnrollmentString()
-	return fmt.Sprintf("%s, %s | %s | Students: %s | Teachers: %s | %s",
+	desc := fmt.Sprintf("%s, %s | %s | Students: %s | Teachers: %s | %s",
 		i.school.City,
 		i.school.State,
 		i.school.District,
@@ -142,6 +212,10 @@ func (i schoolItem) Description() string {
 		teachers,
 		i.school.NCESSCH,
 	)
+	for _, dataset := range i.matched {
+		desc += fmt.Sprintf(" | Matched your '%s' dataset", dataset)
+	}
+	return desc
 }
 
 func (i schoolItem) FilterValue() string {
@@ -149,23 +223,72 @@ func (i schoolItem) FilterValue() string {
 }
 
 type searchMsg struct {
-	schools []School
+	schools          []School
+	alerted          map[string]bool
+	yearChanges      map[string]SchoolYearChange
+	importedMatches  map[string][]string
+	enrollmentTrends map[string]EnrollmentTrend
+	percentiles      map[string]SchoolPercentiles
+	quality          map[string]SchoolQuality
+	staffing         map[string]DistrictStaffing
+	resultStats      *ResultStats
+	err              error
+}
+
+type savedSearchesMsg struct {
+	searches []SavedSearch
+	err      error
+}
+
+type savedSearchCheckMsg struct {
+	changes []SavedSearchChange
 	err     error
 }
 
+type ccdReleaseCheckMsg struct {
+	check *CCDReleaseCheck
+	err   error
+}
+
+type searchSavedMsg struct {
+	search *SavedSearch
+	err    error
+}
+
 type aiScrapeMsg struct {
 	data *EnhancedSchoolData
 	err  error
 }
 
+type bulkSaveMsg struct {
+	dir      string
+	manifest *BulkSaveManifest
+	err      error
+}
+
 type saveMsg struct {
 	filename string
 	err      error
 }
 
 type naepDataMsg struct {
-	data *NAEPData
-	err  error
+	ncessch string // School the data was fetched for
+	data    *NAEPData
+	err     error
+}
+
+type parentSummaryMsg struct {
+	summary *ParentSummary
+	err     error
+}
+
+// resizeDebounce is how long the terminal must stay one size before long
+// content is re-rendered for it
+const resizeDebounce = 100 * time.Millisecond
+
+// resizeSettledMsg re-renders content for the terminal size once resizing stops
+type resizeSettledMsg struct {
+	seq int // The resize it follows; later resizes supersede it
 }
 
 type askMsg struct {
@@ -180,10 +303,45 @@ func scrapeSchoolWebsite(scraper *AIScraperService, school *School) tea.Cmd {
 	}
 }
 
-func fetchNAEPData(client *NAEPClient, school *School) tea.Cmd {
+func fetchNAEPData(ctx context.Context, client *NAEPClient, school *School) tea.Cmd {
+	return func() tea.Msg {
+		data, err := client.FetchNAEPData(ctx, school)
+		return naepDataMsg{ncessch: school.NCESSCH, data: data, err: err}
+	}
+}
+
+func refreshNAEPData(ctx context.Context, client *NAEPClient, school *School) tea.Cmd {
+	return func() tea.Msg {
+		data, err := client.ForceRefresh(ctx, school)
+		return naepDataMsg{ncessch: school.NCESSCH, data: data, err: err}
+	}
+}
+
+// startNAEPFetch loads NAEP data for the selected school, forcing a refresh from the
+// API if asked. Leaving the detail view cancels it.
+func (m *model) startNAEPFetch(refresh bool) tea.Cmd {
+	ctx, cancel := context.WithCancel(context.Background())
+	m.naepCancel = cancel
+	m.loadingNAEP = true
+	if refresh {
+		return refreshNAEPData(ctx, m.naepClient, m.selectedItem)
+	}
+	return fetchNAEPData(ctx, m.naepClient, m.selectedItem)
+}
+
+// cancelNAEPFetch aborts the selected school's in-flight NAEP fetch, if any
+func (m *model) cancelNAEPFetch() {
+	if m.naepCancel != nil {
+		m.naepCancel()
+		m.naepCancel = nil
+	}
+	m.loadingNAEP = false
+}
+
+func generateParentSummary(scraper *AIScraperService, school *School, enhanced *EnhancedSchoolData, naepData *NAEPData) tea.Cmd {
 	return func() tea.Msg {
-		data, err := client.FetchNAEPData(school)
-		return naepDataMsg{data: data, err: err}
+		summary, err := scraper.GenerateParentSummary(context.Background(), school, enhanced, naepData)
+		return parentSummaryMsg{summary: summary, err: err}
 	}
 }
 
@@ -232,7 +390,6 @@ func openInEditor(data *EnhancedSchoolData, db *DB) tea.Cmd {
 	tmpFile.Close()
 
 	tmpFilename := tmpFile.Name()
-	ncessch := data.NCESSCH
 
 	c := exec.Command(editor, tmpFilename)
 	c.Stdin = os.Stdin
@@ -247,12 +404,14 @@ func openInEditor(data *EnhancedSchoolData, db *DB) tea.Cmd {
 		}
 
 		// Reload the edited data and save to database
-		reloaded, loadErr := loadAndSaveEditedData(ncessch, tmpFilename, db)
+		reloaded, loadErr := loadAndSaveEditedData(data, tmpFilename, db)
 		return aiScrapeMsg{data: reloaded, err: loadErr}
 	})
 }
 
-func loadAndSaveEditedData(ncessch, filename string, db *DB) (*EnhancedSchoolData, error) {
+// loadAndSaveEditedData reads website data edited in $EDITOR, saving it and its
+// changed fields in the edit history
+func loadAndSaveEditedData(before *EnhancedSchoolData, filename string, db *DB) (*EnhancedSchoolData, error) {
 	data, err := os.ReadFile(filename)
 	if err != nil {
 		return nil, err
@@ -265,44 +424,9 @@ func loadAndSaveEditedData(ncessch, filename string, db *DB) (*EnhancedSchoolDat
 
 	// Save the edited data back to the database
 	if db != nil {
-		// Prepare legacy data
-		legacyData := map[string]interface{}{
-			"principal":         enhanced.Principal,
-			"vice_principals":   enhanced.VicePrincipals,
-			"mascot":            enhanced.Mascot,
-			"school_colors":     enhanced.SchoolColors,
-			"founded":           enhanced.Founded,
-			"staff_contacts":    enhanced.StaffContacts,
-			"main_office_email": enhanced.MainOfficeEmail,
-			"main_office_phone": enhanced.MainOfficePhone,
-			"ap_courses":        enhanced.APCourses,
-			"honors":            enhanced.Honors,
-			"special_programs":  enhanced.SpecialPrograms,
-			"languages":         enhanced.Languages,
-			"sports":            enhanced.Sports,
-			"clubs":             enhanced.Clubs,
-			"arts":              enhanced.Arts,
-			"facilities":        enhanced.Facilities,
-			"bell_schedule":     enhanced.BellSchedule,
-			"school_hours":      enhanced.SchoolHours,
-			"achievements":      enhanced.Achievements,
-			"accreditations":    enhanced.Accreditations,
-			"mission":           enhanced.Mission,
-			"notes":             enhanced.Notes,
-		}
-
-		legacyJSON, _ := json.Marshal(legacyData)
-
-		if err := db.SaveAIScraperCache(
-			enhanced.NCESSCH,
-			enhanced.SchoolName,
-			enhanced.SourceURL,
-			enhanced.MarkdownContent,
-			legacyJSON,
-			enhanced.ExtractedAt,
-		); err != nil {
+		if _, err := saveManualEdits(db, before, &enhanced, aiEditSourceEditor); err != nil {
 			if logger != nil {
-				logger.Warn("Failed to save edited data to database", "error", err, "ncessch", ncessch)
+				logger.Warn("Failed to save edited data to database", "error", err, "ncessch", before.NCESSCH)
 			}
 		}
 	}
@@ -310,38 +434,121 @@ func loadAndSaveEditedData(ncessch, filename string, db *DB) (*EnhancedSchoolDat
 	return &enhanced, nil
 }
 
-func saveSchoolData(school *School, enhanced *EnhancedSchoolData, naepData *NAEPData, filename string) tea.Cmd {
+// defaultSaveFilename is the suggested JSON filename for a school
+func defaultSaveFilename(school *School) string {
+	return strings.ReplaceAll(strings.ToLower(school.Name), " ", "_") + ".json"
+}
+
+// saveSchoolData writes a school's dossier to filename as JSON, or as a markdown note if
+// filename ends in .md
+func saveSchoolData(db *DB, school *School, enhanced *EnhancedSchoolData, naepData *NAEPData, filename string) tea.Cmd {
 	return func() tea.Msg {
-		// Create a combined data structure
-		data := map[string]interface{}{
-			"school": school,
+		if err := writeSchoolDossier(db, school, enhanced, naepData, filename); err != nil {
+			return saveMsg{err: err}
 		}
+		return saveMsg{filename: filename, err: nil}
+	}
+}
 
-		if enhanced != nil {
-			data["ai_extracted"] = enhanced
+// writeSchoolDossier writes a school's dossier to filename, as a markdown note
+// if filename ends in .md and otherwise as the JSON the bundle API serves
+func writeSchoolDossier(db *DB, school *School, enhanced *EnhancedSchoolData, naepData *NAEPData, filename string) error {
+	if isNoteFilename(filename) {
+		var calls []SchoolCall
+		if db != nil {
+			var err error
+			if calls, err = db.SchoolCalls(school.NCESSCH); err != nil {
+				return err
+			}
 		}
-
-		if naepData != nil {
-			data["naep_data"] = naepData
+		if err := os.WriteFile(filename, []byte(FormatSchoolNote(school, enhanced, naepData, calls)), 0644); err != nil {
+			return fmt.Errorf("failed to write file: %w", err)
 		}
+		return nil
+	}
+
+	// The same dossier /api/v1/schools/{id}/bundle serves
+	bundle, err := BuildSchoolBundle(db, school, enhanced, naepData)
+	if err != nil {
+		return fmt.Errorf("failed to gather school data: %w", err)
+	}
 
-		jsonData, err := json.MarshalIndent(data, "", "  ")
+	jsonData, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal data: %w", err)
+	}
+
+	if err := os.WriteFile(filename, jsonData, 0644); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	return nil
+}
+
+func searchSchools(db *DB, scope SearchScope, filters SearchFilters) tea.Cmd {
+	return func() tea.Msg {
+		db.RecordUsage(usageSearch)
+		schools, err := db.SearchSchoolsWithin(scope, filters, maxResults)
 		if err != nil {
-			return saveMsg{err: fmt.Errorf("failed to marshal data: %w", err)}
+			return searchMsg{err: err}
 		}
-
-		if err := os.WriteFile(filename, jsonData, 0644); err != nil {
-			return saveMsg{err: fmt.Errorf("failed to write file: %w", err)}
+		// Alerts and badges only decorate results, so a failure here is not fatal
+		alerted, _ := db.AlertedSchoolIDs()
+		yearChanges, _ := db.SchoolYearChanges()
+		ids := make([]string, len(schools))
+		var leaids []string
+		for i, s := range schools {
+			ids[i] = s.NCESSCH
+			if s.DistrictID.Valid {
+				leaids = append(leaids, s.DistrictID.String)
+			}
 		}
+		enrollmentTrends, _ := db.EnrollmentTrends(ids)
+		percentiles, _ := db.SchoolPercentiles(ids)
+		quality, _ := db.SchoolQualityFlags(ids)
+		staffing, _ := db.DistrictStaffing(leaids)
+		resultStats, _ := db.SearchResultStatsWithin(scope, filters, false)
+		var importedMatches map[string][]string
+		if expanded, err := filters.ExpandQuery(); err == nil {
+			importedMatches, _ = db.ImportedMatches(expanded.Query, ids)
+		}
+		return searchMsg{schools: schools, alerted: alerted, yearChanges: yearChanges, importedMatches: importedMatches, enrollmentTrends: enrollmentTrends, percentiles: percentiles, quality: quality, staffing: staffing, resultStats: resultStats}
+	}
+}
 
-		return saveMsg{filename: filename, err: nil}
+func loadSavedSearches(db *DB) tea.Cmd {
+	return func() tea.Msg {
+		searches, err := db.ListSavedSearches()
+		return savedSearchesMsg{searches: searches, err: err}
+	}
+}
+
+func checkSavedSearches(db *DB) tea.Cmd {
+	return func() tea.Msg {
+		changes, err := CheckSavedSearches(db)
+		return savedSearchCheckMsg{changes: changes, err: err}
+	}
+}
+
+func checkCCDReleases(db *DB, checker *CCDReleaseChecker) tea.Cmd {
+	return func() tea.Msg {
+		check, err := checker.CheckIfDue(context.Background(), db)
+		return ccdReleaseCheckMsg{check: check, err: err}
+	}
+}
+
+func saveSearch(db *DB, name string, filters SearchFilters) tea.Cmd {
+	return func() tea.Msg {
+		search, err := SaveSearch(db, name, filters, false)
+		return searchSavedMsg{search: search, err: err}
 	}
 }
 
-func searchSchools(db *DB, query, state string) tea.Cmd {
+func toggleSavedSearch(db *DB, search SavedSearch) tea.Cmd {
 	return func() tea.Msg {
-		schools, err := db.SearchSchools(query, state, maxResults)
-		return searchMsg{schools: schools, err: err}
+		if _, err := SetSubscribed(db, search.ID, !search.Subscribed); err != nil {
+			return savedSearchesMsg{err: err}
+		}
+		return loadSavedSearches(db)()
 	}
 }
 
@@ -367,10 +574,15 @@ func askQuestion(question, dataDir string) tea.Cmd {
 			return &agentAIScraperAdapter{scraper: scraper}, nil
 		}
 
+		apiKey := anthropicAPIKey()
+		if apiKey == "" {
+			return askMsg{err: ErrAINotConfigured}
+		}
+
 		// Create the agent using the factory with options
 		fantasyAgent, err := agent.NewAskAgent(
 			cmd.GetRootCmd(),
-			agent.WithAPIKeyFromEnv(),
+			agent.WithAPIKey(apiKey),
 			agent.WithDataDir(dataDir),
 			agent.WithDBInitializer(initDBWrapper),
 			agent.WithAIScraperInitializer(initAIScraperWrapper),
@@ -452,6 +664,16 @@ func initialModel(db *DB, aiScraper *AIScraperService, naepClient *NAEPClient, d
 	si.CharLimit = 200
 	si.Width = 60
 
+	ni := textinput.New()
+	ni.Placeholder = "Name this search (e.g., K-8 public, CA)"
+	ni.CharLimit = 100
+	ni.Width = 60
+
+	di := textinput.New()
+	di.Placeholder = "Directory (e.g., dossiers)"
+	di.CharLimit = 200
+	di.Width = 60
+
 	delegate := list.NewDefaultDelegate()
 	delegate.SetHeight(2)
 
@@ -478,23 +700,41 @@ func initialModel(db *DB, aiScraper *AIScraperService, naepClient *NAEPClient, d
 	}
 
 	return model{
-		db:            db,
-		aiScraper:     aiScraper,
-		naepClient:    naepClient,
-		dataDir:       dataDir,
-		currentView:   searchView,
-		searchInput:   ti,
-		saveInput:     si,
-		viewport:      vp,
-		aiViewport:    aiVp,
-		list:          l,
-		schools:       []School{},
-		autoFetchNAEP: autoFetchNAEP,
+		db:              db,
+		aiScraper:       aiScraper,
+		naepClient:      naepClient,
+		dataDir:         dataDir,
+		currentView:     searchView,
+		searchInput:     ti,
+		saveInput:       si,
+		searchNameInput: ni,
+		saveAllInput:    di,
+		viewport:        vp,
+		aiViewport:      aiVp,
+		list:            l,
+		schools:         []School{},
+		autoFetchNAEP:   autoFetchNAEP,
 	}
 }
 
 func (m model) Init() tea.Cmd {
-	return textinput.Blink
+	if m.db == nil {
+		return textinput.Blink
+	}
+	// Report saved search changes from reloaded data, and new CCD releases
+	cmds := []tea.Cmd{textinput.Blink, checkSavedSearches(m.db)}
+	if m.releaseChecker != nil {
+		cmds = append(cmds, checkCCDReleases(m.db, m.releaseChecker))
+	}
+	return tea.Batch(cmds...)
+}
+
+// searchFilters combines the search box and state filter with any saved search filters
+func (m model) searchFilters() SearchFilters {
+	filters := m.filters
+	filters.Query = m.searchInput.Value()
+	filters.State = m.stateFilter
+	return filters
 }
 
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -518,6 +758,19 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.aiViewport.Height = msg.Height - 15 // More space for UI elements
 		m.aiViewportReady = true
 
+		// Re-rendering long details and AI responses on every step of a drag
+		// is slow, so re-render once the size settles
+		m.resizeSeq++
+		seq := m.resizeSeq
+		return m, tea.Tick(resizeDebounce, func(time.Time) tea.Msg {
+			return resizeSettledMsg{seq: seq}
+		})
+
+	case resizeSettledMsg:
+		if msg.seq != m.resizeSeq {
+			return m, nil
+		}
+
 		// Refresh viewport content if in detail view
 		if m.currentView == detailView {
 			m.updateDetailViewport()
@@ -536,6 +789,12 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m.handleDetailViewKeys(msg)
 		case savePromptView:
 			return m.handleSavePromptKeys(msg)
+		case savedSearchesView:
+			return m.handleSavedSearchesKeys(msg)
+		case saveSearchPromptView:
+			return m.handleSaveSearchPromptKeys(msg)
+		case saveAllPromptView:
+			return m.handleSaveAllPromptKeys(msg)
 		}
 		return m.handleSearchViewKeys(msg)
 
@@ -557,9 +816,14 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 
 		m.schools = msg.schools
+		m.trends = msg.enrollmentTrends
+		m.percentiles = msg.percentiles
+		m.quality = msg.quality
+		m.resultStats = msg.resultStats
+		m.staffing = msg.staffing
 		items := make([]list.Item, len(msg.schools))
 		for i, school := range msg.schools {
-			items[i] = schoolItem{school: school}
+			items[i] = schoolItem{school: school, alerted: msg.alerted[school.NCESSCH], badge: msg.yearChanges[school.NCESSCH].Badge(), matched: msg.importedMatches[school.NCESSCH]}
 		}
 		m.list.SetItems(items)
 		if logger != nil {
@@ -567,6 +831,73 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		return m, nil
 
+	case savedSearchesMsg:
+		if msg.err != nil {
+			m.err = fmt.Errorf("saved searches failed: %w", msg.err)
+			return m, nil
+		}
+		m.savedSearches = msg.searches
+		m.savedCursor = min(m.savedCursor, max(len(msg.searches)-1, 0))
+		m.err = nil
+		return m, nil
+
+	case savedSearchCheckMsg:
+		if msg.err != nil {
+			if logger != nil {
+				logger.Warn("Saved search check failed", "error", msg.err)
+			}
+			return m, nil
+		}
+		switch len(msg.changes) {
+		case 0:
+		case 1:
+			m.savedNotice = fmt.Sprintf("🔔 Saved search %q changed: %s (Ctrl+O to review)", msg.changes[0].SearchName, msg.changes[0].Summary())
+		default:
+			m.savedNotice = fmt.Sprintf("🔔 %d saved searches changed (Ctrl+O to review)", len(msg.changes))
+		}
+		return m, nil
+
+	case ccdReleaseCheckMsg:
+		if msg.err != nil && logger != nil {
+			logger.Warn("CCD release check failed", "error", msg.err)
+		}
+		// A failed check still reports what the last one found
+		if msg.check != nil && len(msg.check.New) > 0 {
+			m.releaseNotice = fmt.Sprintf("📦 New data: %s (schoolfinder db releases --fetch)", msg.check.Summary())
+		}
+		return m, nil
+
+	case searchSavedMsg:
+		if msg.err != nil {
+			m.err = fmt.Errorf("save search failed: %w", msg.err)
+			return m, nil
+		}
+		m.err = nil
+		m.savedNotice = fmt.Sprintf("Saved search %q (Ctrl+O to open saved searches)", msg.search.Name)
+		m.searchNameInput.SetValue("")
+		m.searchNameInput.Blur()
+		m.currentView = searchView
+		m.searchInput.Focus()
+		return m, textinput.Blink
+
+	case bulkSaveMsg:
+		m.savingAll = false
+		if msg.err != nil {
+			m.err = fmt.Errorf("save all failed: %w", msg.err)
+			if logger != nil {
+				logger.Error("Failed to save search results", "error", msg.err, "dir", msg.dir)
+			}
+			return m, nil
+		}
+		m.err = nil
+		m.savedNotice = msg.manifest.Summary(msg.dir)
+		if logger != nil {
+			logger.Info("Search results saved", "dir", msg.dir, "saved", msg.manifest.Saved, "failed", msg.manifest.Failed)
+		}
+		m.currentView = searchView
+		m.searchInput.Focus()
+		return m, textinput.Blink
+
 	case aiScrapeMsg:
 		m.scrapingAI = false
 		if msg.err != nil {
@@ -577,6 +908,14 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, nil
 		}
 		m.enhancedData = msg.data
+		m.schoolHours = nil
+		if m.db != nil && m.selectedItem != nil && msg.data != nil {
+			hours, err := m.db.SchoolHours(m.selectedItem, msg.data.SchoolHours)
+			if err != nil && logger != nil {
+				logger.Warn("Failed to load school hours", "error", err, "school_id", m.selectedItem.NCESSCH)
+			}
+			m.schoolHours = hours
+		}
 		m.err = nil
 		if m.currentView == detailView {
 			m.updateDetailViewport()
@@ -601,10 +940,48 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if logger != nil && m.selectedItem != nil {
 			logger.Info("School data saved", "school_id", m.selectedItem.NCESSCH, "filename", msg.filename)
 		}
+		if hook := saveHookCommand(); hook != "" && m.selectedItem != nil {
+			m.saveSuccess += " (running save hook...)"
+			return m, runSaveHookCmd(hook, msg.filename, m.selectedItem)
+		}
+		return m, nil
+
+	case saveHookMsg:
+		if msg.err != nil {
+			m.saveSuccess = fmt.Sprintf("Saved to: %s", msg.filename)
+			m.err = msg.err
+			if logger != nil {
+				logger.Error("Save hook failed", "error", msg.err, "filename", msg.filename)
+			}
+			return m, nil
+		}
+		m.saveSuccess = fmt.Sprintf("Saved to: %s and ran save hook", msg.filename)
+		return m, nil
+
+	case browserOpenedMsg:
+		if msg.err != nil {
+			m.err = msg.err
+			return m, nil
+		}
+		m.err = nil
+		m.saveSuccess = fmt.Sprintf("Opened %s in your browser", msg.url)
 		return m, nil
 
 	case naepDataMsg:
+		// Fetches for a school the user has since left were canceled or are out of date
+		if m.selectedItem == nil || msg.ncessch != m.selectedItem.NCESSCH || errors.Is(msg.err, context.Canceled) {
+			return m, nil
+		}
 		m.loadingNAEP = false
+		m.naepCancel = nil
+		if errors.Is(msg.err, errNAEPNotAssessed) || errors.Is(msg.err, ErrNoNAEPData) {
+			// Not an error: NAEP simply doesn't report on this school or its system
+			m.naepNote = errorText(msg.err)
+			if m.currentView == detailView {
+				m.updateDetailViewport()
+			}
+			return m, nil
+		}
 		if msg.err != nil {
 			m.err = fmt.Errorf("NAEP fetch failed: %w", msg.err)
 			if logger != nil && m.selectedItem != nil {
@@ -622,6 +999,23 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		return m, nil
 
+	case parentSummaryMsg:
+		m.summarizing = false
+		if msg.err != nil {
+			m.err = fmt.Errorf("parent summary failed: %w", msg.err)
+			if logger != nil && m.selectedItem != nil {
+				logger.Error("Parent summary generation failed", "error", msg.err, "school_id", m.selectedItem.NCESSCH, "school_name", m.selectedItem.Name)
+			}
+			return m, nil
+		}
+		m.parentSummary = msg.summary
+		m.err = nil
+		if m.currentView == detailView {
+			m.viewport.GotoTop() // Summary is rendered at the top
+			m.updateDetailViewport()
+		}
+		return m, nil
+
 	case askMsg:
 		m.askingAI = false
 		if msg.err != nil {
@@ -677,11 +1071,12 @@ func (m model) handleSearchViewKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 				m.askingAI = true
 				m.aiResponse = "" // Clear previous response
 				m.err = nil
+				m.db.RecordUsage(usageAgentQuery)
 				return m, askQuestion(m.searchInput.Value(), m.dataDir)
 			} else {
 				// Perform search
 				m.loading = true
-				return m, searchSchools(m.db, m.searchInput.Value(), m.stateFilter)
+				return m, searchSchools(m.db, m.scope, m.searchFilters())
 			}
 		} else {
 			// Select school from list
@@ -691,10 +1086,9 @@ func (m model) handleSearchViewKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 				m.viewport.GotoTop()     // Reset scroll position
 				m.updateDetailViewport() // Load content into viewport
 
-				// Auto-fetch NAEP data if enabled
-				if m.autoFetchNAEP && m.naepClient != nil && !m.loadingNAEP {
-					m.loadingNAEP = true
-					return m, fetchNAEPData(m.naepClient, m.selectedItem)
+				// Auto-fetch NAEP data if enabled globally and for this school
+				if m.autoFetchNAEP && m.naepClient != nil && !m.loadingNAEP && m.naepClient.AutoFetchEnabled(m.selectedItem.NCESSCH) {
+					return m, m.startNAEPFetch(false)
 				}
 			}
 		}
@@ -722,12 +1116,96 @@ func (m model) handleSearchViewKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		if !found {
 			m.stateFilter = states[0]
 		}
-		if m.searchInput.Value() != "" {
+		if m.searchInput.Value() != "" || m.filters != (SearchFilters{}) {
 			m.loading = true
-			return m, searchSchools(m.db, m.searchInput.Value(), m.stateFilter)
+			return m, searchSchools(m.db, m.scope, m.searchFilters())
+		}
+		return m, nil
+
+	case tea.KeyCtrlO:
+		// Open saved searches
+		if m.useAI {
+			return m, nil
+		}
+		m.currentView = savedSearchesView
+		m.searchInput.Blur()
+		m.err = nil
+		return m, loadSavedSearches(m.db)
+
+	case tea.KeyCtrlB:
+		// Save the current search under a name
+		if m.useAI {
+			return m, nil
+		}
+		if !m.scope.IsZero() {
+			m.err = errors.New("searches within results can't be saved; step back out with Ctrl+P to save the whole search")
+			return m, nil
+		}
+		m.currentView = saveSearchPromptView
+		m.searchInput.Blur()
+		m.err = nil
+		m.searchNameInput.Focus()
+		return m, textinput.Blink
+
+	case tea.KeyCtrlL:
+		// Save a dossier for every result
+		if m.useAI || len(m.schools) == 0 {
+			return m, nil
+		}
+		m.currentView = saveAllPromptView
+		m.searchInput.Blur()
+		m.err = nil
+		if m.saveAllInput.Value() == "" {
+			m.saveAllInput.SetValue("dossiers")
+		}
+		m.saveAllInput.CursorEnd()
+		m.saveAllInput.Focus()
+		return m, textinput.Blink
+
+	case tea.KeyCtrlG:
+		// Toggle charts of the whole result set
+		if m.useAI || m.resultStats == nil {
+			return m, nil
 		}
+		m.showStats = !m.showStats
 		return m, nil
 
+	case tea.KeyCtrlR:
+		// Refine: search within the current results
+		if m.useAI || len(m.schools) == 0 || len(m.scope.Within) >= maxSearchScopeDepth {
+			return m, nil
+		}
+		m.scope = m.scope.Refine(m.searchFilters())
+		m.searchInput.SetValue("")
+		m.stateFilter = ""
+		m.filters = SearchFilters{}
+		m.loading = true
+		return m, searchSchools(m.db, m.scope, m.searchFilters())
+
+	case tea.KeyCtrlP:
+		// Step back out of a drill-down to the search it refined
+		if m.useAI || len(m.scope.Within) == 0 {
+			return m, nil
+		}
+		last := m.scope.Within[len(m.scope.Within)-1]
+		m.scope.Within = m.scope.Within[:len(m.scope.Within)-1]
+		m.searchInput.SetValue(last.QueryText())
+		m.stateFilter = last.State
+		m.filters = last.withoutFieldTerms()
+		m.filters.State = ""
+		m.err = nil
+		m.loading = true
+		return m, searchSchools(m.db, m.scope, m.searchFilters())
+
+	case tea.KeyCtrlX:
+		// Clear saved search filters
+		if m.filters == (SearchFilters{}) {
+			return m, nil
+		}
+		m.filters = SearchFilters{}
+		m.loading = true
+		return m, searchSchools(m.db, m.scope, m.searchFilters())
+
 	case tea.KeyCtrlT:
 		// Toggle AI mode
 		m.useAI = !m.useAI
@@ -759,32 +1237,68 @@ func (m model) handleSearchViewKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 func (m model) handleDetailViewKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	var cmd tea.Cmd
 
+	// The copy menu takes the next key; any key that isn't an action closes it
+	if m.copyMenu {
+		m.copyMenu = false
+		key := msg.String()
+		if msg.Type == tea.KeyCtrlY {
+			key = "i" // Ctrl+Y twice copies the ID
+		}
+		for _, action := range SchoolCopyActions(m.selectedItem, m.enhancedData) {
+			if action.Key != key {
+				continue
+			}
+			if err := writeClipboard(action.Value); err != nil {
+				m.err = fmt.Errorf("couldn't copy to the clipboard: %w", err)
+				return m, nil
+			}
+			m.err = nil
+			m.saveSuccess = "Copied " + action.Value
+			break
+		}
+		return m, nil
+	}
+
 	switch msg.Type {
 	case tea.KeyEsc:
 		if msg.Type == tea.KeyEsc {
+			m.cancelNAEPFetch()
 			m.currentView = searchView
 			m.selectedItem = nil
 			m.enhancedData = nil
+			m.schoolHours = nil
 			m.naepData = nil
+			m.naepNote = ""
+			m.parentSummary = nil
 			m.err = nil
 			m.saveSuccess = ""
+			m.shareQR = ""
+			m.provenance = nil
 			m.viewport.GotoTop()
 			return m, nil
 		}
 
 	case tea.KeyCtrlC:
+		m.cancelNAEPFetch()
 		m.currentView = searchView
 		m.selectedItem = nil
 		m.enhancedData = nil
+		m.schoolHours = nil
 		m.naepData = nil
+		m.naepNote = ""
+		m.parentSummary = nil
 		m.err = nil
 		m.saveSuccess = ""
+		m.shareQR = ""
+		m.provenance = nil
 		m.viewport.GotoTop()
 		return m, nil
 
 	case tea.KeyCtrlY:
+		// Choose what to copy: ID, address, phone, one-line summary, website, or office email
 		if m.selectedItem != nil {
-			_ = clipboard.WriteAll(m.selectedItem.NCESSCH)
+			m.copyMenu = true
+			m.saveSuccess = ""
 		}
 		return m, nil
 
@@ -797,6 +1311,15 @@ func (m model) handleDetailViewKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		}
 		return m, nil
 
+	case tea.KeyCtrlP:
+		// Generate plain-language parent summary
+		if m.selectedItem != nil && !m.summarizing && m.aiScraper != nil {
+			m.summarizing = true
+			m.err = nil
+			return m, generateParentSummary(m.aiScraper, m.selectedItem, m.enhancedData, m.naepData)
+		}
+		return m, nil
+
 	case tea.KeyCtrlE:
 		// Open extracted data in editor
 		if m.enhancedData != nil && m.db != nil {
@@ -811,19 +1334,65 @@ func (m model) handleDetailViewKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			m.saveInput.Focus()
 			m.err = nil
 			m.saveSuccess = ""
-			// Pre-fill with school name
-			defaultName := strings.ReplaceAll(strings.ToLower(m.selectedItem.Name), " ", "_") + ".json"
-			m.saveInput.SetValue(defaultName)
+			m.saveInput.SetValue(defaultSaveFilename(m.selectedItem))
 			return m, textinput.Blink
 		}
 		return m, nil
 
+	case tea.KeyCtrlO:
+		// Open the school's website in the browser
+		if m.selectedItem != nil {
+			return m, openSchoolLink(linkWebsite, m.selectedItem)
+		}
+		return m, nil
+
+	case tea.KeyCtrlG:
+		// Open the school's location in Google Maps
+		if m.selectedItem != nil {
+			return m, openSchoolLink(linkMap, m.selectedItem)
+		}
+		return m, nil
+
+	case tea.KeyCtrlL:
+		// Open the school's NCES listing
+		if m.selectedItem != nil {
+			return m, openSchoolLink(linkNCES, m.selectedItem)
+		}
+		return m, nil
+
+	case tea.KeyCtrlR:
+		// Toggle a QR code of the school's web page for opening it on a phone
+		if m.shareQR != "" {
+			m.shareQR = ""
+		} else if m.selectedItem != nil {
+			code, err := qrTerminal(schoolShareURL(tuiShareBaseURL(), m.selectedItem.NCESSCH))
+			if err != nil {
+				m.err = err
+				return m, nil
+			}
+			m.shareQR = code
+		}
+		return m, nil
+
+	case tea.KeyCtrlT:
+		// Toggle where each of the school's values came from
+		if m.provenance != nil {
+			m.provenance = nil
+		} else if m.selectedItem != nil && m.db != nil {
+			sources, err := m.db.SchoolProvenance(m.selectedItem)
+			if err != nil {
+				m.err = err
+				return m, nil
+			}
+			m.provenance = sources
+		}
+		return m, nil
+
 	case tea.KeyCtrlN:
-		// Fetch NAEP data
+		// Fetch NAEP data, or force a refresh if it is already loaded
 		if m.selectedItem != nil && !m.loadingNAEP && m.naepClient != nil {
-			m.loadingNAEP = true
 			m.err = nil
-			return m, fetchNAEPData(m.naepClient, m.selectedItem)
+			return m, m.startNAEPFetch(m.naepData != nil)
 		}
 		return m, nil
 
@@ -849,7 +1418,19 @@ func (m model) handleSavePromptKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			m.err = fmt.Errorf("filename cannot be empty")
 			return m, nil
 		}
-		return m, saveSchoolData(m.selectedItem, m.enhancedData, m.naepData, filename)
+		return m, saveSchoolData(m.db, m.selectedItem, m.enhancedData, m.naepData, filename)
+
+	case tea.KeyTab:
+		// Switch between a JSON file and a note for note apps
+		if m.selectedItem != nil {
+			if isNoteFilename(m.saveInput.Value()) {
+				m.saveInput.SetValue(defaultSaveFilename(m.selectedItem))
+			} else {
+				m.saveInput.SetValue(schoolNoteFilename(m.selectedItem))
+			}
+			m.saveInput.CursorEnd()
+		}
+		return m, nil
 	}
 
 	var cmd tea.Cmd
@@ -857,18 +1438,168 @@ func (m model) handleSavePromptKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	return m, cmd
 }
 
-func (m model) View() string {
-	switch m.currentView {
-	case detailView:
-		return m.detailViewRender()
-	case savePromptView:
-		return m.savePromptView()
-	}
-	return m.searchViewRender()
-}
-
-func (m model) searchViewRender() string {
-	var b strings.Builder
+func (m model) handleSavedSearchesKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyEsc, tea.KeyCtrlC:
+		m.currentView = searchView
+		m.err = nil
+		m.searchInput.Focus()
+		return m, textinput.Blink
+
+	case tea.KeyUp:
+		if m.savedCursor > 0 {
+			m.savedCursor--
+		}
+		return m, nil
+
+	case tea.KeyDown:
+		if m.savedCursor < len(m.savedSearches)-1 {
+			m.savedCursor++
+		}
+		return m, nil
+
+	case tea.KeyEnter:
+		// Run the selected search
+		if m.savedCursor >= len(m.savedSearches) {
+			return m, nil
+		}
+		filters := m.savedSearches[m.savedCursor].Filters
+		m.searchInput.SetValue(filters.QueryText())
+		m.stateFilter = filters.State
+		m.filters = filters.withoutFieldTerms()
+		m.filters.State = ""
+		m.scope = SearchScope{}
+		m.currentView = searchView
+		m.searchInput.Blur() // Focus the results
+		m.loading = true
+		return m, searchSchools(m.db, m.scope, m.searchFilters())
+
+	case tea.KeyCtrlA:
+		// Toggle change alerts for the selected search
+		if m.savedCursor < len(m.savedSearches) {
+			return m, toggleSavedSearch(m.db, m.savedSearches[m.savedCursor])
+		}
+		return m, nil
+
+	case tea.KeyCtrlD:
+		// Delete the selected search
+		if m.savedCursor < len(m.savedSearches) {
+			if err := m.db.DeleteSavedSearch(m.savedSearches[m.savedCursor].ID); err != nil {
+				m.err = err
+				return m, nil
+			}
+			return m, loadSavedSearches(m.db)
+		}
+		return m, nil
+	}
+
+	return m, nil
+}
+
+func (m model) handleSaveSearchPromptKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyEsc, tea.KeyCtrlC:
+		m.currentView = searchView
+		m.searchNameInput.SetValue("")
+		m.searchNameInput.Blur()
+		m.err = nil
+		m.searchInput.Focus()
+		return m, textinput.Blink
+
+	case tea.KeyEnter:
+		name := strings.TrimSpace(m.searchNameInput.Value())
+		if name == "" {
+			m.err = fmt.Errorf("name cannot be empty")
+			return m, nil
+		}
+		return m, saveSearch(m.db, name, m.searchFilters())
+	}
+
+	var cmd tea.Cmd
+	m.searchNameInput, cmd = m.searchNameInput.Update(msg)
+	return m, cmd
+}
+
+func (m model) handleSaveAllPromptKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.savingAll {
+		if msg.Type == tea.KeyCtrlC {
+			return m, tea.Quit
+		}
+		return m, nil
+	}
+
+	switch msg.Type {
+	case tea.KeyEsc, tea.KeyCtrlC:
+		m.currentView = searchView
+		m.saveAllInput.Blur()
+		m.err = nil
+		m.searchInput.Focus()
+		return m, textinput.Blink
+
+	case tea.KeyEnter:
+		dir := strings.TrimSpace(m.saveAllInput.Value())
+		if dir == "" {
+			m.err = fmt.Errorf("directory cannot be empty")
+			return m, nil
+		}
+		m.err = nil
+		m.savingAll = true
+		opts := m.bulkSave
+		opts.Dir = dir
+		opts.Search = m.searchFilters().Summary()
+		return m, saveAllDossiers(m.db, m.aiScraper, m.naepClient, m.schools, opts)
+
+	case tea.KeyTab:
+		m.bulkSave.Markdown = !m.bulkSave.Markdown
+		return m, nil
+
+	case tea.KeyCtrlN:
+		// Fetch NAEP data for results without it
+		if m.naepClient != nil {
+			m.bulkSave.FetchNAEP = !m.bulkSave.FetchNAEP
+		}
+		return m, nil
+
+	case tea.KeyCtrlA:
+		// Extract website data for results without it
+		if m.aiScraper != nil {
+			m.bulkSave.FetchAI = !m.bulkSave.FetchAI
+		}
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	m.saveAllInput, cmd = m.saveAllInput.Update(msg)
+	return m, cmd
+}
+
+// saveAllDossiers saves a dossier for each school in the background
+func saveAllDossiers(db *DB, ai *AIScraperService, naep *NAEPClient, schools []School, opts BulkSaveOptions) tea.Cmd {
+	schools = slices.Clone(schools)
+	return func() tea.Msg {
+		manifest, err := SaveDossiers(context.Background(), db, ai, naep, schools, opts)
+		return bulkSaveMsg{dir: opts.Dir, manifest: manifest, err: err}
+	}
+}
+
+func (m model) View() string {
+	switch m.currentView {
+	case detailView:
+		return m.detailViewRender()
+	case savePromptView:
+		return m.savePromptView()
+	case savedSearchesView:
+		return m.savedSearchesViewRender()
+	case saveSearchPromptView:
+		return m.saveSearchPromptView()
+	case saveAllPromptView:
+		return m.saveAllPromptViewRender()
+	}
+	return m.searchViewRender()
+}
+
+func (m model) searchViewRender() string {
+	var b strings.Builder
 
 	// Header
 	headerStyle := lipgloss.NewStyle().
@@ -920,6 +1651,26 @@ func (m model) searchViewRender() string {
 		}
 		b.WriteString(fmt.Sprintf("State Filter: %s (Ctrl+S to cycle)", stateText))
 		b.WriteString("\n")
+		if m.filters != (SearchFilters{}) {
+			b.WriteString(fmt.Sprintf("Filters: %s (Ctrl+X to clear)", m.filters.Summary()))
+			b.WriteString("\n")
+		}
+		if !m.scope.IsZero() {
+			var steps []string
+			for _, crumb := range m.scope.Breadcrumbs("", m.searchFilters()) {
+				steps = append(steps, crumb.Label)
+			}
+			b.WriteString(fmt.Sprintf("Within: %s (Ctrl+P to step back)", strings.Join(steps, " › ")))
+			b.WriteString("\n")
+		}
+		if m.savedNotice != "" {
+			b.WriteString(lipgloss.NewStyle().Foreground(lipgloss.Color("33")).Render(m.savedNotice))
+			b.WriteString("\n")
+		}
+		if m.releaseNotice != "" {
+			b.WriteString(lipgloss.NewStyle().Foreground(lipgloss.Color("33")).Render(m.releaseNotice))
+			b.WriteString("\n")
+		}
 	}
 	b.WriteString("\n")
 
@@ -949,7 +1700,7 @@ func (m model) searchViewRender() string {
 	// Error display
 	if m.err != nil {
 		errorStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("196"))
-		b.WriteString(errorStyle.Render(fmt.Sprintf("Error: %v\n", m.err)))
+		b.WriteString(errorStyle.Render("Error: " + errorText(m.err) + "\n"))
 	}
 
 	// AI welcome/suggestions display (when in AI mode but no query yet)
@@ -1007,31 +1758,23 @@ func (m model) searchViewRender() string {
 		}
 	}
 
-	// Results summary stats
+	// Results summary stats: medians and outlier-excluding means across every
+	// matching school, or across those listed when the results weren't summarized
 	if !m.useAI && len(m.schools) > 0 {
-		// Calculate quick stats
-		totalEnrollment := int64(0)
-		totalTeachers := 0.0
-		schoolsWithData := 0
-
-		for _, school := range m.schools {
-			if school.Enrollment.Valid {
-				totalEnrollment += school.Enrollment.Int64
-			}
-			if school.Teachers.Valid {
-				totalTeachers += school.Teachers.Float64
-				schoolsWithData++
+		var enrollment, teachers RobustSummary
+		if m.resultStats != nil {
+			enrollment, teachers = m.resultStats.EnrollmentSummary, m.resultStats.TeachersSummary
+		} else {
+			var students, fte []float64
+			for _, school := range m.schools {
+				if school.Enrollment.Valid && school.Enrollment.Int64 > 0 {
+					students = append(students, float64(school.Enrollment.Int64))
+				}
+				if school.Teachers.Valid && school.Teachers.Float64 > 0 {
+					fte = append(fte, school.Teachers.Float64)
+				}
 			}
-		}
-
-		avgEnrollment := 0.0
-		if len(m.schools) > 0 {
-			avgEnrollment = float64(totalEnrollment) / float64(len(m.schools))
-		}
-
-		avgTeachers := 0.0
-		if schoolsWithData > 0 {
-			avgTeachers = totalTeachers / float64(schoolsWithData)
+			enrollment, teachers = SummarizeRobust(students), SummarizeRobust(fte)
 		}
 
 		// Stats display
@@ -1039,12 +1782,23 @@ func (m model) searchViewRender() string {
 			Foreground(lipgloss.Color("241")).
 			MarginBottom(1)
 
-		stats := fmt.Sprintf("Results: %d schools | Avg Enrollment: %.0f | Avg Teachers: %.1f",
-			len(m.schools), avgEnrollment, avgTeachers)
+		results := fmt.Sprintf("%d schools", len(m.schools))
+		if m.resultStats != nil && m.resultStats.Schools > len(m.schools) {
+			results = fmt.Sprintf("%d of %s schools", len(m.schools), formatChartValue(float64(m.resultStats.Schools)))
+		}
+		stats := fmt.Sprintf("Results: %s | Enrollment: %s | Teachers: %s",
+			results, enrollment.Summary(0), teachers.Summary(1))
+		if method := enrollment.Method(0); method != "" {
+			stats += "\nEnrollment " + method
+		}
 		b.WriteString(statsStyle.Render(stats))
 		b.WriteString("\n")
 
-		b.WriteString(m.list.View())
+		if m.showStats && m.resultStats != nil {
+			b.WriteString(renderResultStats(m.resultStats, m.width))
+		} else {
+			b.WriteString(m.list.View())
+		}
 	}
 
 	// Help text
@@ -1060,7 +1814,7 @@ func (m model) searchViewRender() string {
 			help = "\nEnter: Ask AI | Ctrl+T: Toggle mode | Esc/Ctrl+C: Quit"
 		}
 	} else {
-		help = "\nTab: Switch focus | Enter: Search/Select | Ctrl+S: Filter by state | Ctrl+T: Toggle AI mode | Esc/Ctrl+C: Quit"
+		help = "\nTab: Switch focus | Enter: Search/Select | Ctrl+S: Filter by state | Ctrl+G: Result charts | Ctrl+O: Saved searches | Ctrl+B: Save search | Ctrl+R: Search within results | Ctrl+L: Save all results | Ctrl+T: Toggle AI mode | Esc/Ctrl+C: Quit"
 	}
 	b.WriteString(helpStyle.Render(help))
 
@@ -1089,6 +1843,32 @@ func (m model) detailViewContent() string {
 	valueStyle := lipgloss.NewStyle().
 		Foreground(lipgloss.Color("230"))
 
+	correctedStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("214")).
+		Italic(true)
+
+	noteStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("241"))
+
+	// flagged marks values the data-quality pass found anomalous
+	quality := m.quality[s.NCESSCH]
+	flagged := func(field string) string {
+		if warning := quality.Warning(field); warning != "" {
+			return " " + correctedStyle.Render("⚠ "+warning)
+		}
+		return ""
+	}
+
+	// corrected marks values that come from a user correction rather than CCD
+	corrected := func(fields ...string) string {
+		for _, field := range fields {
+			if s.IsCorrected(field) {
+				return " " + correctedStyle.Render("(user-corrected)")
+			}
+		}
+		return ""
+	}
+
 	sectionStyle := lipgloss.NewStyle().
 		BorderStyle(lipgloss.RoundedBorder()).
 		BorderForeground(lipgloss.Color("62")).
@@ -1099,6 +1879,24 @@ func (m model) detailViewContent() string {
 	b.WriteString(titleStyle.Render("🏫 School Details"))
 	b.WriteString("\n\n")
 
+	// Parent Summary Section (shown first when available)
+	if m.parentSummary != nil {
+		summaryTitle := lipgloss.NewStyle().
+			Bold(true).
+			Foreground(lipgloss.Color("82")).
+			Render("👪 Summary for Parents")
+		b.WriteString(summaryTitle)
+		b.WriteString("\n")
+
+		rendered, err := renderMarkdown(m.parentSummary.Summary, m.width)
+		if err != nil {
+			rendered = m.parentSummary.Summary
+		}
+		b.WriteString(rendered)
+		b.WriteString(lipgloss.NewStyle().Foreground(lipgloss.Color("241")).Render(fmt.Sprintf("AI-generated %s from the data below", m.parentSummary.GeneratedAt.Format("2006-01-02"))))
+		b.WriteString("\n\n")
+	}
+
 	// Basic Info Section
 	var basicInfo strings.Builder
 	basicInfo.WriteString(labelStyle.Render("School Name:") + " " + valueStyle.Render(s.Name) + "\n")
@@ -1106,7 +1904,7 @@ func (m model) detailViewContent() string {
 	basicInfo.WriteString(labelStyle.Render("District:") + " " + valueStyle.Render(s.District) + "\n")
 	basicInfo.WriteString(labelStyle.Render("School Type:") + " " + valueStyle.Render(s.SchoolTypeString()) + "\n")
 	basicInfo.WriteString(labelStyle.Render("Level:") + " " + valueStyle.Render(s.LevelString()) + "\n")
-	basicInfo.WriteString(labelStyle.Render("Grade Range:") + " " + valueStyle.Render(s.GradeRangeString()) + "\n")
+	basicInfo.WriteString(labelStyle.Render("Grade Range:") + " " + valueStyle.Render(s.GradeRangeString()) + flagged(qualityFieldGrades) + "\n")
 	basicInfo.WriteString(labelStyle.Render("Charter School:") + " " + valueStyle.Render(s.CharterString()) + "\n")
 	basicInfo.WriteString(labelStyle.Render("School Year:") + " " + valueStyle.Render(s.SchoolYear) + "\n")
 
@@ -1115,27 +1913,43 @@ func (m model) detailViewContent() string {
 
 	// Location Section
 	var locationInfo strings.Builder
-	locationInfo.WriteString(labelStyle.Render("Street Address:") + " " + valueStyle.Render(s.FullAddress()) + "\n")
-	locationInfo.WriteString(labelStyle.Render("City:") + " " + valueStyle.Render(s.City) + "\n")
+	locationInfo.WriteString(labelStyle.Render("Street Address:") + " " + valueStyle.Render(s.FullAddress()) + corrected(correctionStreet) + "\n")
+	locationInfo.WriteString(labelStyle.Render("City:") + " " + valueStyle.Render(s.City) + corrected(correctionCity) + "\n")
 	locationInfo.WriteString(labelStyle.Render("State:") + " " + valueStyle.Render(fmt.Sprintf("%s (%s)", s.StateName, s.State)) + "\n")
-	locationInfo.WriteString(labelStyle.Render("Zip Code:") + " " + valueStyle.Render(s.ZipString()) + "\n")
+	locationInfo.WriteString(labelStyle.Render("Zip Code:") + " " + valueStyle.Render(s.ZipString()) + corrected(correctionZip) + "\n")
 
 	b.WriteString(sectionStyle.Render(locationInfo.String()))
 	b.WriteString("\n")
 
 	// Contact Section
 	var contactInfo strings.Builder
-	contactInfo.WriteString(labelStyle.Render("Phone:") + " " + valueStyle.Render(s.PhoneString()) + "\n")
-	contactInfo.WriteString(labelStyle.Render("Website:") + " " + valueStyle.Render(s.WebsiteString()) + "\n")
+	contactInfo.WriteString(labelStyle.Render("Phone:") + " " + valueStyle.Render(s.PhoneString()) + corrected(correctionPhone) + "\n")
+	contactInfo.WriteString(labelStyle.Render("Website:") + " " + valueStyle.Render(s.WebsiteString()) + corrected(correctionWebsite) + flagged(qualityFieldWebsite) + "\n")
 
 	b.WriteString(sectionStyle.Render(contactInfo.String()))
 	b.WriteString("\n")
 
 	// Enrollment & Staffing Section
 	var statsInfo strings.Builder
-	statsInfo.WriteString(labelStyle.Render("Total Enrollment:") + " " + valueStyle.Render(s.EnrollmentString()) + "\n")
-	statsInfo.WriteString(labelStyle.Render("Teachers (FTE):") + " " + valueStyle.Render(s.TeachersString()) + "\n")
-	statsInfo.WriteString(labelStyle.Render("Student/Teacher:") + " " + valueStyle.Render(s.StudentTeacherRatio()) + "\n")
+	percentiles := m.percentiles[s.NCESSCH]
+	for _, stat := range []struct{ label, value, metric, field string }{
+		{"Total Enrollment:", s.EnrollmentString(), percentileEnrollment, qualityFieldEnrollment},
+		{"Teachers (FTE):", s.TeachersString(), percentileTeachers, ""},
+		{"Student/Teacher:", s.StudentTeacherRatio(), percentileRatio, qualityFieldRatio},
+	} {
+		statsInfo.WriteString(labelStyle.Render(stat.label) + " " + valueStyle.Render(stat.value) + flagged(stat.field) + "\n")
+		if note := percentiles.Annotation(stat.metric); note != "" {
+			statsInfo.WriteString(labelStyle.Render("") + " " + noteStyle.Render(note) + "\n")
+		}
+	}
+	if trend, ok := m.trends[s.NCESSCH]; ok {
+		statsInfo.WriteString(labelStyle.Render("Enrollment Trend:") + " " + valueStyle.Render(trend.Sparkline()+" "+trend.Label()) + "\n")
+		statsInfo.WriteString(labelStyle.Render("") + " " + valueStyle.Render(trend.Summary()) + "\n")
+	}
+	staffing, hasStaffing := m.staffing[s.DistrictID.String]
+	if hasStaffing {
+		statsInfo.WriteString(labelStyle.Render("Student/Counselor:") + " " + valueStyle.Render(staffing.CounselorRatio()+" (district)") + "\n")
+	}
 
 	b.WriteString(sectionStyle.Render(statsInfo.String()))
 	b.WriteString("\n")
@@ -1178,7 +1992,28 @@ func (m model) detailViewContent() string {
 		b.WriteString("\n")
 	}
 
+	// District staffing composition
+	if hasStaffing && len(staffing.Groups) > 0 {
+		b.WriteString(lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("33")).Render(fmt.Sprintf("👥 District Staffing (%.1f FTE)", staffing.TotalFTE)))
+		b.WriteString("\n")
+		for _, g := range staffing.Groups {
+			b.WriteString(BarChart(fmt.Sprintf("%-22s", g.Name), g.FTE, staffing.TotalFTE, 30, lipgloss.Color("62")) + fmt.Sprintf(" FTE (%.0f%%)", g.Share))
+			b.WriteString("\n")
+		}
+		if staffing.CounselorsOverloaded() {
+			b.WriteString(lipgloss.NewStyle().Foreground(lipgloss.Color("214")).Render(fmt.Sprintf("Counselors carry more than the recommended %d students each", recommendedStudentsPerCounselor)))
+			b.WriteString("\n")
+		}
+		b.WriteString("\n")
+	}
+
 	// NAEP Data Section
+	if m.naepData == nil && m.naepNote != "" {
+		b.WriteString(lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("33")).Render("📊 Nation's Report Card (NAEP)"))
+		b.WriteString("\n")
+		b.WriteString(lipgloss.NewStyle().Foreground(lipgloss.Color("240")).Render(m.naepNote))
+		b.WriteString("\n\n")
+	}
 	if m.naepData != nil {
 		naepTitle := lipgloss.NewStyle().
 			Bold(true).
@@ -1371,9 +2206,19 @@ func (m model) detailViewContent() string {
 		b.WriteString(noteStyle.Render("  • These are state/district averages - individual schools may vary"))
 		b.WriteString("\n\n")
 
+		cacheTTLDays := int(defaultNAEPCacheTTL.Hours() / 24)
+		if m.naepClient != nil {
+			cacheTTLDays = int(m.naepClient.cacheTTL.Hours() / 24)
+		}
+		cacheText := fmt.Sprintf("Data cached: %s (%d-day cache)", m.naepData.ExtractedAt.Format("2006-01-02"), cacheTTLDays)
+		if m.naepData.Refreshing {
+			cacheText += " - past refresh date, updating in background (Ctrl+N to reload)"
+		} else if m.naepData.Stale {
+			cacheText += " - past refresh date"
+		}
 		cacheNote := lipgloss.NewStyle().
 			Foreground(lipgloss.Color("241")).
-			Render(fmt.Sprintf("Data cached: %s (90-day cache)", m.naepData.ExtractedAt.Format("2006-01-02")))
+			Render(cacheText)
 		b.WriteString(cacheNote)
 		b.WriteString("\n\n")
 	}
@@ -1388,6 +2233,27 @@ func (m model) detailViewContent() string {
 		b.WriteString(aiTitle)
 		b.WriteString("\n\n")
 
+		if m.enhancedData.Stale {
+			staleText := "Cached data is past its refresh date"
+			if m.enhancedData.Refreshing {
+				staleText += "; re-extracting in background (Ctrl+A to reload)"
+			}
+			b.WriteString(lipgloss.NewStyle().Foreground(lipgloss.Color("241")).Render(staleText))
+			b.WriteString("\n\n")
+		}
+
+		// The school's day in its own time zone, for calling the front office
+		if m.schoolHours != nil {
+			now := time.Now()
+			status := m.schoolHours.Status(now)
+			color := lipgloss.Color("196")
+			if status.Open {
+				color = lipgloss.Color("42")
+			}
+			b.WriteString(fmt.Sprintf("🕘 Hours: %s  %s  (%s at the school)\n\n",
+				m.schoolHours.Summary(now), lipgloss.NewStyle().Foreground(color).Render(status.Label), status.LocalTime))
+		}
+
 		// If we have markdown content, render it with glamour
 		if m.enhancedData.MarkdownContent != "" {
 			rendered, err := renderMarkdown(m.enhancedData.MarkdownContent, m.width)
@@ -1418,7 +2284,17 @@ func (m *model) updateDetailViewport() {
 		return
 	}
 	content := m.detailViewContent()
+	if content == m.detailContent {
+		return
+	}
+
+	// Keep a reader who has scrolled at the same place in reflowed content
+	scrolled, percent := !m.viewport.AtTop(), m.viewport.ScrollPercent()
 	m.viewport.SetContent(content)
+	m.detailContent = content
+	if scrolled {
+		m.viewport.SetYOffset(int(math.Round(percent * float64(max(m.viewport.TotalLineCount()-m.viewport.Height, 0)))))
+	}
 }
 
 func (m *model) updateAIViewport() {
@@ -1444,6 +2320,27 @@ func (m model) detailViewRender() string {
 
 	var b strings.Builder
 
+	// The share QR code replaces the details until it's dismissed
+	if m.shareQR != "" {
+		shareURL := schoolShareURL(tuiShareBaseURL(), m.selectedItem.NCESSCH)
+		qrStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("15")).Background(lipgloss.Color("0"))
+		b.WriteString(qrStyle.Render(strings.TrimSuffix(m.shareQR, "\n")))
+		b.WriteString("\n\n")
+		b.WriteString(fmt.Sprintf("Scan to open %s\n", shareURL))
+		b.WriteString(lipgloss.NewStyle().Foreground(lipgloss.Color("241")).Render("Run `schoolfinder serve` on this computer first; your phone must be on the same network."))
+		b.WriteString("\n\n")
+		b.WriteString(lipgloss.NewStyle().Foreground(lipgloss.Color("241")).Render("Ctrl+R: Hide QR code | Esc: Back | Ctrl+C: Quit"))
+		return b.String()
+	}
+
+	// The sources replace the details until they're dismissed
+	if m.provenance != nil {
+		b.WriteString(renderProvenance(m.provenance))
+		b.WriteString("\n")
+		b.WriteString(lipgloss.NewStyle().Foreground(lipgloss.Color("241")).Render("Ctrl+T: Hide sources | Esc: Back | Ctrl+C: Quit"))
+		return b.String()
+	}
+
 	// Render viewport
 	b.WriteString(m.viewport.View())
 	b.WriteString("\n")
@@ -1469,6 +2366,12 @@ func (m model) detailViewRender() string {
 		b.WriteString("\n")
 	}
 
+	// Parent summary status
+	if m.summarizing {
+		b.WriteString(statusStyle.Render("⏳ Writing parent summary..."))
+		b.WriteString("\n")
+	}
+
 	// AI scraping status
 	if m.scrapingAI {
 		b.WriteString(statusStyle.Render("⏳ Scraping website with AI..."))
@@ -1489,7 +2392,7 @@ func (m model) detailViewRender() string {
 		errorStyle := lipgloss.NewStyle().
 			Foreground(lipgloss.Color("196")).
 			Bold(true)
-		b.WriteString(errorStyle.Render(fmt.Sprintf("❌ Error: %v", m.err)))
+		b.WriteString(errorStyle.Render("❌ Error: " + errorText(m.err)))
 		b.WriteString("\n")
 	}
 
@@ -1500,87 +2403,279 @@ func (m model) detailViewRender() string {
 	var help string
 	s := m.selectedItem
 
+	if m.copyMenu {
+		var choices []string
+		for _, action := range SchoolCopyActions(s, m.enhancedData) {
+			choices = append(choices, action.Key+": "+action.Label)
+		}
+		b.WriteString(statusStyle.Render("📋 Copy " + strings.Join(choices, " | ")))
+		b.WriteString("\n")
+		b.WriteString(helpStyle.Render("Press a key to copy | Any other key: Cancel"))
+		return b.String()
+	}
+
 	// Build NAEP shortcut text
 	naepText := "Ctrl+N: NAEP"
 	if m.autoFetchNAEP {
 		naepText = "Ctrl+N: Refresh NAEP"
 	}
 
+	// Parent summary shortcut is only offered when AI is configured
+	summaryText := ""
+	if m.aiScraper != nil {
+		summaryText = " | Ctrl+P: Parent Summary"
+	}
+
+	// Browser shortcuts; the website one only when there is a website
+	openText := " | Ctrl+G: Map | Ctrl+L: NCES page | Ctrl+R: QR code | Ctrl+T: Sources"
+	if s.Website.Valid && s.Website.String != "" {
+		openText = " | Ctrl+O: Website" + openText
+	}
+
 	if m.enhancedData != nil {
-		help = fmt.Sprintf("↑/↓/PgUp/PgDn: Scroll | Ctrl+W: Save | Ctrl+E: Edit | %s | Ctrl+Y: Copy ID | Esc: Back | Ctrl+C: Quit", naepText)
+		help = fmt.Sprintf("↑/↓/PgUp/PgDn: Scroll | Ctrl+W: Save | Ctrl+E: Edit | %s%s | Ctrl+Y: Copy%s | Esc: Back | Ctrl+C: Quit", naepText, summaryText, openText)
 	} else if m.aiScraper != nil && s.Website.Valid && s.Website.String != "" {
-		help = fmt.Sprintf("↑/↓/PgUp/PgDn: Scroll | Ctrl+W: Save | Ctrl+A: AI Extract | %s | Ctrl+Y: Copy ID | Esc: Back | Ctrl+C: Quit", naepText)
+		help = fmt.Sprintf("↑/↓/PgUp/PgDn: Scroll | Ctrl+W: Save | Ctrl+A: AI Extract | %s%s | Ctrl+Y: Copy%s | Esc: Back | Ctrl+C: Quit", naepText, summaryText, openText)
 	} else {
-		help = fmt.Sprintf("↑/↓/PgUp/PgDn: Scroll | Ctrl+W: Save | %s | Ctrl+Y: Copy ID | Esc: Back | Ctrl+C: Quit", naepText)
+		help = fmt.Sprintf("↑/↓/PgUp/PgDn: Scroll | Ctrl+W: Save | %s%s | Ctrl+Y: Copy%s | Esc: Back | Ctrl+C: Quit", naepText, summaryText, openText)
 	}
 	b.WriteString(helpStyle.Render(help))
 
 	return b.String()
 }
 
-func (m model) savePromptView() string {
+func (m model) savedSearchesViewRender() string {
 	var b strings.Builder
 
 	titleStyle := lipgloss.NewStyle().
 		Bold(true).
 		Foreground(lipgloss.Color("62")).
 		MarginBottom(1)
+	mutedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("241"))
+	selectedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("170")).Bold(true)
 
-	b.WriteString(titleStyle.Render("💾 Save School Data"))
+	b.WriteString(titleStyle.Render("📌 Saved Searches"))
 	b.WriteString("\n\n")
 
-	if m.selectedItem != nil {
-		infoStyle := lipgloss.NewStyle().
-			Foreground(lipgloss.Color("241"))
-		b.WriteString(infoStyle.Render(fmt.Sprintf("Saving data for: %s", m.selectedItem.Name)))
-		b.WriteString("\n\n")
+	if len(m.savedSearches) == 0 {
+		b.WriteString(mutedStyle.Render("No saved searches yet. Run a search and press Ctrl+B to save it."))
+		b.WriteString("\n")
 	}
 
-	// Input prompt
-	inputStyle := lipgloss.NewStyle().
-		BorderStyle(lipgloss.RoundedBorder()).
-		BorderForeground(lipgloss.Color("62")).
-		Padding(0, 1)
+	for i, search := range m.savedSearches {
+		alerts := "  "
+		if search.Subscribed {
+			alerts = "🔔"
+		}
+		line := fmt.Sprintf("%s %s", alerts, search.Name)
+		if i == m.savedCursor {
+			b.WriteString(selectedStyle.Render("> " + line))
+		} else {
+			b.WriteString("  " + line)
+		}
+		b.WriteString("\n")
 
-	b.WriteString("Filename: ")
-	b.WriteString(inputStyle.Render(m.saveInput.View()))
+		detail := "     " + search.Filters.Summary()
+		if search.LastChange != nil {
+			detail += fmt.Sprintf(" | Last change: %s (%s)", search.LastChange.Summary(), search.LastChange.DetectedAt.Format("Jan 2, 2006"))
+		}
+		b.WriteString(mutedStyle.Render(detail))
+		b.WriteString("\n")
+	}
+
+	if m.err != nil {
+		errorStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("196"))
+		b.WriteString("\n")
+		b.WriteString(errorStyle.Render("Error: " + errorText(m.err) + "\n"))
+	}
+
+	helpStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("241")).
+		MarginTop(1)
+	b.WriteString(helpStyle.Render("\n↑/↓: Select | Enter: Run | Ctrl+A: Toggle change alerts | Ctrl+D: Delete | Esc: Back"))
+
+	return b.String()
+}
+
+func (m model) saveSearchPromptView() string {
+	var b strings.Builder
+
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("62")).
+		MarginBottom(1)
+
+	b.WriteString(titleStyle.Render("📌 Save Search"))
 	b.WriteString("\n\n")
 
-	// Info text
 	infoStyle := lipgloss.NewStyle().
 		Foreground(lipgloss.Color("241"))
+	b.WriteString(infoStyle.Render("Filters: " + m.searchFilters().Summary()))
+	b.WriteString("\n\n")
 
-	info := "The file will contain:\n"
-	info += "  • School information (name, location, contact, enrollment, etc.)\n"
-	if m.enhancedData != nil {
-		info += "  • AI-extracted data (principal, programs, activities, etc.)\n"
-	}
-	info += "\nFormat: JSON"
-	b.WriteString(infoStyle.Render(info))
+	inputStyle := lipgloss.NewStyle().
+		BorderStyle(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("62")).
+		Padding(0, 1)
+
+	b.WriteString("Name: ")
+	b.WriteString(inputStyle.Render(m.searchNameInput.View()))
 	b.WriteString("\n\n")
 
-	// Error display
 	if m.err != nil {
 		errorStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("196"))
-		b.WriteString(errorStyle.Render(fmt.Sprintf("Error: %v\n", m.err)))
+		b.WriteString(errorStyle.Render("Error: " + errorText(m.err) + "\n"))
 	}
 
-	// Help text
 	helpStyle := lipgloss.NewStyle().
 		Foreground(lipgloss.Color("241")).
 		MarginTop(1)
-
-	help := "Enter: Save | Esc: Cancel | Ctrl+C: Quit"
-	b.WriteString(helpStyle.Render(help))
+	b.WriteString(helpStyle.Render("\nEnter: Save | Esc: Cancel | Saving an existing name replaces it"))
 
 	return b.String()
 }
 
-// launchTUI starts the interactive TUI application
-func launchTUI(dataDir string) {
-	// Setup logger first
-	if err := setupLogger(dataDir); err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to setup logger: %v\n", err)
+func (m model) saveAllPromptViewRender() string {
+	var b strings.Builder
+
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("62")).
+		MarginBottom(1)
+
+	b.WriteString(titleStyle.Render("💾 Save All Results"))
+	b.WriteString("\n\n")
+
+	infoStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("241"))
+	noun := "dossiers"
+	if len(m.schools) == 1 {
+		noun = "dossier"
+	}
+	b.WriteString(infoStyle.Render(fmt.Sprintf("Saving %d %s, one per school, and %s", len(m.schools), noun, bulkManifestName)))
+	b.WriteString("\n\n")
+
+	inputStyle := lipgloss.NewStyle().
+		BorderStyle(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("62")).
+		Padding(0, 1)
+
+	b.WriteString("Directory: ")
+	b.WriteString(inputStyle.Render(m.saveAllInput.View()))
+	b.WriteString("\n\n")
+
+	format := "JSON"
+	if m.bulkSave.Markdown {
+		format = "Markdown notes with YAML frontmatter (Obsidian, Notion)"
+	}
+	enrichment := func(fetch bool) string {
+		if fetch {
+			return "cached, and fetched where missing"
+		}
+		return "cached only"
+	}
+	info := "Format: " + format + "\n"
+	if m.naepClient != nil {
+		info += "NAEP data: " + enrichment(m.bulkSave.FetchNAEP) + "\n"
+	}
+	if m.aiScraper != nil {
+		info += "Website data: " + enrichment(m.bulkSave.FetchAI) + "\n"
+	}
+	b.WriteString(infoStyle.Render(info))
+	b.WriteString("\n")
+
+	if m.savingAll {
+		b.WriteString(lipgloss.NewStyle().Foreground(lipgloss.Color("226")).Render("Saving..."))
+		b.WriteString("\n")
+	}
+	if m.err != nil {
+		errorStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("196"))
+		b.WriteString(errorStyle.Render("Error: " + errorText(m.err) + "\n"))
+	}
+
+	helpStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("241")).
+		MarginTop(1)
+	help := "Enter: Save | Tab: JSON/Markdown notes"
+	if m.naepClient != nil {
+		help += " | Ctrl+N: Fetch missing NAEP"
+	}
+	if m.aiScraper != nil {
+		help += " | Ctrl+A: Extract missing website data"
+	}
+	help += " | Esc: Cancel"
+	b.WriteString(helpStyle.Render("\n" + help))
+
+	return b.String()
+}
+
+func (m model) savePromptView() string {
+	var b strings.Builder
+
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("62")).
+		MarginBottom(1)
+
+	b.WriteString(titleStyle.Render("💾 Save School Data"))
+	b.WriteString("\n\n")
+
+	if m.selectedItem != nil {
+		infoStyle := lipgloss.NewStyle().
+			Foreground(lipgloss.Color("241"))
+		b.WriteString(infoStyle.Render(fmt.Sprintf("Saving data for: %s", m.selectedItem.Name)))
+		b.WriteString("\n\n")
+	}
+
+	// Input prompt
+	inputStyle := lipgloss.NewStyle().
+		BorderStyle(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("62")).
+		Padding(0, 1)
+
+	b.WriteString("Filename: ")
+	b.WriteString(inputStyle.Render(m.saveInput.View()))
+	b.WriteString("\n\n")
+
+	// Info text
+	infoStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("241"))
+
+	info := "The file will contain:\n"
+	info += "  • School information (name, location, contact, enrollment, etc.)\n"
+	if m.enhancedData != nil {
+		info += "  • AI-extracted data (principal, programs, activities, etc.)\n"
+	}
+	if isNoteFilename(m.saveInput.Value()) {
+		info += "\nFormat: Markdown note with YAML frontmatter (Obsidian, Notion)"
+	} else {
+		info += "\nFormat: JSON"
+	}
+	b.WriteString(infoStyle.Render(info))
+	b.WriteString("\n\n")
+
+	// Error display
+	if m.err != nil {
+		errorStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("196"))
+		b.WriteString(errorStyle.Render("Error: " + errorText(m.err) + "\n"))
+	}
+
+	// Help text
+	helpStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("241")).
+		MarginTop(1)
+
+	help := "Enter: Save | Tab: JSON/Markdown note | Esc: Cancel | Ctrl+C: Quit"
+	b.WriteString(helpStyle.Render(help))
+
+	return b.String()
+}
+
+// launchTUI starts the interactive TUI application
+func launchTUI(dataDir string) {
+	// Setup logger first
+	if err := setupLogger(dataDir); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to setup logger: %v\n", err)
 	}
 
 	// Check for required data files
@@ -1623,8 +2718,8 @@ func launchTUI(dataDir string) {
 	}
 	defer db.Close()
 
-	// Initialize AI scraper (optional - requires ANTHROPIC_API_KEY)
-	apiKey := os.Getenv("ANTHROPIC_API_KEY")
+	// Initialize AI scraper (optional - requires an Anthropic API key)
+	apiKey := anthropicAPIKey()
 	var aiScraper *AIScraperService
 	if apiKey != "" {
 		aiScraper, err = NewAIScraperService(apiKey, db)
@@ -1647,15 +2742,20 @@ func launchTUI(dataDir string) {
 	} else {
 		fmt.Println("   • NAEP Auto-Fetch: ✗ Disabled (unset NAEP_AUTO_FETCH to enable)")
 	}
-	if os.Getenv("ANTHROPIC_API_KEY") != "" {
+	if anthropicAPIKey() != "" {
 		fmt.Println("   • AI Website Scraper: ✓ Available")
 	} else {
-		fmt.Println("   • AI Website Scraper: ✗ Not configured (set ANTHROPIC_API_KEY)")
+		fmt.Println("   • AI Website Scraper: ✗ Not configured (run schoolfinder auth set anthropic)")
 	}
 	fmt.Println()
 
+	m := initialModel(db, aiScraper, naepClient, dataDir)
+	if checkEnv := os.Getenv("CCD_RELEASE_CHECK"); checkEnv != "0" && checkEnv != "false" && checkEnv != "no" {
+		m.releaseChecker = NewCCDReleaseChecker()
+	}
+
 	p := tea.NewProgram(
-		initialModel(db, aiScraper, naepClient, dataDir),
+		m,
 		tea.WithAltScreen(),
 		tea.WithMouseCellMotion(),
 	)
@@ -1668,6 +2768,14 @@ func launchTUI(dataDir string) {
 
 // initDB initializes the database for CLI commands
 func initDB(dataDir string) (cmd.DBInterface, func(), error) {
+	if server, token := cmd.RemoteServer(); server != "" {
+		remote, err := newRemoteDB(server, token)
+		if err != nil {
+			return nil, nil, err
+		}
+		return remote, func() { remote.Close() }, nil
+	}
+
 	// Setup logger
 	if err := setupLogger(dataDir); err != nil {
 		fmt.Fprintf(os.Stderr, "Warning: Failed to setup logger: %v\n", err)
@@ -1699,12 +2807,19 @@ func initDB(dataDir string) (cmd.DBInterface, func(), error) {
 
 // initAIScraper initializes the AI scraper for CLI commands
 func initAIScraper(db cmd.DBInterface) (cmd.AIScraperInterface, error) {
-	apiKey := os.Getenv("ANTHROPIC_API_KEY")
+	if remote, ok := db.(*remoteDB); ok {
+		return &remoteScraper{db: remote}, nil
+	}
+
+	apiKey := anthropicAPIKey()
 	if apiKey == "" {
-		return nil, fmt.Errorf("ANTHROPIC_API_KEY environment variable not set")
+		return nil, ErrAINotConfigured
 	}
 
-	adapter := db.(*dbAdapter)
+	adapter, ok := db.(*dbAdapter)
+	if !ok {
+		return nil, ErrNeedsLocalDB
+	}
 	aiScraper, err := NewAIScraperService(apiKey, adapter.db)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize AI scraper: %w", err)
@@ -1805,10 +2920,119 @@ func convertSchoolToCmd(s School) cmd.SchoolData {
 	if s.Enrollment.Valid {
 		data.Enrollment = &s.Enrollment.Int64
 	}
+	for _, f := range correctionFields {
+		if s.IsCorrected(f.Field) {
+			data.CorrectedFields = append(data.CorrectedFields, f.Field)
+		}
+	}
 
 	return data
 }
 
+// scrapeDistrict extracts a district's leadership and enrollment contacts for scrape --district
+func scrapeDistrict(dbInterface cmd.DBInterface, leaid string) (*cmd.DistrictContactsJSON, error) {
+	adapter, ok := dbInterface.(*dbAdapter)
+	if !ok {
+		return nil, ErrNeedsLocalDB
+	}
+
+	district, err := adapter.db.GetDistrictByID(leaid)
+	if err != nil {
+		return nil, err
+	}
+	scraper, err := NewAIScraperService(anthropicAPIKey(), adapter.db)
+	if err != nil {
+		return nil, err
+	}
+	contacts, err := scraper.ScrapeDistrictContacts(context.Background(), district)
+	if err != nil {
+		return nil, err
+	}
+
+	toCmd := func(contacts []StaffContact) []cmd.StaffContact {
+		result := make([]cmd.StaffContact, 0, len(contacts))
+		for _, c := range contacts {
+			result = append(result, cmd.StaffContact{Name: c.Name, Title: c.Title, Email: c.Email, Phone: c.Phone, Department: c.Department})
+		}
+		return result
+	}
+	result := &cmd.DistrictContactsJSON{
+		LEAID:            contacts.LEAID,
+		DistrictName:     contacts.DistrictName,
+		SourceURL:        contacts.SourceURL,
+		ExtractedAt:      contacts.ExtractedAt.Format(time.RFC3339),
+		BoardMembers:     toCmd(contacts.BoardMembers),
+		EnrollmentOffice: toCmd(contacts.EnrollmentOffice),
+		MarkdownContent:  contacts.MarkdownContent,
+	}
+	if contacts.Superintendent != nil {
+		result.Superintendent = &toCmd([]StaffContact{*contacts.Superintendent})[0]
+	}
+	return result, nil
+}
+
+// scrapeSchool extracts a school's website data for scrape, reusing data
+// cached within the cache TTL unless forceRefresh is set. A stale cache entry
+// is scraped again in the foreground, as the CLI exits before a background
+// refresh could finish. Against a server, the server always extracts afresh.
+func scrapeSchool(dbInterface cmd.DBInterface, ncessch string, forceRefresh bool) (*cmd.EnhancedSchoolDataJSON, error) {
+	if remote, ok := dbInterface.(*remoteDB); ok {
+		school, err := remote.GetSchoolByID(ncessch)
+		if err != nil {
+			return nil, err
+		}
+		if school == nil {
+			return nil, fmt.Errorf("no school found with ID %s", ncessch)
+		}
+		return (&remoteScraper{db: remote}).ExtractSchoolDataWithWebSearch(school)
+	}
+	adapter, ok := dbInterface.(*dbAdapter)
+	if !ok {
+		return nil, ErrNeedsLocalDB
+	}
+
+	school, err := adapter.db.GetSchoolByID(ncessch)
+	if err != nil {
+		return nil, err
+	}
+	if !school.Website.Valid || school.Website.String == "" {
+		return nil, ErrNoWebsite
+	}
+	apiKey := anthropicAPIKey()
+	if apiKey == "" {
+		return nil, ErrAINotConfigured
+	}
+	scraper, err := NewAIScraperService(apiKey, adapter.db)
+	if err != nil {
+		return nil, err
+	}
+
+	adapter.db.RecordUsage(usageScrape)
+	if !forceRefresh {
+		if cached, err := scraper.loadFromCache(ncessch); err == nil {
+			return convertEnhancedToCmd(cached), nil
+		}
+	}
+	data, err := scraper.scrapeFresh(context.Background(), school, schoolWebsiteURL(school))
+	if err != nil {
+		return nil, err
+	}
+	return convertEnhancedToCmd(data), nil
+}
+
+// scrapeExitCode maps a scrape failure to the command's exit status
+func scrapeExitCode(err error) int {
+	switch {
+	case isUserError(err, ErrNoWebsite):
+		return cmd.ExitNoWebsite
+	case isUserError(err, ErrAINotConfigured):
+		return 1
+	case isUpstreamError(err):
+		return cmd.ExitUpstream
+	}
+	return 1
+}
+
 // aiScraperAdapter adapts *AIScraperService to cmd.AIScraperInterface
 type aiScraperAdapter struct {
 	scraper *AIScraperService
@@ -1816,6 +3040,7 @@ type aiScraperAdapter struct {
 
 func (a *aiScraperAdapter) ExtractSchoolDataWithWebSearch(school *cmd.SchoolData) (*cmd.EnhancedSchoolDataJSON, error) {
 	mainSchool := convertCmdToSchool(school)
+	a.scraper.db.RecordUsage(usageScrape)
 	enhanced, err := a.scraper.ExtractSchoolDataWithWebSearch(context.Background(), &mainSchool)
 	if err != nil {
 		return nil, err
@@ -1901,17 +3126,37 @@ func convertEnhancedToCmd(e *EnhancedSchoolData) *cmd.EnhancedSchoolDataJSON {
 		SpecialPrograms: e.SpecialPrograms,
 		Languages:       e.Languages,
 		Sports:          e.Sports,
+		Conference:      e.AthleticConference,
 		Clubs:           e.Clubs,
 		Arts:            e.Arts,
 		Facilities:      e.Facilities,
 		BellSchedule:    e.BellSchedule,
 		SchoolHours:     e.SchoolHours,
+		BeforeCare:      (*cmd.CareProgram)(e.BeforeCare),
+		AfterCare:       (*cmd.CareProgram)(e.AfterCare),
 		Achievements:    e.Achievements,
 		Accreditations:  e.Accreditations,
 		Mission:         e.Mission,
 		Notes:           e.Notes,
 	}
 
+	for _, p := range e.CTEPathways {
+		data.CTEPathways = append(data.CTEPathways, cmd.CTEPathway(p))
+	}
+	for _, p := range e.DualEnrollment {
+		data.DualEnrollment = append(data.DualEnrollment, cmd.DualEnrollment(p))
+	}
+	for _, o := range e.SportOfferings() {
+		data.Athletics = append(data.Athletics, cmd.SportOffering(o))
+	}
+	for _, o := range e.LanguageOfferings() {
+		data.LanguagePrograms = append(data.LanguagePrograms, cmd.LanguageOffering(o))
+	}
+	for _, o := range e.ArtsOfferings() {
+		data.ArtsPrograms = append(data.ArtsPrograms, cmd.ArtsOffering(o))
+	}
+	data.ArtsCoverage = e.ArtsCoverage().Score()
+
 	for _, contact := range e.StaffContacts {
 		data.StaffContacts = append(data.StaffContacts, cmd.StaffContact{
 			Name:       contact.Name,
@@ -1926,16 +3171,16 @@ func convertEnhancedToCmd(e *EnhancedSchoolData) *cmd.EnhancedSchoolDataJSON {
 }
 
 // startServer initializes and starts the web server
-func startServer(dbInterface cmd.DBInterface, port int, dataDir string) error {
+func startServer(dbInterface cmd.DBInterface, port int, dataDir string, dev bool) error {
 	// Extract the underlying *DB from the adapter
 	adapter, ok := dbInterface.(*dbAdapter)
 	if !ok {
-		return fmt.Errorf("invalid database interface type")
+		return ErrNeedsLocalDB
 	}
 
 	// Try to initialize AI scraper (optional)
 	var aiScraper *AIScraperService
-	apiKey := os.Getenv("ANTHROPIC_API_KEY")
+	apiKey := anthropicAPIKey()
 	if apiKey != "" {
 		var err error
 		aiScraper, err = NewAIScraperService(apiKey, adapter.db)
@@ -1945,30 +3190,1982 @@ func startServer(dbInterface cmd.DBInterface, port int, dataDir string) error {
 			fmt.Println("AI scraper initialized (API key found)")
 		}
 	} else {
-		fmt.Println("AI scraper disabled (ANTHROPIC_API_KEY not set)")
+		fmt.Println("AI scraper disabled (no Anthropic API key)")
 	}
 
 	// Initialize NAEP client
 	naepClient := NewNAEPClient(adapter.db)
 	fmt.Println("NAEP client initialized")
 
+	// Pick up saved search changes from reloaded data without delaying startup
+	go func() {
+		if _, err := CheckSavedSearches(adapter.db); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: saved search check failed: %v\n", err)
+		}
+	}()
+
+	// Check school websites in the background so dead links can be flagged.
+	// The demo has no network to check them with.
+	websiteChecker := newWebsiteCheckerFromEnv(adapter.db)
+	if !cmd.Demo() {
+		go websiteChecker.Run(context.Background())
+	}
+
+	// Remind about emails to schools that are due a follow-up
+	outreachNudger := newOutreachNudgerFromEnv(adapter.db)
+	if outreachNudger != nil && !cmd.Demo() {
+		go outreachNudger.Run(context.Background())
+	}
+
+	users, err := loadUserStore(filepath.Join(dataDir, "users.json"))
+	if err != nil {
+		return err
+	}
+
 	config := ServerConfig{
 		Port:       port,
 		DB:         adapter.db,
 		AIScraper:  aiScraper,
 		NAEPClient: naepClient,
 		DataPath:   dataDir,
+		Dev:        dev,
+		Demo:       cmd.Demo(),
+
+		AdminPassword: os.Getenv("SCHOOLFINDER_ADMIN_PASSWORD"),
+		Users:         users,
+		Notifier:      newSuggestionNotifierFromEnv(),
+
+		WebsiteChecker: websiteChecker,
+		OutreachNudges: outreachNudger != nil && !cmd.Demo(),
+		RateLimiter:    newRateLimiterFromEnv(),
+	}
+	if config.AdminPassword != "" || users.Len() > 0 {
+		fmt.Printf("Multi-user mode: %d accounts; visitors are viewers, who suggest corrections for review at /admin/corrections\n", users.Len())
+	}
+	if config.RateLimiter != nil {
+		fmt.Printf("Rate limiting AI and import requests to %s\n", config.RateLimiter)
 	}
 
 	return StartServer(config)
 }
 
+// schoolReport renders a school's dossier with a report template for the CLI,
+// from cached enrichment data
+func schoolReport(dbInterface cmd.DBInterface, ncessch, templatePath string) (string, error) {
+	adapter, ok := dbInterface.(*dbAdapter)
+	if !ok {
+		return "", ErrNeedsLocalDB
+	}
+
+	tmpl, err := LoadReportTemplate(templatePath)
+	if err != nil {
+		return "", err
+	}
+	school, err := adapter.db.GetSchoolByID(ncessch)
+	if err != nil {
+		return "", err
+	}
+
+	cacheReader := &AIScraperService{db: adapter.db}
+	enhanced, _ := cacheReader.loadCachedData(ncessch, cacheNoExpiry)
+	naepClient := NewNAEPClient(adapter.db)
+	naepData, _ := naepClient.loadCachedData(ncessch, cacheNoExpiry)
+
+	bundle, err := BuildSchoolBundle(adapter.db, school, enhanced, naepData)
+	if err != nil {
+		return "", err
+	}
+	report, err := RenderReport(tmpl, bundle, naepClient)
+	if err != nil {
+		return "", err
+	}
+	return string(report), nil
+}
+
+// generateTourQuestions builds tour questions for the CLI from cached enrichment data
+func generateTourQuestions(dbInterface cmd.DBInterface, ncessch string) (*cmd.TourQuestionsJSON, error) {
+	adapter, ok := dbInterface.(*dbAdapter)
+	if !ok {
+		return nil, ErrNeedsLocalDB
+	}
+
+	school, err := adapter.db.GetSchoolByID(ncessch)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only read caches here - the questions command never calls external services
+	// Expired entries are still useful for spotting gaps
+	cacheReader := &AIScraperService{db: adapter.db}
+	enhanced, _ := cacheReader.loadCachedData(ncessch, cacheNoExpiry)
+	naepData, _ := NewNAEPClient(adapter.db).loadCachedData(ncessch, cacheNoExpiry)
+
+	questions := GenerateTourQuestions(school, enhanced, naepData)
+
+	result := &cmd.TourQuestionsJSON{
+		NCESSCH:    school.NCESSCH,
+		SchoolName: school.Name,
+		Questions:  make([]cmd.TourQuestionJSON, 0, len(questions)),
+		Markdown:   FormatTourQuestionsMarkdown(school, questions),
+	}
+	for _, q := range questions {
+		result.Questions = append(result.Questions, cmd.TourQuestionJSON{
+			Category: q.Category,
+			Question: q.Question,
+			Reason:   q.Reason,
+		})
+	}
+
+	return result, nil
+}
+
+// runBenchmarks times the local database, saves the run, and compares it with the previous run
+func runBenchmarks(dbInterface cmd.DBInterface, iterations int) (*cmd.BenchReportJSON, error) {
+	adapter, ok := dbInterface.(*dbAdapter)
+	if !ok {
+		return nil, ErrNeedsLocalDB
+	}
+
+	previous, err := adapter.db.LatestBenchmarkRun()
+	if err != nil {
+		return nil, err
+	}
+
+	run, err := RunBenchmarks(adapter.db, iterations)
+	if err != nil {
+		return nil, err
+	}
+	if err := adapter.db.SaveBenchmarkRun(*run); err != nil {
+		return nil, err
+	}
+
+	report := &cmd.BenchReportJSON{
+		Version:      run.Version,
+		RunAt:        run.RunAt.Format(time.RFC3339),
+		WithinBudget: run.WithinBudget(),
+	}
+	if previous != nil {
+		report.PreviousVersion = previous.Version
+		report.PreviousRunAt = previous.RunAt.Format(time.RFC3339)
+	}
+
+	for _, r := range run.Results {
+		result := cmd.BenchResultJSON{
+			Name:         r.Name,
+			Iterations:   r.Iterations,
+			P50MS:        durationMS(r.P50),
+			P95MS:        durationMS(r.P95),
+			MaxMS:        durationMS(r.Max),
+			BudgetMS:     durationMS(r.Budget),
+			WithinBudget: r.WithinBudget(),
+			Skipped:      r.Skipped,
+		}
+		if previous != nil {
+			if prev, ok := previous.Result(r.Name); ok && prev.Skipped == "" {
+				p95 := durationMS(prev.P95)
+				result.PreviousP95MS = &p95
+			}
+		}
+		report.Results = append(report.Results, result)
+	}
+
+	return report, nil
+}
+
+// areaSummary summarizes a zip code or metro area's schools for the CLI
+func areaSummary(dbInterface cmd.DBInterface, kind, code string) (*cmd.AreaJSON, error) {
+	adapter, ok := dbInterface.(*dbAdapter)
+	if !ok {
+		return nil, ErrNeedsLocalDB
+	}
+
+	area, err := LoadAreaSummary(adapter.db, kind, code)
+	if err != nil {
+		return nil, err
+	}
+
+	e := area.Enrollment
+	result := &cmd.AreaJSON{
+		Kind:            area.Kind,
+		Code:            area.Code,
+		Name:            area.Title(),
+		SchoolCount:     area.SchoolCount,
+		Levels:          make([]cmd.AreaLevelJSON, 0, len(area.Levels)),
+		Enrollment:      cmd.EnrollmentDistributionJSON{Schools: e.Schools, Min: e.Min, Q1: e.Q1, Median: e.Median, Q3: e.Q3, Max: e.Max},
+		TotalEnrollment: area.TotalEnrollment,
+		Teachers:        area.Teachers,
+		States:          make([]cmd.AreaStateJSON, 0, len(area.States)),
+		Schools:         make([]cmd.AreaSchoolJSON, 0, len(area.Schools)),
+	}
+	if area.RatioSchools > 0 {
+		result.StudentsPerTeacher = &area.StudentsPerTeacher
+	}
+
+	for _, l := range area.Levels {
+		result.Levels = append(result.Levels, cmd.AreaLevelJSON{Level: l.Level, Count: l.Count})
+	}
+
+	for _, state := range area.States {
+		stateJSON := cmd.AreaStateJSON{
+			State:   state.Code,
+			Name:    state.Name,
+			Schools: state.Schools,
+			NAEP:    make([]cmd.AreaNAEPScoreJSON, 0, len(state.NAEP)),
+		}
+		for _, score := range state.NAEP {
+			scoreJSON := cmd.AreaNAEPScoreJSON{
+				Subject:      score.Subject,
+				Grade:        score.Grade,
+				Year:         score.Year,
+				MeanScore:    score.MeanScore,
+				AtProficient: score.AtProficient,
+			}
+			if score.NationalMeanScore > 0 {
+				scoreJSON.NationalMeanScore = &score.NationalMeanScore
+				scoreJSON.NationalProficient = &score.NationalProficient
+			}
+			stateJSON.NAEP = append(stateJSON.NAEP, scoreJSON)
+		}
+		result.States = append(result.States, stateJSON)
+	}
+
+	for _, s := range area.Schools {
+		school := cmd.AreaSchoolJSON{
+			NCESSCH: s.NCESSCH,
+			Name:    s.Name,
+			City:    s.City,
+			Level:   s.Level.String,
+		}
+		if s.GradeLow.Valid && s.GradeHigh.Valid {
+			school.Grades = s.GradeRangeString()
+		}
+		if s.Enrollment.Valid {
+			enrollment := s.Enrollment.Int64
+			school.Enrollment = &enrollment
+			if s.Teachers.Valid && s.Teachers.Float64 > 0 {
+				ratio := float64(enrollment) / s.Teachers.Float64
+				school.Ratio = &ratio
+			}
+		}
+		result.Schools = append(result.Schools, school)
+	}
+
+	return result, nil
+}
+
+// areaRollups totals schools per county or congressional district for the CLI
+func areaRollups(dbInterface cmd.DBInterface, kind, state string) ([]cmd.AreaRollupJSON, error) {
+	adapter, ok := dbInterface.(*dbAdapter)
+	if !ok {
+		return nil, ErrNeedsLocalDB
+	}
+
+	rollups, err := adapter.db.AreaRollups(kind, state)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]cmd.AreaRollupJSON, 0, len(rollups))
+	for _, r := range rollups {
+		rollup := cmd.AreaRollupJSON{
+			Code:           r.Key,
+			Name:           r.Name,
+			State:          r.State,
+			Schools:        r.Schools,
+			CharterSchools: r.CharterSchools,
+			Students:       r.Students,
+			Teachers:       r.Teachers,
+		}
+		if r.Ratio > 0 {
+			ratio := r.Ratio
+			rollup.StudentsPerTeacher = &ratio
+		}
+		result = append(result, rollup)
+	}
+	return result, nil
+}
+
+// diffYears compares two school years' directories for the CLI
+func diffYears(dbInterface cmd.DBInterface, from, to string) (*cmd.YearDiffJSON, error) {
+	adapter, ok := dbInterface.(*dbAdapter)
+	if !ok {
+		return nil, ErrNeedsLocalDB
+	}
+
+	diff, err := DiffSchoolYears(adapter.db, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	toJSON := func(changes []SchoolYearChange) []cmd.SchoolYearChangeJSON {
+		result := make([]cmd.SchoolYearChangeJSON, len(changes))
+		for i, c := range changes {
+			result[i] = cmd.SchoolYearChangeJSON{
+				NCESSCH:         c.NCESSCH,
+				Name:            c.Name,
+				State:           c.State,
+				City:            c.City,
+				PreviousNCESSCH: c.PreviousNCESSCH,
+				PreviousName:    c.PreviousName,
+			}
+		}
+		return result
+	}
+
+	return &cmd.YearDiffJSON{
+		FromYear:  diff.FromYear,
+		ToYear:    diff.ToYear,
+		FromCount: diff.FromCount,
+		ToCount:   diff.ToCount,
+		Opened:    toJSON(diff.Opened),
+		Closed:    toJSON(diff.Closed),
+		Renamed:   toJSON(diff.Renamed),
+		IDChanged: toJSON(diff.IDChanged),
+	}, nil
+}
+
+// usageStats reports usage tracking state and counts for the CLI
+func usageStats(dbInterface cmd.DBInterface) (*cmd.UsageStatsJSON, error) {
+	adapter, ok := dbInterface.(*dbAdapter)
+	if !ok {
+		return nil, ErrNeedsLocalDB
+	}
+
+	report, err := adapter.db.UsageReport()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &cmd.UsageStatsJSON{Enabled: report.Enabled}
+	if !report.ChangedAt.IsZero() {
+		result.ChangedAt = report.ChangedAt.Format(time.RFC3339)
+	}
+	if !report.UploadedThrough.IsZero() {
+		result.UploadedThrough = report.UploadedThrough.Format(time.DateOnly)
+	}
+	for _, c := range report.Totals {
+		result.Events = append(result.Events, cmd.UsageEventJSON{
+			Event:      c.Event,
+			Total:      c.Total,
+			Last30Days: c.Last30Days,
+		})
+	}
+	return result, nil
+}
+
+// reindex rebuilds the search index for the CLI
+func reindex(dbInterface cmd.DBInterface) (*cmd.ReindexJSON, error) {
+	adapter, ok := dbInterface.(*dbAdapter)
+	if !ok {
+		return nil, ErrNeedsLocalDB
+	}
+
+	stats, err := adapter.db.RebuildSearchIndex()
+	if err != nil {
+		return nil, err
+	}
+	return &cmd.ReindexJSON{
+		Schools:          stats.Schools,
+		CorrectedSchools: stats.Corrected,
+		WebsiteText:      stats.WebsiteText,
+		ImportedText:     stats.ImportedText,
+		FullText:         stats.FullText,
+		Seconds:          math.Round(stats.Duration.Seconds()*100) / 100,
+	}, nil
+}
+
+// dataVersions lists the loaded CCD files for db versions
+func dataVersions(dbInterface cmd.DBInterface) ([]cmd.DataVersionJSON, error) {
+	adapter, ok := dbInterface.(*dbAdapter)
+	if !ok {
+		return nil, ErrNeedsLocalDB
+	}
+
+	versions, err := adapter.db.DataVersions("")
+	if err != nil {
+		return nil, err
+	}
+	result := make([]cmd.DataVersionJSON, 0, len(versions))
+	for _, v := range versions {
+		result = append(result, cmd.DataVersionJSON{
+			Filename:   v.Filename,
+			Dataset:    v.Dataset,
+			SchoolYear: v.SchoolYear,
+			Released:   v.Released.Format("2006-01-02"),
+			LoadedAt:   v.LoadedAt.Format(time.RFC3339),
+			Rows:       v.Rows,
+		})
+	}
+	return result, nil
+}
+
+// ccdReleases checks NCES for new CCD releases for db releases, loading them with fetch
+func ccdReleases(dbInterface cmd.DBInterface, fetch bool) (*cmd.CCDReleasesJSON, error) {
+	adapter, ok := dbInterface.(*dbAdapter)
+	if !ok {
+		return nil, ErrNeedsLocalDB
+	}
+
+	ctx := context.Background()
+	checker := NewCCDReleaseChecker()
+	check, err := checker.Check(ctx, adapter.db)
+	if err != nil {
+		return nil, err
+	}
+	result := &cmd.CCDReleasesJSON{
+		CheckedAt: check.CheckedAt.Format(time.RFC3339),
+		Summary:   check.Summary(),
+		New:       make([]cmd.CCDReleaseJSON, 0, len(check.New)),
+	}
+	var loadable []CCDRelease
+	for _, r := range check.New {
+		result.New = append(result.New, cmd.CCDReleaseJSON{
+			Filename:   r.Filename,
+			Dataset:    r.Dataset,
+			SchoolYear: r.SchoolYear,
+			Released:   r.Released.Format("2006-01-02"),
+			URL:        r.URL,
+			Loadable:   r.Loadable(),
+		})
+		if r.Loadable() {
+			loadable = append(loadable, r)
+		}
+	}
+	if !fetch || len(loadable) == 0 {
+		return result, nil
+	}
+
+	fetched, err := checker.Fetch(ctx, adapter.db, loadable)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range fetched {
+		result.Fetched = append(result.Fetched, cmd.DataVersionJSON{
+			Filename:   v.Filename,
+			Dataset:    v.Dataset,
+			SchoolYear: v.SchoolYear,
+			Released:   v.Released.Format("2006-01-02"),
+			LoadedAt:   v.LoadedAt.Format(time.RFC3339),
+			Rows:       v.Rows,
+		})
+	}
+	result.Summary = fmt.Sprintf("Loaded %d CCD files alongside %s", len(fetched), currentSchoolYear())
+	return result, nil
+}
+
+// geocodeSchools backfills coordinates for db geocode, reporting progress on stderr
+func geocodeSchools(ctx context.Context, dbInterface cmd.DBInterface, opts cmd.GeocodeOptions) (*cmd.GeocodeBackfillJSON, error) {
+	adapter, ok := dbInterface.(*dbAdapter)
+	if !ok {
+		return nil, ErrNeedsLocalDB
+	}
+	provider, err := NewGeocodeProvider(opts.Provider)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := BackfillGeocodes(ctx, adapter.db, provider, GeocodeBackfillOptions{
+		Limit: opts.Limit,
+		Rate:  opts.Rate,
+		Retry: opts.Retry,
+		Progress: func(done, total int) {
+			if total > 0 {
+				fmt.Fprintf(os.Stderr, "\rGeocoding %d/%d", done, total)
+				if done == total {
+					fmt.Fprintln(os.Stderr)
+				}
+			}
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &cmd.GeocodeBackfillJSON{
+		Provider:    result.Provider,
+		Missing:     result.Missing,
+		Cached:      result.Cached,
+		Geocoded:    result.Geocoded,
+		Approximate: result.Approximate,
+		NotFound:    result.NotFound,
+		Failed:      result.Failed,
+		Located:     result.Located,
+		Schools:     result.Schools,
+		Coverage:    math.Round(result.Coverage()*10) / 10,
+	}, nil
+}
+
+// runDoctor runs the doctor checks on the local data directory
+func runDoctor(dataDir string, offline bool) *cmd.DoctorReportJSON {
+	if err := setupLogger(dataDir); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Failed to setup logger: %v\n", err)
+	}
+	var checker *CCDReleaseChecker
+	if !offline {
+		checker = NewCCDReleaseChecker()
+	}
+
+	report := &cmd.DoctorReportJSON{OK: true}
+	for _, c := range Diagnose(context.Background(), dataDir, checker) {
+		report.Checks = append(report.Checks, cmd.DoctorCheckJSON{Name: c.Name, Status: c.Status, Detail: c.Detail, Hint: c.Hint})
+		if c.Status == doctorFail {
+			report.OK = false
+		}
+	}
+	return report
+}
+
+// scrapeFeeders infers which schools a school feeds into for scrape --feeders
+func scrapeFeeders(dbInterface cmd.DBInterface, ncessch string) (*cmd.FeederInferenceJSON, error) {
+	adapter, ok := dbInterface.(*dbAdapter)
+	if !ok {
+		return nil, ErrNeedsLocalDB
+	}
+
+	school, err := adapter.db.GetSchoolByID(ncessch)
+	if err != nil {
+		return nil, err
+	}
+	scraper, err := NewAIScraperService(anthropicAPIKey(), adapter.db)
+	if err != nil {
+		return nil, err
+	}
+	inference, err := scraper.ScrapeFeeders(context.Background(), school)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &cmd.FeederInferenceJSON{
+		NCESSCH:    inference.NCESSCH,
+		SchoolName: inference.SchoolName,
+		InferredAt: inference.InferredAt.Format(time.RFC3339),
+		Inferred:   true,
+		Feeders:    []cmd.InferredFeederJSON{},
+	}
+	for _, f := range inference.Feeders {
+		result.Feeders = append(result.Feeders, cmd.InferredFeederJSON{Name: f.ToName, NCESSCH: f.ToNCESSCH, Confidence: f.Confidence, Evidence: f.Evidence, SourceURL: f.SourceURL})
+	}
+	return result, nil
+}
+
+// feederPipeline finds the schools assigned to an address for the pipeline command
+func feederPipeline(dbInterface cmd.DBInterface, address string) (*cmd.FeederPipelineJSON, error) {
+	adapter, ok := dbInterface.(*dbAdapter)
+	if !ok {
+		return nil, ErrNeedsLocalDB
+	}
+
+	location, err := ResolveLocation(context.Background(), adapter.db, NewGeocoder(), address)
+	if err != nil {
+		return nil, err
+	}
+	pipeline, err := BuildFeederPipeline(adapter.db, *location)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &cmd.FeederPipelineJSON{Location: location.String(), Lat: location.Lat, Lon: location.Lon}
+	for _, stage := range pipeline.Stages {
+		s := cmd.PipelineStageJSON{Stage: stage.Stage, Grade: stage.Grade, Source: stage.Source, FeedsFrom: stage.FeedsFrom, Confidence: stage.Confidence, Note: stage.Note}
+		if stage.School != nil {
+			s.NCESSCH = stage.School.NCESSCH
+			s.Name = stage.School.Name
+			s.Grades = gradeLabel(stage.School.GradeLow.String) + "-" + gradeLabel(stage.School.GradeHigh.String)
+		}
+		result.Stages = append(result.Stages, s)
+	}
+	return result, nil
+}
+
+// schoolCalendarJSON converts a school's calendar for the calendar command
+func schoolCalendarJSON(calendar *SchoolCalendar) cmd.SchoolCalendarJSON {
+	result := cmd.SchoolCalendarJSON{
+		NCESSCH:     calendar.NCESSCH,
+		SchoolName:  calendar.SchoolName,
+		SchoolYear:  calendar.SchoolYear,
+		SourceURL:   calendar.SourceURL,
+		ExtractedAt: calendar.ExtractedAt.Format(time.RFC3339),
+		Events:      []cmd.CalendarEventJSON{},
+	}
+	for _, e := range calendar.Events {
+		result.Events = append(result.Events, cmd.CalendarEventJSON{Kind: e.Kind, Name: e.Name, Start: e.Start.Format(timelineDateLayout), End: e.End.Format(timelineDateLayout)})
+	}
+	return result
+}
+
+// compareSchools lines up schools field by field for compare, from the
+// directory and cached data only
+func compareSchools(dbInterface cmd.DBInterface, ncesschList []string) (*cmd.SchoolComparisonJSON, error) {
+	adapter, ok := dbInterface.(*dbAdapter)
+	if !ok {
+		return nil, ErrNeedsLocalDB
+	}
+	if err := ValidateCompareCount(len(ncesschList)); err != nil {
+		return nil, err
+	}
+
+	cacheReader := &AIScraperService{db: adapter.db}
+	naepClient := NewNAEPClient(adapter.db)
+	inputs := make([]CompareSchoolInput, len(ncesschList))
+	ids := make([]string, len(ncesschList))
+	for i, ncessch := range ncesschList {
+		school, err := adapter.db.GetSchoolByID(ncessch)
+		if err != nil {
+			return nil, fmt.Errorf("school %s: %w", ncessch, err)
+		}
+		// Expired entries are still worth comparing
+		enhanced, _ := cacheReader.loadCachedData(school.NCESSCH, cacheNoExpiry)
+		naepData, _ := naepClient.loadCachedData(school.NCESSCH, cacheNoExpiry)
+		inputs[i] = CompareSchoolInput{School: school, Enhanced: enhanced, NAEP: naepData}
+		ids[i] = school.NCESSCH
+	}
+	percentiles, err := adapter.db.SchoolPercentiles(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	comparison := CompareSchools(inputs, percentiles)
+	result := &cmd.SchoolComparisonJSON{Schools: []cmd.ComparedSchoolJSON{}, Rows: []cmd.ComparisonRowJSON{}}
+	for _, school := range comparison.Schools {
+		result.Schools = append(result.Schools, cmd.ComparedSchoolJSON{NCESSCH: school.NCESSCH, SchoolName: school.Name})
+	}
+	for _, row := range comparison.Rows {
+		result.Rows = append(result.Rows, cmd.ComparisonRowJSON(row))
+	}
+	return result, nil
+}
+
+// charterMatch compares a charter school with matched traditional public
+// schools for the charter-match command
+func charterMatch(dbInterface cmd.DBInterface, ncessch string, options cmd.MatchOptions) (*cmd.CharterMatchJSON, error) {
+	adapter, ok := dbInterface.(*dbAdapter)
+	if !ok {
+		return nil, ErrNeedsLocalDB
+	}
+	criteria := MatchCriteria{
+		Area:                 options.Area,
+		AnyLevel:             options.AnyLevel,
+		SizeTolerance:        options.SizeTolerancePct / 100,
+		DemographicTolerance: options.DemographicTolerance,
+		Limit:                options.Limit,
+	}
+	match, err := adapter.db.MatchCharterSchool(ncessch, criteria)
+	if err != nil {
+		return nil, err
+	}
+	comparison, err := adapter.db.CharterMatchComparison(match)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &cmd.CharterMatchJSON{
+		Charter: cmd.ComparedSchoolJSON{NCESSCH: match.Charter.NCESSCH, SchoolName: match.Charter.Name},
+		Criteria: cmd.MatchCriteriaJSON{
+			Area:                 criteria.Area,
+			SameLevel:            !criteria.AnyLevel,
+			SizeTolerancePct:     options.SizeTolerancePct,
+			DemographicTolerance: criteria.DemographicTolerance,
+			Limit:                criteria.Limit,
+		},
+		Steps:   []cmd.MatchStepJSON{},
+		Notes:   []string{},
+		Matches: []cmd.SchoolMatchJSON{},
+		Schools: []cmd.ComparedSchoolJSON{},
+		Rows:    []cmd.ComparisonRowJSON{},
+	}
+	for _, step := range match.Steps {
+		result.Steps = append(result.Steps, cmd.MatchStepJSON(step))
+	}
+	result.Notes = append(result.Notes, match.Notes...)
+	for _, m := range match.Matches {
+		matchJSON := cmd.SchoolMatchJSON{
+			NCESSCH:           m.School.NCESSCH,
+			SchoolName:        m.School.Name,
+			SizeDifferencePct: math.Round(m.SizeDifference * 100),
+		}
+		if !math.IsNaN(m.DemographicDifference) {
+			points := math.Round(m.DemographicDifference*10) / 10
+			matchJSON.DemographicDifference = &points
+		}
+		result.Matches = append(result.Matches, matchJSON)
+	}
+	for _, school := range comparison.Schools {
+		result.Schools = append(result.Schools, cmd.ComparedSchoolJSON{NCESSCH: school.NCESSCH, SchoolName: school.Name})
+	}
+	for _, row := range comparison.Rows {
+		result.Rows = append(result.Rows, cmd.ComparisonRowJSON(row))
+	}
+	return result, nil
+}
+
+// randomSchool picks a random school for the random command, or the school
+// of the day with daily
+func randomSchool(dbInterface cmd.DBInterface, state, level string, daily bool) (*cmd.SchoolPickJSON, error) {
+	adapter, ok := dbInterface.(*dbAdapter)
+	if !ok {
+		return nil, ErrNeedsLocalDB
+	}
+	seed := ""
+	if daily {
+		seed = schoolOfTheDaySeed(time.Now())
+	}
+	pick, err := adapter.db.RandomSchool(RandomSchoolFilter{State: state, Level: level}, seed)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &cmd.SchoolPickJSON{
+		School:       convertSchoolToCmd(*pick.School),
+		Highlights:   []string{},
+		QualityFlags: []string{},
+	}
+	result.Highlights = append(result.Highlights, pick.Highlights...)
+	for _, f := range pick.Quality {
+		result.QualityFlags = append(result.QualityFlags, f.Label())
+	}
+	return result, nil
+}
+
+// syncWithPeer merges caches, saved schools, and notes with another instance for
+// the sync command
+func syncWithPeer(dbInterface cmd.DBInterface, peer, token string, full bool) (*cmd.SyncResultJSON, error) {
+	adapter, ok := dbInterface.(*dbAdapter)
+	if !ok {
+		return nil, ErrNeedsLocalDB
+	}
+	r, err := SyncWithPeer(adapter.db, peer, token, full, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	result := &cmd.SyncResultJSON{
+		Peer:   r.Peer,
+		Pulled: cmd.SyncCountsJSON(r.Pulled),
+		Pushed: cmd.SyncCountsJSON(r.Pushed),
+	}
+	if !r.Since.IsZero() {
+		result.Since = r.Since.Format(time.RFC3339)
+	}
+	return result, nil
+}
+
+// setSecret stores a secret for the auth set command, returning where
+func setSecret(name, value string) (string, error) {
+	store, err := defaultSecretStore()
+	if err != nil {
+		return "", err
+	}
+	return store.Set(name, value)
+}
+
+// removeSecret removes a stored secret for the auth remove command
+func removeSecret(name string) error {
+	store, err := defaultSecretStore()
+	if err != nil {
+		return err
+	}
+	return store.Delete(name)
+}
+
+// secretStatuses reports where each secret comes from for the auth command
+func secretStatuses() []cmd.SecretStatusJSON {
+	// Without a config directory, only environment variables are reported
+	store, _ := defaultSecretStore()
+	statuses := store.Statuses()
+	result := make([]cmd.SecretStatusJSON, len(statuses))
+	for i, s := range statuses {
+		result[i] = cmd.SecretStatusJSON(s)
+	}
+	return result
+}
+
+// compareCalendars lines up schools' calendars for the calendar command,
+// defaulting to every child's saved schools
+func compareCalendars(dbInterface cmd.DBInterface, ncesschList []string) (*cmd.CalendarComparisonJSON, error) {
+	adapter, ok := dbInterface.(*dbAdapter)
+	if !ok {
+		return nil, ErrNeedsLocalDB
+	}
+
+	ids := ncesschList
+	if len(ids) == 0 {
+		var err error
+		if ids, err = favoriteSchoolIDs(adapter.db); err != nil {
+			return nil, err
+		}
+		if len(ids) == 0 {
+			return nil, fmt.Errorf("no schools given and no children have saved schools")
+		}
+	}
+	schools, err := adapter.db.GetSchoolsInOrder(ids)
+	if err != nil {
+		return nil, err
+	}
+	comparison, err := CompareCalendars(adapter.db, schools)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &cmd.CalendarComparisonJSON{Schools: []cmd.SchoolCalendarJSON{}, Mismatches: []cmd.CalendarMismatchJSON{}}
+	if !comparison.Start.IsZero() {
+		result.Start = comparison.Start.Format(timelineDateLayout)
+		result.End = comparison.End.Format(timelineDateLayout)
+	}
+	for _, row := range comparison.Rows {
+		if row.Calendar == nil {
+			result.Schools = append(result.Schools, cmd.SchoolCalendarJSON{NCESSCH: row.School.NCESSCH, SchoolName: row.School.Name, Events: []cmd.CalendarEventJSON{}})
+			continue
+		}
+		result.Schools = append(result.Schools, schoolCalendarJSON(row.Calendar))
+	}
+	for _, m := range comparison.Mismatches {
+		result.Mismatches = append(result.Mismatches, cmd.CalendarMismatchJSON{Start: m.Start.Format(timelineDateLayout), End: m.End.Format(timelineDateLayout), Off: m.Off, InSession: m.InSession})
+	}
+	return result, nil
+}
+
+// extractCalendar finds a school's academic calendar with AI for calendar extract
+func extractCalendar(dbInterface cmd.DBInterface, ncessch string) (*cmd.SchoolCalendarJSON, error) {
+	adapter, ok := dbInterface.(*dbAdapter)
+	if !ok {
+		return nil, ErrNeedsLocalDB
+	}
+
+	school, err := adapter.db.GetSchoolByID(ncessch)
+	if err != nil {
+		return nil, err
+	}
+	scraper, err := NewAIScraperService(anthropicAPIKey(), adapter.db)
+	if err != nil {
+		return nil, err
+	}
+	calendar, err := scraper.ScrapeCalendar(context.Background(), school)
+	if err != nil {
+		return nil, err
+	}
+	result := schoolCalendarJSON(calendar)
+	return &result, nil
+}
+
+// exportCalendar writes a school's academic calendar as iCalendar for calendar ics
+func exportCalendar(dbInterface cmd.DBInterface, w io.Writer, ncessch string) error {
+	adapter, ok := dbInterface.(*dbAdapter)
+	if !ok {
+		return ErrNeedsLocalDB
+	}
+
+	calendar, err := adapter.db.LoadSchoolCalendar(ncessch)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("no calendar for school %s; run calendar extract %s first", ncessch, ncessch)
+	}
+	if err != nil {
+		return err
+	}
+	var location string
+	if school, err := adapter.db.GetSchoolByID(ncessch); err == nil {
+		location = schoolDateLocation(school)
+	}
+	return WriteCalendarICS(w, calendar, location, time.Now())
+}
+
+// asOfQuery pins a query to a school year for query --as-of
+func asOfQuery(dbInterface cmd.DBInterface, query, year string) (string, error) {
+	adapter, ok := dbInterface.(*dbAdapter)
+	if !ok {
+		return "", ErrNeedsLocalDB
+	}
+	return adapter.db.AsOfQuery(query, year)
+}
+
+// statsOverview loads the statistics overview for the CLI
+func statsOverview(dbInterface cmd.DBInterface) (*cmd.StatsOverviewJSON, error) {
+	adapter, ok := dbInterface.(*dbAdapter)
+	if !ok {
+		return nil, ErrNeedsLocalDB
+	}
+
+	overview, err := adapter.db.StatsOverview()
+	if err != nil {
+		return nil, err
+	}
+
+	toJSON := func(s OverviewStat) cmd.OverviewStatJSON {
+		stat := cmd.OverviewStatJSON{
+			Key:            s.Key,
+			Name:           s.Name,
+			State:          s.State,
+			Schools:        s.Schools,
+			CharterSchools: s.CharterSchools,
+			CharterShare:   math.Round(s.CharterShare()*10) / 10,
+			Students:       s.Students,
+		}
+		if s.Ratio > 0 {
+			ratio := math.Round(s.Ratio*10) / 10
+			stat.Ratio = &ratio
+		}
+		return stat
+	}
+	result := &cmd.StatsOverviewJSON{
+		ComputedAt: overview.ComputedAt.Format(time.RFC3339),
+		National:   toJSON(overview.National),
+		States:     []cmd.OverviewStatJSON{},
+		Levels:     []cmd.OverviewStatJSON{},
+		Districts:  []cmd.OverviewStatJSON{},
+	}
+	for _, s := range overview.States {
+		result.States = append(result.States, toJSON(s))
+	}
+	for _, s := range overview.Levels {
+		result.Levels = append(result.Levels, toJSON(s))
+	}
+	for _, s := range overview.Districts {
+		result.Districts = append(result.Districts, toJSON(s))
+	}
+	return result, nil
+}
+
+// setUsageTracking turns usage tracking on or off for the CLI
+func setUsageTracking(dbInterface cmd.DBInterface, enabled bool) error {
+	adapter, ok := dbInterface.(*dbAdapter)
+	if !ok {
+		return ErrNeedsLocalDB
+	}
+	return adapter.db.SetUsageTracking(enabled)
+}
+
+// uploadUsage sends, or with dryRun only builds, the next usage upload for the CLI
+func uploadUsage(dbInterface cmd.DBInterface, url string, dryRun bool) (*cmd.UsagePayloadJSON, error) {
+	adapter, ok := dbInterface.(*dbAdapter)
+	if !ok {
+		return nil, ErrNeedsLocalDB
+	}
+
+	var payload *UsagePayload
+	var err error
+	if dryRun {
+		payload, err = adapter.db.UsageUploadPayload()
+	} else {
+		payload, err = UploadUsage(context.Background(), adapter.db, usageUploadClient, url)
+	}
+	if err != nil || payload == nil {
+		return nil, err
+	}
+	return &cmd.UsagePayloadJSON{
+		Schema:  payload.Schema,
+		Version: payload.Version,
+		From:    payload.From,
+		To:      payload.To,
+		Counts:  payload.Counts,
+	}, nil
+}
+
+// recordUsage counts a CLI feature use if usage tracking is on
+func recordUsage(dbInterface cmd.DBInterface, event string) {
+	if adapter, ok := dbInterface.(*dbAdapter); ok {
+		adapter.db.RecordUsage(event)
+	}
+}
+
+// schoolDateToJSON converts a key date for the CLI
+func schoolDateToJSON(sd SchoolDate) cmd.SchoolDateJSON {
+	return cmd.SchoolDateJSON{
+		ID:         sd.ID,
+		NCESSCH:    sd.NCESSCH,
+		SchoolName: sd.SchoolName,
+		Type:       sd.Kind,
+		Label:      sd.Label(),
+		Date:       sd.Date.Format(timelineDateLayout),
+		Note:       sd.Note,
+	}
+}
+
+// listSchoolDates lists application timeline dates for the CLI
+func listSchoolDates(dbInterface cmd.DBInterface, ncessch string) ([]cmd.SchoolDateJSON, error) {
+	adapter, ok := dbInterface.(*dbAdapter)
+	if !ok {
+		return nil, ErrNeedsLocalDB
+	}
+
+	dates, err := adapter.db.SchoolDates(ncessch)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]cmd.SchoolDateJSON, len(dates))
+	for i, sd := range dates {
+		result[i] = schoolDateToJSON(sd)
+	}
+	return result, nil
+}
+
+// addSchoolDate records an application timeline date for the CLI
+func addSchoolDate(dbInterface cmd.DBInterface, ncessch, kind, date, note string) (*cmd.SchoolDateJSON, error) {
+	adapter, ok := dbInterface.(*dbAdapter)
+	if !ok {
+		return nil, ErrNeedsLocalDB
+	}
+
+	sd, err := AddSchoolDate(adapter.db, ncessch, kind, date, note)
+	if err != nil {
+		return nil, err
+	}
+	result := schoolDateToJSON(*sd)
+	return &result, nil
+}
+
+// removeSchoolDate deletes an application timeline date for the CLI
+func removeSchoolDate(dbInterface cmd.DBInterface, id int64) error {
+	adapter, ok := dbInterface.(*dbAdapter)
+	if !ok {
+		return ErrNeedsLocalDB
+	}
+	return adapter.db.DeleteSchoolDate(id)
+}
+
+// exportTimeline writes every school's key dates as iCalendar or CSV for the CLI
+func exportTimeline(dbInterface cmd.DBInterface, w io.Writer, format string) error {
+	adapter, ok := dbInterface.(*dbAdapter)
+	if !ok {
+		return ErrNeedsLocalDB
+	}
+
+	dates, err := adapter.db.SchoolDates("")
+	if err != nil {
+		return err
+	}
+	if format == "csv" {
+		return WriteTimelineCSV(w, dates)
+	}
+	return WriteTimelineICS(w, dates, time.Now())
+}
+
+// schoolCallToJSON converts a logged call for the CLI, with the school's name
+// and dialable phone when it's in the directory
+func schoolCallToJSON(call SchoolCall, school *School) cmd.SchoolCallJSON {
+	result := cmd.SchoolCallJSON{
+		ID:         call.ID,
+		NCESSCH:    call.NCESSCH,
+		SchoolName: call.NCESSCH,
+		CalledAt:   call.CalledAt.Format(callLogTimeLayout),
+		Note:       call.Note,
+	}
+	if school != nil {
+		result.SchoolName = school.Name
+		result.Phone = dialablePhone(school.Phone.String)
+	}
+	return result
+}
+
+// listSchoolCalls lists the call log for the CLI
+func listSchoolCalls(dbInterface cmd.DBInterface, ncessch string) ([]cmd.SchoolCallJSON, error) {
+	adapter, ok := dbInterface.(*dbAdapter)
+	if !ok {
+		return nil, ErrNeedsLocalDB
+	}
+
+	calls, err := adapter.db.SchoolCalls(ncessch)
+	if err != nil {
+		return nil, err
+	}
+	schools := make(map[string]*School)
+	result := make([]cmd.SchoolCallJSON, len(calls))
+	for i, call := range calls {
+		school, ok := schools[call.NCESSCH]
+		if !ok {
+			school, err = adapter.db.GetSchoolByID(call.NCESSCH)
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return nil, err
+			}
+			schools[call.NCESSCH] = school
+		}
+		result[i] = schoolCallToJSON(call, school)
+	}
+	return result, nil
+}
+
+// addSchoolCall logs a call to a school for the CLI
+func addSchoolCall(dbInterface cmd.DBInterface, ncessch, calledAt, note string) (*cmd.SchoolCallJSON, error) {
+	adapter, ok := dbInterface.(*dbAdapter)
+	if !ok {
+		return nil, ErrNeedsLocalDB
+	}
+
+	call, err := AddSchoolCall(adapter.db, ncessch, calledAt, note, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	school, err := adapter.db.GetSchoolByID(call.NCESSCH)
+	if err != nil {
+		return nil, err
+	}
+	result := schoolCallToJSON(*call, school)
+	return &result, nil
+}
+
+// removeSchoolCall deletes a logged call for the CLI
+func removeSchoolCall(dbInterface cmd.DBInterface, id int64) error {
+	adapter, ok := dbInterface.(*dbAdapter)
+	if !ok {
+		return ErrNeedsLocalDB
+	}
+	return adapter.db.DeleteSchoolCall(id)
+}
+
+// outreachToJSON converts an email to a school for the CLI
+func outreachToJSON(o Outreach, now time.Time) cmd.OutreachJSON {
+	result := cmd.OutreachJSON{
+		ID:          o.ID,
+		NCESSCH:     o.NCESSCH,
+		SchoolName:  o.SchoolName,
+		To:          o.To,
+		Subject:     o.Subject,
+		Template:    o.Template,
+		Status:      o.Status,
+		StatusLabel: o.StatusLabel(),
+		SentOn:      o.SentOn.Format(timelineDateLayout),
+		FollowUpDue: o.FollowUpDue(outreachDay(now)),
+		Note:        o.Note,
+	}
+	if !o.FollowUpOn.IsZero() {
+		result.FollowUpOn = o.FollowUpOn.Format(timelineDateLayout)
+	}
+	return result
+}
+
+// listOutreach lists emails to schools for the CLI
+func listOutreach(dbInterface cmd.DBInterface, ncessch string) ([]cmd.OutreachJSON, error) {
+	adapter, ok := dbInterface.(*dbAdapter)
+	if !ok {
+		return nil, ErrNeedsLocalDB
+	}
+
+	items, err := adapter.db.OutreachList(ncessch)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	result := make([]cmd.OutreachJSON, len(items))
+	for i, o := range items {
+		result[i] = outreachToJSON(o, now)
+	}
+	return result, nil
+}
+
+// addOutreach records an email sent to a school for the CLI
+func addOutreach(dbInterface cmd.DBInterface, ncessch string, input cmd.OutreachInput) (*cmd.OutreachJSON, error) {
+	adapter, ok := dbInterface.(*dbAdapter)
+	if !ok {
+		return nil, ErrNeedsLocalDB
+	}
+
+	o := &Outreach{NCESSCH: ncessch, To: input.To, Subject: input.Subject, Template: input.Template, Status: input.Status, Note: input.Note}
+	var err error
+	if o.SentOn, err = parseOutreachDate(input.SentOn, time.Time{}); err != nil {
+		return nil, err
+	}
+	if o.FollowUpOn, err = parseOutreachDate(input.FollowUpOn, time.Time{}); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if err := RecordOutreach(adapter.db, o, now); err != nil {
+		return nil, err
+	}
+	result := outreachToJSON(*o, now)
+	return &result, nil
+}
+
+// setOutreach changes an email's status, follow-up date, or note for the CLI
+func setOutreach(dbInterface cmd.DBInterface, id int64, input cmd.OutreachInput, changed []string) (*cmd.OutreachJSON, error) {
+	adapter, ok := dbInterface.(*dbAdapter)
+	if !ok {
+		return nil, ErrNeedsLocalDB
+	}
+
+	var update OutreachUpdate
+	for _, name := range changed {
+		switch name {
+		case "status":
+			update.Status = &input.Status
+		case "follow-up":
+			update.FollowUpOn = &input.FollowUpOn
+		case "note":
+			update.Note = &input.Note
+		}
+	}
+	o, err := UpdateOutreach(adapter.db, id, update)
+	if err != nil {
+		return nil, err
+	}
+	result := outreachToJSON(*o, time.Now())
+	return &result, nil
+}
+
+// removeOutreach stops tracking an email for the CLI
+func removeOutreach(dbInterface cmd.DBInterface, id int64) error {
+	adapter, ok := dbInterface.(*dbAdapter)
+	if !ok {
+		return ErrNeedsLocalDB
+	}
+	return adapter.db.DeleteOutreach(id)
+}
+
+// applicationToJSON converts a school choice application for the CLI
+func applicationToJSON(app Application) cmd.ApplicationJSON {
+	result := cmd.ApplicationJSON{
+		ID:          app.ID,
+		Season:      app.Season,
+		NCESSCH:     app.NCESSCH,
+		SchoolName:  app.SchoolName,
+		Status:      app.Status,
+		StatusLabel: app.StatusLabel(),
+		Priorities:  app.Priorities,
+		Seats:       app.Seats,
+		Applicants:  app.Applicants,
+		Weight:      app.Weight,
+		Notes:       app.Notes,
+	}
+	if odds, ok := app.Odds(); ok {
+		result.Odds = &odds
+	}
+	for _, sd := range app.Dates {
+		result.Dates = append(result.Dates, schoolDateToJSON(sd))
+	}
+	return result
+}
+
+// applicationsReport loads a season's applications, status summary, and
+// reminders for the next days for the CLI
+func applicationsReport(dbInterface cmd.DBInterface, season string, days int) (*cmd.ApplicationsReportJSON, error) {
+	adapter, ok := dbInterface.(*dbAdapter)
+	if !ok {
+		return nil, ErrNeedsLocalDB
+	}
+	if season == "" {
+		season = currentSeason(time.Now())
+	}
+
+	apps, err := adapter.db.Applications(season)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &cmd.ApplicationsReportJSON{
+		Season:       season,
+		Summary:      []cmd.ApplicationStatusCountJSON{},
+		Reminders:    []cmd.ApplicationReminderJSON{},
+		Applications: []cmd.ApplicationJSON{},
+	}
+	for _, c := range SummarizeApplications(apps) {
+		report.Summary = append(report.Summary, cmd.ApplicationStatusCountJSON{Status: c.Status, Label: c.Label, Count: c.Count})
+	}
+	for _, r := range ApplicationReminders(apps, time.Now(), time.Duration(days)*24*time.Hour) {
+		report.Reminders = append(report.Reminders, cmd.ApplicationReminderJSON{
+			Date:       r.Date.Format(timelineDateLayout),
+			NCESSCH:    r.NCESSCH,
+			SchoolName: r.SchoolName,
+			Message:    r.Message,
+			Overdue:    r.Overdue,
+		})
+	}
+	for _, app := range apps {
+		report.Applications = append(report.Applications, applicationToJSON(app))
+	}
+	return report, nil
+}
+
+// setApplication adds an application or changes its fields for the CLI
+func setApplication(dbInterface cmd.DBInterface, season, ncessch string, update cmd.ApplicationUpdateJSON) (*cmd.ApplicationJSON, error) {
+	adapter, ok := dbInterface.(*dbAdapter)
+	if !ok {
+		return nil, ErrNeedsLocalDB
+	}
+	if season == "" {
+		season = currentSeason(time.Now())
+	}
+
+	app, err := adapter.db.GetApplication(season, ncessch)
+	if errors.Is(err, sql.ErrNoRows) {
+		app = &Application{Season: season, NCESSCH: ncessch, Status: appPlanning, Weight: 1}
+	} else if err != nil {
+		return nil, err
+	}
+
+	if update.Status != nil {
+		app.Status = *update.Status
+	}
+	if update.Priorities != nil {
+		app.Priorities = *update.Priorities
+	}
+	if update.Seats != nil {
+		app.Seats = *update.Seats
+	}
+	if update.Applicants != nil {
+		app.Applicants = *update.Applicants
+	}
+	if update.Weight != nil {
+		app.Weight = *update.Weight
+	}
+	if update.Notes != nil {
+		app.Notes = *update.Notes
+	}
+	if err := SaveApplication(adapter.db, app); err != nil {
+		return nil, err
+	}
+
+	saved, err := adapter.db.GetApplication(app.Season, app.NCESSCH)
+	if err != nil {
+		return nil, err
+	}
+	result := applicationToJSON(*saved)
+	return &result, nil
+}
+
+// removeApplication deletes an application for the CLI
+func removeApplication(dbInterface cmd.DBInterface, season, ncessch string) error {
+	adapter, ok := dbInterface.(*dbAdapter)
+	if !ok {
+		return ErrNeedsLocalDB
+	}
+	if season == "" {
+		season = currentSeason(time.Now())
+	}
+	return adapter.db.DeleteApplication(season, ncessch)
+}
+
+// seasonRecap renders a season's applications as markdown for the CLI
+func seasonRecap(dbInterface cmd.DBInterface, season string) (string, error) {
+	adapter, ok := dbInterface.(*dbAdapter)
+	if !ok {
+		return "", ErrNeedsLocalDB
+	}
+	if season == "" {
+		season = currentSeason(time.Now())
+	}
+
+	apps, err := adapter.db.Applications(season)
+	if err != nil {
+		return "", err
+	}
+	return FormatSeasonRecap(season, apps), nil
+}
+
+// childToJSON converts a child profile for the CLI
+func childToJSON(c Child) cmd.ChildJSON {
+	return cmd.ChildJSON{ID: c.ID, Name: c.Name, Grade: c.Grade, GradeLabel: c.GradeLabel(), Needs: c.Needs, SavedSchools: c.Schools}
+}
+
+// listChildren loads child profiles for the CLI
+func listChildren(dbInterface cmd.DBInterface) ([]cmd.ChildJSON, error) {
+	adapter, ok := dbInterface.(*dbAdapter)
+	if !ok {
+		return nil, ErrNeedsLocalDB
+	}
+
+	children, err := adapter.db.Children()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]cmd.ChildJSON, len(children))
+	for i, c := range children {
+		result[i] = childToJSON(c)
+	}
+	return result, nil
+}
+
+// saveChild adds or updates a child profile for the CLI
+func saveChild(dbInterface cmd.DBInterface, name, grade string, needs []string) (*cmd.ChildJSON, error) {
+	adapter, ok := dbInterface.(*dbAdapter)
+	if !ok {
+		return nil, ErrNeedsLocalDB
+	}
+
+	child := &Child{Name: name, Grade: grade, Needs: needs}
+	if err := SaveChild(adapter.db, child); err != nil {
+		return nil, err
+	}
+	saved, err := adapter.db.GetChild(child.ID)
+	if err != nil {
+		return nil, err
+	}
+	result := childToJSON(*saved)
+	return &result, nil
+}
+
+// removeChild deletes a child profile for the CLI
+func removeChild(dbInterface cmd.DBInterface, id int64) error {
+	adapter, ok := dbInterface.(*dbAdapter)
+	if !ok {
+		return ErrNeedsLocalDB
+	}
+	return adapter.db.DeleteChild(id)
+}
+
+// setChildSchool saves or unsaves a school for a child for the CLI
+func setChildSchool(dbInterface cmd.DBInterface, id int64, ncessch string, saved bool) error {
+	adapter, ok := dbInterface.(*dbAdapter)
+	if !ok {
+		return ErrNeedsLocalDB
+	}
+	if saved {
+		return SaveChildSchool(adapter.db, id, ncessch)
+	}
+	return adapter.db.RemoveChildSchool(id, ncessch)
+}
+
+// saveDossiers writes a dossier for each school for search --save-dir,
+// including whatever AI and NAEP data is cached
+func saveDossiers(dbInterface cmd.DBInterface, ids []string, opts cmd.SaveDossiersOptions) (string, error) {
+	adapter, ok := dbInterface.(*dbAdapter)
+	if !ok {
+		return "", ErrNeedsLocalDB
+	}
+
+	schools := make([]School, 0, len(ids))
+	for _, id := range ids {
+		school, err := adapter.db.GetSchoolByID(id)
+		if err != nil {
+			return "", err
+		}
+		schools = append(schools, *school)
+	}
+
+	var aiScraper *AIScraperService
+	if apiKey := anthropicAPIKey(); apiKey != "" {
+		var err error
+		if aiScraper, err = NewAIScraperService(apiKey, adapter.db); err != nil {
+			return "", fmt.Errorf("failed to initialize AI scraper: %w", err)
+		}
+	}
+
+	bulk := BulkSaveOptions{
+		Dir:         opts.Dir,
+		Markdown:    opts.Markdown,
+		FetchNAEP:   opts.FetchNAEP,
+		FetchAI:     opts.FetchAI,
+		Concurrency: opts.Concurrency,
+		Search:      opts.Search,
+	}
+	if opts.Template != "" {
+		var err error
+		if bulk.Template, err = LoadReportTemplate(opts.Template); err != nil {
+			return "", err
+		}
+		bulk.TemplatePath = opts.Template
+	}
+
+	manifest, err := SaveDossiers(context.Background(), adapter.db, aiScraper, NewNAEPClient(adapter.db), schools, bulk)
+	if err != nil {
+		return "", err
+	}
+	return manifest.Summary(opts.Dir), nil
+}
+
+// listJobs lists recorded jobs for the CLI
+func listJobs(dbInterface cmd.DBInterface) ([]cmd.JobJSON, error) {
+	adapter, ok := dbInterface.(*dbAdapter)
+	if !ok {
+		return nil, ErrNeedsLocalDB
+	}
+
+	jobs, err := adapter.db.Jobs()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]cmd.JobJSON, len(jobs))
+	for i, j := range jobs {
+		result[i] = cmd.JobJSON{
+			ID:        j.ID,
+			Kind:      j.Kind,
+			Status:    j.Status,
+			Summary:   j.Summary,
+			Total:     j.Total,
+			Done:      j.Done,
+			Failed:    j.Failed,
+			Pending:   j.Pending,
+			Resumable: j.Resumable(),
+			Error:     j.Error,
+			CreatedAt: j.CreatedAt.Format(time.RFC3339),
+			UpdatedAt: j.UpdatedAt.Format(time.RFC3339),
+		}
+	}
+	return result, nil
+}
+
+// resumeJob finishes a bulk save that was cut short, for jobs resume
+func resumeJob(dbInterface cmd.DBInterface, id int64, retryFailed bool) (string, error) {
+	adapter, ok := dbInterface.(*dbAdapter)
+	if !ok {
+		return "", ErrNeedsLocalDB
+	}
+
+	var aiScraper *AIScraperService
+	if apiKey := anthropicAPIKey(); apiKey != "" {
+		var err error
+		if aiScraper, err = NewAIScraperService(apiKey, adapter.db); err != nil {
+			return "", fmt.Errorf("failed to initialize AI scraper: %w", err)
+		}
+	}
+
+	manifest, dir, err := ResumeBulkSave(context.Background(), adapter.db, aiScraper, NewNAEPClient(adapter.db), id, retryFailed)
+	if err != nil {
+		return "", err
+	}
+	return manifest.Summary(dir), nil
+}
+
+// runNotebook runs a notebook for notebook run. Agent queries use the data
+// explorer's agent, which needs ANTHROPIC_API_KEY only when one is asked.
+func runNotebook(dbInterface cmd.DBInterface, path, outDir, asOf string) (string, error) {
+	adapter, ok := dbInterface.(*dbAdapter)
+	if !ok {
+		return "", ErrNeedsLocalDB
+	}
+	nb, err := LoadNotebook(path)
+	if err != nil {
+		return "", err
+	}
+	if asOf != "" {
+		nb.AsOf = asOf
+		if err := nb.validate(); err != nil {
+			return "", err
+		}
+	}
+
+	asker := (&WebHandler{DB: adapter.db}).queryWithAI
+	run, err := RunNotebook(context.Background(), adapter.db, nb, path, outDir, asker)
+	if err != nil {
+		return "", err
+	}
+	if run.Error != "" {
+		return "", fmt.Errorf("%s; results so far are in %s", run.Error, outDir)
+	}
+	return fmt.Sprintf("Ran %d queries from %s on %s data; results are in %s (data %s)", len(run.Queries), filepath.Base(path), run.Data.SchoolYear, outDir, run.Data.Fingerprint), nil
+}
+
+// searchWithNeeds searches schools offering the given programs, care, pre-K,
+// CTE pathways, sports, and languages, optionally within a distance, for the CLI. For the children, it also limits results to
+// schools serving a child's grade and offering the programs their needs call
+// for, listing schools that fit more children first.
+func searchWithNeeds(dbInterface cmd.DBInterface, needs cmd.SearchNeeds) ([]cmd.SchoolData, error) {
+	adapter, ok := dbInterface.(*dbAdapter)
+	if !ok {
+		return nil, ErrNeedsLocalDB
+	}
+
+	filters := SearchFilters{
+		Query:       needs.Query,
+		State:       needs.State,
+		Care:        strings.ToLower(needs.Care),
+		Sector:      strings.ToLower(needs.Sector),
+		CTE:         strings.Join(needs.CTE, ","),
+		Sports:      strings.Join(needs.Sports, ","),
+		Conference:  needs.Conference,
+		WithinMiles: needs.WithinMiles,
+		Near:        needs.Near,
+		Language:    needs.Language,
+	}
+	if needs.PreK {
+		filters.PreK = "Yes"
+	}
+	if needs.Immersion {
+		filters.Immersion = "Yes"
+	}
+	programs := needs.Programs
+	var children []Child
+	if needs.ForChildren {
+		var err error
+		children, err = adapter.db.Children()
+		if err != nil {
+			return nil, err
+		}
+		if len(children) == 0 {
+			return nil, fmt.Errorf("no child profiles; add one with \"schoolfinder children add\"")
+		}
+		filters.ChildGrades = childGradeFilter(children)
+		programs = append(programs, programNeeds(children)...)
+	}
+	var flags []string
+	for _, program := range programs {
+		flag, err := ParseProgramFlag(program)
+		if err != nil {
+			return nil, err
+		}
+		if !slices.Contains(flags, flag) {
+			flags = append(flags, flag)
+		}
+	}
+	filters.Programs = strings.Join(flags, ",")
+	if err := filters.Validate(); err != nil {
+		return nil, err
+	}
+	filters, err := filters.ResolveNear(context.Background(), adapter.db, NewGeocoder())
+	if err != nil {
+		return nil, err
+	}
+
+	schools, err := adapter.db.SearchSchoolsFiltered(filters, needs.Limit)
+	if err != nil {
+		return nil, err
+	}
+	fits := ChildFits(schools, children)
+	RankForChildren(schools, fits)
+
+	result := make([]cmd.SchoolData, len(schools))
+	for i, s := range schools {
+		result[i] = convertSchoolToCmd(s)
+		for _, c := range fits[s.NCESSCH].Served {
+			result[i].FitsChildren = append(result[i].FitsChildren, c.Name)
+		}
+	}
+	return result, nil
+}
+
+// busRuleToJSON converts a bus eligibility rule for the CLI
+func busRuleToJSON(r BusRule) cmd.BusRuleJSON {
+	return cmd.BusRuleJSON{ID: r.ID, Scope: r.Scope, Grades: r.Grades(), Miles: r.Miles, Source: r.Source}
+}
+
+// estimateBus estimates bus eligibility from the saved home to a school for the CLI
+func estimateBus(dbInterface cmd.DBInterface, ncessch string) (*cmd.BusEstimateJSON, error) {
+	adapter, ok := dbInterface.(*dbAdapter)
+	if !ok {
+		return nil, ErrNeedsLocalDB
+	}
+
+	school, err := adapter.db.GetSchoolByID(ncessch)
+	if err != nil {
+		return nil, err
+	}
+	if home, err := adapter.db.Home(); err != nil {
+		return nil, err
+	} else if home == nil {
+		return nil, fmt.Errorf("no home location; set one with \"schoolfinder transport home\"")
+	}
+	estimate, err := adapter.db.SchoolBusEstimate(school, loadChildren(adapter.db))
+	if err != nil {
+		return nil, err
+	}
+	if estimate == nil {
+		return nil, fmt.Errorf("no coordinates for %s; add an %s file to the data directory", school.Name, geocodeFilePattern)
+	}
+
+	return &cmd.BusEstimateJSON{
+		NCESSCH:       school.NCESSCH,
+		SchoolName:    school.Name,
+		Status:        estimate.Status,
+		Estimate:      true,
+		DistanceMiles: math.Round(estimate.DistanceMiles*100) / 100,
+		Grade:         gradeLabel(estimate.Grade),
+		Rule:          busRuleToJSON(estimate.Rule),
+		DefaultRule:   estimate.DefaultRule,
+		Summary:       estimate.Summary(),
+		Note:          estimate.Note,
+	}, nil
+}
+
+// setHome saves the home location for the CLI
+func setHome(dbInterface cmd.DBInterface, coordinates, label string) (string, error) {
+	adapter, ok := dbInterface.(*dbAdapter)
+	if !ok {
+		return "", ErrNeedsLocalDB
+	}
+	home, err := SaveHome(adapter.db, coordinates, label)
+	if err != nil {
+		return "", err
+	}
+	return home.String(), nil
+}
+
+// listBusRules loads bus eligibility rules for the CLI
+func listBusRules(dbInterface cmd.DBInterface) ([]cmd.BusRuleJSON, error) {
+	adapter, ok := dbInterface.(*dbAdapter)
+	if !ok {
+		return nil, ErrNeedsLocalDB
+	}
+
+	rules, err := adapter.db.BusRules()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]cmd.BusRuleJSON, len(rules))
+	for i, r := range rules {
+		result[i] = busRuleToJSON(r)
+	}
+	return result, nil
+}
+
+// addBusRule saves a bus eligibility rule for the CLI
+func addBusRule(dbInterface cmd.DBInterface, scope, grades string, miles float64, source string) (*cmd.BusRuleJSON, error) {
+	adapter, ok := dbInterface.(*dbAdapter)
+	if !ok {
+		return nil, ErrNeedsLocalDB
+	}
+	rule, err := SaveBusRule(adapter.db, scope, grades, miles, source)
+	if err != nil {
+		return nil, err
+	}
+	result := busRuleToJSON(*rule)
+	return &result, nil
+}
+
+// removeBusRule deletes a bus eligibility rule for the CLI
+func removeBusRule(dbInterface cmd.DBInterface, id int64) error {
+	adapter, ok := dbInterface.(*dbAdapter)
+	if !ok {
+		return ErrNeedsLocalDB
+	}
+	return adapter.db.DeleteBusRule(id)
+}
+
+// schoolSafety returns a school's imported safety measures for the CLI
+func schoolSafety(dbInterface cmd.DBInterface, ncessch string) ([]cmd.SafetySourceJSON, error) {
+	adapter, ok := dbInterface.(*dbAdapter)
+	if !ok {
+		return nil, ErrNeedsLocalDB
+	}
+	if _, err := adapter.db.GetSchoolByID(ncessch); err != nil {
+		return nil, fmt.Errorf("school not found: %s", ncessch)
+	}
+
+	sources, err := adapter.db.SchoolSafety(ncessch)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]cmd.SafetySourceJSON, len(sources))
+	for i, src := range sources {
+		result[i] = cmd.SafetySourceJSON{Source: src.Source}
+		for _, m := range src.Metrics {
+			metric := cmd.SafetyMetricJSON{Name: m.Name, Change: m.Change()}
+			for _, v := range m.Values {
+				metric.Values = append(metric.Values, cmd.SafetyValueJSON{Year: v.Year, Value: v.Value})
+			}
+			result[i].Metrics = append(result[i].Metrics, metric)
+		}
+	}
+	return result, nil
+}
+
+// safetyJoinToJSON converts a safety file's join for the CLI
+func safetyJoinToJSON(j SafetyJoin) cmd.SafetyJoinJSON {
+	return cmd.SafetyJoinJSON{Column: j.Column, KeyType: j.KeyType, Matched: j.Matched, Rows: j.Rows, MatchRate: j.MatchRate()}
+}
+
+// importSafety imports a state safety report for the CLI
+func importSafety(dbInterface cmd.DBInterface, path string, opts cmd.SafetyImportOptions) (*cmd.SafetyImportJSON, error) {
+	adapter, ok := dbInterface.(*dbAdapter)
+	if !ok {
+		return nil, ErrNeedsLocalDB
+	}
+
+	imp, err := ImportSafetyFile(adapter.db, path, SafetyImportOptions(opts))
+	if imp == nil {
+		return nil, err
+	}
+	result := &cmd.SafetyImportJSON{
+		Filename: imp.Filename,
+		Join:     safetyJoinToJSON(imp.Join),
+		Metrics:  imp.Metrics,
+		Years:    imp.Years,
+		Values:   imp.Values,
+		DryRun:   opts.DryRun,
+	}
+	for _, c := range imp.Candidates {
+		result.Candidates = append(result.Candidates, safetyJoinToJSON(c))
+	}
+	return result, err
+}
+
+// listSafetyFiles lists imported safety reports for the CLI
+func listSafetyFiles(dbInterface cmd.DBInterface) ([]cmd.SafetyFileJSON, error) {
+	adapter, ok := dbInterface.(*dbAdapter)
+	if !ok {
+		return nil, ErrNeedsLocalDB
+	}
+
+	files, err := adapter.db.SafetyFiles()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]cmd.SafetyFileJSON, len(files))
+	for i, f := range files {
+		result[i] = cmd.SafetyFileJSON{
+			Filename: f.Filename,
+			Source:   f.Source,
+			Join:     safetyJoinToJSON(f.Join),
+			LoadedAt: f.LoadedAt.Format(time.RFC3339),
+		}
+	}
+	return result, nil
+}
+
+// removeSafetyFile removes an imported safety report for the CLI
+func removeSafetyFile(dbInterface cmd.DBInterface, filename string) error {
+	adapter, ok := dbInterface.(*dbAdapter)
+	if !ok {
+		return ErrNeedsLocalDB
+	}
+	return adapter.db.DeleteSafetyFile(filename)
+}
+
+// schoolStateRatings returns a school's state ratings for the CLI
+func schoolStateRatings(dbInterface cmd.DBInterface, ncessch string) ([]cmd.StateRatingJSON, error) {
+	adapter, ok := dbInterface.(*dbAdapter)
+	if !ok {
+		return nil, ErrNeedsLocalDB
+	}
+	if _, err := adapter.db.GetSchoolByID(ncessch); err != nil {
+		return nil, fmt.Errorf("school not found: %s", ncessch)
+	}
+
+	ratings, err := adapter.db.SchoolStateRatings(ncessch)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]cmd.StateRatingJSON, len(ratings))
+	for i, r := range ratings {
+		result[i] = cmd.StateRatingJSON{
+			Source:     r.Source,
+			SchoolYear: r.SchoolYear,
+			Indicator:  r.Indicator,
+			Rating:     r.Rating,
+			Score:      r.Score,
+			SourceURL:  r.SourceURL,
+			FetchedAt:  r.FetchedAt.Format(time.RFC3339),
+		}
+		if source, err := stateRatingSourceByID(r.Source); err == nil {
+			result[i].SourceName = source.Name()
+		}
+	}
+	return result, nil
+}
+
+// listStateRatingSources lists the registered state ratings sources for the CLI
+func listStateRatingSources() []cmd.StateRatingSourceJSON {
+	result := make([]cmd.StateRatingSourceJSON, len(stateRatingSources))
+	for i, s := range stateRatingSources {
+		result[i] = cmd.StateRatingSourceJSON{ID: s.ID(), State: s.State(), Name: s.Name()}
+	}
+	return result
+}
+
+// stateRatingRefreshToJSON converts a ratings refresh for the CLI
+func stateRatingRefreshToJSON(r StateRatingRefresh) cmd.StateRatingRefreshJSON {
+	return cmd.StateRatingRefreshJSON{
+		Source:      r.Source,
+		SchoolYear:  r.SchoolYear,
+		Files:       r.Files,
+		Ratings:     r.Ratings,
+		Matched:     r.Matched,
+		RefreshedAt: r.RefreshedAt.Format(time.RFC3339),
+	}
+}
+
+// refreshStateRatings downloads a state's ratings for the CLI
+func refreshStateRatings(ctx context.Context, dbInterface cmd.DBInterface, source string, year int, files []string) (*cmd.StateRatingRefreshJSON, error) {
+	adapter, ok := dbInterface.(*dbAdapter)
+	if !ok {
+		return nil, ErrNeedsLocalDB
+	}
+	refresh, err := RefreshStateRatings(ctx, adapter.db, source, year, files)
+	if err != nil {
+		return nil, err
+	}
+	result := stateRatingRefreshToJSON(*refresh)
+	return &result, nil
+}
+
+// listStateRatingRefreshes lists ratings refreshes for the CLI
+func listStateRatingRefreshes(dbInterface cmd.DBInterface) ([]cmd.StateRatingRefreshJSON, error) {
+	adapter, ok := dbInterface.(*dbAdapter)
+	if !ok {
+		return nil, ErrNeedsLocalDB
+	}
+	refreshes, err := adapter.db.StateRatingRefreshes()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]cmd.StateRatingRefreshJSON, len(refreshes))
+	for i, r := range refreshes {
+		result[i] = stateRatingRefreshToJSON(r)
+	}
+	return result, nil
+}
+
 func main() {
 	// Set up cmd package callbacks
 	cmd.LaunchTUI = launchTUI
+	cmd.PrepareDemo = prepareDemo
 	cmd.InitDB = initDB
 	cmd.InitAIScraper = initAIScraper
+	cmd.DescribeError = describeCLIError
 	cmd.StartServer = startServer
+	cmd.GenerateTourQuestions = generateTourQuestions
+	cmd.RunBenchmarks = runBenchmarks
+	cmd.DiffYears = diffYears
+	cmd.AreaSummary = areaSummary
+	cmd.AreaRollups = areaRollups
+	cmd.StatsOverview = statsOverview
+	cmd.Reindex = reindex
+	cmd.UsageStats = usageStats
+	cmd.SetUsageTracking = setUsageTracking
+	cmd.UploadUsage = uploadUsage
+	cmd.RecordUsage = recordUsage
+	cmd.ListSchoolDates = listSchoolDates
+	cmd.AddSchoolDate = addSchoolDate
+	cmd.RemoveSchoolDate = removeSchoolDate
+	cmd.ExportTimeline = exportTimeline
+	cmd.ListSchoolCalls = listSchoolCalls
+	cmd.AddSchoolCall = addSchoolCall
+	cmd.RemoveSchoolCall = removeSchoolCall
+	cmd.ListOutreach = listOutreach
+	cmd.ListJobs = listJobs
+	cmd.ResumeJob = resumeJob
+	cmd.AddOutreach = addOutreach
+	cmd.SetOutreach = setOutreach
+	cmd.RemoveOutreach = removeOutreach
+	cmd.ApplicationsReport = applicationsReport
+	cmd.SetApplication = setApplication
+	cmd.RemoveApplication = removeApplication
+	cmd.SeasonRecap = seasonRecap
+	cmd.ListChildren = listChildren
+	cmd.SaveChild = saveChild
+	cmd.RemoveChild = removeChild
+	cmd.SetChildSchool = setChildSchool
+	cmd.SearchWithNeeds = searchWithNeeds
+	cmd.SaveDossiers = saveDossiers
+	cmd.SchoolReport = schoolReport
+	cmd.RunNotebook = runNotebook
+	cmd.DataVersions = dataVersions
+	cmd.AsOfQuery = asOfQuery
+	cmd.CCDReleases = ccdReleases
+	cmd.GeocodeSchools = geocodeSchools
+	cmd.RunDoctor = runDoctor
+	cmd.ScrapeDistrict = scrapeDistrict
+	cmd.ScrapeSchool = scrapeSchool
+	cmd.ScrapeExitCode = scrapeExitCode
+	cmd.FeederPipeline = feederPipeline
+	cmd.ScrapeFeeders = scrapeFeeders
+	cmd.CompareSchools = compareSchools
+	cmd.RandomSchool = randomSchool
+	cmd.SyncPeer = syncWithPeer
+	cmd.LookupSecret = lookupSecret
+	cmd.SetSecret = setSecret
+	cmd.RemoveSecret = removeSecret
+	cmd.SecretStatuses = secretStatuses
+	cmd.CharterMatch = charterMatch
+	cmd.CompareCalendars = compareCalendars
+	cmd.ExtractCalendar = extractCalendar
+	cmd.ExportCalendar = exportCalendar
+	cmd.EstimateBus = estimateBus
+	cmd.SetHome = setHome
+	cmd.ListBusRules = listBusRules
+	cmd.AddBusRule = addBusRule
+	cmd.RemoveBusRule = removeBusRule
+	cmd.SchoolSafety = schoolSafety
+	cmd.ImportSafety = importSafety
+	cmd.ListSafetyFiles = listSafetyFiles
+	cmd.RemoveSafetyFile = removeSafetyFile
+	cmd.SchoolStateRatings = schoolStateRatings
+	cmd.ListStateRatingSources = listStateRatingSources
+	cmd.RefreshStateRatings = refreshStateRatings
+	cmd.ListStateRatingRefreshes = listStateRatingRefreshes
 
 	// Execute the CLI
 	if err := cmd.Execute(); err != nil {
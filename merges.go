@@ -0,0 +1,299 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Limits on the duplicate report
+const (
+	duplicateNameSimilarity = 0.85 // Jaro-Winkler similarity of names that likely mean the same school
+	maxDuplicateCandidates  = 200
+)
+
+// notMergedCondition leaves schools merged into another record out of listings
+const notMergedCondition = "d.NCESSCH NOT IN (SELECT duplicate_ncessch FROM school_merges)"
+
+// mergedCacheTables hold per-school cached data, keyed by ncessch, that a merged
+// duplicate passes to its canonical record when the canonical has none
+var mergedCacheTables = []string{"ai_scraper_cache", "naep_cache", "parent_summary_cache", "naep_overrides"}
+
+// DuplicateSchool is one side of a likely duplicate pair
+type DuplicateSchool struct {
+	NCESSCH    string
+	Name       string
+	City       string
+	Enrollment sql.NullInt64
+}
+
+// DuplicateCandidate is a pair of directory records that likely describe the
+// same school: the same phone or street address, with similar names unless both match
+type DuplicateCandidate struct {
+	A, B           DuplicateSchool
+	State          string
+	SamePhone      bool
+	SameAddress    bool
+	NameSimilarity float64 // 0 to 1
+}
+
+// Reasons explains why the pair was flagged
+func (c DuplicateCandidate) Reasons() []string {
+	var reasons []string
+	if c.SameAddress {
+		reasons = append(reasons, "same address")
+	}
+	if c.SamePhone {
+		reasons = append(reasons, "same phone")
+	}
+	if c.NameSimilarity >= duplicateNameSimilarity {
+		reasons = append(reasons, fmt.Sprintf("similar names (%.0f%%)", c.NameSimilarity*100))
+	}
+	return reasons
+}
+
+// Confidence is "High" when the address, phone, and name all match and "Medium" otherwise
+func (c DuplicateCandidate) Confidence() string {
+	if c.SameAddress && c.SamePhone && c.NameSimilarity >= duplicateNameSimilarity {
+		return "High"
+	}
+	return "Medium"
+}
+
+// SchoolMerge links a duplicate directory record to the canonical record that
+// stands in for it
+type SchoolMerge struct {
+	Duplicate     string
+	DuplicateName string
+	Canonical     string
+	CanonicalName string
+	Note          string
+	MergedAt      time.Time
+}
+
+// FindDuplicateSchools flags likely duplicate records, optionally in one state,
+// leaving out merged records and pairs marked as distinct. Matches on both phone
+// and address come first, then by name similarity.
+func (d *DB) FindDuplicateSchools(state string) ([]DuplicateCandidate, error) {
+	rows, err := d.conn.Query(fmt.Sprintf(`
+		WITH s AS (
+			SELECT
+				d.NCESSCH AS ncessch,
+				d.SCH_NAME AS name,
+				d.ST AS state,
+				COALESCE(d.MCITY, '') AS city,
+				regexp_replace(COALESCE(d.PHONE, ''), '[^0-9]', '', 'g') AS phone,
+				lower(trim(COALESCE(d.MSTREET1, ''))) AS street,
+				left(COALESCE(d.MZIP, ''), 5) AS zip,
+				TRY_CAST(e.STUDENT_COUNT AS BIGINT) AS enrollment
+			FROM directory d
+			LEFT JOIN enrollment e ON d.NCESSCH = e.NCESSCH AND e.TOTAL_INDICATOR = 'Education Unit Total'
+			WHERE %s AND ($1 = '' OR d.ST = $1)
+		),
+		pairs AS (
+			SELECT
+				a.ncessch AS a_id, a.name AS a_name, a.city AS a_city, a.enrollment AS a_enrollment,
+				b.ncessch AS b_id, b.name AS b_name, b.city AS b_city, b.enrollment AS b_enrollment,
+				a.state,
+				length(a.phone) >= 10 AND a.phone = b.phone AS same_phone,
+				a.street <> '' AND a.street = b.street AND a.zip = b.zip AS same_address,
+				jaro_winkler_similarity(lower(a.name), lower(b.name)) AS similarity
+			FROM s a
+			JOIN s b ON a.state = b.state AND a.ncessch < b.ncessch
+				AND ((length(a.phone) >= 10 AND a.phone = b.phone)
+					OR (a.street <> '' AND a.street = b.street AND a.zip = b.zip))
+		)
+		SELECT a_id, a_name, a_city, a_enrollment, b_id, b_name, b_city, b_enrollment,
+			state, same_phone, same_address, similarity
+		FROM pairs
+		WHERE ((same_phone AND same_address) OR similarity >= $2)
+			AND NOT EXISTS (
+				SELECT 1 FROM school_distinct x WHERE x.ncessch_a = a_id AND x.ncessch_b = b_id
+			)
+		ORDER BY (same_phone AND same_address) DESC, similarity DESC, a_id
+		LIMIT %d
+	`, notMergedCondition, maxDuplicateCandidates), state, duplicateNameSimilarity)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find duplicate schools: %w", err)
+	}
+	defer rows.Close()
+
+	var candidates []DuplicateCandidate
+	for rows.Next() {
+		var c DuplicateCandidate
+		if err := rows.Scan(&c.A.NCESSCH, &c.A.Name, &c.A.City, &c.A.Enrollment,
+			&c.B.NCESSCH, &c.B.Name, &c.B.City, &c.B.Enrollment,
+			&c.State, &c.SamePhone, &c.SameAddress, &c.NameSimilarity); err != nil {
+			return nil, fmt.Errorf("failed to scan duplicate schools: %w", err)
+		}
+		candidates = append(candidates, c)
+	}
+
+	return candidates, rows.Err()
+}
+
+// MarkSchoolsDistinct drops a pair from the duplicate report
+func (d *DB) MarkSchoolsDistinct(a, b string) error {
+	if a > b {
+		a, b = b, a
+	}
+	_, err := d.conn.Exec(`INSERT INTO school_distinct (ncessch_a, ncessch_b) VALUES ($1, $2) ON CONFLICT DO NOTHING`, a, b)
+	if err != nil {
+		return fmt.Errorf("failed to mark schools distinct: %w", err)
+	}
+	return nil
+}
+
+// CanonicalID returns the record a school was merged into, or ncessch itself
+func (d *DB) CanonicalID(ncessch string) string {
+	d.mergesMu.RLock()
+	defer d.mergesMu.RUnlock()
+	if canonical, ok := d.merges[ncessch]; ok {
+		return canonical
+	}
+	return ncessch
+}
+
+// loadMerges reads all merges into memory; every school lookup checks them
+func (d *DB) loadMerges() error {
+	rows, err := d.conn.Query(`SELECT duplicate_ncessch, canonical_ncessch FROM school_merges`)
+	if err != nil {
+		return fmt.Errorf("failed to load school merges: %w", err)
+	}
+	defer rows.Close()
+
+	merges := make(map[string]string)
+	for rows.Next() {
+		var duplicate, canonical string
+		if err := rows.Scan(&duplicate, &canonical); err != nil {
+			return fmt.Errorf("failed to scan school merge: %w", err)
+		}
+		merges[duplicate] = canonical
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	d.mergesMu.Lock()
+	d.merges = merges
+	d.mergesMu.Unlock()
+	return nil
+}
+
+// MergeSchools links duplicate to canonical: the duplicate drops out of search
+// results, lookups of it return the canonical school, and its cached website,
+// NAEP, and summary data moves to the canonical school where that has none.
+// Schools already merged into the duplicate are re-pointed at the canonical.
+func (d *DB) MergeSchools(duplicate, canonical, note string) error {
+	canonical = d.CanonicalID(canonical)
+	if duplicate == canonical {
+		return fmt.Errorf("can't merge school %s into itself", duplicate)
+	}
+
+	var found int
+	if err := d.conn.QueryRow(`SELECT count(*) FROM directory WHERE NCESSCH IN ($1, $2)`, duplicate, canonical).Scan(&found); err != nil {
+		return fmt.Errorf("failed to look up merged schools: %w", err)
+	}
+	if found != 2 {
+		return fmt.Errorf("can't merge %s into %s: %w", duplicate, canonical, sql.ErrNoRows)
+	}
+
+	tx, err := d.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		INSERT INTO school_merges (duplicate_ncessch, canonical_ncessch, note, merged_at)
+		VALUES ($1, $2, $3, now())
+		ON CONFLICT (duplicate_ncessch) DO UPDATE SET
+			canonical_ncessch = EXCLUDED.canonical_ncessch,
+			note = EXCLUDED.note,
+			merged_at = now()
+	`, duplicate, canonical, note); err != nil {
+		return fmt.Errorf("failed to save school merge: %w", err)
+	}
+	if _, err := tx.Exec(`UPDATE school_merges SET canonical_ncessch = $2 WHERE canonical_ncessch = $1`, duplicate, canonical); err != nil {
+		return fmt.Errorf("failed to re-point school merges: %w", err)
+	}
+	for _, table := range mergedCacheTables {
+		if _, err := tx.Exec(fmt.Sprintf(`
+			INSERT INTO %[1]s BY NAME
+			SELECT * REPLACE ($2 AS ncessch) FROM %[1]s WHERE ncessch = $1
+			ON CONFLICT DO NOTHING
+		`, table), duplicate, canonical); err != nil {
+			return fmt.Errorf("failed to move %s to the canonical school: %w", table, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit school merge: %w", err)
+	}
+
+	if err := d.loadMerges(); err != nil {
+		return err
+	}
+	d.schoolCache.Delete(duplicate)
+	d.schoolCache.Delete(canonical)
+	d.naepCache.Delete(canonical)
+	d.aiCache.Delete(canonical)
+	d.notifyCacheInvalidated(canonical)
+	d.refreshOverviewAfterMerge()
+
+	if logger != nil {
+		logger.Info("Merged duplicate school", "duplicate", duplicate, "canonical", canonical)
+	}
+	return nil
+}
+
+// refreshOverviewAfterMerge recounts the statistics overview, which leaves
+// out merged duplicates. The merge stands even if the recount fails.
+func (d *DB) refreshOverviewAfterMerge() {
+	if _, err := d.RefreshOverviewStats(); err != nil && logger != nil {
+		logger.Warn("Failed to refresh overview statistics after a merge", "error", err)
+	}
+}
+
+// UnmergeSchool makes a merged duplicate a separate school again. Cached data
+// already moved to the canonical school stays there.
+func (d *DB) UnmergeSchool(duplicate string) error {
+	if _, err := d.conn.Exec(`DELETE FROM school_merges WHERE duplicate_ncessch = $1`, duplicate); err != nil {
+		return fmt.Errorf("failed to delete school merge: %w", err)
+	}
+
+	d.mergesMu.Lock()
+	delete(d.merges, duplicate)
+	d.mergesMu.Unlock()
+	d.schoolCache.Delete(duplicate)
+	d.refreshOverviewAfterMerge()
+	return nil
+}
+
+// ListSchoolMerges returns merges into canonical, or all merges if canonical is empty
+func (d *DB) ListSchoolMerges(canonical string) ([]SchoolMerge, error) {
+	rows, err := d.conn.Query(`
+		SELECT m.duplicate_ncessch, COALESCE(dup.SCH_NAME, ''), m.canonical_ncessch, COALESCE(c.SCH_NAME, ''),
+			m.note, m.merged_at
+		FROM school_merges m
+		LEFT JOIN directory dup ON dup.NCESSCH = m.duplicate_ncessch
+		LEFT JOIN directory c ON c.NCESSCH = m.canonical_ncessch
+		WHERE $1 = '' OR m.canonical_ncessch = $1
+		ORDER BY m.merged_at DESC
+	`, canonical)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list school merges: %w", err)
+	}
+	defer rows.Close()
+
+	var merges []SchoolMerge
+	for rows.Next() {
+		var m SchoolMerge
+		if err := rows.Scan(&m.Duplicate, &m.DuplicateName, &m.Canonical, &m.CanonicalName, &m.Note, &m.MergedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan school merge: %w", err)
+		}
+		merges = append(merges, m)
+	}
+
+	return merges, rows.Err()
+}
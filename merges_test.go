@@ -0,0 +1,165 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+// addDuplicateSchool lists Lincoln Elementary a second time, as after a rename
+func addDuplicateSchool(t *testing.T, db *DB) {
+	t.Helper()
+	_, err := db.conn.Exec(`
+		INSERT INTO directory BY NAME
+		SELECT '360000100099' AS NCESSCH, 'Lincoln Elementary' AS SCH_NAME, 'CA' AS ST, 'California' AS STATENAME,
+			'San Francisco' AS MCITY, 'San Francisco Unified School District' AS LEA_NAME, '0600000' AS LEAID,
+			'2023-2024' AS SCHOOL_YEAR, '(415) 555-0100' AS PHONE, '123 LINCOLN ST' AS MSTREET1, '94102-1234' AS MZIP
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFindDuplicateSchools(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	if candidates, err := db.FindDuplicateSchools(""); err != nil || len(candidates) != 0 {
+		t.Fatalf("mock data duplicates = %+v, %v", candidates, err)
+	}
+
+	addDuplicateSchool(t, db)
+	candidates, err := db.FindDuplicateSchools("CA")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candidates) != 1 {
+		t.Fatalf("Expected 1 duplicate pair, got %+v", candidates)
+	}
+	c := candidates[0]
+	if c.A.NCESSCH != "360000100001" || c.B.NCESSCH != "360000100099" || !c.SamePhone || !c.SameAddress || c.Confidence() != "High" {
+		t.Errorf("candidate = %+v", c)
+	}
+	if reasons := strings.Join(c.Reasons(), ", "); !strings.HasPrefix(reasons, "same address, same phone, similar names") {
+		t.Errorf("reasons = %q", reasons)
+	}
+
+	if candidates, _ := db.FindDuplicateSchools("TX"); len(candidates) != 0 {
+		t.Errorf("state filter: got %+v", candidates)
+	}
+
+	// Pairs marked distinct drop out of the report
+	if err := db.MarkSchoolsDistinct("360000100099", "360000100001"); err != nil {
+		t.Fatal(err)
+	}
+	if candidates, _ := db.FindDuplicateSchools(""); len(candidates) != 0 {
+		t.Errorf("after marking distinct: got %+v", candidates)
+	}
+}
+
+func TestMergeSchools(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	addDuplicateSchool(t, db)
+
+	data := testEnhancedData()
+	data.NCESSCH = "360000100099"
+	if err := saveEnhancedData(db, data); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.MergeSchools("360000100099", "360000100099", ""); err == nil {
+		t.Error("Expected an error merging a school into itself")
+	}
+	if err := db.MergeSchools("360000100099", "360000100001", "Renamed"); err != nil {
+		t.Fatalf("MergeSchools failed: %v", err)
+	}
+
+	// Lookups of the duplicate return the canonical school
+	school, err := db.GetSchoolByID("360000100099")
+	if err != nil || school.NCESSCH != "360000100001" {
+		t.Errorf("GetSchoolByID(duplicate) = %+v, %v", school, err)
+	}
+	schools, err := db.GetSchoolsByIDs([]string{"360000100099", "360000100001"})
+	if err != nil || len(schools) != 1 || schools[0].NCESSCH != "360000100001" {
+		t.Errorf("GetSchoolsByIDs = %d schools, %v", len(schools), err)
+	}
+
+	// The duplicate leaves listings and the report
+	results, err := db.SearchSchoolsFiltered(SearchFilters{State: "CA"}, 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range results {
+		if s.NCESSCH == "360000100099" {
+			t.Error("merged duplicate still appears in search results")
+		}
+	}
+	if candidates, _ := db.FindDuplicateSchools(""); len(candidates) != 0 {
+		t.Errorf("merged pair still reported: %+v", candidates)
+	}
+
+	// Cached website data follows the canonical school
+	if _, _, _, _, _, err := db.LoadAIScraperCache("360000100001", cacheNoExpiry); err != nil {
+		t.Errorf("website data didn't move to the canonical school: %v", err)
+	}
+
+	merges, err := db.ListSchoolMerges("360000100001")
+	if err != nil || len(merges) != 1 || merges[0].DuplicateName != "Lincoln Elementary" || merges[0].Note != "Renamed" {
+		t.Errorf("ListSchoolMerges = %+v, %v", merges, err)
+	}
+
+	if err := db.UnmergeSchool("360000100099"); err != nil {
+		t.Fatal(err)
+	}
+	if school, _ := db.GetSchoolByID("360000100099"); school == nil || school.NCESSCH != "360000100099" {
+		t.Errorf("after unmerge: GetSchoolByID = %+v", school)
+	}
+}
+
+func TestWebDuplicates(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	addDuplicateSchool(t, db)
+	router := NewRouter(ServerConfig{DB: db})
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/duplicates?state=ca", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "360000100099") {
+		t.Fatalf("report: status %d, body %s", rec.Code, rec.Body.String())
+	}
+	doc, err := html.Parse(strings.NewReader(rec.Body.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rule := range a11yRules {
+		for _, problem := range rule.check(doc) {
+			t.Errorf("duplicates page %s: %s", rule.id, problem)
+		}
+	}
+
+	form := url.Values{"duplicate": {"360000100099"}, "canonical": {"360000100001"}, "state": {"CA"}}
+	req := httptest.NewRequest("POST", "/duplicates/merge", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "/duplicates?state=CA" {
+		t.Fatalf("merge: status %d, location %q", rec.Code, rec.Header().Get("Location"))
+	}
+
+	// Links to the duplicate go to the canonical school, which lists it
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/schools/360000100099", nil))
+	if rec.Code != http.StatusFound || rec.Header().Get("Location") != "/schools/360000100001" {
+		t.Errorf("duplicate detail: status %d, location %q", rec.Code, rec.Header().Get("Location"))
+	}
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/schools/360000100001", nil))
+	if !strings.Contains(rec.Body.String(), "Also listed as: Lincoln Elementary (360000100099)") {
+		t.Errorf("canonical detail page doesn't list the duplicate (status %d)", rec.Code)
+	}
+}
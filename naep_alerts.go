@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// defaultNAEPAlertThreshold is the mean score drop (in scale points) that triggers an alert
+const defaultNAEPAlertThreshold = 3.0
+
+// NAEPAlert records a subject whose NAEP mean score dropped versus the prior assessment
+type NAEPAlert struct {
+	ID            int64     `json:"id"`
+	NCESSCH       string    `json:"ncessch"`
+	SchoolName    string    `json:"school_name"`
+	Jurisdiction  string    `json:"jurisdiction"` // State or district the scores belong to
+	Subject       string    `json:"subject"`
+	Grade         int       `json:"grade"`
+	PreviousYear  int       `json:"previous_year"`
+	CurrentYear   int       `json:"current_year"`
+	PreviousScore float64   `json:"previous_score"`
+	CurrentScore  float64   `json:"current_score"`
+	Change        float64   `json:"change"`
+	Dismissed     bool      `json:"dismissed"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// naepAlertThresholdFromEnv reads NAEP_ALERT_THRESHOLD, falling back to the default
+func naepAlertThresholdFromEnv() float64 {
+	if raw := os.Getenv("NAEP_ALERT_THRESHOLD"); raw != "" {
+		if threshold, err := strconv.ParseFloat(raw, 64); err == nil && threshold > 0 {
+			return threshold
+		}
+		if logger != nil {
+			logger.Warn("Ignoring invalid NAEP_ALERT_THRESHOLD", "value", raw)
+		}
+	}
+	return defaultNAEPAlertThreshold
+}
+
+// DetectNAEPDeclines finds subjects whose latest mean score fell more than threshold points
+// below the prior assessment. District scores are used when present, matching the detail view.
+func DetectNAEPDeclines(school *School, data *NAEPData, threshold float64) []NAEPAlert {
+	if data == nil {
+		return nil
+	}
+
+	useDistrict := len(data.DistrictScores) > 0
+	jurisdiction := data.State
+	if useDistrict {
+		jurisdiction = data.District
+	}
+
+	var alerts []NAEPAlert
+	for _, grade := range []int{4, 8} {
+		for _, subject := range []string{"mathematics", "reading", "science"} {
+			current, previous, change := data.GetScoreTrend(subject, grade, useDistrict)
+			if current == nil || previous == nil || -change <= threshold {
+				continue
+			}
+			alerts = append(alerts, NAEPAlert{
+				NCESSCH:       school.NCESSCH,
+				SchoolName:    school.Name,
+				Jurisdiction:  jurisdiction,
+				Subject:       subject,
+				Grade:         grade,
+				PreviousYear:  previous.Year,
+				CurrentYear:   current.Year,
+				PreviousScore: previous.MeanScore,
+				CurrentScore:  current.MeanScore,
+				Change:        change,
+			})
+		}
+	}
+
+	return alerts
+}
+
+// recordDeclineAlerts stores alerts for any significant declines in freshly fetched data
+func (c *NAEPClient) recordDeclineAlerts(school *School, data *NAEPData) {
+	if c.db == nil {
+		return
+	}
+
+	alerts := DetectNAEPDeclines(school, data, c.alertThreshold)
+	for _, alert := range alerts {
+		if err := c.db.SaveNAEPAlert(alert); err != nil {
+			if logger != nil {
+				logger.Warn("Failed to save NAEP alert", "error", err, "ncessch", school.NCESSCH, "subject", alert.Subject)
+			}
+		}
+	}
+
+	if len(alerts) > 0 && logger != nil {
+		logger.Info("Recorded NAEP decline alerts", "ncessch", school.NCESSCH, "count", len(alerts), "threshold", c.alertThreshold)
+	}
+}
+
+// Summary returns a one-line description of the decline
+func (a NAEPAlert) Summary() string {
+	return fmt.Sprintf("Grade %d %s fell %.1f points (%.0f → %.0f) from %d to %d",
+		a.Grade, a.Subject, -a.Change, a.PreviousScore, a.CurrentScore, a.PreviousYear, a.CurrentYear)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"testing"
+)
+
+// TestDetectNAEPDeclines tests that only drops larger than the threshold produce alerts
+func TestDetectNAEPDeclines(t *testing.T) {
+	school := MockSchool("360000100001", "Lincoln Elementary", "San Francisco Unified", "CA", "PK", "05")
+
+	declining := &NAEPData{
+		NCESSCH: "360000100001",
+		State:   "CA",
+		StateScores: []NAEPScore{
+			MockNAEPScore("mathematics", 4, 2022, 230.0, 30.0),
+			MockNAEPScore("mathematics", 4, 2019, 238.0, 38.0),
+			MockNAEPScore("reading", 4, 2022, 216.0, 31.0),
+			MockNAEPScore("reading", 4, 2019, 218.0, 33.0),
+		},
+	}
+
+	testCases := []struct {
+		name      string
+		data      *NAEPData
+		threshold float64
+		expected  []string // subjects expected to alert
+	}{
+		{"Nil data", nil, 3.0, nil},
+		{"Improving scores", MockNAEPData("360000100001", "CA", "", false, false), 3.0, nil},
+		{"Large drop only", declining, 3.0, []string{"mathematics"}},
+		{"Low threshold catches both", declining, 1.0, []string{"mathematics", "reading"}},
+		{"Drop equal to threshold is ignored", declining, 8.0, nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			alerts := DetectNAEPDeclines(school, tc.data, tc.threshold)
+			if len(alerts) != len(tc.expected) {
+				t.Fatalf("Expected %d alerts, got %d: %+v", len(tc.expected), len(alerts), alerts)
+			}
+			for i, subject := range tc.expected {
+				if alerts[i].Subject != subject {
+					t.Errorf("Expected alert %d for %s, got %s", i, subject, alerts[i].Subject)
+				}
+				if alerts[i].Change >= 0 {
+					t.Errorf("Expected negative change, got %.1f", alerts[i].Change)
+				}
+			}
+		})
+	}
+
+	// District scores take precedence when present
+	withDistrict := MockNAEPData("360000100001", "CA", "San Francisco", true, false)
+	withDistrict.DistrictScores = declining.StateScores
+	alerts := DetectNAEPDeclines(school, withDistrict, 3.0)
+	if len(alerts) != 1 || alerts[0].Jurisdiction != "San Francisco" {
+		t.Errorf("Expected one district alert, got %+v", alerts)
+	}
+}
+
+// TestNAEPAlertStorage tests saving, de-duplicating, listing, and dismissing alerts
+func TestNAEPAlertStorage(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	alert := NAEPAlert{
+		NCESSCH:       "360000100001",
+		SchoolName:    "Lincoln Elementary",
+		Jurisdiction:  "CA",
+		Subject:       "mathematics",
+		Grade:         4,
+		PreviousYear:  2019,
+		CurrentYear:   2022,
+		PreviousScore: 238,
+		CurrentScore:  230,
+		Change:        -8,
+	}
+
+	// Saving the same decline twice (e.g. on the next refresh) records it once
+	for i := 0; i < 2; i++ {
+		if err := db.SaveNAEPAlert(alert); err != nil {
+			t.Fatalf("Failed to save alert: %v", err)
+		}
+	}
+
+	alerts, err := db.ListNAEPAlerts("", false)
+	if err != nil {
+		t.Fatalf("Failed to list alerts: %v", err)
+	}
+	if len(alerts) != 1 {
+		t.Fatalf("Expected 1 alert, got %d", len(alerts))
+	}
+
+	alerted, err := db.AlertedSchoolIDs()
+	if err != nil {
+		t.Fatalf("Failed to load alerted schools: %v", err)
+	}
+	if !alerted["360000100001"] {
+		t.Error("Expected school to be flagged")
+	}
+
+	if err := db.DismissNAEPAlert(alerts[0].ID); err != nil {
+		t.Fatalf("Failed to dismiss alert: %v", err)
+	}
+
+	// Dismissed alerts stay dismissed even if the same decline is seen again
+	if err := db.SaveNAEPAlert(alert); err != nil {
+		t.Fatalf("Failed to re-save alert: %v", err)
+	}
+	active, _ := db.ListNAEPAlerts("360000100001", false)
+	if len(active) != 0 {
+		t.Errorf("Expected no active alerts after dismissal, got %d", len(active))
+	}
+	all, _ := db.ListNAEPAlerts("360000100001", true)
+	if len(all) != 1 || !all[0].Dismissed {
+		t.Errorf("Expected one dismissed alert, got %+v", all)
+	}
+
+	if err := db.DismissNAEPAlert(9999); err == nil {
+		t.Error("Expected error dismissing unknown alert")
+	}
+}
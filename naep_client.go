@@ -1,13 +1,16 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"log/slog"
 	"net/http"
 	"net/url"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -33,6 +36,19 @@ type NAEPScore struct {
 
 	// Metadata
 	ErrorCode int `json:"error_code,omitempty"` // NAEP error code (0 = no error)
+
+	// Provenance, so cached values can be audited against nationsreportcard.gov
+	StatType             string `json:"stat_type,omitempty"`              // NAEP stattype behind MeanScore, e.g. "MN:MN"
+	SourceURL            string `json:"source_url,omitempty"`             // API URL MeanScore was fetched from
+	ProficiencyStatType  string `json:"proficiency_stat_type,omitempty"`  // NAEP stattype behind AtProficient, e.g. "ALC:AP"
+	ProficiencySourceURL string `json:"proficiency_source_url,omitempty"` // API URL AtProficient was fetched from
+	ProficiencyErrorCode int    `json:"proficiency_error_code,omitempty"` // NAEP error code for the achievement level value
+	ProficiencyError     string `json:"proficiency_error,omitempty"`      // Why the achievement level request failed, if it did
+}
+
+// HasErrors reports whether NAEP flagged either value or the achievement level request failed
+func (s NAEPScore) HasErrors() bool {
+	return s.ErrorCode != 0 || s.ProficiencyErrorCode != 0 || s.ProficiencyError != ""
 }
 
 // NAEPData represents all NAEP data for a school
@@ -44,13 +60,35 @@ type NAEPData struct {
 	StateScores    []NAEPScore `json:"state_scores"`
 	DistrictScores []NAEPScore `json:"district_scores,omitempty"`
 	NationalScores []NAEPScore `json:"national_scores,omitempty"`
+
+	Stale      bool `json:"-"` // Served from an expired cache entry
+	Refreshing bool `json:"-"` // A background refresh of the stale entry is running
+}
+
+// NAEPRawResponse is an archived NAEP API response body
+type NAEPRawResponse struct {
+	ID           int64     `json:"id"`
+	URL          string    `json:"url"`
+	Jurisdiction string    `json:"jurisdiction"`
+	Subject      string    `json:"subject"`
+	Grade        int       `json:"grade"`
+	StatType     string    `json:"stat_type"`
+	HTTPStatus   int       `json:"http_status"`
+	Body         string    `json:"body"`
+	FetchedAt    time.Time `json:"fetched_at"`
 }
 
 // NAEPClient handles NAEP API requests and caching
 type NAEPClient struct {
-	httpClient *http.Client
-	db         *DB
-	cacheTTL   time.Duration
+	httpClient     HTTPDoer
+	baseURL        string // NAEP data service endpoint
+	db             *DB
+	cacheTTL       time.Duration
+	alertThreshold float64 // Mean score drop that records a decline alert
+	refresher      backgroundRefresher
+	flights        flightGroup      // Coalesces concurrent fetches for the same school
+	retry          *retryPolicy     // Retries transient API failures; nil fetches once
+	now            func() time.Time // Clock for timestamps; nil uses time.Now
 }
 
 // NAEP API response structures
@@ -61,11 +99,20 @@ type naepAPIResponse struct {
 
 type naepDataPoint struct {
 	Value        float64 `json:"value"`
-	ErrorFlag    int     `json:"errorFlag"`
+	ErrorFlag    int     `json:"errorFlag"` // Nonzero when NAEP flags the estimate (e.g. reporting standards not met)
 	Year         int     `json:"year"`
 	Jurisdiction string  `json:"jurisLabel"`
 }
 
+// naepDataServiceURL is the NAEP ad hoc data service endpoint
+const naepDataServiceURL = "https://www.nationsreportcard.gov/DataService/GetAdhocData.aspx"
+
+// NAEP stattypes requested for each subject
+const (
+	naepStatTypeMean       = "MN:MN"  // Average scale score
+	naepStatTypeProficient = "ALC:AP" // At or above proficient
+)
+
 // Map of NAEP large city districts to jurisdiction codes
 var naepDistrictMap = map[string]string{
 	"albuquerque":          "XQ",
@@ -97,6 +144,68 @@ var naepDistrictMap = map[string]string{
 	"shelby county":        "YA",
 }
 
+// errNAEPNotAssessed marks schools in systems NAEP does not report results for
+var errNAEPNotAssessed = errors.New("not assessed by NAEP")
+
+// naepSpecialJurisdiction describes how a school system outside the 50 states and DC maps onto NAEP
+type naepSpecialJurisdiction struct {
+	code     string   // NAEP jurisdiction code; empty when NAEP does not report this system
+	name     string   // Display name
+	subjects []string // Subjects NAEP assesses here; nil means all
+	note     string   // Why there is no NAEP data, when code is empty
+}
+
+var (
+	naepDoDEA = naepSpecialJurisdiction{code: "DS", name: "DoDEA"}
+	naepBIE   = naepSpecialJurisdiction{
+		name: "Bureau of Indian Education",
+		note: "NAEP has not reported BIE schools as a separate jurisdiction in recent assessments; see the National Indian Education Study for AI/AN student results",
+	}
+	naepTerritoryNote = "NAEP assesses Puerto Rico but not other U.S. territories"
+)
+
+// Map of CCD state codes that need special NAEP handling. DoDEA and BIE schools
+// appear under several codes depending on the CCD release.
+var naepSpecialJurisdictions = map[string]naepSpecialJurisdiction{
+	"PR":    {code: "PR", name: "Puerto Rico", subjects: []string{"mathematics"}}, // Mathematics only
+	"DS":    naepDoDEA,
+	"DD":    naepDoDEA,
+	"DA":    naepDoDEA,
+	"DO":    naepDoDEA,
+	"DOD":   naepDoDEA,
+	"DODEA": naepDoDEA,
+	"BI":    naepBIE,
+	"BIE":   naepBIE,
+	"AS":    {name: "American Samoa", note: naepTerritoryNote},
+	"GU":    {name: "Guam", note: naepTerritoryNote},
+	"MP":    {name: "Northern Mariana Islands", note: naepTerritoryNote},
+	"VI":    {name: "U.S. Virgin Islands", note: naepTerritoryNote},
+}
+
+// resolveNAEPJurisdiction returns the NAEP jurisdiction code and assessed subjects (nil means all)
+// for a school's state, or an error wrapping errNAEPNotAssessed when NAEP has no results for it
+func resolveNAEPJurisdiction(school *School) (code string, subjects []string, err error) {
+	special, ok := naepSpecialJurisdictions[strings.ToUpper(school.State)]
+	if !ok {
+		// Fall back to the state name for releases that use unfamiliar codes
+		name := strings.ToLower(school.StateName)
+		switch {
+		case strings.Contains(name, "defense") || strings.HasPrefix(name, "dod"):
+			special, ok = naepDoDEA, true
+		case strings.Contains(name, "indian education"):
+			special, ok = naepBIE, true
+		}
+	}
+
+	if !ok {
+		return school.State, nil, nil
+	}
+	if special.code == "" {
+		return "", nil, fmt.Errorf("%s schools are %w: %s", special.name, errNAEPNotAssessed, special.note)
+	}
+	return special.code, special.subjects, nil
+}
+
 // NAEP subject codes
 var naepSubjects = map[string]struct {
 	code     string
@@ -108,56 +217,134 @@ var naepSubjects = map[string]struct {
 }
 
 // NewNAEPClient creates a new NAEP API client
-func NewNAEPClient(db *DB) *NAEPClient {
+func NewNAEPClient(db *DB, opts ...NAEPOption) *NAEPClient {
+	cacheTTL := cacheTTLFromEnv("NAEP_CACHE_TTL", defaultNAEPCacheTTL)
 	if logger != nil {
-		logger.Info("NAEP client initialized with database caching", "cache_ttl_days", 90)
+		logger.Info("NAEP client initialized with database caching", "cache_ttl_days", cacheTTL.Hours()/24)
 	}
 
-	return &NAEPClient{
-		httpClient: &http.Client{Timeout: 30 * time.Second},
-		db:         db,
-		cacheTTL:   90 * 24 * time.Hour, // 90 days
+	c := &NAEPClient{
+		httpClient:     &http.Client{Timeout: 30 * time.Second},
+		baseURL:        naepDataServiceURL,
+		db:             db,
+		cacheTTL:       cacheTTL,
+		alertThreshold: naepAlertThresholdFromEnv(),
+		retry:          naepRetry,
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// clock returns the current time
+func (c *NAEPClient) clock() time.Time {
+	if c.now == nil {
+		return time.Now()
+	}
+	return c.now()
 }
 
-// FetchNAEPData fetches NAEP data for a school
-func (c *NAEPClient) FetchNAEPData(school *School) (*NAEPData, error) {
-	// Check cache first
-	if cached, err := c.getCachedData(school.NCESSCH); err == nil {
+// FetchNAEPData fetches NAEP data for a school. Canceling ctx aborts the API requests.
+func (c *NAEPClient) FetchNAEPData(ctx context.Context, school *School) (*NAEPData, error) {
+	// Check cache first, serving stale entries while they refresh
+	if cached, err := c.CachedNAEPData(school); err == nil {
 		return cached, nil
 	}
 
+	return c.fetchFresh(ctx, school)
+}
+
+// CachedNAEPData returns cached NAEP data without waiting on the API. Entries older than
+// the cache TTL are returned with Stale set while fresh data is fetched in the background.
+func (c *NAEPClient) CachedNAEPData(school *School) (*NAEPData, error) {
+	data, err := c.loadCachedData(school.NCESSCH, cacheNoExpiry)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.clock().Sub(data.ExtractedAt) > c.cacheTTL {
+		c.refreshInBackground(school)
+		data.Stale = true
+		data.Refreshing = c.IsRefreshing(school.NCESSCH)
+	}
+
+	return data, nil
+}
+
+// refreshInBackground re-fetches a school's NAEP data unless a refresh is already running
+func (c *NAEPClient) refreshInBackground(school *School) {
+	s := *school
+	started := c.refresher.start(s.NCESSCH, func() error {
+		// Outlives the request that noticed the stale entry, so it gets its own deadline
+		ctx, cancel := context.WithTimeout(context.Background(), backgroundRefreshTimeout)
+		defer cancel()
+
+		_, err := c.fetchFresh(ctx, &s)
+		if err != nil && logger != nil {
+			logger.Warn("Background NAEP refresh failed", "error", err, "ncessch", s.NCESSCH)
+		}
+		return err
+	})
+	if started && logger != nil {
+		logger.Info("Refreshing stale NAEP cache in background", "ncessch", s.NCESSCH)
+	}
+}
+
+// IsRefreshing reports whether a background refresh is running for a school
+func (c *NAEPClient) IsRefreshing(ncessch string) bool {
+	return c.refresher.refreshing(ncessch)
+}
+
+// fetchFresh fetches NAEP data from the API and updates the cache. A canceled fetch
+// returns ctx's error and caches nothing. Concurrent fetches for a school, such as
+// two visitors opening it at once, share one set of API requests.
+func (c *NAEPClient) fetchFresh(ctx context.Context, school *School) (*NAEPData, error) {
+	s := *school
+	return coalesce(ctx, &c.flights, flightKey{s.NCESSCH, "naep"}, func(ctx context.Context) (*NAEPData, error) {
+		return c.fetchFromAPI(ctx, &s)
+	})
+}
+
+// fetchFromAPI does fetchFresh's work
+func (c *NAEPClient) fetchFromAPI(ctx context.Context, school *School) (*NAEPData, error) {
 	data := &NAEPData{
 		NCESSCH:     school.NCESSCH,
 		State:       school.State,
-		ExtractedAt: time.Now(),
+		ExtractedAt: c.clock(),
 	}
 
 	// Determine which grades to fetch based on school's grade range
 	grades := c.determineGrades(school)
 	if len(grades) == 0 {
-		return nil, fmt.Errorf("no NAEP grades applicable for this school (grade range: %s-%s)",
-			school.GradeLow.String, school.GradeHigh.String)
+		return nil, fmt.Errorf("%w: no grades applicable for this school (grade range: %s-%s)",
+			ErrNoNAEPData, school.GradeLow.String, school.GradeHigh.String)
+	}
+
+	// Map DoDEA, Puerto Rico, and other non-state systems onto NAEP jurisdictions
+	jurisCode, subjects, err := resolveNAEPJurisdiction(school)
+	if err != nil {
+		return nil, err
 	}
 
 	// Determine years to fetch (most recent assessments)
 	years := []string{"2022", "2019", "2017"}
 
 	// Fetch state-level data
-	stateScores, err := c.fetchScoresForJurisdiction(school.State, grades, years)
+	stateScores, err := c.fetchScoresForJurisdiction(ctx, jurisCode, subjects, grades, years)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch state scores: %w", err)
 	}
 
 	if len(stateScores) == 0 {
-		return nil, fmt.Errorf("no NAEP data available for state %s, grades %v, years %v",
-			school.State, grades, years)
+		return nil, fmt.Errorf("%w for state %s, grades %v, years %v",
+			ErrNoNAEPData, school.State, grades, years)
 	}
 
 	data.StateScores = stateScores
 
 	// Fetch national-level data for comparison
-	nationalScores, err := c.fetchScoresForJurisdiction("NP", grades, years)
+	nationalScores, err := c.fetchScoresForJurisdiction(ctx, "NP", subjects, grades, years)
 	if err == nil && len(nationalScores) > 0 {
 		data.NationalScores = nationalScores
 	} else {
@@ -166,14 +353,23 @@ func (c *NAEPClient) FetchNAEPData(school *School) (*NAEPData, error) {
 		}
 	}
 
-	// Attempt to fetch district-level data for large cities
-	if districtCode := c.matchDistrict(school); districtCode != "" {
-		districtScores, err := c.fetchScoresForJurisdiction(districtCode, grades, years)
+	// Attempt to fetch district-level data for large cities, unless pinned to state results
+	pin := c.Override(school.NCESSCH).Jurisdiction
+	if districtCode := c.matchDistrict(school); districtCode != "" && pin != naepPinState {
+		districtScores, err := c.fetchScoresForJurisdiction(ctx, districtCode, nil, grades, years)
 		if err == nil && len(districtScores) > 0 {
 			data.District = school.District
 			data.DistrictScores = districtScores
 		}
 	}
+	if pin == naepPinDistrict && len(data.DistrictScores) == 0 {
+		return nil, fmt.Errorf("%w for district %q (NAEP jurisdiction is pinned to district)", ErrNoNAEPData, school.District)
+	}
+
+	// Comparison fetches that failed because ctx was canceled would cache incomplete results
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 
 	// Sort all scores by grade, subject (alphabetically), and year (most recent first)
 	sortNAEPScores(data.StateScores)
@@ -185,6 +381,9 @@ func (c *NAEPClient) FetchNAEPData(school *School) (*NAEPData, error) {
 		log.Printf("Warning: failed to cache NAEP data: %v", err)
 	}
 
+	// Flag subjects that dropped sharply since the prior assessment
+	c.recordDeclineAlerts(school, data)
+
 	return data, nil
 }
 
@@ -245,15 +444,21 @@ func (c *NAEPClient) matchDistrict(school *School) string {
 	return ""
 }
 
-// fetchScoresForJurisdiction fetches NAEP scores for a jurisdiction
-func (c *NAEPClient) fetchScoresForJurisdiction(jurisCode string, grades []int, years []string) ([]NAEPScore, error) {
+// fetchScoresForJurisdiction fetches NAEP scores for a jurisdiction, limited to subjects if non-nil
+func (c *NAEPClient) fetchScoresForJurisdiction(ctx context.Context, jurisCode string, subjects []string, grades []int, years []string) ([]NAEPScore, error) {
 	var allScores []NAEPScore
 	var errors []string
 
 	// Fetch for each subject
 	for subjectName, subjectInfo := range naepSubjects {
+		if subjects != nil && !slices.Contains(subjects, subjectName) {
+			continue
+		}
 		for _, grade := range grades {
-			scores, err := c.fetchSubjectScores(jurisCode, subjectName, subjectInfo.code, subjectInfo.subscale, grade, years)
+			scores, err := c.fetchSubjectScores(ctx, jurisCode, subjectName, subjectInfo.code, subjectInfo.subscale, grade, years)
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, ctxErr
+			}
 			if err != nil {
 				// Collect errors but don't fail entire request if one subject/grade combo fails
 				errors = append(errors, fmt.Sprintf("%s grade %d: %v", subjectName, grade, err))
@@ -277,7 +482,7 @@ func (c *NAEPClient) fetchScoresForJurisdiction(jurisCode string, grades []int,
 }
 
 // fetchSubjectScores fetches scores for a specific subject/grade/jurisdiction
-func (c *NAEPClient) fetchSubjectScores(jurisCode, subjectName, subjectCode, subscale string, grade int, years []string) ([]NAEPScore, error) {
+func (c *NAEPClient) fetchSubjectScores(ctx context.Context, jurisCode, subjectName, subjectCode, subscale string, grade int, years []string) ([]NAEPScore, error) {
 	// Build URL for mean scores
 	meanURL := c.buildNAEPURL(map[string]string{
 		"type":         "data",
@@ -286,11 +491,11 @@ func (c *NAEPClient) fetchSubjectScores(jurisCode, subjectName, subjectCode, sub
 		"subscale":     subscale,
 		"variable":     "TOTAL",
 		"jurisdiction": jurisCode,
-		"stattype":     "MN:MN",
+		"stattype":     naepStatTypeMean,
 		"Year":         strings.Join(years, ","),
 	})
 
-	meanScores, err := c.fetchAndParse(meanURL)
+	meanScores, err := c.fetchAndParse(ctx, meanURL)
 	if err != nil {
 		return nil, err
 	}
@@ -303,11 +508,12 @@ func (c *NAEPClient) fetchSubjectScores(jurisCode, subjectName, subjectCode, sub
 		"subscale":     subscale,
 		"variable":     "TOTAL",
 		"jurisdiction": jurisCode,
-		"stattype":     "ALC:AP", // At or above proficient
+		"stattype":     naepStatTypeProficient,
 		"Year":         strings.Join(years, ","),
 	})
 
-	alcScores, _ := c.fetchAndParse(alcURL)
+	// Achievement levels are optional; record the failure on each score instead of dropping them
+	alcScores, alcErr := c.fetchAndParse(ctx, alcURL)
 
 	// Combine mean and achievement level data
 	var scores []NAEPScore
@@ -320,12 +526,22 @@ func (c *NAEPClient) fetchSubjectScores(jurisCode, subjectName, subjectCode, sub
 			JurisCode:    jurisCode,
 			MeanScore:    dp.Value,
 			ErrorCode:    dp.ErrorFlag,
+			StatType:     naepStatTypeMean,
+			SourceURL:    meanURL,
+
+			ProficiencyStatType:  naepStatTypeProficient,
+			ProficiencySourceURL: alcURL,
+		}
+
+		if alcErr != nil {
+			score.ProficiencyError = alcErr.Error()
 		}
 
 		// Find matching achievement level data
 		for _, alc := range alcScores {
 			if alc.Year == dp.Year {
 				score.AtProficient = alc.Value
+				score.ProficiencyErrorCode = alc.ErrorFlag
 				break
 			}
 		}
@@ -338,7 +554,10 @@ func (c *NAEPClient) fetchSubjectScores(jurisCode, subjectName, subjectCode, sub
 
 // buildNAEPURL builds a NAEP API URL with parameters
 func (c *NAEPClient) buildNAEPURL(params map[string]string) string {
-	baseURL := "https://www.nationsreportcard.gov/DataService/GetAdhocData.aspx"
+	baseURL := c.baseURL
+	if baseURL == "" {
+		baseURL = naepDataServiceURL
+	}
 
 	u, _ := url.Parse(baseURL)
 	q := u.Query()
@@ -351,30 +570,20 @@ func (c *NAEPClient) buildNAEPURL(params map[string]string) string {
 	return u.String()
 }
 
-// fetchAndParse fetches and parses NAEP API response
-func (c *NAEPClient) fetchAndParse(apiURL string) ([]naepDataPoint, error) {
-	resp, err := c.httpClient.Get(apiURL)
-	if err != nil {
-		if logger != nil {
-			logger.Error("NAEP API HTTP request failed", "error", err, "url", apiURL)
-		}
-		return nil, fmt.Errorf("HTTP request failed: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		if logger != nil {
-			logger.Error("NAEP API returned non-OK status", "status_code", resp.StatusCode, "url", apiURL)
-		}
-		return nil, fmt.Errorf("API returned status %d for URL: %s", resp.StatusCode, apiURL)
-	}
-
-	body, err := io.ReadAll(resp.Body)
+// fetchAndParse fetches and parses NAEP API response, retrying transient failures
+func (c *NAEPClient) fetchAndParse(ctx context.Context, apiURL string) ([]naepDataPoint, error) {
+	var body []byte
+	err := c.retry.Do(ctx, func() error {
+		var err error
+		body, err = c.fetch(ctx, apiURL)
+		return err
+	})
 	if err != nil {
-		if logger != nil {
-			logger.Error("Failed to read NAEP API response body", "error", err, "url", apiURL)
+		var statusErr *httpStatusError
+		if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusTooManyRequests {
+			return nil, fmt.Errorf("%w: NAEP API returned %v", ErrUpstreamRateLimited, statusErr)
 		}
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return nil, err
 	}
 
 	var apiResp naepAPIResponse
@@ -411,6 +620,64 @@ func (c *NAEPClient) fetchAndParse(apiURL string) ([]naepDataPoint, error) {
 	return apiResp.Result, nil
 }
 
+// fetch makes one NAEP API request and returns the response body
+func (c *NAEPClient) fetch(ctx context.Context, apiURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		if logger != nil {
+			logger.Error("NAEP API HTTP request failed", "error", err, "url", apiURL)
+		}
+		return nil, fmt.Errorf("HTTP request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to read NAEP API response body", "error", err, "url", apiURL)
+		}
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	// Keep the raw response so cached values can be audited later
+	c.archiveRawResponse(apiURL, resp.StatusCode, body)
+
+	if resp.StatusCode != http.StatusOK {
+		if logger != nil {
+			logger.Error("NAEP API returned non-OK status", "status_code", resp.StatusCode, "url", apiURL)
+		}
+		return nil, fmt.Errorf("API returned %w for URL: %s", newHTTPStatusError(resp), apiURL)
+	}
+	return body, nil
+}
+
+// archiveRawResponse stores a NAEP API response body along with the request parameters
+func (c *NAEPClient) archiveRawResponse(apiURL string, statusCode int, body []byte) {
+	if c.db == nil {
+		return
+	}
+
+	var jurisdiction, subject, statType string
+	var grade int
+	if u, err := url.Parse(apiURL); err == nil {
+		q := u.Query()
+		jurisdiction = q.Get("jurisdiction")
+		subject = q.Get("subject")
+		statType = q.Get("stattype")
+		grade, _ = strconv.Atoi(q.Get("grade"))
+	}
+
+	if err := c.db.SaveNAEPRawResponse(apiURL, jurisdiction, subject, grade, statType, statusCode, string(body)); err != nil {
+		if logger != nil {
+			logger.Warn("Failed to archive NAEP API response", "error", err, "url", apiURL)
+		}
+	}
+}
+
 func min(a, b int) int {
 	if a < b {
 		return a
@@ -418,13 +685,18 @@ func min(a, b int) int {
 	return b
 }
 
-// getCachedData retrieves cached NAEP data from the database
+// getCachedData retrieves unexpired cached NAEP data from the database
 func (c *NAEPClient) getCachedData(ncessch string) (*NAEPData, error) {
+	return c.loadCachedData(ncessch, c.cacheTTL)
+}
+
+// loadCachedData retrieves cached NAEP data no older than maxAge
+func (c *NAEPClient) loadCachedData(ncessch string, maxAge time.Duration) (*NAEPData, error) {
 	if c.db == nil {
 		return nil, fmt.Errorf("database not available")
 	}
 
-	state, district, stateScoresJSON, districtScoresJSON, nationalScoresJSON, extractedAt, err := c.db.LoadNAEPCache(ncessch, c.cacheTTL)
+	state, district, stateScoresJSON, districtScoresJSON, nationalScoresJSON, extractedAt, err := c.db.LoadNAEPCache(ncessch, maxAge)
 	if err != nil {
 		return nil, err
 	}
@@ -1,8 +1,16 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 )
 
 // TestDetermineGrades tests the grade determination logic
@@ -562,3 +570,166 @@ func TestGetAchievementLevelsZeroData(t *testing.T) {
 		t.Error("Expected all zeros for missing data")
 	}
 }
+
+// TestFetchSubjectScoresProvenance tests that scores record their source and raw responses are archived
+func TestFetchSubjectScoresProvenance(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("stattype") == naepStatTypeProficient {
+			// Simulate a failed achievement level request
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, "server error")
+			return
+		}
+		fmt.Fprint(w, `{"status":200,"result":[{"value":240.5,"errorFlag":3,"year":2022,"jurisLabel":"California"}]}`)
+	}))
+	defer server.Close()
+
+	client := &NAEPClient{httpClient: server.Client(), baseURL: server.URL, db: db}
+
+	scores, err := client.fetchSubjectScores(context.Background(), "CA", "mathematics", "mathematics", "MRPCM", 4, []string{"2022"})
+	if err != nil {
+		t.Fatalf("fetchSubjectScores failed: %v", err)
+	}
+	if len(scores) != 1 {
+		t.Fatalf("Expected 1 score, got %d", len(scores))
+	}
+
+	score := scores[0]
+	if score.StatType != naepStatTypeMean || score.ProficiencyStatType != naepStatTypeProficient {
+		t.Errorf("Unexpected stattypes: %q, %q", score.StatType, score.ProficiencyStatType)
+	}
+	if !strings.HasPrefix(score.SourceURL, server.URL) || !strings.Contains(score.SourceURL, "stattype=MN") {
+		t.Errorf("Unexpected source URL: %s", score.SourceURL)
+	}
+	if score.ErrorCode != 3 {
+		t.Errorf("Expected error code 3, got %d", score.ErrorCode)
+	}
+	if score.ProficiencyError == "" || !score.HasErrors() {
+		t.Error("Expected failed achievement level request to be recorded")
+	}
+
+	// Both responses, including the failed one, are archived
+	for _, u := range []string{score.SourceURL, score.ProficiencySourceURL} {
+		raw, err := db.ListNAEPRawResponses(u, 1)
+		if err != nil {
+			t.Fatalf("Failed to list raw responses: %v", err)
+		}
+		if len(raw) != 1 {
+			t.Fatalf("Expected 1 archived response for %s, got %d", u, len(raw))
+		}
+		if raw[0].Jurisdiction != "CA" || raw[0].Grade != 4 || raw[0].Subject != "mathematics" {
+			t.Errorf("Unexpected archived metadata: %+v", raw[0])
+		}
+	}
+}
+
+// TestResolveNAEPJurisdiction tests mapping of DoDEA, Puerto Rico, BIE, and territory schools
+func TestResolveNAEPJurisdiction(t *testing.T) {
+	testCases := []struct {
+		name        string
+		state       string
+		stateName   string
+		expected    string
+		subjects    []string
+		notAssessed bool
+	}{
+		{"Regular state", "CA", "California", "CA", nil, false},
+		{"Puerto Rico is mathematics only", "PR", "Puerto Rico", "PR", []string{"mathematics"}, false},
+		{"DoDEA code", "DD", "Department of Defense Education Activity", "DS", nil, false},
+		{"DoDEA by state name", "XX", "DoD (Overseas)", "DS", nil, false},
+		{"DoDEA unknown code with defense name", "ZZ", "Department of Defense", "DS", nil, false},
+		{"BIE not assessed", "BI", "Bureau of Indian Education", "", nil, true},
+		{"BIE by state name", "ZZ", "Bureau of Indian Education", "", nil, true},
+		{"Guam not assessed", "GU", "Guam", "", nil, true},
+		{"Lowercase code", "pr", "Puerto Rico", "PR", []string{"mathematics"}, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			school := &School{State: tc.state, StateName: tc.stateName}
+			code, subjects, err := resolveNAEPJurisdiction(school)
+
+			if tc.notAssessed {
+				if !errors.Is(err, errNAEPNotAssessed) {
+					t.Fatalf("Expected not-assessed error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			expected := tc.expected
+			if expected == "" {
+				expected = tc.state // Unrecognized codes pass through
+			}
+			if code != expected {
+				t.Errorf("Expected jurisdiction %s, got %s", expected, code)
+			}
+			if !slices.Equal(subjects, tc.subjects) {
+				t.Errorf("Expected subjects %v, got %v", tc.subjects, subjects)
+			}
+		})
+	}
+}
+
+// TestFetchNAEPDataNotAssessed tests that territory schools fail fast without calling the API
+func TestFetchNAEPDataNotAssessed(t *testing.T) {
+	client := &NAEPClient{baseURL: "http://127.0.0.1:0"}
+	school := MockSchool("660000100001", "Guam Elementary", "Guam DOE", "GU", "KG", "05")
+
+	_, err := client.FetchNAEPData(context.Background(), school)
+	if !errors.Is(err, errNAEPNotAssessed) {
+		t.Fatalf("Expected not-assessed error, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "Guam") {
+		t.Errorf("Expected error to name the territory, got %q", err.Error())
+	}
+}
+
+// TestFetchNAEPDataCanceled tests that canceling the context aborts in-flight requests without caching
+func TestFetchNAEPDataCanceled(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	started := make(chan struct{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-r.Context().Done() // Hang until the client gives up
+	}))
+	defer server.Close()
+
+	client := &NAEPClient{httpClient: server.Client(), baseURL: server.URL, db: db, cacheTTL: time.Hour}
+	school := MockSchool("360000100001", "Lincoln Elementary School", "San Francisco Unified", "CA", "KG", "05")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := client.FetchNAEPData(ctx, school)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("FetchNAEPData did not return after cancel")
+	}
+
+	if _, err := client.loadCachedData(school.NCESSCH, cacheNoExpiry); !errors.Is(err, ErrCacheMiss) {
+		t.Errorf("Expected nothing cached after cancel, got %v", err)
+	}
+}
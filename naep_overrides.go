@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// NAEP jurisdiction pins for a school
+const (
+	naepPinAuto     = ""         // Use district results when available, otherwise state
+	naepPinState    = "state"    // Always use state results
+	naepPinDistrict = "district" // Require district results
+)
+
+// NAEPOverride holds per-school NAEP settings for schools that don't match NAEP well
+type NAEPOverride struct {
+	NCESSCH          string    `json:"ncessch"`
+	DisableAutoFetch bool      `json:"disable_auto_fetch"` // Don't load NAEP automatically when viewing the school
+	Jurisdiction     string    `json:"jurisdiction"`       // One of naepPinAuto, naepPinState, naepPinDistrict
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// ValidateNAEPPin checks that a jurisdiction pin is recognized
+func ValidateNAEPPin(pin string) error {
+	switch pin {
+	case naepPinAuto, naepPinState, naepPinDistrict:
+		return nil
+	}
+	return fmt.Errorf("invalid NAEP jurisdiction %q (use state, district, or empty for automatic)", pin)
+}
+
+// Override returns the NAEP settings for a school, or defaults if none are saved
+func (c *NAEPClient) Override(ncessch string) NAEPOverride {
+	if c.db == nil {
+		return NAEPOverride{NCESSCH: ncessch}
+	}
+
+	override, err := c.db.GetNAEPOverride(ncessch)
+	if err != nil {
+		if logger != nil {
+			logger.Warn("Failed to load NAEP override", "error", err, "ncessch", ncessch)
+		}
+		return NAEPOverride{NCESSCH: ncessch}
+	}
+	return override
+}
+
+// SetOverride saves a school's NAEP settings. Changing the jurisdiction pin drops the
+// cached results so the next fetch uses the new jurisdiction.
+func (c *NAEPClient) SetOverride(override NAEPOverride) error {
+	if c.db == nil {
+		return fmt.Errorf("database not available")
+	}
+	if err := ValidateNAEPPin(override.Jurisdiction); err != nil {
+		return err
+	}
+
+	previous := c.Override(override.NCESSCH)
+	if err := c.db.SaveNAEPOverride(override); err != nil {
+		return err
+	}
+
+	if previous.Jurisdiction != override.Jurisdiction {
+		if err := c.db.DeleteNAEPCache(override.NCESSCH); err != nil {
+			return err
+		}
+	}
+
+	if logger != nil {
+		logger.Info("Saved NAEP override", "ncessch", override.NCESSCH, "disable_auto_fetch", override.DisableAutoFetch, "jurisdiction", override.Jurisdiction)
+	}
+	return nil
+}
+
+// AutoFetchEnabled reports whether NAEP data should load automatically for a school
+func (c *NAEPClient) AutoFetchEnabled(ncessch string) bool {
+	return !c.Override(ncessch).DisableAutoFetch
+}
+
+// ForceRefresh fetches a school's NAEP data from the API, ignoring any cached copy
+func (c *NAEPClient) ForceRefresh(ctx context.Context, school *School) (*NAEPData, error) {
+	if logger != nil {
+		logger.Info("Forcing NAEP refresh", "ncessch", school.NCESSCH)
+	}
+	return c.fetchFresh(ctx, school)
+}
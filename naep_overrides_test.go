@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestNAEPOverrides tests saving overrides and cache invalidation when the jurisdiction pin changes
+func TestNAEPOverrides(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	client := &NAEPClient{db: db, cacheTTL: defaultNAEPCacheTTL}
+	const id = "360000100001"
+
+	// Defaults when nothing is saved
+	if !client.AutoFetchEnabled(id) {
+		t.Error("Expected auto-fetch to be enabled by default")
+	}
+	if pin := client.Override(id).Jurisdiction; pin != naepPinAuto {
+		t.Errorf("Expected automatic jurisdiction by default, got %q", pin)
+	}
+
+	stateScores, _ := json.Marshal(MockNAEPData(id, "CA", "", false, false).StateScores)
+	if err := db.SaveNAEPCache(id, "CA", "", stateScores, nil, nil, time.Now()); err != nil {
+		t.Fatalf("Failed to seed NAEP cache: %v", err)
+	}
+
+	// Disabling auto-fetch keeps cached results
+	if err := client.SetOverride(NAEPOverride{NCESSCH: id, DisableAutoFetch: true}); err != nil {
+		t.Fatalf("Failed to save override: %v", err)
+	}
+	if client.AutoFetchEnabled(id) {
+		t.Error("Expected auto-fetch to be disabled")
+	}
+	if _, err := client.getCachedData(id); err != nil {
+		t.Errorf("Expected cache to survive a settings change that keeps the jurisdiction: %v", err)
+	}
+
+	// Pinning a jurisdiction drops cached results
+	if err := client.SetOverride(NAEPOverride{NCESSCH: id, DisableAutoFetch: true, Jurisdiction: naepPinState}); err != nil {
+		t.Fatalf("Failed to save override: %v", err)
+	}
+	if got := client.Override(id); got.Jurisdiction != naepPinState || !got.DisableAutoFetch {
+		t.Errorf("Unexpected override after save: %+v", got)
+	}
+	if _, err := client.getCachedData(id); err == nil {
+		t.Error("Expected cache to be cleared after changing the jurisdiction pin")
+	}
+
+	if err := client.SetOverride(NAEPOverride{NCESSCH: id, Jurisdiction: "county"}); err == nil {
+		t.Error("Expected error for invalid jurisdiction pin")
+	}
+
+	router := NewRouter(ServerConfig{DB: db, NAEPClient: client})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("POST", "/schools/999999999999/naep/settings", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown school's settings, got %d", rec.Code)
+	}
+}
+
+// TestFetchFreshRespectsPin tests that state and district pins control which jurisdictions are fetched
+func TestFetchFreshRespectsPin(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	var mu sync.Mutex
+	jurisdictions := make(map[string]bool)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		juris := r.URL.Query().Get("jurisdiction")
+		mu.Lock()
+		jurisdictions[juris] = true
+		mu.Unlock()
+		if juris == "XL" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, `{"status":200,"result":[{"value":240,"errorFlag":0,"year":2022,"jurisLabel":"Test"}]}`)
+	}))
+	defer server.Close()
+
+	client := &NAEPClient{httpClient: server.Client(), baseURL: server.URL, db: db, cacheTTL: defaultNAEPCacheTTL}
+	school := MockSchool("360000100002", "Washington High", "Los Angeles Unified", "CA", "06", "08")
+
+	// Pinned to state: the district is never requested
+	if err := client.SetOverride(NAEPOverride{NCESSCH: school.NCESSCH, Jurisdiction: naepPinState}); err != nil {
+		t.Fatalf("Failed to save override: %v", err)
+	}
+	data, err := client.ForceRefresh(context.Background(), school)
+	if err != nil {
+		t.Fatalf("ForceRefresh failed: %v", err)
+	}
+	if jurisdictions["XL"] || len(data.DistrictScores) > 0 {
+		t.Error("Expected district results to be skipped when pinned to state")
+	}
+
+	// Pinned to district: missing district results are an error rather than a silent state fallback
+	if err := client.SetOverride(NAEPOverride{NCESSCH: school.NCESSCH, Jurisdiction: naepPinDistrict}); err != nil {
+		t.Fatalf("Failed to save override: %v", err)
+	}
+	if _, err := client.ForceRefresh(context.Background(), school); err == nil {
+		t.Error("Expected error when pinned to district and no district data is available")
+	}
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Neighborhood report limits
+const (
+	defaultNeighborhoodMiles = 3
+	maxNeighborhoodMiles     = 25
+	maxNeighborhoodSchools   = 200
+)
+
+// Commute times are estimated from straight-line distance stretched by
+// routeFactor, at typical neighborhood speeds
+const (
+	driveMPH = 25
+	walkMPH  = 3
+	maxWalk  = 45 // Minutes; longer walks aren't shown
+)
+
+// commuteMethod explains how commute times are estimated, for reports
+var commuteMethod = fmt.Sprintf("Commute times are estimates: straight-line distance × %g for the route, at %d mph driving and %d mph walking. Check a map for actual routes and traffic.", routeFactor, driveMPH, walkMPH)
+
+// Map snapshot geometry, in SVG user units
+const (
+	neighborhoodMapSize   = 400
+	neighborhoodMapRadius = 180 // Radius of the search circle
+	milesPerDegree        = 2 * math.Pi * earthRadiusMiles / 360
+)
+
+// neighborhoodLevelColors color the map's school markers by level
+var neighborhoodLevelColors = map[string]string{
+	"Prekindergarten": "#9333ea",
+	"Elementary":      "#16a34a",
+	"Middle":          "#2563eb",
+	"Secondary":       "#dc2626",
+	"High":            "#dc2626",
+}
+
+// NeighborhoodSchool is a school in a neighborhood report, numbered as on the map
+type NeighborhoodSchool struct {
+	*School
+	Number        int
+	DistanceMiles float64
+	X, Y          float64 // Map position
+}
+
+// DriveMinutes estimates the drive to the school, at least one minute
+func (s NeighborhoodSchool) DriveMinutes() int {
+	return commuteMinutes(s.DistanceMiles, driveMPH)
+}
+
+// WalkMinutes estimates the walk to the school, or 0 when it's longer than maxWalk
+func (s NeighborhoodSchool) WalkMinutes() int {
+	if m := commuteMinutes(s.DistanceMiles, walkMPH); m <= maxWalk {
+		return m
+	}
+	return 0
+}
+
+// Color is the school's map marker color
+func (s NeighborhoodSchool) Color() string {
+	if c, ok := neighborhoodLevelColors[strings.TrimSpace(s.Level.String)]; ok {
+		return c
+	}
+	return "#6b7280"
+}
+
+// commuteMinutes estimates travel time over the route for a straight-line distance
+func commuteMinutes(miles float64, mph int) int {
+	return max(1, int(math.Round(miles*routeFactor/float64(mph)*60)))
+}
+
+// NeighborhoodLevel is the report's schools at one level, nearest first
+type NeighborhoodLevel struct {
+	Level   string
+	Color   string
+	Schools []NeighborhoodSchool
+}
+
+// NeighborhoodReport is everything a relocating family asks about the schools
+// around an address or zip code: schools by level with key stats and commute
+// estimates, state NAEP context, and a map
+type NeighborhoodReport struct {
+	Query       string // What was asked for, e.g. "97214" or an address
+	Center      Home
+	RadiusMiles float64
+	Summary     *AreaSummary // Level counts, enrollment, ratio, and NAEP by state
+	Levels      []NeighborhoodLevel
+	Schools     []NeighborhoodSchool // Nearest first, numbered
+	Truncated   bool                 // More than maxNeighborhoodSchools were in range
+	BaseURL     string               // Prefix for school links; the server's URL in exports
+	GeneratedAt time.Time
+}
+
+// Title names the report, e.g. "Schools within 3 mi of ZIP 97214"
+func (r *NeighborhoodReport) Title() string {
+	place := r.Center.Label
+	if place == "" {
+		place = fmt.Sprintf("%.4f, %.4f", r.Center.Lat, r.Center.Lon)
+	}
+	return fmt.Sprintf("Schools within %g mi of %s", r.RadiusMiles, place)
+}
+
+// CommuteMethod explains the commute estimates
+func (r *NeighborhoodReport) CommuteMethod() string {
+	return commuteMethod
+}
+
+// MapSize is the map's width and height
+func (r *NeighborhoodReport) MapSize() int { return neighborhoodMapSize }
+
+// MapCenter is the map's center coordinate
+func (r *NeighborhoodReport) MapCenter() int { return neighborhoodMapSize / 2 }
+
+// MapRadius is the radius of the search circle on the map
+func (r *NeighborhoodReport) MapRadius() int { return neighborhoodMapRadius }
+
+// errInvalidRadius is returned for a radius that isn't a number of miles in range
+var errInvalidRadius = errors.New("invalid radius")
+
+// parseNeighborhoodRadius reads a radius in miles, defaulting to
+// defaultNeighborhoodMiles
+func parseNeighborhoodRadius(text string) (float64, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return defaultNeighborhoodMiles, nil
+	}
+	miles, err := strconv.ParseFloat(text, 64)
+	if err != nil || miles <= 0 || miles > maxNeighborhoodMiles {
+		return 0, fmt.Errorf("%w %q (use miles between 0 and %d)", errInvalidRadius, text, maxNeighborhoodMiles)
+	}
+	return miles, nil
+}
+
+// ResolveNeighborhoodCenter finds the center of a report: a zip code is the
+// average location of its schools, or geocoded when none have coordinates;
+// anything else goes to ResolveLocation
+func ResolveNeighborhoodCenter(ctx context.Context, db *DB, geocoder *Geocoder, location string) (*Home, error) {
+	zip, err := normalizeAreaCode(AreaZip, location)
+	if err != nil {
+		return ResolveLocation(ctx, db, geocoder, location)
+	}
+
+	lat, lon, ok, err := db.zipCentroid(zip)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return &Home{Lat: lat, Lon: lon, Label: "ZIP " + zip}, nil
+	}
+	home, err := geocoder.Geocode(ctx, zip)
+	if err != nil {
+		return nil, err
+	}
+	home.Label = "ZIP " + zip
+	return home, nil
+}
+
+// BuildNeighborhoodReport reports on the schools within radius miles of
+// center. Schools need EDGE coordinates to be placed; it returns an error
+// wrapping sql.ErrNoRows when none are in range.
+func BuildNeighborhoodReport(db *DB, query string, center Home, radius float64) (*NeighborhoodReport, error) {
+	rows, err := db.conn.Query(fmt.Sprintf(`
+		SELECT ncessch, lat, lon, %[1]s AS miles
+		FROM school_coordinates
+		WHERE %[1]s <= $3
+		ORDER BY miles, ncessch
+		LIMIT %[2]d
+	`, distanceMilesSQL("lat", "lon", 1, 2), maxNeighborhoodSchools+1), center.Lat, center.Lon, radius)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find nearby schools: %w", err)
+	}
+	defer rows.Close()
+
+	type point struct{ lat, lon, miles float64 }
+	var ids []string
+	points := make(map[string]point)
+	for rows.Next() {
+		var id string
+		var p point
+		if err := rows.Scan(&id, &p.lat, &p.lon, &p.miles); err != nil {
+			return nil, fmt.Errorf("failed to scan nearby school: %w", err)
+		}
+		ids = append(ids, id)
+		points[id] = p
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	report := &NeighborhoodReport{Query: query, Center: center, RadiusMiles: radius, GeneratedAt: time.Now()}
+	if len(ids) > maxNeighborhoodSchools {
+		ids = ids[:maxNeighborhoodSchools]
+		report.Truncated = true
+	}
+	schools, err := db.GetSchoolsInOrder(ids)
+	if err != nil {
+		return nil, err
+	}
+	if len(schools) == 0 {
+		return nil, fmt.Errorf("no schools with coordinates within %g mi of %s (needs an %s file in the data directory): %w",
+			radius, center, geocodeFilePattern, sql.ErrNoRows)
+	}
+
+	// Merged duplicates come back as their canonical school, which may be listed already
+	seen := make(map[string]bool, len(schools))
+	listed := make([]School, 0, len(schools))
+	for _, s := range schools {
+		if seen[s.NCESSCH] {
+			continue
+		}
+		seen[s.NCESSCH] = true
+		p, ok := points[s.NCESSCH]
+		if !ok {
+			lat, lon, found, err := db.SchoolCoordinates(s.NCESSCH)
+			if err != nil {
+				return nil, err
+			}
+			if !found {
+				continue
+			}
+			p = point{lat, lon, distanceMiles(center.Lat, center.Lon, lat, lon)}
+		}
+		x, y := neighborhoodMapPoint(center, radius, p.lat, p.lon)
+		report.Schools = append(report.Schools, NeighborhoodSchool{School: s, Number: len(report.Schools) + 1, DistanceMiles: p.miles, X: x, Y: y})
+		listed = append(listed, *s)
+	}
+
+	report.Summary = summarizeArea(AreaZip, "", "", listed)
+	for i := range report.Summary.States {
+		state := &report.Summary.States[i]
+		if state.NAEP, err = StateNAEPContext(db, state.Code); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, l := range report.Summary.Levels {
+		level := NeighborhoodLevel{Level: l.Level}
+		for _, s := range report.Schools {
+			name := strings.TrimSpace(s.Level.String)
+			if name == l.Level || (name == "" && l.Level == "Not reported") {
+				level.Schools = append(level.Schools, s)
+			}
+		}
+		if len(level.Schools) > 0 {
+			level.Color = level.Schools[0].Color()
+		}
+		report.Levels = append(report.Levels, level)
+	}
+	return report, nil
+}
+
+// neighborhoodMapPoint projects a location onto the map, with the center in
+// the middle and the radius on the search circle. The projection is flat,
+// which is accurate enough at neighborhood scale.
+func neighborhoodMapPoint(center Home, radius, lat, lon float64) (x, y float64) {
+	scale := neighborhoodMapRadius / radius // Map units per mile
+	east := (lon - center.Lon) * milesPerDegree * math.Cos(center.Lat*math.Pi/180)
+	north := (lat - center.Lat) * milesPerDegree
+	mid := float64(neighborhoodMapSize) / 2
+	return math.Round((mid+east*scale)*10) / 10, math.Round((mid-north*scale)*10) / 10
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCommuteMinutes(t *testing.T) {
+	s := NeighborhoodSchool{DistanceMiles: 1}
+	if got := s.DriveMinutes(); got != 3 {
+		t.Errorf("1 mi drive = %d min, want 3", got)
+	}
+	if got := s.WalkMinutes(); got != 26 {
+		t.Errorf("1 mi walk = %d min, want 26", got)
+	}
+	if got := (NeighborhoodSchool{DistanceMiles: 5}).WalkMinutes(); got != 0 {
+		t.Errorf("5 mi walk = %d min, want 0 (not shown)", got)
+	}
+	if _, err := parseNeighborhoodRadius("40"); !errors.Is(err, errInvalidRadius) {
+		t.Errorf("radius 40: error = %v, want errInvalidRadius", err)
+	}
+}
+
+func TestNeighborhoodReport(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	// Lincoln (zip 94102) and Washington about a mile apart; Roosevelt across the country
+	if _, err := db.conn.Exec(`INSERT INTO school_coordinates VALUES
+		('360000100001', 37.7749, -122.4194), ('360000100002', 37.7890, -122.4100), ('360000100004', 40.7128, -74.0060)`); err != nil {
+		t.Fatal(err)
+	}
+
+	center, err := ResolveNeighborhoodCenter(t.Context(), db, nil, "94102")
+	if err != nil || center.Label != "ZIP 94102" || center.Lat != 37.7749 {
+		t.Fatalf("ResolveNeighborhoodCenter(94102) = %+v, %v", center, err)
+	}
+	report, err := BuildNeighborhoodReport(db, "94102", *center, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Schools) != 2 || report.Schools[0].NCESSCH != "360000100001" || report.Schools[1].Number != 2 {
+		t.Fatalf("schools = %+v", report.Schools)
+	}
+	if len(report.Levels) != 2 || report.Levels[0].Level != "Elementary" || report.Levels[1].Level != "High" {
+		t.Errorf("levels = %+v", report.Levels)
+	}
+	if s := report.Schools[0]; s.X != 200 || s.Y != 200 || s.DistanceMiles != 0 {
+		t.Errorf("center school on map at %g,%g, %g mi", s.X, s.Y, s.DistanceMiles)
+	}
+	if s := report.Schools[1]; s.X <= 200 || s.Y >= 200 {
+		t.Errorf("school to the northeast on map at %g,%g", s.X, s.Y)
+	}
+	if _, err := BuildNeighborhoodReport(db, "", Home{Lat: 45.52, Lon: -122.68}, 3); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("report with no schools: error = %v, want sql.ErrNoRows", err)
+	}
+
+	router := NewRouter(ServerConfig{DB: db})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/neighborhood?location=37.7749,-122.4194&radius=2", nil))
+	body := rec.Body.String()
+	for _, want := range []string{"Schools within 2 mi of 37.7749, -122.4194", "Lincoln Elementary School", "~3 min", "<svg", "/neighborhood/export?location=37.7749%2C-122.4194&amp;radius=2"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("report page is missing %q", want)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/neighborhood/export?location=94102&radius=2", nil))
+	if got := rec.Header().Get("Content-Disposition"); !strings.HasPrefix(got, `attachment; filename="neighborhood-schools-`) {
+		t.Errorf("export Content-Disposition = %q", got)
+	}
+	body = rec.Body.String()
+	if !strings.Contains(body, `href="http://example.com/schools/360000100001"`) || strings.Contains(body, "/static/style.css") {
+		t.Error("export isn't standalone with absolute school links")
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/neighborhood/export?location=94102&radius=100", nil))
+	if rec.Code != 400 {
+		t.Errorf("radius 100: status %d, want 400", rec.Code)
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// noteExtension marks a save as a note for Obsidian, Notion, and similar apps
+// rather than JSON
+const noteExtension = ".md"
+
+// isNoteFilename reports whether a school saved to filename should be a note
+func isNoteFilename(filename string) bool {
+	return strings.EqualFold(filepath.Ext(filename), noteExtension)
+}
+
+// noteFilenameUnsafe matches characters note apps don't allow in note names,
+// either because file systems reject them or because they break [[wiki links]]
+var noteFilenameUnsafe = regexp.MustCompile(`[\\/:*?"<>|#^\[\]]+`)
+
+// tagUnsafe matches runs of characters that end a tag in note apps
+var tagUnsafe = regexp.MustCompile(`[^a-z0-9]+`)
+
+// schoolNoteFilename is a school's note name: its name and NCES ID, so it stays
+// the same on every export and two schools with one name don't collide
+func schoolNoteFilename(school *School) string {
+	name := strings.Join(strings.Fields(noteFilenameUnsafe.ReplaceAllString(school.Name, " ")), " ")
+	return fmt.Sprintf("%s (%s)%s", name, school.NCESSCH, noteExtension)
+}
+
+// noteTag turns text into a tag segment, e.g. "San Francisco Unified" into
+// "san-francisco-unified"
+func noteTag(s string) string {
+	return strings.Trim(tagUnsafe.ReplaceAllString(strings.ToLower(s), "-"), "-")
+}
+
+// schoolNoteTags are the frontmatter tags of a school's note
+func schoolNoteTags(school *School) []string {
+	tags := []string{"school"}
+	if school.Level.Valid && noteTag(school.Level.String) != "" {
+		tags = append(tags, "school/"+noteTag(school.Level.String))
+	}
+	if school.State != "" {
+		tags = append(tags, "state/"+noteTag(school.State))
+	}
+	if school.District != "" {
+		tags = append(tags, "district/"+noteTag(school.District))
+	}
+	if school.CharterString() == "Yes" {
+		tags = append(tags, "charter")
+	}
+	return tags
+}
+
+// wikiLink links to a note by name, dropping characters that would end the link
+func wikiLink(name string) string {
+	return "[[" + strings.TrimSpace(noteFilenameUnsafe.ReplaceAllString(name, " ")) + "]]"
+}
+
+// FormatSchoolNote renders a school as markdown for a note app: YAML frontmatter
+// with its ID, name, district, and tags, then the same sections in the same
+// order on every export so links to headings keep working. Sections without
+// data are left out, except Notes, which is for the reader. Logged calls come
+// just before it.
+func FormatSchoolNote(school *School, enhanced *EnhancedSchoolData, naepData *NAEPData, calls []SchoolCall) string {
+	var b strings.Builder
+
+	// Frontmatter strings are double-quoted, which YAML reads like JSON strings
+	b.WriteString("---\n")
+	fmt.Fprintf(&b, "ncessch: %s\n", strconv.Quote(school.NCESSCH))
+	fmt.Fprintf(&b, "name: %s\n", strconv.Quote(school.Name))
+	fmt.Fprintf(&b, "district: %s\n", strconv.Quote(school.District))
+	fmt.Fprintf(&b, "city: %s\n", strconv.Quote(school.City))
+	fmt.Fprintf(&b, "state: %s\n", strconv.Quote(school.State))
+	if school.Website.Valid && school.Website.String != "" {
+		fmt.Fprintf(&b, "website: %s\n", strconv.Quote(schoolWebsiteURL(school)))
+	}
+	fmt.Fprintf(&b, "aliases:\n  - %s\n", strconv.Quote(school.Name))
+	b.WriteString("tags:\n")
+	for _, tag := range schoolNoteTags(school) {
+		fmt.Fprintf(&b, "  - %s\n", tag)
+	}
+	if school.SchoolYear != "" {
+		fmt.Fprintf(&b, "school_year: %s\n", strconv.Quote(school.SchoolYear))
+	}
+	fmt.Fprintf(&b, "ccd_release: %s\n", ccdDirectoryFile.Released.Format("2006-01-02"))
+	if enhanced != nil && !enhanced.ExtractedAt.IsZero() {
+		fmt.Fprintf(&b, "website_data_as_of: %s\n", enhanced.ExtractedAt.Format("2006-01-02"))
+	}
+	b.WriteString("---\n\n")
+
+	fmt.Fprintf(&b, "# %s\n\n", school.Name)
+	fmt.Fprintf(&b, "%s in %s\n\n", wikiLink(school.District), wikiLink(school.City+", "+school.State))
+
+	b.WriteString("## Overview\n\n")
+	fmt.Fprintf(&b, "- NCES ID: %s\n", school.NCESSCH)
+	fmt.Fprintf(&b, "- Type: %s\n", school.SchoolTypeString())
+	fmt.Fprintf(&b, "- Level: %s\n", school.LevelString())
+	fmt.Fprintf(&b, "- Grades: %s\n", school.GradeRangeString())
+	fmt.Fprintf(&b, "- Charter: %s\n", school.CharterString())
+	fmt.Fprintf(&b, "- Enrollment: %s\n", school.EnrollmentString())
+	fmt.Fprintf(&b, "- Teachers: %s\n", school.TeachersString())
+	fmt.Fprintf(&b, "- Student/teacher ratio: %s\n", school.StudentTeacherRatio())
+	fmt.Fprintf(&b, "- Address: %s\n", strings.Join(strings.Fields(school.FullAddress()), " "))
+	fmt.Fprintf(&b, "- Phone: %s\n", school.PhoneString())
+	fmt.Fprintf(&b, "- Website: %s\n\n", school.WebsiteString())
+
+	if enhanced != nil {
+		writeNoteContacts(&b, enhanced)
+		writeNoteList(&b, "Programs", []noteList{
+			{"AP courses", enhanced.APCourses},
+			{"Honors", enhanced.Honors},
+			{"Special programs", enhanced.SpecialPrograms},
+			{"Languages", enhanced.Languages},
+		})
+		writeNoteList(&b, "Activities", []noteList{
+			{"Sports", enhanced.Sports},
+			{"Clubs", enhanced.Clubs},
+			{"Arts", enhanced.Arts},
+		})
+		writeNoteList(&b, "Facilities", []noteList{{"", enhanced.Facilities}})
+
+		if enhanced.MarkdownContent != "" {
+			b.WriteString("## Website Notes\n\n")
+			b.WriteString(nestMarkdownHeadings(enhanced.MarkdownContent))
+			b.WriteString("\n\n")
+		}
+	}
+
+	if naepData != nil {
+		writeNoteNAEP(&b, naepData)
+	}
+
+	if len(calls) > 0 {
+		b.WriteString("## Call Log\n\n")
+		for _, call := range calls {
+			fmt.Fprintf(&b, "- %s: %s\n", call.CalledAt.Format(callLogTimeLayout), strings.Join(strings.Fields(call.Note), " "))
+		}
+		b.WriteString("\n")
+	}
+
+	b.WriteString("## Notes\n\n")
+	return b.String()
+}
+
+// writeNoteContacts writes the Contacts section, if there are any
+func writeNoteContacts(b *strings.Builder, enhanced *EnhancedSchoolData) {
+	if enhanced.MainOfficePhone == "" && enhanced.MainOfficeEmail == "" && len(enhanced.StaffContacts) == 0 {
+		return
+	}
+	b.WriteString("## Contacts\n\n")
+	if enhanced.MainOfficePhone != "" {
+		fmt.Fprintf(b, "- Main office phone: %s\n", enhanced.MainOfficePhone)
+	}
+	if enhanced.MainOfficeEmail != "" {
+		fmt.Fprintf(b, "- Main office email: %s\n", enhanced.MainOfficeEmail)
+	}
+	for _, c := range enhanced.StaffContacts {
+		line := c.Name
+		if c.Title != "" {
+			line += ", " + c.Title
+		}
+		for _, detail := range []string{c.Email, c.Phone} {
+			if detail != "" {
+				line += " · " + detail
+			}
+		}
+		fmt.Fprintf(b, "- %s\n", line)
+	}
+	b.WriteString("\n")
+}
+
+// noteList is a labeled list of extracted items in a note section
+type noteList struct {
+	label string // Empty to list the items one per line
+	items []string
+}
+
+// writeNoteList writes a section of lists, skipping empty ones, or nothing if
+// all are empty
+func writeNoteList(b *strings.Builder, section string, lists []noteList) {
+	var body strings.Builder
+	for _, list := range lists {
+		if len(list.items) == 0 {
+			continue
+		}
+		if list.label == "" {
+			for _, item := range list.items {
+				fmt.Fprintf(&body, "- %s\n", item)
+			}
+			continue
+		}
+		fmt.Fprintf(&body, "- %s: %s\n", list.label, strings.Join(list.items, ", "))
+	}
+	if body.Len() == 0 {
+		return
+	}
+	fmt.Fprintf(b, "## %s\n\n%s\n", section, body.String())
+}
+
+// writeNoteNAEP writes the latest NAEP results, by district when available
+func writeNoteNAEP(b *strings.Builder, naepData *NAEPData) {
+	useDistrict := len(naepData.DistrictScores) > 0
+	var lines []string
+	for _, grade := range []int{4, 8} {
+		for _, subject := range []string{"mathematics", "reading"} {
+			score := naepData.GetMostRecentScore(subject, grade, useDistrict)
+			if score == nil {
+				continue
+			}
+			lines = append(lines, fmt.Sprintf("- Grade %d %s (%s, %d): %.0f%% proficient or above, average score %.0f",
+				grade, subject, score.Jurisdiction, score.Year, score.AtProficient, score.MeanScore))
+		}
+	}
+	if len(lines) == 0 {
+		return
+	}
+	b.WriteString("## Test Scores\n\n")
+	b.WriteString("NAEP results for the district or state, not this school.\n\n")
+	b.WriteString(strings.Join(lines, "\n"))
+	b.WriteString("\n\n")
+}
+
+// nestMarkdownHeadings moves markdown headings down two levels so they sit
+// under a note section, leaving code blocks alone
+func nestMarkdownHeadings(md string) string {
+	lines := strings.Split(strings.TrimSpace(md), "\n")
+	inFence := false
+	for i, line := range lines {
+		if strings.HasPrefix(line, "```") {
+			inFence = !inFence
+			continue
+		}
+		if inFence || !strings.HasPrefix(line, "#") {
+			continue
+		}
+		level := len(line) - len(strings.TrimLeft(line, "#"))
+		lines[i] = strings.Repeat("#", min(level+2, 6)) + line[level:]
+	}
+	return strings.Join(lines, "\n")
+}
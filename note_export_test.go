@@ -0,0 +1,93 @@
+package main
+
+import (
+	"database/sql"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatSchoolNote(t *testing.T) {
+	school := MockSchool("360000100001", `Lincoln "Honors" High: Campus #2`, "San Francisco Unified", "CA", "09", "12")
+	school.Level = sql.NullString{String: "High", Valid: true}
+	enhanced := &EnhancedSchoolData{
+		ExtractedAt:     time.Date(2026, 3, 4, 0, 0, 0, 0, time.UTC),
+		MainOfficeEmail: "office@lincoln.example.org",
+		StaffContacts:   []StaffContact{{Name: "Dana Ruiz", Title: "Principal", Email: "ruiz@lincoln.example.org"}},
+		APCourses:       []string{"Calculus AB", "Biology"},
+		MarkdownContent: "# About\nSome text\n```\n# not a heading\n```",
+	}
+	naep := MockNAEPData("360000100001", "CA", "", false, false)
+
+	calls := []SchoolCall{{CalledAt: time.Date(2026, 10, 12, 9, 30, 0, 0, time.Local), Note: "Tours are Tuesdays.\nCall back in November."}}
+
+	note := FormatSchoolNote(school, enhanced, naep, calls)
+
+	for _, want := range []string{
+		"---\nncessch: \"360000100001\"\nname: \"Lincoln \\\"Honors\\\" High: Campus #2\"\ndistrict: \"San Francisco Unified\"\n",
+		"tags:\n  - school\n  - school/high\n  - state/ca\n  - district/san-francisco-unified\n",
+		"ccd_release: 2024-07-31\nwebsite_data_as_of: 2026-03-04\n---\n\n# Lincoln",
+		"[[San Francisco Unified]] in [[Test City, CA]]",
+		"## Overview\n\n- NCES ID: 360000100001\n",
+		"## Contacts\n\n- Main office email: office@lincoln.example.org\n- Dana Ruiz, Principal · ruiz@lincoln.example.org\n",
+		"## Programs\n\n- AP courses: Calculus AB, Biology\n",
+		"## Website Notes\n\n### About\nSome text\n```\n# not a heading\n```",
+		"## Test Scores\n",
+		"## Call Log\n\n- 2026-10-12 09:30: Tours are Tuesdays. Call back in November.\n\n## Notes\n",
+	} {
+		if !strings.Contains(note, want) {
+			t.Errorf("note is missing %q:\n%s", want, note)
+		}
+	}
+	if strings.Contains(note, "## Activities") {
+		t.Error("note has an empty Activities section")
+	}
+
+	if got := schoolNoteFilename(school); got != "Lincoln Honors High Campus 2 (360000100001).md" {
+		t.Errorf("note filename = %q", got)
+	}
+}
+
+func TestSaveSchoolNote(t *testing.T) {
+	school := MockSchool("360000100001", "Lincoln High School", "San Francisco Unified", "CA", "09", "12")
+	filename := filepath.Join(t.TempDir(), schoolNoteFilename(school))
+
+	msg := saveSchoolData(nil, school, nil, nil, filename)().(saveMsg)
+	if msg.err != nil {
+		t.Fatal(msg.err)
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), "---\nncessch: \"360000100001\"\n") {
+		t.Errorf("saved note starts %q", string(data)[:40])
+	}
+}
+
+func TestWebSchoolNote(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	router := NewRouter(ServerConfig{DB: db})
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/schools/360000100001/note.md", nil))
+	if rec.Code != 200 {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	if got := rec.Header().Get("Content-Disposition"); !strings.Contains(got, "(360000100001).md") {
+		t.Errorf("Content-Disposition = %q", got)
+	}
+	if !strings.Contains(rec.Body.String(), "ncessch: \"360000100001\"") {
+		t.Error("note has no frontmatter")
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/schools/999999999999/note.md", nil))
+	if rec.Code != 404 {
+		t.Errorf("unknown school status = %d", rec.Code)
+	}
+}
@@ -0,0 +1,474 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Notebooks are files of named SQL or data explorer queries that run in order,
+// each saving its results as CSV, plus a chart when the results are worth
+// charting. A run's manifest stamps the data the results came from, so an
+// analysis can be rerun and compared later. See docs/NOTEBOOKS.md.
+
+// notebookManifestName is the run manifest written alongside a notebook's results
+const notebookManifestName = "run.json"
+
+// Notebook query kinds, in the run manifest
+const (
+	notebookSQL = "sql"
+	notebookAsk = "ask"
+)
+
+// Notebook is a titled list of queries
+type Notebook struct {
+	Title   string          `yaml:"title"`
+	AsOf    string          `yaml:"as_of"` // School year to query, e.g. "2022-23"; empty for the current year
+	Queries []NotebookQuery `yaml:"queries"`
+}
+
+// NotebookQuery is one named query: SQL to run as written, or a question for
+// the data explorer agent
+type NotebookQuery struct {
+	Name        string          `yaml:"name"`
+	Description string          `yaml:"description"`
+	SQL         string          `yaml:"sql"`
+	Ask         string          `yaml:"ask"`
+	Chart       *AgentChartHint `yaml:"chart"` // Overrides the inferred or agent's chart
+}
+
+// NotebookAsker answers a data explorer question; queryWithAI in production
+type NotebookAsker func(ctx context.Context, question string) (*AIQueryResult, error)
+
+// LoadNotebook reads a notebook from a YAML (.yaml, .yml) or markdown (.md) file
+func LoadNotebook(path string) (*Notebook, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read notebook: %w", err)
+	}
+
+	var nb *Notebook
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		nb = &Notebook{}
+		if err := yaml.Unmarshal(data, nb); err != nil {
+			return nil, fmt.Errorf("failed to parse notebook: %w", err)
+		}
+	case ".md", ".markdown":
+		if nb, err = parseMarkdownNotebook(data); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown notebook format %q (use .yaml or .md)", filepath.Ext(path))
+	}
+	if nb.Title == "" {
+		nb.Title = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if err := nb.validate(); err != nil {
+		return nil, err
+	}
+	for _, q := range nb.Queries {
+		if q.Chart != nil {
+			q.Chart.Type = strings.ToLower(strings.TrimSpace(q.Chart.Type))
+		}
+	}
+	return nb, nil
+}
+
+// parseMarkdownNotebook reads a markdown notebook: a "# " title, an optional
+// "As of: 2022-23" line, then one "## " section per query holding a ```sql or
+// ```ask block and optionally a ```chart block. Other text in a section is the
+// query's description.
+func parseMarkdownNotebook(data []byte) (*Notebook, error) {
+	nb := &Notebook{}
+	var query *NotebookQuery
+	var fence string
+	var block, prose []string
+
+	finishQuery := func() {
+		if query != nil {
+			query.Description = strings.Join(strings.Fields(strings.Join(prose, " ")), " ")
+			nb.Queries = append(nb.Queries, *query)
+		}
+		query, prose = nil, nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		trimmed := strings.TrimSpace(text)
+
+		if fence != "" {
+			if trimmed != "```" {
+				block = append(block, text)
+				continue
+			}
+			content := strings.TrimSpace(strings.Join(block, "\n"))
+			switch fence {
+			case notebookSQL:
+				query.SQL = content
+			case notebookAsk:
+				query.Ask = strings.Join(strings.Fields(content), " ")
+			case "chart":
+				var hint AgentChartHint
+				if err := json.Unmarshal([]byte(content), &hint); err != nil {
+					return nil, fmt.Errorf("line %d: unreadable chart block: %w", line, err)
+				}
+				query.Chart = &hint
+			}
+			fence, block = "", nil
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(trimmed, "## "):
+			finishQuery()
+			query = &NotebookQuery{Name: strings.TrimSpace(trimmed[3:])}
+		case strings.HasPrefix(trimmed, "# ") && nb.Title == "" && query == nil:
+			nb.Title = strings.TrimSpace(trimmed[2:])
+		case query == nil && strings.HasPrefix(strings.ToLower(trimmed), "as of:"):
+			nb.AsOf = strings.TrimSpace(trimmed[len("as of:"):])
+		case strings.HasPrefix(trimmed, "```"):
+			if query == nil {
+				return nil, fmt.Errorf("line %d: code block before the first \"## \" query heading", line)
+			}
+			fence = strings.ToLower(strings.TrimSpace(trimmed[3:]))
+			if fence == "" {
+				fence = "text"
+			}
+		case query != nil:
+			prose = append(prose, trimmed)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read notebook: %w", err)
+	}
+	if fence != "" {
+		return nil, fmt.Errorf("unclosed ```%s block", fence)
+	}
+	finishQuery()
+	return nb, nil
+}
+
+// validate checks every query is named once and is either SQL or a question,
+// and that a notebook pinned to a past year has no questions, since the agent
+// only sees current data
+func (nb *Notebook) validate() error {
+	if len(nb.Queries) == 0 {
+		return fmt.Errorf("notebook has no queries")
+	}
+	if nb.AsOf != "" {
+		year, err := normalizeSchoolYear(nb.AsOf)
+		if err != nil {
+			return err
+		}
+		nb.AsOf = year
+	}
+	seen := make(map[string]bool, len(nb.Queries))
+	for i, q := range nb.Queries {
+		if strings.TrimSpace(q.Name) == "" {
+			return fmt.Errorf("query %d has no name", i+1)
+		}
+		if seen[q.Name] {
+			return fmt.Errorf("query %q appears twice", q.Name)
+		}
+		seen[q.Name] = true
+		if (strings.TrimSpace(q.SQL) == "") == (strings.TrimSpace(q.Ask) == "") {
+			return fmt.Errorf("query %q needs one of sql or ask", q.Name)
+		}
+		if q.Ask != "" && nb.AsOf != "" && nb.AsOf != currentSchoolYear() {
+			return fmt.Errorf("query %q asks the agent, which can't be pinned to %s", q.Name, nb.AsOf)
+		}
+	}
+	return nil
+}
+
+// NotebookRun is a notebook run's manifest
+type NotebookRun struct {
+	Notebook string              `json:"notebook"`
+	Title    string              `json:"title"`
+	RanAt    time.Time           `json:"ran_at"`
+	Data     NotebookDataStamp   `json:"data"`
+	Queries  []NotebookRunResult `json:"queries"`
+	Error    string              `json:"error,omitempty"` // Why the run stopped early
+}
+
+// NotebookRunResult is one query's results
+type NotebookRunResult struct {
+	Name     string   `json:"name"`
+	Kind     string   `json:"kind"`               // notebookSQL or notebookAsk
+	Question string   `json:"question,omitempty"` // For agent queries
+	SQL      string   `json:"sql,omitempty"`      // The SQL run, including the agent's
+	Rows     int      `json:"rows"`
+	Files    []string `json:"files"`
+	SHA256   string   `json:"sha256,omitempty"` // Of the CSV, to compare runs
+	Error    string   `json:"error,omitempty"`
+}
+
+// NotebookDataStamp identifies the data a run queried
+type NotebookDataStamp struct {
+	AppVersion     string               `json:"app_version"`
+	SchoolYear     string               `json:"school_year"`
+	Versions       []DataVersion        `json:"versions"` // The school year's CCD files
+	Schools        int64                `json:"schools"`
+	ImportedTables []NotebookTableStamp `json:"imported_tables,omitempty"`
+	Fingerprint    string               `json:"fingerprint"` // Changes when any of the above does
+}
+
+// NotebookTableStamp is an imported table a query may have read
+type NotebookTableStamp struct {
+	Name       string    `json:"name"`
+	SourceFile string    `json:"source_file"`
+	Rows       int64     `json:"rows"`
+	ImportedAt time.Time `json:"imported_at"`
+}
+
+// notebookDataStamp describes the data a notebook queries, the current year's
+// or a past one's, and fingerprints it
+func notebookDataStamp(db *DB, asOf string) (NotebookDataStamp, error) {
+	stamp := NotebookDataStamp{AppVersion: version, SchoolYear: currentSchoolYear()}
+	if asOf != "" {
+		stamp.SchoolYear = asOf
+	}
+	var err error
+	if stamp.SchoolYear == currentSchoolYear() {
+		stamp.Versions, err = db.CurrentDataVersions()
+	} else {
+		stamp.Versions, err = db.DataVersions(stamp.SchoolYear)
+	}
+	if err != nil {
+		return stamp, err
+	}
+	count, err := db.AsOfQuery(`SELECT count(*) FROM directory`, stamp.SchoolYear)
+	if err != nil {
+		return stamp, err
+	}
+	if err := db.conn.QueryRow(count).Scan(&stamp.Schools); err != nil {
+		return stamp, fmt.Errorf("failed to count schools: %w", err)
+	}
+	tables, err := db.ImportedTables()
+	if err != nil {
+		return stamp, err
+	}
+	for _, t := range tables {
+		stamp.ImportedTables = append(stamp.ImportedTables, NotebookTableStamp{Name: t.Name, SourceFile: t.SourceFile, Rows: t.RowCount, ImportedAt: t.ImportedAt.UTC()})
+	}
+
+	data, err := json.Marshal(stamp)
+	if err != nil {
+		return stamp, fmt.Errorf("failed to fingerprint data: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	stamp.Fingerprint = hex.EncodeToString(sum[:8])
+	return stamp, nil
+}
+
+// notebookPrefix names a query's result files, e.g. "02-enrollment-by-state"
+func notebookPrefix(i int, name string) string {
+	slug := noteTag(name)
+	if slug == "" {
+		slug = "query"
+	}
+	return fmt.Sprintf("%02d-%s", i+1, slug)
+}
+
+// RunNotebook runs a notebook's queries in order, writing each one's results to
+// outDir, then the run manifest. It stops at the first failing query, which is
+// recorded in the manifest; the manifest is written either way. asker may be
+// nil for notebooks without agent queries.
+func RunNotebook(ctx context.Context, db *DB, nb *Notebook, source, outDir string, asker NotebookAsker) (*NotebookRun, error) {
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", outDir, err)
+	}
+	stamp, err := notebookDataStamp(db, nb.AsOf)
+	if err != nil {
+		return nil, err
+	}
+
+	run := &NotebookRun{Notebook: filepath.Base(source), Title: nb.Title, RanAt: time.Now().UTC(), Data: stamp}
+	for i, q := range nb.Queries {
+		if err := ctx.Err(); err != nil {
+			run.Error = err.Error()
+			break
+		}
+		result, err := runNotebookQuery(ctx, db, q, nb.AsOf, filepath.Join(outDir, notebookPrefix(i, q.Name)), asker)
+		if err != nil {
+			result.Error = err.Error()
+			run.Error = fmt.Sprintf("query %q failed: %v", q.Name, err)
+		}
+		run.Queries = append(run.Queries, result)
+		if err != nil {
+			break
+		}
+	}
+
+	data, err := json.MarshalIndent(run, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal run manifest: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(outDir, notebookManifestName), data, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write run manifest: %w", err)
+	}
+	return run, nil
+}
+
+// runNotebookQuery runs one query, pinned to asOf if set, and writes its files,
+// named prefix plus an extension: .sql, .csv, .svg for a chart, and .answer.md
+// for the agent's answer. The .sql file is the query as written.
+func runNotebookQuery(ctx context.Context, db *DB, q NotebookQuery, asOf, prefix string, asker NotebookAsker) (NotebookRunResult, error) {
+	result := NotebookRunResult{Name: q.Name, Kind: notebookSQL, SQL: strings.TrimSpace(q.SQL), Files: []string{}}
+	writeFile := func(ext string, data []byte) error {
+		path := prefix + ext
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", filepath.Base(path), err)
+		}
+		result.Files = append(result.Files, filepath.Base(path))
+		return nil
+	}
+
+	hint := q.Chart
+	if q.Ask != "" {
+		result.Kind, result.Question = notebookAsk, q.Ask
+		if asker == nil {
+			return result, ErrAINotConfigured
+		}
+		answer, err := asker(ctx, q.Ask)
+		if err != nil {
+			return result, err
+		}
+		agentHint, text := extractChartHint(answer.ResponseText)
+		if hint == nil {
+			hint = agentHint
+		}
+		if err := writeFile(".answer.md", []byte(text+"\n")); err != nil {
+			return result, err
+		}
+		result.SQL = strings.TrimSpace(answer.SQLQuery)
+		if result.SQL == "" {
+			return result, nil
+		}
+	}
+
+	if err := writeFile(".sql", []byte(result.SQL+"\n")); err != nil {
+		return result, err
+	}
+	query := result.SQL
+	if asOf != "" {
+		var err error
+		if query, err = db.AsOfQuery(query, asOf); err != nil {
+			return result, err
+		}
+	}
+	var buf bytes.Buffer
+	rows, err := db.WriteQueryCSV(&buf, query)
+	if err != nil {
+		return result, err
+	}
+	result.Rows = rows
+	sum := sha256.Sum256(buf.Bytes())
+	result.SHA256 = hex.EncodeToString(sum[:])
+	if err := writeFile(".csv", buf.Bytes()); err != nil {
+		return result, err
+	}
+
+	if rows < 2 || rows > maxChartRows {
+		return result, nil
+	}
+	columns, err := csv.NewReader(&buf).Read()
+	if err != nil {
+		return result, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+	data, err := db.ExecuteQuery(query)
+	if err != nil {
+		return result, err
+	}
+	if chart := buildAgentChart(hint, columns, data); chart != nil {
+		if err := writeFile(".svg", chartSVG(chart)); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// Standalone chart SVG layout, in SVG user units
+const (
+	svgChartLabelWidth = 160
+	svgChartBarWidth   = 340
+	svgChartRowHeight  = 24
+	svgChartTitle      = 30
+)
+
+// chartSeriesColors match the data explorer's chart colors, by series index
+var chartSeriesColors = []string{"#2563eb", "#f59e0b", "#10b981"}
+
+// chartSVG draws a chart as a standalone SVG file, like the data explorer's
+// chart but without the page's styles
+func chartSVG(c *AgentChart) []byte {
+	var b strings.Builder
+	title := c.Title
+	if title == "" {
+		title = c.Description()
+	}
+
+	if c.Type == ChartLine {
+		height := svgChartTitle + lineChartHeight + svgChartRowHeight*2
+		fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" font-family="sans-serif" font-size="12">`+"\n", lineChartWidth+80, height)
+		fmt.Fprintf(&b, `<title>%s</title>`+"\n", html.EscapeString(c.Description()))
+		fmt.Fprintf(&b, `<text x="0" y="18" font-size="14" font-weight="bold">%s</text>`+"\n", html.EscapeString(title))
+		fmt.Fprintf(&b, `<g transform="translate(70 %d)">`+"\n", svgChartTitle)
+		fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="none" stroke="#e2e8f0"/>`+"\n", lineChartWidth, lineChartHeight)
+		for _, s := range c.Series {
+			fmt.Fprintf(&b, `<polyline fill="none" stroke="%s" stroke-width="2" points="%s"/>`+"\n", chartSeriesColors[s.Index%len(chartSeriesColors)], c.Points(s))
+		}
+		fmt.Fprintf(&b, `<text x="-6" y="%d" text-anchor="end">%s</text>`+"\n", lineChartPad+4, html.EscapeString(c.MaxLabel()))
+		fmt.Fprintf(&b, `<text x="-6" y="%d" text-anchor="end">%s</text>`+"\n", lineChartHeight-lineChartPad+4, html.EscapeString(c.MinLabel()))
+		fmt.Fprintf(&b, `<text x="0" y="%d">%s</text>`+"\n", lineChartHeight+16, html.EscapeString(c.FirstLabel()))
+		fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="end">%s</text>`+"\n", lineChartWidth, lineChartHeight+16, html.EscapeString(c.LastLabel()))
+		b.WriteString("</g>\n")
+		writeSVGLegend(&b, c, height-8)
+		b.WriteString("</svg>\n")
+		return []byte(b.String())
+	}
+
+	rows := c.Rows()
+	height := svgChartTitle + len(rows)*svgChartRowHeight + svgChartRowHeight
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" font-family="sans-serif" font-size="12">`+"\n", svgChartLabelWidth+svgChartBarWidth+80, height)
+	fmt.Fprintf(&b, `<title>%s</title>`+"\n", html.EscapeString(c.Description()))
+	fmt.Fprintf(&b, `<text x="0" y="18" font-size="14" font-weight="bold">%s</text>`+"\n", html.EscapeString(title))
+	barHeight := float64(svgChartRowHeight-6) / float64(len(c.Series))
+	for i, row := range rows {
+		y := svgChartTitle + i*svgChartRowHeight
+		fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="end">%s</text>`+"\n", svgChartLabelWidth-6, y+svgChartRowHeight/2+4, html.EscapeString(row.Label))
+		for j, v := range row.Values {
+			width := svgChartBarWidth * v.Percent / 100
+			barY := float64(y+3) + barHeight*float64(j)
+			fmt.Fprintf(&b, `<rect x="%d" y="%.1f" width="%.1f" height="%.1f" fill="%s"/>`+"\n", svgChartLabelWidth, barY, width, barHeight, chartSeriesColors[v.Index%len(chartSeriesColors)])
+			fmt.Fprintf(&b, `<text x="%.1f" y="%.1f" font-size="10">%s</text>`+"\n", float64(svgChartLabelWidth)+width+4, barY+barHeight-2, html.EscapeString(v.Display()))
+		}
+	}
+	writeSVGLegend(&b, c, height-8)
+	b.WriteString("</svg>\n")
+	return []byte(b.String())
+}
+
+// writeSVGLegend names each series in its color along the bottom of a chart
+func writeSVGLegend(b *strings.Builder, c *AgentChart, y int) {
+	x := 0
+	for _, s := range c.Series {
+		fmt.Fprintf(b, `<rect x="%d" y="%d" width="10" height="10" fill="%s"/>`+"\n", x, y-9, chartSeriesColors[s.Index%len(chartSeriesColors)])
+		fmt.Fprintf(b, `<text x="%d" y="%d">%s</text>`+"\n", x+14, y, html.EscapeString(s.Name))
+		x += 24 + 7*len(s.Name)
+	}
+}
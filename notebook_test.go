@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNotebook(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	// The docs' two examples are the same notebook
+	nb, err := LoadNotebook(filepath.Join("docs", "notebook_example.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromMarkdown, err := LoadNotebook(filepath.Join("docs", "notebook_example.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range nb.Queries {
+		nb.Queries[i].SQL = strings.TrimSpace(nb.Queries[i].SQL)
+	}
+	if !reflect.DeepEqual(nb, fromMarkdown) {
+		t.Fatalf("markdown notebook = %+v, want %+v", fromMarkdown, nb)
+	}
+
+	asker := func(ctx context.Context, question string) (*AIQueryResult, error) {
+		return &AIQueryResult{
+			ResponseText: "Texas has the most charters.\n\n```chart\n{\"type\": \"bar\", \"x\": \"ST\", \"y\": [\"charters\"]}\n```",
+			SQLQuery:     "SELECT ST, count(*) AS charters FROM directory GROUP BY ST ORDER BY ST",
+		}, nil
+	}
+	dir := t.TempDir()
+	run, err := RunNotebook(context.Background(), db, nb, "notebook_example.yaml", dir, asker)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.Error != "" || len(run.Queries) != 3 {
+		t.Fatalf("run = %+v", run)
+	}
+	if run.Data.Schools != 5 || run.Data.Fingerprint == "" || len(run.Data.Versions) != 3 || run.Data.SchoolYear != "2023-2024" {
+		t.Errorf("data stamp = %+v", run.Data)
+	}
+
+	wantFiles := [][]string{
+		{"01-schools-by-state.sql", "01-schools-by-state.csv", "01-schools-by-state.svg"},
+		{"02-largest-schools.sql", "02-largest-schools.csv"}, // A list of schools isn't charted
+		{"03-charter-share.answer.md", "03-charter-share.sql", "03-charter-share.csv", "03-charter-share.svg"},
+	}
+	for i, want := range wantFiles {
+		if !reflect.DeepEqual(run.Queries[i].Files, want) {
+			t.Errorf("query %d files = %v, want %v", i+1, run.Queries[i].Files, want)
+		}
+	}
+	if run.Queries[1].Rows != 5 || run.Queries[2].Kind != notebookAsk || !strings.HasPrefix(run.Queries[2].SQL, "SELECT ST") {
+		t.Errorf("queries = %+v", run.Queries)
+	}
+
+	csv, err := os.ReadFile(filepath.Join(dir, "01-schools-by-state.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(csv), "ST,schools,students\nCA,") {
+		t.Errorf("CSV = %q", csv)
+	}
+	svg, err := os.ReadFile(filepath.Join(dir, "01-schools-by-state.svg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(svg), "<svg") || !strings.Contains(string(svg), "Students by state") {
+		t.Errorf("SVG = %s", svg)
+	}
+	answer, err := os.ReadFile(filepath.Join(dir, "03-charter-share.answer.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(answer) != "Texas has the most charters.\n" {
+		t.Errorf("answer = %q", answer)
+	}
+
+	var manifest NotebookRun
+	data, err := os.ReadFile(filepath.Join(dir, notebookManifestName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatal(err)
+	}
+	if manifest.Title != "Enrollment by state" || manifest.Queries[0].SHA256 != run.Queries[0].SHA256 {
+		t.Errorf("manifest = %+v", manifest)
+	}
+
+	// Rerunning on the same data gives the same stamp and checksums
+	rerun, err := RunNotebook(context.Background(), db, nb, "notebook_example.yaml", t.TempDir(), asker)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rerun.Data.Fingerprint != run.Data.Fingerprint || rerun.Queries[0].SHA256 != run.Queries[0].SHA256 {
+		t.Errorf("rerun stamp %s, checksum %s; want %s, %s", rerun.Data.Fingerprint, rerun.Queries[0].SHA256, run.Data.Fingerprint, run.Queries[0].SHA256)
+	}
+
+	// Agent queries need the agent; the run stops there and says why
+	stopped, err := RunNotebook(context.Background(), db, nb, "notebook_example.yaml", t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stopped.Queries) != 3 || stopped.Queries[2].Error == "" || !strings.Contains(stopped.Error, "Charter share") {
+		t.Errorf("run without the agent = %+v", stopped)
+	}
+}
+
+func TestNotebookValidation(t *testing.T) {
+	for name, content := range map[string]string{
+		"empty.yaml":    "title: Nothing\n",
+		"unnamed.yaml":  "queries:\n  - sql: SELECT 1\n",
+		"both.yaml":     "queries:\n  - name: a\n    sql: SELECT 1\n    ask: Why?\n",
+		"twice.md":      "## a\n```sql\nSELECT 1\n```\n## a\n```sql\nSELECT 2\n```\n",
+		"unclosed.md":   "## a\n```sql\nSELECT 1\n",
+		"notebook.toml": "",
+		"badchart.md":   "## a\n```sql\nSELECT 1\n```\n```chart\n{bar}\n```\n",
+		"noheading.md":  "```sql\nSELECT 1\n```\n",
+	} {
+		path := filepath.Join(t.TempDir(), name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadNotebook(path); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,504 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/smtp"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Outreach statuses: an email waits for a reply until the school answers,
+// the user gives up, or the question is settled another way
+const (
+	outreachAwaiting = "awaiting_reply"
+	outreachReplied  = "replied"
+	outreachNoReply  = "no_reply"
+	outreachClosed   = "closed"
+)
+
+// outreachStatuses lists statuses in summary order
+var outreachStatuses = []string{outreachAwaiting, outreachReplied, outreachNoReply, outreachClosed}
+
+var outreachStatusLabels = map[string]string{
+	outreachAwaiting: "Awaiting reply",
+	outreachReplied:  "Replied",
+	outreachNoReply:  "No reply",
+	outreachClosed:   "Closed",
+}
+
+// maxOutreachNote is the longest note an outreach record keeps
+const maxOutreachNote = 2000
+
+// defaultFollowUp is how long an email waits for a reply before a nudge
+const defaultFollowUp = 7 * 24 * time.Hour
+
+// outreachNudgeInterval is how often the server looks for emails due a follow-up
+const outreachNudgeInterval = time.Hour
+
+// Outreach is an email to a school, such as an enrollment inquiry, and
+// whether the school has replied
+type Outreach struct {
+	ID         int64     `json:"id"`
+	NCESSCH    string    `json:"ncessch"`
+	SchoolName string    `json:"school_name"`
+	To         string    `json:"to,omitempty"`
+	Subject    string    `json:"subject"`
+	Template   string    `json:"template,omitempty"` // Inquiry template it was drafted from
+	Status     string    `json:"status"`
+	SentOn     time.Time `json:"sent_on"`
+	FollowUpOn time.Time `json:"follow_up_on,omitzero"` // Zero for no follow-up
+	Note       string    `json:"note,omitempty"`
+	NudgedAt   time.Time `json:"-"` // When the server last sent a reminder; zero if never
+	UpdatedAt  time.Time `json:"-"`
+}
+
+// StatusLabel is the status in words, e.g. "Awaiting reply"
+func (o Outreach) StatusLabel() string {
+	return outreachStatusLabels[o.Status]
+}
+
+// FollowUpDue reports whether the email is still unanswered on or after its follow-up date
+func (o Outreach) FollowUpDue(now time.Time) bool {
+	return o.Status == outreachAwaiting && !o.FollowUpOn.IsZero() && !now.Before(o.FollowUpOn)
+}
+
+// ParseOutreachStatus accepts a status by key or label in any case, with
+// spaces or dashes for underscores
+func ParseOutreachStatus(s string) (string, error) {
+	key := strings.ToLower(strings.NewReplacer(" ", "_", "-", "_").Replace(strings.TrimSpace(s)))
+	for _, status := range outreachStatuses {
+		if key == status || key == strings.ToLower(strings.ReplaceAll(outreachStatusLabels[status], " ", "_")) {
+			return status, nil
+		}
+	}
+	return "", fmt.Errorf("unknown outreach status %q (use one of: %s)", s, strings.Join(outreachStatuses, ", "))
+}
+
+// parseOutreachDate reads a YYYY-MM-DD date, or returns def when s is empty
+func parseOutreachDate(s string, def time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return def, nil
+	}
+	day, err := time.Parse(timelineDateLayout, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q: use YYYY-MM-DD", s)
+	}
+	return day, nil
+}
+
+// outreachDay is now's date, as outreach dates are kept
+func outreachDay(now time.Time) time.Time {
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// RecordOutreach validates and records an email sent to a school. Status
+// defaults to awaiting a reply, the sent date to today, and an email awaiting
+// a reply is followed up after defaultFollowUp unless FollowUpOn is set.
+func RecordOutreach(db *DB, o *Outreach, now time.Time) error {
+	status, err := ParseOutreachStatus(cmp.Or(o.Status, outreachAwaiting))
+	if err != nil {
+		return err
+	}
+	o.Status = status
+	o.To, o.Subject, o.Note = strings.TrimSpace(o.To), strings.TrimSpace(o.Subject), strings.TrimSpace(o.Note)
+	if o.Subject == "" {
+		return errors.New("add the email's subject")
+	}
+	if len(o.Subject) > maxEditTextLength || len(o.To) > maxEditTextLength || len(o.Note) > maxOutreachNote {
+		return fmt.Errorf("keep the subject and recipient under %d characters and the note under %d", maxEditTextLength, maxOutreachNote)
+	}
+	if o.SentOn.IsZero() {
+		o.SentOn = outreachDay(now)
+	}
+	if o.FollowUpOn.IsZero() && o.Status == outreachAwaiting {
+		o.FollowUpOn = o.SentOn.Add(defaultFollowUp)
+	}
+	if !o.FollowUpOn.IsZero() && o.FollowUpOn.Before(o.SentOn) {
+		return errors.New("the follow-up date can't be before the email was sent")
+	}
+	school, err := db.GetSchoolByID(o.NCESSCH)
+	if err != nil {
+		return err
+	}
+	o.NCESSCH = school.NCESSCH
+	o.SchoolName = school.Name
+
+	err = db.conn.QueryRow(`
+		INSERT INTO outreach (ncessch, recipient, subject, template, status, sent_on, follow_up_on, note)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, updated_at
+	`, o.NCESSCH, o.To, o.Subject, o.Template, o.Status, o.SentOn.Format(timelineDateLayout), outreachDateArg(o.FollowUpOn), o.Note).Scan(&o.ID, &o.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record outreach: %w", err)
+	}
+	return nil
+}
+
+// outreachDateArg is a date for the outreach table, NULL when zero
+func outreachDateArg(day time.Time) interface{} {
+	if day.IsZero() {
+		return nil
+	}
+	return day.Format(timelineDateLayout)
+}
+
+// OutreachUpdate changes an outreach record; nil fields are left alone
+type OutreachUpdate struct {
+	Status     *string
+	FollowUpOn *string // YYYY-MM-DD, or empty to stop following up
+	Note       *string
+}
+
+// UpdateOutreach applies an update to an outreach record and returns it.
+// Moving the follow-up date allows another nudge on the new date.
+func UpdateOutreach(db *DB, id int64, update OutreachUpdate) (*Outreach, error) {
+	o, err := db.OutreachByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if update.Status != nil {
+		if o.Status, err = ParseOutreachStatus(*update.Status); err != nil {
+			return nil, err
+		}
+	}
+	if update.FollowUpOn != nil {
+		if o.FollowUpOn, err = parseOutreachDate(*update.FollowUpOn, time.Time{}); err != nil {
+			return nil, err
+		}
+		if !o.FollowUpOn.IsZero() && o.FollowUpOn.Before(o.SentOn) {
+			return nil, errors.New("the follow-up date can't be before the email was sent")
+		}
+		o.NudgedAt = time.Time{}
+	}
+	if update.Note != nil {
+		if o.Note = strings.TrimSpace(*update.Note); len(o.Note) > maxOutreachNote {
+			return nil, fmt.Errorf("keep the note under %d characters", maxOutreachNote)
+		}
+	}
+
+	_, err = db.conn.Exec(`
+		UPDATE outreach SET status = $1, follow_up_on = $2, note = $3, nudged_at = $4, updated_at = now()
+		WHERE id = $5
+	`, o.Status, outreachDateArg(o.FollowUpOn), o.Note, nullTime(o.NudgedAt), o.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update outreach: %w", err)
+	}
+	return db.OutreachByID(id)
+}
+
+// nullTime is a timestamp argument, NULL when zero
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
+// DeleteOutreach removes an outreach record by ID
+func (d *DB) DeleteOutreach(id int64) error {
+	result, err := d.conn.Exec(`DELETE FROM outreach WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete outreach: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("no outreach with ID %d: %w", id, sql.ErrNoRows)
+	}
+	return nil
+}
+
+const outreachColumns = `id, ncessch, COALESCE(recipient, ''), subject, COALESCE(template, ''), status,
+	sent_on, follow_up_on, COALESCE(note, ''), nudged_at, updated_at`
+
+// scanOutreach reads a row selected with outreachColumns
+func scanOutreach(row interface{ Scan(...any) error }) (Outreach, error) {
+	var o Outreach
+	var followUp, nudged sql.NullTime
+	err := row.Scan(&o.ID, &o.NCESSCH, &o.To, &o.Subject, &o.Template, &o.Status, &o.SentOn, &followUp, &o.Note, &nudged, &o.UpdatedAt)
+	o.FollowUpOn, o.NudgedAt = followUp.Time, nudged.Time
+	return o, err
+}
+
+// OutreachByID returns one outreach record, or sql.ErrNoRows
+func (d *DB) OutreachByID(id int64) (*Outreach, error) {
+	o, err := scanOutreach(d.conn.QueryRow(`SELECT `+outreachColumns+` FROM outreach WHERE id = $1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("no outreach with ID %d: %w", id, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load outreach: %w", err)
+	}
+	items := []Outreach{o}
+	if err := d.nameOutreach(items); err != nil {
+		return nil, err
+	}
+	return &items[0], nil
+}
+
+// nameOutreach fills in school names from the directory, so corrections and
+// merges apply; schools no longer listed keep their ID
+func (d *DB) nameOutreach(items []Outreach) error {
+	names := make(map[string]string)
+	for i := range items {
+		o := &items[i]
+		name, ok := names[o.NCESSCH]
+		if !ok {
+			school, err := d.GetSchoolByID(o.NCESSCH)
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return err
+			}
+			name = o.NCESSCH
+			if school != nil {
+				name = school.Name
+			}
+			names[o.NCESSCH] = name
+		}
+		o.SchoolName = name
+	}
+	return nil
+}
+
+// OutreachList returns the outreach to one school, or to every school when
+// ncessch is empty: emails awaiting a reply first, by follow-up date, then the
+// rest, most recently sent first
+func (d *DB) OutreachList(ncessch string) ([]Outreach, error) {
+	rows, err := d.conn.Query(`SELECT `+outreachColumns+` FROM outreach WHERE $1 = '' OR ncessch = $1`, ncessch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load outreach: %w", err)
+	}
+	defer rows.Close()
+
+	var items []Outreach
+	for rows.Next() {
+		o, err := scanOutreach(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan outreach: %w", err)
+		}
+		items = append(items, o)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if err := d.nameOutreach(items); err != nil {
+		return nil, err
+	}
+
+	slices.SortStableFunc(items, func(a, b Outreach) int {
+		aOpen, bOpen := a.Status == outreachAwaiting, b.Status == outreachAwaiting
+		switch {
+		case aOpen != bOpen:
+			if aOpen {
+				return -1
+			}
+			return 1
+		case aOpen && !a.FollowUpOn.Equal(b.FollowUpOn):
+			return a.FollowUpOn.Compare(b.FollowUpOn)
+		case !a.SentOn.Equal(b.SentOn):
+			return b.SentOn.Compare(a.SentOn)
+		}
+		return int(b.ID - a.ID)
+	})
+	return items, nil
+}
+
+// SummarizeOutreach counts outreach by status, leaving out statuses with none
+func SummarizeOutreach(items []Outreach) []ApplicationStatusCount {
+	var summary []ApplicationStatusCount
+	for _, status := range outreachStatuses {
+		count := 0
+		for _, o := range items {
+			if o.Status == status {
+				count++
+			}
+		}
+		if count > 0 {
+			summary = append(summary, ApplicationStatusCount{Status: status, Label: outreachStatusLabels[status], Count: count})
+		}
+	}
+	return summary
+}
+
+// OutreachFollowUps lists the emails still awaiting a reply on or after their
+// follow-up date, most overdue first
+func OutreachFollowUps(items []Outreach, now time.Time) []Outreach {
+	var due []Outreach
+	for _, o := range items {
+		if o.FollowUpDue(outreachDay(now)) {
+			due = append(due, o)
+		}
+	}
+	slices.SortStableFunc(due, func(a, b Outreach) int { return a.FollowUpOn.Compare(b.FollowUpOn) })
+	return due
+}
+
+// followUpMessage describes an email due a follow-up, for reminders
+func followUpMessage(o Outreach) string {
+	to := ""
+	if o.To != "" {
+		to = " to " + o.To
+	}
+	return fmt.Sprintf("No reply from %s%s since %s: %q", o.SchoolName, to, o.SentOn.Format("Jan 2"), o.Subject)
+}
+
+// outreachNudger reminds the user, by webhook, email, or both, about emails
+// to schools that are due a follow-up. It runs in the background of the web
+// server and nudges once per follow-up date.
+type outreachNudger struct {
+	db         *DB
+	webhookURL string
+	emailTo    string
+	smtpAddr   string // host:port
+	smtpFrom   string
+	smtpUser   string
+	smtpPass   string
+	baseURL    string // Public URL of the server, for links; relative links if empty
+
+	client   *http.Client
+	sendMail func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+	now      func() time.Time
+}
+
+// newOutreachNudgerFromEnv configures follow-up reminders from the
+// environment, returning nil when neither a webhook nor an email recipient is set
+func newOutreachNudgerFromEnv(db *DB) *outreachNudger {
+	n := &outreachNudger{
+		db:         db,
+		webhookURL: os.Getenv("OUTREACH_WEBHOOK_URL"),
+		emailTo:    os.Getenv("OUTREACH_NOTIFY_EMAIL"),
+		smtpAddr:   os.Getenv("SMTP_ADDR"),
+		smtpFrom:   os.Getenv("SMTP_FROM"),
+		smtpUser:   os.Getenv("SMTP_USERNAME"),
+		smtpPass:   lookupSecret(SecretSMTP),
+		baseURL:    strings.TrimSuffix(os.Getenv("SCHOOLFINDER_URL"), "/"),
+	}
+	if n.emailTo != "" && n.smtpAddr == "" {
+		fmt.Fprintln(os.Stderr, "Warning: OUTREACH_NOTIFY_EMAIL is set but SMTP_ADDR isn't; follow-up emails are disabled")
+		n.emailTo = ""
+	}
+	if n.webhookURL == "" && n.emailTo == "" {
+		return nil
+	}
+	if n.smtpFrom == "" {
+		n.smtpFrom = n.emailTo
+	}
+	n.client = &http.Client{Timeout: 10 * time.Second}
+	n.sendMail = smtp.SendMail
+	n.now = time.Now
+	return n
+}
+
+// Run nudges about due follow-ups now and every outreachNudgeInterval until ctx is done
+func (n *outreachNudger) Run(ctx context.Context) {
+	ticker := time.NewTicker(outreachNudgeInterval)
+	defer ticker.Stop()
+	for {
+		if err := n.Nudge(ctx); err != nil && logger != nil {
+			logger.Warn("Outreach follow-up reminder failed", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// outreachWebhookPayload is the JSON posted to OUTREACH_WEBHOOK_URL
+type outreachWebhookPayload struct {
+	Event     string     `json:"event"`
+	Text      string     `json:"text"` // Summary for chat webhooks such as Slack's
+	URL       string     `json:"url"`
+	FollowUps []Outreach `json:"follow_ups"`
+}
+
+// Nudge sends one reminder listing the emails that reached their follow-up
+// date since the last reminder, and marks them nudged. Emails are only marked
+// once the reminder is sent, so failures are retried on the next run.
+func (n *outreachNudger) Nudge(ctx context.Context) error {
+	items, err := n.db.OutreachList("")
+	if err != nil {
+		return err
+	}
+	now := n.now()
+	var due []Outreach
+	for _, o := range OutreachFollowUps(items, now) {
+		if o.NudgedAt.IsZero() {
+			due = append(due, o)
+		}
+	}
+	if len(due) == 0 {
+		return nil
+	}
+
+	url := n.baseURL + "/outreach"
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d school email(s) are due a follow-up:\n", len(due))
+	for _, o := range due {
+		fmt.Fprintf(&b, "- %s\n", followUpMessage(o))
+	}
+	fmt.Fprintf(&b, "Outreach: %s", url)
+	text := b.String()
+
+	if n.webhookURL != "" {
+		payload := outreachWebhookPayload{Event: "outreach_follow_up", Text: text, URL: url, FollowUps: due}
+		if err := webhookRetry.Do(ctx, func() error { return n.postWebhook(ctx, payload) }); err != nil {
+			return fmt.Errorf("outreach webhook: %w", err)
+		}
+	}
+	if n.emailTo != "" {
+		if err := n.email(len(due), text); err != nil {
+			return fmt.Errorf("outreach email: %w", err)
+		}
+	}
+
+	for _, o := range due {
+		if _, err := n.db.conn.Exec(`UPDATE outreach SET nudged_at = $1 WHERE id = $2`, now, o.ID); err != nil {
+			return fmt.Errorf("failed to mark outreach nudged: %w", err)
+		}
+	}
+	if logger != nil {
+		logger.Info("Sent outreach follow-up reminder", "emails", len(due))
+	}
+	return nil
+}
+
+func (n *outreachNudger) postWebhook(ctx context.Context, payload outreachWebhookPayload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return newHTTPStatusError(resp)
+	}
+	return nil
+}
+
+func (n *outreachNudger) email(count int, text string) error {
+	var auth smtp.Auth
+	if n.smtpUser != "" {
+		host, _, _ := strings.Cut(n.smtpAddr, ":")
+		auth = smtp.PlainAuth("", n.smtpUser, n.smtpPass, host)
+	}
+
+	subject := fmt.Sprintf("Follow up with %d school(s)", count)
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s\r\n",
+		n.smtpFrom, n.emailTo, subject, strings.ReplaceAll(text, "\n", "\r\n"))
+	return n.sendMail(n.smtpAddr, auth, n.smtpFrom, []string{n.emailTo}, []byte(msg))
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseOutreachStatus(t *testing.T) {
+	for in, want := range map[string]string{
+		"awaiting_reply": outreachAwaiting,
+		"Awaiting reply": outreachAwaiting,
+		"replied":        outreachReplied,
+		"No-Reply":       outreachNoReply,
+		" closed ":       outreachClosed,
+	} {
+		if got, err := ParseOutreachStatus(in); err != nil || got != want {
+			t.Errorf("ParseOutreachStatus(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := ParseOutreachStatus("ghosted"); err == nil {
+		t.Error("ParseOutreachStatus accepted an unknown status")
+	}
+}
+
+func TestOutreach(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	now := time.Date(2026, 10, 14, 10, 17, 0, 0, time.Local)
+
+	for _, o := range []Outreach{
+		{NCESSCH: "360000100001"},
+		{NCESSCH: "360000100001", Subject: "Tours", Status: "ghosted"},
+		{NCESSCH: "360000100001", Subject: strings.Repeat("x", maxEditTextLength+1)},
+		{NCESSCH: "360000100001", Subject: "Tours", SentOn: now, FollowUpOn: now.AddDate(0, 0, -1)},
+		{NCESSCH: "999999999999", Subject: "Tours"},
+	} {
+		if err := RecordOutreach(db, &o, now); err == nil {
+			t.Errorf("RecordOutreach(%+v) succeeded", o)
+		}
+	}
+
+	day := func(s string) time.Time {
+		d, err := time.Parse(timelineDateLayout, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	inquiry := &Outreach{NCESSCH: "360000100001", To: " enroll@sfusd.edu ", Subject: "Enrollment inquiry", Template: "enrollment"}
+	for _, o := range []*Outreach{
+		inquiry,
+		{NCESSCH: "360000100002", Subject: "Transfer question", SentOn: day("2026-10-01"), FollowUpOn: day("2026-10-05")},
+		{NCESSCH: "360000100001", Subject: "Tour dates", SentOn: day("2026-09-20"), Status: "replied"},
+	} {
+		if err := RecordOutreach(db, o, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if inquiry.To != "enroll@sfusd.edu" || inquiry.Status != outreachAwaiting || !inquiry.FollowUpOn.Equal(day("2026-10-21")) {
+		t.Errorf("recorded inquiry = %+v", inquiry)
+	}
+
+	// Awaiting a reply first, soonest follow-up first, then the rest
+	items, err := db.OutreachList("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var subjects []string
+	for _, o := range items {
+		subjects = append(subjects, o.Subject)
+	}
+	if got := strings.Join(subjects, ", "); got != "Transfer question, Enrollment inquiry, Tour dates" {
+		t.Errorf("outreach order = %s", got)
+	}
+	if items[1].SchoolName != "Lincoln Elementary School" {
+		t.Errorf("school name = %q", items[1].SchoolName)
+	}
+	if school, _ := db.OutreachList("360000100002"); len(school) != 1 {
+		t.Errorf("one school's outreach = %+v", school)
+	}
+
+	due := OutreachFollowUps(items, now)
+	if len(due) != 1 || due[0].Subject != "Transfer question" {
+		t.Errorf("follow-ups = %+v", due)
+	}
+	if summary := SummarizeOutreach(items); len(summary) != 2 || summary[0].Count != 2 || summary[1].Label != "Replied" {
+		t.Errorf("summary = %+v", summary)
+	}
+
+	status, followUp, note := "Replied", "", "Tours on Tuesdays"
+	updated, err := UpdateOutreach(db, due[0].ID, OutreachUpdate{Status: &status, FollowUpOn: &followUp, Note: &note})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Status != outreachReplied || !updated.FollowUpOn.IsZero() || updated.Note != note {
+		t.Errorf("updated = %+v", updated)
+	}
+	bad := "2026-09-01"
+	if _, err := UpdateOutreach(db, due[0].ID, OutreachUpdate{FollowUpOn: &bad}); err == nil {
+		t.Error("follow-up before the email was sent was accepted")
+	}
+
+	if err := db.DeleteOutreach(updated.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.DeleteOutreach(updated.ID); err == nil {
+		t.Error("deleting deleted outreach succeeded")
+	}
+}
+
+func TestOutreachNudger(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	now := time.Date(2026, 10, 14, 10, 0, 0, 0, time.UTC)
+
+	o := &Outreach{NCESSCH: "360000100001", To: "enroll@sfusd.edu", Subject: "Enrollment inquiry", SentOn: outreachDay(now).AddDate(0, 0, -7)}
+	if err := RecordOutreach(db, o, now); err != nil {
+		t.Fatal(err)
+	}
+
+	var payloads []outreachWebhookPayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var p outreachWebhookPayload
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			t.Error(err)
+		}
+		payloads = append(payloads, p)
+	}))
+	defer server.Close()
+
+	var mails []string
+	n := &outreachNudger{
+		db:         db,
+		webhookURL: server.URL,
+		emailTo:    "parent@example.com",
+		smtpAddr:   "localhost:25",
+		smtpFrom:   "parent@example.com",
+		baseURL:    "https://schools.example.com",
+		client:     server.Client(),
+		sendMail: func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+			mails = append(mails, string(msg))
+			return nil
+		},
+		now: func() time.Time { return now },
+	}
+
+	// One reminder per follow-up date
+	for range 2 {
+		if err := n.Nudge(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(payloads) != 1 || len(mails) != 1 {
+		t.Fatalf("sent %d webhooks and %d emails, want one each", len(payloads), len(mails))
+	}
+	p := payloads[0]
+	if p.Event != "outreach_follow_up" || p.URL != "https://schools.example.com/outreach" || len(p.FollowUps) != 1 {
+		t.Errorf("payload = %+v", p)
+	}
+	if !strings.Contains(p.Text, `No reply from Lincoln Elementary School to enroll@sfusd.edu since Oct 7: "Enrollment inquiry"`) {
+		t.Errorf("text = %q", p.Text)
+	}
+	if !strings.Contains(mails[0], "Subject: Follow up with 1 school(s)") {
+		t.Errorf("email = %q", mails[0])
+	}
+
+	// Moving the follow-up date allows another reminder on the new date
+	next := outreachDay(now).AddDate(0, 0, 3).Format(timelineDateLayout)
+	if _, err := UpdateOutreach(db, o.ID, OutreachUpdate{FollowUpOn: &next}); err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Nudge(context.Background()); err != nil || len(payloads) != 1 {
+		t.Fatalf("nudged before the new follow-up date: %d, %v", len(payloads), err)
+	}
+	now = now.AddDate(0, 0, 3)
+	if err := n.Nudge(context.Background()); err != nil || len(payloads) != 2 {
+		t.Errorf("not nudged on the new follow-up date: %d, %v", len(payloads), err)
+	}
+}
+
+func TestWebOutreach(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	router := NewRouter(ServerConfig{DB: db})
+
+	post := func(path string, form url.Values, htmx bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if htmx {
+			req.Header.Set("HX-Request", "true")
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+	get := func(path string) string {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		if rec.Code != 200 {
+			t.Fatalf("GET %s = %d", path, rec.Code)
+		}
+		return rec.Body.String()
+	}
+
+	// The drafted inquiry can be marked as sent
+	if body := get("/schools/360000100001/inquiry"); !strings.Contains(body, `hx-post="/schools/360000100001/outreach"`) {
+		t.Errorf("inquiry draft has no Mark as Sent form:\n%s", body)
+	}
+	rec := post("/schools/360000100001/outreach", url.Values{"to": {"enroll@sfusd.edu"}, "subject": {"Enrollment inquiry"}, "template": {"enrollment"}}, true)
+	if body := rec.Body.String(); rec.Code != 200 || !strings.Contains(body, "Enrollment inquiry") || !strings.Contains(body, "Awaiting reply") {
+		t.Fatalf("mark sent = %d\n%s", rec.Code, body)
+	}
+	if rec := post("/schools/360000100001/outreach", url.Values{"subject": {""}}, false); rec.Code != 422 {
+		t.Errorf("outreach without a subject = %d, want 422", rec.Code)
+	}
+	if rec := post("/schools/999999999999/outreach", url.Values{"subject": {"Hello"}}, true); rec.Code != 404 {
+		t.Errorf("outreach to an unknown school = %d, want 404", rec.Code)
+	}
+	if body := get("/schools/360000100001"); !strings.Contains(body, "Track replies") {
+		t.Error("detail page doesn't show emails sent")
+	}
+
+	items, err := db.OutreachList("")
+	if err != nil || len(items) != 1 {
+		t.Fatalf("outreach = %+v, %v", items, err)
+	}
+	if body := get("/outreach"); !strings.Contains(body, "Enrollment inquiry") || !strings.Contains(body, "1</strong> Awaiting reply") {
+		t.Errorf("outreach page:\n%s", body)
+	}
+
+	path := "/outreach/" + strconv.FormatInt(items[0].ID, 10)
+	rec = post(path, url.Values{"status": {"replied"}, "follow_up_on": {""}, "note": {"Tours on Tuesdays"}}, true)
+	if body := rec.Body.String(); rec.Code != 200 || !strings.Contains(body, `<option value="replied" selected>`) || !strings.Contains(body, `value="Tours on Tuesdays"`) {
+		t.Errorf("update = %d\n%s", rec.Code, body)
+	}
+	rec = post(path, url.Values{"status": {"ghosted"}}, true)
+	if body := rec.Body.String(); rec.Code != 200 || !strings.Contains(body, "unknown outreach status") {
+		t.Errorf("invalid update = %d\n%s", rec.Code, body)
+	}
+	if rec := post(path, url.Values{"status": {"ghosted"}}, false); rec.Code != 422 {
+		t.Errorf("invalid update without HTMX = %d, want 422", rec.Code)
+	}
+	if rec := post("/outreach/999", url.Values{"status": {"replied"}}, true); rec.Code != 404 {
+		t.Errorf("update unknown outreach = %d, want 404", rec.Code)
+	}
+}
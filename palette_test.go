@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSuggest(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	router := NewRouter(ServerConfig{DB: db})
+
+	suggest := func(query string) []Suggestion {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest("GET", "/api/suggest?q="+query, nil))
+		if rec.Code != 200 {
+			t.Fatalf("suggest %q: status %d", query, rec.Code)
+		}
+		var body struct{ Suggestions []Suggestion }
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		return body.Suggestions
+	}
+
+	got := suggest("lincoln")
+	if len(got) == 0 || got[0] != (Suggestion{Kind: "school", Label: "Lincoln Elementary School", Detail: "San Francisco, CA · Elementary", URL: "/schools/360000100001"}) {
+		t.Errorf("suggest lincoln = %+v", got)
+	}
+	var district bool
+	for _, s := range suggest("san%20francisco") {
+		district = district || (s.Kind == "district" && s.URL == "/districts/0600000")
+	}
+	if !district {
+		t.Error("suggest san francisco didn't include the district")
+	}
+	if got := suggest("l"); len(got) != 0 {
+		t.Errorf("one letter suggested %+v", got)
+	}
+
+	// Every page loads the palette
+	for _, path := range []string{"/", "/schools/360000100001", "/compare", "/agent", "/import"} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		if !strings.Contains(rec.Body.String(), `<script src="/static/palette.js"></script>`) {
+			t.Errorf("%s doesn't load the command palette", path)
+		}
+	}
+}
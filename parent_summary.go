@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ParentSummary is a plain-language school overview written for parents
+type ParentSummary struct {
+	NCESSCH     string    `json:"ncessch"`
+	Summary     string    `json:"summary"` // Markdown: three paragraphs plus strengths and questions to ask
+	GeneratedAt time.Time `json:"generated_at"`
+}
+
+// GenerateParentSummary produces (or loads from cache) a parent-friendly summary of a school
+// using CCD stats, any cached NAEP results, and any AI-extracted website content.
+// Concurrent requests for a school's summary share one Claude request.
+func (s *AIScraperService) GenerateParentSummary(ctx context.Context, school *School, enhanced *EnhancedSchoolData, naep *NAEPData) (*ParentSummary, error) {
+	// Check database cache first
+	if cached, err := s.LoadParentSummary(school.NCESSCH); err == nil {
+		return cached, nil
+	}
+
+	prompt := buildParentSummaryPrompt(school, enhanced, naep)
+	sc := *school
+	return coalesce(ctx, &s.flights, flightKey{sc.NCESSCH, "summary"}, func(ctx context.Context) (*ParentSummary, error) {
+		return s.generateParentSummary(ctx, &sc, prompt)
+	})
+}
+
+// generateParentSummary has Claude write a school's summary from prompt and caches it
+func (s *AIScraperService) generateParentSummary(ctx context.Context, school *School, prompt string) (*ParentSummary, error) {
+
+	responseText, err := s.completeText(ctx, prompt, 2000)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to generate parent summary", "error", err, "school_name", school.Name, "ncessch", school.NCESSCH)
+		}
+		return nil, err
+	}
+
+	summary := &ParentSummary{
+		NCESSCH:     school.NCESSCH,
+		Summary:     strings.TrimSpace(responseText),
+		GeneratedAt: time.Now(),
+	}
+
+	if s.db != nil {
+		if err := s.db.SaveParentSummaryCache(summary.NCESSCH, summary.Summary, summary.GeneratedAt); err != nil {
+			// Don't fail if cache save fails, just log
+			if logger != nil {
+				logger.Warn("Failed to cache parent summary", "error", err, "ncessch", school.NCESSCH)
+			}
+		}
+	}
+
+	if logger != nil {
+		logger.Info("Generated parent summary", "school_name", school.Name, "ncessch", school.NCESSCH)
+	}
+
+	return summary, nil
+}
+
+// LoadParentSummary loads a previously generated parent summary from the cache
+func (s *AIScraperService) LoadParentSummary(ncessch string) (*ParentSummary, error) {
+	if s.db == nil {
+		return nil, fmt.Errorf("database not available")
+	}
+
+	text, generatedAt, err := s.db.LoadParentSummaryCache(ncessch, s.cacheTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ParentSummary{
+		NCESSCH:     ncessch,
+		Summary:     text,
+		GeneratedAt: generatedAt,
+	}, nil
+}
+
+// buildParentSummaryPrompt assembles all known facts about a school into a summarization prompt
+func buildParentSummaryPrompt(school *School, enhanced *EnhancedSchoolData, naep *NAEPData) string {
+	var b strings.Builder
+
+	b.WriteString("You are helping a parent understand a public school. Using ONLY the facts below, write a plain-language overview.\n\n")
+
+	b.WriteString("## School Facts (NCES Common Core of Data)\n")
+	b.WriteString(fmt.Sprintf("- Name: %s\n", school.Name))
+	b.WriteString(fmt.Sprintf("- District: %s\n", school.District))
+	b.WriteString(fmt.Sprintf("- Location: %s, %s\n", school.City, school.State))
+	b.WriteString(fmt.Sprintf("- Level: %s\n", school.LevelString()))
+	b.WriteString(fmt.Sprintf("- Grades: %s\n", school.GradeRangeString()))
+	b.WriteString(fmt.Sprintf("- School type: %s\n", school.SchoolTypeString()))
+	b.WriteString(fmt.Sprintf("- Charter: %s\n", school.CharterString()))
+	b.WriteString(fmt.Sprintf("- Enrollment: %s students\n", school.EnrollmentString()))
+	b.WriteString(fmt.Sprintf("- Teachers (FTE): %s\n", school.TeachersString()))
+	b.WriteString(fmt.Sprintf("- Student/teacher ratio: %s\n", school.StudentTeacherRatio()))
+
+	if naep != nil {
+		useDistrict := len(naep.DistrictScores) > 0
+		jurisdiction := naep.State
+		if useDistrict {
+			jurisdiction = naep.District
+		}
+		b.WriteString(fmt.Sprintf("\n## NAEP Results (%s averages, not school-specific)\n", jurisdiction))
+		for _, grade := range []int{4, 8} {
+			for _, subject := range []string{"mathematics", "reading", "science"} {
+				current, previous, change := naep.GetScoreTrend(subject, grade, useDistrict)
+				if current == nil {
+					continue
+				}
+				line := fmt.Sprintf("- Grade %d %s (%d): %.0f%% at or above Proficient, average score %.0f", grade, subject, current.Year, current.AtProficient, current.MeanScore)
+				if previous != nil {
+					line += fmt.Sprintf(" (%+.1f points vs %d)", change, previous.Year)
+				}
+				b.WriteString(line + "\n")
+			}
+		}
+	}
+
+	if enhanced != nil && enhanced.MarkdownContent != "" {
+		b.WriteString("\n## Information Gathered From The School Website\n")
+		b.WriteString(truncateString(enhanced.MarkdownContent, 8000))
+		b.WriteString("\n")
+	}
+
+	b.WriteString(`
+## Instructions
+Write in warm, jargon-free language at roughly an 8th-grade reading level. Output markdown with:
+1. Exactly three short paragraphs: what the school is like, how students are doing academically, and what daily life and programs look like.
+2. A "**Strengths**" bullet list (2-4 items) grounded in the facts above.
+3. A "**Questions to Ask**" bullet list (3-5 items) a parent should raise on a tour, especially where data is missing or concerning.
+
+Do not invent facts. If something is unknown, say so. Remind the reader that NAEP figures are state or district averages, not this school's own scores.`)
+
+	return b.String()
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestBuildParentSummaryPrompt tests that all available data sources are included in the prompt
+func TestBuildParentSummaryPrompt(t *testing.T) {
+	school := MockSchool("123456789012", "Lincoln Elementary", "Test District", "CA", "KG", "05")
+
+	testCases := []struct {
+		name        string
+		enhanced    *EnhancedSchoolData
+		naep        *NAEPData
+		contains    []string
+		notContains []string
+	}{
+		{
+			name:        "CCD facts only",
+			contains:    []string{"Lincoln Elementary", "Test District", "Enrollment: 500", "Student/teacher ratio: 20.0:1", "Questions to Ask"},
+			notContains: []string{"NAEP Results", "School Website"},
+		},
+		{
+			name:     "With NAEP trend",
+			naep:     MockNAEPData("123456789012", "CA", "", false, false),
+			contains: []string{"NAEP Results (CA averages", "Grade 4 mathematics (2022): 40% at or above Proficient", "+2.0 points vs 2019"},
+		},
+		{
+			name:     "With district NAEP",
+			naep:     MockNAEPData("123456789012", "CA", "Los Angeles", true, false),
+			contains: []string{"NAEP Results (Los Angeles averages"},
+		},
+		{
+			name:     "With extracted website content",
+			enhanced: &EnhancedSchoolData{MarkdownContent: "## Principal\nJane Doe"},
+			contains: []string{"School Website", "Jane Doe"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			prompt := buildParentSummaryPrompt(school, tc.enhanced, tc.naep)
+			for _, want := range tc.contains {
+				if !strings.Contains(prompt, want) {
+					t.Errorf("Expected prompt to contain %q", want)
+				}
+			}
+			for _, unwanted := range tc.notContains {
+				if strings.Contains(prompt, unwanted) {
+					t.Errorf("Expected prompt not to contain %q", unwanted)
+				}
+			}
+		})
+	}
+}
+
+// TestParentSummaryCache tests saving and loading parent summaries from the database
+func TestParentSummaryCache(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	scraper := &AIScraperService{db: db, cacheTTL: 30 * 24 * time.Hour}
+
+	if _, err := scraper.LoadParentSummary("360000100001"); err == nil {
+		t.Fatal("Expected cache miss before any summary is saved")
+	}
+
+	generatedAt := time.Now().Add(-time.Hour)
+	if err := db.SaveParentSummaryCache("360000100001", "A friendly school.", generatedAt); err != nil {
+		t.Fatalf("Failed to save parent summary: %v", err)
+	}
+
+	summary, err := scraper.LoadParentSummary("360000100001")
+	if err != nil {
+		t.Fatalf("Failed to load parent summary: %v", err)
+	}
+	if summary.Summary != "A friendly school." {
+		t.Errorf("Expected cached summary text, got %q", summary.Summary)
+	}
+
+	// Expired entries are treated as misses
+	if _, _, err := db.LoadParentSummaryCache("360000100001", time.Minute); err == nil {
+		t.Error("Expected expired cache entry to return an error")
+	}
+}
+
+func TestParentSummaryUnknownSchool(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	router := NewRouter(ServerConfig{DB: db})
+
+	req := httptest.NewRequest("POST", "/schools/999999999999/summary", nil)
+	token := strings.Repeat("ab", 32)
+	req.AddCookie(&http.Cookie{Name: csrfCookie, Value: token})
+	req.Header.Set(csrfHeader, token)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", rec.Code)
+	}
+}
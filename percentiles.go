@@ -0,0 +1,190 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Percentile metrics
+const (
+	percentileEnrollment = "enrollment" // Total students
+	percentileTeachers   = "teachers"   // Teachers (FTE)
+	percentileRatio      = "ratio"      // Students per teacher
+)
+
+// percentileWording phrases a metric's comparison, e.g. "larger than" 78% of
+// peers, or "the smallest" of them when no peer is smaller
+var percentileWording = map[string]struct{ more, least string }{
+	percentileEnrollment: {"larger than", "the smallest"},
+	percentileTeachers:   {"more teachers than", "the fewest teachers"},
+	percentileRatio:      {"more students per teacher than", "the fewest students per teacher"},
+}
+
+// minPercentilePeers is the smallest peer group worth comparing against. A
+// state with three high schools says little about where one of them stands.
+var minPercentilePeers = 10
+
+// levelPeers names the schools of a CCD level, as in "CA elementary schools"
+var levelPeers = map[string]string{
+	"Elementary":      "elementary schools",
+	"Middle":          "middle schools",
+	"High":            "high schools",
+	"Secondary":       "secondary schools",
+	"Prekindergarten": "pre-K schools",
+	"Other":           "schools with other grade spans",
+}
+
+// Percentile places a school's metric among schools of the same level, in its
+// state and nationally. Percentiles are the share of peers with a smaller
+// value, from 0 to 100.
+type Percentile struct {
+	Metric        string
+	Value         float64
+	State         string
+	Level         string
+	StatePct      float64
+	StatePeers    int
+	NationalPct   float64
+	NationalPeers int
+}
+
+// peers names the school's peer group by level, e.g. "elementary schools"
+func (p Percentile) peers() string {
+	name, ok := levelPeers[p.Level]
+	if !ok {
+		name = "schools"
+		if p.Level != "" && p.Level != "Not applicable" {
+			name = strings.ToLower(p.Level) + " schools"
+		}
+	}
+	return name
+}
+
+// Annotation describes where the school stands, e.g. "larger than 78% of CA
+// elementary schools (64% nationally)". It is empty when neither peer group
+// is large enough to compare against.
+func (p Percentile) Annotation() string {
+	wording, ok := percentileWording[p.Metric]
+	if !ok {
+		return ""
+	}
+	phrase := func(pct float64, group string) string {
+		if math.Round(pct) == 0 {
+			return wording.least + " of " + group
+		}
+		return fmt.Sprintf("%s %.0f%% of %s", wording.more, pct, group)
+	}
+	stateOK := p.StatePeers >= minPercentilePeers
+	nationalOK := p.NationalPeers >= minPercentilePeers
+	switch {
+	case stateOK && nationalOK && p.NationalPeers > p.StatePeers:
+		return fmt.Sprintf("%s (%.0f%% nationally)", phrase(p.StatePct, p.State+" "+p.peers()), p.NationalPct)
+	case stateOK:
+		return phrase(p.StatePct, p.State+" "+p.peers())
+	case nationalOK:
+		return phrase(p.NationalPct, p.peers()+" nationally")
+	}
+	return ""
+}
+
+// SchoolPercentiles are a school's percentiles by metric
+type SchoolPercentiles map[string]Percentile
+
+// Annotation describes where the school stands on a metric, or is empty
+// without a percentile for it
+func (p SchoolPercentiles) Annotation(metric string) string {
+	if pct, ok := p[metric]; ok {
+		return pct.Annotation()
+	}
+	return ""
+}
+
+// RefreshPercentiles ranks every school's enrollment, teachers, and students
+// per teacher among schools of the same level in its state and in the country.
+// Schools without a value for a metric aren't ranked on it. It returns the
+// number of percentiles saved.
+func (d *DB) RefreshPercentiles() (int, error) {
+	tx, err := d.conn.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.Exec(`DELETE FROM school_percentiles`); err != nil {
+		return 0, fmt.Errorf("failed to clear school percentiles: %w", err)
+	}
+	result, err := tx.Exec(`
+		INSERT INTO school_percentiles
+		WITH schools AS (
+			SELECT d.NCESSCH AS ncessch, COALESCE(d.ST, '') AS state, COALESCE(d.LEVEL, '') AS level,
+				TRY_CAST(e.STUDENT_COUNT AS DOUBLE) AS students, TRY_CAST(t.TEACHERS AS DOUBLE) AS teachers
+			FROM directory d
+			LEFT JOIN teachers t ON d.NCESSCH = t.NCESSCH
+			LEFT JOIN enrollment e ON d.NCESSCH = e.NCESSCH AND e.TOTAL_INDICATOR = 'Education Unit Total'
+		), metrics AS (
+			SELECT ncessch, state, level, $1 AS metric, students AS value FROM schools WHERE students > 0
+			UNION ALL
+			SELECT ncessch, state, level, $2, teachers FROM schools WHERE teachers > 0
+			UNION ALL
+			SELECT ncessch, state, level, $3, students / teachers FROM schools WHERE students > 0 AND teachers > 0
+		)
+		SELECT ncessch, metric, value, state, level,
+			100 * percent_rank() OVER (PARTITION BY metric, state, level ORDER BY value),
+			count(*) OVER (PARTITION BY metric, state, level),
+			100 * percent_rank() OVER (PARTITION BY metric, level ORDER BY value),
+			count(*) OVER (PARTITION BY metric, level)
+		FROM metrics
+	`, percentileEnrollment, percentileTeachers, percentileRatio)
+	if err != nil {
+		return 0, fmt.Errorf("failed to rank schools: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to save school percentiles: %w", err)
+	}
+	n, _ := result.RowsAffected()
+	return int(n), nil
+}
+
+// SyncPercentiles ranks schools when the percentiles are missing, as in new
+// databases and ones from before percentiles
+func SyncPercentiles(d *DB) (int, error) {
+	var count sql.NullInt64
+	if err := d.conn.QueryRow(`SELECT count(*) FROM school_percentiles`).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to check school percentiles: %w", err)
+	}
+	if count.Int64 > 0 {
+		return 0, nil
+	}
+	return d.RefreshPercentiles()
+}
+
+// SchoolPercentiles loads the percentiles of the given schools keyed by NCESSCH
+func (d *DB) SchoolPercentiles(ncesschList []string) (map[string]SchoolPercentiles, error) {
+	percentiles := make(map[string]SchoolPercentiles)
+	if len(ncesschList) == 0 {
+		return percentiles, nil
+	}
+	rows, err := d.conn.Query(`
+		SELECT ncessch, metric, value, state, level, state_pct, state_peers, national_pct, national_peers
+		FROM school_percentiles WHERE ncessch = ANY($1)
+	`, ncesschList)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load school percentiles: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var ncessch string
+		var p Percentile
+		if err := rows.Scan(&ncessch, &p.Metric, &p.Value, &p.State, &p.Level, &p.StatePct, &p.StatePeers, &p.NationalPct, &p.NationalPeers); err != nil {
+			return nil, fmt.Errorf("failed to scan school percentile: %w", err)
+		}
+		if percentiles[ncessch] == nil {
+			percentiles[ncessch] = SchoolPercentiles{}
+		}
+		percentiles[ncessch][p.Metric] = p
+	}
+	return percentiles, rows.Err()
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPercentileAnnotation(t *testing.T) {
+	p := Percentile{Metric: percentileEnrollment, State: "CA", Level: "Elementary", StatePct: 78.4, StatePeers: 5000, NationalPct: 64.2, NationalPeers: 50000}
+	if got := p.Annotation(); got != "larger than 78% of CA elementary schools (64% nationally)" {
+		t.Errorf("Annotation() = %q", got)
+	}
+
+	// Small states fall back to the national comparison
+	p = Percentile{Metric: percentileRatio, State: "DC", Level: "High", StatePct: 50, StatePeers: 3, NationalPct: 12, NationalPeers: 20000}
+	if got := p.Annotation(); got != "more students per teacher than 12% of high schools nationally" {
+		t.Errorf("Annotation() = %q", got)
+	}
+
+	p = Percentile{Metric: percentileTeachers, State: "TX", Level: "Other", StatePct: 0.2, StatePeers: 400, NationalPct: 0.1, NationalPeers: 400}
+	if got := p.Annotation(); got != "the fewest teachers of TX schools with other grade spans" {
+		t.Errorf("Annotation() = %q", got)
+	}
+
+	p.StatePeers, p.NationalPeers = 4, 4
+	if got := p.Annotation(); got != "" {
+		t.Errorf("Annotation() with few peers = %q", got)
+	}
+	if got := (SchoolPercentiles(nil)).Annotation(percentileRatio); got != "" {
+		t.Errorf("Annotation() without percentiles = %q", got)
+	}
+}
+
+func TestSchoolPercentiles(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	percentiles, err := db.SchoolPercentiles([]string{"360000100002", "360000100004"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The test data has two high schools: Washington (CA, 850 students, 45
+	// teachers) and Roosevelt (NY, 725 students, 38.5 teachers)
+	washington := percentiles["360000100002"][percentileEnrollment]
+	if washington.Value != 850 || washington.StatePeers != 1 || washington.NationalPeers != 2 || washington.NationalPct != 100 || washington.Level != "High" {
+		t.Errorf("Washington enrollment percentile = %+v", washington)
+	}
+	roosevelt := percentiles["360000100004"]
+	if roosevelt[percentileTeachers].NationalPct != 0 || roosevelt[percentileRatio].Value >= percentiles["360000100002"][percentileRatio].Value {
+		t.Errorf("Roosevelt percentiles = %+v", roosevelt)
+	}
+
+	// Startup leaves existing percentiles alone
+	if n, err := SyncPercentiles(db); err != nil || n != 0 {
+		t.Errorf("SyncPercentiles() = %d, %v", n, err)
+	}
+
+	router := NewRouter(ServerConfig{DB: db})
+	detail := func() string {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest("GET", "/schools/360000100002", nil))
+		return rec.Body.String()
+	}
+	if strings.Contains(detail(), "percentile-note") {
+		t.Error("detail page compares against two schools")
+	}
+
+	defer func(peers int) { minPercentilePeers = peers }(minPercentilePeers)
+	minPercentilePeers = 2
+	if body := detail(); !strings.Contains(body, "larger than 100% of high schools nationally") {
+		t.Error("detail page is missing the enrollment percentile")
+	}
+}
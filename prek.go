@@ -0,0 +1,240 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// preKFilePattern matches state pre-K and Head Start locator exports: CSV
+// files with a NAME and STATE column for each program site, and optionally
+// PROGRAM_TYPE, STREET, CITY, ZIP, PHONE, WEBSITE, and NCESSCH (the public
+// school the program is housed in)
+const preKFilePattern = "PREK_*.csv"
+
+// preKColumns are the locator columns loaded into prek_programs, in table order
+var preKColumns = []string{"name", "program_type", "street", "city", "state", "zip", "phone", "website", "ncessch"}
+
+// Search sectors
+const (
+	sectorEarlyChildhood = "early_childhood" // Pre-K-only schools and locator programs
+	sectorK12            = "k12"             // Schools serving kindergarten or above
+)
+
+var sectorLabels = map[string]string{
+	sectorEarlyChildhood: "Early Childhood",
+	sectorK12:            "K-12",
+}
+
+// preKSQL matches schools offering pre-K, from CCD grades or a locator
+// program housed in the school
+const preKSQL = "(d.GSLO = 'PK' OR d.NCESSCH IN (SELECT ncessch FROM prek_programs WHERE ncessch IS NOT NULL))"
+
+// EarlyChildhood reports whether the school serves only pre-K
+func (s School) EarlyChildhood() bool {
+	return s.GradeLow.String == "PK" && s.GradeHigh.String == "PK"
+}
+
+// PreKProgram is a pre-K or Head Start site from a locator export
+type PreKProgram struct {
+	Name        string
+	ProgramType string // e.g. "Head Start" or "State Pre-K"
+	Street      string
+	City        string
+	State       string
+	Zip         string
+	Phone       string
+	Website     string
+	NCESSCH     string // The public school the program is housed in, if any
+	Source      string // The locator file it came from
+}
+
+// Location formats the program's address on one line
+func (p PreKProgram) Location() string {
+	var parts []string
+	for _, part := range []string{p.Street, p.City, strings.TrimSpace(p.State + " " + p.Zip)} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// SyncPreKPrograms loads pre-K and Head Start locator files from the data
+// directory that haven't been loaded yet. It returns the number of files loaded.
+func SyncPreKPrograms(db *DB) (int, error) {
+	paths, err := filepath.Glob(filepath.Join(db.dataDir, preKFilePattern))
+	if err != nil {
+		return 0, fmt.Errorf("failed to list pre-K files: %w", err)
+	}
+
+	loaded := 0
+	for _, path := range paths {
+		isNew, err := db.loadPreKFile(path)
+		if err != nil {
+			return loaded, err
+		}
+		if isNew {
+			loaded++
+		}
+	}
+
+	if loaded > 0 && logger != nil {
+		logger.Info("Pre-K locator files loaded", "files", loaded)
+	}
+	return loaded, nil
+}
+
+// loadPreKFile adds a locator file's programs to prek_programs unless it was
+// loaded before. Locator exports differ by state, so columns other than NAME
+// and STATE may be missing. It reports whether the file was new.
+func (d *DB) loadPreKFile(path string) (bool, error) {
+	filename := filepath.Base(path)
+
+	var loaded int
+	if err := d.conn.QueryRow(`SELECT count(*) FROM prek_files WHERE filename = $1`, filename).Scan(&loaded); err != nil {
+		return false, fmt.Errorf("failed to check pre-K files: %w", err)
+	}
+	if loaded > 0 {
+		return false, nil
+	}
+
+	source := fmt.Sprintf("read_csv('%s', all_varchar=true)", path)
+	rows, err := d.conn.Query("DESCRIBE SELECT * FROM " + source)
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s: %w", filename, err)
+	}
+	columns := make(map[string]string)
+	for rows.Next() {
+		var name, columnType string
+		var null, key, defaultValue, extra interface{}
+		if err := rows.Scan(&name, &columnType, &null, &key, &defaultValue, &extra); err != nil {
+			rows.Close()
+			return false, fmt.Errorf("failed to read %s columns: %w", filename, err)
+		}
+		columns[strings.ToLower(name)] = name
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return false, err
+	}
+
+	var selects []string
+	for _, column := range preKColumns {
+		name, ok := columns[column]
+		if !ok {
+			if column == "name" || column == "state" {
+				return false, fmt.Errorf("%s has no %s column", filename, strings.ToUpper(column))
+			}
+			selects = append(selects, "NULL AS "+column)
+			continue
+		}
+		selects = append(selects, fmt.Sprintf(`NULLIF(trim("%s"), '') AS %s`, name, column))
+	}
+
+	tx, err := d.conn.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	_, err = tx.Exec(fmt.Sprintf(`
+		INSERT INTO prek_programs (%s, source)
+		SELECT *, $1 FROM (SELECT %s FROM %s)
+		WHERE name IS NOT NULL
+	`, strings.Join(preKColumns, ", "), strings.Join(selects, ", "), source), filename)
+	if err != nil {
+		return false, fmt.Errorf("failed to load %s into pre-K programs: %w", filename, err)
+	}
+	// State locators write state names or codes; keep codes so state filters match
+	if _, err := tx.Exec(`
+		UPDATE prek_programs SET state = s.ST
+		FROM (SELECT DISTINCT ST, STATENAME FROM directory) s
+		WHERE prek_programs.source = $1 AND upper(prek_programs.state) = upper(s.STATENAME)
+	`, filename); err != nil {
+		return false, fmt.Errorf("failed to normalize pre-K program states: %w", err)
+	}
+
+	if _, err := tx.Exec(`INSERT INTO prek_files (filename) VALUES ($1)`, filename); err != nil {
+		return false, fmt.Errorf("failed to record pre-K file: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit pre-K programs: %w", err)
+	}
+	return true, nil
+}
+
+// SearchPreKPrograms finds locator programs that aren't housed in a public
+// school, for listing under the Early Childhood sector beside pre-K-only
+// schools. Programs housed in a school show up as that school offering pre-K.
+func (d *DB) SearchPreKPrograms(filters SearchFilters, limit int) ([]PreKProgram, error) {
+	conditions := []string{"(ncessch IS NULL OR ncessch NOT IN (SELECT NCESSCH FROM directory))"}
+	var args []interface{}
+	add := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+	if filters.Query != "" {
+		add("(name ILIKE $%[1]d OR city ILIKE $%[1]d OR program_type ILIKE $%[1]d OR zip LIKE $%[1]d)", "%"+filters.Query+"%")
+	}
+	if filters.State != "" {
+		add("upper(state) = upper($%d)", filters.State)
+	}
+	if filters.Name != "" {
+		add("name ILIKE $%d", "%"+filters.Name+"%")
+	}
+	if filters.City != "" {
+		add("lower(city) = lower($%d)", filters.City)
+	}
+	if filters.Zip != "" {
+		add("zip LIKE $%d", filters.Zip+"%")
+	}
+
+	rows, err := d.conn.Query(fmt.Sprintf(`
+		SELECT name, COALESCE(program_type, ''), COALESCE(street, ''), COALESCE(city, ''), state,
+			COALESCE(zip, ''), COALESCE(phone, ''), COALESCE(website, ''), source
+		FROM prek_programs
+		WHERE %s
+		ORDER BY name, city
+		LIMIT %d
+	`, strings.Join(conditions, " AND "), limit), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search pre-K programs: %w", err)
+	}
+	defer rows.Close()
+
+	var programs []PreKProgram
+	for rows.Next() {
+		var p PreKProgram
+		if err := rows.Scan(&p.Name, &p.ProgramType, &p.Street, &p.City, &p.State, &p.Zip, &p.Phone, &p.Website, &p.Source); err != nil {
+			return nil, fmt.Errorf("failed to scan pre-K program: %w", err)
+		}
+		programs = append(programs, p)
+	}
+	return programs, rows.Err()
+}
+
+// PreKPrograms loads the locator programs housed in a school
+func (d *DB) PreKPrograms(ncessch string) ([]PreKProgram, error) {
+	rows, err := d.conn.Query(`
+		SELECT name, COALESCE(program_type, ''), COALESCE(phone, ''), COALESCE(website, ''), source
+		FROM prek_programs
+		WHERE ncessch = $1
+		ORDER BY name
+	`, ncessch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load pre-K programs: %w", err)
+	}
+	defer rows.Close()
+
+	var programs []PreKProgram
+	for rows.Next() {
+		p := PreKProgram{NCESSCH: ncessch}
+		if err := rows.Scan(&p.Name, &p.ProgramType, &p.Phone, &p.Website, &p.Source); err != nil {
+			return nil, fmt.Errorf("failed to scan pre-K program: %w", err)
+		}
+		programs = append(programs, p)
+	}
+	return programs, rows.Err()
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testPreKLocator is a state locator export with one program housed in
+// Madison K-8 and two standalone sites, one without a name
+const testPreKLocator = `Name,Program_Type,Street,City,State,Zip,Phone,NCESSCH
+Sunshine Head Start,Head Start,10 Mission St,San Francisco,California,94110,(415) 555-0199,
+Madison Pre-K,State Pre-K,,Miami,FL,,,360000100005
+,Head Start,,Oakland,CA,,,
+`
+
+// loadTestPreK writes the test locator export to the data directory and loads it,
+// and adds a pre-K-only school to the directory
+func loadTestPreK(t *testing.T, db *DB) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(db.dataDir, "PREK_CA.csv"), []byte(testPreKLocator), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if loaded, err := SyncPreKPrograms(db); err != nil || loaded != 1 {
+		t.Fatalf("SyncPreKPrograms() = %d, %v", loaded, err)
+	}
+	_, err := db.conn.Exec(`
+		INSERT INTO directory BY NAME
+		SELECT '360000100010' AS NCESSCH, 'Little Sprouts Preschool' AS SCH_NAME, 'CA' AS ST, 'California' AS STATENAME,
+			'San Francisco' AS MCITY, 'San Francisco Unified School District' AS LEA_NAME, '0600000' AS LEAID,
+			'2023-2024' AS SCHOOL_YEAR, 'PK' AS GSLO, 'PK' AS GSHI
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSyncPreKPrograms(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	loadTestPreK(t, db)
+
+	// Loaded files are skipped
+	if loaded, err := SyncPreKPrograms(db); err != nil || loaded != 0 {
+		t.Errorf("second SyncPreKPrograms() = %d, %v", loaded, err)
+	}
+
+	programs, err := db.SearchPreKPrograms(SearchFilters{State: "CA"}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(programs) != 1 || programs[0].Name != "Sunshine Head Start" || programs[0].State != "CA" || programs[0].Location() != "10 Mission St, San Francisco, CA 94110" {
+		t.Errorf("SearchPreKPrograms() = %+v", programs)
+	}
+	if programs, err := db.SearchPreKPrograms(SearchFilters{Query: "head start", State: "FL"}, 10); err != nil || len(programs) != 0 {
+		t.Errorf("housed programs were listed as standalone: %+v, %v", programs, err)
+	}
+
+	housed, err := db.PreKPrograms("360000100005")
+	if err != nil || len(housed) != 1 || housed[0].ProgramType != "State Pre-K" {
+		t.Errorf("PreKPrograms() = %+v, %v", housed, err)
+	}
+}
+
+func TestPreKSearchFilters(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	loadTestPreK(t, db)
+
+	ids := func(filters SearchFilters) string {
+		t.Helper()
+		schools, err := db.SearchSchoolsFiltered(filters, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, s := range schools {
+			ids = append(ids, s.NCESSCH[len(s.NCESSCH)-2:])
+		}
+		return strings.Join(ids, ",")
+	}
+
+	// Lincoln serves PK in CCD; Madison houses a locator program
+	if got := ids(SearchFilters{PreK: "Yes", Sector: sectorK12}); got != "01,05" {
+		t.Errorf("pre-K at K-12 schools = %s", got)
+	}
+	if got := ids(SearchFilters{Sector: sectorEarlyChildhood}); got != "10" {
+		t.Errorf("early childhood schools = %s", got)
+	}
+	if got := ids(SearchFilters{Query: "sector:early prek:yes"}); got != "10" {
+		t.Errorf("query syntax = %s", got)
+	}
+
+	if err := (SearchFilters{Sector: "preschool"}).Validate(); err == nil {
+		t.Error("invalid sector was accepted")
+	}
+	if _, err := ParseSearchQuery("prek:no"); err == nil {
+		t.Error("prek:no was accepted")
+	}
+	if summary := (SearchFilters{Sector: sectorEarlyChildhood, PreK: "Yes"}).Summary(); summary != "Early Childhood, with pre-K" {
+		t.Errorf("Summary() = %q", summary)
+	}
+}
+
+func TestEarlyChildhoodSearchResults(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	loadTestPreK(t, db)
+	router := NewRouter(ServerConfig{DB: db})
+
+	form := url.Values{"sector": {sectorEarlyChildhood}, "state": {"CA"}}
+	req := httptest.NewRequest("POST", "/search", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	body := rec.Body.String()
+	for _, want := range []string{"Little Sprouts Preschool", "Sunshine Head Start", "1 early childhood program outside public schools", `class="sector-badge"`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("early childhood results are missing %q", want)
+		}
+	}
+	if strings.Contains(body, "Lincoln Elementary") {
+		t.Error("early childhood results include a K-12 school")
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/schools/360000100005", nil))
+	if !strings.Contains(rec.Body.String(), "Madison Pre-K") {
+		t.Error("school page is missing its housed pre-K program")
+	}
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Program flags a school can have, from CCD or its website
+const (
+	programSpecialEd    = "special_ed"
+	programGifted       = "gifted"
+	programDualLanguage = "dual_language"
+	programIB           = "ib"
+	programMontessori   = "montessori"
+)
+
+// programFlags lists program flags in display order
+var programFlags = []string{programSpecialEd, programGifted, programDualLanguage, programIB, programMontessori}
+
+var programFlagLabels = map[string]string{
+	programSpecialEd:    "Special education services",
+	programGifted:       "Gifted program",
+	programDualLanguage: "Dual-language immersion",
+	programIB:           "International Baccalaureate",
+	programMontessori:   "Montessori",
+}
+
+// Where a program flag came from
+const (
+	programSourceCCD     = "ccd"
+	programSourceWebsite = "website"
+)
+
+// programFlagPatterns find a program mentioned in website text. They look for
+// the program being offered, so a page that says a school has no gifted program
+// still matches; the website source and evidence make that clear to readers.
+var programFlagPatterns = map[string]*regexp.Regexp{
+	programSpecialEd:    regexp.MustCompile(`(?i)special education|special ed\b|\bIEPs?\b|individuali[sz]ed education|resource (room|specialist|program)|special day class|inclusion (program|classroom)|life skills (program|class)|autism program|\b504 plans?\b`),
+	programGifted:       regexp.MustCompile(`(?i)\bgifted\b|\bGATE\b|talented and gifted|highly capable|advanced learning program`),
+	programDualLanguage: regexp.MustCompile(`(?i)dual[- ]language|two[- ]way (bilingual|immersion)|(language|spanish|mandarin|chinese|french|japanese|korean|german|vietnamese|arabic|hebrew|portuguese|italian|russian) immersion|bilingual (program|education)|\bDLI\b`),
+	programIB:           regexp.MustCompile(`(?i:international baccalaureate)|\bIB\b`),
+	programMontessori:   regexp.MustCompile(`(?i)montessori`),
+}
+
+// ProgramFlag is a program a school offers, with where that came from
+type ProgramFlag struct {
+	Flag     string
+	Source   string // programSourceCCD or programSourceWebsite
+	Evidence string // The CCD field or website text that showed it
+}
+
+// Label is the program in words, e.g. "Gifted program"
+func (f ProgramFlag) Label() string {
+	return programFlagLabels[f.Flag]
+}
+
+// SourceLabel describes the source, e.g. "CCD" or "school website"
+func (f ProgramFlag) SourceLabel() string {
+	if f.Source == programSourceCCD {
+		return "CCD"
+	}
+	return "school website"
+}
+
+// programNeedAliases maps common ways families write a need to a program flag
+var programNeedAliases = map[string]string{
+	"iep":                         programSpecialEd,
+	"504":                         programSpecialEd,
+	"special ed":                  programSpecialEd,
+	"special education":           programSpecialEd,
+	"sped":                        programSpecialEd,
+	"gate":                        programGifted,
+	"gt":                          programGifted,
+	"tag":                         programGifted,
+	"talented":                    programGifted,
+	"immersion":                   programDualLanguage,
+	"dual language":               programDualLanguage,
+	"bilingual":                   programDualLanguage,
+	"international baccalaureate": programIB,
+}
+
+// ParseProgramFlag accepts a flag by key or label, or a common way of writing
+// the need, e.g. "IEP", "GATE", or "dual-language"
+func ParseProgramFlag(s string) (string, error) {
+	key := strings.ToLower(strings.TrimSpace(s))
+	spaced := strings.NewReplacer("_", " ", "-", " ").Replace(key)
+	for _, flag := range programFlags {
+		if key == flag || spaced == strings.ReplaceAll(flag, "_", " ") || spaced == strings.NewReplacer("-", " ").Replace(strings.ToLower(programFlagLabels[flag])) {
+			return flag, nil
+		}
+	}
+	if flag, ok := programNeedAliases[spaced]; ok {
+		return flag, nil
+	}
+	return "", fmt.Errorf("unknown program %q (use one of: %s)", s, strings.Join(programFlags, ", "))
+}
+
+// programNeeds returns the program flags the children's needs call for, in
+// display order. Needs that aren't programs, like "bus route", are skipped.
+func programNeeds(children []Child) []string {
+	var flags []string
+	for _, c := range children {
+		for _, need := range c.Needs {
+			if flag, err := ParseProgramFlag(need); err == nil && !slices.Contains(flags, flag) {
+				flags = append(flags, flag)
+			}
+		}
+	}
+	slices.SortFunc(flags, func(a, b string) int {
+		return slices.Index(programFlags, a) - slices.Index(programFlags, b)
+	})
+	return flags
+}
+
+// detectProgramFlags finds programs mentioned in website text, with the
+// sentence that mentioned each
+func detectProgramFlags(texts ...string) []ProgramFlag {
+	var found []ProgramFlag
+	for _, flag := range programFlags {
+		for _, text := range texts {
+			loc := programFlagPatterns[flag].FindStringIndex(text)
+			if loc == nil {
+				continue
+			}
+			found = append(found, ProgramFlag{Flag: flag, Source: programSourceWebsite, Evidence: programEvidence(text, loc[0], loc[1])})
+			break
+		}
+	}
+	return found
+}
+
+// programEvidence returns the line around a match, trimmed of markdown and cut
+// to a readable length
+func programEvidence(text string, start, end int) string {
+	lineStart := strings.LastIndex(text[:start], "\n") + 1
+	lineEnd := len(text)
+	if i := strings.Index(text[end:], "\n"); i >= 0 {
+		lineEnd = end + i
+	}
+	line := strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(text[lineStart:lineEnd]), "#*->| "))
+	return truncateString(line, 160)
+}
+
+// SaveWebsiteProgramFlags replaces a school's website program flags with those
+// found in its extracted website data
+func (d *DB) SaveWebsiteProgramFlags(data *EnhancedSchoolData) error {
+	flags := detectProgramFlags(
+		data.MarkdownContent,
+		strings.Join(data.SpecialPrograms, "\n"),
+		strings.Join(data.Languages, "\n"),
+		strings.Join(data.Accreditations, "\n"),
+	)
+
+	tx, err := d.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.Exec(`DELETE FROM school_program_flags WHERE ncessch = $1 AND source = $2`, data.NCESSCH, programSourceWebsite); err != nil {
+		return fmt.Errorf("failed to clear program flags: %w", err)
+	}
+	for _, f := range flags {
+		if _, err := tx.Exec(`INSERT INTO school_program_flags (ncessch, flag, source, evidence) VALUES ($1, $2, $3, $4)`,
+			data.NCESSCH, f.Flag, f.Source, f.Evidence); err != nil {
+			return fmt.Errorf("failed to save program flag: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to save program flags: %w", err)
+	}
+	return nil
+}
+
+// ProgramFlags loads a school's program flags in display order, CCD before website
+func (d *DB) ProgramFlags(ncessch string) ([]ProgramFlag, error) {
+	rows, err := d.conn.Query(`SELECT flag, source, COALESCE(evidence, '') FROM school_program_flags WHERE ncessch = $1`, ncessch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load program flags: %w", err)
+	}
+	defer rows.Close()
+
+	var flags []ProgramFlag
+	for rows.Next() {
+		var f ProgramFlag
+		if err := rows.Scan(&f.Flag, &f.Source, &f.Evidence); err != nil {
+			return nil, fmt.Errorf("failed to scan program flag: %w", err)
+		}
+		flags = append(flags, f)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(flags, func(a, b ProgramFlag) int {
+		if a.Flag != b.Flag {
+			return slices.Index(programFlags, a.Flag) - slices.Index(programFlags, b.Flag)
+		}
+		return strings.Compare(a.Source, b.Source)
+	})
+	return flags, nil
+}
+
+// SyncProgramFlags recomputes every school's program flags: CCD flags from the
+// directory's school type and name, and website flags from cached website data.
+// It returns the number of flags recorded.
+func SyncProgramFlags(d *DB) (int, error) {
+	tx, err := d.conn.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.Exec(`DELETE FROM school_program_flags WHERE source = $1`, programSourceCCD); err != nil {
+		return 0, fmt.Errorf("failed to clear program flags: %w", err)
+	}
+	// CCD has no program fields, but special education schools have their own
+	// school type, and Montessori, immersion, and IB schools usually say so in
+	// their names
+	_, err = tx.Exec(`
+		INSERT INTO school_program_flags (ncessch, flag, source, evidence)
+		SELECT NCESSCH, $1, $6, 'CCD school type: ' || SCH_TYPE_TEXT FROM directory
+		WHERE LOWER(SCH_TYPE_TEXT) = 'special education school'
+		UNION ALL
+		SELECT NCESSCH, $2, $6, 'School name: ' || SCH_NAME FROM directory
+		WHERE SCH_NAME ILIKE '%montessori%'
+		UNION ALL
+		SELECT NCESSCH, $3, $6, 'School name: ' || SCH_NAME FROM directory
+		WHERE SCH_NAME ILIKE '%immersion%' OR SCH_NAME ILIKE '%dual language%' OR SCH_NAME ILIKE '%dual-language%'
+		UNION ALL
+		SELECT NCESSCH, $4, $6, 'School name: ' || SCH_NAME FROM directory
+		WHERE SCH_NAME ILIKE '%international baccalaureate%' OR regexp_matches(SCH_NAME, '\bIB\b')
+		UNION ALL
+		SELECT NCESSCH, $5, $6, 'School name: ' || SCH_NAME FROM directory
+		WHERE SCH_NAME ILIKE '%gifted%'
+	`, programSpecialEd, programMontessori, programDualLanguage, programIB, programGifted, programSourceCCD)
+	if err != nil {
+		return 0, fmt.Errorf("failed to compute CCD program flags: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to save program flags: %w", err)
+	}
+
+	// Website flags are kept up to date as data is extracted; this backfills
+	// them for data cached before flags were tracked
+	rows, err := d.conn.Query(`
+		SELECT c.ncessch FROM ai_scraper_cache c
+		WHERE NOT EXISTS (SELECT 1 FROM school_program_flags f WHERE f.ncessch = c.ncessch AND f.source = $1)
+	`, programSourceWebsite)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list cached website data: %w", err)
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan cached website data: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	scraper := &AIScraperService{db: d}
+	for _, id := range ids {
+		data, err := scraper.loadCachedData(id, cacheNoExpiry)
+		if err != nil {
+			continue
+		}
+		if err := d.SaveWebsiteProgramFlags(data); err != nil {
+			return 0, err
+		}
+	}
+
+	var count int
+	if err := d.conn.QueryRow(`SELECT count(*) FROM school_program_flags`).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count program flags: %w", err)
+	}
+	return count, nil
+}
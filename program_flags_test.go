@@ -0,0 +1,130 @@
+package main
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDetectProgramFlags(t *testing.T) {
+	text := "# Welcome\n\n## Programs\n- Spanish immersion from kindergarten\n- Our resource specialist supports students with IEPs\n\nWe use a MAP test, not a gifted screener."
+	flags := detectProgramFlags(text)
+
+	var got []string
+	for _, f := range flags {
+		got = append(got, f.Flag+": "+f.Evidence)
+	}
+	want := []string{
+		"special_ed: Our resource specialist supports students with IEPs",
+		"gifted: We use a MAP test, not a gifted screener.",
+		"dual_language: Spanish immersion from kindergarten",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("flags = %v, want %v", got, want)
+	}
+
+	// "IB" only counts as a whole word, in capitals
+	if flags := detectProgramFlags("Our library has a vibrant collection"); len(flags) != 0 {
+		t.Errorf("flags = %v, want none", flags)
+	}
+	if flags := detectProgramFlags("IB Diploma Programme"); len(flags) != 1 || flags[0].Flag != programIB {
+		t.Errorf("flags = %v, want ib", flags)
+	}
+}
+
+func TestParseProgramFlag(t *testing.T) {
+	tests := map[string]string{
+		"special_ed":                  programSpecialEd,
+		"IEP":                         programSpecialEd,
+		"Gifted program":              programGifted,
+		"GATE":                        programGifted,
+		"dual-language":               programDualLanguage,
+		"Dual-language immersion":     programDualLanguage,
+		"International Baccalaureate": programIB,
+		" montessori ":                programMontessori,
+	}
+	for input, want := range tests {
+		if got, err := ParseProgramFlag(input); err != nil || got != want {
+			t.Errorf("ParseProgramFlag(%q) = %q, %v; want %q", input, got, err, want)
+		}
+	}
+	if _, err := ParseProgramFlag("bus route"); err == nil {
+		t.Error("unknown program accepted")
+	}
+
+	children := []Child{{Name: "Maya", Needs: []string{"bus route", "Immersion"}}, {Name: "Leo", Needs: []string{"IEP", "immersion"}}}
+	if got := programNeeds(children); strings.Join(got, ",") != "special_ed,dual_language" {
+		t.Errorf("programNeeds = %v", got)
+	}
+}
+
+func TestProgramFlagSearch(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	// A website mentioning immersion and IEPs, and a Montessori school by name
+	if err := saveEnhancedData(db, &EnhancedSchoolData{
+		NCESSCH:         "360000100001",
+		SchoolName:      "Lincoln Elementary School",
+		SourceURL:       "https://lincoln.example.org",
+		MarkdownContent: "Lincoln offers Spanish immersion.\nWe write an IEP for every student who needs one.",
+		ExtractedAt:     time.Now(),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.conn.Exec(`UPDATE directory SET SCH_NAME = 'Madison Montessori K-8 School' WHERE NCESSCH = '360000100005'`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SyncProgramFlags(db); err != nil {
+		t.Fatal(err)
+	}
+
+	flags, err := db.ProgramFlags("360000100005")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(flags) != 1 || flags[0].Flag != programMontessori || flags[0].SourceLabel() != "CCD" {
+		t.Errorf("flags = %+v", flags)
+	}
+
+	search := func(programs string) []string {
+		t.Helper()
+		schools, err := db.SearchSchoolsFiltered(SearchFilters{Programs: programs}, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, s := range schools {
+			ids = append(ids, s.NCESSCH)
+		}
+		return ids
+	}
+	if got := search("special_ed,dual_language"); strings.Join(got, ",") != "360000100001" {
+		t.Errorf("special_ed and dual_language = %v", got)
+	}
+	if got := search("special_ed,montessori"); len(got) != 0 {
+		t.Errorf("special_ed and montessori = %v, want none", got)
+	}
+
+	// The filter round-trips through the form and shows on the detail page
+	filters, err := SearchFiltersFromValues(url.Values{"programs": {"special_ed", "dual_language"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filters.Summary() != "with Special education services and Dual-language immersion" || filters.DrawerFilterCount() != 1 {
+		t.Errorf("summary = %q, drawer count %d", filters.Summary(), filters.DrawerFilterCount())
+	}
+	if _, err := SearchFiltersFromValues(url.Values{"programs": {"bus route"}}); err == nil {
+		t.Error("unknown program accepted")
+	}
+
+	router := NewRouter(ServerConfig{DB: db})
+	req := httptest.NewRequest("GET", "/schools/360000100001", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if body := rec.Body.String(); !strings.Contains(body, "Dual-language immersion") || !strings.Contains(body, "Lincoln offers Spanish immersion.") {
+		t.Error("detail page is missing the school's programs")
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Kinds of source a displayed value can come from
+const (
+	sourceCCD  = "CCD"
+	sourceNAEP = "NAEP"
+	sourceAI   = "AI"
+	sourceUser = "User"
+)
+
+// ccdFile is a CCD release file loaded into the database
+type ccdFile struct {
+	Name     string
+	Label    string
+	Released time.Time // NCES release date, from the file name
+}
+
+// The CCD files behind the directory, enrollment, and teachers tables
+var (
+	ccdDirectoryFile  = ccdFile{"ccd_sch_029_2324_w_1a_073124.csv", "CCD 2023-24 school directory", time.Date(2024, 7, 31, 0, 0, 0, 0, time.UTC)}
+	ccdMembershipFile = ccdFile{"ccd_sch_052_2324_l_1a_073124.csv", "CCD 2023-24 membership", time.Date(2024, 7, 31, 0, 0, 0, 0, time.UTC)}
+	ccdStaffFile      = ccdFile{"ccd_sch_059_2324_l_1a_073124.csv", "CCD 2023-24 staff", time.Date(2024, 7, 31, 0, 0, 0, 0, time.UTC)}
+)
+
+// provenanceFields registers the CCD file each school detail field is read
+// from, and the correction that overrides it, if it can be corrected
+var provenanceFields = []struct {
+	Label      string
+	File       ccdFile
+	Correction string
+}{
+	{"Name", ccdDirectoryFile, ""},
+	{"School type", ccdDirectoryFile, ""},
+	{"Level", ccdDirectoryFile, ""},
+	{"Grade range", ccdDirectoryFile, ""},
+	{"Charter school", ccdDirectoryFile, ""},
+	{"Street address", ccdDirectoryFile, correctionStreet},
+	{"City", ccdDirectoryFile, correctionCity},
+	{"ZIP code", ccdDirectoryFile, correctionZip},
+	{"District", ccdDirectoryFile, ""},
+	{"Phone", ccdDirectoryFile, correctionPhone},
+	{"Website", ccdDirectoryFile, correctionWebsite},
+	{"Enrollment", ccdMembershipFile, ""},
+	{"Teachers (FTE)", ccdStaffFile, ""},
+}
+
+// FieldSource says where a displayed value came from and when it was last
+// updated
+type FieldSource struct {
+	Field   string    `json:"field"`
+	Kind    string    `json:"kind"` // One of the source* kinds
+	Source  string    `json:"source"`
+	Updated time.Time `json:"updated"`
+	Detail  string    `json:"detail,omitempty"` // e.g. the CCD value a correction replaced
+}
+
+// KindClass is the CSS class for the source's kind
+func (s FieldSource) KindClass() string {
+	return "provenance-" + strings.ToLower(s.Kind)
+}
+
+// SchoolProvenance lists where each of a school's displayed values came from:
+// the CCD file, a user correction, the NAEP API, or AI extraction from the
+// school's website and the user's edits of it
+func (d *DB) SchoolProvenance(school *School) ([]FieldSource, error) {
+	list, err := d.ListSchoolCorrections(school.NCESSCH)
+	if err != nil {
+		return nil, err
+	}
+	corrections := make(map[string]SchoolCorrection, len(list))
+	for _, c := range list {
+		corrections[c.Field] = c
+	}
+
+	var sources []FieldSource
+	for _, f := range provenanceFields {
+		if c, ok := corrections[f.Correction]; ok && f.Correction != "" {
+			detail := "CCD value: " + naLabel(school.CCDValue(f.Correction))
+			if c.Note != "" {
+				detail += "; " + c.Note
+			}
+			sources = append(sources, FieldSource{Field: f.Label, Kind: sourceUser, Source: "Corrected by hand", Updated: c.UpdatedAt, Detail: detail})
+			continue
+		}
+		sources = append(sources, FieldSource{Field: f.Label, Kind: sourceCCD, Source: fmt.Sprintf("%s (%s)", f.File.Label, f.File.Name), Updated: f.File.Released})
+	}
+
+	var sourceURL string
+	var extractedAt time.Time
+	err = d.conn.QueryRow(`SELECT COALESCE(source_url, ''), extracted_at FROM ai_scraper_cache WHERE ncessch = $1`, school.NCESSCH).Scan(&sourceURL, &extractedAt)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to load website data provenance: %w", err)
+	}
+	if err == nil {
+		sources = append(sources, FieldSource{Field: "Website data", Kind: sourceAI, Source: "Extracted with AI from " + sourceURL, Updated: extractedAt})
+	}
+
+	// The latest hand edit of each website data field
+	edits, err := d.ListAIDataEdits(school.NCESSCH, 100)
+	if err != nil {
+		return nil, err
+	}
+	edited := make(map[string]bool)
+	for _, e := range edits {
+		if edited[e.Field] {
+			continue
+		}
+		edited[e.Field] = true
+		sources = append(sources, FieldSource{Field: e.Label(), Kind: sourceUser, Source: "Website data edited by hand", Updated: e.EditedAt})
+	}
+
+	var state, district string
+	err = d.conn.QueryRow(`SELECT COALESCE(state, ''), COALESCE(district, ''), extracted_at FROM naep_cache WHERE ncessch = $1`, school.NCESSCH).Scan(&state, &district, &extractedAt)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to load NAEP provenance: %w", err)
+	}
+	if err == nil {
+		detail := "State " + state
+		if district != "" {
+			detail += ", district " + district
+		}
+		sources = append(sources, FieldSource{Field: "NAEP scores", Kind: sourceNAEP, Source: "Nation's Report Card API", Updated: extractedAt, Detail: detail})
+	}
+
+	err = d.conn.QueryRow(`SELECT generated_at FROM parent_summary_cache WHERE ncessch = $1`, school.NCESSCH).Scan(&extractedAt)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to load parent summary provenance: %w", err)
+	}
+	if err == nil {
+		sources = append(sources, FieldSource{Field: "Summary for parents", Kind: sourceAI, Source: "Written with AI from the school's data", Updated: extractedAt})
+	}
+
+	return sources, nil
+}
+
+// renderProvenance renders where a school's values came from for the TUI
+// detail view
+func renderProvenance(sources []FieldSource) string {
+	var b strings.Builder
+	titleStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("33"))
+	mutedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("241"))
+	kindStyles := map[string]lipgloss.Style{
+		sourceCCD:  lipgloss.NewStyle().Foreground(lipgloss.Color("245")),
+		sourceNAEP: lipgloss.NewStyle().Foreground(lipgloss.Color("33")),
+		sourceAI:   lipgloss.NewStyle().Foreground(lipgloss.Color("170")),
+		sourceUser: lipgloss.NewStyle().Foreground(lipgloss.Color("214")),
+	}
+
+	b.WriteString(titleStyle.Render("🔎 Where this data comes from"))
+	b.WriteString("\n\n")
+	for _, s := range sources {
+		b.WriteString(fmt.Sprintf("%-22s %s %s", s.Field, kindStyles[s.Kind].Render(fmt.Sprintf("%-5s", s.Kind)), s.Source))
+		b.WriteString(mutedStyle.Render(" · " + s.Updated.Format("Jan 2, 2006")))
+		b.WriteString("\n")
+		if s.Detail != "" {
+			b.WriteString(mutedStyle.Render(strings.Repeat(" ", 29) + s.Detail))
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
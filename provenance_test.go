@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSchoolProvenance(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	if err := db.SaveSchoolCorrection(SchoolCorrection{NCESSCH: "360000100001", Field: correctionPhone, Value: "(555) 010-2000", Note: "Called the office"}); err != nil {
+		t.Fatal(err)
+	}
+	extracted := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	if err := db.SaveAIScraperCache("360000100001", "Lincoln Elementary School", "https://lincoln.example.org", "# Lincoln", nil, extracted); err != nil {
+		t.Fatal(err)
+	}
+	school, err := db.GetSchoolByID("360000100001")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sources, err := db.SchoolProvenance(school)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byField := make(map[string]FieldSource)
+	for _, s := range sources {
+		byField[s.Field] = s
+	}
+	if s := byField["Phone"]; s.Kind != sourceUser || !strings.Contains(s.Detail, "Called the office") || s.Updated.IsZero() {
+		t.Errorf("corrected phone source = %+v", s)
+	}
+	if s := byField["Enrollment"]; s.Kind != sourceCCD || !strings.Contains(s.Source, ccdMembershipFile.Name) || !s.Updated.Equal(ccdMembershipFile.Released) {
+		t.Errorf("enrollment source = %+v", s)
+	}
+	if s := byField["Website data"]; s.Kind != sourceAI || !strings.Contains(s.Source, "lincoln.example.org") || !s.Updated.Equal(extracted) {
+		t.Errorf("website data source = %+v", s)
+	}
+	if _, ok := byField["NAEP scores"]; ok {
+		t.Error("NAEP source listed before NAEP data was fetched")
+	}
+
+	if text := renderProvenance(sources); !strings.Contains(text, "Corrected by hand") || !strings.Contains(text, "Mar 1, 2026") {
+		t.Errorf("TUI sources = %q", text)
+	}
+
+	rec := httptest.NewRecorder()
+	NewRouter(ServerConfig{DB: db}).ServeHTTP(rec, httptest.NewRequest("GET", "/schools/360000100001", nil))
+	if body := rec.Body.String(); !strings.Contains(body, "Where this data comes from") || !strings.Contains(body, ccdDirectoryFile.Name) {
+		t.Error("detail page is missing its data sources")
+	}
+}
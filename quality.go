@@ -0,0 +1,195 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Data quality flags, stored in data_quality_flags.flag
+const (
+	qualityZeroEnrollment = "zero_enrollment" // No students reported, but a full staff
+	qualityExtremeRatio   = "extreme_ratio"   // More students per teacher than any real school has
+	qualityMissingWebsite = "missing_website" // A large school without a website
+	qualityGradeRange     = "grade_range"     // Lowest grade above the highest, e.g. 12-01
+)
+
+// Fields a quality flag is about, stored in data_quality_flags.field. Flags on
+// enrollment and ratio leave the values out of result statistics.
+const (
+	qualityFieldEnrollment = "enrollment"
+	qualityFieldRatio      = "ratio"
+	qualityFieldWebsite    = "website"
+	qualityFieldGrades     = "grades"
+)
+
+// Anomaly thresholds. A school reporting no students with this many teachers
+// almost certainly failed to report enrollment, and no school has more
+// students per teacher than maxPlausibleRatio.
+const (
+	zeroEnrollmentMinTeachers = 10
+	maxPlausibleRatio         = 100
+	missingWebsiteMinStudents = 1000
+)
+
+// qualityLabels describe each flag for warning tooltips
+var qualityLabels = map[string]string{
+	qualityZeroEnrollment: "Enrollment of 0 despite a full teaching staff",
+	qualityExtremeRatio:   fmt.Sprintf("Over %d students per teacher", maxPlausibleRatio),
+	qualityMissingWebsite: fmt.Sprintf("No website listed for a school of %d+ students", missingWebsiteMinStudents),
+	qualityGradeRange:     "Lowest grade is above the highest grade",
+}
+
+// QualityFlag is an anomaly the data-quality pass found in a school's CCD record
+type QualityFlag struct {
+	Flag   string
+	Field  string
+	Detail string // The values involved, e.g. "0 students, 40 teachers"
+}
+
+// Label describes the flag, e.g. "Over 100 students per teacher (250 students, 2 teachers)"
+func (f QualityFlag) Label() string {
+	label := qualityLabels[f.Flag]
+	if label == "" {
+		label = f.Flag
+	}
+	if f.Detail != "" {
+		label += " (" + f.Detail + ")"
+	}
+	return label
+}
+
+// SchoolQuality is a school's quality flags
+type SchoolQuality []QualityFlag
+
+// Warning describes the flags on a field, or is empty when it has none
+func (q SchoolQuality) Warning(field string) string {
+	var labels []string
+	for _, f := range q {
+		if f.Field == field {
+			labels = append(labels, f.Label())
+		}
+	}
+	return strings.Join(labels, "; ")
+}
+
+// Summary describes all of the school's flags
+func (q SchoolQuality) Summary() string {
+	labels := make([]string, len(q))
+	for i, f := range q {
+		labels[i] = f.Label()
+	}
+	return strings.Join(labels, "; ")
+}
+
+// qualityExcludedSQL is true for a school whose flagged field is left out of
+// statistics, for a query over directory d
+func qualityExcludedSQL(field string) string {
+	return fmt.Sprintf("d.NCESSCH IN (SELECT ncessch FROM data_quality_flags WHERE field = '%s')", field)
+}
+
+// RefreshQualityFlags runs the cross-field checks over every school's CCD
+// record (enrollment, teachers, website, and grade range) and replaces the
+// stored flags. It returns the number of flags found.
+func (d *DB) RefreshQualityFlags() (int, error) {
+	tx, err := d.conn.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.Exec(`DELETE FROM data_quality_flags`); err != nil {
+		return 0, fmt.Errorf("failed to clear quality flags: %w", err)
+	}
+	result, err := tx.Exec(`
+		INSERT INTO data_quality_flags (ncessch, flag, field, detail)
+		WITH schools AS (
+			SELECT d.NCESSCH AS ncessch,
+				TRY_CAST(e.STUDENT_COUNT AS DOUBLE) AS students,
+				TRY_CAST(t.TEACHERS AS DOUBLE) AS teachers,
+				COALESCE(TRIM(d.WEBSITE), '') AS website,
+				d.GSLO AS gslo, d.GSHI AS gshi,
+				`+gradeRankSQL("d.GSLO")+` AS low_rank,
+				`+gradeRankSQL("d.GSHI")+` AS high_rank
+			FROM directory d
+			LEFT JOIN teachers t ON d.NCESSCH = t.NCESSCH
+			LEFT JOIN enrollment e ON d.NCESSCH = e.NCESSCH AND e.TOTAL_INDICATOR = 'Education Unit Total'
+		)
+		SELECT ncessch, $1, $2, printf('0 students, %g teachers', teachers)
+		FROM schools WHERE students = 0 AND teachers >= $3
+		UNION ALL
+		SELECT ncessch, $4, $5, printf('%.0f students, %g teachers', students, teachers)
+		FROM schools WHERE teachers > 0 AND students / teachers > $6
+		UNION ALL
+		SELECT ncessch, $7, $8, printf('%.0f students', students)
+		FROM schools WHERE website = '' AND students >= $9
+		UNION ALL
+		SELECT ncessch, $10, $11, gslo || '–' || gshi
+		FROM schools WHERE low_rank > high_rank
+	`, qualityZeroEnrollment, qualityFieldEnrollment, zeroEnrollmentMinTeachers,
+		qualityExtremeRatio, qualityFieldRatio, maxPlausibleRatio,
+		qualityMissingWebsite, qualityFieldWebsite, missingWebsiteMinStudents,
+		qualityGradeRange, qualityFieldGrades)
+	if err != nil {
+		return 0, fmt.Errorf("failed to check data quality: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to save quality flags: %w", err)
+	}
+	n, _ := result.RowsAffected()
+	return int(n), nil
+}
+
+// SyncQualityFlags checks data quality after ingestion. The checks are one
+// pass over the directory, so they run on every start and cover newly loaded
+// CCD releases.
+func SyncQualityFlags(d *DB) (int, error) {
+	return d.RefreshQualityFlags()
+}
+
+// SchoolQualityFlags loads the quality flags of the given schools keyed by
+// NCESSCH. Schools without flags are left out.
+func (d *DB) SchoolQualityFlags(ncesschList []string) (map[string]SchoolQuality, error) {
+	quality := make(map[string]SchoolQuality)
+	if len(ncesschList) == 0 {
+		return quality, nil
+	}
+	rows, err := d.conn.Query(`
+		SELECT ncessch, flag, field, COALESCE(detail, '')
+		FROM data_quality_flags WHERE ncessch = ANY($1)
+		ORDER BY ncessch, field, flag
+	`, ncesschList)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load quality flags: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var ncessch string
+		var f QualityFlag
+		if err := rows.Scan(&ncessch, &f.Flag, &f.Field, &f.Detail); err != nil {
+			return nil, fmt.Errorf("failed to scan quality flag: %w", err)
+		}
+		quality[ncessch] = append(quality[ncessch], f)
+	}
+	return quality, rows.Err()
+}
+
+// QualityFlagCounts counts the flagged schools by flag, for the doctor report
+func (d *DB) QualityFlagCounts() (map[string]int, error) {
+	rows, err := d.conn.Query(`SELECT flag, count(DISTINCT ncessch) FROM data_quality_flags GROUP BY flag`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count quality flags: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var flag string
+		var n int
+		if err := rows.Scan(&flag, &n); err != nil {
+			return nil, fmt.Errorf("failed to scan quality flag count: %w", err)
+		}
+		counts[flag] = n
+	}
+	return counts, rows.Err()
+}
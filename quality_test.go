@@ -0,0 +1,103 @@
+package main
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQualityFlags(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	// The fixtures are clean, so the startup pass flags nothing
+	if quality, err := db.SchoolQualityFlags([]string{"360000100001", "360000100002", "360000100003", "360000100004", "360000100005"}); err != nil || len(quality) != 0 {
+		t.Fatalf("startup flags = %+v, %v", quality, err)
+	}
+
+	for _, stmt := range []string{
+		`UPDATE enrollment SET STUDENT_COUNT = 0 WHERE NCESSCH = '360000100003' AND TOTAL_INDICATOR = 'Education Unit Total'`,
+		`UPDATE teachers SET TEACHERS = 5 WHERE NCESSCH = '360000100004'`,
+		`UPDATE enrollment SET STUDENT_COUNT = 1200 WHERE NCESSCH = '360000100002' AND TOTAL_INDICATOR = 'Education Unit Total'`,
+		`UPDATE directory SET WEBSITE = NULL WHERE NCESSCH = '360000100002'`,
+		`UPDATE directory SET GSLO = '12', GSHI = '01' WHERE NCESSCH = '360000100005'`,
+	} {
+		if _, err := db.conn.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	n, err := db.RefreshQualityFlags()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 4 {
+		t.Errorf("RefreshQualityFlags() = %d flags, want 4", n)
+	}
+
+	quality, err := db.SchoolQualityFlags([]string{"360000100001", "360000100002", "360000100003", "360000100004", "360000100005"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for id, want := range map[string]string{
+		"360000100003": "Enrollment of 0 despite a full teaching staff (0 students, 30.2 teachers)",
+		"360000100004": "Over 100 students per teacher (725 students, 5 teachers)",
+		"360000100002": "No website listed for a school of 1000+ students (1200 students)",
+		"360000100005": "Lowest grade is above the highest grade (12–01)",
+	} {
+		if got := quality[id].Summary(); got != want {
+			t.Errorf("%s flags = %q, want %q", id, got, want)
+		}
+	}
+	if _, ok := quality["360000100001"]; ok {
+		t.Errorf("Lincoln Elementary was flagged: %+v", quality["360000100001"])
+	}
+	if w := quality["360000100004"].Warning(qualityFieldEnrollment); w != "" {
+		t.Errorf("ratio flag warned on enrollment: %q", w)
+	}
+
+	// Flagged enrollment and ratios are left out of result charts unless included
+	stats, err := db.SearchResultStats(SearchFilters{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Schools != 5 || stats.Flagged != 2 || stats.Ratio.Schools != 3 {
+		t.Errorf("stats = %d schools, %d flagged, %d ratios; want 5, 2, 3", stats.Schools, stats.Flagged, stats.Ratio.Schools)
+	}
+	if stats.Ratio.Max > maxPlausibleRatio {
+		t.Errorf("flagged ratio %.1f was charted", stats.Ratio.Max)
+	}
+	stats, err = db.SearchResultStats(SearchFilters{}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Ratio.Schools != 4 || stats.Ratio.Max != 145 {
+		t.Errorf("stats with flagged values = %d ratios, max %.1f; want 4, 145", stats.Ratio.Schools, stats.Ratio.Max)
+	}
+
+	router := NewRouter(ServerConfig{DB: db, AIScraper: &AIScraperService{db: db, cacheTTL: time.Hour}})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/schools/360000100005", nil))
+	if body := rec.Body.String(); !strings.Contains(body, "Possible data error: Lowest grade is above the highest grade") {
+		t.Error("detail page doesn't mark the grade range")
+	}
+
+	search := func(form url.Values) string {
+		t.Helper()
+		req := httptest.NewRequest("POST", "/search", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Body.String()
+	}
+	body := search(url.Values{"q": {""}})
+	for _, want := range []string{"⚠ Data check", "Include flagged values", "left out of these charts"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("results are missing %q", want)
+		}
+	}
+	if body := search(url.Values{"q": {""}, "include_flagged": {"1"}}); strings.Contains(body, "left out of these charts") {
+		t.Error("results left flagged values out after including them")
+	}
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// queryTerm is one token of the search box query syntax
+type queryTerm struct {
+	field  string // Empty for free text
+	value  string
+	negate bool
+}
+
+// tokenizeQuery splits a query into free-text words, quoted phrases, and
+// field:value terms, each optionally negated with a leading "-"
+func tokenizeQuery(input string) ([]queryTerm, error) {
+	var terms []queryTerm
+	runes := []rune(input)
+	i := 0
+
+	// readValue reads a quoted or bare value starting at i
+	readValue := func() (string, error) {
+		if i < len(runes) && runes[i] == '"' {
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			if end == len(runes) {
+				return "", fmt.Errorf("unterminated quote")
+			}
+			value := strings.TrimSpace(string(runes[i+1 : end]))
+			i = end + 1
+			if i < len(runes) && !unicode.IsSpace(runes[i]) {
+				return "", fmt.Errorf("missing space after quoted value %q", value)
+			}
+			return value, nil
+		}
+		start := i
+		for i < len(runes) && !unicode.IsSpace(runes[i]) {
+			if runes[i] == '"' {
+				return "", fmt.Errorf("unexpected quote in %q", string(runes[start:]))
+			}
+			i++
+		}
+		return string(runes[start:i]), nil
+	}
+
+	for i < len(runes) {
+		if unicode.IsSpace(runes[i]) {
+			i++
+			continue
+		}
+
+		var term queryTerm
+		if runes[i] == '-' {
+			term.negate = true
+			i++
+			if i == len(runes) || unicode.IsSpace(runes[i]) {
+				return nil, fmt.Errorf("nothing to exclude after \"-\"")
+			}
+		}
+
+		// A field name is a run of letters followed by ":"
+		end := i
+		for end < len(runes) && unicode.IsLetter(runes[end]) {
+			end++
+		}
+		if end > i && end < len(runes) && runes[end] == ':' {
+			term.field = strings.ToLower(string(runes[i:end]))
+			i = end + 1
+		}
+
+		value, err := readValue()
+		if err != nil {
+			return nil, err
+		}
+		if value == "" {
+			if term.field != "" {
+				return nil, fmt.Errorf("missing value for %s:", term.field)
+			}
+			continue // Empty quoted phrase
+		}
+		term.value = value
+		terms = append(terms, term)
+	}
+
+	return terms, nil
+}
+
+// ParseSearchQuery parses search box syntax into filters. Free text and quoted
+// phrases become the text query; field terms set the matching filter:
+//
+//	name:"lincoln" city:portland -district:"charter" state:OR
+//	zip:97214 grades:K-8 charter:no ratio:20 trend:growing sector:early prek:yes cte:aviation,"health sciences"
+//	sport:swimming conference:"west bay" within:15 near:"123 Main St, Portland, OR"
+//	language:mandarin immersion:yes (or immersion:mandarin for both)
+//
+// A leading "-" excludes matches for name, city, and district (a bare -word
+// excludes school names). Each field may appear once.
+func ParseSearchQuery(input string) (SearchFilters, error) {
+	var f SearchFilters
+
+	terms, err := tokenizeQuery(input)
+	if err != nil {
+		return f, err
+	}
+
+	var words []string
+	seen := make(map[string]bool)
+	for _, term := range terms {
+		field := term.field
+		if field == "" && term.negate {
+			field = "name"
+		}
+		if field == "" {
+			words = append(words, term.value)
+			continue
+		}
+
+		key := field
+		if term.negate {
+			key = "-" + field
+		}
+		if seen[key] {
+			return f, fmt.Errorf("%s: can only be used once", key)
+		}
+		seen[key] = true
+
+		if term.negate && field != "name" && field != "city" && field != "district" {
+			return f, fmt.Errorf("%s: can't be excluded", field)
+		}
+
+		switch key {
+		case "name":
+			f.Name = term.value
+		case "-name":
+			f.NotName = term.value
+		case "city":
+			f.City = term.value
+		case "-city":
+			f.NotCity = term.value
+		case "district":
+			f.District = term.value
+		case "-district":
+			f.NotDistrict = term.value
+		case "state":
+			if len(term.value) != 2 {
+				return f, fmt.Errorf("state: expects a two-letter code, got %q", term.value)
+			}
+			f.State = strings.ToUpper(term.value)
+		case "zip":
+			f.Zip = term.value
+		case "grades", "grade":
+			low, high, ok := strings.Cut(term.value, "-")
+			if !ok {
+				high = low
+			}
+			var lowOK, highOK bool
+			f.GradeLow, lowOK = gradeCode(low)
+			f.GradeHigh, highOK = gradeCode(high)
+			if !lowOK || !highOK {
+				return f, fmt.Errorf("grades: expects a grade or range like K-8, got %q", term.value)
+			}
+		case "charter":
+			switch strings.ToLower(term.value) {
+			case "yes", "y", "true":
+				f.Charter = "Yes"
+			case "no", "n", "false":
+				f.Charter = "No"
+			default:
+				return f, fmt.Errorf("charter: expects yes or no, got %q", term.value)
+			}
+		case "cte":
+			f.CTE = term.value
+		case "sport", "sports":
+			f.Sports = term.value
+		case "conference", "league":
+			f.Conference = term.value
+		case "within":
+			miles, err := strconv.ParseFloat(strings.TrimSuffix(strings.ToLower(term.value), "mi"), 64)
+			if err != nil || miles <= 0 {
+				return f, fmt.Errorf("within: expects a distance in miles, got %q", term.value)
+			}
+			f.WithinMiles = miles
+		case "near":
+			f.Near = term.value
+		case "language", "lang", "immersion":
+			// immersion:mandarin is short for language:mandarin immersion:yes
+			value := term.value
+			if field == "immersion" {
+				f.Immersion = "Yes"
+				switch strings.ToLower(value) {
+				case "yes", "y", "true":
+					continue
+				}
+			}
+			if f.Language != "" {
+				return f, fmt.Errorf("%s: names a second language after %q", field, f.Language)
+			}
+			f.Language = value
+		case "sector":
+			switch strings.NewReplacer("-", "", "_", "", " ", "").Replace(strings.ToLower(term.value)) {
+			case "early", "earlychildhood", "ec":
+				f.Sector = sectorEarlyChildhood
+			case "k12":
+				f.Sector = sectorK12
+			default:
+				return f, fmt.Errorf("sector: expects early or k12, got %q", term.value)
+			}
+		case "prek", "pk":
+			switch strings.ToLower(term.value) {
+			case "yes", "y", "true":
+				f.PreK = "Yes"
+			default:
+				return f, fmt.Errorf("%s: expects yes, got %q", field, term.value)
+			}
+		case "ratio":
+			ratio, err := strconv.ParseFloat(strings.TrimPrefix(term.value, "<="), 64)
+			if err != nil || ratio <= 0 {
+				return f, fmt.Errorf("ratio: expects a maximum students per teacher, got %q", term.value)
+			}
+			f.MaxRatio = ratio
+		case "trend":
+			f.Trend = strings.ToLower(term.value)
+			if !validEnrollmentPressure(f.Trend) {
+				return f, fmt.Errorf("trend: expects %s, %s, or %s, got %q", EnrollmentGrowing, EnrollmentStable, EnrollmentShrinking, term.value)
+			}
+		default:
+			return f, fmt.Errorf("unknown field %q", field)
+		}
+	}
+
+	f.Query = strings.Join(words, " ")
+	return f, f.Validate()
+}
+
+// ExpandQuery parses query syntax in f.Query into field filters. Fields set in
+// the query override the same fields set elsewhere (e.g. the state dropdown).
+// On a parse error f is returned unchanged so it can run as a plain text search.
+func (f SearchFilters) ExpandQuery() (SearchFilters, error) {
+	parsed, err := ParseSearchQuery(f.Query)
+	if err != nil {
+		return f, err
+	}
+
+	expanded := f
+	expanded.mergeParsed(parsed)
+	if err := expanded.Validate(); err != nil {
+		return f, err
+	}
+	return expanded, nil
+}
+
+// mergeParsed copies the fields set in parsed, and its text query, into f
+func (f *SearchFilters) mergeParsed(parsed SearchFilters) {
+	merge := func(dst *string, src string) {
+		if src != "" {
+			*dst = src
+		}
+	}
+	f.Query = parsed.Query
+	merge(&f.State, parsed.State)
+	merge(&f.GradeLow, parsed.GradeLow)
+	merge(&f.GradeHigh, parsed.GradeHigh)
+	merge(&f.Charter, parsed.Charter)
+	merge(&f.CTE, parsed.CTE)
+	merge(&f.Sports, parsed.Sports)
+	merge(&f.Conference, parsed.Conference)
+	merge(&f.Language, parsed.Language)
+	merge(&f.Immersion, parsed.Immersion)
+	merge(&f.Sector, parsed.Sector)
+	merge(&f.PreK, parsed.PreK)
+	merge(&f.Trend, parsed.Trend)
+	merge(&f.Name, parsed.Name)
+	merge(&f.City, parsed.City)
+	merge(&f.District, parsed.District)
+	merge(&f.Zip, parsed.Zip)
+	merge(&f.NotName, parsed.NotName)
+	merge(&f.NotCity, parsed.NotCity)
+	merge(&f.NotDistrict, parsed.NotDistrict)
+	if parsed.MaxRatio > 0 {
+		f.MaxRatio = parsed.MaxRatio
+	}
+	if parsed.WithinMiles > 0 {
+		f.WithinMiles = parsed.WithinMiles
+	}
+	if parsed.Near != "" {
+		// A different place needs looking up again
+		f.Near, f.NearLat, f.NearLon = parsed.Near, 0, 0
+	}
+}
+
+// gradeCode converts a grade as typed ("K", "8", "pk") to a CCD code ("KG", "08", "PK")
+func gradeCode(grade string) (string, bool) {
+	rank, ok := gradeRank(grade)
+	switch {
+	case !ok:
+		return "", false
+	case rank == -1:
+		return "PK", true
+	case rank == 0:
+		return "KG", true
+	default:
+		return fmt.Sprintf("%02d", rank), true
+	}
+}
+
+// quoteTerm quotes a field value if it contains spaces, for display in query syntax
+func quoteTerm(value string) string {
+	if strings.ContainsFunc(value, unicode.IsSpace) {
+		return `"` + value + `"`
+	}
+	return value
+}
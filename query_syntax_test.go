@@ -0,0 +1,123 @@
+package main
+
+import (
+	"testing"
+)
+
+// TestParseSearchQuery tests the search box query syntax
+func TestParseSearchQuery(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		expected SearchFilters
+	}{
+		{"Plain text", "lincoln elementary", SearchFilters{Query: "lincoln elementary"}},
+		{"Empty", "  ", SearchFilters{}},
+		{
+			"Fields and exclusion",
+			`name:"lincoln" city:portland -district:"charter school" state:or`,
+			SearchFilters{Name: "lincoln", City: "portland", NotDistrict: "charter school", State: "OR"},
+		},
+		{"Quoted phrase with free text", `"high school" zip:972`, SearchFilters{Query: "high school", Zip: "972"}},
+		{"Bare exclusion", "academy -magnet", SearchFilters{Query: "academy", NotName: "magnet"}},
+		{"Grade range", "grades:K-8", SearchFilters{GradeLow: "KG", GradeHigh: "08"}},
+		{"Single grade", "grade:9", SearchFilters{GradeLow: "09", GradeHigh: "09"}},
+		{"Charter and ratio", "charter:no ratio:<=20", SearchFilters{Charter: "No", MaxRatio: 20}},
+		{"Case-insensitive field", "City:Austin", SearchFilters{City: "Austin"}},
+		{"Enrollment trend", "trend:Growing", SearchFilters{Trend: "growing"}},
+		{"Hyphenated word", "winston-salem", SearchFilters{Query: "winston-salem"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseSearchQuery(tc.input)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got != tc.expected {
+				t.Errorf("Expected %+v, got %+v", tc.expected, got)
+			}
+		})
+	}
+}
+
+// TestParseSearchQueryErrors tests inputs that fall back to plain search
+func TestParseSearchQueryErrors(t *testing.T) {
+	inputs := []string{
+		`name:"lincoln`,
+		"color:blue",
+		"state:oregon",
+		"-state:CA",
+		"name:",
+		"grades:8-K",
+		"charter:maybe",
+		"ratio:lots",
+		"trend:booming",
+		"city:a city:b",
+		"- lincoln",
+		`name:"a"b`,
+	}
+
+	for _, input := range inputs {
+		t.Run(input, func(t *testing.T) {
+			if _, err := ParseSearchQuery(input); err == nil {
+				t.Errorf("Expected error for %q", input)
+			}
+		})
+	}
+}
+
+// TestExpandQuery tests merging parsed syntax with other filters and the plain fallback
+func TestExpandQuery(t *testing.T) {
+	filters := SearchFilters{Query: "state:TX grades:6-8", State: "CA", Charter: "No"}
+	expanded, err := filters.ExpandQuery()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := SearchFilters{State: "TX", GradeLow: "06", GradeHigh: "08", Charter: "No"}
+	if expanded != expected {
+		t.Errorf("Expected %+v, got %+v", expected, expanded)
+	}
+
+	broken := SearchFilters{Query: "color:blue academy", State: "CA"}
+	if got, err := broken.ExpandQuery(); err == nil || got != broken {
+		t.Errorf("Expected unchanged filters and an error, got %+v (err %v)", got, err)
+	}
+
+	// Field terms survive a round trip through the search box
+	parsed, _ := ParseSearchQuery(`lincoln city:"san francisco" -district:charter`)
+	if text := parsed.QueryText(); text != `lincoln city:"san francisco" -district:charter` {
+		t.Errorf("Unexpected query text: %s", text)
+	}
+}
+
+// TestSearchWithQuerySyntax tests field-scoped searches against the test data
+func TestSearchWithQuerySyntax(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	testCases := []struct {
+		query    string
+		expected int
+	}{
+		{"state:CA", 2},
+		{"state:CA -district:los", 1},
+		{"name:roosevelt", 1},
+		{"-name:\"high school\"", 4},
+		{"grades:K-8 state:FL", 1},
+		{"charter:yes state:CA", 0},
+		{`name:"lincoln`, 0}, // Falls back to a plain search for the literal text
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.query, func(t *testing.T) {
+			schools, err := db.SearchSchools(tc.query, "", 100)
+			if err != nil {
+				t.Fatalf("Search failed: %v", err)
+			}
+			if len(schools) != tc.expected {
+				t.Errorf("Expected %d results, got %d", tc.expected, len(schools))
+			}
+		})
+	}
+}
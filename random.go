@@ -0,0 +1,151 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// schoolLevels are the CCD levels a random pick can be narrowed to
+var schoolLevels = []string{"Elementary", "Middle", "High", "Secondary", "Prekindergarten", "Other"}
+
+// RandomSchoolFilter narrows a random pick to a state and CCD level
+type RandomSchoolFilter struct {
+	State string // Two-letter state code; empty for every state
+	Level string // CCD level, e.g. "High"; empty for every level
+}
+
+// SchoolPick is a randomly picked school with what stands out about it, for
+// exploring the data and spot-checking it
+type SchoolPick struct {
+	School     *School
+	Highlights []string      // e.g. "Larger than 78% of CA elementary schools"
+	Quality    SchoolQuality // Data-quality flags on the school's record
+}
+
+// schoolOfTheDaySeed seeds the school of the day, so everyone sees the same
+// school until the date changes
+func schoolOfTheDaySeed(now time.Time) string {
+	return now.Format(time.DateOnly)
+}
+
+// normalizeRandomSchoolFilter upper-cases the state and matches the level to
+// a CCD level regardless of case
+func normalizeRandomSchoolFilter(filter RandomSchoolFilter) (RandomSchoolFilter, error) {
+	filter.State = strings.ToUpper(strings.TrimSpace(filter.State))
+	level := strings.TrimSpace(filter.Level)
+	filter.Level = ""
+	if level == "" {
+		return filter, nil
+	}
+	for _, l := range schoolLevels {
+		if strings.EqualFold(l, level) {
+			filter.Level = l
+			return filter, nil
+		}
+	}
+	return filter, fmt.Errorf("unknown level %q (use %s)", level, strings.Join(schoolLevels, ", "))
+}
+
+// RandomSchoolID picks a school matching filter at random. With a seed the
+// pick is the same for the same seed as long as the data doesn't change;
+// without one it changes every call. It returns ErrNoMatchingSchools when no
+// school matches.
+func (d *DB) RandomSchoolID(filter RandomSchoolFilter, seed string) (string, error) {
+	filter, err := normalizeRandomSchoolFilter(filter)
+	if err != nil {
+		return "", err
+	}
+
+	order := "random()"
+	args := []any{filter.State, filter.Level}
+	if seed != "" {
+		order = "hash(d.NCESSCH || $3)"
+		args = append(args, seed)
+	}
+
+	var ncessch string
+	err = d.conn.QueryRow(`
+		SELECT d.NCESSCH FROM directory d
+		WHERE `+notMergedCondition+`
+			AND ($1 = '' OR d.ST = $1)
+			AND ($2 = '' OR d.LEVEL = $2)
+		ORDER BY `+order+`
+		LIMIT 1
+	`, args...).Scan(&ncessch)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrNoMatchingSchools
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to pick a school: %w", err)
+	}
+	return ncessch, nil
+}
+
+// RandomSchool picks a school matching filter, as RandomSchoolID does, and
+// gathers its highlights
+func (d *DB) RandomSchool(filter RandomSchoolFilter, seed string) (*SchoolPick, error) {
+	ncessch, err := d.RandomSchoolID(filter, seed)
+	if err != nil {
+		return nil, err
+	}
+	return d.SchoolPick(ncessch)
+}
+
+// SchoolPick gathers a school's highlights: where its size and ratio stand
+// among its peers, its enrollment trend, its programs, and any data-quality
+// flags on its record
+func (d *DB) SchoolPick(ncessch string) (*SchoolPick, error) {
+	school, err := d.GetSchoolByID(ncessch)
+	if err != nil {
+		return nil, err
+	}
+	ids := []string{school.NCESSCH}
+	percentiles, err := d.SchoolPercentiles(ids)
+	if err != nil {
+		return nil, err
+	}
+	trends, err := d.EnrollmentTrends(ids)
+	if err != nil {
+		return nil, err
+	}
+	programs, err := d.ProgramFlags(school.NCESSCH)
+	if err != nil {
+		return nil, err
+	}
+	quality, err := d.SchoolQualityFlags(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	pick := &SchoolPick{School: school, Quality: quality[school.NCESSCH]}
+	for _, metric := range []string{percentileEnrollment, percentileRatio} {
+		if annotation := percentiles[school.NCESSCH].Annotation(metric); annotation != "" {
+			pick.Highlights = append(pick.Highlights, upperFirst(annotation))
+		}
+	}
+	if trend, ok := trends[school.NCESSCH]; ok && trend.Pressure != EnrollmentStable {
+		pick.Highlights = append(pick.Highlights, fmt.Sprintf("%s enrollment: %s", trend.Label(), trend.Summary()))
+	}
+	if school.CharterString() == "Yes" {
+		pick.Highlights = append(pick.Highlights, "Charter school")
+	}
+	if len(programs) > 0 {
+		labels := make([]string, len(programs))
+		for i, p := range programs {
+			labels[i] = p.Label()
+		}
+		pick.Highlights = append(pick.Highlights, "Programs: "+strings.Join(labels, ", "))
+	}
+	return pick, nil
+}
+
+// upperFirst capitalizes the first letter of s
+func upperFirst(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	return string(unicode.ToUpper(r)) + s[size:]
+}
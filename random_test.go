@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRandomSchool(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	// Filters narrow the pick, case-insensitively
+	for range 5 {
+		id, err := db.RandomSchoolID(RandomSchoolFilter{State: "ca", Level: "high"}, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id != "360000100002" {
+			t.Fatalf("expected the only CA high school, got %s", id)
+		}
+	}
+
+	// A seed picks the same school every time
+	first, err := db.RandomSchoolID(RandomSchoolFilter{}, "2024-09-03")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 5 {
+		if id, _ := db.RandomSchoolID(RandomSchoolFilter{}, "2024-09-03"); id != first {
+			t.Fatalf("seeded pick changed from %s to %s", first, id)
+		}
+	}
+
+	if _, err := db.RandomSchoolID(RandomSchoolFilter{State: "MT"}, ""); !errors.Is(err, ErrNoMatchingSchools) {
+		t.Errorf("expected ErrNoMatchingSchools, got %v", err)
+	}
+	if _, err := db.RandomSchoolID(RandomSchoolFilter{Level: "Kindergarten"}, ""); err == nil {
+		t.Error("expected an error for an unknown level")
+	}
+
+	pick, err := db.RandomSchool(RandomSchoolFilter{State: "NY"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pick.School.Name != "Roosevelt Charter School" || !slices.Contains(pick.Highlights, "Charter school") {
+		t.Errorf("unexpected pick %s with highlights %v", pick.School.Name, pick.Highlights)
+	}
+}
+
+func TestRandomSchoolWidget(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	router := NewRouter(ServerConfig{DB: db})
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if body := rec.Body.String(); !strings.Contains(body, `aria-label="School of the day"`) || !strings.Contains(body, `hx-get="/random"`) {
+		t.Error("search page has no school of the day")
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/random?state=tx&level=Middle", nil))
+	body := rec.Body.String()
+	if !strings.Contains(body, "Jefferson Middle School") || !strings.Contains(body, `<option value="Middle" selected>`) {
+		t.Errorf("random school widget didn't pick the TX middle school:\n%s", body)
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/random?state=MT", nil))
+	if !strings.Contains(rec.Body.String(), "No schools match those filters.") {
+		t.Error("random school widget doesn't say when nothing matches")
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/random?level=Kindergarten", nil))
+	if rec.Code != 400 {
+		t.Errorf("expected 400 for an unknown level, got %d", rec.Code)
+	}
+}
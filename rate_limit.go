@@ -0,0 +1,263 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultRateLimit is how many AI and import requests one address may make,
+// overridable with RATE_LIMIT
+const defaultRateLimit = "30/h"
+
+// rateLimitSweepInterval is how often buckets that have refilled are forgotten
+const rateLimitSweepInterval = time.Minute
+
+// tokenBucket holds the requests an address has left, as of updated
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// rateLimiter is a per-client token bucket for routes that spend the owner's
+// Anthropic budget or server resources. Each client starts with burst requests
+// and regains them at perSecond; allowlisted addresses, and loopback behind a
+// trusted proxy, aren't limited. IPv6 clients are limited per /64, since one host can use many
+// addresses in it. A nil *rateLimiter allows everything.
+type rateLimiter struct {
+	perSecond  float64
+	burst      float64
+	allowlist  []netip.Prefix
+	trustProxy bool   // Take the client address from X-Forwarded-For, as set by a reverse proxy
+	spec       string // The limit as configured, e.g. "30/h"
+	now        func() time.Time
+
+	mu      sync.Mutex
+	buckets map[netip.Addr]*tokenBucket
+	swept   time.Time
+}
+
+// newRateLimiter allows count requests per period with bursts of up to burst
+func newRateLimiter(count int, period time.Duration, burst int, allowlist []netip.Prefix) *rateLimiter {
+	return &rateLimiter{
+		perSecond: float64(count) / period.Seconds(),
+		burst:     float64(burst),
+		allowlist: allowlist,
+		now:       time.Now,
+		buckets:   make(map[netip.Addr]*tokenBucket),
+	}
+}
+
+// parseRateLimit parses a limit such as "30/h", "100/d", or "5/10m"
+func parseRateLimit(raw string) (int, time.Duration, error) {
+	countText, periodText, ok := strings.Cut(strings.TrimSpace(raw), "/")
+	count, err := strconv.Atoi(strings.TrimSpace(countText))
+	if !ok || err != nil || count <= 0 {
+		return 0, 0, fmt.Errorf("invalid rate limit %q", raw)
+	}
+	periodText = strings.TrimSpace(periodText)
+	if periodText != "" && strings.IndexAny(periodText[:1], "0123456789.") < 0 {
+		periodText = "1" + periodText
+	}
+	period, err := parseCacheTTL(periodText)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid rate limit %q", raw)
+	}
+	return count, period, nil
+}
+
+// parseAllowlist parses comma-separated addresses and CIDR ranges
+func parseAllowlist(raw string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if strings.Contains(entry, "/") {
+			prefix, err := netip.ParsePrefix(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid allowlist entry %q", entry)
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid allowlist entry %q", entry)
+		}
+		addr = addr.Unmap()
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
+
+// newRateLimiterFromEnv configures rate limiting from RATE_LIMIT (e.g. "30/h",
+// or "off"), RATE_LIMIT_BURST, RATE_LIMIT_ALLOWLIST, and RATE_LIMIT_TRUST_PROXY.
+// It returns nil when rate limiting is off.
+func newRateLimiterFromEnv() *rateLimiter {
+	raw := strings.TrimSpace(os.Getenv("RATE_LIMIT"))
+	switch strings.ToLower(raw) {
+	case "off", "0", "false", "no":
+		return nil
+	case "":
+		raw = defaultRateLimit
+	}
+	count, period, err := parseRateLimit(raw)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v; using %s\n", err, defaultRateLimit)
+		raw = defaultRateLimit
+		count, period, _ = parseRateLimit(raw)
+	}
+
+	burst := count
+	if raw := strings.TrimSpace(os.Getenv("RATE_LIMIT_BURST")); raw != "" {
+		if n, err := strconv.Atoi(raw); err == nil && n > 0 {
+			burst = n
+		} else {
+			fmt.Fprintf(os.Stderr, "Warning: invalid RATE_LIMIT_BURST %q; using %d\n", raw, burst)
+		}
+	}
+
+	allowlist, err := parseAllowlist(os.Getenv("RATE_LIMIT_ALLOWLIST"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v; RATE_LIMIT_ALLOWLIST is ignored\n", err)
+		allowlist = nil
+	}
+
+	l := newRateLimiter(count, period, burst, allowlist)
+	l.spec = raw
+	switch strings.ToLower(strings.TrimSpace(os.Getenv("RATE_LIMIT_TRUST_PROXY"))) {
+	case "1", "true", "yes":
+		l.trustProxy = true
+	}
+	return l
+}
+
+// String describes the limit, e.g. "30/h per client, bursts of 30"
+func (l *rateLimiter) String() string {
+	return fmt.Sprintf("%s per client, bursts of %.0f", l.spec, l.burst)
+}
+
+// clientAddr is the address r is limited by: the connection's remote address,
+// or with trustProxy the last X-Forwarded-For entry, which the proxy added
+func (l *rateLimiter) clientAddr(r *http.Request) (netip.Addr, bool) {
+	if l.trustProxy {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			entries := strings.Split(forwarded, ",")
+			if addr, err := netip.ParseAddr(strings.TrimSpace(entries[len(entries)-1])); err == nil {
+				return addr.Unmap(), true
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
+
+// allowed reports whether addr is never limited. Loopback is only exempt when
+// X-Forwarded-For is trusted: otherwise a reverse proxy on the same host makes
+// every client look like loopback.
+func (l *rateLimiter) allowed(addr netip.Addr) bool {
+	if addr.IsLoopback() && l.trustProxy {
+		return true
+	}
+	for _, prefix := range l.allowlist {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// take spends one of addr's requests. When none are left it returns false and
+// how long until one is.
+func (l *rateLimiter) take(addr netip.Addr) (bool, time.Duration) {
+	if addr.Is6() {
+		prefix, _ := addr.WithZone("").Prefix(64)
+		addr = prefix.Addr()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	l.sweep(now)
+
+	bucket, ok := l.buckets[addr]
+	if !ok {
+		bucket = &tokenBucket{tokens: l.burst, updated: now}
+		l.buckets[addr] = bucket
+	}
+	bucket.tokens = math.Min(l.burst, bucket.tokens+now.Sub(bucket.updated).Seconds()*l.perSecond)
+	bucket.updated = now
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - bucket.tokens) / l.perSecond * float64(time.Second))
+	return false, wait
+}
+
+// sweep forgets buckets that have refilled, which behave the same as new ones
+func (l *rateLimiter) sweep(now time.Time) {
+	if now.Sub(l.swept) < rateLimitSweepInterval {
+		return
+	}
+	l.swept = now
+	for addr, bucket := range l.buckets {
+		if bucket.tokens+now.Sub(bucket.updated).Seconds()*l.perSecond >= l.burst {
+			delete(l.buckets, addr)
+		}
+	}
+}
+
+// limit rejects requests from clients that are out of requests with reject,
+// after setting Retry-After to the whole seconds until their next one
+func (l *rateLimiter) limit(reject func(http.ResponseWriter, *http.Request, time.Duration)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if l == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			addr, ok := l.clientAddr(r)
+			if ok && !l.allowed(addr) {
+				if ok, wait := l.take(addr); !ok {
+					retryAfter := time.Duration(math.Ceil(wait.Seconds())) * time.Second
+					w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())))
+					log.Printf("Warning: rate limited %s on %s for %s", addr, r.URL.Path, retryAfter)
+					reject(w, r, retryAfter)
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// rateLimitedError is ErrRateLimited with a hint saying when to try again
+func rateLimitedError(retryAfter time.Duration) *UserError {
+	ue := *ErrRateLimited
+	switch {
+	case retryAfter <= time.Minute:
+		ue.Hint = "Please try again in a minute."
+	case retryAfter < time.Hour:
+		ue.Hint = fmt.Sprintf("Please try again in %d minutes.", int(math.Ceil(retryAfter.Minutes())))
+	default:
+		ue.Hint = "Please try again later."
+	}
+	return &ue
+}
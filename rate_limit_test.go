@@ -0,0 +1,140 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	tests := []struct {
+		raw    string
+		count  int
+		period time.Duration
+	}{
+		{"30/h", 30, time.Hour},
+		{"100/d", 100, 24 * time.Hour},
+		{" 5 / 10m ", 5, 10 * time.Minute},
+	}
+	for _, tt := range tests {
+		count, period, err := parseRateLimit(tt.raw)
+		if err != nil || count != tt.count || period != tt.period {
+			t.Errorf("parseRateLimit(%q) = %d, %v, %v", tt.raw, count, period, err)
+		}
+	}
+	for _, raw := range []string{"30", "0/h", "x/h", "30/fortnight"} {
+		if _, _, err := parseRateLimit(raw); err == nil {
+			t.Errorf("parseRateLimit(%q) succeeded", raw)
+		}
+	}
+
+	allowlist, err := parseAllowlist("203.0.113.7, 198.51.100.0/24,2001:db8::/32")
+	if err != nil || len(allowlist) != 3 {
+		t.Fatalf("parseAllowlist = %v, %v", allowlist, err)
+	}
+	if _, err := parseAllowlist("not-an-ip"); err == nil {
+		t.Error("parseAllowlist accepted an invalid entry")
+	}
+}
+
+func TestRateLimiterTake(t *testing.T) {
+	allowlist, _ := parseAllowlist("198.51.100.0/24")
+	l := newRateLimiter(60, time.Hour, 2, allowlist)
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	l.now = func() time.Time { return now }
+
+	client := netip.MustParseAddr("203.0.113.7")
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.take(client); !ok {
+			t.Fatalf("request %d was limited within the burst", i+1)
+		}
+	}
+	ok, wait := l.take(client)
+	if ok || wait != time.Minute {
+		t.Errorf("third request = %v, wait %v", ok, wait)
+	}
+	// Other clients have their own buckets
+	if ok, _ := l.take(netip.MustParseAddr("203.0.113.8")); !ok {
+		t.Error("another client was limited")
+	}
+	// One request comes back a minute later
+	now = now.Add(time.Minute)
+	if ok, _ := l.take(client); !ok {
+		t.Error("request after a minute was limited")
+	}
+
+	// IPv6 addresses in one /64 share a bucket
+	for _, addr := range []string{"2001:db8::1", "2001:db8::2"} {
+		l.take(netip.MustParseAddr(addr))
+	}
+	if ok, _ := l.take(netip.MustParseAddr("2001:db8::3")); ok {
+		t.Error("third request from the same /64 was allowed")
+	}
+
+	if !l.allowed(netip.MustParseAddr("198.51.100.20")) || l.allowed(client) {
+		t.Error("allowlist doesn't cover the configured range only")
+	}
+	// Without a trusted proxy, loopback may be a same-host proxy's clients
+	if l.allowed(netip.MustParseAddr("127.0.0.1")) {
+		t.Error("loopback is exempt without a trusted proxy")
+	}
+	l.trustProxy = true
+	if !l.allowed(netip.MustParseAddr("127.0.0.1")) {
+		t.Error("loopback is limited behind a trusted proxy")
+	}
+
+	// Refilled buckets are forgotten
+	now = now.Add(time.Hour)
+	l.take(client)
+	if len(l.buckets) != 1 {
+		t.Errorf("%d buckets after an hour, want 1", len(l.buckets))
+	}
+}
+
+func TestRateLimitedRoutes(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	limiter := newRateLimiter(1, time.Hour, 1, nil)
+	router := NewRouter(ServerConfig{DB: db, RateLimiter: limiter})
+
+	post := func(path, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("HX-Request", "true")
+		token := strings.Repeat("ab", 32)
+		req.AddCookie(&http.Cookie{Name: csrfCookie, Value: token})
+		req.Header.Set(csrfHeader, token)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Without an API key the first request fails normally; the second is limited
+	if rec := post("/api/schools/360000100001/ai", "203.0.113.7:4000"); rec.Code == 429 {
+		t.Fatal("first API request was limited")
+	}
+	rec := post("/api/schools/360000100001/ai", "203.0.113.7:4001")
+	if rec.Code != 429 || rec.Header().Get("Retry-After") != "3600" || !strings.Contains(rec.Body.String(), `"error"`) {
+		t.Errorf("limited API request = %d, Retry-After %q: %s", rec.Code, rec.Header().Get("Retry-After"), rec.Body.String())
+	}
+
+	// Web routes share the client's bucket and explain the limit in HTML
+	rec = post("/schools/360000100001/summary", "203.0.113.7:4002")
+	if rec.Code != 429 || !strings.Contains(rec.Body.String(), `class="user-error"`) || !strings.Contains(rec.Body.String(), "try again later") {
+		t.Errorf("limited web request = %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// Without a trusted proxy, loopback clients are limited too, since a proxy
+	// on the same host would otherwise be unlimited
+	post("/api/schools/360000100001/ai", "127.0.0.1:4003")
+	if rec := post("/api/schools/360000100001/ai", "127.0.0.1:4003"); rec.Code != 429 {
+		t.Errorf("second loopback request = %d, want 429", rec.Code)
+	}
+	// Routes that don't cost anything aren't limited
+	if rec := post("/saved-searches/check", "203.0.113.7:4004"); rec.Code == 429 {
+		t.Error("unlimited route was limited")
+	}
+}
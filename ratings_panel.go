@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// RatingsPanel sets a school's official ratings side by side, one column per
+// publisher. It collates what states published and never combines them into
+// a score of its own.
+type RatingsPanel struct {
+	Sources    []RatingsPanelSource
+	Indicators []string // Every rated indicator, "Overall" first
+}
+
+// RatingsPanelSource is one publisher's most recent ratings for a school
+type RatingsPanelSource struct {
+	ID         string
+	Name       string
+	SchoolYear string
+	Ratings    map[string]StateRating // Latest year's ratings by indicator
+	Previous   map[string]StateRating // The year before's ratings by indicator
+	SourceURLs []string               // Files the latest ratings were read from
+	FetchedAt  time.Time
+}
+
+// Cell returns a source's rating for an indicator, or nil when it doesn't rate it
+func (s RatingsPanelSource) Cell(indicator string) *StateRating {
+	if r, ok := s.Ratings[indicator]; ok {
+		return &r
+	}
+	return nil
+}
+
+// Change describes the previous year's rating when it differs, e.g. "was B in 2022-23"
+func (s RatingsPanelSource) Change(indicator string) string {
+	prev, ok := s.Previous[indicator]
+	if !ok || prev.Rating == s.Ratings[indicator].Rating {
+		return ""
+	}
+	return fmt.Sprintf("was %s in %s", prev.Rating, prev.SchoolYear)
+}
+
+// Summary is one line for comparisons, e.g. "TEA A-F Accountability Ratings
+// 2023-24: Overall B"
+func (s RatingsPanelSource) Summary(indicators []string) string {
+	var parts []string
+	for _, ind := range indicators {
+		if r, ok := s.Ratings[ind]; ok {
+			parts = append(parts, ind+" "+r.Rating)
+		}
+	}
+	return s.Name + " " + s.SchoolYear + ": " + strings.Join(parts, " · ")
+}
+
+// Summaries is each source's summary line
+func (p *RatingsPanel) Summaries() []string {
+	var lines []string
+	for _, s := range p.Sources {
+		lines = append(lines, s.Summary(p.Indicators))
+	}
+	return lines
+}
+
+// ratingClasses style the ratings states publish: Dashboard colors and A-F letters
+var ratingClasses = map[string]string{
+	"red": "rating-red", "orange": "rating-orange", "yellow": "rating-yellow", "green": "rating-green", "blue": "rating-blue",
+	"a": "rating-a", "b": "rating-b", "c": "rating-c", "d": "rating-d", "f": "rating-f",
+}
+
+// RatingClass returns the CSS class for a published rating
+func RatingClass(rating string) string {
+	if class, ok := ratingClasses[strings.ToLower(rating)]; ok {
+		return class
+	}
+	return "rating-other"
+}
+
+// SchoolRatingsPanel collates a school's saved state ratings, or returns nil
+// when none have been refreshed for it
+func (d *DB) SchoolRatingsPanel(ncessch string) (*RatingsPanel, error) {
+	ratings, err := d.SchoolStateRatings(ncessch)
+	if err != nil {
+		return nil, err
+	}
+	return buildRatingsPanel(ratings), nil
+}
+
+// buildRatingsPanel groups ratings, which are ordered newest year first, by source
+func buildRatingsPanel(ratings []StateRating) *RatingsPanel {
+	if len(ratings) == 0 {
+		return nil
+	}
+	panel := &RatingsPanel{}
+	bySource := map[string]*RatingsPanelSource{}
+	var order []string
+	for _, r := range ratings {
+		s, ok := bySource[r.Source]
+		if !ok {
+			name := r.Source
+			if source, err := stateRatingSourceByID(r.Source); err == nil {
+				name = source.Name()
+			}
+			s = &RatingsPanelSource{
+				ID:         r.Source,
+				Name:       name,
+				SchoolYear: r.SchoolYear,
+				Ratings:    map[string]StateRating{},
+				Previous:   map[string]StateRating{},
+			}
+			bySource[r.Source] = s
+			order = append(order, r.Source)
+		}
+
+		switch {
+		case r.SchoolYear == s.SchoolYear:
+			s.Ratings[r.Indicator] = r
+			if !slices.Contains(s.SourceURLs, r.SourceURL) {
+				s.SourceURLs = append(s.SourceURLs, r.SourceURL)
+			}
+			if r.FetchedAt.After(s.FetchedAt) {
+				s.FetchedAt = r.FetchedAt
+			}
+			if !slices.Contains(panel.Indicators, r.Indicator) {
+				panel.Indicators = append(panel.Indicators, r.Indicator)
+			}
+		case s.Previous[r.Indicator].SchoolYear == "":
+			s.Previous[r.Indicator] = r
+		}
+	}
+	for _, id := range order {
+		panel.Sources = append(panel.Sources, *bySource[id])
+	}
+	slices.SortStableFunc(panel.Indicators, func(a, b string) int {
+		switch {
+		case a == b:
+			return 0
+		case a == "Overall":
+			return -1
+		case b == "Overall":
+			return 1
+		}
+		return strings.Compare(a, b)
+	})
+	return panel
+}
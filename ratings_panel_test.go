@@ -0,0 +1,75 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRatingsPanel(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	if panel, err := db.SchoolRatingsPanel("360000100001"); err != nil || panel != nil {
+		t.Fatalf("panel without ratings = %+v, %v", panel, err)
+	}
+
+	if _, err := db.conn.Exec(`
+		INSERT INTO state_ratings (ncessch, state, source, school_year, indicator, rating, score, source_url, fetched_at) VALUES
+		('360000100001', 'CA', 'ca-dashboard', '2023-24', 'Mathematics', 'Yellow', -12.5, 'https://example.org/mathdownload2024.txt', '2024-12-10 09:00:00'),
+		('360000100001', 'CA', 'ca-dashboard', '2023-24', 'Chronic Absenteeism', 'Orange', 12.4, 'https://example.org/chronicdownload2024.txt', '2024-12-10 09:00:00'),
+		('360000100001', 'CA', 'ca-dashboard', '2022-23', 'Chronic Absenteeism', 'Red', 20.0, 'https://example.org/chronicdownload2023.txt', '2023-12-10 09:00:00'),
+		('360000100001', 'CA', 'ca-dashboard', '2022-23', 'Mathematics', 'Yellow', -15.0, 'https://example.org/mathdownload2023.txt', '2023-12-10 09:00:00'),
+		('360000100001', 'CA', 'local-test', '2023-24', 'Overall', 'B', NULL, 'https://example.org/overall.csv', '2024-11-01 09:00:00')
+	`); err != nil {
+		t.Fatal(err)
+	}
+
+	panel, err := db.SchoolRatingsPanel("360000100001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(panel.Indicators, ","); got != "Overall,Chronic Absenteeism,Mathematics" {
+		t.Errorf("indicators = %s", got)
+	}
+	if len(panel.Sources) != 2 {
+		t.Fatalf("sources = %+v", panel.Sources)
+	}
+	ca := panel.Sources[0]
+	if ca.Name != "California School Dashboard" || ca.SchoolYear != "2023-24" || len(ca.SourceURLs) != 2 {
+		t.Errorf("CA source = %+v", ca)
+	}
+	if got := ca.Change("Chronic Absenteeism"); got != "was Red in 2022-23" {
+		t.Errorf("Change() = %q", got)
+	}
+	if got := ca.Change("Mathematics"); got != "" {
+		t.Errorf("unchanged rating Change() = %q", got)
+	}
+	if ca.Cell("Overall") != nil || ca.Cell("Mathematics").ScoreLabel() != "-12.5" {
+		t.Errorf("cells = %+v", ca.Ratings)
+	}
+	want := "California School Dashboard 2023-24: Chronic Absenteeism Orange · Mathematics Yellow"
+	if got := panel.Summaries()[0]; got != want {
+		t.Errorf("Summaries()[0] = %q, want %q", got, want)
+	}
+	// Sources no longer registered keep their ID as their name
+	if got := panel.Summaries()[1]; got != "local-test 2023-24: Overall B" {
+		t.Errorf("Summaries()[1] = %q", got)
+	}
+
+	router := NewRouter(ServerConfig{DB: db})
+	req := httptest.NewRequest("GET", "/schools/360000100001", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	body := rec.Body.String()
+	if !strings.Contains(body, `class="rating rating-orange"`) || !strings.Contains(body, "was Red in 2022-23") || !strings.Contains(body, `href="https://example.org/chronicdownload2024.txt"`) {
+		t.Error("detail page is missing the ratings panel")
+	}
+
+	req = httptest.NewRequest("GET", "/compare?ids=360000100001,360000100002", nil)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if body := rec.Body.String(); !strings.Contains(body, "Official Ratings") || !strings.Contains(body, "local-test 2023-24: Overall B") {
+		t.Error("compare page is missing official ratings")
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Data Explorer SQL is written by a model from a visitor's question, so it's
+// only run if it's a single SELECT (or WITH) over the database's own tables and
+// views, in a read-only transaction. DuckDB can't turn off file access for one
+// connection, so table functions that read files (read_csv, read_text, glob,
+// ...) and file paths used as tables are refused before the query runs.
+
+// readQueryTableFunctions are the table functions Data Explorer SQL may call;
+// duckdb_* and pragma_* catalog functions are also allowed
+var readQueryTableFunctions = map[string]bool{
+	"range": true, "generate_series": true, "unnest": true,
+}
+
+// ExecuteReadQuery is ExecuteQuery for SQL the Data Explorer wrote: see readQuery
+func (d *DB) ExecuteReadQuery(ctx context.Context, query string) ([]map[string]interface{}, error) {
+	var results []map[string]interface{}
+	err := d.readQuery(ctx, query, func(rows *sql.Rows) error {
+		var err error
+		results, err = scanQueryRows(rows)
+		return err
+	})
+	return results, err
+}
+
+// readQuery checks query with checkReadQuery, then runs it as
+// SELECT * FROM (query) in a read-only transaction and hands its rows to read
+func (d *DB) readQuery(ctx context.Context, query string, read func(*sql.Rows) error) error {
+	if err := d.checkReadQuery(query); err != nil {
+		return err
+	}
+
+	conn, err := d.conn.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, "BEGIN TRANSACTION READ ONLY"); err != nil {
+		return fmt.Errorf("failed to begin read-only transaction: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), "ROLLBACK")
+
+	// The newlines keep a trailing comment from swallowing the parenthesis
+	query = strings.TrimRight(strings.TrimSpace(query), ";")
+	rows, err := conn.QueryContext(ctx, "SELECT * FROM (\n"+query+"\n)")
+	if err != nil {
+		return fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+	return read(rows)
+}
+
+// checkReadQuery returns ErrNotReadQuery, with the reason, unless query is a
+// single SELECT that reads only tables, views, CTEs, and allowed table
+// functions. DuckDB's parser decides what the statement is:
+// json_serialize_sql only serializes SELECTs.
+func (d *DB) checkReadQuery(query string) error {
+	var serialized string
+	if err := d.conn.QueryRow(`SELECT json_serialize_sql(?::VARCHAR)::VARCHAR`, query).Scan(&serialized); err != nil {
+		return fmt.Errorf("failed to parse query: %w", err)
+	}
+	var parsed struct {
+		Error        bool              `json:"error"`
+		ErrorMessage string            `json:"error_message"`
+		Statements   []json.RawMessage `json:"statements"`
+	}
+	if err := json.Unmarshal([]byte(serialized), &parsed); err != nil {
+		return fmt.Errorf("failed to read parsed query: %w", err)
+	}
+	switch {
+	case parsed.Error:
+		return fmt.Errorf("%w: %s", ErrNotReadQuery, parsed.ErrorMessage)
+	case len(parsed.Statements) != 1:
+		return fmt.Errorf("%w: %d statements", ErrNotReadQuery, len(parsed.Statements))
+	}
+
+	var tree interface{}
+	if err := json.Unmarshal(parsed.Statements[0], &tree); err != nil {
+		return fmt.Errorf("failed to read parsed query: %w", err)
+	}
+	ctes := make(map[string]bool)
+	var tables []map[string]interface{}
+	var functions []string
+	var walk func(interface{})
+	walk = func(n interface{}) {
+		switch n := n.(type) {
+		case []interface{}:
+			for _, c := range n {
+				walk(c)
+			}
+		case map[string]interface{}:
+			if name, ok := n["cte_name"].(string); ok {
+				ctes[strings.ToLower(name)] = true
+			}
+			if m, ok := n["cte_map"].(map[string]interface{}); ok {
+				for _, entry := range asSlice(m["map"]) {
+					if e, ok := entry.(map[string]interface{}); ok {
+						if key, ok := e["key"].(string); ok {
+							ctes[strings.ToLower(key)] = true
+						}
+					}
+				}
+			}
+			switch n["type"] {
+			case "BASE_TABLE":
+				tables = append(tables, n)
+			case "TABLE_FUNCTION":
+				if f, ok := n["function"].(map[string]interface{}); ok {
+					name, _ := f["function_name"].(string)
+					functions = append(functions, strings.ToLower(name))
+				}
+			}
+			for _, c := range n {
+				walk(c)
+			}
+		}
+	}
+	walk(tree)
+
+	for _, name := range functions {
+		if !readQueryTableFunctions[name] && !strings.HasPrefix(name, "duckdb_") && !strings.HasPrefix(name, "pragma_") {
+			return fmt.Errorf("%w: table function %s", ErrNotReadQuery, name)
+		}
+	}
+	for _, t := range tables {
+		catalog, _ := t["catalog_name"].(string)
+		schema, _ := t["schema_name"].(string)
+		name, _ := t["table_name"].(string)
+		if catalog == "" && schema == "" && ctes[strings.ToLower(name)] {
+			continue
+		}
+		exists, err := d.relationExists(catalog, schema, name)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("%w: no table named %q", ErrNotReadQuery, name)
+		}
+	}
+	return nil
+}
+
+// relationExists reports whether the database has a table or view named name,
+// in schema and catalog if they're given. Anything else in a FROM clause would
+// be read as a file.
+func (d *DB) relationExists(catalog, schema, name string) (bool, error) {
+	var n int
+	err := d.conn.QueryRow(`
+		SELECT count(*) FROM (
+			SELECT database_name, schema_name, table_name AS name FROM duckdb_tables()
+			UNION ALL
+			SELECT database_name, schema_name, view_name FROM duckdb_views()
+		)
+		WHERE lower(name) = lower($3)
+			AND lower(database_name) = lower(COALESCE(NULLIF($1, ''), current_database()))
+			AND ($2 = '' OR lower(schema_name) = lower($2))
+	`, catalog, schema, name).Scan(&n)
+	if err != nil {
+		return false, fmt.Errorf("failed to check table %s: %w", name, err)
+	}
+	return n > 0, nil
+}
+
+// asSlice returns v as a slice, or nil if it isn't one
+func asSlice(v interface{}) []interface{} {
+	s, _ := v.([]interface{})
+	return s
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExecuteReadQuery(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	secret := filepath.Join(t.TempDir(), "secret.csv")
+	if err := os.WriteFile(secret, []byte("password\nhunter2\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, query := range []string{
+		"SELECT SCH_NAME FROM directory WHERE ST = 'CA';",
+		"WITH ca AS (SELECT * FROM directory WHERE ST = 'CA') SELECT SCH_NAME FROM ca",
+		"SELECT count(*) FROM directory -- the comment stays inside",
+		"SELECT table_name FROM duckdb_tables() WHERE table_name = 'directory'",
+		"SELECT * FROM range(3)",
+	} {
+		rows, err := db.ExecuteReadQuery(ctx, query)
+		if err != nil || len(rows) == 0 {
+			t.Errorf("ExecuteReadQuery(%q) = %d rows, %v", query, len(rows), err)
+		}
+	}
+
+	for _, query := range []string{
+		"UPDATE directory SET SCH_NAME = 'x'",
+		"DELETE FROM directory",
+		"SELECT 1; DROP TABLE directory",
+		"COPY directory TO '" + secret + "'",
+		"ATTACH '" + secret + ".db' AS other",
+		"SELECT * FROM read_csv('" + secret + "')",
+		"SELECT * FROM read_text('" + secret + "')",
+		"SELECT * FROM '" + secret + "'",
+		"WITH x AS (SELECT * FROM '" + secret + "') SELECT * FROM x",
+		"SELECT (SELECT count(*) FROM glob('/etc/*'))",
+	} {
+		if _, err := db.ExecuteReadQuery(ctx, query); !errors.Is(err, ErrNotReadQuery) {
+			t.Errorf("ExecuteReadQuery(%q) error = %v, want ErrNotReadQuery", query, err)
+		}
+	}
+
+	rows, err := db.ExecuteQuery("SELECT count(*) AS n FROM directory")
+	if err != nil || rows[0]["n"] == int64(0) {
+		t.Errorf("directory changed: %v, %v", rows, err)
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"schoolfinder/cmd"
+)
+
+// remoteTimeout bounds each request to a remote server; website extraction
+// runs on the server and can take a couple of minutes
+const remoteTimeout = 3 * time.Minute
+
+// remoteDB implements cmd.DBInterface over another School Finder server's JSON
+// API, so one household server can back everyone's CLI commands; the TUI
+// still needs a local database. Requests carry the user's API token, issued by an
+// admin at /admin/users.
+type remoteDB struct {
+	baseURL string
+	token   string
+	client  *http.Client
+}
+
+// newRemoteDB connects to the server at rawURL, an http or https URL
+func newRemoteDB(rawURL, token string) (*remoteDB, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid server URL %q: use http://host:port or https://host", rawURL)
+	}
+	return &remoteDB{
+		baseURL: strings.TrimSuffix(u.String(), "/"),
+		token:   token,
+		client:  &http.Client{Timeout: remoteTimeout},
+	}, nil
+}
+
+// do sends a request to the API and decodes its JSON response into out. Error
+// responses become UserErrors carrying the server's message and hint.
+func (d *remoteDB) do(method, path string, body, out interface{}) error {
+	var reqBody io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		reqBody = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, d.baseURL+path, reqBody)
+	if err != nil {
+		return fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if d.token != "" {
+		req.Header.Set("Authorization", "Bearer "+d.token)
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrServerUnreachable, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var apiErr struct {
+			Error string `json:"error"`
+			Hint  string `json:"hint"`
+		}
+		if err := json.NewDecoder(io.LimitReader(resp.Body, 64*1024)).Decode(&apiErr); err != nil || apiErr.Error == "" {
+			apiErr.Error = fmt.Sprintf("The server responded %s.", resp.Status)
+		}
+		if resp.StatusCode == http.StatusUnauthorized {
+			apiErr.Hint = "Pass an API token from an admin with --token or SCHOOLFINDER_TOKEN."
+		}
+		return &UserError{
+			err:     fmt.Sprintf("%s %s: HTTP %d: %s", method, path, resp.StatusCode, apiErr.Error),
+			Message: apiErr.Error,
+			Hint:    apiErr.Hint,
+			Status:  resp.StatusCode,
+		}
+	}
+
+	dec := json.NewDecoder(resp.Body)
+	dec.UseNumber() // Keep integers from query results exact
+	if err := dec.Decode(out); err != nil {
+		return fmt.Errorf("failed to read server response: %w", err)
+	}
+	return nil
+}
+
+func (d *remoteDB) SearchSchools(query string, state string, limit int) ([]cmd.SchoolData, error) {
+	params := url.Values{"q": {query}}
+	if state != "" {
+		params.Set("state", state)
+	}
+	if limit > 0 {
+		params.Set("limit", strconv.Itoa(limit))
+	}
+	var resp struct {
+		Schools []School `json:"schools"`
+	}
+	if err := d.do(http.MethodGet, "/api/search?"+params.Encode(), nil, &resp); err != nil {
+		return nil, err
+	}
+
+	result := make([]cmd.SchoolData, 0, len(resp.Schools))
+	for _, s := range resp.Schools {
+		if limit > 0 && len(result) == limit {
+			break
+		}
+		result = append(result, convertSchoolToCmd(s))
+	}
+	return result, nil
+}
+
+func (d *remoteDB) GetSchoolByID(ncessch string) (*cmd.SchoolData, error) {
+	var resp struct {
+		School *School `json:"school"`
+	}
+	err := d.do(http.MethodGet, "/api/schools/"+url.PathEscape(ncessch), nil, &resp)
+	if ue := userError(err); ue != nil && ue.Status == http.StatusNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if resp.School == nil {
+		return nil, nil
+	}
+	result := convertSchoolToCmd(*resp.School)
+	return &result, nil
+}
+
+// ExecuteQuery runs SQL on the server, which requires an editor's token. The
+// server only runs single SELECTs (including SHOW, DESCRIBE, and SUMMARIZE)
+// on its own tables; see readQuery.
+func (d *remoteDB) ExecuteQuery(query string) ([]map[string]interface{}, error) {
+	var resp struct {
+		Rows []map[string]interface{} `json:"rows"`
+	}
+	if err := d.do(http.MethodPost, "/api/v1/query", map[string]string{"sql": query}, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Rows, nil
+}
+
+func (d *remoteDB) Close() error {
+	d.client.CloseIdleConnections()
+	return nil
+}
+
+// remoteScraper extracts website data on the server, which requires an
+// editor's token and uses the server's Anthropic key
+type remoteScraper struct {
+	db *remoteDB
+}
+
+func (s *remoteScraper) ExtractSchoolDataWithWebSearch(school *cmd.SchoolData) (*cmd.EnhancedSchoolDataJSON, error) {
+	var resp struct {
+		EnhancedData *EnhancedSchoolData `json:"enhancedData"`
+	}
+	if err := s.db.do(http.MethodPost, "/api/schools/"+url.PathEscape(school.NCESSCH)+"/ai", nil, &resp); err != nil {
+		return nil, err
+	}
+	if resp.EnhancedData == nil {
+		return nil, fmt.Errorf("the server returned no website data")
+	}
+	return convertEnhancedToCmd(resp.EnhancedData), nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRemoteDB(t *testing.T) {
+	fastHashing(t)
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	users, _ := loadUserStore("")
+	for name, role := range map[string]Role{"vera": RoleViewer, "ada": RoleAdmin} {
+		if err := users.Save(name, name+"-password", role); err != nil {
+			t.Fatal(err)
+		}
+	}
+	viewerToken, err := users.IssueToken("vera")
+	if err != nil {
+		t.Fatal(err)
+	}
+	adminToken, _ := users.IssueToken("ada")
+	if role, ok := users.AuthenticateToken(adminToken); !ok || role != RoleAdmin {
+		t.Errorf("AuthenticateToken = %v, %v", role, ok)
+	}
+	if _, ok := users.AuthenticateToken(adminToken + "0"); ok {
+		t.Error("wrong token accepted")
+	}
+
+	server := httptest.NewServer(NewRouter(ServerConfig{DB: db, Users: users}))
+	defer server.Close()
+
+	if _, err := newRemoteDB("ftp://example.org", ""); err == nil {
+		t.Error("non-HTTP server URL accepted")
+	}
+	viewer, err := newRemoteDB(server.URL+"/", viewerToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	schools, err := viewer.SearchSchools("Lincoln", "", 10)
+	if err != nil || len(schools) == 0 || schools[0].Name != "Lincoln Elementary School" {
+		t.Fatalf("SearchSchools = %+v, %v", schools, err)
+	}
+	local, _ := db.GetSchoolByID("360000100001")
+	school, err := viewer.GetSchoolByID("360000100001")
+	if err != nil || school == nil || school.City != local.City || school.Enrollment == nil || *school.Enrollment != local.Enrollment.Int64 {
+		t.Errorf("GetSchoolByID = %+v, %v", school, err)
+	}
+	if school, err := viewer.GetSchoolByID("999999999999"); school != nil || err != nil {
+		t.Errorf("missing school = %+v, %v", school, err)
+	}
+
+	// Read-only SQL needs an editor's token
+	if _, err := viewer.ExecuteQuery("SELECT 1"); userError(err) == nil || userError(err).Status != http.StatusForbidden {
+		t.Errorf("viewer query error = %v", err)
+	}
+	anonymous, _ := newRemoteDB(server.URL, "")
+	if _, err := anonymous.ExecuteQuery("SELECT 1"); userError(err) == nil || userError(err).Status != http.StatusUnauthorized {
+		t.Errorf("anonymous query error = %v", err)
+	}
+	admin, _ := newRemoteDB(server.URL, adminToken)
+	rows, err := admin.ExecuteQuery("SELECT count(*) AS schools FROM directory")
+	if err != nil || len(rows) != 1 || rows[0]["schools"] != json.Number("5") {
+		t.Errorf("ExecuteQuery = %v, %v", rows, err)
+	}
+	if rows, err := admin.ExecuteQuery("SUMMARIZE directory"); err != nil || len(rows) == 0 {
+		t.Errorf("remote SUMMARIZE = %d rows, %v", len(rows), err)
+	}
+	for _, query := range []string{"DELETE FROM directory", "SELECT * FROM read_text('/etc/hostname')"} {
+		_, err := admin.ExecuteQuery(query)
+		if ue := userError(err); ue == nil || ue.Status != http.StatusBadRequest || !strings.Contains(ue.Message, "SELECT") {
+			t.Errorf("remote %q error = %v", query, err)
+		}
+	}
+	if _, err := admin.ExecuteQuery("SELECT no_such_column FROM directory"); userError(err) == nil || userError(err).Status != http.StatusUnprocessableEntity {
+		t.Errorf("bad query error = %v", err)
+	}
+
+	server.Close()
+	if _, err := viewer.SearchSchools("Lincoln", "", 10); !errors.Is(err, ErrServerUnreachable) {
+		t.Errorf("stopped server error = %v", err)
+	}
+}
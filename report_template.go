@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Report templates let users write a school's dossier in their own format
+// with Go's text/template. The data a template runs with is ReportData,
+// documented in docs/REPORT_TEMPLATES.md.
+
+// ReportData is what a report template is executed with
+type ReportData struct {
+	School       *School
+	EnhancedData *EnhancedSchoolData // Nil without website data
+	NAEPView     *NAEPDataView       // Nil without NAEP data
+	Tags         []BundleTag
+	Notes        []BundleNote
+	Provenance   []FieldSource
+	DataVersion  []DataVersion // The CCD files the school's record is from
+	GeneratedAt  time.Time
+}
+
+// reportFuncs are the functions report templates can call, besides text/template's own
+var reportFuncs = template.FuncMap{
+	"formatNumber": formatNumber,
+	"pct":          pct,
+	"ratio":        ratio,
+	"naLabel":      naLabel,
+	"gradeLabel":   gradeLabel,
+	"join":         strings.Join,
+	"date": func(layout string, t time.Time) string {
+		return t.Format(layout)
+	},
+}
+
+// LoadReportTemplate parses a report template file
+func LoadReportTemplate(path string) (*template.Template, error) {
+	tmpl, err := template.New(filepath.Base(path)).Funcs(reportFuncs).ParseFiles(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load report template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// reportExtension is the file extension for reports from a template: the
+// extension before .tmpl, e.g. ".md" for report.md.tmpl, or ".txt"
+func reportExtension(tmpl *template.Template) string {
+	if ext := filepath.Ext(strings.TrimSuffix(tmpl.Name(), ".tmpl")); ext != "" {
+		return ext
+	}
+	return ".txt"
+}
+
+// newReportData gathers a report's data from a school's dossier
+func newReportData(bundle *SchoolBundle, naep *NAEPClient) ReportData {
+	data := ReportData{
+		School:       bundle.School,
+		EnhancedData: bundle.AIExtracted,
+		Tags:         bundle.Tags,
+		Notes:        bundle.Notes,
+		Provenance:   bundle.Provenance,
+		DataVersion:  bundle.DataVersion,
+		GeneratedAt:  time.Now(),
+	}
+	if bundle.NAEPData != nil {
+		data.NAEPView = (&WebHandler{NAEPClient: naep}).enrichNAEPData(bundle.NAEPData)
+	}
+	return data
+}
+
+// RenderReport runs a report template on a school's dossier
+func RenderReport(tmpl *template.Template, bundle *SchoolBundle, naep *NAEPClient) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, newReportData(bundle, naep)); err != nil {
+		return nil, fmt.Errorf("failed to render report: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// writeSchoolReport renders a school's dossier with a report template to filename
+func writeSchoolReport(db *DB, naep *NAEPClient, tmpl *template.Template, school *School, enhanced *EnhancedSchoolData, naepData *NAEPData, filename string) error {
+	bundle, err := BuildSchoolBundle(db, school, enhanced, naepData)
+	if err != nil {
+		return fmt.Errorf("failed to gather school data: %w", err)
+	}
+	report, err := RenderReport(tmpl, bundle, naep)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filename, report, 0644); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	return nil
+}
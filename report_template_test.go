@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReportTemplate(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	school, err := db.GetSchoolByID("360000100001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	enhanced := &EnhancedSchoolData{
+		NCESSCH:         school.NCESSCH,
+		SchoolName:      school.Name,
+		SourceURL:       "https://lincoln.example.org",
+		MarkdownContent: "Lincoln offers Spanish immersion.",
+		Principal:       "Maria Alvarez",
+		ExtractedAt:     time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC),
+	}
+	if err := saveEnhancedData(db, enhanced); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SyncProgramFlags(db); err != nil {
+		t.Fatal(err)
+	}
+	naep := NewNAEPClient(db, WithNAEPHTTPClient(&http.Client{Transport: testFixtures(t, "naep.json", naepFixtureKey)}))
+	naepData, err := naep.FetchNAEPData(context.Background(), school)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The example in the docs uses every part of the data
+	tmpl, err := LoadReportTemplate(filepath.Join("docs", "report_example.md.tmpl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ext := reportExtension(tmpl); ext != ".md" {
+		t.Errorf("report extension = %q", ext)
+	}
+	bundle, err := BuildSchoolBundle(db, school, enhanced, naepData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := RenderReport(tmpl, bundle, naep)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"# Lincoln Elementary School",
+		"| Enrollment | 500 |",
+		"- Dual-language immersion (school website)",
+		"Extracted March 1, 2026 from https://lincoln.example.org",
+		"Principal: Maria Alvarez",
+		"% at or above proficient",
+		"- Enrollment: ",
+		"- CCD 2023-2024 directory, released 2024-07-31, loaded ",
+	} {
+		if !strings.Contains(string(report), want) {
+			t.Errorf("report is missing %q:\n%s", want, report)
+		}
+	}
+
+	// Bulk saves write each school with the template
+	custom := filepath.Join(t.TempDir(), "card.tmpl")
+	if err := os.WriteFile(custom, []byte(`{{.School.Name}} ({{len .Tags}} programs){{if not .NAEPView}}, no NAEP{{end}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if tmpl, err = LoadReportTemplate(custom); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	manifest, err := SaveDossiers(context.Background(), db, nil, nil, []School{*school}, BulkSaveOptions{Dir: dir, Template: tmpl})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.Format != "template" || manifest.Template != "card.tmpl" || manifest.Schools[0].File != "Lincoln Elementary School (360000100001).txt" {
+		t.Fatalf("manifest = %+v", manifest)
+	}
+	card, err := os.ReadFile(filepath.Join(dir, manifest.Schools[0].File))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(card) != "Lincoln Elementary School (1 programs), no NAEP" {
+		t.Errorf("card = %q", card)
+	}
+
+	if _, err := LoadReportTemplate(filepath.Join(t.TempDir(), "missing.tmpl")); err == nil {
+		t.Error("Expected a missing template to fail")
+	}
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// A retry budget keeps an outage from turning every request into a burst of
+// retries: each retry spends a token, and each successful call earns back a
+// fraction of one
+const (
+	retryBudgetTokens = 10.0
+	retryBudgetRefill = 0.1
+)
+
+// retryPolicy retries transient failures of one kind of outside request with
+// jittered exponential backoff, up to maxAttempts tries within maxElapsed
+type retryPolicy struct {
+	name        string // Operation name for logs and metrics
+	maxAttempts int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+	maxElapsed  time.Duration
+
+	mu     sync.Mutex
+	tokens float64
+	stats  RetryStats
+}
+
+// RetryStats counts a retry policy's calls and retries
+type RetryStats struct {
+	Name            string `json:"name"`
+	Calls           int64  `json:"calls"`
+	Retries         int64  `json:"retries"`
+	Recovered       int64  `json:"recovered"`        // Calls that succeeded after retrying
+	Failed          int64  `json:"failed"`           // Calls that returned an error
+	BudgetExhausted int64  `json:"budget_exhausted"` // Retries skipped because the budget was spent
+}
+
+func newRetryPolicy(name string, maxAttempts int, baseDelay, maxDelay, maxElapsed time.Duration) *retryPolicy {
+	return &retryPolicy{
+		name:        name,
+		maxAttempts: maxAttempts,
+		baseDelay:   baseDelay,
+		maxDelay:    maxDelay,
+		maxElapsed:  maxElapsed,
+		tokens:      retryBudgetTokens,
+		stats:       RetryStats{Name: name},
+	}
+}
+
+// Retry policies for outside requests, shared so each has one budget
+var (
+	naepRetry     = newRetryPolicy("naep", 4, 500*time.Millisecond, 8*time.Second, 30*time.Second)
+	websiteRetry  = newRetryPolicy("website", 3, time.Second, 5*time.Second, 20*time.Second)
+	downloadRetry = newRetryPolicy("download", 5, 2*time.Second, 30*time.Second, 10*time.Minute)
+	webhookRetry  = newRetryPolicy("webhook", 3, time.Second, 5*time.Second, 20*time.Second)
+)
+
+// RetryMetrics returns the retry counts for each outside service
+func RetryMetrics() []RetryStats {
+	var stats []RetryStats
+	for _, p := range []*retryPolicy{naepRetry, websiteRetry, downloadRetry, webhookRetry} {
+		stats = append(stats, p.Stats())
+	}
+	return stats
+}
+
+// Stats returns a snapshot of the policy's counts
+func (p *retryPolicy) Stats() RetryStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.stats
+}
+
+// Do calls fn until it succeeds or fails with a permanent error, retrying
+// transient errors until the policy runs out of attempts, time, or budget. It
+// returns fn's last error. A nil policy calls fn once.
+func (p *retryPolicy) Do(ctx context.Context, fn func() error) error {
+	if p == nil {
+		return fn()
+	}
+
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil {
+			p.finish(attempt, nil)
+			return nil
+		}
+		if !isTransient(err) || attempt >= p.maxAttempts {
+			p.finish(attempt, err)
+			return err
+		}
+
+		delay := p.backoff(attempt, err)
+		if p.maxElapsed > 0 && time.Since(start)+delay > p.maxElapsed {
+			p.finish(attempt, err)
+			return err
+		}
+		if !p.spendToken() {
+			if logger != nil {
+				logger.Warn("Retry budget exhausted; not retrying", "operation", p.name, "attempt", attempt, "error", err)
+			}
+			p.finish(attempt, err)
+			return err
+		}
+
+		if logger != nil {
+			logger.Warn("Retrying after transient failure", "operation", p.name, "attempt", attempt, "delay", delay, "error", err)
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			p.finish(attempt, err)
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff returns the delay before the retry after the given attempt: an
+// exponentially growing delay capped at maxDelay, with jitter so clients that
+// failed together don't retry together. A longer Retry-After from the server
+// wins, up to maxDelay.
+func (p *retryPolicy) backoff(attempt int, err error) time.Duration {
+	delay := p.maxDelay
+	if shift := attempt - 1; shift < 30 && p.baseDelay<<shift < p.maxDelay {
+		delay = p.baseDelay << shift
+	}
+	delay = delay/2 + rand.N(delay/2+1)
+
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) && statusErr.RetryAfter > delay {
+		delay = statusErr.RetryAfter
+		if delay > p.maxDelay {
+			delay = p.maxDelay
+		}
+	}
+	return delay
+}
+
+// spendToken takes a token from the retry budget, reporting whether one was left
+func (p *retryPolicy) spendToken() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.tokens < 1 {
+		p.stats.BudgetExhausted++
+		return false
+	}
+	p.tokens--
+	p.stats.Retries++
+	return true
+}
+
+// finish records a call's outcome after the given number of attempts
+func (p *retryPolicy) finish(attempts int, err error) {
+	p.mu.Lock()
+	p.stats.Calls++
+	switch {
+	case err != nil:
+		p.stats.Failed++
+	case attempts > 1:
+		p.stats.Recovered++
+	}
+	if err == nil {
+		p.tokens = math.Min(p.tokens+retryBudgetRefill, retryBudgetTokens)
+	}
+	p.mu.Unlock()
+
+	if logger == nil || attempts == 1 {
+		return
+	}
+	if err != nil {
+		logger.Error("Giving up after retries", "operation", p.name, "attempts", attempts, "error", err)
+	} else {
+		logger.Info("Recovered after retries", "operation", p.name, "attempts", attempts)
+	}
+}
+
+// httpStatusError is a non-OK HTTP response, typed so retries can tell
+// transient statuses from permanent ones
+type httpStatusError struct {
+	StatusCode int
+	RetryAfter time.Duration // From the Retry-After header, if any
+}
+
+func newHTTPStatusError(resp *http.Response) *httpStatusError {
+	err := &httpStatusError{StatusCode: resp.StatusCode}
+	if seconds, parseErr := strconv.Atoi(resp.Header.Get("Retry-After")); parseErr == nil && seconds > 0 {
+		err.RetryAfter = time.Duration(seconds) * time.Second
+	}
+	return err
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("HTTP %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// isTransient reports whether err is worth retrying: timeouts, dropped or refused
+// connections, and HTTP statuses that mean "try again later"
+func isTransient(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) {
+		switch statusErr.StatusCode {
+		case http.StatusRequestTimeout, http.StatusTooManyRequests, http.StatusInternalServerError,
+			http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsTemporary || dnsErr.IsTimeout
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
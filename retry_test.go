@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// testRetryPolicy retries quickly so tests don't wait on real backoff
+func testRetryPolicy(maxAttempts int) *retryPolicy {
+	return newRetryPolicy("test", maxAttempts, time.Millisecond, 4*time.Millisecond, time.Second)
+}
+
+func TestRetryPolicyDo(t *testing.T) {
+	transient := &httpStatusError{StatusCode: http.StatusServiceUnavailable}
+	permanent := &httpStatusError{StatusCode: http.StatusNotFound}
+
+	tests := []struct {
+		name         string
+		failures     []error // Errors returned by successive attempts before succeeding
+		maxAttempts  int
+		wantErr      error
+		wantAttempts int
+		want         RetryStats
+	}{
+		{"succeeds first try", nil, 3, nil, 1, RetryStats{Calls: 1}},
+		{"recovers from transient errors", []error{transient, transient}, 3, nil, 3, RetryStats{Calls: 1, Retries: 2, Recovered: 1}},
+		{"gives up after max attempts", []error{transient, transient, transient}, 3, transient, 3, RetryStats{Calls: 1, Retries: 2, Failed: 1}},
+		{"doesn't retry permanent errors", []error{permanent}, 3, permanent, 1, RetryStats{Calls: 1, Failed: 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := testRetryPolicy(tt.maxAttempts)
+			attempts := 0
+			err := p.Do(context.Background(), func() error {
+				attempts++
+				if attempts <= len(tt.failures) {
+					return tt.failures[attempts-1]
+				}
+				return nil
+			})
+
+			if err != tt.wantErr {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+			if attempts != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", attempts, tt.wantAttempts)
+			}
+			tt.want.Name = "test"
+			if got := p.Stats(); got != tt.want {
+				t.Errorf("stats = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryBudget(t *testing.T) {
+	p := testRetryPolicy(3)
+	p.tokens = 1
+
+	failing := func() error { return syscall.ECONNRESET }
+
+	// The one token buys one retry; after that calls fail on their first error
+	_ = p.Do(context.Background(), failing)
+	_ = p.Do(context.Background(), failing)
+
+	stats := p.Stats()
+	if stats.Retries != 1 || stats.BudgetExhausted != 2 || stats.Failed != 2 {
+		t.Errorf("stats = %+v, want 1 retry, 2 budget exhausted, 2 failed", stats)
+	}
+
+	// Successful calls earn the budget back
+	for i := 0; i < 20; i++ {
+		_ = p.Do(context.Background(), func() error { return nil })
+	}
+	if p.tokens < 1 {
+		t.Errorf("tokens = %v after 20 successes, want at least 1", p.tokens)
+	}
+}
+
+func TestRetryStopsOnCancel(t *testing.T) {
+	p := newRetryPolicy("test", 5, time.Hour, time.Hour, 0)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	attempts := 0
+	err := p.Do(ctx, func() error {
+		attempts++
+		return syscall.ECONNREFUSED
+	})
+	if !errors.Is(err, syscall.ECONNREFUSED) || attempts != 1 {
+		t.Errorf("err = %v after %d attempts, want ECONNREFUSED after 1", err, attempts)
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	p := newRetryPolicy("test", 10, 100*time.Millisecond, time.Second, 0)
+
+	for attempt, want := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 4: 800 * time.Millisecond, 5: time.Second, 40: time.Second} {
+		for i := 0; i < 20; i++ {
+			got := p.backoff(attempt, syscall.ECONNRESET)
+			if got < want/2 || got > want {
+				t.Fatalf("backoff(%d) = %v, want between %v and %v", attempt, got, want/2, want)
+			}
+		}
+	}
+
+	// Retry-After wins over a shorter backoff, capped at maxDelay
+	if got := p.backoff(1, &httpStatusError{StatusCode: 429, RetryAfter: 500 * time.Millisecond}); got != 500*time.Millisecond {
+		t.Errorf("backoff with Retry-After 500ms = %v", got)
+	}
+	if got := p.backoff(1, &httpStatusError{StatusCode: 429, RetryAfter: time.Minute}); got != time.Second {
+		t.Errorf("backoff with Retry-After 1m = %v, want maxDelay", got)
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&httpStatusError{StatusCode: 429}, true},
+		{fmt.Errorf("API returned %w", &httpStatusError{StatusCode: 503}), true},
+		{&httpStatusError{StatusCode: 404}, false},
+		{&httpStatusError{StatusCode: 403}, false},
+		{fmt.Errorf("HTTP request failed: %w", syscall.ECONNRESET), true},
+		{syscall.ECONNREFUSED, true},
+		{io.ErrUnexpectedEOF, true},
+		{context.DeadlineExceeded, true},
+		{context.Canceled, false},
+		{errors.New("failed to parse JSON"), false},
+	}
+
+	for _, tt := range tests {
+		if got := isTransient(tt.err); got != tt.want {
+			t.Errorf("isTransient(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestNAEPFetchRetries(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		fmt.Fprint(w, `{"status":200,"result":[{"value":240.5,"year":2022,"jurisLabel":"California"}]}`)
+	}))
+	defer server.Close()
+
+	retry := testRetryPolicy(3)
+	client := &NAEPClient{httpClient: server.Client(), baseURL: server.URL, retry: retry}
+
+	points, err := client.fetchAndParse(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("fetchAndParse failed: %v", err)
+	}
+	if len(points) != 1 || requests.Load() != 2 {
+		t.Errorf("got %d points after %d requests, want 1 after 2", len(points), requests.Load())
+	}
+	if stats := retry.Stats(); stats.Recovered != 1 {
+		t.Errorf("stats = %+v, want 1 recovered call", stats)
+	}
+}
+
+func TestNAEPFetchRateLimited(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	client := &NAEPClient{httpClient: server.Client(), baseURL: server.URL, retry: testRetryPolicy(2)}
+	if _, err := client.fetchAndParse(context.Background(), server.URL); !errors.Is(err, ErrUpstreamRateLimited) {
+		t.Errorf("err = %v, want ErrUpstreamRateLimited", err)
+	}
+}
+
+func TestDownloadFileRetries(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, "NCESSCH,SCH_NAME\n")
+	}))
+	defer server.Close()
+
+	saved := downloadRetry
+	downloadRetry = testRetryPolicy(3)
+	defer func() { downloadRetry = saved }()
+
+	path := filepath.Join(t.TempDir(), "data.zip")
+	if err := DownloadFileWithProgress(path, server.URL, 1, 1, 0, 0); err != nil {
+		t.Fatalf("DownloadFileWithProgress failed: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "NCESSCH,SCH_NAME\n" || requests.Load() != 2 {
+		t.Errorf("got %q after %d requests", data, requests.Load())
+	}
+}
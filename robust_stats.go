@@ -0,0 +1,172 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+)
+
+// Robust statistics settings. Values more than outlierFence interquartile
+// ranges beyond the quartiles are outliers (Tukey's fences), and trimmed
+// means drop trimFraction of the values from each end.
+const (
+	outlierFence = 1.5
+	trimFraction = 0.1
+)
+
+// RobustSummary describes a set of values with statistics that data errors
+// such as a 0 or 10x enrollment don't skew
+type RobustSummary struct {
+	Values      int // Values summarized
+	Median      float64
+	Q1, Q3      float64
+	Mean        float64 // Mean excluding outliers
+	TrimmedMean float64 // Mean of the middle values, trimFraction dropped from each end
+	Outliers    int     // Values beyond the fences, left out of Mean
+}
+
+// SummarizeRobust summarizes values. Quartiles interpolate between values
+// like DuckDB's quantile_cont, so summaries computed here and in SQL agree.
+func SummarizeRobust(values []float64) RobustSummary {
+	s := RobustSummary{Values: len(values)}
+	if len(values) == 0 {
+		return s
+	}
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+	s.Q1, s.Median, s.Q3 = quantile(sorted, 0.25), quantile(sorted, 0.5), quantile(sorted, 0.75)
+
+	low, high := s.Fences()
+	var sum float64
+	kept := 0
+	for _, v := range sorted {
+		if v < low || v > high {
+			s.Outliers++
+			continue
+		}
+		sum += v
+		kept++
+	}
+	s.Mean = sum / float64(kept)
+
+	trim := int(math.Floor(float64(len(sorted)) * trimFraction))
+	sum = 0
+	for _, v := range sorted[trim : len(sorted)-trim] {
+		sum += v
+	}
+	s.TrimmedMean = sum / float64(len(sorted)-2*trim)
+	return s
+}
+
+// quantile interpolates the p quantile of sorted values
+func quantile(sorted []float64, p float64) float64 {
+	h := float64(len(sorted)-1) * p
+	lo := int(math.Floor(h))
+	if lo+1 >= len(sorted) {
+		return sorted[lo]
+	}
+	return sorted[lo] + (h-float64(lo))*(sorted[lo+1]-sorted[lo])
+}
+
+// Fences are the lowest and highest values that aren't outliers
+func (s RobustSummary) Fences() (float64, float64) {
+	iqr := s.Q3 - s.Q1
+	return s.Q1 - outlierFence*iqr, s.Q3 + outlierFence*iqr
+}
+
+// Summary gives the median and outlier-excluding mean with decimals places,
+// e.g. "median 540, mean 562"
+func (s RobustSummary) Summary(decimals int) string {
+	if s.Values == 0 {
+		return "not reported"
+	}
+	return fmt.Sprintf("median %s, mean %s", formatStat(s.Median, decimals), formatStat(s.Mean, decimals))
+}
+
+// Method notes how the mean was computed, e.g. "mean excludes 3 outliers
+// beyond 1.5×IQR; 10% trimmed mean 548"
+func (s RobustSummary) Method(decimals int) string {
+	if s.Values == 0 {
+		return ""
+	}
+	excluded := "no outliers"
+	if s.Outliers > 0 {
+		excluded = fmt.Sprintf("%d outlier%s", s.Outliers, pluralS(s.Outliers))
+	}
+	return fmt.Sprintf("mean excludes %s beyond %g×IQR; %g%% trimmed mean %s",
+		excluded, outlierFence, trimFraction*100, formatStat(s.TrimmedMean, decimals))
+}
+
+// formatStat formats a statistic with thousands separators and decimals places
+func formatStat(v float64, decimals int) string {
+	if decimals == 0 {
+		return formatChartValue(math.Round(v))
+	}
+	return fmt.Sprintf("%.*f", decimals, v)
+}
+
+// pluralS is "s" unless n is 1
+func pluralS(n int) string {
+	if n == 1 {
+		return ""
+	}
+	return "s"
+}
+
+// summarizeRobustSQL summarizes column over the rows of the matches CTE that
+// report it (values above 0), computed in SQL like SummarizeRobust
+func summarizeRobustSQL(q queryer, matches, column string, args []interface{}) (RobustSummary, error) {
+	var s RobustSummary
+	var median, q1, q3, mean, trimmed sql.NullFloat64
+	var outliers sql.NullInt64
+	err := q.QueryRow(matches+fmt.Sprintf(`
+		, reported AS (
+			SELECT %[1]s AS v, row_number() OVER (ORDER BY %[1]s) AS i, count(*) OVER () AS n
+			FROM matches WHERE %[1]s > 0
+		), quartiles AS (
+			SELECT quantile_cont(v, 0.25) AS q1, quantile_cont(v, 0.75) AS q3 FROM reported
+		)
+		SELECT count(v), median(v), any_value(q1), any_value(q3),
+			avg(v) FILTER (WHERE v BETWEEN q1 - %[2]g * (q3 - q1) AND q3 + %[2]g * (q3 - q1)),
+			count(v) FILTER (WHERE v NOT BETWEEN q1 - %[2]g * (q3 - q1) AND q3 + %[2]g * (q3 - q1)),
+			avg(v) FILTER (WHERE i > floor(n * %[3]g) AND i <= n - floor(n * %[3]g))
+		FROM reported, quartiles
+	`, column, outlierFence, trimFraction), args...).Scan(&s.Values, &median, &q1, &q3, &mean, &outliers, &trimmed)
+	if err != nil {
+		return s, fmt.Errorf("failed to summarize %s: %w", column, err)
+	}
+	s.Median, s.Q1, s.Q3 = median.Float64, q1.Float64, q3.Float64
+	s.Mean, s.TrimmedMean, s.Outliers = mean.Float64, trimmed.Float64, int(outliers.Int64)
+	return s, nil
+}
+
+// summarizeNumericColumns gives robust statistics for each numeric column of
+// query results, so the agent cites medians and outlier-excluding means
+// rather than averaging data errors. ID columns are skipped.
+func summarizeNumericColumns(rows []map[string]interface{}, columns []string) string {
+	var b strings.Builder
+	for _, col := range columns {
+		lower := strings.ToLower(col)
+		if lower == "ncessch" || lower == "leaid" || strings.HasSuffix(lower, "_id") {
+			continue
+		}
+		var values []float64
+		for _, row := range rows {
+			if v, ok := numericValue(row[col]); ok {
+				values = append(values, v)
+			}
+		}
+		if len(values) < 2 {
+			continue
+		}
+		s := SummarizeRobust(values)
+		fmt.Fprintf(&b, "- %s (%d values): %s, min %s, max %s; %s\n", col, s.Values,
+			s.Summary(2), formatStat(slices.Min(values), 2), formatStat(slices.Max(values), 2), s.Method(2))
+	}
+	if b.Len() == 0 {
+		return ""
+	}
+	return "Column statistics across all rows:\n" + b.String()
+}
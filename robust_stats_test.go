@@ -0,0 +1,71 @@
+package main
+
+import (
+	"math"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestSummarizeRobust(t *testing.T) {
+	s := SummarizeRobust([]float64{12, 10, 100, 11, 13, 12})
+	if s.Median != 12 || s.Q1 != 11.25 || s.Q3 != 12.75 || s.Outliers != 1 || math.Abs(s.Mean-11.6) > 1e-9 {
+		t.Errorf("SummarizeRobust() = %+v", s)
+	}
+	if got := s.Summary(1); got != "median 12.0, mean 11.6" {
+		t.Errorf("Summary() = %q", got)
+	}
+	if got := s.Method(1); got != "mean excludes 1 outlier beyond 1.5×IQR; 10% trimmed mean 26.3" {
+		t.Errorf("Method() = %q", got)
+	}
+	if got := SummarizeRobust(nil).Summary(0); got != "not reported" {
+		t.Errorf("empty Summary() = %q", got)
+	}
+
+	// The agent gets the same statistics for numeric query columns, but not IDs
+	rows := []map[string]interface{}{
+		{"NCESSCH": "01", "students": int64(500)},
+		{"NCESSCH": "02", "students": int64(520)},
+		{"NCESSCH": "03", "students": int64(0)},
+	}
+	stats := summarizeNumericColumns(rows, []string{"NCESSCH", "students"})
+	if strings.Contains(stats, "NCESSCH") || !strings.Contains(stats, "- students (3 values): median 500.00") {
+		t.Errorf("summarizeNumericColumns() = %q", stats)
+	}
+}
+
+func TestSearchResultStatsRobust(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	// Madison K-8 reports 10x its real enrollment
+	if _, err := db.conn.Exec(`UPDATE enrollment SET STUDENT_COUNT = 6800 WHERE NCESSCH = '360000100005' AND TOTAL_INDICATOR = 'Education Unit Total'`); err != nil {
+		t.Fatal(err)
+	}
+	stats, err := db.SearchResultStats(SearchFilters{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := SummarizeRobust([]float64{500, 850, 620, 725, 6800})
+	got := stats.EnrollmentSummary
+	if got.Values != want.Values || got.Outliers != 1 || got.Outliers != want.Outliers ||
+		math.Abs(got.Median-want.Median) > 1e-9 || math.Abs(got.Mean-want.Mean) > 1e-9 || math.Abs(got.TrimmedMean-want.TrimmedMean) > 1e-9 {
+		t.Errorf("SQL enrollment summary = %+v, want %+v", got, want)
+	}
+	if got.Mean != 673.75 {
+		t.Errorf("mean enrollment = %.2f, want 673.75 without the outlier", got.Mean)
+	}
+
+	router := NewRouter(ServerConfig{DB: db})
+	req := httptest.NewRequest("POST", "/search", strings.NewReader(url.Values{"q": {""}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	body := rec.Body.String()
+	for _, want := range []string{"Enrollment: median 725, mean 674", "mean excludes 1 outlier beyond 1.5×IQR"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("results are missing %q", want)
+		}
+	}
+}
@@ -0,0 +1,478 @@
+package main
+
+import (
+	"context"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Role is what a web server user may do. Each role can do everything the
+// roles before it can.
+type Role int
+
+const (
+	RoleViewer Role = iota // Search and read
+	RoleEditor             // Also ask the Data Explorer, scrape websites, import data, and annotate schools
+	RoleAdmin              // Also review suggestions and manage caches and users
+)
+
+// roleNames are the roles as written in users.json, forms, and the API
+var roleNames = []string{"viewer", "editor", "admin"}
+
+func (r Role) String() string {
+	if r < RoleViewer || r > RoleAdmin {
+		return "unknown"
+	}
+	return roleNames[r]
+}
+
+// Allows reports whether the role includes min
+func (r Role) Allows(min Role) bool {
+	return r >= min
+}
+
+// CanEdit reports whether the role may scrape, import, and annotate
+func (r Role) CanEdit() bool {
+	return r.Allows(RoleEditor)
+}
+
+// CanAdmin reports whether the role may manage caches and users
+func (r Role) CanAdmin() bool {
+	return r.Allows(RoleAdmin)
+}
+
+// ParseRole parses a role name such as "editor"
+func ParseRole(name string) (Role, error) {
+	for i, n := range roleNames {
+		if strings.EqualFold(strings.TrimSpace(name), n) {
+			return Role(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown role %q (want viewer, editor, or admin)", name)
+}
+
+func (r Role) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.String())
+}
+
+func (r *Role) UnmarshalJSON(b []byte) error {
+	var name string
+	if err := json.Unmarshal(b, &name); err != nil {
+		return err
+	}
+	role, err := ParseRole(name)
+	if err != nil {
+		return err
+	}
+	*r = role
+	return nil
+}
+
+// pbkdf2Iterations is the PBKDF2-SHA256 work factor for new password hashes;
+// hashes record their own count, so raising it doesn't invalidate old ones
+var pbkdf2Iterations = 600_000
+
+// usernamePattern is what a username may contain
+var usernamePattern = regexp.MustCompile(`^[a-zA-Z0-9._@-]{1,64}$`)
+
+// minPasswordLen is the shortest password a user may be given
+const minPasswordLen = 8
+
+// User is a web server account
+type User struct {
+	Name         string    `json:"name"`
+	Role         Role      `json:"role"`
+	PasswordHash string    `json:"password_hash"`
+	TokenHash    string    `json:"token_hash,omitempty"` // sha256 of the user's API token, for CLI commands run with --server
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// HasToken reports whether the user has been issued an API token
+func (u User) HasToken() bool {
+	return u.TokenHash != ""
+}
+
+// apiTokenPrefix starts every API token, so they're recognizable in config files
+const apiTokenPrefix = "sf_"
+
+// hashToken hashes an API token for storage. Tokens are random, so unlike
+// passwords they don't need a slow, salted hash.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// hashPassword derives a salted PBKDF2-SHA256 hash, written as
+// pbkdf2-sha256$iterations$salt$hash
+func hashPassword(password string) (string, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+	key, err := pbkdf2.Key(sha256.New, password, salt, pbkdf2Iterations, 32)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	enc := base64.RawStdEncoding
+	return fmt.Sprintf("pbkdf2-sha256$%d$%s$%s", pbkdf2Iterations, enc.EncodeToString(salt), enc.EncodeToString(key)), nil
+}
+
+// checkPassword reports whether password matches a hash from hashPassword
+func checkPassword(hash, password string) bool {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 4 || parts[0] != "pbkdf2-sha256" {
+		return false
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations <= 0 {
+		return false
+	}
+	enc := base64.RawStdEncoding
+	salt, err := enc.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	want, err := enc.DecodeString(parts[3])
+	if err != nil || len(want) == 0 {
+		return false
+	}
+	got, err := pbkdf2.Key(sha256.New, password, salt, iterations, len(want))
+	return err == nil && subtle.ConstantTimeCompare(got, want) == 1
+}
+
+// userStore keeps web server accounts in users.json in the data directory,
+// readable only by its owner. Accounts aren't stored in DuckDB because the
+// Data Explorer can read every table there. Successful sign-ins are
+// remembered in memory so requests don't each pay for PBKDF2.
+type userStore struct {
+	path string // Empty keeps accounts in memory only
+
+	mu       sync.RWMutex
+	users    map[string]User
+	verified map[[32]byte]Role // sha256 of name and password for recent sign-ins
+}
+
+// loadUserStore reads the accounts in path, which needn't exist yet
+func loadUserStore(path string) (*userStore, error) {
+	s := &userStore{path: path, users: make(map[string]User), verified: make(map[[32]byte]Role)}
+	if path == "" {
+		return s, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read users: %w", err)
+	}
+	var users []User
+	if err := json.Unmarshal(data, &users); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	for _, u := range users {
+		s.users[strings.ToLower(u.Name)] = u
+	}
+	return s, nil
+}
+
+// save writes the accounts to disk; callers hold mu
+func (s *userStore) save() error {
+	if s.path == "" {
+		return nil
+	}
+	users := make([]User, 0, len(s.users))
+	for _, u := range s.users {
+		users = append(users, u)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Name < users[j].Name })
+	data, err := json.MarshalIndent(users, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode users: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".users-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to save users: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save users: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save users: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save users: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to save users: %w", err)
+	}
+	return nil
+}
+
+// Len is the number of accounts
+func (s *userStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.users)
+}
+
+// List returns the accounts by name
+func (s *userStore) List() []User {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	users := make([]User, 0, len(s.users))
+	for _, u := range s.users {
+		users = append(users, u)
+	}
+	sort.Slice(users, func(i, j int) bool { return strings.ToLower(users[i].Name) < strings.ToLower(users[j].Name) })
+	return users
+}
+
+// IsLastAdmin reports whether name is the only admin account
+func (s *userStore) IsLastAdmin(name string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	u, ok := s.users[strings.ToLower(name)]
+	if !ok || u.Role != RoleAdmin {
+		return false
+	}
+	for _, other := range s.users {
+		if other.Role == RoleAdmin && other.Name != u.Name {
+			return false
+		}
+	}
+	return true
+}
+
+// Save creates an account or updates one's role and, unless password is
+// empty, its password
+func (s *userStore) Save(name, password string, role Role) error {
+	if !usernamePattern.MatchString(name) {
+		return fmt.Errorf("usernames are 1-64 letters, digits, and . _ @ -")
+	}
+	if strings.EqualFold(name, adminUsername) {
+		return fmt.Errorf("%q is reserved for SCHOOLFINDER_ADMIN_PASSWORD", adminUsername)
+	}
+	if role < RoleViewer || role > RoleAdmin {
+		return fmt.Errorf("unknown role %d", role)
+	}
+
+	var hash string
+	if password != "" {
+		if len(password) < minPasswordLen {
+			return fmt.Errorf("passwords must be at least %d characters", minPasswordLen)
+		}
+		var err error
+		if hash, err = hashPassword(password); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := strings.ToLower(name)
+	u, exists := s.users[key]
+	if !exists {
+		if hash == "" {
+			return fmt.Errorf("new users need a password")
+		}
+		u = User{Name: name, CreatedAt: time.Now().UTC()}
+	}
+	u.Role = role
+	if hash != "" {
+		u.PasswordHash = hash
+	}
+	s.users[key] = u
+	clear(s.verified)
+	return s.save()
+}
+
+// Delete removes an account, reporting whether it existed
+func (s *userStore) Delete(name string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := strings.ToLower(name)
+	if _, ok := s.users[key]; !ok {
+		return false, nil
+	}
+	delete(s.users, key)
+	clear(s.verified)
+	return true, s.save()
+}
+
+// IssueToken gives an account a new API token, replacing any it had, and
+// returns it. Only its hash is kept, so it can't be shown again.
+func (s *userStore) IssueToken(name string) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	token := apiTokenPrefix + hex.EncodeToString(b)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := strings.ToLower(name)
+	u, ok := s.users[key]
+	if !ok {
+		return "", fmt.Errorf("no user named %q", name)
+	}
+	u.TokenHash = hashToken(token)
+	s.users[key] = u
+	if err := s.save(); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// AuthenticateToken returns the role of the account an API token belongs to
+func (s *userStore) AuthenticateToken(token string) (Role, bool) {
+	if !strings.HasPrefix(token, apiTokenPrefix) {
+		return 0, false
+	}
+	hash := []byte(hashToken(token))
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, u := range s.users {
+		if u.TokenHash != "" && subtle.ConstantTimeCompare([]byte(u.TokenHash), hash) == 1 {
+			return u.Role, true
+		}
+	}
+	return 0, false
+}
+
+// Authenticate returns the role of the account name signs in to with password
+func (s *userStore) Authenticate(name, password string) (Role, bool) {
+	sum := sha256.Sum256([]byte(strings.ToLower(name) + "\x00" + password))
+	s.mu.RLock()
+	role, ok := s.verified[sum]
+	u, exists := s.users[strings.ToLower(name)]
+	s.mu.RUnlock()
+	if ok {
+		return role, true
+	}
+	if !exists || !checkPassword(u.PasswordHash, password) {
+		return 0, false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// The account may have changed while the password was checked
+	if current, ok := s.users[strings.ToLower(name)]; !ok || current.PasswordHash != u.PasswordHash {
+		return 0, false
+	}
+	s.verified[sum] = u.Role
+	return u.Role, true
+}
+
+// adminUsername signs in with SCHOOLFINDER_ADMIN_PASSWORD as an admin
+const adminUsername = "admin"
+
+// signInRealm names the credentials browsers prompt for
+const signInRealm = "School Finder"
+
+// access decides what each web request may do. With neither an admin
+// password nor accounts the server is single-user and everyone is an admin;
+// otherwise visitors who haven't signed in are viewers.
+type access struct {
+	adminPassword string
+	users         *userStore
+}
+
+// multiUser reports whether requests need to sign in for more than viewing
+func (a *access) multiUser() bool {
+	return a.adminPassword != "" || a.users.Len() > 0
+}
+
+type roleContextKey struct{}
+
+// requestAccess is the role a request was identified with and whether it signed in
+type requestAccess struct {
+	role     Role
+	signedIn bool
+}
+
+// requestRole is the role identify gave r; requests it hasn't seen are admins,
+// as in single-user mode
+func requestRole(r *http.Request) Role {
+	if ra, ok := r.Context().Value(roleContextKey{}).(requestAccess); ok {
+		return ra.role
+	}
+	return RoleAdmin
+}
+
+// requestSignedIn reports whether r signed in with valid credentials
+func requestSignedIn(r *http.Request) bool {
+	ra, _ := r.Context().Value(roleContextKey{}).(requestAccess)
+	return ra.signedIn
+}
+
+// identify records each request's role for handlers and requireRole, from
+// HTTP Basic credentials or a Bearer API token if it sent either
+func (a *access) identify(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ra := requestAccess{role: RoleViewer}
+		if !a.multiUser() {
+			ra.role = RoleAdmin
+		}
+		if name, password, ok := r.BasicAuth(); ok {
+			if role, ok := a.authenticate(name, password); ok {
+				ra = requestAccess{role: role, signedIn: true}
+			}
+		} else if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+			if role, ok := a.users.AuthenticateToken(strings.TrimSpace(token)); ok {
+				ra = requestAccess{role: role, signedIn: true}
+			}
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), roleContextKey{}, ra)))
+	})
+}
+
+// authenticate checks a username and password against the admin password
+// and the accounts
+func (a *access) authenticate(name, password string) (Role, bool) {
+	if a.adminPassword != "" && name == adminUsername {
+		ok := subtle.ConstantTimeCompare([]byte(password), []byte(a.adminPassword)) == 1
+		return RoleAdmin, ok
+	}
+	return a.users.Authenticate(name, password)
+}
+
+// requireRole rejects requests whose role doesn't include min with reject:
+// ErrSignInRequired, with a sign-in challenge, if they haven't signed in and
+// ErrForbidden if they have
+func (a *access) requireRole(min Role, reject func(http.ResponseWriter, *http.Request, *UserError)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ra, ok := r.Context().Value(roleContextKey{}).(requestAccess)
+			if !ok || ra.role.Allows(min) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if !ra.signedIn {
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf("Basic realm=%q, charset=\"UTF-8\"", signInRealm))
+				reject(w, r, ErrSignInRequired)
+				return
+			}
+			reject(w, r, ErrForbidden)
+		})
+	}
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fastHashing makes password hashing cheap for the test's duration
+func fastHashing(t *testing.T) {
+	saved := pbkdf2Iterations
+	pbkdf2Iterations = 1000
+	t.Cleanup(func() { pbkdf2Iterations = saved })
+}
+
+func TestRoles(t *testing.T) {
+	for _, name := range []string{"viewer", "Editor", " admin "} {
+		role, err := ParseRole(name)
+		if err != nil || role.String() != strings.ToLower(strings.TrimSpace(name)) {
+			t.Errorf("ParseRole(%q) = %v, %v", name, role, err)
+		}
+	}
+	if _, err := ParseRole("owner"); err == nil {
+		t.Error("unknown role parsed")
+	}
+	if RoleViewer.CanEdit() || !RoleEditor.CanEdit() || RoleEditor.CanAdmin() || !RoleAdmin.CanAdmin() {
+		t.Error("role permissions are wrong")
+	}
+
+	b, _ := json.Marshal(RoleEditor)
+	var role Role
+	if string(b) != `"editor"` || json.Unmarshal(b, &role) != nil || role != RoleEditor {
+		t.Errorf("JSON round trip: %s -> %v", b, role)
+	}
+}
+
+func TestPasswordHashing(t *testing.T) {
+	fastHashing(t)
+	hash, err := hashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(hash, "pbkdf2-sha256$1000$") {
+		t.Errorf("hash = %q", hash)
+	}
+	if !checkPassword(hash, "correct horse") {
+		t.Error("correct password rejected")
+	}
+	if checkPassword(hash, "correct horse!") || checkPassword("", "") || checkPassword("plain", "plain") {
+		t.Error("wrong password accepted")
+	}
+}
+
+func TestUserStore(t *testing.T) {
+	fastHashing(t)
+	path := filepath.Join(t.TempDir(), "users.json")
+	store, err := loadUserStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Save("ada", "short", RoleEditor); err == nil {
+		t.Error("short password accepted")
+	}
+	if err := store.Save("admin", "long enough", RoleAdmin); err == nil {
+		t.Error("reserved name accepted")
+	}
+	if err := store.Save("ada", "", RoleEditor); err == nil {
+		t.Error("new user without a password accepted")
+	}
+	if err := store.Save("ada", "analytical", RoleEditor); err != nil {
+		t.Fatal(err)
+	}
+	if role, ok := store.Authenticate("Ada", "analytical"); !ok || role != RoleEditor {
+		t.Errorf("Authenticate = %v, %v", role, ok)
+	}
+
+	// Changing the role without a password keeps the old one, and the
+	// remembered sign-in doesn't keep the old role
+	if err := store.Save("ada", "", RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	if role, ok := store.Authenticate("ada", "analytical"); !ok || role != RoleAdmin {
+		t.Errorf("Authenticate after role change = %v, %v", role, ok)
+	}
+	if _, ok := store.Authenticate("ada", "wrong password"); ok {
+		t.Error("wrong password accepted")
+	}
+	if !store.IsLastAdmin("ada") {
+		t.Error("ada should be the last admin")
+	}
+
+	info, err := os.Stat(path)
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("users.json mode = %v, %v", info, err)
+	}
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "analytical") {
+		t.Error("password saved in plain text")
+	}
+
+	reloaded, err := loadUserStore(path)
+	if err != nil || reloaded.Len() != 1 {
+		t.Fatalf("reloaded %d users, %v", reloaded.Len(), err)
+	}
+	if role, ok := reloaded.Authenticate("ada", "analytical"); !ok || role != RoleAdmin {
+		t.Errorf("Authenticate after reload = %v, %v", role, ok)
+	}
+
+	if deleted, err := reloaded.Delete("ADA"); !deleted || err != nil {
+		t.Errorf("Delete = %v, %v", deleted, err)
+	}
+	if _, ok := reloaded.Authenticate("ada", "analytical"); ok {
+		t.Error("deleted user signed in")
+	}
+}
+
+func TestRolePermissions(t *testing.T) {
+	fastHashing(t)
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	// Without accounts or an admin password everyone is an admin
+	single := NewRouter(ServerConfig{DB: db})
+	rec := httptest.NewRecorder()
+	single.ServeHTTP(rec, httptest.NewRequest("GET", "/api/me", nil))
+	if !strings.Contains(rec.Body.String(), `"role":"admin"`) {
+		t.Errorf("single-user /api/me = %s", rec.Body.String())
+	}
+
+	users, _ := loadUserStore("")
+	for name, role := range map[string]Role{"vera": RoleViewer, "eddie": RoleEditor, "ada": RoleAdmin} {
+		if err := users.Save(name, name+"-password", role); err != nil {
+			t.Fatal(err)
+		}
+	}
+	router := NewRouter(ServerConfig{DB: db, Users: users})
+	do := func(method, path, user string, form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("HX-Request", "true")
+		if user != "" {
+			req.SetBasicAuth(user, user+"-password")
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+	corrections := url.Values{"city": {"Oakland"}}
+
+	rec = do("POST", "/schools/360000100001/corrections", "", corrections)
+	if rec.Code != http.StatusUnauthorized || !strings.Contains(rec.Header().Get("WWW-Authenticate"), "Basic") {
+		t.Errorf("anonymous correction: status %d, challenge %q", rec.Code, rec.Header().Get("WWW-Authenticate"))
+	}
+	if rec := do("POST", "/schools/360000100001/corrections", "vera", corrections); rec.Code != http.StatusForbidden {
+		t.Errorf("viewer correction: status %d", rec.Code)
+	}
+	if rec := do("GET", "/admin/users", "eddie", nil); rec.Code != http.StatusForbidden {
+		t.Errorf("editor /admin/users: status %d", rec.Code)
+	}
+	if rec := do("GET", "/admin/users", "ada", nil); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "eddie") {
+		t.Errorf("admin /admin/users: status %d", rec.Code)
+	}
+
+	// The Data Explorer runs model-written SQL, so viewers can't use it
+	if rec := do("POST", "/agent/query", "", url.Values{"query": {"schools in CA"}}); rec.Code != http.StatusUnauthorized {
+		t.Errorf("anonymous /agent/query: status %d", rec.Code)
+	}
+	for _, path := range []string{"/agent", "/agent/export/abc"} {
+		if rec := do("GET", path, "vera", nil); rec.Code != http.StatusForbidden {
+			t.Errorf("viewer %s: status %d", path, rec.Code)
+		}
+	}
+	if rec := do("GET", "/agent", "eddie", nil); rec.Code != http.StatusOK {
+		t.Errorf("editor /agent: status %d", rec.Code)
+	}
+
+	// AI writing spends the owner's Anthropic budget, so it's for editors too
+	for _, path := range []string{"/schools/360000100001/summary", "/compare/narrative"} {
+		if rec := do("POST", path, "vera", url.Values{"ids": {"360000100001,360000100002"}}); rec.Code != http.StatusForbidden {
+			t.Errorf("viewer %s: status %d", path, rec.Code)
+		}
+		if rec := do("POST", path, "eddie", url.Values{"ids": {"360000100001,360000100002"}}); !strings.Contains(rec.Body.String(), ErrCSRFMismatch.Message) {
+			t.Errorf("editor %s without a CSRF token: %s", path, rec.Body.String())
+		}
+	}
+	if body := do("GET", "/schools/360000100001", "vera", nil).Body.String(); !strings.Contains(body, "Sign In to Summarize") {
+		t.Error("viewer detail page doesn't offer to sign in to summarize")
+	}
+
+	// So are the family's children, applications, outreach, and timeline
+	for _, path := range []string{"/children", "/outreach", "/applications", "/applications/recap.md", "/timeline.ics", "/timeline.csv"} {
+		if rec := do("GET", path, "", nil); rec.Code != http.StatusUnauthorized {
+			t.Errorf("anonymous %s: status %d", path, rec.Code)
+		}
+		if rec := do("GET", path, "vera", nil); rec.Code != http.StatusForbidden {
+			t.Errorf("viewer %s: status %d", path, rec.Code)
+		}
+		if rec := do("GET", path, "eddie", nil); rec.Code != http.StatusOK {
+			t.Errorf("editor %s: status %d", path, rec.Code)
+		}
+	}
+
+	// Another site can't make a signed-in browser change data
+	forge := func(path, user string, header, value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, strings.NewReader(corrections.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set(header, value)
+		req.SetBasicAuth(user, user+"-password")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+	if rec := forge("/schools/360000100001/corrections", "eddie", "Origin", "https://evil.example"); rec.Code != http.StatusForbidden {
+		t.Errorf("cross-origin correction: status %d", rec.Code)
+	}
+	if rec := forge("/admin/corrections/1/approve", "ada", "Sec-Fetch-Site", "cross-site"); rec.Code != http.StatusForbidden {
+		t.Errorf("cross-site approval: status %d", rec.Code)
+	}
+	if rec := forge("/schools/360000100001/corrections", "eddie", "Sec-Fetch-Site", "same-origin"); rec.Code != http.StatusSeeOther {
+		t.Errorf("same-origin correction: status %d", rec.Code)
+	}
+	if rec := forge("/schools/360000100001/corrections", "eddie", "Origin", "http://example.com"); rec.Code != http.StatusSeeOther {
+		t.Errorf("correction from the server's own origin: status %d", rec.Code)
+	}
+
+	var me struct {
+		Role     Role `json:"role"`
+		SignedIn bool `json:"signed_in"`
+		CanEdit  bool `json:"can_edit"`
+	}
+	if err := json.NewDecoder(do("GET", "/api/me", "eddie", nil).Body).Decode(&me); err != nil || me.Role != RoleEditor || !me.SignedIn || !me.CanEdit {
+		t.Errorf("editor /api/me = %+v, %v", me, err)
+	}
+	// Wrong passwords are treated as not signing in
+	req := httptest.NewRequest("GET", "/api/me", nil)
+	req.SetBasicAuth("ada", "guess")
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if !strings.Contains(rec.Body.String(), `"role":"viewer"`) || !strings.Contains(rec.Body.String(), `"signed_in":false`) {
+		t.Errorf("bad password /api/me = %s", rec.Body.String())
+	}
+
+	// Viewers aren't offered actions they can't take
+	if body := do("GET", "/schools/360000100001", "vera", nil).Body.String(); !strings.Contains(body, "Sign In to Extract") {
+		t.Error("viewer detail page doesn't offer to sign in")
+	}
+	if body := do("GET", "/schools/360000100001", "eddie", nil).Body.String(); strings.Contains(body, "Sign In to Extract") {
+		t.Error("editor detail page asks to sign in")
+	}
+}
+
+func TestUserAdminLockout(t *testing.T) {
+	fastHashing(t)
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	users, _ := loadUserStore("")
+	if err := users.Save("ada", "ada-password", RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	h := NewWebHandler(db, nil, nil)
+	h.access = &access{users: users}
+	if !h.lockoutRisk("ada") {
+		t.Error("removing the only admin isn't a lockout risk")
+	}
+	h.access.adminPassword = "secret"
+	if h.lockoutRisk("ada") {
+		t.Error("the admin password still signs in")
+	}
+}
+
+func TestClearCache(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	if err := db.SaveAIScraperCache("360000100001", "Lincoln Elementary School", "https://lincoln.sfusd.edu", "# Lincoln", []byte(`{}`), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	sizes, err := db.CacheSizes()
+	if err != nil || len(sizes) != len(managedCaches) || sizes[0].Entries != 1 {
+		t.Fatalf("CacheSizes = %+v, %v", sizes, err)
+	}
+
+	n, err := db.ClearCache("ai_scraper_cache")
+	if err != nil || n != 1 {
+		t.Errorf("ClearCache = %d, %v", n, err)
+	}
+	if _, _, _, _, _, err := db.LoadAIScraperCache("360000100001", time.Hour); err == nil {
+		t.Error("cleared entry still cached")
+	}
+	if _, err := db.ClearCache("directory"); err == nil {
+		t.Error("cleared a table that isn't a cache")
+	}
+}
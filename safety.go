@@ -0,0 +1,460 @@
+package main
+
+import (
+	"cmp"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// State safety and discipline reports have no common layout, so each file is
+// joined to CCD schools by whichever of its columns holds a school key
+const (
+	safetyKeyNCESSCH = "ncessch"  // 12-digit NCES school ID
+	safetyKeyStateID = "state_id" // State school ID, compared digits-only with CCD's ST_SCHID
+	safetyKeyName    = "name"     // Exact school name, where only one school has it
+)
+
+var safetyKeyLabels = map[string]string{
+	safetyKeyNCESSCH: "NCES school ID",
+	safetyKeyStateID: "state school ID",
+	safetyKeyName:    "school name",
+}
+
+// minSafetyMatchRate is the share of rows a key column must match to be used
+// without naming it explicitly
+const minSafetyMatchRate = 0.5
+
+var (
+	// safetyYearColumn names a column holding each row's school year
+	safetyYearColumn = regexp.MustCompile(`(?i)^(school[ _]?year|academic[ _]?year|year)$`)
+	// safetyIDColumn names columns that hold codes rather than counts
+	safetyIDColumn = regexp.MustCompile(`(?i)(^|[ _])(id|code|cds|nces\w*|leaid|zip|phone|schid)($|[ _])`)
+)
+
+// SafetyJoin is how a safety file's rows map to CCD schools
+type SafetyJoin struct {
+	Column  string // The file's key column
+	KeyType string // safetyKeyNCESSCH, safetyKeyStateID, or safetyKeyName
+	Matched int    // Rows matching exactly one school
+	Rows    int
+}
+
+// MatchRate is the share of rows matched to a school
+func (j SafetyJoin) MatchRate() float64 {
+	if j.Rows == 0 {
+		return 0
+	}
+	return float64(j.Matched) / float64(j.Rows)
+}
+
+// String describes the join, e.g. `"CDS Code" as state school ID (412 of 430 rows, 96%)`
+func (j SafetyJoin) String() string {
+	return fmt.Sprintf("%q as %s (%d of %d rows, %.0f%%)", j.Column, safetyKeyLabels[j.KeyType], j.Matched, j.Rows, j.MatchRate()*100)
+}
+
+// SafetyImportOptions describes a state safety report to import
+type SafetyImportOptions struct {
+	Source    string            // Who published the data, e.g. a department of education report name or URL
+	Year      string            // School year for files without a year column, e.g. "2022-23"
+	State     string            // Limits name matching to one state
+	KeyColumn string            // The key column, when it shouldn't be detected
+	KeyType   string            // The key column's type, with KeyColumn
+	Filters   map[string]string // Column values rows must have, e.g. a total subgroup
+	DryRun    bool              // Report the join and metrics without saving
+}
+
+// SafetyImport is the result of importing a safety report
+type SafetyImport struct {
+	Filename   string
+	Join       SafetyJoin
+	Candidates []SafetyJoin // Other key columns considered, best first
+	Metrics    []string
+	Years      []string
+	Values     int // Values saved
+}
+
+// quoteIdent quotes a column name for SQL
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// safetyKeyCondition returns the condition joining a file column on s to directory d
+func safetyKeyCondition(keyType, column string) string {
+	digits := fmt.Sprintf("regexp_replace(s.%s, '[^0-9]', '', 'g')", quoteIdent(column))
+	switch keyType {
+	case safetyKeyNCESSCH:
+		return fmt.Sprintf("length(%s) BETWEEN 11 AND 12 AND d.NCESSCH = LPAD(%s, 12, '0')", digits, digits)
+	case safetyKeyStateID:
+		return fmt.Sprintf("length(%s) >= 6 AND regexp_replace(d.ST_SCHID, '[^0-9]', '', 'g') = %s", digits, digits)
+	default:
+		return fmt.Sprintf("LOWER(TRIM(s.%s)) = LOWER(TRIM(d.SCH_NAME))", quoteIdent(column))
+	}
+}
+
+// matchSafetyKeys fills safety_keys with the import rows that match exactly one
+// school by a key column, and returns how many matched
+func matchSafetyKeys(tx *sql.Tx, keyType, column, state string) (int, error) {
+	if _, err := tx.Exec(`DELETE FROM safety_keys`); err != nil {
+		return 0, fmt.Errorf("failed to clear safety keys: %w", err)
+	}
+	var args []interface{}
+	stateCondition := ""
+	if state != "" {
+		args = append(args, state)
+		stateCondition = "AND d.ST = $1"
+	}
+	result, err := tx.Exec(fmt.Sprintf(`
+		INSERT INTO safety_keys
+		SELECT s.row_id_, any_value(d.NCESSCH) FROM safety_import s
+		JOIN directory d ON %s %s
+		GROUP BY s.row_id_ HAVING count(*) = 1
+	`, safetyKeyCondition(keyType, column), stateCondition), args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to match %q to schools: %w", column, err)
+	}
+	n, _ := result.RowsAffected()
+	return int(n), nil
+}
+
+// suggestSafetyJoins tries every column as each kind of school key and returns
+// those that matched any rows, best first
+func suggestSafetyJoins(tx *sql.Tx, columns []string, rows int, state string, hasStateIDs bool) ([]SafetyJoin, error) {
+	var joins []SafetyJoin
+	for _, column := range columns {
+		for _, keyType := range []string{safetyKeyNCESSCH, safetyKeyStateID, safetyKeyName} {
+			if keyType == safetyKeyStateID && !hasStateIDs {
+				continue
+			}
+			matched, err := matchSafetyKeys(tx, keyType, column, state)
+			if err != nil {
+				return nil, err
+			}
+			if matched > 0 {
+				joins = append(joins, SafetyJoin{Column: column, KeyType: keyType, Matched: matched, Rows: rows})
+			}
+		}
+	}
+	slices.SortStableFunc(joins, func(a, b SafetyJoin) int {
+		return cmp.Compare(b.Matched, a.Matched)
+	})
+	return joins, nil
+}
+
+// ImportSafetyFile imports a state-published school incident or discipline
+// report. Its rows are joined to schools by a detected (or given) key column,
+// and each numeric column is saved as a metric for the row's school year.
+func ImportSafetyFile(db *DB, path string, opts SafetyImportOptions) (*SafetyImport, error) {
+	opts.Source = strings.TrimSpace(opts.Source)
+	if opts.Source == "" {
+		return nil, fmt.Errorf("safety data needs a source, such as the report name or URL")
+	}
+	if opts.KeyType != "" && safetyKeyLabels[opts.KeyType] == "" {
+		return nil, fmt.Errorf("invalid key type %q (use %s, %s, or %s)", opts.KeyType, safetyKeyNCESSCH, safetyKeyStateID, safetyKeyName)
+	}
+	imp := &SafetyImport{Filename: filepath.Base(path)}
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.Exec(fmt.Sprintf(`
+		CREATE OR REPLACE TEMP TABLE safety_import AS
+		SELECT row_number() OVER () AS row_id_, * FROM read_csv('%s', all_varchar=true)
+	`, path)); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", imp.Filename, err)
+	}
+	if _, err := tx.Exec(`CREATE OR REPLACE TEMP TABLE safety_keys (row_id BIGINT, ncessch VARCHAR)`); err != nil {
+		return nil, fmt.Errorf("failed to create safety keys: %w", err)
+	}
+	columns, err := csvColumns(tx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep only the rows asked for, e.g. the total for all students
+	for column, value := range opts.Filters {
+		if !slices.Contains(columns, column) {
+			return nil, fmt.Errorf("no column %q to filter on", column)
+		}
+		if _, err := tx.Exec(fmt.Sprintf(`DELETE FROM safety_import WHERE COALESCE(TRIM(%s), '') <> $1`, quoteIdent(column)), value); err != nil {
+			return nil, fmt.Errorf("failed to filter rows: %w", err)
+		}
+	}
+	var rows int
+	if err := tx.QueryRow(`SELECT count(*) FROM safety_import`).Scan(&rows); err != nil {
+		return nil, fmt.Errorf("failed to count rows: %w", err)
+	}
+	if rows == 0 {
+		return nil, fmt.Errorf("%s has no rows to import", imp.Filename)
+	}
+
+	// Find the key column, then match with it
+	var hasStateIDs int
+	if err := tx.QueryRow(`SELECT count(*) FROM duckdb_columns() WHERE table_name = 'directory' AND column_name = 'ST_SCHID'`).Scan(&hasStateIDs); err != nil {
+		return nil, fmt.Errorf("failed to check directory columns: %w", err)
+	}
+	switch {
+	case opts.KeyColumn != "":
+		if !slices.Contains(columns, opts.KeyColumn) {
+			return nil, fmt.Errorf("no column %q in %s", opts.KeyColumn, imp.Filename)
+		}
+		if opts.KeyType == "" {
+			return nil, fmt.Errorf("a key column needs a key type (%s, %s, or %s)", safetyKeyNCESSCH, safetyKeyStateID, safetyKeyName)
+		}
+		if opts.KeyType == safetyKeyStateID && hasStateIDs == 0 {
+			return nil, fmt.Errorf("the CCD directory has no state school IDs (ST_SCHID) to match")
+		}
+		imp.Join = SafetyJoin{Column: opts.KeyColumn, KeyType: opts.KeyType, Rows: rows}
+	default:
+		imp.Candidates, err = suggestSafetyJoins(tx, columns, rows, opts.State, hasStateIDs > 0)
+		if err != nil {
+			return nil, err
+		}
+		if len(imp.Candidates) == 0 || imp.Candidates[0].MatchRate() < minSafetyMatchRate {
+			return imp, fmt.Errorf("no column of %s matches at least %.0f%% of rows to schools; name one with a key column and type", imp.Filename, minSafetyMatchRate*100)
+		}
+		imp.Join = imp.Candidates[0]
+	}
+	if imp.Join.Matched, err = matchSafetyKeys(tx, imp.Join.KeyType, imp.Join.Column, opts.State); err != nil {
+		return nil, err
+	}
+
+	// The school year comes from a year column, or applies to the whole file
+	yearExpr := ""
+	for _, column := range columns {
+		if safetyYearColumn.MatchString(column) {
+			yearExpr = "TRIM(s." + quoteIdent(column) + ")"
+			break
+		}
+	}
+	if yearExpr == "" {
+		year := strings.TrimSpace(opts.Year)
+		if year == "" {
+			return nil, fmt.Errorf("%s has no year column, so it needs a school year", imp.Filename)
+		}
+		yearExpr = "'" + strings.ReplaceAll(year, "'", "''") + "'"
+	}
+
+	// Each school needs one row per year, or its values would be ambiguous
+	var duplicated int
+	if err := tx.QueryRow(fmt.Sprintf(`
+		SELECT count(*) FROM (
+			SELECT k.ncessch FROM safety_import s JOIN safety_keys k ON k.row_id = s.row_id_
+			GROUP BY k.ncessch, %s HAVING count(*) > 1
+		)
+	`, yearExpr)).Scan(&duplicated); err != nil {
+		return nil, fmt.Errorf("failed to check for repeated schools: %w", err)
+	}
+	if duplicated > 0 {
+		return imp, fmt.Errorf("%d schools have more than one row per year; filter to one row per school, e.g. the total for all students", duplicated)
+	}
+
+	// Numeric columns other than keys and codes are metrics
+	for _, column := range columns {
+		if column == imp.Join.Column || safetyYearColumn.MatchString(column) || safetyIDColumn.MatchString(column) || opts.Filters[column] != "" {
+			continue
+		}
+		var values, numeric int
+		if err := tx.QueryRow(fmt.Sprintf(`
+			SELECT count(NULLIF(TRIM(c), '')), count(TRY_CAST(replace(c, ',', '') AS DOUBLE))
+			FROM (SELECT %s AS c FROM safety_import)
+		`, quoteIdent(column))).Scan(&values, &numeric); err != nil {
+			return nil, fmt.Errorf("failed to check column %q: %w", column, err)
+		}
+		// Suppressed counts such as "*" or "<10" are skipped, but most values must be numbers
+		if numeric > 0 && float64(numeric) >= 0.5*float64(values) {
+			imp.Metrics = append(imp.Metrics, column)
+		}
+	}
+	if len(imp.Metrics) == 0 {
+		return imp, fmt.Errorf("%s has no numeric columns to import", imp.Filename)
+	}
+
+	yearRows, err := tx.Query(fmt.Sprintf(`SELECT DISTINCT %s AS year FROM safety_import s ORDER BY year`, yearExpr))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list school years: %w", err)
+	}
+	for yearRows.Next() {
+		var year sql.NullString
+		if err := yearRows.Scan(&year); err != nil {
+			yearRows.Close()
+			return nil, fmt.Errorf("failed to scan school year: %w", err)
+		}
+		if year.String != "" {
+			imp.Years = append(imp.Years, year.String)
+		}
+	}
+	yearRows.Close()
+
+	if opts.DryRun {
+		return imp, nil
+	}
+
+	// Re-importing a file replaces what it loaded before
+	if _, err := tx.Exec(`DELETE FROM school_safety WHERE filename = $1`, imp.Filename); err != nil {
+		return nil, fmt.Errorf("failed to clear previous import: %w", err)
+	}
+	for _, metric := range imp.Metrics {
+		value := fmt.Sprintf("TRY_CAST(replace(s.%s, ',', '') AS DOUBLE)", quoteIdent(metric))
+		result, err := tx.Exec(fmt.Sprintf(`
+			INSERT OR REPLACE INTO school_safety (ncessch, school_year, metric, value, source, filename)
+			SELECT k.ncessch, %s, $1, %s, $2, $3
+			FROM safety_import s JOIN safety_keys k ON k.row_id = s.row_id_
+			WHERE %s IS NOT NULL AND NULLIF(%s, '') IS NOT NULL
+		`, yearExpr, value, value, yearExpr), metric, opts.Source, imp.Filename)
+		if err != nil {
+			return nil, fmt.Errorf("failed to save %q: %w", metric, err)
+		}
+		n, _ := result.RowsAffected()
+		imp.Values += int(n)
+	}
+	if _, err := tx.Exec(`
+		INSERT OR REPLACE INTO safety_files (filename, source, key_column, key_type, matched, rows, loaded_at)
+		VALUES ($1, $2, $3, $4, $5, $6, CURRENT_TIMESTAMP)
+	`, imp.Filename, opts.Source, imp.Join.Column, imp.Join.KeyType, imp.Join.Matched, imp.Join.Rows); err != nil {
+		return nil, fmt.Errorf("failed to record safety file: %w", err)
+	}
+	if _, err := tx.Exec(`DROP TABLE safety_import; DROP TABLE safety_keys`); err != nil {
+		return nil, fmt.Errorf("failed to clean up import: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to save safety data: %w", err)
+	}
+	if logger != nil {
+		logger.Info("Safety data imported", "file", imp.Filename, "values", imp.Values)
+	}
+	return imp, nil
+}
+
+// SafetyFile is an imported safety report
+type SafetyFile struct {
+	Filename string
+	Source   string
+	Join     SafetyJoin
+	LoadedAt time.Time
+}
+
+// SafetyFiles lists imported safety reports, newest first
+func (d *DB) SafetyFiles() ([]SafetyFile, error) {
+	rows, err := d.conn.Query(`SELECT filename, source, key_column, key_type, matched, rows, loaded_at FROM safety_files ORDER BY loaded_at DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load safety files: %w", err)
+	}
+	defer rows.Close()
+
+	var files []SafetyFile
+	for rows.Next() {
+		var f SafetyFile
+		if err := rows.Scan(&f.Filename, &f.Source, &f.Join.Column, &f.Join.KeyType, &f.Join.Matched, &f.Join.Rows, &f.LoadedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan safety file: %w", err)
+		}
+		files = append(files, f)
+	}
+	return files, rows.Err()
+}
+
+// DeleteSafetyFile removes an imported safety report and its values
+func (d *DB) DeleteSafetyFile(filename string) error {
+	result, err := d.conn.Exec(`DELETE FROM safety_files WHERE filename = $1`, filename)
+	if err != nil {
+		return fmt.Errorf("failed to delete safety file: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("no safety file named %s", filename)
+	}
+	if _, err := d.conn.Exec(`DELETE FROM school_safety WHERE filename = $1`, filename); err != nil {
+		return fmt.Errorf("failed to delete safety data: %w", err)
+	}
+	return nil
+}
+
+// SafetyValue is one year's value of a safety metric
+type SafetyValue struct {
+	Year  string
+	Value float64
+}
+
+// SafetyMetric is a school's safety metric over the years it was reported
+type SafetyMetric struct {
+	Name   string
+	Values []SafetyValue // Oldest first
+}
+
+// Latest is the most recent value
+func (m SafetyMetric) Latest() SafetyValue {
+	return m.Values[len(m.Values)-1]
+}
+
+// Change describes the change from the previous year, e.g. "+3 (+25%) from
+// 2021-22", or "" with only one year
+func (m SafetyMetric) Change() string {
+	if len(m.Values) < 2 {
+		return ""
+	}
+	prev, latest := m.Values[len(m.Values)-2], m.Latest()
+	change := fmt.Sprintf("%+g", latest.Value-prev.Value)
+	if prev.Value != 0 {
+		change += fmt.Sprintf(" (%+.0f%%)", (latest.Value-prev.Value)/prev.Value*100)
+	}
+	return change + " from " + prev.Year
+}
+
+// Trend is "up", "down", or "flat" from the previous year, for styling
+func (m SafetyMetric) Trend() string {
+	if len(m.Values) < 2 {
+		return "flat"
+	}
+	switch diff := m.Latest().Value - m.Values[len(m.Values)-2].Value; {
+	case diff > 0:
+		return "up"
+	case diff < 0:
+		return "down"
+	default:
+		return "flat"
+	}
+}
+
+// SafetySource is a school's metrics from one published report
+type SafetySource struct {
+	Source  string
+	Metrics []SafetyMetric
+}
+
+// SchoolSafety loads a school's imported safety metrics, grouped by source
+func (d *DB) SchoolSafety(ncessch string) ([]SafetySource, error) {
+	rows, err := d.conn.Query(`
+		SELECT source, metric, school_year, value FROM school_safety
+		WHERE ncessch = $1 ORDER BY source, metric, school_year
+	`, ncessch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load safety data: %w", err)
+	}
+	defer rows.Close()
+
+	var sources []SafetySource
+	for rows.Next() {
+		var source, name string
+		var v SafetyValue
+		if err := rows.Scan(&source, &name, &v.Year, &v.Value); err != nil {
+			return nil, fmt.Errorf("failed to scan safety data: %w", err)
+		}
+		if n := len(sources); n == 0 || sources[n-1].Source != source {
+			sources = append(sources, SafetySource{Source: source})
+		}
+		src := &sources[len(sources)-1]
+		if n := len(src.Metrics); n == 0 || src.Metrics[n-1].Name != name {
+			src.Metrics = append(src.Metrics, SafetyMetric{Name: name})
+		}
+		metric := &src.Metrics[len(src.Metrics)-1]
+		metric.Values = append(metric.Values, v)
+	}
+	return sources, rows.Err()
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestImportSafetyFile(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	write := func(name, content string) string {
+		t.Helper()
+		path := filepath.Join(t.TempDir(), name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	// Keyed by NCES ID with a year column; suppressed counts are skipped
+	byID := write("incidents.csv", "School Year,NCES ID,School,Incidents,Suspensions\n"+
+		"2021-22,360000100001,Lincoln Elementary,4,10\n"+
+		"2022-23,360000100001,Lincoln Elementary,6,*\n"+
+		"2022-23,360000100002,Washington High,\"1,204\",30\n"+
+		"2022-23,999999999999,Closed School,1,1\n")
+	if _, err := ImportSafetyFile(db, byID, SafetyImportOptions{}); err == nil {
+		t.Error("import without a source accepted")
+	}
+	imp, err := ImportSafetyFile(db, byID, SafetyImportOptions{Source: "State Incident Report", DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imp.Join.Column != "NCES ID" || imp.Join.KeyType != safetyKeyNCESSCH || imp.Join.Matched != 3 || imp.Join.Rows != 4 {
+		t.Errorf("join = %s", imp.Join)
+	}
+	if strings.Join(imp.Metrics, ",") != "Incidents,Suspensions" || strings.Join(imp.Years, ",") != "2021-22,2022-23" {
+		t.Errorf("metrics = %v, years = %v", imp.Metrics, imp.Years)
+	}
+	if sources, _ := db.SchoolSafety("360000100001"); len(sources) != 0 {
+		t.Error("dry run saved data")
+	}
+
+	if imp, err = ImportSafetyFile(db, byID, SafetyImportOptions{Source: "State Incident Report"}); err != nil {
+		t.Fatal(err)
+	}
+	if imp.Values != 5 {
+		t.Errorf("saved %d values, want 5", imp.Values)
+	}
+
+	// Keyed by name, for one year, with a row per subgroup
+	byName := write("discipline.csv", "School Name,Group,Expulsions\n"+
+		"Lincoln Elementary School,All,2\n"+
+		"Lincoln Elementary School,Boys,1\n"+
+		"Jefferson Middle School,All,5\n")
+	if _, err := ImportSafetyFile(db, byName, SafetyImportOptions{Source: "Discipline Report"}); err == nil {
+		t.Error("import without a school year accepted")
+	}
+	if _, err := ImportSafetyFile(db, byName, SafetyImportOptions{Source: "Discipline Report", Year: "2022-23"}); err == nil || !strings.Contains(err.Error(), "more than one row") {
+		t.Errorf("repeated schools error = %v", err)
+	}
+	imp, err = ImportSafetyFile(db, byName, SafetyImportOptions{Source: "Discipline Report", Year: "2022-23", Filters: map[string]string{"Group": "All"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imp.Join.KeyType != safetyKeyName || imp.Join.Matched != 2 || strings.Join(imp.Metrics, ",") != "Expulsions" {
+		t.Errorf("name import = %s, %v", imp.Join, imp.Metrics)
+	}
+
+	sources, err := db.SchoolSafety("360000100001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sources) != 2 || sources[0].Source != "Discipline Report" || sources[1].Source != "State Incident Report" {
+		t.Fatalf("sources = %+v", sources)
+	}
+	incidents := sources[1].Metrics[0]
+	if incidents.Name != "Incidents" || incidents.Change() != "+2 (+50%) from 2021-22" || incidents.Trend() != "up" {
+		t.Errorf("incidents = %+v, change %q", incidents, incidents.Change())
+	}
+	if suspensions := sources[1].Metrics[1]; len(suspensions.Values) != 1 || suspensions.Change() != "" {
+		t.Errorf("suspensions = %+v", suspensions)
+	}
+	if sources, _ := db.SchoolSafety("360000100002"); sources[0].Metrics[0].Latest().Value != 1204 {
+		t.Errorf("Washington incidents = %+v", sources[0].Metrics[0])
+	}
+
+	// The detail page shows each source
+	req := httptest.NewRequest("GET", "/schools/360000100001", nil)
+	rec := httptest.NewRecorder()
+	NewRouter(ServerConfig{DB: db}).ServeHTTP(rec, req)
+	if body := rec.Body.String(); !strings.Contains(body, "Source: State Incident Report") || !strings.Contains(body, "50%) from 2021-22") {
+		t.Error("detail page is missing safety data")
+	}
+
+	// Removing a file removes its data
+	if err := db.DeleteSafetyFile("discipline.csv"); err != nil {
+		t.Fatal(err)
+	}
+	if sources, _ := db.SchoolSafety("360000100003"); len(sources) != 0 {
+		t.Errorf("removed file's data remains: %+v", sources)
+	}
+	if files, err := db.SafetyFiles(); err != nil || len(files) != 1 {
+		t.Errorf("SafetyFiles() = %+v, %v", files, err)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// saveHookTimeout bounds how long a post-save hook may run
+const saveHookTimeout = 2 * time.Minute
+
+// saveHookOutputLimit is how much of a failed hook's output is shown
+const saveHookOutputLimit = 200
+
+// saveHookCommand returns the shell command to run after a school is saved to a
+// file, from SCHOOLFINDER_SAVE_HOOK, or "" if none is configured
+func saveHookCommand() string {
+	return strings.TrimSpace(os.Getenv("SCHOOLFINDER_SAVE_HOOK"))
+}
+
+// saveHookEnv returns the variables a save hook can refer to, in addition to
+// the caller's environment
+func saveHookEnv(filename string, school *School) []string {
+	return []string{
+		"SCHOOLFINDER_FILE=" + filename,
+		"SCHOOLFINDER_SCHOOL_ID=" + school.NCESSCH,
+		"SCHOOLFINDER_SCHOOL_NAME=" + school.Name,
+		"SCHOOLFINDER_SCHOOL_CITY=" + school.City,
+		"SCHOOLFINDER_SCHOOL_STATE=" + school.State,
+	}
+}
+
+// runSaveHook runs command in the shell after filename was saved. The file's
+// absolute path is both $1 and $SCHOOLFINDER_FILE, and the school's details are
+// in the environment (see saveHookEnv), so the shell expands them rather than
+// pasting school names into the command.
+func runSaveHook(ctx context.Context, command, filename string, school *School) error {
+	if abs, err := filepath.Abs(filename); err == nil {
+		filename = abs
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, saveHookTimeout)
+	defer cancel()
+
+	var c *exec.Cmd
+	if runtime.GOOS == "windows" {
+		c = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		c = exec.CommandContext(ctx, "sh", "-c", command, "sh", filename)
+	}
+	c.Env = append(os.Environ(), saveHookEnv(filename, school)...)
+
+	output, err := c.CombinedOutput()
+	if err == nil {
+		return nil
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("save hook timed out after %s", saveHookTimeout)
+	}
+	if detail := lastLine(string(output)); detail != "" {
+		if len(detail) > saveHookOutputLimit {
+			detail = detail[:saveHookOutputLimit] + "…"
+		}
+		return fmt.Errorf("save hook failed (%w): %s", err, detail)
+	}
+	return fmt.Errorf("save hook failed: %w", err)
+}
+
+// lastLine returns the last non-blank line of s, where commands usually put
+// the reason they failed
+func lastLine(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+// saveHookMsg reports the outcome of a post-save hook
+type saveHookMsg struct {
+	filename string
+	err      error
+}
+
+// runSaveHookCmd runs the configured save hook for a saved school file
+func runSaveHookCmd(command, filename string, school *School) tea.Cmd {
+	return func() tea.Msg {
+		return saveHookMsg{filename: filename, err: runSaveHook(context.Background(), command, filename, school)}
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestRunSaveHook(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hooks run with sh")
+	}
+
+	dir := t.TempDir()
+	saved := filepath.Join(dir, "lincoln.json")
+	if err := os.WriteFile(saved, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	school := MockSchool("360000100001", "Lincoln's \"High\" School", "San Francisco Unified", "CA", "09", "12")
+
+	out := filepath.Join(dir, "hook.txt")
+	hook := `printf '%s|%s|%s|%s' "$1" "$SCHOOLFINDER_FILE" "$SCHOOLFINDER_SCHOOL_ID" "$SCHOOLFINDER_SCHOOL_NAME" > ` + out
+	if err := runSaveHook(context.Background(), hook, saved, school); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := saved + "|" + saved + "|360000100001|Lincoln's \"High\" School"
+	if string(got) != want {
+		t.Errorf("hook saw %q, want %q", got, want)
+	}
+
+	err = runSaveHook(context.Background(), `echo "uploading $1"; echo "vault not found" >&2; exit 3`, saved, school)
+	if err == nil || !strings.Contains(err.Error(), "vault not found") || !strings.Contains(err.Error(), "exit status 3") {
+		t.Errorf("failed hook error = %v", err)
+	}
+}
+
+func TestSaveHookFailureShownInTUI(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	m := initialModel(db, nil, nil, "")
+	m.selectedItem = MockSchool("360000100001", "Lincoln High School", "San Francisco Unified", "CA", "09", "12")
+	m.currentView = detailView
+
+	t.Setenv("SCHOOLFINDER_SAVE_HOOK", "false")
+	updated, cmd := m.Update(saveMsg{filename: "lincoln.json"})
+	m = updated.(model)
+	if cmd == nil || !strings.Contains(m.saveSuccess, "running save hook") {
+		t.Fatalf("save didn't start the hook: %q", m.saveSuccess)
+	}
+
+	updated, _ = m.Update(cmd())
+	m = updated.(model)
+	if m.saveSuccess != "Saved to: lincoln.json" || m.err == nil || !strings.Contains(m.err.Error(), "save hook failed") {
+		t.Errorf("after failed hook: success %q, err %v", m.saveSuccess, m.err)
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	maxSavedSearchResults = 1000 // Bounds the result snapshot kept for change alerts
+	maxSavedSearchChanges = 20   // Recent changes shown with the saved searches list
+)
+
+// SavedSearch is a named filter combination that can be re-run, and optionally
+// watched for changes when the underlying data is refreshed
+type SavedSearch struct {
+	ID         int64
+	Name       string
+	Filters    SearchFilters
+	Subscribed bool
+	ResultIDs  []string  // Snapshot of matching NCESSCH IDs at the last check
+	CheckedAt  time.Time // Zero until the first snapshot is taken
+	CreatedAt  time.Time
+	LastChange *SavedSearchChange // Most recent change, if any
+}
+
+// SavedSearchChange records schools that started or stopped matching a subscribed search
+type SavedSearchChange struct {
+	ID         int64
+	SearchID   int64
+	SearchName string
+	Added      []string
+	Removed    []string
+	DetectedAt time.Time
+}
+
+// Summary describes the change, e.g. "+2 new, 1 no longer matching"
+func (c SavedSearchChange) Summary() string {
+	var parts []string
+	if len(c.Added) > 0 {
+		parts = append(parts, fmt.Sprintf("+%d new", len(c.Added)))
+	}
+	if len(c.Removed) > 0 {
+		parts = append(parts, fmt.Sprintf("%d no longer matching", len(c.Removed)))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// diffResultIDs returns the IDs in current but not previous, and in previous but not current
+func diffResultIDs(previous, current []string) (added, removed []string) {
+	for _, id := range current {
+		if !slices.Contains(previous, id) {
+			added = append(added, id)
+		}
+	}
+	for _, id := range previous {
+		if !slices.Contains(current, id) {
+			removed = append(removed, id)
+		}
+	}
+	return added, removed
+}
+
+// RunSavedSearch runs a saved search's filters
+func RunSavedSearch(db *DB, search *SavedSearch, limit int) ([]School, error) {
+	return db.SearchSchoolsFiltered(search.Filters, limit)
+}
+
+// SaveSearch validates and saves a named search. Subscribed searches take an
+// initial snapshot so later checks can report what changed.
+func SaveSearch(db *DB, name string, filters SearchFilters, subscribed bool) (*SavedSearch, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, fmt.Errorf("saved search name cannot be empty")
+	}
+	if err := filters.Validate(); err != nil {
+		return nil, err
+	}
+	// Store query syntax as filters so the saved search reads clearly
+	if expanded, err := filters.ExpandQuery(); err == nil {
+		filters = expanded
+	}
+
+	search, err := db.SaveSavedSearch(name, filters, subscribed)
+	if err != nil {
+		return nil, err
+	}
+
+	if subscribed && search.CheckedAt.IsZero() {
+		if _, err := checkSavedSearch(db, search); err != nil {
+			return nil, err
+		}
+	}
+	return search, nil
+}
+
+// SetSubscribed turns change alerts for a saved search on or off, taking the
+// initial snapshot when alerts are first turned on
+func SetSubscribed(db *DB, id int64, subscribed bool) (*SavedSearch, error) {
+	if err := db.SetSavedSearchSubscribed(id, subscribed); err != nil {
+		return nil, err
+	}
+
+	search, err := db.GetSavedSearch(id)
+	if err != nil {
+		return nil, err
+	}
+	if subscribed && search.CheckedAt.IsZero() {
+		if _, err := checkSavedSearch(db, search); err != nil {
+			return nil, err
+		}
+	}
+	return search, nil
+}
+
+// CheckSavedSearches re-runs every subscribed search and records a change for each
+// whose results differ from the last snapshot. It runs at startup so changes from
+// reloaded CCD data are picked up.
+func CheckSavedSearches(db *DB) ([]SavedSearchChange, error) {
+	searches, err := db.ListSavedSearches()
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []SavedSearchChange
+	for i := range searches {
+		if !searches[i].Subscribed {
+			continue
+		}
+		change, err := checkSavedSearch(db, &searches[i])
+		if err != nil {
+			return changes, err
+		}
+		if change != nil {
+			changes = append(changes, *change)
+		}
+	}
+
+	if logger != nil && len(changes) > 0 {
+		logger.Info("Saved search results changed", "searches", len(changes))
+	}
+	return changes, nil
+}
+
+// checkSavedSearch re-runs one search, records a change if the results differ from
+// its snapshot, and stores the new snapshot. The first check only takes a snapshot.
+func checkSavedSearch(db *DB, search *SavedSearch) (*SavedSearchChange, error) {
+	schools, err := RunSavedSearch(db, search, maxSavedSearchResults)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run saved search %q: %w", search.Name, err)
+	}
+
+	current := make([]string, len(schools))
+	for i, s := range schools {
+		current[i] = s.NCESSCH
+	}
+
+	var change *SavedSearchChange
+	if !search.CheckedAt.IsZero() {
+		added, removed := diffResultIDs(search.ResultIDs, current)
+		if len(added) > 0 || len(removed) > 0 {
+			change = &SavedSearchChange{SearchID: search.ID, SearchName: search.Name, Added: added, Removed: removed}
+			if err := db.SaveSavedSearchChange(change); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := db.UpdateSavedSearchSnapshot(search.ID, current); err != nil {
+		return nil, err
+	}
+	search.ResultIDs = current
+	search.CheckedAt = time.Now()
+	return change, nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"testing"
+)
+
+// TestSavedSearches tests saving, re-running, and replacing saved searches
+func TestSavedSearches(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	k8 := SearchFilters{GradeLow: "KG", GradeHigh: "08", Charter: "No"}
+	search, err := SaveSearch(db, "K-8 public", k8, false)
+	if err != nil {
+		t.Fatalf("Failed to save search: %v", err)
+	}
+
+	schools, err := RunSavedSearch(db, search, 100)
+	if err != nil {
+		t.Fatalf("Failed to run saved search: %v", err)
+	}
+	if len(schools) != 1 || schools[0].NCESSCH != "360000100005" {
+		t.Errorf("Expected Madison K-8, got %+v", schools)
+	}
+
+	// Saving under the same name replaces the filters
+	if _, err := SaveSearch(db, "K-8 public", SearchFilters{State: "CA"}, false); err != nil {
+		t.Fatalf("Failed to replace search: %v", err)
+	}
+	searches, err := db.ListSavedSearches()
+	if err != nil {
+		t.Fatalf("Failed to list searches: %v", err)
+	}
+	if len(searches) != 1 || searches[0].Filters.State != "CA" {
+		t.Errorf("Expected one search filtered to CA, got %+v", searches)
+	}
+
+	if _, err := SaveSearch(db, "  ", k8, false); err == nil {
+		t.Error("Expected error for empty name")
+	}
+	if _, err := SaveSearch(db, "Bad", SearchFilters{Charter: "maybe"}, false); err == nil {
+		t.Error("Expected error for invalid filters")
+	}
+
+	if err := db.DeleteSavedSearch(searches[0].ID); err != nil {
+		t.Fatalf("Failed to delete search: %v", err)
+	}
+	if err := db.DeleteSavedSearch(searches[0].ID); err == nil {
+		t.Error("Expected error deleting a missing search")
+	}
+}
+
+// TestCheckSavedSearches tests that subscribed searches record added and removed schools
+func TestCheckSavedSearches(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	search, err := SaveSearch(db, "California", SearchFilters{State: "CA"}, true)
+	if err != nil {
+		t.Fatalf("Failed to save search: %v", err)
+	}
+	if len(search.ResultIDs) != 2 {
+		t.Fatalf("Expected initial snapshot of 2 schools, got %v", search.ResultIDs)
+	}
+
+	// No data change, no recorded change
+	changes, err := CheckSavedSearches(db)
+	if err != nil || len(changes) != 0 {
+		t.Fatalf("Expected no changes, got %+v (err %v)", changes, err)
+	}
+
+	// Simulate refreshed data: one school leaves, one joins
+	if _, err := db.conn.Exec(`UPDATE directory SET ST = 'NV' WHERE NCESSCH = '360000100002'`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.conn.Exec(`UPDATE directory SET ST = 'CA' WHERE NCESSCH = '360000100003'`); err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err = CheckSavedSearches(db)
+	if err != nil {
+		t.Fatalf("CheckSavedSearches failed: %v", err)
+	}
+	if len(changes) != 1 {
+		t.Fatalf("Expected 1 change, got %+v", changes)
+	}
+	change := changes[0]
+	if len(change.Added) != 1 || change.Added[0] != "360000100003" || len(change.Removed) != 1 || change.Removed[0] != "360000100002" {
+		t.Errorf("Unexpected change: %+v", change)
+	}
+	if change.Summary() != "+1 new, 1 no longer matching" {
+		t.Errorf("Unexpected summary: %s", change.Summary())
+	}
+
+	searches, _ := db.ListSavedSearches()
+	if len(searches) != 1 || searches[0].LastChange == nil || searches[0].LastChange.ID != change.ID {
+		t.Errorf("Expected last change on saved search, got %+v", searches)
+	}
+
+	// Unsubscribed searches are not checked
+	if _, err := SetSubscribed(db, search.ID, false); err != nil {
+		t.Fatalf("Failed to unsubscribe: %v", err)
+	}
+	if _, err := db.conn.Exec(`UPDATE directory SET ST = 'CA' WHERE NCESSCH = '360000100002'`); err != nil {
+		t.Fatal(err)
+	}
+	if changes, _ := CheckSavedSearches(db); len(changes) != 0 {
+		t.Errorf("Expected unsubscribed search to be skipped, got %+v", changes)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// SchoolBundle is a school's whole dossier in one document: what the TUI
+// saves with Ctrl+W and /api/v1/schools/{id}/bundle returns, so a saved file
+// and the API always agree
+type SchoolBundle struct {
+	School      *School             `json:"school"`
+	AIExtracted *EnhancedSchoolData `json:"ai_extracted,omitempty"`
+	NAEPData    *NAEPData           `json:"naep_data,omitempty"`
+	Tags        []BundleTag         `json:"tags,omitempty"`
+	Notes       []BundleNote        `json:"notes,omitempty"`
+	Provenance  []FieldSource       `json:"provenance,omitempty"`
+	DataVersion []DataVersion       `json:"data_version,omitempty"` // The CCD files the school's record is from
+}
+
+// BundleTag is a program the school is flagged for, such as "gifted"
+type BundleTag struct {
+	Tag      string `json:"tag"`
+	Label    string `json:"label"`
+	Source   string `json:"source"`
+	Evidence string `json:"evidence,omitempty"`
+}
+
+// BundleNote is something written down about the school: a key date or an
+// application
+type BundleNote struct {
+	Kind  string    `json:"kind"` // "date" or "application"
+	Title string    `json:"title"`
+	Date  time.Time `json:"date"`
+	Text  string    `json:"text,omitempty"`
+}
+
+// BuildSchoolBundle puts together a school's dossier from the data already
+// loaded about it. enhanced and naep may be nil; without db, the bundle has no
+// tags, notes, provenance, or data version.
+func BuildSchoolBundle(db *DB, school *School, enhanced *EnhancedSchoolData, naep *NAEPData) (*SchoolBundle, error) {
+	bundle := &SchoolBundle{School: school, AIExtracted: enhanced, NAEPData: naep}
+	if db == nil {
+		return bundle, nil
+	}
+
+	flags, err := db.ProgramFlags(school.NCESSCH)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range flags {
+		bundle.Tags = append(bundle.Tags, BundleTag{Tag: f.Flag, Label: f.Label(), Source: f.SourceLabel(), Evidence: f.Evidence})
+	}
+
+	dates, err := db.SchoolDates(school.NCESSCH)
+	if err != nil {
+		return nil, err
+	}
+	for _, sd := range dates {
+		bundle.Notes = append(bundle.Notes, BundleNote{Kind: "date", Title: sd.Label(), Date: sd.Date, Text: sd.Note})
+	}
+
+	apps, err := db.SchoolApplications(school.NCESSCH)
+	if err != nil {
+		return nil, err
+	}
+	for _, app := range apps {
+		title := fmt.Sprintf("%s application: %s", app.Season, app.StatusLabel())
+		bundle.Notes = append(bundle.Notes, BundleNote{Kind: "application", Title: title, Date: app.UpdatedAt, Text: app.Notes})
+	}
+
+	if bundle.Provenance, err = db.SchoolProvenance(school); err != nil {
+		return nil, err
+	}
+	if bundle.DataVersion, err = db.CurrentDataVersions(); err != nil {
+		return nil, err
+	}
+	return bundle, nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSchoolBundle(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	if err := saveEnhancedData(db, &EnhancedSchoolData{
+		NCESSCH:         "360000100001",
+		SchoolName:      "Lincoln Elementary School",
+		SourceURL:       "https://lincoln.example.org",
+		MarkdownContent: "Lincoln offers Spanish immersion.",
+		ExtractedAt:     time.Now(),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SyncProgramFlags(db); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := AddSchoolDate(db, "360000100001", "tour", "2027-01-12", "Bring questions about aftercare"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveApplication(db, &Application{Season: "2027-28", NCESSCH: "360000100001", Status: appApplied, Notes: "Sibling priority"}); err != nil {
+		t.Fatal(err)
+	}
+
+	scraper := &AIScraperService{db: db, cacheTTL: time.Hour}
+	router := NewRouter(ServerConfig{DB: db, AIScraper: scraper})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/schools/360000100001/bundle", nil))
+	if rec.Code != 200 {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var bundle SchoolBundle
+	if err := json.Unmarshal(rec.Body.Bytes(), &bundle); err != nil {
+		t.Fatal(err)
+	}
+	if bundle.School.NCESSCH != "360000100001" || bundle.AIExtracted == nil || bundle.AIExtracted.SourceURL != "https://lincoln.example.org" {
+		t.Errorf("bundle school = %+v, ai_extracted = %+v", bundle.School, bundle.AIExtracted)
+	}
+	if len(bundle.Tags) != 1 || bundle.Tags[0].Tag != programDualLanguage {
+		t.Errorf("tags = %+v", bundle.Tags)
+	}
+	if len(bundle.Notes) != 2 || bundle.Notes[0].Text != "Bring questions about aftercare" || bundle.Notes[1].Title != "2027-28 application: Applied" {
+		t.Errorf("notes = %+v", bundle.Notes)
+	}
+	if len(bundle.Provenance) == 0 {
+		t.Error("bundle has no provenance")
+	}
+
+	// Ctrl+W in the TUI saves the same document
+	school, err := db.GetSchoolByID("360000100001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	enhanced, err := scraper.CachedSchoolData(school)
+	if err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(t.TempDir(), "lincoln.json")
+	if msg := saveSchoolData(db, school, enhanced, nil, filename)().(saveMsg); msg.err != nil {
+		t.Fatal(msg.err)
+	}
+	saved, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, saved); err != nil {
+		t.Fatal(err)
+	}
+	if got := bytes.TrimSpace(rec.Body.Bytes()); !bytes.Equal(got, compact.Bytes()) {
+		t.Errorf("API bundle differs from the saved file:\n%s\n%s", got, compact.Bytes())
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/schools/999999999999/bundle", nil))
+	if rec.Code != 404 {
+		t.Errorf("unknown school status = %d", rec.Code)
+	}
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // School time zones don't depend on the host's zoneinfo
+)
+
+// stateTimeZones is the time zone most of each state's schools keep; the
+// counties in countyTimeZones keep another
+var stateTimeZones = map[string]string{
+	"AL": "America/Chicago", "AK": "America/Anchorage", "AZ": "America/Phoenix", "AR": "America/Chicago",
+	"CA": "America/Los_Angeles", "CO": "America/Denver", "CT": "America/New_York", "DE": "America/New_York",
+	"DC": "America/New_York", "FL": "America/New_York", "GA": "America/New_York", "HI": "Pacific/Honolulu",
+	"ID": "America/Boise", "IL": "America/Chicago", "IN": "America/Indiana/Indianapolis", "IA": "America/Chicago",
+	"KS": "America/Chicago", "KY": "America/New_York", "LA": "America/Chicago", "ME": "America/New_York",
+	"MD": "America/New_York", "MA": "America/New_York", "MI": "America/Detroit", "MN": "America/Chicago",
+	"MS": "America/Chicago", "MO": "America/Chicago", "MT": "America/Denver", "NE": "America/Chicago",
+	"NV": "America/Los_Angeles", "NH": "America/New_York", "NJ": "America/New_York", "NM": "America/Denver",
+	"NY": "America/New_York", "NC": "America/New_York", "ND": "America/Chicago", "OH": "America/New_York",
+	"OK": "America/Chicago", "OR": "America/Los_Angeles", "PA": "America/New_York", "RI": "America/New_York",
+	"SC": "America/New_York", "SD": "America/Chicago", "TN": "America/Chicago", "TX": "America/Chicago",
+	"UT": "America/Denver", "VT": "America/New_York", "VA": "America/New_York", "WA": "America/Los_Angeles",
+	"WV": "America/New_York", "WI": "America/Chicago", "WY": "America/Denver",
+	"PR": "America/Puerto_Rico", "VI": "America/St_Thomas", "GU": "Pacific/Guam", "AS": "Pacific/Pago_Pago",
+	"MP": "Pacific/Saipan",
+}
+
+// countyTimeZones lists the counties, by FIPS code, of states split between
+// time zones that don't keep their state's zone. Counties split themselves
+// keep the zone of their county seat.
+var countyTimeZones = func() map[string]string {
+	zones := make(map[string]string)
+	add := func(zone, state string, counties ...string) {
+		for _, c := range counties {
+			zones[state+c] = zone
+		}
+	}
+	// Florida panhandle west of the Apalachicola River
+	add("America/Chicago", "12", "005", "013", "033", "059", "063", "091", "113", "131", "133")
+	// Northwest and southwest Indiana
+	add("America/Chicago", "18", "051", "073", "089", "091", "111", "123", "127", "129", "147", "149", "163", "173")
+	// Western Kentucky
+	add("America/Chicago", "21", "001", "003", "007", "009", "027", "031", "033", "035", "039", "047", "053", "055",
+		"057", "059", "061", "075", "083", "085", "087", "091", "099", "101", "105", "107", "139", "141", "143", "145",
+		"149", "157", "169", "171", "177", "183", "207", "213", "219", "221", "225", "227", "233")
+	// Michigan's Upper Peninsula counties bordering Wisconsin
+	add("America/Menominee", "26", "043", "053", "071", "109")
+	// East Tennessee
+	add("America/New_York", "47", "001", "009", "011", "013", "019", "025", "029", "057", "059", "063", "065", "067",
+		"073", "089", "091", "093", "105", "107", "121", "123", "129", "139", "143", "145", "151", "155", "163", "171",
+		"173", "179")
+	// Western Kansas, Nebraska, and the Dakotas, and far west Texas
+	add("America/Denver", "20", "071", "075", "181", "199")
+	add("America/Denver", "31", "005", "007", "013", "029", "033", "045", "049", "057", "069", "075", "091", "101",
+		"105", "123", "135", "157", "161", "165")
+	add("America/Denver", "38", "001", "007", "011", "025", "033", "037", "041", "085", "087", "089")
+	add("America/Denver", "46", "007", "019", "031", "033", "041", "047", "055", "063", "071", "081", "093", "102",
+		"103", "105", "113", "137")
+	add("America/Denver", "48", "141", "229")
+	// Idaho's panhandle, and Oregon's Malheur County
+	add("America/Los_Angeles", "16", "009", "017", "021", "035", "049", "055", "057", "061", "069", "079")
+	add("America/Boise", "41", "045")
+	return zones
+}()
+
+// schoolTimeZone returns the time zone of a school in a state and county
+// (5-digit FIPS code, or empty when unknown), or nil for states without one,
+// such as Bureau of Indian Education schools
+func schoolTimeZone(state, county string) *time.Location {
+	name, ok := countyTimeZones[county]
+	if !ok {
+		name, ok = stateTimeZones[strings.ToUpper(state)]
+	}
+	if !ok {
+		return nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil
+	}
+	return loc
+}
+
+// hoursRangePattern matches a school day's start and end, e.g. "8:00 AM - 3:05 PM",
+// "8:15am–2:45pm", "7:50 to 2:30", or "8 a.m. until 3 p.m."
+var hoursRangePattern = regexp.MustCompile(`(?i)\b(\d{1,2})(?:[:.](\d{2}))?\s*(?:([ap])\.?\s*m\b\.?)?\s*(?:-|–|—|to|until|till)\s*(\d{1,2})(?:[:.](\d{2}))?\s*(?:([ap])\.?\s*m\b\.?)?`)
+
+// weekdayRangePattern matches the days a school is in session, e.g.
+// "Monday–Thursday", "Mon-Fri", or "M-F"
+var weekdayRangePattern = regexp.MustCompile(`(?i)\b(mon|tue|wed|thu|fri|sat|sun|m)[a-z]*\.?\s*(?:-|–|—|through|thru|to)\s*(mon|tue|wed|thu|fri|sat|sun|f)[a-z]*\b`)
+
+var weekdayPrefixes = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "m": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "f": time.Friday, "sat": time.Saturday,
+}
+
+// SchoolHours is a school's day, normalized from the hours on its website
+type SchoolHours struct {
+	Raw      string         // As the website or extraction gave it
+	Open     int            // Minutes after midnight, school time
+	Close    int            // Minutes after midnight, school time
+	FirstDay time.Weekday   // First day of the school week, Monday unless the hours say
+	LastDay  time.Weekday   // Last day of the school week, Friday unless the hours say
+	Location *time.Location // The school's time zone
+}
+
+// HoursStatus is whether a school is in session at a moment, for calling its front office
+type HoursStatus struct {
+	Open      bool
+	Label     string // e.g. "Open now, closes in 2h 10m" or "Closed, opens tomorrow at 8:00 AM"
+	LocalTime string // The time at the school, e.g. "10:05 AM PDT"
+}
+
+// parseSchoolHours reads the start and end of the school day from hours text,
+// as minutes after midnight. Times without AM or PM are read as a morning
+// start and an afternoon end.
+func parseSchoolHours(text string) (open, close int, ok bool) {
+	for _, m := range hoursRangePattern.FindAllStringSubmatch(text, -1) {
+		// Without minutes or AM/PM on each side, "6-8" is more likely grades than hours
+		if (m[2] == "" && m[3] == "") || (m[5] == "" && m[6] == "") {
+			continue
+		}
+		openHour, _ := strconv.Atoi(m[1])
+		openMinute, _ := strconv.Atoi(m[2])
+		closeHour, _ := strconv.Atoi(m[4])
+		closeMinute, _ := strconv.Atoi(m[5])
+		if openHour < 1 || openHour > 12 || closeHour < 1 || closeHour > 12 || openMinute > 59 || closeMinute > 59 {
+			continue
+		}
+		openMeridiem, closeMeridiem := strings.ToLower(m[3]), strings.ToLower(m[6])
+		if openMeridiem == "" {
+			// "1:00-3:00 PM" starts in the afternoon; "8:00-3:00 PM" doesn't
+			openMeridiem = "a"
+			if closeMeridiem == "p" && openHour < closeHour && closeHour != 12 || openHour == 12 {
+				openMeridiem = "p"
+			}
+		}
+		open = clockMinutes(openHour, openMinute, openMeridiem)
+		if closeMeridiem == "" {
+			closeMeridiem = "a"
+			if clockMinutes(closeHour, closeMinute, "a") <= open {
+				closeMeridiem = "p"
+			}
+		}
+		close = clockMinutes(closeHour, closeMinute, closeMeridiem)
+		if close > open {
+			return open, close, true
+		}
+	}
+	return 0, 0, false
+}
+
+// clockMinutes converts a 12-hour time to minutes after midnight
+func clockMinutes(hour, minute int, meridiem string) int {
+	hour %= 12
+	if meridiem == "p" {
+		hour += 12
+	}
+	return hour*60 + minute
+}
+
+// parseSchoolWeek reads the days a school is in session, Monday through Friday unless the text says
+func parseSchoolWeek(text string) (first, last time.Weekday) {
+	if m := weekdayRangePattern.FindStringSubmatch(text); m != nil {
+		return weekdayPrefixes[strings.ToLower(m[1])], weekdayPrefixes[strings.ToLower(m[2])]
+	}
+	return time.Monday, time.Friday
+}
+
+// NewSchoolHours normalizes a school's hours text in its time zone. It
+// returns nil when the text has no start and end times or the zone is unknown.
+func NewSchoolHours(raw string, loc *time.Location) *SchoolHours {
+	open, close, ok := parseSchoolHours(raw)
+	if !ok || loc == nil {
+		return nil
+	}
+	first, last := parseSchoolWeek(raw)
+	return &SchoolHours{Raw: raw, Open: open, Close: close, FirstDay: first, LastDay: last, Location: loc}
+}
+
+// schoolDay reports whether the school is in session on a weekday
+func (h *SchoolHours) schoolDay(day time.Weekday) bool {
+	if h.FirstDay <= h.LastDay {
+		return day >= h.FirstDay && day <= h.LastDay
+	}
+	return day >= h.FirstDay || day <= h.LastDay
+}
+
+// clock formats minutes after midnight, e.g. "8:05 AM"
+func (h *SchoolHours) clock(minutes int) string {
+	return time.Date(2000, 1, 1, minutes/60, minutes%60, 0, 0, time.UTC).Format("3:04 PM")
+}
+
+// Days describes the school week, e.g. "Mon–Fri"
+func (h *SchoolHours) Days() string {
+	return h.FirstDay.String()[:3] + "–" + h.LastDay.String()[:3]
+}
+
+// Summary describes the school day in the school's time zone as of now, e.g.
+// "Mon–Fri 8:00 AM – 3:05 PM PDT"
+func (h *SchoolHours) Summary(now time.Time) string {
+	return fmt.Sprintf("%s %s – %s %s", h.Days(), h.clock(h.Open), h.clock(h.Close), now.In(h.Location).Format("MST"))
+}
+
+// Status reports whether the school is in session at now, and when it next
+// opens or closes
+func (h *SchoolHours) Status(now time.Time) HoursStatus {
+	local := now.In(h.Location)
+	status := HoursStatus{LocalTime: local.Format("3:04 PM MST")}
+	minutes := local.Hour()*60 + local.Minute()
+	if h.schoolDay(local.Weekday()) && minutes >= h.Open && minutes < h.Close {
+		status.Open = true
+		status.Label = "Open now, closes in " + formatWait(time.Duration(h.Close-minutes)*time.Minute)
+		return status
+	}
+
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, h.Location)
+	for days := 0; days <= 7; days++ {
+		day := midnight.AddDate(0, 0, days)
+		if !h.schoolDay(day.Weekday()) || (days == 0 && minutes >= h.Open) {
+			continue
+		}
+		opens := day.Add(time.Duration(h.Open) * time.Minute)
+		switch wait := opens.Sub(local); {
+		case wait < 12*time.Hour:
+			status.Label = "Closed, opens in " + formatWait(wait)
+		case days == 1:
+			status.Label = "Closed, opens tomorrow at " + h.clock(h.Open)
+		default:
+			status.Label = "Closed, opens " + day.Weekday().String() + " at " + h.clock(h.Open)
+		}
+		return status
+	}
+	status.Label = "Closed"
+	return status
+}
+
+// formatWait formats a wait to the minute, e.g. "2h", "2h 10m", or "45m"
+func formatWait(d time.Duration) string {
+	minutes := int(d.Round(time.Minute) / time.Minute)
+	switch {
+	case minutes < 60:
+		return fmt.Sprintf("%dm", max(minutes, 1))
+	case minutes%60 == 0:
+		return fmt.Sprintf("%dh", minutes/60)
+	default:
+		return fmt.Sprintf("%dh %dm", minutes/60, minutes%60)
+	}
+}
+
+// schoolHoursLinePattern matches the "School hours: ..." line the extraction prompt asks for
+var schoolHoursLinePattern = regexp.MustCompile(`(?im)^[\s>*+-]*\**school (?:day )?hours\**\s*:\**\s*(.+)$`)
+
+// parseSchoolHoursLine reads the school hours line from the extracted
+// markdown, or returns "" when there isn't one or it says the hours aren't published
+func parseSchoolHoursLine(markdown string) string {
+	m := schoolHoursLinePattern.FindStringSubmatch(markdown)
+	if m == nil {
+		return ""
+	}
+	hours := strings.Trim(strings.TrimSpace(m[1]), "*")
+	if careUnknown.MatchString(hours) {
+		return ""
+	}
+	return hours
+}
+
+// SchoolCounty returns a school's county FIPS code from the EDGE geocode
+// files, or "" when no file covers the school
+func (d *DB) SchoolCounty(ncessch string) (string, error) {
+	var county string
+	err := d.conn.QueryRow(`SELECT county FROM school_counties WHERE ncessch = $1`, ncessch).Scan(&county)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to look up school county: %w", err)
+	}
+	return county, nil
+}
+
+// SchoolHours normalizes a school's hours text in the school's time zone. It
+// returns nil when the hours can't be read.
+func (d *DB) SchoolHours(school *School, raw string) (*SchoolHours, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	county, err := d.SchoolCounty(school.NCESSCH)
+	if err != nil {
+		return nil, err
+	}
+	return NewSchoolHours(raw, schoolTimeZone(school.State, county)), nil
+}
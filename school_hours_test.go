@@ -0,0 +1,101 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSchoolHours(t *testing.T) {
+	tests := []struct {
+		text        string
+		open, close int
+		ok          bool
+	}{
+		{"Monday-Friday, 8:00 AM - 3:05 PM", 8 * 60, 15*60 + 5, true},
+		{"8:15am–2:45pm", 8*60 + 15, 14*60 + 45, true},
+		{"7:50 to 2:30", 7*60 + 50, 14*60 + 30, true},
+		{"8 a.m. until 3 p.m.", 8 * 60, 15 * 60, true},
+		{"1:00-3:00 PM", 13 * 60, 15 * 60, true},
+		{"Grades 6-8", 0, 0, false},
+		{"not published", 0, 0, false},
+	}
+	for _, tt := range tests {
+		open, close, ok := parseSchoolHours(tt.text)
+		if open != tt.open || close != tt.close || ok != tt.ok {
+			t.Errorf("parseSchoolHours(%q) = %d, %d, %v, want %d, %d, %v", tt.text, open, close, ok, tt.open, tt.close, tt.ok)
+		}
+	}
+}
+
+func TestSchoolTimeZone(t *testing.T) {
+	tests := []struct {
+		state, county, want string
+	}{
+		{"CA", "", "America/Los_Angeles"},
+		{"FL", "12086", "America/New_York"},
+		{"FL", "12033", "America/Chicago"},
+		{"tn", "47093", "America/New_York"},
+		{"TN", "47037", "America/Chicago"},
+	}
+	for _, tt := range tests {
+		loc := schoolTimeZone(tt.state, tt.county)
+		if loc == nil || loc.String() != tt.want {
+			t.Errorf("schoolTimeZone(%q, %q) = %v, want %s", tt.state, tt.county, loc, tt.want)
+		}
+	}
+	if loc := schoolTimeZone("BI", ""); loc != nil {
+		t.Errorf("schoolTimeZone(BI) = %v, want nil", loc)
+	}
+}
+
+func TestSchoolHoursStatus(t *testing.T) {
+	loc := schoolTimeZone("CA", "")
+	hours := NewSchoolHours("Mon-Thu 8:00 AM - 3:05 PM", loc)
+	if hours == nil {
+		t.Fatal("hours not parsed")
+	}
+	if got := hours.Summary(time.Date(2026, 10, 14, 9, 0, 0, 0, loc)); got != "Mon–Thu 8:00 AM – 3:05 PM PDT" {
+		t.Errorf("Summary = %q", got)
+	}
+
+	tests := []struct {
+		at   time.Time
+		open bool
+		want string
+	}{
+		// Wednesday mid-morning at the school
+		{time.Date(2026, 10, 14, 10, 0, 0, 0, loc), true, "Open now, closes in 5h 5m"},
+		// Wednesday early morning, read from New York
+		{time.Date(2026, 10, 14, 9, 0, 0, 0, schoolTimeZone("NY", "")), false, "Closed, opens in 2h"},
+		{time.Date(2026, 10, 14, 16, 0, 0, 0, loc), false, "Closed, opens tomorrow at 8:00 AM"},
+		{time.Date(2026, 10, 15, 16, 0, 0, 0, loc), false, "Closed, opens Monday at 8:00 AM"},
+	}
+	for _, tt := range tests {
+		status := hours.Status(tt.at)
+		if status.Open != tt.open || status.Label != tt.want {
+			t.Errorf("Status(%v) = %v %q, want %v %q", tt.at, status.Open, status.Label, tt.open, tt.want)
+		}
+	}
+	if got := hours.Status(time.Date(2026, 10, 14, 13, 0, 0, 0, time.UTC)).LocalTime; got != "6:00 AM PDT" {
+		t.Errorf("LocalTime = %q", got)
+	}
+
+	if NewSchoolHours("Hours vary", loc) != nil || NewSchoolHours("8:00 AM - 3:00 PM", nil) != nil {
+		t.Error("hours without times or a time zone should be nil")
+	}
+}
+
+func TestParseSchoolHoursLine(t *testing.T) {
+	tests := []struct {
+		markdown, want string
+	}{
+		{"## Schedule\n- **School hours:** Monday-Friday, 8:00 AM - 3:05 PM\n", "Monday-Friday, 8:00 AM - 3:05 PM"},
+		{"- School hours: not published", ""},
+		{"Our school hours are posted online.", ""},
+	}
+	for _, tt := range tests {
+		if got := parseSchoolHoursLine(tt.markdown); got != tt.want {
+			t.Errorf("parseSchoolHoursLine(%q) = %q, want %q", tt.markdown, got, tt.want)
+		}
+	}
+}
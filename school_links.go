@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+	"text/template"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Outside pages a school links to
+const (
+	linkWebsite = "website"
+	linkMap     = "map"
+	linkNCES    = "nces"
+)
+
+// schoolLinkTemplates build the URL of each outside page from a schoolLinkData.
+// Values in query strings must go through urlquery.
+var schoolLinkTemplates = template.Must(template.New("links").Parse(`
+{{- define "website"}}{{.Website}}{{end -}}
+{{- define "map"}}https://www.google.com/maps/search/?api=1&query={{urlquery .Location}}{{end -}}
+{{- define "nces"}}https://nces.ed.gov/ccd/schoolsearch/school_detail.asp?ID={{urlquery .NCESSCH}}{{end -}}
+`))
+
+// schoolLinkData is what link templates can refer to
+type schoolLinkData struct {
+	NCESSCH  string
+	Name     string
+	Website  string // Normalized, with a scheme
+	Location string // Name and mailing address, for map searches
+}
+
+func newSchoolLinkData(s *School) schoolLinkData {
+	parts := []string{s.Name}
+	if s.Street1.Valid && s.Street1.String != "" {
+		parts = append(parts, s.Street1.String)
+	}
+	if s.City != "" {
+		parts = append(parts, s.City)
+	}
+	parts = append(parts, strings.TrimSpace(s.State+" "+s.Zip.String))
+
+	data := schoolLinkData{
+		NCESSCH:  s.NCESSCH,
+		Name:     s.Name,
+		Location: strings.Join(parts, ", "),
+	}
+	if s.Website.Valid && s.Website.String != "" {
+		data.Website = schoolWebsiteURL(s)
+	}
+	return data
+}
+
+// SchoolLink returns the URL of one of a school's outside pages (linkWebsite,
+// linkMap, or linkNCES). It fails with ErrNoWebsite for a school without one.
+func SchoolLink(kind string, s *School) (string, error) {
+	data := newSchoolLinkData(s)
+	if kind == linkWebsite && data.Website == "" {
+		return "", ErrNoWebsite
+	}
+
+	var b strings.Builder
+	if err := schoolLinkTemplates.ExecuteTemplate(&b, kind, data); err != nil {
+		return "", fmt.Errorf("failed to build %s link: %w", kind, err)
+	}
+	return b.String(), nil
+}
+
+// startBrowser opens url in the default browser without waiting for it to exit
+var startBrowser = func(url string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		// cmd treats & as a command separator unless escaped
+		cmd = exec.Command("cmd", "/c", "start", "", strings.ReplaceAll(url, "&", "^&"))
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("couldn't open a browser: %w", err)
+	}
+	go cmd.Wait() // Reap the launcher
+	return nil
+}
+
+// browserOpenedMsg reports the outcome of opening a school link
+type browserOpenedMsg struct {
+	url string
+	err error
+}
+
+// openSchoolLink opens one of a school's outside pages in the default browser
+func openSchoolLink(kind string, s *School) tea.Cmd {
+	return func() tea.Msg {
+		url, err := SchoolLink(kind, s)
+		if err != nil {
+			return browserOpenedMsg{err: err}
+		}
+		return browserOpenedMsg{url: url, err: startBrowser(url)}
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestSchoolLink(t *testing.T) {
+	school := MockSchool("360000100001", "Lincoln Elementary School", "SFUSD", "CA", "KG", "05")
+	school.City = "San Francisco"
+	school.Street1 = sql.NullString{String: "123 Lincoln St", Valid: true}
+	school.Website = sql.NullString{String: "Lincoln.SFUSD.edu", Valid: true}
+
+	tests := []struct {
+		kind string
+		want string
+	}{
+		{linkWebsite, "https://lincoln.sfusd.edu"},
+		{linkMap, "https://www.google.com/maps/search/?api=1&query=Lincoln+Elementary+School%2C+123+Lincoln+St%2C+San+Francisco%2C+CA+90001"},
+		{linkNCES, "https://nces.ed.gov/ccd/schoolsearch/school_detail.asp?ID=360000100001"},
+	}
+	for _, tt := range tests {
+		if got, err := SchoolLink(tt.kind, school); err != nil || got != tt.want {
+			t.Errorf("SchoolLink(%s) = %q, %v; want %q", tt.kind, got, err, tt.want)
+		}
+	}
+
+	school.Website = sql.NullString{}
+	if _, err := SchoolLink(linkWebsite, school); !errors.Is(err, ErrNoWebsite) {
+		t.Errorf("website link without a website: %v", err)
+	}
+}
+
+func TestDetailViewOpenInBrowser(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	var opened []string
+	defer func(orig func(string) error) { startBrowser = orig }(startBrowser)
+	startBrowser = func(url string) error {
+		opened = append(opened, url)
+		return nil
+	}
+
+	m := initialModel(db, nil, nil, "")
+	m.width = 80
+	m.height = 24
+	m.currentView = detailView
+	m.selectedItem = MockSchool("123456", "Test School", "Test District", "CA", "PK", "05")
+
+	for _, key := range []tea.KeyType{tea.KeyCtrlO, tea.KeyCtrlG, tea.KeyCtrlL} {
+		newModel, cmd := m.handleDetailViewKeys(tea.KeyMsg{Type: key})
+		if cmd == nil {
+			t.Fatalf("%v: no command", key)
+		}
+		newModel, _ = newModel.(model).Update(cmd())
+		m = newModel.(model)
+	}
+
+	if len(opened) != 3 || opened[0] != "https://test.school" {
+		t.Fatalf("opened %v", opened)
+	}
+	if m.saveSuccess != "Opened "+opened[2]+" in your browser" {
+		t.Errorf("status = %q", m.saveSuccess)
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestScrapeSchool tests that scrape returns data cached within the TTL
+// without calling Claude
+func TestScrapeSchool(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	adapter := &dbAdapter{db: db}
+
+	t.Setenv("SCHOOLFINDER_SECRETS_DIR", t.TempDir())
+	t.Setenv("SCHOOLFINDER_KEYCHAIN", "0")
+	t.Setenv("ANTHROPIC_API_KEY", "")
+	if _, err := scrapeSchool(adapter, "360000100001", false); !errors.Is(err, ErrAINotConfigured) {
+		t.Errorf("without a key: %v", err)
+	}
+
+	t.Setenv("ANTHROPIC_API_KEY", "test-key")
+	if err := db.SaveAIScraperCache("360000100001", "Lincoln Elementary School", "https://lincoln.sfusd.edu", "# Lincoln", []byte(`{"principal":"Dr. Maria Lopez"}`), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	data, err := scrapeSchool(adapter, "360000100001", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data.MarkdownContent != "# Lincoln" || data.Principal != "Dr. Maria Lopez" {
+		t.Errorf("cached data = %+v", data)
+	}
+}
@@ -0,0 +1,542 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// SearchFilters is a structured search: free text plus optional field filters
+type SearchFilters struct {
+	Query     string  `json:"query,omitempty"`
+	State     string  `json:"state,omitempty"`
+	GradeLow  string  `json:"grade_low,omitempty"`  // School must serve this grade (e.g. "KG")...
+	GradeHigh string  `json:"grade_high,omitempty"` // ...through this grade (e.g. "08")
+	Charter   string  `json:"charter,omitempty"`    // "Yes" or "No"; empty for either
+	MaxRatio  float64 `json:"max_ratio,omitempty"`  // Maximum students per teacher; 0 for no limit
+	Trend     string  `json:"trend,omitempty"`      // Enrollment growth pressure, e.g. EnrollmentGrowing
+	Care      string  `json:"care,omitempty"`       // careBefore, careAfter, or careBoth from website data
+	Sector    string  `json:"sector,omitempty"`     // sectorEarlyChildhood or sectorK12; empty for either
+	PreK      string  `json:"prek,omitempty"`       // "Yes" for schools offering pre-K; empty for any
+
+	// School must serve at least one of these comma-separated grades, e.g. "KG,06"
+	// from the child profiles
+	ChildGrades string `json:"child_grades,omitempty"`
+	// School must offer all of these comma-separated programs, e.g. "special_ed,gifted"
+	Programs string `json:"programs,omitempty"`
+	// School must publish a CTE pathway or career cluster matching any of these
+	// comma-separated terms, e.g. "aviation,health sciences"
+	CTE string `json:"cte,omitempty"`
+	// School must field all of these comma-separated sports, e.g. "swimming,tennis"
+	Sports     string `json:"sports,omitempty"`
+	Conference string `json:"conference,omitempty"` // Athletic conference or league contains
+	// School must teach this language (e.g. "mandarin"), through immersion if
+	// Immersion is "Yes". Immersion alone matches any immersion program.
+	Language  string `json:"language,omitempty"`
+	Immersion string `json:"immersion,omitempty"`
+
+	// School must be within WithinMiles of Near: an address, "lat,lon"
+	// coordinates, or empty for the saved home. ResolveNear looks up Near's
+	// coordinates.
+	WithinMiles float64 `json:"within_miles,omitempty"`
+	Near        string  `json:"near,omitempty"`
+	NearLat     float64 `json:"near_lat,omitempty"`
+	NearLon     float64 `json:"near_lon,omitempty"`
+
+	// Field-scoped terms, usually from query syntax such as name:"lincoln" -district:charter
+	Name        string `json:"name,omitempty"`     // School name contains
+	City        string `json:"city,omitempty"`     // City is (case-insensitive)
+	District    string `json:"district,omitempty"` // District name contains
+	Zip         string `json:"zip,omitempty"`      // Zip code starts with
+	NotName     string `json:"not_name,omitempty"`
+	NotCity     string `json:"not_city,omitempty"`
+	NotDistrict string `json:"not_district,omitempty"`
+}
+
+// maxWithinMiles is the farthest distance filter accepted
+const maxWithinMiles = 250
+
+// gradeOrder ranks CCD grade codes; numbered grades rank by their number
+var gradeOrder = map[string]int{"PK": -1, "KG": 0}
+
+// gradeRank returns the rank of a CCD grade code such as "PK", "KG", or "08"
+func gradeRank(grade string) (int, bool) {
+	grade = strings.ToUpper(strings.TrimSpace(grade))
+	if rank, ok := gradeOrder[grade]; ok {
+		return rank, true
+	}
+	if grade == "K" {
+		return 0, true
+	}
+	n, err := strconv.Atoi(grade)
+	if err != nil || n < 1 || n > 13 {
+		return 0, false
+	}
+	return n, true
+}
+
+// gradeRankSQL ranks a CCD grade column the same way gradeRank does
+func gradeRankSQL(column string) string {
+	return fmt.Sprintf("CASE %s WHEN 'PK' THEN -1 WHEN 'KG' THEN 0 ELSE TRY_CAST(%s AS INTEGER) END", column, column)
+}
+
+// gradeLabel formats a grade code for display
+func gradeLabel(grade string) string {
+	rank, ok := gradeRank(grade)
+	switch {
+	case !ok:
+		return grade
+	case rank == -1:
+		return "PK"
+	case rank == 0:
+		return "K"
+	default:
+		return strconv.Itoa(rank)
+	}
+}
+
+// Validate checks that the filters can be turned into a query
+func (f SearchFilters) Validate() error {
+	for _, grade := range []string{f.GradeLow, f.GradeHigh} {
+		if grade == "" {
+			continue
+		}
+		if _, ok := gradeRank(grade); !ok {
+			return fmt.Errorf("invalid grade %q", grade)
+		}
+	}
+	if f.GradeLow != "" && f.GradeHigh != "" {
+		low, _ := gradeRank(f.GradeLow)
+		high, _ := gradeRank(f.GradeHigh)
+		if low > high {
+			return fmt.Errorf("grade range %s-%s is backwards", gradeLabel(f.GradeLow), gradeLabel(f.GradeHigh))
+		}
+	}
+	for _, grade := range f.childGrades() {
+		if _, ok := gradeRank(grade); !ok {
+			return fmt.Errorf("invalid child grade %q", grade)
+		}
+	}
+	for _, program := range f.programs() {
+		if _, err := ParseProgramFlag(program); err != nil {
+			return err
+		}
+	}
+	if _, ok := sectorLabels[f.Sector]; f.Sector != "" && !ok {
+		return fmt.Errorf("invalid sector %q (use %s or %s)", f.Sector, sectorEarlyChildhood, sectorK12)
+	}
+	if f.Immersion != "" && f.Immersion != "Yes" {
+		return fmt.Errorf("invalid immersion filter %q (use Yes)", f.Immersion)
+	}
+	if f.PreK != "" && f.PreK != "Yes" {
+		return fmt.Errorf("invalid pre-K filter %q (use Yes)", f.PreK)
+	}
+	if f.Charter != "" && f.Charter != "Yes" && f.Charter != "No" {
+		return fmt.Errorf("invalid charter filter %q (use Yes or No)", f.Charter)
+	}
+	if _, ok := careFilterLabels[f.Care]; f.Care != "" && !ok {
+		return fmt.Errorf("invalid care filter %q (use %s, %s, or %s)", f.Care, careBefore, careAfter, careBoth)
+	}
+	if f.MaxRatio < 0 {
+		return fmt.Errorf("maximum student/teacher ratio can't be negative")
+	}
+	if f.WithinMiles < 0 || f.WithinMiles > maxWithinMiles {
+		return fmt.Errorf("invalid distance %g (use miles between 0 and %d)", f.WithinMiles, maxWithinMiles)
+	}
+	if f.Trend != "" && !validEnrollmentPressure(f.Trend) {
+		return fmt.Errorf("invalid enrollment trend %q (use %s, %s, or %s)", f.Trend, EnrollmentGrowing, EnrollmentStable, EnrollmentShrinking)
+	}
+	for _, r := range f.Zip {
+		if r < '0' || r > '9' {
+			return fmt.Errorf("invalid zip code %q", f.Zip)
+		}
+	}
+	return nil
+}
+
+// childGrades splits ChildGrades into grade codes
+func (f SearchFilters) childGrades() []string {
+	var grades []string
+	for _, grade := range strings.Split(f.ChildGrades, ",") {
+		if grade = strings.TrimSpace(grade); grade != "" {
+			grades = append(grades, grade)
+		}
+	}
+	return grades
+}
+
+// programs splits Programs into program flags
+func (f SearchFilters) programs() []string {
+	var programs []string
+	for _, program := range strings.Split(f.Programs, ",") {
+		if program = strings.TrimSpace(program); program != "" {
+			programs = append(programs, program)
+		}
+	}
+	return programs
+}
+
+// HasProgram reports whether the filters require a program, for checking its box
+func (f SearchFilters) HasProgram(flag string) bool {
+	return slices.Contains(f.programs(), flag)
+}
+
+// IsZero reports whether no search or filter is set
+func (f SearchFilters) IsZero() bool {
+	return f == SearchFilters{}
+}
+
+// DrawerFilterCount is the number of filters set in the search page's "More filters"
+// drawer (grades, children's grades, programs, CTE pathways, sports,
+// conference, distance, language, care, sector, pre-K, charter, ratio, and
+// trend), shown on the drawer's toggle. A language and immersion count once.
+func (f SearchFilters) DrawerFilterCount() int {
+	count := 0
+	for _, set := range []bool{f.GradeLow != "" || f.GradeHigh != "", f.ChildGrades != "", f.Programs != "", f.CTE != "", f.Sports != "", f.Conference != "", f.WithinMiles > 0, f.Language != "" || f.Immersion != "", f.Care != "", f.Sector != "", f.PreK != "", f.Charter != "", f.MaxRatio > 0, f.Trend != ""} {
+		if set {
+			count++
+		}
+	}
+	return count
+}
+
+// Summary describes the filters in short form, e.g. `"lincoln", K-8, charter=No, CA, ratio<=20`
+func (f SearchFilters) Summary() string {
+	var parts []string
+	if f.Query != "" {
+		parts = append(parts, strconv.Quote(f.Query))
+	}
+	parts = append(parts, f.fieldTerms()...)
+	switch {
+	case f.GradeLow != "" && f.GradeHigh != "":
+		parts = append(parts, gradeLabel(f.GradeLow)+"-"+gradeLabel(f.GradeHigh))
+	case f.GradeLow != "":
+		parts = append(parts, "from grade "+gradeLabel(f.GradeLow))
+	case f.GradeHigh != "":
+		parts = append(parts, "through grade "+gradeLabel(f.GradeHigh))
+	}
+	if grades := f.childGrades(); len(grades) > 0 {
+		labels := make([]string, len(grades))
+		for i, grade := range grades {
+			labels[i] = gradeLabel(grade)
+		}
+		parts = append(parts, "for grade "+strings.Join(labels, " or "))
+	}
+	if programs := f.programs(); len(programs) > 0 {
+		labels := make([]string, len(programs))
+		for i, program := range programs {
+			labels[i] = programFlagLabels[program]
+		}
+		parts = append(parts, "with "+strings.Join(labels, " and "))
+	}
+	if terms := ctePathwayTerms(f.CTE); len(terms) > 0 {
+		parts = append(parts, "with "+strings.Join(terms, " or ")+" CTE")
+	}
+	if sports := sportTerms(f.Sports); len(sports) > 0 {
+		parts = append(parts, "with "+strings.Join(sports, " and "))
+	}
+	if f.Conference != "" {
+		parts = append(parts, "in conference "+strconv.Quote(f.Conference))
+	}
+	switch {
+	case f.Language != "" && f.Immersion != "":
+		parts = append(parts, languageFilterName(f.Language)+" immersion")
+	case f.Language != "":
+		parts = append(parts, "teaching "+languageFilterName(f.Language))
+	case f.Immersion != "":
+		parts = append(parts, "with language immersion")
+	}
+	if f.WithinMiles > 0 {
+		near := f.Near
+		if near == "" {
+			near = "home"
+		}
+		parts = append(parts, "within "+strconv.FormatFloat(f.WithinMiles, 'f', -1, 64)+" mi of "+near)
+	}
+	if f.Care != "" {
+		parts = append(parts, "with "+careFilterLabels[f.Care])
+	}
+	if f.Sector != "" {
+		parts = append(parts, sectorLabels[f.Sector])
+	}
+	if f.PreK != "" {
+		parts = append(parts, "with pre-K")
+	}
+	if f.Charter != "" {
+		parts = append(parts, "charter="+f.Charter)
+	}
+	if f.State != "" {
+		parts = append(parts, f.State)
+	}
+	if f.MaxRatio > 0 {
+		parts = append(parts, "ratio<="+strconv.FormatFloat(f.MaxRatio, 'f', -1, 64))
+	}
+	if f.Trend != "" {
+		parts = append(parts, "enrollment "+f.Trend)
+	}
+	if len(parts) == 0 {
+		return "All schools"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// fieldTerms writes the field-scoped filters in query syntax, e.g. name:lincoln -district:"charter"
+func (f SearchFilters) fieldTerms() []string {
+	var terms []string
+	for _, term := range []struct{ field, value string }{
+		{"name", f.Name}, {"city", f.City}, {"district", f.District}, {"zip", f.Zip},
+		{"-name", f.NotName}, {"-city", f.NotCity}, {"-district", f.NotDistrict},
+	} {
+		if term.value != "" {
+			terms = append(terms, term.field+":"+quoteTerm(term.value))
+		}
+	}
+	return terms
+}
+
+// QueryText returns the text query with the field-scoped filters written back
+// as query syntax, for pre-filling a search box
+func (f SearchFilters) QueryText() string {
+	parts := f.fieldTerms()
+	if f.Query != "" {
+		parts = append([]string{f.Query}, parts...)
+	}
+	return strings.Join(parts, " ")
+}
+
+// withoutFieldTerms clears the text query and field-scoped filters, leaving
+// the filters that have their own controls
+func (f SearchFilters) withoutFieldTerms() SearchFilters {
+	return SearchFilters{State: f.State, GradeLow: f.GradeLow, GradeHigh: f.GradeHigh, ChildGrades: f.ChildGrades, Programs: f.Programs, CTE: f.CTE, Sports: f.Sports, Conference: f.Conference, Language: f.Language, Immersion: f.Immersion, WithinMiles: f.WithinMiles, Near: f.Near, NearLat: f.NearLat, NearLon: f.NearLon, Care: f.Care, Sector: f.Sector, PreK: f.PreK, Charter: f.Charter, MaxRatio: f.MaxRatio, Trend: f.Trend}
+}
+
+// Values encodes the filters as form/query parameters
+func (f SearchFilters) Values() url.Values {
+	v := url.Values{}
+	set := func(key, value string) {
+		if value != "" {
+			v.Set(key, value)
+		}
+	}
+	set("query", f.Query)
+	set("state", f.State)
+	set("name", f.Name)
+	set("city", f.City)
+	set("district", f.District)
+	set("zip", f.Zip)
+	set("not_name", f.NotName)
+	set("not_city", f.NotCity)
+	set("not_district", f.NotDistrict)
+	set("grade_low", f.GradeLow)
+	set("grade_high", f.GradeHigh)
+	set("child_grades", f.ChildGrades)
+	set("programs", f.Programs)
+	set("cte", f.CTE)
+	set("sports", f.Sports)
+	set("conference", f.Conference)
+	set("language", f.Language)
+	set("immersion", f.Immersion)
+	set("near", f.Near)
+	set("care", f.Care)
+	set("sector", f.Sector)
+	set("prek", f.PreK)
+	set("charter", f.Charter)
+	set("trend", f.Trend)
+	if f.MaxRatio > 0 {
+		v.Set("max_ratio", strconv.FormatFloat(f.MaxRatio, 'f', -1, 64))
+	}
+	if f.WithinMiles > 0 {
+		v.Set("within_miles", strconv.FormatFloat(f.WithinMiles, 'f', -1, 64))
+	}
+	return v
+}
+
+// URL returns the search page link that pre-fills and runs these filters
+func (f SearchFilters) URL() string {
+	if f.IsZero() {
+		return "/"
+	}
+	return "/?" + f.Values().Encode()
+}
+
+// SearchFiltersFromValues reads filters from form/query parameters written by Values
+func SearchFiltersFromValues(v url.Values) (SearchFilters, error) {
+	f := SearchFilters{
+		Query:       strings.TrimSpace(v.Get("query")),
+		State:       v.Get("state"),
+		GradeLow:    strings.ToUpper(v.Get("grade_low")),
+		GradeHigh:   strings.ToUpper(v.Get("grade_high")),
+		ChildGrades: strings.ToUpper(strings.TrimSpace(v.Get("child_grades"))),
+		Programs:    strings.ToLower(strings.Join(v["programs"], ",")), // Checkboxes send one value each
+		CTE:         strings.TrimSpace(v.Get("cte")),
+		Sports:      strings.TrimSpace(v.Get("sports")),
+		Conference:  strings.TrimSpace(v.Get("conference")),
+		Language:    strings.TrimSpace(v.Get("language")),
+		Immersion:   v.Get("immersion"),
+		Near:        strings.TrimSpace(v.Get("near")),
+		Care:        v.Get("care"),
+		Sector:      v.Get("sector"),
+		PreK:        v.Get("prek"),
+		Charter:     v.Get("charter"),
+		Trend:       v.Get("trend"),
+		Name:        strings.TrimSpace(v.Get("name")),
+		City:        strings.TrimSpace(v.Get("city")),
+		District:    strings.TrimSpace(v.Get("district")),
+		Zip:         strings.TrimSpace(v.Get("zip")),
+		NotName:     strings.TrimSpace(v.Get("not_name")),
+		NotCity:     strings.TrimSpace(v.Get("not_city")),
+		NotDistrict: strings.TrimSpace(v.Get("not_district")),
+	}
+	if raw := strings.TrimSpace(v.Get("max_ratio")); raw != "" {
+		ratio, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return f, fmt.Errorf("invalid maximum student/teacher ratio %q", raw)
+		}
+		f.MaxRatio = ratio
+	}
+	if raw := strings.TrimSpace(v.Get("within_miles")); raw != "" {
+		miles, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return f, fmt.Errorf("invalid distance %q", raw)
+		}
+		f.WithinMiles = miles
+	}
+	return f, f.Validate()
+}
+
+// sqlConditions appends the filters other than the free-text query to args and returns matching
+// "AND ..." conditions for a query over directory d, teachers t, and enrollment e
+func (f SearchFilters) sqlConditions(args []interface{}) (string, []interface{}) {
+	var conditions []string
+	add := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if f.State != "" {
+		add("d.ST = $%d", f.State)
+	}
+	if f.Name != "" {
+		add("LOWER(d.SCH_NAME) LIKE $%d", "%"+strings.ToLower(f.Name)+"%")
+	}
+	if f.City != "" {
+		add("LOWER(d.MCITY) = $%d", strings.ToLower(f.City))
+	}
+	if f.District != "" {
+		add("LOWER(d.LEA_NAME) LIKE $%d", "%"+strings.ToLower(f.District)+"%")
+	}
+	if f.Zip != "" {
+		add("d.MZIP LIKE $%d", f.Zip+"%")
+	}
+	if f.NotName != "" {
+		add("LOWER(d.SCH_NAME) NOT LIKE $%d", "%"+strings.ToLower(f.NotName)+"%")
+	}
+	if f.NotCity != "" {
+		add("COALESCE(LOWER(d.MCITY), '') <> $%d", strings.ToLower(f.NotCity))
+	}
+	if f.NotDistrict != "" {
+		add("COALESCE(LOWER(d.LEA_NAME), '') NOT LIKE $%d", "%"+strings.ToLower(f.NotDistrict)+"%")
+	}
+	if rank, ok := gradeRank(f.GradeLow); ok && f.GradeLow != "" {
+		add(gradeRankSQL("d.GSLO")+" <= $%d", rank)
+	}
+	if rank, ok := gradeRank(f.GradeHigh); ok && f.GradeHigh != "" {
+		add(gradeRankSQL("d.GSHI")+" >= $%d", rank)
+	}
+	if grades := f.childGrades(); len(grades) > 0 {
+		var anyGrade []string
+		for _, grade := range grades {
+			rank, _ := gradeRank(grade)
+			args = append(args, rank)
+			anyGrade = append(anyGrade, fmt.Sprintf("(%s <= $%d AND %s >= $%d)", gradeRankSQL("d.GSLO"), len(args), gradeRankSQL("d.GSHI"), len(args)))
+		}
+		conditions = append(conditions, "("+strings.Join(anyGrade, " OR ")+")")
+	}
+	for _, program := range f.programs() {
+		flag, _ := ParseProgramFlag(program)
+		add("d.NCESSCH IN (SELECT ncessch FROM school_program_flags WHERE flag = $%d)", flag)
+	}
+	if terms := ctePathwayTerms(f.CTE); len(terms) > 0 {
+		var anyPathway []string
+		for _, term := range terms {
+			args = append(args, cteTermPattern(term))
+			anyPathway = append(anyPathway, fmt.Sprintf("lower(pathway) LIKE $%[1]d OR lower(cluster) LIKE $%[1]d", len(args)))
+		}
+		conditions = append(conditions, "d.NCESSCH IN (SELECT ncessch FROM school_cte_pathways WHERE "+strings.Join(anyPathway, " OR ")+")")
+	}
+	for _, term := range sportTerms(f.Sports) {
+		name, ok := CanonicalSportName(term)
+		if !ok {
+			name = term
+		}
+		add("d.NCESSCH IN (SELECT ncessch FROM school_sports WHERE lower(sport) = $%d)", strings.ToLower(name))
+	}
+	if f.Conference != "" {
+		add("d.NCESSCH IN (SELECT ncessch FROM school_athletic_conferences WHERE lower(conference) LIKE $%d)", "%"+strings.ToLower(f.Conference)+"%")
+	}
+	switch {
+	case f.Language != "" && f.Immersion != "":
+		add("d.NCESSCH IN (SELECT ncessch FROM school_languages WHERE lower(language) = $%d AND immersion)", strings.ToLower(languageFilterName(f.Language)))
+	case f.Language != "":
+		add("d.NCESSCH IN (SELECT ncessch FROM school_languages WHERE lower(language) = $%d)", strings.ToLower(languageFilterName(f.Language)))
+	case f.Immersion != "":
+		conditions = append(conditions, "d.NCESSCH IN (SELECT ncessch FROM school_languages WHERE immersion)")
+	}
+	if f.WithinMiles > 0 {
+		// Schools without EDGE coordinates can't be placed, so they don't match
+		args = append(args, f.NearLat, f.NearLon, f.WithinMiles)
+		conditions = append(conditions, fmt.Sprintf("d.NCESSCH IN (SELECT ncessch FROM school_coordinates WHERE %s <= $%d)",
+			distanceMilesSQL("lat", "lon", len(args)-2, len(args)-1), len(args)))
+	}
+	if f.Care != "" {
+		conditions = append(conditions, careSQL(f.Care))
+	}
+	switch f.Sector {
+	case sectorEarlyChildhood:
+		conditions = append(conditions, "d.GSLO = 'PK' AND d.GSHI = 'PK'")
+	case sectorK12:
+		conditions = append(conditions, "COALESCE(d.GSHI, '') <> 'PK'")
+	}
+	if f.PreK != "" {
+		conditions = append(conditions, preKSQL)
+	}
+	switch f.Charter {
+	case "Yes":
+		conditions = append(conditions, "d.CHARTER_TEXT = 'Yes'")
+	case "No":
+		// CCD reports "No" or "Not applicable" for non-charter schools
+		conditions = append(conditions, "COALESCE(d.CHARTER_TEXT, '') <> 'Yes'")
+	}
+	if f.MaxRatio > 0 {
+		add("TRY_CAST(e.STUDENT_COUNT AS DOUBLE) / NULLIF(TRY_CAST(t.TEACHERS AS DOUBLE), 0) <= $%d", f.MaxRatio)
+	}
+	if f.Trend != "" {
+		add("d.NCESSCH IN (SELECT ncessch FROM enrollment_trends WHERE pressure = $%d)", f.Trend)
+	}
+
+	if len(conditions) == 0 {
+		return "", args
+	}
+	return "AND " + strings.Join(conditions, " AND "), args
+}
+
+// ResolveNear looks up where the distance filter is measured from: Near's
+// coordinates, its geocoded address, or the saved home when Near is empty.
+// Filters already resolved, or without a distance, are returned unchanged.
+func (f SearchFilters) ResolveNear(ctx context.Context, db *DB, geocoder *Geocoder) (SearchFilters, error) {
+	if f.WithinMiles <= 0 || f.NearLat != 0 || f.NearLon != 0 {
+		return f, nil
+	}
+	if _, _, err := parseCoordinates(f.Near); geocoder == nil && f.Near != "" && err != nil {
+		return f, fmt.Errorf("can't look up %q here; give coordinates as lat,lon", f.Near)
+	}
+	location, err := ResolveLocation(ctx, db, geocoder, f.Near)
+	if err != nil {
+		return f, err
+	}
+	f.NearLat, f.NearLon = location.Lat, location.Lon
+	return f, nil
+}
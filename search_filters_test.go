@@ -0,0 +1,99 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+)
+
+// TestSearchSchoolsFiltered tests grade span, charter, ratio, and state filters
+func TestSearchSchoolsFiltered(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	testCases := []struct {
+		name     string
+		filters  SearchFilters
+		expected []string
+	}{
+		{"Serves K-8", SearchFilters{GradeLow: "KG", GradeHigh: "08"}, []string{"360000100005"}},
+		{"Serves grade 6 and up to 8", SearchFilters{GradeLow: "06", GradeHigh: "08"}, []string{"360000100003", "360000100005"}},
+		{"Charter only", SearchFilters{Charter: "Yes"}, []string{"360000100004"}},
+		{"Not charter, high school", SearchFilters{Charter: "No", GradeHigh: "12"}, []string{"360000100002"}},
+		{"Ratio limit", SearchFilters{MaxRatio: 18.85}, []string{"360000100004"}},
+		{"Ratio and state", SearchFilters{MaxRatio: 20, State: "CA"}, []string{"360000100001", "360000100002"}},
+		{"Text and grades", SearchFilters{Query: "School", GradeLow: "PK"}, []string{"360000100001"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			schools, err := db.SearchSchoolsFiltered(tc.filters, 100)
+			if err != nil {
+				t.Fatalf("SearchSchoolsFiltered failed: %v", err)
+			}
+			var ids []string
+			for _, s := range schools {
+				ids = append(ids, s.NCESSCH)
+			}
+			if len(ids) != len(tc.expected) {
+				t.Fatalf("Expected %v, got %v", tc.expected, ids)
+			}
+			for _, id := range tc.expected {
+				found := false
+				for _, got := range ids {
+					found = found || got == id
+				}
+				if !found {
+					t.Errorf("Expected %s in results %v", id, ids)
+				}
+			}
+		})
+	}
+}
+
+// TestSearchFiltersValues tests round-tripping filters through form values and validation
+func TestSearchFiltersValues(t *testing.T) {
+	filters := SearchFilters{Query: "lincoln", State: "CA", GradeLow: "KG", GradeHigh: "08", Charter: "No", MaxRatio: 20, Trend: "stable"}
+
+	parsed, err := SearchFiltersFromValues(filters.Values())
+	if err != nil {
+		t.Fatalf("Failed to parse values: %v", err)
+	}
+	if parsed != filters {
+		t.Errorf("Expected %+v, got %+v", filters, parsed)
+	}
+	if got := filters.Summary(); got != `"lincoln", K-8, charter=No, CA, ratio<=20, enrollment stable` {
+		t.Errorf("Unexpected summary: %s", got)
+	}
+
+	invalid := []url.Values{
+		{"grade_low": {"08"}, "grade_high": {"KG"}},
+		{"grade_low": {"14"}},
+		{"charter": {"maybe"}},
+		{"max_ratio": {"lots"}},
+		{"trend": {"booming"}},
+	}
+	for _, v := range invalid {
+		if _, err := SearchFiltersFromValues(v); err == nil {
+			t.Errorf("Expected error for %v", v)
+		}
+	}
+}
+
+func TestDrawerFilterCount(t *testing.T) {
+	tests := []struct {
+		filters SearchFilters
+		want    int
+	}{
+		{SearchFilters{}, 0},
+		{SearchFilters{Query: "lincoln", State: "CA", Name: "lincoln"}, 0},
+		{SearchFilters{GradeLow: "KG", GradeHigh: "08"}, 1},
+		{SearchFilters{GradeHigh: "05", Charter: "No"}, 2},
+		{SearchFilters{GradeLow: "KG", Charter: "Yes", MaxRatio: 20, Trend: "growing"}, 4},
+	}
+
+	for _, tt := range tests {
+		if got := tt.filters.DrawerFilterCount(); got != tt.want {
+			t.Errorf("DrawerFilterCount(%+v) = %d, want %d", tt.filters, got, tt.want)
+		}
+	}
+}
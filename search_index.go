@@ -0,0 +1,287 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The search index is the search_index table, one row per school combining the
+// CCD directory fields with user corrections, website text extracted with AI,
+// and the searchable columns of imported tables keyed by NCESSCH. The FTS
+// extension indexes it as fts_main_search_index. DuckDB can't update an FTS
+// index in place, so changes refresh the school's rows and the index is
+// rebuilt once they settle.
+
+// searchIndexDelay is how long after the last change the index is rebuilt, so a
+// burst of corrections or a cache clear rebuilds it once
+const searchIndexDelay = 5 * time.Second
+
+// searchIndexFields are the search_index columns full-text search matches
+var searchIndexFields = []string{"SCH_NAME", "LEA_NAME", "MCITY", "MSTREET1", "MZIP", "WEBSITE_TEXT", "IMPORTED_TEXT"}
+
+// searchIndexer tracks schools whose search_index rows are out of date
+type searchIndexer struct {
+	mu      sync.Mutex
+	pending map[string]bool
+	all     bool // Rebuild every row, after an import
+	timer   *time.Timer
+	closed  bool
+
+	flushMu sync.Mutex // Serializes flushes
+}
+
+// SearchIndexStats describes a rebuilt search index
+type SearchIndexStats struct {
+	Schools      int
+	Corrected    int // Schools with corrected address fields
+	WebsiteText  int // Schools with website text from AI extraction
+	ImportedText int // Schools with text from imported tables
+	FullText     bool
+	Duration     time.Duration
+}
+
+// searchIndexSelect selects search_index rows from the directory, with
+// corrections, website text, and imported text applied. where filters d.
+func (d *DB) searchIndexSelect(where string) (string, error) {
+	imported, err := d.importedSearchText()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(`
+		SELECT
+			d.NCESSCH,
+			d.SCH_NAME,
+			d.LEA_NAME,
+			COALESCE(city.value, d.MCITY) AS MCITY,
+			COALESCE(street.value, d.MSTREET1) AS MSTREET1,
+			COALESCE(zip.value, d.MZIP) AS MZIP,
+			ai.markdown_content AS WEBSITE_TEXT,
+			imported.text AS IMPORTED_TEXT
+		FROM directory d
+		LEFT JOIN school_corrections city ON city.ncessch = d.NCESSCH AND city.field = '%s'
+		LEFT JOIN school_corrections street ON street.ncessch = d.NCESSCH AND street.field = '%s'
+		LEFT JOIN school_corrections zip ON zip.ncessch = d.NCESSCH AND zip.field = '%s'
+		LEFT JOIN ai_scraper_cache ai ON ai.ncessch = d.NCESSCH
+		LEFT JOIN (%s) imported ON imported.ncessch = d.NCESSCH
+		%s
+	`, correctionCity, correctionStreet, correctionZip, imported, where), nil
+}
+
+// importedSearchText returns a query of the searchable text of imported tables
+// keyed by NCESSCH, one row per school, as (ncessch, text)
+func (d *DB) importedSearchText() (string, error) {
+	tables, err := d.ImportedTables()
+	if err != nil {
+		return "", err
+	}
+
+	var parts []string
+	for _, t := range tables {
+		columns, err := d.searchColumns(t)
+		if err != nil {
+			return "", err
+		}
+		if len(columns) == 0 {
+			continue
+		}
+		parts = append(parts, fmt.Sprintf(`SELECT %s AS ncessch, %s AS text FROM %s`,
+			importedSchoolID(t), importedText(columns), quoteIdent(t.Name)))
+	}
+	if len(parts) == 0 {
+		return `SELECT NULL::VARCHAR AS ncessch, NULL::VARCHAR AS text WHERE false`, nil
+	}
+	return `SELECT ncessch, string_agg(text, ' ') AS text FROM (` + strings.Join(parts, " UNION ALL ") + `) GROUP BY ncessch`, nil
+}
+
+// RebuildSearchIndex rebuilds the search_index table and its full-text index
+// from scratch
+func (d *DB) RebuildSearchIndex() (*SearchIndexStats, error) {
+	start := time.Now()
+	d.searchIndex.flushMu.Lock()
+	defer d.searchIndex.flushMu.Unlock()
+	d.searchIndex.mu.Lock()
+	d.searchIndex.pending, d.searchIndex.all = nil, false
+	d.searchIndex.mu.Unlock()
+
+	query, err := d.searchIndexSelect("")
+	if err != nil {
+		return nil, err
+	}
+	_, err = d.conn.Exec(`CREATE OR REPLACE TABLE search_index AS ` + query + `;
+		COMMENT ON TABLE search_index IS 'Text the search box matches, one row per school: directory names and corrected addresses, website text, and imported text. Use directory for school facts'`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build search index: %w", err)
+	}
+	if err := d.buildFullTextIndex(); err != nil {
+		return nil, err
+	}
+
+	unlock := d.lockSearchIndex()
+	stats := &SearchIndexStats{FullText: d.hasFTS}
+	unlock()
+	err = d.conn.QueryRow(`
+		SELECT count(*),
+			count(*) FILTER (WHERE NCESSCH IN (SELECT ncessch FROM school_corrections WHERE field IN ($1, $2, $3))),
+			count(WEBSITE_TEXT),
+			count(IMPORTED_TEXT)
+		FROM search_index
+	`, correctionCity, correctionStreet, correctionZip).Scan(&stats.Schools, &stats.Corrected, &stats.WebsiteText, &stats.ImportedText)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count search index: %w", err)
+	}
+	stats.Duration = time.Since(start)
+	if logger != nil {
+		logger.Info("Rebuilt search index", "schools", stats.Schools, "full_text", stats.FullText, "duration", stats.Duration)
+	}
+	return stats, nil
+}
+
+// buildFullTextIndex indexes search_index with the FTS extension, if it loaded.
+// Searches wait while the index is replaced.
+func (d *DB) buildFullTextIndex() error {
+	if !d.ftsLoaded {
+		return nil
+	}
+	d.ftsMu.Lock()
+	defer d.ftsMu.Unlock()
+	_, err := d.conn.Exec(fmt.Sprintf(`PRAGMA create_fts_index('search_index', 'NCESSCH', '%s', overwrite=1)`,
+		strings.Join(searchIndexFields, "', '")))
+	if err != nil {
+		// Searches fall back to matching search_index with LIKE
+		d.hasFTS = false
+		if logger != nil {
+			logger.Warn("Failed to build full-text search index", "error", err)
+		}
+		return nil
+	}
+	d.hasFTS = true
+	return nil
+}
+
+// lockSearchIndex keeps the full-text index from being replaced while a
+// search uses it; call the returned function when the search is done
+func (d *DB) lockSearchIndex() func() {
+	d.ftsMu.RLock()
+	return d.ftsMu.RUnlock
+}
+
+// queueSearchIndex marks schools' search_index rows out of date, or every row
+// when no schools are given, and schedules a refresh
+func (d *DB) queueSearchIndex(ncessch ...string) {
+	s := &d.searchIndex
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	if len(ncessch) == 0 {
+		s.all = true
+	}
+	for _, id := range ncessch {
+		if s.pending == nil {
+			s.pending = make(map[string]bool)
+		}
+		s.pending[id] = true
+	}
+	if s.timer == nil {
+		s.timer = time.AfterFunc(searchIndexDelay, func() {
+			if err := d.flushSearchIndex(); err != nil && logger != nil {
+				logger.Warn("Failed to refresh search index", "error", err)
+			}
+		})
+	} else {
+		s.timer.Reset(searchIndexDelay)
+	}
+}
+
+// flushSearchIndex refreshes the out-of-date search_index rows and rebuilds
+// the full-text index
+func (d *DB) flushSearchIndex() error {
+	s := &d.searchIndex
+	s.mu.Lock()
+	pending, all := s.pending, s.all
+	s.pending, s.all = nil, false
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	s.mu.Unlock()
+	if all {
+		_, err := d.RebuildSearchIndex()
+		return err
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	s.flushMu.Lock()
+	defer s.flushMu.Unlock()
+	ids := make([]string, 0, len(pending))
+	for id := range pending {
+		ids = append(ids, quoteLiteral(id))
+	}
+	in := "(" + strings.Join(ids, ", ") + ")"
+	query, err := d.searchIndexSelect("WHERE d.NCESSCH IN " + in)
+	if err != nil {
+		return err
+	}
+
+	tx, err := d.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+	if _, err := tx.Exec(`DELETE FROM search_index WHERE NCESSCH IN ` + in); err != nil {
+		return fmt.Errorf("failed to refresh search index: %w", err)
+	}
+	if _, err := tx.Exec(`INSERT INTO search_index ` + query); err != nil {
+		return fmt.Errorf("failed to refresh search index: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to refresh search index: %w", err)
+	}
+	return d.buildFullTextIndex()
+}
+
+// closeSearchIndex applies any pending changes before the database closes
+func (d *DB) closeSearchIndex() {
+	d.searchIndex.mu.Lock()
+	d.searchIndex.closed = true
+	d.searchIndex.mu.Unlock()
+	if err := d.flushSearchIndex(); err != nil && logger != nil {
+		logger.Warn("Failed to refresh search index", "error", err)
+	}
+}
+
+// SyncSearchIndex builds the search index when it's missing, as in new
+// databases and ones from before it, where full-text search indexed only the
+// directory
+func SyncSearchIndex(d *DB) (*SearchIndexStats, error) {
+	var exists, indexed bool
+	err := d.conn.QueryRow(`
+		SELECT
+			EXISTS (SELECT 1 FROM information_schema.tables WHERE table_schema = 'main' AND table_name = 'search_index'),
+			EXISTS (SELECT 1 FROM information_schema.schemata WHERE schema_name = 'fts_main_search_index')
+	`).Scan(&exists, &indexed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check search index: %w", err)
+	}
+	if exists && (indexed || !d.ftsLoaded) {
+		d.hasFTS = indexed && d.ftsLoaded
+		return nil, nil
+	}
+
+	fmt.Fprintln(os.Stderr, "   Building search index...")
+	stats, err := d.RebuildSearchIndex()
+	if err != nil {
+		return nil, err
+	}
+	if d.ftsLoaded {
+		// The old index of the directory alone is no longer used
+		_, _ = d.conn.Exec(`PRAGMA drop_fts_index('directory')`)
+	}
+	return stats, nil
+}
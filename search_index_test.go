@@ -0,0 +1,161 @@
+package main
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func searchIDs(t *testing.T, db *DB, query string) map[string]bool {
+	t.Helper()
+	schools, err := db.SearchSchools(query, "", maxResults)
+	if err != nil {
+		t.Fatalf("SearchSchools(%q): %v", query, err)
+	}
+	ids := make(map[string]bool)
+	for _, s := range schools {
+		ids[s.NCESSCH] = true
+	}
+	return ids
+}
+
+func TestSearchIndexUpdates(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	if ids := searchIDs(t, db, "Lincoln"); !ids["360000100001"] {
+		t.Fatalf("directory search missed Lincoln: %v", ids)
+	}
+
+	// A corrected city is searchable once the index refreshes
+	if err := db.SaveSchoolCorrection(SchoolCorrection{NCESSCH: "360000100001", Field: correctionCity, Value: "Oakland"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.flushSearchIndex(); err != nil {
+		t.Fatal(err)
+	}
+	if ids := searchIDs(t, db, "Oakland"); !ids["360000100001"] {
+		t.Errorf("corrected city not searchable: %v", ids)
+	}
+
+	// So is website text from AI extraction
+	if err := db.SaveAIScraperCache("360000100002", "Washington Middle School", "https://washington.example.org", "# Clubs\nRobotics team and chess club", nil, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.flushSearchIndex(); err != nil {
+		t.Fatal(err)
+	}
+	if ids := searchIDs(t, db, "robotics"); len(ids) != 1 || !ids["360000100002"] {
+		t.Errorf("website text search = %v", ids)
+	}
+
+	// And text from an imported table keyed by school, whose IDs lost their
+	// leading zeros as numbers
+	if _, err := db.conn.Exec(`CREATE TABLE pta_notes AS SELECT 360000100003 AS NCESSCH, 'Award-winning garden program' AS note`); err != nil {
+		t.Fatal(err)
+	}
+	imported, err := db.RecordImport(ImportedTable{Name: "pta_notes", Description: "PTA notes", RowCount: 1})
+	if err != nil || imported.SchoolColumn != "NCESSCH" {
+		t.Fatalf("RecordImport = %+v, %v", imported, err)
+	}
+	if err := db.flushSearchIndex(); err != nil {
+		t.Fatal(err)
+	}
+	if ids := searchIDs(t, db, "garden"); len(ids) != 1 || !ids["360000100003"] {
+		t.Errorf("imported text search = %v", ids)
+	}
+
+	stats, err := db.RebuildSearchIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Schools != 5 || stats.Corrected != 1 || stats.WebsiteText != 1 || stats.ImportedText != 1 {
+		t.Errorf("RebuildSearchIndex = %+v", stats)
+	}
+}
+
+func TestSearchIndexQueue(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	db.queueSearchIndex("360000100001")
+	db.queueSearchIndex("360000100002")
+	db.searchIndex.mu.Lock()
+	pending, timer := len(db.searchIndex.pending), db.searchIndex.timer
+	db.searchIndex.mu.Unlock()
+	if pending != 2 || timer == nil {
+		t.Fatalf("queued %d schools, timer %v", pending, timer)
+	}
+	if err := db.flushSearchIndex(); err != nil {
+		t.Fatal(err)
+	}
+	db.searchIndex.mu.Lock()
+	defer db.searchIndex.mu.Unlock()
+	if len(db.searchIndex.pending) != 0 || db.searchIndex.timer != nil {
+		t.Error("flush left schools queued")
+	}
+}
+
+func TestImportedSearchColumns(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	if _, err := db.conn.Exec(`CREATE TABLE pta_ratings AS SELECT * FROM (VALUES
+		('360000100003', 'Award-winning garden program', 'Meets on Tuesdays'),
+		('360000100004', 'Strong music program', 'Garden tours in May')
+	) v(nces_id, comment, schedule)`); err != nil {
+		t.Fatal(err)
+	}
+	imported, err := db.RecordImport(ImportedTable{Name: "pta_ratings", RowCount: 2})
+	if err != nil || !reflect.DeepEqual(imported.SearchColumns, []string{"comment", "schedule"}) {
+		t.Fatalf("RecordImport = %+v, %v", imported, err)
+	}
+
+	// Only the declared columns are searched, under the table's label
+	if _, err := db.UpdateImportedTable("pta_ratings", "PTA ratings", "nces_id", []string{"comment"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.UpdateImportedTable("pta_ratings", "", "nces_id", []string{"missing"}); err == nil {
+		t.Error("unknown search column accepted")
+	}
+	if _, err := db.UpdateImportedTable("no_such_table", "", "", nil); err == nil {
+		t.Error("unknown table accepted")
+	}
+	if err := db.flushSearchIndex(); err != nil {
+		t.Fatal(err)
+	}
+	if ids := searchIDs(t, db, "garden"); len(ids) != 1 || !ids["360000100003"] {
+		t.Errorf("search of declared columns = %v", ids)
+	}
+
+	matches, err := db.ImportedMatches("Garden program", []string{"360000100003", "360000100004"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string][]string{"360000100003": {"PTA ratings"}}; !reflect.DeepEqual(matches, want) {
+		t.Errorf("ImportedMatches = %v, want %v", matches, want)
+	}
+
+	router := NewRouter(ServerConfig{DB: db})
+	req := httptest.NewRequest("POST", "/search", strings.NewReader(url.Values{"query": {"garden"}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if body := rec.Body.String(); !strings.Contains(body, "Matched your 'PTA ratings' dataset") {
+		t.Errorf("search results don't note the matching dataset:\n%s", body)
+	}
+
+	// Unmapping the school column takes the table out of search
+	if _, err := db.UpdateImportedTable("pta_ratings", "PTA ratings", "", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.flushSearchIndex(); err != nil {
+		t.Fatal(err)
+	}
+	if ids := searchIDs(t, db, "garden"); len(ids) != 0 {
+		t.Errorf("unmapped table still searched: %v", ids)
+	}
+}
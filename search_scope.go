@@ -0,0 +1,198 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"slices"
+)
+
+// SearchScope narrows a search to a district's schools and to the results of
+// earlier searches, so searches can drill down: each refinement looks only
+// within the results of the one before it, e.g. a district, then "magnet"
+// within it, then K-5 within those.
+type SearchScope struct {
+	DistrictID string          // NCES LEA ID; empty for every district
+	Within     []SearchFilters // Earlier searches, outermost first
+}
+
+// maxSearchScopeDepth is the most earlier searches a scope can hold, which
+// keeps the URLs carrying it a reasonable length
+const maxSearchScopeDepth = 8
+
+// searchScopeCondition matches the schools in the search_scope temp table,
+// for a query over directory d
+const searchScopeCondition = "d.NCESSCH IN (SELECT ncessch FROM search_scope)"
+
+// queryer runs queries on the database or in a transaction, so a search can
+// run on the connection holding its scope's temp table
+type queryer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// IsZero reports whether the scope is every school
+func (s SearchScope) IsZero() bool {
+	return s.DistrictID == "" && len(s.Within) == 0
+}
+
+// Refine returns the scope of a search within filters' results
+func (s SearchScope) Refine(filters SearchFilters) SearchScope {
+	return SearchScope{DistrictID: s.DistrictID, Within: append(slices.Clone(s.Within), filters)}
+}
+
+// Values encodes the scope as form/query parameters to send with a search's
+// own: within_district, and a within parameter per earlier search holding
+// that search's encoded parameters
+func (s SearchScope) Values() url.Values {
+	v := url.Values{}
+	if s.DistrictID != "" {
+		v.Set("within_district", s.DistrictID)
+	}
+	for _, f := range s.Within {
+		v.Add("within", f.Values().Encode())
+	}
+	return v
+}
+
+// URL returns the search page link that runs filters within the scope
+func (s SearchScope) URL(filters SearchFilters) string {
+	v := filters.Values()
+	for key, values := range s.Values() {
+		v[key] = values
+	}
+	if len(v) == 0 {
+		return "/"
+	}
+	return "/?" + v.Encode()
+}
+
+// SearchScopeFromValues reads a scope from form/query parameters written by Values
+func SearchScopeFromValues(v url.Values) (SearchScope, error) {
+	scope := SearchScope{DistrictID: v.Get("within_district")}
+	if len(v["within"]) > maxSearchScopeDepth {
+		return scope, fmt.Errorf("searches can be refined at most %d times", maxSearchScopeDepth)
+	}
+	for _, raw := range v["within"] {
+		values, err := url.ParseQuery(raw)
+		if err != nil {
+			return scope, fmt.Errorf("invalid earlier search %q", raw)
+		}
+		filters, err := SearchFiltersFromValues(values)
+		if err != nil {
+			return scope, err
+		}
+		scope.Within = append(scope.Within, filters)
+	}
+	return scope, nil
+}
+
+// SearchCrumb is one step of a drill-down, linking to that step's results
+type SearchCrumb struct {
+	Label string
+	URL   string // Empty for the current search
+}
+
+// Breadcrumbs lists the refinement chain from every school, through the
+// district (named districtName) and each earlier search, to current
+func (s SearchScope) Breadcrumbs(districtName string, current SearchFilters) []SearchCrumb {
+	crumbs := []SearchCrumb{{Label: "All schools", URL: "/"}}
+	if s.DistrictID != "" {
+		if districtName == "" {
+			districtName = "District " + s.DistrictID
+		}
+		crumbs = append(crumbs, SearchCrumb{Label: districtName, URL: SearchScope{DistrictID: s.DistrictID}.URL(SearchFilters{})})
+	}
+	for i, f := range s.Within {
+		crumbs = append(crumbs, SearchCrumb{Label: f.Summary(), URL: SearchScope{DistrictID: s.DistrictID, Within: s.Within[:i]}.URL(f)})
+	}
+	if !current.IsZero() {
+		crumbs = append(crumbs, SearchCrumb{Label: current.Summary()})
+	} else {
+		// Searching the whole scope: the last step is the current one
+		crumbs[len(crumbs)-1].URL = ""
+	}
+	return crumbs
+}
+
+// SearchSchoolsWithin searches like SearchSchoolsFiltered, only among the
+// schools in scope
+func (d *DB) SearchSchoolsWithin(scope SearchScope, filters SearchFilters, limit int) ([]School, error) {
+	if scope.IsZero() {
+		return d.SearchSchoolsFiltered(filters, limit)
+	}
+	expanded, err := d.expandFilters(filters)
+	if err != nil {
+		return nil, err
+	}
+	var schools []School
+	err = d.withSearchScope(scope, func(q queryer) error {
+		schools, err = d.querySchools(q, expanded, limit, d.hasFTS, true)
+		return err
+	})
+	return schools, err
+}
+
+// SearchResultStatsWithin summarizes like SearchResultStats, only among the
+// schools in scope
+func (d *DB) SearchResultStatsWithin(scope SearchScope, filters SearchFilters, includeFlagged bool) (*ResultStats, error) {
+	if scope.IsZero() {
+		return d.SearchResultStats(filters, includeFlagged)
+	}
+	expanded, err := d.expandFilters(filters)
+	if err != nil {
+		return nil, err
+	}
+	var stats *ResultStats
+	err = d.withSearchScope(scope, func(q queryer) error {
+		stats, err = d.resultStats(q, expanded, includeFlagged, true)
+		return err
+	})
+	return stats, err
+}
+
+// withSearchScope collects the IDs of the schools in scope into a
+// search_scope temp table and calls fn with the transaction holding it, for
+// searches nested over it with searchScopeCondition. The table starts as
+// the district's schools, or every school, and each earlier search in turn
+// deletes the schools it doesn't match. The transaction is rolled back after,
+// dropping the table.
+func (d *DB) withSearchScope(scope SearchScope, fn func(q queryer) error) error {
+	defer d.lockSearchIndex()()
+
+	// Temp tables belong to one connection, so build and search it in one transaction
+	tx, err := d.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	base := `CREATE OR REPLACE TEMP TABLE search_scope AS SELECT d.NCESSCH AS ncessch FROM directory d WHERE ` + notMergedCondition
+	var args []any
+	if scope.DistrictID != "" {
+		base += " AND d.LEAID = $1"
+		args = append(args, scope.DistrictID)
+	}
+	if _, err := tx.Exec(base, args...); err != nil {
+		return fmt.Errorf("failed to scope search: %w", err)
+	}
+
+	for _, f := range scope.Within {
+		expanded, err := d.expandFilters(f)
+		if err != nil {
+			return err
+		}
+		where, args := expanded.searchWhere(d.hasFTS)
+		if _, err := tx.Exec(`DELETE FROM search_scope WHERE ncessch NOT IN (
+			SELECT d.NCESSCH FROM directory d
+			LEFT JOIN teachers t ON d.NCESSCH = t.NCESSCH
+			LEFT JOIN enrollment e ON d.NCESSCH = e.NCESSCH AND e.TOTAL_INDICATOR = 'Education Unit Total'
+			`+where+`
+		)`, args...); err != nil {
+			return fmt.Errorf("failed to scope search to %s: %w", f.Summary(), err)
+		}
+	}
+
+	return fn(tx)
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSearchSchoolsWithin(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	ids := func(schools []School) []string {
+		var result []string
+		for _, s := range schools {
+			result = append(result, s.NCESSCH)
+		}
+		return result
+	}
+
+	// Every test school matches "School"; drilling into the California
+	// ones and then K-8 narrows them step by step
+	scope := SearchScope{}.Refine(SearchFilters{Query: "School", State: "CA"})
+	schools, err := db.SearchSchoolsWithin(scope, SearchFilters{}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(schools); !reflect.DeepEqual(got, []string{"360000100001", "360000100002"}) {
+		t.Errorf("within CA = %v", got)
+	}
+	schools, err = db.SearchSchoolsWithin(scope, SearchFilters{GradeHigh: "12"}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(schools); !reflect.DeepEqual(got, []string{"360000100002"}) {
+		t.Errorf("high schools within CA = %v", got)
+	}
+
+	// A district scope starts from the district's schools
+	district := SearchScope{DistrictID: "0600000"}
+	schools, err = db.SearchSchoolsWithin(district, SearchFilters{Query: "School"}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(schools); !reflect.DeepEqual(got, []string{"360000100001"}) {
+		t.Errorf("within district = %v", got)
+	}
+	schools, err = db.SearchSchoolsWithin(district.Refine(SearchFilters{Query: "Washington"}), SearchFilters{}, 10)
+	if err != nil || len(schools) != 0 {
+		t.Errorf("Washington within the district = %v, %v", ids(schools), err)
+	}
+
+	stats, err := db.SearchResultStatsWithin(scope, SearchFilters{}, false)
+	if err != nil || stats.Schools != 2 {
+		t.Errorf("stats within CA = %+v, %v", stats, err)
+	}
+}
+
+func TestSearchScopeValues(t *testing.T) {
+	scope := SearchScope{DistrictID: "0600000"}.Refine(SearchFilters{Query: "magnet"}).Refine(SearchFilters{GradeLow: "KG", GradeHigh: "05"})
+	current := SearchFilters{Charter: "No"}
+
+	link, err := url.Parse(scope.URL(current))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := SearchScopeFromValues(link.Query())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, scope) {
+		t.Errorf("round trip = %+v, want %+v", got, scope)
+	}
+
+	crumbs := scope.Breadcrumbs("San Francisco Unified", current)
+	var labels []string
+	for _, c := range crumbs {
+		labels = append(labels, c.Label)
+	}
+	want := []string{"All schools", "San Francisco Unified", `"magnet"`, "K-5", "charter=No"}
+	if !reflect.DeepEqual(labels, want) {
+		t.Errorf("crumbs = %q, want %q", labels, want)
+	}
+	if crumbs[len(crumbs)-1].URL != "" || crumbs[2].URL != "/?query=magnet&within_district=0600000" {
+		t.Errorf("crumb links = %+v", crumbs)
+	}
+
+	// Searching the whole scope makes the last earlier search current
+	crumbs = SearchScope{DistrictID: "0600000"}.Breadcrumbs("San Francisco Unified", SearchFilters{})
+	if len(crumbs) != 2 || crumbs[1].URL != "" {
+		t.Errorf("district crumbs = %+v", crumbs)
+	}
+
+	tooDeep := url.Values{}
+	for range maxSearchScopeDepth + 1 {
+		tooDeep.Add("within", "query=a")
+	}
+	if _, err := SearchScopeFromValues(tooDeep); err == nil {
+		t.Error("expected an error for too many refinements")
+	}
+}
+
+func TestSearchResultsDrillDown(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	router := NewRouter(ServerConfig{DB: db})
+
+	// Searching "School" within San Francisco Unified
+	form := url.Values{"query": {"School"}, "within_district": {"0600000"}}
+	req := httptest.NewRequest("POST", "/search", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	body := rec.Body.String()
+	for _, want := range []string{"Lincoln Elementary", `aria-label="Search refinements"`, `<a href="/">All schools</a>`, "San Francisco Unified School District", `<span aria-current="page">&#34;School&#34;</span>`, "Search within these results"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("drill-down results are missing %q", want)
+		}
+	}
+	if strings.Contains(body, "Washington High") {
+		t.Error("drill-down results include a school outside the district")
+	}
+
+	// The district page searches within the district
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/districts/0600000", nil))
+	if !strings.Contains(rec.Body.String(), `name="within_district" value="0600000"`) {
+		t.Error("district page has no search within the district")
+	}
+
+	// The search page carries the scope in the form
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/?within_district=0600000&within=query%3DSchool", nil))
+	body = rec.Body.String()
+	if !strings.Contains(body, `name="within" value="query=School"`) || !strings.Contains(body, "load, submit") {
+		t.Error("search page doesn't run the drill-down")
+	}
+}
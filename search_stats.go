@@ -0,0 +1,320 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// enrollmentBinEdges are the lower edges of the enrollment histogram's bins.
+// School sizes are skewed, so bins widen as schools get larger.
+var enrollmentBinEdges = []float64{0, 100, 250, 500, 750, 1000, 1500, 2000}
+
+// Result chart sizes: the histogram's SVG viewBox and the level pie's radius
+const (
+	histogramWidth  = 320
+	histogramHeight = 120
+	pieRadius       = 50
+)
+
+// HistogramBin is one bar of a histogram
+type HistogramBin struct {
+	Low   float64
+	High  float64 // Exclusive; 0 for the open-ended last bin
+	Count int
+}
+
+// Label names the bin's range, e.g. "250–499" or "2,000+"
+func (b HistogramBin) Label() string {
+	if b.High == 0 {
+		return formatChartValue(b.Low) + "+"
+	}
+	return formatChartValue(b.Low) + "–" + formatChartValue(b.High-1)
+}
+
+// ResultStats summarizes every school matching a search, not just the ones listed
+type ResultStats struct {
+	Schools    int                    // Schools matching the search
+	Enrollment []HistogramBin         // Schools by enrollment, for those reporting it
+	Ratio      EnrollmentDistribution // Students per teacher, for a box plot
+	Levels     []AreaLevelCount       // Schools by level, in schoolLevelOrder
+
+	EnrollmentSummary RobustSummary // Median and outlier-excluding mean enrollment
+	TeachersSummary   RobustSummary // The same for teachers (FTE)
+
+	IncludeFlagged bool // Whether enrollment and ratios the data-quality pass flagged are charted
+	Flagged        int  // Matching schools with flagged enrollment or ratio
+}
+
+// SearchResultStats summarizes all schools matching filters, computed in SQL
+// over the whole result set rather than the first page of results. Enrollment
+// and ratios flagged as anomalies are left out unless includeFlagged.
+func (d *DB) SearchResultStats(filters SearchFilters, includeFlagged bool) (*ResultStats, error) {
+	expanded, err := d.expandFilters(filters)
+	if err != nil {
+		return nil, err
+	}
+	defer d.lockSearchIndex()()
+	return d.resultStats(d.conn, expanded, includeFlagged, false)
+}
+
+// resultStats summarizes the schools matching expanded filters on q, only
+// among the schools in the search_scope temp table if scoped. The caller
+// holds the search index lock.
+func (d *DB) resultStats(q queryer, expanded SearchFilters, includeFlagged, scoped bool) (*ResultStats, error) {
+	where, args := expanded.searchWhere(d.hasFTS)
+	if scoped {
+		where += " AND " + searchScopeCondition
+	}
+	enrollmentFlagged := qualityExcludedSQL(qualityFieldEnrollment)
+	ratioFlagged := "(" + enrollmentFlagged + " OR " + qualityExcludedSQL(qualityFieldRatio) + ")"
+	matches := fmt.Sprintf(`
+		WITH flagged AS (
+			SELECT COALESCE(d.LEVEL, 'Not reported') AS level,
+				TRY_CAST(e.STUDENT_COUNT AS DOUBLE) AS students,
+				TRY_CAST(t.TEACHERS AS DOUBLE) AS teachers,
+				TRY_CAST(e.STUDENT_COUNT AS DOUBLE) / NULLIF(TRY_CAST(t.TEACHERS AS DOUBLE), 0) AS ratio,
+				%s AS enrollment_flagged, %s AS ratio_flagged
+			FROM directory d
+			LEFT JOIN teachers t ON d.NCESSCH = t.NCESSCH
+			LEFT JOIN enrollment e ON d.NCESSCH = e.NCESSCH AND e.TOTAL_INDICATOR = 'Education Unit Total'
+			%s
+		), matches AS (
+			SELECT level, enrollment_flagged OR ratio_flagged AS anomalous, teachers,
+				CASE WHEN enrollment_flagged AND NOT %t THEN NULL ELSE students END AS students,
+				CASE WHEN ratio_flagged AND NOT %t THEN NULL ELSE ratio END AS ratio
+			FROM flagged
+		)
+	`, enrollmentFlagged, ratioFlagged, where, includeFlagged, includeFlagged)
+
+	stats := &ResultStats{IncludeFlagged: includeFlagged}
+	var lo, q1, median, q3, hi sql.NullFloat64
+	err := q.QueryRow(matches+`
+		SELECT count(*), count(*) FILTER (WHERE anomalous), count(ratio) FILTER (WHERE ratio > 0),
+			min(ratio) FILTER (WHERE ratio > 0), quantile_cont(ratio, 0.25) FILTER (WHERE ratio > 0),
+			quantile_cont(ratio, 0.5) FILTER (WHERE ratio > 0), quantile_cont(ratio, 0.75) FILTER (WHERE ratio > 0),
+			max(ratio) FILTER (WHERE ratio > 0)
+		FROM matches
+	`, args...).Scan(&stats.Schools, &stats.Flagged, &stats.Ratio.Schools, &lo, &q1, &median, &q3, &hi)
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize search results: %w", err)
+	}
+	stats.Ratio.Min, stats.Ratio.Q1, stats.Ratio.Median = lo.Float64, q1.Float64, median.Float64
+	stats.Ratio.Q3, stats.Ratio.Max = q3.Float64, hi.Float64
+
+	// Medians and outlier-excluding means, so data errors don't skew the summary line
+	if stats.EnrollmentSummary, err = summarizeRobustSQL(q, matches, "students", args); err != nil {
+		return nil, err
+	}
+	if stats.TeachersSummary, err = summarizeRobustSQL(q, matches, "teachers", args); err != nil {
+		return nil, err
+	}
+
+	// Bin enrollment by the highest edge each school reaches
+	var bin strings.Builder
+	bin.WriteString("CASE")
+	for i := len(enrollmentBinEdges) - 1; i > 0; i-- {
+		fmt.Fprintf(&bin, " WHEN students >= %g THEN %d", enrollmentBinEdges[i], i)
+	}
+	bin.WriteString(" ELSE 0 END")
+	for i, low := range enrollmentBinEdges {
+		b := HistogramBin{Low: low}
+		if i+1 < len(enrollmentBinEdges) {
+			b.High = enrollmentBinEdges[i+1]
+		}
+		stats.Enrollment = append(stats.Enrollment, b)
+	}
+	rows, err := q.Query(matches+`
+		SELECT `+bin.String()+` AS bin, count(*) FROM matches WHERE students > 0 GROUP BY bin
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to bin search results: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var i, count int
+		if err := rows.Scan(&i, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan enrollment bin: %w", err)
+		}
+		stats.Enrollment[i].Count = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	levelRows, err := q.Query(matches+`SELECT level, count(*) FROM matches GROUP BY level`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count search results by level: %w", err)
+	}
+	defer levelRows.Close()
+	for levelRows.Next() {
+		var l AreaLevelCount
+		if err := levelRows.Scan(&l.Level, &l.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan level count: %w", err)
+		}
+		stats.Levels = append(stats.Levels, l)
+	}
+	if err := levelRows.Err(); err != nil {
+		return nil, err
+	}
+	slices.SortFunc(stats.Levels, func(a, b AreaLevelCount) int {
+		ai, bi := slices.Index(schoolLevelOrder, a.Level), slices.Index(schoolLevelOrder, b.Level)
+		if ai == bi {
+			return strings.Compare(a.Level, b.Level)
+		}
+		if ai == -1 {
+			return 1
+		}
+		if bi == -1 {
+			return -1
+		}
+		return ai - bi
+	})
+	return stats, nil
+}
+
+// HistogramBar is an enrollment bin laid out in the histogram's SVG viewBox
+type HistogramBar struct {
+	HistogramBin
+	X, Y, Width, Height float64
+}
+
+// HistogramViewBox is the enrollment histogram's SVG viewBox
+func (s *ResultStats) HistogramViewBox() string {
+	return fmt.Sprintf("0 0 %d %d", histogramWidth, histogramHeight)
+}
+
+// HistogramBars lays out the enrollment histogram, bar heights scaled to the fullest bin
+func (s *ResultStats) HistogramBars() []HistogramBar {
+	most := 0
+	for _, b := range s.Enrollment {
+		most = max(most, b.Count)
+	}
+	width := float64(histogramWidth) / float64(len(s.Enrollment))
+	bars := make([]HistogramBar, len(s.Enrollment))
+	for i, b := range s.Enrollment {
+		height := 0.0
+		if most > 0 {
+			height = float64(b.Count) / float64(most) * histogramHeight
+		}
+		bars[i] = HistogramBar{HistogramBin: b, X: float64(i)*width + 1, Y: histogramHeight - height, Width: width - 2, Height: height}
+	}
+	return bars
+}
+
+// PieSlice is a school level's share of the results, as an SVG path
+type PieSlice struct {
+	AreaLevelCount
+	Percent float64
+	Path    string
+	Index   int // Position among the slices, for colors
+}
+
+// LevelSlices lays out the level breakdown as pie slices in a 100 by 100 viewBox
+func (s *ResultStats) LevelSlices() []PieSlice {
+	total := 0
+	for _, l := range s.Levels {
+		total += l.Count
+	}
+	if total == 0 {
+		return nil
+	}
+
+	point := func(fraction float64) (float64, float64) {
+		angle := 2*math.Pi*fraction - math.Pi/2
+		return pieRadius + pieRadius*math.Cos(angle), pieRadius + pieRadius*math.Sin(angle)
+	}
+	var pie []PieSlice
+	start := 0.0
+	for i, l := range s.Levels {
+		fraction := float64(l.Count) / float64(total)
+		var path string
+		if fraction == 1 {
+			// An arc can't end where it starts, so a whole pie is two halves
+			path = fmt.Sprintf("M%d,0 A%d,%d 0 1 1 %d,%d A%d,%d 0 1 1 %d,0 Z",
+				pieRadius, pieRadius, pieRadius, pieRadius, 2*pieRadius, pieRadius, pieRadius, pieRadius)
+		} else {
+			x1, y1 := point(start)
+			x2, y2 := point(start + fraction)
+			large := 0
+			if fraction > 0.5 {
+				large = 1
+			}
+			path = fmt.Sprintf("M%d,%d L%.2f,%.2f A%d,%d 0 %d 1 %.2f,%.2f Z",
+				pieRadius, pieRadius, x1, y1, pieRadius, pieRadius, large, x2, y2)
+		}
+		pie = append(pie, PieSlice{AreaLevelCount: l, Percent: fraction * 100, Path: path, Index: i})
+		start += fraction
+	}
+	return pie
+}
+
+// levelColors color the level breakdown in the TUI, in slice order
+var levelColors = []lipgloss.Color{"33", "214", "82", "201", "45", "226", "196", "141", "250"}
+
+// renderResultStats draws the result set's enrollment histogram, ratio box
+// plot, and level breakdown with the TUI chart helpers
+func renderResultStats(s *ResultStats, width int) string {
+	var b strings.Builder
+	titleStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("33"))
+	mutedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("241"))
+	barWidth := max(10, min(40, width-30))
+
+	b.WriteString(titleStyle.Render(fmt.Sprintf("📊 All %s matching schools", formatChartValue(float64(s.Schools)))))
+	b.WriteString("\n")
+	if s.Flagged > 0 && !s.IncludeFlagged {
+		b.WriteString(mutedStyle.Render(fmt.Sprintf("⚠ Leaving out flagged enrollment or ratios at %s schools", formatChartValue(float64(s.Flagged)))))
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+
+	b.WriteString(titleStyle.Render("Enrollment"))
+	b.WriteString("\n")
+	most := 0
+	for _, bin := range s.Enrollment {
+		most = max(most, bin.Count)
+	}
+	for _, bin := range s.Enrollment {
+		b.WriteString(BarChart(fmt.Sprintf("%-11s", bin.Label()), float64(bin.Count), float64(max(most, 1)), barWidth, lipgloss.Color("33")))
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+
+	b.WriteString(titleStyle.Render("Students per Teacher"))
+	b.WriteString("\n")
+	if r := s.Ratio; r.Schools > 0 {
+		b.WriteString(fmt.Sprintf("%5.1f ", r.Min) + BoxPlot(r.Median, r.Min, r.Q1, r.Median, r.Q3, r.Max, barWidth) + fmt.Sprintf(" %.1f", r.Max))
+		b.WriteString("\n")
+		b.WriteString(mutedStyle.Render(fmt.Sprintf("middle half %.1f–%.1f, median %.1f, across %d schools", r.Q1, r.Q3, r.Median, r.Schools)))
+	} else {
+		b.WriteString(mutedStyle.Render("No schools report both enrollment and teachers"))
+	}
+	b.WriteString("\n\n")
+
+	b.WriteString(titleStyle.Render("Level"))
+	b.WriteString("\n")
+	var segments []struct {
+		Label string
+		Value float64
+		Color lipgloss.Color
+	}
+	for _, slice := range s.LevelSlices() {
+		color := levelColors[slice.Index%len(levelColors)]
+		segments = append(segments, struct {
+			Label string
+			Value float64
+			Color lipgloss.Color
+		}{slice.Level, float64(slice.Count), color})
+	}
+	b.WriteString(DistributionBar(segments, barWidth+12))
+	b.WriteString("\n")
+	for _, slice := range s.LevelSlices() {
+		swatch := lipgloss.NewStyle().Foreground(levelColors[slice.Index%len(levelColors)]).Render("█")
+		b.WriteString(fmt.Sprintf("%s %s %s (%.0f%%)\n", swatch, slice.Level, formatChartValue(float64(slice.Count)), slice.Percent))
+	}
+	return b.String()
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestSearchResultStats(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	stats, err := db.SearchResultStats(SearchFilters{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Schools != 5 || stats.Ratio.Schools != 5 {
+		t.Fatalf("stats = %+v", stats)
+	}
+	// Four schools have 500-749 students and Washington has 850
+	var counts []int
+	for _, b := range stats.Enrollment {
+		counts = append(counts, b.Count)
+	}
+	if len(counts) != len(enrollmentBinEdges) || counts[3] != 4 || counts[4] != 1 || stats.Enrollment[3].Label() != "500–749" {
+		t.Errorf("enrollment bins = %v", counts)
+	}
+	if got := stats.Enrollment[len(stats.Enrollment)-1].Label(); got != "2,000+" {
+		t.Errorf("last bin label = %q", got)
+	}
+	var levels []string
+	for _, l := range stats.Levels {
+		levels = append(levels, l.Level)
+	}
+	if strings.Join(levels, ",") != "Elementary,Middle,High,Other" || stats.Levels[2].Count != 2 {
+		t.Errorf("levels = %+v", stats.Levels)
+	}
+	// Roosevelt has the lowest ratio: 725 students, 38.5 teachers
+	if stats.Ratio.Min < 18.83 || stats.Ratio.Min > 18.84 || stats.Ratio.Median <= stats.Ratio.Min {
+		t.Errorf("ratio = %+v", stats.Ratio)
+	}
+
+	// Filters and queries narrow the stats the same way they narrow results
+	stats, err = db.SearchResultStats(SearchFilters{Query: "lincoln", State: "CA"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Schools != 1 || len(stats.Levels) != 1 {
+		t.Fatalf("filtered stats = %+v", stats)
+	}
+	if pie := stats.LevelSlices(); len(pie) != 1 || pie[0].Percent != 100 || !strings.HasPrefix(pie[0].Path, "M50,0 A") {
+		t.Errorf("whole pie = %+v", pie)
+	}
+	if panel := renderResultStats(stats, 80); !strings.Contains(panel, "Elementary 1 (100%)") || !strings.Contains(panel, "Students per Teacher") {
+		t.Errorf("TUI panel = %q", panel)
+	}
+
+	router := NewRouter(ServerConfig{DB: db})
+	req := httptest.NewRequest("POST", "/search", strings.NewReader(url.Values{"state": {"CA"}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	body := rec.Body.String()
+	if !strings.Contains(body, "Charts of all 2 matching schools") || !strings.Contains(body, `class="level-slice slice-1"`) || !strings.Contains(body, `class="histogram-bar"`) {
+		t.Error("search results are missing the result charts")
+	}
+}
@@ -0,0 +1,516 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// Secret names, as in schoolfinder auth set anthropic
+const (
+	SecretAnthropic = "anthropic"
+	SecretSMTP      = "smtp"
+	SecretToken     = "token"
+	SecretPeerToken = "peer-token"
+)
+
+// secretSpec is a secret the store can hold. Its environment variable, when
+// set, takes precedence over the stored value.
+type secretSpec struct {
+	Name        string
+	Env         string
+	Description string
+}
+
+var secretSpecs = []secretSpec{
+	{SecretAnthropic, "ANTHROPIC_API_KEY", "Anthropic API key for website extraction, the data explorer, and import descriptions"},
+	{SecretSMTP, "SMTP_PASSWORD", "SMTP password for notification emails"},
+	{SecretToken, "SCHOOLFINDER_TOKEN", "API token for --server"},
+	{SecretPeerToken, "SCHOOLFINDER_PEER_TOKEN", "Admin API token for sync --peer with a server"},
+}
+
+// keychainService is the service name secrets are filed under in the OS keychain
+const keychainService = "schoolfinder"
+
+// ErrSecretNotFound means no backend holds the secret
+var ErrSecretNotFound = errors.New("secret not found")
+
+// lookupSecretSpec finds a secret by name
+func lookupSecretSpec(name string) (secretSpec, error) {
+	for _, s := range secretSpecs {
+		if s.Name == name {
+			return s, nil
+		}
+	}
+	names := make([]string, len(secretSpecs))
+	for i, s := range secretSpecs {
+		names[i] = s.Name
+	}
+	return secretSpec{}, fmt.Errorf("unknown secret %q (use %s)", name, strings.Join(names, ", "))
+}
+
+// secretBackend stores secrets by name
+type secretBackend interface {
+	Name() string
+	Get(name string) (string, error) // ErrSecretNotFound when missing
+	Set(name, value string) error
+	Delete(name string) error // nil when missing
+}
+
+// SecretStore keeps secrets in the OS keychain when one is available, and
+// otherwise in an encrypted file
+type SecretStore struct {
+	backends []secretBackend
+}
+
+// newSecretStore opens the store whose encrypted file lives in dir, trying
+// the OS keychain first when keychain is set
+func newSecretStore(dir string, keychain bool) *SecretStore {
+	s := &SecretStore{}
+	if keychain {
+		if k := osKeychain(); k != nil {
+			s.backends = append(s.backends, k)
+		}
+	}
+	s.backends = append(s.backends, &fileSecrets{
+		path:    filepath.Join(dir, "secrets.json"),
+		keyPath: filepath.Join(dir, "secrets.key"),
+	})
+	return s
+}
+
+// defaultSecretStore opens the user's store: the OS keychain, falling back to
+// schoolfinder/secrets.json in the user config directory.
+// SCHOOLFINDER_SECRETS_DIR moves the file and SCHOOLFINDER_KEYCHAIN=0 skips
+// the keychain.
+func defaultSecretStore() (*SecretStore, error) {
+	dir := os.Getenv("SCHOOLFINDER_SECRETS_DIR")
+	if dir == "" {
+		config, err := os.UserConfigDir()
+		if err != nil {
+			return nil, fmt.Errorf("no config directory for secrets: %w", err)
+		}
+		dir = filepath.Join(config, "schoolfinder")
+	}
+	keychain := os.Getenv("SCHOOLFINDER_KEYCHAIN")
+	return newSecretStore(dir, keychain != "0" && keychain != "false" && keychain != "no"), nil
+}
+
+// Get returns a stored secret and the backend holding it
+func (s *SecretStore) Get(name string) (value, backend string, err error) {
+	for _, b := range s.backends {
+		value, err := b.Get(name)
+		if errors.Is(err, ErrSecretNotFound) {
+			continue
+		}
+		if err != nil {
+			return "", "", fmt.Errorf("%s: %w", b.Name(), err)
+		}
+		return value, b.Name(), nil
+	}
+	return "", "", ErrSecretNotFound
+}
+
+// Set stores a secret in the first backend that takes it, removing it from
+// the others, and returns that backend's name
+func (s *SecretStore) Set(name, value string) (string, error) {
+	if _, err := lookupSecretSpec(name); err != nil {
+		return "", err
+	}
+	if value = strings.TrimSpace(value); value == "" {
+		return "", fmt.Errorf("%s cannot be empty", name)
+	}
+	var errs []error
+	for i, b := range s.backends {
+		if err := b.Set(name, value); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", b.Name(), err))
+			continue
+		}
+		for _, other := range s.backends[i+1:] {
+			if err := other.Delete(name); err != nil && logger != nil {
+				logger.Warn("Failed to remove secret from fallback store", "secret", name, "store", other.Name(), "error", err)
+			}
+		}
+		return b.Name(), nil
+	}
+	return "", fmt.Errorf("failed to store %s: %w", name, errors.Join(errs...))
+}
+
+// Delete removes a secret from every backend
+func (s *SecretStore) Delete(name string) error {
+	if _, err := lookupSecretSpec(name); err != nil {
+		return err
+	}
+	for _, b := range s.backends {
+		if err := b.Delete(name); err != nil {
+			return fmt.Errorf("failed to remove %s from %s: %w", name, b.Name(), err)
+		}
+	}
+	return nil
+}
+
+// SecretStatus reports where a secret comes from, without its value
+type SecretStatus struct {
+	Name        string
+	Env         string
+	Description string
+	Source      string // The environment variable, the backend storing it, or empty when unset
+}
+
+// Statuses reports where each secret comes from; a nil store reports only
+// environment variables
+func (s *SecretStore) Statuses() []SecretStatus {
+	statuses := make([]SecretStatus, len(secretSpecs))
+	for i, spec := range secretSpecs {
+		statuses[i] = SecretStatus{Name: spec.Name, Env: spec.Env, Description: spec.Description}
+		if os.Getenv(spec.Env) != "" {
+			statuses[i].Source = spec.Env
+		} else if s == nil {
+			continue
+		} else if _, backend, err := s.Get(spec.Name); err == nil {
+			statuses[i].Source = backend
+		}
+	}
+	return statuses
+}
+
+var (
+	storedSecretsMu sync.Mutex
+	storedSecrets   = map[string]map[string]string{} // By store location
+)
+
+// lookupSecret returns a secret from its environment variable, or else from
+// the secret store. Stored secrets are read once per process.
+func lookupSecret(name string) string {
+	spec, err := lookupSecretSpec(name)
+	if err != nil {
+		return ""
+	}
+	if value := os.Getenv(spec.Env); value != "" {
+		return value
+	}
+
+	storedSecretsMu.Lock()
+	defer storedSecretsMu.Unlock()
+	location := os.Getenv("SCHOOLFINDER_SECRETS_DIR") + "|" + os.Getenv("SCHOOLFINDER_KEYCHAIN")
+	values, ok := storedSecrets[location]
+	if !ok {
+		values = map[string]string{}
+		storedSecrets[location] = values
+		if store, err := defaultSecretStore(); err == nil {
+			for _, s := range secretSpecs {
+				value, _, err := store.Get(s.Name)
+				if err != nil && !errors.Is(err, ErrSecretNotFound) && logger != nil {
+					logger.Warn("Failed to read stored secret", "secret", s.Name, "error", err)
+				}
+				if value != "" {
+					values[s.Name] = value
+				}
+			}
+		}
+	}
+	return values[name]
+}
+
+// anthropicAPIKey returns the Anthropic API key from ANTHROPIC_API_KEY or the
+// secret store
+func anthropicAPIKey() string {
+	return lookupSecret(SecretAnthropic)
+}
+
+// osKeychain returns the platform's keychain, or nil when it has none this
+// can use: macOS's security tool or libsecret's secret-tool elsewhere
+func osKeychain() secretBackend {
+	switch runtime.GOOS {
+	case "darwin":
+		if path, err := exec.LookPath("security"); err == nil {
+			return macKeychain{path}
+		}
+	case "windows":
+		// Windows has no built-in tool that reads back a stored credential
+	default:
+		if path, err := exec.LookPath("secret-tool"); err == nil {
+			return secretTool{path}
+		}
+	}
+	return nil
+}
+
+// macKeychain stores secrets as generic passwords in the login keychain
+type macKeychain struct {
+	path string
+}
+
+func (k macKeychain) Name() string {
+	return "macOS keychain"
+}
+
+func (k macKeychain) Get(name string) (string, error) {
+	out, err := exec.Command(k.path, "find-generic-password", "-s", keychainService, "-a", name, "-w").Output()
+	if err != nil {
+		// Exit status 44 is errSecItemNotFound
+		if exit := (*exec.ExitError)(nil); errors.As(err, &exit) && exit.ExitCode() == 44 {
+			return "", ErrSecretNotFound
+		}
+		return "", err
+	}
+	return strings.TrimSuffix(string(out), "\n"), nil
+}
+
+func (k macKeychain) Set(name, value string) error {
+	// Pass the command on stdin, and the value hex-encoded, so the secret
+	// isn't in the process list or mangled by quoting
+	cmd := exec.Command(k.path, "-i")
+	cmd.Stdin = strings.NewReader(fmt.Sprintf("add-generic-password -U -s %s -a %s -X %s\n", keychainService, name, hex.EncodeToString([]byte(value))))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%w: %s", err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+func (k macKeychain) Delete(name string) error {
+	err := exec.Command(k.path, "delete-generic-password", "-s", keychainService, "-a", name).Run()
+	if exit := (*exec.ExitError)(nil); errors.As(err, &exit) && exit.ExitCode() == 44 {
+		return nil
+	}
+	return err
+}
+
+// secretTool stores secrets in the Secret Service (GNOME Keyring or KWallet)
+// through libsecret's secret-tool
+type secretTool struct {
+	path string
+}
+
+func (k secretTool) Name() string {
+	return "Secret Service keyring"
+}
+
+func (k secretTool) Get(name string) (string, error) {
+	out, err := exec.Command(k.path, "lookup", "service", keychainService, "account", name).Output()
+	if err != nil {
+		// lookup exits 1 with no output both when the secret is missing and
+		// when no keyring is running; either way the file store is next
+		return "", ErrSecretNotFound
+	}
+	return strings.TrimSuffix(string(out), "\n"), nil
+}
+
+func (k secretTool) Set(name, value string) error {
+	cmd := exec.Command(k.path, "store", "--label", "School Finder "+name, "service", keychainService, "account", name)
+	cmd.Stdin = strings.NewReader(value)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%w: %s", err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+func (k secretTool) Delete(name string) error {
+	// clear succeeds when nothing matches; it fails only without a keyring,
+	// which then holds nothing to remove
+	_ = exec.Command(k.path, "clear", "service", keychainService, "account", name).Run()
+	return nil
+}
+
+// fileSecrets keeps secrets encrypted with AES-256-GCM in a JSON file. The
+// key is derived from SCHOOLFINDER_SECRETS_PASSPHRASE when it's set, and
+// otherwise is a random key in a separate file readable only by the user.
+type fileSecrets struct {
+	path    string
+	keyPath string
+}
+
+// secretsFile is the encrypted file's layout. Each value is base64 of the
+// nonce followed by the ciphertext, sealed with the secret's name.
+type secretsFile struct {
+	KDF     string            `json:"kdf"`            // "keyfile" or "pbkdf2"
+	Salt    string            `json:"salt,omitempty"` // Base64, for pbkdf2
+	Secrets map[string]string `json:"secrets"`
+}
+
+func (f *fileSecrets) Name() string {
+	return "encrypted file " + f.path
+}
+
+// load reads the file, or starts an empty one keyed the current way
+func (f *fileSecrets) load() (*secretsFile, error) {
+	data, err := os.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		file := &secretsFile{KDF: "keyfile", Secrets: map[string]string{}}
+		if os.Getenv("SCHOOLFINDER_SECRETS_PASSPHRASE") != "" {
+			salt := make([]byte, 16)
+			if _, err := rand.Read(salt); err != nil {
+				return nil, fmt.Errorf("failed to generate salt: %w", err)
+			}
+			file.KDF, file.Salt = "pbkdf2", base64.StdEncoding.EncodeToString(salt)
+		}
+		return file, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var file secretsFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid secrets file: %w", err)
+	}
+	if file.Secrets == nil {
+		file.Secrets = map[string]string{}
+	}
+	return &file, nil
+}
+
+// key returns the file's encryption key, creating the key file if needed
+func (f *fileSecrets) key(file *secretsFile, create bool) ([]byte, error) {
+	switch file.KDF {
+	case "pbkdf2":
+		passphrase := os.Getenv("SCHOOLFINDER_SECRETS_PASSPHRASE")
+		if passphrase == "" {
+			return nil, errors.New("the secrets file is locked with a passphrase: set SCHOOLFINDER_SECRETS_PASSPHRASE")
+		}
+		salt, err := base64.StdEncoding.DecodeString(file.Salt)
+		if err != nil {
+			return nil, fmt.Errorf("invalid secrets file salt: %w", err)
+		}
+		return pbkdf2.Key(sha256.New, passphrase, salt, pbkdf2Iterations, 32)
+	case "keyfile":
+		key, err := os.ReadFile(f.keyPath)
+		if err == nil && len(key) == 32 {
+			return key, nil
+		}
+		if err == nil || !errors.Is(err, os.ErrNotExist) || !create {
+			return nil, fmt.Errorf("can't read secrets key %s: %v", f.keyPath, err)
+		}
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, fmt.Errorf("failed to generate secrets key: %w", err)
+		}
+		if err := writePrivateFile(f.keyPath, key); err != nil {
+			return nil, err
+		}
+		return key, nil
+	default:
+		return nil, fmt.Errorf("unknown secrets file key %q", file.KDF)
+	}
+}
+
+func (f *fileSecrets) Get(name string) (string, error) {
+	file, err := f.load()
+	if err != nil {
+		return "", err
+	}
+	sealed, ok := file.Secrets[name]
+	if !ok {
+		return "", ErrSecretNotFound
+	}
+	key, err := f.key(file, false)
+	if err != nil {
+		return "", err
+	}
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		return "", fmt.Errorf("invalid secret %s: %w", name, err)
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	if len(data) < gcm.NonceSize() {
+		return "", fmt.Errorf("invalid secret %s", name)
+	}
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], []byte(name))
+	if err != nil {
+		return "", fmt.Errorf("can't decrypt %s (wrong passphrase or key file?)", name)
+	}
+	return string(plain), nil
+}
+
+func (f *fileSecrets) Set(name, value string) error {
+	file, err := f.load()
+	if err != nil {
+		return err
+	}
+	key, err := f.key(file, true)
+	if err != nil {
+		return err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	file.Secrets[name] = base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, []byte(value), []byte(name)))
+	return f.save(file)
+}
+
+func (f *fileSecrets) Delete(name string) error {
+	file, err := f.load()
+	if err != nil {
+		return err
+	}
+	if _, ok := file.Secrets[name]; !ok {
+		return nil
+	}
+	delete(file.Secrets, name)
+	return f.save(file)
+}
+
+func (f *fileSecrets) save(file *secretsFile) error {
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode secrets: %w", err)
+	}
+	return writePrivateFile(f.path, data)
+}
+
+// newGCM returns AES-256-GCM with key
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid secrets key: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// writePrivateFile replaces path with data readable only by the user
+func writePrivateFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to save %s: %w", path, err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".secrets-*")
+	if err != nil {
+		return fmt.Errorf("failed to save %s: %w", path, err)
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save %s: %w", path, err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save %s: %w", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to save %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSecretStore(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("SCHOOLFINDER_SECRETS_PASSPHRASE", "")
+	store := newSecretStore(dir, false)
+
+	if _, _, err := store.Get(SecretAnthropic); err != ErrSecretNotFound {
+		t.Fatalf("empty store returned %v", err)
+	}
+	backend, err := store.Set(SecretAnthropic, " sk-ant-test\n")
+	if err != nil || !strings.HasPrefix(backend, "encrypted file") {
+		t.Fatalf("Set = %q, %v", backend, err)
+	}
+	if value, _, err := store.Get(SecretAnthropic); err != nil || value != "sk-ant-test" {
+		t.Errorf("Get = %q, %v", value, err)
+	}
+
+	// The file holds only ciphertext, and it and its key are private
+	data, err := os.ReadFile(filepath.Join(dir, "secrets.json"))
+	if err != nil || strings.Contains(string(data), "sk-ant-test") {
+		t.Errorf("secrets file has the plain secret or is missing: %v", err)
+	}
+	for _, name := range []string{"secrets.json", "secrets.key"} {
+		if info, err := os.Stat(filepath.Join(dir, name)); err != nil || info.Mode().Perm() != 0600 {
+			t.Errorf("%s: %v, %v", name, info, err)
+		}
+	}
+
+	// Environment variables take precedence over stored secrets
+	t.Setenv("SCHOOLFINDER_SECRETS_DIR", dir)
+	t.Setenv("SCHOOLFINDER_KEYCHAIN", "0")
+	t.Setenv("ANTHROPIC_API_KEY", "")
+	if key := anthropicAPIKey(); key != "sk-ant-test" {
+		t.Errorf("stored key = %q", key)
+	}
+	t.Setenv("ANTHROPIC_API_KEY", "from-env")
+	if key := anthropicAPIKey(); key != "from-env" {
+		t.Errorf("key with ANTHROPIC_API_KEY set = %q", key)
+	}
+	statuses, _ := defaultSecretStore()
+	for _, s := range statuses.Statuses() {
+		if s.Name == SecretAnthropic && s.Source != "ANTHROPIC_API_KEY" || s.Name == SecretSMTP && s.Source != "" {
+			t.Errorf("status %+v", s)
+		}
+	}
+
+	if err := store.Delete(SecretAnthropic); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := store.Get(SecretAnthropic); err != ErrSecretNotFound {
+		t.Errorf("deleted secret returned %v", err)
+	}
+	for _, name := range []string{"github", ""} {
+		if _, err := store.Set(name, "value"); err == nil {
+			t.Errorf("stored unknown secret %q", name)
+		}
+	}
+	if _, err := store.Set(SecretSMTP, "  "); err == nil {
+		t.Error("stored an empty secret")
+	}
+
+	// A file created with a passphrase needs it to be read
+	locked := newSecretStore(t.TempDir(), false)
+	t.Setenv("SCHOOLFINDER_SECRETS_PASSPHRASE", "correct horse")
+	if _, err := locked.Set(SecretSMTP, "hunter2"); err != nil {
+		t.Fatal(err)
+	}
+	if value, _, err := locked.Get(SecretSMTP); err != nil || value != "hunter2" {
+		t.Errorf("Get with passphrase = %q, %v", value, err)
+	}
+	t.Setenv("SCHOOLFINDER_SECRETS_PASSPHRASE", "wrong")
+	if _, _, err := locked.Get(SecretSMTP); err == nil || err == ErrSecretNotFound {
+		t.Errorf("Get with the wrong passphrase returned %v", err)
+	}
+}
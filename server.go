@@ -17,16 +17,58 @@ type ServerConfig struct {
 	AIScraper  *AIScraperService
 	NAEPClient *NAEPClient
 	DataPath   string
+	Dev        bool // Reload templates on change, disable caching, and show error details
+	Demo       bool // Running on the demo's sample schools and recorded responses
+
+	// AdminPassword and Users make the server multi-user: visitors are viewers who
+	// suggest corrections, and editors and admins sign in to do more. The admin
+	// password signs in as "admin".
+	AdminPassword string
+	Users         *userStore          // Accounts from users.json; nil for none
+	Notifier      *suggestionNotifier // Tells admins about new suggestions; optional
+
+	WebsiteChecker *websiteChecker // Checks school websites when their detail page is viewed; optional
+	Geocoder       *Geocoder       // Geocodes addresses for the feeder pipeline page; nil uses the Census geocoder
+	OutreachNudges bool            // Whether outreach follow-up reminders are being sent
+
+	// RateLimiter limits AI and import requests per client; nil allows everything
+	RateLimiter *rateLimiter
 }
 
 // StartServer initializes and starts the HTTP server
 func StartServer(config ServerConfig) error {
+	addr := fmt.Sprintf(":%d", config.Port)
+	log.Printf("Starting server on http://localhost%s", addr)
+	return http.ListenAndServe(addr, NewRouter(config))
+}
+
+// NewRouter builds the router for the web UI, static files, and JSON API
+func NewRouter(config ServerConfig) http.Handler {
 	r := chi.NewRouter()
 
 	// Middleware
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
 	r.Use(middleware.Timeout(60 * time.Second))
+	if config.Dev {
+		r.Use(middleware.NoCache)
+	}
+	r.Use(bumpDataVersionOnWrite(config.DB))
+
+	// Roles come from the admin password and accounts; with neither, everyone is an admin
+	users := config.Users
+	if users == nil {
+		users, _ = loadUserStore("")
+	}
+	acc := &access{adminPassword: config.AdminPassword, users: users}
+	r.Use(acc.identify)
+
+	// ETags for the JSON API and heavy pages and partials; off in dev mode
+	var etags *lruCache[etagEntry]
+	if !config.Dev {
+		etags = newLRUCache[etagEntry](hotCacheSizeFromEnv(), cacheTTLFromEnv("HOT_CACHE_TTL", defaultHotCacheTTL))
+	}
+	conditional := r.With(conditionalGET(config.DB, etags))
 
 	// Static files
 	fileServer := http.FileServer(http.Dir("./static"))
@@ -39,30 +81,149 @@ func StartServer(config ServerConfig) error {
 
 	// Web handlers (HTMX HTML responses)
 	webHandler := NewWebHandler(config.DB, config.AIScraper, config.NAEPClient)
+	if config.Dev {
+		webHandler.enableDevMode()
+	}
+	webHandler.websiteChecker = config.WebsiteChecker
+	webHandler.geocoder = config.Geocoder
+	webHandler.outreachNudging = config.OutreachNudges
+	if webHandler.geocoder == nil {
+		webHandler.geocoder = NewGeocoder()
+	}
+	webHandler.demo = config.Demo
+	webHandler.access = acc
+	// Viewers search and read; editors also scrape, import, and annotate; admins
+	// also review suggestions and manage caches and users. Their POSTs must come
+	// from this server's own pages, since browsers send credentials to any site.
+	editor := r.With(acc.requireRole(RoleEditor, webHandler.denied), requireSameOrigin(webHandler.denied))
+	admin := r.With(acc.requireRole(RoleAdmin, webHandler.denied), requireSameOrigin(webHandler.denied))
+	// AI requests spend the owner's Anthropic budget, and imports load whole files
+	limit := config.RateLimiter.limit(webHandler.rateLimited)
+	// Forms that import data, ask for AI writing, or manage the server also need
+	// the page's CSRF token (webHandler.requireCSRF), checked before the limit so
+	// forged requests don't use up the visitor's limit
 	r.Get("/", webHandler.SearchPage)
+	r.Get("/login", webHandler.SignIn)
 	r.Post("/search", webHandler.SearchResults)
-	r.Get("/schools/{id}", webHandler.SchoolDetail)
-	r.Post("/schools/{id}/ai", webHandler.ExtractAI)
+	r.Get("/random", webHandler.RandomSchool)
+	conditional.Get("/schools/{id}", webHandler.SchoolDetail)
+	r.Get("/schools/{id}/share", webHandler.ShareSchool)
+	r.Get("/schools/{id}/matched", webHandler.CharterMatchPage)
+	editor.With(limit).Post("/schools/{id}/ai", webHandler.ExtractAI)
+	conditional.Get("/schools/{id}/ai", webHandler.AIData)
+	editor.Get("/schools/{id}/ai/edit", webHandler.EditAIData)
+	editor.Post("/schools/{id}/ai/edit", webHandler.SaveAIData)
 	r.Post("/schools/{id}/naep", webHandler.FetchNAEP)
+	r.Post("/schools/{id}/naep/refresh", webHandler.RefreshNAEP)
+	editor.Post("/schools/{id}/naep/settings", webHandler.SaveNAEPSettings)
+	if acc.multiUser() {
+		webHandler.enableSuggestions(config.Notifier)
+		r.Post("/schools/{id}/suggestions", webHandler.SuggestCorrections)
+	}
+
+	// Routes that change shared data
+	admin.Get("/admin/corrections", webHandler.CorrectionReviewPage)
+	admin.Post("/admin/corrections/{id}/approve", webHandler.ApproveCorrection)
+	admin.Post("/admin/corrections/{id}/reject", webHandler.RejectCorrection)
+	admin.Get("/admin/users", webHandler.UsersPage)
+	admin.With(webHandler.requireCSRF).Post("/admin/users", webHandler.SaveUser)
+	admin.With(webHandler.requireCSRF).Post("/admin/users/{name}/delete", webHandler.DeleteUser)
+	admin.With(webHandler.requireCSRF).Post("/admin/users/{name}/token", webHandler.IssueUserToken)
+	admin.Get("/admin/cache", webHandler.CachePage)
+	admin.With(webHandler.requireCSRF).Post("/admin/cache/{table}/clear", webHandler.ClearCache)
+	editor.Post("/schools/{id}/corrections", webHandler.SaveCorrections)
+	editor.Get("/duplicates", webHandler.DuplicatesPage)
+	editor.Post("/duplicates/merge", webHandler.MergeDuplicate)
+	editor.Post("/duplicates/unmerge", webHandler.UnmergeDuplicate)
+	editor.Post("/duplicates/dismiss", webHandler.DismissDuplicate)
+	editor.With(webHandler.requireCSRF, limit).Post("/schools/{id}/summary", webHandler.ParentSummary)
+	r.Post("/schools/{id}/questions", webHandler.TourQuestions)
+	r.Get("/schools/{id}/questions.md", webHandler.TourQuestionsMarkdown)
+	r.Get("/schools/{id}/inquiry", webHandler.InquiryDraft)
+	r.Get("/schools/{id}/hours", webHandler.SchoolHoursStatus)
+	r.Get("/schools/{id}/note.md", webHandler.SchoolNote)
+	// The family's own plans (children, applications, outreach, and timeline)
+	// are private to editors and up on a shared server
+	editor.Get("/timeline.ics", webHandler.TimelineICS)
+	editor.Get("/timeline.csv", webHandler.TimelineCSV)
+	r.Get("/calendars", webHandler.CalendarsPage)
+	editor.With(limit).Post("/schools/{id}/calendar", webHandler.ExtractCalendar)
+	r.Get("/schools/{id}/calendar.ics", webHandler.CalendarICS)
+	editor.Get("/applications", webHandler.ApplicationsPage)
+	editor.Get("/applications/recap.md", webHandler.ApplicationsRecap)
+	editor.Get("/children", webHandler.ChildrenPage)
+	editor.Post("/children", webHandler.AddChild)
+	editor.Post("/children/{id}/delete", webHandler.DeleteChild)
+	editor.Post("/children/{id}/schools/{school}", webHandler.ToggleChildSchool)
+	editor.Post("/schools/{id}/bus", webHandler.SetHome)
+	editor.Post("/schools/{id}/calls", webHandler.LogCall)
+	editor.Post("/schools/{id}/calls/{call}/delete", webHandler.DeleteCall)
+	editor.Get("/outreach", webHandler.OutreachPage)
+	editor.Post("/schools/{id}/outreach", webHandler.RecordSchoolOutreach)
+	editor.Post("/outreach/{id}", webHandler.UpdateOutreachStatus)
+	r.Get("/districts/{id}", webHandler.DistrictPage)
+	editor.With(limit).Post("/districts/{id}/contacts", webHandler.ExtractDistrictContacts)
+	conditional.Get("/districts/{id}/schools", webHandler.DistrictSchools)
+	r.Get("/pipeline", webHandler.PipelinePage)
+	r.Get("/languages", webHandler.LanguagesPage)
+	editor.With(limit).Post("/schools/{id}/feeders", webHandler.InferFeeders)
+	r.Get("/area/{zip}", webHandler.AreaPage)
+	r.Get("/area/cbsa/{cbsa}", webHandler.MetroAreaPage)
+	r.Get("/area/county", webHandler.CountiesPage)
+	r.Get("/area/county/{fips}", webHandler.CountyPage)
+	r.Get("/area/cd", webHandler.CongressionalDistrictsPage)
+	r.Get("/area/cd/{cd}", webHandler.CongressionalDistrictPage)
+	r.Post("/area/naep/{id}", webHandler.AreaNAEP)
+	r.Get("/neighborhood", webHandler.NeighborhoodPage)
+	r.Get("/neighborhood/export", webHandler.ExportNeighborhood)
+	r.Get("/saved-searches", webHandler.SavedSearchesPage)
+	editor.Post("/saved-searches", webHandler.SaveSearch)
+	editor.Post("/saved-searches/check", webHandler.CheckSavedSearches)
+	editor.Post("/saved-searches/{id}/subscribe", webHandler.ToggleSavedSearch)
+	editor.Post("/saved-searches/{id}/delete", webHandler.DeleteSavedSearch)
+
+	// Compare basket routes
+	r.Get("/compare", webHandler.ComparePage)
+	editor.With(webHandler.requireCSRF, limit).Post("/compare/narrative", webHandler.CompareNarrative)
 
 	// AI Agent / Data Explorer routes
-	r.Get("/agent", webHandler.AgentPage)
-	r.Post("/agent/query", webHandler.AgentQuery)
-	r.Post("/agent/paginate", webHandler.AgentPaginate)
+	r.Get("/naep/raw", webHandler.NAEPRawResponse)
+	conditional.Get("/stats", webHandler.StatsPage)
+	conditional.Get("/stats/choropleth", webHandler.StatsChoropleth)
+	r.Get("/alerts", webHandler.AlertsPage)
+	editor.Post("/alerts/{id}/dismiss", webHandler.DismissAlert)
+	// The Data Explorer runs SQL a model writes, so it's for editors and up
+	editor.Get("/agent", webHandler.AgentPage)
+	editor.With(webHandler.requireCSRF, limit).Post("/agent/query", webHandler.AgentQuery)
+	editor.With(webHandler.requireCSRF).Post("/agent/paginate", webHandler.AgentPaginate)
+	editor.Get("/agent/export/{id}", webHandler.AgentExportCSV)
+	r.Get("/docs/schema", webHandler.SchemaPage)
 
 	// Data Import routes
 	r.Get("/import", webHandler.ImportPage)
-	r.Post("/import/upload", webHandler.ImportCSV)
+	editor.With(webHandler.requireCSRF, limit).Post("/import/upload", webHandler.ImportCSV)
+	editor.With(webHandler.requireCSRF).Post("/import/tables/{name}", webHandler.UpdateImportedTable)
 
 	// API handlers (JSON responses)
-	apiHandler := &APIHandler{DB: config.DB, AIScraper: config.AIScraper}
+	apiHandler := &APIHandler{DB: config.DB, AIScraper: config.AIScraper, NAEPClient: config.NAEPClient}
 	r.Route("/api", func(r chi.Router) {
-		r.Get("/search", apiHandler.Search)
-		r.Get("/schools/{id}", apiHandler.GetSchool)
-		r.Post("/schools/{id}/ai", apiHandler.ExtractAI)
+		r.With(conditionalGET(config.DB, etags)).Get("/search", apiHandler.Search)
+		r.Get("/suggest", apiHandler.Suggest)
+		r.With(conditionalGET(config.DB, etags)).Get("/schools/{id}", apiHandler.GetSchool)
+		r.With(conditionalGET(config.DB, etags)).Get("/v1/schools/{id}/bundle", apiHandler.GetSchoolBundle)
+		r.Get("/v1/lookup", apiHandler.Lookup)
+		r.With(conditionalGET(config.DB, etags)).Get("/v1/stats/choropleth", apiHandler.Choropleth)
+		r.With(acc.requireRole(RoleEditor, apiDenied), requireSameOrigin(apiDenied), config.RateLimiter.limit(apiRateLimited)).Post("/schools/{id}/ai", apiHandler.ExtractAI)
+		r.Get("/me", apiHandler.Me)
+		r.With(acc.requireRole(RoleEditor, apiDenied), requireSameOrigin(apiDenied)).Post("/v1/query", apiHandler.Query)
+		// Sync reads and overwrites private records, so it's only offered when
+		// admins have to sign in
+		if acc.multiUser() {
+			r.With(acc.requireRole(RoleAdmin, apiDenied)).Get("/v1/sync", apiHandler.SyncExport)
+			r.With(acc.requireRole(RoleAdmin, apiDenied), requireSameOrigin(apiDenied)).Post("/v1/sync", apiHandler.SyncImport)
+		}
+		r.Get("/metrics/retries", apiHandler.RetryMetrics)
 	})
 
-	addr := fmt.Sprintf(":%d", config.Port)
-	log.Printf("Starting server on http://localhost%s", addr)
-	return http.ListenAndServe(addr, r)
+	return r
 }
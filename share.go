@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"rsc.io/qr"
+)
+
+// defaultServePort is where `schoolfinder serve` listens unless --port is given;
+// the TUI assumes it when sharing a school
+const defaultServePort = 3000
+
+// qrQuietZone is the blank border, in modules, scanners need around a QR code
+const qrQuietZone = 4
+
+// shareBaseURL returns the root URL another device should use to reach this
+// server: SCHOOLFINDER_URL if set, otherwise the request's host with localhost
+// replaced by this machine's LAN address so a phone on the same network can open it
+func shareBaseURL(r *http.Request) string {
+	if base := strings.TrimSuffix(os.Getenv("SCHOOLFINDER_URL"), "/"); base != "" {
+		return base
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	host, port, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		host, port = r.Host, ""
+	}
+	if isLoopbackHost(host) {
+		if ip := lanIPv4(); ip != "" {
+			host = ip
+		}
+	}
+	if port != "" {
+		host = net.JoinHostPort(host, port)
+	}
+	return scheme + "://" + host
+}
+
+// tuiShareBaseURL is shareBaseURL for the TUI, which has no request: it points at
+// a server started with `schoolfinder serve` on the default port
+func tuiShareBaseURL() string {
+	if base := strings.TrimSuffix(os.Getenv("SCHOOLFINDER_URL"), "/"); base != "" {
+		return base
+	}
+	host := "localhost"
+	if ip := lanIPv4(); ip != "" {
+		host = ip
+	}
+	return fmt.Sprintf("http://%s:%d", host, defaultServePort)
+}
+
+func isLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// lanIPv4 returns this machine's first private IPv4 address, or "" if it has none
+func lanIPv4() string {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return ""
+	}
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		if ip := ipNet.IP.To4(); ip != nil && ip.IsPrivate() {
+			return ip.String()
+		}
+	}
+	return ""
+}
+
+// schoolShareURL is the address of a school's detail page under base
+func schoolShareURL(base, ncessch string) string {
+	return base + "/schools/" + ncessch
+}
+
+// qrSVG renders text as a QR code in SVG, one unit per module, with a
+// quiet zone, scaled to fill the element's width and height
+func qrSVG(text string) (template.HTML, error) {
+	code, err := qr.Encode(text, qr.M)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode QR code: %w", err)
+	}
+
+	size := code.Size + 2*qrQuietZone
+	var path strings.Builder
+	for y := 0; y < code.Size; y++ {
+		for x := 0; x < code.Size; x++ {
+			if code.Black(x, y) {
+				fmt.Fprintf(&path, "M%d %dh1v1h-1z", x+qrQuietZone, y+qrQuietZone)
+			}
+		}
+	}
+
+	svg := fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" shape-rendering="crispEdges" aria-hidden="true" focusable="false"><rect width="%d" height="%d" fill="#fff"/><path d="%s" fill="#000"/></svg>`,
+		size, size, size, size, path.String())
+	return template.HTML(svg), nil
+}
+
+// qrTerminal renders text as a QR code in half-block characters, two rows of
+// modules per line. Blocks are the light modules, so draw it white on black.
+func qrTerminal(text string) (string, error) {
+	code, err := qr.Encode(text, qr.L)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode QR code: %w", err)
+	}
+
+	// Modules outside the code are part of the light quiet zone
+	dark := func(x, y int) bool {
+		return x >= 0 && y >= 0 && x < code.Size && y < code.Size && code.Black(x, y)
+	}
+
+	var b strings.Builder
+	for y := -qrQuietZone; y < code.Size+qrQuietZone; y += 2 {
+		for x := -qrQuietZone; x < code.Size+qrQuietZone; x++ {
+			top, bottom := dark(x, y), dark(x, y+1)
+			switch {
+			case top && bottom:
+				b.WriteRune(' ')
+			case top:
+				b.WriteRune('▄')
+			case bottom:
+				b.WriteRune('▀')
+			default:
+				b.WriteRune('█')
+			}
+		}
+		b.WriteRune('\n')
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"golang.org/x/net/html"
+)
+
+func TestShareBaseURL(t *testing.T) {
+	t.Setenv("SCHOOLFINDER_URL", "")
+
+	req := httptest.NewRequest("GET", "/schools/360000100001/share", nil)
+	req.Host = "192.168.1.20:3000"
+	if got := shareBaseURL(req); got != "http://192.168.1.20:3000" {
+		t.Errorf("LAN host: %q", got)
+	}
+
+	// localhost means nothing to a phone; use the LAN address when there is one
+	req.Host = "localhost:3000"
+	got := shareBaseURL(req)
+	if ip := lanIPv4(); ip != "" && got != "http://"+ip+":3000" {
+		t.Errorf("localhost: %q, LAN address %s", got, ip)
+	}
+
+	t.Setenv("SCHOOLFINDER_URL", "https://schools.example.org/")
+	if got := shareBaseURL(req); got != "https://schools.example.org" {
+		t.Errorf("configured URL: %q", got)
+	}
+}
+
+func TestQRCode(t *testing.T) {
+	url := "http://192.168.1.20:3000/schools/360000100001"
+
+	code, err := qrTerminal(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(code, "\n"), "\n")
+	width := len([]rune(lines[0]))
+	if width < 21+2*qrQuietZone || len(lines) != (width+1)/2 {
+		t.Errorf("QR code is %d columns by %d lines", width, len(lines))
+	}
+	// The quiet zone is light all round
+	if strings.Trim(lines[0], "█") != "" {
+		t.Errorf("top row isn't blank: %q", lines[0])
+	}
+
+	svg, err := qrSVG(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(svg), "<svg") || !strings.Contains(string(svg), `<path d="M`) {
+		t.Errorf("svg = %.80s", svg)
+	}
+}
+
+func TestWebShareSchool(t *testing.T) {
+	t.Setenv("SCHOOLFINDER_URL", "https://schools.example.org")
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	router := NewRouter(ServerConfig{DB: db})
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/schools/360000100001/share", nil))
+	body := rec.Body.String()
+	if rec.Code != http.StatusOK || !strings.Contains(body, "https://schools.example.org/schools/360000100001") || !strings.Contains(body, "<svg") {
+		t.Fatalf("share: status %d, body %.200s", rec.Code, body)
+	}
+
+	doc, err := html.Parse(strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rule := range a11yRules {
+		if rule.fullPage {
+			continue
+		}
+		for _, problem := range rule.check(doc) {
+			t.Errorf("share partial %s: %s", rule.id, problem)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/schools/999999999999/share", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unknown school: status %d", rec.Code)
+	}
+}
+
+func TestDetailViewShareQR(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	m := initialModel(db, nil, nil, "")
+	m.width = 80
+	m.height = 24
+	m.viewportReady = true
+	m.currentView = detailView
+	m.selectedItem = MockSchool("123456", "Test School", "Test District", "CA", "PK", "05")
+
+	newModel, _ := m.handleDetailViewKeys(tea.KeyMsg{Type: tea.KeyCtrlR})
+	m = newModel.(model)
+	if m.shareQR == "" || !strings.Contains(m.detailViewRender(), "/schools/123456") {
+		t.Fatal("Ctrl+R didn't show the QR code")
+	}
+
+	newModel, _ = m.handleDetailViewKeys(tea.KeyMsg{Type: tea.KeyCtrlR})
+	if newModel.(model).shareQR != "" {
+		t.Error("second Ctrl+R didn't hide the QR code")
+	}
+}
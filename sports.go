@@ -0,0 +1,332 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Sports seasons, in school-year order
+const (
+	seasonFall   = "fall"
+	seasonWinter = "winter"
+	seasonSpring = "spring"
+	seasonSummer = "summer"
+)
+
+var sportSeasons = []string{seasonFall, seasonWinter, seasonSpring, seasonSummer}
+
+// SportOffering is a team a school fields, normalized from its website so
+// "Boys Swim & Dive (JV)" and "Swimming" are both Swimming
+type SportOffering struct {
+	Sport  string `json:"sport"`            // Canonical name, e.g. "Swimming"
+	Season string `json:"season,omitempty"` // seasonFall, seasonWinter, seasonSpring, or seasonSummer
+	Level  string `json:"level,omitempty"`  // e.g. "Varsity", "JV", or "Freshman"
+}
+
+// Summary describes the offering, e.g. "Swimming (winter, Varsity)"
+func (o SportOffering) Summary() string {
+	var details []string
+	for _, d := range []string{o.Season, o.Level} {
+		if d != "" {
+			details = append(details, d)
+		}
+	}
+	if len(details) == 0 {
+		return o.Sport
+	}
+	return o.Sport + " (" + strings.Join(details, ", ") + ")"
+}
+
+// canonicalSport is a sport's canonical name, its usual high school season
+// (empty where it varies by state), and the words websites use for it. More
+// specific sports come first so "flag football" isn't read as Football.
+type canonicalSport struct {
+	name    string
+	season  string
+	pattern *regexp.Regexp
+}
+
+func sport(name, season, aliases string) canonicalSport {
+	return canonicalSport{name: name, season: season, pattern: regexp.MustCompile(`(?i)\b(` + aliases + `)`)}
+}
+
+var canonicalSports = []canonicalSport{
+	sport("Flag Football", seasonSpring, `flag football`),
+	sport("Football", seasonFall, `football`),
+	sport("Field Hockey", seasonFall, `field hockey`),
+	sport("Ice Hockey", seasonWinter, `ice hockey|hockey`),
+	sport("Beach Volleyball", seasonSpring, `beach volleyball|sand volleyball`),
+	sport("Volleyball", seasonFall, `volleyball`),
+	sport("Water Polo", seasonFall, `water polo`),
+	sport("Swimming", "", `swim|diving|dive team`),
+	sport("Cross Country", seasonFall, `cross[- ]country|xc\b`),
+	sport("Track & Field", seasonSpring, `track|field events`),
+	sport("Basketball", seasonWinter, `basketball`),
+	sport("Baseball", seasonSpring, `baseball`),
+	sport("Softball", seasonSpring, `softball`),
+	sport("Soccer", "", `soccer|futbol`),
+	sport("Wrestling", seasonWinter, `wrestl`),
+	sport("Lacrosse", seasonSpring, `lacrosse`),
+	sport("Tennis", "", `tennis`),
+	sport("Golf", "", `golf`),
+	sport("Gymnastics", seasonWinter, `gymnastic`),
+	sport("Cheerleading", "", `cheer|spirit squad`),
+	sport("Dance", "", `dance|drill team|pom\b`),
+	sport("Badminton", seasonSpring, `badminton`),
+	sport("Bowling", seasonWinter, `bowling`),
+	sport("Skiing", seasonWinter, `ski\b|skiing|snowboard`),
+	sport("Rowing", seasonSpring, `rowing|crew\b`),
+	sport("Rugby", seasonSpring, `rugby`),
+	sport("Ultimate", seasonSpring, `ultimate`),
+	sport("Fencing", seasonWinter, `fencing`),
+	sport("Esports", "", `e-?sports`),
+	sport("Archery", "", `archery`),
+	sport("Equestrian", "", `equestrian|rodeo`),
+	sport("Sailing", "", `sailing`),
+	sport("Climbing", "", `climbing`),
+	sport("Mountain Biking", "", `mountain bik|cycling`),
+}
+
+// sportLevelPatterns map the team levels websites name to display labels
+var sportLevelPatterns = []struct {
+	level   string
+	pattern *regexp.Regexp
+}{
+	{"Varsity", regexp.MustCompile(`(?i)\bvarsity\b`)},
+	{"JV", regexp.MustCompile(`(?i)\b(jv|junior varsity)\b`)},
+	{"Freshman", regexp.MustCompile(`(?i)\b(freshman|frosh|9th grade)\b`)},
+	{"Middle School", regexp.MustCompile(`(?i)\bmiddle school\b`)},
+	{"Intramural", regexp.MustCompile(`(?i)\bintramural`)},
+	{"Club", regexp.MustCompile(`(?i)\bclub\b`)},
+}
+
+// juniorVarsity is removed before looking for varsity, which "junior varsity"
+// would otherwise match
+var juniorVarsity = regexp.MustCompile(`(?i)\bjunior varsity\b`)
+
+// sportSeasonPattern matches a season named on a website, e.g. "Girls Golf (Fall)"
+var sportSeasonPattern = regexp.MustCompile(`(?i)\b(fall|autumn|winter|spring|summer)\b`)
+
+// findSport returns the canonical sport named in text, if any
+func findSport(text string) (canonicalSport, bool) {
+	for _, s := range canonicalSports {
+		if s.pattern.MatchString(text) {
+			return s, true
+		}
+	}
+	return canonicalSport{}, false
+}
+
+// CanonicalSportName returns the canonical name of a sport as typed, e.g.
+// "Swimming" for "swim & dive"
+func CanonicalSportName(text string) (string, bool) {
+	s, ok := findSport(text)
+	return s.name, ok
+}
+
+// sportSeason reads the season named in text, or the sport's usual season
+func sportSeason(text string, s canonicalSport) string {
+	if m := sportSeasonPattern.FindString(text); m != "" {
+		if season := strings.ToLower(m); season != "autumn" {
+			return season
+		}
+		return seasonFall
+	}
+	return s.season
+}
+
+// sportLevels reads the team levels named in text, e.g. "Varsity & JV Basketball"
+func sportLevels(text string) []string {
+	var levels []string
+	for _, l := range sportLevelPatterns {
+		match := l.pattern.MatchString(text)
+		if l.level == "Varsity" {
+			match = l.pattern.MatchString(juniorVarsity.ReplaceAllString(text, ""))
+		}
+		if match {
+			levels = append(levels, l.level)
+		}
+	}
+	return levels
+}
+
+// sportOfferings normalizes one sport as a website lists it into an offering
+// per team level. Sports not in canonicalSports keep their listed name.
+func sportOfferings(name, season, levels string) []SportOffering {
+	name = strings.TrimSpace(name)
+	if name == "" || careUnknown.MatchString(name) || strings.EqualFold(name, "none") {
+		return nil
+	}
+	text := name + " " + season + " " + levels
+
+	offering := SportOffering{Sport: name}
+	if s, ok := findSport(name); ok {
+		offering.Sport = s.name
+		offering.Season = sportSeason(text, s)
+	} else {
+		offering.Season = sportSeason(text, canonicalSport{})
+	}
+
+	found := sportLevels(text)
+	if len(found) == 0 {
+		return []SportOffering{offering}
+	}
+	offerings := make([]SportOffering, len(found))
+	for i, level := range found {
+		offerings[i] = offering
+		offerings[i].Level = level
+	}
+	return offerings
+}
+
+// sportLinePattern and conferenceLinePattern match the athletics lines the
+// extraction prompt asks for, e.g. "- Sport: Swimming | winter | Varsity, JV"
+// and "- Athletic conference: West Bay Athletic League", allowing for
+// markdown bold and bullets
+var (
+	sportLinePattern      = regexp.MustCompile(`(?im)^[\s>*+-]*\**sport\**\s*:\**\s*(.+)$`)
+	conferenceLinePattern = regexp.MustCompile(`(?im)^[\s>*+-]*\**athletic (?:conference|league)\**\s*:\**\s*(.+)$`)
+)
+
+// parseAthleticsSection reads the sports teams and athletic conference from
+// the extracted markdown's "Athletics" lines
+func parseAthleticsSection(markdown string) (offerings []SportOffering, conference string) {
+	for _, m := range sportLinePattern.FindAllStringSubmatch(markdown, -1) {
+		fields := strings.Split(m[1], "|")
+		for i := range fields {
+			fields[i] = strings.Trim(strings.TrimSpace(fields[i]), "*")
+		}
+		for len(fields) < 3 {
+			fields = append(fields, "")
+		}
+		offerings = append(offerings, sportOfferings(fields[0], fields[1], fields[2])...)
+	}
+	if m := conferenceLinePattern.FindStringSubmatch(markdown); m != nil {
+		if conference = strings.Trim(strings.TrimSpace(m[1]), "*"); careUnknown.MatchString(conference) {
+			conference = ""
+		}
+	}
+	return offerings, conference
+}
+
+// normalizeSports merges the extracted athletics with the free-text sports
+// list, one offering per sport and level, ordered by season then sport
+func normalizeSports(athletics []SportOffering, sports []string) []SportOffering {
+	var all []SportOffering
+	for _, o := range athletics {
+		all = append(all, sportOfferings(o.Sport, o.Season, o.Level)...)
+	}
+	for _, s := range sports {
+		all = append(all, sportOfferings(s, "", "")...)
+	}
+
+	seen := make(map[SportOffering]bool)
+	var offerings []SportOffering
+	for _, o := range all {
+		key := SportOffering{Sport: o.Sport, Level: o.Level}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		offerings = append(offerings, o)
+	}
+	// A sport listed at specific levels doesn't also need a row without one
+	offerings = slices.DeleteFunc(offerings, func(o SportOffering) bool {
+		if o.Level != "" {
+			return false
+		}
+		return slices.ContainsFunc(offerings, func(other SportOffering) bool {
+			return other.Sport == o.Sport && other.Level != ""
+		})
+	})
+
+	seasonRank := func(season string) int {
+		if i := slices.Index(sportSeasons, season); i >= 0 {
+			return i
+		}
+		return len(sportSeasons)
+	}
+	slices.SortStableFunc(offerings, func(a, b SportOffering) int {
+		if d := seasonRank(a.Season) - seasonRank(b.Season); d != 0 {
+			return d
+		}
+		return strings.Compare(a.Sport, b.Sport)
+	})
+	return offerings
+}
+
+// SportOfferings returns the school's teams normalized by sport, season, and level
+func (data *EnhancedSchoolData) SportOfferings() []SportOffering {
+	return normalizeSports(data.Athletics, data.Sports)
+}
+
+// sportTerms splits a comma-separated sports filter, e.g. "swimming, tennis"
+func sportTerms(filter string) []string {
+	var terms []string
+	for _, term := range strings.Split(filter, ",") {
+		if term = strings.TrimSpace(term); term != "" {
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// SaveSports replaces a school's normalized sports and athletic conference
+// with those in its extracted website data
+func (d *DB) SaveSports(data *EnhancedSchoolData) error {
+	tx, err := d.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.Exec(`DELETE FROM school_sports WHERE ncessch = $1`, data.NCESSCH); err != nil {
+		return fmt.Errorf("failed to clear sports: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM school_athletic_conferences WHERE ncessch = $1`, data.NCESSCH); err != nil {
+		return fmt.Errorf("failed to clear athletic conference: %w", err)
+	}
+	for _, o := range data.SportOfferings() {
+		if _, err := tx.Exec(`INSERT INTO school_sports (ncessch, sport, season, level) VALUES ($1, $2, $3, $4)`,
+			data.NCESSCH, o.Sport, o.Season, o.Level); err != nil {
+			return fmt.Errorf("failed to save sport: %w", err)
+		}
+	}
+	if data.AthleticConference != "" {
+		if _, err := tx.Exec(`INSERT INTO school_athletic_conferences (ncessch, conference) VALUES ($1, $2)`,
+			data.NCESSCH, data.AthleticConference); err != nil {
+			return fmt.Errorf("failed to save athletic conference: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to save sports: %w", err)
+	}
+	return nil
+}
+
+// SportNames lists the sports schools field, most common first, for
+// suggesting sports filters
+func (d *DB) SportNames() ([]string, error) {
+	rows, err := d.conn.Query(`
+		SELECT sport FROM school_sports
+		GROUP BY sport
+		ORDER BY count(DISTINCT ncessch) DESC, sport
+		LIMIT 50
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sports: %w", err)
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to scan sport: %w", err)
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
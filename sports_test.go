@@ -0,0 +1,144 @@
+package main
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNormalizeSports(t *testing.T) {
+	markdown := `## Athletics
+- **Athletic conference:** West Bay Athletic League (CCS)
+- Sport: Swim & Dive | not published | Varsity, JV
+- Sport: Girls Golf | Fall | not published
+- Sport: Quidditch | spring | Club
+- Sport: none | not published | not published`
+	athletics, conference := parseAthleticsSection(markdown)
+	if conference != "West Bay Athletic League (CCS)" {
+		t.Errorf("conference = %q", conference)
+	}
+
+	var got []string
+	for _, o := range normalizeSports(athletics, []string{"Boys Junior Varsity Basketball", "swimming", "Flag Football", "Girls Golf"}) {
+		got = append(got, o.Summary())
+	}
+	want := []string{
+		"Golf (fall)",
+		"Basketball (winter, JV)",
+		"Flag Football (spring)",
+		"Quidditch (spring, Club)",
+		"Swimming (Varsity)", // Swim season varies by state, so it isn't guessed
+		"Swimming (JV)",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("normalizeSports() =\n%q\nwant\n%q", got, want)
+	}
+
+	if name, ok := CanonicalSportName("cross-country"); !ok || name != "Cross Country" {
+		t.Errorf("CanonicalSportName(cross-country) = %q, %v", name, ok)
+	}
+}
+
+func TestSportsFilter(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	// Washington High is in San Francisco, Roosevelt Charter High in New York
+	for id, website := range map[string]*EnhancedSchoolData{
+		"360000100002": {Sports: []string{"Varsity Swimming", "Tennis"}, MarkdownContent: "- Athletic conference: West Bay Athletic League"},
+		"360000100004": {Sports: []string{"Swim Team", "Basketball"}},
+		"360000100003": {MarkdownContent: "- Sport: Tennis | spring | Varsity"},
+	} {
+		website.NCESSCH, website.ExtractedAt = id, time.Now()
+		if err := saveEnhancedData(db, website); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := db.conn.Exec(`INSERT INTO school_coordinates VALUES ('360000100002', 37.7749, -122.4194), ('360000100004', 40.7128, -74.0060)`); err != nil {
+		t.Fatal(err)
+	}
+
+	search := func(filters SearchFilters) string {
+		t.Helper()
+		schools, err := db.SearchSchoolsFiltered(filters, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, s := range schools {
+			ids = append(ids, s.NCESSCH[len(s.NCESSCH)-2:])
+		}
+		slices.Sort(ids)
+		return strings.Join(ids, ",")
+	}
+	if got := search(SearchFilters{Sports: "swim"}); got != "02,04" {
+		t.Errorf("swimming = %s", got)
+	}
+	if got := search(SearchFilters{Sports: "swimming, tennis"}); got != "02" {
+		t.Errorf("swimming and tennis = %s", got)
+	}
+	if got := search(SearchFilters{Query: `conference:"west bay"`}); got != "02" {
+		t.Errorf("conference = %s", got)
+	}
+
+	// Distance is measured from coordinates, or from the saved home
+	if got := search(SearchFilters{Sports: "swimming", WithinMiles: 15, Near: "37.8044,-122.2712"}); got != "02" {
+		t.Errorf("swimming within 15 miles of Oakland = %s", got)
+	}
+	if _, err := db.SearchSchoolsFiltered(SearchFilters{Query: "sport:swimming within:15"}, 100); err == nil {
+		t.Error("distance search without a home location succeeded")
+	}
+	if _, err := SaveHome(db, "40.7306,-73.9352", "Home"); err != nil {
+		t.Fatal(err)
+	}
+	if got := search(SearchFilters{Query: "sport:swimming within:15mi"}); got != "04" {
+		t.Errorf("swimming within 15 miles of home = %s", got)
+	}
+
+	filters, err := SearchFiltersFromValues(url.Values{"sports": {"swimming"}, "within_miles": {"15"}, "near": {"Oakland, CA"}})
+	if err != nil || filters.Summary() != "with swimming, within 15 mi of Oakland, CA" || filters.DrawerFilterCount() != 2 {
+		t.Errorf("filters = %+v (%q), %v", filters, filters.Summary(), err)
+	}
+	if _, err := ParseSearchQuery("within:far"); err == nil {
+		t.Error("within:far was accepted")
+	}
+
+	// The school page lists the normalized teams and the conference
+	router := NewRouter(ServerConfig{DB: db, AIScraper: &AIScraperService{db: db, cacheTTL: time.Hour}})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/schools/360000100002/ai", nil))
+	body := rec.Body.String()
+	for _, want := range []string{`<a href="/?sports=Swimming">Swimming</a>`, "West Bay Athletic League"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("website data is missing %q", want)
+		}
+	}
+}
+
+func TestSyncWebsiteTables(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	// Saved straight to the cache, as before these tables existed or by a sync
+	markdown := "- Sport: Tennis | spring | Varsity\n- Arts program: Stagecraft\n- Language: Spanish | course\n- Pathway: Culinary Arts | Hospitality & Tourism"
+	if err := db.SaveAIScraperCache("360000100002", "Washington High School", "https://washington.example", markdown, nil, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := SyncWebsiteTables(db)
+	if err != nil || n != 1 {
+		t.Fatalf("SyncWebsiteTables = %d, %v; want 1 school", n, err)
+	}
+	for _, table := range []string{"school_sports", "school_arts", "school_languages", "school_cte_pathways"} {
+		var count int
+		if err := db.conn.QueryRow(`SELECT count(*) FROM ` + table + ` WHERE ncessch = '360000100002'`).Scan(&count); err != nil || count != 1 {
+			t.Errorf("%s has %d rows, %v; want 1", table, count, err)
+		}
+	}
+	if n, err := SyncWebsiteTables(db); err != nil || n != 0 {
+		t.Errorf("second SyncWebsiteTables = %d, %v; want nothing to do", n, err)
+	}
+}
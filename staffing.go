@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// staffFilePattern matches CCD district (LEA) staff files. CCD reports counselors,
+// aides, administrators, and support staff by district; the school staff file
+// (ccd_sch_059) has teachers only.
+const staffFilePattern = "ccd_lea_059_*.csv"
+
+// recommendedStudentsPerCounselor is the American School Counselor Association's
+// recommended maximum caseload
+const recommendedStudentsPerCounselor = 250
+
+// staffGroups are the staffing composition groups in display order, each with the
+// lowercase keywords that place a CCD STAFF category in it. Categories are matched
+// against the groups in order and fall into the last group when nothing matches.
+var staffGroups = []struct {
+	name     string
+	keywords []string
+}{
+	{"Teachers", []string{"teacher"}},
+	{"Counselors", []string{"counselor"}},
+	{"Instructional aides", []string{"aide", "paraprofessional"}},
+	{"Administrative support", []string{"administrative support"}},
+	{"Administrators", []string{"administrator", "coordinator"}},
+	{"Student support", []string{"student support", "psycholog", "librar", "media"}},
+	{"Other support", nil},
+}
+
+// staffGroupSQL classifies a CCD STAFF category column into a staffing group
+func staffGroupSQL(column string) string {
+	var b strings.Builder
+	b.WriteString("CASE")
+	for _, group := range staffGroups {
+		if len(group.keywords) == 0 {
+			fmt.Fprintf(&b, " ELSE '%s'", group.name)
+			continue
+		}
+		var matches []string
+		for _, keyword := range group.keywords {
+			matches = append(matches, fmt.Sprintf("LOWER(%s) LIKE '%%%s%%'", column, keyword))
+		}
+		fmt.Fprintf(&b, " WHEN %s THEN '%s'", strings.Join(matches, " OR "), group.name)
+	}
+	b.WriteString(" END")
+	return b.String()
+}
+
+// StaffingGroup is the full-time equivalent staff in one staffing group
+type StaffingGroup struct {
+	Name  string
+	Slug  string  // CSS-friendly name, e.g. "instructional-aides"
+	FTE   float64 // Full-time equivalent staff
+	Share float64 // Percentage of all staff
+}
+
+// DistrictStaffing is a district's staffing composition from the CCD staff file
+type DistrictStaffing struct {
+	LEAID    string
+	Groups   []StaffingGroup // In staffGroups order, omitting empty groups
+	TotalFTE float64
+	Students int64 // District enrollment, summed from its schools
+}
+
+// Counselors returns the district's counselor FTE
+func (s DistrictStaffing) Counselors() float64 {
+	for _, g := range s.Groups {
+		if g.Name == "Counselors" {
+			return g.FTE
+		}
+	}
+	return 0
+}
+
+// StudentsPerCounselor returns students per counselor FTE, or zero when either is unreported
+func (s DistrictStaffing) StudentsPerCounselor() float64 {
+	counselors := s.Counselors()
+	if counselors == 0 || s.Students == 0 {
+		return 0
+	}
+	return float64(s.Students) / counselors
+}
+
+// CounselorRatio formats the student/counselor ratio, e.g. "412:1"
+func (s DistrictStaffing) CounselorRatio() string {
+	ratio := s.StudentsPerCounselor()
+	if ratio == 0 {
+		return "N/A"
+	}
+	return fmt.Sprintf("%.0f:1", ratio)
+}
+
+// CounselorsOverloaded reports whether counselors have more students than recommended
+func (s DistrictStaffing) CounselorsOverloaded() bool {
+	return s.StudentsPerCounselor() > recommendedStudentsPerCounselor
+}
+
+// RecommendedStudentsPerCounselor is the recommended caseload, for templates
+func (s DistrictStaffing) RecommendedStudentsPerCounselor() int {
+	return recommendedStudentsPerCounselor
+}
+
+// newDistrictStaffing orders a district's staffing groups and computes each group's share
+func newDistrictStaffing(leaid string, fte map[string]float64, students int64) DistrictStaffing {
+	staffing := DistrictStaffing{LEAID: leaid, Students: students}
+	for _, group := range staffGroups {
+		if fte[group.name] > 0 {
+			staffing.TotalFTE += fte[group.name]
+		}
+	}
+	for _, group := range staffGroups {
+		value := fte[group.name]
+		if value <= 0 {
+			continue
+		}
+		staffing.Groups = append(staffing.Groups, StaffingGroup{
+			Name:  group.name,
+			Slug:  strings.ReplaceAll(strings.ToLower(group.name), " ", "-"),
+			FTE:   value,
+			Share: value / staffing.TotalFTE * 100,
+		})
+	}
+	return staffing
+}
+
+// SyncStaffing loads the most recent district staff file in the data directory if it
+// hasn't been loaded yet. It reports whether a new file was loaded.
+func SyncStaffing(db *DB) (bool, error) {
+	paths, err := filepath.Glob(filepath.Join(db.dataDir, staffFilePattern))
+	if err != nil {
+		return false, fmt.Errorf("failed to list staff files: %w", err)
+	}
+	if len(paths) == 0 {
+		return false, nil
+	}
+
+	// File names carry the school year, so the last one is the most recent
+	sort.Strings(paths)
+	loaded, err := db.loadStaffFile(paths[len(paths)-1])
+	if err != nil {
+		return false, err
+	}
+
+	if loaded && logger != nil {
+		logger.Info("District staffing loaded", "file", filepath.Base(paths[len(paths)-1]))
+	}
+	return loaded, nil
+}
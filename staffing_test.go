@@ -0,0 +1,89 @@
+package main
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// districtStaffFile is a CCD district staff file for San Francisco (0600000), whose
+// only school has 500 students. Derived subtotals repeat the category rows and must
+// not be counted twice.
+const districtStaffFile = `LEAID,STAFF,STAFF_COUNT,TOTAL_INDICATOR
+0600000,Elementary Teachers,20,Category Set A
+0600000,Secondary Teachers,5.5,Category Set A
+0600000,Teachers,25.5,Derived - Subtotal
+0600000,Elementary School Counselors,1,Category Set A
+0600000,Secondary School Counselors,0.5,Category Set A
+0600000,School Counselors,1.5,Derived - Subtotal
+0600000,Instructional Aides,6,Category Set A
+0600000,School Administrators,2,Category Set A
+0600000,School Administrative Support Staff,3,Category Set A
+0600000,School Psychologist,1,Category Set A
+0600000,All Other Support Staff,1,Category Set A
+0600000,Total Staff,40,Education Unit Total
+4800000,Teachers,30,Category Set A
+`
+
+func TestDistrictStaffing(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	if loaded, err := SyncStaffing(db); err != nil || loaded {
+		t.Fatalf("SyncStaffing() without a staff file = %v, %v", loaded, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(db.dataDir, "ccd_lea_059_2324_l_1a_073124.csv"), []byte(districtStaffFile), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if loaded, err := SyncStaffing(db); err != nil || !loaded {
+		t.Fatalf("SyncStaffing() = %v, %v", loaded, err)
+	}
+	if loaded, err := SyncStaffing(db); err != nil || loaded {
+		t.Errorf("second SyncStaffing() = %v, %v, want false", loaded, err)
+	}
+
+	staffing, err := db.DistrictStaffing([]string{"0600000", "4800000", "3600000"})
+	if err != nil {
+		t.Fatalf("DistrictStaffing() error = %v", err)
+	}
+	if len(staffing) != 2 {
+		t.Fatalf("DistrictStaffing() = %+v, want two districts", staffing)
+	}
+
+	sf := staffing["0600000"]
+	want := []struct {
+		name string
+		fte  float64
+	}{
+		{"Teachers", 25.5},
+		{"Counselors", 1.5},
+		{"Instructional aides", 6},
+		{"Administrative support", 3},
+		{"Administrators", 2},
+		{"Student support", 1},
+		{"Other support", 1},
+	}
+	if len(sf.Groups) != len(want) {
+		t.Fatalf("Groups = %+v", sf.Groups)
+	}
+	for i, w := range want {
+		if sf.Groups[i].Name != w.name || sf.Groups[i].FTE != w.fte {
+			t.Errorf("Groups[%d] = %s %.1f, want %s %.1f", i, sf.Groups[i].Name, sf.Groups[i].FTE, w.name, w.fte)
+		}
+	}
+	if sf.TotalFTE != 40 || math.Abs(sf.Groups[0].Share-63.75) > 0.001 {
+		t.Errorf("TotalFTE = %.1f, teacher share = %.2f", sf.TotalFTE, sf.Groups[0].Share)
+	}
+
+	// 500 students / 1.5 counselors
+	if sf.Students != 500 || sf.CounselorRatio() != "333:1" || !sf.CounselorsOverloaded() {
+		t.Errorf("Students = %d, CounselorRatio() = %q, overloaded = %v", sf.Students, sf.CounselorRatio(), sf.CounselorsOverloaded())
+	}
+
+	// No counselors reported
+	if houston := staffing["4800000"]; houston.CounselorRatio() != "N/A" || houston.CounselorsOverloaded() {
+		t.Errorf("Houston CounselorRatio() = %q", houston.CounselorRatio())
+	}
+}
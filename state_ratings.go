@@ -0,0 +1,371 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// StateRatingSource downloads one state's published report card ratings. To
+// add a state, implement this in a state_ratings_<state>.go file and list it in
+// stateRatingSources: the refresh downloads URLs, hands each file to Parse, and
+// matches the ratings to CCD schools by state school ID or NCES ID.
+type StateRatingSource interface {
+	ID() string    // Stable name used in commands and the state_ratings table, e.g. "ca-dashboard"
+	State() string // Two-letter state code, e.g. "CA"
+	Name() string  // Publisher's name for the ratings, e.g. "California School Dashboard"
+	// URLs returns the files to download for a reporting year, e.g. 2024
+	URLs(year int) []string
+	// Parse reads one downloaded file; url is where it came from, for provenance
+	Parse(r io.Reader, url string, year int) ([]StateRating, error)
+}
+
+// stateRatingSources are the states whose ratings can be refreshed
+var stateRatingSources = []StateRatingSource{
+	caDashboardSource{},
+	txAccountabilitySource{},
+}
+
+// stateRatingSourceByID finds a registered source
+func stateRatingSourceByID(id string) (StateRatingSource, error) {
+	for _, s := range stateRatingSources {
+		if strings.EqualFold(s.ID(), id) {
+			return s, nil
+		}
+	}
+	var ids []string
+	for _, s := range stateRatingSources {
+		ids = append(ids, s.ID())
+	}
+	return nil, fmt.Errorf("unknown ratings source %q (available: %s)", id, strings.Join(ids, ", "))
+}
+
+// StateRating is one official rating a state published for a school
+type StateRating struct {
+	NCESSCH       string   // Set by sources that publish NCES IDs, or by matching StateSchoolID
+	StateSchoolID string   // The state's school ID, e.g. a California CDS code
+	SchoolYear    string   // e.g. "2023-24"
+	Indicator     string   // What is rated, e.g. "Overall" or "Chronic Absenteeism"
+	Rating        string   // The published rating, e.g. "B" or "Orange"
+	Score         *float64 // The number behind the rating, when published
+	SourceURL     string   // The file the rating was read from
+
+	Source    string    // Set from the source's ID when saved
+	FetchedAt time.Time // Set when saved
+}
+
+// ScoreLabel formats the score behind a rating, or "" when none was published
+func (r StateRating) ScoreLabel() string {
+	if r.Score == nil {
+		return ""
+	}
+	return formatChartValue(*r.Score)
+}
+
+// schoolYearLabel turns a reporting year into its school year, e.g. 2024 into "2023-24"
+func schoolYearLabel(year int) string {
+	return fmt.Sprintf("%d-%02d", year-1, year%100)
+}
+
+// ratingsTable is a delimited ratings file read for parsing
+type ratingsTable struct {
+	columns map[string]int // Lowercased header name to index
+	rows    [][]string
+}
+
+// readRatingsTable reads a delimited file with a header row, such as the CSV
+// and tab-delimited files states publish
+func readRatingsTable(r io.Reader, comma rune) (*ratingsTable, error) {
+	reader := csv.NewReader(r)
+	reader.Comma = comma
+	reader.LazyQuotes = true
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("file is empty")
+	}
+	t := &ratingsTable{columns: map[string]int{}, rows: records[1:]}
+	for i, name := range records[0] {
+		t.columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	return t, nil
+}
+
+// column returns the index of the first of names in the header, or -1 and an
+// error naming them all, since states rename columns between years
+func (t *ratingsTable) column(names ...string) (int, error) {
+	for _, name := range names {
+		if i, ok := t.columns[strings.ToLower(name)]; ok {
+			return i, nil
+		}
+	}
+	return -1, fmt.Errorf("no %s column", strings.Join(names, " or "))
+}
+
+// value returns a row's trimmed value at index i
+func (t *ratingsTable) value(row []string, i int) string {
+	if i < 0 || i >= len(row) {
+		return ""
+	}
+	return strings.TrimSpace(row[i])
+}
+
+// stateRatingsClient downloads ratings files; state servers can be slow
+var stateRatingsClient = &http.Client{Timeout: 5 * time.Minute}
+
+// openRatingsFile opens a ratings file from a URL or a local path
+func openRatingsFile(ctx context.Context, location string) (io.ReadCloser, error) {
+	if !strings.HasPrefix(location, "http://") && !strings.HasPrefix(location, "https://") {
+		f, err := os.Open(location)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open ratings file: %w", err)
+		}
+		return f, nil
+	}
+
+	var body io.ReadCloser
+	err := downloadRetry.Do(ctx, func() error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := stateRatingsClient.Do(req)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return newHTTPStatusError(resp)
+		}
+		body = resp.Body
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", location, err)
+	}
+	return body, nil
+}
+
+// StateRatingRefresh records one refresh of a source's ratings
+type StateRatingRefresh struct {
+	Source      string
+	SchoolYear  string
+	Files       []string
+	Ratings     int // Ratings read from the files
+	Matched     int // Ratings matched to a CCD school and saved
+	RefreshedAt time.Time
+}
+
+// RefreshStateRatings downloads and parses a source's ratings for a reporting
+// year, and replaces that year's saved ratings. files overrides the source's
+// URLs with other URLs or local copies.
+func RefreshStateRatings(ctx context.Context, db *DB, sourceID string, year int, files []string) (*StateRatingRefresh, error) {
+	source, err := stateRatingSourceByID(sourceID)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		files = source.URLs(year)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%s has no files for %d", source.Name(), year)
+	}
+
+	var ratings []StateRating
+	for _, file := range files {
+		r, err := openRatingsFile(ctx, file)
+		if err != nil {
+			return nil, err
+		}
+		parsed, err := source.Parse(r, file, year)
+		r.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+		}
+		ratings = append(ratings, parsed...)
+	}
+	if len(ratings) == 0 {
+		return nil, fmt.Errorf("no ratings found in %s", strings.Join(files, ", "))
+	}
+
+	keys, err := db.stateSchoolKeys(source.State())
+	if err != nil {
+		return nil, err
+	}
+	refresh := &StateRatingRefresh{
+		Source:      source.ID(),
+		SchoolYear:  ratings[0].SchoolYear,
+		Files:       files,
+		Ratings:     len(ratings),
+		RefreshedAt: time.Now(),
+	}
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// A refresh replaces the year's ratings, so withdrawn ratings don't linger
+	years := map[string]bool{}
+	for _, r := range ratings {
+		years[r.SchoolYear] = true
+	}
+	for y := range years {
+		if _, err := tx.Exec(`DELETE FROM state_ratings WHERE source = $1 AND school_year = $2`, source.ID(), y); err != nil {
+			return nil, fmt.Errorf("failed to clear previous ratings: %w", err)
+		}
+	}
+	stmt, err := tx.Prepare(`
+		INSERT OR REPLACE INTO state_ratings (ncessch, state, source, school_year, indicator, rating, score, source_url, fetched_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare ratings insert: %w", err)
+	}
+	defer stmt.Close()
+	for _, r := range ratings {
+		ncessch := r.NCESSCH
+		if ncessch == "" {
+			ncessch = keys.lookup(r.StateSchoolID)
+		}
+		if ncessch == "" {
+			continue
+		}
+		if _, err := stmt.Exec(ncessch, source.State(), source.ID(), r.SchoolYear, r.Indicator, r.Rating, r.Score, r.SourceURL, refresh.RefreshedAt); err != nil {
+			return nil, fmt.Errorf("failed to save rating: %w", err)
+		}
+		refresh.Matched++
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO state_rating_refreshes (source, school_year, files, ratings, matched, refreshed_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, refresh.Source, refresh.SchoolYear, strings.Join(files, "\n"), refresh.Ratings, refresh.Matched, refresh.RefreshedAt); err != nil {
+		return nil, fmt.Errorf("failed to record refresh: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to save ratings: %w", err)
+	}
+
+	if logger != nil {
+		logger.Info("State ratings refreshed", "source", refresh.Source, "year", refresh.SchoolYear, "ratings", refresh.Ratings, "matched", refresh.Matched)
+	}
+	return refresh, nil
+}
+
+// nonDigits strips state school IDs down to the digits they're compared by
+var nonDigits = regexp.MustCompile(`[^0-9]`)
+
+// stateSchoolKeys maps a state's school IDs to NCES IDs
+type stateSchoolKeys map[string]string
+
+// lookup finds the NCES ID for a state school ID, ignoring punctuation and
+// leading apostrophes that spreadsheets add
+func (k stateSchoolKeys) lookup(stateID string) string {
+	digits := nonDigits.ReplaceAllString(stateID, "")
+	if digits == "" {
+		return ""
+	}
+	return k[digits]
+}
+
+// stateSchoolKeys loads the state's CCD ST_SCHID values, e.g. "CA-0161119-0130401".
+// Both the whole ID and its last part are keys, since states publish either
+// (California's 14-digit CDS code, Texas's 9-digit campus number); keys shared
+// by more than one school are dropped.
+func (d *DB) stateSchoolKeys(state string) (stateSchoolKeys, error) {
+	keys := stateSchoolKeys{}
+	var hasStateIDs int
+	if err := d.conn.QueryRow(`SELECT count(*) FROM duckdb_columns() WHERE table_name = 'directory' AND column_name = 'ST_SCHID'`).Scan(&hasStateIDs); err != nil {
+		return nil, fmt.Errorf("failed to check directory columns: %w", err)
+	}
+	if hasStateIDs == 0 {
+		return keys, nil
+	}
+
+	rows, err := d.conn.Query(`SELECT NCESSCH, ST_SCHID FROM directory WHERE ST = $1 AND ST_SCHID IS NOT NULL`, state)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load state school IDs: %w", err)
+	}
+	defer rows.Close()
+
+	ambiguous := map[string]bool{}
+	for rows.Next() {
+		var ncessch, stateID string
+		if err := rows.Scan(&ncessch, &stateID); err != nil {
+			return nil, fmt.Errorf("failed to scan state school ID: %w", err)
+		}
+		parts := strings.Split(stateID, "-")
+		for _, key := range []string{nonDigits.ReplaceAllString(stateID, ""), nonDigits.ReplaceAllString(parts[len(parts)-1], "")} {
+			if key == "" || ambiguous[key] {
+				continue
+			}
+			if existing, ok := keys[key]; ok && existing != ncessch {
+				delete(keys, key)
+				ambiguous[key] = true
+				continue
+			}
+			keys[key] = ncessch
+		}
+	}
+	return keys, rows.Err()
+}
+
+// SchoolStateRatings loads a school's saved state ratings, newest year first
+func (d *DB) SchoolStateRatings(ncessch string) ([]StateRating, error) {
+	rows, err := d.conn.Query(`
+		SELECT ncessch, source, school_year, indicator, rating, score, source_url, fetched_at
+		FROM state_ratings WHERE ncessch = $1
+		ORDER BY school_year DESC, source, indicator = 'Overall' DESC, indicator
+	`, ncessch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load state ratings: %w", err)
+	}
+	defer rows.Close()
+
+	var ratings []StateRating
+	for rows.Next() {
+		var r StateRating
+		var score sql.NullFloat64
+		if err := rows.Scan(&r.NCESSCH, &r.Source, &r.SchoolYear, &r.Indicator, &r.Rating, &score, &r.SourceURL, &r.FetchedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan state rating: %w", err)
+		}
+		if score.Valid {
+			r.Score = &score.Float64
+		}
+		ratings = append(ratings, r)
+	}
+	return ratings, rows.Err()
+}
+
+// StateRatingRefreshes lists ratings refreshes, newest first
+func (d *DB) StateRatingRefreshes() ([]StateRatingRefresh, error) {
+	rows, err := d.conn.Query(`SELECT source, school_year, files, ratings, matched, refreshed_at FROM state_rating_refreshes ORDER BY refreshed_at DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load ratings refreshes: %w", err)
+	}
+	defer rows.Close()
+
+	var refreshes []StateRatingRefresh
+	for rows.Next() {
+		var r StateRatingRefresh
+		var files string
+		if err := rows.Scan(&r.Source, &r.SchoolYear, &files, &r.Ratings, &r.Matched, &r.RefreshedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan ratings refresh: %w", err)
+		}
+		r.Files = slices.DeleteFunc(strings.Split(files, "\n"), func(s string) bool { return s == "" })
+		refreshes = append(refreshes, r)
+	}
+	return refreshes, rows.Err()
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// caDashboardIndicators are the California School Dashboard state indicators,
+// by the name of their download file
+var caDashboardIndicators = []struct {
+	file  string
+	label string
+}{
+	{"ela", "English Language Arts"},
+	{"math", "Mathematics"},
+	{"chronic", "Chronic Absenteeism"},
+	{"susp", "Suspension Rate"},
+	{"grad", "Graduation Rate"},
+	{"elpi", "English Learner Progress"},
+}
+
+// caDashboardColors are the performance levels behind the Dashboard's color
+// codes; 0 means no color was assigned, usually for small student counts
+var caDashboardColors = map[string]string{
+	"1": "Red",
+	"2": "Orange",
+	"3": "Yellow",
+	"4": "Green",
+	"5": "Blue",
+}
+
+// caDashboardSource reads the California School Dashboard's indicator colors
+// from CDE's tab-delimited data files. Schools are keyed by their 14-digit CDS
+// code.
+type caDashboardSource struct{}
+
+func (caDashboardSource) ID() string    { return "ca-dashboard" }
+func (caDashboardSource) State() string { return "CA" }
+func (caDashboardSource) Name() string  { return "California School Dashboard" }
+
+// URLs returns one file per indicator, e.g. chronicdownload2024.txt
+func (caDashboardSource) URLs(year int) []string {
+	var urls []string
+	for _, ind := range caDashboardIndicators {
+		urls = append(urls, fmt.Sprintf("https://www3.cde.ca.gov/researchfiles/cadashboard/%sdownload%d.txt", ind.file, year))
+	}
+	return urls
+}
+
+// Parse reads school-level colors for all students ("rtype" S, "studentgroup" ALL)
+func (caDashboardSource) Parse(r io.Reader, url string, year int) ([]StateRating, error) {
+	t, err := readRatingsTable(r, '\t')
+	if err != nil {
+		return nil, err
+	}
+	cds, err := t.column("cds")
+	if err != nil {
+		return nil, err
+	}
+	color, err := t.column("color")
+	if err != nil {
+		return nil, err
+	}
+	rtype, err := t.column("rtype")
+	if err != nil {
+		return nil, err
+	}
+	group, err := t.column("studentgroup")
+	if err != nil {
+		return nil, err
+	}
+	status, _ := t.column("currstatus")
+
+	// The file name says which indicator it holds
+	indicator := ""
+	for _, ind := range caDashboardIndicators {
+		if strings.Contains(strings.ToLower(url), ind.file+"download") {
+			indicator = ind.label
+			break
+		}
+	}
+	if indicator == "" {
+		return nil, fmt.Errorf("can't tell the Dashboard indicator from %s; keep CDE's file name, e.g. chronicdownload%d.txt", url, year)
+	}
+
+	var ratings []StateRating
+	for _, row := range t.rows {
+		if t.value(row, rtype) != "S" || t.value(row, group) != "ALL" {
+			continue
+		}
+		level, ok := caDashboardColors[t.value(row, color)]
+		if !ok {
+			continue
+		}
+		rating := StateRating{
+			StateSchoolID: t.value(row, cds),
+			SchoolYear:    schoolYearLabel(year),
+			Indicator:     indicator,
+			Rating:        level,
+			SourceURL:     url,
+		}
+		if v, err := strconv.ParseFloat(t.value(row, status), 64); err == nil {
+			rating.Score = &v
+		}
+		ratings = append(ratings, rating)
+	}
+	return ratings, nil
+}
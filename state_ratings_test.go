@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const caChronicFile = "cds\trtype\tschoolname\tstudentgroup\tcurrstatus\tcolor\n" +
+	"06000000000001\tS\tLincoln Elementary School\tALL\t12.4\t2\n" +
+	"06000000000001\tS\tLincoln Elementary School\tEL\t20.1\t1\n" +
+	"06000000000000\tD\tSan Francisco Unified\tALL\t15.0\t3\n" +
+	"06000010000002\tS\tWashington High School\tALL\t8.0\t0\n" +
+	"09999999999999\tS\tUnknown School\tALL\t5.0\t5\n"
+
+func TestStateRatingSources(t *testing.T) {
+	ratings, err := caDashboardSource{}.Parse(strings.NewReader(caChronicFile), "https://example.org/chronicdownload2024.txt", 2024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only school rows for all students with a color
+	if len(ratings) != 2 {
+		t.Fatalf("CA ratings = %+v", ratings)
+	}
+	r := ratings[0]
+	if r.StateSchoolID != "06000000000001" || r.Indicator != "Chronic Absenteeism" || r.Rating != "Orange" || *r.Score != 12.4 || r.SchoolYear != "2023-24" {
+		t.Errorf("CA rating = %+v", r)
+	}
+	if _, err := (caDashboardSource{}).Parse(strings.NewReader(caChronicFile), "dashboard.txt", 2024); err == nil {
+		t.Error("CA file without an indicator in its name accepted")
+	}
+
+	tx := "CAMPUS,CAMPNAME,C_RATING,CDALLS\n'101912001,Jefferson Middle School,b,84\n101912002,Other Campus,Not Rated,\n"
+	ratings, err = txAccountabilitySource{}.Parse(strings.NewReader(tx), "camprate.csv", 2024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ratings) != 2 || ratings[0].StateSchoolID != "101912001" || ratings[0].Rating != "B" || *ratings[0].Score != 84 || ratings[1].Rating != "Not Rated" || ratings[1].Score != nil {
+		t.Errorf("TX ratings = %+v", ratings)
+	}
+	if _, err := (txAccountabilitySource{}).Parse(strings.NewReader("Campus,Grade\n1,A\n"), "x.csv", 2024); err == nil {
+		t.Error("TX file without a rating column accepted")
+	}
+
+	if _, err := stateRatingSourceByID("zz-ratings"); err == nil {
+		t.Error("unknown source accepted")
+	}
+}
+
+func TestRefreshStateRatings(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	// The test directory has no state school IDs; add CCD-style ones
+	if _, err := db.conn.Exec(`
+		ALTER TABLE directory ADD COLUMN ST_SCHID VARCHAR;
+		UPDATE directory SET ST_SCHID = 'CA-0600000-0000001' WHERE NCESSCH = '360000100001';
+		UPDATE directory SET ST_SCHID = 'CA-0600001-0000002' WHERE NCESSCH = '360000100002';
+		UPDATE directory SET ST_SCHID = 'TX-101912-101912001' WHERE NCESSCH = '360000100003';
+	`); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "chronicdownload2024.txt")
+	if err := os.WriteFile(path, []byte(caChronicFile), 0644); err != nil {
+		t.Fatal(err)
+	}
+	refresh, err := RefreshStateRatings(context.Background(), db, "ca-dashboard", 2024, []string{path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refresh.Ratings != 2 || refresh.Matched != 1 || refresh.SchoolYear != "2023-24" {
+		t.Errorf("refresh = %+v", refresh)
+	}
+
+	// Downloads work the same way, keyed by the campus number
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("CAMPUS,C_RATING,CDALLS\n'101912001,A,91\n"))
+	}))
+	defer server.Close()
+	if _, err := RefreshStateRatings(context.Background(), db, "tx-accountability", 2024, []string{server.URL + "/camprate.csv"}); err != nil {
+		t.Fatal(err)
+	}
+
+	ratings, err := db.SchoolStateRatings("360000100003")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ratings) != 1 || ratings[0].Rating != "A" || ratings[0].Source != "tx-accountability" || ratings[0].SourceURL != server.URL+"/camprate.csv" || ratings[0].FetchedAt.IsZero() {
+		t.Errorf("TX ratings = %+v", ratings)
+	}
+
+	// A refresh replaces the year's ratings
+	if err := os.WriteFile(path, []byte("cds\trtype\tstudentgroup\tcurrstatus\tcolor\n06000010000002\tS\tALL\t8.0\t4\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RefreshStateRatings(context.Background(), db, "ca-dashboard", 2024, []string{path}); err != nil {
+		t.Fatal(err)
+	}
+	if ratings, _ := db.SchoolStateRatings("360000100001"); len(ratings) != 0 {
+		t.Errorf("withdrawn rating kept: %+v", ratings)
+	}
+	if ratings, _ := db.SchoolStateRatings("360000100002"); len(ratings) != 1 || ratings[0].Rating != "Green" {
+		t.Errorf("refreshed rating = %+v", ratings)
+	}
+
+	refreshes, err := db.StateRatingRefreshes()
+	if err != nil || len(refreshes) != 3 || refreshes[0].Files[0] != path {
+		t.Errorf("StateRatingRefreshes() = %+v, %v", refreshes, err)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// txAccountabilitySource reads Texas Education Agency A-F campus ratings from
+// TEA's accountability data download. Campuses are keyed by their 9-digit
+// campus number, which spreadsheets often export with a leading apostrophe.
+type txAccountabilitySource struct{}
+
+func (txAccountabilitySource) ID() string    { return "tx-accountability" }
+func (txAccountabilitySource) State() string { return "TX" }
+func (txAccountabilitySource) Name() string  { return "TEA A-F Accountability Ratings" }
+
+// URLs returns the campus ratings download. TEA has moved it between years, so
+// refresh with a downloaded copy when it 404s.
+func (txAccountabilitySource) URLs(year int) []string {
+	return []string{fmt.Sprintf("https://rptsvr1.tea.texas.gov/perfreport/account/%d/download/camprate.csv", year)}
+}
+
+// Parse reads each campus's overall rating and scaled score. Column names
+// differ between TEA's raw download and its spreadsheet exports.
+func (txAccountabilitySource) Parse(r io.Reader, url string, year int) ([]StateRating, error) {
+	t, err := readRatingsTable(r, ',')
+	if err != nil {
+		return nil, err
+	}
+	campus, err := t.column("CAMPUS", "Campus Number", "Campus ID")
+	if err != nil {
+		return nil, err
+	}
+	rating, err := t.column("C_RATING", "Overall Rating", "Rating")
+	if err != nil {
+		return nil, err
+	}
+	score, _ := t.column("CDALLS", "Overall Score", "Score")
+
+	var ratings []StateRating
+	for _, row := range t.rows {
+		value := t.value(row, rating)
+		if value == "" {
+			continue
+		}
+		// Letter grades are kept as letters; labels such as "Not Rated" are kept as published
+		if len(value) == 1 {
+			value = strings.ToUpper(value)
+		}
+		r := StateRating{
+			StateSchoolID: strings.TrimLeft(t.value(row, campus), "'"),
+			SchoolYear:    schoolYearLabel(year),
+			Indicator:     "Overall",
+			Rating:        value,
+			SourceURL:     url,
+		}
+		if v, err := strconv.ParseFloat(t.value(row, score), 64); err == nil {
+			r.Score = &v
+		}
+		ratings = append(ratings, r)
+	}
+	return ratings, nil
+}
@@ -0,0 +1,121 @@
+// Accessibility helpers: high-contrast mode, screen reader announcements for HTMX
+// updates, and arrow-key navigation of search results
+(function () {
+  const CONTRAST_KEY = "schoolfinder.highContrast";
+  const root = document.documentElement;
+
+  // Applied before the page renders so high-contrast pages don't flash
+  if (localStorage.getItem(CONTRAST_KEY) === "1") {
+    root.classList.add("high-contrast");
+  }
+
+  function bindContrastToggles() {
+    document.querySelectorAll("[data-contrast-toggle]").forEach(function (btn) {
+      btn.setAttribute("aria-pressed", root.classList.contains("high-contrast"));
+      btn.addEventListener("click", function () {
+        const on = root.classList.toggle("high-contrast");
+        localStorage.setItem(CONTRAST_KEY, on ? "1" : "0");
+        btn.setAttribute("aria-pressed", on);
+      });
+    });
+  }
+
+  // announce reads message through the page's polite status region. Clearing it
+  // first makes screen readers repeat an identical message.
+  function announce(message) {
+    const status = document.getElementById("a11y-status");
+    if (!status || !message) {
+      return;
+    }
+    status.textContent = "";
+    setTimeout(function () {
+      status.textContent = message;
+    }, 100);
+  }
+
+  // regionLabel names the labelled region an HTMX target belongs to
+  function regionLabel(el) {
+    const region = el.closest ? el.closest("[aria-label]") : null;
+    return region ? region.getAttribute("aria-label") : "";
+  }
+
+  document.addEventListener("htmx:beforeRequest", function (e) {
+    const target = e.detail.target;
+    if (target) {
+      target.setAttribute("aria-busy", "true");
+    }
+  });
+
+  document.addEventListener("htmx:afterSwap", function (e) {
+    const target = e.detail.target;
+    target.removeAttribute("aria-busy");
+
+    // Swapped content can say what to announce; otherwise name the updated region,
+    // but only for requests the user started, so polling and auto-loads stay quiet
+    const note = target.isConnected ? target.querySelector("[data-announce]") : null;
+    if (note) {
+      announce(note.textContent.trim().replace(/\s+/g, " "));
+      return;
+    }
+    const elt = e.detail.elt;
+    if (elt && elt.matches("button, form, input, select, textarea")) {
+      const label = regionLabel(target);
+      announce(label ? label + " updated" : "Page updated");
+    }
+  });
+
+  // Show sign-in, permission, and rate limit messages in place, like other
+  // errors the server explains
+  document.addEventListener("htmx:beforeSwap", function (e) {
+    const status = e.detail.xhr.status;
+    if (status === 401 || status === 403 || status === 429) {
+      e.detail.shouldSwap = true;
+      e.detail.isError = false;
+    }
+  });
+
+  document.addEventListener("htmx:responseError", function (e) {
+    if (e.detail.target) {
+      e.detail.target.removeAttribute("aria-busy");
+    }
+    announce("Something went wrong. Please try again.");
+  });
+
+  // Up and down arrows move between result cards; down from the search box jumps
+  // to the first result
+  document.addEventListener("keydown", function (e) {
+    if (e.key !== "ArrowDown" && e.key !== "ArrowUp") {
+      return;
+    }
+    const active = document.activeElement;
+    const cards = Array.from(document.querySelectorAll("#results .district-card h3 a, #results .school-card"));
+    if (cards.length === 0) {
+      return;
+    }
+
+    if (active && active.id === "search-input") {
+      if (e.key === "ArrowDown") {
+        e.preventDefault();
+        cards[0].focus();
+      }
+      return;
+    }
+
+    const index = cards.indexOf(active);
+    if (index === -1) {
+      return;
+    }
+    e.preventDefault();
+    if (e.key === "ArrowDown" && index < cards.length - 1) {
+      cards[index + 1].focus();
+    } else if (e.key === "ArrowUp") {
+      if (index > 0) {
+        cards[index - 1].focus();
+      } else if (document.getElementById("search-input")) {
+        document.getElementById("search-input").focus();
+      }
+    }
+  });
+
+  document.addEventListener("DOMContentLoaded", bindContrastToggles);
+})();
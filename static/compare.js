@@ -0,0 +1,68 @@
+// Compare basket: up to 3 schools stored in localStorage and shared across pages
+(function () {
+  const KEY = "schoolfinder.compare";
+  const MAX = 3;
+
+  function load() {
+    try {
+      return JSON.parse(localStorage.getItem(KEY)) || [];
+    } catch (e) {
+      return [];
+    }
+  }
+
+  function save(items) {
+    localStorage.setItem(KEY, JSON.stringify(items));
+    updateBadges();
+  }
+
+  function updateBadges() {
+    const count = load().length;
+    document.querySelectorAll("[data-compare-count]").forEach(function (el) {
+      el.textContent = count > 0 ? "(" + count + ")" : "";
+    });
+    document.querySelectorAll("[data-compare-add]").forEach(function (btn) {
+      const inBasket = load().some(function (s) {
+        return s.id === btn.dataset.compareAdd;
+      });
+      btn.textContent = inBasket ? "✓ In Compare" : "+ Add to Compare";
+    });
+  }
+
+  window.compareBasket = {
+    items: load,
+    add: function (id, name) {
+      const items = load().filter(function (s) {
+        return s.id !== id;
+      });
+      if (items.length >= MAX) {
+        alert("You can compare up to " + MAX + " schools. Remove one first.");
+        return;
+      }
+      items.push({ id: id, name: name });
+      save(items);
+    },
+    remove: function (id) {
+      save(
+        load().filter(function (s) {
+          return s.id !== id;
+        })
+      );
+    },
+    clear: function () {
+      save([]);
+    },
+    url: function () {
+      return (
+        "/compare?ids=" +
+        load()
+          .map(function (s) {
+            return encodeURIComponent(s.id);
+          })
+          .join(",")
+      );
+    },
+  };
+
+  document.addEventListener("DOMContentLoaded", updateBadges);
+})();
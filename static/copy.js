@@ -0,0 +1,46 @@
+// Copy buttons: clicking an element with data-copy puts its value on the
+// clipboard and says so in place and to screen readers
+(function () {
+  function announce(message) {
+    const status = document.getElementById("a11y-status");
+    if (!status) {
+      return;
+    }
+    status.textContent = "";
+    setTimeout(function () {
+      status.textContent = message;
+    }, 100);
+  }
+
+  function flash(btn, text) {
+    if (!btn.dataset.copyText) {
+      btn.dataset.copyText = btn.textContent;
+    }
+    btn.textContent = text;
+    clearTimeout(btn.copyTimer);
+    btn.copyTimer = setTimeout(function () {
+      btn.textContent = btn.dataset.copyText;
+    }, 1500);
+  }
+
+  document.addEventListener("click", function (e) {
+    const btn = e.target.closest ? e.target.closest("[data-copy]") : null;
+    if (!btn) {
+      return;
+    }
+    const label = btn.dataset.copyText || btn.textContent.trim();
+    if (!navigator.clipboard) {
+      announce("Copying isn't available on this page");
+      return;
+    }
+    navigator.clipboard.writeText(btn.dataset.copy).then(
+      function () {
+        flash(btn, "✓ Copied");
+        announce("Copied " + label);
+      },
+      function () {
+        announce("Couldn't copy " + label);
+      }
+    );
+  });
+})();
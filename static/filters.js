@@ -0,0 +1,68 @@
+// Search filter drawer: on phones the "More filters" panel opens as a bottom sheet
+// over the results, so it starts closed and closes on "Show results", Escape, or a
+// tap outside it
+(function () {
+  const PHONE = window.matchMedia("(max-width: 640px)");
+
+  // countFilters mirrors SearchFilters.DrawerFilterCount: the grade range counts once
+  function countFilters(drawer) {
+    let count = 0;
+    const value = function (name) {
+      const field = drawer.querySelector('[name="' + name + '"]');
+      return field ? field.value : "";
+    };
+    if (value("grade_low") || value("grade_high")) {
+      count++;
+    }
+    // A language and immersion count once
+    if (value("language") || drawer.querySelector('[name="immersion"]:checked')) {
+      count++;
+    }
+    ["cte", "sports", "conference", "within_miles", "care", "sector", "charter", "max_ratio", "trend"].forEach(function (name) {
+      if (value(name)) {
+        count++;
+      }
+    });
+    ["child_grades", "programs", "prek"].forEach(function (name) {
+      if (drawer.querySelector('[name="' + name + '"]:checked')) {
+        count++;
+      }
+    });
+    return count;
+  }
+
+  function close(drawer) {
+    drawer.open = false;
+    drawer.querySelector("summary").focus();
+  }
+
+  document.addEventListener("DOMContentLoaded", function () {
+    document.querySelectorAll("[data-filter-drawer]").forEach(function (drawer) {
+      if (PHONE.matches) {
+        drawer.open = false;
+      }
+
+      drawer.addEventListener("change", function () {
+        const count = countFilters(drawer);
+        drawer.querySelector("[data-filter-count]").textContent = count > 0 ? count : "";
+      });
+
+      drawer.querySelector("[data-filter-drawer-close]").addEventListener("click", function () {
+        close(drawer);
+      });
+
+      // The backdrop is the drawer's own ::before, so a tap on it targets the drawer
+      drawer.addEventListener("click", function (e) {
+        if (e.target === drawer && PHONE.matches) {
+          close(drawer);
+        }
+      });
+
+      drawer.addEventListener("keydown", function (e) {
+        if (e.key === "Escape" && drawer.open && PHONE.matches) {
+          close(drawer);
+        }
+      });
+    });
+  });
+})();
@@ -0,0 +1,266 @@
+// Command palette: Ctrl+K (⌘K on a Mac) on any page opens a school search
+// backed by /api/suggest, with recently viewed schools and site actions
+(function () {
+  const RECENT_KEY = "schoolfinder.recent";
+  const MAX_RECENT = 5;
+  const isMac = /Mac|iPhone|iPad/.test(navigator.platform);
+
+  let backdrop, input, list, opener;
+  let items = [];
+  let active = 0;
+  let pending = null;
+  let timer = null;
+
+  function loadRecent() {
+    try {
+      return JSON.parse(localStorage.getItem(RECENT_KEY)) || [];
+    } catch (e) {
+      return [];
+    }
+  }
+
+  function remember(item) {
+    const recent = loadRecent().filter(function (r) {
+      return r.url !== item.url;
+    });
+    recent.unshift({ label: item.label, detail: item.detail || "", url: item.url });
+    localStorage.setItem(RECENT_KEY, JSON.stringify(recent.slice(0, MAX_RECENT)));
+  }
+
+  // actions are the palette's commands; those that only apply on some pages
+  // check for the page's controls
+  function actions() {
+    const list = [];
+    const add = document.querySelector("[data-compare-add]");
+    if (add && window.compareBasket) {
+      list.push({
+        label: "Add " + add.dataset.compareName + " to compare",
+        run: function () {
+          add.click();
+        },
+      });
+    }
+    list.push(
+      { label: "Compare schools", url: window.compareBasket ? compareBasket.url() : "/compare" },
+      { label: "Open the Data Explorer", url: "/agent" },
+      { label: "Import data", url: "/import" },
+      { label: "Saved searches", url: "/saved-searches" },
+      { label: "NAEP alerts", url: "/alerts" },
+      { label: "Applications", url: "/applications" },
+      { label: "School outreach", url: "/outreach" },
+      { label: "Statistics", url: "/stats" },
+      { label: "Language programs", url: "/languages" },
+      { label: "Neighborhood report", url: "/neighborhood" },
+      {
+        label: "Toggle high contrast",
+        run: function () {
+          const toggle = document.querySelector("[data-contrast-toggle]");
+          if (toggle) {
+            toggle.click();
+          }
+        },
+      }
+    );
+    return list.map(function (a) {
+      a.kind = "action";
+      return a;
+    });
+  }
+
+  function build() {
+    backdrop = document.createElement("div");
+    backdrop.className = "palette-backdrop";
+    backdrop.hidden = true;
+    backdrop.innerHTML =
+      '<div class="palette" role="dialog" aria-modal="true" aria-label="Command palette">' +
+      '<input type="text" class="palette-input" role="combobox" aria-label="Search schools or run a command"' +
+      ' aria-expanded="true" aria-controls="palette-list" aria-autocomplete="list" autocomplete="off" spellcheck="false"' +
+      ' placeholder="Search schools, districts, or commands">' +
+      '<ul id="palette-list" class="palette-list" role="listbox" aria-label="Results"></ul>' +
+      '<p class="palette-hint">↑↓ to move · Enter to open · Esc to close</p>' +
+      "</div>";
+    document.body.appendChild(backdrop);
+    input = backdrop.querySelector(".palette-input");
+    list = backdrop.querySelector(".palette-list");
+
+    backdrop.addEventListener("mousedown", function (e) {
+      if (e.target === backdrop) {
+        close();
+      }
+    });
+    input.addEventListener("input", function () {
+      clearTimeout(timer);
+      render(input.value, []);
+      timer = setTimeout(function () {
+        suggest(input.value);
+      }, 150);
+    });
+    input.addEventListener("keydown", function (e) {
+      if (e.key === "ArrowDown" || e.key === "ArrowUp") {
+        e.preventDefault();
+        if (items.length > 0) {
+          active = (active + (e.key === "ArrowDown" ? 1 : items.length - 1)) % items.length;
+          highlight();
+        }
+      } else if (e.key === "Enter") {
+        e.preventDefault();
+        choose(items[active]);
+      } else if (e.key === "Escape") {
+        e.preventDefault();
+        close();
+      } else if (e.key === "Tab") {
+        e.preventDefault(); // Keep focus in the dialog
+      }
+    });
+    list.addEventListener("mousedown", function (e) {
+      const option = e.target.closest("[role=option]");
+      if (option) {
+        e.preventDefault();
+        choose(items[Number(option.dataset.index)]);
+      }
+    });
+  }
+
+  function suggest(query) {
+    if (pending) {
+      pending.abort();
+    }
+    if (query.trim().length < 2) {
+      return;
+    }
+    pending = new AbortController();
+    fetch("/api/suggest?q=" + encodeURIComponent(query), { signal: pending.signal })
+      .then(function (res) {
+        return res.ok ? res.json() : { suggestions: [] };
+      })
+      .then(function (data) {
+        if (input.value === query) {
+          render(query, data.suggestions || []);
+        }
+      })
+      .catch(function () {});
+  }
+
+  // render lists what matches query: with nothing typed, recent schools and
+  // every action; otherwise a full search, the suggestions, and matching actions
+  function render(query, suggestions) {
+    const q = query.trim().toLowerCase();
+    const matching = actions().filter(function (a) {
+      return q === "" || a.label.toLowerCase().indexOf(q) !== -1;
+    });
+    if (q === "") {
+      items = loadRecent()
+        .map(function (r) {
+          return { kind: "recent", label: r.label, detail: r.detail, url: r.url };
+        })
+        .concat(matching);
+    } else {
+      items = [{ kind: "search", label: "Search for “" + query.trim() + "”", url: "/?q=" + encodeURIComponent(query.trim()) }]
+        .concat(suggestions)
+        .concat(matching);
+    }
+
+    list.innerHTML = "";
+    items.forEach(function (item, i) {
+      const li = document.createElement("li");
+      li.id = "palette-option-" + i;
+      li.className = "palette-option";
+      li.setAttribute("role", "option");
+      li.dataset.index = i;
+      const kind = document.createElement("span");
+      kind.className = "palette-kind";
+      kind.textContent = item.kind;
+      const label = document.createElement("span");
+      label.className = "palette-label";
+      label.textContent = item.label;
+      li.append(kind, label);
+      if (item.detail) {
+        const detail = document.createElement("span");
+        detail.className = "palette-detail";
+        detail.textContent = item.detail;
+        li.append(detail);
+      }
+      list.appendChild(li);
+    });
+    active = 0;
+    highlight();
+  }
+
+  function highlight() {
+    list.querySelectorAll("[role=option]").forEach(function (li, i) {
+      li.setAttribute("aria-selected", i === active);
+    });
+    const current = document.getElementById("palette-option-" + active);
+    if (current) {
+      input.setAttribute("aria-activedescendant", current.id);
+      current.scrollIntoView({ block: "nearest" });
+    } else {
+      input.removeAttribute("aria-activedescendant");
+    }
+  }
+
+  function choose(item) {
+    if (!item) {
+      return;
+    }
+    if (item.kind === "school" || item.kind === "district") {
+      remember(item);
+    }
+    close();
+    if (item.run) {
+      item.run();
+    } else {
+      location.href = item.url;
+    }
+  }
+
+  function open() {
+    if (!backdrop) {
+      build();
+    }
+    opener = document.activeElement;
+    backdrop.hidden = false;
+    input.value = "";
+    render("", []);
+    input.focus();
+  }
+
+  function close() {
+    if (!backdrop || backdrop.hidden) {
+      return;
+    }
+    backdrop.hidden = true;
+    if (opener && opener.focus) {
+      opener.focus();
+    }
+  }
+
+  document.addEventListener("keydown", function (e) {
+    if ((e.ctrlKey || e.metaKey) && !e.altKey && !e.shiftKey && e.key.toLowerCase() === "k") {
+      e.preventDefault();
+      if (backdrop && !backdrop.hidden) {
+        close();
+      } else {
+        open();
+      }
+    }
+  });
+
+  document.addEventListener("DOMContentLoaded", function () {
+    // A school page counts as a recent item
+    const school = document.querySelector("[data-compare-add]");
+    if (school && location.pathname.indexOf("/schools/") === 0) {
+      remember({ label: school.dataset.compareName, detail: school.dataset.compareDetail || "", url: location.pathname });
+    }
+
+    document.querySelectorAll(".main-nav").forEach(function (nav) {
+      const btn = document.createElement("button");
+      btn.type = "button";
+      btn.className = "palette-toggle";
+      btn.setAttribute("aria-keyshortcuts", "Control+K Meta+K");
+      btn.textContent = "Jump to… " + (isMac ? "⌘K" : "Ctrl+K");
+      btn.addEventListener("click", open);
+      nav.insertBefore(btn, nav.querySelector("[data-contrast-toggle]"));
+    });
+  });
+})();
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Overview sections, stored in overview_stats.section
+const (
+	overviewNational = "national"
+	overviewState    = "state"
+	overviewLevel    = "level"
+	overviewDistrict = "district"
+)
+
+// largestDistricts is how many districts the overview lists, by enrollment
+const largestDistricts = 25
+
+// OverviewStat is one row of the statistics overview: the country, a state,
+// a school level, or a district
+type OverviewStat struct {
+	Key            string // "US", a state code, a CCD level, or an LEAID
+	Name           string
+	State          string // The district's state
+	Schools        int
+	CharterSchools int
+	Students       int64
+	Ratio          float64 // Pooled students per teacher, or 0 when no school reports both
+}
+
+// CharterShare is the percentage of schools that are charter schools
+func (s OverviewStat) CharterShare() float64 {
+	if s.Schools == 0 {
+		return 0
+	}
+	return float64(s.CharterSchools) / float64(s.Schools) * 100
+}
+
+// RatioLabel formats the pooled ratio, e.g. "15.4:1"
+func (s OverviewStat) RatioLabel() string {
+	if s.Ratio == 0 {
+		return "N/A"
+	}
+	return fmt.Sprintf("%.1f:1", s.Ratio)
+}
+
+// StatsOverview is the statewide and national statistics dashboard, read
+// from the overview_stats table computed at startup
+type StatsOverview struct {
+	National   OverviewStat
+	States     []OverviewStat // Most schools first
+	Levels     []OverviewStat // Most schools first
+	Districts  []OverviewStat // The largest districts by enrollment
+	ComputedAt time.Time
+}
+
+// RefreshOverviewStats recomputes the statistics overview: schools, charter
+// share, enrollment, and the pooled student/teacher ratio for the country,
+// each state, each school level, and each district. Merged duplicates aren't
+// counted. It returns the number of rows saved.
+func (d *DB) RefreshOverviewStats() (int, error) {
+	tx, err := d.conn.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.Exec(`DELETE FROM overview_stats`); err != nil {
+		return 0, fmt.Errorf("failed to clear overview statistics: %w", err)
+	}
+	// The ratio pools schools reporting both students and teachers
+	const measures = `
+		count(*) AS schools,
+		count(*) FILTER (WHERE charter) AS charter_schools,
+		COALESCE(sum(students), 0)::BIGINT AS students,
+		sum(students) FILTER (WHERE teachers > 0) / NULLIF(sum(teachers) FILTER (WHERE students > 0), 0) AS ratio
+	`
+	result, err := tx.Exec(`
+		INSERT INTO overview_stats
+		WITH schools AS (
+			SELECT d.ST AS state, COALESCE(d.STATENAME, d.ST) AS state_name,
+				COALESCE(d.LEVEL, 'Not reported') AS level, d.LEAID AS leaid, COALESCE(d.LEA_NAME, d.LEAID) AS lea_name,
+				COALESCE(d.CHARTER_TEXT = 'Yes', false) AS charter,
+				NULLIF(TRY_CAST(e.STUDENT_COUNT AS DOUBLE), 0) AS students,
+				NULLIF(TRY_CAST(t.TEACHERS AS DOUBLE), 0) AS teachers
+			FROM directory d
+			LEFT JOIN teachers t ON d.NCESSCH = t.NCESSCH
+			LEFT JOIN enrollment e ON d.NCESSCH = e.NCESSCH AND e.TOTAL_INDICATOR = 'Education Unit Total'
+			WHERE `+notMergedCondition+`
+		), grouped AS (
+			SELECT $1 AS section, 'US' AS key, 'United States' AS name, NULL AS state, `+measures+` FROM schools
+			UNION ALL
+			SELECT $2, state, any_value(state_name), state, `+measures+` FROM schools WHERE state IS NOT NULL GROUP BY state
+			UNION ALL
+			SELECT $3, level, level, NULL, `+measures+` FROM schools GROUP BY level
+			UNION ALL
+			SELECT $4, leaid, any_value(lea_name), any_value(state), `+measures+` FROM schools WHERE leaid IS NOT NULL GROUP BY leaid
+		)
+		SELECT section, key, name, state, schools, charter_schools, students, ratio,
+			row_number() OVER (PARTITION BY section ORDER BY CASE WHEN section = $4 THEN students ELSE schools END DESC, key),
+			now()
+		FROM grouped
+	`, overviewNational, overviewState, overviewLevel, overviewDistrict)
+	if err != nil {
+		return 0, fmt.Errorf("failed to compute overview statistics: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to save overview statistics: %w", err)
+	}
+	n, _ := result.RowsAffected()
+	return int(n), nil
+}
+
+// SyncOverviewStats computes the statistics overview when it's missing, as in
+// new databases and ones from before the overview
+func SyncOverviewStats(d *DB) (int, error) {
+	var count sql.NullInt64
+	if err := d.conn.QueryRow(`SELECT count(*) FROM overview_stats`).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to check overview statistics: %w", err)
+	}
+	if count.Int64 > 0 {
+		return 0, nil
+	}
+	return d.RefreshOverviewStats()
+}
+
+// StatsOverview loads the statistics overview
+func (d *DB) StatsOverview() (*StatsOverview, error) {
+	rows, err := d.conn.Query(`
+		SELECT section, key, name, COALESCE(state, ''), schools, charter_schools, students, COALESCE(ratio, 0), computed_at
+		FROM overview_stats
+		WHERE section <> $1 OR rank <= $2
+		ORDER BY section, rank
+	`, overviewDistrict, largestDistricts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load overview statistics: %w", err)
+	}
+	defer rows.Close()
+
+	overview := &StatsOverview{}
+	for rows.Next() {
+		var section string
+		var s OverviewStat
+		if err := rows.Scan(&section, &s.Key, &s.Name, &s.State, &s.Schools, &s.CharterSchools, &s.Students, &s.Ratio, &overview.ComputedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan overview statistic: %w", err)
+		}
+		switch section {
+		case overviewNational:
+			overview.National = s
+		case overviewState:
+			overview.States = append(overview.States, s)
+		case overviewLevel:
+			overview.Levels = append(overview.Levels, s)
+		case overviewDistrict:
+			overview.Districts = append(overview.Districts, s)
+		}
+	}
+	return overview, rows.Err()
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"math"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestStatsOverview(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	overview, err := db.StatsOverview()
+	if err != nil {
+		t.Fatal(err)
+	}
+	us := overview.National
+	if us.Schools != 5 || us.CharterSchools != 1 || us.Students != 3375 || us.CharterShare() != 20 {
+		t.Errorf("national = %+v", us)
+	}
+	// 3,375 students and 174.2 teachers
+	if math.Abs(us.Ratio-3375/174.2) > 1e-9 || us.RatioLabel() != "19.4:1" {
+		t.Errorf("national ratio = %v", us.Ratio)
+	}
+	if len(overview.States) != 4 || overview.States[0].Key != "CA" || overview.States[0].Schools != 2 || overview.States[0].Name != "California" {
+		t.Errorf("states = %+v", overview.States)
+	}
+	if len(overview.Levels) != 4 || overview.Levels[0].Name != "High" {
+		t.Errorf("levels = %+v", overview.Levels)
+	}
+	if len(overview.Districts) != 5 || overview.Districts[0].Key != "0600001" || overview.Districts[0].State != "CA" || overview.Districts[0].Students != 850 {
+		t.Errorf("districts = %+v", overview.Districts)
+	}
+	if overview.ComputedAt.IsZero() {
+		t.Error("overview has no computed time")
+	}
+
+	// Merged duplicates drop out of the counts
+	if err := db.MergeSchools("360000100002", "360000100001", ""); err != nil {
+		t.Fatal(err)
+	}
+	if overview, err := db.StatsOverview(); err != nil || overview.National.Schools != 4 {
+		t.Errorf("overview after merge = %+v, %v", overview, err)
+	}
+
+	router := NewRouter(ServerConfig{DB: db})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/stats", nil))
+	body := rec.Body.String()
+	if rec.Code != 200 || !strings.Contains(body, "Schools per State") || !strings.Contains(body, `href="/districts/4800000"`) || !strings.Contains(body, "25.0%") {
+		t.Errorf("stats page = %d", rec.Code)
+	}
+}
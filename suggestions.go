@@ -0,0 +1,290 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/smtp"
+	"os"
+	"strings"
+	"time"
+)
+
+// Review status of a suggested correction
+const (
+	suggestionPending  = "pending"
+	suggestionApproved = "approved"
+	suggestionRejected = "rejected"
+)
+
+// Limits on suggestions from the public
+const (
+	maxSuggestionComment  = 1000
+	maxPendingSuggestions = 500 // Across all schools, so a flood of submissions can't fill the database
+)
+
+// CorrectionSuggestion is a correction of one directory field submitted for an
+// admin to review. Approving it saves it as a SchoolCorrection.
+type CorrectionSuggestion struct {
+	ID         int64        `json:"id"`
+	NCESSCH    string       `json:"ncessch"`
+	SchoolName string       `json:"school_name"`
+	Field      string       `json:"field"`
+	Value      string       `json:"value"`
+	OldValue   string       `json:"old_value"` // The school's value when the suggestion was made
+	Comment    string       `json:"comment,omitempty"`
+	Submitter  string       `json:"submitter,omitempty"` // Optional name or email to follow up with
+	Status     string       `json:"status"`
+	CreatedAt  time.Time    `json:"created_at"`
+	ReviewedAt sql.NullTime `json:"-"`
+}
+
+// Label names the suggested field for display
+func (s CorrectionSuggestion) Label() string {
+	for _, f := range correctionFields {
+		if f.Field == s.Field {
+			return f.Label
+		}
+	}
+	return s.Field
+}
+
+// SaveCorrectionSuggestions queues suggestions for review, filling in their IDs.
+// It fails with ErrSuggestionQueueFull once too many are waiting.
+func (d *DB) SaveCorrectionSuggestions(suggestions []CorrectionSuggestion) error {
+	var pending int
+	if err := d.conn.QueryRow(`SELECT count(*) FROM correction_suggestions WHERE status = $1`, suggestionPending).Scan(&pending); err != nil {
+		return fmt.Errorf("failed to count pending suggestions: %w", err)
+	}
+	if pending+len(suggestions) > maxPendingSuggestions {
+		return fmt.Errorf("%w: %d pending", ErrSuggestionQueueFull, pending)
+	}
+
+	tx, err := d.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for i := range suggestions {
+		s := &suggestions[i]
+		s.Status = suggestionPending
+		err := tx.QueryRow(`
+			INSERT INTO correction_suggestions (ncessch, school_name, field, value, old_value, comment, submitter, status)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			RETURNING id, created_at
+		`, s.NCESSCH, s.SchoolName, s.Field, s.Value, s.OldValue, s.Comment, s.Submitter, s.Status).Scan(&s.ID, &s.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to save correction suggestion: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit correction suggestions: %w", err)
+	}
+	return nil
+}
+
+// ListCorrectionSuggestions returns suggestions with the given status, oldest
+// first so the queue is reviewed in order
+func (d *DB) ListCorrectionSuggestions(status string) ([]CorrectionSuggestion, error) {
+	rows, err := d.conn.Query(`
+		SELECT id, ncessch, school_name, field, value, old_value, comment, submitter, status, created_at, reviewed_at
+		FROM correction_suggestions
+		WHERE status = $1
+		ORDER BY created_at, id
+	`, status)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list correction suggestions: %w", err)
+	}
+	defer rows.Close()
+
+	var suggestions []CorrectionSuggestion
+	for rows.Next() {
+		var s CorrectionSuggestion
+		if err := rows.Scan(&s.ID, &s.NCESSCH, &s.SchoolName, &s.Field, &s.Value, &s.OldValue,
+			&s.Comment, &s.Submitter, &s.Status, &s.CreatedAt, &s.ReviewedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan correction suggestion: %w", err)
+		}
+		suggestions = append(suggestions, s)
+	}
+
+	return suggestions, rows.Err()
+}
+
+// ReviewCorrectionSuggestion approves or rejects a pending suggestion. Approving
+// saves it as a correction, noting the submitter's comment.
+func (d *DB) ReviewCorrectionSuggestion(id int64, approve bool) (*CorrectionSuggestion, error) {
+	var s CorrectionSuggestion
+	err := d.conn.QueryRow(`
+		SELECT id, ncessch, school_name, field, value, comment
+		FROM correction_suggestions
+		WHERE id = $1 AND status = $2
+	`, id, suggestionPending).Scan(&s.ID, &s.NCESSCH, &s.SchoolName, &s.Field, &s.Value, &s.Comment)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("no pending correction suggestion %d: %w", id, err)
+		}
+		return nil, fmt.Errorf("failed to load correction suggestion: %w", err)
+	}
+
+	s.Status = suggestionRejected
+	if approve {
+		note := "Suggested correction"
+		if s.Comment != "" {
+			note += ": " + s.Comment
+		}
+		if runes := []rune(note); len(runes) > maxEditTextLength {
+			note = string(runes[:maxEditTextLength])
+		}
+		if err := d.SaveSchoolCorrection(SchoolCorrection{NCESSCH: s.NCESSCH, Field: s.Field, Value: s.Value, Note: note}); err != nil {
+			return nil, err
+		}
+		s.Status = suggestionApproved
+	}
+
+	if _, err := d.conn.Exec(`UPDATE correction_suggestions SET status = $1, reviewed_at = now() WHERE id = $2`, s.Status, id); err != nil {
+		return nil, fmt.Errorf("failed to update correction suggestion: %w", err)
+	}
+
+	if logger != nil {
+		logger.Info("Reviewed correction suggestion", "id", id, "ncessch", s.NCESSCH, "field", s.Field, "status", s.Status)
+	}
+	return &s, nil
+}
+
+// suggestionNotifier tells admins about new correction suggestions by webhook,
+// email, or both
+type suggestionNotifier struct {
+	webhookURL string
+	emailTo    string
+	smtpAddr   string // host:port
+	smtpFrom   string
+	smtpUser   string
+	smtpPass   string
+	baseURL    string // Public URL of the server, for review links; relative links if empty
+
+	client   *http.Client
+	sendMail func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+// newSuggestionNotifierFromEnv configures notifications from the environment,
+// returning nil when neither a webhook nor an email recipient is set
+func newSuggestionNotifierFromEnv() *suggestionNotifier {
+	n := &suggestionNotifier{
+		webhookURL: os.Getenv("CORRECTION_WEBHOOK_URL"),
+		emailTo:    os.Getenv("CORRECTION_NOTIFY_EMAIL"),
+		smtpAddr:   os.Getenv("SMTP_ADDR"),
+		smtpFrom:   os.Getenv("SMTP_FROM"),
+		smtpUser:   os.Getenv("SMTP_USERNAME"),
+		smtpPass:   lookupSecret(SecretSMTP),
+		baseURL:    strings.TrimSuffix(os.Getenv("SCHOOLFINDER_URL"), "/"),
+	}
+	if n.emailTo != "" && n.smtpAddr == "" {
+		fmt.Fprintln(os.Stderr, "Warning: CORRECTION_NOTIFY_EMAIL is set but SMTP_ADDR isn't; suggestion emails are disabled")
+		n.emailTo = ""
+	}
+	if n.webhookURL == "" && n.emailTo == "" {
+		return nil
+	}
+	if n.smtpFrom == "" {
+		n.smtpFrom = n.emailTo
+	}
+	n.client = &http.Client{Timeout: 10 * time.Second}
+	n.sendMail = smtp.SendMail
+	return n
+}
+
+// suggestionWebhookPayload is the JSON posted to CORRECTION_WEBHOOK_URL
+type suggestionWebhookPayload struct {
+	Event       string                 `json:"event"`
+	Text        string                 `json:"text"` // Summary for chat webhooks such as Slack's
+	ReviewURL   string                 `json:"review_url"`
+	Suggestions []CorrectionSuggestion `json:"suggestions"`
+}
+
+// Notify sends one notification for a submission's suggestions. Failures are
+// returned together; a nil notifier does nothing.
+func (n *suggestionNotifier) Notify(ctx context.Context, suggestions []CorrectionSuggestion) error {
+	if n == nil || len(suggestions) == 0 {
+		return nil
+	}
+
+	reviewURL := n.baseURL + "/admin/corrections"
+	text := suggestionSummary(suggestions, reviewURL)
+
+	var errs []error
+	if n.webhookURL != "" {
+		payload := suggestionWebhookPayload{
+			Event:       "correction_suggested",
+			Text:        text,
+			ReviewURL:   reviewURL,
+			Suggestions: suggestions,
+		}
+		if err := webhookRetry.Do(ctx, func() error { return n.postWebhook(ctx, payload) }); err != nil {
+			errs = append(errs, fmt.Errorf("correction webhook: %w", err))
+		}
+	}
+	if n.emailTo != "" {
+		if err := n.email(suggestions[0].SchoolName, text); err != nil {
+			errs = append(errs, fmt.Errorf("correction email: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (n *suggestionNotifier) postWebhook(ctx context.Context, payload suggestionWebhookPayload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return newHTTPStatusError(resp)
+	}
+	return nil
+}
+
+func (n *suggestionNotifier) email(schoolName, text string) error {
+	var auth smtp.Auth
+	if n.smtpUser != "" {
+		host, _, _ := strings.Cut(n.smtpAddr, ":")
+		auth = smtp.PlainAuth("", n.smtpUser, n.smtpPass, host)
+	}
+
+	subject := strings.NewReplacer("\r", " ", "\n", " ").Replace("Correction suggested for " + schoolName)
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s\r\n",
+		n.smtpFrom, n.emailTo, subject, strings.ReplaceAll(text, "\n", "\r\n"))
+	return n.sendMail(n.smtpAddr, auth, n.smtpFrom, []string{n.emailTo}, []byte(msg))
+}
+
+// suggestionSummary describes a submission in plain text for notifications
+func suggestionSummary(suggestions []CorrectionSuggestion, reviewURL string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "New correction suggested for %s (%s):\n", suggestions[0].SchoolName, suggestions[0].NCESSCH)
+	for _, s := range suggestions {
+		fmt.Fprintf(&b, "- %s: %q -> %q\n", s.Label(), s.OldValue, s.Value)
+	}
+	if c := suggestions[0].Comment; c != "" {
+		fmt.Fprintf(&b, "Comment: %s\n", c)
+	}
+	if who := suggestions[0].Submitter; who != "" {
+		fmt.Fprintf(&b, "From: %s\n", who)
+	}
+	fmt.Fprintf(&b, "Review: %s", reviewURL)
+	return b.String()
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+func testSuggestions() []CorrectionSuggestion {
+	return []CorrectionSuggestion{{
+		NCESSCH:    "360000100001",
+		SchoolName: "Lincoln Elementary School",
+		Field:      correctionPhone,
+		Value:      "415-555-0199",
+		OldValue:   "415-555-0100",
+		Comment:    "The main line changed",
+		Submitter:  "parent@example.org",
+	}}
+}
+
+func TestSuggestionNotifier(t *testing.T) {
+	payloads := make(chan suggestionWebhookPayload, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var p suggestionWebhookPayload
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			t.Errorf("webhook body: %v", err)
+		}
+		payloads <- p
+	}))
+	defer server.Close()
+
+	var mail struct {
+		addr string
+		to   []string
+		msg  string
+	}
+	n := &suggestionNotifier{
+		webhookURL: server.URL,
+		emailTo:    "admin@example.org",
+		smtpAddr:   "mail.example.org:587",
+		smtpFrom:   "schoolfinder@example.org",
+		baseURL:    "https://schools.example.org",
+		client:     server.Client(),
+		sendMail: func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+			mail.addr, mail.to, mail.msg = addr, to, string(msg)
+			return nil
+		},
+	}
+
+	if err := n.Notify(context.Background(), testSuggestions()); err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+
+	p := <-payloads
+	if p.Event != "correction_suggested" || p.ReviewURL != "https://schools.example.org/admin/corrections" || len(p.Suggestions) != 1 {
+		t.Errorf("webhook payload = %+v", p)
+	}
+	if !strings.Contains(p.Text, `Phone: "415-555-0100" -> "415-555-0199"`) || !strings.Contains(p.Text, "parent@example.org") {
+		t.Errorf("webhook text = %q", p.Text)
+	}
+
+	if mail.addr != "mail.example.org:587" || len(mail.to) != 1 || mail.to[0] != "admin@example.org" {
+		t.Errorf("email sent to %s %v", mail.addr, mail.to)
+	}
+	if !strings.Contains(mail.msg, "Subject: Correction suggested for Lincoln Elementary School\r\n") || !strings.Contains(mail.msg, "The main line changed") {
+		t.Errorf("email message = %q", mail.msg)
+	}
+
+	// A nil notifier is a no-op
+	var none *suggestionNotifier
+	if err := none.Notify(context.Background(), testSuggestions()); err != nil {
+		t.Errorf("nil notifier: %v", err)
+	}
+}
+
+func TestCorrectionSuggestionReview(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	notified := make(chan suggestionWebhookPayload, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var p suggestionWebhookPayload
+		json.NewDecoder(r.Body).Decode(&p)
+		notified <- p
+	}))
+	defer server.Close()
+
+	router := NewRouter(ServerConfig{
+		DB:            db,
+		AdminPassword: "secret",
+		Notifier:      &suggestionNotifier{webhookURL: server.URL, client: server.Client()},
+	})
+	do := func(method, path string, form url.Values, admin bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("HX-Request", "true")
+		if admin {
+			req.SetBasicAuth("admin", "secret")
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+	form := func(city string) url.Values {
+		return url.Values{
+			"phone":     {"415-555-0100"},
+			"website":   {"https://lincoln.sfusd.edu"},
+			"street":    {"123 Lincoln St"},
+			"city":      {city},
+			"zip":       {"94102"},
+			"note":      {"Moved campuses"},
+			"submitter": {"parent@example.org"},
+		}
+	}
+
+	if rec := do("POST", "/schools/999999999999/suggestions", form("Oakland"), false); rec.Code != http.StatusNotFound {
+		t.Errorf("suggestion for an unknown school: status %d, want 404", rec.Code)
+	}
+
+	// Visitors get the suggestion form and can't correct directly
+	rec := do("GET", "/schools/360000100001", nil, false)
+	if body := rec.Body.String(); !strings.Contains(body, "Suggest a correction") || strings.Contains(body, `/schools/360000100001/corrections"`) {
+		t.Errorf("detail page doesn't offer suggestions (status %d)", rec.Code)
+	}
+	if rec := do("POST", "/schools/360000100001/corrections", form("Oakland"), false); rec.Code != http.StatusUnauthorized {
+		t.Errorf("direct correction without signing in: status %d", rec.Code)
+	}
+
+	// An unchanged form isn't queued
+	rec = do("POST", "/schools/360000100001/suggestions", form("San Francisco"), false)
+	if !strings.Contains(rec.Body.String(), "Change at least one field") {
+		t.Errorf("unchanged suggestion: status %d, body %s", rec.Code, rec.Body.String())
+	}
+
+	rec = do("POST", "/schools/360000100001/suggestions", form("Oakland"), false)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "will be reviewed") {
+		t.Fatalf("suggestion: status %d, body %s", rec.Code, rec.Body.String())
+	}
+	select {
+	case p := <-notified:
+		if len(p.Suggestions) != 1 || p.Suggestions[0].Field != correctionCity || p.Suggestions[0].Value != "Oakland" {
+			t.Errorf("notification = %+v", p)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("no notification of the suggestion")
+	}
+	if school, _ := db.GetSchoolByID("360000100001"); school.City != "San Francisco" {
+		t.Errorf("suggestion applied before review: city %q", school.City)
+	}
+
+	// Only admins see the review queue
+	if rec := do("GET", "/admin/corrections", nil, false); rec.Code != http.StatusUnauthorized {
+		t.Errorf("review queue without signing in: status %d", rec.Code)
+	}
+	rec = do("GET", "/admin/corrections", nil, true)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "Oakland") {
+		t.Fatalf("review queue: status %d, body %s", rec.Code, rec.Body.String())
+	}
+	doc, err := html.Parse(strings.NewReader(rec.Body.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rule := range a11yRules {
+		for _, problem := range rule.check(doc) {
+			t.Errorf("review queue %s: %s", rule.id, problem)
+		}
+	}
+
+	pending, err := db.ListCorrectionSuggestions(suggestionPending)
+	if err != nil || len(pending) != 1 {
+		t.Fatalf("pending suggestions = %+v, %v", pending, err)
+	}
+	approve := fmt.Sprintf("/admin/corrections/%d/approve", pending[0].ID)
+	if rec := do("POST", approve, nil, true); rec.Code != http.StatusOK {
+		t.Fatalf("approve: status %d, body %s", rec.Code, rec.Body.String())
+	}
+	school, err := db.GetSchoolByID("360000100001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if school.City != "Oakland" || !school.IsCorrected(correctionCity) {
+		t.Errorf("after approval: city %q, corrected %v", school.City, school.IsCorrected(correctionCity))
+	}
+	if corrections, _ := db.ListSchoolCorrections("360000100001"); len(corrections) != 1 || !strings.Contains(corrections[0].Note, "Moved campuses") {
+		t.Errorf("corrections = %+v", corrections)
+	}
+
+	// A reviewed suggestion can't be reviewed again
+	if rec := do("POST", approve, nil, true); rec.Code != http.StatusNotFound {
+		t.Errorf("second approval: status %d", rec.Code)
+	}
+}
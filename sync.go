@@ -0,0 +1,520 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// syncPeerCatalog is the name a peer's database file is attached under
+const syncPeerCatalog = "sync_peer"
+
+// syncClockSkew is how far before the last sync a delta starts, so rows
+// stamped by a machine whose clock runs a little behind aren't missed. Merging
+// a row twice changes nothing.
+const syncClockSkew = time.Hour
+
+// maxSyncBatchSize caps the sync batch a server accepts from a peer; a first
+// full sync of the scraper cache is the largest
+const maxSyncBatchSize = 64 << 20
+
+// SyncBatch is what one instance sends another: the rows of the synced tables
+// changed since the last sync
+type SyncBatch struct {
+	Since            time.Time             `json:"since"` // Zero for everything
+	AIScrapes        []SyncAIScrape        `json:"ai_scraper_cache"`
+	NAEP             []SyncNAEP            `json:"naep_cache"`
+	Favorites        []SyncFavorite        `json:"favorites"`
+	Calls            []SyncCall            `json:"calls"`
+	ApplicationNotes []SyncApplicationNote `json:"application_notes"`
+}
+
+// SyncAIScrape is an ai_scraper_cache row; the newer extraction wins
+type SyncAIScrape struct {
+	NCESSCH         string          `json:"ncessch"`
+	SchoolName      string          `json:"school_name"`
+	SourceURL       string          `json:"source_url"`
+	MarkdownContent string          `json:"markdown_content"`
+	LegacyData      json.RawMessage `json:"legacy_data,omitempty"`
+	ExtractedAt     time.Time       `json:"extracted_at"`
+}
+
+// SyncNAEP is a naep_cache row; the newer extraction wins
+type SyncNAEP struct {
+	NCESSCH        string          `json:"ncessch"`
+	State          string          `json:"state"`
+	District       string          `json:"district"`
+	StateScores    json.RawMessage `json:"state_scores,omitempty"`
+	DistrictScores json.RawMessage `json:"district_scores,omitempty"`
+	NationalScores json.RawMessage `json:"national_scores,omitempty"`
+	ExtractedAt    time.Time       `json:"extracted_at"`
+}
+
+// SyncFavorite is a school saved to a child's list, with the child's profile.
+// Children are matched by name; NCESSCH is empty for a child with no saved
+// schools.
+type SyncFavorite struct {
+	Child   string          `json:"child"`
+	Grade   string          `json:"grade"`
+	Needs   json.RawMessage `json:"needs,omitempty"`
+	NCESSCH string          `json:"ncessch,omitempty"`
+	AddedAt time.Time       `json:"added_at,omitzero"`
+}
+
+// SyncCall is a call log entry. Calls are only ever added, so one is merged
+// unless the other side has a call to the same school at the same time with
+// the same note.
+type SyncCall struct {
+	NCESSCH  string `json:"ncessch"`
+	CalledAt string `json:"called_at"` // Wall-clock time, as callLogTimeLayout
+	Note     string `json:"note"`
+}
+
+// SyncApplicationNote is an application's notes; the newer update wins.
+// Notes are only merged into applications both sides track.
+type SyncApplicationNote struct {
+	Season    string    `json:"season"`
+	NCESSCH   string    `json:"ncessch"`
+	Notes     string    `json:"notes"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// SyncCounts counts the rows a sync changed on one side
+type SyncCounts struct {
+	AIScrapes int `json:"ai_scraper_cache"`
+	NAEP      int `json:"naep_cache"`
+	Favorites int `json:"favorites"`
+	Notes     int `json:"notes"` // Call log entries and application notes
+}
+
+// Total is the number of rows changed
+func (c SyncCounts) Total() int {
+	return c.AIScrapes + c.NAEP + c.Favorites + c.Notes
+}
+
+// SyncResult is what a sync with a peer changed on each side
+type SyncResult struct {
+	Peer   string     `json:"peer"`
+	Since  time.Time  `json:"since"`  // Zero for a full sync
+	Pulled SyncCounts `json:"pulled"` // Rows merged into this instance
+	Pushed SyncCounts `json:"pushed"` // Rows merged into the peer
+}
+
+// syncPeer is the other instance in a sync
+type syncPeer interface {
+	Export(since time.Time) (*SyncBatch, error)
+	Apply(b *SyncBatch) (SyncCounts, error)
+	Close() error
+}
+
+// attachedPeer is another instance's database file, attached to this one
+type attachedPeer struct {
+	db *DB
+}
+
+func (p attachedPeer) Export(since time.Time) (*SyncBatch, error) {
+	return p.db.exportSync(syncPeerCatalog, since)
+}
+
+func (p attachedPeer) Apply(b *SyncBatch) (SyncCounts, error) {
+	return p.db.applySync(syncPeerCatalog, b)
+}
+
+func (p attachedPeer) Close() error {
+	_, err := p.db.conn.Exec("DETACH " + syncPeerCatalog)
+	return err
+}
+
+// remotePeer is another instance's web server, reached through its sync API
+type remotePeer struct {
+	remote *remoteDB
+}
+
+func (p remotePeer) Export(since time.Time) (*SyncBatch, error) {
+	var b SyncBatch
+	path := "/api/v1/sync"
+	if !since.IsZero() {
+		path += "?" + url.Values{"since": {since.UTC().Format(time.RFC3339)}}.Encode()
+	}
+	if err := p.remote.do(http.MethodGet, path, nil, &b); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+func (p remotePeer) Apply(b *SyncBatch) (SyncCounts, error) {
+	var counts SyncCounts
+	err := p.remote.do(http.MethodPost, "/api/v1/sync", b, &counts)
+	return counts, err
+}
+
+func (p remotePeer) Close() error {
+	return nil
+}
+
+// openSyncPeer connects to a peer given as an http or https server URL, or as
+// a data directory or database file. It returns the peer and the key its
+// watermark is stored under.
+func (d *DB) openSyncPeer(peer, token string) (syncPeer, string, error) {
+	peer = strings.TrimSpace(peer)
+	if peer == "" {
+		return nil, "", errors.New("peer cannot be empty: pass a data directory or server URL")
+	}
+	if strings.HasPrefix(peer, "http://") || strings.HasPrefix(peer, "https://") {
+		remote, err := newRemoteDB(peer, token)
+		if err != nil {
+			return nil, "", err
+		}
+		return remotePeer{remote}, remote.baseURL, nil
+	}
+
+	path, err := filepath.Abs(peer)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid peer path %q: %w", peer, err)
+	}
+	if info, err := os.Stat(path); err != nil {
+		return nil, "", fmt.Errorf("peer %s not found: %w", path, err)
+	} else if info.IsDir() {
+		path = filepath.Join(path, "data.duckdb")
+		if _, err := os.Stat(path); err != nil {
+			return nil, "", fmt.Errorf("no School Finder database in %s: %w", peer, err)
+		}
+	}
+	if own, err := filepath.Abs(filepath.Join(d.dataDir, "data.duckdb")); err == nil && own == path {
+		return nil, "", errors.New("the peer is this instance's own database")
+	}
+
+	if _, err := d.conn.Exec(fmt.Sprintf("ATTACH %s AS %s", quoteLiteral(path), syncPeerCatalog)); err != nil {
+		return nil, "", fmt.Errorf("failed to open peer database %s (if schoolfinder is running on it, stop it or sync with its server URL instead): %w", path, err)
+	}
+	return attachedPeer{d}, path, nil
+}
+
+// syncTable names a table in catalog, or in this database when catalog is empty
+func syncTable(catalog, table string) string {
+	if catalog == "" {
+		return table
+	}
+	return catalog + "." + table
+}
+
+// SyncWithPeer merges AI and NAEP caches, children's saved schools, call log
+// notes, and application notes with another instance, in both directions.
+// Only rows changed since the last sync with the peer are exchanged unless
+// full is set. Deletions aren't synced.
+func SyncWithPeer(db *DB, peer, token string, full bool, now time.Time) (*SyncResult, error) {
+	p, key, err := db.openSyncPeer(peer, token)
+	if err != nil {
+		return nil, err
+	}
+	defer p.Close()
+
+	result := &SyncResult{Peer: key}
+	if !full {
+		var last sql.NullTime
+		err := db.conn.QueryRow(`SELECT synced_at FROM sync_peers WHERE peer = $1`, key).Scan(&last)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("failed to load last sync: %w", err)
+		}
+		if last.Valid {
+			result.Since = last.Time.Add(-syncClockSkew)
+		}
+	}
+
+	// Read both sides before writing either, so each gets only the other's rows
+	theirs, err := p.Export(result.Since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from peer: %w", err)
+	}
+	ours, err := db.exportSync("", result.Since)
+	if err != nil {
+		return nil, err
+	}
+	if result.Pulled, err = db.applySync("", theirs); err != nil {
+		return nil, err
+	}
+	if result.Pushed, err = p.Apply(ours); err != nil {
+		return nil, fmt.Errorf("failed to write to peer: %w", err)
+	}
+
+	_, err = db.conn.Exec(`
+		INSERT INTO sync_peers (peer, synced_at) VALUES ($1, $2)
+		ON CONFLICT (peer) DO UPDATE SET synced_at = EXCLUDED.synced_at
+	`, key, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record sync: %w", err)
+	}
+	if logger != nil {
+		logger.Info("Synced with peer", "peer", key, "pulled", result.Pulled.Total(), "pushed", result.Pushed.Total())
+	}
+	return result, nil
+}
+
+// rawJSON converts a JSON column read as text to a message, nil when NULL
+func rawJSON(s sql.NullString) json.RawMessage {
+	if !s.Valid || s.String == "" {
+		return nil
+	}
+	return json.RawMessage(s.String)
+}
+
+// exportSync reads the synced rows in catalog changed after since. Cache rows
+// count as changed when extracted or stored, so rows merged from a third
+// instance are passed on.
+func (d *DB) exportSync(catalog string, since time.Time) (*SyncBatch, error) {
+	b := &SyncBatch{Since: since}
+
+	rows, err := d.conn.Query(fmt.Sprintf(`
+		SELECT ncessch, COALESCE(school_name, ''), COALESCE(source_url, ''), COALESCE(markdown_content, ''), legacy_data::VARCHAR, extracted_at
+		FROM %s
+		WHERE extracted_at IS NOT NULL AND greatest(extracted_at, COALESCE(created_at, extracted_at)) > $1
+		ORDER BY ncessch
+	`, syncTable(catalog, "ai_scraper_cache")), since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read AI scraper cache: %w", err)
+	}
+	for rows.Next() {
+		var r SyncAIScrape
+		var legacy sql.NullString
+		if err := rows.Scan(&r.NCESSCH, &r.SchoolName, &r.SourceURL, &r.MarkdownContent, &legacy, &r.ExtractedAt); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan AI scraper cache: %w", err)
+		}
+		r.LegacyData = rawJSON(legacy)
+		b.AIScrapes = append(b.AIScrapes, r)
+	}
+	rows.Close()
+
+	rows, err = d.conn.Query(fmt.Sprintf(`
+		SELECT ncessch, COALESCE(state, ''), COALESCE(district, ''), state_scores::VARCHAR, district_scores::VARCHAR, national_scores::VARCHAR, extracted_at
+		FROM %s
+		WHERE extracted_at IS NOT NULL AND greatest(extracted_at, COALESCE(created_at, extracted_at)) > $1
+		ORDER BY ncessch
+	`, syncTable(catalog, "naep_cache")), since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read NAEP cache: %w", err)
+	}
+	for rows.Next() {
+		var r SyncNAEP
+		var state, district, national sql.NullString
+		if err := rows.Scan(&r.NCESSCH, &r.State, &r.District, &state, &district, &national, &r.ExtractedAt); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan NAEP cache: %w", err)
+		}
+		r.StateScores, r.DistrictScores, r.NationalScores = rawJSON(state), rawJSON(district), rawJSON(national)
+		b.NAEP = append(b.NAEP, r)
+	}
+	rows.Close()
+
+	rows, err = d.conn.Query(fmt.Sprintf(`
+		SELECT c.name, c.grade, c.needs::VARCHAR, COALESCE(cs.ncessch, ''), cs.added_at
+		FROM %s c
+		LEFT JOIN %s cs ON cs.child_id = c.id
+		WHERE c.created_at > $1 OR cs.added_at > $1
+		ORDER BY c.name, cs.added_at
+	`, syncTable(catalog, "children"), syncTable(catalog, "child_schools")), since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read saved schools: %w", err)
+	}
+	for rows.Next() {
+		var r SyncFavorite
+		var needs sql.NullString
+		var added sql.NullTime
+		if err := rows.Scan(&r.Child, &r.Grade, &needs, &r.NCESSCH, &added); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan saved schools: %w", err)
+		}
+		r.Needs, r.AddedAt = rawJSON(needs), added.Time
+		b.Favorites = append(b.Favorites, r)
+	}
+	rows.Close()
+
+	rows, err = d.conn.Query(fmt.Sprintf(`
+		SELECT ncessch, strftime(called_at, '%%Y-%%m-%%d %%H:%%M'), note
+		FROM %s
+		WHERE created_at > $1
+		ORDER BY called_at, id
+	`, syncTable(catalog, "school_calls")), since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read call log: %w", err)
+	}
+	for rows.Next() {
+		var r SyncCall
+		if err := rows.Scan(&r.NCESSCH, &r.CalledAt, &r.Note); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan call log: %w", err)
+		}
+		b.Calls = append(b.Calls, r)
+	}
+	rows.Close()
+
+	rows, err = d.conn.Query(fmt.Sprintf(`
+		SELECT season, ncessch, COALESCE(notes, ''), updated_at
+		FROM %s
+		WHERE updated_at > $1
+		ORDER BY season, ncessch
+	`, syncTable(catalog, "applications")), since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read application notes: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var r SyncApplicationNote
+		if err := rows.Scan(&r.Season, &r.NCESSCH, &r.Notes, &r.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan application notes: %w", err)
+		}
+		b.ApplicationNotes = append(b.ApplicationNotes, r)
+	}
+	return b, rows.Err()
+}
+
+// applySync merges a batch into catalog, or into this database when catalog
+// is empty, and counts the rows it changed. Caches keep the newer extraction
+// and application notes the newer update; saved schools and calls are added
+// when missing.
+func (d *DB) applySync(catalog string, b *SyncBatch) (SyncCounts, error) {
+	var counts SyncCounts
+	seq := func(name string) string {
+		return quoteLiteral(syncTable(catalog, name))
+	}
+
+	tx, err := d.conn.Begin()
+	if err != nil {
+		return counts, fmt.Errorf("failed to start sync: %w", err)
+	}
+	defer tx.Rollback()
+
+	// exec runs a statement and adds the rows it changed to n
+	exec := func(n *int, query string, args ...interface{}) error {
+		result, err := tx.Exec(query, args...)
+		if err != nil {
+			return err
+		}
+		affected, _ := result.RowsAffected()
+		*n += int(affected)
+		return nil
+	}
+
+	var refreshed []string
+	for _, r := range b.AIScrapes {
+		before := counts.AIScrapes
+		err := exec(&counts.AIScrapes, fmt.Sprintf(`
+			INSERT INTO %s (ncessch, school_name, source_url, markdown_content, legacy_data, extracted_at)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (ncessch) DO UPDATE SET
+				school_name = EXCLUDED.school_name,
+				source_url = EXCLUDED.source_url,
+				markdown_content = EXCLUDED.markdown_content,
+				legacy_data = EXCLUDED.legacy_data,
+				extracted_at = EXCLUDED.extracted_at,
+				created_at = now()
+			WHERE extracted_at IS NULL OR EXCLUDED.extracted_at > extracted_at
+		`, syncTable(catalog, "ai_scraper_cache")), r.NCESSCH, r.SchoolName, r.SourceURL, r.MarkdownContent, jsonParam(r.LegacyData), r.ExtractedAt)
+		if err != nil {
+			return counts, fmt.Errorf("failed to merge AI scraper cache for %s: %w", r.NCESSCH, err)
+		}
+		if counts.AIScrapes > before {
+			refreshed = append(refreshed, r.NCESSCH)
+		}
+	}
+
+	var naepRefreshed []string
+	for _, r := range b.NAEP {
+		before := counts.NAEP
+		err := exec(&counts.NAEP, fmt.Sprintf(`
+			INSERT INTO %s (ncessch, state, district, state_scores, district_scores, national_scores, extracted_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			ON CONFLICT (ncessch) DO UPDATE SET
+				state = EXCLUDED.state,
+				district = EXCLUDED.district,
+				state_scores = EXCLUDED.state_scores,
+				district_scores = EXCLUDED.district_scores,
+				national_scores = EXCLUDED.national_scores,
+				extracted_at = EXCLUDED.extracted_at,
+				created_at = now()
+			WHERE extracted_at IS NULL OR EXCLUDED.extracted_at > extracted_at
+		`, syncTable(catalog, "naep_cache")), r.NCESSCH, r.State, r.District, jsonParam(r.StateScores), jsonParam(r.DistrictScores), jsonParam(r.NationalScores), r.ExtractedAt)
+		if err != nil {
+			return counts, fmt.Errorf("failed to merge NAEP cache for %s: %w", r.NCESSCH, err)
+		}
+		if counts.NAEP > before {
+			naepRefreshed = append(naepRefreshed, r.NCESSCH)
+		}
+	}
+
+	for _, r := range b.Favorites {
+		err := exec(&counts.Favorites, fmt.Sprintf(`
+			INSERT INTO %s (id, name, grade, needs) VALUES (nextval(%s), $1, $2, $3)
+			ON CONFLICT (name) DO NOTHING
+		`, syncTable(catalog, "children"), seq("children_seq")), r.Child, r.Grade, jsonParam(r.Needs))
+		if err != nil {
+			return counts, fmt.Errorf("failed to merge child %s: %w", r.Child, err)
+		}
+		if r.NCESSCH == "" {
+			continue
+		}
+		added := sql.NullTime{Time: r.AddedAt, Valid: !r.AddedAt.IsZero()}
+		err = exec(&counts.Favorites, fmt.Sprintf(`
+			INSERT INTO %s (child_id, ncessch, added_at)
+			SELECT id, $2, COALESCE($3, now()) FROM %s WHERE name = $1
+			ON CONFLICT DO NOTHING
+		`, syncTable(catalog, "child_schools"), syncTable(catalog, "children")), r.Child, r.NCESSCH, added)
+		if err != nil {
+			return counts, fmt.Errorf("failed to merge %s's saved school %s: %w", r.Child, r.NCESSCH, err)
+		}
+	}
+
+	for _, r := range b.Calls {
+		err := exec(&counts.Notes, fmt.Sprintf(`
+			INSERT INTO %s (id, ncessch, called_at, note)
+			SELECT nextval(%s), $1, CAST($2 AS TIMESTAMP), $3
+			WHERE NOT EXISTS (
+				SELECT 1 FROM %s
+				WHERE ncessch = $1 AND called_at = CAST($2 AS TIMESTAMP) AND note = $3
+			)
+		`, syncTable(catalog, "school_calls"), seq("school_calls_seq"), syncTable(catalog, "school_calls")), r.NCESSCH, r.CalledAt, r.Note)
+		if err != nil {
+			return counts, fmt.Errorf("failed to merge call to %s: %w", r.NCESSCH, err)
+		}
+	}
+
+	for _, r := range b.ApplicationNotes {
+		err := exec(&counts.Notes, fmt.Sprintf(`
+			UPDATE %s SET notes = $1, updated_at = $2
+			WHERE season = $3 AND ncessch = $4 AND updated_at < $2 AND COALESCE(notes, '') <> $1
+		`, syncTable(catalog, "applications")), r.Notes, r.UpdatedAt, r.Season, r.NCESSCH)
+		if err != nil {
+			return counts, fmt.Errorf("failed to merge application notes for %s: %w", r.NCESSCH, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return counts, fmt.Errorf("failed to save sync: %w", err)
+	}
+
+	if catalog == "" {
+		// Re-derive program flags, CTE, sports, arts, and languages from the
+		// newer website data, as saving an extraction does. An attached peer's
+		// tables are filled when it next opens (SyncWebsiteTables).
+		scraper := &AIScraperService{db: d}
+		for _, id := range refreshed {
+			d.aiCache.Delete(id)
+			if data, err := scraper.loadCachedData(id, cacheNoExpiry); err == nil {
+				saveDerivedData(d, data)
+			}
+			d.notifyCacheInvalidated(id)
+		}
+		for _, id := range naepRefreshed {
+			d.naepCache.Delete(id)
+			d.notifyCacheInvalidated(id)
+		}
+	}
+	return counts, nil
+}
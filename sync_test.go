@@ -0,0 +1,169 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSyncWithPeer(t *testing.T) {
+	laptop, cleanup := SetupTestDB(t)
+	defer cleanup()
+	desktop, cleanupDesktop := SetupTestDB(t)
+	defer cleanupDesktop()
+
+	now := time.Now()
+	season := currentSeason(now)
+	addChildSchool := func(db *DB, ncessch string) {
+		t.Helper()
+		child := &Child{Name: "Ada", Grade: "3"}
+		if err := SaveChild(db, child); err != nil {
+			t.Fatal(err)
+		}
+		if err := SaveChildSchool(db, child.ID, ncessch); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The laptop has an older extraction, one saved school, a call, and notes
+	// the desktop updates afterwards
+	if err := laptop.SaveAIScraperCache("360000100001", "Lincoln Elementary", "https://lincoln.example", "laptop", nil, now.Add(-48*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := laptop.SaveNAEPCache("360000100001", "CA", "", []byte(`[]`), nil, nil, now); err != nil {
+		t.Fatal(err)
+	}
+	addChildSchool(laptop, "360000100001")
+	if _, err := AddSchoolCall(laptop, "360000100001", "2026-09-01 10:00", "Ask about aftercare", now); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveApplication(laptop, &Application{Season: season, NCESSCH: "360000100003", Status: appPlanning, Notes: "Tour first"}); err != nil {
+		t.Fatal(err)
+	}
+
+	desktopMarkdown := "desktop\n- Sport: Tennis | spring | Varsity"
+	if err := desktop.SaveAIScraperCache("360000100001", "Lincoln Elementary", "https://lincoln.example", desktopMarkdown, nil, now.Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := desktop.SaveNAEPCache("360000100003", "TX", "", []byte(`[]`), nil, nil, now); err != nil {
+		t.Fatal(err)
+	}
+	addChildSchool(desktop, "360000100003")
+	for _, note := range []string{"Ask about aftercare", "Buses start at 7:10"} {
+		if _, err := AddSchoolCall(desktop, "360000100001", "2026-09-01 10:00", note, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := SaveApplication(desktop, &Application{Season: season, NCESSCH: "360000100003", Status: appPlanning, Notes: "Toured; loved the library"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The desktop's database file is the peer; it can't be open elsewhere
+	desktopDir := desktop.dataDir
+	desktop.Close()
+
+	result, err := SyncWithPeer(laptop, desktopDir, "", false, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := SyncResult{
+		Pulled: SyncCounts{AIScrapes: 1, NAEP: 1, Favorites: 1, Notes: 2},
+		Pushed: SyncCounts{NAEP: 1, Favorites: 1},
+	}
+	if result.Pulled != want.Pulled || result.Pushed != want.Pushed || !result.Since.IsZero() {
+		t.Errorf("first sync = %+v, want %+v", result, want)
+	}
+
+	_, _, markdown, _, _, err := laptop.LoadAIScraperCache("360000100001", 7*24*time.Hour)
+	if err != nil || markdown != desktopMarkdown {
+		t.Errorf("laptop cache = %q, %v; want the desktop's newer extraction", markdown, err)
+	}
+	// Tables derived from website data follow the newer extraction
+	var sports int
+	if err := laptop.conn.QueryRow(`SELECT count(*) FROM school_sports WHERE ncessch = '360000100001' AND sport = 'Tennis'`).Scan(&sports); err != nil || sports != 1 {
+		t.Errorf("laptop has %d synced sports, %v; want 1", sports, err)
+	}
+	app, err := laptop.GetApplication(season, "360000100003")
+	if err != nil || app.Notes != "Toured; loved the library" {
+		t.Errorf("laptop application = %+v, %v", app, err)
+	}
+	children, err := laptop.Children()
+	if err != nil || len(children) != 1 || len(children[0].Schools) != 2 {
+		t.Errorf("laptop children = %+v, %v", children, err)
+	}
+
+	// Syncing again resends the overlap but changes nothing
+	result, err = SyncWithPeer(laptop, desktopDir, "", false, now.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Since.IsZero() || result.Pulled.Total() != 0 || result.Pushed.Total() != 0 {
+		t.Errorf("second sync = %+v, want no changes", result)
+	}
+
+	// A peer's web server works the same way
+	desktop, err = NewDB(desktopDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer desktop.Close()
+	fastHashing(t)
+	users, _ := loadUserStore("")
+	if err := users.Save("ada", "ada-password", RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	token, _ := users.IssueToken("ada")
+	srv := httptest.NewServer(NewRouter(ServerConfig{DB: desktop, Users: users}))
+	defer srv.Close()
+	var calls int
+	if err := desktop.conn.QueryRow(`SELECT count(*) FROM school_calls`).Scan(&calls); err != nil || calls != 2 {
+		t.Errorf("desktop has %d calls, %v; want 2", calls, err)
+	}
+	if _, err := AddSchoolCall(laptop, "360000100002", "", "Left a voicemail", now); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SyncWithPeer(laptop, srv.URL, "", false, now); userError(err) == nil || userError(err).Status != http.StatusUnauthorized {
+		t.Errorf("sync without a token = %v", err)
+	}
+	result, err = SyncWithPeer(laptop, srv.URL, token, false, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Peer != srv.URL || result.Pushed.Notes != 1 || result.Pulled.Total() != 0 {
+		t.Errorf("server sync = %+v", result)
+	}
+
+	for _, peer := range []string{"", laptop.dataDir, desktopDir + "/missing"} {
+		if _, err := SyncWithPeer(laptop, peer, "", false, now); err == nil {
+			t.Errorf("expected an error syncing with %q", peer)
+		}
+	}
+
+	router := NewRouter(ServerConfig{DB: laptop, Users: users})
+	send := func(method, path, contentType, body string) int {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	if code := send("GET", "/api/v1/sync?since=yesterday", "", ""); code != http.StatusBadRequest {
+		t.Errorf("GET /api/v1/sync with a bad since returned %d", code)
+	}
+	if code := send("POST", "/api/v1/sync", "text/plain", "{}"); code != http.StatusUnsupportedMediaType {
+		t.Errorf("POST /api/v1/sync as text/plain returned %d", code)
+	}
+	if code := send("POST", "/api/v1/sync", "application/json", `{"calls": [`+strings.Repeat(" ", maxSyncBatchSize)+`]}`); code != http.StatusRequestEntityTooLarge {
+		t.Errorf("POST /api/v1/sync with an oversized batch returned %d", code)
+	}
+
+	// Without accounts everyone is an admin, so sync isn't offered
+	rec := httptest.NewRecorder()
+	NewRouter(ServerConfig{DB: laptop}).ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/sync", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("single-user GET /api/v1/sync returned %d", rec.Code)
+	}
+}
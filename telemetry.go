@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// Usage events that can be counted. Nothing else is recorded: no queries,
+// school IDs, or other details, only how many times each happened per day.
+const (
+	usageSearch     = "search"
+	usageScrape     = "scrape"
+	usageAgentQuery = "agent_query"
+)
+
+// usageEvents is the allowlist of usage events, in display order
+var usageEvents = []string{usageSearch, usageScrape, usageAgentQuery}
+
+// usagePayloadSchema versions the upload format
+const usagePayloadSchema = 1
+
+// usageUploadClient posts usage uploads
+var usageUploadClient = &http.Client{Timeout: 30 * time.Second}
+
+// UsageReport summarizes locally recorded usage counts
+type UsageReport struct {
+	Enabled         bool
+	ChangedAt       time.Time // When tracking was last turned on or off; zero if never
+	UploadedThrough time.Time // Last day included in an upload; zero if never uploaded
+	Totals          []UsageCount
+}
+
+// UsageCount is how many times one event happened
+type UsageCount struct {
+	Event      string `json:"event"`
+	Total      int64  `json:"total"`
+	Last30Days int64  `json:"last_30_days"`
+}
+
+// UsagePayload is everything an upload sends: event totals for whole days
+// not uploaded before, with no identifiers
+type UsagePayload struct {
+	Schema  int              `json:"schema"`
+	Version string           `json:"version"`
+	From    string           `json:"from"` // First day included, YYYY-MM-DD
+	To      string           `json:"to"`   // Last day included
+	Counts  map[string]int64 `json:"counts"`
+}
+
+// loadUsageSettings reads whether usage tracking is on into memory, since
+// every counted event checks it
+func (d *DB) loadUsageSettings() error {
+	var enabled bool
+	err := d.conn.QueryRow(`SELECT COALESCE(any_value(enabled), false) FROM usage_settings`).Scan(&enabled)
+	if err != nil {
+		return fmt.Errorf("failed to load usage settings: %w", err)
+	}
+	d.usageEnabled.Store(enabled)
+	return nil
+}
+
+// RecordUsage counts one use of a feature if the user opted in to usage
+// tracking. Events outside the allowlist are dropped.
+func (d *DB) RecordUsage(event string) {
+	if d == nil || !d.usageEnabled.Load() || !slices.Contains(usageEvents, event) {
+		return
+	}
+	_, err := d.conn.Exec(`
+		INSERT INTO usage_counts (event, day, count) VALUES ($1, current_date, 1)
+		ON CONFLICT (event, day) DO UPDATE SET count = count + 1
+	`, event)
+	if err != nil && logger != nil {
+		logger.Warn("Failed to record usage", "error", err, "event", event)
+	}
+}
+
+// SetUsageTracking turns usage tracking on or off. Turning it off deletes the
+// counts recorded so far.
+func (d *DB) SetUsageTracking(enabled bool) error {
+	tx, err := d.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		INSERT INTO usage_settings (id, enabled, changed_at) VALUES (1, $1, now())
+		ON CONFLICT (id) DO UPDATE SET enabled = EXCLUDED.enabled, changed_at = EXCLUDED.changed_at
+	`, enabled); err != nil {
+		return fmt.Errorf("failed to save usage settings: %w", err)
+	}
+	if !enabled {
+		if _, err := tx.Exec(`DELETE FROM usage_counts`); err != nil {
+			return fmt.Errorf("failed to delete usage counts: %w", err)
+		}
+		if _, err := tx.Exec(`UPDATE usage_settings SET uploaded_through = NULL`); err != nil {
+			return fmt.Errorf("failed to reset usage uploads: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit usage settings: %w", err)
+	}
+	d.usageEnabled.Store(enabled)
+	return nil
+}
+
+// UsageReport returns whether tracking is on and the counts recorded so far
+func (d *DB) UsageReport() (*UsageReport, error) {
+	report := &UsageReport{Enabled: d.usageEnabled.Load()}
+
+	var changedAt, uploadedThrough *time.Time
+	err := d.conn.QueryRow(`SELECT any_value(changed_at), any_value(uploaded_through) FROM usage_settings`).Scan(&changedAt, &uploadedThrough)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load usage settings: %w", err)
+	}
+	if changedAt != nil {
+		report.ChangedAt = *changedAt
+	}
+	if uploadedThrough != nil {
+		report.UploadedThrough = *uploadedThrough
+	}
+
+	rows, err := d.conn.Query(`
+		SELECT event, sum(count), sum(count) FILTER (WHERE day > current_date - 30)
+		FROM usage_counts
+		GROUP BY event
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load usage counts: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[string]UsageCount)
+	for rows.Next() {
+		var c UsageCount
+		var recent *int64
+		if err := rows.Scan(&c.Event, &c.Total, &recent); err != nil {
+			return nil, fmt.Errorf("failed to scan usage count: %w", err)
+		}
+		if recent != nil {
+			c.Last30Days = *recent
+		}
+		counts[c.Event] = c
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, event := range usageEvents {
+		c := counts[event]
+		c.Event = event
+		report.Totals = append(report.Totals, c)
+	}
+	return report, nil
+}
+
+// UsageUploadPayload builds the next upload: totals for complete days (before
+// today) that haven't been uploaded. It returns nil when there's nothing new.
+func (d *DB) UsageUploadPayload() (*UsagePayload, error) {
+	rows, err := d.conn.Query(`
+		SELECT event, sum(count), strftime(min(day), '%Y-%m-%d'), strftime(max(day), '%Y-%m-%d')
+		FROM usage_counts
+		WHERE day < current_date
+			AND day > COALESCE((SELECT any_value(uploaded_through) FROM usage_settings), DATE '1970-01-01')
+		GROUP BY event
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load usage counts: %w", err)
+	}
+	defer rows.Close()
+
+	payload := &UsagePayload{Schema: usagePayloadSchema, Version: version, Counts: make(map[string]int64)}
+	for rows.Next() {
+		var event, from, to string
+		var count int64
+		if err := rows.Scan(&event, &count, &from, &to); err != nil {
+			return nil, fmt.Errorf("failed to scan usage count: %w", err)
+		}
+		if !slices.Contains(usageEvents, event) {
+			continue
+		}
+		payload.Counts[event] = count
+		if payload.From == "" || from < payload.From {
+			payload.From = from
+		}
+		if to > payload.To {
+			payload.To = to
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(payload.Counts) == 0 {
+		return nil, nil
+	}
+	return payload, nil
+}
+
+// UploadUsage posts the next usage payload to url as JSON and remembers the
+// last day sent. Tracking must be on. It returns the payload sent, or nil if
+// there was nothing new.
+func UploadUsage(ctx context.Context, d *DB, client *http.Client, url string) (*UsagePayload, error) {
+	if !d.usageEnabled.Load() {
+		return nil, fmt.Errorf("usage tracking is off; turn it on with `schoolfinder stats --enable` first")
+	}
+	if url == "" {
+		return nil, fmt.Errorf("no upload URL: pass --url or set SCHOOLFINDER_TELEMETRY_URL")
+	}
+
+	payload, err := d.UsageUploadPayload()
+	if err != nil || payload == nil {
+		return nil, err
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	err = webhookRetry.Do(ctx, func() error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return newHTTPStatusError(resp)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("usage upload failed: %w", err)
+	}
+
+	if _, err := d.conn.Exec(`UPDATE usage_settings SET uploaded_through = $1`, payload.To); err != nil {
+		return nil, fmt.Errorf("failed to save usage upload: %w", err)
+	}
+	return payload, nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestUsageTracking(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	// Off by default: nothing is recorded
+	db.RecordUsage(usageSearch)
+	report, err := db.UsageReport()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Enabled || report.Totals[0].Total != 0 {
+		t.Fatalf("usage recorded without opting in: %+v", report)
+	}
+
+	if err := db.SetUsageTracking(true); err != nil {
+		t.Fatal(err)
+	}
+	db.RecordUsage(usageSearch)
+	db.RecordUsage(usageSearch)
+	db.RecordUsage(usageAgentQuery)
+	db.RecordUsage("lincoln high school") // Not an allowed event
+
+	report, err = db.UsageReport()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int64{usageSearch: 2, usageScrape: 0, usageAgentQuery: 1}
+	if !report.Enabled || len(report.Totals) != len(want) {
+		t.Fatalf("report = %+v", report)
+	}
+	for _, c := range report.Totals {
+		if c.Total != want[c.Event] || c.Last30Days != want[c.Event] {
+			t.Errorf("%s = %d total, %d recent; want %d", c.Event, c.Total, c.Last30Days, want[c.Event])
+		}
+	}
+
+	// Turning tracking off deletes the counts
+	if err := db.SetUsageTracking(false); err != nil {
+		t.Fatal(err)
+	}
+	db.RecordUsage(usageSearch)
+	if report, _ := db.UsageReport(); report.Enabled || report.Totals[0].Total != 0 {
+		t.Errorf("counts kept after opting out: %+v", report)
+	}
+}
+
+func TestUploadUsage(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	var received []UsagePayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload UsagePayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Error(err)
+		}
+		received = append(received, payload)
+	}))
+	defer server.Close()
+
+	if _, err := UploadUsage(context.Background(), db, server.Client(), server.URL); err == nil {
+		t.Fatal("uploaded with tracking off")
+	}
+	if err := db.SetUsageTracking(true); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only whole days are uploaded, so today's count waits
+	db.RecordUsage(usageSearch)
+	_, err := db.conn.Exec(`
+		INSERT INTO usage_counts (event, day, count) VALUES
+			('search', current_date - 2, 3),
+			('search', current_date - 1, 4),
+			('scrape', current_date - 1, 1)
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload, err := UploadUsage(context.Background(), db, server.Client(), server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payload == nil || payload.Counts[usageSearch] != 7 || payload.Counts[usageScrape] != 1 || payload.From >= payload.To {
+		t.Fatalf("payload = %+v", payload)
+	}
+	if len(received) != 1 || received[0].Counts[usageSearch] != 7 || received[0].Schema != usagePayloadSchema {
+		t.Errorf("server received %+v", received)
+	}
+
+	// The same days aren't sent twice
+	if payload, err := UploadUsage(context.Background(), db, server.Client(), server.URL); payload != nil || err != nil {
+		t.Errorf("second upload = %+v, %v", payload, err)
+	}
+	if report, _ := db.UsageReport(); report.UploadedThrough.IsZero() {
+		t.Error("upload wasn't remembered")
+	}
+}
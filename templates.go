@@ -0,0 +1,201 @@
+package main
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// templatePatterns are the globs for the web UI's pages and partials
+var templatePatterns = []string{"templates/*.html", "templates/partials/*.html"}
+
+// templateSet executes the web templates. In dev mode it reparses them whenever a
+// template file is added, removed, or changed, so edits show up on the next request
+// without restarting the server, and it logs each template it renders.
+type templateSet struct {
+	patterns []string
+	dev      bool
+
+	mu    sync.Mutex
+	tmpl  *template.Template
+	stamp string // Names, sizes, and modification times of the parsed files
+}
+
+// newTemplateSet parses the templates matching patterns, panicking if they don't parse
+func newTemplateSet(patterns []string, dev bool) *templateSet {
+	s := &templateSet{patterns: patterns, dev: dev}
+	s.stamp = s.fileStamp()
+	s.tmpl = template.Must(parseTemplates(patterns))
+	return s
+}
+
+// parseTemplates parses every file matching patterns into one template set with
+// the helper functions in templateFuncs
+func parseTemplates(patterns []string) (*template.Template, error) {
+	tmpl := template.New("").Funcs(templateFuncs)
+	for _, pattern := range patterns {
+		var err error
+		if tmpl, err = tmpl.ParseGlob(pattern); err != nil {
+			return nil, err
+		}
+	}
+	return tmpl, nil
+}
+
+// templateFuncs are formatting helpers available to every template, so partials can
+// show raw values without a view struct. Numbers may be any Go numeric type or a
+// sql.Null* value; missing values show as "N/A".
+//
+//	{{formatNumber .Enrollment}}        1,234
+//	{{pct .Charters .Total}}            42.5%
+//	{{ratio .Enrollment .Teachers}}     15.2:1
+//	{{naLabel .Phone}}                  (555) 123-4567, or N/A
+//	{{markdown .Summary}}               rendered HTML
+//	{{ratingClass .Rating}}             rating-orange, rating-b, or rating-other
+//	{{telURL .Phone}}                   tel:+15551234567, or empty
+var templateFuncs = template.FuncMap{
+	"formatNumber": formatNumber,
+	"pct":          pct,
+	"ratio":        ratio,
+	"naLabel":      naLabel,
+	"markdown":     markdownToHTML,
+	"ratingClass":  RatingClass,
+	"telURL":       telURL,
+}
+
+// formatNumber formats a number with thousands separators and at most one decimal place
+func formatNumber(v interface{}) string {
+	n, ok := templateNumber(v)
+	if !ok {
+		return "N/A"
+	}
+	return formatChartValue(n)
+}
+
+// pct formats part as a percentage of whole with one decimal place
+func pct(part, whole interface{}) string {
+	p, ok1 := templateNumber(part)
+	w, ok2 := templateNumber(whole)
+	if !ok1 || !ok2 || w == 0 {
+		return "N/A"
+	}
+	return fmt.Sprintf("%.1f%%", p/w*100)
+}
+
+// ratio formats a to b as "a:1", like a student-teacher ratio
+func ratio(a, b interface{}) string {
+	x, ok1 := templateNumber(a)
+	y, ok2 := templateNumber(b)
+	if !ok1 || !ok2 || y == 0 {
+		return "N/A"
+	}
+	return fmt.Sprintf("%.1f:1", x/y)
+}
+
+// telURL links a phone number for click-to-call, or returns "" when it can't
+// be dialed. html/template only allows http, https, and mailto links, so it's marked safe.
+func telURL(v interface{}) template.URL {
+	v = templateValue(v)
+	if v == nil {
+		return ""
+	}
+	if phone := dialablePhone(fmt.Sprint(v)); phone != "" {
+		return template.URL("tel:" + phone)
+	}
+	return ""
+}
+
+// naLabel returns v as text, or "N/A" when it's nil, NULL, or empty
+func naLabel(v interface{}) string {
+	v = templateValue(v)
+	if v == nil {
+		return "N/A"
+	}
+	if s := fmt.Sprint(v); s != "" {
+		return s
+	}
+	return "N/A"
+}
+
+// templateNumber converts a template argument to float64, unwrapping sql.Null* values
+func templateNumber(v interface{}) (float64, bool) {
+	return numericValue(templateValue(v))
+}
+
+// templateValue unwraps sql.Null* and other driver values, returning nil for NULL
+func templateValue(v interface{}) interface{} {
+	if valuer, ok := v.(driver.Valuer); ok {
+		value, err := valuer.Value()
+		if err != nil {
+			return nil
+		}
+		return value
+	}
+	return v
+}
+
+// ExecuteTemplate renders the named template to w
+func (s *templateSet) ExecuteTemplate(w io.Writer, name string, data interface{}) error {
+	tmpl, err := s.current()
+	if err != nil {
+		return err
+	}
+	if !s.dev {
+		return tmpl.ExecuteTemplate(w, name, data)
+	}
+
+	start := time.Now()
+	err = tmpl.ExecuteTemplate(w, name, data)
+	if err != nil {
+		log.Printf("Rendered %s in %v with error: %v", name, time.Since(start), err)
+	} else {
+		log.Printf("Rendered %s in %v", name, time.Since(start))
+	}
+	return err
+}
+
+// current returns the parsed templates, reparsing them first in dev mode if the
+// files have changed. A failed reparse is returned so the page shows it, and is
+// retried on the next request.
+func (s *templateSet) current() (*template.Template, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.dev {
+		return s.tmpl, nil
+	}
+
+	stamp := s.fileStamp()
+	if stamp == s.stamp {
+		return s.tmpl, nil
+	}
+	tmpl, err := parseTemplates(s.patterns)
+	if err != nil {
+		return nil, fmt.Errorf("reloading templates: %w", err)
+	}
+	s.tmpl, s.stamp = tmpl, stamp
+	log.Printf("Reloaded templates")
+	return tmpl, nil
+}
+
+// fileStamp summarizes the template files so changes can be detected cheaply
+func (s *templateSet) fileStamp() string {
+	var b strings.Builder
+	for _, pattern := range s.patterns {
+		paths, _ := filepath.Glob(pattern)
+		for _, path := range paths {
+			info, err := os.Stat(path)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(&b, "%s:%d:%d\n", path, info.Size(), info.ModTime().UnixNano())
+		}
+	}
+	return b.String()
+}
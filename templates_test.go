@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTemplateSetReload(t *testing.T) {
+	tests := []struct {
+		name string
+		dev  bool
+		want string
+	}{
+		{"dev mode reloads", true, "Goodbye, world"},
+		{"production keeps parsed templates", false, "Hello"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "page.html")
+			if err := os.WriteFile(path, []byte(`{{define "page.html"}}Hello{{end}}`), 0644); err != nil {
+				t.Fatal(err)
+			}
+			s := newTemplateSet([]string{filepath.Join(dir, "*.html")}, tt.dev)
+
+			var buf bytes.Buffer
+			if err := s.ExecuteTemplate(&buf, "page.html", nil); err != nil || buf.String() != "Hello" {
+				t.Fatalf("first render = %q, %v", buf.String(), err)
+			}
+
+			if err := os.WriteFile(path, []byte(`{{define "page.html"}}Goodbye, world{{end}}`), 0644); err != nil {
+				t.Fatal(err)
+			}
+			buf.Reset()
+			if err := s.ExecuteTemplate(&buf, "page.html", nil); err != nil || buf.String() != tt.want {
+				t.Errorf("render after edit = %q, %v; want %q", buf.String(), err, tt.want)
+			}
+		})
+	}
+}
+
+func TestTemplateSetReloadError(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "page.html")
+	if err := os.WriteFile(path, []byte(`{{define "page.html"}}Hello{{end}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s := newTemplateSet([]string{filepath.Join(dir, "*.html")}, true)
+
+	// A broken edit is reported on every request until it's fixed
+	if err := os.WriteFile(path, []byte(`{{define "page.html"}}{{.Broken{{end}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := s.ExecuteTemplate(&bytes.Buffer{}, "page.html", nil); err == nil || !strings.Contains(err.Error(), "reloading templates") {
+			t.Errorf("render %d of broken template: err = %v", i+1, err)
+		}
+	}
+
+	if err := os.WriteFile(path, []byte(`{{define "page.html"}}Fixed{{end}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := s.ExecuteTemplate(&buf, "page.html", nil); err != nil || buf.String() != "Fixed" {
+		t.Errorf("render after fix = %q, %v", buf.String(), err)
+	}
+}
+
+func TestDevModeErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		dev        bool
+		wantDetail bool
+	}{
+		{"dev mode", true, true},
+		{"production", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewWebHandler(nil, nil, nil)
+			if tt.dev {
+				h.enableDevMode()
+			}
+
+			rec := httptest.NewRecorder()
+			h.templateError(rec, errors.New(`template: no such template "missing.html"`))
+			if rec.Code != http.StatusInternalServerError {
+				t.Errorf("status = %d, want 500", rec.Code)
+			}
+			if got := strings.Contains(rec.Body.String(), "missing.html"); got != tt.wantDetail {
+				t.Errorf("template error body %q shows details = %v, want %v", rec.Body.String(), got, tt.wantDetail)
+			}
+
+			req := httptest.NewRequest("POST", "/schools/360000100001/summary", nil)
+			req.Header.Set("HX-Request", "true")
+			rec = httptest.NewRecorder()
+			h.renderUserError(rec, req, errors.New("Binder Error: column not found"), "The summary couldn't be generated")
+			if got := strings.Contains(rec.Body.String(), "Binder Error"); got != tt.wantDetail {
+				t.Errorf("user error body shows details = %v, want %v", got, tt.wantDetail)
+			}
+		})
+	}
+}
+
+func TestTemplateFuncs(t *testing.T) {
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"formatNumber int", formatNumber(1234567), "1,234,567"},
+		{"formatNumber float", formatNumber(1234.56), "1,234.6"},
+		{"formatNumber NullInt64", formatNumber(sql.NullInt64{Int64: 2500, Valid: true}), "2,500"},
+		{"formatNumber NULL", formatNumber(sql.NullInt64{}), "N/A"},
+		{"formatNumber text", formatNumber("many"), "N/A"},
+		{"pct", pct(17, 40), "42.5%"},
+		{"pct of zero", pct(5, 0), "N/A"},
+		{"pct NULL", pct(sql.NullFloat64{}, 40), "N/A"},
+		{"ratio", ratio(sql.NullInt64{Int64: 456, Valid: true}, sql.NullFloat64{Float64: 30, Valid: true}), "15.2:1"},
+		{"ratio by zero", ratio(456, 0.0), "N/A"},
+		{"naLabel string", naLabel(sql.NullString{String: "(555) 123-4567", Valid: true}), "(555) 123-4567"},
+		{"naLabel NULL", naLabel(sql.NullString{}), "N/A"},
+		{"naLabel empty", naLabel(""), "N/A"},
+		{"naLabel nil", naLabel(nil), "N/A"},
+		{"naLabel number", naLabel(42), "42"},
+	}
+
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestTemplateFuncsInTemplates(t *testing.T) {
+	dir := t.TempDir()
+	page := `{{define "page.html"}}{{formatNumber .Enrollment}} students, {{ratio .Enrollment .Teachers}}, {{naLabel .Phone}}{{markdown "**Note**"}}{{end}}`
+	if err := os.WriteFile(filepath.Join(dir, "page.html"), []byte(page), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s := newTemplateSet([]string{filepath.Join(dir, "*.html")}, false)
+
+	school := MockSchool("360000100001", "Lincoln Elementary School", "Springfield", "CA", "KG", "05")
+	school.Enrollment = sql.NullInt64{Int64: 1250, Valid: true}
+	school.Teachers = sql.NullFloat64{Float64: 50, Valid: true}
+	school.Phone = sql.NullString{}
+
+	var buf bytes.Buffer
+	if err := s.ExecuteTemplate(&buf, "page.html", school); err != nil {
+		t.Fatalf("ExecuteTemplate failed: %v", err)
+	}
+	if want := "1,250 students, 25.0:1, N/A<p><strong>Note</strong></p>"; strings.TrimSpace(buf.String()) != want {
+		t.Errorf("rendered %q, want %q", buf.String(), want)
+	}
+}
@@ -7,7 +7,7 @@ import (
 )
 
 // SetupTestDB creates a test database with mock data
-func SetupTestDB(t *testing.T) (*DB, func()) {
+func SetupTestDB(t testing.TB) (*DB, func()) {
 	t.Helper()
 
 	// Create temporary directory for test database
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"cmp"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Kinds of key dates in a school's application timeline
+const (
+	dateOpenHouse  = "open_house"
+	dateTour       = "tour"
+	dateDeadline   = "application_deadline"
+	dateLottery    = "lottery"
+	dateDecision   = "decision"
+	dateEnrollment = "enrollment_deadline"
+	dateOther      = "other"
+)
+
+// schoolDateKinds lists key date kinds in the order a timeline groups them
+var schoolDateKinds = []string{dateOpenHouse, dateTour, dateDeadline, dateLottery, dateDecision, dateEnrollment, dateOther}
+
+var schoolDateLabels = map[string]string{
+	dateOpenHouse:  "Open house",
+	dateTour:       "Tour",
+	dateDeadline:   "Application deadline",
+	dateLottery:    "Lottery",
+	dateDecision:   "Decision notification",
+	dateEnrollment: "Enrollment deadline",
+	dateOther:      "Other",
+}
+
+// timelineDateLayout is how key dates are entered and exported
+const timelineDateLayout = "2006-01-02"
+
+// SchoolDate is a key date in a school's application season, such as an
+// application deadline or a lottery
+type SchoolDate struct {
+	ID         int64
+	NCESSCH    string
+	SchoolName string // From the directory when listed
+	Location   string // Street address and city, for calendar events
+	Kind       string
+	Date       time.Time
+	Note       string
+	CreatedAt  time.Time
+}
+
+// Label is the kind of date in words, e.g. "Application deadline"
+func (d SchoolDate) Label() string {
+	return schoolDateLabels[d.Kind]
+}
+
+// ParseSchoolDateKind accepts a kind by key or label in any case, with spaces
+// or dashes for underscores, e.g. "open-house" or "Application deadline"
+func ParseSchoolDateKind(s string) (string, error) {
+	key := strings.ToLower(strings.NewReplacer(" ", "_", "-", "_").Replace(strings.TrimSpace(s)))
+	for _, kind := range schoolDateKinds {
+		if key == kind || key == strings.ToLower(strings.ReplaceAll(schoolDateLabels[kind], " ", "_")) {
+			return kind, nil
+		}
+	}
+	return "", fmt.Errorf("unknown date type %q (use one of: %s)", s, strings.Join(schoolDateKinds, ", "))
+}
+
+// AddSchoolDate validates and records a key date for a school. date is YYYY-MM-DD.
+func AddSchoolDate(db *DB, ncessch, kind, date, note string) (*SchoolDate, error) {
+	kind, err := ParseSchoolDateKind(kind)
+	if err != nil {
+		return nil, err
+	}
+	day, err := time.Parse(timelineDateLayout, strings.TrimSpace(date))
+	if err != nil {
+		return nil, fmt.Errorf("invalid date %q: use YYYY-MM-DD", date)
+	}
+	school, err := db.GetSchoolByID(ncessch)
+	if err != nil {
+		return nil, err
+	}
+
+	sd := &SchoolDate{NCESSCH: school.NCESSCH, SchoolName: school.Name, Kind: kind, Date: day, Note: strings.TrimSpace(note)}
+	err = db.conn.QueryRow(`
+		INSERT INTO school_dates (ncessch, kind, date, note) VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`, sd.NCESSCH, sd.Kind, day.Format(timelineDateLayout), sd.Note).Scan(&sd.ID, &sd.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save date: %w", err)
+	}
+	return sd, nil
+}
+
+// DeleteSchoolDate removes a key date by ID
+func (d *DB) DeleteSchoolDate(id int64) error {
+	result, err := d.conn.Exec(`DELETE FROM school_dates WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete date: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("no date with ID %d", id)
+	}
+	return nil
+}
+
+// SchoolDates returns the key dates of one school, or of every school when
+// ncessch is empty, grouped by school name, then kind, then date
+func (d *DB) SchoolDates(ncessch string) ([]SchoolDate, error) {
+	rows, err := d.conn.Query(`
+		SELECT id, ncessch, kind, date, COALESCE(note, ''), created_at
+		FROM school_dates
+		WHERE $1 = '' OR ncessch = $1
+	`, ncessch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load dates: %w", err)
+	}
+	defer rows.Close()
+
+	var dates []SchoolDate
+	for rows.Next() {
+		var sd SchoolDate
+		if err := rows.Scan(&sd.ID, &sd.NCESSCH, &sd.Kind, &sd.Date, &sd.Note, &sd.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan date: %w", err)
+		}
+		dates = append(dates, sd)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Names come from the directory so corrections and merges apply
+	schools := make(map[string]*School)
+	for i := range dates {
+		sd := &dates[i]
+		school, ok := schools[sd.NCESSCH]
+		if !ok {
+			school, err = d.GetSchoolByID(sd.NCESSCH)
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return nil, err
+			}
+			schools[sd.NCESSCH] = school
+		}
+		if school == nil {
+			sd.SchoolName = sd.NCESSCH
+			continue
+		}
+		sd.SchoolName = school.Name
+		sd.Location = schoolDateLocation(school)
+	}
+
+	slices.SortFunc(dates, func(a, b SchoolDate) int {
+		return cmp.Or(
+			cmp.Compare(a.SchoolName, b.SchoolName),
+			cmp.Compare(a.NCESSCH, b.NCESSCH),
+			cmp.Compare(slices.Index(schoolDateKinds, a.Kind), slices.Index(schoolDateKinds, b.Kind)),
+			a.Date.Compare(b.Date),
+			cmp.Compare(a.ID, b.ID),
+		)
+	})
+	return dates, nil
+}
+
+// schoolDateLocation is a one-line address for calendar events
+func schoolDateLocation(s *School) string {
+	var parts []string
+	if s.Street1.Valid && s.Street1.String != "" {
+		parts = append(parts, s.Street1.String)
+	}
+	if s.City != "" {
+		parts = append(parts, s.City)
+	}
+	parts = append(parts, strings.TrimSpace(s.State+" "+s.Zip.String))
+	return strings.Join(parts, ", ")
+}
+
+// WriteTimelineCSV writes key dates as CSV, one row per date, in the order given
+func WriteTimelineCSV(w io.Writer, dates []SchoolDate) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"school", "ncessch", "type", "date", "note"}); err != nil {
+		return err
+	}
+	for _, sd := range dates {
+		if err := cw.Write([]string{sd.SchoolName, sd.NCESSCH, sd.Label(), sd.Date.Format(timelineDateLayout), sd.Note}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteTimelineICS writes key dates as an iCalendar file of all-day events that
+// calendar apps can import. UIDs are stable, so importing again updates events
+// instead of duplicating them.
+func WriteTimelineICS(w io.Writer, dates []SchoolDate, now time.Time) error {
+	var b strings.Builder
+	line := func(s string) {
+		b.WriteString(foldICSLine(s))
+		b.WriteString("\r\n")
+	}
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//School Finder//Application Timeline//EN")
+	line("CALSCALE:GREGORIAN")
+	line("X-WR-CALNAME:School applications")
+	stamp := now.UTC().Format("20060102T150405Z")
+	for _, sd := range dates {
+		description := fmt.Sprintf("%s\nNCES ID %s", sd.Label(), sd.NCESSCH)
+		if sd.Note != "" {
+			description = sd.Note + "\n\n" + description
+		}
+
+		line("BEGIN:VEVENT")
+		line(fmt.Sprintf("UID:school-date-%d-%s@schoolfinder", sd.ID, sd.NCESSCH))
+		line("DTSTAMP:" + stamp)
+		line("DTSTART;VALUE=DATE:" + sd.Date.Format("20060102"))
+		line("DTEND;VALUE=DATE:" + sd.Date.AddDate(0, 0, 1).Format("20060102"))
+		line("SUMMARY:" + escapeICSText(sd.Label()+": "+sd.SchoolName))
+		line("DESCRIPTION:" + escapeICSText(description))
+		if sd.Location != "" {
+			line("LOCATION:" + escapeICSText(sd.Location))
+		}
+		line("CATEGORIES:" + escapeICSText(sd.Label()))
+		line("TRANSP:TRANSPARENT")
+		line("END:VEVENT")
+	}
+	line("END:VCALENDAR")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// escapeICSText escapes a TEXT value for iCalendar (RFC 5545 §3.3.11)
+func escapeICSText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+// foldICSLine splits a content line into 75-octet pieces joined by CRLF and a
+// space, without splitting UTF-8 characters (RFC 5545 §3.1)
+func foldICSLine(s string) string {
+	const limit = 75
+	var b strings.Builder
+	width := 0
+	for _, r := range s {
+		n := utf8.RuneLen(r)
+		if width+n > limit {
+			b.WriteString("\r\n ")
+			width = 1 // The leading space counts toward the next line
+		}
+		b.WriteRune(r)
+		width += n
+	}
+	return b.String()
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSchoolDateKind(t *testing.T) {
+	tests := map[string]string{
+		"application_deadline": dateDeadline,
+		"Application deadline": dateDeadline,
+		"open-house":           dateOpenHouse,
+		" LOTTERY ":            dateLottery,
+	}
+	for input, want := range tests {
+		if got, err := ParseSchoolDateKind(input); got != want || err != nil {
+			t.Errorf("ParseSchoolDateKind(%q) = %q, %v; want %q", input, got, err, want)
+		}
+	}
+	if _, err := ParseSchoolDateKind("recital"); err == nil {
+		t.Error("unknown kind accepted")
+	}
+}
+
+func TestSchoolDates(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	for _, tt := range []struct{ id, kind, date string }{
+		{"360000100001", "recital", "2027-01-15"},
+		{"360000100001", dateDeadline, "Jan 15"},
+		{"999999999999", dateDeadline, "2027-01-15"},
+	} {
+		if _, err := AddSchoolDate(db, tt.id, tt.kind, tt.date, ""); err == nil {
+			t.Errorf("AddSchoolDate(%s, %s, %s) succeeded", tt.id, tt.kind, tt.date)
+		}
+	}
+
+	for _, tt := range []struct{ id, kind, date, note string }{
+		{"360000100002", dateLottery, "2027-02-20", "Online, results by email"},
+		{"360000100001", dateDeadline, "2027-01-15", "Round 1"},
+		{"360000100001", dateOpenHouse, "2026-11-07", ""},
+		{"360000100001", dateDeadline, "2027-01-08", "Early"},
+	} {
+		if _, err := AddSchoolDate(db, tt.id, tt.kind, tt.date, tt.note); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dates, err := db.SchoolDates("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var order []string
+	for _, sd := range dates {
+		order = append(order, sd.SchoolName+"/"+sd.Kind+"/"+sd.Date.Format(timelineDateLayout))
+	}
+	want := []string{
+		"Lincoln Elementary School/open_house/2026-11-07",
+		"Lincoln Elementary School/application_deadline/2027-01-08",
+		"Lincoln Elementary School/application_deadline/2027-01-15",
+		"Washington High School/lottery/2027-02-20",
+	}
+	if strings.Join(order, "\n") != strings.Join(want, "\n") {
+		t.Errorf("timeline order:\n%s\nwant:\n%s", strings.Join(order, "\n"), strings.Join(want, "\n"))
+	}
+
+	if err := db.DeleteSchoolDate(dates[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.DeleteSchoolDate(dates[0].ID); err == nil {
+		t.Error("deleted a missing date")
+	}
+	if dates, _ := db.SchoolDates("360000100001"); len(dates) != 2 {
+		t.Errorf("Lincoln has %d dates after delete", len(dates))
+	}
+}
+
+func TestWriteTimelineICS(t *testing.T) {
+	dates := []SchoolDate{{
+		ID:         7,
+		NCESSCH:    "360000100001",
+		SchoolName: "Lincoln Elementary School",
+		Location:   "123 Lincoln St, San Francisco, CA 94102",
+		Kind:       dateDeadline,
+		Date:       time.Date(2027, 1, 15, 0, 0, 0, 0, time.UTC),
+		Note:       "Round 1; bring proof of address, birth certificate, and immunization records for each child applying",
+	}}
+
+	var buf bytes.Buffer
+	if err := WriteTimelineICS(&buf, dates, time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatal(err)
+	}
+	ics := buf.String()
+
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\nVERSION:2.0\r\n",
+		"UID:school-date-7-360000100001@schoolfinder\r\n",
+		"DTSTAMP:20261001T120000Z\r\n",
+		"DTSTART;VALUE=DATE:20270115\r\nDTEND;VALUE=DATE:20270116\r\n",
+		"SUMMARY:Application deadline: Lincoln Elementary School\r\n",
+		"LOCATION:123 Lincoln St\\, San Francisco\\, CA 94102\r\n",
+		"END:VEVENT\r\nEND:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(ics, want) {
+			t.Errorf("calendar is missing %q:\n%s", want, ics)
+		}
+	}
+	for _, line := range strings.Split(ics, "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("line longer than 75 octets: %q", line)
+		}
+	}
+	unfolded := strings.ReplaceAll(ics, "\r\n ", "")
+	if !strings.Contains(unfolded, "DESCRIPTION:Round 1\\; bring proof of address\\, birth certificate\\,") {
+		t.Errorf("description isn't escaped:\n%s", unfolded)
+	}
+}
+
+func TestWebTimelineExport(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	router := NewRouter(ServerConfig{DB: db})
+
+	if _, err := AddSchoolDate(db, "360000100001", dateTour, "2026-11-12", "9am, meet in the office"); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/timeline.csv", nil))
+	want := "school,ncessch,type,date,note\nLincoln Elementary School,360000100001,Tour,2026-11-12,\"9am, meet in the office\"\n"
+	if rec.Code != 200 || rec.Body.String() != want {
+		t.Errorf("CSV export = %d %q", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/timeline.ics", nil))
+	if rec.Code != 200 || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/calendar") || !strings.Contains(rec.Body.String(), "SUMMARY:Tour: Lincoln Elementary School") {
+		t.Errorf("iCal export = %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/schools/360000100001", nil))
+	if body := rec.Body.String(); !strings.Contains(body, `<time datetime="2026-11-12">Thu, Nov 12, 2026</time> <strong>Tour</strong>: 9am, meet in the office`) {
+		t.Error("detail page doesn't list the key date")
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TourQuestion is a question to ask on a school tour along with why it was suggested
+type TourQuestion struct {
+	Category string `json:"category"`
+	Question string `json:"question"`
+	Reason   string `json:"reason"`
+}
+
+// Thresholds used to flag weak points worth asking about
+const (
+	tourHighRatio         = 20.0 // Student/teacher ratio above this prompts a class-size question
+	tourLargeEnrollment   = 1000 // Enrollment above this prompts an individual-attention question
+	tourLowProficiency    = 30.0 // NAEP % proficient below this prompts an academic-support question
+	tourNAEPDeclinePoints = -2.0 // NAEP mean score change at or below this is treated as a decline
+	tourMaxNAEPQuestions  = 3    // Cap NAEP-driven questions so they don't crowd out the rest
+)
+
+// Tour question categories
+const (
+	tourCategoryAcademics  = "Academics"
+	tourCategoryClassSize  = "Class Size & Staffing"
+	tourCategoryPrograms   = "Programs & Activities"
+	tourCategoryAdmissions = "Admissions"
+	tourCategoryContact    = "Communication"
+)
+
+// GenerateTourQuestions builds a tailored question list from a school's data gaps and weak points
+func GenerateTourQuestions(school *School, enhanced *EnhancedSchoolData, naep *NAEPData) []TourQuestion {
+	var questions []TourQuestion
+	add := func(category, question, reason string) {
+		questions = append(questions, TourQuestion{Category: category, Question: question, Reason: reason})
+	}
+
+	// Class size and staffing
+	if school.Enrollment.Valid && school.Teachers.Valid && school.Teachers.Float64 > 0 {
+		ratio := float64(school.Enrollment.Int64) / school.Teachers.Float64
+		if ratio > tourHighRatio {
+			add(tourCategoryClassSize,
+				"What are typical class sizes in my child's grade, and are there aides or co-teachers in larger classes?",
+				fmt.Sprintf("Student/teacher ratio is %.1f:1, above %.0f:1", ratio, tourHighRatio))
+		}
+	} else {
+		add(tourCategoryClassSize,
+			"How many students and teachers does the school have, and what are typical class sizes?",
+			"Enrollment or teacher counts are missing from federal data")
+	}
+
+	if school.Enrollment.Valid && school.Enrollment.Int64 > tourLargeEnrollment {
+		add(tourCategoryClassSize,
+			"How does the school make sure individual students don't get lost (advisory periods, houses, counselors)?",
+			fmt.Sprintf("Large school with %d students", school.Enrollment.Int64))
+	}
+
+	// Academics from NAEP
+	if naep == nil {
+		add(tourCategoryAcademics,
+			"How does the school measure student progress, and where can I see recent state test results?",
+			"No NAEP assessment data is loaded for this school")
+	} else {
+		useDistrict := len(naep.DistrictScores) > 0
+		naepQuestions := 0
+		for _, grade := range []int{4, 8} {
+			for _, subject := range []string{"mathematics", "reading", "science"} {
+				if naepQuestions >= tourMaxNAEPQuestions {
+					break
+				}
+				current, previous, change := naep.GetScoreTrend(subject, grade, useDistrict)
+				if current == nil {
+					continue
+				}
+				if previous != nil && change <= tourNAEPDeclinePoints {
+					add(tourCategoryAcademics,
+						fmt.Sprintf("Area %s scores have declined recently. What is the school doing to strengthen %s instruction?", subject, subject),
+						fmt.Sprintf("Grade %d %s fell %.1f points from %d to %d", grade, subject, -change, previous.Year, current.Year))
+					naepQuestions++
+				} else if current.AtProficient > 0 && current.AtProficient < tourLowProficiency {
+					add(tourCategoryAcademics,
+						fmt.Sprintf("What extra help (tutoring, intervention blocks) is available for students who struggle in %s?", subject),
+						fmt.Sprintf("Only %.0f%% of grade %d students in the area are proficient in %s", current.AtProficient, grade, subject))
+					naepQuestions++
+				}
+			}
+		}
+	}
+
+	// Programs and activities from AI extraction
+	if enhanced == nil {
+		add(tourCategoryPrograms,
+			"What arts, music, sports, and after-school programs are offered, and are there fees?",
+			"No program information has been extracted from the school website")
+	} else {
+		content := strings.ToLower(enhanced.MarkdownContent)
+		if len(enhanced.Arts) == 0 && len(enhanced.ArtsPrograms) == 0 && !containsAny(content, "arts", "music", "band", "theater", "choir") {
+			add(tourCategoryPrograms,
+				"Do students get regular art and music classes? How often, and taught by specialists?",
+				"No arts or music programs are listed")
+		}
+		if len(enhanced.Languages) == 0 && len(enhanced.LanguagePrograms) == 0 && !containsAny(content, "language", "spanish", "french", "mandarin") {
+			add(tourCategoryPrograms,
+				"Are world languages taught, and starting in which grade?",
+				"No language programs are listed")
+		}
+		if isSecondaryLevel(school) && len(enhanced.Sports) == 0 && len(enhanced.Athletics) == 0 && !containsAny(content, "sport", "athletic") {
+			add(tourCategoryPrograms,
+				"Which sports teams are available, and are there cuts or participation fees?",
+				"No athletics are listed")
+		}
+		if enhanced.SchoolHours == "" && !containsAny(content, "hours", "bell schedule") {
+			add(tourCategoryPrograms,
+				"What are the daily start and end times, and is before- or after-care available?",
+				"School hours are not listed")
+		}
+	}
+
+	// Admissions
+	if school.CharterString() == "Yes" {
+		add(tourCategoryAdmissions,
+			"How does enrollment work (lottery dates, sibling or neighborhood priority, waitlist movement)?",
+			"Charter school admissions usually differ from neighborhood assignment")
+	}
+
+	// Communication and contacts
+	if !school.Website.Valid || school.Website.String == "" {
+		add(tourCategoryContact,
+			"How does the school communicate with families (newsletters, apps, portal)?",
+			"No website is listed in federal data")
+	}
+	if enhanced != nil && enhanced.MainOfficeEmail == "" && len(enhanced.StaffContacts) == 0 && !strings.Contains(strings.ToLower(enhanced.MarkdownContent), "@") {
+		add(tourCategoryContact,
+			"Who is the best person to contact with follow-up questions, and what is their email?",
+			"No staff email contacts were found")
+	}
+
+	return questions
+}
+
+// containsAny reports whether s contains any of the given substrings
+func containsAny(s string, substrs ...string) bool {
+	for _, sub := range substrs {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
+
+// isSecondaryLevel reports whether a school serves middle or high school grades
+func isSecondaryLevel(school *School) bool {
+	level := strings.ToLower(school.LevelString())
+	return strings.Contains(level, "middle") || strings.Contains(level, "high") || strings.Contains(level, "secondary")
+}
+
+// FormatTourQuestionsMarkdown renders tour questions as a printable markdown checklist
+func FormatTourQuestionsMarkdown(school *School, questions []TourQuestion) string {
+	var b strings.Builder
+
+	b.WriteString(fmt.Sprintf("# Tour Questions: %s\n\n", school.Name))
+	b.WriteString(fmt.Sprintf("%s, %s | NCES ID %s\n\n", school.City, school.State, school.NCESSCH))
+
+	if len(questions) == 0 {
+		b.WriteString("No specific concerns were found in the available data.\n")
+		return b.String()
+	}
+
+	// Group by category, keeping first-seen order
+	var categories []string
+	byCategory := make(map[string][]TourQuestion)
+	for _, q := range questions {
+		if _, ok := byCategory[q.Category]; !ok {
+			categories = append(categories, q.Category)
+		}
+		byCategory[q.Category] = append(byCategory[q.Category], q)
+	}
+
+	for _, category := range categories {
+		b.WriteString(fmt.Sprintf("## %s\n\n", category))
+		for _, q := range byCategory[category] {
+			b.WriteString(fmt.Sprintf("- [ ] %s\n  - _Why: %s_\n", q.Question, q.Reason))
+		}
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
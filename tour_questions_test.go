@@ -0,0 +1,141 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestGenerateTourQuestions tests that weak points and data gaps produce tailored questions
+func TestGenerateTourQuestions(t *testing.T) {
+	testCases := []struct {
+		name          string
+		modify        func(s *School)
+		enhanced      *EnhancedSchoolData
+		naep          *NAEPData
+		wantReasons   []string
+		unwantReasons []string
+	}{
+		{
+			name:        "No enrichment asks about programs and progress",
+			wantReasons: []string{"No NAEP assessment data", "No program information"},
+			// MockSchool has a 20:1 ratio, which is not above the threshold
+			unwantReasons: []string{"Student/teacher ratio"},
+		},
+		{
+			name: "High ratio and large enrollment",
+			modify: func(s *School) {
+				s.Enrollment = sql.NullInt64{Int64: 1500, Valid: true}
+				s.Teachers = sql.NullFloat64{Float64: 50, Valid: true}
+			},
+			wantReasons: []string{"Student/teacher ratio is 30.0:1", "Large school with 1500 students"},
+		},
+		{
+			name: "Missing staffing data",
+			modify: func(s *School) {
+				s.Teachers = sql.NullFloat64{}
+			},
+			wantReasons: []string{"Enrollment or teacher counts are missing"},
+		},
+		{
+			name: "Declining NAEP trend",
+			naep: &NAEPData{StateScores: []NAEPScore{
+				MockNAEPScore("mathematics", 4, 2022, 230.0, 35.0),
+				MockNAEPScore("mathematics", 4, 2019, 236.0, 40.0),
+			}},
+			wantReasons: []string{"Grade 4 mathematics fell 6.0 points from 2019 to 2022"},
+		},
+		{
+			name: "Low proficiency",
+			naep: &NAEPData{StateScores: []NAEPScore{
+				MockNAEPScore("reading", 8, 2022, 250.0, 22.0),
+			}},
+			wantReasons: []string{"Only 22% of grade 8 students"},
+		},
+		{
+			name:          "Extracted content without arts or contacts",
+			enhanced:      &EnhancedSchoolData{MarkdownContent: "## Principal\nJane Doe\n\n## Hours\n8:00-3:00\n\nSpanish offered."},
+			wantReasons:   []string{"No arts or music programs", "No staff email contacts"},
+			unwantReasons: []string{"No language programs", "School hours are not listed"},
+		},
+		{
+			name: "Charter admissions",
+			modify: func(s *School) {
+				s.CharterText = sql.NullString{String: "Yes", Valid: true}
+			},
+			wantReasons: []string{"Charter school admissions"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			school := MockSchool("123456789012", "Test School", "Test District", "CA", "KG", "05")
+			if tc.modify != nil {
+				tc.modify(school)
+			}
+
+			questions := GenerateTourQuestions(school, tc.enhanced, tc.naep)
+
+			var reasons []string
+			for _, q := range questions {
+				reasons = append(reasons, q.Reason)
+			}
+			joined := strings.Join(reasons, "\n")
+
+			for _, want := range tc.wantReasons {
+				if !strings.Contains(joined, want) {
+					t.Errorf("Expected a question with reason %q, got:\n%s", want, joined)
+				}
+			}
+			for _, unwanted := range tc.unwantReasons {
+				if strings.Contains(joined, unwanted) {
+					t.Errorf("Did not expect a question with reason %q", unwanted)
+				}
+			}
+		})
+	}
+}
+
+// TestFormatTourQuestionsMarkdown tests markdown grouping by category
+func TestFormatTourQuestionsMarkdown(t *testing.T) {
+	school := MockSchool("123456789012", "Test School", "Test District", "CA", "KG", "05")
+	questions := []TourQuestion{
+		{Category: "Academics", Question: "Q1?", Reason: "R1"},
+		{Category: "Programs", Question: "Q2?", Reason: "R2"},
+		{Category: "Academics", Question: "Q3?", Reason: "R3"},
+	}
+
+	md := FormatTourQuestionsMarkdown(school, questions)
+
+	if !strings.HasPrefix(md, "# Tour Questions: Test School") {
+		t.Errorf("Expected markdown title, got %q", md)
+	}
+	if strings.Count(md, "## Academics") != 1 {
+		t.Error("Expected questions to be grouped under a single Academics heading")
+	}
+	if strings.Index(md, "Q3?") > strings.Index(md, "## Programs") {
+		t.Error("Expected Q3 to be grouped with Academics before Programs")
+	}
+	if !strings.Contains(md, "- [ ] Q2?\n  - _Why: R2_") {
+		t.Error("Expected checklist item with reason")
+	}
+}
+
+func TestTourQuestionsUnknownSchool(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	router := NewRouter(ServerConfig{DB: db})
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest("GET", "/schools/999999999999/questions.md", nil),
+		httptest.NewRequest("POST", "/schools/999999999999/questions", nil),
+	} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("%s %s: status %d, want 404", req.Method, req.URL.Path, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,385 @@
+package main
+
+import (
+	"cmp"
+	"database/sql"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Bus eligibility is estimated from straight-line distance, but districts
+// measure the walking or driving route, which is usually longer. Schools
+// closer than the threshold but within routeFactor of it are borderline.
+const (
+	routeFactor       = 1.3
+	earthRadiusMiles  = 3958.8
+	defaultBusMiles   = 1.5
+	defaultBusSource  = "No rule configured for this district or state; using a common 1.5-mile walk zone"
+	maxBusRuleMiles   = 50
+	maxHomeLabelChars = 100
+)
+
+// Bus eligibility estimates
+const (
+	busLikely     = "Likely eligible"
+	busBorderline = "Borderline"
+	busUnlikely   = "Likely not eligible"
+)
+
+var (
+	stateScopePattern    = regexp.MustCompile(`^[A-Z]{2}$`)
+	districtScopePattern = regexp.MustCompile(`^\d{7}$`)
+)
+
+// BusRule is a district's or state's bus eligibility rule: students who live
+// farther than Miles from school are bused
+type BusRule struct {
+	ID        int64
+	Scope     string // State code like "CA", or a district LEAID
+	GradeLow  string // Optional grade band the rule covers, as CCD codes
+	GradeHigh string
+	Miles     float64
+	Source    string // Where the rule comes from, e.g. a policy name or URL
+	CreatedAt time.Time
+}
+
+// IsDistrict reports whether the rule is a district's rather than a state's
+func (r BusRule) IsDistrict() bool {
+	return districtScopePattern.MatchString(r.Scope)
+}
+
+// Grades formats the rule's grade band, e.g. "K-5", or "All grades"
+func (r BusRule) Grades() string {
+	if r.GradeLow == "" {
+		return "All grades"
+	}
+	return gradeLabel(r.GradeLow) + "-" + gradeLabel(r.GradeHigh)
+}
+
+// covers reports whether the rule applies to a grade
+func (r BusRule) covers(grade string) bool {
+	if r.GradeLow == "" {
+		return true
+	}
+	rank, ok := gradeRank(grade)
+	low, _ := gradeRank(r.GradeLow)
+	high, _ := gradeRank(r.GradeHigh)
+	return ok && low <= rank && rank <= high
+}
+
+// parseGradeBand parses a grade band such as "K-5" or "9-12" into CCD codes
+func parseGradeBand(band string) (low, high string, err error) {
+	band = strings.TrimSpace(band)
+	if band == "" {
+		return "", "", nil
+	}
+	lowText, highText, found := strings.Cut(band, "-")
+	if !found {
+		highText = lowText
+	}
+	low, lowOK := normalizeGrade(strings.TrimSpace(lowText))
+	high, highOK := normalizeGrade(strings.TrimSpace(highText))
+	if !lowOK || !highOK {
+		return "", "", fmt.Errorf("invalid grade band %q (use e.g. K-5 or 9-12)", band)
+	}
+	lowRank, _ := gradeRank(low)
+	highRank, _ := gradeRank(high)
+	if lowRank > highRank {
+		return "", "", fmt.Errorf("invalid grade band %q: %s is above %s", band, gradeLabel(low), gradeLabel(high))
+	}
+	return low, high, nil
+}
+
+// SaveBusRule validates and saves a bus eligibility rule. grades is an
+// optional band such as "K-5".
+func SaveBusRule(db *DB, scope, grades string, miles float64, source string) (*BusRule, error) {
+	rule := &BusRule{Scope: strings.ToUpper(strings.TrimSpace(scope)), Miles: miles, Source: strings.TrimSpace(source)}
+	if !stateScopePattern.MatchString(rule.Scope) && !districtScopePattern.MatchString(rule.Scope) {
+		return nil, fmt.Errorf("invalid scope %q (use a state code like CA or a 7-digit district ID)", scope)
+	}
+	if miles <= 0 || miles > maxBusRuleMiles {
+		return nil, fmt.Errorf("invalid distance %g (use miles between 0 and %d)", miles, maxBusRuleMiles)
+	}
+	if rule.Source == "" {
+		return nil, fmt.Errorf("a rule needs a source, such as the district policy or its URL")
+	}
+	var err error
+	if rule.GradeLow, rule.GradeHigh, err = parseGradeBand(grades); err != nil {
+		return nil, err
+	}
+
+	err = db.conn.QueryRow(`
+		INSERT INTO bus_rules (scope, grade_low, grade_high, miles, source) VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`, rule.Scope, rule.GradeLow, rule.GradeHigh, rule.Miles, rule.Source).Scan(&rule.ID, &rule.CreatedAt)
+	if err != nil {
+		if logger != nil {
+			logger.Error("Failed to save bus rule", "error", err)
+		}
+		return nil, fmt.Errorf("failed to save bus rule: %w", err)
+	}
+	return rule, nil
+}
+
+// BusRules loads every bus eligibility rule, by scope and grade
+func (d *DB) BusRules() ([]BusRule, error) {
+	rows, err := d.conn.Query(`SELECT id, scope, grade_low, grade_high, miles, source, created_at FROM bus_rules`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load bus rules: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []BusRule
+	for rows.Next() {
+		var r BusRule
+		if err := rows.Scan(&r.ID, &r.Scope, &r.GradeLow, &r.GradeHigh, &r.Miles, &r.Source, &r.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan bus rule: %w", err)
+		}
+		rules = append(rules, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(rules, func(a, b BusRule) int {
+		lowA, _ := gradeRank(a.GradeLow)
+		lowB, _ := gradeRank(b.GradeLow)
+		return cmp.Or(cmp.Compare(a.Scope, b.Scope), cmp.Compare(lowA, lowB), cmp.Compare(a.ID, b.ID))
+	})
+	return rules, nil
+}
+
+// DeleteBusRule removes a bus eligibility rule
+func (d *DB) DeleteBusRule(id int64) error {
+	result, err := d.conn.Exec(`DELETE FROM bus_rules WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete bus rule: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("no bus rule with ID %d", id)
+	}
+	return nil
+}
+
+// matchBusRule picks the rule for a school and grade: a district rule before a
+// state rule, and a rule for the grade's band before one for all grades. It
+// returns false when no rule applies.
+func matchBusRule(rules []BusRule, school *School, grade string) (BusRule, bool) {
+	for _, scope := range []string{school.DistrictID.String, school.State} {
+		var match *BusRule
+		for i, r := range rules {
+			if scope == "" || r.Scope != scope || !r.covers(grade) {
+				continue
+			}
+			if match == nil || (match.GradeLow == "" && r.GradeLow != "") {
+				match = &rules[i]
+			}
+		}
+		if match != nil {
+			return *match, true
+		}
+	}
+	return BusRule{}, false
+}
+
+// Home is the family's home location, used to estimate distances to schools
+type Home struct {
+	Lat   float64
+	Lon   float64
+	Label string // e.g. "Home" or a street name, for display
+}
+
+// String formats the location, e.g. "Home (45.5200, -122.6800)"
+func (h Home) String() string {
+	coords := fmt.Sprintf("%.4f, %.4f", h.Lat, h.Lon)
+	if h.Label == "" {
+		return coords
+	}
+	return h.Label + " (" + coords + ")"
+}
+
+// parseCoordinates parses "lat,lon", e.g. "45.52,-122.68"
+func parseCoordinates(s string) (lat, lon float64, err error) {
+	latText, lonText, found := strings.Cut(s, ",")
+	if found {
+		lat, err = strconv.ParseFloat(strings.TrimSpace(latText), 64)
+	}
+	if found && err == nil {
+		lon, err = strconv.ParseFloat(strings.TrimSpace(lonText), 64)
+	}
+	if !found || err != nil || lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+		return 0, 0, fmt.Errorf("invalid coordinates %q (use latitude,longitude, e.g. 45.52,-122.68)", s)
+	}
+	return lat, lon, nil
+}
+
+// SaveHome sets the home location from "lat,lon" coordinates
+func SaveHome(db *DB, coordinates, label string) (*Home, error) {
+	lat, lon, err := parseCoordinates(coordinates)
+	if err != nil {
+		return nil, err
+	}
+	home := &Home{Lat: lat, Lon: lon, Label: truncateString(strings.TrimSpace(label), maxHomeLabelChars)}
+
+	_, err = db.conn.Exec(`
+		INSERT OR REPLACE INTO home_location (id, lat, lon, label, updated_at)
+		VALUES (1, $1, $2, $3, CURRENT_TIMESTAMP)
+	`, home.Lat, home.Lon, home.Label)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save home location: %w", err)
+	}
+	return home, nil
+}
+
+// Home loads the home location, or nil if none is set
+func (d *DB) Home() (*Home, error) {
+	var home Home
+	var label sql.NullString
+	err := d.conn.QueryRow(`SELECT lat, lon, label FROM home_location WHERE id = 1`).Scan(&home.Lat, &home.Lon, &label)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load home location: %w", err)
+	}
+	home.Label = label.String
+	return &home, nil
+}
+
+// SchoolCoordinates returns a school's latitude and longitude from the EDGE
+// geocode files. ok is false when no loaded file covers the school.
+func (d *DB) SchoolCoordinates(ncessch string) (lat, lon float64, ok bool, err error) {
+	err = d.conn.QueryRow(`SELECT lat, lon FROM school_coordinates WHERE ncessch = $1`, ncessch).Scan(&lat, &lon)
+	if err == sql.ErrNoRows {
+		return 0, 0, false, nil
+	}
+	if err != nil {
+		return 0, 0, false, fmt.Errorf("failed to look up school coordinates: %w", err)
+	}
+	return lat, lon, true, nil
+}
+
+// distanceMiles is the great-circle distance between two points
+func distanceMiles(lat1, lon1, lat2, lon2 float64) float64 {
+	rad := func(deg float64) float64 { return deg * math.Pi / 180 }
+	dLat := rad(lat2 - lat1)
+	dLon := rad(lon2 - lon1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(rad(lat1))*math.Cos(rad(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusMiles * math.Asin(math.Sqrt(a))
+}
+
+// distanceMilesSQL computes distanceMiles in SQL between the latColumn and
+// lonColumn and the point in the numbered query arguments
+func distanceMilesSQL(latColumn, lonColumn string, latArg, lonArg int) string {
+	return fmt.Sprintf("2 * %g * asin(sqrt(pow(sin(radians(%s - $%d) / 2), 2) + cos(radians($%d)) * cos(radians(%s)) * pow(sin(radians(%s - $%d) / 2), 2)))",
+		earthRadiusMiles, latColumn, latArg, latArg, latColumn, lonColumn, lonArg)
+}
+
+// BusEstimate is an estimate of whether a school's bus service would cover
+// the family's home
+type BusEstimate struct {
+	Status        string // busLikely, busBorderline, or busUnlikely
+	DistanceMiles float64
+	Grade         string // CCD grade the rule was matched for
+	Rule          BusRule
+	DefaultRule   bool   // No rule was configured, so defaultBusMiles was used
+	Note          string // A caveat, e.g. that charters often don't run buses
+}
+
+// Summary explains the estimate with its rule and source, e.g. "Likely
+// eligible: 2.1 mi straight-line, over the 1.5 mi threshold for grades K-5
+// (CA rule: Ed Code 39800)"
+func (e BusEstimate) Summary() string {
+	comparison := "over"
+	if e.DistanceMiles <= e.Rule.Miles {
+		comparison = "within"
+	}
+	text := fmt.Sprintf("%s: %.1f mi straight-line, %s the %g mi threshold", e.Status, e.DistanceMiles, comparison, e.Rule.Miles)
+	if e.DefaultRule {
+		return text + " (" + e.Rule.Source + ")"
+	}
+	scope := e.Rule.Scope + " rule"
+	if e.Rule.IsDistrict() {
+		scope = "district rule"
+	}
+	if e.Rule.GradeLow != "" {
+		text += " for grades " + e.Rule.Grades()
+	}
+	return text + " (" + scope + ": " + e.Rule.Source + ")"
+}
+
+// StatusClass names the status for styling: "likely", "borderline", or "unlikely"
+func (e BusEstimate) StatusClass() string {
+	switch e.Status {
+	case busLikely:
+		return "likely"
+	case busBorderline:
+		return "borderline"
+	default:
+		return "unlikely"
+	}
+}
+
+// EstimateBus estimates bus eligibility from home to a school for a grade,
+// using the school's lowest grade when grade is empty
+func EstimateBus(school *School, home Home, schoolLat, schoolLon float64, rules []BusRule, grade string) BusEstimate {
+	if grade == "" {
+		grade = school.GradeLow.String
+	}
+	rule, ok := matchBusRule(rules, school, grade)
+	if !ok {
+		rule = BusRule{Miles: defaultBusMiles, Source: defaultBusSource}
+	}
+
+	e := BusEstimate{
+		DistanceMiles: distanceMiles(home.Lat, home.Lon, schoolLat, schoolLon),
+		Grade:         grade,
+		Rule:          rule,
+		DefaultRule:   !ok,
+	}
+	switch {
+	case e.DistanceMiles > rule.Miles:
+		e.Status = busLikely
+	case e.DistanceMiles*routeFactor > rule.Miles:
+		e.Status = busBorderline
+	default:
+		e.Status = busUnlikely
+	}
+	if school.CharterString() == "Yes" {
+		e.Note = "Charter schools often don't run buses, or only within their district; check with the school"
+	}
+	return e
+}
+
+// SchoolBusEstimate estimates bus eligibility for a school from the saved home
+// location and rules, for the first child the school serves or its lowest
+// grade. It returns nil when no home is set or the school has no coordinates.
+func (d *DB) SchoolBusEstimate(school *School, children []Child) (*BusEstimate, error) {
+	home, err := d.Home()
+	if err != nil || home == nil {
+		return nil, err
+	}
+	lat, lon, ok, err := d.SchoolCoordinates(school.NCESSCH)
+	if err != nil || !ok {
+		return nil, err
+	}
+	rules, err := d.BusRules()
+	if err != nil {
+		return nil, err
+	}
+
+	var grade string
+	for _, c := range children {
+		if c.Serves(*school) {
+			grade = c.Grade
+			break
+		}
+	}
+	estimate := EstimateBus(school, *home, lat, lon, rules, grade)
+	return &estimate, nil
+}
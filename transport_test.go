@@ -0,0 +1,146 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseCoordinates(t *testing.T) {
+	if lat, lon, err := parseCoordinates(" 45.52, -122.68 "); err != nil || lat != 45.52 || lon != -122.68 {
+		t.Errorf("parseCoordinates() = %v, %v, %v", lat, lon, err)
+	}
+	for _, bad := range []string{"", "45.52", "north,west", "95,-122", "45,-200"} {
+		if _, _, err := parseCoordinates(bad); err == nil {
+			t.Errorf("parseCoordinates(%q) accepted", bad)
+		}
+	}
+
+	// One degree of latitude is about 69 miles
+	if d := distanceMiles(37, -122, 38, -122); d < 68.5 || d > 69.5 {
+		t.Errorf("distanceMiles() = %.2f, want about 69", d)
+	}
+}
+
+func TestBusEstimate(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	geocodes := "NCESSCH,NAME,CBSA,NMCBSA,LAT,LON\n" +
+		"360000100001,Lincoln Elementary School,41860,\"San Francisco-Oakland-Berkeley, CA\",37.7793,-122.4193\n" +
+		"360000100002,Washington High School,31080,\"Los Angeles-Long Beach-Anaheim, CA\",M,M\n"
+	if err := os.WriteFile(filepath.Join(db.dataDir, "EDGE_GEOCODE_PUBLICSCH_2324.csv"), []byte(geocodes), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SyncGeocodes(db); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, ok, err := db.SchoolCoordinates("360000100002"); err != nil || ok {
+		t.Errorf("SchoolCoordinates() for a school without coordinates = %v, %v", ok, err)
+	}
+
+	for _, bad := range []struct{ scope, grades, source string }{
+		{"California", "", "policy"},
+		{"CA", "5-K", "policy"},
+		{"CA", "", " "},
+	} {
+		if _, err := SaveBusRule(db, bad.scope, bad.grades, 1, bad.source); err == nil {
+			t.Errorf("SaveBusRule(%q, %q, %q) accepted", bad.scope, bad.grades, bad.source)
+		}
+	}
+	if _, err := SaveBusRule(db, "ca", "", 2, "State guidance"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SaveBusRule(db, "0600000", "K-5", 1, "SFUSD transportation policy"); err != nil {
+		t.Fatal(err)
+	}
+
+	school, err := db.GetSchoolByID("360000100001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if estimate, err := db.SchoolBusEstimate(school, nil); err != nil || estimate != nil {
+		t.Fatalf("estimate without a home = %+v, %v", estimate, err)
+	}
+	if _, err := SaveHome(db, "37.80,-122.42", "Home"); err != nil {
+		t.Fatal(err)
+	}
+
+	// About 1.4 miles away: the school's lowest grade, PK, falls outside the
+	// district's K-5 rule, so the state's 2-mile rule applies
+	estimate, err := db.SchoolBusEstimate(school, nil)
+	if err != nil || estimate == nil {
+		t.Fatalf("SchoolBusEstimate() = %+v, %v", estimate, err)
+	}
+	if estimate.Status != busUnlikely || estimate.Rule.Scope != "CA" {
+		t.Errorf("estimate = %s", estimate.Summary())
+	}
+
+	// A third grader gets the district's 1-mile rule
+	estimate, err = db.SchoolBusEstimate(school, []Child{{Name: "Maya", Grade: "03"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "Likely eligible: 1.4 mi straight-line, over the 1 mi threshold for grades K-5 (district rule: SFUSD transportation policy)"
+	if got := estimate.Summary(); got != want {
+		t.Errorf("Summary() = %q, want %q", got, want)
+	}
+
+	// Without rules, the default is used and says so
+	rules, err := db.BusRules()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range rules {
+		if err := db.DeleteBusRule(r.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	estimate, err = db.SchoolBusEstimate(school, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !estimate.DefaultRule || estimate.Status != busBorderline || !strings.Contains(estimate.Summary(), "No rule configured") {
+		t.Errorf("default estimate = %s", estimate.Summary())
+	}
+}
+
+func TestWebBusEstimate(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	router := NewRouter(ServerConfig{DB: db})
+
+	geocodes := "NCESSCH,NAME,CBSA,NMCBSA,LAT,LON\n360000100001,Lincoln Elementary School,41860,San Francisco,37.7793,-122.4193\n"
+	if err := os.WriteFile(filepath.Join(db.dataDir, "EDGE_GEOCODE_PUBLICSCH_2324.csv"), []byte(geocodes), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SyncGeocodes(db); err != nil {
+		t.Fatal(err)
+	}
+
+	post := func(home string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/schools/360000100001/bus", strings.NewReader(url.Values{"home": {home}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+	if rec := post("somewhere"); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid home status = %d", rec.Code)
+	}
+	rec := post("37.80,-122.42")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "Estimate: Borderline") {
+		t.Errorf("status = %d, body %s", rec.Code, rec.Body.String())
+	}
+
+	req := httptest.NewRequest("GET", "/compare?ids=360000100001,360000100002", nil)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if body := rec.Body.String(); !strings.Contains(body, "Bus Service (estimate)") || !strings.Contains(body, "No rule configured") {
+		t.Error("compare page is missing the bus estimate")
+	}
+}
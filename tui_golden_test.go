@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/exp/golden"
+	"github.com/charmbracelet/x/exp/teatest"
+	"github.com/muesli/termenv"
+)
+
+// Golden TUI tests run the app on the test schools at several terminal widths
+// and compare each final screen with testdata/<test>/<width>x<height>.golden. After an
+// intended layout change, review the diff and rewrite the files with
+// go test -run Golden -update
+
+// goldenWidths are the terminal widths each screen is checked at
+var goldenWidths = []int{60, 80, 120}
+
+// goldenClock is the fixed time golden screens are rendered at
+var goldenClock = time.Date(2024, time.September, 3, 9, 0, 0, 0, time.UTC)
+
+// goldenWait is how long a golden test waits for a screen
+const goldenWait = 10 * time.Second
+
+// runGolden runs test in a subtest for each golden width
+func runGolden(t *testing.T, height int, test func(t *testing.T, width, height int)) {
+	for _, width := range goldenWidths {
+		t.Run(fmt.Sprintf("%dx%d", width, height), func(t *testing.T) {
+			test(t, width, height)
+		})
+	}
+}
+
+// newGoldenModel starts the TUI at a terminal size, rendering without color
+// so screens compare as plain text
+func newGoldenModel(t *testing.T, m model, width, height int) *teatest.TestModel {
+	t.Helper()
+	profile := lipgloss.ColorProfile()
+	lipgloss.SetColorProfile(termenv.Ascii)
+	t.Cleanup(func() { lipgloss.SetColorProfile(profile) })
+
+	return teatest.NewTestModel(t, m, teatest.WithInitialTermSize(width, height))
+}
+
+// waitForScreen waits until the TUI has drawn text
+func waitForScreen(t *testing.T, tm *teatest.TestModel, text string) {
+	t.Helper()
+	teatest.WaitFor(t, tm.Output(), func(out []byte) bool {
+		return bytes.Contains(out, []byte(text))
+	}, teatest.WithDuration(goldenWait))
+}
+
+// requireGoldenScreen quits the TUI and compares its final screen with the
+// golden file
+func requireGoldenScreen(t *testing.T, tm *teatest.TestModel) {
+	t.Helper()
+	if err := tm.Quit(); err != nil {
+		t.Fatal(err)
+	}
+	final := tm.FinalModel(t, teatest.WithFinalTimeout(goldenWait))
+	golden.RequireEqual(t, []byte(final.View()))
+}
+
+func TestSearchViewGolden(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	runGolden(t, 24, func(t *testing.T, width, height int) {
+		tm := newGoldenModel(t, initialModel(db, nil, nil, ""), width, height)
+		tm.Type("School")
+		tm.Send(tea.KeyMsg{Type: tea.KeyEnter})
+		waitForScreen(t, tm, "5 items")
+		requireGoldenScreen(t, tm)
+	})
+}
+
+func TestDetailViewNAEPGolden(t *testing.T) {
+	t.Setenv("NAEP_AUTO_FETCH", "1")
+
+	// Tall enough to show the whole page, down to the NAEP results
+	runGolden(t, 200, func(t *testing.T, width, height int) {
+		db, cleanup := SetupTestDB(t)
+		defer cleanup()
+		naep := NewNAEPClient(db,
+			WithNAEPHTTPClient(&http.Client{Transport: testFixtures(t, "naep.json", naepFixtureKey)}),
+			WithNAEPClock(func() time.Time { return goldenClock }),
+		)
+
+		tm := newGoldenModel(t, initialModel(db, nil, naep, ""), width, height)
+		tm.Type("Lincoln")
+		tm.Send(tea.KeyMsg{Type: tea.KeyEnter})
+		waitForScreen(t, tm, "1 item")
+		tm.Send(tea.KeyMsg{Type: tea.KeyTab})
+		tm.Send(tea.KeyMsg{Type: tea.KeyEnter})
+		waitForScreen(t, tm, "National Comparison")
+		requireGoldenScreen(t, tm)
+	})
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"strings"
 	"testing"
 
@@ -444,6 +446,98 @@ func TestStateFilterCycling(t *testing.T) {
 	}
 }
 
+// TestSavedSearchFlow tests saving the current search and re-running it from the saved searches view
+func TestSavedSearchFlow(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	m := initialModel(db, nil, nil, "")
+	m.stateFilter = "CA"
+	m.filters = SearchFilters{Charter: "No", MaxRatio: 20}
+
+	// Ctrl+B opens the name prompt, Enter saves
+	newModel, _ := m.handleSearchViewKeys(tea.KeyMsg{Type: tea.KeyCtrlB})
+	m = newModel.(model)
+	if m.currentView != saveSearchPromptView {
+		t.Fatalf("Expected saveSearchPromptView, got %v", m.currentView)
+	}
+	m.searchNameInput.SetValue("CA public")
+	newModel, cmd := m.handleSaveSearchPromptKeys(tea.KeyMsg{Type: tea.KeyEnter})
+	m = newModel.(model)
+	newModel, _ = m.Update(cmd())
+	m = newModel.(model)
+	if m.err != nil || m.currentView != searchView {
+		t.Fatalf("Expected search view after saving, got view %v, err %v", m.currentView, m.err)
+	}
+
+	// Clear everything, then re-run from the saved searches view
+	m.stateFilter = ""
+	m.filters = SearchFilters{}
+	newModel, cmd = m.handleSearchViewKeys(tea.KeyMsg{Type: tea.KeyCtrlO})
+	m = newModel.(model)
+	newModel, _ = m.Update(cmd())
+	m = newModel.(model)
+	if m.currentView != savedSearchesView || len(m.savedSearches) != 1 {
+		t.Fatalf("Expected 1 saved search in savedSearchesView, got %d in view %v", len(m.savedSearches), m.currentView)
+	}
+	if !strings.Contains(m.View(), "CA public") {
+		t.Error("Expected saved search name in view")
+	}
+
+	newModel, cmd = m.handleSavedSearchesKeys(tea.KeyMsg{Type: tea.KeyEnter})
+	m = newModel.(model)
+	if m.stateFilter != "CA" || m.filters.Charter != "No" || m.filters.MaxRatio != 20 {
+		t.Errorf("Expected saved filters restored, got state %q filters %+v", m.stateFilter, m.filters)
+	}
+	newModel, _ = m.Update(cmd())
+	m = newModel.(model)
+	if len(m.schools) != 2 {
+		t.Errorf("Expected 2 CA schools, got %d", len(m.schools))
+	}
+}
+
+// TestSearchDrillDown tests searching within results with Ctrl+R and stepping back out with Ctrl+P
+func TestSearchDrillDown(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	m := initialModel(db, nil, nil, "")
+	m.stateFilter = "CA"
+	newModel, _ := m.Update(searchSchools(db, m.scope, m.searchFilters())())
+	m = newModel.(model)
+	if len(m.schools) != 2 {
+		t.Fatalf("Expected 2 CA schools, got %d", len(m.schools))
+	}
+
+	// Ctrl+R searches within the CA results; "Lincoln" narrows them further
+	newModel, _ = m.handleSearchViewKeys(tea.KeyMsg{Type: tea.KeyCtrlR})
+	m = newModel.(model)
+	if len(m.scope.Within) != 1 || m.stateFilter != "" {
+		t.Fatalf("Expected one earlier search and a cleared state filter, got scope %+v state %q", m.scope, m.stateFilter)
+	}
+	m.searchInput.SetValue("Lincoln")
+	newModel, _ = m.Update(searchSchools(db, m.scope, m.searchFilters())())
+	m = newModel.(model)
+	if len(m.schools) != 1 || m.schools[0].State != "CA" {
+		t.Fatalf("Expected the CA Lincoln school, got %+v", m.schools)
+	}
+	if !strings.Contains(m.View(), `Within: All schools › CA › "Lincoln"`) {
+		t.Error("Expected the refinement chain in the view")
+	}
+
+	// Ctrl+P steps back out to the CA search
+	newModel, cmd := m.handleSearchViewKeys(tea.KeyMsg{Type: tea.KeyCtrlP})
+	m = newModel.(model)
+	if !m.scope.IsZero() || m.stateFilter != "CA" {
+		t.Fatalf("Expected the CA search restored, got scope %+v state %q", m.scope, m.stateFilter)
+	}
+	newModel, _ = m.Update(cmd())
+	m = newModel.(model)
+	if len(m.schools) != 2 {
+		t.Errorf("Expected 2 CA schools after stepping back, got %d", len(m.schools))
+	}
+}
+
 // TestSchoolItemInterface tests schoolItem list.Item interface
 func TestSchoolItemInterface(t *testing.T) {
 	school := School{
@@ -480,3 +574,124 @@ func TestSchoolItemInterface(t *testing.T) {
 		t.Error("Expected filter value to contain school name")
 	}
 }
+
+// TestDetailViewCancelsNAEPFetch tests that leaving the detail view cancels the NAEP fetch
+func TestDetailViewCancelsNAEPFetch(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	m := initialModel(db, nil, nil, "")
+	m.currentView = detailView
+	m.selectedItem = MockSchool("123456", "Test School", "Test District", "CA", "PK", "05")
+	m.loadingNAEP = true
+	ctx, cancel := context.WithCancel(context.Background())
+	m.naepCancel = cancel
+
+	newModel, _ := m.handleDetailViewKeys(tea.KeyMsg{Type: tea.KeyEsc})
+	m = newModel.(model)
+
+	if ctx.Err() == nil {
+		t.Error("Expected the NAEP fetch to be canceled")
+	}
+	if m.loadingNAEP || m.naepCancel != nil {
+		t.Error("Expected NAEP loading state to be cleared")
+	}
+}
+
+// TestStaleNAEPMessageIgnored tests that results for another school or a canceled fetch are dropped
+func TestStaleNAEPMessageIgnored(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	tests := []struct {
+		name string
+		msg  naepDataMsg
+	}{
+		{"other school", naepDataMsg{ncessch: "999999", data: &NAEPData{}}},
+		{"canceled fetch", naepDataMsg{ncessch: "123456", err: fmt.Errorf("failed to fetch state scores: %w", context.Canceled)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := initialModel(db, nil, nil, "")
+			m.currentView = detailView
+			m.selectedItem = MockSchool("123456", "Test School", "Test District", "CA", "PK", "05")
+			m.loadingNAEP = true
+
+			newModel, _ := m.Update(tt.msg)
+			m = newModel.(model)
+
+			if m.naepData != nil || m.err != nil {
+				t.Errorf("Expected message to be dropped, got data %v, err %v", m.naepData, m.err)
+			}
+			if !m.loadingNAEP {
+				t.Error("Expected the current fetch to still be loading")
+			}
+		})
+	}
+}
+
+// TestResizeRerendersOnceSettled tests that details are re-rendered for the last size once resizing stops
+func TestResizeRerendersOnceSettled(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	m := initialModel(db, nil, nil, "")
+	newModel, _ := m.Update(tea.WindowSizeMsg{Width: 120, Height: 30})
+	m = newModel.(model)
+	m.currentView = detailView
+	m.selectedItem = MockSchool("123456", "Test School", "Test District", "CA", "PK", "05")
+	m.parentSummary = &ParentSummary{Summary: strings.Repeat("Families describe a welcoming school with strong reading support. ", 40)}
+	m.updateDetailViewport()
+	wide := m.detailContent
+
+	// Scroll halfway, then drag the terminal narrower in two steps
+	m.viewport.SetYOffset((m.viewport.TotalLineCount() - m.viewport.Height) / 2)
+	var cmds []tea.Cmd
+	for _, width := range []int{100, 70} {
+		var cmd tea.Cmd
+		newModel, cmd = m.Update(tea.WindowSizeMsg{Width: width, Height: 30})
+		m = newModel.(model)
+		cmds = append(cmds, cmd)
+	}
+	if m.detailContent != wide {
+		t.Fatal("Expected details not to be re-rendered while resizing")
+	}
+
+	for i, cmd := range cmds {
+		newModel, _ = m.Update(cmd())
+		m = newModel.(model)
+		if i == 0 && m.detailContent != wide {
+			t.Error("Expected a superseded resize not to re-render")
+		}
+	}
+	if m.detailContent == wide {
+		t.Fatal("Expected details to be re-rendered for the settled width")
+	}
+	if percent := m.viewport.ScrollPercent(); percent < 0.4 || percent > 0.6 {
+		t.Errorf("Expected the scroll position to be kept near 50%%, got %.0f%%", percent*100)
+	}
+}
+
+// TestRenderMarkdownCache tests that markdown is rendered once per width
+func TestRenderMarkdownCache(t *testing.T) {
+	content := "# Cache test\n\nRendered once per width."
+	hits, _ := markdownRenders.Stats()
+
+	first, err := renderMarkdown(content, 80)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _ := renderMarkdown(content, 80)
+	if second != first {
+		t.Error("Expected the cached render to match")
+	}
+	if now, _ := markdownRenders.Stats(); now != hits+1 {
+		t.Errorf("Expected one cache hit, got %d", now-hits)
+	}
+
+	renderMarkdown(content, 120)
+	if now, _ := markdownRenders.Stats(); now != hits+1 {
+		t.Error("Expected another width to render again")
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Limits on data imports. Uploads are saved to user_data/quarantine, checked,
+// and only then moved into user_data and loaded.
+const (
+	maxImportSize        = 100 * 1024 * 1024 // 100MB
+	defaultImportMaxRows = 1_000_000         // Overridable with IMPORT_MAX_ROWS
+	uploadSniffLen       = 512               // Bytes read to check a file's contents match its type
+	maxUploadNameLen     = 100
+)
+
+// importTableName is what an imported table may be called, as the import form
+// says: a lowercase letter, then lowercase letters, digits, and underscores
+var importTableName = regexp.MustCompile(`^[a-z][a-z0-9_]{0,62}$`)
+
+// importMaxRowsFromEnv reads the most rows an import may have from IMPORT_MAX_ROWS
+func importMaxRowsFromEnv() int64 {
+	raw := strings.TrimSpace(os.Getenv("IMPORT_MAX_ROWS"))
+	if raw == "" {
+		return defaultImportMaxRows
+	}
+	n, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || n <= 0 {
+		if logger != nil {
+			logger.Warn("Ignoring invalid import row limit", "variable", "IMPORT_MAX_ROWS", "value", raw)
+		}
+		return defaultImportMaxRows
+	}
+	return n
+}
+
+// sanitizeUploadName reduces a client-supplied filename to something safe to
+// show and log: the base name without directories or control characters,
+// shortened to maxUploadNameLen characters
+func sanitizeUploadName(name string) string {
+	name = strings.ReplaceAll(name, `\`, "/")
+	name = name[strings.LastIndex(name, "/")+1:]
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == unicode.ReplacementChar {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(strings.TrimLeft(name, "."))
+	if runes := []rune(name); len(runes) > maxUploadNameLen {
+		name = string(runes[:maxUploadNameLen])
+	}
+	if name == "" {
+		return "upload"
+	}
+	return name
+}
+
+// sniffUpload checks that the start of an upload matches its extension: an
+// .xlsx file must be a ZIP archive and a .csv file plain text
+func sniffUpload(head []byte, ext string) error {
+	if len(head) == 0 {
+		return fmt.Errorf("the file is empty")
+	}
+	contentType := http.DetectContentType(head)
+	switch ext {
+	case ".xlsx":
+		if !bytes.HasPrefix(head, []byte("PK\x03\x04")) {
+			return fmt.Errorf("the file isn't an Excel workbook (detected %s)", contentType)
+		}
+	case ".csv":
+		if !strings.HasPrefix(contentType, "text/plain") {
+			return fmt.Errorf("the file isn't plain-text CSV (detected %s)", contentType)
+		}
+	default:
+		return fmt.Errorf("unsupported file type %q", ext)
+	}
+	return nil
+}
+
+// quarantineUpload saves an upload under a random name in dataDir's
+// user_data/quarantine directory and returns its path
+func quarantineUpload(dataDir string, src io.Reader, ext string) (string, error) {
+	dir := filepath.Join(dataDir, "user_data", "quarantine")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create quarantine directory: %w", err)
+	}
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to name upload: %w", err)
+	}
+	path := filepath.Join(dir, hex.EncodeToString(b)+ext)
+
+	out, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return "", fmt.Errorf("failed to create file: %w", err)
+	}
+	if _, err := io.Copy(out, src); err != nil {
+		out.Close()
+		os.Remove(path)
+		return "", fmt.Errorf("failed to save file: %w", err)
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(path)
+		return "", fmt.Errorf("failed to save file: %w", err)
+	}
+	return path, nil
+}
+
+// promoteUpload moves a checked upload out of quarantine to user_data/<name><ext>.
+// It refuses destinations that would land outside user_data.
+func promoteUpload(dataDir, quarantined, name, ext string) (string, error) {
+	userDataDir := filepath.Join(dataDir, "user_data")
+	path := filepath.Join(userDataDir, name+ext)
+	if !insideDir(userDataDir, path) {
+		return "", fmt.Errorf("refusing to save %q outside %s", name+ext, userDataDir)
+	}
+	if err := os.Rename(quarantined, path); err != nil {
+		return "", fmt.Errorf("failed to save file: %w", err)
+	}
+	return path, nil
+}
+
+// insideDir reports whether path is within dir, after cleaning both
+func insideDir(dir, path string) bool {
+	rel, err := filepath.Rel(filepath.Clean(dir), filepath.Clean(path))
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
+
+// quoteLiteral quotes a string for SQL
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSanitizeUploadName(t *testing.T) {
+	tests := map[string]string{
+		"scores.csv":                 "scores.csv",
+		"../../etc/passwd.csv":       "passwd.csv",
+		`C:\Users\me\Desktop\q1.csv`: "q1.csv",
+		"bad\x00name\n.csv":          "badname.csv",
+		"..":                         "upload",
+		".hidden.csv":                "hidden.csv",
+	}
+	for in, want := range tests {
+		if got := sanitizeUploadName(in); got != want {
+			t.Errorf("sanitizeUploadName(%q) = %q, want %q", in, got, want)
+		}
+	}
+	if got := sanitizeUploadName(strings.Repeat("a", 300) + ".csv"); len(got) != maxUploadNameLen {
+		t.Errorf("long name kept %d characters", len(got))
+	}
+}
+
+func TestSniffUpload(t *testing.T) {
+	if err := sniffUpload([]byte("name,score\nAda,90\n"), ".csv"); err != nil {
+		t.Errorf("CSV rejected: %v", err)
+	}
+	if err := sniffUpload([]byte("PK\x03\x04\x14\x00\x06\x00"), ".xlsx"); err != nil {
+		t.Errorf("workbook rejected: %v", err)
+	}
+	for _, tt := range []struct {
+		head string
+		ext  string
+	}{
+		{"\x7fELF\x02\x01\x01\x00\x00\x00", ".csv"},
+		{"<html><script>alert(1)</script>", ".csv"},
+		{"PK\x03\x04\x14\x00", ".csv"},
+		{"name,score\n", ".xlsx"},
+		{"", ".csv"},
+	} {
+		if err := sniffUpload([]byte(tt.head), tt.ext); err == nil {
+			t.Errorf("sniffUpload(%q, %s) accepted", tt.head, tt.ext)
+		}
+	}
+}
+
+func TestInsideDir(t *testing.T) {
+	dir := filepath.Join("data", "user_data")
+	if !insideDir(dir, filepath.Join(dir, "scores.csv")) {
+		t.Error("file in the directory rejected")
+	}
+	for _, path := range []string{dir, filepath.Join(dir, "..", "data.duckdb"), filepath.Join("elsewhere", "scores.csv")} {
+		if insideDir(dir, path) {
+			t.Errorf("insideDir accepted %s", path)
+		}
+	}
+}
+
+func TestImportUpload(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	router := NewRouter(ServerConfig{DB: db})
+
+	// The import page issues the CSRF token
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/import", nil))
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != csrfCookie || !cookies[0].HttpOnly || !strings.Contains(rec.Body.String(), cookies[0].Value) {
+		t.Fatalf("import page cookies = %v", cookies)
+	}
+	token := cookies[0].Value
+
+	upload := func(table, filename, content, csrf string) string {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		_ = mw.WriteField("table_name", table)
+		_ = mw.WriteField("description", "Test scores")
+		fw, _ := mw.CreateFormFile("csv_file", filename)
+		_, _ = fw.Write([]byte(content))
+		_ = mw.Close()
+
+		req := httptest.NewRequest("POST", "/import/upload", &body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		req.Header.Set("HX-Request", "true")
+		req.AddCookie(&http.Cookie{Name: csrfCookie, Value: token})
+		if csrf != "" {
+			req.Header.Set(csrfHeader, csrf)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Body.String()
+	}
+	const scores = "name,score\nAda,90\nGrace,95\nKatherine,88\n"
+
+	if body := upload("scores", "scores.csv", scores, ""); !strings.Contains(body, "came from another site") {
+		t.Errorf("upload without a CSRF token wasn't rejected: %s", body)
+	}
+	if body := upload("scores; DROP TABLE directory", "scores.csv", scores, token); !strings.Contains(body, "Table name must start") {
+		t.Errorf("invalid table name wasn't rejected: %s", body)
+	}
+	if body := upload("directory", "scores.csv", scores, token); !strings.Contains(body, "already exists") {
+		t.Errorf("existing table name wasn't rejected: %s", body)
+	}
+	if body := upload("scores", "scores.csv", "\x7fELF\x02\x01\x01\x00\x00\x00\x00", token); !strings.Contains(body, "isn&#39;t plain-text CSV") {
+		t.Errorf("binary file wasn't rejected: %s", body)
+	}
+	t.Setenv("IMPORT_MAX_ROWS", "2")
+	if body := upload("scores", "scores.csv", scores, token); !strings.Contains(body, "limited to 2") {
+		t.Errorf("row limit wasn't enforced: %s", body)
+	}
+	t.Setenv("IMPORT_MAX_ROWS", "")
+
+	body := upload("scores", "../../scores.csv", scores, token)
+	if !strings.Contains(body, "user_data/scores.csv") {
+		t.Errorf("import failed: %s", body)
+	}
+	rows, err := db.ExecuteQuery("SELECT count(*) AS count FROM scores")
+	if err != nil || rows[0]["count"] != int64(3) {
+		t.Errorf("imported rows = %v, %v", rows, err)
+	}
+
+	// Nothing is left in quarantine, and rejected files weren't kept
+	quarantine, _ := os.ReadDir(filepath.Join(db.dataDir, "user_data", "quarantine"))
+	saved, _ := filepath.Glob(filepath.Join(db.dataDir, "user_data", "*.csv"))
+	if len(quarantine) != 0 || len(saved) != 1 {
+		t.Errorf("quarantine has %d files and user_data %v", len(quarantine), saved)
+	}
+}
@@ -1,16 +1,24 @@
 package main
 
 import (
+	"bytes"
+	"cmp"
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
+	"io"
 	"log"
+	"mime"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -30,7 +38,35 @@ type WebHandler struct {
 	DB         *DB
 	AIScraper  *AIScraperService
 	NAEPClient *NAEPClient
-	templates  *template.Template
+	templates  *templateSet
+	dev        bool // Development mode: reload templates, skip caches, show error details
+
+	// In-memory caches of NAEP views and rendered NAEP fragments, keyed by NCESSCH and
+	// dropped whenever the school's cached NAEP data changes
+	naepViews     *lruCache[*NAEPDataView]
+	naepFragments *lruCache[[]byte]
+
+	// Multi-user deployments take corrections as suggestions for admins to review
+	suggestions bool
+	notifier    *suggestionNotifier
+
+	// Queues liveness checks of websites shown on detail pages; nil when not running
+	websiteChecker *websiteChecker
+
+	// Whether follow-up reminders go out for outreach awaiting a reply
+	outreachNudging bool
+
+	// Turns addresses into coordinates for the feeder pipeline page
+	geocoder *Geocoder
+
+	// SQL behind recent data explorer answers, keyed by export ID for CSV downloads
+	agentExports *lruCache[agentExport]
+
+	// Who may do what: the admin password and web server accounts
+	access *access
+
+	// Demo mode suggests the Data Explorer questions with recorded answers
+	demo bool
 }
 
 // markdownToHTML converts markdown text to HTML
@@ -76,34 +112,171 @@ type NAEPDataView struct {
 	Grade4Scores   []NAEPScoreView
 	Grade8Scores   []NAEPScoreView
 	NationalByKey  map[string]*NAEPScoreView // key: "subject-grade"
+	Stale          bool                      // Served from an expired cache entry
+	Refreshing     bool                      // A background refresh is running
+	CacheTTLDays   int
 }
 
 // NewWebHandler creates a new WebHandler with parsed templates
 func NewWebHandler(db *DB, aiScraper *AIScraperService, naepClient *NAEPClient) *WebHandler {
-	tmpl := template.Must(template.ParseGlob("templates/*.html"))
-	template.Must(tmpl.ParseGlob("templates/partials/*.html"))
-	return &WebHandler{
-		DB:         db,
-		AIScraper:  aiScraper,
-		NAEPClient: naepClient,
-		templates:  tmpl,
+	hotSize, hotTTL := hotCacheSizeFromEnv(), cacheTTLFromEnv("HOT_CACHE_TTL", defaultHotCacheTTL)
+	h := &WebHandler{
+		DB:            db,
+		AIScraper:     aiScraper,
+		NAEPClient:    naepClient,
+		templates:     newTemplateSet(templatePatterns, false),
+		naepViews:     newLRUCache[*NAEPDataView](hotSize, hotTTL),
+		naepFragments: newLRUCache[[]byte](hotSize, hotTTL),
+		agentExports:  newLRUCache[agentExport](maxAgentExports, agentExportTTL),
+	}
+	if db != nil {
+		db.OnCacheInvalidated(func(ncessch string) {
+			h.naepViews.Delete(ncessch)
+			h.naepFragments.Delete(ncessch)
+		})
+	}
+	return h
+}
+
+// enableDevMode reloads templates when they change, turns off the in-memory NAEP
+// caches so template and data changes show up right away, and shows error details
+// in responses
+func (h *WebHandler) enableDevMode() {
+	h.dev = true
+	h.templates = newTemplateSet(templatePatterns, true)
+	h.naepViews = nil
+	h.naepFragments = nil
+}
+
+// enableSuggestions replaces direct corrections on the detail page with
+// suggestions queued for admin review, sending notifications through notifier
+func (h *WebHandler) enableSuggestions(notifier *suggestionNotifier) {
+	h.suggestions = true
+	h.notifier = notifier
+}
+
+// templateError logs a failed render and responds with a 500, including the error
+// in dev mode
+func (h *WebHandler) templateError(w http.ResponseWriter, err error) {
+	log.Printf("Template error: %v", err)
+	if h.dev {
+		http.Error(w, fmt.Sprintf("Template error: %v", err), http.StatusInternalServerError)
+		return
 	}
+	http.Error(w, "Internal server error", http.StatusInternalServerError)
 }
 
-// SearchPage renders the main search page
+// SearchPage renders the main search page. Filters in the URL (as written by
+// SearchFilters.Values, e.g. from a saved search) are pre-filled and run on load.
 func (h *WebHandler) SearchPage(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+	if values.Get("query") == "" && values.Get("q") != "" {
+		values.Set("query", values.Get("q"))
+	}
+	// Invalid filters are dropped by the form and reported when the search runs
+	filters, _ := SearchFiltersFromValues(values)
+	scope, _ := SearchScopeFromValues(values)
+
+	// With child profiles, new searches are limited to schools serving at least
+	// one child and offering the programs their needs call for; links that carry
+	// their own filters or drill into earlier results are left as they are
+	autoRun := !filters.IsZero() || !scope.IsZero()
+	children := loadChildren(h.DB)
+	childFilter := childGradeFilter(children)
+	if !autoRun {
+		filters.ChildGrades = childFilter
+		filters.Programs = strings.Join(programNeeds(children), ",")
+	}
+
+	// Suggest the pathways schools publish for the CTE filter
+	pathways, err := h.DB.CTEPathways()
+	if err != nil {
+		log.Printf("Warning: failed to list CTE pathways: %v", err)
+	}
+	// ...and the sports schools field for the sports filter
+	sports, err := h.DB.SportNames()
+	if err != nil {
+		log.Printf("Warning: failed to list sports: %v", err)
+	}
+	// ...and the languages schools teach for the language filter
+	languages, err := h.DB.LanguageNames()
+	if err != nil {
+		log.Printf("Warning: failed to list languages: %v", err)
+	}
+
+	// Landing visitors get the school of the day to explore
+	var pick *SchoolPick
+	if !autoRun {
+		if pick, err = h.DB.RandomSchool(RandomSchoolFilter{}, schoolOfTheDaySeed(time.Now())); err != nil && !errors.Is(err, ErrNoMatchingSchools) {
+			log.Printf("Warning: failed to pick the school of the day: %v", err)
+		}
+	}
+
 	data := map[string]interface{}{
-		"Title": "School Finder",
-		"Query": r.URL.Query().Get("q"),
-		"State": r.URL.Query().Get("state"),
+		"Title":       "School Finder",
+		"Query":       filters.QueryText(),
+		"State":       filters.State,
+		"Filters":     filters,
+		"ScopeValues": scope.Values(),
+		"AutoRun":     autoRun,
+		"Grades":      searchGradeOptions,
+		"Children":    childrenSummary(children),
+		"ChildFilter": childFilter,
+		"Programs":    programOptions,
+		"CTEPathways": pathways,
+		"Sports":      sports,
+		"Languages":   languages,
+		"Pick":        schoolPickView{Pick: pick, Title: "School of the day", Levels: schoolLevels},
 	}
 
 	if err := h.templates.ExecuteTemplate(w, "search.html", data); err != nil {
-		log.Printf("Template error: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		h.templateError(w, err)
 	}
 }
 
+// schoolPickView is the data for the random school widget
+type schoolPickView struct {
+	Pick   *SchoolPick // Nil when no school matched
+	Title  string
+	State  string
+	Level  string
+	Levels []string
+}
+
+// RandomSchool renders a random school matching the state and level in the
+// query, for the "Another school" button of the school of the day
+func (h *WebHandler) RandomSchool(w http.ResponseWriter, r *http.Request) {
+	filter, err := normalizeRandomSchoolFilter(RandomSchoolFilter{State: r.URL.Query().Get("state"), Level: r.URL.Query().Get("level")})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	view := schoolPickView{Title: "Random school", State: filter.State, Level: filter.Level, Levels: schoolLevels}
+	view.Pick, err = h.DB.RandomSchool(filter, "")
+	if err != nil && !errors.Is(err, ErrNoMatchingSchools) {
+		log.Printf("Random school error: %v", err)
+		h.renderUserError(w, r, err, "Picking a random school failed")
+		return
+	}
+
+	if err := h.templates.ExecuteTemplate(w, "school_pick.html", view); err != nil {
+		h.templateError(w, err)
+	}
+}
+
+// programOptions are the program flags offered by the program needs filter
+var programOptions = func() []ProgramFlag {
+	options := make([]ProgramFlag, len(programFlags))
+	for i, flag := range programFlags {
+		options[i] = ProgramFlag{Flag: flag}
+	}
+	return options
+}()
+
+// searchGradeOptions are the grade codes offered by the grade range filter
+var searchGradeOptions = []string{"PK", "KG", "01", "02", "03", "04", "05", "06", "07", "08", "09", "10", "11", "12"}
+
 // SearchResults handles search requests and returns results partial
 func (h *WebHandler) SearchResults(w http.ResponseWriter, r *http.Request) {
 	if err := r.ParseForm(); err != nil {
@@ -111,36 +284,172 @@ func (h *WebHandler) SearchResults(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	query := r.FormValue("query")
-	state := r.FormValue("state")
+	filters, err := SearchFiltersFromValues(r.Form)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// A drill-down searches only within a district or earlier results
+	scope, err := SearchScopeFromValues(r.Form)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Apply field syntax up front so districts use the remaining text and the
+	// save form keeps the parsed filters
+	var syntaxError string
+	if expanded, err := filters.ExpandQuery(); err != nil {
+		syntaxError = err.Error()
+	} else {
+		filters = expanded
+	}
+
+	// Distances are measured from the saved home or a geocoded address; when
+	// neither can be found the search runs without the distance
+	var nearError string
+	if resolved, err := filters.ResolveNear(r.Context(), h.DB, h.geocoder); err != nil {
+		log.Printf("Warning: failed to find %q: %v", filters.Near, err)
+		switch {
+		case errors.Is(err, ErrNoLocation):
+			nearError = "Set a home location from a school's bus estimate, or enter an address, to search by distance."
+		case errors.Is(err, ErrAddressNotFound):
+			nearError = "The Census geocoder couldn't find that address. Try including the city and state, or enter latitude,longitude."
+		default:
+			nearError = "The address couldn't be looked up right now. Try again, or enter latitude,longitude."
+		}
+		filters.WithinMiles = 0
+	} else {
+		filters = resolved
+	}
 
-	schools, err := h.DB.SearchSchools(query, state, maxResults)
+	h.DB.RecordUsage(usageSearch)
+	schools, err := h.DB.SearchSchoolsWithin(scope, filters, maxResults)
 	if err != nil {
 		log.Printf("Search error: %v", err)
 		http.Error(w, "Search failed", http.StatusInternalServerError)
 		return
 	}
 
+	// Districts whose names match are listed separately from schools, unless
+	// searching within results
+	var districts []District
+	if filters.Query != "" && scope.IsZero() {
+		districts, err = h.DB.SearchDistricts(filters.Query, filters.State, maxDistrictResults)
+		if err != nil {
+			log.Printf("Warning: district search failed: %v", err)
+		}
+	}
+
+	// Badge schools with active NAEP decline alerts
+	alerted, err := h.DB.AlertedSchoolIDs()
+	if err != nil {
+		log.Printf("Warning: failed to load NAEP alerts: %v", err)
+	}
+
+	// Badge schools that opened, were renamed, or changed ID in the latest school year
+	yearChanges, err := h.DB.SchoolYearChanges()
+	if err != nil {
+		log.Printf("Warning: failed to load school year changes: %v", err)
+	}
+
+	// Mark schools whose CCD records the data-quality pass flagged
+	quality, err := h.DB.SchoolQualityFlags(schoolIDs(schools))
+	if err != nil {
+		log.Printf("Warning: failed to load quality flags: %v", err)
+	}
+
+	// Pre-K and Head Start sites outside public schools are listed under Early Childhood
+	var preK []PreKProgram
+	if filters.Sector == sectorEarlyChildhood && scope.IsZero() {
+		preK, err = h.DB.SearchPreKPrograms(filters, maxResults)
+		if err != nil {
+			log.Printf("Warning: pre-K program search failed: %v", err)
+		}
+	}
+
+	// Chart every matching school, not just the ones listed, leaving out
+	// flagged enrollment and ratios unless the user asks for them
+	stats, err := h.DB.SearchResultStatsWithin(scope, filters, r.Form.Get("include_flagged") != "")
+	if err != nil {
+		log.Printf("Warning: failed to summarize search results: %v", err)
+	}
+
+	// Note which of the user's imported datasets matched each school
+	importedMatches, err := h.DB.ImportedMatches(filters.Query, schoolIDs(schools))
+	if err != nil {
+		log.Printf("Warning: failed to match imported datasets: %v", err)
+	}
+
+	// Flag schools that serve the children, and rank those serving more of
+	// them first when searching for the children
+	childFits := ChildFits(schools, loadChildren(h.DB))
+	if filters.ChildGrades != "" {
+		RankForChildren(schools, childFits)
+	}
+
 	data := map[string]interface{}{
-		"Schools": schools,
-		"Query":   query,
-		"State":   state,
-		"Count":   len(schools),
+		"Schools":      schools,
+		"Districts":    districts,
+		"PreK":         preK,
+		"Query":        filters.Query,
+		"State":        filters.State,
+		"Filters":      filters,
+		"SyntaxError":  syntaxError,
+		"NearError":    nearError,
+		"Count":        len(schools),
+		"Alerted":      alerted,
+		"YearChanges":  yearChanges,
+		"QualityFlags": quality,
+		"ChildFits":    childFits,
+		"Stats":        stats,
+		"Role":         requestRole(r),
+		"Scoped":       !scope.IsZero(),
+		"Breadcrumbs":  h.searchBreadcrumbs(scope, filters),
+		"RefineURL":    scope.Refine(filters).URL(SearchFilters{}),
+
+		"ImportedMatches": importedMatches,
 	}
 
 	if err := h.templates.ExecuteTemplate(w, "results.html", data); err != nil {
-		log.Printf("Template error: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		h.templateError(w, err)
 	}
 }
 
-// SchoolDetail renders the school detail page
-func (h *WebHandler) SchoolDetail(w http.ResponseWriter, r *http.Request) {
+// searchBreadcrumbs lists a drill-down's steps, naming its district, or
+// none for a search of every school
+func (h *WebHandler) searchBreadcrumbs(scope SearchScope, filters SearchFilters) []SearchCrumb {
+	if scope.IsZero() {
+		return nil
+	}
+	var districtName string
+	if scope.DistrictID != "" {
+		if district, err := h.DB.GetDistrictByID(scope.DistrictID); err != nil {
+			log.Printf("Warning: failed to load district %s: %v", scope.DistrictID, err)
+		} else {
+			districtName = district.Name
+		}
+	}
+	return scope.Breadcrumbs(districtName, filters)
+}
+
+// DistrictPage renders a district overview with its schools
+func (h *WebHandler) DistrictPage(w http.ResponseWriter, r *http.Request) {
+	h.renderDistrict(w, r, "district.html")
+}
+
+// DistrictSchools returns the school cards for a district, for expanding a district search result
+func (h *WebHandler) DistrictSchools(w http.ResponseWriter, r *http.Request) {
+	h.renderDistrict(w, r, "school_cards.html")
+}
+
+// renderDistrict loads the district in the URL and its schools and renders tmpl
+func (h *WebHandler) renderDistrict(w http.ResponseWriter, r *http.Request, tmpl string) {
 	id := chi.URLParam(r, "id")
 
-	school, err := h.DB.GetSchoolByID(id)
+	district, err := h.DB.GetDistrictByID(id)
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, sql.ErrNoRows) {
 			http.NotFound(w, r)
 			return
 		}
@@ -149,261 +458,2977 @@ func (h *WebHandler) SchoolDetail(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check if we have cached AI data (requires AI scraper)
-	var enhancedData *EnhancedSchoolData
-	if h.AIScraper != nil {
-		// Try to load from cache using the AI scraper's private method
-		// We'll check the database cache directly with a reasonable TTL
-		_, sourceURL, markdownContent, legacyData, extractedAt, err := h.DB.LoadAIScraperCache(school.NCESSCH, 30*24*time.Hour)
-		if err == nil && sourceURL != "" {
-			// Parse the cached data
-			enhancedData = &EnhancedSchoolData{
-				NCESSCH:         school.NCESSCH,
-				SchoolName:      school.Name,
-				SourceURL:       sourceURL,
-				MarkdownContent: markdownContent,
-				ExtractedAt:     extractedAt,
-			}
-			// Parse legacy data if available
-			if len(legacyData) > 0 {
-				if err := json.Unmarshal(legacyData, enhancedData); err != nil {
-					// Log error but continue - enhanced data is optional
-					log.Printf("Warning: failed to unmarshal legacy data: %v", err)
-				}
-			}
-		}
+	schools, err := h.DB.GetSchoolsByDistrict(id, maxDistrictSchools)
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
 	}
 
-	// Check if we have cached NAEP data
-	var naepView *NAEPDataView
-	if h.NAEPClient != nil && h.DB != nil {
-		// Try to load from cache with 90-day TTL
-		state, district, stateScoresJSON, districtScoresJSON, nationalScoresJSON, extractedAt, err := h.DB.LoadNAEPCache(school.NCESSCH, 90*24*time.Hour)
-		if err == nil && len(stateScoresJSON) > 0 {
-			// Parse the cached data
-			naepData := &NAEPData{
-				NCESSCH:     school.NCESSCH,
-				State:       state,
-				District:    district,
-				ExtractedAt: extractedAt,
-			}
-			// Unmarshal state scores
-			if len(stateScoresJSON) > 0 {
-				if err := json.Unmarshal(stateScoresJSON, &naepData.StateScores); err != nil {
-					log.Printf("Warning: failed to unmarshal state scores: %v", err)
-				}
-			}
-			// Unmarshal district scores
-			if len(districtScoresJSON) > 0 {
-				if err := json.Unmarshal(districtScoresJSON, &naepData.DistrictScores); err != nil {
-					log.Printf("Warning: failed to unmarshal district scores: %v", err)
-				}
-			}
-			// Unmarshal national scores
-			if len(nationalScoresJSON) > 0 {
-				if err := json.Unmarshal(nationalScoresJSON, &naepData.NationalScores); err != nil {
-					log.Printf("Warning: failed to unmarshal national scores: %v", err)
-				}
-			}
-			// Enrich the cached data for template
-			naepView = h.enrichNAEPData(naepData)
+	alerted, err := h.DB.AlertedSchoolIDs()
+	if err != nil {
+		log.Printf("Warning: failed to load NAEP alerts: %v", err)
+	}
+
+	yearChanges, err := h.DB.SchoolYearChanges()
+	if err != nil {
+		log.Printf("Warning: failed to load school year changes: %v", err)
+	}
+
+	quality, err := h.DB.SchoolQualityFlags(schoolIDs(schools))
+	if err != nil {
+		log.Printf("Warning: failed to load quality flags: %v", err)
+	}
+
+	// The staffing card, projection, and contacts are on the district page, not the expanded search result
+	var staffing *DistrictStaffing
+	var projection *EnrollmentProjection
+	var contacts *DistrictContacts
+	if tmpl == "district.html" {
+		if byDistrict, err := h.DB.DistrictStaffing([]string{district.LEAID}); err != nil {
+			log.Printf("Warning: failed to load district staffing: %v", err)
+		} else if s, ok := byDistrict[district.LEAID]; ok {
+			staffing = &s
+		}
+		if projection, err = h.DB.DistrictEnrollmentProjection(district.LEAID); err != nil {
+			log.Printf("Warning: failed to load enrollment projection: %v", err)
+		}
+		if contacts, err = h.DB.LoadDistrictContacts(district.LEAID); err != nil && !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("Warning: failed to load district contacts: %v", err)
 		}
 	}
 
 	data := map[string]interface{}{
-		"Title":        school.Name,
-		"School":       school,
-		"EnhancedData": enhancedData,
-		"NAEPData":     naepView,
+		"Title":        district.Name,
+		"District":     district,
+		"Schools":      schools,
+		"Alerted":      alerted,
+		"YearChanges":  yearChanges,
+		"QualityFlags": quality,
+		"Staffing":     staffing,
+		"Projection":   projection,
+		"Contacts":     contacts,
 		"AIAvailable":  h.AIScraper != nil,
+		"Role":         requestRole(r),
+	}
+
+	if err := h.templates.ExecuteTemplate(w, tmpl, data); err != nil {
+		h.templateError(w, err)
+	}
+}
+
+// ExtractDistrictContacts finds the superintendent, school board, and enrollment
+// office contacts of the district in the URL with AI, for the district page
+func (h *WebHandler) ExtractDistrictContacts(w http.ResponseWriter, r *http.Request) {
+	district, err := h.DB.GetDistrictByID(chi.URLParam(r, "id"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.NotFound(w, r)
+			return
+		}
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if h.AIScraper == nil {
+		h.renderUserError(w, r, ErrAINotConfigured, "District contact extraction failed")
+		return
+	}
+
+	contacts, err := h.AIScraper.ScrapeDistrictContacts(r.Context(), district)
+	if err != nil {
+		log.Printf("District contact extraction error: %v", err)
+		h.renderUserError(w, r, err, "District contact extraction failed")
+		return
+	}
+
+	data := map[string]interface{}{
+		"District":    district,
+		"Contacts":    contacts,
+		"AIAvailable": true,
+		"Role":        requestRole(r),
+	}
+	if err := h.templates.ExecuteTemplate(w, "district_contacts.html", data); err != nil {
+		h.templateError(w, err)
+	}
+}
+
+// AreaPage renders the summary of the schools in the zip code in the URL
+func (h *WebHandler) AreaPage(w http.ResponseWriter, r *http.Request) {
+	h.renderArea(w, r, AreaZip, chi.URLParam(r, "zip"))
+}
+
+// MetroAreaPage renders the summary of the schools in the CBSA (metro area) in the URL
+func (h *WebHandler) MetroAreaPage(w http.ResponseWriter, r *http.Request) {
+	h.renderArea(w, r, AreaCBSA, chi.URLParam(r, "cbsa"))
+}
+
+// CountyPage summarizes the schools in a county, by its FIPS code
+func (h *WebHandler) CountyPage(w http.ResponseWriter, r *http.Request) {
+	h.renderArea(w, r, AreaCounty, chi.URLParam(r, "fips"))
+}
+
+// CongressionalDistrictPage summarizes the schools in a congressional
+// district, e.g. /area/cd/IL-07
+func (h *WebHandler) CongressionalDistrictPage(w http.ResponseWriter, r *http.Request) {
+	h.renderArea(w, r, AreaCD, chi.URLParam(r, "cd"))
+}
+
+// CountiesPage totals schools, enrollment, and staffing per county, in the
+// ?state= state or all of them
+func (h *WebHandler) CountiesPage(w http.ResponseWriter, r *http.Request) {
+	h.renderAreaRollups(w, r, AreaCounty)
+}
+
+// CongressionalDistrictsPage totals schools, enrollment, and staffing per
+// congressional district, in the ?state= state or all of them
+func (h *WebHandler) CongressionalDistrictsPage(w http.ResponseWriter, r *http.Request) {
+	h.renderAreaRollups(w, r, AreaCD)
+}
+
+// renderAreaRollups loads and renders the county or congressional district totals
+func (h *WebHandler) renderAreaRollups(w http.ResponseWriter, r *http.Request, kind string) {
+	state := strings.ToUpper(strings.TrimSpace(r.URL.Query().Get("state")))
+	if state != "" && len(state) != 2 {
+		http.Error(w, fmt.Sprintf("invalid state %q (expected a two-letter code)", state), http.StatusBadRequest)
+		return
+	}
+
+	rollups, err := h.DB.AreaRollups(kind, state)
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	title := "Counties"
+	if kind == AreaCD {
+		title = "Congressional Districts"
+	}
+	if state != "" {
+		title += " in " + state
+	}
+	data := map[string]interface{}{
+		"Title":   title,
+		"Kind":    kind,
+		"State":   state,
+		"Rollups": rollups,
+	}
+
+	if err := h.templates.ExecuteTemplate(w, "area_rollups.html", data); err != nil {
+		h.templateError(w, err)
+	}
+}
+
+// renderArea loads and renders an area summary
+func (h *WebHandler) renderArea(w http.ResponseWriter, r *http.Request, kind, code string) {
+	if _, err := normalizeAreaCode(kind, code); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	area, err := LoadAreaSummary(h.DB, kind, code)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	alerted, err := h.DB.AlertedSchoolIDs()
+	if err != nil {
+		log.Printf("Warning: failed to load NAEP alerts: %v", err)
+	}
+
+	yearChanges, err := h.DB.SchoolYearChanges()
+	if err != nil {
+		log.Printf("Warning: failed to load school year changes: %v", err)
+	}
+
+	quality, err := h.DB.SchoolQualityFlags(schoolIDs(area.Schools))
+	if err != nil {
+		log.Printf("Warning: failed to load quality flags: %v", err)
+	}
+
+	data := map[string]interface{}{
+		"Title":        area.Title(),
+		"Area":         area,
+		"Schools":      area.Schools,
+		"Alerted":      alerted,
+		"YearChanges":  yearChanges,
+		"QualityFlags": quality,
+	}
+
+	if err := h.templates.ExecuteTemplate(w, "area.html", data); err != nil {
+		h.templateError(w, err)
+	}
+}
+
+// NeighborhoodPage reports on the schools within ?radius= miles of the zip
+// code or address in ?location=, or of the home location when none is given
+func (h *WebHandler) NeighborhoodPage(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	data := map[string]interface{}{
+		"Title":    "Neighborhood School Report",
+		"Location": strings.TrimSpace(query.Get("location")),
+		"Radius":   strings.TrimSpace(query.Get("radius")),
+	}
+
+	report, err := h.neighborhoodReport(r)
+	switch {
+	case errors.Is(err, ErrNoLocation):
+		// Nothing to report until a location is entered
+	case err != nil:
+		log.Printf("Warning: neighborhood report for %q: %v", data["Location"], err)
+		data["Error"] = neighborhoodErrorMessage(err)
+	default:
+		data["Title"] = report.Title()
+		data["Report"] = report
+		data["ExportQuery"] = template.URL(url.Values{"location": {report.Query}, "radius": {fmt.Sprintf("%g", report.RadiusMiles)}}.Encode())
+	}
+
+	if err := h.templates.ExecuteTemplate(w, "neighborhood.html", data); err != nil {
+		h.templateError(w, err)
+	}
+}
+
+// ExportNeighborhood downloads a neighborhood report as a standalone HTML
+// file with its styles inline, which prints to PDF from any browser
+func (h *WebHandler) ExportNeighborhood(w http.ResponseWriter, r *http.Request) {
+	report, err := h.neighborhoodReport(r)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, sql.ErrNoRows) {
+			status = http.StatusNotFound
+		}
+		http.Error(w, neighborhoodErrorMessage(err), status)
+		return
+	}
+	report.BaseURL = apiBaseURL(r)
+
+	var buf bytes.Buffer
+	if err := h.templates.ExecuteTemplate(&buf, "neighborhood_export.html", report); err != nil {
+		h.templateError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "neighborhood-schools-"+report.GeneratedAt.Format("2006-01-02")+".html"))
+	if _, err := buf.WriteTo(w); err != nil {
+		log.Printf("Warning: failed to write response: %v", err)
+	}
+}
+
+// neighborhoodReport builds the report for a request's location and radius
+func (h *WebHandler) neighborhoodReport(r *http.Request) (*NeighborhoodReport, error) {
+	query := r.URL.Query()
+	radius, err := parseNeighborhoodRadius(query.Get("radius"))
+	if err != nil {
+		return nil, err
+	}
+	location := strings.TrimSpace(query.Get("location"))
+	center, err := ResolveNeighborhoodCenter(r.Context(), h.DB, h.geocoder, location)
+	if err != nil {
+		return nil, err
+	}
+	if location == "" && center.Label == "" {
+		center.Label = "Home"
+	}
+	return BuildNeighborhoodReport(h.DB, location, *center, radius)
+}
+
+// neighborhoodErrorMessage explains why a neighborhood report couldn't be built
+func neighborhoodErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, ErrAddressNotFound):
+		return "The Census geocoder couldn't find that address. Try including the city and state, or enter latitude,longitude."
+	case errors.Is(err, sql.ErrNoRows):
+		return "No schools with known locations are within that radius. Try a larger radius, or add an NCES EDGE geocode file (" + geocodeFilePattern + ") to the data directory."
+	case errors.Is(err, errInvalidRadius):
+		return err.Error()
+	default:
+		return "The location couldn't be looked up right now. Try again, or enter latitude,longitude."
+	}
+}
+
+// PipelinePage shows the elementary, middle, and high school assigned to an
+// address, or to the home location when no address is given
+func (h *WebHandler) PipelinePage(w http.ResponseWriter, r *http.Request) {
+	address := strings.TrimSpace(r.URL.Query().Get("address"))
+	data := map[string]interface{}{
+		"Title":       "Schools Serving an Address",
+		"Address":     address,
+		"AIAvailable": h.AIScraper != nil,
+		"Role":        requestRole(r),
+	}
+
+	location, err := ResolveLocation(r.Context(), h.DB, h.geocoder, address)
+	switch {
+	case errors.Is(err, ErrNoLocation):
+		// Nothing to look up until an address is entered
+	case err != nil:
+		log.Printf("Warning: failed to find %q: %v", address, err)
+		if errors.Is(err, ErrAddressNotFound) {
+			data["Error"] = "The Census geocoder couldn't find that address. Try including the city and state, or enter latitude,longitude."
+		} else {
+			data["Error"] = "The address couldn't be looked up right now. Try again, or enter latitude,longitude."
+		}
+	default:
+		pipeline, err := BuildFeederPipeline(h.DB, *location)
+		if err != nil {
+			log.Printf("Database error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		data["Pipeline"] = pipeline
+	}
+
+	if err := h.templates.ExecuteTemplate(w, "pipeline.html", data); err != nil {
+		h.templateError(w, err)
+	}
+}
+
+// InferFeeders infers with AI which schools the school in the URL feeds into,
+// for pipeline stages no boundary or feeder table covers
+func (h *WebHandler) InferFeeders(w http.ResponseWriter, r *http.Request) {
+	school, err := h.DB.GetSchoolByID(chi.URLParam(r, "id"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.NotFound(w, r)
+			return
+		}
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if h.AIScraper == nil {
+		h.renderUserError(w, r, ErrAINotConfigured, "Feeder inference failed")
+		return
+	}
+
+	inference, err := h.AIScraper.ScrapeFeeders(r.Context(), school)
+	if err != nil {
+		log.Printf("Feeder inference error: %v", err)
+		h.renderUserError(w, r, err, "Feeder inference failed")
+		return
+	}
+
+	data := map[string]interface{}{
+		"School":    school,
+		"Inference": inference,
+		"Address":   r.FormValue("address"),
+	}
+	if err := h.templates.ExecuteTemplate(w, "feeder_inference.html", data); err != nil {
+		h.templateError(w, err)
+	}
+}
+
+// CalendarsPage lines up the academic calendars of the schools in the URL's
+// ids, or of every child's saved schools for editors and up, who can see the
+// children
+func (h *WebHandler) CalendarsPage(w http.ResponseWriter, r *http.Request) {
+	ids := parseCompareIDs(r.URL.Query().Get("ids"))
+	fromChildren := len(ids) == 0
+	if fromChildren && requestRole(r).CanEdit() {
+		var err error
+		if ids, err = favoriteSchoolIDs(h.DB); err != nil {
+			log.Printf("Database error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	schools, err := h.loadCompareSchools(ids)
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	comparison, err := CompareCalendars(h.DB, schools)
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	data := map[string]interface{}{
+		"Title":        "School Calendars",
+		"Comparison":   comparison,
+		"IDs":          strings.Join(ids, ","),
+		"FromChildren": fromChildren,
+		"AIAvailable":  h.AIScraper != nil,
+		"Role":         requestRole(r),
+	}
+	if err := h.templates.ExecuteTemplate(w, "calendars.html", data); err != nil {
+		h.templateError(w, err)
+	}
+}
+
+// ExtractCalendar finds the academic calendar of the school in the URL with AI
+// and reloads the calendar comparison
+func (h *WebHandler) ExtractCalendar(w http.ResponseWriter, r *http.Request) {
+	school, err := h.DB.GetSchoolByID(chi.URLParam(r, "id"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.NotFound(w, r)
+			return
+		}
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if h.AIScraper == nil {
+		h.renderUserError(w, r, ErrAINotConfigured, "Calendar extraction failed")
+		return
+	}
+	if _, err := h.AIScraper.ScrapeCalendar(r.Context(), school); err != nil {
+		log.Printf("Calendar extraction error: %v", err)
+		h.renderUserError(w, r, err, "Calendar extraction failed")
+		return
+	}
+
+	// The new calendar changes the whole comparison, so reload it
+	if r.Header.Get("HX-Request") == "true" {
+		w.Header().Set("HX-Refresh", "true")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	target := "/calendars"
+	if ids := parseCompareIDs(r.FormValue("ids")); len(ids) > 0 {
+		target += "?ids=" + url.QueryEscape(strings.Join(ids, ","))
+	}
+	http.Redirect(w, r, target, http.StatusSeeOther)
+}
+
+// CalendarICS downloads the academic calendar of the school in the URL
+func (h *WebHandler) CalendarICS(w http.ResponseWriter, r *http.Request) {
+	calendar, err := h.DB.LoadSchoolCalendar(chi.URLParam(r, "id"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.NotFound(w, r)
+			return
+		}
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	var location string
+	if school, err := h.DB.GetSchoolByID(calendar.NCESSCH); err == nil {
+		location = schoolDateLocation(school)
+	}
+	var buf bytes.Buffer
+	if err := WriteCalendarICS(&buf, calendar, location, time.Now()); err != nil {
+		log.Printf("Calendar export error: %v", err)
+		http.Error(w, "Failed to export calendar", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "calendar-"+calendar.NCESSCH+".ics"))
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		log.Printf("Warning: failed to write response: %v", err)
+	}
+}
+
+// AreaNAEP fetches NAEP results for the school in the URL and renders its state's
+// results for an area page
+func (h *WebHandler) AreaNAEP(w http.ResponseWriter, r *http.Request) {
+	school, err := h.DB.GetSchoolByID(chi.URLParam(r, "id"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.NotFound(w, r)
+			return
+		}
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if h.NAEPClient == nil {
+		http.Error(w, "NAEP data not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	state := AreaState{Code: school.State, Name: school.StateName}
+	if _, err := h.NAEPClient.FetchNAEPData(r.Context(), school); err != nil {
+		log.Printf("NAEP fetch error: %v", err)
+		state.NAEPError = err.Error()
+	}
+	if state.NAEP, err = StateNAEPContext(h.DB, school.State); err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.templates.ExecuteTemplate(w, "area_naep.html", state); err != nil {
+		h.templateError(w, err)
+	}
+}
+
+// SchoolDetail renders the school detail page
+func (h *WebHandler) SchoolDetail(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	school, err := h.DB.GetSchoolByID(id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.NotFound(w, r)
+			return
+		}
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if school.NCESSCH != id {
+		// Merged duplicates link to their canonical school
+		http.Redirect(w, r, "/schools/"+school.NCESSCH, http.StatusFound)
+		return
+	}
+
+	merges, err := h.DB.ListSchoolMerges(school.NCESSCH)
+	if err != nil {
+		log.Printf("Warning: failed to load merged duplicates: %v", err)
+	}
+
+	// Latest liveness check of the school's website, rechecked in the background when stale
+	var websiteCheck *WebsiteCheck
+	if school.Website.Valid && school.Website.String != "" {
+		check, err := h.DB.GetWebsiteCheck(school.NCESSCH)
+		if err != nil && err != sql.ErrNoRows {
+			log.Printf("Warning: failed to load website check: %v", err)
+		}
+		if h.websiteChecker != nil && h.websiteChecker.Stale(check, school) {
+			h.websiteChecker.Enqueue(school.NCESSCH)
+		}
+		if check != nil && check.URL == schoolWebsiteURL(school) {
+			websiteCheck = check
+		}
+	}
+
+	// Check if we have cached AI data (requires AI scraper); editors refresh stale entries
+	var enhancedData *EnhancedSchoolData
+	var aiEdits []AIDataEdit
+	if h.AIScraper != nil {
+		if cached, err := h.AIScraper.CachedSchoolData(school); err == nil && cached.SourceURL != "" {
+			enhancedData = cached
+			aiEdits = h.aiEdits(school.NCESSCH)
+		}
+	}
+
+	// Check if we have cached NAEP data; stale entries refresh in the background
+	var naepView *NAEPDataView
+	if h.NAEPClient != nil && h.DB != nil {
+		if cached, err := h.NAEPClient.CachedNAEPData(school); err == nil && len(cached.StateScores) > 0 {
+			naepView = h.cachedNAEPView(cached)
+		}
+	}
+
+	// Check if we have a cached parent summary
+	var parentSummary *ParentSummary
+	var parentSummaryHTML template.HTML
+	if h.AIScraper != nil {
+		if cached, err := h.AIScraper.LoadParentSummary(school.NCESSCH); err == nil {
+			parentSummary = cached
+			parentSummaryHTML = markdownToHTML(cached.Summary)
+		}
+	}
+
+	// Per-school NAEP settings
+	naepOverride := NAEPOverride{NCESSCH: school.NCESSCH}
+	if h.NAEPClient != nil {
+		naepOverride = h.NAEPClient.Override(school.NCESSCH)
+	}
+
+	// Active NAEP decline alerts for the badge
+	alerts, err := h.DB.ListNAEPAlerts(school.NCESSCH, false)
+	if err != nil {
+		log.Printf("Warning: failed to load NAEP alerts: %v", err)
+	}
+
+	// Opened, renamed, or new ID in the latest school year
+	var yearChange *SchoolYearChange
+	if changes, err := h.DB.SchoolYearChanges(); err != nil {
+		log.Printf("Warning: failed to load school year changes: %v", err)
+	} else if c, ok := changes[school.NCESSCH]; ok {
+		yearChange = &c
+	}
+
+	// Enrollment across loaded school years
+	var enrollmentTrend *EnrollmentTrend
+	if trends, err := h.DB.EnrollmentTrends([]string{school.NCESSCH}); err != nil {
+		log.Printf("Warning: failed to load enrollment trend: %v", err)
+	} else if t, ok := trends[school.NCESSCH]; ok {
+		enrollmentTrend = &t
+	}
+
+	// Where enrollment, teachers, and ratio stand among similar schools
+	var percentiles SchoolPercentiles
+	if byID, err := h.DB.SchoolPercentiles([]string{school.NCESSCH}); err != nil {
+		log.Printf("Warning: failed to load school percentiles: %v", err)
+	} else {
+		percentiles = byID[school.NCESSCH]
+	}
+
+	// Anomalies the data-quality pass found in the school's CCD record
+	var quality SchoolQuality
+	if byID, err := h.DB.SchoolQualityFlags([]string{school.NCESSCH}); err != nil {
+		log.Printf("Warning: failed to load quality flags: %v", err)
+	} else {
+		quality = byID[school.NCESSCH]
+	}
+
+	// District staffing composition and counselor ratio
+	var staffing *DistrictStaffing
+	if school.DistrictID.Valid {
+		if byDistrict, err := h.DB.DistrictStaffing([]string{school.DistrictID.String}); err != nil {
+			log.Printf("Warning: failed to load district staffing: %v", err)
+		} else if s, ok := byDistrict[school.DistrictID.String]; ok {
+			staffing = &s
+		}
+	}
+
+	// Links to the school's zip code, metro area, county, and congressional district summaries
+	areaZip, _ := normalizeAreaCode(AreaZip, school.Zip.String)
+	var metroArea, county, congressionalDistrict *AreaState
+	if cbsa, name, err := h.DB.SchoolCBSA(school.NCESSCH); err != nil {
+		log.Printf("Warning: failed to load metro area: %v", err)
+	} else if cbsa != "" {
+		metroArea = &AreaState{Code: cbsa, Name: name}
+	}
+	if fips, name, cd, err := h.DB.SchoolGeographies(school.NCESSCH); err != nil {
+		log.Printf("Warning: failed to load county and congressional district: %v", err)
+	} else {
+		if fips != "" {
+			county = &AreaState{Code: fips, Name: name}
+		}
+		if cd != "" {
+			congressionalDistrict = &AreaState{Code: cd}
+		}
+	}
+
+	keyDates, err := h.DB.SchoolDates(school.NCESSCH)
+	if err != nil {
+		log.Printf("Warning: failed to load key dates: %v", err)
+	}
+	children := loadChildren(h.DB)
+	programs, err := h.DB.ProgramFlags(school.NCESSCH)
+	if err != nil {
+		log.Printf("Warning: failed to load program flags: %v", err)
+	}
+	preK, err := h.DB.PreKPrograms(school.NCESSCH)
+	if err != nil {
+		log.Printf("Warning: failed to load pre-K programs: %v", err)
+	}
+	bus := h.busView(school, children)
+	safety, err := h.DB.SchoolSafety(school.NCESSCH)
+	if err != nil {
+		log.Printf("Warning: failed to load safety data: %v", err)
+	}
+	ratings, err := h.DB.SchoolRatingsPanel(school.NCESSCH)
+	if err != nil {
+		log.Printf("Warning: failed to load state ratings: %v", err)
+	}
+	provenance, err := h.DB.SchoolProvenance(school)
+	if err != nil {
+		log.Printf("Warning: failed to load data sources: %v", err)
+	}
+	hours := h.schoolHours(school, enhancedData)
+	callLog := h.callLogView(r, school.NCESSCH)
+	outreach := h.schoolOutreachView(school.NCESSCH)
+
+	data := map[string]interface{}{
+		"Title":                 school.Name,
+		"School":                school,
+		"EnhancedData":          enhancedData,
+		"AIEdits":               aiEdits,
+		"NAEPData":              naepView,
+		"ParentSummary":         parentSummary,
+		"ParentSummaryHTML":     parentSummaryHTML,
+		"AIAvailable":           h.AIScraper != nil,
+		"Alerts":                alerts,
+		"YearChange":            yearChange,
+		"NAEPOverride":          naepOverride,
+		"EnrollmentTrend":       enrollmentTrend,
+		"Percentiles":           percentiles,
+		"Quality":               quality,
+		"AreaZip":               areaZip,
+		"MetroArea":             metroArea,
+		"County":                county,
+		"CongressionalDistrict": congressionalDistrict,
+		"Staffing":              staffing,
+		"CorrectionFields":      school.CorrectionFields(),
+		"SuggestCorrections":    h.suggestions && !requestRole(r).CanEdit(),
+		"Merges":                merges,
+		"WebsiteCheck":          websiteCheck,
+		"KeyDates":              keyDates,
+		"ChildSaves":            childSaves(school, children),
+		"Programs":              programs,
+		"PreKPrograms":          preK,
+		"Bus":                   bus,
+		"Safety":                safety,
+		"Ratings":               ratings,
+		"Provenance":            provenance,
+		"Hours":                 hours,
+		"CallLog":               callLog,
+		"Outreach":              outreach,
+		"Now":                   time.Now(),
+		"CopyActions":           SchoolCopyActions(school, enhancedData),
+		"Role":                  requestRole(r),
+		"CSRFToken":             csrfToken(w, r),
+	}
+
+	if err := h.templates.ExecuteTemplate(w, "detail.html", data); err != nil {
+		h.templateError(w, err)
+	}
+}
+
+// ShareSchool renders a QR code of the school's page for opening it on a phone
+func (h *WebHandler) ShareSchool(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	school, err := h.DB.GetSchoolByID(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.NotFound(w, r)
+			return
+		}
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	shareURL := schoolShareURL(shareBaseURL(r), school.NCESSCH)
+	code, err := qrSVG(shareURL)
+	if err != nil {
+		log.Printf("QR code error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	data := map[string]interface{}{
+		"URL":    shareURL,
+		"QRCode": code,
+		"Local":  os.Getenv("SCHOOLFINDER_URL") == "",
+	}
+
+	if err := h.templates.ExecuteTemplate(w, "school_share.html", data); err != nil {
+		h.templateError(w, err)
+	}
+}
+
+// ExtractAI handles AI extraction requests and returns AI data partial
+func (h *WebHandler) ExtractAI(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	school, err := h.DB.GetSchoolByID(id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.NotFound(w, r)
+			return
+		}
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// Check if AI scraper is available
+	if h.AIScraper == nil {
+		h.renderUserError(w, r, ErrAINotConfigured, "AI extraction failed")
+		return
+	}
+
+	// Extract AI data using ScrapeSchoolWebsite which properly fills metadata and caches
+	enhancedData, err := h.AIScraper.ScrapeSchoolWebsite(r.Context(), school)
+	if err != nil {
+		log.Printf("AI extraction error: %v", err)
+		h.renderUserError(w, r, err, "AI extraction failed")
+		return
+	}
+
+	data := map[string]interface{}{
+		"EnhancedData": enhancedData,
+		"School":       school,
+		"Role":         requestRole(r),
+	}
+
+	if err := h.templates.ExecuteTemplate(w, "ai_data.html", data); err != nil {
+		h.templateError(w, err)
+	}
+}
+
+// cachedAIData loads a school and its saved website data for viewing or editing,
+// writing an error response and returning false if either is unavailable
+func (h *WebHandler) cachedAIData(w http.ResponseWriter, r *http.Request) (*School, *EnhancedSchoolData, bool) {
+	id := chi.URLParam(r, "id")
+
+	school, err := h.DB.GetSchoolByID(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.NotFound(w, r)
+			return nil, nil, false
+		}
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return nil, nil, false
+	}
+
+	if h.AIScraper == nil {
+		h.renderUserError(w, r, ErrAINotConfigured, "Website data couldn't be loaded")
+		return nil, nil, false
+	}
+	enhanced, err := h.AIScraper.loadCachedData(school.NCESSCH, cacheNoExpiry)
+	if err != nil {
+		h.renderUserError(w, r, err, "Website data couldn't be loaded")
+		return nil, nil, false
+	}
+	return school, enhanced, true
+}
+
+// aiEdits loads a school's recent manual website data edits for the edit history
+func (h *WebHandler) aiEdits(ncessch string) []AIDataEdit {
+	edits, err := h.DB.ListAIDataEdits(ncessch, maxAIEditHistory)
+	if err != nil {
+		log.Printf("Warning: failed to load website data edits: %v", err)
+	}
+	return edits
+}
+
+// AIData returns the saved website data partial, e.g. after canceling an edit
+func (h *WebHandler) AIData(w http.ResponseWriter, r *http.Request) {
+	school, enhanced, ok := h.cachedAIData(w, r)
+	if !ok {
+		return
+	}
+
+	data := map[string]interface{}{
+		"EnhancedData": enhanced,
+		"School":       school,
+		"AIEdits":      h.aiEdits(school.NCESSCH),
+		"Role":         requestRole(r),
+	}
+
+	if err := h.templates.ExecuteTemplate(w, "ai_data.html", data); err != nil {
+		h.templateError(w, err)
+	}
+}
+
+// EditAIData returns the form for correcting a school's website data by hand
+func (h *WebHandler) EditAIData(w http.ResponseWriter, r *http.Request) {
+	school, enhanced, ok := h.cachedAIData(w, r)
+	if !ok {
+		return
+	}
+
+	data := map[string]interface{}{
+		"School": school,
+		"Form":   newAIDataEditForm(enhanced),
+	}
+
+	if err := h.templates.ExecuteTemplate(w, "ai_edit_form.html", data); err != nil {
+		h.templateError(w, err)
+	}
+}
+
+// SaveAIData validates and saves the website data edit form, recording the changed
+// fields in the school's edit history. An invalid form is returned with its errors.
+func (h *WebHandler) SaveAIData(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	school, enhanced, ok := h.cachedAIData(w, r)
+	if !ok {
+		return
+	}
+
+	form := parseAIDataEditForm(r.PostForm)
+	if !form.Validate() {
+		// HTMX only swaps successful responses
+		if r.Header.Get("HX-Request") != "true" {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+		}
+		data := map[string]interface{}{
+			"School": school,
+			"Form":   form,
+		}
+		if err := h.templates.ExecuteTemplate(w, "ai_edit_form.html", data); err != nil {
+			h.templateError(w, err)
+		}
+		return
+	}
+
+	edited := form.Apply(enhanced)
+	if _, err := saveManualEdits(h.DB, enhanced, edited, aiEditSourceWeb); err != nil {
+		log.Printf("Website data edit error: %v", err)
+		h.renderUserError(w, r, err, "Your edits couldn't be saved")
+		return
+	}
+
+	data := map[string]interface{}{
+		"EnhancedData": edited,
+		"School":       school,
+		"AIEdits":      h.aiEdits(school.NCESSCH),
+		"Saved":        true,
+		"Role":         requestRole(r),
+	}
+
+	if err := h.templates.ExecuteTemplate(w, "ai_data.html", data); err != nil {
+		h.templateError(w, err)
+	}
+}
+
+// ParentSummary generates a plain-language parent summary and returns the summary partial
+func (h *WebHandler) ParentSummary(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	school, err := h.DB.GetSchoolByID(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.NotFound(w, r)
+			return
+		}
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if h.AIScraper == nil {
+		h.renderUserError(w, r, ErrAINotConfigured, "The parent summary couldn't be written")
+		return
+	}
+
+	// Feed whatever enrichment is already cached into the summary
+	enhancedData, naepData := h.loadCachedEnrichment(school.NCESSCH)
+
+	summary, err := h.AIScraper.GenerateParentSummary(r.Context(), school, enhancedData, naepData)
+	if err != nil {
+		log.Printf("Parent summary error: %v", err)
+		h.renderUserError(w, r, err, "The parent summary couldn't be written")
+		return
+	}
+
+	data := map[string]interface{}{
+		"ParentSummary":     summary,
+		"ParentSummaryHTML": markdownToHTML(summary.Summary),
+		"School":            school,
+	}
+
+	if err := h.templates.ExecuteTemplate(w, "parent_summary.html", data); err != nil {
+		h.templateError(w, err)
+	}
+}
+
+// TourQuestions generates tailored tour questions and returns the questions partial
+func (h *WebHandler) TourQuestions(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	school, err := h.DB.GetSchoolByID(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.NotFound(w, r)
+			return
+		}
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	enhancedData, naepData := h.loadCachedEnrichment(school.NCESSCH)
+
+	data := map[string]interface{}{
+		"School":    school,
+		"Questions": GenerateTourQuestions(school, enhancedData, naepData),
+	}
+
+	if err := h.templates.ExecuteTemplate(w, "tour_questions.html", data); err != nil {
+		h.templateError(w, err)
+	}
+}
+
+// InquiryDraft drafts an email to the enrollment office of the school in the
+// URL with the ?template= inquiry template, for the ?child= child or the first
+// child the school serves, and returns the inquiry partial
+func (h *WebHandler) InquiryDraft(w http.ResponseWriter, r *http.Request) {
+	school, err := h.DB.GetSchoolByID(chi.URLParam(r, "id"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.NotFound(w, r)
+			return
+		}
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	templates, err := LoadInquiryTemplates(h.DB.dataDir)
+	if err != nil {
+		log.Printf("Inquiry template error: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	name := cmp.Or(r.URL.Query().Get("template"), defaultInquiryTemplate)
+	tmpl, ok := templates[name]
+	if !ok {
+		http.Error(w, fmt.Sprintf("unknown inquiry template %q", name), http.StatusBadRequest)
+		return
+	}
+
+	children, err := h.DB.Children()
+	if err != nil {
+		log.Printf("Warning: failed to load children: %v", err)
+	}
+	var child *Child
+	childID := r.URL.Query().Get("child")
+	for i, c := range children {
+		if (childID == "" && c.Serves(*school)) || strconv.FormatInt(c.ID, 10) == childID {
+			child = &children[i]
+			break
+		}
+	}
+
+	var district *DistrictContacts
+	if school.DistrictID.Valid {
+		if district, err = h.DB.LoadDistrictContacts(school.DistrictID.String); err != nil && !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("Warning: failed to load district contacts: %v", err)
+		}
+	}
+	enhancedData, naepData := h.loadCachedEnrichment(school.NCESSCH)
+
+	inquiry, err := DraftInquiry(tmpl, school, child, enhancedData, district, naepData)
+	if err != nil {
+		log.Printf("Inquiry template error: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	data := map[string]interface{}{
+		"School":    school,
+		"Inquiry":   inquiry,
+		"Templates": inquiryTemplateNames(templates),
+		"Children":  children,
+		"Child":     child,
+		"Role":      requestRole(r),
+	}
+	if err := h.templates.ExecuteTemplate(w, "inquiry.html", data); err != nil {
+		h.templateError(w, err)
+	}
+}
+
+// SchoolHoursStatus returns whether the school is in session now, in its own
+// time zone. Detail pages poll it, since the page itself is cached.
+func (h *WebHandler) SchoolHoursStatus(w http.ResponseWriter, r *http.Request) {
+	school, err := h.DB.GetSchoolByID(chi.URLParam(r, "id"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.NotFound(w, r)
+			return
+		}
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	enhancedData, _ := h.loadCachedEnrichment(school.NCESSCH)
+	hours := h.schoolHours(school, enhancedData)
+	if hours == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if err := h.templates.ExecuteTemplate(w, "hours_status.html", hours.Status(time.Now())); err != nil {
+		h.templateError(w, err)
+	}
+}
+
+// schoolHours normalizes the school hours in a school's website data, or
+// returns nil when there are none or they can't be read
+func (h *WebHandler) schoolHours(school *School, enhancedData *EnhancedSchoolData) *SchoolHours {
+	if enhancedData == nil {
+		return nil
+	}
+	hours, err := h.DB.SchoolHours(school, enhancedData.SchoolHours)
+	if err != nil {
+		log.Printf("Warning: failed to load school hours: %v", err)
+	}
+	return hours
+}
+
+// TourQuestionsMarkdown returns the tour questions as a downloadable markdown file
+func (h *WebHandler) TourQuestionsMarkdown(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	school, err := h.DB.GetSchoolByID(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.NotFound(w, r)
+			return
+		}
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	enhancedData, naepData := h.loadCachedEnrichment(school.NCESSCH)
+	markdown := FormatTourQuestionsMarkdown(school, GenerateTourQuestions(school, enhancedData, naepData))
+
+	w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"tour_questions_%s.md\"", school.NCESSCH))
+	if _, err := w.Write([]byte(markdown)); err != nil {
+		log.Printf("Warning: failed to write response: %v", err)
+	}
+}
+
+// SchoolNote returns a school as a markdown note with YAML frontmatter for
+// note apps like Obsidian and Notion
+func (h *WebHandler) SchoolNote(w http.ResponseWriter, r *http.Request) {
+	school, err := h.DB.GetSchoolByID(chi.URLParam(r, "id"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.NotFound(w, r)
+			return
+		}
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	enhancedData, naepData := h.loadCachedEnrichment(school.NCESSCH)
+	calls, err := h.DB.SchoolCalls(school.NCESSCH)
+	if err != nil {
+		log.Printf("Warning: failed to load call log: %v", err)
+	}
+	note := FormatSchoolNote(school, enhancedData, naepData, calls)
+
+	w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": schoolNoteFilename(school)}))
+	if _, err := w.Write([]byte(note)); err != nil {
+		log.Printf("Warning: failed to write response: %v", err)
+	}
+}
+
+// TimelineICS downloads every school's key dates as an iCalendar file
+func (h *WebHandler) TimelineICS(w http.ResponseWriter, r *http.Request) {
+	h.exportTimeline(w, "text/calendar; charset=utf-8", "school-applications.ics", func(w io.Writer, dates []SchoolDate) error {
+		return WriteTimelineICS(w, dates, time.Now())
+	})
+}
+
+// TimelineCSV downloads every school's key dates as CSV
+func (h *WebHandler) TimelineCSV(w http.ResponseWriter, r *http.Request) {
+	h.exportTimeline(w, "text/csv; charset=utf-8", "school-applications.csv", WriteTimelineCSV)
+}
+
+func (h *WebHandler) exportTimeline(w http.ResponseWriter, contentType, filename string, write func(io.Writer, []SchoolDate) error) {
+	dates, err := h.DB.SchoolDates("")
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := write(&buf, dates); err != nil {
+		log.Printf("Timeline export error: %v", err)
+		http.Error(w, "Failed to export timeline", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	if _, err := buf.WriteTo(w); err != nil {
+		log.Printf("Warning: failed to write response: %v", err)
+	}
+}
+
+// ApplicationsPage lists a season's applications with a status summary and
+// reminders. ?season= picks another season.
+func (h *WebHandler) ApplicationsPage(w http.ResponseWriter, r *http.Request) {
+	season := r.URL.Query().Get("season")
+	if season == "" {
+		season = currentSeason(time.Now())
+	}
+	if _, _, ok := seasonWindow(season); !ok {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	apps, err := h.DB.Applications(season)
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	data := map[string]interface{}{
+		"Title":        "Applications",
+		"Season":       season,
+		"Applications": apps,
+		"Summary":      SummarizeApplications(apps),
+		"Reminders":    ApplicationReminders(apps, time.Now(), defaultReminderWindow),
+		"ReminderDays": int(defaultReminderWindow.Hours() / 24),
+	}
+
+	if err := h.templates.ExecuteTemplate(w, "applications.html", data); err != nil {
+		h.templateError(w, err)
+	}
+}
+
+// ApplicationsRecap downloads a season's applications as a markdown recap
+func (h *WebHandler) ApplicationsRecap(w http.ResponseWriter, r *http.Request) {
+	season := r.URL.Query().Get("season")
+	if season == "" {
+		season = currentSeason(time.Now())
+	}
+	if _, _, ok := seasonWindow(season); !ok {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	apps, err := h.DB.Applications(season)
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "applications-"+season+".md"))
+	if _, err := io.WriteString(w, FormatSeasonRecap(season, apps)); err != nil {
+		log.Printf("Warning: failed to write response: %v", err)
+	}
+}
+
+// childView is a child with their saved schools loaded, for the children page
+type childView struct {
+	Child   Child
+	Schools []*School
+}
+
+// ChildrenPage lists the child profiles and their saved schools
+func (h *WebHandler) ChildrenPage(w http.ResponseWriter, r *http.Request) {
+	h.renderChildren(w, "children.html", "")
+}
+
+func (h *WebHandler) renderChildren(w http.ResponseWriter, tmpl, message string) {
+	children, err := h.DB.Children()
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	views := make([]childView, len(children))
+	for i, c := range children {
+		views[i].Child = c
+		for _, id := range c.Schools {
+			school, err := h.DB.GetSchoolByID(id)
+			if err != nil {
+				log.Printf("Warning: failed to load saved school %s: %v", id, err)
+				continue
+			}
+			views[i].Schools = append(views[i].Schools, school)
+		}
+	}
+
+	data := map[string]interface{}{
+		"Title":    "Children",
+		"Children": views,
+		"Grades":   searchGradeOptions,
+		"Message":  message,
+	}
+
+	if err := h.templates.ExecuteTemplate(w, tmpl, data); err != nil {
+		h.templateError(w, err)
+	}
+}
+
+// AddChild saves the submitted child profile and re-renders the list
+func (h *WebHandler) AddChild(w http.ResponseWriter, r *http.Request) {
+	child := &Child{Name: r.FormValue("name"), Grade: r.FormValue("grade"), Needs: strings.Split(r.FormValue("needs"), ",")}
+	if err := SaveChild(h.DB, child); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	h.renderChildren(w, "child_list.html", "Saved "+child.Name)
+}
+
+// DeleteChild removes a child profile and re-renders the list
+func (h *WebHandler) DeleteChild(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.DB.DeleteChild(id); err != nil {
+		log.Printf("Delete child error: %v", err)
+		http.NotFound(w, r)
+		return
+	}
+	h.renderChildren(w, "child_list.html", "")
+}
+
+// schoolOutreachView is the emails sent to a school, under its inquiry draft
+type schoolOutreachView struct {
+	Items []Outreach
+	Error string
+}
+
+// schoolOutreachView loads a school's outreach, where a failure only empties the list
+func (h *WebHandler) schoolOutreachView(ncessch string) schoolOutreachView {
+	var view schoolOutreachView
+	var err error
+	if view.Items, err = h.DB.OutreachList(ncessch); err != nil {
+		log.Printf("Warning: failed to load outreach: %v", err)
+	}
+	return view
+}
+
+// RecordSchoolOutreach marks a drafted inquiry as sent, to track the reply,
+// and returns the school's updated outreach
+func (h *WebHandler) RecordSchoolOutreach(w http.ResponseWriter, r *http.Request) {
+	school, err := h.DB.GetSchoolByID(chi.URLParam(r, "id"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	o := &Outreach{NCESSCH: school.NCESSCH, To: r.FormValue("to"), Subject: r.FormValue("subject"), Template: r.FormValue("template")}
+	err = RecordOutreach(h.DB, o, time.Now())
+	view := h.schoolOutreachView(school.NCESSCH)
+	if err != nil {
+		view.Error = err.Error()
+		if r.Header.Get("HX-Request") != "true" {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+		}
+	}
+	if err := h.templates.ExecuteTemplate(w, "school_outreach.html", view); err != nil {
+		h.templateError(w, err)
+	}
+}
+
+// outreachStatusOption is a choice in an outreach row's status menu
+type outreachStatusOption struct {
+	Value string
+	Label string
+}
+
+// outreachRow is an email on the outreach page, with its form for editors
+type outreachRow struct {
+	Item     Outreach
+	Role     Role
+	Statuses []outreachStatusOption
+	Today    time.Time
+	MaxNote  int
+	Error    string
+}
+
+func newOutreachRow(r *http.Request, item Outreach, now time.Time) outreachRow {
+	row := outreachRow{Item: item, Role: requestRole(r), Today: outreachDay(now), MaxNote: maxOutreachNote}
+	for _, status := range outreachStatuses {
+		row.Statuses = append(row.Statuses, outreachStatusOption{Value: status, Label: outreachStatusLabels[status]})
+	}
+	return row
+}
+
+// OutreachPage lists emails to schools, those due a follow-up first
+func (h *WebHandler) OutreachPage(w http.ResponseWriter, r *http.Request) {
+	items, err := h.DB.OutreachList("")
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	rows := make([]outreachRow, len(items))
+	for i, item := range items {
+		rows[i] = newOutreachRow(r, item, now)
+	}
+	data := map[string]interface{}{
+		"Title":     "Outreach",
+		"Items":     items,
+		"Rows":      rows,
+		"Summary":   SummarizeOutreach(items),
+		"FollowUps": OutreachFollowUps(items, now),
+		"Nudging":   h.outreachNudging,
+	}
+	if err := h.templates.ExecuteTemplate(w, "outreach.html", data); err != nil {
+		h.templateError(w, err)
+	}
+}
+
+// UpdateOutreachStatus saves an outreach row's status, follow-up date, and
+// note, and returns the row. HTMX only swaps successful responses, so other
+// requests with an invalid update get a 422.
+func (h *WebHandler) UpdateOutreachStatus(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+	status, followUp, note := r.FormValue("status"), r.FormValue("follow_up_on"), r.FormValue("note")
+	item, err := UpdateOutreach(h.DB, id, OutreachUpdate{Status: &status, FollowUpOn: &followUp, Note: &note})
+	if errors.Is(err, sql.ErrNoRows) {
+		http.NotFound(w, r)
+		return
+	}
+
+	var row outreachRow
+	if err != nil {
+		current, loadErr := h.DB.OutreachByID(id)
+		if loadErr != nil {
+			log.Printf("Database error: %v", loadErr)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		row = newOutreachRow(r, *current, time.Now())
+		row.Error = err.Error()
+		if r.Header.Get("HX-Request") != "true" {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+		}
+	} else {
+		row = newOutreachRow(r, *item, time.Now())
+	}
+	if err := h.templates.ExecuteTemplate(w, "outreach_row.html", row); err != nil {
+		h.templateError(w, err)
+	}
+}
+
+// callLogView is a school's call log and the form for logging another call
+type callLogView struct {
+	NCESSCH  string
+	Calls    []SchoolCall
+	Role     Role
+	MaxNote  int
+	Note     string // A note that wasn't logged, kept for fixing
+	CalledAt string
+	Error    string
+}
+
+// callLogView loads a school's call log, where a failure only empties the list
+func (h *WebHandler) callLogView(r *http.Request, ncessch string) callLogView {
+	view := callLogView{NCESSCH: ncessch, Role: requestRole(r), MaxNote: maxCallNote}
+	var err error
+	if view.Calls, err = h.DB.SchoolCalls(ncessch); err != nil {
+		log.Printf("Warning: failed to load call log: %v", err)
+	}
+	return view
+}
+
+// LogCall adds a call to a school's call log and returns the updated log. HTMX
+// only swaps successful responses, so other requests with an invalid call get a 422.
+func (h *WebHandler) LogCall(w http.ResponseWriter, r *http.Request) {
+	school, err := h.DB.GetSchoolByID(chi.URLParam(r, "id"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	note, calledAt := r.FormValue("note"), r.FormValue("called_at")
+	_, err = AddSchoolCall(h.DB, school.NCESSCH, calledAt, note, time.Now())
+	view := h.callLogView(r, school.NCESSCH)
+	if err != nil {
+		view.Note, view.CalledAt, view.Error = note, calledAt, err.Error()
+		if r.Header.Get("HX-Request") != "true" {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+		}
+	}
+	if err := h.templates.ExecuteTemplate(w, "call_log.html", view); err != nil {
+		h.templateError(w, err)
+	}
+}
+
+// DeleteCall removes a call from a school's call log and returns the updated log
+func (h *WebHandler) DeleteCall(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "call"), 10, 64)
+	if err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+	if err := h.DB.DeleteSchoolCall(id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.NotFound(w, r)
+			return
+		}
+		log.Printf("Delete call error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.templates.ExecuteTemplate(w, "call_log.html", h.callLogView(r, chi.URLParam(r, "id"))); err != nil {
+		h.templateError(w, err)
+	}
+}
+
+// busView is the bus eligibility estimate on a school's page
+type busView struct {
+	NCESSCH        string
+	Estimate       *BusEstimate
+	Home           *Home
+	HasCoordinates bool
+}
+
+// busView estimates bus eligibility for a school, where a failure only drops
+// the estimate
+func (h *WebHandler) busView(school *School, children []Child) busView {
+	view := busView{NCESSCH: school.NCESSCH}
+	var err error
+	if view.Home, err = h.DB.Home(); err != nil {
+		log.Printf("Warning: failed to load home location: %v", err)
+	}
+	if _, _, view.HasCoordinates, err = h.DB.SchoolCoordinates(school.NCESSCH); err != nil {
+		log.Printf("Warning: failed to load school coordinates: %v", err)
+	}
+	if view.Estimate, err = h.DB.SchoolBusEstimate(school, children); err != nil {
+		log.Printf("Warning: failed to estimate bus eligibility: %v", err)
+	}
+	return view
+}
+
+// SetHome saves the home location from a school's page and returns the
+// school's updated bus estimate
+func (h *WebHandler) SetHome(w http.ResponseWriter, r *http.Request) {
+	school, err := h.DB.GetSchoolByID(chi.URLParam(r, "id"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	if _, err := SaveHome(h.DB, r.FormValue("home"), r.FormValue("label")); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.templates.ExecuteTemplate(w, "bus_estimate.html", h.busView(school, loadChildren(h.DB))); err != nil {
+		h.templateError(w, err)
+	}
+}
+
+// childSave is a child's save button on a school page
+type childSave struct {
+	Child   Child
+	NCESSCH string
+	Saved   bool
+	Serves  bool
+}
+
+// childSaves builds the save buttons for each child on a school's page
+func childSaves(school *School, children []Child) []childSave {
+	saves := make([]childSave, len(children))
+	for i, c := range children {
+		saves[i] = childSave{Child: c, NCESSCH: school.NCESSCH, Saved: c.Saved(school.NCESSCH), Serves: c.Serves(*school)}
+	}
+	return saves
+}
+
+// ToggleChildSchool adds a school to a child's list (saved=1) or removes it,
+// and returns the updated button
+func (h *WebHandler) ToggleChildSchool(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+	ncessch := chi.URLParam(r, "school")
+
+	if r.FormValue("saved") == "1" {
+		err = SaveChildSchool(h.DB, id, ncessch)
+	} else {
+		err = h.DB.RemoveChildSchool(id, ncessch)
+	}
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.NotFound(w, r)
+			return
+		}
+		log.Printf("Child school error: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	child, err := h.DB.GetChild(id)
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	school, err := h.DB.GetSchoolByID(ncessch)
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.templates.ExecuteTemplate(w, "child_save.html", childSaves(school, []Child{*child})[0]); err != nil {
+		h.templateError(w, err)
+	}
+}
+
+// ComparePage renders the side-by-side comparison page for the schools in the compare basket
+func (h *WebHandler) ComparePage(w http.ResponseWriter, r *http.Request) {
+	ids := parseCompareIDs(r.URL.Query().Get("ids"))
+
+	schools, err := h.loadCompareSchools(ids)
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// Bus estimates need a home location and school coordinates, so some or
+	// all schools may have none
+	children := loadChildren(h.DB)
+	busEstimates := make(map[string]*BusEstimate)
+	for _, school := range schools {
+		estimate, err := h.DB.SchoolBusEstimate(school, children)
+		if err != nil {
+			log.Printf("Warning: failed to estimate bus eligibility: %v", err)
+		} else if estimate != nil {
+			busEstimates[school.NCESSCH] = estimate
+		}
+	}
+
+	// Official ratings, side by side as each state published them
+	ratingsPanels := make(map[string]*RatingsPanel)
+	for _, school := range schools {
+		panel, err := h.DB.SchoolRatingsPanel(school.NCESSCH)
+		if err != nil {
+			log.Printf("Warning: failed to load state ratings: %v", err)
+		} else if panel != nil {
+			ratingsPanels[school.NCESSCH] = panel
+		}
+	}
+
+	// Arts programs by discipline, for schools whose websites have been extracted
+	arts, err := h.DB.SchoolArtsCoverage(ids)
+	if err != nil {
+		log.Printf("Warning: failed to load arts programs: %v", err)
+	}
+
+	data := map[string]interface{}{
+		"Title":           "Compare Schools",
+		"BusEstimates":    busEstimates,
+		"RatingsPanels":   ratingsPanels,
+		"Arts":            arts,
+		"ArtsDisciplines": artsDisciplines,
+		"Schools":         schools,
+		"IDs":             strings.Join(ids, ","),
+		"CanCompare":      ValidateCompareCount(len(schools)) == nil,
+		"MaxSchools":      maxCompareSchools,
+		"AIAvailable":     h.AIScraper != nil,
+		"Role":            requestRole(r),
+		"CSRFToken":       csrfToken(w, r),
+	}
+
+	if err := h.templates.ExecuteTemplate(w, "compare.html", data); err != nil {
+		h.templateError(w, err)
+	}
+}
+
+// CharterMatchPage compares a charter school with the traditional public
+// schools most like it, under criteria adjustable from the page's form
+func (h *WebHandler) CharterMatchPage(w http.ResponseWriter, r *http.Request) {
+	criteria, err := matchCriteriaFromQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	match, err := h.DB.MatchCharterSchool(chi.URLParam(r, "id"), criteria)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.NotFound(w, r)
+			return
+		}
+		if userError(err) == nil {
+			// Criteria out of range
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		h.renderUserError(w, r, err, "Matching failed")
+		return
+	}
+	comparison, err := h.DB.CharterMatchComparison(match)
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	data := map[string]interface{}{
+		"Title":      "Matched Comparison: " + match.Charter.Name,
+		"Match":      match,
+		"Comparison": comparison,
+		"SizePct":    criteria.SizeTolerance * 100,
+		"Areas":      matchAreas,
+		"MaxMatches": maxCharterMatches,
+	}
+
+	if err := h.templates.ExecuteTemplate(w, "charter_match.html", data); err != nil {
+		h.templateError(w, err)
+	}
+}
+
+// matchCriteriaFromQuery reads matching criteria from the charter match
+// page's form, defaulting those not given
+func matchCriteriaFromQuery(q url.Values) (MatchCriteria, error) {
+	criteria := DefaultMatchCriteria
+	if area := q.Get("area"); area != "" {
+		criteria.Area = area
+	}
+	criteria.AnyLevel = q.Get("any_level") != ""
+	for _, field := range []struct {
+		name  string
+		value *float64
+		scale float64
+	}{
+		{"size", &criteria.SizeTolerance, 100},
+		{"demographics", &criteria.DemographicTolerance, 1},
+	} {
+		if raw := strings.TrimSpace(q.Get(field.name)); raw != "" {
+			v, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				return criteria, fmt.Errorf("invalid %s %q", field.name, raw)
+			}
+			*field.value = v / field.scale
+		}
+	}
+	if raw := q.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil {
+			return criteria, fmt.Errorf("invalid limit %q", raw)
+		}
+		criteria.Limit = limit
+	}
+	return criteria, criteria.Validate()
+}
+
+// CompareNarrative generates the AI "which is better for us?" narrative and returns its partial
+func (h *WebHandler) CompareNarrative(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	if h.AIScraper == nil {
+		h.renderUserError(w, r, ErrAINotConfigured, "The comparison couldn't be written")
+		return
+	}
+
+	ids := parseCompareIDs(r.FormValue("ids"))
+	schools, err := h.loadCompareSchools(ids)
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if err := ValidateCompareCount(len(schools)); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	inputs := make([]CompareSchoolInput, len(schools))
+	for i, school := range schools {
+		enhancedData, naepData := h.loadCachedEnrichment(school.NCESSCH)
+		inputs[i] = CompareSchoolInput{School: school, Enhanced: enhancedData, NAEP: naepData}
+	}
+
+	narrative, err := h.AIScraper.GenerateComparisonNarrative(r.Context(), inputs, r.FormValue("priorities"))
+	if err != nil {
+		log.Printf("Comparison narrative error: %v", err)
+		h.renderUserError(w, r, err, "The comparison couldn't be written")
+		return
+	}
+
+	data := map[string]interface{}{
+		"Narrative":     narrative,
+		"NarrativeHTML": markdownToHTML(narrative.Narrative),
+	}
+
+	if err := h.templates.ExecuteTemplate(w, "compare_narrative.html", data); err != nil {
+		h.templateError(w, err)
+	}
+}
+
+// parseCompareIDs splits a comma-separated ID list, dropping blanks and duplicates
+func parseCompareIDs(raw string) []string {
+	var ids []string
+	seen := make(map[string]bool)
+	for _, id := range strings.Split(raw, ",") {
+		id = strings.TrimSpace(id)
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	if len(ids) > maxCompareSchools {
+		ids = ids[:maxCompareSchools]
+	}
+	return ids
+}
+
+// loadCompareSchools loads schools for comparison, preserving the basket order
+func (h *WebHandler) loadCompareSchools(ids []string) ([]*School, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	return h.DB.GetSchoolsInOrder(ids)
+}
+
+// loadCachedEnrichment returns any cached AI extraction and NAEP data for a school without fetching
+func (h *WebHandler) loadCachedEnrichment(ncessch string) (*EnhancedSchoolData, *NAEPData) {
+	// Expired entries are still useful context here
+	var enhancedData *EnhancedSchoolData
+	if h.AIScraper != nil {
+		enhancedData, _ = h.AIScraper.loadCachedData(ncessch, cacheNoExpiry)
+	}
+
+	var naepData *NAEPData
+	if h.NAEPClient != nil {
+		naepData, _ = h.NAEPClient.loadCachedData(ncessch, cacheNoExpiry)
+	}
+
+	return enhancedData, naepData
+}
+
+// FetchNAEP handles NAEP data fetching requests and returns NAEP data partial
+func (h *WebHandler) FetchNAEP(w http.ResponseWriter, r *http.Request) {
+	// Serve the last rendered partial while the school's cached data is unchanged
+	if fragment, ok := h.naepFragments.Get(chi.URLParam(r, "id")); ok {
+		w.Header().Set("Content-Type", "text/html")
+		if _, err := w.Write(fragment); err != nil {
+			log.Printf("Warning: failed to write response: %v", err)
+		}
+		return
+	}
+
+	h.renderNAEP(w, r, func(school *School) (*NAEPData, error) {
+		return h.NAEPClient.FetchNAEPData(r.Context(), school)
+	})
+}
+
+// RefreshNAEP re-fetches NAEP data from the API, bypassing the cache
+func (h *WebHandler) RefreshNAEP(w http.ResponseWriter, r *http.Request) {
+	h.renderNAEP(w, r, func(school *School) (*NAEPData, error) {
+		return h.NAEPClient.ForceRefresh(r.Context(), school)
+	})
+}
+
+// renderUserError renders err's user-facing message and hint in place of a partial
+// that failed to load; callers log err itself. HTMX only swaps successful responses,
+// so HTMX requests get a 200 to show the message and others get the error's status.
+// In dev mode the hint also shows err.
+func (h *WebHandler) renderUserError(w http.ResponseWriter, r *http.Request, err error, failed string) {
+	ue := describeError(err, failed)
+	if h.dev {
+		detailed := *ue
+		detailed.Hint = fmt.Sprintf("%s (%v)", ue.Hint, err)
+		ue = &detailed
+	}
+
+	var buf bytes.Buffer
+	if err := h.templates.ExecuteTemplate(&buf, "user_error.html", ue); err != nil {
+		h.templateError(w, err)
+		return
+	}
+
+	status := ue.Status
+	if r.Header.Get("HX-Request") == "true" {
+		status = http.StatusOK
+	}
+	w.Header().Set("Content-Type", "text/html")
+	w.WriteHeader(status)
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		log.Printf("Warning: failed to write response: %v", err)
+	}
+}
+
+// writeUserError renders ue with its own status, even for HTMX requests, so
+// clients and browsers can act on it; a11y.js swaps the message in anyway
+func (h *WebHandler) writeUserError(w http.ResponseWriter, ue *UserError) {
+	var buf bytes.Buffer
+	if err := h.templates.ExecuteTemplate(&buf, "user_error.html", ue); err != nil {
+		h.templateError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html")
+	w.WriteHeader(ue.Status)
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		log.Printf("Warning: failed to write response: %v", err)
+	}
+}
+
+// rateLimited tells a client that's out of AI and import requests when to try again
+func (h *WebHandler) rateLimited(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+	h.writeUserError(w, rateLimitedError(retryAfter))
+}
+
+// denied tells a visitor their role doesn't allow a request. A 401 keeps its
+// status so the browser asks them to sign in.
+func (h *WebHandler) denied(w http.ResponseWriter, r *http.Request, ue *UserError) {
+	h.writeUserError(w, ue)
+}
+
+// renderNAEP loads NAEP data for the school in the URL with fetch and renders the NAEP partial
+func (h *WebHandler) renderNAEP(w http.ResponseWriter, r *http.Request, fetch func(*School) (*NAEPData, error)) {
+	id := chi.URLParam(r, "id")
+
+	school, err := h.DB.GetSchoolByID(id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.NotFound(w, r)
+			return
+		}
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// Check if NAEP client is available
+	if h.NAEPClient == nil {
+		http.Error(w, "NAEP data not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	// Fetch NAEP data
+	naepData, err := fetch(school)
+	if err != nil {
+		log.Printf("NAEP fetch error: %v", err)
+
+		// Territories and systems NAEP doesn't report get an explanation rather than an error
+		if errors.Is(err, errNAEPNotAssessed) {
+			w.Header().Set("Content-Type", "text/html")
+			w.WriteHeader(http.StatusOK)
+			if _, err := w.Write([]byte(`<div class="naep-no-data">
+				<p class="help-text">
+					<strong>Not Assessed by NAEP</strong><br>
+					` + template.HTMLEscapeString(err.Error()) + `
+				</p>
+			</div>`)); err != nil {
+				log.Printf("Warning: failed to write response: %v", err)
+			}
+			return
+		}
+
+		h.renderUserError(w, r, err, "NAEP data couldn't be loaded")
+		return
+	}
+
+	// Convert to view model with pre-calculated data
+	naepView := h.cachedNAEPView(naepData)
+
+	data := map[string]interface{}{
+		"NAEPData": naepView,
+		"School":   school,
+	}
+
+	var buf bytes.Buffer
+	if err := h.templates.ExecuteTemplate(&buf, "naep_data.html", data); err != nil {
+		h.templateError(w, err)
+		return
+	}
+
+	// Stale or refreshing output polls for newer data, so only settled results are reused
+	if !naepData.Stale && !naepData.Refreshing {
+		h.naepFragments.Put(school.NCESSCH, buf.Bytes())
+	}
+
+	w.Header().Set("Content-Type", "text/html")
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		log.Printf("Warning: failed to write response: %v", err)
+	}
+}
+
+// cachedNAEPView returns the view model for data, reusing a previous enrichment when the
+// school's cached NAEP data hasn't changed since
+func (h *WebHandler) cachedNAEPView(data *NAEPData) *NAEPDataView {
+	if data.Stale || data.Refreshing {
+		return h.enrichNAEPData(data)
+	}
+
+	if view, ok := h.naepViews.Get(data.NCESSCH); ok && view.ExtractedAt.Equal(data.ExtractedAt) {
+		return view
+	}
+
+	view := h.enrichNAEPData(data)
+	h.naepViews.Put(data.NCESSCH, view)
+	return view
+}
+
+// SaveNAEPSettings saves a school's NAEP override from the detail page settings form
+func (h *WebHandler) SaveNAEPSettings(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	if h.NAEPClient == nil {
+		http.Error(w, "NAEP data not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	school, err := h.DB.GetSchoolByID(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.NotFound(w, r)
+			return
+		}
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	override := NAEPOverride{
+		NCESSCH:          school.NCESSCH,
+		DisableAutoFetch: r.FormValue("disable_auto_fetch") != "",
+		Jurisdiction:     r.FormValue("jurisdiction"),
+	}
+	if err := ValidateNAEPPin(override.Jurisdiction); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := h.NAEPClient.SetOverride(override); err != nil {
+		log.Printf("NAEP settings error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	data := map[string]interface{}{
+		"School":       school,
+		"NAEPOverride": h.NAEPClient.Override(school.NCESSCH),
+		"Saved":        true,
+	}
+
+	if err := h.templates.ExecuteTemplate(w, "naep_settings.html", data); err != nil {
+		h.templateError(w, err)
+	}
+}
+
+// correctionForm is a submitted corrections or suggestion form, validated field
+// by field so every problem is reported at once
+type correctionForm struct {
+	Fields    []CorrectionField
+	Restore   map[string]bool // Fields to restore to their CCD values
+	Note      string
+	Submitter string
+	NoteError string
+	FormError string
+}
+
+// parseCorrectionForm reads and validates the corrections form for school.
+// Fields being restored or left unchanged aren't validated, so odd CCD values
+// don't block corrections of other fields.
+func parseCorrectionForm(r *http.Request, school *School, maxNote int) *correctionForm {
+	form := &correctionForm{
+		Fields:    school.CorrectionFields(),
+		Restore:   make(map[string]bool),
+		Note:      strings.TrimSpace(r.PostForm.Get("note")),
+		Submitter: strings.TrimSpace(r.PostForm.Get("submitter")),
+	}
+	if len(form.Note) > maxNote {
+		form.NoteError = fmt.Sprintf("Keep the note under %d characters.", maxNote)
+	}
+	if len(form.Submitter) > maxEditTextLength {
+		form.FormError = fmt.Sprintf("Keep your name or email under %d characters.", maxEditTextLength)
+	}
+
+	for i := range form.Fields {
+		f := &form.Fields[i]
+		if r.PostForm.Get("restore_"+f.Field) != "" {
+			form.Restore[f.Field] = true
+			continue
+		}
+		c := SchoolCorrection{NCESSCH: school.NCESSCH, Field: f.Field, Value: r.PostForm.Get(f.Field)}
+		if strings.TrimSpace(c.Value) == f.Value {
+			continue
+		}
+		if err := ValidateCorrection(&c); err != nil {
+			f.Error = err.Error()
+		}
+		f.Value = c.Value
+	}
+	return form
+}
+
+// Valid reports whether the form has no errors
+func (f *correctionForm) Valid() bool {
+	if f.NoteError != "" || f.FormError != "" {
+		return false
+	}
+	for _, field := range f.Fields {
+		if field.Error != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// renderCorrectionForm returns the corrections form with its errors. HTMX only
+// swaps successful responses, so other requests get a 422.
+func (h *WebHandler) renderCorrectionForm(w http.ResponseWriter, r *http.Request, school *School, form *correctionForm) {
+	if r.Header.Get("HX-Request") != "true" {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}
+	data := map[string]interface{}{
+		"School":             school,
+		"SuggestCorrections": h.suggestions && !requestRole(r).CanEdit(),
+		"CorrectionFields":   form.Fields,
+		"CorrectionNote":     form.Note,
+		"Submitter":          form.Submitter,
+		"CorrectionErrors":   true,
+		"NoteError":          form.NoteError,
+		"FormError":          form.FormError,
+	}
+	if err := h.templates.ExecuteTemplate(w, "school_corrections.html", data); err != nil {
+		h.templateError(w, err)
+	}
+}
+
+// SaveCorrections saves the detail page's corrections form. Every field is
+// validated before any is saved, so a bad value leaves the school unchanged.
+func (h *WebHandler) SaveCorrections(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	school, err := h.DB.GetSchoolByID(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.NotFound(w, r)
+			return
+		}
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	form := parseCorrectionForm(r, school, maxEditTextLength)
+	if !form.Valid() {
+		h.renderCorrectionForm(w, r, school, form)
+		return
+	}
+
+	for _, f := range form.Fields {
+		if err := h.DB.updateCorrection(school, f, form.Restore[f.Field], form.Note); err != nil {
+			log.Printf("School correction error: %v", err)
+			h.renderUserError(w, r, err, "Your corrections couldn't be saved")
+			return
+		}
+	}
+
+	// Corrections change the whole page, so reload it
+	if r.Header.Get("HX-Request") == "true" {
+		w.Header().Set("HX-Refresh", "true")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	http.Redirect(w, r, "/schools/"+school.NCESSCH, http.StatusSeeOther)
+}
+
+// SuggestCorrections queues the changed fields of the suggestion form for an
+// admin to review and notifies the admins
+func (h *WebHandler) SuggestCorrections(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	school, err := h.DB.GetSchoolByID(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.NotFound(w, r)
+			return
+		}
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	form := parseCorrectionForm(r, school, maxSuggestionComment)
+	var suggestions []CorrectionSuggestion
+	for _, f := range form.Fields {
+		if current := school.fieldValue(f.Field); f.Value != current {
+			suggestions = append(suggestions, CorrectionSuggestion{
+				NCESSCH:    school.NCESSCH,
+				SchoolName: school.Name,
+				Field:      f.Field,
+				Value:      f.Value,
+				OldValue:   current,
+				Comment:    form.Note,
+				Submitter:  form.Submitter,
+			})
+		}
+	}
+	if len(suggestions) == 0 && form.Valid() {
+		form.FormError = "Change at least one field to suggest a correction."
+	}
+	if !form.Valid() {
+		h.renderCorrectionForm(w, r, school, form)
+		return
+	}
+
+	if err := h.DB.SaveCorrectionSuggestions(suggestions); err != nil {
+		log.Printf("Correction suggestion error: %v", err)
+		h.renderUserError(w, r, err, "Your suggestion couldn't be saved")
+		return
+	}
+
+	// Don't make the submitter wait on a slow webhook or mail server
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		if err := h.notifier.Notify(ctx, suggestions); err != nil {
+			log.Printf("Warning: correction suggestion notification failed: %v", err)
+		}
+	}()
+
+	if r.Header.Get("HX-Request") != "true" {
+		http.Redirect(w, r, "/schools/"+school.NCESSCH, http.StatusSeeOther)
+		return
+	}
+	data := map[string]interface{}{
+		"School":             school,
+		"SuggestCorrections": true,
+		"SuggestionSent":     true,
+	}
+	if err := h.templates.ExecuteTemplate(w, "school_corrections.html", data); err != nil {
+		h.templateError(w, err)
+	}
+}
+
+// CorrectionReviewPage lists suggested corrections waiting for review
+func (h *WebHandler) CorrectionReviewPage(w http.ResponseWriter, r *http.Request) {
+	suggestions, err := h.DB.ListCorrectionSuggestions(suggestionPending)
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	data := map[string]interface{}{
+		"Title":       "Review Corrections",
+		"Suggestions": suggestions,
+	}
+
+	if err := h.templates.ExecuteTemplate(w, "admin_corrections.html", data); err != nil {
+		h.templateError(w, err)
+	}
+}
+
+// ApproveCorrection applies a suggested correction and removes its row
+func (h *WebHandler) ApproveCorrection(w http.ResponseWriter, r *http.Request) {
+	h.reviewCorrection(w, r, true)
+}
+
+// RejectCorrection discards a suggested correction and removes its row
+func (h *WebHandler) RejectCorrection(w http.ResponseWriter, r *http.Request) {
+	h.reviewCorrection(w, r, false)
+}
+
+func (h *WebHandler) reviewCorrection(w http.ResponseWriter, r *http.Request, approve bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.DB.ReviewCorrectionSuggestion(id, approve); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.NotFound(w, r)
+			return
+		}
+		log.Printf("Correction review error: %v", err)
+		h.renderUserError(w, r, err, "The suggestion couldn't be reviewed")
+		return
+	}
+
+	if r.Header.Get("HX-Request") != "true" {
+		http.Redirect(w, r, "/admin/corrections", http.StatusSeeOther)
+		return
+	}
+	// HTMX swaps the row out with this empty response
+	w.WriteHeader(http.StatusOK)
+}
+
+// SignIn asks the browser for credentials, then returns to the page the
+// visitor came from. Browsers send credentials given here with every later
+// request to the server.
+func (h *WebHandler) SignIn(w http.ResponseWriter, r *http.Request) {
+	if !requestSignedIn(r) {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf("Basic realm=%q, charset=\"UTF-8\"", signInRealm))
+		h.writeUserError(w, ErrSignInRequired)
+		return
+	}
+	next := r.URL.Query().Get("next")
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") {
+		next = "/"
+	}
+	http.Redirect(w, r, next, http.StatusSeeOther)
+}
+
+// UsersPage lists web server accounts for admins to manage
+func (h *WebHandler) UsersPage(w http.ResponseWriter, r *http.Request) {
+	h.renderUsersPage(w, r, "", "")
+}
+
+func (h *WebHandler) renderUsersPage(w http.ResponseWriter, r *http.Request, message, formError string) {
+	h.renderUsersPageWithToken(w, r, message, formError, "")
+}
+
+func (h *WebHandler) renderUsersPageWithToken(w http.ResponseWriter, r *http.Request, message, formError, newToken string) {
+	data := map[string]interface{}{
+		"Title":         "Users",
+		"Users":         h.access.users.List(),
+		"Roles":         []Role{RoleViewer, RoleEditor, RoleAdmin},
+		"AdminPassword": h.access.adminPassword != "",
+		"Message":       message,
+		"FormError":     formError,
+		"NewToken":      newToken,
+		"CSRFToken":     csrfToken(w, r),
+	}
+	if formError != "" {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}
+	if err := h.templates.ExecuteTemplate(w, "admin_users.html", data); err != nil {
+		h.templateError(w, err)
+	}
+}
+
+// SaveUser creates an account or changes one's role and password
+func (h *WebHandler) SaveUser(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+	name := strings.TrimSpace(r.PostFormValue("name"))
+	role, err := ParseRole(r.PostFormValue("role"))
+	if err == nil && role != RoleAdmin && h.lockoutRisk(name) {
+		err = fmt.Errorf("%s is the only admin; make another user an admin first", name)
+	}
+	if err == nil {
+		err = h.access.users.Save(name, r.PostFormValue("password"), role)
+	}
+	if err != nil {
+		h.renderUsersPage(w, r, "", err.Error())
+		return
+	}
+	h.renderUsersPage(w, r, fmt.Sprintf("Saved %s as %s.", name, role), "")
+}
+
+// DeleteUser removes an account
+func (h *WebHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	if h.lockoutRisk(name) {
+		h.renderUsersPage(w, r, "", fmt.Sprintf("%s is the only admin; make another user an admin first", name))
+		return
+	}
+	deleted, err := h.access.users.Delete(name)
+	if err != nil {
+		log.Printf("User delete error: %v", err)
+		h.renderUserError(w, r, err, "The user couldn't be deleted")
+		return
+	}
+	if !deleted {
+		http.NotFound(w, r)
+		return
+	}
+	h.renderUsersPage(w, r, fmt.Sprintf("Deleted %s.", name), "")
+}
+
+// IssueUserToken gives an account a new API token for CLI commands and
+// shows it once
+func (h *WebHandler) IssueUserToken(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	token, err := h.access.users.IssueToken(name)
+	if err != nil {
+		h.renderUsersPage(w, r, "", err.Error())
+		return
+	}
+	h.renderUsersPageWithToken(w, r, fmt.Sprintf("Issued a new API token for %s.", name), "", token)
+}
+
+// lockoutRisk reports whether demoting or deleting name would leave no way
+// to sign in as an admin
+func (h *WebHandler) lockoutRisk(name string) bool {
+	return h.access.adminPassword == "" && h.access.users.IsLastAdmin(name)
+}
+
+// CachePage shows what the AI and NAEP caches hold for admins to clear
+func (h *WebHandler) CachePage(w http.ResponseWriter, r *http.Request) {
+	h.renderCachePage(w, r, "")
+}
+
+func (h *WebHandler) renderCachePage(w http.ResponseWriter, r *http.Request, message string) {
+	sizes, err := h.DB.CacheSizes()
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	data := map[string]interface{}{
+		"Title":     "Caches",
+		"Caches":    sizes,
+		"Message":   message,
+		"CSRFToken": csrfToken(w, r),
+	}
+	if err := h.templates.ExecuteTemplate(w, "admin_cache.html", data); err != nil {
+		h.templateError(w, err)
+	}
+}
+
+// ClearCache empties one of the managed caches
+func (h *WebHandler) ClearCache(w http.ResponseWriter, r *http.Request) {
+	table := chi.URLParam(r, "table")
+	c, ok := findManagedCache(table)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	n, err := h.DB.ClearCache(table)
+	if err != nil {
+		log.Printf("Cache clear error: %v", err)
+		h.renderUserError(w, r, err, "The cache couldn't be cleared")
+		return
+	}
+	h.renderCachePage(w, r, fmt.Sprintf("Cleared %s (%s entries).", strings.ToLower(c.Label), formatNumber(n)))
+}
+
+// DuplicatesPage reports likely duplicate school records, optionally in one
+// state, and the records already merged
+func (h *WebHandler) DuplicatesPage(w http.ResponseWriter, r *http.Request) {
+	state := strings.ToUpper(strings.TrimSpace(r.URL.Query().Get("state")))
+
+	candidates, err := h.DB.FindDuplicateSchools(state)
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	merges, err := h.DB.ListSchoolMerges("")
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	data := map[string]interface{}{
+		"Title":         "Duplicate Schools",
+		"State":         state,
+		"Candidates":    candidates,
+		"MaxCandidates": maxDuplicateCandidates,
+		"Merges":        merges,
+	}
+
+	if err := h.templates.ExecuteTemplate(w, "duplicates.html", data); err != nil {
+		h.templateError(w, err)
+	}
+}
+
+// LanguagesPage renders the language directory: the schools teaching each
+// language, immersion programs unless courses=1
+func (h *WebHandler) LanguagesPage(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	query := LanguageDirectoryQuery{
+		State:   strings.ToUpper(strings.TrimSpace(q.Get("state"))),
+		Level:   q.Get("level"),
+		Courses: q.Get("courses") != "",
+	}
+	if language := strings.TrimSpace(q.Get("language")); language != "" {
+		query.Language = languageFilterName(language)
+	}
+
+	directory, err := h.DB.LanguageDirectory(query)
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	data := map[string]interface{}{
+		"Title":      "Language Immersion Directory",
+		"Directory":  directory,
+		"MaxSchools": maxLanguageDirectorySchools,
+	}
+
+	if err := h.templates.ExecuteTemplate(w, "languages.html", data); err != nil {
+		h.templateError(w, err)
+	}
+}
+
+// MergeDuplicate merges the "duplicate" school into the "canonical" one
+func (h *WebHandler) MergeDuplicate(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	err := h.DB.MergeSchools(r.PostForm.Get("duplicate"), r.PostForm.Get("canonical"), strings.TrimSpace(r.PostForm.Get("note")))
+	if err != nil {
+		log.Printf("School merge error: %v", err)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	h.redirectToDuplicates(w, r)
+}
+
+// UnmergeDuplicate makes a merged duplicate a separate school again
+func (h *WebHandler) UnmergeDuplicate(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.DB.UnmergeSchool(r.PostForm.Get("duplicate")); err != nil {
+		log.Printf("School unmerge error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	h.redirectToDuplicates(w, r)
+}
+
+// DismissDuplicate marks a flagged pair as different schools
+func (h *WebHandler) DismissDuplicate(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.DB.MarkSchoolsDistinct(r.PostForm.Get("a"), r.PostForm.Get("b")); err != nil {
+		log.Printf("Dismiss duplicate error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	h.redirectToDuplicates(w, r)
+}
+
+// redirectToDuplicates returns to the duplicate report, keeping its state filter
+func (h *WebHandler) redirectToDuplicates(w http.ResponseWriter, r *http.Request) {
+	target := "/duplicates"
+	if state := r.PostForm.Get("state"); state != "" {
+		target += "?state=" + url.QueryEscape(state)
+	}
+	http.Redirect(w, r, target, http.StatusSeeOther)
+}
+
+// enrichNAEPData converts NAEPData to NAEPDataView with pre-calculated achievement levels
+func (h *WebHandler) enrichNAEPData(data *NAEPData) *NAEPDataView {
+	useDistrict := len(data.DistrictScores) > 0
+
+	view := &NAEPDataView{
+		NCESSCH:       data.NCESSCH,
+		State:         data.State,
+		District:      data.District,
+		ExtractedAt:   data.ExtractedAt,
+		UseDistrict:   useDistrict,
+		NationalByKey: make(map[string]*NAEPScoreView),
+		Stale:         data.Stale,
+		Refreshing:    data.Refreshing,
+		CacheTTLDays:  int(defaultNAEPCacheTTL.Hours() / 24),
+	}
+	if h.NAEPClient != nil {
+		view.CacheTTLDays = int(h.NAEPClient.cacheTTL.Hours() / 24)
+	}
+
+	// First, enrich national scores and create lookup map
+	for _, score := range data.NationalScores {
+		enrichedScore := h.enrichScore(score, data, false)
+		view.NationalScores = append(view.NationalScores, enrichedScore)
+		key := score.Subject + "-" + fmt.Sprintf("%d", score.Grade)
+		view.NationalByKey[key] = &enrichedScore
+	}
+
+	// Enrich state scores with national comparison
+	for _, score := range data.StateScores {
+		enrichedScore := h.enrichScore(score, data, false)
+		h.addNationalComparison(&enrichedScore, view.NationalByKey)
+		view.StateScores = append(view.StateScores, enrichedScore)
+	}
+
+	// Enrich district scores with national comparison
+	for _, score := range data.DistrictScores {
+		enrichedScore := h.enrichScore(score, data, true)
+		h.addNationalComparison(&enrichedScore, view.NationalByKey)
+		view.DistrictScores = append(view.DistrictScores, enrichedScore)
+	}
+
+	// Group scores by grade for easier template iteration
+	primaryScores := view.StateScores
+	if useDistrict {
+		primaryScores = view.DistrictScores
+	}
+
+	for _, score := range primaryScores {
+		switch score.Grade {
+		case 4:
+			view.Grade4Scores = append(view.Grade4Scores, score)
+		case 8:
+			view.Grade8Scores = append(view.Grade8Scores, score)
+		}
+	}
+
+	return view
+}
+
+// addNationalComparison adds national comparison data to a score
+func (h *WebHandler) addNationalComparison(score *NAEPScoreView, nationalByKey map[string]*NAEPScoreView) {
+	key := score.Subject + "-" + fmt.Sprintf("%d", score.Grade)
+	if national, ok := nationalByKey[key]; ok {
+		score.NationalScore = national
+		if score.AtProficient >= national.AtProficient {
+			score.NationalCompare = "Above"
+		} else {
+			score.NationalCompare = "Below"
+		}
+	}
+}
+
+// enrichScore adds achievement level percentages to a score
+func (h *WebHandler) enrichScore(score NAEPScore, data *NAEPData, useDistrict bool) NAEPScoreView {
+	belowBasic, basic, proficient, advanced := data.GetAchievementLevels(score.Subject, score.Grade, useDistrict)
+
+	return NAEPScoreView{
+		NAEPScore:     score,
+		BelowBasicPct: belowBasic,
+		BasicPct:      basic,
+		ProficientPct: proficient,
+		AdvancedPct:   advanced,
+	}
+}
+
+// NAEPRawResponse serves the most recent archived NAEP API response for a source URL
+func (h *WebHandler) NAEPRawResponse(w http.ResponseWriter, r *http.Request) {
+	sourceURL := r.URL.Query().Get("url")
+	if sourceURL == "" {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	responses, err := h.DB.ListNAEPRawResponses(sourceURL, 1)
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if len(responses) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	raw := responses[0]
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-NAEP-Fetched-At", raw.FetchedAt.Format(time.RFC3339))
+	w.Header().Set("X-NAEP-HTTP-Status", strconv.Itoa(raw.HTTPStatus))
+	if _, err := w.Write([]byte(raw.Body)); err != nil {
+		log.Printf("Warning: failed to write response: %v", err)
+	}
+}
+
+// AlertsPage lists NAEP decline alerts across all schools
+func (h *WebHandler) AlertsPage(w http.ResponseWriter, r *http.Request) {
+	showDismissed := r.URL.Query().Get("all") == "1"
+
+	alerts, err := h.DB.ListNAEPAlerts("", showDismissed)
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	threshold := defaultNAEPAlertThreshold
+	if h.NAEPClient != nil {
+		threshold = h.NAEPClient.alertThreshold
+	}
+
+	data := map[string]interface{}{
+		"Title":         "NAEP Alerts",
+		"Alerts":        alerts,
+		"ShowDismissed": showDismissed,
+		"Threshold":     threshold,
+	}
+
+	if err := h.templates.ExecuteTemplate(w, "alerts.html", data); err != nil {
+		h.templateError(w, err)
+	}
+}
+
+// StatsPage renders the statewide and national statistics dashboard
+func (h *WebHandler) StatsPage(w http.ResponseWriter, r *http.Request) {
+	overview, err := h.DB.StatsOverview()
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	heatmap, err := h.choroplethView(DefaultChoroplethQuery, overview)
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	data := map[string]interface{}{
+		"Title":    "Statistics",
+		"Overview": overview,
+		"Heatmap":  heatmap,
+	}
+
+	if err := h.templates.ExecuteTemplate(w, "stats.html", data); err != nil {
+		h.templateError(w, err)
+	}
+}
+
+// choroplethView is the data for the heatmap on the stats page
+type choroplethView struct {
+	*Choropleth
+	Map      ChoroplethMap
+	Metrics  []choroplethMetric
+	States   []OverviewStat
+	Subjects []string
+	Grades   []int
+}
+
+// choroplethView computes a heatmap and the options its form offers. The
+// state picker defaults to the state with the most schools.
+func (h *WebHandler) choroplethView(q ChoroplethQuery, overview *StatsOverview) (choroplethView, error) {
+	if q.State == "" && len(overview.States) > 0 {
+		q.State = overview.States[0].Key
+	}
+	c, err := h.DB.Choropleth(q)
+	if err != nil {
+		return choroplethView{}, err
+	}
+	states := slices.Clone(overview.States)
+	sort.Slice(states, func(i, j int) bool { return states[i].Name < states[j].Name })
+	return choroplethView{
+		Choropleth: c,
+		Map:        c.Map(),
+		Metrics:    choroplethMetrics,
+		States:     states,
+		Subjects:   []string{"mathematics", "reading", "science"},
+		Grades:     naepGradesTested,
+	}, nil
+}
+
+// choroplethQueryFromQuery reads a heatmap's metric, level, state, and NAEP
+// subject and grade from a query string, defaulting any that are missing
+func choroplethQueryFromQuery(q url.Values) (ChoroplethQuery, error) {
+	query := DefaultChoroplethQuery
+	for _, field := range []struct {
+		name  string
+		value *string
+	}{
+		{"metric", &query.Metric},
+		{"level", &query.Level},
+		{"state", &query.State},
+		{"subject", &query.Subject},
+	} {
+		if v := strings.TrimSpace(q.Get(field.name)); v != "" {
+			*field.value = v
+		}
 	}
-
-	if err := h.templates.ExecuteTemplate(w, "detail.html", data); err != nil {
-		log.Printf("Template error: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	query.State = strings.ToUpper(query.State)
+	if raw := q.Get("grade"); raw != "" {
+		grade, err := strconv.Atoi(raw)
+		if err != nil {
+			return query, fmt.Errorf("invalid grade %q", raw)
+		}
+		query.Grade = grade
 	}
+	return query, query.Validate()
 }
 
-// ExtractAI handles AI extraction requests and returns AI data partial
-func (h *WebHandler) ExtractAI(w http.ResponseWriter, r *http.Request) {
-	id := chi.URLParam(r, "id")
-
-	school, err := h.DB.GetSchoolByID(id)
+// StatsChoropleth renders the stats page heatmap for the metric and areas in
+// the query, when its form changes
+func (h *WebHandler) StatsChoropleth(w http.ResponseWriter, r *http.Request) {
+	q, err := choroplethQueryFromQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	overview, err := h.DB.StatsOverview()
 	if err != nil {
-		if err == sql.ErrNoRows {
-			http.NotFound(w, r)
-			return
-		}
 		log.Printf("Database error: %v", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
-
-	// Check if AI scraper is available
-	if h.AIScraper == nil {
-		http.Error(w, "AI extraction not available: ANTHROPIC_API_KEY not set", http.StatusServiceUnavailable)
+	heatmap, err := h.choroplethView(q, overview)
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	// Extract AI data using ScrapeSchoolWebsite which properly fills metadata and caches
-	enhancedData, err := h.AIScraper.ScrapeSchoolWebsite(r.Context(), school)
+	if err := h.templates.ExecuteTemplate(w, "choropleth.html", heatmap); err != nil {
+		h.templateError(w, err)
+	}
+}
+
+// SchemaPage renders the data dictionary: every table and column with its
+// description
+func (h *WebHandler) SchemaPage(w http.ResponseWriter, r *http.Request) {
+	tables, err := h.DB.DataDictionary("")
 	if err != nil {
-		log.Printf("AI extraction error: %v", err)
-		http.Error(w, "AI extraction failed: "+err.Error(), http.StatusInternalServerError)
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
 	data := map[string]interface{}{
-		"EnhancedData": enhancedData,
-		"School":       school,
+		"Title":  "Data Dictionary",
+		"Tables": tables,
 	}
 
-	if err := h.templates.ExecuteTemplate(w, "ai_data.html", data); err != nil {
-		log.Printf("Template error: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	if err := h.templates.ExecuteTemplate(w, "schema.html", data); err != nil {
+		h.templateError(w, err)
 	}
 }
 
-// FetchNAEP handles NAEP data fetching requests and returns NAEP data partial
-func (h *WebHandler) FetchNAEP(w http.ResponseWriter, r *http.Request) {
-	id := chi.URLParam(r, "id")
-
-	school, err := h.DB.GetSchoolByID(id)
+// DismissAlert marks a NAEP alert as reviewed and removes its row
+func (h *WebHandler) DismissAlert(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			http.NotFound(w, r)
-			return
-		}
-		log.Printf("Database error: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		http.Error(w, "Bad request", http.StatusBadRequest)
 		return
 	}
 
-	// Check if NAEP client is available
-	if h.NAEPClient == nil {
-		http.Error(w, "NAEP data not available", http.StatusServiceUnavailable)
+	if err := h.DB.DismissNAEPAlert(id); err != nil {
+		log.Printf("Dismiss alert error: %v", err)
+		http.NotFound(w, r)
 		return
 	}
 
-	// Fetch NAEP data
-	naepData, err := h.NAEPClient.FetchNAEPData(school)
-	if err != nil {
-		log.Printf("NAEP fetch error: %v", err)
+	// HTMX swaps the row out with this empty response
+	w.WriteHeader(http.StatusOK)
+}
 
-		// Check if this is a "no data available" error vs a real server error
-		errMsg := err.Error()
-		if strings.Contains(errMsg, "no NAEP data available") ||
-			strings.Contains(errMsg, "no NAEP grades applicable") {
-			// Return 200 with content indicating no data available
-			w.Header().Set("Content-Type", "text/html")
-			w.WriteHeader(http.StatusOK)
-			if _, err := w.Write([]byte(`<div class="naep-no-data">
-				<p class="help-text error-message">
-					<strong>No NAEP Data Available</strong><br>
-					Assessment data is not available for this school.
-				</p>
-			</div>`)); err != nil {
-				log.Printf("Warning: failed to write response: %v", err)
-			}
-			return
-		}
+// SavedSearchesPage lists saved searches and recent changes to subscribed ones
+func (h *WebHandler) SavedSearchesPage(w http.ResponseWriter, r *http.Request) {
+	h.renderSavedSearches(w, "saved_searches.html", "")
+}
 
-		// Real server error
-		http.Error(w, "NAEP data fetch failed: "+err.Error(), http.StatusInternalServerError)
+// renderSavedSearches renders tmpl with every saved search and recent changes
+func (h *WebHandler) renderSavedSearches(w http.ResponseWriter, tmpl, message string) {
+	searches, err := h.DB.ListSavedSearches()
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	// Convert to view model with pre-calculated data
-	naepView := h.enrichNAEPData(naepData)
+	changes, err := h.DB.ListSavedSearchChanges(maxSavedSearchChanges)
+	if err != nil {
+		log.Printf("Warning: failed to load saved search changes: %v", err)
+	}
 
 	data := map[string]interface{}{
-		"NAEPData": naepView,
-		"School":   school,
+		"Title":    "Saved Searches",
+		"Searches": searches,
+		"Changes":  changes,
+		"Message":  message,
 	}
 
-	if err := h.templates.ExecuteTemplate(w, "naep_data.html", data); err != nil {
-		log.Printf("Template error: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	if err := h.templates.ExecuteTemplate(w, tmpl, data); err != nil {
+		h.templateError(w, err)
 	}
 }
 
-// enrichNAEPData converts NAEPData to NAEPDataView with pre-calculated achievement levels
-func (h *WebHandler) enrichNAEPData(data *NAEPData) *NAEPDataView {
-	useDistrict := len(data.DistrictScores) > 0
-
-	view := &NAEPDataView{
-		NCESSCH:       data.NCESSCH,
-		State:         data.State,
-		District:      data.District,
-		ExtractedAt:   data.ExtractedAt,
-		UseDistrict:   useDistrict,
-		NationalByKey: make(map[string]*NAEPScoreView),
+// SaveSearch saves the submitted filters under a name and confirms inline
+func (h *WebHandler) SaveSearch(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
 	}
 
-	// First, enrich national scores and create lookup map
-	for _, score := range data.NationalScores {
-		enrichedScore := h.enrichScore(score, data, false)
-		view.NationalScores = append(view.NationalScores, enrichedScore)
-		key := score.Subject + "-" + fmt.Sprintf("%d", score.Grade)
-		view.NationalByKey[key] = &enrichedScore
+	filters, err := SearchFiltersFromValues(r.Form)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
-	// Enrich state scores with national comparison
-	for _, score := range data.StateScores {
-		enrichedScore := h.enrichScore(score, data, false)
-		h.addNationalComparison(&enrichedScore, view.NationalByKey)
-		view.StateScores = append(view.StateScores, enrichedScore)
+	search, err := SaveSearch(h.DB, r.FormValue("name"), filters, r.FormValue("subscribed") != "")
+	if err != nil {
+		log.Printf("Save search error: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
-	// Enrich district scores with national comparison
-	for _, score := range data.DistrictScores {
-		enrichedScore := h.enrichScore(score, data, true)
-		h.addNationalComparison(&enrichedScore, view.NationalByKey)
-		view.DistrictScores = append(view.DistrictScores, enrichedScore)
-	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(w, `<p class="save-search-status">Saved as <a href="/saved-searches">%s</a></p>`, template.HTMLEscapeString(search.Name))
+}
 
-	// Group scores by grade for easier template iteration
-	primaryScores := view.StateScores
-	if useDistrict {
-		primaryScores = view.DistrictScores
+// ToggleSavedSearch turns change alerts for a saved search on or off
+func (h *WebHandler) ToggleSavedSearch(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
 	}
 
-	for _, score := range primaryScores {
-		switch score.Grade {
-		case 4:
-			view.Grade4Scores = append(view.Grade4Scores, score)
-		case 8:
-			view.Grade8Scores = append(view.Grade8Scores, score)
+	if _, err := SetSubscribed(h.DB, id, r.FormValue("subscribed") == "1"); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.NotFound(w, r)
+			return
 		}
+		log.Printf("Saved search error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
 	}
 
-	return view
+	h.renderSavedSearches(w, "saved_search_list.html", "")
 }
 
-// addNationalComparison adds national comparison data to a score
-func (h *WebHandler) addNationalComparison(score *NAEPScoreView, nationalByKey map[string]*NAEPScoreView) {
-	key := score.Subject + "-" + fmt.Sprintf("%d", score.Grade)
-	if national, ok := nationalByKey[key]; ok {
-		score.NationalScore = national
-		if score.AtProficient >= national.AtProficient {
-			score.NationalCompare = "Above"
-		} else {
-			score.NationalCompare = "Below"
-		}
+// DeleteSavedSearch removes a saved search and its row
+func (h *WebHandler) DeleteSavedSearch(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
 	}
+
+	if err := h.DB.DeleteSavedSearch(id); err != nil {
+		log.Printf("Delete saved search error: %v", err)
+		http.NotFound(w, r)
+		return
+	}
+
+	// HTMX swaps the row out with this empty response
+	w.WriteHeader(http.StatusOK)
 }
 
-// enrichScore adds achievement level percentages to a score
-func (h *WebHandler) enrichScore(score NAEPScore, data *NAEPData, useDistrict bool) NAEPScoreView {
-	belowBasic, basic, proficient, advanced := data.GetAchievementLevels(score.Subject, score.Grade, useDistrict)
+// CheckSavedSearches re-runs subscribed searches now rather than waiting for the next start
+func (h *WebHandler) CheckSavedSearches(w http.ResponseWriter, r *http.Request) {
+	changes, err := CheckSavedSearches(h.DB)
+	if err != nil {
+		log.Printf("Saved search check error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
 
-	return NAEPScoreView{
-		NAEPScore:     score,
-		BelowBasicPct: belowBasic,
-		BasicPct:      basic,
-		ProficientPct: proficient,
-		AdvancedPct:   advanced,
+	message := "No changes since the last check."
+	switch {
+	case len(changes) == 1:
+		message = "1 saved search changed."
+	case len(changes) > 1:
+		message = fmt.Sprintf("%d saved searches changed.", len(changes))
 	}
+	h.renderSavedSearches(w, "saved_search_list.html", message)
 }
 
 // AgentPage renders the AI agent page
@@ -412,11 +3437,14 @@ func (h *WebHandler) AgentPage(w http.ResponseWriter, r *http.Request) {
 		"Title":       "AI Agent",
 		"Query":       r.URL.Query().Get("q"),
 		"AIAvailable": h.AIScraper != nil,
+		"CSRFToken":   csrfToken(w, r),
+	}
+	if h.demo {
+		data["DemoQuestions"] = demoQuestions
 	}
 
 	if err := h.templates.ExecuteTemplate(w, "agent.html", data); err != nil {
-		log.Printf("Template error: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		h.templateError(w, err)
 	}
 }
 
@@ -428,6 +3456,7 @@ type AgentQueryResponse struct {
 	SQLQuery     string                   // The SQL query executed
 	TableData    []map[string]interface{} // Raw query results as table
 	TableColumns []string                 // Column names for table display
+	Chart        *AgentChart              // Chart of aggregated results, if any
 	Schools      []*School
 	TotalCount   int
 	Page         int
@@ -438,7 +3467,8 @@ type AgentQueryResponse struct {
 	PrevPage     int
 	NextPage     int
 	SchoolIDs    string
-	Error        string
+	ExportID     string // Set when the results can be downloaded as CSV
+	Error        *UserError
 }
 
 // AgentQuery handles AI agent queries
@@ -458,30 +3488,34 @@ func (h *WebHandler) AgentQuery(w http.ResponseWriter, r *http.Request) {
 	if h.AIScraper == nil {
 		data := AgentQueryResponse{
 			Query: query,
-			Error: "AI Agent requires ANTHROPIC_API_KEY to be set",
+			Error: ErrAINotConfigured,
 		}
 		if err := h.templates.ExecuteTemplate(w, "agent_response.html", data); err != nil {
-			log.Printf("Template error: %v", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			h.templateError(w, err)
 		}
 		return
 	}
 
 	// Use Claude to interpret the query and generate a SQL search
+	h.DB.RecordUsage(usageAgentQuery)
 	result, err := h.queryWithAI(r.Context(), query)
 	if err != nil {
 		log.Printf("AI query error: %v", err)
 		data := AgentQueryResponse{
 			Query: query,
-			Error: fmt.Sprintf("Failed to process query: %v", err),
+			Error: describeError(err, "Your question couldn't be answered"),
 		}
 		if err := h.templates.ExecuteTemplate(w, "agent_response.html", data); err != nil {
-			log.Printf("Template error: %v", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			h.templateError(w, err)
 		}
 		return
 	}
 
+	// The agent picks a chart in a fenced block that isn't part of the answer text
+	chartHint, responseText := extractChartHint(result.ResponseText)
+	result.ResponseText = responseText
+	chart := buildAgentChart(chartHint, result.TableColumns, result.TableData)
+
 	// Fetch the schools by IDs (if the query returned school IDs)
 	var schools []*School
 	if len(result.SchoolIDs) > 0 {
@@ -495,11 +3529,10 @@ func (h *WebHandler) AgentQuery(w http.ResponseWriter, r *http.Request) {
 				SQLQuery:     result.SQLQuery,
 				TableData:    result.TableData,
 				TableColumns: result.TableColumns,
-				Error:        "Failed to fetch school details",
+				Error:        describeError(err, "The matching schools couldn't be loaded"),
 			}
 			if err := h.templates.ExecuteTemplate(w, "agent_response.html", data); err != nil {
-				log.Printf("Template error: %v", err)
-				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				h.templateError(w, err)
 			}
 			return
 		}
@@ -527,6 +3560,16 @@ func (h *WebHandler) AgentQuery(w http.ResponseWriter, r *http.Request) {
 	// Convert school IDs to comma-separated string for pagination
 	schoolIDsStr := strings.Join(result.SchoolIDs, ",")
 
+	// Keep the SQL server-side so the full results can be downloaded as CSV
+	var exportID string
+	if result.SQLQuery != "" {
+		if exportID, err = newAgentExportID(); err != nil {
+			log.Printf("Warning: %v", err)
+		} else {
+			h.agentExports.Put(exportID, agentExport{Query: query, SQL: result.SQLQuery})
+		}
+	}
+
 	data := AgentQueryResponse{
 		Query:        query,
 		ResponseText: result.ResponseText,
@@ -534,6 +3577,7 @@ func (h *WebHandler) AgentQuery(w http.ResponseWriter, r *http.Request) {
 		SQLQuery:     result.SQLQuery,
 		TableData:    result.TableData,
 		TableColumns: result.TableColumns,
+		Chart:        chart,
 		Schools:      paginatedSchools,
 		TotalCount:   totalCount,
 		Page:         page,
@@ -544,11 +3588,35 @@ func (h *WebHandler) AgentQuery(w http.ResponseWriter, r *http.Request) {
 		PrevPage:     page - 1,
 		NextPage:     page + 1,
 		SchoolIDs:    schoolIDsStr,
+		ExportID:     exportID,
 	}
 
 	if err := h.templates.ExecuteTemplate(w, "agent_response.html", data); err != nil {
-		log.Printf("Template error: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		h.templateError(w, err)
+	}
+}
+
+// AgentExportCSV re-runs the SQL behind a data explorer answer, with the same
+// read-only checks as the first run, and streams the full result set as a CSV
+// download
+func (h *WebHandler) AgentExportCSV(w http.ResponseWriter, r *http.Request) {
+	export, ok := h.agentExports.Get(chi.URLParam(r, "id"))
+	if !ok {
+		http.Error(w, "This download has expired. Ask the question again to download its results.", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", export.Filename()))
+
+	count, err := h.DB.WriteReadQueryCSV(r.Context(), w, export.SQL)
+	if err != nil {
+		log.Printf("CSV export error after %d rows: %v", count, err)
+		if count == 0 {
+			// Nothing has been sent yet, so report the failure instead of a partial file
+			w.Header().Del("Content-Disposition")
+			http.Error(w, "Failed to export results", http.StatusInternalServerError)
+		}
 	}
 }
 
@@ -582,11 +3650,10 @@ func (h *WebHandler) AgentPaginate(w http.ResponseWriter, r *http.Request) {
 		log.Printf("Database error: %v", err)
 		data := AgentQueryResponse{
 			Query: query,
-			Error: "Failed to fetch schools",
+			Error: describeError(err, "The matching schools couldn't be loaded"),
 		}
 		if err := h.templates.ExecuteTemplate(w, "agent_response.html", data); err != nil {
-			log.Printf("Template error: %v", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			h.templateError(w, err)
 		}
 		return
 	}
@@ -626,8 +3693,7 @@ func (h *WebHandler) AgentPaginate(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := h.templates.ExecuteTemplate(w, "agent_response.html", data); err != nil {
-		log.Printf("Template error: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		h.templateError(w, err)
 	}
 }
 
@@ -635,9 +3701,9 @@ func (h *WebHandler) AgentPaginate(w http.ResponseWriter, r *http.Request) {
 // The agent has built-in retry logic and will self-correct failed SQL queries
 func (h *WebHandler) queryWithAI(ctx context.Context, query string) (*AIQueryResult, error) {
 	// Get API key from environment
-	apiKey := os.Getenv("ANTHROPIC_API_KEY")
+	apiKey := anthropicAPIKey()
 	if apiKey == "" {
-		return nil, fmt.Errorf("ANTHROPIC_API_KEY not set")
+		return nil, ErrAINotConfigured
 	}
 
 	// Create Anthropic provider for Fantasy
@@ -660,24 +3726,41 @@ Answer the user's question by querying the database using the 'query' tool. The
 
 **Available Tools:**
 - 'query': Execute SQL queries against the DuckDB database (returns a summary of results)
-- 'schema': Get database schema information for ALL tables including user-imported data
+- 'schema': Get every table, including user-imported data, with a description of each column; check it for code values (e.g. GSLO grade codes) before filtering on them
 
 **Core Database Schema:**
 - **directory**: School information (NCESSCH, SCH_NAME, ST, STATENAME, MCITY, LEA_NAME, SCH_TYPE_TEXT, LEVEL, GSLO, GSHI, CHARTER_TEXT, PHONE, WEBSITE, MSTREET1, MZIP, SCHOOL_YEAR)
 - **enrollment**: Student counts (NCESSCH, STUDENT_COUNT, TOTAL_INDICATOR - use = 'Education Unit Total' for totals)
 - **teachers**: Teacher FTE counts (NCESSCH, TEACHERS)
+- **enrollment_projections**: Next year's projected enrollment per school (ncessch, school_year, projected, low, high, change as a fraction, e.g. -0.05 for -5%)
+- **district_enrollment_projections**: The same per district (leaid matches directory.LEAID); e.g. districts projected to shrink more than 5%: "SELECT DISTINCT d.LEA_NAME, d.ST, p.change FROM district_enrollment_projections p JOIN directory d ON d.LEAID = p.leaid WHERE p.change < -0.05 ORDER BY p.change"
+- **school_percentiles**: Where each school stands among same-level schools (metric: enrollment, teachers, or ratio; state_pct and national_pct are 0-100, the percent of peers with a smaller value; state_peers and national_peers count them)
+- **school_languages**: Languages taught, from school websites (ncessch, language as a canonical name such as Spanish, Mandarin, or French, immersion true for immersion/dual-language programs and false for world-language courses, grades as published); e.g. Mandarin immersion elementary schools in WA: "SELECT d.NCESSCH, d.SCH_NAME, d.MCITY, l.grades FROM school_languages l JOIN directory d ON d.NCESSCH = l.ncessch WHERE l.language = 'Mandarin' AND l.immersion AND d.LEVEL = 'Elementary' AND d.ST = 'WA'". Only schools whose websites have been extracted are listed, so say so when counting
+- **data_quality_flags**: Anomalies found in CCD records (ncessch; flag: zero_enrollment, extreme_ratio, missing_website, or grade_range; field: enrollment, ratio, website, or grades; detail with the values)
+- **school_counties**: Each school's county (ncessch, county as the 5-digit FIPS code, county_name such as 'Cook County'); use it for counties instead of guessing their cities. County names repeat across states, so filter on the state too, e.g. schools in Cook County, IL: "SELECT d.NCESSCH, d.SCH_NAME, d.MCITY FROM school_counties c JOIN directory d ON d.NCESSCH = c.ncessch WHERE c.county_name = 'Cook County' AND d.ST = 'IL' LIMIT 200"
+- **school_congressional_districts**: Each school's congressional district (ncessch, cd as 4 digits: the state FIPS code then the district number, 00 for at-large); "IL-07" is d.ST = 'IL' AND right(cd, 2) = '07'
+- **overview_stats**: Precomputed totals to cite for counts and shares (section: national, state, level, or district; key; name; schools; charter_schools; students; ratio as pooled students per teacher; rank within the section, districts by students); e.g. charter share by state: "SELECT key, charter_schools * 100.0 / schools AS charter_pct FROM overview_stats WHERE section = 'state' ORDER BY charter_pct DESC"
 
 **User-Imported Tables:**
 - Users can import custom CSV datasets which appear as additional tables
 - Use the 'schema' tool to discover all available tables and their columns
-- Table and column comments provide important context about user-imported data
+- Column descriptions give the meaning and allowed values of each column, including user-imported data
 
 **Query Strategy:**
 1. For SEARCH queries (finding specific schools): Return SQL that selects NCESSCH IDs and relevant school info
    Example: "SELECT d.NCESSCH, d.SCH_NAME, d.MCITY, d.ST FROM directory d WHERE d.ST = 'CA' AND d.LEVEL = 'High' LIMIT 200"
 
 2. For ANALYSIS queries (statistics/aggregations): Return SQL with aggregated results
-   Example: "SELECT d.ST, AVG(e.STUDENT_COUNT) as avg_enrollment FROM directory d LEFT JOIN enrollment e ON d.NCESSCH = e.NCESSCH WHERE e.TOTAL_INDICATOR = 'Education Unit Total' GROUP BY d.ST ORDER BY avg_enrollment DESC"
+   Example: "SELECT d.ST, MEDIAN(TRY_CAST(e.STUDENT_COUNT AS DOUBLE)) as median_enrollment FROM directory d LEFT JOIN enrollment e ON d.NCESSCH = e.NCESSCH WHERE e.TOTAL_INDICATOR = 'Education Unit Total' AND TRY_CAST(e.STUDENT_COUNT AS DOUBLE) > 0 GROUP BY d.ST ORDER BY median_enrollment DESC"
+
+**Robust Statistics:**
+CCD has data errors (enrollment of 0 at staffed schools, ratios over 100:1) that skew plain averages, so:
+- Prefer MEDIAN() for typical values, and leave out zeros and NULLs (e.g. "STUDENT_COUNT > 0")
+- When a mean is asked for, exclude outliers beyond 1.5×IQR of the quartiles:
+  "WITH v AS (SELECT d.ST, TRY_CAST(e.STUDENT_COUNT AS DOUBLE) AS x FROM directory d JOIN enrollment e ON d.NCESSCH = e.NCESSCH AND e.TOTAL_INDICATOR = 'Education Unit Total' WHERE TRY_CAST(e.STUDENT_COUNT AS DOUBLE) > 0), q AS (SELECT ST, quantile_cont(x, 0.25) AS q1, quantile_cont(x, 0.75) AS q3 FROM v GROUP BY ST) SELECT v.ST, AVG(x) AS mean_enrollment, COUNT(*) FILTER (WHERE x NOT BETWEEN q1 - 1.5 * (q3 - q1) AND q3 + 1.5 * (q3 - q1)) AS outliers FROM v JOIN q USING (ST) WHERE x BETWEEN q1 - 1.5 * (q3 - q1) AND q3 + 1.5 * (q3 - q1) GROUP BY v.ST"
+- Schools flagged in data_quality_flags (field enrollment or ratio) can also be left out
+- The query tool adds column statistics (median, mean excluding outliers, 10% trimmed mean) for numeric results; use them rather than averaging the rows yourself
+- Always say which method you used, e.g. "median enrollment" or "mean excluding 12 outliers beyond 1.5×IQR"
 
 **Important SQL Guidelines:**
 - JOIN on NCESSCH
@@ -698,7 +3781,13 @@ The query tool will return an error. Analyze the error, correct your SQL, and tr
 2. Analyze the summary results returned by the tool
 3. Provide a clear, natural language answer based on the summary
 4. If it's a search query, mention how many schools were found
-5. If it's an analysis, present key insights and aggregated data clearly`
+5. If it's an analysis, present key insights and aggregated data clearly
+
+**Charts:**
+When the final query returns aggregated numbers (at most 50 rows), end your response with a chart hint naming the columns to plot:
+` + "```chart\n" + `{"type": "bar", "x": "ST", "y": ["median_enrollment"], "title": "Median enrollment by state"}
+` + "```" + `
+Use "bar" to compare categories, "line" for values over years (x is the year column), and "none" for lists of schools or anything not worth charting. Plot at most 3 numeric columns; x and y must be column names from the query.`
 
 	// Variables to capture SQL and full results (outside agent context)
 	var capturedSQL string
@@ -715,8 +3804,8 @@ The query tool will return an error. Analyze the error, correct your SQL, and tr
 				return fantasy.NewTextErrorResponse("sql parameter is required"), nil
 			}
 
-			// Execute the query using the DB
-			rows, err := h.DB.ExecuteQuery(input.SQL)
+			// Execute the query read-only: it was written by the model, not the visitor
+			rows, err := h.DB.ExecuteReadQuery(ctx, input.SQL)
 			if err != nil {
 				// Return the error so agent can retry with corrected SQL
 				return fantasy.NewTextErrorResponse(fmt.Sprintf("SQL error: %v", err)), nil
@@ -744,87 +3833,12 @@ The query tool will return an error. Analyze the error, correct your SQL, and tr
 	// Create schema tool for database introspection
 	schemaTool := fantasy.NewAgentTool(
 		"schema",
-		"Get database schema information for all tables including user-imported tables",
+		"Get every table in the database, including user-imported tables, with each column's type and a description of what it holds",
 		func(ctx context.Context, input agent.SchemaInput, call fantasy.ToolCall) (fantasy.ToolResponse, error) {
-			// Get list of all tables using DuckDB's SHOW ALL TABLES
-			tablesQuery := "SHOW ALL TABLES"
-			tableRows, err := h.DB.ExecuteQuery(tablesQuery)
+			// Table and column comments come from the data dictionary
+			schemas, err := h.DB.DataDictionary("")
 			if err != nil {
-				return fantasy.NewTextErrorResponse(fmt.Sprintf("failed to get table list: %v", err)), nil
-			}
-
-			// Extract table names from SHOW ALL TABLES result
-			var tables []string
-			for _, row := range tableRows {
-				if tableName, ok := row["table_name"].(string); ok {
-					// Skip internal/system tables
-					if tableName != "fts_main_directory" && tableName != "fts_main_directory_docs" && tableName != "fts_main_directory_config" {
-						tables = append(tables, tableName)
-					}
-				} else if name, ok := row["name"].(string); ok {
-					// Some versions use 'name' instead of 'table_name'
-					if name != "fts_main_directory" && name != "fts_main_directory_docs" && name != "fts_main_directory_config" {
-						tables = append(tables, name)
-					}
-				}
-			}
-
-			type SchemaOutput struct {
-				TableName    string `json:"table_name"`
-				TableComment string `json:"table_comment,omitempty"`
-				Columns      []struct {
-					Name    string `json:"name"`
-					Type    string `json:"type"`
-					Comment string `json:"comment,omitempty"`
-				} `json:"columns"`
-			}
-			schemas := make([]SchemaOutput, 0, len(tables))
-
-			for _, tableName := range tables {
-				// Get column info from duckdb_columns() which includes comments
-				colQuery := fmt.Sprintf("SELECT column_name, data_type, comment FROM duckdb_columns() WHERE table_name = '%s' ORDER BY column_index", tableName)
-				rows, err := h.DB.ExecuteQuery(colQuery)
-				if err != nil {
-					continue
-				}
-
-				schema := SchemaOutput{
-					TableName: tableName,
-					Columns: make([]struct {
-						Name    string `json:"name"`
-						Type    string `json:"type"`
-						Comment string `json:"comment,omitempty"`
-					}, 0),
-				}
-
-				// Get table comment from duckdb_tables()
-				tableCommentQuery := fmt.Sprintf("SELECT comment FROM duckdb_tables() WHERE table_name = '%s'", tableName)
-				if commentRows, err := h.DB.ExecuteQuery(tableCommentQuery); err == nil && len(commentRows) > 0 {
-					if comment, ok := commentRows[0]["comment"].(string); ok && comment != "" {
-						schema.TableComment = comment
-					}
-				}
-
-				// Get column details and comments
-				for _, row := range rows {
-					name, _ := row["column_name"].(string)
-					colType, _ := row["data_type"].(string)
-					comment, _ := row["comment"].(string)
-
-					col := struct {
-						Name    string `json:"name"`
-						Type    string `json:"type"`
-						Comment string `json:"comment,omitempty"`
-					}{Name: name, Type: colType}
-
-					if comment != "" {
-						col.Comment = comment
-					}
-
-					schema.Columns = append(schema.Columns, col)
-				}
-
-				schemas = append(schemas, schema)
+				return fantasy.NewTextErrorResponse(fmt.Sprintf("failed to get schema: %v", err)), nil
 			}
 
 			jsonBytes, _ := json.MarshalIndent(schemas, "", "  ")
@@ -923,18 +3937,29 @@ func summarizeQueryResults(rows []map[string]interface{}, maxRows int) string {
 		summary.WriteString(fmt.Sprintf("\n... and %d more rows not shown ...\n", len(rows)-displayRows))
 	}
 
+	// Robust statistics over every row, for answers that summarize the numbers
+	if stats := summarizeNumericColumns(rows, columns); stats != "" {
+		summary.WriteString("\n" + stats)
+	}
+
 	return summary.String()
 }
 
 // ImportPage renders the data import page
 func (h *WebHandler) ImportPage(w http.ResponseWriter, r *http.Request) {
+	datasets, err := h.importedTableViews()
+	if err != nil {
+		log.Printf("Warning: failed to list imported tables: %v", err)
+	}
 	data := map[string]interface{}{
-		"Title": "Import Data",
+		"Title":     "Import Data",
+		"CSRFToken": csrfToken(w, r),
+		"Role":      requestRole(r),
+		"Datasets":  datasets,
 	}
 
 	if err := h.templates.ExecuteTemplate(w, "import.html", data); err != nil {
-		log.Printf("Template error: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		h.templateError(w, err)
 	}
 }
 
@@ -973,14 +3998,19 @@ func (h *WebHandler) ImportCSV(w http.ResponseWriter, r *http.Request) {
 		ProcessingStages: make([]ProcessingStage, 0),
 	}
 
-	// Stage 1: Parse multipart form
+	// Stage 1: Parse multipart form, refusing bodies over the size limit
 	stageStart := time.Now()
-	const maxFileSize = 100 * 1024 * 1024 // 100MB
-	if err := r.ParseMultipartForm(maxFileSize); err != nil {
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize+1<<20) // Leave room for the other fields
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
 		result.Error = fmt.Sprintf("Failed to parse form: %v", err)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			result.Error = fmt.Sprintf("The file is larger than the %s limit", formatFileSize(maxImportSize))
+		}
 		h.renderImportResult(w, result)
 		return
 	}
+	defer func() { _ = r.MultipartForm.RemoveAll() }()
 	result.ProcessingStages = append(result.ProcessingStages, ProcessingStage{
 		Stage:    "Parse Form",
 		Message:  "Form data parsed successfully",
@@ -996,6 +4026,17 @@ func (h *WebHandler) ImportCSV(w http.ResponseWriter, r *http.Request) {
 		h.renderImportResult(w, result)
 		return
 	}
+	if !importTableName.MatchString(tableName) {
+		result.Error = "Table name must start with a lowercase letter and contain only lowercase letters, numbers, and underscores (at most 63 characters)"
+		h.renderImportResult(w, result)
+		return
+	}
+	var existing int
+	if err := h.DB.conn.QueryRow(`SELECT count(*) FROM information_schema.tables WHERE table_name = $1`, tableName).Scan(&existing); err != nil || existing > 0 {
+		result.Error = fmt.Sprintf("A table named '%s' already exists; choose another name", tableName)
+		h.renderImportResult(w, result)
+		return
+	}
 
 	result.TableName = tableName
 
@@ -1009,61 +4050,82 @@ func (h *WebHandler) ImportCSV(w http.ResponseWriter, r *http.Request) {
 	}
 	defer file.Close()
 
-	// Detect file type from extension
-	fileExt := strings.ToLower(filepath.Ext(header.Filename))
+	// Detect file type from extension, then check the contents match it
+	filename := sanitizeUploadName(header.Filename)
+	fileExt := strings.ToLower(filepath.Ext(filename))
 	if fileExt != ".csv" && fileExt != ".xlsx" {
 		result.Error = "Unsupported file type. Please upload a .csv or .xlsx file"
 		h.renderImportResult(w, result)
 		return
 	}
+	head := make([]byte, uploadSniffLen)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		result.Error = fmt.Sprintf("Failed to read uploaded file: %v", err)
+		h.renderImportResult(w, result)
+		return
+	}
+	head = head[:n]
+	if err := sniffUpload(head, fileExt); err != nil {
+		result.Error = fmt.Sprintf("%s was rejected: %v", filename, err)
+		h.renderImportResult(w, result)
+		return
+	}
 
 	result.FileSize = formatFileSize(header.Size)
 	result.ProcessingStages = append(result.ProcessingStages, ProcessingStage{
 		Stage:    "File Upload",
-		Message:  fmt.Sprintf("Received file: %s (%s)", header.Filename, result.FileSize),
+		Message:  fmt.Sprintf("Received file: %s (%s)", filename, result.FileSize),
 		Duration: time.Since(stageStart).String(),
 	})
 
-	// Stage 3: Create user_data directory and save file
+	// Stage 3: Save the file to quarantine and check its row count there
 	stageStart = time.Now()
-	userDataDir := filepath.Join(h.DB.dataDir, "user_data")
-	if err := os.MkdirAll(userDataDir, 0755); err != nil {
-		result.Error = fmt.Sprintf("Failed to create user_data directory: %v", err)
+	quarantined, err := quarantineUpload(h.DB.dataDir, io.MultiReader(bytes.NewReader(head), file), fileExt)
+	if err != nil {
+		result.Error = err.Error()
 		h.renderImportResult(w, result)
 		return
 	}
+	defer os.Remove(quarantined) // Fails harmlessly once the file has been moved
 
-	// Save file with table name and appropriate extension
-	filePath := filepath.Join(userDataDir, fmt.Sprintf("%s%s", tableName, fileExt))
-	outFile, err := os.Create(filePath)
+	readFile := func(path string) string {
+		if fileExt == ".xlsx" {
+			return fmt.Sprintf("read_xlsx(%s)", quoteLiteral(path))
+		}
+		return fmt.Sprintf("read_csv(%s, auto_detect=true)", quoteLiteral(path))
+	}
+	maxRows := importMaxRowsFromEnv()
+	countRows, err := h.DB.ExecuteQuery(fmt.Sprintf("SELECT COUNT(*) AS count FROM %s", readFile(quarantined)))
 	if err != nil {
-		result.Error = fmt.Sprintf("Failed to create file: %v", err)
+		result.Error = fmt.Sprintf("Failed to read data file: %v", err)
 		h.renderImportResult(w, result)
 		return
 	}
-	defer outFile.Close()
+	if len(countRows) > 0 {
+		if count, ok := countRows[0]["count"].(int64); ok && count > maxRows {
+			result.Error = fmt.Sprintf("The file has %s rows; imports are limited to %s", formatNumber(count), formatNumber(maxRows))
+			h.renderImportResult(w, result)
+			return
+		}
+	}
 
-	if _, err := outFile.ReadFrom(file); err != nil {
-		result.Error = fmt.Sprintf("Failed to save file: %v", err)
+	filePath, err := promoteUpload(h.DB.dataDir, quarantined, tableName, fileExt)
+	if err != nil {
+		result.Error = err.Error()
 		h.renderImportResult(w, result)
 		return
 	}
 
 	result.ProcessingStages = append(result.ProcessingStages, ProcessingStage{
 		Stage:    "Save File",
-		Message:  fmt.Sprintf("File saved to %s", filePath),
+		Message:  fmt.Sprintf("File checked and saved to user_data/%s", filepath.Base(filePath)),
 		Duration: time.Since(stageStart).String(),
 	})
 
 	// Stage 4: Run SUMMARIZE to analyze the data
 	stageStart = time.Now()
-	// Determine which DuckDB function to use based on file extension
-	var readFunction string
-	if fileExt == ".xlsx" {
-		readFunction = fmt.Sprintf("read_xlsx('%s')", filePath)
-	} else {
-		readFunction = fmt.Sprintf("read_csv('%s', auto_detect=true)", filePath)
-	}
+	readFunction := readFile(filePath)
 	summarizeQuery := fmt.Sprintf("SUMMARIZE SELECT * FROM %s", readFunction)
 	summaryRows, err := h.DB.ExecuteQuery(summarizeQuery)
 	if err != nil {
@@ -1096,16 +4158,24 @@ func (h *WebHandler) ImportCSV(w http.ResponseWriter, r *http.Request) {
 
 	// Get row count
 	countQuery := fmt.Sprintf("SELECT COUNT(*) as count FROM %s", tableName)
-	countRows, err := h.DB.ExecuteQuery(countQuery)
+	countRows, err = h.DB.ExecuteQuery(countQuery)
 	if err == nil && len(countRows) > 0 {
 		if count, ok := countRows[0]["count"].(int64); ok {
 			result.RowCount = count
 		}
 	}
 
+	createMessage := fmt.Sprintf("Table '%s' created with %d rows", tableName, result.RowCount)
+	imported, err := h.DB.RecordImport(ImportedTable{Name: tableName, Description: description, SourceFile: filepath.Base(filePath), RowCount: result.RowCount})
+	if err != nil {
+		log.Printf("Warning: Failed to record import: %v", err)
+	} else if len(imported.SearchColumns) > 0 {
+		createMessage += fmt.Sprintf("; %s are searchable by school through %s (change this under Your datasets)",
+			strings.Join(imported.SearchColumns, ", "), imported.SchoolColumn)
+	}
 	result.ProcessingStages = append(result.ProcessingStages, ProcessingStage{
 		Stage:    "Create Table",
-		Message:  fmt.Sprintf("Table '%s' created with %d rows", tableName, result.RowCount),
+		Message:  createMessage,
 		Duration: time.Since(stageStart).String(),
 	})
 
@@ -1142,8 +4212,72 @@ func (h *WebHandler) ImportCSV(w http.ResponseWriter, r *http.Request) {
 // renderImportResult renders the import result partial
 func (h *WebHandler) renderImportResult(w http.ResponseWriter, result *ImportResult) {
 	if err := h.templates.ExecuteTemplate(w, "import_result.html", result); err != nil {
-		log.Printf("Template error: %v", err)
+		h.templateError(w, err)
+	}
+}
+
+// importedTableView is an imported table with the columns its settings form
+// offers
+type importedTableView struct {
+	ImportedTable
+	Columns     []string // Every column, for choosing the school column
+	TextColumns []string // Text columns other than the school column
+}
+
+// importedTableViews lists the imported tables for the Your datasets section
+// of the import page
+func (h *WebHandler) importedTableViews() ([]importedTableView, error) {
+	tables, err := h.DB.ImportedTables()
+	if err != nil {
+		return nil, err
+	}
+	views := make([]importedTableView, 0, len(tables))
+	for _, t := range tables {
+		view := importedTableView{ImportedTable: t}
+		if view.Columns, err = h.DB.tableColumns(t.Name, "", false); err != nil {
+			return nil, err
+		}
+		if view.TextColumns, err = h.DB.tableColumns(t.Name, t.SchoolColumn, true); err != nil {
+			return nil, err
+		}
+		views = append(views, view)
+	}
+	return views, nil
+}
+
+// UpdateImportedTable saves an imported table's label, school column, and
+// searchable columns from the Your datasets section of the import page
+func (h *WebHandler) UpdateImportedTable(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	name := chi.URLParam(r, "name")
+	t, err := h.DB.UpdateImportedTable(name, r.FormValue("label"), r.FormValue("school_column"), r.Form["search_column"])
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.NotFound(w, r)
+			return
+		}
+		// The form only offers the table's own columns, so this is a bad request
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	datasets, err := h.importedTableViews()
+	if err != nil {
+		log.Printf("Database error: %v", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	data := map[string]interface{}{
+		"Datasets": datasets,
+		"Role":     requestRole(r),
+		"Saved":    t.Name,
+	}
+	if err := h.templates.ExecuteTemplate(w, "imported_tables.html", data); err != nil {
+		h.templateError(w, err)
 	}
 }
 
@@ -1202,9 +4336,9 @@ func (h *WebHandler) generateAIDescriptions(ctx context.Context, tableName strin
 		return "", nil, fmt.Errorf("AI not available")
 	}
 
-	apiKey := os.Getenv("ANTHROPIC_API_KEY")
+	apiKey := anthropicAPIKey()
 	if apiKey == "" {
-		return "", nil, fmt.Errorf("ANTHROPIC_API_KEY not set")
+		return "", nil, ErrAINotConfigured
 	}
 
 	// Create Anthropic client
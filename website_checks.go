@@ -0,0 +1,485 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Outcome of a website liveness check
+const (
+	websiteOK         = "ok"
+	websiteDead       = "dead"       // Not found, gone, or the domain no longer resolves
+	websiteRedirected = "redirected" // Lands on another site or the homepage of the same one
+	websiteUnknown    = "unknown"    // Timeouts, server errors, and blocked requests; checked again sooner
+)
+
+// Website checker schedule, overridable with WEBSITE_CHECK_TTL and WEBSITE_CHECK_BATCH
+const (
+	defaultWebsiteCheckTTL   = 7 * 24 * time.Hour
+	websiteRecheckUnknown    = 24 * time.Hour
+	websiteSweepInterval     = time.Hour
+	defaultWebsiteCheckBatch = 50 // Schools checked per sweep; 0 turns sweeps off
+	websiteCheckPause        = time.Second
+	websiteCheckTimeout      = 15 * time.Second
+	websiteCheckQueueSize    = 100
+)
+
+// WebsiteCheck is the result of the latest liveness check of a school's website
+type WebsiteCheck struct {
+	NCESSCH    string
+	URL        string // The website checked, with a scheme
+	Status     string
+	StatusCode int    // 0 when no response was received
+	FinalURL   string // Where redirects ended up
+	Error      string
+	CheckedAt  time.Time
+}
+
+// Dead reports whether the website no longer exists
+func (c *WebsiteCheck) Dead() bool {
+	return c != nil && c.Status == websiteDead
+}
+
+// Redirected reports whether the website sends visitors somewhere else
+func (c *WebsiteCheck) Redirected() bool {
+	return c != nil && c.Status == websiteRedirected
+}
+
+// Problem describes why a dead website failed, for display
+func (c *WebsiteCheck) Problem() string {
+	if c.StatusCode != 0 {
+		return fmt.Sprintf("%d %s", c.StatusCode, http.StatusText(c.StatusCode))
+	}
+	return c.Error
+}
+
+// stale reports whether the check is missing, out of date, or of a website the
+// school no longer lists
+func (c *WebsiteCheck) stale(school *School, ttl time.Duration) bool {
+	if c == nil || c.URL != schoolWebsiteURL(school) {
+		return true
+	}
+	if c.Status == websiteUnknown && ttl > websiteRecheckUnknown {
+		ttl = websiteRecheckUnknown
+	}
+	return time.Since(c.CheckedAt) > ttl
+}
+
+// checkWebsite requests a website, following redirects, and classifies the result
+func checkWebsite(ctx context.Context, client *http.Client, rawURL string) *WebsiteCheck {
+	check := &WebsiteCheck{URL: rawURL, CheckedAt: time.Now()}
+
+	// Many school sites mishandle HEAD, so fall back to GET when it doesn't succeed
+	resp, err := requestWebsite(ctx, client, http.MethodHead, rawURL)
+	if err != nil || resp.StatusCode >= 400 {
+		if resp != nil {
+			resp.Body.Close()
+		}
+		resp, err = requestWebsite(ctx, client, http.MethodGet, rawURL)
+	}
+	if err != nil {
+		check.Error = err.Error()
+		check.Status = websiteUnknown
+		if websiteGone(err) {
+			check.Status = websiteDead
+		}
+		return check
+	}
+	resp.Body.Close()
+
+	check.StatusCode = resp.StatusCode
+	check.FinalURL = resp.Request.URL.String()
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		check.Status = websiteDead
+	case resp.StatusCode >= 400:
+		check.Status = websiteUnknown
+	case redirectedAway(rawURL, resp.Request.URL):
+		check.Status = websiteRedirected
+	default:
+		check.Status = websiteOK
+	}
+	return check
+}
+
+func requestWebsite(ctx context.Context, client *http.Client, method, rawURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "SchoolFinder link checker")
+	return client.Do(req)
+}
+
+// websiteGone reports whether a request failed because the site no longer
+// exists rather than being briefly unreachable
+func websiteGone(err error) bool {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsNotFound
+	}
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) && !urlErr.Timeout() && errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+	return false
+}
+
+// redirectedAway reports whether a request for from ended at another site, or at
+// the homepage of the same site when a specific page was asked for
+func redirectedAway(from string, to *url.URL) bool {
+	orig, err := url.Parse(from)
+	if err != nil {
+		return false
+	}
+	host := func(u *url.URL) string { return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.") }
+	if host(orig) != host(to) {
+		return true
+	}
+	return strings.Trim(orig.Path, "/") != "" && strings.Trim(to.Path, "/") == ""
+}
+
+// trackingParams are query parameters that only identify a campaign or click
+var trackingParams = map[string]bool{
+	"fbclid": true, "gclid": true, "dclid": true, "msclkid": true, "yclid": true,
+	"igshid": true, "mc_cid": true, "mc_eid": true, "_ga": true, "_gl": true,
+}
+
+// normalizeWebsiteURL puts a website address in the form links use: with a
+// scheme (https unless http is given), a lowercase host without a default port,
+// and no tracking parameters or fragment. It reports false, returning the
+// trimmed input, for values that aren't a web address.
+func normalizeWebsiteURL(raw string) (string, bool) {
+	raw = strings.TrimSpace(raw)
+	withScheme := raw
+	if lower := strings.ToLower(raw); !strings.HasPrefix(lower, "http://") && !strings.HasPrefix(lower, "https://") {
+		withScheme = "https://" + strings.TrimPrefix(raw, "//")
+	}
+
+	u, err := url.Parse(withScheme)
+	if err != nil || !strings.Contains(u.Hostname(), ".") || strings.ContainsAny(u.Host, " \t") {
+		return raw, false
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	if (u.Scheme == "https" && u.Port() == "443") || (u.Scheme == "http" && u.Port() == "80") {
+		u.Host = u.Hostname()
+	}
+	if u.RawQuery != "" {
+		q := u.Query()
+		tracked := false
+		for key := range q {
+			if k := strings.ToLower(key); trackingParams[k] || strings.HasPrefix(k, "utm_") {
+				q.Del(key)
+				tracked = true
+			}
+		}
+		// Re-encoding sorts the parameters, so only do it when one was removed
+		if tracked {
+			u.RawQuery = q.Encode()
+		}
+	}
+	u.Fragment, u.RawFragment = "", ""
+	return u.String(), true
+}
+
+// NormalizeDirectoryWebsites stores every directory website in normalized form,
+// returning how many changed. Already-normalized values are left alone, so after
+// the first run on a database this only touches newly imported rows.
+func NormalizeDirectoryWebsites(d *DB) (int, error) {
+	rows, err := d.conn.Query(`SELECT NCESSCH, WEBSITE FROM directory WHERE COALESCE(WEBSITE, '') <> ''`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read directory websites: %w", err)
+	}
+	var ids, urls []string
+	for rows.Next() {
+		var id, website string
+		if err := rows.Scan(&id, &website); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan directory website: %w", err)
+		}
+		if normalized, ok := normalizeWebsiteURL(website); ok && normalized != website {
+			ids = append(ids, id)
+			urls = append(urls, normalized)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	tx, err := d.conn.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Update in batches of VALUES rows; one statement per school is slow in DuckDB
+	const batch = 500
+	for start := 0; start < len(ids); start += batch {
+		end := min(start+batch, len(ids))
+		var values []string
+		var args []any
+		for i := start; i < end; i++ {
+			values = append(values, fmt.Sprintf("($%d, $%d)", len(args)+1, len(args)+2))
+			args = append(args, ids[i], urls[i])
+		}
+		_, err := tx.Exec(fmt.Sprintf(`
+			UPDATE directory SET WEBSITE = v.url
+			FROM (VALUES %s) v(ncessch, url)
+			WHERE directory.NCESSCH = v.ncessch
+		`, strings.Join(values, ", ")), args...)
+		if err != nil {
+			return 0, fmt.Errorf("failed to normalize directory websites: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit website normalization: %w", err)
+	}
+	d.schoolCache.Purge()
+	if logger != nil {
+		logger.Info("Normalized directory websites", "changed", len(ids))
+	}
+	return len(ids), nil
+}
+
+// upgradeWebsiteToHTTPS replaces a school's http website with the https address
+// once that was found to work
+func (d *DB) upgradeWebsiteToHTTPS(ncessch, insecure, secure string) error {
+	_, err := d.conn.Exec(`UPDATE directory SET WEBSITE = $3 WHERE NCESSCH = $1 AND WEBSITE = $2`, ncessch, insecure, secure)
+	if err != nil {
+		return fmt.Errorf("failed to upgrade website to https: %w", err)
+	}
+	d.schoolCache.Delete(ncessch)
+	return nil
+}
+
+// GetWebsiteCheck loads the latest check of a school's website
+func (d *DB) GetWebsiteCheck(ncessch string) (*WebsiteCheck, error) {
+	c := WebsiteCheck{NCESSCH: ncessch}
+	err := d.conn.QueryRow(`
+		SELECT url, status, status_code, final_url, error, checked_at
+		FROM website_checks
+		WHERE ncessch = $1
+	`, ncessch).Scan(&c.URL, &c.Status, &c.StatusCode, &c.FinalURL, &c.Error, &c.CheckedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to load website check: %w", err)
+	}
+	return &c, nil
+}
+
+// SaveWebsiteCheck records a website check, replacing the previous one
+func (d *DB) SaveWebsiteCheck(c *WebsiteCheck) error {
+	_, err := d.conn.Exec(`
+		INSERT OR REPLACE INTO website_checks (ncessch, url, status, status_code, final_url, error, checked_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, c.NCESSCH, c.URL, c.Status, c.StatusCode, c.FinalURL, c.Error, c.CheckedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save website check: %w", err)
+	}
+	// Checks run in the background and show on detail pages
+	d.BumpDataVersion()
+	return nil
+}
+
+// schoolsDueWebsiteCheck lists schools with a website that was never checked or
+// was last checked before the cutoff, never-checked first
+func (d *DB) schoolsDueWebsiteCheck(limit int, ttl time.Duration) ([]string, error) {
+	unknownTTL := websiteRecheckUnknown
+	if ttl < unknownTTL {
+		unknownTTL = ttl
+	}
+	now := time.Now()
+	rows, err := d.conn.Query(fmt.Sprintf(`
+		SELECT d.NCESSCH
+		FROM directory d
+		LEFT JOIN website_checks c ON c.ncessch = d.NCESSCH
+		WHERE %s AND COALESCE(d.WEBSITE, '') <> ''
+			AND (c.ncessch IS NULL
+				OR c.checked_at < CASE WHEN c.status = $1 THEN $2 ELSE $3 END)
+		ORDER BY c.checked_at NULLS FIRST, d.NCESSCH
+		LIMIT $4
+	`, notMergedCondition), websiteUnknown, now.Add(-unknownTTL), now.Add(-ttl), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list schools due a website check: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan school: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// websiteChecker checks school websites in the background: schools queued by
+// page views first, and every hour a batch of schools not checked recently
+type websiteChecker struct {
+	db     *DB
+	client *http.Client
+	ttl    time.Duration
+	batch  int
+
+	queue  chan string
+	mu     sync.Mutex
+	queued map[string]bool
+}
+
+// newWebsiteCheckerFromEnv creates a website checker configured by WEBSITE_CHECK_TTL
+// and WEBSITE_CHECK_BATCH
+func newWebsiteCheckerFromEnv(db *DB) *websiteChecker {
+	batch := defaultWebsiteCheckBatch
+	if raw := strings.TrimSpace(os.Getenv("WEBSITE_CHECK_BATCH")); raw != "" {
+		if n, err := strconv.Atoi(raw); err == nil && n >= 0 {
+			batch = n
+		} else {
+			fmt.Fprintf(os.Stderr, "Warning: ignoring invalid WEBSITE_CHECK_BATCH %q\n", raw)
+		}
+	}
+	return newWebsiteChecker(db, cacheTTLFromEnv("WEBSITE_CHECK_TTL", defaultWebsiteCheckTTL), batch)
+}
+
+func newWebsiteChecker(db *DB, ttl time.Duration, batch int) *websiteChecker {
+	return &websiteChecker{
+		db:     db,
+		client: &http.Client{Timeout: websiteCheckTimeout},
+		ttl:    ttl,
+		batch:  batch,
+		queue:  make(chan string, websiteCheckQueueSize),
+		queued: make(map[string]bool),
+	}
+}
+
+// Enqueue asks for a school's website to be checked soon. It never blocks; when
+// the queue is full the school waits for a later sweep.
+func (c *websiteChecker) Enqueue(ncessch string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.queued[ncessch] {
+		return
+	}
+	select {
+	case c.queue <- ncessch:
+		c.queued[ncessch] = true
+	default:
+	}
+}
+
+// Stale reports whether a school's website check should be redone
+func (c *websiteChecker) Stale(check *WebsiteCheck, school *School) bool {
+	return check.stale(school, c.ttl)
+}
+
+// Run checks queued schools and sweeps for unchecked ones until ctx is done
+func (c *websiteChecker) Run(ctx context.Context) {
+	ticker := time.NewTicker(websiteSweepInterval)
+	defer ticker.Stop()
+
+	c.sweep(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ncessch := <-c.queue:
+			c.mu.Lock()
+			delete(c.queued, ncessch)
+			c.mu.Unlock()
+			c.checkByID(ctx, ncessch)
+		case <-ticker.C:
+			c.sweep(ctx)
+		}
+	}
+}
+
+// sweep checks one batch of schools due a check, pausing between sites
+func (c *websiteChecker) sweep(ctx context.Context) {
+	if c.batch == 0 {
+		return
+	}
+	ids, err := c.db.schoolsDueWebsiteCheck(c.batch, c.ttl)
+	if err != nil {
+		if logger != nil {
+			logger.Warn("Website check sweep failed", "error", err)
+		}
+		return
+	}
+	for _, id := range ids {
+		c.checkByID(ctx, id)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(websiteCheckPause):
+		}
+	}
+}
+
+func (c *websiteChecker) checkByID(ctx context.Context, ncessch string) {
+	school, err := c.db.GetSchoolByID(ncessch)
+	if err != nil {
+		return
+	}
+	if _, err := c.Check(ctx, school); err != nil && logger != nil {
+		logger.Warn("Website check failed", "error", err, "ncessch", ncessch)
+	}
+}
+
+// Check checks a school's current website, including any user correction, and
+// saves the result
+func (c *websiteChecker) Check(ctx context.Context, school *School) (*WebsiteCheck, error) {
+	if !school.Website.Valid || school.Website.String == "" {
+		return nil, ErrNoWebsite
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, websiteCheckTimeout)
+	defer cancel()
+	website := schoolWebsiteURL(school)
+
+	// Prefer https when the site serves it. User corrections are left as entered.
+	var check *WebsiteCheck
+	if insecure, ok := strings.CutPrefix(website, "http://"); ok && !school.IsCorrected(correctionWebsite) {
+		if secure := checkWebsite(ctx, c.client, "https://"+insecure); secure.Status == websiteOK {
+			if err := c.db.upgradeWebsiteToHTTPS(school.NCESSCH, website, secure.URL); err != nil {
+				return nil, err
+			}
+			check = secure
+		}
+	}
+	if check == nil {
+		check = checkWebsite(ctx, c.client, website)
+	}
+	check.NCESSCH = school.NCESSCH
+	if err := c.db.SaveWebsiteCheck(check); err != nil {
+		return nil, err
+	}
+
+	if logger != nil && check.Status != websiteOK {
+		logger.Info("School website check", "ncessch", school.NCESSCH, "url", check.URL, "status", check.Status, "final_url", check.FinalURL, "error", check.Error)
+	}
+	return check, nil
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckWebsite(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+		}
+	})
+	mux.HandleFunc("/lincoln", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("/old-lincoln", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/no-head", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	// A port nothing listens on refuses connections
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	refused := "http://" + ln.Addr().String()
+	ln.Close()
+
+	tests := []struct {
+		url        string
+		wantStatus string
+		wantCode   int
+	}{
+		{server.URL + "/lincoln", websiteOK, 200},
+		{server.URL + "/no-head", websiteOK, 200},
+		{server.URL + "/closed", websiteDead, 404},
+		{server.URL + "/old-lincoln", websiteRedirected, 200},
+		{refused, websiteDead, 0},
+	}
+	for _, tt := range tests {
+		check := checkWebsite(context.Background(), server.Client(), tt.url)
+		if check.Status != tt.wantStatus || check.StatusCode != tt.wantCode {
+			t.Errorf("checkWebsite(%s) = %s %d (%s), want %s %d", tt.url, check.Status, check.StatusCode, check.Error, tt.wantStatus, tt.wantCode)
+		}
+	}
+
+	if check := checkWebsite(context.Background(), server.Client(), server.URL+"/old-lincoln"); check.FinalURL != server.URL+"/" {
+		t.Errorf("final URL = %q", check.FinalURL)
+	}
+}
+
+func TestWebsiteCheckStale(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	school, err := db.GetSchoolByID("360000100001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checker := newWebsiteChecker(db, defaultWebsiteCheckTTL, defaultWebsiteCheckBatch)
+
+	due, err := db.schoolsDueWebsiteCheck(100, defaultWebsiteCheckTTL)
+	if err != nil || len(due) == 0 || due[0] != "360000100001" {
+		t.Fatalf("schools due a check = %v, %v", due, err)
+	}
+
+	check := &WebsiteCheck{NCESSCH: school.NCESSCH, URL: "https://lincoln.sfusd.edu", Status: websiteOK, StatusCode: 200, CheckedAt: time.Now()}
+	if err := db.SaveWebsiteCheck(check); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := db.GetWebsiteCheck(school.NCESSCH)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checker.Stale(saved, school) {
+		t.Error("fresh check is stale")
+	}
+	if due, _ := db.schoolsDueWebsiteCheck(100, defaultWebsiteCheckTTL); len(due) > 0 && due[0] == "360000100001" {
+		t.Errorf("checked school still due: %v", due)
+	}
+
+	// Unknown results are retried sooner
+	saved.Status = websiteUnknown
+	saved.CheckedAt = time.Now().Add(-2 * websiteRecheckUnknown)
+	if !checker.Stale(saved, school) {
+		t.Error("day-old unknown check isn't stale")
+	}
+
+	// Correcting the website makes the check stale
+	if err := db.SaveSchoolCorrection(SchoolCorrection{NCESSCH: school.NCESSCH, Field: correctionWebsite, Value: "https://lincoln.example.org"}); err != nil {
+		t.Fatal(err)
+	}
+	school, _ = db.GetSchoolByID("360000100001")
+	if !checker.Stale(saved, school) {
+		t.Error("check of the old website isn't stale")
+	}
+}
+
+func TestWebDeadWebsite(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	router := NewRouter(ServerConfig{DB: db})
+
+	err := db.SaveWebsiteCheck(&WebsiteCheck{
+		NCESSCH:    "360000100001",
+		URL:        "https://lincoln.sfusd.edu",
+		Status:     websiteDead,
+		StatusCode: http.StatusNotFound,
+		CheckedAt:  time.Date(2026, 3, 4, 0, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/schools/360000100001", nil))
+	if body := rec.Body.String(); !strings.Contains(body, "This link appears to be dead (404 Not Found), checked Mar 4, 2026.") {
+		t.Errorf("detail page doesn't flag the dead link (status %d)", rec.Code)
+	}
+
+	// Extraction is pointed at a search for the new site
+	s := &AIScraperService{db: db}
+	school, _ := db.GetSchoolByID("360000100001")
+	if check := s.deadWebsite(school); check == nil || check.URL != "https://lincoln.sfusd.edu" {
+		t.Errorf("deadWebsite = %+v", check)
+	}
+}
+
+func TestNormalizeWebsiteURL(t *testing.T) {
+	tests := []struct {
+		raw    string
+		want   string
+		wantOK bool
+	}{
+		{"lincoln.sfusd.edu", "https://lincoln.sfusd.edu", true},
+		{"  WWW.Lincoln.SFUSD.edu/About  ", "https://www.lincoln.sfusd.edu/About", true},
+		{"HTTP://lincoln.sfusd.edu:80/", "http://lincoln.sfusd.edu/", true},
+		{"//lincoln.sfusd.edu", "https://lincoln.sfusd.edu", true},
+		{"https://lincoln.sfusd.edu/?utm_source=ccd&utm_medium=list&page=2&fbclid=x#staff", "https://lincoln.sfusd.edu/?page=2", true},
+		{"https://lincoln.sfusd.edu/index.php?id=5&b=1", "https://lincoln.sfusd.edu/index.php?id=5&b=1", true},
+		{"none", "none", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := normalizeWebsiteURL(tt.raw)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("normalizeWebsiteURL(%q) = %q, %v; want %q, %v", tt.raw, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestNormalizeDirectoryWebsites(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	if _, err := db.conn.Exec(`UPDATE directory SET WEBSITE = 'WWW.Lincoln.SFUSD.edu/?utm_source=ccd' WHERE NCESSCH = '360000100001'`); err != nil {
+		t.Fatal(err)
+	}
+	changed, err := NormalizeDirectoryWebsites(db)
+	if err != nil || changed != 1 {
+		t.Fatalf("NormalizeDirectoryWebsites = %d, %v", changed, err)
+	}
+	school, err := db.GetSchoolByID("360000100001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if school.Website.String != "https://www.lincoln.sfusd.edu/" {
+		t.Errorf("website = %q", school.Website.String)
+	}
+
+	if changed, _ := NormalizeDirectoryWebsites(db); changed != 0 {
+		t.Errorf("second run changed %d websites", changed)
+	}
+}
+
+func TestWebsiteHTTPSUpgrade(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "https://")
+
+	if _, err := db.conn.Exec(`UPDATE directory SET WEBSITE = $1 WHERE NCESSCH = '360000100001'`, "http://"+host); err != nil {
+		t.Fatal(err)
+	}
+	school, err := db.GetSchoolByID("360000100001")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checker := newWebsiteChecker(db, defaultWebsiteCheckTTL, 0)
+	checker.client = server.Client()
+	check, err := checker.Check(context.Background(), school)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if check.Status != websiteOK || check.URL != server.URL {
+		t.Errorf("check = %+v", check)
+	}
+
+	school, _ = db.GetSchoolByID("360000100001")
+	if school.WebsiteString() != server.URL {
+		t.Errorf("website after upgrade = %q", school.WebsiteString())
+	}
+	if checker.Stale(check, school) {
+		t.Error("check of the upgraded website is stale")
+	}
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// directoryFilePattern matches CCD school directory files for any school year
+const directoryFilePattern = "ccd_sch_029_*.csv"
+
+// Kinds of change between two school years
+const (
+	SchoolOpened    = "opened"
+	SchoolClosed    = "closed"
+	SchoolRenamed   = "renamed"
+	SchoolIDChanged = "id_changed"
+)
+
+// historySchool is one school's directory entry in a given school year
+type historySchool struct {
+	NCESSCH  string
+	Name     string
+	State    string
+	City     string
+	District string
+	Street   string
+	Zip      string
+}
+
+// SchoolYearChange is a school that opened, closed, was renamed, or was
+// assigned a new NCES ID between two school years
+type SchoolYearChange struct {
+	Kind            string
+	NCESSCH         string // ID in the later year; the earlier year's ID for closures
+	Name            string
+	State           string
+	City            string
+	FromYear        string
+	ToYear          string
+	PreviousNCESSCH string // Earlier ID, for ID changes
+	PreviousName    string // Earlier name, for renames and ID changes
+}
+
+// Badge is a short label for the school's card and page, e.g. "Opened 2023"
+func (c SchoolYearChange) Badge() string {
+	year := schoolYearStart(c.ToYear)
+	switch c.Kind {
+	case SchoolOpened:
+		return "Opened " + year
+	case SchoolClosed:
+		return "Closed " + year
+	case SchoolRenamed:
+		return "Renamed " + year
+	case SchoolIDChanged:
+		return "New NCES ID " + year
+	}
+	return ""
+}
+
+// Detail explains the change, e.g. `Formerly "Hoover Elementary" (360000100050)`
+func (c SchoolYearChange) Detail() string {
+	switch c.Kind {
+	case SchoolOpened:
+		return fmt.Sprintf("Not in the %s directory", c.FromYear)
+	case SchoolClosed:
+		return fmt.Sprintf("Not in the %s directory", c.ToYear)
+	case SchoolRenamed:
+		return fmt.Sprintf("Formerly %q", c.PreviousName)
+	case SchoolIDChanged:
+		return fmt.Sprintf("Formerly %q (%s)", c.PreviousName, c.PreviousNCESSCH)
+	}
+	return ""
+}
+
+// YearDiff is the comparison of the school directory between two school years
+type YearDiff struct {
+	FromYear  string
+	ToYear    string
+	FromCount int
+	ToCount   int
+	Opened    []SchoolYearChange
+	Closed    []SchoolYearChange
+	Renamed   []SchoolYearChange
+	IDChanged []SchoolYearChange
+}
+
+// Changes returns every change in the diff
+func (d *YearDiff) Changes() []SchoolYearChange {
+	var all []SchoolYearChange
+	for _, group := range [][]SchoolYearChange{d.Opened, d.Closed, d.Renamed, d.IDChanged} {
+		all = append(all, group...)
+	}
+	return all
+}
+
+// normalizeSchoolYear accepts "2022-23", "2022-2023", "2223", or "2022" and returns
+// the CCD SCHOOL_YEAR form, "2022-2023"
+func normalizeSchoolYear(year string) (string, error) {
+	year = strings.TrimSpace(year)
+	invalid := fmt.Errorf("invalid school year %q (use a form like 2022-23)", year)
+
+	start, end, hasDash := strings.Cut(year, "-")
+	if !hasDash && len(year) == 4 && year[:2] != "19" && year[:2] != "20" {
+		// Compact CCD file form, e.g. "2223"
+		start, end = "20"+year[:2], year[2:]
+	}
+	startYear, err := strconv.Atoi(start)
+	if err != nil || len(start) != 4 {
+		return "", invalid
+	}
+	if end != "" {
+		endYear, err := strconv.Atoi(end)
+		if err != nil || (len(end) != 2 && len(end) != 4) {
+			return "", invalid
+		}
+		if endYear%100 != (startYear+1)%100 {
+			return "", invalid
+		}
+	}
+	return fmt.Sprintf("%d-%d", startYear, startYear+1), nil
+}
+
+// schoolYearStart returns the first calendar year of a school year, e.g. "2023" for "2023-2024"
+func schoolYearStart(year string) string {
+	start, _, _ := strings.Cut(year, "-")
+	return start
+}
+
+// matchKey normalizes a school's name or address for matching across years
+func matchKey(parts ...string) string {
+	var b strings.Builder
+	for _, part := range parts {
+		for _, r := range strings.ToLower(part) {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				b.WriteRune(r)
+			}
+		}
+		b.WriteByte('|')
+	}
+	return b.String()
+}
+
+// zip5 returns the five-digit zip code
+func zip5(zip string) string {
+	if len(zip) > 5 {
+		return zip[:5]
+	}
+	return zip
+}
+
+// DiffSchoolYears compares the directory history for two school years. Schools missing
+// from one year are matched to the other year by street address and zip, then by name
+// and city; a match is reported as an ID change rather than a closure and an opening.
+func DiffSchoolYears(db *DB, from, to string) (*YearDiff, error) {
+	fromYear, err := normalizeSchoolYear(from)
+	if err != nil {
+		return nil, err
+	}
+	toYear, err := normalizeSchoolYear(to)
+	if err != nil {
+		return nil, err
+	}
+
+	before, err := db.historySchools(fromYear)
+	if err != nil {
+		return nil, err
+	}
+	after, err := db.historySchools(toYear)
+	if err != nil {
+		return nil, err
+	}
+	for year, schools := range map[string][]historySchool{fromYear: before, toYear: after} {
+		if len(schools) == 0 {
+			years, _ := db.SchoolYears()
+			return nil, fmt.Errorf("no directory data for %s (loaded years: %s); add its %s file to the data directory", year, strings.Join(years, ", "), directoryFilePattern)
+		}
+	}
+
+	diff := &YearDiff{FromYear: fromYear, ToYear: toYear, FromCount: len(before), ToCount: len(after)}
+
+	beforeByID := make(map[string]historySchool, len(before))
+	for _, s := range before {
+		beforeByID[s.NCESSCH] = s
+	}
+	afterByID := make(map[string]historySchool, len(after))
+	for _, s := range after {
+		afterByID[s.NCESSCH] = s
+	}
+
+	change := func(kind string, s historySchool) SchoolYearChange {
+		return SchoolYearChange{Kind: kind, NCESSCH: s.NCESSCH, Name: s.Name, State: s.State, City: s.City, FromYear: fromYear, ToYear: toYear}
+	}
+
+	// Schools that disappeared, indexed for matching against new IDs
+	var gone []historySchool
+	goneByAddress := make(map[string]int)
+	goneByName := make(map[string]int)
+	for _, s := range before {
+		if _, ok := afterByID[s.NCESSCH]; ok {
+			continue
+		}
+		goneByAddress[matchKey(s.State, s.Street, zip5(s.Zip))] = len(gone)
+		goneByName[matchKey(s.State, s.City, s.Name)] = len(gone)
+		gone = append(gone, s)
+	}
+	matched := make(map[int]bool)
+
+	for _, s := range after {
+		previous, existed := beforeByID[s.NCESSCH]
+		if existed {
+			if matchKey(previous.Name) != matchKey(s.Name) {
+				c := change(SchoolRenamed, s)
+				c.PreviousName = previous.Name
+				diff.Renamed = append(diff.Renamed, c)
+			}
+			continue
+		}
+
+		i, ok := goneByAddress[matchKey(s.State, s.Street, zip5(s.Zip))]
+		if !ok || s.Street == "" || matched[i] {
+			i, ok = goneByName[matchKey(s.State, s.City, s.Name)]
+		}
+		if ok && !matched[i] {
+			matched[i] = true
+			c := change(SchoolIDChanged, s)
+			c.PreviousNCESSCH, c.PreviousName = gone[i].NCESSCH, gone[i].Name
+			diff.IDChanged = append(diff.IDChanged, c)
+			continue
+		}
+
+		diff.Opened = append(diff.Opened, change(SchoolOpened, s))
+	}
+
+	for i, s := range gone {
+		if !matched[i] {
+			diff.Closed = append(diff.Closed, change(SchoolClosed, s))
+		}
+	}
+
+	for _, group := range [][]SchoolYearChange{diff.Opened, diff.Closed, diff.Renamed, diff.IDChanged} {
+		sort.Slice(group, func(i, j int) bool {
+			if group[i].State != group[j].State {
+				return group[i].State < group[j].State
+			}
+			return group[i].Name < group[j].Name
+		})
+	}
+
+	return diff, nil
+}
+
+// SyncDirectoryHistory loads any new directory files in the data directory and, when
+// at least two school years are loaded, refreshes the badges for the latest year.
+// It returns the number of new files loaded.
+func SyncDirectoryHistory(db *DB) (int, error) {
+	paths, err := filepath.Glob(filepath.Join(db.dataDir, directoryFilePattern))
+	if err != nil {
+		return 0, fmt.Errorf("failed to list directory files: %w", err)
+	}
+
+	loaded := 0
+	for _, path := range paths {
+		isNew, err := db.loadDirectoryHistoryFile(path)
+		if err != nil {
+			return loaded, err
+		}
+		if isNew {
+			loaded++
+		}
+	}
+	if loaded == 0 {
+		return 0, nil
+	}
+
+	years, err := db.SchoolYears()
+	if err != nil {
+		return loaded, err
+	}
+	// Badges describe the current directory, so a newer year loaded alongside it doesn't count
+	years = slices.DeleteFunc(years, func(year string) bool { return year > currentSchoolYear() })
+	if len(years) < 2 {
+		return loaded, nil
+	}
+
+	diff, err := DiffSchoolYears(db, years[len(years)-2], years[len(years)-1])
+	if err != nil {
+		return loaded, err
+	}
+	if err := db.SaveSchoolYearChanges(diff.Changes()); err != nil {
+		return loaded, err
+	}
+
+	if logger != nil {
+		logger.Info("School year changes updated", "from", diff.FromYear, "to", diff.ToYear,
+			"opened", len(diff.Opened), "closed", len(diff.Closed), "renamed", len(diff.Renamed), "id_changed", len(diff.IDChanged))
+	}
+	return loaded, nil
+}
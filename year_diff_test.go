@@ -0,0 +1,112 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// priorYearDirectory is a 2022-23 directory for the test data: Washington was renamed,
+// Jefferson hadn't opened, Roosevelt had a different ID, and Hoover later closed
+const priorYearDirectory = `NCESSCH,SCH_NAME,ST,STATENAME,MCITY,LEA_NAME,LEAID,SCHOOL_YEAR,LEVEL,PHONE,WEBSITE,MZIP,MSTREET1,MSTREET2,MSTREET3,SCH_TYPE_TEXT,GSLO,GSHI,CHARTER_TEXT
+360000100001,Lincoln Elementary School,CA,California,San Francisco,San Francisco Unified School District,0600000,2022-2023,Elementary,,,94102,123 Lincoln St,,,Regular school,PK,05,Not applicable
+360000100002,Washington Senior High School,CA,California,Los Angeles,Los Angeles Unified School District,0600001,2022-2023,High,,,90001,456 Washington Ave,,,Regular school,09,12,Not applicable
+360000100099,Roosevelt Charter School,NY,New York,New York City,New York City Department Of Education,3600000,2022-2023,High,,,10001-1234,321 Roosevelt Blvd.,,,Charter school,09,12,Yes
+360000100005,Madison K-8 School,FL,Florida,Miami,Miami-Dade County Public Schools,1200000,2022-2023,Other,,,33101,654 Madison Pkwy,,,Regular school,KG,08,Not applicable
+360000100050,Hoover Elementary School,FL,Florida,Miami,Miami-Dade County Public Schools,1200000,2022-2023,Elementary,,,33102,1 Hoover Way,,,Regular school,KG,05,Not applicable
+`
+
+// TestNormalizeSchoolYear tests accepted school year forms
+func TestNormalizeSchoolYear(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"2022-23", "2022-2023"},
+		{"2022-2023", "2022-2023"},
+		{"2223", "2022-2023"},
+		{"2022", "2022-2023"},
+		{"1999-00", "1999-2000"},
+	}
+	for _, tc := range testCases {
+		got, err := normalizeSchoolYear(tc.input)
+		if err != nil || got != tc.expected {
+			t.Errorf("normalizeSchoolYear(%q) = %q, %v; want %q", tc.input, got, err, tc.expected)
+		}
+	}
+
+	for _, input := range []string{"", "2022-25", "twenty", "22-23"} {
+		if _, err := normalizeSchoolYear(input); err == nil {
+			t.Errorf("Expected error for %q", input)
+		}
+	}
+}
+
+// TestDiffSchoolYears tests opened, closed, renamed, and ID-changed detection and badges
+func TestDiffSchoolYears(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+
+	// Only one year loaded: nothing to compare
+	if _, err := DiffSchoolYears(db, "2022-23", "2023-24"); err == nil {
+		t.Fatal("Expected error when a year isn't loaded")
+	}
+
+	path := filepath.Join(db.dataDir, "ccd_sch_029_2223_w_1a_083023.csv")
+	if err := os.WriteFile(path, []byte(priorYearDirectory), 0644); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := SyncDirectoryHistory(db)
+	if err != nil || loaded != 1 {
+		t.Fatalf("Expected 1 new file loaded, got %d (err %v)", loaded, err)
+	}
+	if loaded, _ := SyncDirectoryHistory(db); loaded != 0 {
+		t.Errorf("Expected file to load only once, got %d", loaded)
+	}
+
+	diff, err := DiffSchoolYears(db, "2022-23", "2023-24")
+	if err != nil {
+		t.Fatalf("DiffSchoolYears failed: %v", err)
+	}
+	if diff.FromCount != 5 || diff.ToCount != 5 {
+		t.Errorf("Expected 5 schools in each year, got %d and %d", diff.FromCount, diff.ToCount)
+	}
+
+	check := func(kind string, changes []SchoolYearChange, expected ...string) {
+		t.Helper()
+		if len(changes) != len(expected) {
+			t.Fatalf("Expected %s %v, got %+v", kind, expected, changes)
+		}
+		for i, id := range expected {
+			if changes[i].NCESSCH != id {
+				t.Errorf("Expected %s %s, got %s", kind, id, changes[i].NCESSCH)
+			}
+		}
+	}
+	check("opened", diff.Opened, "360000100003")
+	check("closed", diff.Closed, "360000100050")
+	check("renamed", diff.Renamed, "360000100002")
+	check("id_changed", diff.IDChanged, "360000100004")
+
+	if diff.IDChanged[0].PreviousNCESSCH != "360000100099" {
+		t.Errorf("Expected previous ID 360000100099, got %s", diff.IDChanged[0].PreviousNCESSCH)
+	}
+	if diff.Renamed[0].PreviousName != "Washington Senior High School" {
+		t.Errorf("Unexpected previous name: %s", diff.Renamed[0].PreviousName)
+	}
+
+	// Badges for the latest year were stored by the sync; closures have no badge
+	changes, err := db.SchoolYearChanges()
+	if err != nil {
+		t.Fatalf("Failed to load changes: %v", err)
+	}
+	if len(changes) != 3 {
+		t.Errorf("Expected 3 badged schools, got %d", len(changes))
+	}
+	if badge := changes["360000100003"].Badge(); badge != "Opened 2023" {
+		t.Errorf("Expected Opened 2023, got %q", badge)
+	}
+	if _, ok := changes["360000100050"]; ok {
+		t.Error("Expected no badge for a closed school")
+	}
+}